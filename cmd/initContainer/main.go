@@ -7,6 +7,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -254,6 +255,18 @@ func removePolicyReport(client *client.Client, kind string) error {
 			fmt.Sprintf("polr-ns-%s", ns.GetName()),
 		}
 
+		// large reports are split into chunks named polr-ns-<namespace name>-<index>
+		polrs, err := client.ListResource("", kind, ns.GetName(), nil)
+		if err != nil {
+			logger.Error(err, "failed to list policy reports", "namespace", ns.GetName())
+		} else {
+			for _, polr := range polrs.Items {
+				if polr.GetName() != fmt.Sprintf("polr-ns-%s", ns.GetName()) && strings.HasPrefix(polr.GetName(), "polr-ns-") {
+					reportNames = append(reportNames, polr.GetName())
+				}
+			}
+		}
+
 		var wg sync.WaitGroup
 		wg.Add(len(reportNames))
 		for _, reportName := range reportNames {
@@ -9,25 +9,42 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/metadata"
+	rest "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	log "sigs.k8s.io/controller-runtime/pkg/log"
 
+	"github.com/kyverno/kyverno/pkg/admissionpolicy"
+	"github.com/kyverno/kyverno/pkg/auth"
+	"github.com/kyverno/kyverno/pkg/background"
 	backwardcompatibility "github.com/kyverno/kyverno/pkg/backward_compatibility"
+	"github.com/kyverno/kyverno/pkg/canary"
+	"github.com/kyverno/kyverno/pkg/cleanup"
+	"github.com/kyverno/kyverno/pkg/cleanuppolicy"
 	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
 	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/common"
 	"github.com/kyverno/kyverno/pkg/config"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/engine"
+	enginecommon "github.com/kyverno/kyverno/pkg/engine/common"
+	"github.com/kyverno/kyverno/pkg/engine/deprecations"
+	"github.com/kyverno/kyverno/pkg/engine/jmespath"
+	"github.com/kyverno/kyverno/pkg/evaluation"
 	event "github.com/kyverno/kyverno/pkg/event"
 	"github.com/kyverno/kyverno/pkg/generate"
 	generatecleanup "github.com/kyverno/kyverno/pkg/generate/cleanup"
 	"github.com/kyverno/kyverno/pkg/leaderelection"
 	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/metrics/internalqueue"
 	"github.com/kyverno/kyverno/pkg/openapi"
 	"github.com/kyverno/kyverno/pkg/policy"
 	"github.com/kyverno/kyverno/pkg/policycache"
@@ -44,23 +61,60 @@ import (
 
 const resyncPeriod = 15 * time.Minute
 
+// leaderShutdownTimeout is the time the controllers of the leader are given to stop, e.g. for the report
+// generator to checkpoint the pending results, once the leadership is lost or Kyverno shuts down
+const leaderShutdownTimeout = 10 * time.Second
+
 var (
 	//TODO: this has been added to backward support command line arguments
 	// will be removed in future and the configuration will be set only via configmaps
-	filterK8sResources           string
-	kubeconfig                   string
-	serverIP                     string
-	excludeGroupRole             string
-	excludeUsername              string
-	profilePort                  string
-	metricsPort                  string
-	webhookTimeout               int
-	genWorkers                   int
-	profile                      bool
-	disableMetricsExport         bool
-	policyControllerResyncPeriod time.Duration
-	imagePullSecrets             string
-	setupLog                     = log.Log.WithName("setup")
+	filterK8sResources          string
+	kubeconfig                  string
+	serverIP                    string
+	devMode                     bool
+	excludeGroupRole            string
+	excludeUsername             string
+	profilePort                 string
+	metricsPort                 string
+	webhookTimeout              int
+	genWorkers                  int
+	profile                     bool
+	disableMetricsExport        bool
+	backgroundScanOptions       policy.ScanOptions
+	backgroundScanWorkers       int
+	imagePullSecrets            string
+	registryOptions             cosign.RegistryOptions
+	registryNoProxy             string
+	insecureRegistries          string
+	maxReportResults            int
+	passResultRetention         time.Duration
+	reportCheckpointInterval    time.Duration
+	requireAdmissionPermissions bool
+	policyCacheSnapshot         string
+	queueAgeWarningThreshold    time.Duration
+	cleanupMode                 bool
+	cleanupDryRun               bool
+	cleanupReports              bool
+	deregisterOnShutdown        bool
+	complexityLimits            = enginecommon.DefaultComplexityLimits
+	generateRetryPolicy         = generate.DefaultRetryPolicy
+	evaluationAddr              string
+	evaluationRateLimit         float64
+	evaluationBurst             int
+	canaryMode                  bool
+	canaryInterval              time.Duration
+	canaryTimeout               time.Duration
+	enablePolicyExceptions      bool
+	generateAdmissionPolicies   bool
+	traceAddr                   string
+	traceRequests               int
+	traceAllowRemote            bool
+	apiCallCacheResources       string
+	apiCallCacheConfig          = resourcecache.APICallCacheConfig{MaxObjects: 10000}
+	admissionDumpConfig         = webhooks.AdmissionDumpConfig{RateLimit: 1, Burst: 5, MaxFiles: 1000}
+	admissionDumpNamespaces     string
+	admissionDumpKinds          string
+	setupLog                    = log.Log.WithName("setup")
 )
 
 func main() {
@@ -72,13 +126,57 @@ func main() {
 	flag.IntVar(&webhookTimeout, "webhooktimeout", 3, "Timeout for webhook configurations")
 	flag.IntVar(&genWorkers, "gen-workers", 10, "Workers for generate controller")
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
-	flag.StringVar(&serverIP, "serverIP", "", "IP address where Kyverno controller runs. Only required if out-of-cluster.")
+	flag.StringVar(&serverIP, "serverIP", "", "IP address and port where Kyverno controller runs, e.g. 192.168.1.10:9443. Only required if out-of-cluster, requires --devMode.")
+	flag.BoolVar(&devMode, "devMode", false, "Set this flag to 'true' to run Kyverno out-of-cluster with --serverIP, the webhooks are registered with the URL of the local process. Never set this flag in a cluster.")
 	flag.BoolVar(&profile, "profile", false, "Set this flag to 'true', to enable profiling.")
 	flag.StringVar(&profilePort, "profile-port", "6060", "Enable profiling at given port, defaults to 6060.")
 	flag.BoolVar(&disableMetricsExport, "disable-metrics", false, "Set this flag to 'true', to enable exposing the metrics.")
 	flag.StringVar(&metricsPort, "metrics-port", "8000", "Expose prometheus metrics at the given port, default to 8000.")
-	flag.DurationVar(&policyControllerResyncPeriod, "background-scan", time.Hour, "Perform background scan every given interval, e.g., 30s, 15m, 1h.")
+	flag.DurationVar(&backgroundScanOptions.Interval, "background-scan", time.Hour, "Deprecated, use --backgroundScanInterval instead.")
+	flag.DurationVar(&backgroundScanOptions.Interval, "backgroundScanInterval", time.Hour, "Perform background scan every given interval, e.g., 30s, 15m, 1h. The policies with a spec.schedule are scanned on their own schedule.")
+	flag.IntVar(&backgroundScanWorkers, "backgroundScanWorkers", 2, "Number of policies scanned concurrently in the background.")
+	flag.IntVar(&backgroundScanOptions.ChunkSize, "backgroundScanChunkSize", 0, "Maximum number of resources of a namespace evaluated and reported at once in the background scans. Set to 0 to evaluate all the resources of a namespace at once.")
+	flag.Float64Var(&backgroundScanOptions.Jitter, "backgroundScanJitter", 0, "Maximum fraction of the scan interval the background scan of each policy is randomly delayed by, e.g. 0.1, to spread the scans over time. Set to 0 to scan all the policies at once.")
 	flag.StringVar(&imagePullSecrets, "imagePullSecrets", "", "Secret resource names for image registry access credentials")
+	flag.StringVar(&registryOptions.CABundle, "registry-ca-bundle", "", "Path of a PEM encoded CA bundle trusted, in addition to the system roots, by the image registry API calls of the verifyImages rules, e.g. for the registry mirror of an air-gapped cluster. The bundle is also trusted by the Rekor API calls.")
+	flag.StringVar(&registryOptions.Proxy, "registry-proxy", "", "URL of the HTTP(S) proxy of the image registry and Rekor API calls of the verifyImages rules, e.g. http://proxy.corp:3128. The proxy of the HTTPS_PROXY environment variable is used if empty.")
+	flag.StringVar(&registryNoProxy, "registry-no-proxy", "", "Comma-separated hosts accessed without --registry-proxy, e.g. \"registry.local,.corp\". A leading dot matches the subdomains only.")
+	flag.StringVar(&insecureRegistries, "insecure-registries", "", "Comma-separated registries accessed over HTTP or without verifying their certificates by the verifyImages rules, e.g. \"registry.local:5000\".")
+	flag.IntVar(&maxReportResults, "max-report-results", 1000, "Maximum number of results in a single policy report, larger reports are split into multiple reports. Set to 0 to disable splitting.")
+	flag.DurationVar(&passResultRetention, "pass-result-retention", 0, "Remove pass results older than the given period from policy reports, e.g., 24h. Set to 0 to keep all pass results.")
+	flag.DurationVar(&reportCheckpointInterval, "report-checkpoint-interval", 30*time.Second, "Interval of the checkpoints of the policy report results aggregated in memory by the leader, a crash of the leader loses at most the results of one interval, e.g., 30s. Set to 0 to aggregate all results through report change requests.")
+	flag.BoolVar(&requireAdmissionPermissions, "require-admission-permissions", false, "Set this flag to 'true' to refuse to start when permissions required to serve admission requests are missing.")
+	flag.StringVar(&policyCacheSnapshot, "policy-cache-snapshot", "", "Path of the file the policy cache is saved to on shutdown and restored from on startup, e.g. on an emptyDir volume. The policy cache is not saved if empty.")
+	flag.DurationVar(&queueAgeWarningThreshold, "queue-age-warning-threshold", 5*time.Minute, "Log a warning when the oldest item of the report, update request or event queue is older than the given period, e.g., 5m. Set to 0 to disable the warning.")
+	flag.BoolVar(&cleanupMode, "cleanup", false, "Set this flag to 'true' to remove the Kyverno webhook configurations and the finalizers of the generate and report change requests, and exit. Run it before uninstalling Kyverno, e.g. in a pre-delete hook.")
+	flag.BoolVar(&cleanupDryRun, "cleanupDryRun", false, "Set this flag to 'true' with --cleanup to list the changes without applying them.")
+	flag.BoolVar(&cleanupReports, "cleanupReports", false, "Set this flag to 'true' with --cleanup to also delete the policy reports generated by Kyverno.")
+	flag.BoolVar(&deregisterOnShutdown, "deregisterOnShutdown", false, "Set this flag to 'true' to remove the webhook configurations when the last available replica shuts down, the webhooks are registered again on startup.")
+	flag.IntVar(&complexityLimits.MaxDepth, "max-resource-depth", enginecommon.DefaultComplexityLimits.MaxDepth, "Maximum nesting of maps and arrays in a resource evaluated by the validate patterns and the mutate overlays. Set to 0 to disable the limit.")
+	flag.IntVar(&complexityLimits.MaxElements, "max-resource-elements", enginecommon.DefaultComplexityLimits.MaxElements, "Maximum number of elements of a single map or array in a resource evaluated by the validate patterns and the mutate overlays. Set to 0 to disable the limit.")
+	flag.IntVar(&complexityLimits.MaxNodes, "max-resource-nodes", enginecommon.DefaultComplexityLimits.MaxNodes, "Maximum number of nodes of a resource evaluated by a validate pattern or a mutate overlay. Set to 0 to disable the limit.")
+	flag.StringVar(&evaluationAddr, "evaluation-addr", "", "Address of the gRPC evaluation service evaluating resources against the policies for callers outside of the cluster, e.g. :9444. The clients authenticate with certificates signed by the Kyverno root CA. The service is disabled if empty.")
+	flag.Float64Var(&evaluationRateLimit, "evaluation-rate-limit", 10, "Maximum number of evaluation requests per second of each caller of the evaluation service. Set to 0 to disable the limit.")
+	flag.IntVar(&evaluationBurst, "evaluation-burst", 20, "Maximum burst of evaluation requests of each caller of the evaluation service.")
+	flag.BoolVar(&canaryMode, "canary", false, "Set this flag to 'true' to only run the admission canary, in a separate lightweight deployment. The canary periodically submits a dry-run create of a policy matched by the Kyverno webhooks, exports the kyverno_canary_admission_success and latency metrics, and writes the kyverno-canary-status ConfigMap.")
+	flag.DurationVar(&canaryInterval, "canary-interval", time.Minute, "Interval of the canary admission requests, e.g., 30s, 1m.")
+	flag.DurationVar(&canaryTimeout, "canary-timeout", 10*time.Second, "Timeout of the canary admission requests, e.g., 10s.")
+	flag.BoolVar(&enablePolicyExceptions, "enable-policy-exceptions", false, "Set this flag to 'true' to skip the validate and verifyImages rules exempted by the PolicyException resources. The policy exceptions of the Kyverno namespace apply to all the namespaces.")
+	flag.BoolVar(&generateAdmissionPolicies, "generate-validating-admission-policies", false, "Set this flag to 'true' to generate the ValidatingAdmissionPolicies and their bindings of the ClusterPolicies annotated with 'kyverno.io/generate-validating-admission-policies: \"true\"', so that their CEL validate rules are enforced by the API server.")
+	flag.BoolVar(&complexityLimits.Enforce, "enforce-resource-limits", enginecommon.DefaultComplexityLimits.Enforce, "Set this flag to 'false' to only log the resources exceeding the resource limits instead of failing the rules with \"resource too complex for rule evaluation\".")
+	flag.IntVar(&generateRetryPolicy.MaxRetries, "generate-max-retries", generate.DefaultRetryPolicy.MaxRetries, "Maximum number of failed attempts to process a generate request, the request is then Failed and is processed again when the policy or the trigger resource changes.")
+	flag.DurationVar(&generateRetryPolicy.MaxDelay, "generate-retry-max-delay", generate.DefaultRetryPolicy.MaxDelay, "Maximum delay between two attempts to process a failed generate request, the delay doubles from 1s with each retry, e.g. 5m.")
+	flag.StringVar(&traceAddr, "trace-addr", "", "Loopback address of the debug endpoint serving the admission request traces at /debug/traces, e.g. localhost:6061. The requests are not traced if empty.")
+	flag.BoolVar(&traceAllowRemote, "trace-allow-remote", false, "Set this flag to 'true' to allow a non-loopback --trace-addr, the trace endpoint is not authenticated.")
+	flag.IntVar(&traceRequests, "trace-requests", 100, "Number of admission request traces kept in memory by the debug endpoint.")
+	flag.StringVar(&apiCallCacheResources, "api-call-cache-resources", "", "Comma-separated resources cached by informers for the apiCall context entries, e.g. \"v1/services,networking.k8s.io/v1/ingresses\". The API calls of the cached resources, with an optional labelSelector query parameter, are served from the cache once it is synced, the other API calls are served by the API server.")
+	flag.IntVar(&apiCallCacheConfig.MaxObjects, "api-call-cache-max-objects", apiCallCacheConfig.MaxObjects, "Maximum number of objects cached for each resource of --api-call-cache-resources. A resource exceeding it is no longer cached and its API calls are served by the API server. Set to 0 to disable the limit.")
+	flag.StringVar(&admissionDumpConfig.Dir, "admission-dump-dir", "", "Directory the sanitized admission reviews, the request and the response, are written to for debugging, e.g. an emptyDir volume. The data values of the Secrets and of the sensitive kinds are masked. The admission reviews are replayed offline with 'kyverno apply --admission-review'. The admission reviews are not dumped if empty.")
+	flag.StringVar(&admissionDumpNamespaces, "admission-dump-namespaces", "", "Comma-separated namespaces of the dumped admission requests, wildcards ('*' and '?') are allowed. All the admission requests are dumped if empty, the requests of the cluster-wide resources only if empty.")
+	flag.StringVar(&admissionDumpKinds, "admission-dump-kinds", "", "Comma-separated kinds of the dumped admission requests, e.g. \"Deployment,Pod\", wildcards ('*' and '?') are allowed. All the kinds are dumped if empty.")
+	flag.Float64Var(&admissionDumpConfig.RateLimit, "admission-dump-rate-limit", admissionDumpConfig.RateLimit, "Maximum number of admission reviews dumped per second, the admission reviews exceeding it are not dumped. Set to 0 to disable the limit.")
+	flag.IntVar(&admissionDumpConfig.Burst, "admission-dump-burst", admissionDumpConfig.Burst, "Maximum burst of dumped admission reviews.")
+	flag.IntVar(&admissionDumpConfig.MaxFiles, "admission-dump-max-files", admissionDumpConfig.MaxFiles, "Maximum number of admission reviews kept in --admission-dump-dir, the oldest are removed. Set to 0 to disable the limit.")
 
 	if err := flag.Set("v", "2"); err != nil {
 		setupLog.Error(err, "failed to set log level")
@@ -87,6 +185,20 @@ func main() {
 
 	flag.Parse()
 
+	if err := webhookconfig.CheckDevMode(serverIP, devMode); err != nil {
+		setupLog.Error(err, "refusing to register the webhooks out-of-cluster")
+		os.Exit(1)
+	}
+
+	if err := backgroundScanOptions.Validate(); err != nil {
+		setupLog.Error(err, "invalid background scan options")
+		os.Exit(1)
+	}
+
+	internalqueue.SetAgeWarningThreshold(queueAgeWarningThreshold)
+	enginecommon.SetComplexityLimits(complexityLimits)
+	generate.SetRetryPolicy(generateRetryPolicy)
+
 	version.PrintVersionInfo(log.Log)
 	cleanUp := make(chan struct{})
 	stopCh := signal.SetupSignalHandler()
@@ -115,7 +227,7 @@ func main() {
 	if !disableMetricsExport {
 		promConfig = metrics.NewPromConfig()
 		metricsServerMux = http.NewServeMux()
-		metricsServerMux.Handle("/metrics", promhttp.HandlerFor(promConfig.MetricsRegistry, promhttp.HandlerOpts{Timeout: 10 * time.Second}))
+		metricsServerMux.Handle("/metrics", promhttp.HandlerFor(promConfig.MetricsRegistry, promhttp.HandlerOpts{Timeout: 10 * time.Second, EnableOpenMetrics: true}))
 		metricsAddr := ":" + metricsPort
 		go func() {
 			setupLog.Info("enabling metrics service", "address", metricsAddr)
@@ -126,6 +238,26 @@ func main() {
 		}()
 	}
 
+	var traces *webhooks.TraceStore
+	if traceAddr != "" {
+		if err := webhooks.CheckTraceAddr(traceAddr, traceAllowRemote); err != nil {
+			setupLog.Error(err, "failed to enable the trace endpoint")
+			os.Exit(1)
+		}
+
+		traces = webhooks.NewTraceStore(traceRequests)
+		traceServerMux := http.NewServeMux()
+		traceServerMux.Handle(webhooks.TracesServicePath, traces)
+		traceServerMux.Handle(webhooks.TracesServicePath+"/", traces)
+		go func() {
+			setupLog.Info("enabling admission request tracing", "address", traceAddr)
+			if err := http.ListenAndServe(traceAddr, traceServerMux); err != nil {
+				setupLog.Error(err, "failed to enable the trace endpoint", "address", traceAddr)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// KYVERNO CRD CLIENT
 	// access CRD resources
 	//		- ClusterPolicy, Policy
@@ -145,6 +277,34 @@ func main() {
 		os.Exit(1)
 	}
 
+	// CLEANUP
+	// - one-shot removal of the resources left behind on uninstall
+	if cleanupMode {
+		actions, err := cleanup.Run(client, cleanup.Options{DryRun: cleanupDryRun, DeleteReports: cleanupReports}, log.Log)
+		for _, action := range actions {
+			if cleanupDryRun {
+				fmt.Println("(dry run) " + action.String())
+			} else {
+				fmt.Println(action.String())
+			}
+		}
+
+		if err != nil {
+			setupLog.Error(err, "cleanup failed")
+			os.Exit(1)
+		}
+
+		setupLog.Info("cleanup completed", "actions", len(actions), "dryRun", cleanupDryRun)
+		os.Exit(0)
+	}
+
+	// CANARY
+	// - lightweight deployment mode, only the leader submits the canary admission requests
+	if canaryMode {
+		runCanary(clientConfig, pclient, promConfig, stopCh)
+		os.Exit(0)
+	}
+
 	// CRD CHECK
 	// - verify if Kyverno CRDs are available
 	if !utils.CRDsInstalled(client.DiscoveryClient) {
@@ -152,29 +312,68 @@ func main() {
 		os.Exit(1)
 	}
 
+	// DEPRECATED APIS
+	// - the deprecations rules check the next minor version of the cluster by default
+	if serverVersion, err := client.DiscoveryClient.GetServerVersion(); err != nil {
+		setupLog.Error(err, "failed to get the Kubernetes version, the deprecations rules require a targetVersion")
+	} else if err := deprecations.SetClusterVersion(serverVersion.Major, serverVersion.Minor); err != nil {
+		setupLog.Error(err, "failed to parse the Kubernetes version, the deprecations rules require a targetVersion")
+	}
+
 	kubeClient, err := utils.NewKubeClient(clientConfig)
 	if err != nil {
 		setupLog.Error(err, "Failed to create kubernetes client")
 		os.Exit(1)
 	}
 
+	// RBAC PRE-FLIGHT
+	// - check the permissions required by each feature
+	// - the result is written to the kyverno-diagnostics ConfigMap
+	preflight := auth.RunPreflight(kubeClient, auth.RequiredPermissions(config.KyvernoNamespace), log.Log.WithName("Preflight"))
+	if table := preflight.Table(); table != "" {
+		setupLog.Info("missing permissions, some features may not work\n" + table)
+	}
+	if err := preflight.WriteConfigMap(kubeClient, config.KyvernoNamespace); err != nil {
+		setupLog.Error(err, "failed to write diagnostics")
+	}
+	if requireAdmissionPermissions && len(preflight.MissingAdmissionCritical()) > 0 {
+		setupLog.Error(fmt.Errorf("%d admission-critical permissions missing", len(preflight.MissingAdmissionCritical())), "Failed RBAC pre-flight")
+		os.Exit(1)
+	}
+
 	kubeInformer := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, resyncPeriod)
 	kubedynamicInformer := client.NewDynamicSharedInformerFactory(resyncPeriod)
 
-	rCache, err := resourcecache.NewResourceCache(client, kubedynamicInformer, log.Log.WithName("resourcecache"))
+	if apiCallCacheResources != "" {
+		apiCallCacheConfig.Resources = strings.Split(apiCallCacheResources, ",")
+	}
+	rCache, err := resourcecache.NewResourceCache(client, kubedynamicInformer, apiCallCacheConfig, log.Log.WithName("resourcecache"))
 	if err != nil {
 		setupLog.Error(err, "ConfigMap lookup disabled: failed to create resource cache")
 		os.Exit(1)
 	}
 
-	// load image registry secrets
-	secrets := strings.Split(imagePullSecrets, ",")
-	if imagePullSecrets != "" && len(secrets) > 0 {
+	// load image registry secrets, the image pull secrets of the policies are resolved also without global secrets
+	var secrets []string
+	if imagePullSecrets != "" {
+		secrets = strings.Split(imagePullSecrets, ",")
 		setupLog.Info("initializing registry credentials", "secrets", secrets)
-		if err := cosign.Initialize(kubeClient, config.KyvernoNamespace, "", secrets); err != nil {
-			setupLog.Error(err, "failed to initialize image pull secrets")
-			os.Exit(1)
-		}
+	}
+	if err := cosign.Initialize(kubeClient, config.KyvernoNamespace, "", secrets); err != nil {
+		setupLog.Error(err, "failed to initialize image pull secrets")
+		os.Exit(1)
+	}
+
+	if registryNoProxy != "" {
+		registryOptions.NoProxy = strings.Split(registryNoProxy, ",")
+	}
+	if insecureRegistries != "" {
+		registryOptions.InsecureRegistries = strings.Split(insecureRegistries, ",")
+		setupLog.Info("registry certificates are not verified", "registries", registryOptions.InsecureRegistries)
+	}
+	if err := cosign.ConfigureRegistry(registryOptions); err != nil {
+		setupLog.Error(err, "failed to configure the image registry client")
+		os.Exit(1)
 	}
 
 	// KYVERNO CRD INFORMER
@@ -183,7 +382,7 @@ func main() {
 	//		- ClusterPolicyReport, PolicyReport
 	//		- GenerateRequest
 	//		- ClusterReportChangeRequest, ReportChangeRequest
-	pInformer := kyvernoinformer.NewSharedInformerFactoryWithOptions(pclient, policyControllerResyncPeriod)
+	pInformer := kyvernoinformer.NewSharedInformerFactoryWithOptions(pclient, backgroundScanOptions.Interval)
 
 	// EVENT GENERATOR
 	// - generate event with retry mechanism
@@ -192,15 +391,24 @@ func main() {
 		pInformer.Kyverno().V1().ClusterPolicies(),
 		pInformer.Kyverno().V1().Policies(),
 		rCache,
+		promConfig,
 		log.Log.WithName("EventGenerator"))
 
 	// POLICY Report GENERATOR
+	// the leader aggregates the results in memory, unless the checkpoints are disabled
+	var resultStore *policyreport.ResultStore
+	if reportCheckpointInterval > 0 {
+		resultStore = policyreport.NewResultStore()
+	}
+
 	reportReqGen := policyreport.NewReportChangeRequestGenerator(pclient,
 		client,
 		pInformer.Kyverno().V1alpha1().ReportChangeRequests(),
 		pInformer.Kyverno().V1alpha1().ClusterReportChangeRequests(),
 		pInformer.Kyverno().V1().ClusterPolicies(),
 		pInformer.Kyverno().V1().Policies(),
+		promConfig,
+		resultStore,
 		log.Log.WithName("ReportChangeRequestGenerator"),
 	)
 
@@ -213,6 +421,10 @@ func main() {
 		pInformer.Kyverno().V1alpha1().ReportChangeRequests(),
 		pInformer.Kyverno().V1alpha1().ClusterReportChangeRequests(),
 		kubeInformer.Core().V1().Namespaces(),
+		maxReportResults,
+		passResultRetention,
+		resultStore,
+		reportCheckpointInterval,
 		log.Log.WithName("PolicyReportGenerator"),
 	)
 
@@ -229,6 +441,7 @@ func main() {
 		serverIP,
 		int32(webhookTimeout),
 		debug,
+		deregisterOnShutdown,
 		log.Log)
 
 	webhookMonitor, err := webhookconfig.NewMonitor(kubeClient, log.Log.WithName("WebhookMonitor"))
@@ -237,6 +450,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// INVENTORY
+	// - indexes values of existing resources declared in the configMap, e.g. Ingress hosts
+	// - policies look them up with the lookup_index JMESPath function
+	inventory := resourcecache.NewInventory(rCache, client, log.Log.WithName("Inventory"))
+	inventoryCh := make(chan bool, 10)
+	jmespath.RegisterIndexLookup(inventory)
+
 	// Configuration Data
 	// dynamically load the configuration from configMap
 	// - resource filters
@@ -249,9 +469,24 @@ func main() {
 		excludeUsername,
 		prgen.ReconcileCh,
 		webhookCfg.UpdateWebhookChan,
+		inventoryCh,
 		log.Log.WithName("ConfigData"),
 	)
 
+	// BACKGROUND GATE
+	// - pauses the background scans, the generate requests of the existing namespaces and
+	//   the full reconciliations of the policy reports while backgroundScanPaused is set
+	backgroundGate := background.NewGate(configData, kubeClient, config.KyvernoNamespace, promConfig, log.Log.WithName("BackgroundGate"))
+
+	// the policy exceptions are only watched when enabled, the lister is nil otherwise
+	var pexLister kyvernolister.PolicyExceptionLister
+	var pexSynced cache.InformerSynced
+	if enablePolicyExceptions {
+		pexInformer := pInformer.Kyverno().V1().PolicyExceptions()
+		pexLister = pexInformer.Lister()
+		pexSynced = pexInformer.Informer().HasSynced
+	}
+
 	// POLICY CONTROLLER
 	// - reconciliation policy and policy violation
 	// - process policy on existing resources
@@ -262,6 +497,7 @@ func main() {
 		client,
 		pInformer.Kyverno().V1().ClusterPolicies(),
 		pInformer.Kyverno().V1().Policies(),
+		pInformer.Kyverno().V1().PolicySets(),
 		pInformer.Kyverno().V1().GenerateRequests(),
 		configData,
 		eventGenerator,
@@ -270,8 +506,10 @@ func main() {
 		kubeInformer.Core().V1().Namespaces(),
 		log.Log.WithName("PolicyController"),
 		rCache,
-		policyControllerResyncPeriod,
+		backgroundScanOptions,
 		promConfig,
+		backgroundGate,
+		pexLister,
 	)
 
 	if err != nil {
@@ -290,11 +528,14 @@ func main() {
 		client,
 		pInformer.Kyverno().V1().ClusterPolicies(),
 		pInformer.Kyverno().V1().GenerateRequests(),
+		kubeInformer.Core().V1().ResourceQuotas(),
 		eventGenerator,
 		kubedynamicInformer,
 		log.Log.WithName("GenerateController"),
 		configData,
 		rCache,
+		promConfig,
+		backgroundGate,
 	)
 	if err != nil {
 		setupLog.Error(err, "Failed to create generate controller")
@@ -317,12 +558,49 @@ func main() {
 		os.Exit(1)
 	}
 
+	// VALIDATING ADMISSION POLICIES
+	// - generates the ValidatingAdmissionPolicies of the annotated ClusterPolicies
+	var admissionPolicyCtrl *admissionpolicy.Controller
+	if generateAdmissionPolicies {
+		admissionPolicyCtrl = admissionpolicy.NewController(client, pInformer.Kyverno().V1().ClusterPolicies(), log.Log.WithName("AdmissionPolicyController"))
+	}
+
+	// CLEANUP POLICIES
+	// - deletes the resources matching the cleanup policies on their schedule
+	cleanupPolicyCtrl := cleanuppolicy.NewController(
+		client,
+		pclient,
+		pInformer.Kyverno().V1().CleanupPolicies(),
+		kubeInformer.Core().V1().Namespaces().Lister(),
+		configData,
+		log.Log.WithName("CleanupPolicyController"),
+	)
+
 	pCacheController := policycache.NewPolicyCacheController(
 		pInformer.Kyverno().V1().ClusterPolicies(),
 		pInformer.Kyverno().V1().Policies(),
+		promConfig,
 		log.Log.WithName("PolicyCacheController"),
 	)
 
+	// narrows the resource webhook rules to the kinds of the cached policies
+	webhookRuleManager := webhookconfig.NewRuleManager(
+		webhookCfg,
+		pCacheController.Cache,
+		pCacheController.HasWarmedUp,
+		client.DiscoveryClient,
+		pclient,
+		log.Log.WithName("WebhookRuleManager"),
+	)
+
+	// the restored policy cache serves admission requests while the informers sync
+	policyCacheRestored := false
+	if policyCacheSnapshot != "" {
+		policyCacheRestored = restorePolicyCache(policyCacheSnapshot, clientConfig, pCacheController.Cache, webhookCfg)
+	}
+
+	nsRuleCache := engine.NewNamespaceRuleCache(kubeInformer.Core().V1().Namespaces(), rCache, log.Log.WithName("NamespaceRuleCache"))
+
 	auditHandler := webhooks.NewValidateAuditHandler(
 		pCacheController.Cache,
 		eventGenerator,
@@ -335,6 +613,8 @@ func main() {
 		rCache,
 		client,
 		promConfig,
+		nsRuleCache,
+		pexLister,
 	)
 
 	certRenewer := ktls.NewCertRenewer(client, clientConfig, ktls.CertRenewalInterval, ktls.CertValidityDuration, serverIP, log.Log.WithName("CertRenewer"))
@@ -367,6 +647,7 @@ func main() {
 			os.Exit(1)
 		}
 		webhookCfg.UpdateWebhookChan <- true
+		go webhookRuleManager.Run(stopCh)
 	}
 
 	// leader election context
@@ -380,7 +661,7 @@ func main() {
 	}()
 
 	// webhookconfigurations are registered by the leader only
-	webhookRegisterLeader, err := leaderelection.New("webhook-register", config.KyvernoNamespace, kubeClient, registerWebhookConfigurations, nil, log.Log.WithName("webhookRegister/LeaderElection"))
+	webhookRegisterLeader, err := leaderelection.New("webhook-register", config.KyvernoNamespace, kubeClient, registerWebhookConfigurations, nil, promConfig, log.Log.WithName("webhookRegister/LeaderElection"))
 	if err != nil {
 		setupLog.Error(err, "failed to elector leader")
 		os.Exit(1)
@@ -404,6 +685,23 @@ func main() {
 	// -- annotations on resources with update details on mutation JSON patches
 	// -- generate policy violation resource
 	// -- generate events on policy and resource
+	var admissionDumper *webhooks.AdmissionDumper
+	if admissionDumpConfig.Dir != "" {
+		if admissionDumpNamespaces != "" {
+			admissionDumpConfig.Namespaces = strings.Split(admissionDumpNamespaces, ",")
+		}
+		if admissionDumpKinds != "" {
+			admissionDumpConfig.Kinds = strings.Split(admissionDumpKinds, ",")
+		}
+
+		admissionDumper, err = webhooks.NewAdmissionDumper(admissionDumpConfig, configData.GetSensitiveKinds, log.Log.WithName("AdmissionDumper"))
+		if err != nil {
+			setupLog.Error(err, "failed to enable the admission review dump")
+			os.Exit(1)
+		}
+		setupLog.Info("dumping the admission reviews", "dir", admissionDumpConfig.Dir, "namespaces", admissionDumpConfig.Namespaces, "kinds", admissionDumpConfig.Kinds)
+	}
+
 	server, err := webhooks.NewWebhookServer(
 		pclient,
 		client,
@@ -429,6 +727,10 @@ func main() {
 		rCache,
 		grc,
 		promConfig,
+		nsRuleCache,
+		pexLister,
+		traces,
+		admissionDumper,
 	)
 
 	if err != nil {
@@ -437,13 +739,38 @@ func main() {
 	}
 
 	// wrap all controllers that need leaderelection
-	// start them once by the leader
+	// start them once by the leader, they stop when the leadership is lost
+	leaderCtx, leaderCancel := context.WithCancel(ctx)
+	defer leaderCancel()
+
+	var leaderWork sync.WaitGroup
+	runLeaderWork := func(work func(stopCh <-chan struct{})) {
+		leaderWork.Add(1)
+		go func() {
+			defer leaderWork.Done()
+			work(leaderCtx.Done())
+		}()
+	}
+
 	run := func() {
-		go certManager.Run(stopCh)
-		go policyCtrl.Run(2, prgen.ReconcileCh, stopCh)
-		go prgen.Run(1, stopCh)
-		go grc.Run(genWorkers, stopCh)
-		go grcc.Run(1, stopCh)
+		runLeaderWork(certManager.Run)
+		runLeaderWork(func(stopCh <-chan struct{}) { backgroundGate.Run(time.Second, stopCh) })
+		runLeaderWork(func(stopCh <-chan struct{}) { policyCtrl.Run(backgroundScanWorkers, prgen.ReconcileCh, stopCh) })
+		runLeaderWork(func(stopCh <-chan struct{}) { prgen.Run(1, stopCh) })
+		runLeaderWork(func(stopCh <-chan struct{}) { grc.Run(genWorkers, stopCh) })
+		runLeaderWork(func(stopCh <-chan struct{}) { grcc.Run(1, stopCh) })
+		if admissionPolicyCtrl != nil {
+			runLeaderWork(func(stopCh <-chan struct{}) { admissionPolicyCtrl.Run(1, stopCh) })
+		}
+		runLeaderWork(cleanupPolicyCtrl.Run)
+
+		// the webhook monitor registers the webhooks again and renews the certificates if needed
+		if !debug {
+			runLeaderWork(func(stopCh <-chan struct{}) { webhookMonitor.Run(webhookCfg, certRenewer, eventGenerator, stopCh) })
+		}
+
+		go backwardcompatibility.AddLabels(pclient, pInformer.Kyverno().V1().GenerateRequests())
+		go backwardcompatibility.AddCloneLabel(client, pInformer.Kyverno().V1().ClusterPolicies())
 	}
 
 	kubeClientLeaderElection, err := utils.NewKubeClient(clientConfig)
@@ -452,16 +779,20 @@ func main() {
 		os.Exit(1)
 	}
 
-	// cleanup Kyverno managed resources followed by webhook shutdown
-	// No need to exit here, as server.Stop(ctx) closes the cleanUp
-	// chan, thus the main process exits.
+	// a replica losing the leadership stops the controllers of the leader and shuts down, it is restarted to
+	// campaign again as the controllers cannot be started twice. The webhooks are served by all the replicas,
+	// the webhook server is stopped on shutdown only, once the controllers of the leader are stopped.
 	stop := func() {
-		c, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		server.Stop(c)
+		leaderCancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		setupLog.Info("leadership lost, shutting down")
+		signal.RequestShutdown()
 	}
 
-	le, err := leaderelection.New("kyverno", config.KyvernoNamespace, kubeClientLeaderElection, run, stop, log.Log.WithName("kyverno/LeaderElection"))
+	le, err := leaderelection.New("kyverno", config.KyvernoNamespace, kubeClientLeaderElection, run, stop, promConfig, log.Log.WithName("kyverno/LeaderElection"))
 	if err != nil {
 		setupLog.Error(err, "failed to elector leader")
 		os.Exit(1)
@@ -473,32 +804,160 @@ func main() {
 
 	go reportReqGen.Run(2, stopCh)
 	go configData.Run(stopCh)
+	go inventory.Run(configData, inventoryCh, stopCh)
 	go eventGenerator.Run(3, stopCh)
 	go grgen.Run(10, stopCh)
 	go pCacheController.Run(1, stopCh)
 	go auditHandler.Run(10, stopCh)
-	if !debug {
-		go webhookMonitor.Run(webhookCfg, certRenewer, eventGenerator, stopCh)
-	}
-
-	go backwardcompatibility.AddLabels(pclient, pInformer.Kyverno().V1().GenerateRequests())
-	go backwardcompatibility.AddCloneLabel(client, pInformer.Kyverno().V1().ClusterPolicies())
 
 	pInformer.Start(stopCh)
 	kubeInformer.Start(stopCh)
 	kubedynamicInformer.Start(stopCh)
 
+	if pexSynced != nil && !cache.WaitForCacheSync(stopCh, pexSynced) {
+		setupLog.Info("failed to sync the policy exceptions informer cache")
+	}
+
+	if policyCacheRestored {
+		server.RunAsync(stopCh)
+	}
+
+	// fill the policy cache before the webhook starts serving, unless it is restored
+	if err := pCacheController.Warmup(stopCh); err != nil {
+		setupLog.Error(err, "failed to warm up policy cache")
+		os.Exit(1)
+	}
+
 	// verifies if the admission control is enabled and active
-	server.RunAsync(stopCh)
+	if !policyCacheRestored {
+		server.RunAsync(stopCh)
+	}
+
+	// the evaluation service serves the policies of the warmed up cache
+	if evaluationAddr != "" {
+		rootCA, err := ktls.ReadRootCASecret(clientConfig, client)
+		if err != nil {
+			setupLog.Error(err, "failed to read the root CA of the evaluation service")
+			os.Exit(1)
+		}
+
+		creds, err := evaluation.ServerCredentials(tlsPair, rootCA)
+		if err != nil {
+			setupLog.Error(err, "failed to create the credentials of the evaluation service")
+			os.Exit(1)
+		}
+
+		evaluationServer := evaluation.NewServer(creds, pCacheController.Cache, configData, client, rCache, kubeInformer.Core().V1().Namespaces().Lister(), evaluationRateLimit, evaluationBurst, log.Log.WithName("EvaluationServer"))
+		go evaluationServer.Run(evaluationAddr, stopCh)
+	}
 
 	<-stopCh
 
+	// the report generator of the leader checkpoints the pending results
+	if !waitForLeaderWork(&leaderWork, leaderShutdownTimeout) {
+		setupLog.Info("timed out waiting for the controllers of the leader to stop", "timeout", leaderShutdownTimeout.String())
+	}
+
+	if policyCacheSnapshot != "" && pCacheController.HasWarmedUp() {
+		snapshot := pCacheController.Cache.Snapshot()
+		snapshot.WebhookRules = webhookCfg.ResourceWebhookRules()
+		if err := policycache.SaveSnapshot(policyCacheSnapshot, snapshot); err != nil {
+			setupLog.Error(err, "failed to save policy cache snapshot", "path", policyCacheSnapshot)
+		} else {
+			setupLog.Info("saved policy cache snapshot", "path", policyCacheSnapshot, "policies", len(snapshot.Policies))
+		}
+	}
+
 	// resource cleanup
-	// remove webhook configurations
+	// the webhook configurations are removed by the last replica, server.Stop closes the cleanUp chan
+	stopCtx, stopCancel := context.WithCancel(context.Background())
+	defer stopCancel()
+	server.Stop(stopCtx)
 	<-cleanUp
 	setupLog.Info("Kyverno shutdown successful")
 }
 
+// restorePolicyCache restores the policy cache snapshot, the policies changed since the snapshot was
+// saved are skipped. It returns true if the snapshot is complete, i.e. all the current policies are
+// restored, in which case the resource webhooks are also registered with the rules of the snapshot.
+func restorePolicyCache(path string, clientConfig *rest.Config, pCache policycache.Interface, webhookCfg *webhookconfig.Register) bool {
+	snapshot, err := policycache.LoadSnapshot(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			setupLog.Error(err, "failed to load policy cache snapshot", "path", path)
+		}
+		return false
+	}
+
+	metadataClient, err := metadata.NewForConfig(clientConfig)
+	if err != nil {
+		setupLog.Error(err, "failed to create metadata client")
+		return false
+	}
+
+	// the resourceVersions are verified before the policies of the snapshot are trusted
+	resourceVersions, err := policycache.ListResourceVersions(metadataClient)
+	if err != nil {
+		setupLog.Error(err, "failed to verify policy cache snapshot")
+		return false
+	}
+
+	restored, complete := pCache.Restore(snapshot, resourceVersions)
+	setupLog.Info("restored policy cache snapshot", "path", path, "policies", restored, "complete", complete)
+	if !complete {
+		return false
+	}
+
+	for key, rules := range snapshot.WebhookRules {
+		webhookCfg.SetResourceWebhookRules(key, rules)
+	}
+	return true
+}
+
+// runCanary runs the admission canary until the stop channel is closed, the canary requests are
+// submitted by the leader only
+func runCanary(clientConfig *rest.Config, pclient kyvernoclient.Interface, promConfig *metrics.PromConfig, stopCh <-chan struct{}) {
+	kubeClient, err := utils.NewKubeClient(clientConfig)
+	if err != nil {
+		setupLog.Error(err, "Failed to create kubernetes client")
+		os.Exit(1)
+	}
+
+	c := canary.New(canary.NewDryRunClient(pclient), kubeClient, config.KyvernoNamespace, canaryTimeout, promConfig, log.Log.WithName("Canary"))
+	run := func() {
+		go c.Run(canaryInterval, stopCh)
+	}
+
+	le, err := leaderelection.New("kyverno-canary", config.KyvernoNamespace, kubeClient, run, nil, promConfig, log.Log.WithName("canary/LeaderElection"))
+	if err != nil {
+		setupLog.Error(err, "failed to elector leader")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go le.Run(ctx)
+
+	<-stopCh
+	setupLog.Info("canary shutdown successful")
+}
+
+// waitForLeaderWork waits for the controllers of the leader to stop, it returns false on timeout
+func waitForLeaderWork(leaderWork *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		leaderWork.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 func startOpenAPIController(client *dclient.Client, stopCh <-chan struct{}) *openapi.Controller {
 	openAPIController, err := openapi.NewOpenAPIController()
 	if err != nil {
@@ -0,0 +1,409 @@
+package admissionpolicy
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// APIVersion is the API version of the generated resources
+	APIVersion = "admissionregistration.k8s.io/v1"
+
+	// PolicyKind is the kind of the generated policies
+	PolicyKind = "ValidatingAdmissionPolicy"
+
+	// BindingKind is the kind of the generated policy bindings
+	BindingKind = "ValidatingAdmissionPolicyBinding"
+
+	// GenerateAnnotation is the ClusterPolicy annotation set to "true" to generate its
+	// ValidatingAdmissionPolicies in the cluster
+	GenerateAnnotation = "kyverno.io/generate-validating-admission-policies"
+
+	// PolicyNameLabel is the label of the generated resources set to the name of their policy
+	PolicyNameLabel = "kyverno.io/policy-name"
+
+	// namespaceNameLabel is the label set by the API server to the name of the namespace
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+)
+
+// defaultOperations are the operations matched by the rules without operations
+var defaultOperations = []interface{}{"CREATE", "UPDATE"}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// ResourceResolver returns the API groups and the resource of a kind of a match block,
+// e.g. "apps" and "deployments" for "Deployment" or "apps/v1/Deployment"
+type ResourceResolver func(kind string) (groups []string, resource string, err error)
+
+// Generate converts the validate rules of the policy into a ValidatingAdmissionPolicy and a
+// ValidatingAdmissionPolicyBinding per rule, named after the policy and the rule. The policy
+// is not converted if one of its rules cannot be enforced by the API server, i.e. it is not a
+// CEL validate rule or it uses a feature of Kyverno that the API server does not support.
+func Generate(policy *kyverno.ClusterPolicy, resolve ResourceResolver) ([]*unstructured.Unstructured, error) {
+	if err := checkPolicy(policy); err != nil {
+		return nil, fmt.Errorf("policy %s cannot be converted: %v", policy.GetName(), err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, rule := range policy.Spec.Rules {
+		vap, binding, err := generateRule(policy, rule, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s of policy %s cannot be converted: %v", rule.Name, policy.GetName(), err)
+		}
+
+		objects = append(objects, vap, binding)
+	}
+
+	return objects, nil
+}
+
+// checkPolicy returns an error if the settings of the policy cannot be applied by the API server
+func checkPolicy(policy *kyverno.ClusterPolicy) error {
+	if len(policy.Spec.Rules) == 0 {
+		return fmt.Errorf("the policy has no rules")
+	}
+
+	if !policy.IsEnabled() || policy.IsSuspended() {
+		return fmt.Errorf("the policy is disabled")
+	}
+
+	if !policy.AdmissionProcessingEnabled() {
+		return fmt.Errorf("admission is disabled")
+	}
+
+	action := policy.GetValidationFailureAction()
+	if action != "" && action != "enforce" && action != "audit" {
+		return fmt.Errorf("unknown validationFailureAction %s", action)
+	}
+
+	if policy.IsRollingOut() {
+		return fmt.Errorf("rolloutPercentage is not supported")
+	}
+
+	if policy.IsShadowEnforce() {
+		return fmt.Errorf("shadowEnforce is not supported")
+	}
+
+	if policy.AllowsNamespaceThresholds() {
+		return fmt.Errorf("allowNamespaceThresholds is not supported")
+	}
+
+	return nil
+}
+
+func generateRule(policy *kyverno.ClusterPolicy, rule kyverno.Rule, resolve ResourceResolver) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	if err := checkRule(rule); err != nil {
+		return nil, nil, err
+	}
+
+	name, err := resourceName(policy, rule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matchConstraints, err := buildMatchConstraints(policy, rule, resolve)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var validations []interface{}
+	for _, expression := range rule.Validation.CEL.Expressions {
+		validation := map[string]interface{}{"expression": expression.Expression}
+		message := expression.Message
+		if message == "" {
+			message = rule.Validation.Message
+		}
+		if message != "" {
+			validation["message"] = message
+		}
+		validations = append(validations, validation)
+	}
+
+	vap := newObject(policy, PolicyKind, name)
+	vap.Object["spec"] = map[string]interface{}{
+		"failurePolicy":    string(policy.GetFailurePolicy()),
+		"matchConstraints": matchConstraints,
+		"validations":      validations,
+	}
+
+	validationActions := []interface{}{"Audit"}
+	if policy.GetValidationFailureAction() == "enforce" {
+		validationActions = []interface{}{"Deny"}
+	}
+
+	binding := newObject(policy, BindingKind, name)
+	binding.Object["spec"] = map[string]interface{}{
+		"policyName":        name,
+		"validationActions": validationActions,
+	}
+
+	return vap, binding, nil
+}
+
+// checkRule returns an error if the rule is not a CEL validate rule that the API server can apply
+func checkRule(rule kyverno.Rule) error {
+	if rule.HasMutate() || rule.HasGenerate() || rule.HasVerifyImages() {
+		return fmt.Errorf("only validate rules are supported")
+	}
+
+	if rule.Validation.CEL == nil {
+		return fmt.Errorf("only the validate rules with CEL expressions are supported")
+	}
+
+	validation := rule.Validation
+	validation.CEL = nil
+	validation.Message = ""
+	if !reflect.DeepEqual(validation, kyverno.Validation{}) {
+		return fmt.Errorf("the CEL expressions cannot be combined with other validations or localized messages")
+	}
+
+	if len(rule.Context) > 0 {
+		return fmt.Errorf("context is not supported")
+	}
+
+	if rule.AnyAllConditions != nil {
+		return fmt.Errorf("preconditions are not supported")
+	}
+
+	if len(rule.TimeWindows) > 0 {
+		return fmt.Errorf("timeWindows are not supported")
+	}
+
+	if rule.ApplyToDeleting || rule.Scope == kyverno.NamespaceScope {
+		return fmt.Errorf("applyToDeleting and the Namespace scope are not supported")
+	}
+
+	if !reflect.DeepEqual(rule.MatchResources.UserInfo, kyverno.UserInfo{}) || !reflect.DeepEqual(rule.ExcludeResources.UserInfo, kyverno.UserInfo{}) {
+		return fmt.Errorf("subjects, roles and clusterRoles are not supported")
+	}
+
+	if rule.MatchResources.IgnorePlatformMutations {
+		return fmt.Errorf("ignorePlatformMutations is not supported")
+	}
+
+	return nil
+}
+
+// buildMatchConstraints converts the match and the exclude blocks of the rule
+func buildMatchConstraints(policy *kyverno.ClusterPolicy, rule kyverno.Rule, resolve ResourceResolver) (map[string]interface{}, error) {
+	match := rule.MatchResources.ResourceDescription
+	if len(match.Kinds) == 0 {
+		return nil, fmt.Errorf("match.resources.kinds is required")
+	}
+
+	if len(match.Annotations) > 0 || match.MinimumAge != nil || match.DryRun != "" {
+		return nil, fmt.Errorf("match.resources supports kinds, names, namespaces, selector, namespaceSelector and operations")
+	}
+
+	names := match.Names
+	if match.Name != "" {
+		names = append([]string{match.Name}, names...)
+	}
+
+	if err := checkWildcards("match.resources.names", names); err != nil {
+		return nil, err
+	}
+
+	if err := checkWildcards("match.resources.namespaces", match.Namespaces); err != nil {
+		return nil, err
+	}
+
+	operations := defaultOperations
+	if len(match.Operations) > 0 {
+		operations = toList(match.Operations)
+	}
+
+	resourceRules, err := buildResourceRules(match.Kinds, names, operations, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints := map[string]interface{}{
+		"resourceRules": resourceRules,
+	}
+
+	namespaceSelector := match.NamespaceSelector.DeepCopy()
+	namespaces := match.Namespaces
+	if policy.GetNamespace() != "" {
+		// the rules of a namespaced policy only apply to its namespace
+		namespaces = []string{policy.GetNamespace()}
+	}
+	if len(namespaces) > 0 {
+		namespaceSelector = withNamespaces(namespaceSelector, metav1.LabelSelectorOpIn, namespaces)
+	}
+
+	exclude := rule.ExcludeResources.ResourceDescription
+	if !reflect.DeepEqual(exclude, kyverno.ResourceDescription{}) {
+		excludeNames := exclude.Names
+		if exclude.Name != "" {
+			excludeNames = append([]string{exclude.Name}, excludeNames...)
+		}
+
+		switch {
+		case len(exclude.Namespaces) > 0 && reflect.DeepEqual(exclude, kyverno.ResourceDescription{Namespaces: exclude.Namespaces}):
+			if err := checkWildcards("exclude.resources.namespaces", exclude.Namespaces); err != nil {
+				return nil, err
+			}
+			namespaceSelector = withNamespaces(namespaceSelector, metav1.LabelSelectorOpNotIn, exclude.Namespaces)
+
+		case reflect.DeepEqual(exclude, kyverno.ResourceDescription{Kinds: exclude.Kinds, Name: exclude.Name, Names: exclude.Names}):
+			if err := checkWildcards("exclude.resources.names", excludeNames); err != nil {
+				return nil, err
+			}
+
+			kinds := exclude.Kinds
+			if len(kinds) == 0 {
+				kinds = match.Kinds
+			}
+
+			excludeRules, err := buildResourceRules(kinds, excludeNames, []interface{}{"*"}, resolve)
+			if err != nil {
+				return nil, err
+			}
+			constraints["excludeResourceRules"] = excludeRules
+
+		default:
+			return nil, fmt.Errorf("exclude.resources supports either namespaces, or kinds and names")
+		}
+	}
+
+	if namespaceSelector != nil {
+		selector, err := toMap(namespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		constraints["namespaceSelector"] = selector
+	}
+
+	if match.Selector != nil {
+		selector, err := toMap(match.Selector)
+		if err != nil {
+			return nil, err
+		}
+		constraints["objectSelector"] = selector
+	}
+
+	return constraints, nil
+}
+
+// buildResourceRules returns a resource rule per kind, all the versions of the groups of the kind are matched
+func buildResourceRules(kinds, names []string, operations []interface{}, resolve ResourceResolver) ([]interface{}, error) {
+	var rules []interface{}
+	for _, kind := range kinds {
+		if _, k := common.GetKindFromGVK(kind); strings.Contains(k, "*") {
+			return nil, fmt.Errorf("the wildcard kind %s is not supported", kind)
+		}
+
+		groups, resource, err := resolve(kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the resource of kind %s: %v", kind, err)
+		}
+
+		rule := map[string]interface{}{
+			"apiGroups":   toList(groups),
+			"apiVersions": []interface{}{"*"},
+			"resources":   []interface{}{resource},
+			"operations":  operations,
+		}
+		if len(names) > 0 {
+			rule["resourceNames"] = toList(names)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// withNamespaces adds the requirement on the namespace names to a copy of the namespace selector
+func withNamespaces(selector *metav1.LabelSelector, operator metav1.LabelSelectorOperator, namespaces []string) *metav1.LabelSelector {
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+
+	values := append([]string{}, namespaces...)
+	sort.Strings(values)
+	selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      namespaceNameLabel,
+		Operator: operator,
+		Values:   values,
+	})
+
+	return selector
+}
+
+func checkWildcards(field string, values []string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, "*?") {
+			return fmt.Errorf("the wildcards of %s are not supported", field)
+		}
+	}
+
+	return nil
+}
+
+// resourceName returns the name of the generated resources of the rule, the names of the
+// namespaced policies are prefixed with their namespace
+func resourceName(policy *kyverno.ClusterPolicy, rule kyverno.Rule) (string, error) {
+	parts := []string{policy.GetName(), rule.Name}
+	if policy.GetNamespace() != "" {
+		parts = append([]string{policy.GetNamespace()}, parts...)
+	}
+
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	name = strings.Trim(name, "-.")
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid name %s: %s", name, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
+
+// newObject returns a generated resource of the policy, the resources of a ClusterPolicy are
+// owned by the policy and deleted with it
+func newObject(policy *kyverno.ClusterPolicy, kind, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(APIVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{
+		"app.kubernetes.io/managed-by": "kyverno",
+		PolicyNameLabel:                policy.GetName(),
+	})
+
+	if policy.GetNamespace() == "" && policy.GetUID() != "" {
+		obj.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: "kyverno.io/v1",
+			Kind:       "ClusterPolicy",
+			Name:       policy.GetName(),
+			UID:        policy.GetUID(),
+		}})
+	}
+
+	return obj
+}
+
+func toList(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+func toMap(selector *metav1.LabelSelector) (map[string]interface{}, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the label selector: %v", err)
+	}
+	return obj, nil
+}
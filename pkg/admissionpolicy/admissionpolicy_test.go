@@ -0,0 +1,246 @@
+package admissionpolicy
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func newTestPolicy(t *testing.T, raw string) *kyverno.ClusterPolicy {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal([]byte(raw), &policy))
+	return &policy
+}
+
+func assertYAML(t *testing.T, obj *unstructured.Unstructured, expected string) {
+	var expectedObj map[string]interface{}
+	assert.NilError(t, yaml.Unmarshal([]byte(expected), &expectedObj))
+
+	actual, err := yaml.Marshal(obj.Object)
+	assert.NilError(t, err)
+	expectedRaw, err := yaml.Marshal(expectedObj)
+	assert.NilError(t, err)
+	assert.Equal(t, string(actual), string(expectedRaw))
+}
+
+func Test_Generate(t *testing.T) {
+	policy := newTestPolicy(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "deployment-replicas", "uid": "1234"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-replicas",
+					"match": {"resources": {"kinds": ["apps/v1/Deployment"], "namespaceSelector": {"matchLabels": {"env": "prod"}}}},
+					"exclude": {"resources": {"namespaces": ["kube-system"]}},
+					"validate": {
+						"message": "the replicas are restricted",
+						"cel": {"expressions": [
+							{"expression": "object.spec.replicas <= 5", "message": "at most 5 replicas are allowed"},
+							{"expression": "object.spec.replicas >= 2"}
+						]}
+					}
+				}
+			]
+		}
+	}`)
+
+	objects, err := Generate(policy, SchemeResolver)
+	assert.NilError(t, err)
+	assert.Equal(t, len(objects), 2)
+
+	assertYAML(t, objects[0], `
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: deployment-replicas-check-replicas
+  labels:
+    app.kubernetes.io/managed-by: kyverno
+    kyverno.io/policy-name: deployment-replicas
+  ownerReferences:
+  - apiVersion: kyverno.io/v1
+    kind: ClusterPolicy
+    name: deployment-replicas
+    uid: "1234"
+spec:
+  failurePolicy: Fail
+  matchConstraints:
+    resourceRules:
+    - apiGroups: [apps]
+      apiVersions: ["*"]
+      resources: [deployments]
+      operations: [CREATE, UPDATE]
+    namespaceSelector:
+      matchLabels:
+        env: prod
+      matchExpressions:
+      - key: kubernetes.io/metadata.name
+        operator: NotIn
+        values: [kube-system]
+  validations:
+  - expression: object.spec.replicas <= 5
+    message: at most 5 replicas are allowed
+  - expression: object.spec.replicas >= 2
+    message: the replicas are restricted
+`)
+
+	assertYAML(t, objects[1], `
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: deployment-replicas-check-replicas
+  labels:
+    app.kubernetes.io/managed-by: kyverno
+    kyverno.io/policy-name: deployment-replicas
+  ownerReferences:
+  - apiVersion: kyverno.io/v1
+    kind: ClusterPolicy
+    name: deployment-replicas
+    uid: "1234"
+spec:
+  policyName: deployment-replicas-check-replicas
+  validationActions: [Deny]
+`)
+}
+
+func Test_Generate_Namespaced(t *testing.T) {
+	policy := newTestPolicy(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "Policy",
+		"metadata": {"name": "pod-names", "namespace": "prod"},
+		"spec": {
+			"validationFailureAction": "audit",
+			"failurePolicy": "Ignore",
+			"rules": [
+				{
+					"name": "Check Names",
+					"match": {"resources": {"kinds": ["Pod"], "names": ["web", "web-canary"], "operations": ["CREATE"], "selector": {"matchLabels": {"app": "web"}}}},
+					"exclude": {"resources": {"names": ["web-canary"]}},
+					"validate": {"cel": {"expressions": [{"expression": "object.metadata.name.startsWith('prod-')"}]}}
+				}
+			]
+		}
+	}`)
+
+	objects, err := Generate(policy, SchemeResolver)
+	assert.NilError(t, err)
+	assert.Equal(t, len(objects), 2)
+
+	assertYAML(t, objects[0], `
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: prod-pod-names-check-names
+  labels:
+    app.kubernetes.io/managed-by: kyverno
+    kyverno.io/policy-name: pod-names
+spec:
+  failurePolicy: Ignore
+  matchConstraints:
+    resourceRules:
+    - apiGroups: [""]
+      apiVersions: ["*"]
+      resources: [pods]
+      operations: [CREATE]
+      resourceNames: [web, web-canary]
+    excludeResourceRules:
+    - apiGroups: [""]
+      apiVersions: ["*"]
+      resources: [pods]
+      operations: ["*"]
+      resourceNames: [web-canary]
+    namespaceSelector:
+      matchExpressions:
+      - key: kubernetes.io/metadata.name
+        operator: In
+        values: [prod]
+    objectSelector:
+      matchLabels:
+        app: web
+  validations:
+  - expression: object.metadata.name.startsWith('prod-')
+`)
+
+	binding, _, _ := unstructured.NestedStringSlice(objects[1].Object, "spec", "validationActions")
+	assert.DeepEqual(t, binding, []string{"Audit"})
+}
+
+func Test_Generate_Unsupported(t *testing.T) {
+	testcases := []struct {
+		spec string
+		err  string
+	}{
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "validate": {"deny": {}}}]}`,
+			err:  "only the validate rules with CEL expressions are supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "mutate": {"patchStrategicMerge": {"metadata": {"labels": {"a": "b"}}}}}]}`,
+			err:  "only validate rules are supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "context": [{"name": "cm", "configMap": {"name": "cm", "namespace": "default"}}], "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "context is not supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "preconditions": {"any": []}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "preconditions are not supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}, "subjects": [{"kind": "User", "name": "dev"}]}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "subjects, roles and clusterRoles are not supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"], "names": ["web-*"]}}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "the wildcards of match.resources.names are not supported",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "exclude": {"resources": {"kinds": ["Pod"], "namespaces": ["dev"]}}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "exclude.resources supports either namespaces, or kinds and names",
+		},
+		{
+			spec: `{"rules": [{"name": "r", "match": {"resources": {"kinds": ["Widget"]}}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "failed to resolve the resource of kind Widget",
+		},
+		{
+			spec: `{"rolloutPercentage": 10, "validationFailureAction": "enforce", "rules": [{"name": "r", "match": {"resources": {"kinds": ["Pod"]}}, "validate": {"cel": {"expressions": [{"expression": "true"}]}}}]}`,
+			err:  "rolloutPercentage is not supported",
+		},
+	}
+
+	for _, tc := range testcases {
+		policy := newTestPolicy(t, `{"apiVersion": "kyverno.io/v1", "kind": "ClusterPolicy", "metadata": {"name": "p"}, "spec": `+tc.spec+`}`)
+		_, err := Generate(policy, SchemeResolver)
+		assert.ErrorContains(t, err, tc.err, tc.spec)
+	}
+}
+
+func Test_SchemeResolver(t *testing.T) {
+	testcases := []struct {
+		kind     string
+		groups   []string
+		resource string
+	}{
+		{kind: "Pod", groups: []string{""}, resource: "pods"},
+		{kind: "v1/ConfigMap", groups: []string{""}, resource: "configmaps"},
+		{kind: "apps/v1/Deployment", groups: []string{"apps"}, resource: "deployments"},
+		{kind: "NetworkPolicy", groups: []string{"extensions", "networking.k8s.io"}, resource: "networkpolicies"},
+		{kind: "Ingress", groups: []string{"extensions", "networking.k8s.io"}, resource: "ingresses"},
+	}
+
+	for _, tc := range testcases {
+		groups, resource, err := SchemeResolver(tc.kind)
+		assert.NilError(t, err, tc.kind)
+		assert.DeepEqual(t, groups, tc.groups)
+		assert.Equal(t, resource, tc.resource)
+	}
+
+	_, _, err := SchemeResolver("Widget")
+	assert.ErrorContains(t, err, "unknown kind Widget")
+}
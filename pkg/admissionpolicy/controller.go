@@ -0,0 +1,203 @@
+package admissionpolicy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	dclient "github.com/kyverno/kyverno/pkg/dclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const maxRetries = 10
+
+// Controller generates the ValidatingAdmissionPolicies and their bindings of the ClusterPolicies
+// annotated with GenerateAnnotation, so that the API server enforces them without calling the
+// webhooks. The generated resources are updated with the policies and deleted when the annotation
+// is removed or the policy cannot be converted anymore.
+type Controller struct {
+	client *dclient.Client
+
+	// pLister can list/get cluster policy from the shared informer's store
+	pLister kyvernolister.ClusterPolicyLister
+
+	// pSynced returns true if the cluster policy store has been synced at least once
+	pSynced cache.InformerSynced
+
+	// the names of the policies to sync
+	queue workqueue.RateLimitingInterface
+
+	log logr.Logger
+}
+
+// NewController returns a new controller generating the ValidatingAdmissionPolicies
+func NewController(client *dclient.Client, pInformer kyvernoinformer.ClusterPolicyInformer, log logr.Logger) *Controller {
+	c := &Controller{
+		client:  client,
+		pLister: pInformer.Lister(),
+		pSynced: pInformer.Informer().HasSynced,
+		queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "validating-admission-policies"),
+		log:     log,
+	}
+
+	pInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+	})
+
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		c.log.Error(err, "failed to compute key")
+		return
+	}
+
+	c.queue.Add(key)
+}
+
+// Run starts the workers
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	c.log.Info("starting")
+	defer c.log.Info("shutting down")
+
+	if !cache.WaitForCacheSync(stopCh, c.pSynced) {
+		c.log.Info("failed to sync informer cache")
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.sync(key.(string))
+	c.handleErr(err, key)
+	return true
+}
+
+func (c *Controller) handleErr(err error, key interface{}) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+
+	if c.queue.NumRequeues(key) < maxRetries {
+		c.log.V(3).Info("retrying policy", "key", key, "error", err.Error())
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	c.log.Error(err, "failed to generate the validating admission policies", "key", key)
+	c.queue.Forget(key)
+}
+
+// sync creates or updates the generated resources of the policy and deletes the stale ones.
+// The resources of the deleted policies are garbage collected with their owner.
+func (c *Controller) sync(name string) error {
+	logger := c.log.WithValues("policy", name)
+	policy, err := c.pLister.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	var objects []*unstructured.Unstructured
+	if policy.GetAnnotations()[GenerateAnnotation] == "true" {
+		objects, err = Generate(policy.DeepCopy(), DiscoveryResolver(c.client.DiscoveryClient))
+		if err != nil {
+			logger.Info("the policy is enforced by the webhooks", "reason", err.Error())
+			objects = nil
+		}
+	}
+
+	generated := map[string]bool{}
+	for _, obj := range objects {
+		generated[obj.GetKind()+"/"+obj.GetName()] = true
+		if err := c.apply(obj); err != nil {
+			return err
+		}
+	}
+
+	return c.deleteStale(policy, generated)
+}
+
+// apply creates the resource or updates the existing one
+func (c *Controller) apply(obj *unstructured.Unstructured) error {
+	existing, err := c.client.GetResource(APIVersion, obj.GetKind(), "", obj.GetName())
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+
+		if _, err := c.client.CreateResource(APIVersion, obj.GetKind(), "", obj, false); err != nil {
+			return fmt.Errorf("failed to create %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+
+		c.log.V(2).Info("created", "kind", obj.GetKind(), "name", obj.GetName())
+		return nil
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if _, err := c.client.UpdateResource(APIVersion, obj.GetKind(), "", obj, false); err != nil {
+		return fmt.Errorf("failed to update %s %s: %v", obj.GetKind(), obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// deleteStale deletes the resources generated for the policy that are not generated anymore,
+// e.g. for the removed rules
+func (c *Controller) deleteStale(policy *kyverno.ClusterPolicy, generated map[string]bool) error {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{PolicyNameLabel: policy.GetName()}}
+	for _, kind := range []string{BindingKind, PolicyKind} {
+		list, err := c.client.ListResource(APIVersion, kind, "", selector)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %v", kind, err)
+		}
+
+		for _, obj := range list.Items {
+			if generated[kind+"/"+obj.GetName()] {
+				continue
+			}
+
+			if err := c.client.DeleteResource(APIVersion, kind, "", obj.GetName(), false); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s %s: %v", kind, obj.GetName(), err)
+			}
+
+			c.log.V(2).Info("deleted", "kind", kind, "name", obj.GetName())
+		}
+	}
+
+	return nil
+}
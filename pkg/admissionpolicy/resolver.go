@@ -0,0 +1,72 @@
+package admissionpolicy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kyverno/kyverno/pkg/common"
+	dclient "github.com/kyverno/kyverno/pkg/dclient"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// SchemeResolver resolves the kinds of the Kubernetes APIs known to the client, it does not
+// need a cluster. A kind served by several groups, e.g. "Ingress", matches all the groups
+// unless its group is set, e.g. "networking.k8s.io/v1/Ingress".
+func SchemeResolver(kind string) ([]string, string, error) {
+	apiVersion, kind := common.GetKindFromGVK(kind)
+	var gv schema.GroupVersion
+	if apiVersion != "" {
+		var err error
+		if gv, err = schema.ParseGroupVersion(apiVersion); err != nil {
+			return nil, "", err
+		}
+	}
+
+	groups := map[string]bool{}
+	var resource string
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if gvk.Kind != kind || gvk.Version == runtime.APIVersionInternal {
+			continue
+		}
+
+		if apiVersion != "" && (gvk.Group != gv.Group || gvk.Version != gv.Version) {
+			continue
+		}
+
+		groups[gvk.Group] = true
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		resource = plural.Resource
+	}
+
+	if len(groups) == 0 {
+		return nil, "", fmt.Errorf("unknown kind %s, the custom resources are resolved in the cluster", kind)
+	}
+
+	return sortedKeys(groups), resource, nil
+}
+
+// DiscoveryResolver resolves the kinds with the API discovery of the cluster, the preferred
+// group of the kind is matched unless its group is set
+func DiscoveryResolver(discovery dclient.IDiscovery) ResourceResolver {
+	return func(kind string) ([]string, string, error) {
+		apiVersion, kind := common.GetKindFromGVK(kind)
+		_, gvr, err := discovery.FindResource(apiVersion, kind)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return []string{gvr.Group}, gvr.Resource, nil
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
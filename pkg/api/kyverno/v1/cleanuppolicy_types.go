@@ -0,0 +1,76 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CleanupPolicy deletes the resources matching its selectors and conditions on a cron schedule,
+// e.g. the completed Jobs or the orphaned PersistentVolumeClaims.
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=cleanuppolicies,scope="Cluster",shortName=cleanpol
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+// +kubebuilder:printcolumn:name="Last Execution",type="date",JSONPath=".status.lastExecutionTime"
+type CleanupPolicy struct {
+	metav1.TypeMeta   `json:",inline,omitempty" yaml:",inline,omitempty"`
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Spec declares the deleted resources and the schedule of the cleanup.
+	Spec CleanupPolicySpec `json:"spec" yaml:"spec"`
+
+	// Status contains the result of the last cleanup.
+	// +optional
+	Status CleanupPolicyStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// CleanupPolicySpec declares the deleted resources and the schedule of the cleanup.
+type CleanupPolicySpec struct {
+
+	// MatchResources selects the deleted resources by kind, name, namespace, labels and
+	// minimum age. The resources of all the namespaces are selected unless namespaces or
+	// a namespaceSelector are set.
+	MatchResources ResourceDescription `json:"match" yaml:"match"`
+
+	// ExcludeResources selects the matched resources that are not deleted.
+	// +optional
+	ExcludeResources ResourceDescription `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+
+	// Conditions are evaluated on each matched resource, available as "{{ request.object }}",
+	// and the resource is deleted only if they pass, e.g. the Jobs whose status has a
+	// completion time. Optional.
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Conditions *AnyAllConditions `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+
+	// Schedule is the cron schedule of the cleanup in the standard five fields format,
+	// e.g. "0 2 * * *" to delete the resources every day at 2am UTC.
+	Schedule string `json:"schedule" yaml:"schedule"`
+}
+
+// CleanupPolicyStatus contains the result of the last cleanup.
+type CleanupPolicyStatus struct {
+
+	// LastExecutionTime is the time of the last cleanup.
+	// +optional
+	LastExecutionTime *metav1.Time `json:"lastExecutionTime,omitempty" yaml:"lastExecutionTime,omitempty"`
+
+	// DeletedCount is the number of resources deleted by the last cleanup.
+	// +optional
+	DeletedCount int `json:"deletedCount,omitempty" yaml:"deletedCount,omitempty"`
+
+	// Conditions are the conditions of the cleanup policy, the Ready condition is false
+	// when the schedule is invalid or the last cleanup failed.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+}
+
+// CleanupPolicyList is a list of CleanupPolicy instances.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CleanupPolicyList struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	metav1.ListMeta `json:"metadata" yaml:"metadata"`
+	Items           []CleanupPolicy `json:"items" yaml:"items"`
+}
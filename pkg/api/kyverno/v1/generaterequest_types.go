@@ -62,6 +62,19 @@ type RequestInfo struct {
 	// UserInfo is the userInfo carried in the admission request.
 	// +optional
 	AdmissionUserInfo authenticationv1.UserInfo `json:"userInfo" yaml:"userInfo"`
+
+	// Operation is the operation of the admission request, e.g. CREATE.
+	// +optional
+	Operation string `json:"operation,omitempty" yaml:"operation,omitempty"`
+
+	// DryRun is set if the admission request is a dry-run.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+
+	// SubResource is the resource and the subresource of the admission request, e.g. "pods/exec".
+	// It is empty for the requests of the resources.
+	// +optional
+	SubResource string `json:"subResource,omitempty" yaml:"subResource,omitempty"`
 }
 
 // GenerateRequestStatus stores the status of generated request.
@@ -73,6 +86,19 @@ type GenerateRequestStatus struct {
 	// +optional
 	Message string `json:"message,omitempty" yaml:"message,omitempty"`
 
+	// Retries is the number of failed attempts to process the request since it last succeeded.
+	// The request is retried with an exponential backoff and is Failed after the maximum number of retries.
+	// +optional
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+
+	// FailureReason is the reason of the last failed attempt, e.g. PermissionDenied.
+	// +optional
+	FailureReason FailureReason `json:"failureReason,omitempty" yaml:"failureReason,omitempty"`
+
+	// LastFailureTime is the time of the last failed attempt.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty" yaml:"lastFailureTime,omitempty"`
+
 	// This will track the resources that are generated by the generate Policy.
 	// Will be used during clean up resources.
 	GeneratedResources []ResourceSpec `json:"generatedResources,omitempty" yaml:"generatedResources,omitempty"`
@@ -85,11 +111,39 @@ const (
 	// Pending - the Request is yet to be processed or resource has not been created.
 	Pending GenerateRequestState = "Pending"
 
-	// Failed - the Generate Request Controller failed to process the rules.
+	// Failed - the Generate Request Controller failed to process the rules after the maximum number of retries.
+	// The request is processed again when the policy or the trigger resource changes.
 	Failed GenerateRequestState = "Failed"
 
 	// Completed - the Generate Request Controller created resources defined in the policy.
 	Completed GenerateRequestState = "Completed"
+
+	// Skipped - the Generate Request Controller did not create the resources, e.g. as they would exceed the namespace quota.
+	// The request is processed again when the blocking condition changes.
+	Skipped GenerateRequestState = "Skipped"
+)
+
+// FailureReason is the reason a generate request failed.
+type FailureReason string
+
+const (
+	// PermissionDenied - Kyverno is not allowed to get, create or update a resource.
+	PermissionDenied FailureReason = "PermissionDenied"
+
+	// ResourceNotFound - a resource the rule depends on, e.g. the clone source, does not exist.
+	ResourceNotFound FailureReason = "ResourceNotFound"
+
+	// Conflict - the generated resource was changed concurrently or exists already.
+	Conflict FailureReason = "Conflict"
+
+	// InvalidPolicy - the rule or the resource it generates is invalid, e.g. a variable cannot be resolved.
+	InvalidPolicy FailureReason = "InvalidPolicy"
+
+	// APIServerError - the API server failed to process a request, e.g. it timed out or is unavailable.
+	APIServerError FailureReason = "APIServerError"
+
+	// UnknownError - the failure does not match any of the reasons above.
+	UnknownError FailureReason = "UnknownError"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
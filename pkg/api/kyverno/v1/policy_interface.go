@@ -0,0 +1,116 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyInterface abstracts the ClusterPolicy and the namespaced Policy, so that both are handled
+// alike without converting the Policy to a ClusterPolicy
+type PolicyInterface interface {
+	metav1.Object
+
+	// GetKind returns "ClusterPolicy" or "Policy", also when the TypeMeta is empty as for the lister results
+	GetKind() string
+
+	// IsNamespaced checks if the policy is a namespaced Policy
+	IsNamespaced() bool
+
+	// GetSpec returns the spec of the policy
+	GetSpec() *Spec
+
+	// GetStatus returns the status of the policy
+	GetStatus() *PolicyStatus
+
+	// CreateDeepCopy returns a deep copy of the policy
+	CreateDeepCopy() PolicyInterface
+
+	// RemoveUnsupportedRules removes the rules listed in status.unsupportedRules from the policy
+	RemoveUnsupportedRules()
+
+	// AsClusterPolicy returns the policy as the ClusterPolicy evaluated by the engine. The namespace, labels
+	// and annotations of a Policy are kept and its kind remains Policy.
+	AsClusterPolicy() *ClusterPolicy
+}
+
+var (
+	_ PolicyInterface = &ClusterPolicy{}
+	_ PolicyInterface = &Policy{}
+)
+
+// GetKind returns "ClusterPolicy", or "Policy" for a Policy converted to a ClusterPolicy
+func (p *ClusterPolicy) GetKind() string {
+	if p.IsNamespaced() {
+		return "Policy"
+	}
+
+	return "ClusterPolicy"
+}
+
+// IsNamespaced checks if the ClusterPolicy is a converted Policy
+func (p *ClusterPolicy) IsNamespaced() bool {
+	return p.GetNamespace() != ""
+}
+
+// GetSpec returns the spec of the policy
+func (p *ClusterPolicy) GetSpec() *Spec {
+	return &p.Spec
+}
+
+// GetStatus returns the status of the policy
+func (p *ClusterPolicy) GetStatus() *PolicyStatus {
+	return &p.Status
+}
+
+// CreateDeepCopy returns a deep copy of the policy
+func (p *ClusterPolicy) CreateDeepCopy() PolicyInterface {
+	return p.DeepCopy()
+}
+
+// AsClusterPolicy returns the ClusterPolicy
+func (p *ClusterPolicy) AsClusterPolicy() *ClusterPolicy {
+	return p
+}
+
+// GetKind returns "Policy"
+func (p *Policy) GetKind() string {
+	return "Policy"
+}
+
+// IsNamespaced returns true
+func (p *Policy) IsNamespaced() bool {
+	return true
+}
+
+// GetSpec returns the spec of the policy
+func (p *Policy) GetSpec() *Spec {
+	return &p.Spec
+}
+
+// GetStatus returns the status of the policy
+func (p *Policy) GetStatus() *PolicyStatus {
+	return &p.Status
+}
+
+// CreateDeepCopy returns a deep copy of the policy
+func (p *Policy) CreateDeepCopy() PolicyInterface {
+	return p.DeepCopy()
+}
+
+// AsClusterPolicy converts the Policy to a ClusterPolicy. The kind remains Policy, also when the TypeMeta
+// of the Policy is empty as for the lister results, so the converted policy is reported as a Policy.
+func (p *Policy) AsClusterPolicy() *ClusterPolicy {
+	cpol := ClusterPolicy(*p)
+	if cpol.Kind == "" {
+		cpol.Kind = "Policy"
+	}
+	if cpol.APIVersion == "" {
+		cpol.APIVersion = SchemeGroupVersion.String()
+	}
+	return &cpol
+}
+
+// RemoveUnsupportedRules removes the rules listed in status.unsupportedRules from the policy,
+// the rules use fields unknown to this version and are not applied
+func (p *Policy) RemoveUnsupportedRules() {
+	p.Spec.Rules = supportedRules(p.Spec.Rules, p.Status.UnsupportedRules)
+}
@@ -54,8 +54,110 @@ type Spec struct {
 	// uses variables that are only available in the admission review request (e.g. user name).
 	// +optional
 	Background *bool `json:"background,omitempty" yaml:"background,omitempty"`
+
+	// Schedule is the cron schedule of the background scans of the policy in the standard five
+	// fields format, e.g. "0 */6 * * *" to scan the existing resources every 6 hours. The policy
+	// is also scanned when it is created or updated. Optional. By default the policy is scanned
+	// at the interval set with the "--backgroundScanInterval" flag.
+	// +optional
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+
+	// MutateExistingOnPolicyUpdate controls if the mutate rules are applied to the existing resources
+	// when the policy is created or updated. The matching resources are patched in the background,
+	// the policy must be processed in the background. Optional. Default value is "false".
+	// +optional
+	MutateExistingOnPolicyUpdate bool `json:"mutateExistingOnPolicyUpdate,omitempty" yaml:"mutateExistingOnPolicyUpdate,omitempty"`
+
+	// Admission controls if rules are applied to admission review requests. Optional.
+	// Default value is "true". Set it to "false" for background only policies, e.g.
+	// compliance scans of existing resources.
+	// +optional
+	Admission *bool `json:"admission,omitempty" yaml:"admission,omitempty"`
+
+	// Enabled controls if the policy is applied. A disabled policy is kept but it is not
+	// applied to admission review requests or during background scans, and no results are
+	// reported for it. Optional. Default value is "true".
+	// +optional
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	// FailurePolicy defines how errors while processing the policy rules, e.g. when a context
+	// entry cannot be loaded, are handled in admission review requests. With "Fail" the rule
+	// fails and the request is denied if the policy enforces it. With "Ignore" the error is
+	// reported and the request is not denied by the policy. When set to "Fail" explicitly, the
+	// policy is also served by the resource webhooks
+	// whose failure policy is "Fail", so that the requests it matches are rejected while Kyverno is
+	// unavailable. The other policies are served by the webhooks whose failure policy is "Ignore".
+	// Optional. Default value is "Fail".
+	// +optional
+	FailurePolicy FailurePolicyType `json:"failurePolicy,omitempty" yaml:"failurePolicy,omitempty"`
+
+	// WebhookTimeoutSeconds is the timeout of the resource webhook calls for the policy. The
+	// webhooks serving several policies use the largest timeout of their policies. Optional.
+	// By default the timeout set with the "--webhooktimeout" flag is used.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	// +optional
+	WebhookTimeoutSeconds *int32 `json:"webhookTimeoutSeconds,omitempty" yaml:"webhookTimeoutSeconds,omitempty"`
+
+	// RolloutPercentage gradually rolls out an enforce policy by namespace. The policy is
+	// enforced in the namespaces whose name hashes below the percentage and audited in the
+	// other namespaces. The assignment of a namespace is deterministic. Optional. By default
+	// the validationFailureAction applies to all namespaces.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	RolloutPercentage *int `json:"rolloutPercentage,omitempty" yaml:"rolloutPercentage,omitempty"`
+
+	// AllowNamespaceThresholds lets the namespaces lower the enforcement of the policy with the
+	// "policies.kyverno.io/enforcement-threshold" annotation, set to "critical", "high" or
+	// "medium". The failures of an enforce policy whose severity is below the threshold of the
+	// namespace are audited. Optional. Default value is "false".
+	// +optional
+	AllowNamespaceThresholds *bool `json:"allowNamespaceThresholds,omitempty" yaml:"allowNamespaceThresholds,omitempty"`
+
+	// MutateDryRun evaluates the mutate rules without applying them. The patches are reported
+	// in a policy report result with the status "warn" but they are not returned in the
+	// admission review response. Optional. Default value is "false".
+	// +optional
+	MutateDryRun bool `json:"mutateDryRun,omitempty" yaml:"mutateDryRun,omitempty"`
+
+	// ShadowEnforce evaluates the validate rules of an enforce policy without denying the
+	// requests. The failures are reported in the policy reports and the events as for an
+	// audit policy, to measure the requests that would be denied before the policy is
+	// enforced. Optional. Default value is "false".
+	// +optional
+	ShadowEnforce bool `json:"shadowEnforce,omitempty" yaml:"shadowEnforce,omitempty"`
+
+	// Priority orders the mutate policies applied to a resource. The policies are applied in
+	// increasing order of priority, and the policies of the same priority in order of name, so
+	// that the patches of a policy override the patches of the policies with a lower priority
+	// to the same fields. Optional. Default value is "0".
+	// +optional
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
 }
 
+// FailurePolicyType specifies how errors while processing a policy are handled.
+// +kubebuilder:validation:Enum=Ignore;Fail
+type FailurePolicyType string
+
+const (
+	// Ignore means that an error while processing the policy is reported and otherwise ignored.
+	Ignore FailurePolicyType = "Ignore"
+	// Fail means that an error while processing the policy fails the rule.
+	Fail FailurePolicyType = "Fail"
+)
+
+// RuleScope specifies what the results of a rule depend on.
+// +kubebuilder:validation:Enum=Resource;Namespace
+type RuleScope string
+
+const (
+	// ResourceScope means that the rule is evaluated for each resource.
+	ResourceScope RuleScope = "Resource"
+	// NamespaceScope means that the rule result is the same for all the resources of a namespace.
+	NamespaceScope RuleScope = "Namespace"
+)
+
 // Rule defines a validation, mutation, or generation control for matching resources.
 // Each rules contains a match declaration to select resources, and an optional exclude
 // declaration to specify which resources to exclude.
@@ -65,6 +167,16 @@ type Rule struct {
 	// +kubebuilder:validation:MaxLength=63
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 
+	// ID identifies the rule in an external catalog of controls, e.g. "SEC-014". It is reported
+	// with the rule results in the deny messages, events and policy reports.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+
+	// DocumentationURL is the URL of the documentation of the rule, it is reported with the ID.
+	// +optional
+	DocumentationURL string `json:"documentationURL,omitempty" yaml:"documentationURL,omitempty"`
+
 	// Context defines variables and data sources that can be used during rule execution.
 	// +optional
 	Context []ContextEntry `json:"context,omitempty" yaml:"context,omitempty"`
@@ -90,6 +202,23 @@ type Rule struct {
 	// +optional
 	AnyAllConditions apiextensions.JSON `json:"preconditions,omitempty" yaml:"preconditions,omitempty"`
 
+	// TimeWindows restrict the rule to the time windows, the rule is applied if the admission
+	// time, or the scan time of the background scans, is in one of the windows.
+	// +optional
+	TimeWindows []TimeWindow `json:"timeWindows,omitempty" yaml:"timeWindows,omitempty"`
+
+	// ApplyToDeleting applies the validate and mutate rule to the resources being deleted, i.e. with a
+	// deletion timestamp, e.g. to guard the removal of the finalizers. By default these resources are
+	// skipped, so that the updates removing the finalizers are not blocked and the deletions complete.
+	// +optional
+	ApplyToDeleting bool `json:"applyToDeleting,omitempty" yaml:"applyToDeleting,omitempty"`
+
+	// Scope is the scope of the rule results, Resource or Namespace. The results of the rules with
+	// the Namespace scope depend only on the namespace of the resource and are cached by namespace
+	// until the namespace or the referenced ConfigMaps change. Defaults to Resource.
+	// +optional
+	Scope RuleScope `json:"scope,omitempty" yaml:"scope,omitempty"`
+
 	// Mutation is used to modify matching resources.
 	// +optional
 	Mutation Mutation `json:"mutate,omitempty" yaml:"mutate,omitempty"`
@@ -107,6 +236,26 @@ type Rule struct {
 	VerifyImages []*ImageVerification `json:"verifyImages,omitempty" yaml:"verifyImages,omitempty"`
 }
 
+// TimeWindow is a weekly recurring time range, e.g. Mon-Fri 09:00-17:00 in Europe/Berlin.
+type TimeWindow struct {
+	// Days are the days of the week the window starts on, e.g. "Mon", "Tuesday" or "Mon-Fri".
+	// Defaults to every day.
+	// +optional
+	Days []string `json:"days,omitempty" yaml:"days,omitempty"`
+
+	// Start is the time of day the window starts at, in the HH:MM format.
+	Start string `json:"start" yaml:"start"`
+
+	// End is the time of day the window ends at, in the HH:MM format. A window that ends before
+	// it starts spans midnight, e.g. 17:00-09:00 ends on the next day. Use "24:00" to end at midnight.
+	End string `json:"end" yaml:"end"`
+
+	// TimeZone is the IANA time zone of the window, e.g. "Europe/Berlin". The local time is
+	// used, daylight saving time transitions shift the window. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`
+}
+
 // AnyAllCondition consists of conditions wrapped denoting a logical criteria to be fulfilled.
 // AnyConditions get fulfilled when at least one of its sub-conditions passes.
 // AllConditions get fulfilled only when all of its sub-conditions pass.
@@ -136,7 +285,7 @@ type ContextEntry struct {
 	// ConfigMap is the ConfigMap reference.
 	ConfigMap *ConfigMapReference `json:"configMap,omitempty" yaml:"configMap,omitempty"`
 
-	// APICall defines an HTTP request to the Kubernetes API server. The JSON
+	// APICall defines an HTTP request to the Kubernetes API server or to a service. The JSON
 	// data retrieved is stored in the context.
 	APICall *APICall `json:"apiCall,omitempty" yaml:"apiCall,omitempty"`
 }
@@ -149,10 +298,30 @@ type ConfigMapReference struct {
 
 	// Namespace is the ConfigMap namespace.
 	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Fallbacks are the ConfigMaps tried in order when the ConfigMap is not found.
+	// +optional
+	Fallbacks []ConfigMapFallback `json:"fallbacks,omitempty" yaml:"fallbacks,omitempty"`
+
+	// Optional binds the context entry to null when neither the ConfigMap nor its
+	// fallbacks are found, instead of failing the rule.
+	// +optional
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
 }
 
-// APICall defines an HTTP request to the Kubernetes API server. The JSON
-// data retrieved is stored in the context. An APICall contains a URLPath
+// ConfigMapFallback refers to a ConfigMap tried when the previous ConfigMaps are not found
+type ConfigMapFallback struct {
+
+	// Name is the ConfigMap name.
+	Name string `json:"name" yaml:"name"`
+
+	// Namespace is the ConfigMap namespace, the namespace of the ConfigMap reference is used if not set.
+	// +optional
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+// APICall defines an HTTP request to the Kubernetes API server or to a service. The JSON
+// data retrieved is stored in the context. An APICall contains a URLPath or a Service
 // used to perform the HTTP GET request and an optional JMESPath used to
 // transform the retrieved JSON data.
 type APICall struct {
@@ -160,7 +329,30 @@ type APICall struct {
 	// URLPath is the URL path to be used in the HTTP GET request to the
 	// Kubernetes API server (e.g. "/api/v1/namespaces" or  "/apis/apps/v1/deployments").
 	// The format required is the same format used by the `kubectl get --raw` command.
-	URLPath string `json:"urlPath" yaml:"urlPath"`
+	// +optional
+	URLPath string `json:"urlPath,omitempty" yaml:"urlPath,omitempty"`
+
+	// Service is the service the HTTP GET request is sent to, e.g. an in-cluster
+	// inventory service. The response must be JSON.
+	// +optional
+	Service *ServiceCall `json:"service,omitempty" yaml:"service,omitempty"`
+
+	// Timeout is the timeout of the request to the service, e.g. "5s". Optional.
+	// Default value is "10s".
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// CacheTTL is the duration the response is reused for the requests to the same
+	// URL, e.g. "1m". The responses are not cached if not set.
+	// +optional
+	CacheTTL *metav1.Duration `json:"cacheTTL,omitempty" yaml:"cacheTTL,omitempty"`
+
+	// FailurePolicy defines how a failed request is handled. With "Fail" the rule
+	// fails, with "Ignore" the context entry is bound to null. Optional. Default
+	// value is "Fail".
+	// +kubebuilder:validation:Enum=Ignore;Fail
+	// +optional
+	FailurePolicy FailurePolicyType `json:"failurePolicy,omitempty" yaml:"failurePolicy,omitempty"`
 
 	// JMESPath is an optional JSON Match Expression that can be used to
 	// transform the JSON response returned from the API server. For example
@@ -171,6 +363,19 @@ type APICall struct {
 	JMESPath string `json:"jmesPath,omitempty" yaml:"jmesPath,omitempty"`
 }
 
+// ServiceCall is the service an APICall sends its request to.
+type ServiceCall struct {
+
+	// URL is the URL of the request, e.g. "http://inventory.tools.svc/images/{{request.object.metadata.name}}".
+	// The variables are substituted in the URL.
+	URL string `json:"url" yaml:"url"`
+
+	// CABundle is the PEM encoded CA bundle verifying the certificate of a HTTPS
+	// service, the system roots are used if not set.
+	// +optional
+	CABundle string `json:"caBundle,omitempty" yaml:"caBundle,omitempty"`
+}
+
 // Condition defines variable-based conditional criteria for rule execution.
 type Condition struct {
 	// Key is the context entry (using JMESPath) for conditional rule evaluation.
@@ -227,6 +432,13 @@ type MatchResources struct {
 	// ResourceDescription contains information about the resource being created or modified.
 	// Requires at least one tag to be specified when under MatchResources.
 	ResourceDescription `json:"resources,omitempty" yaml:"resources,omitempty"`
+
+	// IgnorePlatformMutations evaluates the validation patterns against the resource without
+	// the fields owned by the platform field managers, e.g. the defaults set by OpenShift
+	// SecurityContextConstraints, so that the fields the platform sets are not reported.
+	// The platform field managers are configured in the Kyverno ConfigMap.
+	// +optional
+	IgnorePlatformMutations bool `json:"ignorePlatformMutations,omitempty" yaml:"ignorePlatformMutations,omitempty"`
 }
 
 // ExcludeResources specifies resource and admission review request data for
@@ -254,11 +466,19 @@ type UserInfo struct {
 	// Subjects is the list of subject names like users, user groups, and service accounts.
 	// +optional
 	Subjects []rbacv1.Subject `json:"subjects,omitempty" yaml:"subjects,omitempty"`
+
+	// SubjectsKind is the kind of the requestor: "ServiceAccount" matches the requests of the service
+	// accounts, "User" the requests of the other users, and "Group" the requests matched by one of the
+	// Group subjects. The subjects must be of this kind. Unlike the other user info fields, it is
+	// combined with them with AND.
+	// +optional
+	SubjectsKind string `json:"subjectsKind,omitempty" yaml:"subjectsKind,omitempty"`
 }
 
 // ResourceDescription contains criteria used to match resources.
 type ResourceDescription struct {
-	// Kinds is a list of resource kinds.
+	// Kinds is a list of resource kinds. A kind can be qualified with a subresource, e.g. "pods/exec",
+	// "pods/ephemeralcontainers" or "services/status", to match the admission requests of the subresource.
 	// +optional
 	Kinds []string `json:"kinds,omitempty" yaml:"kinds,omitempty"`
 
@@ -298,8 +518,40 @@ type ResourceDescription struct {
 	// does not match an empty label set.
 	// +optional
 	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+
+	// MinimumAge matches the resources created at least the duration ago, e.g. "2160h" for 90 days.
+	// The age is evaluated against the creation timestamp of the resource at the scan time, it is
+	// only supported in the match block of the rules of background only policies.
+	// +optional
+	MinimumAge *metav1.Duration `json:"minimumAge,omitempty" yaml:"minimumAge,omitempty"`
+
+	// Operations is a list of the admission request operations: CREATE, UPDATE, DELETE or CONNECT.
+	// The resource webhooks are only registered for the operations matched by the rules.
+	// +optional
+	Operations []string `json:"operations,omitempty" yaml:"operations,omitempty"`
+
+	// DryRun matches the dry-run admission requests: true, false or any. The dry-run requests
+	// are always sent to Kyverno, the webhooks have no side effects on dry-run.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	DryRun DryRunMatch `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
 }
 
+// DryRunMatch matches the dry-run admission requests, it is set as a boolean or as "any".
+type DryRunMatch string
+
+const (
+	// DryRunTrue matches the dry-run requests only
+	DryRunTrue DryRunMatch = "true"
+
+	// DryRunFalse matches the requests which are not dry-run
+	DryRunFalse DryRunMatch = "false"
+
+	// DryRunAny matches all requests, like an unset dryRun
+	DryRunAny DryRunMatch = "any"
+)
+
 // Mutation defines how resource are modified.
 type Mutation struct {
 	// Overlay specifies an overlay pattern to modify resources.
@@ -328,6 +580,38 @@ type Mutation struct {
 	// See https://tools.ietf.org/html/rfc6902 and https://kubectl.docs.kubernetes.io/references/kustomize/patchesjson6902/.
 	// +optional
 	PatchesJSON6902 string `json:"patchesJson6902,omitempty" yaml:"patchesJson6902,omitempty"`
+
+	// PreserveExisting only fills in the fields the resource leaves empty, the fields already
+	// set in the resource are removed from the patchStrategicMerge or overlay patch. The elements
+	// of the lists are matched by name. Optional. Default value is "false".
+	// +optional
+	PreserveExisting bool `json:"preserveExisting,omitempty" yaml:"preserveExisting,omitempty"`
+
+	// ForEachMutation applies the patchStrategicMerge patches to each element of the lists
+	// selected in the request, e.g. the containers of a pod.
+	// +optional
+	ForEachMutation []ForEachMutation `json:"foreach,omitempty" yaml:"foreach,omitempty"`
+}
+
+// ForEachMutation applies a patch to each element of a list. The element is available in the
+// variable "element", and its index in the list in the variable "elementIndex".
+type ForEachMutation struct {
+	// List is a JMESPath expression returning the list of elements, e.g. "request.object.spec.containers".
+	List string `json:"list" yaml:"list"`
+
+	// Context defines variables and data sources loaded for each element.
+	// +optional
+	Context []ContextEntry `json:"context,omitempty" yaml:"context,omitempty"`
+
+	// Preconditions select the elements that are patched, they can reference the element variables.
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Preconditions apiextensions.JSON `json:"preconditions,omitempty" yaml:"preconditions,omitempty"`
+
+	// PatchStrategicMerge is a strategic merge patch applied to the resource for each element,
+	// e.g. a patch of the container named "{{element.name}}".
+	// +kubebuilder:validation:XPreserveUnknownFields
+	PatchStrategicMerge apiextensions.JSON `json:"patchStrategicMerge,omitempty" yaml:"patchStrategicMerge,omitempty"`
 }
 
 // +k8s:deepcopy-gen=false
@@ -356,6 +640,12 @@ type Validation struct {
 	// +optional
 	Message string `json:"message,omitempty" yaml:"message,omitempty"`
 
+	// Messages are the variants of the message by locale, e.g. "de" or "fr-CA". The variant of
+	// the locale of the request is displayed on failure, and the variant of the default locale
+	// is reported. Message is used for the locales without a variant.
+	// +optional
+	Messages map[string]string `json:"messages,omitempty" yaml:"messages,omitempty"`
+
 	// Pattern specifies an overlay-style pattern used to check resources.
 	// +kubebuilder:validation:XPreserveUnknownFields
 	// +optional
@@ -370,6 +660,102 @@ type Validation struct {
 	// Deny defines conditions used to pass or fail a validation rule.
 	// +optional
 	Deny *Deny `json:"deny,omitempty" yaml:"deny,omitempty"`
+
+	// Deprecations fails the resources that use an API version removed in the target Kubernetes version,
+	// e.g. an Ingress in extensions/v1beta1, and names the replacement API in the message.
+	// +optional
+	Deprecations *Deprecations `json:"deprecations,omitempty" yaml:"deprecations,omitempty"`
+
+	// References fails the resources that reference objects that do not exist, e.g. an Ingress
+	// referencing a missing TLS Secret, and names each missing reference in the message.
+	// +optional
+	References []Reference `json:"references,omitempty" yaml:"references,omitempty"`
+
+	// CEL checks the request with CEL expressions, like a ValidatingAdmissionPolicy. The rule
+	// fails if one of the expressions is false.
+	// +optional
+	CEL *CEL `json:"cel,omitempty" yaml:"cel,omitempty"`
+
+	// ForEachValidation applies the validate checks to each element of the lists selected in
+	// the request, e.g. the containers of a pod. The rule fails if one of the elements fails.
+	// +optional
+	ForEachValidation []ForEachValidation `json:"foreach,omitempty" yaml:"foreach,omitempty"`
+}
+
+// ForEachValidation applies a pattern, an anyPattern or deny conditions to each element of a list.
+// The element is available in the variable "element", and its index in the list in the variable "elementIndex".
+type ForEachValidation struct {
+	// List is a JMESPath expression returning the list of elements, e.g. "request.object.spec.containers".
+	List string `json:"list" yaml:"list"`
+
+	// Context defines variables and data sources loaded for each element.
+	// +optional
+	Context []ContextEntry `json:"context,omitempty" yaml:"context,omitempty"`
+
+	// Preconditions select the elements that are checked, they can reference the element variables.
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Preconditions apiextensions.JSON `json:"preconditions,omitempty" yaml:"preconditions,omitempty"`
+
+	// Pattern specifies an overlay-style pattern checked on each element.
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Pattern apiextensions.JSON `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+
+	// AnyPattern specifies a list of patterns, each element must satisfy at least one of them.
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	AnyPattern apiextensions.JSON `json:"anyPattern,omitempty" yaml:"anyPattern,omitempty"`
+
+	// Deny defines conditions that fail the rule for an element.
+	// +optional
+	Deny *Deny `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// CEL declares the CEL expressions of a validate rule.
+type CEL struct {
+	// Expressions are the CEL expressions checked in order. The variables "object", "oldObject" and
+	// "request" are the new object, the old object and the admission request. "object" is null for
+	// the DELETE requests and "oldObject" is null for the CREATE requests.
+	Expressions []CELExpression `json:"expressions" yaml:"expressions"`
+}
+
+// CELExpression is a CEL expression returning a boolean, e.g. "object.spec.replicas <= 5".
+type CELExpression struct {
+	// Expression is the CEL expression, the resource fails if it is false.
+	Expression string `json:"expression" yaml:"expression"`
+
+	// Message is displayed when the expression is false. Defaults to "failed expression: <expression>".
+	// +optional
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// Reference selects the names of the objects referenced by a resource, the referenced objects must exist.
+type Reference struct {
+	// Path is a JMESPath expression on the resource returning the name, or a list of names,
+	// of the referenced objects, e.g. "spec.tls[].secretName".
+	Path string `json:"path" yaml:"path"`
+
+	// Kind is the kind of the referenced objects, e.g. "Secret".
+	Kind string `json:"kind" yaml:"kind"`
+
+	// Namespace is the namespace of the referenced objects. Defaults to the namespace of the resource,
+	// it is ignored for cluster-wide kinds.
+	// +optional
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// AllowMissing passes the resources that do not reference any object at the path,
+	// e.g. the Ingresses without TLS. By default, a resource must reference an object.
+	// +optional
+	AllowMissing bool `json:"allowMissing,omitempty" yaml:"allowMissing,omitempty"`
+}
+
+// Deprecations checks the API versions of the resources against the APIs removed in a Kubernetes version.
+type Deprecations struct {
+	// TargetVersion is the Kubernetes version the resources are checked against, e.g. "1.25".
+	// Defaults to the minor version after the version of the cluster.
+	// +optional
+	TargetVersion string `json:"targetVersion,omitempty" yaml:"targetVersion,omitempty"`
 }
 
 // Deny specifies a list of conditions used to pass or fail a validation rule.
@@ -383,8 +769,9 @@ type Deny struct {
 }
 
 // ImageVerification validates that images that match the specified pattern
-// are signed with the supplied public key. Once the image is verified it is
-// mutated to include the SHA digest retrieved during the registration.
+// are signed with the supplied public keys, or with keyless signatures when no key
+// is supplied. Once the image is verified it is mutated to include the SHA digest
+// retrieved during the registration.
 type ImageVerification struct {
 
 	// Image is the image name consisting of the registry address, repository, image, and tag.
@@ -393,6 +780,93 @@ type ImageVerification struct {
 
 	// Key is the PEM encoded public key that the image is signed with.
 	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+
+	// Keys are the PEM encoded public keys that the image is signed with, the number of keys
+	// required is set by Count. At most one of Key or Keys must be specified.
+	// +optional
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+
+	// Count is the minimum number of the Keys that the image must be signed with, e.g. 1
+	// to accept a signature by any of the keys. Defaults to all the keys.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Count int `json:"count,omitempty" yaml:"count,omitempty"`
+
+	// Subject is the identity of the keyless signer, i.e. the email or the URI in the
+	// certificate issued by Fulcio, e.g. "https://github.com/acme/*". Wildcards ('*' and '?')
+	// are allowed. The keyless signatures are verified when neither Key nor Keys are specified.
+	// +optional
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+
+	// Roots are the PEM encoded root certificates of the keyless signatures.
+	// Defaults to the roots of the public Fulcio instance.
+	// +optional
+	Roots string `json:"roots,omitempty" yaml:"roots,omitempty"`
+
+	// Rekor is the URL of the Rekor transparency log the signatures are checked against.
+	// The keyless signatures are always checked, against the public Rekor instance by default.
+	// The signatures of the keys are only checked if the URL is set.
+	// +optional
+	Rekor string `json:"rekor,omitempty" yaml:"rekor,omitempty"`
+
+	// ImagePullSecrets are the names of the image pull secrets of the Kyverno namespace used to access the
+	// registry of the image, in addition to the image pull secrets configured globally. The credentials of
+	// the secrets are resolved by registry.
+	// +optional
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty" yaml:"imagePullSecrets,omitempty"`
+
+	// Attestations are the in-toto attestations the image must have, e.g. the SLSA provenance
+	// of its build. The attestations are verified with the keys, each attestation must be
+	// signed with one of them.
+	// +optional
+	Attestations []Attestation `json:"attestations,omitempty" yaml:"attestations,omitempty"`
+}
+
+// Attestation checks the in-toto attestations of a predicate type attached to an image.
+type Attestation struct {
+
+	// PredicateType is the type of the in-toto predicate, e.g. "https://slsa.dev/provenance/v0.1".
+	PredicateType string `json:"predicateType" yaml:"predicateType"`
+
+	// Conditions are checked on the attestations of the predicate type, the image is verified
+	// if one of its attestations satisfies the conditions. The in-toto statement is available
+	// in the variable "attestation", e.g. "{{attestation.predicate.builder.id}}".
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Conditions apiextensions.JSON `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+
+	// SeverityFrom computes the severity of the rule result from the predicate of the attestations,
+	// e.g. from the highest CVSS score of a vulnerability scan. The severity of the policy is reported
+	// if the score is missing or is not a number.
+	// +optional
+	SeverityFrom *SeverityFrom `json:"severityFrom,omitempty" yaml:"severityFrom,omitempty"`
+}
+
+// SeverityFrom maps a score of the predicate of an attestation to a severity.
+type SeverityFrom struct {
+
+	// JMESPath is the JMESPath expression returning the score from the predicate,
+	// e.g. "max(vulnerabilities[].cvss)".
+	JMESPath string `json:"jmesPath" yaml:"jmesPath"`
+
+	// Thresholds are the minimum scores of the severities, the lower scores are "low".
+	Thresholds SeverityThresholds `json:"thresholds" yaml:"thresholds"`
+}
+
+// SeverityThresholds are the minimum scores of the severities, a threshold is ignored if not set.
+type SeverityThresholds struct {
+
+	// Critical is the minimum score of the critical severity, e.g. 9.
+	// +optional
+	Critical int `json:"critical,omitempty" yaml:"critical,omitempty"`
+
+	// High is the minimum score of the high severity, e.g. 7.
+	// +optional
+	High int `json:"high,omitempty" yaml:"high,omitempty"`
+
+	// Medium is the minimum score of the medium severity, e.g. 4.
+	// +optional
+	Medium int `json:"medium,omitempty" yaml:"medium,omitempty"`
 }
 
 // Generation defines how new resources should be created and managed.
@@ -408,6 +882,13 @@ type Generation struct {
 	// +optional
 	Synchronize bool `json:"synchronize,omitempty" yaml:"synchronize,omitempty"`
 
+	// OwnerReference sets the trigger resource as the owner of the generated resources, so that they
+	// are garbage collected when the trigger resource is deleted. The owner reference is only set when
+	// the trigger resource is cluster-scoped or in the namespace of the generated resource.
+	// Optional. Defaults to "false" if not specified.
+	// +optional
+	OwnerReference bool `json:"ownerReference,omitempty" yaml:"ownerReference,omitempty"`
+
 	// Data provides the resource declaration used to populate each generated resource.
 	// At most one of Data or Clone must be specified. If neither are provided, the generated
 	// resource will be created with default data only.
@@ -420,6 +901,27 @@ type Generation struct {
 	// resource will be created with default data only.
 	// +optional
 	Clone CloneFrom `json:"clone,omitempty" yaml:"clone,omitempty"`
+
+	// CloneList specifies the source resources cloned into the namespace of the generate rule, each
+	// resource of the kinds matching the selector in the source namespace is cloned with its name.
+	// The kind and the name of the generate rule are not used. Only one of Data, Clone or CloneList
+	// can be specified.
+	// +optional
+	CloneList CloneList `json:"cloneList,omitempty" yaml:"cloneList,omitempty"`
+
+	// ForEachNamespace generates the resource in each existing namespace matching the selector,
+	// when the policy is created or updated and when the namespace labels change, instead of on
+	// the admission of the matched resources. The generated resources are removed from the
+	// namespaces that no longer match.
+	// +optional
+	ForEachNamespace *ForEachNamespace `json:"forEachNamespace,omitempty" yaml:"forEachNamespace,omitempty"`
+}
+
+// ForEachNamespace selects the namespaces a resource is generated in.
+type ForEachNamespace struct {
+
+	// Selector is a label selector for the namespaces the resource is generated in.
+	Selector *metav1.LabelSelector `json:"selector,omitempty" yaml:"selector,omitempty"`
 }
 
 // CloneFrom provides the location of the source resource used to generate target resources.
@@ -434,6 +936,21 @@ type CloneFrom struct {
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 }
 
+// CloneList selects the source resources used to generate target resources.
+type CloneList struct {
+
+	// Namespace specifies the namespace of the source resources.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Kinds specifies the kinds of the source resources, e.g. ConfigMap or v1/Secret.
+	Kinds []string `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+
+	// Selector is a label selector for the source resources, all the resources of the kinds
+	// are cloned if it is not specified.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty" yaml:"selector,omitempty"`
+}
+
 // PolicyStatus mostly contains runtime information related to policy execution.
 // Deprecated. Policy metrics are now available via the "/metrics" endpoint.
 // See: https://kyverno.io/docs/monitoring-kyverno-with-prometheus-metrics/
@@ -469,8 +986,54 @@ type PolicyStatus struct {
 	// Rules provides per rule statistics
 	// +optional
 	Rules []RuleStats `json:"ruleStatus,omitempty" yaml:"ruleStatus,omitempty"`
+
+	// PolicySet is the name of the PolicySet this policy is a member of, if any.
+	// +optional
+	PolicySet string `json:"policySet,omitempty" yaml:"policySet,omitempty"`
+
+	// ValidationFailureAction is the effective validation failure action when it is
+	// overridden by a PolicySet. It takes precedence over spec.validationFailureAction.
+	// +optional
+	ValidationFailureAction string `json:"validationFailureAction,omitempty" yaml:"validationFailureAction,omitempty"`
+
+	// Conditions reports the observed state of the policy, e.g. "Suspended".
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+
+	// UnsupportedRules are the names of the rules using fields unknown to this version of
+	// Kyverno, e.g. rules authored for a newer version. They are not applied.
+	// +optional
+	UnsupportedRules []string `json:"unsupportedRules,omitempty" yaml:"unsupportedRules,omitempty"`
 }
 
+const (
+	// PolicyConditionSuspended is the condition type set on policies that are not applied
+	// because they are suspended, e.g. by a disabled PolicySet.
+	PolicyConditionSuspended = "Suspended"
+
+	// PolicyConditionDisabled is the condition type set on policies that are disabled
+	// with spec.enabled set to "false".
+	PolicyConditionDisabled = "Disabled"
+
+	// PolicyConditionKindNotFound is the condition type set on policies with rules that
+	// match kinds which are not known to the API server, e.g. misspelled kinds.
+	PolicyConditionKindNotFound = "KindNotFound"
+
+	// PolicyConditionReady is the condition type set on policies once the resource webhooks
+	// are configured for the kinds they match. The observedGeneration of the condition is the
+	// generation of the policy that is applied to the admission requests.
+	PolicyConditionReady = "Ready"
+
+	// PolicyConditionIgnoredUnsupported is the condition type set on policies with rules that
+	// use fields unknown to this version of Kyverno, the rules are ignored.
+	PolicyConditionIgnoredUnsupported = "Ignored-Unsupported"
+
+	// PolicyConditionMutateExisting is the condition type set on policies that mutate the existing
+	// resources, it reports how many resources were patched. The observedGeneration of the condition
+	// is the generation of the policy that was applied to the existing resources.
+	PolicyConditionMutateExisting = "MutateExisting"
+)
+
 // RuleStats provides statistics for an individual rule within a policy.
 // Deprecated. Policy metrics are now available via the "/metrics" endpoint.
 // See: https://kyverno.io/docs/monitoring-kyverno-with-prometheus-metrics/
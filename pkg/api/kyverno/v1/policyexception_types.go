@@ -0,0 +1,51 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyException exempts the matching resources and requests from the named policies and rules.
+// The exceptions apply to the resources of the namespace of the PolicyException, the exceptions in
+// the Kyverno namespace apply to the resources of all the namespaces and to the cluster resources.
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=policyexceptions,scope="Namespaced",shortName=polex
+type PolicyException struct {
+	metav1.TypeMeta   `json:",inline,omitempty" yaml:",inline,omitempty"`
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Spec declares the exempted policy rules and the resources they do not apply to.
+	Spec PolicyExceptionSpec `json:"spec" yaml:"spec"`
+}
+
+// PolicyExceptionSpec declares the exempted policy rules and the resources they do not apply to.
+type PolicyExceptionSpec struct {
+
+	// Exceptions is the list of the exempted policy rules.
+	Exceptions []Exception `json:"exceptions" yaml:"exceptions"`
+
+	// Match selects the exempted resources and the requests of the exempted subjects.
+	Match MatchResources `json:"match" yaml:"match"`
+}
+
+// Exception names the exempted rules of a policy.
+type Exception struct {
+
+	// PolicyName is the name of the policy. The name of a namespaced policy is prefixed with its
+	// namespace, e.g. "default/require-labels".
+	PolicyName string `json:"policyName" yaml:"policyName"`
+
+	// RuleNames is the list of the exempted rules of the policy, the names may contain the wildcards
+	// "*" and "?". All the rules of the policy are exempted if empty.
+	// +optional
+	RuleNames []string `json:"ruleNames,omitempty" yaml:"ruleNames,omitempty"`
+}
+
+// PolicyExceptionList is a list of PolicyException instances.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PolicyExceptionList struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	metav1.ListMeta `json:"metadata" yaml:"metadata"`
+	Items           []PolicyException `json:"items" yaml:"items"`
+}
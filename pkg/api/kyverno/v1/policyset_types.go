@@ -0,0 +1,63 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicySet groups existing ClusterPolicy instances, selected by label, and applies
+// shared settings to all members. Member policy specs are never modified; the effective
+// settings are reported in the member policy status.
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=policysets,scope="Cluster",shortName=pset
+// +kubebuilder:printcolumn:name="Enabled",type="boolean",JSONPath=".spec.enabled"
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.validationFailureAction"
+type PolicySet struct {
+	metav1.TypeMeta   `json:",inline,omitempty" yaml:",inline,omitempty"`
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	// Spec declares the member selector and the shared settings.
+	Spec PolicySetSpec `json:"spec" yaml:"spec"`
+
+	// Status contains the resolved members of the policy set.
+	// +optional
+	Status PolicySetStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// PolicySetSpec selects member policies and holds settings shared by all members.
+type PolicySetSpec struct {
+
+	// PolicySelector is a label selector for member ClusterPolicy instances.
+	// An empty selector matches no policies.
+	PolicySelector *metav1.LabelSelector `json:"policySelector" yaml:"policySelector"`
+
+	// ValidationFailureAction overrides the validationFailureAction of all member
+	// policies. Optional. When empty, each member keeps its own setting.
+	// +kubebuilder:validation:Enum=audit;enforce
+	// +optional
+	ValidationFailureAction string `json:"validationFailureAction,omitempty" yaml:"validationFailureAction,omitempty"`
+
+	// Enabled controls whether member policies are applied. When set to "false" all
+	// members are suspended until the set is enabled again. Optional. Default value is "true".
+	// +optional
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// PolicySetStatus contains runtime information of a policy set.
+type PolicySetStatus struct {
+
+	// Members is the sorted list of ClusterPolicy names that currently belong to the set.
+	// +optional
+	Members []string `json:"members,omitempty" yaml:"members,omitempty"`
+}
+
+// PolicySetList is a list of PolicySet instances.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PolicySetList struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+	metav1.ListMeta `json:"metadata" yaml:"metadata"`
+	Items           []PolicySet `json:"items" yaml:"items"`
+}
@@ -31,12 +31,18 @@ var (
 // Adds the list of known types to Scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&CleanupPolicy{},
+		&CleanupPolicyList{},
 		&ClusterPolicy{},
 		&ClusterPolicyList{},
 		&GenerateRequest{},
 		&GenerateRequestList{},
 		&Policy{},
 		&PolicyList{},
+		&PolicyException{},
+		&PolicyExceptionList{},
+		&PolicySet{},
+		&PolicySetList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -2,8 +2,13 @@ package v1
 
 import (
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"reflect"
+	"strconv"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 // HasAutoGenAnnotation checks if a policy has auto-gen annotation
@@ -38,6 +43,17 @@ func (p *ClusterPolicy) HasMutate() bool {
 	return false
 }
 
+// HasForEachNamespace checks for generate rules creating the resources in the namespaces matching a selector
+func (p *ClusterPolicy) HasForEachNamespace() bool {
+	for _, rule := range p.Spec.Rules {
+		if rule.IsForEachNamespace() {
+			return true
+		}
+	}
+
+	return false
+}
+
 //HasVerifyImages checks for image verification rule types
 func (p *ClusterPolicy) HasVerifyImages() bool {
 	for _, rule := range p.Spec.Rules {
@@ -58,6 +74,191 @@ func (p *ClusterPolicy) BackgroundProcessingEnabled() bool {
 	return *p.Spec.Background
 }
 
+// AdmissionProcessingEnabled checks if admission is set to true
+func (p *ClusterPolicy) AdmissionProcessingEnabled() bool {
+	if p.Spec.Admission == nil {
+		return true
+	}
+
+	return *p.Spec.Admission
+}
+
+// IsEnabled checks if the policy is enabled, which is the default
+func (p *ClusterPolicy) IsEnabled() bool {
+	if p.Spec.Enabled == nil {
+		return true
+	}
+
+	return *p.Spec.Enabled
+}
+
+// IsMutateDryRun checks if the mutate rules are evaluated without applying the patches
+func (p *ClusterPolicy) IsMutateDryRun() bool {
+	return p.Spec.MutateDryRun
+}
+
+// IsShadowEnforce checks if the validate rules of an enforce policy are evaluated without denying the requests
+func (p *ClusterPolicy) IsShadowEnforce() bool {
+	return p.Spec.ShadowEnforce
+}
+
+// MutateExistingOnPolicyUpdate checks if the mutate rules are applied to the existing resources
+func (p *ClusterPolicy) MutateExistingOnPolicyUpdate() bool {
+	return p.Spec.MutateExistingOnPolicyUpdate && p.HasMutate()
+}
+
+// GetPriority returns the priority of the policy, the mutate policies are applied in increasing order of priority
+func (p *ClusterPolicy) GetPriority() int {
+	return p.Spec.Priority
+}
+
+// GetFailurePolicy returns the failure policy, which defaults to Fail
+func (p *ClusterPolicy) GetFailurePolicy() FailurePolicyType {
+	if p.Spec.FailurePolicy == "" {
+		return Fail
+	}
+
+	return p.Spec.FailurePolicy
+}
+
+// HasWebhookFailurePolicyFail checks if the failure policy is set to Fail explicitly, such a policy is
+// served by the resource webhooks whose failure policy is Fail
+func (p *ClusterPolicy) HasWebhookFailurePolicyFail() bool {
+	return p.Spec.FailurePolicy == Fail
+}
+
+// GetValidationFailureAction returns the effective validation failure action.
+// An override set in the status (e.g. by a PolicySet) takes precedence over the spec.
+func (p *ClusterPolicy) GetValidationFailureAction() string {
+	if p.Status.ValidationFailureAction != "" {
+		return p.Status.ValidationFailureAction
+	}
+
+	return p.Spec.ValidationFailureAction
+}
+
+// GetValidationFailureActionForNamespace returns the effective validation failure action for
+// resources in the namespace. While an enforce policy is rolled out, it is enforced only in the
+// namespaces whose rollout bucket is below spec.rolloutPercentage and audited in the others.
+func (p *ClusterPolicy) GetValidationFailureActionForNamespace(namespace string) string {
+	action := p.GetValidationFailureAction()
+	if p.Spec.RolloutPercentage == nil || action != "enforce" {
+		return action
+	}
+
+	if RolloutBucket(namespace) < *p.Spec.RolloutPercentage {
+		return "enforce"
+	}
+
+	return "audit"
+}
+
+// IsRollingOut checks if an enforce policy is rolled out to a percentage of the namespaces
+func (p *ClusterPolicy) IsRollingOut() bool {
+	return p.Spec.RolloutPercentage != nil && p.GetValidationFailureAction() == "enforce"
+}
+
+// RolloutBucket deterministically assigns the namespace to a bucket between 0 and 99.
+// Cluster-wide resources are assigned to the bucket of the empty namespace name.
+func RolloutBucket(namespace string) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32() % 100)
+}
+
+// SeverityAnnotation is the policy annotation set to the severity of its rules,
+// "critical", "high", "medium" or "low"
+const SeverityAnnotation = "policies.kyverno.io/severity"
+
+// EnforcementThresholdAnnotation is the namespace annotation set to the lowest severity,
+// "critical", "high" or "medium", of the enforce policies blocking the requests in the namespace
+const EnforcementThresholdAnnotation = "policies.kyverno.io/enforcement-threshold"
+
+// severityLevels orders the severities, an unknown severity is 0
+var severityLevels = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+// SeverityLevel returns the level of the severity, from 1 for "low" to 4 for "critical", or 0 if it is unknown
+func SeverityLevel(severity string) int {
+	return severityLevels[severity]
+}
+
+// AllowsNamespaceThresholds checks if the namespaces can lower the enforcement of the policy
+func (p *ClusterPolicy) AllowsNamespaceThresholds() bool {
+	return p.Spec.AllowNamespaceThresholds != nil && *p.Spec.AllowNamespaceThresholds
+}
+
+// GetValidationFailureActionForThreshold returns the effective validation failure action for
+// resources in the namespace with the enforcement threshold annotation. An enforce policy that
+// allows namespace thresholds is audited if its severity is below the threshold. A policy without
+// a known severity is treated as critical, so that its failures are always enforced.
+func (p *ClusterPolicy) GetValidationFailureActionForThreshold(namespace, threshold string) string {
+	action := p.GetValidationFailureActionForNamespace(namespace)
+	if action != "enforce" || !p.AllowsNamespaceThresholds() {
+		return action
+	}
+
+	thresholdLevel, ok := severityLevels[threshold]
+	if !ok {
+		return action
+	}
+
+	severityLevel, ok := severityLevels[p.GetAnnotations()[SeverityAnnotation]]
+	if !ok {
+		severityLevel = severityLevels["critical"]
+	}
+
+	if severityLevel < thresholdLevel {
+		return "audit"
+	}
+
+	return action
+}
+
+// RemoveUnsupportedRules removes the rules listed in status.unsupportedRules from the policy,
+// the rules use fields unknown to this version and are not applied
+func (p *ClusterPolicy) RemoveUnsupportedRules() {
+	p.Spec.Rules = supportedRules(p.Spec.Rules, p.Status.UnsupportedRules)
+}
+
+// supportedRules returns the rules without the unsupported rules
+func supportedRules(rules []Rule, unsupportedRules []string) []Rule {
+	if len(unsupportedRules) == 0 {
+		return rules
+	}
+
+	unsupported := make(map[string]bool, len(unsupportedRules))
+	for _, name := range unsupportedRules {
+		unsupported[name] = true
+	}
+
+	supported := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if !unsupported[rule.Name] {
+			supported = append(supported, rule)
+		}
+	}
+	return supported
+}
+
+// IsSuspended checks if the policy has the Suspended condition set
+func (p *ClusterPolicy) IsSuspended() bool {
+	return meta.IsStatusConditionTrue(p.Status.Conditions, PolicyConditionSuspended)
+}
+
+// IsEnabled checks if the policy set is enabled, which is the default
+func (ps *PolicySet) IsEnabled() bool {
+	if ps.Spec.Enabled == nil {
+		return true
+	}
+
+	return *ps.Spec.Enabled
+}
+
 // HasMutate checks for mutate rule
 func (r Rule) HasMutate() bool {
 	return !reflect.DeepEqual(r.Mutation, Mutation{})
@@ -68,6 +269,11 @@ func (r Rule) HasVerifyImages() bool {
 	return r.VerifyImages != nil && !reflect.DeepEqual(r.VerifyImages, ImageVerification{})
 }
 
+// IsNamespaceScoped checks if the results of the rule are the same for all the resources of a namespace
+func (r Rule) IsNamespaceScoped() bool {
+	return r.Scope == NamespaceScope
+}
+
 // HasValidate checks for validate rule
 func (r Rule) HasValidate() bool {
 	return !reflect.DeepEqual(r.Validation, Validation{})
@@ -78,6 +284,40 @@ func (r Rule) HasGenerate() bool {
 	return !reflect.DeepEqual(r.Generation, Generation{})
 }
 
+// HasValidateForEach checks for validate rules applying the checks to each element of a list
+func (r Rule) HasValidateForEach() bool {
+	return len(r.Validation.ForEachValidation) > 0
+}
+
+// HasMutateForEach checks for mutate rules applying the patches to each element of a list
+func (r Rule) HasMutateForEach() bool {
+	return len(r.Mutation.ForEachMutation) > 0
+}
+
+// ForEachContext returns the context entries of the foreach declarations of the rule
+func (r Rule) ForEachContext() []ContextEntry {
+	var entries []ContextEntry
+	for _, foreach := range r.Validation.ForEachValidation {
+		entries = append(entries, foreach.Context...)
+	}
+
+	for _, foreach := range r.Mutation.ForEachMutation {
+		entries = append(entries, foreach.Context...)
+	}
+
+	return entries
+}
+
+// IsForEachNamespace checks if the generate rule creates the resource in the namespaces matching a selector
+func (r Rule) IsForEachNamespace() bool {
+	return r.HasGenerate() && r.Generation.ForEachNamespace != nil
+}
+
+// HasCloneList checks if the generate rule clones a list of resources
+func (r Rule) HasCloneList() bool {
+	return r.HasGenerate() && len(r.Generation.CloneList.Kinds) > 0
+}
+
 // DeserializeAnyPattern deserialize apiextensions.JSON to []interface{}
 func (in *Validation) DeserializeAnyPattern() ([]interface{}, error) {
 	if in.AnyPattern == nil {
@@ -102,6 +342,12 @@ func (in *Validation) DeserializeAnyPattern() ([]interface{}, error) {
 func (in *Mutation) DeepCopyInto(out *Mutation) {
 	if out != nil {
 		*out = *in
+		if in.ForEachMutation != nil {
+			out.ForEachMutation = make([]ForEachMutation, len(in.ForEachMutation))
+			for i := range in.ForEachMutation {
+				in.ForEachMutation[i].DeepCopyInto(&out.ForEachMutation[i])
+			}
+		}
 	}
 }
 
@@ -120,11 +366,55 @@ func (pp *Patch) DeepCopyInto(out *Patch) {
 func (in *Validation) DeepCopyInto(out *Validation) {
 	if out != nil {
 		*out = *in
+		if in.References != nil {
+			out.References = make([]Reference, len(in.References))
+			copy(out.References, in.References)
+		}
+		if in.CEL != nil {
+			out.CEL = in.CEL.DeepCopy()
+		}
+		if in.Messages != nil {
+			out.Messages = make(map[string]string, len(in.Messages))
+			for key, val := range in.Messages {
+				out.Messages[key] = val
+			}
+		}
+		if in.ForEachValidation != nil {
+			out.ForEachValidation = make([]ForEachValidation, len(in.ForEachValidation))
+			for i := range in.ForEachValidation {
+				in.ForEachValidation[i].DeepCopyInto(&out.ForEachValidation[i])
+			}
+		}
+	}
+}
+func (in *ForEachValidation) DeepCopyInto(out *ForEachValidation) {
+	*out = *in
+	if in.Context != nil {
+		out.Context = make([]ContextEntry, len(in.Context))
+		for i := range in.Context {
+			in.Context[i].DeepCopyInto(&out.Context[i])
+		}
+	}
+	if in.Deny != nil {
+		out.Deny = in.Deny.DeepCopy()
+	}
+}
+func (in *ForEachMutation) DeepCopyInto(out *ForEachMutation) {
+	*out = *in
+	if in.Context != nil {
+		out.Context = make([]ContextEntry, len(in.Context))
+		for i := range in.Context {
+			in.Context[i].DeepCopyInto(&out.Context[i])
+		}
 	}
 }
 func (gen *Generation) DeepCopyInto(out *Generation) {
 	if out != nil {
 		*out = *gen
+		if gen.ForEachNamespace != nil {
+			out.ForEachNamespace = gen.ForEachNamespace.DeepCopy()
+		}
+		gen.CloneList.DeepCopyInto(&out.CloneList)
 	}
 }
 func (cond *Condition) DeepCopyInto(out *Condition) {
@@ -138,6 +428,17 @@ func (in *Deny) DeepCopyInto(out *Deny) {
 		out.AnyAllConditions = in.AnyAllConditions
 	}
 }
+func (in *Attestation) DeepCopyInto(out *Attestation) {
+	*out = *in
+	if in.Conditions != nil {
+		out.Conditions = in.Conditions
+	}
+	if in.SeverityFrom != nil {
+		in, out := &in.SeverityFrom, &out.SeverityFrom
+		*out = new(SeverityFrom)
+		**out = **in
+	}
+}
 func (in *Rule) DeepCopyInto(out *Rule) {
 	*out = *in
 	if in.Context != nil {
@@ -152,6 +453,13 @@ func (in *Rule) DeepCopyInto(out *Rule) {
 	if in.AnyAllConditions != nil {
 		out.AnyAllConditions = in.AnyAllConditions
 	}
+	if in.TimeWindows != nil {
+		in, out := &in.TimeWindows, &out.TimeWindows
+		*out = make([]TimeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.Mutation.DeepCopyInto(&out.Mutation)
 	in.Validation.DeepCopyInto(&out.Validation)
 	in.Generation.DeepCopyInto(&out.Generation)
@@ -162,7 +470,7 @@ func (in *Rule) DeepCopyInto(out *Rule) {
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
 				*out = new(ImageVerification)
-				**out = **in
+				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
@@ -187,4 +495,25 @@ type ViolatedRule struct {
 
 	// +optional
 	Check string `json:"check" yaml:"check"`
+
+	// Properties stores additional information on the rule result.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty" yaml:"properties,omitempty"`
+}
+
+// UnmarshalJSON accepts the dryRun match as a boolean or a string
+func (d *DryRunMatch) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*d = DryRunMatch(strconv.FormatBool(b))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("dryRun must be true, false or any: %v", err)
+	}
+
+	*d = DryRunMatch(s)
+	return nil
 }
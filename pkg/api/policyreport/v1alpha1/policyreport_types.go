@@ -32,9 +32,10 @@ const (
 
 // Severity specifies priority of a policy result
 const (
-	SeverityHigh   = "high"
-	SeverityMedium = "medium"
-	SeverityLow    = "low"
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
 )
 
 // PolicyReportSummary provides a status count summary
@@ -72,10 +73,11 @@ type PolicyReportSummary struct {
 type PolicyStatus string
 
 // PolicySeverity has one of the following values:
+//   - critical
 //   - high
 //   - low
 //   - medium
-// +kubebuilder:validation:Enum=high;low;medium
+// +kubebuilder:validation:Enum=critical;high;low;medium
 type PolicySeverity string
 
 // PolicyReportResult provides the result for an individual policy
@@ -118,6 +120,10 @@ type PolicyReportResult struct {
 	// Severity indicates policy severity
 	// +optional
 	Severity PolicySeverity `json:"severity,omitempty"`
+
+	// Timestamp indicates the time the result was found
+	// +optional
+	Timestamp metav1.Timestamp `json:"timestamp,omitempty"`
 }
 
 // +genclient
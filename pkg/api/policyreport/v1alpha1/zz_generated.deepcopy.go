@@ -214,6 +214,7 @@ func (in *PolicyReportResult) DeepCopyInto(out *PolicyReportResult) {
 			(*out)[key] = val
 		}
 	}
+	out.Timestamp = in.Timestamp
 	return
 }
 
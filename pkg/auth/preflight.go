@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DiagnosticsConfigMapName is the name of the ConfigMap the pre-flight report is written to
+const DiagnosticsConfigMapName = "kyverno-diagnostics"
+
+// Features checked by the pre-flight
+const (
+	FeatureWebhooks   = "webhooks"
+	FeatureReports    = "reports"
+	FeatureGenerate   = "generate"
+	FeatureBackground = "background"
+)
+
+// Permission is a verb on a resource, checked with a SelfSubjectAccessReview
+type Permission struct {
+	Group       string `json:"group"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb"`
+}
+
+func (p Permission) resource() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource = resource + "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource = resource + "." + p.Group
+	}
+	return resource
+}
+
+// PermissionGroup is the set of permissions a feature needs
+type PermissionGroup struct {
+	Feature string
+	// AdmissionCritical groups are required to serve admission requests
+	AdmissionCritical bool
+	Permissions       []Permission
+}
+
+// PermissionResult is the result of a single permission check
+type PermissionResult struct {
+	Feature           string     `json:"feature"`
+	AdmissionCritical bool       `json:"admissionCritical,omitempty"`
+	Permission        Permission `json:"permission"`
+	Allowed           bool       `json:"allowed"`
+	Reason            string     `json:"reason,omitempty"`
+}
+
+// PreflightReport holds the results of the pre-flight permission checks
+type PreflightReport struct {
+	Results []PermissionResult `json:"results"`
+}
+
+func permissions(group string, resources []string, namespace string, verbs ...string) []Permission {
+	var perms []Permission
+	for _, resource := range resources {
+		for _, verb := range verbs {
+			perms = append(perms, Permission{Group: group, Resource: resource, Namespace: namespace, Verb: verb})
+		}
+	}
+	return perms
+}
+
+// RequiredPermissions returns the permissions Kyverno needs, grouped by feature.
+// namespace is the namespace Kyverno is installed in.
+func RequiredPermissions(namespace string) []PermissionGroup {
+	webhooks := permissions("admissionregistration.k8s.io", []string{"mutatingwebhookconfigurations", "validatingwebhookconfigurations"}, "", "create", "get", "update", "delete")
+	webhooks = append(webhooks, permissions("certificates.k8s.io", []string{"certificatesigningrequests"}, "", "create", "get", "delete")...)
+	webhooks = append(webhooks, permissions("", []string{"secrets"}, namespace, "create", "get", "update")...)
+	webhooks = append(webhooks, permissions("coordination.k8s.io", []string{"leases"}, namespace, "create", "get", "update")...)
+	webhooks = append(webhooks, permissions("kyverno.io", []string{"clusterpolicies", "policies"}, "", "get", "list", "watch")...)
+	webhooks = append(webhooks, permissions("", []string{"configmaps"}, "", "get", "list", "watch")...)
+	webhooks = append(webhooks, permissions("", []string{"events"}, "", "create")...)
+
+	reports := permissions("wgpolicyk8s.io", []string{"policyreports", "clusterpolicyreports"}, "", "create", "get", "list", "update", "delete")
+	reports = append(reports, permissions("kyverno.io", []string{"reportchangerequests", "clusterreportchangerequests"}, "", "create", "list", "delete", "deletecollection")...)
+
+	generate := permissions("kyverno.io", []string{"generaterequests"}, namespace, "create", "get", "list", "update", "delete", "watch")
+	generate = append(generate, permissions("", []string{"namespaces"}, "", "watch")...)
+
+	background := permissions("*", []string{"*"}, "", "get", "list", "watch")
+
+	return []PermissionGroup{
+		{Feature: FeatureWebhooks, AdmissionCritical: true, Permissions: webhooks},
+		{Feature: FeatureReports, Permissions: reports},
+		{Feature: FeatureGenerate, Permissions: generate},
+		{Feature: FeatureBackground, Permissions: background},
+	}
+}
+
+// RunPreflight checks each permission with a SelfSubjectAccessReview.
+// A failed review is reported as a missing permission.
+func RunPreflight(client kubernetes.Interface, groups []PermissionGroup, log logr.Logger) *PreflightReport {
+	report := &PreflightReport{}
+	for _, group := range groups {
+		for _, perm := range group.Permissions {
+			result := PermissionResult{Feature: group.Feature, AdmissionCritical: group.AdmissionCritical, Permission: perm}
+			result.Allowed, result.Reason = canI(client, perm)
+			if !result.Allowed {
+				log.V(4).Info("missing permission", "feature", group.Feature, "verb", perm.Verb, "resource", perm.resource(), "namespace", perm.Namespace, "reason", result.Reason)
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report
+}
+
+func canI(client kubernetes.Interface, perm Permission) (bool, string) {
+	sar := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   perm.Namespace,
+				Verb:        perm.Verb,
+				Group:       perm.Group,
+				Resource:    perm.Resource,
+				Subresource: perm.Subresource,
+			},
+		},
+	}
+
+	resp, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Sprintf("failed to create SelfSubjectAccessReview: %v", err)
+	}
+
+	if resp.Status.EvaluationError != "" {
+		return resp.Status.Allowed, resp.Status.EvaluationError
+	}
+	return resp.Status.Allowed, resp.Status.Reason
+}
+
+// Missing returns the denied permissions
+func (r *PreflightReport) Missing() []PermissionResult {
+	var missing []PermissionResult
+	for _, result := range r.Results {
+		if !result.Allowed {
+			missing = append(missing, result)
+		}
+	}
+	return missing
+}
+
+// MissingAdmissionCritical returns the denied permissions that are required to serve admission requests
+func (r *PreflightReport) MissingAdmissionCritical() []PermissionResult {
+	var missing []PermissionResult
+	for _, result := range r.Missing() {
+		if result.AdmissionCritical {
+			missing = append(missing, result)
+		}
+	}
+	return missing
+}
+
+// Table returns the missing permissions as a table, or an empty string if all permissions are granted
+func (r *PreflightReport) Table() string {
+	missing := r.Missing()
+	if len(missing) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FEATURE\tVERB\tRESOURCE\tNAMESPACE\tADMISSION CRITICAL\tREASON")
+	for _, result := range missing {
+		p := result.Permission
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", result.Feature, p.Verb, p.resource(), p.Namespace, result.AdmissionCritical, result.Reason)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// ConfigMap returns the report as the diagnostics ConfigMap
+func (r *PreflightReport) ConfigMap(namespace string) (*v1.ConfigMap, error) {
+	results, err := json.Marshal(r.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	status := "ok"
+	if len(r.MissingAdmissionCritical()) > 0 {
+		status = "admission-critical permissions missing"
+	} else if len(r.Missing()) > 0 {
+		status = "permissions missing"
+	}
+
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DiagnosticsConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "kyverno",
+			},
+		},
+		Data: map[string]string{
+			"status":  status,
+			"summary": fmt.Sprintf("%d/%d permissions granted", len(r.Results)-len(r.Missing()), len(r.Results)),
+			"missing": r.Table(),
+			"results": string(results),
+		},
+	}, nil
+}
+
+// WriteConfigMap creates or updates the diagnostics ConfigMap
+func (r *PreflightReport) WriteConfigMap(client kubernetes.Interface, namespace string) error {
+	cm, err := r.ConfigMap(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to build ConfigMap %s: %v", DiagnosticsConfigMapName, err)
+	}
+
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	if _, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{}); err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create ConfigMap %s: %v", DiagnosticsConfigMapName, err)
+	}
+
+	if _, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s: %v", DiagnosticsConfigMapName, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newFakeSSARClient returns a client that answers SelfSubjectAccessReviews with allowed
+func newFakeSSARClient(allowed func(attrs *authorizationv1.ResourceAttributes) bool) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		sar.Status.Allowed = allowed(sar.Spec.ResourceAttributes)
+		if !sar.Status.Allowed {
+			sar.Status.Reason = "forbidden"
+		}
+		return true, sar, nil
+	})
+	return client
+}
+
+func countPermissions(groups []PermissionGroup, admissionCritical bool) int {
+	var count int
+	for _, group := range groups {
+		if !admissionCritical || group.AdmissionCritical {
+			count += len(group.Permissions)
+		}
+	}
+	return count
+}
+
+func Test_Preflight_All_Permissions(t *testing.T) {
+	groups := RequiredPermissions("kyverno")
+	client := newFakeSSARClient(func(*authorizationv1.ResourceAttributes) bool { return true })
+
+	report := RunPreflight(client, groups, log.Log)
+	assert.Equal(t, len(report.Results), countPermissions(groups, false))
+	assert.Equal(t, len(report.Missing()), 0)
+	assert.Equal(t, len(report.MissingAdmissionCritical()), 0)
+	assert.Equal(t, report.Table(), "")
+
+	assert.NilError(t, report.WriteConfigMap(client, "kyverno"))
+	cm, err := client.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), DiagnosticsConfigMapName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, cm.Data["status"], "ok")
+	assert.Equal(t, cm.Data["missing"], "")
+
+	var results []PermissionResult
+	assert.NilError(t, json.Unmarshal([]byte(cm.Data["results"]), &results))
+	assert.DeepEqual(t, results, report.Results)
+}
+
+func Test_Preflight_Partial_Permissions(t *testing.T) {
+	groups := RequiredPermissions("kyverno")
+	// reports and generate requests cannot be deleted
+	client := newFakeSSARClient(func(attrs *authorizationv1.ResourceAttributes) bool {
+		return !(attrs.Verb == "delete" && (attrs.Resource == "policyreports" || attrs.Resource == "generaterequests"))
+	})
+
+	report := RunPreflight(client, groups, log.Log)
+	missing := report.Missing()
+	assert.Equal(t, len(missing), 2)
+	assert.Equal(t, missing[0].Feature, FeatureReports)
+	assert.Equal(t, missing[0].Permission.Resource, "policyreports")
+	assert.Equal(t, missing[1].Feature, FeatureGenerate)
+	assert.Equal(t, missing[1].Permission.Namespace, "kyverno")
+	assert.Equal(t, len(report.MissingAdmissionCritical()), 0)
+
+	table := report.Table()
+	assert.Assert(t, strings.Contains(table, "policyreports.wgpolicyk8s.io"), table)
+	assert.Assert(t, strings.Contains(table, "generaterequests.kyverno.io"), table)
+
+	// an existing ConfigMap is updated
+	assert.NilError(t, RunPreflight(newFakeSSARClient(func(*authorizationv1.ResourceAttributes) bool { return true }), groups, log.Log).WriteConfigMap(client, "kyverno"))
+	assert.NilError(t, report.WriteConfigMap(client, "kyverno"))
+	cm, err := client.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), DiagnosticsConfigMapName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, cm.Data["status"], "permissions missing")
+	assert.Equal(t, cm.Data["missing"], table)
+}
+
+func Test_Preflight_No_Permissions(t *testing.T) {
+	groups := RequiredPermissions("kyverno")
+	client := newFakeSSARClient(func(*authorizationv1.ResourceAttributes) bool { return false })
+
+	report := RunPreflight(client, groups, log.Log)
+	assert.Equal(t, len(report.Missing()), countPermissions(groups, false))
+	assert.Equal(t, len(report.MissingAdmissionCritical()), countPermissions(groups, true))
+	for _, result := range report.Missing() {
+		assert.Equal(t, result.Reason, "forbidden")
+	}
+
+	cm, err := report.ConfigMap("kyverno")
+	assert.NilError(t, err)
+	assert.Equal(t, cm.Data["status"], "admission-critical permissions missing")
+	assert.Equal(t, cm.Data["summary"], fmt.Sprintf("0/%d permissions granted", countPermissions(groups, false)))
+}
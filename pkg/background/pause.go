@@ -0,0 +1,234 @@
+// Package background pauses the background processing of Kyverno while backgroundScanPaused is set in the
+// Kyverno ConfigMap, e.g. to relieve the API server during an incident. The background scans, the enumeration
+// of the existing namespaces by the generate controller and the full reconciliations of the policy reports wait
+// at safe points, between two namespaces or two generate requests, and continue from there once resumed.
+// The admission requests are not affected.
+package background
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// StatusConfigMapName is the name of the ConfigMap the status conditions of Kyverno are written to
+const StatusConfigMapName = "kyverno-status"
+
+// ConditionPaused is the type of the status condition reporting the paused background processing
+const ConditionPaused = "BackgroundScanPaused"
+
+// conditionsKey is the key of the status conditions, as a JSON list, in the status ConfigMap
+const conditionsKey = "conditions"
+
+// Gate pauses the background processing at safe points while it is paused in the configuration.
+// The methods of a nil gate never pause.
+type Gate struct {
+	configHandler config.Interface
+	kubeClient    kubernetes.Interface
+	namespace     string
+	metrics       *metrics.PromMetrics
+	log           logr.Logger
+
+	mutex   sync.Mutex
+	paused  bool
+	resumed chan struct{}
+	stopCh  <-chan struct{}
+}
+
+// NewGate returns the gate following the configuration, the status ConfigMap is written in the namespace.
+// The metrics are not reported if the prometheus configuration is nil.
+func NewGate(configHandler config.Interface, kubeClient kubernetes.Interface, namespace string, promConfig *metrics.PromConfig, log logr.Logger) *Gate {
+	g := &Gate{
+		configHandler: configHandler,
+		kubeClient:    kubeClient,
+		namespace:     namespace,
+		log:           log,
+	}
+
+	if promConfig != nil {
+		g.metrics = promConfig.Metrics
+	}
+
+	return g
+}
+
+// Run checks the configuration every interval until the stop channel is closed, the processing
+// waiting at a safe point is released once stopped
+func (g *Gate) Run(interval time.Duration, stopCh <-chan struct{}) {
+	g.mutex.Lock()
+	g.stopCh = stopCh
+	g.mutex.Unlock()
+
+	g.log.Info("starting", "interval", interval.String())
+	wait.Until(g.Sync, interval, stopCh)
+}
+
+// Sync pauses or resumes the background processing according to the configuration,
+// the transitions are recorded in the metric and in the status ConfigMap
+func (g *Gate) Sync() {
+	paused := g.configHandler.GetBackgroundScanPaused()
+
+	g.mutex.Lock()
+	changed := paused != g.paused
+	if changed {
+		g.paused = paused
+		if paused {
+			g.resumed = make(chan struct{})
+		} else {
+			close(g.resumed)
+			g.resumed = nil
+		}
+	}
+	g.mutex.Unlock()
+
+	if g.metrics != nil {
+		value := 0.0
+		if paused {
+			value = 1
+		}
+		g.metrics.BackgroundScanPaused.Set(value)
+	}
+
+	if !changed {
+		return
+	}
+
+	g.log.Info("background processing updated", "paused", paused)
+	if err := g.writeCondition(paused); err != nil {
+		g.log.Error(err, "failed to write the status condition", "configMap", StatusConfigMapName)
+	}
+}
+
+// Paused returns true if the background processing is paused
+func (g *Gate) Paused() bool {
+	if g == nil {
+		return false
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.paused
+}
+
+// Resumed returns the channel closed once the paused background processing resumes,
+// or nil if it is not paused
+func (g *Gate) Resumed() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.resumed
+}
+
+// Wait is called at the safe points of the background processing, it blocks while the processing
+// is paused and returns false if the gate is stopped meanwhile, the caller then gives up
+func (g *Gate) Wait() bool {
+	if g == nil {
+		return true
+	}
+
+	g.mutex.Lock()
+	resumed, stopCh := g.resumed, g.stopCh
+	g.mutex.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-stopCh:
+		return false
+	}
+}
+
+// writeCondition sets the paused condition in the status ConfigMap, the other conditions are kept
+func (g *Gate) writeCondition(paused bool) error {
+	condition := metav1.Condition{
+		Type:    ConditionPaused,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Resumed",
+		Message: "the background processing is running",
+	}
+	if paused {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Paused"
+		condition.Message = "backgroundScanPaused is set in the Kyverno ConfigMap, the background processing waits at the next safe point"
+	}
+
+	configMaps := g.kubeClient.CoreV1().ConfigMaps(g.namespace)
+	cm, err := configMaps.Get(context.TODO(), StatusConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap %s: %v", StatusConfigMapName, err)
+		}
+
+		cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: StatusConfigMapName, Namespace: g.namespace}}
+		if err := setCondition(cm, condition); err != nil {
+			return err
+		}
+
+		if _, err := configMaps.Create(context.TODO(), cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create ConfigMap %s: %v", StatusConfigMapName, err)
+		}
+		return nil
+	}
+
+	if err := setCondition(cm, condition); err != nil {
+		return err
+	}
+
+	if _, err := configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s: %v", StatusConfigMapName, err)
+	}
+	return nil
+}
+
+// setCondition sets the condition in the conditions of the status ConfigMap
+func setCondition(cm *v1.ConfigMap, condition metav1.Condition) error {
+	conditions, err := Conditions(cm)
+	if err != nil {
+		return err
+	}
+
+	meta.SetStatusCondition(&conditions, condition)
+	raw, err := json.Marshal(conditions)
+	if err != nil {
+		return fmt.Errorf("failed to encode the conditions: %v", err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[conditionsKey] = string(raw)
+	return nil
+}
+
+// Conditions returns the status conditions of the status ConfigMap
+func Conditions(cm *v1.ConfigMap) ([]metav1.Condition, error) {
+	var conditions []metav1.Condition
+	raw, ok := cm.Data[conditionsKey]
+	if !ok || raw == "" {
+		return conditions, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &conditions); err != nil {
+		return nil, fmt.Errorf("failed to decode the conditions of ConfigMap %s: %v", cm.Name, err)
+	}
+	return conditions, nil
+}
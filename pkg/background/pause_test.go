@@ -0,0 +1,217 @@
+package background
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeConfig returns the backgroundScanPaused setting of the test
+type fakeConfig struct {
+	config.Interface
+	mutex  sync.Mutex
+	paused bool
+}
+
+func (f *fakeConfig) GetBackgroundScanPaused() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.paused
+}
+
+func (f *fakeConfig) setPaused(paused bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.paused = paused
+}
+
+func pausedCondition(t *testing.T, kubeClient kubernetes.Interface) metav1.Condition {
+	cm, err := kubeClient.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), StatusConfigMapName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	conditions, err := Conditions(cm)
+	assert.NilError(t, err)
+	for _, condition := range conditions {
+		if condition.Type == ConditionPaused {
+			return condition
+		}
+	}
+	t.Fatalf("condition %s not found in %v", ConditionPaused, conditions)
+	return metav1.Condition{}
+}
+
+// waitReturned returns true if the call to Wait returns within the timeout
+func waitReturned(done <-chan bool, timeout time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func Test_Gate_Pause_Resume(t *testing.T) {
+	configHandler := &fakeConfig{}
+	kubeClient := fake.NewSimpleClientset()
+	promConfig := metrics.NewPromConfig()
+	g := NewGate(configHandler, kubeClient, "kyverno", promConfig, log.Log)
+
+	// not paused, no status is written
+	g.Sync()
+	assert.Assert(t, !g.Paused())
+	assert.Assert(t, g.Wait())
+	assert.Assert(t, g.Resumed() == nil)
+	assert.Equal(t, testutil.ToFloat64(promConfig.Metrics.BackgroundScanPaused), float64(0))
+	_, err := kubeClient.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), StatusConfigMapName, metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+
+	configHandler.setPaused(true)
+	g.Sync()
+	assert.Assert(t, g.Paused())
+	assert.Equal(t, testutil.ToFloat64(promConfig.Metrics.BackgroundScanPaused), float64(1))
+	condition := pausedCondition(t, kubeClient)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "Paused")
+
+	done := make(chan bool)
+	go func() { done <- g.Wait() }()
+	assert.Assert(t, !waitReturned(done, 50*time.Millisecond), "the processing must wait while paused")
+
+	configHandler.setPaused(false)
+	g.Sync()
+	assert.Assert(t, waitReturned(done, time.Second), "the processing must continue once resumed")
+	assert.Assert(t, !g.Paused())
+	assert.Equal(t, testutil.ToFloat64(promConfig.Metrics.BackgroundScanPaused), float64(0))
+	condition = pausedCondition(t, kubeClient)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "Resumed")
+}
+
+func Test_Gate_Resume_Continuity(t *testing.T) {
+	configHandler := &fakeConfig{}
+	g := NewGate(configHandler, fake.NewSimpleClientset(), "kyverno", nil, log.Log)
+
+	namespaces := make([]string, 10)
+	for i := range namespaces {
+		namespaces[i] = fmt.Sprintf("ns-%d", i)
+	}
+
+	// the scan is paused while processing the fourth namespace
+	var mutex sync.Mutex
+	var processed []string
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		for i, ns := range namespaces {
+			if !g.Wait() {
+				return
+			}
+
+			mutex.Lock()
+			processed = append(processed, ns)
+			mutex.Unlock()
+
+			if i == 3 {
+				configHandler.setPaused(true)
+				g.Sync()
+			}
+		}
+	}()
+
+	count := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(processed)
+	}
+
+	assert.NilError(t, waitFor(g.Paused))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, count(), 4)
+
+	// the configuration is reloaded while paused, the scan stays paused
+	g.Sync()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, count(), 4)
+
+	configHandler.setPaused(false)
+	g.Sync()
+	select {
+	case <-scanned:
+	case <-time.After(time.Second):
+		t.Fatal("the scan did not resume")
+	}
+
+	// each namespace is processed once, the scan continues after the last processed namespace
+	assert.DeepEqual(t, processed, namespaces)
+}
+
+func Test_Gate_Stopped_While_Paused(t *testing.T) {
+	configHandler := &fakeConfig{paused: true}
+	g := NewGate(configHandler, fake.NewSimpleClientset(), "kyverno", nil, log.Log)
+
+	stopCh := make(chan struct{})
+	go g.Run(10*time.Millisecond, stopCh)
+	assert.NilError(t, waitFor(g.Paused))
+
+	done := make(chan bool)
+	go func() { done <- g.Wait() }()
+	assert.Assert(t, !waitReturned(done, 50*time.Millisecond))
+
+	close(stopCh)
+	select {
+	case resumed := <-done:
+		assert.Assert(t, !resumed, "the processing must give up once stopped")
+	case <-time.After(time.Second):
+		t.Fatal("the processing is still waiting")
+	}
+}
+
+func Test_Gate_Conditions_Kept(t *testing.T) {
+	other, err := json.Marshal([]metav1.Condition{{Type: "Other", Status: metav1.ConditionTrue, Reason: "Test"}})
+	assert.NilError(t, err)
+	kubeClient := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: StatusConfigMapName, Namespace: "kyverno"},
+		Data:       map[string]string{conditionsKey: string(other)},
+	})
+
+	g := NewGate(&fakeConfig{paused: true}, kubeClient, "kyverno", nil, log.Log)
+	g.Sync()
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), StatusConfigMapName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	conditions, err := Conditions(cm)
+	assert.NilError(t, err)
+	assert.Equal(t, len(conditions), 2)
+	assert.Equal(t, conditions[0].Type, "Other")
+	assert.Equal(t, conditions[1].Type, ConditionPaused)
+}
+
+func Test_Gate_Nil(t *testing.T) {
+	var g *Gate
+	assert.Assert(t, !g.Paused())
+	assert.Assert(t, g.Wait())
+	assert.Assert(t, g.Resumed() == nil)
+}
+
+// waitFor polls the condition until it is true
+func waitFor(condition func() bool) error {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("condition not met")
+}
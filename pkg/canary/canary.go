@@ -0,0 +1,243 @@
+// Package canary submits periodic dry-run admission requests matched by the Kyverno webhooks,
+// to detect the requests rejected, or admitted without policies, while Kyverno is unavailable.
+// The canary runs in a separate lightweight deployment so that it reports even when the
+// Kyverno pods are degraded.
+package canary
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// StatusConfigMapName is the name of the ConfigMap the canary status is written to
+const StatusConfigMapName = "kyverno-canary-status"
+
+// PolicyName is the name of the canary ClusterPolicy, it is never persisted
+const PolicyName = "kyverno-canary"
+
+// Result is the result of a canary admission request
+type Result string
+
+const (
+	// Success the canary is admitted and processed by the Kyverno webhook
+	Success Result = "success"
+	// Timeout the canary admission request timed out
+	Timeout Result = "timeout"
+	// Rejected the canary is rejected, e.g. the API server failed calling the Kyverno
+	// webhook with failurePolicy Fail
+	Rejected Result = "rejected"
+	// Bypassed the canary is admitted without being processed by the Kyverno webhook,
+	// e.g. the API server failed calling the webhook with failurePolicy Ignore
+	Bypassed Result = "bypassed"
+)
+
+// DryRunClient submits the server-side dry-run create of the canary policy
+type DryRunClient interface {
+	DryRunCreate(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error)
+}
+
+// NewDryRunClient returns the dry-run client of the Kyverno clientset
+func NewDryRunClient(client kyvernoclient.Interface) DryRunClient {
+	return dryRunClient{client: client}
+}
+
+type dryRunClient struct {
+	client kyvernoclient.Interface
+}
+
+func (c dryRunClient) DryRunCreate(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+	return c.client.KyvernoV1().ClusterPolicies().Create(ctx, policy, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+}
+
+// Status is the status of the last canary admission request
+type Status struct {
+	Result              Result
+	Message             string
+	Latency             time.Duration
+	ProbeTime           time.Time
+	LastSuccessTime     time.Time
+	ConsecutiveFailures int
+}
+
+// Canary submits the canary admission requests
+type Canary struct {
+	client     DryRunClient
+	kubeClient kubernetes.Interface
+	namespace  string
+	timeout    time.Duration
+	metrics    *metrics.PromMetrics
+	log        logr.Logger
+
+	mutex  sync.Mutex
+	status Status
+}
+
+// New returns the canary submitting requests with the timeout, the status ConfigMap is written
+// in the namespace. The metrics are not reported if the prometheus configuration is nil.
+func New(client DryRunClient, kubeClient kubernetes.Interface, namespace string, timeout time.Duration, promConfig *metrics.PromConfig, log logr.Logger) *Canary {
+	c := &Canary{
+		client:     client,
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		timeout:    timeout,
+		log:        log,
+	}
+
+	if promConfig != nil {
+		c.metrics = promConfig.Metrics
+	}
+
+	return c
+}
+
+// Run submits a canary admission request every interval until the stop channel is closed
+func (c *Canary) Run(interval time.Duration, stopCh <-chan struct{}) {
+	c.log.Info("starting canary", "interval", interval.String(), "timeout", c.timeout.String())
+	wait.Until(func() {
+		status := c.Probe()
+		if err := c.WriteConfigMap(status); err != nil {
+			c.log.Error(err, "failed to write canary status")
+		}
+	}, interval, stopCh)
+}
+
+// Probe submits a canary admission request and returns the updated status
+func (c *Canary) Probe() Status {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	admitted, err := c.client.DryRunCreate(ctx, newCanaryPolicy())
+	latency := time.Since(start)
+	result, message := classify(ctx, admitted, err)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.status.Result = result
+	c.status.Message = message
+	c.status.Latency = latency
+	c.status.ProbeTime = start
+	if result == Success {
+		c.status.LastSuccessTime = start
+		c.status.ConsecutiveFailures = 0
+	} else {
+		c.status.ConsecutiveFailures++
+		c.log.Info("canary admission request failed", "result", result, "message", message, "latency", latency.String(), "consecutiveFailures", c.status.ConsecutiveFailures)
+	}
+
+	if c.metrics != nil {
+		success := 0.0
+		if result == Success {
+			success = 1
+		}
+		c.metrics.CanaryAdmissionSuccess.Set(success)
+		c.metrics.CanaryAdmissionLatency.With(prom.Labels{"result": string(result)}).Observe(float64(latency.Milliseconds()))
+	}
+
+	return c.status
+}
+
+// classify returns the result of the canary admission request
+func classify(ctx context.Context, admitted *kyverno.ClusterPolicy, err error) (Result, string) {
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded || errors.IsTimeout(err) || errors.IsServerTimeout(err) {
+			return Timeout, err.Error()
+		}
+		return Rejected, err.Error()
+	}
+
+	// the policy mutation webhook adds the pod controllers annotation to the policies matching pods
+	if !admitted.HasAutoGenAnnotation() {
+		return Bypassed, "the canary is admitted without the Kyverno policy mutation"
+	}
+
+	return Success, ""
+}
+
+// ConfigMap returns the status ConfigMap, external monitors check it when the Kyverno pods are degraded
+func (s Status) ConfigMap(namespace string) *v1.ConfigMap {
+	data := map[string]string{
+		"result":              string(s.Result),
+		"message":             s.Message,
+		"latencyMilliseconds": strconv.FormatInt(s.Latency.Milliseconds(), 10),
+		"probeTime":           s.ProbeTime.UTC().Format(time.RFC3339),
+		"consecutiveFailures": strconv.Itoa(s.ConsecutiveFailures),
+	}
+	if !s.LastSuccessTime.IsZero() {
+		data["lastSuccessTime"] = s.LastSuccessTime.UTC().Format(time.RFC3339)
+	}
+
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      StatusConfigMapName,
+			Namespace: namespace,
+		},
+		Data: data,
+	}
+}
+
+// WriteConfigMap creates or updates the status ConfigMap
+func (c *Canary) WriteConfigMap(status Status) error {
+	cm := status.ConfigMap(c.namespace)
+	configMaps := c.kubeClient.CoreV1().ConfigMaps(c.namespace)
+	_, err := configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create ConfigMap %s: %v", StatusConfigMapName, err)
+	}
+
+	if _, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s: %v", StatusConfigMapName, err)
+	}
+	return nil
+}
+
+// newCanaryPolicy returns the canary policy, an audit policy matching pods which the Kyverno
+// policy webhooks mutate and validate
+func newCanaryPolicy() *kyverno.ClusterPolicy {
+	background := false
+	return &kyverno.ClusterPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "kyverno.io/v1",
+			Kind:       "ClusterPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: PolicyName,
+		},
+		Spec: kyverno.Spec{
+			ValidationFailureAction: "audit",
+			Background:              &background,
+			Rules: []kyverno.Rule{
+				{
+					Name: "canary",
+					MatchResources: kyverno.MatchResources{
+						ResourceDescription: kyverno.ResourceDescription{
+							Kinds: []string{"Pod"},
+							Names: []string{PolicyName},
+						},
+					},
+					Validation: kyverno.Validation{
+						Message: "canary",
+						Pattern: map[string]interface{}{"metadata": map[string]interface{}{"name": PolicyName}},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeDryRunClient admits the canary policy with the response of the test case
+type fakeDryRunClient func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error)
+
+func (f fakeDryRunClient) DryRunCreate(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+	return f(ctx, policy)
+}
+
+// mutated admits the canary policy with the annotation added by the policy mutation webhook
+func mutated(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+	admitted := policy.DeepCopy()
+	admitted.SetAnnotations(map[string]string{"pod-policies.kyverno.io/autogen-controllers": "DaemonSet,Deployment,Job,StatefulSet,CronJob"})
+	return admitted, nil
+}
+
+func Test_Canary_Probe(t *testing.T) {
+	testCases := []struct {
+		name    string
+		client  fakeDryRunClient
+		result  Result
+		message string
+	}{
+		{
+			name:   "success",
+			client: mutated,
+			result: Success,
+		},
+		{
+			name: "timeout",
+			client: func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			result:  Timeout,
+			message: "context deadline exceeded",
+		},
+		{
+			name: "webhook rejection",
+			client: func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+				return nil, apierrors.NewInternalError(errors.New(`failed calling webhook "validate-policy.kyverno.svc": connection refused`))
+			},
+			result:  Rejected,
+			message: `Internal error occurred: failed calling webhook "validate-policy.kyverno.svc": connection refused`,
+		},
+		{
+			name: "webhook bypassed",
+			client: func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+				return policy.DeepCopy(), nil
+			},
+			result:  Bypassed,
+			message: "the canary is admitted without the Kyverno policy mutation",
+		},
+	}
+
+	for _, tc := range testCases {
+		promConfig := metrics.NewPromConfig()
+		c := New(tc.client, fake.NewSimpleClientset(), "kyverno", 100*time.Millisecond, promConfig, log.Log)
+
+		status := c.Probe()
+		assert.Equal(t, status.Result, tc.result, tc.name)
+		assert.Equal(t, status.Message, tc.message, tc.name)
+
+		success := testutil.ToFloat64(promConfig.Metrics.CanaryAdmissionSuccess)
+		if tc.result == Success {
+			assert.Equal(t, success, float64(1), tc.name)
+			assert.Equal(t, status.ConsecutiveFailures, 0, tc.name)
+			assert.Equal(t, status.LastSuccessTime, status.ProbeTime, tc.name)
+		} else {
+			assert.Equal(t, success, float64(0), tc.name)
+			assert.Equal(t, status.ConsecutiveFailures, 1, tc.name)
+			assert.Assert(t, status.LastSuccessTime.IsZero(), tc.name)
+		}
+
+		// a single latency series, labeled with the result: getting it does not add a series
+		assert.Equal(t, testutil.CollectAndCount(promConfig.Metrics.CanaryAdmissionLatency), 1, tc.name)
+		promConfig.Metrics.CanaryAdmissionLatency.With(prom.Labels{"result": string(tc.result)})
+		assert.Equal(t, testutil.CollectAndCount(promConfig.Metrics.CanaryAdmissionLatency), 1, tc.name)
+	}
+}
+
+func Test_Canary_DryRun(t *testing.T) {
+	var submitted *kyverno.ClusterPolicy
+	client := fakeDryRunClient(func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+		submitted = policy
+		return mutated(ctx, policy)
+	})
+
+	c := New(client, fake.NewSimpleClientset(), "kyverno", time.Second, nil, log.Log)
+	assert.Equal(t, c.Probe().Result, Success)
+
+	// the canary policy matches pods, so that the policy mutation webhook adds the autogen annotation
+	assert.Equal(t, submitted.GetName(), PolicyName)
+	assert.DeepEqual(t, submitted.Spec.Rules[0].MatchResources.Kinds, []string{"Pod"})
+	assert.Equal(t, submitted.Spec.ValidationFailureAction, "audit")
+}
+
+func Test_Canary_WriteConfigMap(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	failing := true
+	client := fakeDryRunClient(func(ctx context.Context, policy *kyverno.ClusterPolicy) (*kyverno.ClusterPolicy, error) {
+		if failing {
+			return nil, apierrors.NewInternalError(errors.New("failed calling webhook"))
+		}
+		return mutated(ctx, policy)
+	})
+	c := New(client, kubeClient, "kyverno", time.Second, nil, log.Log)
+
+	read := func() map[string]string {
+		cm, err := kubeClient.CoreV1().ConfigMaps("kyverno").Get(context.TODO(), StatusConfigMapName, metav1.GetOptions{})
+		assert.NilError(t, err)
+		return cm.Data
+	}
+
+	// the ConfigMap is created, then updated
+	assert.NilError(t, c.WriteConfigMap(c.Probe()))
+	assert.NilError(t, c.WriteConfigMap(c.Probe()))
+	data := read()
+	assert.Equal(t, data["result"], string(Rejected))
+	assert.Equal(t, data["consecutiveFailures"], "2")
+	_, ok := data["lastSuccessTime"]
+	assert.Assert(t, !ok)
+
+	failing = false
+	assert.NilError(t, c.WriteConfigMap(c.Probe()))
+	data = read()
+	assert.Equal(t, data["result"], string(Success))
+	assert.Equal(t, data["message"], "")
+	assert.Equal(t, data["consecutiveFailures"], "0")
+	assert.Equal(t, data["lastSuccessTime"], data["probeTime"])
+}
@@ -0,0 +1,209 @@
+package cleanup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/config"
+	client "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/policyreport"
+	errorsapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Options configures the cleanup of the resources Kyverno leaves behind on uninstall
+type Options struct {
+	// DryRun lists the changes without applying them
+	DryRun bool
+
+	// DeleteReports also deletes the policy reports generated by Kyverno
+	DeleteReports bool
+}
+
+// the actions of the cleanup
+const (
+	actionDelete           = "delete"
+	actionRemoveFinalizers = "remove finalizers"
+)
+
+// Action is a change made, or listed in dry run mode, by the cleanup
+type Action struct {
+	Action    string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (a Action) String() string {
+	if a.Namespace == "" {
+		return fmt.Sprintf("%s %s %s", a.Action, a.Kind, a.Name)
+	}
+
+	return fmt.Sprintf("%s %s %s/%s", a.Action, a.Kind, a.Namespace, a.Name)
+}
+
+// webhookConfigurations are the names of the webhook configurations registered by Kyverno by kind,
+// including the configurations registered out-of-cluster
+var webhookConfigurations = map[string][]string{
+	"MutatingWebhookConfiguration": {
+		config.MutatingWebhookConfigurationName,
+		config.MutatingWebhookConfigurationDebugName,
+		config.PolicyMutatingWebhookConfigurationName,
+		config.PolicyMutatingWebhookConfigurationDebugName,
+		config.VerifyMutatingWebhookConfigurationName,
+		config.VerifyMutatingWebhookConfigurationDebugName,
+	},
+	"ValidatingWebhookConfiguration": {
+		config.ValidatingWebhookConfigurationName,
+		config.ValidatingWebhookConfigurationDebugName,
+		config.PolicyValidatingWebhookConfigurationName,
+		config.PolicyValidatingWebhookConfigurationDebugName,
+	},
+}
+
+// Run removes the webhook configurations of Kyverno, so that the admission requests are not sent
+// to the removed service, and the finalizers of the update and report change requests, so that
+// they do not block the deletion of the namespaces. The policy reports generated by Kyverno are
+// deleted if set in the options.
+// It returns the actions applied, or the actions that would be applied in dry run mode. The
+// cleanup continues on errors, which are returned together.
+func Run(client *client.Client, opts Options, log logr.Logger) ([]Action, error) {
+	c := &cleaner{client: client, opts: opts, log: log.WithName("Cleanup")}
+
+	for _, kind := range []string{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"} {
+		for _, name := range webhookConfigurations[kind] {
+			c.deleteWebhookConfiguration(kind, name)
+		}
+	}
+
+	c.removeFinalizers("GenerateRequest", config.KyvernoNamespace)
+	c.removeFinalizers("ReportChangeRequest", "")
+	c.removeFinalizers("ClusterReportChangeRequest", "")
+
+	if opts.DeleteReports {
+		c.deleteReports("PolicyReport")
+		c.deleteReports("ClusterPolicyReport")
+	}
+
+	if len(c.errors) > 0 {
+		return c.actions, fmt.Errorf("failed to cleanup Kyverno resources: %s", strings.Join(c.errors, "; "))
+	}
+
+	return c.actions, nil
+}
+
+type cleaner struct {
+	client  *client.Client
+	opts    Options
+	log     logr.Logger
+	actions []Action
+	errors  []string
+}
+
+func (c *cleaner) deleteWebhookConfiguration(kind, name string) {
+	if _, err := c.client.GetResource("", kind, "", name); err != nil {
+		if !errorsapi.IsNotFound(err) {
+			c.fail(err, "failed to get webhook configuration", kind, "", name)
+		}
+		return
+	}
+
+	c.delete(kind, "", name)
+}
+
+// removeFinalizers removes the finalizers of the resources of the kind, in all the namespaces if
+// the namespace is empty
+func (c *cleaner) removeFinalizers(kind, namespace string) {
+	list, err := c.list(kind, namespace)
+	if err != nil {
+		return
+	}
+
+	for i := range list {
+		obj := &list[i]
+		if len(obj.GetFinalizers()) == 0 {
+			continue
+		}
+
+		action := Action{Action: actionRemoveFinalizers, Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		if !c.opts.DryRun {
+			obj.SetFinalizers(nil)
+			if _, err := c.client.UpdateResource(obj.GetAPIVersion(), kind, obj.GetNamespace(), obj, false); err != nil && !errorsapi.IsNotFound(err) {
+				c.fail(err, "failed to remove finalizers", kind, obj.GetNamespace(), obj.GetName())
+				continue
+			}
+		}
+
+		c.record(action)
+	}
+}
+
+// deleteReports deletes the reports of the kind generated by Kyverno, the reports created by other
+// tools are kept
+func (c *cleaner) deleteReports(kind string) {
+	list, err := c.list(kind, "")
+	if err != nil {
+		return
+	}
+
+	for _, report := range list {
+		if !policyreport.IsGeneratedReport(report.GetNamespace(), report.GetName()) {
+			continue
+		}
+
+		c.delete(kind, report.GetNamespace(), report.GetName())
+	}
+}
+
+// list returns the resources of the kind sorted by namespace and name, a kind that is not
+// installed, e.g. the CRD is already removed, has no resources
+func (c *cleaner) list(kind, namespace string) ([]unstructured.Unstructured, error) {
+	list, err := c.client.ListResource("", kind, namespace, nil)
+	if err != nil {
+		if errorsapi.IsNotFound(err) {
+			return nil, err
+		}
+
+		c.fail(err, "failed to list resources", kind, namespace, "")
+		return nil, err
+	}
+
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
+	})
+
+	return items, nil
+}
+
+func (c *cleaner) delete(kind, namespace, name string) {
+	action := Action{Action: actionDelete, Kind: kind, Namespace: namespace, Name: name}
+	if !c.opts.DryRun {
+		if err := c.client.DeleteResource("", kind, namespace, name, false); err != nil && !errorsapi.IsNotFound(err) {
+			c.fail(err, "failed to delete resource", kind, namespace, name)
+			return
+		}
+	}
+
+	c.record(action)
+}
+
+func (c *cleaner) record(action Action) {
+	if c.opts.DryRun {
+		c.log.Info("dry run, would "+action.String(), "kind", action.Kind, "namespace", action.Namespace, "name", action.Name)
+	} else {
+		c.log.Info(action.String(), "kind", action.Kind, "namespace", action.Namespace, "name", action.Name)
+	}
+
+	c.actions = append(c.actions, action)
+}
+
+func (c *cleaner) fail(err error, msg, kind, namespace, name string) {
+	c.log.Error(err, msg, "kind", kind, "namespace", namespace, "name", name)
+	c.errors = append(c.errors, fmt.Sprintf("%s %s %s/%s: %v", msg, kind, namespace, name, err))
+}
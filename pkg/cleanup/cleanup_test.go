@@ -0,0 +1,153 @@
+package cleanup
+
+import (
+	"testing"
+
+	"github.com/kyverno/kyverno/pkg/config"
+	client "github.com/kyverno/kyverno/pkg/dclient"
+	"gotest.tools/assert"
+	errorsapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newObject(apiVersion, kind, namespace, name string, finalizers ...string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetFinalizers(finalizers)
+	return obj
+}
+
+func newMockClient(t *testing.T) *client.Client {
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "mutatingwebhookconfigurations"}:   "MutatingWebhookConfigurationList",
+		{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"}: "ValidatingWebhookConfigurationList",
+		{Group: "kyverno.io", Version: "v1", Resource: "generaterequests"}:                                       "GenerateRequestList",
+		{Group: "kyverno.io", Version: "v1alpha1", Resource: "reportchangerequests"}:                             "ReportChangeRequestList",
+		{Group: "kyverno.io", Version: "v1alpha1", Resource: "clusterreportchangerequests"}:                      "ClusterReportChangeRequestList",
+		{Group: "wgpolicyk8s.io", Version: "v1alpha1", Resource: "policyreports"}:                                "PolicyReportList",
+		{Group: "wgpolicyk8s.io", Version: "v1alpha1", Resource: "clusterpolicyreports"}:                         "ClusterPolicyReportList",
+	}
+
+	var resources []schema.GroupVersionResource
+	for gvr := range gvrToListKind {
+		resources = append(resources, gvr)
+	}
+
+	objects := []runtime.Object{
+		newObject("admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "", config.ValidatingWebhookConfigurationName),
+		newObject("admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "", "cert-manager-webhook"),
+		newObject("admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "", config.PolicyMutatingWebhookConfigurationName),
+		newObject("admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "", config.VerifyMutatingWebhookConfigurationDebugName),
+		newObject("kyverno.io/v1", "GenerateRequest", config.KyvernoNamespace, "gr-b", "kyverno.io/cleanup"),
+		newObject("kyverno.io/v1", "GenerateRequest", config.KyvernoNamespace, "gr-a"),
+		newObject("kyverno.io/v1alpha1", "ReportChangeRequest", config.KyvernoNamespace, "rcr-a", "kyverno.io/cleanup"),
+		newObject("kyverno.io/v1alpha1", "ClusterReportChangeRequest", "", "crcr-a", "kyverno.io/cleanup", "example.com/hold"),
+		newObject("wgpolicyk8s.io/v1alpha1", "PolicyReport", "default", "polr-ns-default"),
+		newObject("wgpolicyk8s.io/v1alpha1", "PolicyReport", "default", "polr-ns-default-1"),
+		newObject("wgpolicyk8s.io/v1alpha1", "PolicyReport", "default", "trivy-report"),
+		newObject("wgpolicyk8s.io/v1alpha1", "ClusterPolicyReport", "", "clusterpolicyreport"),
+		newObject("wgpolicyk8s.io/v1alpha1", "ClusterPolicyReport", "", "kube-bench"),
+	}
+
+	c, err := client.NewMockClient(runtime.NewScheme(), gvrToListKind, objects...)
+	assert.NilError(t, err)
+	c.SetDiscovery(client.NewFakeDiscoveryClient(resources))
+	return c
+}
+
+func actionStrings(actions []Action) []string {
+	result := []string{}
+	for _, action := range actions {
+		result = append(result, action.String())
+	}
+	return result
+}
+
+func exists(t *testing.T, c *client.Client, kind, namespace, name string) bool {
+	_, err := c.GetResource("", kind, namespace, name)
+	if errorsapi.IsNotFound(err) {
+		return false
+	}
+
+	assert.NilError(t, err)
+	return true
+}
+
+func finalizers(t *testing.T, c *client.Client, kind, namespace, name string) []string {
+	obj, err := c.GetResource("", kind, namespace, name)
+	assert.NilError(t, err)
+	return obj.GetFinalizers()
+}
+
+var expectedActions = []string{
+	"delete MutatingWebhookConfiguration " + config.PolicyMutatingWebhookConfigurationName,
+	"delete MutatingWebhookConfiguration " + config.VerifyMutatingWebhookConfigurationDebugName,
+	"delete ValidatingWebhookConfiguration " + config.ValidatingWebhookConfigurationName,
+	"remove finalizers GenerateRequest " + config.KyvernoNamespace + "/gr-b",
+	"remove finalizers ReportChangeRequest " + config.KyvernoNamespace + "/rcr-a",
+	"remove finalizers ClusterReportChangeRequest crcr-a",
+}
+
+var expectedReportActions = []string{
+	"delete PolicyReport default/polr-ns-default",
+	"delete PolicyReport default/polr-ns-default-1",
+	"delete ClusterPolicyReport clusterpolicyreport",
+}
+
+func Test_Cleanup_DryRun(t *testing.T) {
+	c := newMockClient(t)
+
+	actions, err := Run(c, Options{DryRun: true, DeleteReports: true}, log.Log)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actionStrings(actions), append(append([]string{}, expectedActions...), expectedReportActions...))
+
+	// nothing is changed
+	assert.Assert(t, exists(t, c, "ValidatingWebhookConfiguration", "", config.ValidatingWebhookConfigurationName))
+	assert.Assert(t, exists(t, c, "MutatingWebhookConfiguration", "", config.PolicyMutatingWebhookConfigurationName))
+	assert.DeepEqual(t, finalizers(t, c, "GenerateRequest", config.KyvernoNamespace, "gr-b"), []string{"kyverno.io/cleanup"})
+	assert.DeepEqual(t, finalizers(t, c, "ClusterReportChangeRequest", "", "crcr-a"), []string{"kyverno.io/cleanup", "example.com/hold"})
+	assert.Assert(t, exists(t, c, "PolicyReport", "default", "polr-ns-default"))
+	assert.Assert(t, exists(t, c, "ClusterPolicyReport", "", "clusterpolicyreport"))
+}
+
+func Test_Cleanup(t *testing.T) {
+	c := newMockClient(t)
+
+	actions, err := Run(c, Options{}, log.Log)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actionStrings(actions), expectedActions)
+
+	assert.Assert(t, !exists(t, c, "ValidatingWebhookConfiguration", "", config.ValidatingWebhookConfigurationName))
+	assert.Assert(t, !exists(t, c, "MutatingWebhookConfiguration", "", config.PolicyMutatingWebhookConfigurationName))
+	assert.Assert(t, !exists(t, c, "MutatingWebhookConfiguration", "", config.VerifyMutatingWebhookConfigurationDebugName))
+	assert.Assert(t, exists(t, c, "ValidatingWebhookConfiguration", "", "cert-manager-webhook"))
+
+	assert.Equal(t, len(finalizers(t, c, "GenerateRequest", config.KyvernoNamespace, "gr-b")), 0)
+	assert.Equal(t, len(finalizers(t, c, "ReportChangeRequest", config.KyvernoNamespace, "rcr-a")), 0)
+	assert.Equal(t, len(finalizers(t, c, "ClusterReportChangeRequest", "", "crcr-a")), 0)
+
+	// the reports are kept unless set in the options
+	assert.Assert(t, exists(t, c, "PolicyReport", "default", "polr-ns-default"))
+	assert.Assert(t, exists(t, c, "ClusterPolicyReport", "", "clusterpolicyreport"))
+
+	actions, err = Run(c, Options{DeleteReports: true}, log.Log)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actionStrings(actions), expectedReportActions)
+
+	assert.Assert(t, !exists(t, c, "PolicyReport", "default", "polr-ns-default"))
+	assert.Assert(t, !exists(t, c, "PolicyReport", "default", "polr-ns-default-1"))
+	assert.Assert(t, !exists(t, c, "ClusterPolicyReport", "", "clusterpolicyreport"))
+	assert.Assert(t, exists(t, c, "PolicyReport", "default", "trivy-report"))
+	assert.Assert(t, exists(t, c, "ClusterPolicyReport", "", "kube-bench"))
+
+	// the cleanup is complete, a new run has nothing to do
+	actions, err = Run(c, Options{DeleteReports: true}, log.Log)
+	assert.NilError(t, err)
+	assert.Equal(t, len(actions), 0)
+}
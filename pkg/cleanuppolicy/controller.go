@@ -0,0 +1,256 @@
+package cleanuppolicy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
+	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	dclient "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/engine"
+	enginecontext "github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// checkInterval is the interval of the checks of the due cleanup policies, the
+	// finest resolution of the cron schedules is a minute
+	checkInterval = time.Minute
+
+	// ConditionReady is the condition of the cleanup policies reporting the last cleanup
+	ConditionReady = "Ready"
+
+	// the reasons of the Ready condition
+	reasonSucceeded       = "Succeeded"
+	reasonInvalidSchedule = "InvalidSchedule"
+	reasonCleanupFailed   = "CleanupFailed"
+)
+
+// Controller deletes the resources matching the CleanupPolicies when their schedule is due
+type Controller struct {
+	client *dclient.Client
+
+	kyvernoClient kyvernoclient.Interface
+
+	// cpLister can list/get cleanup policies from the shared informer's store
+	cpLister kyvernolister.CleanupPolicyLister
+
+	// cpSynced returns true if the cleanup policy store has been synced at least once
+	cpSynced cache.InformerSynced
+
+	// nsLister can list/get namespaces from the shared informer's store
+	nsLister listerv1.NamespaceLister
+
+	// configHandler filters the resources excluded from Kyverno
+	configHandler config.Interface
+
+	log logr.Logger
+}
+
+// NewController returns a new controller running the cleanup policies
+func NewController(
+	client *dclient.Client,
+	kyvernoClient kyvernoclient.Interface,
+	cpInformer kyvernoinformer.CleanupPolicyInformer,
+	nsLister listerv1.NamespaceLister,
+	configHandler config.Interface,
+	log logr.Logger,
+) *Controller {
+	return &Controller{
+		client:        client,
+		kyvernoClient: kyvernoClient,
+		cpLister:      cpInformer.Lister(),
+		cpSynced:      cpInformer.Informer().HasSynced,
+		nsLister:      nsLister,
+		configHandler: configHandler,
+		log:           log,
+	}
+}
+
+// Run checks the due cleanup policies every minute
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+
+	c.log.Info("starting")
+	defer c.log.Info("shutting down")
+
+	if !cache.WaitForCacheSync(stopCh, c.cpSynced) {
+		c.log.Info("failed to sync informer cache")
+		return
+	}
+
+	wait.Until(c.runDue, checkInterval, stopCh)
+}
+
+// runDue runs the cleanup policies whose next execution time has passed
+func (c *Controller) runDue() {
+	policies, err := c.cpLister.List(labels.Everything())
+	if err != nil {
+		c.log.Error(err, "failed to list cleanup policies")
+		return
+	}
+
+	now := time.Now()
+	for _, policy := range policies {
+		logger := c.log.WithValues("policy", policy.GetName())
+		next, err := nextExecutionTime(policy)
+		if err != nil {
+			c.updateStatus(policy, nil, 0, metav1.ConditionFalse, reasonInvalidSchedule, err.Error())
+			continue
+		}
+
+		if next.After(now) {
+			continue
+		}
+
+		deleted, err := c.cleanup(policy, now)
+		if err != nil {
+			logger.Error(err, "failed to clean up resources", "deleted", deleted)
+			c.updateStatus(policy, &now, deleted, metav1.ConditionFalse, reasonCleanupFailed, err.Error())
+			continue
+		}
+
+		logger.V(2).Info("cleaned up resources", "deleted", deleted)
+		c.updateStatus(policy, &now, deleted, metav1.ConditionTrue, reasonSucceeded, fmt.Sprintf("deleted %d resources", deleted))
+	}
+}
+
+// nextExecutionTime returns the next execution time of the policy after its last execution,
+// or after its creation if it never ran
+func nextExecutionTime(policy *kyverno.CleanupPolicy) (time.Time, error) {
+	schedule, err := cron.ParseStandard(policy.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule %s: %v", policy.Spec.Schedule, err)
+	}
+
+	last := policy.GetCreationTimestamp().Time
+	if policy.Status.LastExecutionTime != nil {
+		last = policy.Status.LastExecutionTime.Time
+	}
+
+	return schedule.Next(last), nil
+}
+
+// cleanup deletes the resources matching the policy and returns the number of deleted resources.
+// The errors do not stop the cleanup of the other resources.
+func (c *Controller) cleanup(policy *kyverno.CleanupPolicy, now time.Time) (int, error) {
+	var deleted int
+	var errs []error
+	for _, k := range policy.Spec.MatchResources.Kinds {
+		apiVersion, kind := common.GetKindFromGVK(k)
+		list, err := c.client.ListResource(apiVersion, kind, "", policy.Spec.MatchResources.Selector)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %v", k, err))
+			continue
+		}
+
+		for _, resource := range list.Items {
+			if c.configHandler.ToFilter(resource.GetKind(), resource.GetNamespace(), resource.GetName()) {
+				continue
+			}
+
+			namespaceLabels := map[string]string{}
+			if resource.GetNamespace() != "" {
+				namespaceLabels = common.GetNamespaceSelectorsFromNamespaceLister(resource.GetKind(), resource.GetNamespace(), c.nsLister, c.log)
+			}
+
+			if !matches(c.log, policy, resource, namespaceLabels, now) {
+				continue
+			}
+
+			err := c.client.DeleteResource(resource.GetAPIVersion(), resource.GetKind(), resource.GetNamespace(), resource.GetName(), false)
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete %s %s/%s: %v", resource.GetKind(), resource.GetNamespace(), resource.GetName(), err))
+				continue
+			}
+
+			c.log.V(3).Info("deleted resource", "policy", policy.GetName(), "kind", resource.GetKind(), "namespace", resource.GetNamespace(), "name", resource.GetName())
+			deleted++
+		}
+	}
+
+	if len(errs) > 0 {
+		return deleted, fmt.Errorf("%d errors, first error: %v", len(errs), errs[0])
+	}
+
+	return deleted, nil
+}
+
+// matches returns true if the resource matches the match and exclude blocks of the policy, is at
+// least the minimum age at the time and passes the conditions
+func matches(log logr.Logger, policy *kyverno.CleanupPolicy, resource unstructured.Unstructured, namespaceLabels map[string]string, now time.Time) bool {
+	rule := kyverno.Rule{
+		Name:             policy.GetName(),
+		MatchResources:   kyverno.MatchResources{ResourceDescription: policy.Spec.MatchResources},
+		ExcludeResources: kyverno.ExcludeResources{ResourceDescription: policy.Spec.ExcludeResources},
+	}
+
+	if err := engine.MatchesResourceDescription(resource, rule, kyverno.RequestInfo{}, nil, namespaceLabels); err != nil {
+		return false
+	}
+
+	if ok, _ := engine.MatchesMinimumAge(resource, policy.Spec.MatchResources.MinimumAge, now); !ok {
+		return false
+	}
+
+	if policy.Spec.Conditions == nil {
+		return true
+	}
+
+	raw, err := resource.MarshalJSON()
+	if err != nil {
+		log.Error(err, "failed to marshal resource", "kind", resource.GetKind(), "namespace", resource.GetNamespace(), "name", resource.GetName())
+		return false
+	}
+
+	ctx := enginecontext.NewContext()
+	if err := ctx.AddResource(raw); err != nil {
+		log.Error(err, "failed to add resource to the context", "kind", resource.GetKind(), "namespace", resource.GetNamespace(), "name", resource.GetName())
+		return false
+	}
+
+	return variables.EvaluateConditions(log, ctx, *policy.Spec.Conditions.DeepCopy(), true)
+}
+
+// updateStatus records the result of the cleanup in the status of the policy, a repeated
+// result of a policy that did not run, e.g. with an invalid schedule, is not updated
+func (c *Controller) updateStatus(policy *kyverno.CleanupPolicy, executionTime *time.Time, deleted int, status metav1.ConditionStatus, reason, message string) {
+	updated := policy.DeepCopy()
+	if executionTime != nil {
+		updated.Status.LastExecutionTime = &metav1.Time{Time: *executionTime}
+		updated.Status.DeletedCount = deleted
+	}
+
+	meta.SetStatusCondition(&updated.Status.Conditions, metav1.Condition{
+		Type:               ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: policy.GetGeneration(),
+	})
+
+	if reflect.DeepEqual(updated.Status, policy.Status) {
+		return
+	}
+
+	if _, err := c.kyvernoClient.KyvernoV1().CleanupPolicies().UpdateStatus(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		c.log.Error(err, "failed to update cleanup policy status", "policy", policy.GetName())
+	}
+}
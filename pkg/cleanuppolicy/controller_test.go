@@ -0,0 +1,119 @@
+package cleanuppolicy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestPolicy(t *testing.T, raw string) *kyverno.CleanupPolicy {
+	var policy kyverno.CleanupPolicy
+	assert.NilError(t, json.Unmarshal([]byte(raw), &policy))
+	return &policy
+}
+
+func newTestResource(t *testing.T, raw string) unstructured.Unstructured {
+	var resource unstructured.Unstructured
+	assert.NilError(t, resource.UnmarshalJSON([]byte(raw)))
+	return resource
+}
+
+func Test_nextExecutionTime(t *testing.T) {
+	testcases := []struct {
+		policy string
+		next   string
+		err    string
+	}{
+		{
+			policy: `{"metadata": {"name": "p", "creationTimestamp": "2022-03-01T10:30:00Z"}, "spec": {"schedule": "0 2 * * *"}}`,
+			next:   "2022-03-02T02:00:00Z",
+		},
+		{
+			policy: `{"metadata": {"name": "p", "creationTimestamp": "2022-03-01T10:30:00Z"}, "spec": {"schedule": "0 2 * * *"}, "status": {"lastExecutionTime": "2022-03-05T02:00:00Z"}}`,
+			next:   "2022-03-06T02:00:00Z",
+		},
+		{
+			policy: `{"metadata": {"name": "p", "creationTimestamp": "2022-03-01T10:30:00Z"}, "spec": {"schedule": "*/15 * * * *"}}`,
+			next:   "2022-03-01T10:45:00Z",
+		},
+		{
+			policy: `{"metadata": {"name": "p", "creationTimestamp": "2022-03-01T10:30:00Z"}, "spec": {"schedule": "every day"}}`,
+			err:    "invalid schedule every day",
+		},
+	}
+
+	for _, tc := range testcases {
+		next, err := nextExecutionTime(newTestPolicy(t, tc.policy))
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err, tc.policy)
+			continue
+		}
+
+		assert.NilError(t, err, tc.policy)
+		assert.Equal(t, next.UTC().Format(time.RFC3339), tc.next, tc.policy)
+	}
+}
+
+func Test_matches(t *testing.T) {
+	policy := newTestPolicy(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "CleanupPolicy",
+		"metadata": {"name": "completed-jobs"},
+		"spec": {
+			"schedule": "0 2 * * *",
+			"match": {"kinds": ["batch/v1/Job"], "namespaceSelector": {"matchLabels": {"env": "dev"}}, "minimumAge": "168h"},
+			"exclude": {"selector": {"matchLabels": {"keep": "true"}}},
+			"conditions": {"all": [{"key": "{{ request.object.status.succeeded }}", "operator": "GreaterThanOrEquals", "value": 1}]}
+		}
+	}`)
+	now, err := time.Parse(time.RFC3339, "2022-03-10T02:00:00Z")
+	assert.NilError(t, err)
+
+	testcases := []struct {
+		name            string
+		resource        string
+		namespaceLabels map[string]string
+		expected        bool
+	}{
+		{
+			name:            "completed old job",
+			resource:        `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "job", "namespace": "dev", "creationTimestamp": "2022-03-01T02:00:00Z"}, "status": {"succeeded": 1}}`,
+			namespaceLabels: map[string]string{"env": "dev"},
+			expected:        true,
+		},
+		{
+			name:            "running old job",
+			resource:        `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "job", "namespace": "dev", "creationTimestamp": "2022-03-01T02:00:00Z"}, "status": {"active": 1}}`,
+			namespaceLabels: map[string]string{"env": "dev"},
+			expected:        false,
+		},
+		{
+			name:            "completed recent job",
+			resource:        `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "job", "namespace": "dev", "creationTimestamp": "2022-03-09T02:00:00Z"}, "status": {"succeeded": 1}}`,
+			namespaceLabels: map[string]string{"env": "dev"},
+			expected:        false,
+		},
+		{
+			name:            "completed old job in another namespace",
+			resource:        `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "job", "namespace": "prod", "creationTimestamp": "2022-03-01T02:00:00Z"}, "status": {"succeeded": 1}}`,
+			namespaceLabels: map[string]string{"env": "prod"},
+			expected:        false,
+		},
+		{
+			name:            "excluded completed old job",
+			resource:        `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "job", "namespace": "dev", "labels": {"keep": "true"}, "creationTimestamp": "2022-03-01T02:00:00Z"}, "status": {"succeeded": 1}}`,
+			namespaceLabels: map[string]string{"env": "dev"},
+			expected:        false,
+		},
+	}
+
+	for _, tc := range testcases {
+		resource := newTestResource(t, tc.resource)
+		assert.Equal(t, matches(log.Log, policy, resource, tc.namespaceLabels, now), tc.expected, tc.name)
+	}
+}
@@ -28,6 +28,10 @@ type FakeKyvernoV1 struct {
 	*testing.Fake
 }
 
+func (c *FakeKyvernoV1) CleanupPolicies() v1.CleanupPolicyInterface {
+	return &FakeCleanupPolicies{c}
+}
+
 func (c *FakeKyvernoV1) ClusterPolicies() v1.ClusterPolicyInterface {
 	return &FakeClusterPolicies{c}
 }
@@ -40,6 +44,14 @@ func (c *FakeKyvernoV1) Policies(namespace string) v1.PolicyInterface {
 	return &FakePolicies{c, namespace}
 }
 
+func (c *FakeKyvernoV1) PolicyExceptions(namespace string) v1.PolicyExceptionInterface {
+	return &FakePolicyExceptions{c, namespace}
+}
+
+func (c *FakeKyvernoV1) PolicySets() v1.PolicySetInterface {
+	return &FakePolicySets{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeKyvernoV1) RESTClient() rest.Interface {
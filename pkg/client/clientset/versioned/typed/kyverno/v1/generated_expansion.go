@@ -18,8 +18,14 @@ limitations under the License.
 
 package v1
 
+type CleanupPolicyExpansion interface{}
+
 type ClusterPolicyExpansion interface{}
 
 type GenerateRequestExpansion interface{}
 
 type PolicyExpansion interface{}
+
+type PolicyExceptionExpansion interface{}
+
+type PolicySetExpansion interface{}
@@ -26,9 +26,12 @@ import (
 
 type KyvernoV1Interface interface {
 	RESTClient() rest.Interface
+	CleanupPoliciesGetter
 	ClusterPoliciesGetter
 	GenerateRequestsGetter
 	PoliciesGetter
+	PolicyExceptionsGetter
+	PolicySetsGetter
 }
 
 // KyvernoV1Client is used to interact with features provided by the kyverno.io group.
@@ -36,6 +39,10 @@ type KyvernoV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *KyvernoV1Client) CleanupPolicies() CleanupPolicyInterface {
+	return newCleanupPolicies(c)
+}
+
 func (c *KyvernoV1Client) ClusterPolicies() ClusterPolicyInterface {
 	return newClusterPolicies(c)
 }
@@ -48,6 +55,14 @@ func (c *KyvernoV1Client) Policies(namespace string) PolicyInterface {
 	return newPolicies(c, namespace)
 }
 
+func (c *KyvernoV1Client) PolicyExceptions(namespace string) PolicyExceptionInterface {
+	return newPolicyExceptions(c, namespace)
+}
+
+func (c *KyvernoV1Client) PolicySets() PolicySetInterface {
+	return newPolicySets(c)
+}
+
 // NewForConfig creates a new KyvernoV1Client for the given config.
 func NewForConfig(c *rest.Config) (*KyvernoV1Client, error) {
 	config := *c
@@ -0,0 +1,184 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	scheme "github.com/kyverno/kyverno/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PolicySetsGetter has a method to return a PolicySetInterface.
+// A group's client should implement this interface.
+type PolicySetsGetter interface {
+	PolicySets() PolicySetInterface
+}
+
+// PolicySetInterface has methods to work with PolicySet resources.
+type PolicySetInterface interface {
+	Create(ctx context.Context, policySet *v1.PolicySet, opts metav1.CreateOptions) (*v1.PolicySet, error)
+	Update(ctx context.Context, policySet *v1.PolicySet, opts metav1.UpdateOptions) (*v1.PolicySet, error)
+	UpdateStatus(ctx context.Context, policySet *v1.PolicySet, opts metav1.UpdateOptions) (*v1.PolicySet, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.PolicySet, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.PolicySetList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PolicySet, err error)
+	PolicySetExpansion
+}
+
+// policySets implements PolicySetInterface
+type policySets struct {
+	client rest.Interface
+}
+
+// newPolicySets returns a PolicySets
+func newPolicySets(c *KyvernoV1Client) *policySets {
+	return &policySets{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the policySet, and returns the corresponding policySet object, and an error if there is any.
+func (c *policySets) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.PolicySet, err error) {
+	result = &v1.PolicySet{}
+	err = c.client.Get().
+		Resource("policysets").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PolicySets that match those selectors.
+func (c *policySets) List(ctx context.Context, opts metav1.ListOptions) (result *v1.PolicySetList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.PolicySetList{}
+	err = c.client.Get().
+		Resource("policysets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested policySets.
+func (c *policySets) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("policysets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a policySet and creates it.  Returns the server's representation of the policySet, and an error, if there is any.
+func (c *policySets) Create(ctx context.Context, policySet *v1.PolicySet, opts metav1.CreateOptions) (result *v1.PolicySet, err error) {
+	result = &v1.PolicySet{}
+	err = c.client.Post().
+		Resource("policysets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(policySet).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a policySet and updates it. Returns the server's representation of the policySet, and an error, if there is any.
+func (c *policySets) Update(ctx context.Context, policySet *v1.PolicySet, opts metav1.UpdateOptions) (result *v1.PolicySet, err error) {
+	result = &v1.PolicySet{}
+	err = c.client.Put().
+		Resource("policysets").
+		Name(policySet.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(policySet).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *policySets) UpdateStatus(ctx context.Context, policySet *v1.PolicySet, opts metav1.UpdateOptions) (result *v1.PolicySet, err error) {
+	result = &v1.PolicySet{}
+	err = c.client.Put().
+		Resource("policysets").
+		Name(policySet.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(policySet).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the policySet and deletes it. Returns an error if one occurs.
+func (c *policySets) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("policysets").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *policySets) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("policysets").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched policySet.
+func (c *policySets) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.PolicySet, err error) {
+	result = &v1.PolicySet{}
+	err = c.client.Patch(pt).
+		Resource("policysets").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -18,6 +18,10 @@ limitations under the License.
 
 package v1
 
+// CleanupPolicyListerExpansion allows custom methods to be added to
+// CleanupPolicyLister.
+type CleanupPolicyListerExpansion interface{}
+
 // ClusterPolicyListerExpansion allows custom methods to be added to
 // ClusterPolicyLister.
 type ClusterPolicyListerExpansion interface{}
@@ -33,3 +37,15 @@ type PolicyListerExpansion interface{}
 // PolicyNamespaceListerExpansion allows custom methods to be added to
 // PolicyNamespaceLister.
 type PolicyNamespaceListerExpansion interface{}
+
+// PolicyExceptionListerExpansion allows custom methods to be added to
+// PolicyExceptionLister.
+type PolicyExceptionListerExpansion interface{}
+
+// PolicyExceptionNamespaceListerExpansion allows custom methods to be added to
+// PolicyExceptionNamespaceLister.
+type PolicyExceptionNamespaceListerExpansion interface{}
+
+// PolicySetListerExpansion allows custom methods to be added to
+// PolicySetLister.
+type PolicySetListerExpansion interface{}
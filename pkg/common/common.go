@@ -1,19 +1,27 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	enginutils "github.com/kyverno/kyverno/pkg/engine/utils"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -61,6 +69,46 @@ func GetNamespaceSelectorsFromNamespaceLister(kind, namespaceOfResource string,
 	return namespaceLabels
 }
 
+// GetNamespaceEnforcementThreshold - extract the enforcement threshold annotation of the namespace from the namespace lister
+func GetNamespaceEnforcementThreshold(kind, namespaceOfResource string, nsLister listerv1.NamespaceLister, logger logr.Logger) string {
+	if kind == "Namespace" || namespaceOfResource == "" {
+		return ""
+	}
+
+	namespaceObj, err := nsLister.Get(namespaceOfResource)
+	if err != nil {
+		logger.Error(err, "failed to get the namespace", "name", namespaceOfResource)
+		return ""
+	}
+	return namespaceObj.GetAnnotations()[kyverno.EnforcementThresholdAnnotation]
+}
+
+// GetPolicyExceptions - extract the policy exceptions applying to the resources of the namespace from the policy exception
+// lister, i.e. the exceptions of the namespace and of the Kyverno namespace. It is nil if the policy exceptions are disabled.
+func GetPolicyExceptions(namespaceOfResource string, pexLister kyvernolister.PolicyExceptionLister, logger logr.Logger) []*kyverno.PolicyException {
+	if pexLister == nil {
+		return nil
+	}
+
+	namespaces := []string{config.KyvernoNamespace}
+	if namespaceOfResource != "" && namespaceOfResource != config.KyvernoNamespace {
+		namespaces = append(namespaces, namespaceOfResource)
+	}
+
+	var exceptions []*kyverno.PolicyException
+	for _, ns := range namespaces {
+		polexs, err := pexLister.PolicyExceptions(ns).List(labels.Everything())
+		if err != nil {
+			logger.Error(err, "failed to list the policy exceptions", "namespace", ns)
+			continue
+		}
+
+		exceptions = append(exceptions, polexs...)
+	}
+
+	return exceptions
+}
+
 // GetNamespaceLabels - from namespace obj
 func GetNamespaceLabels(namespaceObj *v1.Namespace, logger logr.Logger) map[string]string {
 	namespaceObj.Kind = "Namespace"
@@ -84,6 +132,43 @@ func GetKindFromGVK(str string) (apiVersion string, kind string) {
 	return splitString[0] + "/" + splitString[1], splitString[2]
 }
 
+// versionRegex matches the API versions of the kinds, e.g. "v1" or "v2beta1", and "*" for any version
+var versionRegex = regexp.MustCompile(`^(v[0-9]+((alpha|beta)[0-9]+)?|\*)$`)
+
+// ParseKind splits a kind of the policy rules into its API version, kind and subresource. The kind can be
+// qualified with a version, e.g. "v1/Pod" or "*/Pod", with a group and version, e.g. "apps/v1/Deployment"
+// or "apps/*/Deployment", and with a subresource, e.g. "pods/exec" or "apps/v1/deployments/scale".
+// The wildcard kinds of a group, e.g. "apps/*", have the API version "apps/*" and the kind "*".
+func ParseKind(gvk string) (apiVersion, kind, subresource string) {
+	parts := strings.Split(gvk, "/")
+	switch {
+	case len(parts) > 2 && versionRegex.MatchString(parts[1]):
+		apiVersion, parts = parts[0]+"/"+parts[1], parts[2:]
+	case len(parts) > 1 && versionRegex.MatchString(parts[0]):
+		apiVersion, parts = parts[0], parts[1:]
+	case len(parts) == 2 && parts[1] == "*":
+		return parts[0] + "/*", "*", ""
+	}
+
+	return apiVersion, parts[0], strings.Join(parts[1:], "/")
+}
+
+// SingularKind returns the lower case singular form of a kind or of a resource, so that "Pod", "pod"
+// and "pods" are the same kind
+func SingularKind(kind string) string {
+	kind = strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(kind, "ies"):
+		return strings.TrimSuffix(kind, "ies") + "y"
+	case strings.HasSuffix(kind, "sses"), strings.HasSuffix(kind, "shes"), strings.HasSuffix(kind, "ches"), strings.HasSuffix(kind, "xes"):
+		return strings.TrimSuffix(kind, "es")
+	case strings.HasSuffix(kind, "s") && !strings.HasSuffix(kind, "ss"):
+		return strings.TrimSuffix(kind, "s")
+	}
+
+	return kind
+}
+
 func VariableToJSON(key, value string) []byte {
 	var subString string
 	splitBySlash := strings.Split(key, "\"")
@@ -201,3 +286,14 @@ func removePolicyFromLabels(pName string, labels map[string]string) (bool, map[s
 
 	return false, labels
 }
+
+// UpdatePolicyStatus updates the status subresource of the Policy or ClusterPolicy, the namespaced
+// policies are converted back from the ClusterPolicy they are processed as. The updated policy is returned.
+func UpdatePolicyStatus(client kyvernoclient.Interface, policy *kyverno.ClusterPolicy) (runtime.Object, error) {
+	if policy.GetNamespace() == "" {
+		return client.KyvernoV1().ClusterPolicies().UpdateStatus(context.TODO(), policy, metav1.UpdateOptions{})
+	}
+
+	nsPolicy := kyverno.Policy(*policy)
+	return client.KyvernoV1().Policies(policy.GetNamespace()).UpdateStatus(context.TODO(), &nsPolicy, metav1.UpdateOptions{})
+}
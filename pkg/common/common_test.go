@@ -0,0 +1,54 @@
+package common
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_ParseKind(t *testing.T) {
+	testCases := []struct {
+		gvk         string
+		apiVersion  string
+		kind        string
+		subresource string
+	}{
+		{gvk: "Pod", kind: "Pod"},
+		{gvk: "*", kind: "*"},
+		{gvk: "v1/Pod", apiVersion: "v1", kind: "Pod"},
+		{gvk: "*/Pod", apiVersion: "*", kind: "Pod"},
+		{gvk: "apps/v1/Deployment", apiVersion: "apps/v1", kind: "Deployment"},
+		{gvk: "apps/*/Deployment", apiVersion: "apps/*", kind: "Deployment"},
+		{gvk: "batch/v1beta1/CronJob", apiVersion: "batch/v1beta1", kind: "CronJob"},
+		{gvk: "pods/exec", kind: "pods", subresource: "exec"},
+		{gvk: "v1/pods/exec", apiVersion: "v1", kind: "pods", subresource: "exec"},
+		{gvk: "apps/v1/deployments/scale", apiVersion: "apps/v1", kind: "deployments", subresource: "scale"},
+		{gvk: "*/*", apiVersion: "*", kind: "*"},
+		{gvk: "v1/*", apiVersion: "v1", kind: "*"},
+		{gvk: "apps/*", apiVersion: "apps/*", kind: "*"},
+		{gvk: "apps/v1/*", apiVersion: "apps/v1", kind: "*"},
+	}
+
+	for _, test := range testCases {
+		apiVersion, kind, subresource := ParseKind(test.gvk)
+		assert.Equal(t, apiVersion, test.apiVersion, test.gvk)
+		assert.Equal(t, kind, test.kind, test.gvk)
+		assert.Equal(t, subresource, test.subresource, test.gvk)
+	}
+}
+
+func Test_SingularKind(t *testing.T) {
+	tests := map[string]string{
+		"Pod":             "pod",
+		"pods":            "pod",
+		"Ingress":         "ingress",
+		"ingresses":       "ingress",
+		"networkpolicies": "networkpolicy",
+		"Endpoints":       "endpoint",
+		"*":               "*",
+	}
+
+	for kind, expected := range tests {
+		assert.Equal(t, SingularKind(kind), expected, kind)
+	}
+}
@@ -16,10 +16,13 @@ const (
 	MutatingWebhookConfigurationDebugName = "kyverno-resource-mutating-webhook-cfg-debug"
 	//MutatingWebhookName default resource mutating webhook name
 	MutatingWebhookName = "mutate.kyverno.svc"
+	//MutatingFailWebhookName default resource mutating webhook name of the policies whose failure policy is Fail
+	MutatingFailWebhookName = "mutate-fail.kyverno.svc"
 
 	ValidatingWebhookConfigurationName      = "kyverno-resource-validating-webhook-cfg"
 	ValidatingWebhookConfigurationDebugName = "kyverno-resource-validating-webhook-cfg-debug"
 	ValidatingWebhookName                   = "validate.kyverno.svc"
+	ValidatingFailWebhookName               = "validate-fail.kyverno.svc"
 
 	//VerifyMutatingWebhookConfigurationName default verify mutating webhook configuration name
 	VerifyMutatingWebhookConfigurationName = "kyverno-verify-mutating-webhook-cfg"
@@ -66,9 +69,15 @@ var (
 	//MutatingWebhookServicePath is the path for mutation webhook
 	MutatingWebhookServicePath = "/mutate"
 
+	//MutatingFailWebhookServicePath is the path for mutation webhook of the policies whose failure policy is Fail
+	MutatingFailWebhookServicePath = "/mutate/fail"
+
 	//ValidatingWebhookServicePath is the path for validation webhook
 	ValidatingWebhookServicePath = "/validate"
 
+	//ValidatingFailWebhookServicePath is the path for validation webhook of the policies whose failure policy is Fail
+	ValidatingFailWebhookServicePath = "/validate/fail"
+
 	//PolicyValidatingWebhookServicePath is the path for policy validation webhook(used to validate policy resource)
 	PolicyValidatingWebhookServicePath = "/policyvalidate"
 
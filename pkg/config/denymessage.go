@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	gojmespath "github.com/jmespath/go-jmespath"
+)
+
+// DenyMessageTemplateAnnotation opts a policy out of the deny message template with the value "disabled"
+const DenyMessageTemplateAnnotation = "policies.kyverno.io/deny-message-template"
+
+var denyMessageVariable = regexp.MustCompile(`{{([^{}]*)}}`)
+
+// DenyMessageTemplate composes the message of a failed rule in the denied admission requests, e.g. to add
+// a ticket link and the cluster name to every denial. The variables of the template are JMESPath expressions
+// on the policy, rule, resource and cluster, e.g. "{{rule.message}} (cluster {{cluster.name}})".
+type DenyMessageTemplate struct {
+	template    string
+	expressions map[string]*gojmespath.JMESPath
+}
+
+// ParseDenyMessageTemplate parses the template and compiles its variables
+func ParseDenyMessageTemplate(template string) (*DenyMessageTemplate, error) {
+	t := &DenyMessageTemplate{
+		template:    template,
+		expressions: make(map[string]*gojmespath.JMESPath),
+	}
+
+	for _, match := range denyMessageVariable.FindAllStringSubmatch(template, -1) {
+		expression := strings.TrimSpace(match[1])
+		if expression == "" {
+			return nil, fmt.Errorf("empty variable %s", match[0])
+		}
+
+		compiled, err := gojmespath.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid variable %s: %v", match[0], err)
+		}
+
+		t.expressions[match[0]] = compiled
+	}
+
+	if rest := denyMessageVariable.ReplaceAllString(template, ""); strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return nil, fmt.Errorf("unbalanced braces in the template %q", template)
+	}
+
+	return t, nil
+}
+
+// String returns the template
+func (t *DenyMessageTemplate) String() string {
+	return t.template
+}
+
+// Render substitutes the variables of the template with the values in the data,
+// the variables that do not resolve are substituted with empty strings
+func (t *DenyMessageTemplate) Render(data map[string]interface{}) string {
+	return denyMessageVariable.ReplaceAllStringFunc(t.template, func(variable string) string {
+		value, err := t.expressions[variable].Search(data)
+		if err != nil || value == nil {
+			return ""
+		}
+
+		if s, ok := value.(string); ok {
+			return s
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+
+		return string(raw)
+	})
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_DenyMessageTemplate_Render(t *testing.T) {
+	template, err := ParseDenyMessageTemplate("{{rule.message}} [{{ cluster.name }}] {{policy.name}}/{{rule.name}} {{resource.labels}}{{missing.value}}")
+	assert.NilError(t, err)
+
+	message := template.Render(map[string]interface{}{
+		"policy":   map[string]interface{}{"name": "require-team"},
+		"rule":     map[string]interface{}{"name": "check-team-label", "message": "label 'team' is required"},
+		"resource": map[string]interface{}{"labels": map[string]interface{}{"app": "nginx"}},
+		"cluster":  map[string]interface{}{"name": "prod-eu-1"},
+	})
+	assert.Equal(t, message, `label 'team' is required [prod-eu-1] require-team/check-team-label {"app":"nginx"}`)
+
+	// a template without variables is a static message
+	template, err = ParseDenyMessageTemplate("denied, see https://tickets.example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, template.Render(nil), "denied, see https://tickets.example.com")
+}
+
+func Test_ParseDenyMessageTemplate_Errors(t *testing.T) {
+	for _, template := range []string{
+		"{{rule.message",
+		"{{rule.message}} }}",
+		"{{ }}",
+		"{{rule..message}}",
+		"{{rule.message[}}",
+	} {
+		_, err := ParseDenyMessageTemplate(template)
+		assert.Assert(t, err != nil, template)
+	}
+}
+
+func Test_Load_DenyMessageTemplate(t *testing.T) {
+	cd := &ConfigData{log: log.Log}
+	load := func(data map[string]string) {
+		cm := v1.ConfigMap{Data: data}
+		cm.Name = "init-config"
+		cd.load(cm)
+	}
+
+	load(map[string]string{"denyMessageTemplate": "{{rule.message}} ({{cluster.name}})", "clusterName": "prod-eu-1"})
+	assert.Assert(t, cd.GetDenyMessageTemplate() != nil)
+	assert.Equal(t, cd.GetDenyMessageTemplate().String(), "{{rule.message}} ({{cluster.name}})")
+	assert.Equal(t, cd.GetClusterName(), "prod-eu-1")
+
+	// the previous template is kept if the new template cannot be parsed
+	_, err := ParseDenyMessageTemplate("{{rule.message}} ({{cluster.name)")
+	assert.Assert(t, err != nil)
+	load(map[string]string{"denyMessageTemplate": "{{rule.message}} ({{cluster.name)"})
+	assert.Assert(t, cd.GetDenyMessageTemplate() != nil)
+	assert.Equal(t, cd.GetDenyMessageTemplate().String(), "{{rule.message}} ({{cluster.name}})")
+
+	// an empty template removes the template
+	load(map[string]string{"denyMessageTemplate": ""})
+	assert.Assert(t, cd.GetDenyMessageTemplate() == nil)
+}
@@ -2,12 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/minio/pkg/wildcard"
@@ -22,12 +24,60 @@ import (
 // this configmap stores the resources that are to be filtered
 const cmNameEnv string = "INIT_CONFIG"
 
+// The values of unsupportedPolicyFields, how the policies using fields unknown to this version are admitted
+const (
+	// UnsupportedPolicyFieldsReject denies the policies with a message listing the unknown fields
+	UnsupportedPolicyFieldsReject = "reject"
+
+	// UnsupportedPolicyFieldsIgnore admits the policies, the rules using unknown fields are not applied
+	UnsupportedPolicyFieldsIgnore = "ignore"
+)
+
+// The values of webhookBudgetOverflow, how the requests exceeding the policy evaluation budget are admitted
+const (
+	// WebhookBudgetOverflowDeny denies the requests if enforce policies are not evaluated
+	WebhookBudgetOverflowDeny = "deny"
+
+	// WebhookBudgetOverflowAllow admits the requests without evaluating the remaining enforce policies
+	WebhookBudgetOverflowAllow = "allow"
+)
+
+// WebhookBudget limits the policies evaluated for an admission request in the webhooks and in the audit handler
+type WebhookBudget struct {
+	// MaxPolicies is the maximum number of policies evaluated per request, 0 for no limit
+	MaxPolicies int
+
+	// MaxEvaluationTime is the maximum time spent on a request, including the context loads, 0 for no limit
+	MaxEvaluationTime time.Duration
+
+	// Overflow is how the requests are admitted once the budget is exceeded, "deny" or "allow"
+	Overflow string
+}
+
 var defaultExcludeGroupRole []string = []string{"system:serviceaccounts:kube-system", "system:nodes", "system:kube-scheduler"}
 
 type WebhookConfig struct {
 	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" protobuf:"bytes,5,opt,name=namespaceSelector"`
 }
 
+// InventoryIndex declares an index of the values projected from the resources of a kind,
+// e.g. the hosts of all Ingresses, which policies look up with the lookup_index JMESPath function
+type InventoryIndex struct {
+	// Name is the name used to look up the index
+	Name string `json:"name"`
+
+	// Kind is the kind of the indexed resources, e.g. "Ingress" or "networking.k8s.io/v1/Ingress"
+	Kind string `json:"kind"`
+
+	// JMESPath projects the indexed values from a resource, e.g. "spec.rules[].host"
+	JMESPath string `json:"jmesPath"`
+
+	// LiveLookup lists the resources from the API server on every lookup instead of using the index.
+	// The index is updated asynchronously, enforce rules that must not admit conflicting resources
+	// created at the same time can use it at the cost of a LIST per lookup.
+	LiveLookup bool `json:"liveLookup,omitempty"`
+}
+
 // ConfigData stores the configuration
 type ConfigData struct {
 	client                      kubernetes.Interface
@@ -38,10 +88,24 @@ type ConfigData struct {
 	excludeUsername             []string
 	restrictDevelopmentUsername []string
 	webhooks                    []WebhookConfig
+	inventoryIndexes            []InventoryIndex
+	platformFieldManagers       []string
+	sensitiveKinds              []string
+	denyMessageTemplate         *DenyMessageTemplate
+	clusterName                 string
+	defaultLocale               string
+	matchOriginalUser           bool
 	generateSuccessEvents       bool
+	mutateDryRun                bool
+	mutateConflictDetection     bool
+	shadowEnforce               bool
+	unsupportedPolicyFields     string
+	webhookBudget               WebhookBudget
+	backgroundScanPaused        bool
 	cmSycned                    cache.InformerSynced
 	reconcilePolicyReport       chan<- bool
 	updateWebhookConfigurations chan<- bool
+	updateInventoryIndexes      chan<- bool
 	log                         logr.Logger
 }
 
@@ -110,6 +174,107 @@ func (cd *ConfigData) GetWebhooks() []WebhookConfig {
 	return cd.webhooks
 }
 
+// GetInventoryIndexes return the inventory index declarations
+func (cd *ConfigData) GetInventoryIndexes() []InventoryIndex {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.inventoryIndexes
+}
+
+// GetPlatformFieldManagers return the field managers of the platform mutations
+func (cd *ConfigData) GetPlatformFieldManagers() []string {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.platformFieldManagers
+}
+
+// GetSensitiveKinds return the kinds, in addition to Secret, whose data values are masked
+func (cd *ConfigData) GetSensitiveKinds() []string {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.sensitiveKinds
+}
+
+// GetDenyMessageTemplate return the template of the messages of the denied requests, or nil
+func (cd *ConfigData) GetDenyMessageTemplate() *DenyMessageTemplate {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.denyMessageTemplate
+}
+
+// GetClusterName return the name of the cluster, e.g. for the deny messages
+func (cd *ConfigData) GetClusterName() string {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.clusterName
+}
+
+// GetDefaultLocale return the locale of the rule messages without a locale in the request, e.g. "en"
+func (cd *ConfigData) GetDefaultLocale() string {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.defaultLocale
+}
+
+// GetMatchOriginalUser returns if the subjects of match and exclude are checked against the original user of impersonated requests
+func (cd *ConfigData) GetMatchOriginalUser() bool {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.matchOriginalUser
+}
+
+// GetMutateDryRun returns if all the mutate policies are evaluated without applying the patches, e.g. during an incident response
+func (cd *ConfigData) GetMutateDryRun() bool {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.mutateDryRun
+}
+
+// GetMutateConflictDetection returns if the mutate policies writing different values to the same field of a resource are reported
+func (cd *ConfigData) GetMutateConflictDetection() bool {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.mutateConflictDetection
+}
+
+// GetShadowEnforce returns if all the enforce policies are evaluated without denying the requests, e.g. before an upgrade
+func (cd *ConfigData) GetShadowEnforce() bool {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.shadowEnforce
+}
+
+// GetUnsupportedPolicyFields returns how the policies using fields unknown to this version are admitted,
+// "reject" denies them and "ignore" admits them without the rules using the unknown fields
+func (cd *ConfigData) GetUnsupportedPolicyFields() string {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	if cd.unsupportedPolicyFields == "" {
+		return UnsupportedPolicyFieldsReject
+	}
+	return cd.unsupportedPolicyFields
+}
+
+// GetWebhookBudget returns the limits of the policies evaluated per admission request,
+// the overflow defaults to "deny"
+func (cd *ConfigData) GetWebhookBudget() WebhookBudget {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	budget := cd.webhookBudget
+	if budget.Overflow == "" {
+		budget.Overflow = WebhookBudgetOverflowDeny
+	}
+	return budget
+}
+
+// GetBackgroundScanPaused returns true if the background processing is paused, i.e. the background scans,
+// the enumeration of the existing namespaces by the generate controller and the full reconciliations of the reports
+func (cd *ConfigData) GetBackgroundScanPaused() bool {
+	cd.mux.RLock()
+	defer cd.mux.RUnlock()
+	return cd.backgroundScanPaused
+}
+
 func (cd *ConfigData) GetInitConfigMapName() string {
 	return cd.cmName
 }
@@ -123,11 +288,24 @@ type Interface interface {
 	RestrictDevelopmentUsername() []string
 	FilterNamespaces(namespaces []string) []string
 	GetWebhooks() []WebhookConfig
+	GetInventoryIndexes() []InventoryIndex
+	GetPlatformFieldManagers() []string
+	GetSensitiveKinds() []string
+	GetDenyMessageTemplate() *DenyMessageTemplate
+	GetClusterName() string
+	GetDefaultLocale() string
+	GetMatchOriginalUser() bool
+	GetMutateDryRun() bool
+	GetMutateConflictDetection() bool
+	GetShadowEnforce() bool
+	GetUnsupportedPolicyFields() string
+	GetWebhookBudget() WebhookBudget
+	GetBackgroundScanPaused() bool
 	GetInitConfigMapName() string
 }
 
 // NewConfigData ...
-func NewConfigData(rclient kubernetes.Interface, cmInformer informers.ConfigMapInformer, filterK8sResources, excludeGroupRole, excludeUsername string, reconcilePolicyReport, updateWebhookConfigurations chan<- bool, log logr.Logger) *ConfigData {
+func NewConfigData(rclient kubernetes.Interface, cmInformer informers.ConfigMapInformer, filterK8sResources, excludeGroupRole, excludeUsername string, reconcilePolicyReport, updateWebhookConfigurations, updateInventoryIndexes chan<- bool, log logr.Logger) *ConfigData {
 	// environment var is read at start only
 	if cmNameEnv == "" {
 		log.Info("ConfigMap name not defined in env:INIT_CONFIG: loading no default configuration")
@@ -139,6 +317,7 @@ func NewConfigData(rclient kubernetes.Interface, cmInformer informers.ConfigMapI
 		cmSycned:                    cmInformer.Informer().HasSynced,
 		reconcilePolicyReport:       reconcilePolicyReport,
 		updateWebhookConfigurations: updateWebhookConfigurations,
+		updateInventoryIndexes:      updateInventoryIndexes,
 		log:                         log,
 	}
 
@@ -186,7 +365,11 @@ func (cd *ConfigData) addCM(obj interface{}) {
 	if cm.Name != cd.cmName {
 		return
 	}
-	cd.load(*cm)
+
+	// the inventory indexes are created once the ConfigMap is loaded
+	if _, _, updateInventory := cd.load(*cm); updateInventory {
+		cd.updateInventoryIndexes <- true
+	}
 }
 
 func (cd *ConfigData) updateCM(old, cur interface{}) {
@@ -195,7 +378,7 @@ func (cd *ConfigData) updateCM(old, cur interface{}) {
 		return
 	}
 	// if data has not changed then dont load configmap
-	reconcilePolicyReport, updateWebook := cd.load(*cm)
+	reconcilePolicyReport, updateWebook, updateInventory := cd.load(*cm)
 	if reconcilePolicyReport {
 		cd.log.Info("resource filters changed, sending reconcile signal to the policy controller")
 		cd.reconcilePolicyReport <- true
@@ -205,6 +388,11 @@ func (cd *ConfigData) updateCM(old, cur interface{}) {
 		cd.log.Info("webhook configurations changed, updating webhook configurations")
 		cd.updateWebhookConfigurations <- true
 	}
+
+	if updateInventory {
+		cd.log.Info("inventory indexes changed, updating inventory indexes")
+		cd.updateInventoryIndexes <- true
+	}
 }
 
 func (cd *ConfigData) deleteCM(obj interface{}) {
@@ -230,7 +418,7 @@ func (cd *ConfigData) deleteCM(obj interface{}) {
 	cd.unload(*cm)
 }
 
-func (cd *ConfigData) load(cm v1.ConfigMap) (reconcilePolicyReport, updateWebhook bool) {
+func (cd *ConfigData) load(cm v1.ConfigMap) (reconcilePolicyReport, updateWebhook, updateInventory bool) {
 	logger := cd.log.WithValues("name", cm.Name, "namespace", cm.Namespace)
 	if cm.Data == nil {
 		logger.V(4).Info("configuration: No data defined in ConfigMap")
@@ -301,6 +489,108 @@ func (cd *ConfigData) load(cm v1.ConfigMap) (reconcilePolicyReport, updateWebhoo
 		}
 	}
 
+	inventoryIndexes, ok := cm.Data["inventoryIndexes"]
+	if !ok {
+		logger.V(4).Info("configuration: No inventoryIndexes defined in ConfigMap")
+	} else {
+		indexes, err := parseInventoryIndexes(inventoryIndexes)
+		if err != nil {
+			logger.Error(err, "unable to parse inventory indexes")
+		} else if reflect.DeepEqual(indexes, cd.inventoryIndexes) {
+			logger.V(4).Info("inventoryIndexes did not change")
+		} else {
+			logger.V(2).Info("Updated inventory indexes", "oldInventoryIndexes", cd.inventoryIndexes, "newInventoryIndexes", indexes)
+			cd.inventoryIndexes = indexes
+			updateInventory = true
+		}
+	}
+
+	platformFieldManagers, ok := cm.Data["platformFieldManagers"]
+	if !ok {
+		logger.V(4).Info("configuration: No platformFieldManagers defined in ConfigMap")
+	} else {
+		managers := parseList(platformFieldManagers)
+		if reflect.DeepEqual(managers, cd.platformFieldManagers) {
+			logger.V(4).Info("platformFieldManagers did not change")
+		} else {
+			logger.V(2).Info("Updated platform field managers", "oldPlatformFieldManagers", cd.platformFieldManagers, "newPlatformFieldManagers", managers)
+			cd.platformFieldManagers = managers
+			reconcilePolicyReport = true
+		}
+	}
+
+	sensitiveKinds, ok := cm.Data["sensitiveKinds"]
+	if !ok {
+		logger.V(4).Info("configuration: No sensitiveKinds defined in ConfigMap")
+	} else {
+		kinds := parseList(sensitiveKinds)
+		if reflect.DeepEqual(kinds, cd.sensitiveKinds) {
+			logger.V(4).Info("sensitiveKinds did not change")
+		} else {
+			logger.V(2).Info("Updated sensitive kinds", "oldSensitiveKinds", cd.sensitiveKinds, "newSensitiveKinds", kinds)
+			cd.sensitiveKinds = kinds
+			reconcilePolicyReport = true
+		}
+	}
+
+	denyMessageTemplate, ok := cm.Data["denyMessageTemplate"]
+	if !ok {
+		logger.V(4).Info("configuration: No denyMessageTemplate defined in ConfigMap")
+	} else if strings.TrimSpace(denyMessageTemplate) == "" {
+		if cd.denyMessageTemplate != nil {
+			logger.V(2).Info("Removed deny message template", "oldDenyMessageTemplate", cd.denyMessageTemplate.String())
+			cd.denyMessageTemplate = nil
+		}
+	} else if cd.denyMessageTemplate != nil && cd.denyMessageTemplate.String() == denyMessageTemplate {
+		logger.V(4).Info("denyMessageTemplate did not change")
+	} else {
+		template, err := ParseDenyMessageTemplate(denyMessageTemplate)
+		if err != nil {
+			// a broken template must not change the deny messages of all the policies
+			logger.Info("warning: failed to parse denyMessageTemplate, keeping the previous template", "error", err.Error())
+		} else {
+			logger.V(2).Info("Updated deny message template", "newDenyMessageTemplate", denyMessageTemplate)
+			cd.denyMessageTemplate = template
+		}
+	}
+
+	clusterName, ok := cm.Data["clusterName"]
+	if !ok {
+		logger.V(4).Info("configuration: No clusterName defined in ConfigMap")
+	} else if clusterName == cd.clusterName {
+		logger.V(4).Info("clusterName did not change")
+	} else {
+		logger.V(2).Info("Updated cluster name", "oldClusterName", cd.clusterName, "newClusterName", clusterName)
+		cd.clusterName = clusterName
+	}
+
+	defaultLocale, ok := cm.Data["defaultLocale"]
+	if !ok {
+		logger.V(4).Info("configuration: No defaultLocale defined in ConfigMap")
+		cd.defaultLocale = ""
+	} else if defaultLocale == cd.defaultLocale {
+		logger.V(4).Info("defaultLocale did not change")
+	} else {
+		logger.V(2).Info("Updated default locale", "oldDefaultLocale", cd.defaultLocale, "newDefaultLocale", defaultLocale)
+		cd.defaultLocale = defaultLocale
+	}
+
+	matchOriginalUser, ok := cm.Data["matchOriginalUser"]
+	if !ok {
+		logger.V(4).Info("configuration: No matchOriginalUser defined in ConfigMap")
+		cd.matchOriginalUser = false
+	} else {
+		matchOriginalUser, err := strconv.ParseBool(matchOriginalUser)
+		if err != nil {
+			logger.V(4).Info("configuration: matchOriginalUser must be either true/false")
+		} else if matchOriginalUser == cd.matchOriginalUser {
+			logger.V(4).Info("matchOriginalUser did not change")
+		} else {
+			logger.V(2).Info("Updated matchOriginalUser", "oldMatchOriginalUser", cd.matchOriginalUser, "newMatchOriginalUser", matchOriginalUser)
+			cd.matchOriginalUser = matchOriginalUser
+		}
+	}
+
 	generateSuccessEvents, ok := cm.Data["generateSuccessEvents"]
 	if !ok {
 		logger.V(4).Info("configuration: No generateSuccessEvents defined in ConfigMap")
@@ -317,6 +607,128 @@ func (cd *ConfigData) load(cm v1.ConfigMap) (reconcilePolicyReport, updateWebhoo
 		}
 	}
 
+	mutateDryRun, ok := cm.Data["mutateDryRun"]
+	if !ok {
+		logger.V(4).Info("configuration: No mutateDryRun defined in ConfigMap")
+		cd.mutateDryRun = false
+	} else {
+		mutateDryRun, err := strconv.ParseBool(mutateDryRun)
+		if err != nil {
+			logger.V(4).Info("configuration: mutateDryRun must be either true/false")
+		} else if mutateDryRun == cd.mutateDryRun {
+			logger.V(4).Info("mutateDryRun did not change")
+		} else {
+			logger.V(2).Info("Updated mutateDryRun", "oldMutateDryRun", cd.mutateDryRun, "newMutateDryRun", mutateDryRun)
+			cd.mutateDryRun = mutateDryRun
+		}
+	}
+
+	mutateConflictDetection, ok := cm.Data["mutateConflictDetection"]
+	if !ok {
+		logger.V(4).Info("configuration: No mutateConflictDetection defined in ConfigMap")
+		cd.mutateConflictDetection = false
+	} else {
+		mutateConflictDetection, err := strconv.ParseBool(mutateConflictDetection)
+		if err != nil {
+			logger.V(4).Info("configuration: mutateConflictDetection must be either true/false")
+		} else if mutateConflictDetection == cd.mutateConflictDetection {
+			logger.V(4).Info("mutateConflictDetection did not change")
+		} else {
+			logger.V(2).Info("Updated mutateConflictDetection", "oldMutateConflictDetection", cd.mutateConflictDetection, "newMutateConflictDetection", mutateConflictDetection)
+			cd.mutateConflictDetection = mutateConflictDetection
+		}
+	}
+
+	shadowEnforce, ok := cm.Data["shadowEnforce"]
+	if !ok {
+		logger.V(4).Info("configuration: No shadowEnforce defined in ConfigMap")
+		cd.shadowEnforce = false
+	} else {
+		shadowEnforce, err := strconv.ParseBool(shadowEnforce)
+		if err != nil {
+			logger.V(4).Info("configuration: shadowEnforce must be either true/false")
+		} else if shadowEnforce == cd.shadowEnforce {
+			logger.V(4).Info("shadowEnforce did not change")
+		} else {
+			logger.V(2).Info("Updated shadowEnforce", "oldShadowEnforce", cd.shadowEnforce, "newShadowEnforce", shadowEnforce)
+			cd.shadowEnforce = shadowEnforce
+		}
+	}
+
+	unsupportedPolicyFields, ok := cm.Data["unsupportedPolicyFields"]
+	if !ok {
+		logger.V(4).Info("configuration: No unsupportedPolicyFields defined in ConfigMap")
+		cd.unsupportedPolicyFields = ""
+	} else if unsupportedPolicyFields != UnsupportedPolicyFieldsReject && unsupportedPolicyFields != UnsupportedPolicyFieldsIgnore {
+		logger.V(4).Info("configuration: unsupportedPolicyFields must be either reject/ignore")
+	} else if unsupportedPolicyFields == cd.unsupportedPolicyFields {
+		logger.V(4).Info("unsupportedPolicyFields did not change")
+	} else {
+		logger.V(2).Info("Updated unsupportedPolicyFields", "oldUnsupportedPolicyFields", cd.unsupportedPolicyFields, "newUnsupportedPolicyFields", unsupportedPolicyFields)
+		cd.unsupportedPolicyFields = unsupportedPolicyFields
+	}
+
+	webhookMaxPolicies, ok := cm.Data["webhookMaxPolicies"]
+	if !ok {
+		logger.V(4).Info("configuration: No webhookMaxPolicies defined in ConfigMap")
+		cd.webhookBudget.MaxPolicies = 0
+	} else {
+		maxPolicies, err := strconv.Atoi(webhookMaxPolicies)
+		if err != nil || maxPolicies < 0 {
+			logger.V(4).Info("configuration: webhookMaxPolicies must be a positive number")
+		} else if maxPolicies == cd.webhookBudget.MaxPolicies {
+			logger.V(4).Info("webhookMaxPolicies did not change")
+		} else {
+			logger.V(2).Info("Updated webhookMaxPolicies", "oldWebhookMaxPolicies", cd.webhookBudget.MaxPolicies, "newWebhookMaxPolicies", maxPolicies)
+			cd.webhookBudget.MaxPolicies = maxPolicies
+		}
+	}
+
+	webhookMaxEvaluationTime, ok := cm.Data["webhookMaxEvaluationTime"]
+	if !ok {
+		logger.V(4).Info("configuration: No webhookMaxEvaluationTime defined in ConfigMap")
+		cd.webhookBudget.MaxEvaluationTime = 0
+	} else {
+		maxEvaluationTime, err := time.ParseDuration(webhookMaxEvaluationTime)
+		if err != nil || maxEvaluationTime < 0 {
+			logger.V(4).Info("configuration: webhookMaxEvaluationTime must be a positive duration, e.g. 2s")
+		} else if maxEvaluationTime == cd.webhookBudget.MaxEvaluationTime {
+			logger.V(4).Info("webhookMaxEvaluationTime did not change")
+		} else {
+			logger.V(2).Info("Updated webhookMaxEvaluationTime", "oldWebhookMaxEvaluationTime", cd.webhookBudget.MaxEvaluationTime.String(), "newWebhookMaxEvaluationTime", maxEvaluationTime.String())
+			cd.webhookBudget.MaxEvaluationTime = maxEvaluationTime
+		}
+	}
+
+	webhookBudgetOverflow, ok := cm.Data["webhookBudgetOverflow"]
+	if !ok {
+		logger.V(4).Info("configuration: No webhookBudgetOverflow defined in ConfigMap")
+		cd.webhookBudget.Overflow = ""
+	} else if webhookBudgetOverflow != WebhookBudgetOverflowDeny && webhookBudgetOverflow != WebhookBudgetOverflowAllow {
+		logger.V(4).Info("configuration: webhookBudgetOverflow must be either deny/allow")
+	} else if webhookBudgetOverflow == cd.webhookBudget.Overflow {
+		logger.V(4).Info("webhookBudgetOverflow did not change")
+	} else {
+		logger.V(2).Info("Updated webhookBudgetOverflow", "oldWebhookBudgetOverflow", cd.webhookBudget.Overflow, "newWebhookBudgetOverflow", webhookBudgetOverflow)
+		cd.webhookBudget.Overflow = webhookBudgetOverflow
+	}
+
+	backgroundScanPaused, ok := cm.Data["backgroundScanPaused"]
+	if !ok {
+		logger.V(4).Info("configuration: No backgroundScanPaused defined in ConfigMap")
+		cd.backgroundScanPaused = false
+	} else {
+		backgroundScanPaused, err := strconv.ParseBool(backgroundScanPaused)
+		if err != nil {
+			logger.V(4).Info("configuration: backgroundScanPaused must be either true/false")
+		} else if backgroundScanPaused == cd.backgroundScanPaused {
+			logger.V(4).Info("backgroundScanPaused did not change")
+		} else {
+			logger.V(2).Info("Updated backgroundScanPaused", "oldBackgroundScanPaused", cd.backgroundScanPaused, "newBackgroundScanPaused", backgroundScanPaused)
+			cd.backgroundScanPaused = backgroundScanPaused
+		}
+	}
+
 	return
 }
 
@@ -360,7 +772,14 @@ func (cd *ConfigData) unload(cm v1.ConfigMap) {
 	cd.excludeGroupRole = []string{}
 	cd.excludeGroupRole = append(cd.excludeGroupRole, defaultExcludeGroupRole...)
 	cd.excludeUsername = []string{}
+	cd.inventoryIndexes = nil
 	cd.generateSuccessEvents = false
+	cd.mutateDryRun = false
+	cd.mutateConflictDetection = false
+	cd.shadowEnforce = false
+	cd.unsupportedPolicyFields = ""
+	cd.webhookBudget = WebhookBudget{}
+	cd.backgroundScanPaused = false
 }
 
 type k8Resource struct {
@@ -402,6 +821,17 @@ func parseRbac(list string) []string {
 	return strings.Split(list, ",")
 }
 
+// parseList parses a comma separated list, e.g. of field managers or kinds
+func parseList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseWebhooks(webhooks string) ([]WebhookConfig, error) {
 	webhookCfgs := make([]WebhookConfig, 0, 10)
 	if err := json.Unmarshal([]byte(webhooks), &webhookCfgs); err != nil {
@@ -410,3 +840,23 @@ func parseWebhooks(webhooks string) ([]WebhookConfig, error) {
 
 	return webhookCfgs, nil
 }
+
+func parseInventoryIndexes(indexes string) ([]InventoryIndex, error) {
+	var inventoryIndexes []InventoryIndex
+	if err := json.Unmarshal([]byte(indexes), &inventoryIndexes); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, index := range inventoryIndexes {
+		if index.Name == "" || index.Kind == "" || index.JMESPath == "" {
+			return nil, fmt.Errorf("inventory index %q requires a name, a kind and a jmesPath", index.Name)
+		}
+		if names[index.Name] {
+			return nil, fmt.Errorf("duplicate inventory index %q", index.Name)
+		}
+		names[index.Name] = true
+	}
+
+	return inventoryIndexes, nil
+}
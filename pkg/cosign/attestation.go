@@ -0,0 +1,224 @@
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/engine/jmespath"
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+const (
+	// attestationTagSuffix is the suffix of the tag of the attestations of a digest, e.g. sha256-<hex>.att
+	attestationTagSuffix = ".att"
+
+	// inTotoPayloadType is the DSSE payload type of the in-toto statements
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// envelope is a DSSE envelope signing an in-toto statement
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// Statement is an in-toto statement, the predicate is the attested data
+type Statement struct {
+	Type          string                   `json:"_type"`
+	PredicateType string                   `json:"predicateType"`
+	Subject       []map[string]interface{} `json:"subject"`
+	Predicate     interface{}              `json:"predicate"`
+}
+
+// Severity returns the severity of the score returned by the JMESPath expression of severityFrom on the predicate,
+// the highest severity whose threshold the score reaches or "low". It returns false if the score is missing or
+// is not a number, the severity of the policy applies.
+func (s Statement) Severity(severityFrom v1.SeverityFrom) (string, bool, error) {
+	jp, err := jmespath.New(severityFrom.JMESPath)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "invalid JMESPath %s", severityFrom.JMESPath)
+	}
+
+	result, err := jp.Search(s.Predicate)
+	if err != nil {
+		return "", false, nil
+	}
+
+	score, ok := toScore(result)
+	if !ok {
+		return "", false, nil
+	}
+
+	thresholds := severityFrom.Thresholds
+	for _, threshold := range []struct {
+		severity string
+		minimum  int
+	}{
+		{report.SeverityCritical, thresholds.Critical},
+		{report.SeverityHigh, thresholds.High},
+		{report.SeverityMedium, thresholds.Medium},
+	} {
+		if threshold.minimum > 0 && score >= float64(threshold.minimum) {
+			return threshold.severity, true, nil
+		}
+	}
+
+	return report.SeverityLow, true, nil
+}
+
+// toScore converts the result of the JMESPath expression to a number, the numbers can be quoted
+func toScore(result interface{}) (float64, bool) {
+	switch value := result.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		score, err := value.Float64()
+		return score, err == nil
+	case string:
+		score, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return score, err == nil
+	}
+
+	return 0, false
+}
+
+// FetchAttestations returns the in-toto statements attached to the digest of the image that are signed
+// with one of the keys, the statements of other subjects and the unsigned statements are ignored
+func FetchAttestations(imageRef string, keys []string, log logr.Logger) ([]Statement, error) {
+	ref, err := parseReference(imageRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse image")
+	}
+
+	digestRef, err := resolveDigest(ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve image digest")
+	}
+
+	var pubKeys []*ecdsa.PublicKey
+	for i, key := range keys {
+		pubKey, err := cosign.PemToECDSAKey([]byte(key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode PEM of key %d", i)
+		}
+
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	digest := digestRef.DigestStr()
+	tag := digestRef.Context().Tag(strings.Replace(digest, ":", "-", 1) + attestationTagSuffix)
+	img, err := remote.Image(tag, remoteOptions()...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the attestations")
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the attestations")
+	}
+
+	var statements []Statement
+	for i, layer := range layers {
+		rc, err := layer.Compressed()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch attestation %d", i)
+		}
+
+		raw, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read attestation %d", i)
+		}
+
+		statement, err := verifyEnvelope(raw, pubKeys, digest)
+		if err != nil {
+			log.V(3).Info("ignoring attestation", "image", imageRef, "index", i, "reason", err.Error())
+			continue
+		}
+
+		statements = append(statements, *statement)
+	}
+
+	return statements, nil
+}
+
+// verifyEnvelope checks the signature of the DSSE envelope with the keys and returns its in-toto statement
+// if the digest is one of its subjects
+func verifyEnvelope(raw []byte, pubKeys []*ecdsa.PublicKey, digest string) (*Statement, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, errors.Wrap(err, "invalid envelope")
+	}
+
+	if env.PayloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("unsupported payload type %s", env.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid payload")
+	}
+
+	if !verifySignatures(env, payload, pubKeys) {
+		return nil, fmt.Errorf("not signed with the keys")
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, errors.Wrap(err, "invalid in-toto statement")
+	}
+
+	for _, subject := range statement.Subject {
+		digests, _ := subject["digest"].(map[string]interface{})
+		for algorithm, hex := range digests {
+			if fmt.Sprintf("%s:%v", algorithm, hex) == digest {
+				return &statement, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("the image %s is not a subject of the statement", digest)
+}
+
+// verifySignatures checks if one of the signatures of the envelope is valid for one of the keys
+func verifySignatures(env envelope, payload []byte, pubKeys []*ecdsa.PublicKey) bool {
+	hash := sha256.Sum256(preAuthEncoding(env.PayloadType, payload))
+	for _, signature := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err != nil {
+			continue
+		}
+
+		for _, pubKey := range pubKeys {
+			if ecdsa.VerifyASN1(pubKey, hash[:], sig) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// preAuthEncoding returns the DSSE pre-authentication encoding of the payload, i.e. the signed bytes
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
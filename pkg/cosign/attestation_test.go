@@ -0,0 +1,104 @@
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+)
+
+const testDigest = "sha256:4a1c4b21597c1b4415bdbecb28a3296c6b5e23ca4f9feeb599860a1dac6a0108"
+
+func newTestEnvelope(t *testing.T, key *ecdsa.PrivateKey, payloadType string, statement string) []byte {
+	payload := []byte(statement)
+	hash := sha256.Sum256(preAuthEncoding(payloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	assert.NilError(t, err)
+
+	raw, err := json.Marshal(map[string]interface{}{
+		"payloadType": payloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures":  []map[string]string{{"keyid": "", "sig": base64.StdEncoding.EncodeToString(sig)}},
+	})
+	assert.NilError(t, err)
+	return raw
+}
+
+func Test_VerifyEnvelope(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	statement := `{
+		"_type": "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.1",
+		"subject": [{"name": "ghcr.io/acme/nginx", "digest": {"sha256": "4a1c4b21597c1b4415bdbecb28a3296c6b5e23ca4f9feeb599860a1dac6a0108"}}],
+		"predicate": {"builder": {"id": "https://github.com/acme/builder"}}
+	}`
+
+	raw := newTestEnvelope(t, key, inTotoPayloadType, statement)
+	verified, err := verifyEnvelope(raw, []*ecdsa.PublicKey{&otherKey.PublicKey, &key.PublicKey}, testDigest)
+	assert.NilError(t, err)
+	assert.Equal(t, verified.PredicateType, "https://slsa.dev/provenance/v0.1")
+	assert.DeepEqual(t, verified.Predicate, map[string]interface{}{"builder": map[string]interface{}{"id": "https://github.com/acme/builder"}})
+
+	// the envelope must be signed with one of the keys
+	_, err = verifyEnvelope(raw, []*ecdsa.PublicKey{&otherKey.PublicKey}, testDigest)
+	assert.ErrorContains(t, err, "not signed with the keys")
+
+	// the statement must be about the image
+	_, err = verifyEnvelope(raw, []*ecdsa.PublicKey{&key.PublicKey}, "sha256:0000")
+	assert.ErrorContains(t, err, "is not a subject of the statement")
+
+	_, err = verifyEnvelope(newTestEnvelope(t, key, "text/plain", statement), []*ecdsa.PublicKey{&key.PublicKey}, testDigest)
+	assert.ErrorContains(t, err, "unsupported payload type text/plain")
+}
+
+func Test_StatementSeverity(t *testing.T) {
+	severityFrom := v1.SeverityFrom{
+		JMESPath:   "max(scanner.result[].cvss)",
+		Thresholds: v1.SeverityThresholds{Critical: 9, High: 7, Medium: 4},
+	}
+
+	testCases := []struct {
+		name      string
+		predicate string
+		severity  string
+		computed  bool
+	}{
+		{name: "critical", predicate: `{"scanner":{"result":[{"id":"CVE-2021-44228","cvss":10},{"id":"CVE-2021-3711","cvss":9.8}]}}`, severity: "critical", computed: true},
+		{name: "high", predicate: `{"scanner":{"result":[{"id":"CVE-2021-3449","cvss":7.5},{"id":"CVE-2021-23840","cvss":5.3}]}}`, severity: "high", computed: true},
+		{name: "medium at the threshold", predicate: `{"scanner":{"result":[{"id":"CVE-2021-23841","cvss":4}]}}`, severity: "medium", computed: true},
+		{name: "low", predicate: `{"scanner":{"result":[{"id":"CVE-2021-3450","cvss":3.7}]}}`, severity: "low", computed: true},
+		{name: "no vulnerabilities", predicate: `{"scanner":{"result":[]}}`},
+		{name: "missing score", predicate: `{"scanner":{}}`},
+		{name: "non-numeric score", predicate: `{"scanner":{"result":[{"id":"CVE-2021-3449","cvss":"unknown"}]}}`},
+	}
+
+	for _, test := range testCases {
+		var predicate interface{}
+		assert.NilError(t, json.Unmarshal([]byte(test.predicate), &predicate))
+
+		severity, computed, err := Statement{Predicate: predicate}.Severity(severityFrom)
+		assert.NilError(t, err, test.name)
+		assert.Equal(t, computed, test.computed, test.name)
+		assert.Equal(t, severity, test.severity, test.name)
+	}
+
+	// the scores can be quoted, and the thresholds that are not set are ignored
+	statement := Statement{Predicate: map[string]interface{}{"score": "8.1"}}
+	severity, computed, err := statement.Severity(v1.SeverityFrom{JMESPath: "score", Thresholds: v1.SeverityThresholds{Critical: 9, Medium: 4}})
+	assert.NilError(t, err)
+	assert.Assert(t, computed)
+	assert.Equal(t, severity, "medium")
+
+	_, _, err = statement.Severity(v1.SeverityFrom{JMESPath: "max("})
+	assert.ErrorContains(t, err, "invalid JMESPath")
+}
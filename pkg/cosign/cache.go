@@ -0,0 +1,72 @@
+package cosign
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// verifiedCacheTTL is the time a successful verification of a digest is reused. The digest of an image
+// is immutable, the TTL bounds the size of the cache and the time a revoked signature is accepted.
+const verifiedCacheTTL = 30 * time.Minute
+
+// verifiedCache is shared by the verifications of all the admission requests
+var verifiedCache = newVerificationCache(verifiedCacheTTL)
+
+// verificationCache stores the successful verifications of the images by digest and options,
+// the failed verifications are not cached as the signatures may be pushed later
+type verificationCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	verified map[string]time.Time
+	now      func() time.Time
+}
+
+func newVerificationCache(ttl time.Duration) *verificationCache {
+	return &verificationCache{
+		ttl:      ttl,
+		verified: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (c *verificationCache) get(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.verified[key]
+	if !ok {
+		return false
+	}
+
+	if c.now().After(expires) {
+		delete(c.verified, key)
+		return false
+	}
+
+	return true
+}
+
+func (c *verificationCache) set(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, expires := range c.verified {
+		if now.After(expires) {
+			delete(c.verified, k)
+		}
+	}
+
+	c.verified[key] = now.Add(c.ttl)
+}
+
+// cacheKey returns the key of the verification of the digest with the options
+func cacheKey(ref name.Digest, opts Options) string {
+	raw, _ := json.Marshal(opts)
+	return fmt.Sprintf("%s/%x", ref.String(), sha256.Sum256(raw))
+}
@@ -0,0 +1,35 @@
+package cosign
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/assert"
+)
+
+func Test_VerificationCache(t *testing.T) {
+	now := time.Now()
+	cache := newVerificationCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ref, err := name.NewDigest("ghcr.io/acme/nginx@sha256:4a1c4b21597c1b4415bdbecb28a3296c6b5e23ca4f9feeb599860a1dac6a0108")
+	assert.NilError(t, err)
+
+	anyKey := cacheKey(ref, Options{Keys: []string{"key-1", "key-2"}, Count: 1})
+	allKeys := cacheKey(ref, Options{Keys: []string{"key-1", "key-2"}})
+	assert.Assert(t, anyKey != allKeys)
+	assert.Equal(t, anyKey, cacheKey(ref, Options{Keys: []string{"key-1", "key-2"}, Count: 1}))
+
+	assert.Assert(t, !cache.get(anyKey))
+	cache.set(anyKey)
+	assert.Assert(t, cache.get(anyKey))
+
+	// the verification with other options is not cached
+	assert.Assert(t, !cache.get(allKeys))
+
+	// the verifications expire after the TTL
+	now = now.Add(2 * time.Minute)
+	assert.Assert(t, !cache.get(anyKey))
+	assert.Equal(t, len(cache.verified), 0)
+}
@@ -3,114 +3,227 @@ package cosign
 import (
 	"context"
 	"crypto"
-	"encoding/json"
+	"crypto/x509"
 	"fmt"
+	"strings"
+
 	"github.com/go-logr/logr"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/minio/pkg/wildcard"
 	"github.com/pkg/errors"
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/sigstore/pkg/signature"
 	"k8s.io/client-go/kubernetes"
 )
 
-// Initialize loads the image pull secrets and initializes the default auth method for container registry API calls
+// DefaultRekorURL is the public Rekor transparency log the keyless signatures are checked against
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+// Options are the options of the verification of the signatures of an image
+type Options struct {
+	// Keys are the PEM encoded public keys, the keyless signatures are verified if empty
+	Keys []string
+
+	// Count is the minimum number of the keys that must have signed the image, 0 for all the keys
+	Count int
+
+	// Subject is the pattern of the email or the URI of the keyless signer
+	Subject string
+
+	// Roots are the PEM encoded roots of the keyless certificates, the Fulcio roots if empty
+	Roots string
+
+	// Rekor is the URL of the transparency log, the signatures of the keys are not checked if empty
+	Rekor string
+
+	// ImagePullSecrets are the names of the image pull secrets of the Kyverno namespace of the registry
+	ImagePullSecrets []string
+}
+
+// Initialize loads the image pull secrets and initializes the default auth method for container registry API calls.
+// The credentials of the image pull secrets of the policies are resolved first, then the credentials of the global
+// image pull secrets, or of the default keychain if there are none.
 func Initialize(client kubernetes.Interface, namespace, serviceAccount string, imagePullSecrets []string) error {
-	var kc authn.Keychain
-	kcOpts := &k8schain.Options{
-		Namespace:          namespace,
-		ServiceAccountName: serviceAccount,
-		ImagePullSecrets:   imagePullSecrets,
-	}
+	base := authn.DefaultKeychain
+	if len(imagePullSecrets) > 0 {
+		kcOpts := &k8schain.Options{
+			Namespace:          namespace,
+			ServiceAccountName: serviceAccount,
+			ImagePullSecrets:   imagePullSecrets,
+		}
 
-	kc, err := k8schain.New(context.Background(), client, *kcOpts)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize registry keychain")
+		kc, err := k8schain.New(context.Background(), client, *kcOpts)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize registry keychain")
+		}
+
+		base = kc
 	}
 
-	authn.DefaultKeychain = kc
+	keychain = newRegistryKeychain(base, client, namespace)
+	authn.DefaultKeychain = keychain
 	return nil
 }
 
-func Verify(imageRef string, key []byte, log logr.Logger) (digest string, err error) {
-	pubKey, err := decodePEM(key)
+// Verify verifies the signatures of the image and returns its digest. The tag of the image is resolved
+// to a digest first, and the result of a successful verification of the digest is cached.
+func Verify(imageRef string, opts Options, log logr.Logger) (digest string, err error) {
+	keychain.useSecrets(opts.ImagePullSecrets)
+	ref, err := parseReference(imageRef)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to decode PEM %v", string(key))
+		return "", errors.Wrap(err, "failed to parse image")
 	}
 
-	cosignOpts := &cosign.CheckOpts{
-		Annotations: map[string]interface{}{},
-		Claims:      false,
-		Tlog:        false,
-		Roots:       nil,
-		PubKey:      pubKey,
+	digestRef, err := resolveDigest(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve image digest")
 	}
 
-	ref, err := name.ParseReference(imageRef)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to parse image")
+	digest = digestRef.DigestStr()
+	key := cacheKey(digestRef, opts)
+	if verifiedCache.get(key) {
+		log.V(4).Info("image signatures already verified", "image", imageRef, "digest", digest)
+		return digest, nil
 	}
 
-	verified, err := cosign.Verify(context.Background(), ref, cosignOpts, "https://rekor.sigstore.dev")
-	if err != nil {
-		return "", errors.Wrap(err, "failed to verify image")
+	if len(opts.Keys) == 0 {
+		err = verifyKeyless(digestRef, opts, log)
+	} else {
+		err = verifyKeys(digestRef, opts, log)
 	}
 
-	digest, err = extractDigest(imageRef, verified, log)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get digest")
+		return "", err
 	}
 
+	verifiedCache.set(key)
 	return digest, nil
 }
 
-func decodePEM(raw []byte) (pub cosign.PublicKey, err error) {
-	// PEM encoded file.
-	ed, err := cosign.PemToECDSAKey(raw)
+// resolveDigest returns the reference of the image by digest, the tag is resolved with a HEAD request
+func resolveDigest(ref name.Reference) (name.Digest, error) {
+	if d, ok := ref.(name.Digest); ok {
+		return d, nil
+	}
+
+	desc, err := remote.Head(ref, remoteOptions()...)
 	if err != nil {
-		return nil, errors.Wrap(err, "pem to ecdsa")
+		return name.Digest{}, err
 	}
 
-	return signature.ECDSAVerifier{Key: ed, HashAlg: crypto.SHA256}, nil
+	return ref.Context().Digest(desc.Digest.String()), nil
 }
 
-func extractDigest(imgRef string, verified []cosign.SignedPayload, log logr.Logger) (string, error) {
-	var jsonMap map[string]interface{}
-	for _, vp := range verified {
-		if err := json.Unmarshal(vp.Payload, &jsonMap); err != nil {
-			return "", err
+// verifyKeys checks that the image is signed with at least Count of the keys
+func verifyKeys(ref name.Digest, opts Options, log logr.Logger) error {
+	required := opts.Count
+	if required == 0 || required > len(opts.Keys) {
+		required = len(opts.Keys)
+	}
+
+	var verified int
+	var failures []string
+	for i, key := range opts.Keys {
+		pubKey, err := decodePEM([]byte(key))
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode PEM of key %d", i)
+		}
+
+		cosignOpts := &cosign.CheckOpts{
+			Annotations: map[string]interface{}{},
+			Claims:      true,
+			Tlog:        opts.Rekor != "",
+			Roots:       nil,
+			PubKey:      pubKey,
 		}
 
-		log.V(4).Info("image verification response", "image", imgRef, "payload", jsonMap)
-
-		// The cosign response is in the JSON format:
-		// {
-		//   "critical": {
-		// 	   "identity": {
-		// 	     "docker-reference": "registry-v2.nirmata.io/pause"
-		// 	    },
-		//   	"image": {
-		// 	     "docker-manifest-digest": "sha256:4a1c4b21597c1b4415bdbecb28a3296c6b5e23ca4f9feeb599860a1dac6a0108"
-		// 	    },
-		// 	    "type": "cosign container image signature"
-		//    },
-		//    "optional": null
-		// }
-		critical := jsonMap["critical"].(map[string]interface{})
-		if critical != nil {
-			typeStr := critical["type"].(string)
-			if typeStr == "cosign container image signature" {
-				identity := critical["identity"].(map[string]interface{})
-				if identity != nil {
-					image := critical["image"].(map[string]interface{})
-					if image != nil {
-						return image["docker-manifest-digest"].(string), nil
-					}
-				}
+		if _, err := cosign.Verify(context.Background(), ref, cosignOpts, opts.Rekor); err != nil {
+			log.V(3).Info("image not signed with the key", "image", ref.String(), "key", i, "reason", err.Error())
+			failures = append(failures, fmt.Sprintf("key %d: %v", i, err))
+			continue
+		}
+
+		verified++
+		if verified == required {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to verify image: signed with %d of the %d required keys: %s", verified, required, strings.Join(failures, "; "))
+}
+
+// verifyKeyless checks that the image has a keyless signature of the subject, whose certificate chains
+// to the roots and which is recorded in the transparency log
+func verifyKeyless(ref name.Digest, opts Options, log logr.Logger) error {
+	pem := fulcioRoots
+	if opts.Roots != "" {
+		pem = opts.Roots
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(pem)) {
+		return fmt.Errorf("failed to load the roots: no PEM encoded certificate")
+	}
+
+	rekor := opts.Rekor
+	if rekor == "" {
+		rekor = DefaultRekorURL
+	}
+
+	cosignOpts := &cosign.CheckOpts{
+		Annotations: map[string]interface{}{},
+		Claims:      true,
+		Tlog:        true,
+		Roots:       roots,
+	}
+
+	verified, err := cosign.Verify(context.Background(), ref, cosignOpts, rekor)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify image")
+	}
+
+	if opts.Subject == "" {
+		return nil
+	}
+
+	var signers []string
+	for _, payload := range verified {
+		if payload.Cert == nil {
+			continue
+		}
+
+		for _, subject := range certSubjects(payload.Cert) {
+			if wildcard.Match(opts.Subject, subject) {
+				log.V(4).Info("verified keyless signature", "image", ref.String(), "subject", subject)
+				return nil
 			}
+
+			signers = append(signers, subject)
 		}
 	}
 
-	return "", fmt.Errorf("digest not found for " + imgRef)
+	return fmt.Errorf("failed to verify image: no signature of subject %s, signed by [%s]", opts.Subject, strings.Join(signers, ", "))
+}
+
+// certSubjects returns the emails and the URIs of the certificate
+func certSubjects(cert *x509.Certificate) []string {
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+
+	return subjects
+}
+
+func decodePEM(raw []byte) (pub cosign.PublicKey, err error) {
+	// PEM encoded file.
+	ed, err := cosign.PemToECDSAKey(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "pem to ecdsa")
+	}
+
+	return signature.ECDSAVerifier{Key: ed, HashAlg: crypto.SHA256}, nil
 }
@@ -0,0 +1,174 @@
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// secretsTTL is the time the credentials of an image pull secret are reused before the secret is read again
+const secretsTTL = 5 * time.Minute
+
+// keychain resolves the credentials of the image pull secrets of the policies, it is nil until Initialize is called
+var keychain *registryKeychain
+
+// registryKeychain resolves the credentials of a registry from the image pull secrets of the verifyImages rules,
+// and falls back to the keychain of the global image pull secrets. The secrets are read from the Kyverno namespace
+// and are checked in the order of their names.
+type registryKeychain struct {
+	base      authn.Keychain
+	client    kubernetes.Interface
+	namespace string
+
+	mu      sync.Mutex
+	secrets map[string]*registrySecret
+	now     func() time.Time
+}
+
+// registrySecret are the credentials of an image pull secret by registry
+type registrySecret struct {
+	auths   map[string]authn.AuthConfig
+	fetched time.Time
+}
+
+func newRegistryKeychain(base authn.Keychain, client kubernetes.Interface, namespace string) *registryKeychain {
+	return &registryKeychain{
+		base:      base,
+		client:    client,
+		namespace: namespace,
+		secrets:   make(map[string]*registrySecret),
+		now:       time.Now,
+	}
+}
+
+// useSecrets registers the image pull secrets of a rule
+func (k *registryKeychain) useSecrets(names []string) {
+	if k == nil {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, secret := range names {
+		if _, ok := k.secrets[secret]; !ok && secret != "" {
+			k.secrets[secret] = &registrySecret{}
+		}
+	}
+}
+
+// Resolve returns the credentials of the registry of the target
+func (k *registryKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := normalizeRegistry(target.RegistryStr())
+
+	k.mu.Lock()
+	names := make([]string, 0, len(k.secrets))
+	for secret := range k.secrets {
+		names = append(names, secret)
+	}
+	k.mu.Unlock()
+
+	sort.Strings(names)
+	for _, secret := range names {
+		auths, err := k.auths(secret)
+		if err != nil {
+			return nil, err
+		}
+
+		if auth, ok := auths[registry]; ok {
+			return authn.FromConfig(auth), nil
+		}
+	}
+
+	return k.base.Resolve(target)
+}
+
+// auths returns the credentials of the image pull secret, the secret is read again once the TTL has expired
+func (k *registryKeychain) auths(secretName string) (map[string]authn.AuthConfig, error) {
+	k.mu.Lock()
+	cached := k.secrets[secretName]
+	if cached != nil && cached.auths != nil && k.now().Sub(cached.fetched) < secretsTTL {
+		k.mu.Unlock()
+		return cached.auths, nil
+	}
+	k.mu.Unlock()
+
+	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image pull secret %s/%s: %v", k.namespace, secretName, err)
+	}
+
+	auths, err := parseDockerConfig(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image pull secret %s/%s: %v", k.namespace, secretName, err)
+	}
+
+	k.mu.Lock()
+	k.secrets[secretName] = &registrySecret{auths: auths, fetched: k.now()}
+	k.mu.Unlock()
+	return auths, nil
+}
+
+// dockerAuth are the credentials of a registry in a docker config
+type dockerAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+// parseDockerConfig returns the credentials of a secret of type kubernetes.io/dockerconfigjson or
+// kubernetes.io/dockercfg by registry
+func parseDockerConfig(secret *corev1.Secret) (map[string]authn.AuthConfig, error) {
+	var auths map[string]dockerAuth
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		var config struct {
+			Auths map[string]dockerAuth `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		auths = config.Auths
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		if err := json.Unmarshal(data, &auths); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("no %s or %s key", corev1.DockerConfigJsonKey, corev1.DockerConfigKey)
+	}
+
+	configs := make(map[string]authn.AuthConfig, len(auths))
+	for registry, auth := range auths {
+		configs[normalizeRegistry(registry)] = authn.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			Auth:          auth.Auth,
+			IdentityToken: auth.IdentityToken,
+			RegistryToken: auth.RegistryToken,
+		}
+	}
+
+	return configs, nil
+}
+
+// normalizeRegistry returns the host of the registry of a docker config entry, the Docker Hub aliases are
+// all the default registry, e.g. "index.docker.io" for "https://index.docker.io/v1/"
+func normalizeRegistry(registry string) string {
+	registry = registryHost(registry)
+	switch registry {
+	case "docker.io", "registry-1.docker.io", "index.docker.io":
+		return name.DefaultRegistry
+	}
+
+	return registry
+}
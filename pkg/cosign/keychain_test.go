@@ -0,0 +1,81 @@
+package cosign
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ParseDockerConfig(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"},"registry.local:5000":{"username":"user","password":"pass"}}}`),
+		},
+	}
+
+	auths, err := parseDockerConfig(secret)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, auths, map[string]authn.AuthConfig{
+		name.DefaultRegistry:  {Auth: "dXNlcjpwYXNz"},
+		"registry.local:5000": {Username: "user", Password: "pass"},
+	})
+
+	secret = &corev1.Secret{
+		Data: map[string][]byte{
+			corev1.DockerConfigKey: []byte(`{"ghcr.io":{"username":"user","password":"token"}}`),
+		},
+	}
+
+	auths, err = parseDockerConfig(secret)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, auths, map[string]authn.AuthConfig{"ghcr.io": {Username: "user", Password: "token"}})
+
+	_, err = parseDockerConfig(&corev1.Secret{})
+	assert.ErrorContains(t, err, "no .dockerconfigjson or .dockercfg key")
+}
+
+func Test_RegistryKeychain(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kyverno", Name: "mirror"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.local:5000":{"username":"user","password":"pass"}}}`),
+		},
+	})
+
+	kc := newRegistryKeychain(authn.NewMultiKeychain(), client, "kyverno")
+	mirror, err := name.NewRepository("registry.local:5000/acme/nginx")
+	assert.NilError(t, err)
+
+	// the registry is anonymous until the secret of a rule is used
+	auth, err := kc.Resolve(mirror)
+	assert.NilError(t, err)
+	assert.Equal(t, auth, authn.Anonymous)
+
+	kc.useSecrets([]string{"mirror"})
+	auth, err = kc.Resolve(mirror)
+	assert.NilError(t, err)
+	config, err := auth.Authorization()
+	assert.NilError(t, err)
+	assert.Equal(t, config.Username, "user")
+
+	// the other registries fall back to the base keychain
+	ghcr, err := name.NewRepository("ghcr.io/acme/nginx")
+	assert.NilError(t, err)
+	auth, err = kc.Resolve(ghcr)
+	assert.NilError(t, err)
+	assert.Equal(t, auth, authn.Anonymous)
+
+	kc.useSecrets([]string{"missing"})
+	_, err = kc.Resolve(ghcr)
+	assert.ErrorContains(t, err, "failed to get image pull secret kyverno/missing")
+
+	// the keychain is disabled until Initialize is called
+	var disabled *registryKeychain
+	disabled.useSecrets([]string{"mirror"})
+}
@@ -0,0 +1,184 @@
+package cosign
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// RegistryOptions configure the access to the container registries, e.g. to the mirrors of an air-gapped cluster
+type RegistryOptions struct {
+	// CABundle is the path of the PEM encoded certificates trusted in addition to the system roots
+	CABundle string
+
+	// Proxy is the URL of the HTTP(S) proxy of the registries, the proxy of the environment is used if empty
+	Proxy string
+
+	// NoProxy are the hosts accessed without the proxy, e.g. "registry.local" or ".corp" for the subdomains
+	NoProxy []string
+
+	// InsecureRegistries are the registries accessed over HTTP or with unverified certificates, e.g. "registry.local:5000"
+	InsecureRegistries []string
+}
+
+var (
+	// registryTransport is the transport of the registry API calls
+	registryTransport http.RoundTripper = http.DefaultTransport
+
+	// insecureRegistries are the hosts of the insecure registries
+	insecureRegistries = map[string]bool{}
+)
+
+// ConfigureRegistry configures the transport of the registry API calls. The transport also becomes the default HTTP
+// transport, as the signatures and the Rekor entries are fetched by cosign with the default transport.
+func ConfigureRegistry(opts RegistryOptions) error {
+	if opts.CABundle == "" && opts.Proxy == "" && len(opts.InsecureRegistries) == 0 {
+		return nil
+	}
+
+	transport, err := newRegistryTransport(opts)
+	if err != nil {
+		return err
+	}
+
+	insecureRegistries = transport.insecureHosts
+	registryTransport = transport
+	http.DefaultTransport = transport
+	return nil
+}
+
+// registryRoundTripper sends the requests of the insecure registries without verifying their certificates
+type registryRoundTripper struct {
+	secure        http.RoundTripper
+	insecure      http.RoundTripper
+	insecureHosts map[string]bool
+}
+
+func (t *registryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.insecureHosts[req.URL.Host] {
+		return t.insecure.RoundTrip(req)
+	}
+
+	return t.secure.RoundTrip(req)
+}
+
+func newRegistryTransport(opts RegistryOptions) (*registryRoundTripper, error) {
+	secure := http.DefaultTransport.(*http.Transport).Clone()
+	if secure.TLSClientConfig == nil {
+		secure.TLSClientConfig = &tls.Config{}
+	}
+
+	if opts.CABundle != "" {
+		bundle, err := ioutil.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the registry CA bundle")
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		if !roots.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("failed to load the registry CA bundle %s: no PEM encoded certificate", opts.CABundle)
+		}
+
+		secure.TLSClientConfig.RootCAs = roots
+	}
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the registry proxy")
+		}
+
+		noProxy := opts.NoProxy
+		secure.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+
+			return proxyURL, nil
+		}
+	}
+
+	insecure := secure.Clone()
+	insecure.TLSClientConfig.InsecureSkipVerify = true
+
+	hosts := map[string]bool{}
+	for _, registry := range opts.InsecureRegistries {
+		if registry = registryHost(registry); registry != "" {
+			hosts[registry] = true
+		}
+	}
+
+	return &registryRoundTripper{secure: secure, insecure: insecure, insecureHosts: hosts}, nil
+}
+
+// bypassProxy checks if the host is accessed without the proxy. The entries match the host, its subdomains
+// if prefixed with a dot, or all the hosts for "*".
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*", entry == host:
+			return true
+		case strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry):
+			return true
+		case !strings.HasPrefix(entry, ".") && strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+
+	return false
+}
+
+// registryHost returns the host of a registry, e.g. "registry.local:5000" for "https://registry.local:5000/v2/"
+func registryHost(registry string) string {
+	registry = strings.TrimSpace(registry)
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+
+	return strings.ToLower(registry)
+}
+
+// parseReference parses the image, the images of the insecure registries may be accessed over HTTP
+func parseReference(imageRef string) (name.Reference, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if insecureRegistries[ref.Context().RegistryStr()] {
+		return name.ParseReference(imageRef, name.Insecure)
+	}
+
+	return ref, nil
+}
+
+// remoteOptions are the options of the registry API calls
+func remoteOptions() []remote.Option {
+	return []remote.Option{
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithTransport(registryTransport),
+	}
+}
@@ -0,0 +1,75 @@
+package cosign
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/assert"
+)
+
+func Test_RegistryTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	host := server.Listener.Addr().String()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v2/", nil)
+	assert.NilError(t, err)
+
+	// the self-signed certificate of the registry is not trusted
+	transport, err := newRegistryTransport(RegistryOptions{})
+	assert.NilError(t, err)
+	_, err = transport.RoundTrip(req)
+	assert.ErrorContains(t, err, "certificate")
+
+	// the certificates of the insecure registries are not verified
+	transport, err = newRegistryTransport(RegistryOptions{InsecureRegistries: []string{"https://" + host + "/"}})
+	assert.NilError(t, err)
+	resp, err := transport.RoundTrip(req)
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	resp.Body.Close()
+
+	_, err = newRegistryTransport(RegistryOptions{CABundle: "missing.pem"})
+	assert.ErrorContains(t, err, "failed to read the registry CA bundle")
+}
+
+func Test_BypassProxy(t *testing.T) {
+	testcases := []struct {
+		host    string
+		noProxy []string
+		bypass  bool
+	}{
+		{host: "registry.local", noProxy: nil, bypass: false},
+		{host: "registry.local", noProxy: []string{"registry.local"}, bypass: true},
+		{host: "Registry.Local", noProxy: []string{" registry.local:5000 "}, bypass: true},
+		{host: "mirror.registry.local", noProxy: []string{"registry.local"}, bypass: true},
+		{host: "mirror.corp", noProxy: []string{".corp"}, bypass: true},
+		{host: "corp", noProxy: []string{".corp"}, bypass: false},
+		{host: "ghcr.io", noProxy: []string{"registry.local", ".corp"}, bypass: false},
+		{host: "ghcr.io", noProxy: []string{"*"}, bypass: true},
+		{host: "notcorp", noProxy: []string{"corp"}, bypass: false},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, bypassProxy(tc.host, tc.noProxy), tc.bypass, "host %s, no proxy %v", tc.host, tc.noProxy)
+	}
+}
+
+func Test_ParseReference(t *testing.T) {
+	defer func(registries map[string]bool) { insecureRegistries = registries }(insecureRegistries)
+	insecureRegistries = map[string]bool{"registry.local:5000": true}
+
+	ref, err := parseReference("registry.local:5000/acme/nginx:1.21")
+	assert.NilError(t, err)
+	assert.Equal(t, ref.Context().Registry.Scheme(), "http")
+
+	ref, err = parseReference("ghcr.io/acme/nginx:1.21")
+	assert.NilError(t, err)
+	assert.Equal(t, ref.Context().Registry.Scheme(), "https")
+
+	ref, err = parseReference("nginx")
+	assert.NilError(t, err)
+	assert.Equal(t, ref.Context().RegistryStr(), name.DefaultRegistry)
+}
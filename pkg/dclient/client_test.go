@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/kyverno/kyverno/pkg/config"
@@ -126,3 +127,47 @@ func TestCSRInterface(t *testing.T) {
 		t.Errorf("Testing CSR interface not working: %s", err)
 	}
 }
+
+// countingDiscovery resolves the kinds of the fake discovery client, and counts the resolutions
+type countingDiscovery struct {
+	*fakeDiscoveryClient
+	calls int
+}
+
+func (c *countingDiscovery) FindResource(apiVersion string, kind string) (*meta.APIResource, schema.GroupVersionResource, error) {
+	c.calls++
+	gvr, _ := c.GetGVRFromKind(kind)
+	if gvr.Empty() {
+		return nil, gvr, fmt.Errorf("kind %s not found", kind)
+	}
+	return &meta.APIResource{Kind: kind}, gvr, nil
+}
+
+func TestRequestScoped(t *testing.T) {
+	f := newFixture(t)
+	discovery := &countingDiscovery{fakeDiscoveryClient: NewFakeDiscoveryClient(nil)}
+	f.client.SetDiscovery(discovery)
+
+	scoped := f.client.RequestScoped()
+	for i := 0; i < 3; i++ {
+		gvr, err := scoped.DiscoveryClient.GetGVRFromKind("Deployment")
+		if err != nil || gvr.Resource != "deployments" {
+			t.Errorf("unexpected resolution of Deployment: %v, %v", gvr, err)
+		}
+		if _, err := scoped.DiscoveryClient.GetGVRFromKind("Unknown"); err == nil {
+			t.Errorf("expected the resolution of Unknown to fail")
+		}
+	}
+	if discovery.calls != 2 {
+		t.Errorf("expected 2 resolutions, got %d", discovery.calls)
+	}
+
+	// the resolutions are not shared with the client and the other requests
+	f.client.RequestScoped().DiscoveryClient.GetGVRFromKind("Deployment")
+	if discovery.calls != 3 {
+		t.Errorf("expected 3 resolutions, got %d", discovery.calls)
+	}
+	if f.client.DiscoveryClient != discovery {
+		t.Errorf("the discovery client of the client is changed")
+	}
+}
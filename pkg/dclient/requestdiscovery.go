@@ -0,0 +1,79 @@
+package client
+
+import (
+	"sync"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RequestScoped returns a copy of the client that resolves each kind once. The resolutions,
+// including the failed ones, are dropped with the copy, e.g. at the end of an admission request.
+func (c *Client) RequestScoped() *Client {
+	scoped := *c
+	scoped.DiscoveryClient = newRequestDiscovery(c.DiscoveryClient)
+	return &scoped
+}
+
+type resourceKey struct {
+	apiVersion string
+	kind       string
+}
+
+type resolvedResource struct {
+	resource *meta.APIResource
+	gvr      schema.GroupVersionResource
+	err      error
+}
+
+// requestDiscovery memoizes the kind resolutions of the discovery client, a kind that is not found
+// invalidates the discovery cache and is retried by the discovery client only once per request
+type requestDiscovery struct {
+	IDiscovery
+
+	mu        sync.Mutex
+	resources map[resourceKey]resolvedResource
+}
+
+func newRequestDiscovery(d IDiscovery) *requestDiscovery {
+	if scoped, ok := d.(*requestDiscovery); ok {
+		d = scoped.IDiscovery
+	}
+
+	return &requestDiscovery{
+		IDiscovery: d,
+		resources:  make(map[resourceKey]resolvedResource),
+	}
+}
+
+// FindResource resolves the kind with the discovery client on the first call
+func (d *requestDiscovery) FindResource(apiVersion string, kind string) (*meta.APIResource, schema.GroupVersionResource, error) {
+	key := resourceKey{apiVersion: apiVersion, kind: kind}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resolved, ok := d.resources[key]
+	if !ok {
+		resolved.resource, resolved.gvr, resolved.err = d.IDiscovery.FindResource(apiVersion, kind)
+		d.resources[key] = resolved
+	}
+
+	return resolved.resource, resolved.gvr, resolved.err
+}
+
+// GetGVRFromKind get the Group Version Resource from kind
+func (d *requestDiscovery) GetGVRFromKind(kind string) (schema.GroupVersionResource, error) {
+	if kind == "" {
+		return schema.GroupVersionResource{}, nil
+	}
+
+	_, gvr, err := d.FindResource("", kind)
+	return gvr, err
+}
+
+// GetGVRFromAPIVersionKind get the Group Version Resource from APIVersion and kind
+func (d *requestDiscovery) GetGVRFromAPIVersionKind(apiVersion string, kind string) schema.GroupVersionResource {
+	_, gvr, _ := d.FindResource(apiVersion, kind)
+	return gvr
+}
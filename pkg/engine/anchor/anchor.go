@@ -28,6 +28,8 @@ func CreateElementHandler(element string, pattern interface{}, path string) Vali
 		return NewEqualityHandler(element, pattern, path)
 	case commonAnchors.IsNegationAnchor(element):
 		return NewNegationHandler(element, pattern, path)
+	case commonAnchors.IsGlobalAnchor(element):
+		return NewGlobalAnchorHandler(element, pattern, path)
 	default:
 		return NewDefaultHandler(element, pattern, path)
 	}
@@ -159,6 +161,28 @@ func (ch ConditionAnchorHandler) Handle(handler resourceElementHandler, resource
 	return "", nil
 }
 
+//NewGlobalAnchorHandler returns an instance of global anchor handler
+func NewGlobalAnchorHandler(anchor string, pattern interface{}, path string) ValidationHandler {
+	return GlobalAnchorHandler{
+		anchor:  anchor,
+		pattern: pattern,
+		path:    path,
+	}
+}
+
+//GlobalAnchorHandler provides handler for global anchor
+type GlobalAnchorHandler struct {
+	anchor  string
+	pattern interface{}
+	path    string
+}
+
+//Handle processes the global anchor handler. The global anchors are evaluated against the whole
+//resource before the pattern, so the fields of the anchors are not checked again.
+func (gh GlobalAnchorHandler) Handle(handler resourceElementHandler, resourceMap map[string]interface{}, originPattern interface{}, ac *common.AnchorKey) (string, error) {
+	return "", nil
+}
+
 //NewExistenceHandler returns existence handler
 func NewExistenceHandler(anchor string, pattern interface{}, path string) ValidationHandler {
 	return ExistenceHandler{
@@ -230,7 +254,7 @@ func GetAnchorsResourcesFromMap(patternMap map[string]interface{}) (map[string]i
 	anchors := map[string]interface{}{}
 	resources := map[string]interface{}{}
 	for key, value := range patternMap {
-		if commonAnchors.IsConditionAnchor(key) || commonAnchors.IsExistenceAnchor(key) || commonAnchors.IsEqualityAnchor(key) || commonAnchors.IsNegationAnchor(key) {
+		if commonAnchors.IsConditionAnchor(key) || commonAnchors.IsExistenceAnchor(key) || commonAnchors.IsEqualityAnchor(key) || commonAnchors.IsNegationAnchor(key) || commonAnchors.IsGlobalAnchor(key) {
 			anchors[key] = value
 			continue
 		}
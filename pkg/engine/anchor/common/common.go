@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"path"
 	"strings"
 )
@@ -28,6 +29,19 @@ func IsNegationAnchor(str string) bool {
 	return (str[:len(left)] == left && str[len(str)-len(right):] == right)
 }
 
+// IsGlobalAnchor checks for global anchor, e.g. "<(hostPath)". The pattern is skipped if the
+// resource has no field of the anchor whose value matches the pattern of the anchor.
+func IsGlobalAnchor(key string) bool {
+	const left = "<("
+	const right = ")"
+
+	if len(key) < len(left)+len(right) {
+		return false
+	}
+
+	return left == key[:len(left)] && right == key[len(key)-len(right):]
+}
+
 // IsAddingAnchor checks for addition anchor
 func IsAddingAnchor(key string) bool {
 	const left = "+("
@@ -40,6 +54,47 @@ func IsAddingAnchor(key string) bool {
 	return left == key[:len(left)] && right == key[len(key)-len(right):]
 }
 
+// IsSelectionAnchor checks for selection anchor, e.g. "+(name=istio-proxy)".
+// It selects the list elements whose fields have the values of the selector.
+func IsSelectionAnchor(key string) bool {
+	return IsAddingAnchor(key) && isSelector(key[2:len(key)-1])
+}
+
+// IsNegatedSelectionAnchor checks for negated selection anchor, e.g. "X(name=istio-proxy)".
+// No list element may have the values of the selector.
+func IsNegatedSelectionAnchor(key string) bool {
+	return IsNegationAnchor(key) && isSelector(key[2:len(key)-1])
+}
+
+func isSelector(str string) bool {
+	if !strings.Contains(str, "=") {
+		return false
+	}
+
+	_, err := ParseSelector(str)
+	return err == nil
+}
+
+// ParseSelector parses the comma separated key=value pairs of a selection anchor, e.g. "name=istio-proxy,image=proxyv2"
+func ParseSelector(selector string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid selector %s, expected key=value pairs", selector)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if key == "" || strings.ContainsAny(key, "()") {
+			return nil, fmt.Errorf("invalid selector %s, expected key=value pairs", selector)
+		}
+
+		fields[key] = strings.TrimSpace(kv[1])
+	}
+
+	return fields, nil
+}
+
 // IsEqualityAnchor checks for equality anchor
 func IsEqualityAnchor(str string) bool {
 	left := "=("
@@ -70,7 +125,7 @@ func RemoveAnchor(key string) (string, string) {
 		return key[1 : len(key)-1], key[0:1]
 	}
 
-	if IsExistenceAnchor(key) || IsAddingAnchor(key) || IsEqualityAnchor(key) || IsNegationAnchor(key) {
+	if IsExistenceAnchor(key) || IsAddingAnchor(key) || IsEqualityAnchor(key) || IsNegationAnchor(key) || IsGlobalAnchor(key) {
 		return key[2 : len(key)-1], key[0:2]
 	}
 
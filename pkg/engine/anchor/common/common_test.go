@@ -66,3 +66,32 @@ func TestRemoveAnchorsFromPath_WorksWithRelativePath(t *testing.T) {
 	newPath := RemoveAnchorsFromPath("path/(to)/X(anchors)")
 	assert.Equal(t, newPath, "path/to/anchors")
 }
+
+func TestIsSelectionAnchor(t *testing.T) {
+	assert.Assert(t, IsSelectionAnchor("+(name=istio-proxy)"))
+	assert.Assert(t, IsSelectionAnchor("+(name=istio-proxy, image=proxyv2)"))
+	assert.Assert(t, !IsSelectionAnchor("+(name)"))
+	assert.Assert(t, !IsSelectionAnchor("+(=istio-proxy)"))
+	assert.Assert(t, !IsSelectionAnchor("(name=istio-proxy)"))
+	assert.Assert(t, IsNegatedSelectionAnchor("X(name=debug)"))
+	assert.Assert(t, !IsNegatedSelectionAnchor("X(name)"))
+}
+
+func TestParseSelector(t *testing.T) {
+	fields, err := ParseSelector("name=istio-proxy, image = proxyv2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fields, map[string]string{"name": "istio-proxy", "image": "proxyv2"})
+
+	_, err = ParseSelector("name=istio-proxy,image")
+	assert.Assert(t, err != nil)
+}
+
+func TestIsGlobalAnchor(t *testing.T) {
+	assert.Assert(t, IsGlobalAnchor("<(hostPath)"))
+	assert.Assert(t, !IsGlobalAnchor("<hostPath"))
+	assert.Assert(t, !IsGlobalAnchor("(hostPath)"))
+
+	key, prefix := RemoveAnchor("<(hostPath)")
+	assert.Equal(t, key, "hostPath")
+	assert.Equal(t, prefix, "<(")
+}
@@ -0,0 +1,121 @@
+package anchor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
+	"github.com/kyverno/kyverno/pkg/engine/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// IsSelectionMap checks if the pattern selects list elements, i.e. all its keys are selection
+// anchors, e.g. "+(name=istio-proxy)", or negated selection anchors, e.g. "X(name=istio-proxy)"
+func IsSelectionMap(patternMap map[string]interface{}) bool {
+	if len(patternMap) == 0 {
+		return false
+	}
+
+	for key := range patternMap {
+		if !commonAnchors.IsSelectionAnchor(key) && !commonAnchors.IsNegatedSelectionAnchor(key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateSelection validates the list elements selected by the selection anchors of the pattern.
+// The elements selected by a selection anchor must exist and satisfy the pattern of the anchor,
+// the elements selected by a negated selection anchor must not exist.
+func ValidateSelection(handler resourceElementHandler, resourceList []interface{}, patternMap map[string]interface{}, originPattern interface{}, path string, ac *common.AnchorKey) (string, error) {
+	keys := make([]string, 0, len(patternMap))
+	for key := range patternMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		selector, prefix := commonAnchors.RemoveAnchor(key)
+		fields, err := commonAnchors.ParseSelector(selector)
+		if err != nil {
+			return path, err
+		}
+
+		selected := selectElements(resourceList, fields)
+		if commonAnchors.IsNegatedSelectionAnchor(key) {
+			if len(selected) > 0 {
+				currentPath := path + strconv.Itoa(selected[0]) + "/"
+				return currentPath, fmt.Errorf("Validation rule failed at %s, element %s is disallowed", currentPath, selector)
+			}
+			continue
+		}
+
+		if len(selected) == 0 {
+			return path, fmt.Errorf("Validation rule failed at %s, element %s is not present", path, selector)
+		}
+
+		for _, i := range selected {
+			currentPath := path + strconv.Itoa(i) + "/"
+			returnPath, err := handler(log.Log, resourceList[i], patternMap[key], originPattern, currentPath, ac)
+			if err != nil {
+				if common.IsConditionalAnchorError(err.Error()) {
+					continue
+				}
+				return returnPath, fmt.Errorf("element %s%s): %v", prefix, selector, err)
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// SelectedElements returns the map elements of the list selected by the selection anchor, e.g. "+(name=istio-proxy)"
+func SelectedElements(resourceList []interface{}, key string) []interface{} {
+	selector, _ := commonAnchors.RemoveAnchor(key)
+	fields, err := commonAnchors.ParseSelector(selector)
+	if err != nil {
+		return nil
+	}
+
+	var elements []interface{}
+	for _, i := range selectElements(resourceList, fields) {
+		elements = append(elements, resourceList[i])
+	}
+
+	return elements
+}
+
+// selectElements returns the indexes of the map elements of the list with the field values
+func selectElements(resourceList []interface{}, fields map[string]string) []int {
+	var selected []int
+	for i, element := range resourceList {
+		elementMap, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if hasFields(elementMap, fields) {
+			selected = append(selected, i)
+		}
+	}
+
+	return selected
+}
+
+func hasFields(elementMap map[string]interface{}, fields map[string]string) bool {
+	for key, value := range fields {
+		v, ok := elementMap[key]
+		if !ok || v == nil {
+			return false
+		}
+
+		if strings.TrimSpace(fmt.Sprint(v)) != value {
+			return false
+		}
+	}
+
+	return true
+}
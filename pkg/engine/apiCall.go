@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+const (
+	// defaultAPICallTimeout is the timeout of the requests to the services without a timeout
+	defaultAPICallTimeout = 10 * time.Second
+
+	// maxAPICallResponseSize is the maximum size of the response of a service
+	maxAPICallResponseSize = 2 << 20
+)
+
+// apiCalls caches the responses of the API calls with a cache TTL, for all the policies
+var apiCalls = newAPICallCache()
+
+// apiCallCache stores the responses of the API calls by request until their TTL expires
+type apiCallCache struct {
+	mu        sync.Mutex
+	responses map[string]cachedAPICall
+	now       func() time.Time
+}
+
+type cachedAPICall struct {
+	data    []byte
+	expires time.Time
+}
+
+func newAPICallCache() *apiCallCache {
+	return &apiCallCache{
+		responses: make(map[string]cachedAPICall),
+		now:       time.Now,
+	}
+}
+
+func (c *apiCallCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.responses[key]
+	if !ok {
+		return nil, false
+	}
+
+	if c.now().After(cached.expires) {
+		delete(c.responses, key)
+		return nil, false
+	}
+
+	return cached.data, true
+}
+
+func (c *apiCallCache) set(key string, data []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, cached := range c.responses {
+		if now.After(cached.expires) {
+			delete(c.responses, k)
+		}
+	}
+
+	c.responses[key] = cachedAPICall{data: data, expires: now.Add(ttl)}
+}
+
+// cachedFetch returns the cached response of the request if the API call has a cache TTL, and
+// caches the fetched response otherwise. The failed requests are not cached.
+func cachedFetch(apiCall *kyverno.APICall, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if apiCall.CacheTTL == nil || apiCall.CacheTTL.Duration <= 0 {
+		return fetch()
+	}
+
+	if data, ok := apiCalls.get(key); ok {
+		return data, nil
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	apiCalls.set(key, data, apiCall.CacheTTL.Duration)
+	return data, nil
+}
+
+// callService sends the GET request of the API call to the URL of its service and returns the JSON response
+func callService(apiCall *kyverno.APICall, url string) ([]byte, error) {
+	client, err := serviceClient(apiCall)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to %s: %v", url, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxAPICallResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of %s: %v", url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to call %s: HTTP %d", url, resp.StatusCode)
+	}
+
+	if len(body) > maxAPICallResponseSize {
+		return nil, fmt.Errorf("response of %s exceeds %d bytes", url, maxAPICallResponseSize)
+	}
+
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("response of %s is not JSON", url)
+	}
+
+	return body, nil
+}
+
+// serviceClient returns the HTTP client of the service of the API call, with its timeout and CA bundle
+func serviceClient(apiCall *kyverno.APICall) (*http.Client, error) {
+	timeout := defaultAPICallTimeout
+	if apiCall.Timeout != nil && apiCall.Timeout.Duration > 0 {
+		timeout = apiCall.Timeout.Duration
+	}
+
+	client := &http.Client{Timeout: timeout}
+	if apiCall.Service.CABundle == "" {
+		return client, nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(apiCall.Service.CABundle)) {
+		return nil, fmt.Errorf("failed to load the CA bundle: no PEM encoded certificate")
+	}
+
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}
+
+	return client, nil
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newInventoryServer(calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		switch r.URL.Path {
+		case "/images/web":
+			fmt.Fprint(w, `{"owner":"team-a","approved":true}`)
+		case "/images/text":
+			fmt.Fprint(w, `approved`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newAPICallTestContext(t *testing.T) *PolicyContext {
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"prod"}}`)))
+	return &PolicyContext{JSONContext: ctx}
+}
+
+func Test_LoadAPIData_Service(t *testing.T) {
+	var calls int
+	server := newInventoryServer(&calls)
+	defer server.Close()
+
+	entry := kyverno.ContextEntry{
+		Name: "inventory",
+		APICall: &kyverno.APICall{
+			Service: &kyverno.ServiceCall{URL: server.URL + "/images/{{request.object.metadata.name}}"},
+		},
+	}
+
+	// the response is bound to the context entry
+	ctx := newAPICallTestContext(t)
+	assert.NilError(t, loadAPIData(log.Log, entry, ctx))
+	owner, err := ctx.JSONContext.Query("inventory.owner")
+	assert.NilError(t, err)
+	assert.Equal(t, owner, "team-a")
+
+	// the JMESPath transforms the response
+	entry.APICall.JMESPath = "approved"
+	ctx = newAPICallTestContext(t)
+	assert.NilError(t, loadAPIData(log.Log, entry, ctx))
+	approved, err := ctx.JSONContext.Query("inventory")
+	assert.NilError(t, err)
+	assert.Equal(t, approved, true)
+
+	// the failed requests fail the rule
+	entry.APICall.Service.URL = server.URL + "/images/unknown"
+	assert.ErrorContains(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)), "HTTP 404")
+
+	entry.APICall.Service.URL = server.URL + "/images/text"
+	assert.ErrorContains(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)), "is not JSON")
+
+	// unless the failure policy ignores them
+	entry.APICall.FailurePolicy = kyverno.Ignore
+	ctx = newAPICallTestContext(t)
+	assert.NilError(t, loadAPIData(log.Log, entry, ctx))
+	inventory, err := ctx.JSONContext.Query("inventory")
+	assert.NilError(t, err)
+	assert.Assert(t, inventory == nil)
+	assert.Assert(t, ctx.contextResolutions["inventory"] != "")
+}
+
+func Test_LoadAPIData_Cache(t *testing.T) {
+	var calls int
+	server := newInventoryServer(&calls)
+	defer server.Close()
+
+	entry := kyverno.ContextEntry{
+		Name: "inventory",
+		APICall: &kyverno.APICall{
+			Service:  &kyverno.ServiceCall{URL: server.URL + "/images/{{request.object.metadata.name}}"},
+			JMESPath: "owner",
+		},
+	}
+
+	// the responses are not cached without a cache TTL
+	assert.NilError(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)))
+	assert.NilError(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)))
+	assert.Equal(t, calls, 2)
+
+	now := time.Now()
+	apiCalls.now = func() time.Time { return now }
+	defer func() { apiCalls.now = time.Now }()
+
+	entry.APICall.CacheTTL = &metav1.Duration{Duration: time.Minute}
+	assert.NilError(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)))
+	assert.NilError(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)))
+	assert.Equal(t, calls, 3)
+
+	// the responses expire after the TTL
+	now = now.Add(2 * time.Minute)
+	assert.NilError(t, loadAPIData(log.Log, entry, newAPICallTestContext(t)))
+	assert.Equal(t, calls, 4)
+}
+
+func Test_CallService_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	apiCall := &kyverno.APICall{
+		Service: &kyverno.ServiceCall{URL: server.URL},
+		Timeout: &metav1.Duration{Duration: 50 * time.Millisecond},
+	}
+
+	_, err := callService(apiCall, server.URL)
+	assert.ErrorContains(t, err, "failed to call")
+}
@@ -2,7 +2,10 @@ package engine
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type APIPath struct {
@@ -12,11 +15,48 @@ type APIPath struct {
 	ResourceType string
 	Name         string
 	Namespace    string
+
+	// LabelSelector filters the listed resources, e.g. "/api/v1/services?labelSelector=app%3Dweb"
+	LabelSelector string
 }
 
-// NewAPIPath validates and parses an API path.
+// NewAPIPath validates and parses an API path, with an optional labelSelector query parameter.
 // See: https://kubernetes.io/docs/reference/using-api/api-concepts/
 func NewAPIPath(path string) (*APIPath, error) {
+	var query string
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], strings.TrimSpace(path[i+1:])
+	}
+
+	p, err := parseAPIPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if query == "" {
+		return p, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+
+	for key := range values {
+		if key != "labelSelector" {
+			return nil, fmt.Errorf("unsupported query parameter %s, only labelSelector is supported", key)
+		}
+	}
+
+	if p.Name != "" {
+		return nil, fmt.Errorf("the labelSelector query parameter is only supported by the lists of resources")
+	}
+
+	p.LabelSelector = values.Get("labelSelector")
+	return p, nil
+}
+
+func parseAPIPath(path string) (*APIPath, error) {
 	trimmedPath := strings.Trim(path, "/ ")
 	paths := strings.Split(trimmedPath, "/")
 
@@ -157,5 +197,19 @@ func (a *APIPath) String() string {
 
 	result := "/" + strings.Join(paths, "/")
 	result = strings.ReplaceAll(result, "//", "/")
+	if a.LabelSelector != "" {
+		result += "?" + url.Values{"labelSelector": []string{a.LabelSelector}}.Encode()
+	}
+
 	return result
 }
+
+// GroupVersionResource returns the group, version and resource of the path
+func (a *APIPath) GroupVersionResource() schema.GroupVersionResource {
+	if a.Root == "api" {
+		return schema.GroupVersionResource{Version: a.Group, Resource: a.ResourceType}
+	}
+
+	gv, _ := schema.ParseGroupVersion(a.Version)
+	return gv.WithResource(a.ResourceType)
+}
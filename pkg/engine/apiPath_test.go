@@ -49,3 +49,31 @@ func Test_GroupVersions(t *testing.T) {
 	f("/api/v1/namespace/{{ request.namespace }}", "v1")
 	f("/apis/extensions/v1beta1/namespaces/example/ingresses", "extensions/v1beta1")
 }
+
+func Test_LabelSelector(t *testing.T) {
+	p, err := NewAPIPath("/apis/networking.k8s.io/v1/namespaces/prod/ingresses?labelSelector=app=web,tier in (frontend)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.LabelSelector != "app=web,tier in (frontend)" {
+		t.Errorf("expected app=web,tier in (frontend) got %s", p.LabelSelector)
+	}
+
+	if gvr := p.GroupVersionResource(); gvr.Group != "networking.k8s.io" || gvr.Version != "v1" || gvr.Resource != "ingresses" {
+		t.Errorf("unexpected group version resource %v", gvr)
+	}
+
+	expected := "/apis/networking.k8s.io/v1/namespaces/prod/ingresses?labelSelector=app%3Dweb%2Ctier+in+%28frontend%29"
+	if p.String() != expected {
+		t.Errorf("expected %s got %s", expected, p.String())
+	}
+
+	if _, err := NewAPIPath("/api/v1/namespaces/prod/services/web?labelSelector=app=web"); err == nil {
+		t.Error("expected an error for the label selector of a resource")
+	}
+
+	if _, err := NewAPIPath("/api/v1/services?fieldSelector=metadata.name=web"); err == nil {
+		t.Error("expected an error for an unsupported query parameter")
+	}
+}
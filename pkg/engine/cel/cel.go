@@ -0,0 +1,151 @@
+package cel
+
+import (
+	"fmt"
+	"sync"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+const (
+	// ObjectVar is the variable of the new object, null for the DELETE requests
+	ObjectVar = "object"
+
+	// OldObjectVar is the variable of the old object, null for the CREATE requests
+	OldObjectVar = "oldObject"
+
+	// RequestVar is the variable of the admission request
+	RequestVar = "request"
+)
+
+var (
+	envOnce sync.Once
+	env     *celgo.Env
+	envErr  error
+
+	// programs caches the compiled expressions
+	programs sync.Map
+)
+
+// environment returns the CEL environment declaring the variables of the expressions
+func environment() (*celgo.Env, error) {
+	envOnce.Do(func() {
+		var registry ref.TypeRegistry
+		if registry, envErr = types.NewRegistry(); envErr != nil {
+			return
+		}
+
+		env, envErr = celgo.NewEnv(
+			celgo.CustomTypeAdapter(nullableAdapter{registry}),
+			celgo.Declarations(
+				decls.NewVar(ObjectVar, decls.Dyn),
+				decls.NewVar(OldObjectVar, decls.Dyn),
+				decls.NewVar(RequestVar, decls.Dyn),
+			),
+		)
+	})
+
+	return env, envErr
+}
+
+// nullableAdapter converts the objects and their fields to values which can be compared with null,
+// e.g. "oldObject == null". CEL fails the comparisons of the values of different types.
+type nullableAdapter struct {
+	ref.TypeAdapter
+}
+
+func (a nullableAdapter) NativeToValue(value interface{}) ref.Val {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return nullableMap{types.NewStringInterfaceMap(a, v)}
+	case []interface{}:
+		return nullableList{types.NewDynamicList(a, v)}
+	}
+
+	return a.TypeAdapter.NativeToValue(value)
+}
+
+// nullableMap is an object, or a map field, which is not equal to null
+type nullableMap struct {
+	traits.Mapper
+}
+
+func (m nullableMap) Equal(other ref.Val) ref.Val {
+	if other.Type() == types.NullType {
+		return types.False
+	}
+
+	return m.Mapper.Equal(other)
+}
+
+// nullableList is a list field which is not equal to null
+type nullableList struct {
+	traits.Lister
+}
+
+func (l nullableList) Equal(other ref.Val) ref.Val {
+	if other.Type() == types.NullType {
+		return types.False
+	}
+
+	return l.Lister.Equal(other)
+}
+
+// Compile parses and checks the expression, it must return a boolean
+func Compile(expression string) (celgo.Program, error) {
+	if program, ok := programs.Load(expression); ok {
+		return program.(celgo.Program), nil
+	}
+
+	env, err := environment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CEL environment: %v", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid CEL expression %s: %v", expression, issues.Err())
+	}
+
+	resultType := ast.ResultType()
+	if resultType.GetPrimitive() != decls.Bool.GetPrimitive() && resultType.GetDyn() == nil {
+		return nil, fmt.Errorf("CEL expression %s must return a boolean", expression)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEL expression %s: %v", expression, err)
+	}
+
+	programs.Store(expression, program)
+	return program, nil
+}
+
+// Evaluate returns the result of the expression for the objects and the admission request.
+// The null objects are passed as nil.
+func Evaluate(expression string, object, oldObject, request interface{}) (bool, error) {
+	program, err := Compile(expression)
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := program.Eval(map[string]interface{}{
+		ObjectVar:    object,
+		OldObjectVar: oldObject,
+		RequestVar:   request,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate CEL expression %s: %v", expression, err)
+	}
+
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("CEL expression %s returned %v, expect a boolean", expression, out.Value())
+	}
+
+	return result, nil
+}
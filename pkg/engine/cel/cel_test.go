@@ -0,0 +1,76 @@
+package cel
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_Compile(t *testing.T) {
+	testcases := []struct {
+		expression string
+		err        string
+	}{
+		{expression: "object.spec.replicas <= 5"},
+		{expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"},
+		{expression: "oldObject == null || object.spec.replicas >= oldObject.spec.replicas"},
+		{expression: "request.userInfo.username != 'admin'"},
+		{expression: "object.spec.replicas"},
+		{expression: "object.spec.replicas <=", err: "invalid CEL expression"},
+		{expression: "unknown.spec", err: "undeclared reference"},
+		{expression: "'a' + 'b'", err: "must return a boolean"},
+	}
+
+	for _, tc := range testcases {
+		_, err := Compile(tc.expression)
+		if tc.err == "" {
+			assert.NilError(t, err, tc.expression)
+		} else {
+			assert.ErrorContains(t, err, tc.err, tc.expression)
+		}
+	}
+}
+
+func Test_Evaluate(t *testing.T) {
+	object := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web", "labels": map[string]interface{}{"team": "a"}},
+		"spec":     map[string]interface{}{"replicas": int64(3)},
+	}
+	oldObject := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web"},
+		"spec":     map[string]interface{}{"replicas": int64(5)},
+	}
+	request := map[string]interface{}{"operation": "UPDATE", "userInfo": map[string]interface{}{"username": "dev"}}
+
+	testcases := []struct {
+		expression string
+		result     bool
+		err        string
+	}{
+		{expression: "object.spec.replicas <= 5", result: true},
+		{expression: "object.spec.replicas >= oldObject.spec.replicas", result: false},
+		{expression: "'team' in object.metadata.labels", result: true},
+		{expression: "has(oldObject.metadata.labels)", result: false},
+		{expression: "request.operation == 'UPDATE' && request.userInfo.username == 'dev'", result: true},
+		{expression: "oldObject == null", result: false},
+		{expression: "oldObject == null || object.spec.replicas >= oldObject.spec.replicas", result: false},
+		{expression: "object.metadata.labels != null && object.spec != null", result: true},
+		{expression: "oldObject.metadata.labels.team == 'a'", err: "failed to evaluate"},
+		{expression: "object.spec.replicas", err: "expect a boolean"},
+	}
+
+	for _, tc := range testcases {
+		result, err := Evaluate(tc.expression, object, oldObject, request)
+		if tc.err == "" {
+			assert.NilError(t, err, tc.expression)
+			assert.Equal(t, result, tc.result, tc.expression)
+		} else {
+			assert.ErrorContains(t, err, tc.err, tc.expression)
+		}
+	}
+
+	// the null objects
+	result, err := Evaluate("oldObject == null", object, nil, request)
+	assert.NilError(t, err)
+	assert.Assert(t, result)
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/cel"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// validateCEL fails the resource if one of the CEL expressions of the rule is false. The expressions
+// are evaluated in order, the message of the first false expression is reported.
+func validateCEL(ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	object := celObject(ctx.NewResource)
+	oldObject := celObject(ctx.OldResource)
+	request, err := ctx.JSONContext.Query("request")
+	if err != nil {
+		request = nil
+	}
+
+	for _, expression := range rule.Validation.CEL.Expressions {
+		passed, err := cel.Evaluate(expression.Expression, object, oldObject, request)
+		if err != nil {
+			ruleResp := ruleError(rule, "failed to evaluate the CEL expressions", err)
+			return &ruleResp
+		}
+
+		if passed {
+			continue
+		}
+
+		failure := expression.Message
+		if failure == "" {
+			failure = "failed expression: " + expression.Expression
+		}
+
+		message := "validation error: " + failure
+		if rule.Validation.Message != "" {
+			message = fmt.Sprintf("validation error: %s. %s", strings.TrimSuffix(rule.Validation.Message, "."), failure)
+		}
+
+		return &response.RuleResponse{
+			Name:    rule.Name,
+			Type:    utils.Validation.String(),
+			Message: message,
+			Success: false,
+		}
+	}
+
+	return &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Validation.String(),
+		Message: fmt.Sprintf("validation rule '%s' passed.", rule.Name),
+		Success: true,
+	}
+}
+
+// celObject returns the content of the object for the CEL expressions, nil if there is no object
+func celObject(resource unstructured.Unstructured) interface{} {
+	if reflect.DeepEqual(resource, unstructured.Unstructured{}) {
+		return nil
+	}
+
+	return resource.Object
+}
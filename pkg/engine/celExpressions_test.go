@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+	"k8s.io/api/admission/v1beta1"
+)
+
+func Test_Validate_CEL(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "deployment-replicas"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-replicas",
+					"match": {"resources": {"kinds": ["Deployment"]}},
+					"validate": {
+						"cel": {
+							"expressions": [
+								{"expression": "object.spec.replicas <= 5", "message": "at most 5 replicas are allowed"},
+								{"expression": "oldObject == null || object.spec.replicas >= oldObject.spec.replicas"}
+							]
+						}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	validate := func(operation v1beta1.Operation, rawResource, rawOldResource string) response.RuleResponse {
+		resource, err := utils.ConvertToUnstructured([]byte(rawResource))
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddRequest(&v1beta1.AdmissionRequest{Operation: operation}))
+		assert.NilError(t, ctx.AddResource([]byte(rawResource)))
+
+		policyContext := &PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx}
+		if rawOldResource != "" {
+			oldResource, err := utils.ConvertToUnstructured([]byte(rawOldResource))
+			assert.NilError(t, err)
+			policyContext.OldResource = *oldResource
+		}
+
+		er := Validate(policyContext)
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	deployment := func(replicas string) string {
+		return `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web", "namespace": "prod"}, "spec": {"replicas": ` + replicas + `}}`
+	}
+
+	rule := validate(v1beta1.Create, deployment("3"), "")
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the message of the first false expression is reported
+	rule = validate(v1beta1.Create, deployment("8"), "")
+	assert.Assert(t, !rule.Success)
+	assert.Assert(t, !rule.Error)
+	assert.Equal(t, rule.Message, "validation error: at most 5 replicas are allowed")
+
+	// the expressions without a message report the expression
+	rule = validate(v1beta1.Update, deployment("2"), deployment("3"))
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: failed expression: oldObject == null || object.spec.replicas >= oldObject.spec.replicas")
+
+	rule = validate(v1beta1.Update, deployment("4"), deployment("3"))
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the message of the rule prefixes the message of the expression
+	policy.Spec.Rules[0].Validation.Message = "the replicas are restricted."
+	rule = validate(v1beta1.Create, deployment("8"), "")
+	assert.Equal(t, rule.Message, "validation error: the replicas are restricted. at most 5 replicas are allowed")
+
+	// the admission request is available
+	policy.Spec.Rules[0].Validation.CEL.Expressions = []kyverno.CELExpression{{Expression: "request.operation == 'CREATE'"}}
+	rule = validate(v1beta1.Create, deployment("8"), "")
+	assert.Assert(t, rule.Success, rule.Message)
+
+	rule = validate(v1beta1.Update, deployment("8"), deployment("8"))
+	assert.Assert(t, !rule.Success)
+
+	// the evaluation errors fail the rule with an error
+	policy.Spec.Rules[0].Validation.CEL.Expressions = []kyverno.CELExpression{{Expression: "object.spec.template.spec.hostNetwork == false"}}
+	rule = validate(v1beta1.Create, deployment("3"), "")
+	assert.Assert(t, !rule.Success)
+	assert.Assert(t, rule.Error, rule.Message)
+}
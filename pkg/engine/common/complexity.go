@@ -0,0 +1,102 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrResourceTooComplex is returned when a resource exceeds the complexity limits of the
+// pattern evaluation
+var ErrResourceTooComplex = errors.New("resource too complex for rule evaluation")
+
+// ComplexityLimits bound the size of the resources walked by the validate patterns and the
+// mutate overlays. A limit set to 0 is not checked.
+type ComplexityLimits struct {
+	// MaxDepth is the maximum nesting of maps and arrays in a resource
+	MaxDepth int
+
+	// MaxElements is the maximum number of elements of a single map or array in a resource
+	MaxElements int
+
+	// MaxNodes is the maximum number of maps, arrays and values in a resource, per rule
+	MaxNodes int
+
+	// Enforce fails the rules on resources exceeding the limits, the resources are only
+	// logged if not set
+	Enforce bool
+}
+
+// DefaultComplexityLimits are generous enough for the resources of regular workloads
+var DefaultComplexityLimits = ComplexityLimits{
+	MaxDepth:    64,
+	MaxElements: 10000,
+	MaxNodes:    250000,
+	Enforce:     true,
+}
+
+var (
+	complexityLimits    = DefaultComplexityLimits
+	complexityLimitsMux sync.RWMutex
+)
+
+// SetComplexityLimits sets the complexity limits of the pattern evaluation
+func SetComplexityLimits(limits ComplexityLimits) {
+	complexityLimitsMux.Lock()
+	defer complexityLimitsMux.Unlock()
+	complexityLimits = limits
+}
+
+// GetComplexityLimits returns the complexity limits of the pattern evaluation
+func GetComplexityLimits() ComplexityLimits {
+	complexityLimitsMux.RLock()
+	defer complexityLimitsMux.RUnlock()
+	return complexityLimits
+}
+
+// CheckComplexity walks the element and returns an error wrapping ErrResourceTooComplex
+// as soon as one of the limits is exceeded. The walk stops at the first exceeded limit so
+// that the check itself is bounded by the limits.
+func CheckComplexity(element interface{}, limits ComplexityLimits) error {
+	nodes := 0
+	return checkComplexity(element, limits, 1, &nodes)
+}
+
+func checkComplexity(element interface{}, limits ComplexityLimits, depth int, nodes *int) error {
+	*nodes++
+	if limits.MaxNodes > 0 && *nodes > limits.MaxNodes {
+		return fmt.Errorf("%w: more than %d nodes", ErrResourceTooComplex, limits.MaxNodes)
+	}
+
+	switch typed := element.(type) {
+	case map[string]interface{}:
+		if err := checkContainer(len(typed), limits, depth); err != nil {
+			return err
+		}
+		for _, value := range typed {
+			if err := checkComplexity(value, limits, depth+1, nodes); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if err := checkContainer(len(typed), limits, depth); err != nil {
+			return err
+		}
+		for _, value := range typed {
+			if err := checkComplexity(value, limits, depth+1, nodes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkContainer(elements int, limits ComplexityLimits, depth int) error {
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return fmt.Errorf("%w: more than %d levels of nesting", ErrResourceTooComplex, limits.MaxDepth)
+	}
+	if limits.MaxElements > 0 && elements > limits.MaxElements {
+		return fmt.Errorf("%w: more than %d elements", ErrResourceTooComplex, limits.MaxElements)
+	}
+	return nil
+}
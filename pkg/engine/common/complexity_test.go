@@ -0,0 +1,56 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// nestedResource returns a resource with the given levels of nested maps
+func nestedResource(depth int) map[string]interface{} {
+	resource := map[string]interface{}{"name": "leaf"}
+	for i := 1; i < depth; i++ {
+		resource = map[string]interface{}{"nested": resource}
+	}
+	return resource
+}
+
+// wideResource returns a resource with an array of the given number of elements
+func wideResource(elements int) map[string]interface{} {
+	items := make([]interface{}, elements)
+	for i := range items {
+		items[i] = map[string]interface{}{"name": "item"}
+	}
+	return map[string]interface{}{"items": items}
+}
+
+func Test_CheckComplexity(t *testing.T) {
+	limits := ComplexityLimits{MaxDepth: 10, MaxElements: 100, MaxNodes: 500, Enforce: true}
+
+	testCases := []struct {
+		name     string
+		resource interface{}
+		limits   ComplexityLimits
+		tooLarge bool
+	}{
+		{name: "regular resource", resource: nestedResource(5), limits: limits},
+		{name: "depth at limit", resource: nestedResource(10), limits: limits},
+		{name: "depth over limit", resource: nestedResource(11), limits: limits, tooLarge: true},
+		{name: "elements at limit", resource: wideResource(100), limits: limits},
+		{name: "elements over limit", resource: wideResource(101), limits: ComplexityLimits{MaxElements: 100}, tooLarge: true},
+		{name: "nodes over limit", resource: wideResource(90), limits: ComplexityLimits{MaxNodes: 100}, tooLarge: true},
+		{name: "limits disabled", resource: wideResource(50000), limits: ComplexityLimits{}},
+		{name: "default limits", resource: wideResource(50000), limits: DefaultComplexityLimits, tooLarge: true},
+		{name: "default limits on deep resource", resource: nestedResource(100), limits: DefaultComplexityLimits, tooLarge: true},
+	}
+
+	for _, tc := range testCases {
+		err := CheckComplexity(tc.resource, tc.limits)
+		if tc.tooLarge {
+			assert.Assert(t, errors.Is(err, ErrResourceTooComplex), tc.name)
+		} else {
+			assert.NilError(t, err, tc.name)
+		}
+	}
+}
@@ -9,7 +9,9 @@ import (
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/userinfo"
 	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -48,8 +50,14 @@ type Context struct {
 	builtInVars       []string
 	images            *Images
 	log               logr.Logger
+
+	// queryRecorder records the queries and their results, e.g. to trace the variables resolved for a rule
+	queryRecorder QueryRecorder
 }
 
+// QueryRecorder records a query of the context with its result or error
+type QueryRecorder func(query string, result interface{}, err error)
+
 //NewContext returns a new context
 // builtInVars is the list of known variables (e.g. serviceAccountName)
 func NewContext(builtInVars ...string) *Context {
@@ -62,6 +70,19 @@ func NewContext(builtInVars ...string) *Context {
 	return &ctx
 }
 
+// SetQueryRecorder sets the recorder of the queries, a nil recorder stops recording
+func (ctx *Context) SetQueryRecorder(recorder QueryRecorder) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.queryRecorder = recorder
+}
+
+func (ctx *Context) getQueryRecorder() QueryRecorder {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	return ctx.queryRecorder
+}
+
 // InvalidVariableErr represents error for non-white-listed variables
 type InvalidVariableErr struct {
 	variable  string
@@ -131,12 +152,34 @@ func (ctx *Context) AddResource(dataRaw []byte) error {
 	return ctx.AddJSON(objRaw)
 }
 
+// impersonatedRequestInfo adds the user impersonating the requesting user at request.userInfo.impersonatedBy
+type impersonatedRequestInfo struct {
+	kyverno.RequestInfo
+	UserInfo impersonatedUserInfo `json:"userInfo"`
+}
+
+type impersonatedUserInfo struct {
+	authenticationv1.UserInfo
+	ImpersonatedBy *userinfo.ImpersonatedBy `json:"impersonatedBy"`
+}
+
 //AddUserInfo adds userInfo at path request.userInfo
 func (ctx *Context) AddUserInfo(userRequestInfo kyverno.RequestInfo) error {
+	var request interface{} = userRequestInfo
+	if impersonatedBy := userinfo.GetImpersonatedBy(userRequestInfo.AdmissionUserInfo); impersonatedBy != nil {
+		request = impersonatedRequestInfo{
+			RequestInfo: userRequestInfo,
+			UserInfo: impersonatedUserInfo{
+				UserInfo:       userRequestInfo.AdmissionUserInfo,
+				ImpersonatedBy: impersonatedBy,
+			},
+		}
+	}
+
 	modifiedResource := struct {
 		Request interface{} `json:"request"`
 	}{
-		Request: userRequestInfo,
+		Request: request,
 	}
 
 	objRaw, err := json.Marshal(modifiedResource)
@@ -217,6 +260,61 @@ func (ctx *Context) AddNamespace(namespace string) error {
 	return ctx.AddJSON(objRaw)
 }
 
+// AddElement adds the element of a foreach list at path element, and its index at elementIndex.
+// The element replaces the previous element, the fields of the previous element are not merged.
+func (ctx *Context) AddElement(data interface{}, index int) error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(ctx.jsonRaw, &document); err != nil {
+		ctx.log.Error(err, "failed to unmarshal context")
+		return err
+	}
+
+	if document == nil {
+		document = make(map[string]interface{})
+	}
+
+	document["element"] = data
+	document["elementIndex"] = index
+	jsonRaw, err := json.Marshal(document)
+	if err != nil {
+		ctx.log.Error(err, "failed to marshal the element")
+		return err
+	}
+
+	ctx.jsonRaw = jsonRaw
+	return nil
+}
+
+// AddAttestation adds the in-toto statement of an image attestation at path attestation.
+// The statement replaces the previous statement, the fields are not merged.
+func (ctx *Context) AddAttestation(statement interface{}) error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(ctx.jsonRaw, &document); err != nil {
+		ctx.log.Error(err, "failed to unmarshal context")
+		return err
+	}
+
+	if document == nil {
+		document = make(map[string]interface{})
+	}
+
+	document["attestation"] = statement
+	jsonRaw, err := json.Marshal(document)
+	if err != nil {
+		ctx.log.Error(err, "failed to marshal the attestation")
+		return err
+	}
+
+	ctx.jsonRaw = jsonRaw
+	return nil
+}
+
 func (ctx *Context) AddImageInfo(resource *unstructured.Unstructured) error {
 	initContainersImgs, containersImgs := extractImageInfo(resource, ctx.log)
 	if len(initContainersImgs) == 0 && len(containersImgs) == 0 {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/userinfo"
 	authenticationv1 "k8s.io/api/authentication/v1"
 )
 
@@ -118,3 +119,53 @@ func Test_addResourceAndUserContext(t *testing.T) {
 		t.Error("exected result does not match")
 	}
 }
+
+func Test_AddUserInfo_Impersonation(t *testing.T) {
+	userInfo := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:ci:deployer",
+		Groups:   []string{"system:serviceaccounts"},
+	}
+
+	// without impersonation the userInfo is unchanged
+	ctx := NewContext()
+	if err := ctx.AddUserInfo(kyverno.RequestInfo{AdmissionUserInfo: userInfo}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ctx.Query("request.userInfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"username": "system:serviceaccount:ci:deployer", "groups": []interface{}{"system:serviceaccounts"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	// the impersonating user is added from the userInfo extras
+	userInfo.Extra = map[string]authenticationv1.ExtraValue{
+		userinfo.OriginalUserExtraKey:   {"alice"},
+		userinfo.OriginalGroupsExtraKey: {"admins", "system:authenticated"},
+	}
+
+	ctx = NewContext()
+	if err := ctx.AddUserInfo(kyverno.RequestInfo{ClusterRoles: []string{"edit"}, AdmissionUserInfo: userInfo}); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, expected := range map[string]interface{}{
+		"request.userInfo.username":                "system:serviceaccount:ci:deployer",
+		"request.userInfo.impersonatedBy.username": "alice",
+		"request.userInfo.impersonatedBy.groups":   []interface{}{"admins", "system:authenticated"},
+		"request.clusterRoles":                     []interface{}{"edit"},
+	} {
+		result, err := ctx.Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("%s: expected %v, got %v", query, expected, result)
+		}
+	}
+}
@@ -10,6 +10,15 @@ import (
 
 //Query the JSON context with JMESPATH search path
 func (ctx *Context) Query(query string) (interface{}, error) {
+	result, err := ctx.query(query)
+	if recorder := ctx.getQueryRecorder(); recorder != nil {
+		recorder(strings.TrimSpace(query), result, err)
+	}
+
+	return result, err
+}
+
+func (ctx *Context) query(query string) (interface{}, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, fmt.Errorf("invalid query (nil)")
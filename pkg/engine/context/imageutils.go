@@ -4,8 +4,8 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/distribution/distribution/reference"
 	"github.com/go-logr/logr"
+	imageutils "github.com/kyverno/kyverno/pkg/utils/image"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -31,13 +31,13 @@ type ImageInfo struct {
 	JSONPath string `json:"jsonPath,omitempty"`
 }
 
-func (i *ImageInfo) String() string {
-	image := i.Registry + "/" + i.Path + ":" + i.Tag
-	if i.Digest != "" {
-		image = image + "@" + i.Digest
-	}
+// Info returns the parsed image reference
+func (i *ImageInfo) Info() *imageutils.Info {
+	return &imageutils.Info{Registry: i.Registry, Name: i.Name, Path: i.Path, Tag: i.Tag, Digest: i.Digest}
+}
 
-	return image
+func (i *ImageInfo) String() string {
+	return i.Info().String()
 }
 
 type ContainerImage struct {
@@ -145,47 +145,17 @@ func convertToImageInfo(containers []interface{}, jsonPath string) (images []*Co
 }
 
 func newImageInfo(image, jsonPath string) (*ImageInfo, error) {
-	image = addDefaultDomain(image)
-	ref, err := reference.Parse(image)
+	info, err := imageutils.Parse(image)
 	if err != nil {
-		return nil, errors.Wrapf(err, "bad image: %s", image)
-	}
-
-	var registry, path, name, tag, digest string
-	if named, ok := ref.(reference.Named); ok {
-		registry = reference.Domain(named)
-		path = reference.Path(named)
-		name = path[strings.LastIndex(path, "/")+1:]
-	}
-
-	if tagged, ok := ref.(reference.Tagged); ok {
-		tag = tagged.Tag()
-	}
-
-	if digested, ok := ref.(reference.Digested); ok {
-		digest = digested.Digest().String()
-	}
-
-	// set default tag - the domain is set via addDefaultDomain before parsing
-	if tag == "" {
-		tag = "latest"
+		return nil, err
 	}
 
 	return &ImageInfo{
-		Registry: registry,
-		Name:     name,
-		Path:     path,
-		Tag:      tag,
-		Digest:   digest,
+		Registry: info.Registry,
+		Name:     info.Name,
+		Path:     info.Path,
+		Tag:      info.Tag,
+		Digest:   info.Digest,
 		JSONPath: jsonPath,
 	}, nil
 }
-
-func addDefaultDomain(name string) string {
-	i := strings.IndexRune(name, '/')
-	if i == -1 || (!strings.ContainsAny(name[:i], ".:") && name[:i] != "localhost" && strings.ToLower(name[:i]) == name[:i]) {
-		return "docker.io/" + name
-	}
-
-	return name
-}
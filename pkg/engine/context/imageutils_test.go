@@ -103,6 +103,24 @@ func Test_ImageInfo_String(t *testing.T) {
 		"latest",
 		"",
 		"localhost:4443/test/nginx:latest")
+
+	validateImageInfo(t,
+		"nginx@sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10",
+		"nginx",
+		"nginx",
+		"docker.io",
+		"",
+		"sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10",
+		"docker.io/nginx@sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10")
+
+	validateImageInfo(t,
+		"Registry.Test.IO:5000/Test/MyApp:V1",
+		"MyApp",
+		"Test/MyApp",
+		"registry.test.io:5000",
+		"V1",
+		"",
+		"registry.test.io:5000/Test/MyApp:V1")
 }
 
 func validateImageInfo(t *testing.T, raw, name, path, registry, tag, digest, str string) {
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SkipsDeletingResource returns true if the rule is not applied to the resource as the resource is being deleted,
+// i.e. it has a deletion timestamp, and the rule does not apply to the deleting resources. The creations are
+// never skipped.
+func SkipsDeletingResource(rule kyverno.Rule, resource unstructured.Unstructured, operation string) bool {
+	if rule.ApplyToDeleting || operation == "CREATE" {
+		return false
+	}
+
+	return resource.GetDeletionTimestamp() != nil
+}
+
+// checkDeleting returns true if the rule is applied to the new resource of the policy context. The validate and
+// mutate rules are not applied to the resources being deleted, e.g. the updates removing the finalizers, unless
+// the rule sets applyToDeleting. A skipped response is returned for the rules that are not applied.
+func checkDeleting(log logr.Logger, rule kyverno.Rule, ctx *PolicyContext, ruleType string) (bool, *response.RuleResponse) {
+	if !SkipsDeletingResource(rule, ctx.NewResource, ctx.AdmissionInfo.Operation) {
+		return true, nil
+	}
+
+	deletionTimestamp := ctx.NewResource.GetDeletionTimestamp()
+	log.V(3).Info("rule is not applied to the resource being deleted", "deletionTimestamp", deletionTimestamp.UTC().Format(time.RFC3339))
+	return false, &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    ruleType,
+		Message: fmt.Sprintf("rule %s is not applied to the resource being deleted", rule.Name),
+		Success: true,
+		Skipped: true,
+		Properties: map[string]string{
+			response.RulePropertySkipReason:        response.SkipReasonDeleting,
+			response.RulePropertyDeletionTimestamp: deletionTimestamp.UTC().Format(time.RFC3339),
+		},
+	}
+}
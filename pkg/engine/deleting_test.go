@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+)
+
+var deletingPodRaw = []byte(`{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {
+		"name": "web",
+		"namespace": "prod",
+		"deletionTimestamp": "2021-03-29T08:30:00Z",
+		"finalizers": ["example.com/cleanup"]
+	},
+	"spec": {"containers": [{"name": "web", "image": "nginx"}]}
+}`)
+
+var podRaw = []byte(`{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {"name": "web", "namespace": "prod"},
+	"spec": {"containers": [{"name": "web", "image": "nginx"}]}
+}`)
+
+func newDeletingTestPolicy(t *testing.T, applyToDeleting bool) kyverno.ClusterPolicy {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "require-team"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "the label 'team' is required",
+						"pattern": {"metadata": {"labels": {"team": "?*"}}}
+					}
+				},
+				{
+					"name": "add-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"mutate": {
+						"patchStrategicMerge": {"metadata": {"labels": {"+(team)": "unknown"}}}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+	for i := range policy.Spec.Rules {
+		policy.Spec.Rules[i].ApplyToDeleting = applyToDeleting
+	}
+
+	return policy
+}
+
+func newDeletingTestContext(t *testing.T, policy kyverno.ClusterPolicy, rawResource []byte, operation string) *PolicyContext {
+	resource, err := utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(rawResource))
+	return &PolicyContext{
+		Policy:        policy,
+		NewResource:   *resource,
+		JSONContext:   ctx,
+		AdmissionInfo: kyverno.RequestInfo{Operation: operation},
+	}
+}
+
+func Test_SkipsDeletingResource(t *testing.T) {
+	deleting, err := utils.ConvertToUnstructured(deletingPodRaw)
+	assert.NilError(t, err)
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	rule := kyverno.Rule{Name: "check-team"}
+	optIn := kyverno.Rule{Name: "check-team", ApplyToDeleting: true}
+
+	testcases := []struct {
+		rule      kyverno.Rule
+		deleting  bool
+		operation string
+		skipped   bool
+	}{
+		{rule: rule, deleting: true, operation: "UPDATE", skipped: true},
+		// the background scans have no operation
+		{rule: rule, deleting: true, operation: "", skipped: true},
+		// the creations are never skipped
+		{rule: rule, deleting: true, operation: "CREATE", skipped: false},
+		{rule: rule, deleting: false, operation: "UPDATE", skipped: false},
+		{rule: rule, deleting: false, operation: "CREATE", skipped: false},
+		{rule: optIn, deleting: true, operation: "UPDATE", skipped: false},
+		{rule: optIn, deleting: true, operation: "", skipped: false},
+	}
+
+	for _, tc := range testcases {
+		r := *resource
+		if tc.deleting {
+			r = *deleting
+		}
+
+		assert.Equal(t, SkipsDeletingResource(tc.rule, r, tc.operation), tc.skipped, "%+v", tc)
+	}
+}
+
+func Test_Validate_Deleting(t *testing.T) {
+	// the update removing the finalizers of the deleting resource is not blocked
+	er := Validate(newDeletingTestContext(t, newDeletingTestPolicy(t, false), deletingPodRaw, "UPDATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.IsSuccessful())
+	assert.Assert(t, er.PolicyResponse.Rules[0].Skipped)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertySkipReason], response.SkipReasonDeleting)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyDeletionTimestamp], "2021-03-29T08:30:00Z")
+	assert.Equal(t, len(er.GetSuccessRules()), 0)
+
+	// the rule guarding the removal of the finalizers applies
+	er = Validate(newDeletingTestContext(t, newDeletingTestPolicy(t, true), deletingPodRaw, "UPDATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.IsSuccessful())
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Skipped)
+
+	// the creations are never skipped
+	er = Validate(newDeletingTestContext(t, newDeletingTestPolicy(t, false), deletingPodRaw, "CREATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.IsSuccessful())
+
+	// the updates of the resources that are not deleted are unaffected
+	er = Validate(newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "UPDATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.IsSuccessful())
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertySkipReason], "")
+}
+
+func Test_Mutate_Deleting(t *testing.T) {
+	er := Mutate(newDeletingTestContext(t, newDeletingTestPolicy(t, false), deletingPodRaw, "UPDATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.PolicyResponse.Rules[0].Skipped)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertySkipReason], response.SkipReasonDeleting)
+	assert.Equal(t, len(er.GetPatches()), 0)
+
+	er = Mutate(newDeletingTestContext(t, newDeletingTestPolicy(t, true), deletingPodRaw, "UPDATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Skipped)
+	assert.Assert(t, len(er.GetPatches()) > 0)
+
+	er = Mutate(newDeletingTestContext(t, newDeletingTestPolicy(t, false), deletingPodRaw, "CREATE"))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Skipped)
+	assert.Assert(t, len(er.GetPatches()) > 0)
+}
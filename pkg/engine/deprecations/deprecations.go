@@ -0,0 +1,180 @@
+package deprecations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Removal is an API version of a kind removed in a Kubernetes version
+type Removal struct {
+	// APIVersion is the removed API version, e.g. "extensions/v1beta1"
+	APIVersion string
+
+	// Kind is the kind served by the removed API version, e.g. "Ingress"
+	Kind string
+
+	// RemovedIn is the Kubernetes minor version the API version is removed in, e.g. "1.22"
+	RemovedIn string
+
+	// Replacement is the API version to migrate to, empty if the API has no replacement
+	Replacement string
+
+	// Note explains the migration of the APIs without replacement
+	Note string
+}
+
+// removals are the API versions removed from Kubernetes, see
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var removals = []Removal{
+	// 1.16
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", RemovedIn: "1.16", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", RemovedIn: "1.16", Replacement: "policy/v1beta1"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", RemovedIn: "1.16", Replacement: "apps/v1"},
+
+	// 1.22
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", RemovedIn: "1.22", Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", RemovedIn: "1.22", Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", RemovedIn: "1.22", Replacement: "apiextensions.k8s.io/v1"},
+	{APIVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", RemovedIn: "1.22", Replacement: "apiregistration.k8s.io/v1"},
+	{APIVersion: "authentication.k8s.io/v1beta1", Kind: "TokenReview", RemovedIn: "1.22", Replacement: "authentication.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "LocalSubjectAccessReview", RemovedIn: "1.22", Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SelfSubjectAccessReview", RemovedIn: "1.22", Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SubjectAccessReview", RemovedIn: "1.22", Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", RemovedIn: "1.22", Replacement: "certificates.k8s.io/v1"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", RemovedIn: "1.22", Replacement: "coordination.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", RemovedIn: "1.22", Replacement: "scheduling.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+
+	// 1.25
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", RemovedIn: "1.25", Replacement: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", RemovedIn: "1.25", Replacement: "discovery.k8s.io/v1"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", RemovedIn: "1.25", Replacement: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", RemovedIn: "1.25", Replacement: "autoscaling/v2"},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", RemovedIn: "1.25", Replacement: "policy/v1"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", RemovedIn: "1.25", Note: "use the Pod Security Admission or policies instead"},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", RemovedIn: "1.25", Replacement: "node.k8s.io/v1"},
+
+	// 1.26
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", RemovedIn: "1.26", Replacement: "autoscaling/v2"},
+
+	// 1.27
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", RemovedIn: "1.27", Replacement: "storage.k8s.io/v1"},
+
+	// 1.29
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", RemovedIn: "1.29", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", RemovedIn: "1.29", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+
+	// 1.32
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", RemovedIn: "1.32", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", RemovedIn: "1.32", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// Message describes the removal and the migration
+func (r Removal) Message() string {
+	msg := fmt.Sprintf("%s %s is removed in Kubernetes %s", r.APIVersion, r.Kind, r.RemovedIn)
+	if r.Replacement != "" {
+		return fmt.Sprintf("%s, use %s %s instead", msg, r.Replacement, r.Kind)
+	}
+
+	if r.Note != "" {
+		return fmt.Sprintf("%s without replacement, %s", msg, r.Note)
+	}
+
+	return msg + " without replacement"
+}
+
+// Find returns the removal of the API version of the kind in the target version, e.g. "1.25"
+func Find(apiVersion, kind, targetVersion string) (*Removal, error) {
+	target, err := ParseVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range removals {
+		r := &removals[i]
+		if r.APIVersion != apiVersion || r.Kind != kind {
+			continue
+		}
+
+		removedIn, err := ParseVersion(r.RemovedIn)
+		if err != nil {
+			return nil, err
+		}
+
+		if target >= removedIn {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ParseVersion parses a Kubernetes 1.x version, e.g. "1.25", "v1.25" or "1.25.3", and returns the minor version
+func ParseVersion(version string) (int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("invalid Kubernetes version %q, expected a 1.x version, e.g. 1.25", version)
+	}
+
+	minor, err := strconv.Atoi(strings.TrimSuffix(parts[1], "+"))
+	if err != nil || minor < 0 {
+		return 0, fmt.Errorf("invalid Kubernetes version %q, expected a 1.x version, e.g. 1.25", version)
+	}
+
+	return minor, nil
+}
+
+var (
+	mutex                sync.RWMutex
+	defaultTargetVersion string
+)
+
+// SetClusterVersion sets the default target version to the minor version after the version of the cluster,
+// the minor version is reported by the discovery, e.g. "21" or "21+" on managed clusters
+func SetClusterVersion(major, minor string) error {
+	version, err := ParseVersion(major + "." + minor)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	defaultTargetVersion = fmt.Sprintf("1.%d", version+1)
+	return nil
+}
+
+// ClearClusterVersion forgets the version of the cluster, the rules must then set their target version
+func ClearClusterVersion() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	defaultTargetVersion = ""
+}
+
+// DefaultTargetVersion returns the default target version, empty if the version of the cluster is not known
+func DefaultTargetVersion() string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return defaultTargetVersion
+}
@@ -0,0 +1,87 @@
+package deprecations
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_Find(t *testing.T) {
+	testcases := []struct {
+		apiVersion    string
+		kind          string
+		targetVersion string
+		message       string
+	}{
+		// Ingress
+		{apiVersion: "extensions/v1beta1", kind: "Ingress", targetVersion: "1.21"},
+		{apiVersion: "extensions/v1beta1", kind: "Ingress", targetVersion: "1.22", message: "extensions/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress instead"},
+		{apiVersion: "networking.k8s.io/v1beta1", kind: "Ingress", targetVersion: "v1.25.3", message: "networking.k8s.io/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress instead"},
+		{apiVersion: "networking.k8s.io/v1", kind: "Ingress", targetVersion: "1.30"},
+		// PodSecurityPolicy
+		{apiVersion: "policy/v1beta1", kind: "PodSecurityPolicy", targetVersion: "1.24"},
+		{apiVersion: "policy/v1beta1", kind: "PodSecurityPolicy", targetVersion: "1.25", message: "policy/v1beta1 PodSecurityPolicy is removed in Kubernetes 1.25 without replacement, use the Pod Security Admission or policies instead"},
+		{apiVersion: "extensions/v1beta1", kind: "PodSecurityPolicy", targetVersion: "1.16", message: "extensions/v1beta1 PodSecurityPolicy is removed in Kubernetes 1.16, use policy/v1beta1 PodSecurityPolicy instead"},
+		// CronJob
+		{apiVersion: "batch/v1beta1", kind: "CronJob", targetVersion: "1.24"},
+		{apiVersion: "batch/v1beta1", kind: "CronJob", targetVersion: "1.25", message: "batch/v1beta1 CronJob is removed in Kubernetes 1.25, use batch/v1 CronJob instead"},
+		{apiVersion: "batch/v1", kind: "CronJob", targetVersion: "1.25"},
+		// the same version of another kind
+		{apiVersion: "policy/v1beta1", kind: "Eviction", targetVersion: "1.25"},
+	}
+
+	for _, tc := range testcases {
+		removal, err := Find(tc.apiVersion, tc.kind, tc.targetVersion)
+		assert.NilError(t, err)
+		if tc.message == "" {
+			assert.Assert(t, removal == nil, "%s %s %s", tc.apiVersion, tc.kind, tc.targetVersion)
+			continue
+		}
+
+		assert.Assert(t, removal != nil, "%s %s %s", tc.apiVersion, tc.kind, tc.targetVersion)
+		assert.Equal(t, removal.Message(), tc.message)
+	}
+}
+
+func Test_Removals(t *testing.T) {
+	seen := map[string]bool{}
+	for _, r := range removals {
+		_, err := ParseVersion(r.RemovedIn)
+		assert.NilError(t, err, r.APIVersion, r.Kind)
+		assert.Assert(t, r.Replacement != "" || r.Note != "", "%s %s has no replacement", r.APIVersion, r.Kind)
+		assert.Assert(t, r.Replacement != r.APIVersion, "%s %s is replaced by itself", r.APIVersion, r.Kind)
+
+		key := r.APIVersion + "/" + r.Kind
+		assert.Assert(t, !seen[key], "%s %s is removed twice", r.APIVersion, r.Kind)
+		seen[key] = true
+	}
+}
+
+func Test_ParseVersion(t *testing.T) {
+	for version, minor := range map[string]int{"1.25": 25, "v1.22": 22, "1.21.4": 21, "1.20+": 20} {
+		parsed, err := ParseVersion(version)
+		assert.NilError(t, err, version)
+		assert.Equal(t, parsed, minor, version)
+	}
+
+	for _, version := range []string{"", "1", "2.1", "1.x", "latest"} {
+		_, err := ParseVersion(version)
+		assert.Assert(t, err != nil, version)
+	}
+
+	_, err := Find("batch/v1beta1", "CronJob", "next")
+	assert.ErrorContains(t, err, `invalid Kubernetes version "next"`)
+}
+
+func Test_SetClusterVersion(t *testing.T) {
+	defer ClearClusterVersion()
+
+	assert.NilError(t, SetClusterVersion("1", "24+"))
+	assert.Equal(t, DefaultTargetVersion(), "1.25")
+
+	assert.Assert(t, SetClusterVersion("", "") != nil)
+	assert.Equal(t, DefaultTargetVersion(), "1.25")
+
+	ClearClusterVersion()
+	assert.Equal(t, DefaultTargetVersion(), "")
+}
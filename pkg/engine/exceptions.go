@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/minio/pkg/wildcard"
+)
+
+// policyKey returns the name of the policy the exceptions refer to, the name of a namespaced
+// policy is prefixed with its namespace
+func policyKey(policy kyverno.ClusterPolicy) string {
+	if policy.GetNamespace() == "" {
+		return policy.GetName()
+	}
+
+	return policy.GetNamespace() + "/" + policy.GetName()
+}
+
+// exempts returns true if the exception names the rule of the policy. All the rules of the
+// policy are exempted if the exception does not name any rule.
+func exempts(exception kyverno.Exception, policyName, ruleName string) bool {
+	if exception.PolicyName != policyName {
+		return false
+	}
+
+	if len(exception.RuleNames) == 0 {
+		return true
+	}
+
+	for _, pattern := range exception.RuleNames {
+		if wildcard.Match(pattern, ruleName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findException returns the first policy exception of the policy context exempting the rule
+// for the resource and the requester, or nil
+func findException(log logr.Logger, rule kyverno.Rule, ctx *PolicyContext) *kyverno.PolicyException {
+	policyName := policyKey(ctx.Policy)
+	for _, polex := range ctx.Exceptions {
+		if polex == nil {
+			continue
+		}
+
+		named := false
+		for _, exception := range polex.Spec.Exceptions {
+			if exempts(exception, policyName, rule.Name) {
+				named = true
+				break
+			}
+		}
+
+		if !named {
+			continue
+		}
+
+		exceptionRule := kyverno.Rule{
+			Name:           fmt.Sprintf("%s/%s", polex.GetNamespace(), polex.GetName()),
+			MatchResources: polex.Spec.Match,
+		}
+
+		if matches(log.WithValues("exception", exceptionRule.Name), exceptionRule, ctx) {
+			return polex
+		}
+	}
+
+	return nil
+}
+
+// checkExceptions returns true if the rule is applied to the resource of the policy context, i.e. no policy
+// exception exempts the resource or the requester from the rule. A skipped response is returned for the
+// rules that are not applied.
+func checkExceptions(log logr.Logger, rule kyverno.Rule, ctx *PolicyContext, ruleType string) (bool, *response.RuleResponse) {
+	if len(ctx.Exceptions) == 0 {
+		return true, nil
+	}
+
+	polex := findException(log, rule, ctx)
+	if polex == nil {
+		return true, nil
+	}
+
+	name := fmt.Sprintf("%s/%s", polex.GetNamespace(), polex.GetName())
+	log.V(3).Info("rule is exempted by a policy exception", "exception", name)
+	return false, &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    ruleType,
+		Message: fmt.Sprintf("rule %s is exempted by the policy exception %s", rule.Name, name),
+		Success: true,
+		Skipped: true,
+		Properties: map[string]string{
+			response.RulePropertySkipReason:      response.SkipReasonException,
+			response.RulePropertyPolicyException: name,
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"gotest.tools/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func newTestPolicyException(t *testing.T, raw string) *kyverno.PolicyException {
+	var polex kyverno.PolicyException
+	assert.NilError(t, json.Unmarshal([]byte(raw), &polex))
+	return &polex
+}
+
+func Test_Exempts(t *testing.T) {
+	testcases := []struct {
+		exception kyverno.Exception
+		policy    string
+		rule      string
+		exempted  bool
+	}{
+		{exception: kyverno.Exception{PolicyName: "require-team"}, policy: "require-team", rule: "check-team", exempted: true},
+		{exception: kyverno.Exception{PolicyName: "require-team", RuleNames: []string{"check-team"}}, policy: "require-team", rule: "check-team", exempted: true},
+		{exception: kyverno.Exception{PolicyName: "require-team", RuleNames: []string{"check-*"}}, policy: "require-team", rule: "check-team", exempted: true},
+		{exception: kyverno.Exception{PolicyName: "require-team", RuleNames: []string{"add-*"}}, policy: "require-team", rule: "check-team", exempted: false},
+		{exception: kyverno.Exception{PolicyName: "require-owner"}, policy: "require-team", rule: "check-team", exempted: false},
+		// the namespaced policies are named with their namespace
+		{exception: kyverno.Exception{PolicyName: "prod/require-team"}, policy: "prod/require-team", rule: "check-team", exempted: true},
+		{exception: kyverno.Exception{PolicyName: "require-team"}, policy: "prod/require-team", rule: "check-team", exempted: false},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, exempts(tc.exception, tc.policy, tc.rule), tc.exempted, "%+v", tc)
+	}
+}
+
+func Test_Validate_PolicyException(t *testing.T) {
+	byName := newTestPolicyException(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "PolicyException",
+		"metadata": {"name": "web", "namespace": "prod"},
+		"spec": {
+			"exceptions": [{"policyName": "require-team", "ruleNames": ["check-*"]}],
+			"match": {"resources": {"kinds": ["Pod"], "names": ["web"]}}
+		}
+	}`)
+
+	bySubject := newTestPolicyException(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "PolicyException",
+		"metadata": {"name": "break-glass", "namespace": "kyverno"},
+		"spec": {
+			"exceptions": [{"policyName": "require-team"}],
+			"match": {"subjects": [{"kind": "User", "name": "admin"}]}
+		}
+	}`)
+
+	otherPolicy := newTestPolicyException(t, `{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "PolicyException",
+		"metadata": {"name": "other", "namespace": "prod"},
+		"spec": {
+			"exceptions": [{"policyName": "require-owner"}],
+			"match": {"resources": {"kinds": ["Pod"]}}
+		}
+	}`)
+
+	// the resource is not exempted without exceptions
+	ctx := newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "CREATE")
+	er := Validate(ctx)
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.IsSuccessful())
+
+	// the exception of the resource skips the rule
+	ctx = newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "CREATE")
+	ctx.Exceptions = []*kyverno.PolicyException{otherPolicy, byName}
+	er = Validate(ctx)
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.IsSuccessful())
+	assert.Assert(t, er.PolicyResponse.Rules[0].Skipped)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Message, "rule check-team is exempted by the policy exception prod/web")
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertySkipReason], response.SkipReasonException)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyPolicyException], "prod/web")
+
+	// the exceptions of other policies do not apply
+	ctx = newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "CREATE")
+	ctx.Exceptions = []*kyverno.PolicyException{otherPolicy}
+	er = Validate(ctx)
+	assert.Assert(t, !er.IsSuccessful())
+
+	// the exception of the subject only applies to the requests of the subject
+	ctx = newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "CREATE")
+	ctx.Exceptions = []*kyverno.PolicyException{bySubject}
+	ctx.AdmissionInfo.AdmissionUserInfo = authenticationv1.UserInfo{Username: "dev"}
+	er = Validate(ctx)
+	assert.Assert(t, !er.IsSuccessful())
+
+	ctx = newDeletingTestContext(t, newDeletingTestPolicy(t, false), podRaw, "CREATE")
+	ctx.Exceptions = []*kyverno.PolicyException{bySubject}
+	ctx.AdmissionInfo.AdmissionUserInfo = authenticationv1.UserInfo{Username: "admin"}
+	er = Validate(ctx)
+	assert.Assert(t, er.IsSuccessful())
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyPolicyException], "kyverno/break-glass")
+}
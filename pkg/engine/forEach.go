@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	gojmespath "github.com/jmespath/go-jmespath"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/common"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/mutate"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// evaluateList returns the elements of the list selected by the JMESPath expression of a foreach
+// declaration, the expression may be enclosed in braces. A null result is an empty list.
+func evaluateList(jmesPath string, ctx context.EvalInterface) ([]interface{}, error) {
+	query := strings.TrimSpace(jmesPath)
+	query = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(query, "{{"), "}}"))
+
+	result, err := ctx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, nil
+	}
+
+	elements, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s does not return a list but %T", jmesPath, result)
+	}
+
+	return elements, nil
+}
+
+// loadElement adds the element to the context, loads the context entries of the foreach declaration
+// and evaluates its preconditions. It returns false if the element is not processed.
+func loadElement(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule, entries []kyverno.ContextEntry, preconditions apiextensions.JSON, element interface{}, index int) (bool, error) {
+	if err := ctx.JSONContext.AddElement(element, index); err != nil {
+		return false, err
+	}
+
+	if len(entries) > 0 {
+		if err := LoadContext(log, entries, ctx.ResourceCache, ctx, rule.Name); err != nil {
+			if _, ok := err.(gojmespath.NotFoundError); ok {
+				log.V(3).Info("failed to load the context of the element", "elementIndex", index, "reason", err.Error())
+				return false, nil
+			}
+
+			return false, err
+		}
+	}
+
+	preconditionsCopy, err := copyConditions(preconditions)
+	if err != nil {
+		log.V(2).Info("wrongfully configured data", "reason", err.Error())
+		return false, nil
+	}
+
+	if !variables.EvaluateConditions(log, ctx.JSONContext, preconditionsCopy, true) {
+		log.V(4).Info("element fails the preconditions", "elementIndex", index)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// validateForEach applies the foreach declarations of the validate rule to the elements of their lists.
+// The rule fails with the message of the first failing element and the violations of all the failing
+// elements, and nil is returned if no element is checked.
+func validateForEach(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	var checked int
+	var failed *response.RuleResponse
+	var violations []response.Violation
+	for i, foreach := range rule.Validation.ForEachValidation {
+		elements, err := evaluateList(foreach.List, ctx.JSONContext)
+		if err != nil {
+			if _, ok := err.(gojmespath.NotFoundError); ok {
+				log.V(3).Info("failed to evaluate the foreach list", "list", foreach.List, "reason", err.Error())
+				continue
+			}
+
+			log.Error(err, "failed to evaluate the foreach list", "list", foreach.List)
+			ruleResp := ruleError(rule, fmt.Sprintf("failed to evaluate the list of foreach[%d]", i), err)
+			return &ruleResp
+		}
+
+		for index, element := range elements {
+			ruleResp := validateElement(log.WithValues("elementIndex", index), ctx, rule, foreach, element, index)
+			if ruleResp == nil {
+				continue
+			}
+
+			checked++
+			if ruleResp.Success {
+				continue
+			}
+
+			if ruleResp.Error {
+				return ruleResp
+			}
+
+			violations = append(violations, elementViolations(*ruleResp, foreach.List, element, index)...)
+			if failed == nil {
+				ruleResp.Message = fmt.Sprintf("%s for element %d of %s", ruleResp.Message, index, foreach.List)
+				if ruleResp.LocalizedMessage != "" {
+					ruleResp.LocalizedMessage = fmt.Sprintf("%s for element %d of %s", ruleResp.LocalizedMessage, index, foreach.List)
+				}
+				failed = ruleResp
+			}
+		}
+	}
+
+	if failed != nil {
+		failed.Violations = violations
+		return failed
+	}
+
+	if checked == 0 {
+		return nil
+	}
+
+	return &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Validation.String(),
+		Message: fmt.Sprintf("validation rule '%s' passed for %d elements.", rule.Name, checked),
+		Success: true,
+	}
+}
+
+// elementViolations returns the violations of a failing element, with the element they are reported for
+func elementViolations(ruleResp response.RuleResponse, list string, element interface{}, index int) []response.Violation {
+	violations := ruleResp.Violations
+	if len(violations) == 0 {
+		violations = []response.Violation{{Rule: ruleResp.Name, Message: ruleResp.Message}}
+	}
+
+	var name string
+	if object, ok := element.(map[string]interface{}); ok {
+		name, _ = object["name"].(string)
+		if name == "" {
+			name, _, _ = unstructured.NestedString(object, "metadata", "name")
+		}
+	}
+
+	withElement := make([]response.Violation, len(violations))
+	for i, violation := range violations {
+		elementIndex := index
+		violation.List = list
+		violation.Element = &elementIndex
+		violation.ElementName = name
+		withElement[i] = violation
+	}
+
+	return withElement
+}
+
+// validateElement applies the pattern, the anyPattern or the deny conditions of the foreach declaration,
+// with the variables substituted, to the element. It returns nil if the element is not checked.
+func validateElement(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule, foreach kyverno.ForEachValidation, element interface{}, index int) *response.RuleResponse {
+	process, err := loadElement(log, ctx, rule, foreach.Context, foreach.Preconditions, element, index)
+	if err != nil {
+		log.Error(err, "failed to load the element")
+		ruleResp := ruleError(rule, "failed to load the element", err)
+		return &ruleResp
+	}
+
+	if !process {
+		return nil
+	}
+
+	elementRule := kyverno.Rule{
+		Name: rule.Name,
+		Validation: kyverno.Validation{
+			Pattern:    foreach.Pattern,
+			AnyPattern: foreach.AnyPattern,
+			Deny:       foreach.Deny,
+		},
+	}
+
+	if elementRule, err = variables.SubstituteAllInRule(log, ctx.JSONContext, elementRule); err != nil {
+		if _, ok := err.(gojmespath.NotFoundError); ok {
+			log.V(2).Info("failed to substitute variables, skip the element", "info", err.Error())
+			return nil
+		}
+
+		log.Error(err, "failed to substitute variables, skip the element")
+		ruleResp := ruleError(rule, "variable substitution failed", err)
+		return &ruleResp
+	}
+	restoreDenyConditions(&elementRule.Validation, foreach.Deny)
+	substituteMessages(log, ctx.JSONContext, &elementRule.Validation, rule.Validation.Message, rule.Validation.Messages)
+
+	defaultMessage, message, locale := localizedMessages(ctx, elementRule.Validation)
+	elementRule.Validation.Message = defaultMessage
+
+	var ruleResp *response.RuleResponse
+	if elementRule.Validation.Pattern != nil || elementRule.Validation.AnyPattern != nil {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			resp := ruleError(rule, "failed to validate the element", fmt.Errorf("the patterns apply to objects, the element is %T", element))
+			return &resp
+		}
+
+		resp := validatePatterns(log, ctx.JSONContext, unstructured.Unstructured{Object: object}, elementRule)
+		if common.IsConditionalAnchorError(resp.Message) {
+			return nil
+		}
+
+		ruleResp = &resp
+	} else {
+		ruleResp = applyValidationRule(log, ctx, elementRule)
+	}
+
+	localizeRuleResponse(ruleResp, defaultMessage, message, locale)
+	return ruleResp
+}
+
+// mutateForEach applies the patches of the foreach declarations of the mutate rule for the elements of
+// their lists. The patches of all the elements are returned in a single rule response.
+func mutateForEach(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule, resource unstructured.Unstructured) (response.RuleResponse, unstructured.Unstructured) {
+	ruleResp := response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Mutation.String(),
+		Success: true,
+	}
+
+	patchedResource := resource
+	var mutated int
+	for i, foreach := range rule.Mutation.ForEachMutation {
+		elements, err := evaluateList(foreach.List, ctx.JSONContext)
+		if err != nil {
+			if _, ok := err.(gojmespath.NotFoundError); ok {
+				log.V(3).Info("failed to evaluate the foreach list", "list", foreach.List, "reason", err.Error())
+				continue
+			}
+
+			ruleResp.Success = false
+			ruleResp.Message = fmt.Sprintf("failed to evaluate the list of foreach[%d]: %v", i, err)
+			return ruleResp, resource
+		}
+
+		for index, element := range elements {
+			logger := log.WithValues("elementIndex", index)
+			process, err := loadElement(logger, ctx, rule, foreach.Context, foreach.Preconditions, element, index)
+			if err != nil {
+				ruleResp.Success = false
+				ruleResp.Message = fmt.Sprintf("failed to load element %d of %s: %v", index, foreach.List, err)
+				return ruleResp, resource
+			}
+
+			if !process {
+				continue
+			}
+
+			patch, err := variables.SubstituteAll(logger, ctx.JSONContext, foreach.PatchStrategicMerge)
+			if err != nil {
+				if _, ok := err.(gojmespath.NotFoundError); ok {
+					logger.V(2).Info("failed to substitute variables, skip the element", "info", err.Error())
+					continue
+				}
+
+				ruleResp.Success = false
+				ruleResp.Message = fmt.Sprintf("variable substitution failed for element %d of %s: %v", index, foreach.List, err)
+				return ruleResp, resource
+			}
+
+			mutation := &kyverno.Mutation{PatchStrategicMerge: patch}
+			elementResp, elementResource := mutate.CreateMutateHandler(rule.Name, mutation, patchedResource, ctx.JSONContext, logger).Handle()
+			if !elementResp.Success {
+				ruleResp.Success = false
+				ruleResp.Message = fmt.Sprintf("%s for element %d of %s", elementResp.Message, index, foreach.List)
+				return ruleResp, resource
+			}
+
+			if len(elementResp.Patches) > 0 {
+				mutated++
+				ruleResp.Patches = append(ruleResp.Patches, elementResp.Patches...)
+				patchedResource = elementResource
+			}
+		}
+	}
+
+	ruleResp.Message = fmt.Sprintf("mutated %d elements", mutated)
+	return ruleResp, patchedResource
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var forEachPodRaw = []byte(`{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {"name": "web", "namespace": "prod"},
+	"spec": {
+		"containers": [
+			{"name": "nginx", "image": "ghcr.io/acme/nginx:1.21"},
+			{"name": "sidecar", "image": "docker.io/acme/sidecar:1.0"}
+		]
+	}
+}`)
+
+func Test_Validate_ForEach(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "trusted-registries"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-registries",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "the images must be pulled from ghcr.io",
+						"foreach": [
+							{
+								"list": "request.object.spec.containers",
+								"pattern": {"image": "ghcr.io/*"}
+							}
+						]
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	validate := func(policy kyverno.ClusterPolicy) response.RuleResponse {
+		resource, err := utils.ConvertToUnstructured(forEachPodRaw)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(forEachPodRaw))
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	rule := validate(policy)
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: the images must be pulled from ghcr.io. Rule check-registries failed at path /image/ for element 1 of request.object.spec.containers")
+
+	// the preconditions select the elements, and the deny conditions reference the element
+	rawForEach := []byte(`{
+		"list": "request.object.spec.containers",
+		"preconditions": {"all": [{"key": "{{element.name}}", "operator": "NotEquals", "value": "sidecar"}]},
+		"deny": {"conditions": {"any": [{"key": "{{element.image}}", "operator": "NotEquals", "value": "ghcr.io/*"}]}}
+	}`)
+	var foreach kyverno.ForEachValidation
+	assert.NilError(t, json.Unmarshal(rawForEach, &foreach))
+	policy.Spec.Rules[0].Validation.ForEachValidation = []kyverno.ForEachValidation{foreach}
+
+	rule = validate(policy)
+	assert.Assert(t, rule.Success, rule.Message)
+	assert.Equal(t, rule.Message, "validation rule 'check-registries' passed for 1 elements.")
+}
+
+func Test_Mutate_ForEach(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "pull-policy"},
+		"spec": {
+			"rules": [
+				{
+					"name": "set-pull-policy",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"mutate": {
+						"foreach": [
+							{
+								"list": "request.object.spec.containers",
+								"preconditions": {"all": [{"key": "{{element.image}}", "operator": "Equals", "value": "docker.io/*"}]},
+								"patchStrategicMerge": {
+									"spec": {
+										"containers": [
+											{"name": "{{element.name}}", "imagePullPolicy": "Always"}
+										]
+									}
+								}
+							}
+						]
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(forEachPodRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(forEachPodRaw))
+
+	er := Mutate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	rule := er.PolicyResponse.Rules[0]
+	assert.Assert(t, rule.Success, rule.Message)
+	assert.Equal(t, rule.Message, "mutated 1 elements")
+
+	containers, found, err := unstructured.NestedSlice(er.PatchedResource.Object, "spec", "containers")
+	assert.NilError(t, err)
+	assert.Assert(t, found)
+	assert.Equal(t, len(containers), 2)
+	for _, c := range containers {
+		container := c.(map[string]interface{})
+		if container["name"] == "sidecar" {
+			assert.Equal(t, container["imagePullPolicy"], "Always")
+		} else {
+			_, ok := container["imagePullPolicy"]
+			assert.Assert(t, !ok)
+		}
+	}
+}
+
+func Test_Validate_ForEach_Violations(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "trusted-registries"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-registries",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "container {{element.name}} uses image {{element.image}}",
+						"foreach": [
+							{
+								"list": "request.object.spec.containers",
+								"pattern": {"image": "quay.io/*"}
+							}
+						]
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(forEachPodRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(forEachPodRaw))
+
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+
+	// the rule reports the first failing element, with the violations of all the failing elements
+	rule := er.PolicyResponse.Rules[0]
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: container nginx uses image ghcr.io/acme/nginx:1.21. Rule check-registries failed at path /image/ for element 0 of request.object.spec.containers")
+
+	first, second := 0, 1
+	assert.DeepEqual(t, rule.Violations, []response.Violation{
+		{Rule: "check-registries", Check: "pattern", Path: "/image/", List: "request.object.spec.containers", Element: &first, ElementName: "nginx", Message: "container nginx uses image ghcr.io/acme/nginx:1.21"},
+		{Rule: "check-registries", Check: "pattern", Path: "/image/", List: "request.object.spec.containers", Element: &second, ElementName: "sidecar", Message: "container sidecar uses image docker.io/acme/sidecar:1.0"},
+	})
+	assert.Equal(t, rule.Violations[1].String(), "rule check-registries: pattern failed for element 1 (sidecar) of request.object.spec.containers at path /image/: container sidecar uses image docker.io/acme/sidecar:1.0")
+}
+
+func Test_Validate_UnresolvedMessageVariable(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "require-team"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "pod {{request.object.metadata.name}} of team {{request.object.metadata.labels.team}} is denied",
+						"deny": {}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(forEachPodRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(forEachPodRaw))
+
+	// the pod has no team label, the rule is applied with the message as is
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+
+	rule := er.PolicyResponse.Rules[0]
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "pod {{request.object.metadata.name}} of team {{request.object.metadata.labels.team}} is denied")
+	assert.DeepEqual(t, rule.Violations, []response.Violation{{Rule: "check-team", Check: "deny", Message: rule.Message}})
+}
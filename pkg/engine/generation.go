@@ -6,6 +6,7 @@ import (
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -32,6 +33,7 @@ func filterRules(policyContext *PolicyContext, startTime time.Time) *response.En
 			PolicyStats: response.PolicyStats{
 				PolicyExecutionTimestamp: startTime.Unix(),
 			},
+			CorrelationID: policyContext.CorrelationID,
 			Resource: response.ResourceSpec{
 				Kind:       kind,
 				Name:       name,
@@ -42,7 +44,7 @@ func filterRules(policyContext *PolicyContext, startTime time.Time) *response.En
 	}
 
 	if policyContext.ExcludeResourceFunc(kind, namespace, name) {
-		log.Log.WithName("Generate").Info("resource excluded", "kind", kind, "namespace", namespace, "name", name)
+		policyContext.logger(log.Log.WithName("Generate")).Info("resource excluded", "kind", kind, "namespace", namespace, "name", name)
 		return resp
 	}
 
@@ -52,6 +54,9 @@ func filterRules(policyContext *PolicyContext, startTime time.Time) *response.En
 		}
 	}
 
+	setRuleDocumentation(policyContext.Policy, resp)
+	setTimeWindowMode(policyContext, resp)
+	policyContext.masker().Response(resp)
 	return resp
 }
 
@@ -65,7 +70,7 @@ func filterRule(rule kyverno.Rule, policyContext *PolicyContext) *response.RuleR
 	policy := policyContext.Policy
 	newResource := policyContext.NewResource
 	oldResource := policyContext.OldResource
-	admissionInfo := policyContext.AdmissionInfo
+	admissionInfo := policyContext.matchInfo()
 	ctx := policyContext.JSONContext
 	resCache := policyContext.ResourceCache
 	excludeGroupRole := policyContext.ExcludeGroupRole
@@ -73,6 +78,7 @@ func filterRule(rule kyverno.Rule, policyContext *PolicyContext) *response.RuleR
 
 	logger := log.Log.WithName("Generate").WithValues("policy", policy.Name,
 		"kind", newResource.GetKind(), "namespace", newResource.GetNamespace(), "name", newResource.GetName())
+	logger = policyContext.masker().Logger(policyContext.logger(logger))
 
 	if err := MatchesResourceDescription(newResource, rule, admissionInfo, excludeGroupRole, namespaceLabels); err != nil {
 
@@ -92,6 +98,18 @@ func filterRule(rule kyverno.Rule, policyContext *PolicyContext) *response.RuleR
 		return nil
 	}
 
+	if ok, err := MatchesForEachNamespace(rule, newResource); !ok {
+		if err != nil {
+			logger.Error(err, "failed to match the forEachNamespace selector", "rule", rule.Name)
+		}
+		return nil
+	}
+
+	// the generate requests are only created for the rules inside of their time windows
+	if apply, _ := checkTimeWindows(logger, rule, policyContext, "Generation"); !apply {
+		return nil
+	}
+
 	policyContext.JSONContext.Checkpoint()
 	defer policyContext.JSONContext.Restore()
 
@@ -124,3 +142,22 @@ func filterRule(rule kyverno.Rule, policyContext *PolicyContext) *response.RuleR
 		},
 	}
 }
+
+// MatchesForEachNamespace checks if the namespace matches the forEachNamespace selector of the
+// generate rule, the rules without forEachNamespace match all the resources
+func MatchesForEachNamespace(rule kyverno.Rule, namespace unstructured.Unstructured) (bool, error) {
+	if !rule.IsForEachNamespace() {
+		return true, nil
+	}
+
+	if namespace.GetKind() != "Namespace" {
+		return false, nil
+	}
+
+	selector := rule.Generation.ForEachNamespace.Selector
+	if selector == nil {
+		return false, nil
+	}
+
+	return checkSelector(selector.DeepCopy(), namespace.GetLabels())
+}
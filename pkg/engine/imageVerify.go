@@ -9,7 +9,8 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/context"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
-	"github.com/minio/minio/pkg/wildcard"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	imageutils "github.com/kyverno/kyverno/pkg/utils/image"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"time"
 )
@@ -25,6 +26,7 @@ func VerifyAndPatchImages(policyContext *PolicyContext) (resp *response.EngineRe
 	patchedResource := policyContext.NewResource
 	logger := log.Log.WithName("EngineVerifyImages").WithValues("policy", policy.Name,
 		"kind", patchedResource.GetKind(), "namespace", patchedResource.GetNamespace(), "name", patchedResource.GetName())
+	logger = policyContext.logger(logger)
 
 	if ManagedPodResource(policy, patchedResource) {
 		logger.V(4).Info("container images for pods managed by workload controllers are already verified", "policy", policy.GetName())
@@ -51,23 +53,40 @@ func VerifyAndPatchImages(policyContext *PolicyContext) (resp *response.EngineRe
 			continue
 		}
 
+		if apply, ruleResp := checkDeleting(logger, rule, policyContext, utils.Validation.String()); !apply {
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			continue
+		}
+
+		if apply, ruleResp := checkTimeWindows(logger, rule, policyContext, utils.Validation.String()); !apply {
+			if ruleResp != nil {
+				resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			}
+			continue
+		}
+
+		if apply, ruleResp := checkExceptions(logger, rule, policyContext, utils.Validation.String()); !apply {
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			continue
+		}
+
 		policyContext.JSONContext.Restore()
 		for _, imageVerify := range rule.VerifyImages {
-			verifyAndPatchImages(logger, &rule, imageVerify, images.Containers, resp)
-			verifyAndPatchImages(logger, &rule, imageVerify, images.InitContainers, resp)
+			verifyAndPatchImages(logger, policyContext.JSONContext, &rule, imageVerify, images.Containers, resp)
+			verifyAndPatchImages(logger, policyContext.JSONContext, &rule, imageVerify, images.InitContainers, resp)
 		}
 	}
 
 	return
 }
 
-func verifyAndPatchImages(logger logr.Logger, rule *v1.Rule, imageVerify *v1.ImageVerification, images map[string]*context.ImageInfo, resp *response.EngineResponse) {
+func verifyAndPatchImages(logger logr.Logger, ctx *context.Context, rule *v1.Rule, imageVerify *v1.ImageVerification, images map[string]*context.ImageInfo, resp *response.EngineResponse) {
 	imagePattern := imageVerify.Image
-	key := imageVerify.Key
+	opts := verifyOptions(imageVerify)
 
 	for _, imageInfo := range images {
 		image := imageInfo.String()
-		if !wildcard.Match(imagePattern, image) {
+		if !imageutils.Match(imagePattern, imageInfo.Info()) {
 			logger.V(4).Info("image does not match pattern", "image", image, "pattern", imagePattern)
 			continue
 		}
@@ -81,9 +100,18 @@ func verifyAndPatchImages(logger logr.Logger, rule *v1.Rule, imageVerify *v1.Ima
 		}
 
 		start := time.Now()
-		digest, err := cosign.Verify(image, []byte(key), logger)
+		var severity string
+		digest, err := cosign.Verify(image, opts, logger)
+		if err == nil && len(imageVerify.Attestations) > 0 {
+			severity, err = verifyAttestations(logger, ctx, image, imageVerify.Attestations, opts.Keys)
+		}
+
+		if severity != "" {
+			ruleResp.Properties = map[string]string{response.RulePropertySeverity: severity}
+		}
+
 		if err != nil {
-			logger.Info("failed to verify image", "image", image, "key", key, "error", err, "duration", time.Since(start).Seconds())
+			logger.Info("failed to verify image", "image", image, "keys", len(opts.Keys), "subject", opts.Subject, "error", err, "duration", time.Since(start).Seconds())
 			ruleResp.Success = false
 			ruleResp.Message = fmt.Sprintf("image verification failed for %s: %v", image, err)
 		} else {
@@ -107,6 +135,120 @@ func verifyAndPatchImages(logger logr.Logger, rule *v1.Rule, imageVerify *v1.Ima
 	}
 }
 
+// verifyAttestations checks that the image has an attestation signed with one of the keys satisfying
+// the conditions for each of the predicate types. It returns the highest severity computed from the
+// attestations, the severity of the verified attestation or of the attestations failing the conditions,
+// or an empty severity if none is computed.
+func verifyAttestations(logger logr.Logger, ctx *context.Context, image string, attestations []v1.Attestation, keys []string) (string, error) {
+	statements, err := cosign.FetchAttestations(image, keys, logger)
+	if err != nil {
+		return "", err
+	}
+
+	var severity string
+	for _, attestation := range attestations {
+		var found bool
+		var verified bool
+		var predicateSeverity string
+		for _, statement := range statements {
+			if statement.PredicateType != attestation.PredicateType {
+				continue
+			}
+
+			found = true
+			if verified, err = checkAttestation(logger, ctx, statement, attestation); err != nil {
+				return severity, err
+			}
+
+			statementSeverity := attestationSeverity(logger, statement, attestation)
+			if verified {
+				predicateSeverity = statementSeverity
+				break
+			}
+
+			predicateSeverity = higherSeverity(predicateSeverity, statementSeverity)
+		}
+
+		severity = higherSeverity(severity, predicateSeverity)
+		if !found {
+			return severity, fmt.Errorf("no signed attestation of predicate type %s", attestation.PredicateType)
+		}
+
+		if !verified {
+			return severity, fmt.Errorf("the attestations of predicate type %s do not satisfy the conditions", attestation.PredicateType)
+		}
+	}
+
+	return severity, nil
+}
+
+// attestationSeverity returns the severity computed from the predicate of the in-toto statement, or an empty
+// severity if the attestation has no severityFrom or the score is missing or is not a number
+func attestationSeverity(logger logr.Logger, statement cosign.Statement, attestation v1.Attestation) string {
+	if attestation.SeverityFrom == nil {
+		return ""
+	}
+
+	severity, ok, err := statement.Severity(*attestation.SeverityFrom)
+	if err != nil {
+		logger.Error(err, "failed to compute the severity of the attestation", "predicateType", attestation.PredicateType)
+		return ""
+	}
+
+	if !ok {
+		logger.V(3).Info("no score in the attestation, using the severity of the policy", "predicateType", attestation.PredicateType, "jmesPath", attestation.SeverityFrom.JMESPath)
+		return ""
+	}
+
+	return severity
+}
+
+// higherSeverity returns the higher of the two severities, an empty severity is the lowest
+func higherSeverity(severity, other string) string {
+	if v1.SeverityLevel(other) > v1.SeverityLevel(severity) {
+		return other
+	}
+
+	return severity
+}
+
+// checkAttestation evaluates the conditions of the attestation on the in-toto statement
+func checkAttestation(logger logr.Logger, ctx *context.Context, statement cosign.Statement, attestation v1.Attestation) (bool, error) {
+	if attestation.Conditions == nil {
+		return true, nil
+	}
+
+	if err := ctx.AddAttestation(statement); err != nil {
+		return false, fmt.Errorf("failed to add the attestation to the context: %v", err)
+	}
+
+	conditions, err := copyConditions(attestation.Conditions)
+	if err != nil {
+		return false, fmt.Errorf("invalid conditions of predicate type %s: %v", attestation.PredicateType, err)
+	}
+
+	return variables.EvaluateConditions(logger, ctx, conditions, true), nil
+}
+
+// verifyOptions returns the options of the verification of the images, the keyless signatures
+// are verified if there is no key
+func verifyOptions(imageVerify *v1.ImageVerification) cosign.Options {
+	var keys []string
+	if imageVerify.Key != "" {
+		keys = append(keys, imageVerify.Key)
+	}
+
+	keys = append(keys, imageVerify.Keys...)
+	return cosign.Options{
+		Keys:             keys,
+		Count:            imageVerify.Count,
+		Subject:          imageVerify.Subject,
+		Roots:            imageVerify.Roots,
+		Rekor:            imageVerify.Rekor,
+		ImagePullSecrets: imageVerify.ImagePullSecrets,
+	}
+}
+
 func makeAddDigestPatch(imageInfo *context.ImageInfo, digest string) ([]byte, error) {
 	var patch = make(map[string]interface{})
 	patch["op"] = "replace"
@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/cosign"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_CheckAttestation(t *testing.T) {
+	statement := cosign.Statement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: "https://slsa.dev/provenance/v0.1",
+		Predicate:     map[string]interface{}{"builder": map[string]interface{}{"id": "https://github.com/acme/builder"}},
+	}
+
+	rawAttestation := []byte(`{
+		"predicateType": "https://slsa.dev/provenance/v0.1",
+		"conditions": {"all": [{"key": "{{attestation.predicate.builder.id}}", "operator": "Equals", "value": "https://github.com/acme/*"}]}
+	}`)
+	var attestation kyverno.Attestation
+	assert.NilError(t, json.Unmarshal(rawAttestation, &attestation))
+
+	ctx := context.NewContext()
+	verified, err := checkAttestation(log.Log, ctx, statement, attestation)
+	assert.NilError(t, err)
+	assert.Assert(t, verified)
+
+	statement.Predicate = map[string]interface{}{"builder": map[string]interface{}{"id": "https://ci.example.com"}}
+	verified, err = checkAttestation(log.Log, ctx, statement, attestation)
+	assert.NilError(t, err)
+	assert.Assert(t, !verified)
+
+	// an attestation without conditions only requires the predicate type
+	verified, err = checkAttestation(log.Log, ctx, statement, kyverno.Attestation{PredicateType: statement.PredicateType})
+	assert.NilError(t, err)
+	assert.Assert(t, verified)
+}
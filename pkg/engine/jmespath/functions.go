@@ -1,14 +1,21 @@
 package jmespath
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"path"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	gojmespath "github.com/jmespath/go-jmespath"
+	"github.com/kyverno/kyverno/pkg/utils/image"
+	"sigs.k8s.io/yaml"
 )
 
 var (
@@ -40,6 +47,19 @@ var (
 	regexReplaceAllLiteral = "regex_replace_all_literal"
 	regexMatch             = "regex_match"
 	labelMatch             = "label_match"
+	base64Decode           = "base64_decode"
+	base64Encode           = "base64_encode"
+	parseJson              = "parse_json"
+	compareSemver          = "compare_semver"
+	lookupIndex            = "lookup_index"
+	parseImage             = "parse_image"
+	parseYaml              = "parse_yaml"
+	semverCompare          = "semver_compare"
+	timeSince              = "time_since"
+	cidrContains           = "cidr_contains"
+	pathCanonicalize       = "path_canonicalize"
+	pathBase               = "path_base"
+	pathDir                = "path_dir"
 )
 
 const errorPrefix = "JMESPath function '%s': "
@@ -156,6 +176,115 @@ func getFunctions() []*gojmespath.FunctionEntry {
 			},
 			Handler: jpLabelMatch,
 		},
+		{
+			Name: base64Decode,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpBase64Decode,
+		},
+		{
+			Name: base64Encode,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpBase64Encode,
+		},
+		{
+			// Parses a JSON string (param1), the result can be used in further expressions
+			Name: parseJson,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpParseJson,
+		},
+		{
+			// Compares semantic versions (param1, param2), returns -1, 0 or 1
+			Name: compareSemver,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpCompareSemver,
+		},
+		{
+			// Returns the references of the resources with a value (param2) in an inventory index (param1)
+			Name: lookupIndex,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString, JpNumber}},
+			},
+			Handler: jpLookupIndex,
+		},
+		{
+			// Parses an image reference (param1), returns its registry, name, path, tag and digest as in the images variable
+			Name: parseImage,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpParseImage,
+		},
+		{
+			// Parses a YAML string (param1), the result can be used in further expressions
+			Name: parseYaml,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpParseYaml,
+		},
+		{
+			// Checks if a semantic version (param1) satisfies a range (param2), e.g. ">=1.19.0 <1.22.0 || >=1.24.0"
+			Name: semverCompare,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpSemverCompare,
+		},
+		{
+			// Returns the duration between two timestamps (param2, param3) in a layout (param1).
+			// The layout defaults to RFC 3339 if empty, and the end to the current time if empty.
+			Name: timeSince,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpTimeSince,
+		},
+		{
+			// Checks if a CIDR block (param1) contains an IP address (param2)
+			Name: cidrContains,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpCidrContains,
+		},
+		{
+			// Returns the shortest path equivalent to a slash separated path (param1)
+			Name: pathCanonicalize,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpPathCanonicalize,
+		},
+		{
+			// Returns the last element of a slash separated path (param1)
+			Name: pathBase,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpPathBase,
+		},
+		{
+			// Returns all but the last element of a slash separated path (param1)
+			Name: pathDir,
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+			},
+			Handler: jpPathDir,
+		},
 	}
 
 }
@@ -385,6 +514,233 @@ func jpLabelMatch(arguments []interface{}) (interface{}, error) {
 	return true, nil
 }
 
+func jpBase64Decode(arguments []interface{}) (interface{}, error) {
+	var err error
+	str, err := validateArg(base64Decode, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(str.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, base64Decode, err.Error())
+	}
+
+	return string(decoded), nil
+}
+
+func jpBase64Encode(arguments []interface{}) (interface{}, error) {
+	var err error
+	str, err := validateArg(base64Encode, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(str.String())), nil
+}
+
+func jpParseJson(arguments []interface{}) (interface{}, error) {
+	var err error
+	str, err := validateArg(parseJson, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(str.String()), &data); err != nil {
+		return nil, fmt.Errorf(genericError, parseJson, err.Error())
+	}
+
+	return data, nil
+}
+
+func jpCompareSemver(arguments []interface{}) (interface{}, error) {
+	var err error
+	a, err := validateArg(compareSemver, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := validateArg(compareSemver, arguments, 1, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	v1, err := parseSemver(a.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, compareSemver, err.Error())
+	}
+
+	v2, err := parseSemver(b.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, compareSemver, err.Error())
+	}
+
+	return v1.compare(v2), nil
+}
+
+func jpParseImage(arguments []interface{}) (interface{}, error) {
+	var err error
+	ref, err := validateArg(parseImage, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := image.Parse(ref.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, parseImage, err.Error())
+	}
+
+	// the components are converted to a map with the same keys as the images variable
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf(genericError, parseImage, err.Error())
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf(genericError, parseImage, err.Error())
+	}
+
+	return data, nil
+}
+
+func jpParseYaml(arguments []interface{}) (interface{}, error) {
+	var err error
+	str, err := validateArg(parseYaml, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	// the YAML is converted to JSON so that the numbers are float64 as for parse_json
+	raw, err := yaml.YAMLToJSON([]byte(str.String()))
+	if err != nil {
+		return nil, fmt.Errorf(genericError, parseYaml, err.Error())
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf(genericError, parseYaml, err.Error())
+	}
+
+	return data, nil
+}
+
+func jpSemverCompare(arguments []interface{}) (interface{}, error) {
+	var err error
+	version, err := validateArg(semverCompare, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	versionRange, err := validateArg(semverCompare, arguments, 1, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseSemver(version.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, semverCompare, err.Error())
+	}
+
+	satisfied, err := satisfiesSemverRange(v, versionRange.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, semverCompare, err.Error())
+	}
+
+	return satisfied, nil
+}
+
+func jpTimeSince(arguments []interface{}) (interface{}, error) {
+	var err error
+	layout, err := validateArg(timeSince, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	ts1, err := validateArg(timeSince, arguments, 1, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	ts2, err := validateArg(timeSince, arguments, 2, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	l := layout.String()
+	if l == "" {
+		l = time.RFC3339
+	}
+
+	start, err := time.Parse(l, ts1.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, timeSince, err.Error())
+	}
+
+	end := time.Now()
+	if ts2.String() != "" {
+		end, err = time.Parse(l, ts2.String())
+		if err != nil {
+			return nil, fmt.Errorf(genericError, timeSince, err.Error())
+		}
+	}
+
+	return end.Sub(start).String(), nil
+}
+
+func jpCidrContains(arguments []interface{}) (interface{}, error) {
+	var err error
+	cidr, err := validateArg(cidrContains, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, err := validateArg(cidrContains, arguments, 1, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	_, network, err := net.ParseCIDR(cidr.String())
+	if err != nil {
+		return nil, fmt.Errorf(genericError, cidrContains, err.Error())
+	}
+
+	address := net.ParseIP(ip.String())
+	if address == nil {
+		return nil, fmt.Errorf(genericError, cidrContains, fmt.Sprintf("invalid IP address %s", ip.String()))
+	}
+
+	return network.Contains(address), nil
+}
+
+func jpPathCanonicalize(arguments []interface{}) (interface{}, error) {
+	str, err := validateArg(pathCanonicalize, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Clean(str.String()), nil
+}
+
+func jpPathBase(arguments []interface{}) (interface{}, error) {
+	str, err := validateArg(pathBase, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Base(str.String()), nil
+}
+
+func jpPathDir(arguments []interface{}) (interface{}, error) {
+	str, err := validateArg(pathDir, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Dir(str.String()), nil
+}
+
 // InterfaceToString casts an interface to a string type
 func ifaceToString(iface interface{}) (string, error) {
 	switch iface.(type) {
@@ -406,7 +762,7 @@ func ifaceToString(iface interface{}) (string, error) {
 func validateArg(f string, arguments []interface{}, index int, expectedType reflect.Kind) (reflect.Value, error) {
 	arg := reflect.ValueOf(arguments[index])
 	if arg.Type().Kind() != expectedType {
-		return reflect.Value{}, fmt.Errorf(invalidArgumentTypeError, equalFold, index+1, expectedType.String())
+		return reflect.Value{}, fmt.Errorf(invalidArgumentTypeError, f, index+1, expectedType.String())
 	}
 
 	return arg, nil
@@ -2,7 +2,9 @@ package jmespath
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 )
@@ -301,3 +303,383 @@ func Test_labelMatch(t *testing.T) {
 	}
 
 }
+
+func Test_base64Decode(t *testing.T) {
+	testCases := []struct {
+		query          string
+		expectedResult interface{}
+		expectedErr    bool
+	}{
+		{query: "base64_decode('aGVsbG8gd29ybGQ=')", expectedResult: "hello world"},
+		{query: "base64_decode('')", expectedResult: ""},
+		{query: "base64_decode('not base64!')", expectedErr: true},
+		{query: "base64_decode('aGVsbG8')", expectedErr: true},
+		{query: "base64_decode(`1`)", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.query)
+			continue
+		}
+
+		assert.NilError(t, err, tc.query)
+		assert.Equal(t, result, tc.expectedResult, tc.query)
+	}
+}
+
+func Test_base64Encode(t *testing.T) {
+	testCases := []struct {
+		query          string
+		expectedResult interface{}
+		expectedErr    bool
+	}{
+		{query: "base64_encode('hello world')", expectedResult: "aGVsbG8gd29ybGQ="},
+		{query: "base64_encode('')", expectedResult: ""},
+		{query: "base64_decode(base64_encode('{\"a\": 1}'))", expectedResult: `{"a": 1}`},
+		{query: "base64_encode(`true`)", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.query)
+			continue
+		}
+
+		assert.NilError(t, err, tc.query)
+		assert.Equal(t, result, tc.expectedResult, tc.query)
+	}
+}
+
+func Test_parseJson(t *testing.T) {
+	data := map[string]interface{}{
+		"annotation": `{"replicas": 3, "tier": "backend", "ports": [80, 443]}`,
+	}
+
+	testCases := []struct {
+		query          string
+		expectedResult interface{}
+		expectedErr    bool
+	}{
+		{query: "parse_json(annotation).tier", expectedResult: "backend"},
+		{query: "parse_json(annotation).replicas", expectedResult: 3.0},
+		{query: "parse_json(annotation).ports[1]", expectedResult: 443.0},
+		{query: "length(parse_json(annotation).ports)", expectedResult: 2.0},
+		// the absent keys are not found, as in the other expressions
+		{query: "parse_json(annotation).missing", expectedErr: true},
+		{query: "parse_json('\"text\"')", expectedResult: "text"},
+		{query: "parse_json('{\"replicas\": ')", expectedErr: true},
+		{query: "parse_json('')", expectedErr: true},
+		{query: "parse_json(`1`)", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search(data)
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.query)
+			continue
+		}
+
+		assert.NilError(t, err, tc.query)
+		assert.DeepEqual(t, result, tc.expectedResult)
+	}
+}
+
+func Test_compareSemver(t *testing.T) {
+	testCases := []struct {
+		a, b           string
+		expectedResult int
+		expectedErr    bool
+	}{
+		{a: "1.19.0", b: "1.19.0", expectedResult: 0},
+		{a: "1.19.1", b: "1.19.0", expectedResult: 1},
+		{a: "1.18.20", b: "1.19.0", expectedResult: -1},
+		{a: "2.0.0", b: "1.99.99", expectedResult: 1},
+		{a: "v1.19.0", b: "1.19.0", expectedResult: 0},
+		{a: "1.19", b: "1.19.0", expectedResult: 0},
+		{a: "1.20", b: "1.19.4", expectedResult: 1},
+		{a: "1.19.0+build.1", b: "1.19.0+build.2", expectedResult: 0},
+		{a: "1.19.0-alpha", b: "1.19.0", expectedResult: -1},
+		{a: "1.19.0", b: "1.19.0-rc.1", expectedResult: 1},
+		{a: "1.0.0-alpha", b: "1.0.0-alpha.1", expectedResult: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha.beta", expectedResult: -1},
+		{a: "1.0.0-beta.2", b: "1.0.0-beta.11", expectedResult: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0-beta.11", expectedResult: 1},
+		{a: "latest", b: "1.19.0", expectedErr: true},
+		{a: "1.19.0", b: "", expectedErr: true},
+		{a: "1.19.0.1", b: "1.19.0", expectedErr: true},
+		{a: "1.x", b: "1.19.0", expectedErr: true},
+		{a: "1.19.0-", b: "1.19.0", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(fmt.Sprintf("compare_semver('%s', '%s')", tc.a, tc.b))
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, "%s %s", tc.a, tc.b)
+			continue
+		}
+
+		assert.NilError(t, err, "%s %s", tc.a, tc.b)
+		assert.Equal(t, result, tc.expectedResult, "%s %s", tc.a, tc.b)
+	}
+}
+
+func Test_parseYaml(t *testing.T) {
+	data := map[string]interface{}{
+		"config": "replicas: 3\ntier: backend\nports:\n- 80\n- 443\n",
+	}
+
+	testCases := []struct {
+		query          string
+		expectedResult interface{}
+		expectedErr    bool
+	}{
+		{query: "parse_yaml(config).tier", expectedResult: "backend"},
+		{query: "parse_yaml(config).replicas", expectedResult: 3.0},
+		{query: "parse_yaml(config).ports[1]", expectedResult: 443.0},
+		{query: "parse_yaml('{\"a\": \"b\"}').a", expectedResult: "b"},
+		{query: "parse_yaml('a: [')", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search(data)
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.query)
+			continue
+		}
+
+		assert.NilError(t, err, tc.query)
+		assert.DeepEqual(t, result, tc.expectedResult)
+	}
+}
+
+func Test_semverCompare(t *testing.T) {
+	testCases := []struct {
+		version, versionRange string
+		expectedResult        bool
+		expectedErr           bool
+	}{
+		{version: "1.19.0", versionRange: "1.19.0", expectedResult: true},
+		{version: "1.19.0", versionRange: "=1.19", expectedResult: true},
+		{version: "1.19.0", versionRange: "!=1.19.0", expectedResult: false},
+		{version: "1.20.3", versionRange: ">=1.19.0 <1.22.0", expectedResult: true},
+		{version: "1.22.0", versionRange: ">=1.19.0 <1.22.0", expectedResult: false},
+		{version: "1.24.1", versionRange: ">=1.19.0 <1.22.0 || >=1.24.0", expectedResult: true},
+		{version: "v1.18.9", versionRange: ">1.18.9 || <=1.17", expectedResult: false},
+		{version: "1.20.0-rc.1", versionRange: "<1.20.0", expectedResult: true},
+		{version: "1.19.0", versionRange: "", expectedErr: true},
+		{version: "1.19.0", versionRange: ">=1.19.0 ||", expectedErr: true},
+		{version: "1.19.0", versionRange: "~1.19", expectedErr: true},
+		{version: "latest", versionRange: ">=1.19.0", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(fmt.Sprintf("semver_compare('%s', '%s')", tc.version, tc.versionRange))
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, "%s %s", tc.version, tc.versionRange)
+			continue
+		}
+
+		assert.NilError(t, err, "%s %s", tc.version, tc.versionRange)
+		assert.Equal(t, result, tc.expectedResult, "%s %s", tc.version, tc.versionRange)
+	}
+}
+
+func Test_timeSince(t *testing.T) {
+	testCases := []struct {
+		query          string
+		expectedResult interface{}
+		expectedErr    bool
+	}{
+		{query: "time_since('', '2022-03-01T10:00:00Z', '2022-03-01T12:30:00Z')", expectedResult: "2h30m0s"},
+		{query: "time_since('2006-01-02', '2022-03-01', '2022-03-08')", expectedResult: "168h0m0s"},
+		{query: "time_since('', '2022-03-01T12:00:00Z', '2022-03-01T10:00:00Z')", expectedResult: "-2h0m0s"},
+		{query: "time_since('', 'yesterday', '')", expectedErr: true},
+		{query: "time_since('', '2022-03-01T10:00:00Z', 'now')", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.query)
+			continue
+		}
+
+		assert.NilError(t, err, tc.query)
+		assert.Equal(t, result, tc.expectedResult, tc.query)
+	}
+
+	// the end defaults to the current time
+	query, err := New("time_since('', '2022-03-01T10:00:00Z', '')")
+	assert.NilError(t, err)
+	result, err := query.Search("")
+	assert.NilError(t, err)
+	duration, err := time.ParseDuration(result.(string))
+	assert.NilError(t, err)
+	assert.Assert(t, duration > 0)
+}
+
+func Test_cidrContains(t *testing.T) {
+	testCases := []struct {
+		cidr, ip       string
+		expectedResult bool
+		expectedErr    bool
+	}{
+		{cidr: "10.0.0.0/8", ip: "10.1.2.3", expectedResult: true},
+		{cidr: "10.0.0.0/8", ip: "192.168.1.1", expectedResult: false},
+		{cidr: "192.168.1.0/24", ip: "192.168.1.255", expectedResult: true},
+		{cidr: "2001:db8::/32", ip: "2001:db8::1", expectedResult: true},
+		{cidr: "2001:db8::/32", ip: "10.1.2.3", expectedResult: false},
+		{cidr: "10.0.0.0", ip: "10.1.2.3", expectedErr: true},
+		{cidr: "10.0.0.0/8", ip: "10.1.2", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(fmt.Sprintf("cidr_contains('%s', '%s')", tc.cidr, tc.ip))
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, "%s %s", tc.cidr, tc.ip)
+			continue
+		}
+
+		assert.NilError(t, err, "%s %s", tc.cidr, tc.ip)
+		assert.Equal(t, result, tc.expectedResult, "%s %s", tc.cidr, tc.ip)
+	}
+}
+
+func Test_pathFunctions(t *testing.T) {
+	testCases := []struct {
+		query          string
+		expectedResult string
+	}{
+		{query: "path_canonicalize('/var/lib/../run//kyverno/')", expectedResult: "/var/run/kyverno"},
+		{query: "path_canonicalize('')", expectedResult: "."},
+		{query: "path_base('/etc/kubernetes/admin.conf')", expectedResult: "admin.conf"},
+		{query: "path_base('/etc/kubernetes/')", expectedResult: "kubernetes"},
+		{query: "path_dir('/etc/kubernetes/admin.conf')", expectedResult: "/etc/kubernetes"},
+		{query: "path_dir('admin.conf')", expectedResult: "."},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(tc.query)
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		assert.NilError(t, err, tc.query)
+		assert.Equal(t, result, tc.expectedResult, tc.query)
+	}
+}
+
+type fakeIndexLookup map[string][]interface{}
+
+func (f fakeIndexLookup) LookupIndex(name, value string) ([]interface{}, error) {
+	if name != "node-ports" {
+		return nil, fmt.Errorf("inventory index %s is not configured", name)
+	}
+	return f[value], nil
+}
+
+func Test_lookupIndex(t *testing.T) {
+	query, err := New("lookup_index('node-ports', `30080`)")
+	assert.NilError(t, err)
+
+	// no inventory
+	RegisterIndexLookup(nil)
+	_, err = query.Search("")
+	assert.Error(t, err, "JMESPath function 'lookup_index': inventory indexes are not available")
+
+	RegisterIndexLookup(fakeIndexLookup{"30080": {map[string]interface{}{"kind": "Service", "namespace": "team-a", "name": "web"}}})
+	defer RegisterIndexLookup(nil)
+
+	// numbers are looked up by their string value
+	result, err := query.Search("")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, []interface{}{map[string]interface{}{"kind": "Service", "namespace": "team-a", "name": "web"}})
+
+	query, err = New("lookup_index('node-ports', '30080')[].name")
+	assert.NilError(t, err)
+	result, err = query.Search("")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, []interface{}{"web"})
+
+	// no conflicts
+	query, err = New("length(lookup_index('node-ports', '30081'))")
+	assert.NilError(t, err)
+	result, err = query.Search("")
+	assert.NilError(t, err)
+	assert.Equal(t, result, 0.0)
+
+	query, err = New("lookup_index('ingress-hosts', 'example.com')")
+	assert.NilError(t, err)
+	_, err = query.Search("")
+	assert.Error(t, err, "JMESPath function 'lookup_index': inventory index ingress-hosts is not configured")
+}
+
+func Test_parseImage(t *testing.T) {
+	testCases := []struct {
+		image          string
+		expectedResult map[string]interface{}
+		expectedErr    bool
+	}{
+		{
+			image:          "nginx",
+			expectedResult: map[string]interface{}{"registry": "docker.io", "name": "nginx", "path": "nginx", "tag": "latest"},
+		},
+		{
+			image:          "Registry.Example.COM:5000/Team/App:v1",
+			expectedResult: map[string]interface{}{"registry": "registry.example.com:5000", "name": "App", "path": "Team/App", "tag": "v1"},
+		},
+		{
+			image: "ghcr.io/org/app@sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10",
+			expectedResult: map[string]interface{}{"registry": "ghcr.io", "name": "app", "path": "org/app",
+				"digest": "sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10"},
+		},
+		{image: `C:\images\app`, expectedErr: true},
+		{image: "nginx:", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		query, err := New(fmt.Sprintf("parse_image('%s')", tc.image))
+		assert.NilError(t, err)
+
+		result, err := query.Search("")
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.image)
+			continue
+		}
+
+		assert.NilError(t, err, tc.image)
+		assert.DeepEqual(t, result, tc.expectedResult)
+	}
+
+	query, err := New("parse_image(image).registry")
+	assert.NilError(t, err)
+	result, err := query.Search(map[string]interface{}{"image": "GHCR.io/org/app:v1"})
+	assert.NilError(t, err)
+	assert.Equal(t, result, "ghcr.io")
+}
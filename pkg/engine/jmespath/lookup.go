@@ -0,0 +1,61 @@
+package jmespath
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// IndexLookup looks up the resources with a value in an inventory index
+type IndexLookup interface {
+	// LookupIndex returns the references of the resources with the value in the named index
+	LookupIndex(name, value string) ([]interface{}, error)
+}
+
+var (
+	indexLookupMux sync.RWMutex
+	indexLookup    IndexLookup
+)
+
+// RegisterIndexLookup sets the inventory used by the lookup_index function
+func RegisterIndexLookup(lookup IndexLookup) {
+	indexLookupMux.Lock()
+	defer indexLookupMux.Unlock()
+	indexLookup = lookup
+}
+
+func jpLookupIndex(arguments []interface{}) (interface{}, error) {
+	name, err := validateArg(lookupIndex, arguments, 0, reflect.String)
+	if err != nil {
+		return nil, err
+	}
+
+	var value string
+	switch v := arguments[1].(type) {
+	case string:
+		value = v
+	case float64:
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return nil, fmt.Errorf(invalidArgumentTypeError, lookupIndex, 2, "String or Number")
+	}
+
+	indexLookupMux.RLock()
+	lookup := indexLookup
+	indexLookupMux.RUnlock()
+
+	if lookup == nil {
+		return nil, fmt.Errorf(genericError, lookupIndex, "inventory indexes are not available")
+	}
+
+	refs, err := lookup.LookupIndex(name.String(), value)
+	if err != nil {
+		return nil, fmt.Errorf(genericError, lookupIndex, err.Error())
+	}
+
+	if refs == nil {
+		refs = []interface{}{}
+	}
+	return refs, nil
+}
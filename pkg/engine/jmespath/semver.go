@@ -0,0 +1,167 @@
+package jmespath
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var preReleaseIdentifier = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// semanticVersion is a parsed semantic version, see https://semver.org
+type semanticVersion struct {
+	numbers    [3]uint64
+	preRelease []string
+}
+
+// parseSemver parses a semantic version. An optional "v" prefix is allowed, and the minor and
+// patch versions can be omitted (e.g. image tags like "1.19"). Build metadata is ignored.
+func parseSemver(version string) (semanticVersion, error) {
+	var v semanticVersion
+	str := strings.TrimPrefix(version, "v")
+	if i := strings.Index(str, "+"); i != -1 {
+		str = str[:i]
+	}
+
+	if i := strings.Index(str, "-"); i != -1 {
+		v.preRelease = strings.Split(str[i+1:], ".")
+		for _, identifier := range v.preRelease {
+			if !preReleaseIdentifier.MatchString(identifier) {
+				return v, fmt.Errorf("invalid semantic version %q: invalid pre-release identifier %q", version, identifier)
+			}
+		}
+		str = str[:i]
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid semantic version %q: too many version numbers", version)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid semantic version %q: invalid version number %q", version, part)
+		}
+		v.numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 if the version is lower than, equal to or greater than the other version
+func (v semanticVersion) compare(other semanticVersion) int {
+	for i := range v.numbers {
+		if v.numbers[i] != other.numbers[i] {
+			return compareUint(v.numbers[i], other.numbers[i])
+		}
+	}
+
+	// a pre-release version has a lower precedence than the normal version
+	if len(v.preRelease) == 0 || len(other.preRelease) == 0 {
+		return compareUint(uint64(len(other.preRelease)), uint64(len(v.preRelease)))
+	}
+
+	for i := 0; i < len(v.preRelease) && i < len(other.preRelease); i++ {
+		if result := comparePreReleaseIdentifier(v.preRelease[i], other.preRelease[i]); result != 0 {
+			return result
+		}
+	}
+
+	return compareUint(uint64(len(v.preRelease)), uint64(len(other.preRelease)))
+}
+
+// comparePreReleaseIdentifier compares numeric identifiers numerically, and others lexically.
+// Numeric identifiers have a lower precedence than alphanumeric identifiers.
+func comparePreReleaseIdentifier(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case errA == nil && errB == nil:
+		return compareUint(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// semverRangeOperators are the operators of the version range terms, the longer operators come first
+var semverRangeOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// satisfiesSemverRange returns true if the version satisfies the range. A range is made of groups
+// separated by "||", a group is made of space separated terms which must all be satisfied,
+// e.g. ">=1.19.0 <1.22.0 || >=1.24.0". A term without an operator must be equal to the version.
+func satisfiesSemverRange(version semanticVersion, versionRange string) (bool, error) {
+	if strings.TrimSpace(versionRange) == "" {
+		return false, fmt.Errorf("invalid semantic version range %q: empty range", versionRange)
+	}
+
+	var satisfied bool
+	for _, group := range strings.Split(versionRange, "||") {
+		terms := strings.Fields(group)
+		if len(terms) == 0 {
+			return false, fmt.Errorf("invalid semantic version range %q: empty group", versionRange)
+		}
+
+		groupSatisfied := true
+		for _, term := range terms {
+			ok, err := satisfiesSemverTerm(version, term)
+			if err != nil {
+				return false, fmt.Errorf("invalid semantic version range %q: %v", versionRange, err)
+			}
+			groupSatisfied = groupSatisfied && ok
+		}
+
+		satisfied = satisfied || groupSatisfied
+	}
+
+	return satisfied, nil
+}
+
+func satisfiesSemverTerm(version semanticVersion, term string) (bool, error) {
+	operator := "="
+	for _, op := range semverRangeOperators {
+		if strings.HasPrefix(term, op) {
+			operator = op
+			term = strings.TrimPrefix(term, op)
+			break
+		}
+	}
+
+	other, err := parseSemver(term)
+	if err != nil {
+		return false, err
+	}
+
+	result := version.compare(other)
+	switch operator {
+	case ">=":
+		return result >= 0, nil
+	case "<=":
+		return result <= 0, nil
+	case "!=":
+		return result != 0, nil
+	case ">":
+		return result > 0, nil
+	case "<":
+		return result < 0, nil
+	default:
+		return result == 0, nil
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-
+	"sort"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -15,11 +15,16 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/variables"
 	"github.com/kyverno/kyverno/pkg/kyverno/store"
 	"github.com/kyverno/kyverno/pkg/resourcecache"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/dynamic/dynamiclister"
 )
 
 // LoadContext - Fetches and adds external data to the Context.
 func LoadContext(logger logr.Logger, contextEntries []kyverno.ContextEntry, resCache resourcecache.ResourceCache, ctx *PolicyContext, ruleName string) error {
+	ctx.contextResolutions = nil
 	if len(contextEntries) == 0 {
 		return nil
 	}
@@ -27,8 +32,8 @@ func LoadContext(logger logr.Logger, contextEntries []kyverno.ContextEntry, resC
 	policyName := ctx.Policy.Name
 	if store.GetMock() {
 		rule := store.GetPolicyRuleFromContext(policyName, ruleName)
-		if len(rule.Values) == 0 {
-			return fmt.Errorf("No values found for policy %s rule %s", policyName, ruleName)
+		if rule == nil || len(rule.Values) == 0 {
+			return fmt.Errorf("No values found for policy %s rule %s, pass the values of the context entries in the values file", policyName, ruleName)
 		}
 		variables := rule.Values
 
@@ -51,7 +56,7 @@ func LoadContext(logger logr.Logger, contextEntries []kyverno.ContextEntry, resC
 
 		for _, entry := range contextEntries {
 			if entry.ConfigMap != nil {
-				if err := loadConfigMap(logger, entry, lister, ctx.JSONContext); err != nil {
+				if err := loadConfigMap(logger, entry, lister, ctx); err != nil {
 					return err
 				}
 			} else if entry.APICall != nil {
@@ -67,7 +72,24 @@ func LoadContext(logger logr.Logger, contextEntries []kyverno.ContextEntry, resC
 func loadAPIData(logger logr.Logger, entry kyverno.ContextEntry, ctx *PolicyContext) error {
 	jsonData, err := fetchAPIData(logger, entry, ctx)
 	if err != nil {
-		return err
+		if entry.APICall.FailurePolicy != kyverno.Ignore {
+			return err
+		}
+
+		// the failed API calls with the Ignore failure policy bind the context entry to null
+		logger.V(2).Info("API call failed, binding the context entry to null", "contextEntry", entry.Name, "reason", err.Error())
+		ctx.setContextResolution(entry.Name, fmt.Sprintf("failed: %v, bound to null", err))
+		return addContextEntry(ctx, entry, nil)
+	}
+
+	// the response of a service is bound to the context entry
+	if entry.APICall.JMESPath == "" && entry.APICall.Service != nil {
+		var data interface{}
+		if err := json.Unmarshal(jsonData, &data); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON: %s, error: %v", string(jsonData), err)
+		}
+
+		return addContextEntry(ctx, entry, data)
 	}
 
 	if entry.APICall.JMESPath == "" {
@@ -89,20 +111,24 @@ func loadAPIData(logger logr.Logger, entry kyverno.ContextEntry, ctx *PolicyCont
 		return err
 	}
 
-	contextNamedData := make(map[string]interface{})
-	contextNamedData[entry.Name] = results
-	contextData, err := json.Marshal(contextNamedData)
-	if err != nil {
-		return fmt.Errorf("failed to marshall data %v for context entry %v: %v", contextNamedData, entry, err)
+	if err := addContextEntry(ctx, entry, results); err != nil {
+		return fmt.Errorf("failed to add JMESPath (%s) results to context, error: %v", entry.APICall.JMESPath, err)
 	}
 
-	err = ctx.JSONContext.AddJSON(contextData)
+	logger.Info("added APICall context entry", "data", map[string]interface{}{entry.Name: results})
+	return nil
+}
+
+// addContextEntry binds the data to the name of the context entry
+func addContextEntry(ctx *PolicyContext, entry kyverno.ContextEntry, data interface{}) error {
+	contextNamedData := make(map[string]interface{})
+	contextNamedData[entry.Name] = data
+	contextData, err := json.Marshal(contextNamedData)
 	if err != nil {
-		return fmt.Errorf("failed to add JMESPath (%s) results to context, error: %v", entry.APICall.JMESPath, err)
+		return fmt.Errorf("failed to marshall data %v for context entry %v: %v", contextNamedData, entry.Name, err)
 	}
 
-	logger.Info("added APICall context entry", "data", contextNamedData)
-	return nil
+	return ctx.JSONContext.AddJSON(contextData)
 }
 
 func applyJMESPath(jmesPath string, jsonData []byte) (interface{}, error) {
@@ -125,17 +151,48 @@ func fetchAPIData(log logr.Logger, entry kyverno.ContextEntry, ctx *PolicyContex
 		return nil, fmt.Errorf("missing APICall in context entry %s %v", entry.Name, entry.APICall)
 	}
 
+	if entry.APICall.Service != nil {
+		url, err := variables.SubstituteAll(log, ctx.JSONContext, entry.APICall.Service.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute variables in context entry %s %s: %v", entry.Name, entry.APICall.Service.URL, err)
+		}
+
+		urlStr := url.(string)
+		return cachedFetch(entry.APICall, "service:"+urlStr, func() ([]byte, error) {
+			return callService(entry.APICall, urlStr)
+		})
+	}
+
 	path, err := variables.SubstituteAll(log, ctx.JSONContext, entry.APICall.URLPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to substitute variables in context entry %s %s: %v", entry.Name, entry.APICall.URLPath, err)
 	}
 
 	pathStr := path.(string)
+	return cachedFetch(entry.APICall, "urlPath:"+pathStr, func() ([]byte, error) {
+		return fetchResources(ctx, entry, pathStr)
+	})
+}
+
+// fetchResources returns the resource or the list of resources of the URL path of the Kubernetes API
+func fetchResources(ctx *PolicyContext, entry kyverno.ContextEntry, pathStr string) ([]byte, error) {
 	p, err := NewAPIPath(pathStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build API path for %s %v: %v", entry.Name, entry.APICall, err)
 	}
 
+	// the resources cached by the informers of the API call cache are not fetched from the API server
+	if ctx.ResourceCache != nil {
+		if gc, ok := ctx.ResourceCache.GetAPICallCache(p.GroupVersionResource()); ok {
+			jsonData, err := loadCachedResources(gc, p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load cached resources with urlPath: %s: %v", p, err)
+			}
+
+			return jsonData, nil
+		}
+	}
+
 	var jsonData []byte
 	if p.Name != "" {
 		jsonData, err = loadResource(ctx, p)
@@ -154,7 +211,19 @@ func fetchAPIData(log logr.Logger, entry kyverno.ContextEntry, ctx *PolicyContex
 }
 
 func loadResourceList(ctx *PolicyContext, p *APIPath) ([]byte, error) {
-	l, err := ctx.Client.ListResource(p.Version, p.ResourceType, p.Namespace, nil)
+	if ctx.Client == nil {
+		return nil, fmt.Errorf("API client is not available")
+	}
+
+	var selector *metav1.LabelSelector
+	if p.LabelSelector != "" {
+		var err error
+		if selector, err = metav1.ParseToLabelSelector(p.LabelSelector); err != nil {
+			return nil, fmt.Errorf("invalid label selector %s: %v", p.LabelSelector, err)
+		}
+	}
+
+	l, err := ctx.Client.ListResource(p.Version, p.ResourceType, p.Namespace, selector)
 	if err != nil {
 		return nil, err
 	}
@@ -175,13 +244,78 @@ func loadResource(ctx *PolicyContext, p *APIPath) ([]byte, error) {
 	return r.MarshalJSON()
 }
 
-func loadConfigMap(logger logr.Logger, entry kyverno.ContextEntry, lister dynamiclister.Lister, ctx *context.Context) error {
-	data, err := fetchConfigMap(logger, entry, lister, ctx)
+// loadCachedResources returns the resource or the list of resources of the path from the API call cache,
+// the missing resources are not found as with the API server
+func loadCachedResources(gc resourcecache.GenericCache, p *APIPath) ([]byte, error) {
+	gvr := p.GroupVersionResource()
+	if p.Name != "" {
+		var obj *unstructured.Unstructured
+		var err error
+		if gc.IsNamespaced() {
+			obj, err = gc.NamespacedLister(p.Namespace).Get(p.Name)
+		} else {
+			obj, err = gc.Lister().Get(p.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return obj.MarshalJSON()
+	}
+
+	selector := labels.Everything()
+	if p.LabelSelector != "" {
+		var err error
+		if selector, err = labels.Parse(p.LabelSelector); err != nil {
+			return nil, fmt.Errorf("invalid label selector %s: %v", p.LabelSelector, err)
+		}
+	}
+
+	var objs []*unstructured.Unstructured
+	var err error
+	if gc.IsNamespaced() && p.Namespace != "" {
+		objs, err = gc.NamespacedLister(p.Namespace).List(selector)
+	} else {
+		objs, err = gc.Lister().List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the objects are sorted by namespace and name, as listed by the API server
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+	}}
+	for _, obj := range objs {
+		list.Items = append(list.Items, *obj)
+	}
+	if len(objs) > 0 {
+		list.SetKind(objs[0].GetKind() + "List")
+	}
+
+	return list.MarshalJSON()
+}
+
+func loadConfigMap(logger logr.Logger, entry kyverno.ContextEntry, lister dynamiclister.Lister, ctx *PolicyContext) error {
+	data, resolution, err := fetchConfigMap(logger, entry, lister, ctx.JSONContext)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve config map for context entry %s: %v", entry.Name, err)
 	}
 
-	err = ctx.AddJSON(data)
+	if resolution != "" {
+		ctx.setContextResolution(entry.Name, resolution)
+	}
+
+	err = ctx.JSONContext.AddJSON(data)
 	if err != nil {
 		return fmt.Errorf("failed to add config map for context entry %s: %v", entry.Name, err)
 	}
@@ -189,45 +323,92 @@ func loadConfigMap(logger logr.Logger, entry kyverno.ContextEntry, lister dynami
 	return nil
 }
 
-func fetchConfigMap(logger logr.Logger, entry kyverno.ContextEntry, lister dynamiclister.Lister, jsonContext *context.Context) ([]byte, error) {
-	contextData := make(map[string]interface{})
+// fetchConfigMap returns the context data of the first ConfigMap found among the ConfigMap
+// reference and its fallbacks, or null if none is found and the reference is optional.
+// For the references with fallbacks or optional, it also returns the resolution of the
+// entry, e.g. "prod/web-config: not found, prod/default-config: found".
+func fetchConfigMap(logger logr.Logger, entry kyverno.ContextEntry, lister dynamiclister.Lister, jsonContext *context.Context) ([]byte, string, error) {
+	references := []kyverno.ConfigMapFallback{{Name: entry.ConfigMap.Name, Namespace: entry.ConfigMap.Namespace}}
+	for _, fallback := range entry.ConfigMap.Fallbacks {
+		if fallback.Namespace == "" {
+			fallback.Namespace = entry.ConfigMap.Namespace
+		}
+		references = append(references, fallback)
+	}
 
-	name, err := variables.SubstituteAll(logger, jsonContext, entry.ConfigMap.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to substitute variables in context %s configMap.name %s: %v", entry.Name, entry.ConfigMap.Name, err)
+	var contextData interface{}
+	var tried []string
+	for _, reference := range references {
+		key, err := configMapKey(logger, entry, reference, jsonContext)
+		if err != nil {
+			return nil, "", err
+		}
+
+		obj, err := lister.Get(key)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, "", fmt.Errorf("failed to read configmap %s from cache: %v", key, err)
+			}
+
+			logger.V(4).Info("configmap not found", "contextEntry", entry.Name, "configmap", key)
+			tried = append(tried, key+": not found")
+			continue
+		}
+
+		tried = append(tried, key+": found")
+		unstructuredObj := obj.DeepCopy().Object
+
+		// update the unstructuredObj["data"] to delimit and split the string value (containing "\n") with "\n"
+		data, _ := unstructuredObj["data"].(map[string]interface{})
+		unstructuredObj["data"] = parseMultilineBlockBody(data)
+
+		// extract configmap data
+		contextData = map[string]interface{}{
+			"data":     unstructuredObj["data"],
+			"metadata": unstructuredObj["metadata"],
+		}
+		break
 	}
 
-	namespace, err := variables.SubstituteAll(logger, jsonContext, entry.ConfigMap.Namespace)
-	if err != nil {
-		return nil, fmt.Errorf("failed to substitute variables in context %s configMap.namespace %s: %v", entry.Name, entry.ConfigMap.Namespace, err)
+	if contextData == nil {
+		if !entry.ConfigMap.Optional {
+			return nil, "", fmt.Errorf("no configmap found: %s", strings.Join(tried, ", "))
+		}
+		tried = append(tried, "bound to null")
 	}
 
-	if namespace == "" {
-		namespace = "default"
+	var resolution string
+	if len(entry.ConfigMap.Fallbacks) > 0 || entry.ConfigMap.Optional {
+		resolution = strings.Join(tried, ", ")
 	}
 
-	key := fmt.Sprintf("%s/%s", namespace, name)
-	obj, err := lister.Get(key)
+	contextNamedData := make(map[string]interface{})
+	contextNamedData[entry.Name] = contextData
+	data, err := json.Marshal(contextNamedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read configmap %s/%s from cache: %v", namespace, name, err)
+		return nil, "", fmt.Errorf("failed to marshal configmap for context entry %s: %v", entry.Name, err)
 	}
 
-	unstructuredObj := obj.DeepCopy().Object
+	return data, resolution, nil
+}
 
-	// update the unstructuredObj["data"] to delimit and split the string value (containing "\n") with "\n"
-	unstructuredObj["data"] = parseMultilineBlockBody(unstructuredObj["data"].(map[string]interface{}))
+// configMapKey returns the "<namespace>/<name>" key of the ConfigMap reference, with the variables substituted
+func configMapKey(logger logr.Logger, entry kyverno.ContextEntry, reference kyverno.ConfigMapFallback, jsonContext *context.Context) (string, error) {
+	name, err := variables.SubstituteAll(logger, jsonContext, reference.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to substitute variables in context %s configMap.name %s: %v", entry.Name, reference.Name, err)
+	}
 
-	// extract configmap data
-	contextData["data"] = unstructuredObj["data"]
-	contextData["metadata"] = unstructuredObj["metadata"]
-	contextNamedData := make(map[string]interface{})
-	contextNamedData[entry.Name] = contextData
-	data, err := json.Marshal(contextNamedData)
+	namespace, err := variables.SubstituteAll(logger, jsonContext, reference.Namespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal configmap %s/%s: %v", namespace, name, err)
+		return "", fmt.Errorf("failed to substitute variables in context %s configMap.namespace %s: %v", entry.Name, reference.Namespace, err)
+	}
+
+	if namespace == "" {
+		namespace = "default"
 	}
 
-	return data, nil
+	return fmt.Sprintf("%s/%s", namespace, name), nil
 }
 
 // parseMultilineBlockBody recursively iterates through a map and updates its values in the following way
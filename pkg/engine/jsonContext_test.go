@@ -3,8 +3,16 @@ package engine
 import (
 	"bytes"
 	"encoding/json"
-	"gotest.tools/assert"
 	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func Test_parseMultilineBlockBody(t *testing.T) {
@@ -69,3 +77,204 @@ func Test_parseMultilineBlockBody(t *testing.T) {
 		}
 	}
 }
+
+func Test_LoadContext_ConfigMapFallbacks(t *testing.T) {
+	unmockStore(t)
+
+	configMaps := newFakeGenericCache(t, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, true,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web-config", "namespace": "prod"}, "data": {"replicas": "3"}}`,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "default-config", "namespace": "prod"}, "data": {"replicas": "1"}}`)
+	resCache := fakeResourceCache{caches: map[string]resourcecache.GenericCache{"ConfigMap": configMaps}}
+
+	testCases := []struct {
+		name       string
+		configMap  kyverno.ConfigMapReference
+		replicas   interface{}
+		resolution string
+		err        bool
+	}{
+		{
+			name:       "first hit",
+			configMap:  kyverno.ConfigMapReference{Name: "{{request.object.metadata.name}}-config", Namespace: "prod", Fallbacks: []kyverno.ConfigMapFallback{{Name: "default-config"}}},
+			replicas:   "3",
+			resolution: "prod/web-config: found",
+		},
+		{
+			name:       "fallback hit",
+			configMap:  kyverno.ConfigMapReference{Name: "api-config", Namespace: "prod", Fallbacks: []kyverno.ConfigMapFallback{{Name: "shared-config", Namespace: "platform"}, {Name: "default-config"}}},
+			replicas:   "1",
+			resolution: "prod/api-config: not found, platform/shared-config: not found, prod/default-config: found",
+		},
+		{
+			name:      "nothing found",
+			configMap: kyverno.ConfigMapReference{Name: "api-config", Namespace: "prod", Fallbacks: []kyverno.ConfigMapFallback{{Name: "shared-config"}}},
+			err:       true,
+		},
+		{
+			name:       "nothing found optional",
+			configMap:  kyverno.ConfigMapReference{Name: "api-config", Namespace: "prod", Fallbacks: []kyverno.ConfigMapFallback{{Name: "shared-config"}}, Optional: true},
+			replicas:   nil,
+			resolution: "prod/api-config: not found, prod/shared-config: not found, bound to null",
+		},
+	}
+
+	for _, tc := range testCases {
+		jsonContext := context.NewContext()
+		assert.NilError(t, jsonContext.AddJSON([]byte(`{"request": {"object": {"metadata": {"name": "web"}}}}`)))
+		ctx := &PolicyContext{JSONContext: jsonContext}
+
+		configMap := tc.configMap
+		err := LoadContext(log.Log, []kyverno.ContextEntry{{Name: "config", ConfigMap: &configMap}}, resCache, ctx, "rule")
+		if tc.err {
+			assert.ErrorContains(t, err, "prod/api-config: not found, prod/shared-config: not found", tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+
+		if tc.replicas == nil {
+			config, err := jsonContext.Query("config")
+			assert.NilError(t, err, tc.name)
+			assert.Assert(t, config == nil, tc.name)
+		} else {
+			replicas, err := jsonContext.Query("config.data.replicas")
+			assert.NilError(t, err, tc.name)
+			assert.Equal(t, replicas, tc.replicas, tc.name)
+		}
+		assert.Equal(t, ctx.contextResolutions["config"], tc.resolution, tc.name)
+	}
+}
+
+func Test_Validate_ConfigMapFallbackResolution(t *testing.T) {
+	unmockStore(t)
+
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "replicas"},
+		"spec": {
+			"rules": [
+				{
+					"name": "max-replicas",
+					"match": {"resources": {"kinds": ["Deployment"]}},
+					"context": [
+						{
+							"name": "config",
+							"configMap": {
+								"name": "{{request.object.metadata.name}}-config",
+								"namespace": "prod",
+								"fallbacks": [{"name": "default-config"}]
+							}
+						}
+					],
+					"validate": {
+						"message": "too many replicas",
+						"deny": {"conditions": [{"key": "{{request.object.spec.replicas}}", "operator": "GreaterThan", "value": "{{config.data.replicas}}"}]}
+					}
+				}
+			]
+		}
+	}`)
+
+	rawResource := []byte(`{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "api", "namespace": "prod"},
+		"spec": {"replicas": 2}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+	resource, err := utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	configMaps := newFakeGenericCache(t, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, true,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "default-config", "namespace": "prod"}, "data": {"replicas": "1"}}`)
+	resCache := fakeResourceCache{caches: map[string]resourcecache.GenericCache{"ConfigMap": configMaps}}
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(rawResource))
+
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx, ResourceCache: resCache})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Success, false)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyContextPrefix+"config"], "prod/api-config: not found, prod/default-config: found")
+}
+
+func Test_LoadContext_APICallCache(t *testing.T) {
+	unmockStore(t)
+
+	servicesGVR := schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	resCache := fakeResourceCache{
+		caches: map[string]resourcecache.GenericCache{
+			"ConfigMap": newFakeGenericCache(t, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, true),
+		},
+		apiCallCaches: map[schema.GroupVersionResource]resourcecache.GenericCache{
+			servicesGVR: newFakeGenericCache(t, servicesGVR, true,
+				`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "namespace": "prod", "labels": {"app": "web"}}}`,
+				`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web-canary", "namespace": "prod", "labels": {"app": "web"}}}`,
+				`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "api", "namespace": "prod", "labels": {"app": "api"}}}`,
+				`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "namespace": "staging", "labels": {"app": "web"}}}`),
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		urlPath  string
+		jmesPath string
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "list with label selector",
+			urlPath:  "/api/v1/namespaces/prod/services?labelSelector=app=web",
+			jmesPath: "items[].metadata.name",
+			expected: []interface{}{"web", "web-canary"},
+		},
+		{
+			name:     "list of all namespaces",
+			urlPath:  "/api/v1/services?labelSelector=app%3Dweb",
+			jmesPath: "items[].metadata.namespace",
+			expected: []interface{}{"prod", "prod", "staging"},
+		},
+		{
+			name:     "list kind",
+			urlPath:  "/api/v1/namespaces/prod/services",
+			jmesPath: "kind",
+			expected: "ServiceList",
+		},
+		{
+			name:     "get",
+			urlPath:  "/api/v1/namespaces/staging/services/web",
+			jmesPath: "metadata.labels.app",
+			expected: "web",
+		},
+		{
+			name:    "not found",
+			urlPath: "/api/v1/namespaces/staging/services/api",
+			err:     "not found",
+		},
+		{
+			// the resources that are not cached are fetched from the API server
+			name:    "not cached",
+			urlPath: "/api/v1/namespaces/prod/secrets",
+			err:     "API client is not available",
+		},
+	}
+
+	for _, tc := range testCases {
+		jsonContext := context.NewContext()
+		ctx := &PolicyContext{JSONContext: jsonContext, ResourceCache: resCache}
+
+		entry := kyverno.ContextEntry{Name: "result", APICall: &kyverno.APICall{URLPath: tc.urlPath, JMESPath: tc.jmesPath}}
+		err := LoadContext(log.Log, []kyverno.ContextEntry{entry}, resCache, ctx, "rule")
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+
+		result, err := jsonContext.Query("result")
+		assert.NilError(t, err, tc.name)
+		assert.DeepEqual(t, result, tc.expected)
+	}
+}
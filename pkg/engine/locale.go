@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+)
+
+// LocaleAnnotation is the annotation of the resources setting the locale of the rule messages
+// displayed to the requester, e.g. "de"
+const LocaleAnnotation = "kyverno.io/locale"
+
+// localizedMessages returns the message of the rule for the default locale, which is reported,
+// and the message for the locale of the request, which is displayed, with the locale it is
+// selected for. The message field is used for the locales without a variant, the locale is
+// empty then.
+func localizedMessages(ctx *PolicyContext, validation kyverno.Validation) (defaultMessage, message, locale string) {
+	if len(validation.Messages) == 0 {
+		return validation.Message, validation.Message, ""
+	}
+
+	defaultMessage = validation.Message
+	if variant, ok := messageForLocale(validation.Messages, ctx.DefaultLocale); ok {
+		defaultMessage = variant
+	}
+
+	locale = ctx.Locale
+	if locale == "" {
+		locale = ctx.DefaultLocale
+	}
+
+	if variant, ok := messageForLocale(validation.Messages, locale); ok {
+		return defaultMessage, variant, locale
+	}
+
+	return defaultMessage, validation.Message, ""
+}
+
+// messageForLocale returns the variant of the message for the locale, or for its language,
+// e.g. "de" for "de-CH"
+func messageForLocale(messages map[string]string, locale string) (string, bool) {
+	if locale == "" {
+		return "", false
+	}
+
+	if message, ok := messages[locale]; ok {
+		return message, true
+	}
+
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		message, ok := messages[locale[:i]]
+		return message, ok
+	}
+
+	return "", false
+}
+
+// localizeRuleResponse sets the message of the rule response displayed to the requester, the
+// response message built with the default message is kept for the reports
+func localizeRuleResponse(resp *response.RuleResponse, defaultMessage, message, locale string) {
+	if resp == nil || locale == "" {
+		return
+	}
+
+	properties := make(map[string]string, len(resp.Properties)+1)
+	for key, value := range resp.Properties {
+		properties[key] = value
+	}
+
+	properties[response.RulePropertyLocale] = locale
+	resp.Properties = properties
+
+	if message == defaultMessage {
+		return
+	}
+
+	if resp.Message == defaultMessage {
+		resp.LocalizedMessage = message
+	} else if defaultMessage != "" && strings.Contains(resp.Message, defaultMessage) {
+		// the message of the pattern failures includes the path, e.g. "validation error: <message>. Rule
+		// check-labels failed at path /metadata/labels/team/"
+		resp.LocalizedMessage = strings.Replace(resp.Message, defaultMessage, message, 1)
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+)
+
+func Test_Validate_LocalizedMessages(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "restrict-registries"},
+		"spec": {
+			"rules": [
+				{
+					"name": "check-registry",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "image {{request.object.spec.containers[0].image}} is not allowed",
+						"messages": {
+							"de": "Image {{request.object.spec.containers[0].image}} ist nicht erlaubt",
+							"fr": "l'image {{request.object.spec.containers[0].image}} n'est pas autorisée"
+						},
+						"deny": {"conditions": [{"key": "{{request.object.spec.containers[0].image}}", "operator": "Equals", "value": "nginx:latest"}]}
+					}
+				}
+			]
+		}
+	}`)
+
+	rawResource := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "nginx", "namespace": "web"}, "spec": {"containers": [{"name": "nginx", "image": "nginx:latest"}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	validate := func(locale, defaultLocale string) response.RuleResponse {
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(rawResource))
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx, Locale: locale, DefaultLocale: defaultLocale})
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		assert.Assert(t, !er.PolicyResponse.Rules[0].Success)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	english := "image nginx:latest is not allowed"
+	german := "Image nginx:latest ist nicht erlaubt"
+	french := "l'image nginx:latest n'est pas autorisée"
+
+	// the variant of the locale of the request is displayed, the message is reported
+	rule := validate("de", "")
+	assert.Equal(t, rule.Message, english)
+	assert.Equal(t, rule.LocalizedMessage, german)
+	assert.Equal(t, rule.Properties[response.RulePropertyLocale], "de")
+
+	// the variant of the language is used for a regional locale
+	rule = validate("de-CH", "")
+	assert.Equal(t, rule.LocalizedMessage, german)
+	assert.Equal(t, rule.Properties[response.RulePropertyLocale], "de-CH")
+
+	// the message is used for a locale without a variant
+	rule = validate("es", "")
+	assert.Equal(t, rule.Message, english)
+	assert.Equal(t, rule.LocalizedMessage, "")
+	assert.Assert(t, rule.Properties == nil)
+
+	// the variant of the default locale is reported and displayed without a locale in the request
+	rule = validate("", "fr")
+	assert.Equal(t, rule.Message, french)
+	assert.Equal(t, rule.LocalizedMessage, "")
+	assert.Equal(t, rule.Properties[response.RulePropertyLocale], "fr")
+
+	// the variant of the default locale is reported, the variant of the request is displayed
+	rule = validate("de", "fr")
+	assert.Equal(t, rule.Message, french)
+	assert.Equal(t, rule.LocalizedMessage, german)
+	assert.Equal(t, rule.Properties[response.RulePropertyLocale], "de")
+}
@@ -0,0 +1,331 @@
+package mask
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/minio/pkg/wildcard"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Secret is always a sensitive kind, the configured sensitive kinds are added to it
+const Secret = "Secret"
+
+// Prefix replaces the masked values, it is followed by a hash prefix of the value for correlation
+const Prefix = "***"
+
+// minLength is the minimum length of the values masked in the free text, shorter values
+// would mask unrelated parts of the messages
+const minLength = 4
+
+// dataFields are the fields of a sensitive resource whose values are masked
+var dataFields = []string{"data", "stringData"}
+
+// Masker redacts the data values of sensitive resources from the messages, the report
+// properties and the logs. The engine evaluates the rules on the unmasked resources,
+// only its outputs are masked.
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// IsSensitive returns true if the kind is a Secret or matches one of the sensitive kinds
+func IsSensitive(kind string, sensitiveKinds []string) bool {
+	if kind == Secret {
+		return true
+	}
+
+	for _, k := range sensitiveKinds {
+		if wildcard.Match(k, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// New returns a masker of the data values of the sensitive resources, or nil if no resource is sensitive.
+// A nil masker does not mask anything.
+func New(sensitiveKinds []string, resources ...unstructured.Unstructured) *Masker {
+	values := map[string]bool{}
+	for _, resource := range resources {
+		if resource.Object == nil || !IsSensitive(resource.GetKind(), sensitiveKinds) {
+			continue
+		}
+
+		for _, field := range dataFields {
+			data, _, _ := unstructured.NestedMap(resource.Object, field)
+			for _, v := range data {
+				if value, ok := v.(string); ok {
+					addValue(values, value, field == "data")
+				}
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := make([]string, 0, len(values))
+	for value := range values {
+		sorted = append(sorted, value)
+	}
+
+	// the replacer prefers the first values, the longer values are masked before their substrings
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	oldnew := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		oldnew = append(oldnew, value, Masked(value))
+	}
+
+	return &Masker{replacer: strings.NewReplacer(oldnew...)}
+}
+
+// addValue adds the value and its encoded forms, the decoded value is added for base64 encoded data
+func addValue(values map[string]bool, value string, encoded bool) {
+	forms := []string{value}
+	if encoded {
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && utf8.Valid(decoded) {
+			forms = append(forms, string(decoded), strings.TrimSpace(string(decoded)))
+		}
+	}
+
+	for _, form := range forms {
+		if len(form) < minLength {
+			continue
+		}
+
+		values[form] = true
+		if escaped, err := json.Marshal(form); err == nil {
+			values[string(escaped[1:len(escaped)-1])] = true
+		}
+	}
+}
+
+// Masked returns the masked form of the value, "***" followed by a hash prefix of the value
+func Masked(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("%s%x", Prefix, sum[:4])
+}
+
+// String masks the data values in the text
+func (m *Masker) String(s string) string {
+	if m == nil {
+		return s
+	}
+
+	return m.replacer.Replace(s)
+}
+
+// Response masks the data values in the rule messages, localized messages, violations and properties of the response
+func (m *Masker) Response(resp *response.EngineResponse) {
+	if m == nil || resp == nil {
+		return
+	}
+
+	for i := range resp.PolicyResponse.Rules {
+		rule := &resp.PolicyResponse.Rules[i]
+		rule.Message = m.String(rule.Message)
+		rule.LocalizedMessage = m.String(rule.LocalizedMessage)
+		for j := range rule.Violations {
+			rule.Violations[j].Message = m.String(rule.Violations[j].Message)
+		}
+		for k, v := range rule.Properties {
+			rule.Properties[k] = m.String(v)
+		}
+	}
+}
+
+// Value masks a logged value. The JSON patches and resources are masked structurally, the values
+// added to the data of a sensitive resource by the patches are masked even if they are not known yet.
+func (m *Masker) Value(v interface{}) interface{} {
+	if m == nil {
+		return v
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return m.json(value)
+	case []byte:
+		return m.json(string(value))
+	case [][]byte:
+		masked := make([]string, len(value))
+		for i := range value {
+			masked[i] = m.json(string(value[i]))
+		}
+		return masked
+	case error:
+		return m.String(value.Error())
+	case map[string]interface{}, []interface{}:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return Prefix
+		}
+		return m.json(string(raw))
+	default:
+		s := fmt.Sprintf("%+v", v)
+		if masked := m.String(s); masked != s {
+			return masked
+		}
+		return v
+	}
+}
+
+// json masks the data values of the JSON patches or the resource in the text, and the known values
+func (m *Masker) json(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return m.String(s)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(trimmed), &doc); err != nil {
+		return m.String(s)
+	}
+
+	if !maskDocument(doc) {
+		return m.String(s)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return m.String(s)
+	}
+
+	return m.String(string(raw))
+}
+
+// maskDocument masks the data values of a patch, a list of patches or a resource, and returns true if the document changed
+func maskDocument(doc interface{}) bool {
+	switch d := doc.(type) {
+	case []interface{}:
+		changed := false
+		for _, elem := range d {
+			if maskDocument(elem) {
+				changed = true
+			}
+		}
+		return changed
+	case map[string]interface{}:
+		if path, ok := d["path"].(string); ok {
+			if value, ok := d["value"]; ok && isDataPath(path) {
+				d["value"] = maskData(value)
+				return true
+			}
+			return false
+		}
+
+		changed := false
+		for _, field := range dataFields {
+			if value, ok := d[field]; ok {
+				d[field] = maskData(value)
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	return false
+}
+
+func isDataPath(path string) bool {
+	for _, field := range dataFields {
+		if path == "/"+field || strings.HasPrefix(path, "/"+field+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func maskData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Masked(v)
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for k, data := range v {
+			masked[k] = maskData(data)
+		}
+		return masked
+	}
+
+	return value
+}
+
+// Logger returns a logger masking the data values in the messages, errors and values
+func (m *Masker) Logger(logger logr.Logger) logr.Logger {
+	if m == nil {
+		return logger
+	}
+
+	return maskingLogger{logger: logger, masker: m}
+}
+
+type maskingLogger struct {
+	logger logr.Logger
+	masker *Masker
+}
+
+func (l maskingLogger) Enabled() bool {
+	return l.logger.Enabled()
+}
+
+func (l maskingLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !l.logger.Enabled() {
+		return
+	}
+
+	l.logger.Info(l.masker.String(msg), l.values(keysAndValues)...)
+}
+
+func (l maskingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		err = errors.New(l.masker.String(err.Error()))
+	}
+
+	l.logger.Error(err, l.masker.String(msg), l.values(keysAndValues)...)
+}
+
+func (l maskingLogger) V(level int) logr.Logger {
+	return maskingLogger{logger: l.logger.V(level), masker: l.masker}
+}
+
+func (l maskingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return maskingLogger{logger: l.logger.WithValues(l.values(keysAndValues)...), masker: l.masker}
+}
+
+func (l maskingLogger) WithName(name string) logr.Logger {
+	return maskingLogger{logger: l.logger.WithName(name), masker: l.masker}
+}
+
+// values masks the values of the key and value pairs, the keys are not masked
+func (l maskingLogger) values(keysAndValues []interface{}) []interface{} {
+	masked := make([]interface{}, len(keysAndValues))
+	for i, v := range keysAndValues {
+		if i%2 == 0 {
+			masked[i] = v
+			continue
+		}
+
+		masked[i] = l.masker.Value(v)
+	}
+
+	return masked
+}
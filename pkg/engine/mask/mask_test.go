@@ -0,0 +1,149 @@
+package mask
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// the data values of the secret: password is "hunter2-super-secret", base64 encoded
+const (
+	password        = "hunter2-super-secret"
+	encodedPassword = "aHVudGVyMi1zdXBlci1zZWNyZXQ="
+	token           = "token-with-\"quotes\""
+)
+
+func newSecret() unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "db", "namespace": "prod"},
+		"data":       map[string]interface{}{"password": encodedPassword, "short": "YWI="},
+		"stringData": map[string]interface{}{"token": token},
+	}}
+}
+
+func assertNoPlaintext(t *testing.T, output string, values ...string) {
+	for _, value := range append([]string{password, encodedPassword, token, `quotes\"`}, values...) {
+		assert.Assert(t, !strings.Contains(output, value), "%q contains %q", output, value)
+	}
+}
+
+func Test_Masker_String(t *testing.T) {
+	m := New(nil, newSecret())
+	assert.Assert(t, m != nil)
+
+	masked := m.String(fmt.Sprintf("password %s (%s) and token %s, escaped %q", password, encodedPassword, token, token))
+	assertNoPlaintext(t, masked)
+
+	// the hash prefix correlates the masked values
+	assert.Assert(t, strings.Contains(masked, Masked(password)))
+	assert.Assert(t, strings.Contains(masked, Masked(encodedPassword)))
+	assert.Assert(t, strings.HasPrefix(Masked(password), Prefix))
+	assert.Equal(t, Masked(password), m.String(password))
+
+	// the short values are not masked in the free text
+	assert.Equal(t, m.String("ab"), "ab")
+}
+
+func Test_Masker_SensitiveKinds(t *testing.T) {
+	configMap := newSecret()
+	configMap.SetKind("ConfigMap")
+
+	assert.Assert(t, New(nil, configMap) == nil)
+	assert.Assert(t, New([]string{"Config*"}, configMap) != nil)
+	assert.Assert(t, IsSensitive("Secret", nil))
+	assert.Assert(t, !IsSensitive("Pod", []string{"ConfigMap"}))
+
+	// a nil masker does not mask anything
+	var m *Masker
+	assert.Equal(t, m.String(password), password)
+	assert.Equal(t, m.Value(password), password)
+}
+
+func Test_Masker_Response(t *testing.T) {
+	resp := &response.EngineResponse{
+		PolicyResponse: response.PolicyResponse{
+			Rules: []response.RuleResponse{{
+				Name:             "check-password",
+				Message:          "the password " + password + " is too weak",
+				LocalizedMessage: "le mot de passe " + password + " est trop faible",
+				Violations:       []response.Violation{{Rule: "check-password", Check: "deny", Message: "the password " + encodedPassword + " is too weak"}},
+				Properties:       map[string]string{"password": encodedPassword},
+				Patches:          [][]byte{[]byte(`{"op":"add","path":"/data/password","value":"` + encodedPassword + `"}`)},
+			}},
+		},
+	}
+
+	New(nil, newSecret()).Response(resp)
+	assertNoPlaintext(t, resp.PolicyResponse.Rules[0].Message)
+	assertNoPlaintext(t, resp.PolicyResponse.Rules[0].LocalizedMessage)
+	assertNoPlaintext(t, resp.PolicyResponse.Rules[0].Violations[0].Message)
+	assertNoPlaintext(t, resp.PolicyResponse.Rules[0].Properties["password"])
+
+	// the patches are applied to the resource, they are not masked
+	assert.Assert(t, strings.Contains(string(resp.PolicyResponse.Rules[0].Patches[0]), encodedPassword))
+}
+
+func Test_Masker_Logger(t *testing.T) {
+	recorder := &recordingLogger{}
+	logger := New(nil, newSecret()).Logger(recorder).WithValues("data", newSecret().Object["data"])
+
+	// the patch adds a value that is not in the resource yet
+	patches := [][]byte{
+		[]byte(`{"op":"add","path":"/stringData/apiKey","value":"new-api-key-value"}`),
+		[]byte(`{"op":"replace","path":"/data","value":{"password":"` + encodedPassword + `"}}`),
+		[]byte(`{"op":"add","path":"/metadata/labels/team","value":"payments"}`),
+	}
+
+	logger.V(4).Info("generated patches", "patches", patches)
+	logger.Info("generated patch", "patch", string(patches[0]))
+	logger.Info("patched resource", "resource", newSecret().Object)
+	logger.Error(errors.New("invalid password "+password), "failed to apply patch", "value", token)
+
+	output := strings.Join(recorder.lines, "\n")
+	assertNoPlaintext(t, output, "new-api-key-value")
+
+	// the values outside of the data are logged
+	assert.Assert(t, strings.Contains(output, "payments"), output)
+	assert.Assert(t, strings.Contains(output, Masked("new-api-key-value")), output)
+}
+
+// recordingLogger records the messages, errors and values
+type recordingLogger struct {
+	lines  []string
+	values []interface{}
+}
+
+var _ logr.Logger = &recordingLogger{}
+
+func (l *recordingLogger) Enabled() bool {
+	return true
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("%s %v %v", msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("%v %s %v %v", err, msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) V(level int) logr.Logger {
+	return l
+}
+
+func (l *recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.values = append(l.values, keysAndValues...)
+	return l
+}
+
+func (l *recordingLogger) WithName(name string) logr.Logger {
+	return l
+}
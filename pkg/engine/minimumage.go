@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MatchesMinimumAge returns true if the resource is at least the minimum age at the time, and the
+// age of the resource. A resource without a creation timestamp does not match a minimum age.
+func MatchesMinimumAge(resource unstructured.Unstructured, minimumAge *metav1.Duration, t time.Time) (bool, time.Duration) {
+	if minimumAge == nil {
+		return true, 0
+	}
+
+	created := resource.GetCreationTimestamp()
+	if created.IsZero() {
+		return false, 0
+	}
+
+	age := t.Sub(created.Time)
+	return age >= minimumAge.Duration, age
+}
+
+// matchesRuleMinimumAge returns true if the resource is at least the minimum age of the rule
+// at the evaluation time of the policy context
+func matchesRuleMinimumAge(log logr.Logger, rule kyverno.Rule, ctx *PolicyContext) bool {
+	minimumAge := rule.MatchResources.MinimumAge
+	if minimumAge == nil {
+		return true
+	}
+
+	ok, age := MatchesMinimumAge(ctx.NewResource, minimumAge, ctx.evaluationTime())
+	if !ok {
+		log.V(4).Info("resource does not match rule", "reason", "resource is younger than the minimum age", "age", age.Round(time.Second).String(), "minimumAge", minimumAge.Duration.String())
+	}
+
+	return ok
+}
+
+// setResourceAge records on the responses of the rules with a minimum age the age of the resource
+// at the evaluation time, e.g. for the background scan reports
+func setResourceAge(ctx *PolicyContext, resp *response.EngineResponse) {
+	rules := make(map[string]bool, len(ctx.Policy.Spec.Rules))
+	for _, rule := range ctx.Policy.Spec.Rules {
+		rules[rule.Name] = rule.MatchResources.MinimumAge != nil
+	}
+
+	created := resp.PatchedResource.GetCreationTimestamp()
+	if created.IsZero() {
+		return
+	}
+
+	age := ctx.evaluationTime().Sub(created.Time).Round(time.Second).String()
+	for i := range resp.PolicyResponse.Rules {
+		ruleResp := &resp.PolicyResponse.Rules[i]
+		if !rules[ruleResp.Name] {
+			continue
+		}
+
+		if ruleResp.Properties == nil {
+			ruleResp.Properties = make(map[string]string)
+		}
+		ruleResp.Properties[response.RulePropertyResourceAge] = age
+	}
+}
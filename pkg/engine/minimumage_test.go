@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_MatchesMinimumAge(t *testing.T) {
+	now := utcTime(t, "2021-06-30T00:00:00Z")
+	minimumAge := &metav1.Duration{Duration: 2160 * time.Hour}
+
+	newResource := func(created string) unstructured.Unstructured {
+		resource := unstructured.Unstructured{}
+		resource.SetCreationTimestamp(metav1.NewTime(utcTime(t, created)))
+		return resource
+	}
+
+	ok, age := MatchesMinimumAge(newResource("2021-04-01T00:00:00Z"), minimumAge, now)
+	assert.Assert(t, ok)
+	assert.Equal(t, age, 2160*time.Hour)
+
+	ok, age = MatchesMinimumAge(newResource("2021-04-01T00:00:01Z"), minimumAge, now)
+	assert.Assert(t, !ok)
+	assert.Equal(t, age, 2160*time.Hour-time.Second)
+
+	// without a minimum age all the resources match
+	ok, _ = MatchesMinimumAge(newResource("2021-06-29T23:59:59Z"), nil, now)
+	assert.Assert(t, ok)
+
+	// the age of a resource without a creation timestamp is unknown
+	ok, _ = MatchesMinimumAge(unstructured.Unstructured{}, minimumAge, now)
+	assert.Assert(t, !ok)
+}
+
+func Test_Validate_MinimumAge(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "stale-configmaps"},
+		"spec": {
+			"admission": false,
+			"background": true,
+			"rules": [
+				{
+					"name": "require-owner",
+					"match": {"resources": {"kinds": ["ConfigMap"], "minimumAge": "2160h"}},
+					"validate": {
+						"message": "the ConfigMaps older than 90 days require the label 'owner'",
+						"pattern": {"metadata": {"labels": {"owner": "?*"}}}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	validate := func(created string) *response.EngineResponse {
+		rawResource := []byte(fmt.Sprintf(`{
+			"apiVersion": "v1",
+			"kind": "ConfigMap",
+			"metadata": {"name": "settings", "namespace": "prod", "creationTimestamp": "%s"}
+		}`, created))
+
+		resource, err := utils.ConvertToUnstructured(rawResource)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(rawResource))
+		return Validate(&PolicyContext{
+			Policy:      policy,
+			NewResource: *resource,
+			JSONContext: ctx,
+			Time:        utcTime(t, "2021-06-30T00:00:00Z"),
+		})
+	}
+
+	// exactly 90 days old at the scan time
+	er := validate("2021-04-01T00:00:00Z")
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Success)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyResourceAge], "2160h0m0s")
+
+	// one second younger, the resource is matched in a later scan
+	er = validate("2021-04-01T00:00:01Z")
+	assert.Equal(t, len(er.PolicyResponse.Rules), 0)
+}
@@ -55,7 +55,7 @@ func newPatchStrategicMergeHandler(ruleName string, mutate *kyverno.Mutation, pa
 }
 
 func (h patchStrategicMergeHandler) Handle() (response.RuleResponse, unstructured.Unstructured) {
-	return ProcessStrategicMergePatch(h.ruleName, h.mutation.PatchStrategicMerge, h.patchedResource, h.logger)
+	return ProcessStrategicMergePatch(h.ruleName, h.mutation.PatchStrategicMerge, h.mutation.PreserveExisting, h.patchedResource, h.logger)
 }
 
 // overlayHandler
@@ -13,6 +13,7 @@ import (
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-logr/logr"
 	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
+	"github.com/kyverno/kyverno/pkg/engine/common"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -32,6 +33,12 @@ func ProcessOverlay(log logr.Logger, ruleName string, overlay interface{}, resou
 		logger.V(4).Info("finished applying overlay rule", "processingTime", resp.RuleStats.ProcessingTime.String())
 	}()
 
+	if err := checkComplexity(logger, resource.UnstructuredContent()); err != nil {
+		resp.Success = false
+		resp.Message = err.Error()
+		return resp, resource
+	}
+
 	patches, overlayerr := processOverlayPatches(logger, resource.UnstructuredContent(), overlay)
 	if !reflect.DeepEqual(overlayerr, overlayError{}) {
 		switch overlayerr.statusCode {
@@ -104,6 +111,20 @@ func ProcessOverlay(log logr.Logger, ruleName string, overlay interface{}, resou
 	return resp, patchedResource
 }
 
+// checkComplexity returns an error if the resource exceeds the complexity limits of the overlay
+// processing and the limits are enforced
+func checkComplexity(log logr.Logger, resource interface{}) error {
+	limits := common.GetComplexityLimits()
+	if err := common.CheckComplexity(resource, limits); err != nil {
+		if limits.Enforce {
+			log.Info("failed to process overlay", "reason", err.Error())
+			return err
+		}
+		log.Info("processing overlay on a resource exceeding the complexity limits", "reason", err.Error())
+	}
+	return nil
+}
+
 func processOverlayPatches(log logr.Logger, resource, overlay interface{}) ([][]byte, overlayError) {
 	if path, overlayerr := meetConditions(log, resource, overlay); !reflect.DeepEqual(overlayerr, overlayError{}) {
 		switch overlayerr.statusCode {
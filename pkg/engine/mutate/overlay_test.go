@@ -3,11 +3,15 @@ package mutate
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/kyverno/kyverno/pkg/engine/common"
+	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -1180,3 +1184,41 @@ func TestApplyOverlay_ConditionOnArray(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Assert(t, string(utils.JoinPatches(p)) == string(expectedPatches))
 }
+
+func TestProcessOverlay_ComplexityLimits(t *testing.T) {
+	defer common.SetComplexityLimits(common.GetComplexityLimits())
+
+	resourceRaw := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","labels":{"app":"test"}}}`)
+	overlayRaw := []byte(`{"metadata":{"labels":{"overlay":"true"}}}`)
+
+	var resource unstructured.Unstructured
+	assert.NilError(t, resource.UnmarshalJSON(resourceRaw))
+	var overlay interface{}
+	assert.NilError(t, json.Unmarshal(overlayRaw, &overlay))
+
+	processors := map[string]func() (response.RuleResponse, unstructured.Unstructured){
+		"overlay": func() (response.RuleResponse, unstructured.Unstructured) {
+			return ProcessOverlay(log.Log, "overlay", overlay, resource)
+		},
+		"patchStrategicMerge": func() (response.RuleResponse, unstructured.Unstructured) {
+			return ProcessStrategicMergePatch("overlay", overlay, false, resource, log.Log)
+		},
+	}
+
+	for name, process := range processors {
+		common.SetComplexityLimits(common.DefaultComplexityLimits)
+		resp, patched := process()
+		assert.Assert(t, resp.Success, name)
+		assert.Equal(t, patched.GetLabels()["overlay"], "true", name)
+
+		common.SetComplexityLimits(common.ComplexityLimits{MaxDepth: 2, Enforce: true})
+		resp, patched = process()
+		assert.Assert(t, !resp.Success, name)
+		assert.Assert(t, strings.HasPrefix(resp.Message, "resource too complex for rule evaluation"), name)
+		assert.DeepEqual(t, patched, resource)
+
+		common.SetComplexityLimits(common.ComplexityLimits{MaxDepth: 2})
+		resp, _ = process()
+		assert.Assert(t, resp.Success, name)
+	}
+}
@@ -11,7 +11,7 @@ import (
 
 func Test_GeneratePatches(t *testing.T) {
 
-	out, err := strategicMergePatch(string(baseBytes), string(overlayBytes))
+	out, err := strategicMergePatch(string(baseBytes), string(overlayBytes), false)
 	assert.NilError(t, err)
 
 	expectedPatches := map[string]bool{
@@ -0,0 +1,131 @@
+package mutate
+
+import (
+	"strings"
+
+	yaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// preserveExistingFields removes the fields set in the resource from the pattern, so that the
+// strategic merge patch only fills in the fields the resource leaves empty.
+// The maps are walked recursively, and the elements of the lists of maps are matched by name.
+// A field set in the resource with a different structure than the pattern, e.g. a list of
+// strings, is preserved as a whole.
+//
+// pattern : {"spec": {"containers": [{"name": "nginx", "imagePullPolicy": "Always", "resources": {"limits": {"memory": "256Mi", "cpu": "500m"}}}]}}
+// resource : {"spec": {"containers": [{"name": "nginx", "imagePullPolicy": "IfNotPresent", "resources": {"limits": {"memory": "1Gi"}}}]}}
+// result : {"spec": {"containers": [{"name": "nginx", "resources": {"limits": {"cpu": "500m"}}}]}}
+func preserveExistingFields(pattern, resource *yaml.RNode) error {
+	return preserveExistingMap(pattern, resource, "")
+}
+
+// preserveExistingMap removes the fields set in the resource from the pattern map, except
+// for the merge key of the list elements
+func preserveExistingMap(pattern, resource *yaml.RNode, mergeKey string) error {
+	if pattern.YNode().Kind != yaml.MappingNode || resource.YNode().Kind != yaml.MappingNode {
+		return nil
+	}
+
+	fields, err := pattern.Fields()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range fields {
+		// keep the merge key and the strategic merge directives, e.g. $patch
+		if key == mergeKey || strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		resourceField := resource.Field(key)
+		if resourceField.IsNilOrEmpty() {
+			continue
+		}
+
+		patternField := pattern.Field(key)
+		if patternField.IsNilOrEmpty() {
+			continue
+		}
+
+		switch {
+		case isMergeableMap(patternField.Value, resourceField.Value):
+			if err := preserveExistingMap(patternField.Value, resourceField.Value, ""); err != nil {
+				return err
+			}
+		case isMergeableList(patternField.Value, resourceField.Value):
+			if err := preserveExistingList(patternField.Value, resourceField.Value); err != nil {
+				return err
+			}
+		default:
+			// the resource sets the field, remove it from the pattern
+			currentFields, err := pattern.Fields()
+			if err != nil {
+				return err
+			}
+			if ind := getIndex(key, currentFields); ind != -1 {
+				removeAnchorNode(pattern, ind)
+			}
+		}
+	}
+	return nil
+}
+
+// preserveExistingList removes the fields set in the resource from the elements of the pattern
+// list, the elements are matched by name. The elements not found in the resource are kept.
+func preserveExistingList(pattern, resource *yaml.RNode) error {
+	patternElements, err := pattern.Elements()
+	if err != nil {
+		return err
+	}
+	resourceElements, err := resource.Elements()
+	if err != nil {
+		return err
+	}
+
+	for _, patternElement := range patternElements {
+		patternName, err := patternElement.Field("name").Value.String()
+		if err != nil {
+			return err
+		}
+		for _, resourceElement := range resourceElements {
+			resourceNameField := resourceElement.Field("name")
+			if resourceNameField == nil {
+				continue
+			}
+			resourceName, err := resourceNameField.Value.String()
+			if err != nil {
+				return err
+			}
+			if patternName == resourceName {
+				if err := preserveExistingMap(patternElement, resourceElement, "name"); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// isMergeableMap checks if the pattern and the resource values are both maps
+func isMergeableMap(pattern, resource *yaml.RNode) bool {
+	return pattern.YNode().Kind == yaml.MappingNode && resource.YNode().Kind == yaml.MappingNode
+}
+
+// isMergeableList checks if the pattern and the resource values are both lists, and all the
+// elements of the pattern list are maps with a name to match the resource elements
+func isMergeableList(pattern, resource *yaml.RNode) bool {
+	if pattern.YNode().Kind != yaml.SequenceNode || resource.YNode().Kind != yaml.SequenceNode {
+		return false
+	}
+	patternElements, err := pattern.Elements()
+	if err != nil || len(patternElements) == 0 {
+		return false
+	}
+	for _, patternElement := range patternElements {
+		if patternElement.YNode().Kind != yaml.MappingNode || patternElement.Field("name") == nil {
+			return false
+		}
+	}
+	return true
+}
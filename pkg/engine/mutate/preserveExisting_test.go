@@ -0,0 +1,81 @@
+package mutate
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	assertnew "github.com/stretchr/testify/assert"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	yaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func Test_preserveExistingFields(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rawPolicy   []byte
+		rawResource []byte
+		expected    string
+	}{
+		{
+			name:        "partially set nested fields",
+			rawPolicy:   []byte(`{"spec":{"containers":[{"name":"nginx","imagePullPolicy":"Always","args":["--verbose"],"resources":{"limits":{"memory":"256Mi","cpu":"500m"},"requests":{"cpu":"100m"}}}]}}`),
+			rawResource: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"nginx","image":"nginx","imagePullPolicy":"IfNotPresent","args":["--quiet"],"resources":{"limits":{"memory":"1Gi"}}}]}}`),
+			expected:    `{"spec":{"containers":[{"name":"nginx","resources":{"limits":{"cpu":"500m"},"requests":{"cpu":"100m"}}}]}}`,
+		},
+		{
+			name:        "list elements matched by name",
+			rawPolicy:   []byte(`{"spec":{"containers":[{"name":"nginx","livenessProbe":{"tcpSocket":{"port":8080},"periodSeconds":10}},{"name":"sidecar","livenessProbe":{"tcpSocket":{"port":8080},"periodSeconds":10}}]}}`),
+			rawResource: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"sidecar","image":"busybox"},{"name":"nginx","image":"nginx","livenessProbe":{"tcpSocket":{"port":80},"periodSeconds":30}}]}}`),
+			expected:    `{"spec":{"containers":[{"name":"nginx","livenessProbe":{"tcpSocket":{}}},{"name":"sidecar","livenessProbe":{"tcpSocket":{"port":8080},"periodSeconds":10}}]}}`,
+		},
+		{
+			name:        "unset fields",
+			rawPolicy:   []byte(`{"spec":{"containers":[{"name":"nginx","resources":{"limits":{"memory":"256Mi"}}}],"securityContext":{"runAsNonRoot":true}}}`),
+			rawResource: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`),
+			expected:    `{"spec":{"containers":[{"name":"nginx","resources":{"limits":{"memory":"256Mi"}}}],"securityContext":{"runAsNonRoot":true}}}`,
+		},
+	}
+
+	re := regexp.MustCompile("\\n")
+	for _, test := range testCases {
+		preProcessedPolicy, err := preProcessStrategicMergePatch(string(test.rawPolicy), string(test.rawResource))
+		assert.NilError(t, err, test.name)
+
+		err = preserveExistingFields(preProcessedPolicy, yaml.MustParse(string(test.rawResource)))
+		assert.NilError(t, err, test.name)
+
+		output, err := preProcessedPolicy.String()
+		assert.NilError(t, err, test.name)
+		assertnew.Equal(t, test.expected, strings.ReplaceAll(re.ReplaceAllString(output, ""), " ", ""), test.name)
+	}
+}
+
+func Test_ProcessStrategicMergePatch_PreserveExisting(t *testing.T) {
+	rawPolicy := []byte(`{"spec":{"containers":[{"name":"nginx","imagePullPolicy":"Always","resources":{"limits":{"memory":"256Mi","cpu":"500m"}}}]}}`)
+	rawResource := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"nginx","image":"nginx","imagePullPolicy":"IfNotPresent","resources":{"limits":{"memory":"1Gi"}}}]}}`)
+
+	var resource unstructured.Unstructured
+	assert.NilError(t, resource.UnmarshalJSON(rawResource))
+	var patch interface{}
+	assert.NilError(t, json.Unmarshal(rawPolicy, &patch))
+
+	for preserveExisting, expected := range map[bool][]string{
+		true:  {"IfNotPresent", "1Gi", "500m"},
+		false: {"Always", "256Mi", "500m"},
+	} {
+		resp, patchedResource := ProcessStrategicMergePatch("defaults", patch, preserveExisting, resource, log.Log)
+		assert.Assert(t, resp.Success, resp.Message)
+
+		containers, _, err := unstructured.NestedSlice(patchedResource.Object, "spec", "containers")
+		assert.NilError(t, err)
+		assert.Equal(t, len(containers), 1)
+		container := containers[0].(map[string]interface{})
+		memory, _, _ := unstructured.NestedString(container, "resources", "limits", "memory")
+		cpu, _, _ := unstructured.NestedString(container, "resources", "limits", "cpu")
+		assert.DeepEqual(t, []string{container["imagePullPolicy"].(string), memory, cpu}, expected)
+	}
+}
@@ -17,7 +17,8 @@ import (
 )
 
 // ProcessStrategicMergePatch ...
-func ProcessStrategicMergePatch(ruleName string, overlay interface{}, resource unstructured.Unstructured, log logr.Logger) (resp response.RuleResponse, patchedResource unstructured.Unstructured) {
+// The fields set in the resource are not patched if preserveExisting is set
+func ProcessStrategicMergePatch(ruleName string, overlay interface{}, preserveExisting bool, resource unstructured.Unstructured, log logr.Logger) (resp response.RuleResponse, patchedResource unstructured.Unstructured) {
 	startTime := time.Now()
 	logger := log.WithName("ProcessStrategicMergePatch").WithValues("rule", ruleName)
 	logger.V(4).Info("started applying strategicMerge patch", "startTime", startTime)
@@ -30,6 +31,12 @@ func ProcessStrategicMergePatch(ruleName string, overlay interface{}, resource u
 		logger.V(4).Info("finished applying strategicMerge patch", "processingTime", resp.RuleStats.ProcessingTime.String())
 	}()
 
+	if err := checkComplexity(logger, resource.UnstructuredContent()); err != nil {
+		resp.Success = false
+		resp.Message = err.Error()
+		return resp, resource
+	}
+
 	// ====== Meet Conditions =======
 	if path, overlayerr := meetConditions(log, resource.UnstructuredContent(), overlay); !reflect.DeepEqual(overlayerr, overlayError{}) {
 		switch overlayerr.statusCode {
@@ -65,7 +72,7 @@ func ProcessStrategicMergePatch(ruleName string, overlay interface{}, resource u
 		resp.Message = fmt.Sprintf("failed to process patchStrategicMerge: %v", err)
 		return resp, resource
 	}
-	patchedBytes, err := strategicMergePatch(string(base), string(overlayBytes))
+	patchedBytes, err := strategicMergePatch(string(base), string(overlayBytes), preserveExisting)
 	if err != nil {
 		log.Error(err, "failed to apply patchStrategicMerge")
 		msg := fmt.Sprintf("failed to apply patchStrategicMerge: %v", err)
@@ -103,13 +110,19 @@ func ProcessStrategicMergePatch(ruleName string, overlay interface{}, resource u
 	return resp, patchedResource
 }
 
-func strategicMergePatch(base, overlay string) ([]byte, error) {
+func strategicMergePatch(base, overlay string, preserveExisting bool) ([]byte, error) {
 
 	preprocessedYaml, err := preProcessStrategicMergePatch(overlay, base)
 	if err != nil {
 		return []byte{}, fmt.Errorf("failed to preProcess rule: %+v", err)
 	}
 
+	if preserveExisting {
+		if err := preserveExistingFields(preprocessedYaml, yaml.MustParse(base)); err != nil {
+			return []byte{}, fmt.Errorf("failed to preserve existing fields: %+v", err)
+		}
+	}
+
 	f := patchstrategicmerge.Filter{
 		Patch: preprocessedYaml,
 	}
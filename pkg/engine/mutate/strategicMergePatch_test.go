@@ -39,7 +39,7 @@ func TestMergePatch(t *testing.T) {
 	for i, test := range testCases {
 
 		// out
-		out, err := strategicMergePatch(string(test.rawResource), string(test.rawPolicy))
+		out, err := strategicMergePatch(string(test.rawResource), string(test.rawPolicy), false)
 		assert.NilError(t, err)
 
 		// expect
@@ -132,7 +132,7 @@ func Test_PolicyDeserilize(t *testing.T) {
 	patchString, err := json.Marshal(overlayPatches)
 	assert.NilError(t, err)
 
-	out, err := strategicMergePatch(string(baseBytes), string(patchString))
+	out, err := strategicMergePatch(string(baseBytes), string(patchString), false)
 	assert.NilError(t, err)
 
 	var ep unstructured.Unstructured
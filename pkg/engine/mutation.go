@@ -35,11 +35,12 @@ func Mutate(policyContext *PolicyContext) (resp *response.EngineResponse) {
 	resCache := policyContext.ResourceCache
 	logger := log.Log.WithName("EngineMutate").WithValues("policy", policy.Name, "kind", patchedResource.GetKind(),
 		"namespace", patchedResource.GetNamespace(), "name", patchedResource.GetName())
+	logger = policyContext.masker().Logger(policyContext.logger(logger))
 
 	logger.V(4).Info("start policy processing", "startTime", startTime)
 
 	startMutateResultResponse(resp, policy, patchedResource)
-	defer endMutateResultResponse(logger, resp, startTime)
+	defer endMutateResultResponse(logger, resp, policyContext, startTime)
 
 	if ManagedPodResource(policy, patchedResource) {
 		logger.V(5).Info("changes to pods managed by workload controllers are not permitted", "policy", policy.GetName())
@@ -57,14 +58,30 @@ func Mutate(policyContext *PolicyContext) (resp *response.EngineResponse) {
 
 		var ruleResponse response.RuleResponse
 		logger := logger.WithValues("rule", rule.Name)
+		trace := policyContext.traceRule(rule, utils.Mutation.String())
 
 		excludeResource := []string{}
 		if len(policyContext.ExcludeGroupRole) > 0 {
 			excludeResource = policyContext.ExcludeGroupRole
 		}
 
-		if err := MatchesResourceDescription(patchedResource, rule, policyContext.AdmissionInfo, excludeResource, policyContext.NamespaceLabels); err != nil {
+		if err := MatchesResourceDescription(patchedResource, rule, policyContext.matchInfo(), excludeResource, policyContext.NamespaceLabels); err != nil {
 			logger.V(4).Info("rule not matched", "reason", err.Error())
+			trace.skip(fmt.Sprintf("resource does not match: %v", err))
+			continue
+		}
+
+		if apply, ruleResp := checkDeleting(logger, rule, policyContext, utils.Mutation.String()); !apply {
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			trace.done(ruleResp, "")
+			continue
+		}
+
+		if apply, ruleResp := checkTimeWindows(logger, rule, policyContext, utils.Mutation.String()); !apply {
+			if ruleResp != nil {
+				resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			}
+			trace.done(ruleResp, "rule is outside of its time windows")
 			continue
 		}
 
@@ -74,8 +91,18 @@ func Mutate(policyContext *PolicyContext) (resp *response.EngineResponse) {
 		if err := LoadContext(logger, rule.Context, resCache, policyContext, rule.Name); err != nil {
 			if _, ok := err.(gojmespath.NotFoundError); ok {
 				logger.V(3).Info("failed to load context", "reason", err.Error())
+				trace.skip(fmt.Sprintf("context entry not found: %v", err))
 			} else {
 				logger.Error(err, "failed to load context")
+				if ignoresErrors(policyContext) {
+					ruleResp := ruleError(rule, "failed to load context", err)
+					ruleResp.Type = utils.Mutation.String()
+					incrementAppliedCount(resp)
+					resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResp)
+					trace.done(&ruleResp, "")
+				} else {
+					trace.skip(fmt.Sprintf("failed to load context: %v", err))
+				}
 			}
 			continue
 		}
@@ -84,36 +111,51 @@ func Mutate(policyContext *PolicyContext) (resp *response.EngineResponse) {
 		copyConditions, err := copyConditions(rule.AnyAllConditions)
 		if err != nil {
 			logger.V(2).Info("failed to load context", "reason", err.Error())
+			trace.skip(fmt.Sprintf("invalid preconditions: %v", err))
 			continue
 		}
 		// evaluate pre-conditions
 		// - handle variable substitutions
 		if !variables.EvaluateConditions(logger, ctx, copyConditions, true) {
 			logger.V(3).Info("resource fails the preconditions")
+			trace.skip("resource fails the preconditions")
 			continue
 		}
 
-		if rule, err = variables.SubstituteAllInRule(logger, policyContext.JSONContext, rule); err != nil {
-			ruleResp := response.RuleResponse{
-				Name:    rule.Name,
-				Type:    utils.Validation.String(),
-				Message: fmt.Sprintf("variable substitution failed for rule %s: %s", rule.Name, err.Error()),
-				Success: true,
+		if rule.HasMutateForEach() {
+			// the variables are substituted for each element
+			ruleResponse, patchedResource = mutateForEach(logger, policyContext, rule, patchedResource)
+		} else {
+			if rule, err = variables.SubstituteAllInRule(logger, policyContext.JSONContext, rule); err != nil {
+				ruleResp := response.RuleResponse{
+					Name:    rule.Name,
+					Type:    utils.Validation.String(),
+					Message: fmt.Sprintf("variable substitution failed for rule %s: %s", rule.Name, err.Error()),
+					Success: true,
+				}
+
+				if _, ok := err.(gojmespath.NotFoundError); !ok && ignoresErrors(policyContext) {
+					ruleResp = ruleError(rule, "variable substitution failed", err)
+					ruleResp.Type = utils.Mutation.String()
+				}
+
+				incrementAppliedCount(resp)
+				resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResp)
+
+				logger.Error(err, "failed to substitute variables, skip current rule", "rule name", rule.Name)
+				trace.done(&ruleResp, "")
+				continue
 			}
 
-			incrementAppliedCount(resp)
-			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResp)
-
-			logger.Error(err, "failed to substitute variables, skip current rule", "rule name", rule.Name)
-			continue
+			mutation := rule.Mutation.DeepCopy()
+			mutateHandler := mutate.CreateMutateHandler(rule.Name, mutation, patchedResource, ctx, logger)
+			ruleResponse, patchedResource = mutateHandler.Handle()
 		}
-
-		mutation := rule.Mutation.DeepCopy()
-		mutateHandler := mutate.CreateMutateHandler(rule.Name, mutation, patchedResource, ctx, logger)
-		ruleResponse, patchedResource = mutateHandler.Handle()
+		policyContext.setContextResolutions(&ruleResponse)
 		if ruleResponse.Success {
 			// - overlay pattern does not match the resource conditions
 			if ruleResponse.Patches == nil {
+				trace.skip("no patches")
 				continue
 			}
 
@@ -122,6 +164,7 @@ func Mutate(policyContext *PolicyContext) (resp *response.EngineResponse) {
 
 		resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResponse)
 		incrementAppliedRuleCount(resp)
+		trace.done(&ruleResponse, "")
 	}
 
 	resp.PatchedResource = patchedResource
@@ -132,6 +175,13 @@ func incrementAppliedRuleCount(resp *response.EngineResponse) {
 	resp.PolicyResponse.RulesAppliedCount++
 }
 
+// ignoresErrors returns true if the failurePolicy of the policy is Ignore. The mutate rules whose
+// context cannot be loaded or whose variables cannot be substituted are then reported as errors,
+// otherwise they are skipped.
+func ignoresErrors(ctx *PolicyContext) bool {
+	return ctx.Policy.GetFailurePolicy() == kyverno.Ignore
+}
+
 func startMutateResultResponse(resp *response.EngineResponse, policy kyverno.ClusterPolicy, resource unstructured.Unstructured) {
 	if resp == nil {
 		return
@@ -145,11 +195,16 @@ func startMutateResultResponse(resp *response.EngineResponse, policy kyverno.Clu
 	resp.PolicyResponse.Resource.APIVersion = resource.GetAPIVersion()
 }
 
-func endMutateResultResponse(logger logr.Logger, resp *response.EngineResponse, startTime time.Time) {
+func endMutateResultResponse(logger logr.Logger, resp *response.EngineResponse, policyContext *PolicyContext, startTime time.Time) {
 	if resp == nil {
 		return
 	}
 
+	resp.PolicyResponse.CorrelationID = policyContext.CorrelationID
+	setRuleDocumentation(policyContext.Policy, resp)
+	setTimeWindowMode(policyContext, resp)
+	policyContext.masker(resp.PatchedResource).Response(resp)
+
 	resp.PolicyResponse.ProcessingTime = time.Since(startTime)
 	resp.PolicyResponse.PolicyExecutionTimestamp = startTime.Unix()
 	logger.V(5).Info("finished processing policy", "processingTime", resp.PolicyResponse.ProcessingTime.String(), "mutationRulesApplied", resp.PolicyResponse.RulesAppliedCount)
@@ -265,3 +265,57 @@ func Test_variableSubstitutionCLI(t *testing.T) {
 		t.Error("patches dont match")
 	}
 }
+
+func Test_Mutate_FailurePolicy(t *testing.T) {
+	unmockStore(t)
+
+	policyRaw := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "add-registry"},
+		"spec": {
+			"rules": [
+				{
+					"name": "add-registry-label",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"context": [{"name": "registries", "configMap": {"name": "registries", "namespace": "kyverno"}}],
+					"mutate": {"patchStrategicMerge": {"metadata": {"labels": {"registry": "{{registries.data.default}}"}}}}
+				},
+				{
+					"name": "add-team-label",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"mutate": {"patchStrategicMerge": {"metadata": {"labels": {"team": "web"}}}}
+				}
+			]
+		}
+	}`)
+	resourceRaw := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web"}, "spec": {"containers": [{"name": "web", "image": "nginx"}]}}`)
+
+	for _, failurePolicy := range []kyverno.FailurePolicyType{"", kyverno.Fail, kyverno.Ignore} {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		policy.Spec.FailurePolicy = failurePolicy
+		resource, err := utils.ConvertToUnstructured(resourceRaw)
+		assert.NilError(t, err)
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(resourceRaw))
+
+		// the ConfigMap of the context cannot be loaded
+		er := Mutate(&PolicyContext{Policy: policy, JSONContext: ctx, NewResource: *resource, ResourceCache: fakeResourceCache{}})
+		if failurePolicy != kyverno.Ignore {
+			// the rule is skipped
+			assert.Equal(t, len(er.PolicyResponse.Rules), 1, failurePolicy)
+			assert.Equal(t, er.PolicyResponse.Rules[0].Name, "add-team-label", failurePolicy)
+			assert.Assert(t, er.IsSuccessful(), failurePolicy)
+			continue
+		}
+
+		// the error is reported, the other rules are applied
+		assert.Equal(t, len(er.PolicyResponse.Rules), 2)
+		assert.Equal(t, er.PolicyResponse.Rules[0].Name, "add-registry-label")
+		assert.Equal(t, er.PolicyResponse.Rules[0].Type, utils.Mutation.String())
+		assert.Assert(t, er.PolicyResponse.Rules[0].Error)
+		assert.Assert(t, er.PolicyResponse.Rules[1].Success)
+		assert.Assert(t, len(er.GetPatches()) > 0)
+	}
+}
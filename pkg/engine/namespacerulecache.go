@@ -0,0 +1,308 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	corev1 "k8s.io/api/core/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceRuleCache caches the responses of the namespace scoped rules by namespace. The rules
+// only depend on the namespace and the ConfigMaps of their context, the response is reused for
+// the resources of the namespace until the namespace or one of the ConfigMaps changes.
+type NamespaceRuleCache struct {
+	nsLister listerv1.NamespaceLister
+	resCache resourcecache.ResourceCache
+
+	mu      sync.RWMutex
+	entries map[namespaceRuleKey]namespaceRuleEntry
+
+	log logr.Logger
+}
+
+type namespaceRuleKey struct {
+	policy    string
+	rule      string
+	namespace string
+
+	// locale is the locale of the request and the default locale, the messages depend on them
+	locale string
+}
+
+type namespaceRuleEntry struct {
+	// policyVersion is the resource version of the policy the response is computed with
+	policyVersion string
+
+	// namespaceVersion is the resource version of the namespace before the rule is applied
+	namespaceVersion string
+
+	// configMaps are the resource versions of the ConfigMaps of the rule context, by namespace/name
+	configMaps map[string]string
+
+	// response is the rule response, nil if the rule does not apply in the namespace
+	response *response.RuleResponse
+}
+
+// NewNamespaceRuleCache returns a cache invalidated by the events of the namespace informer and
+// of the ConfigMap informer of the resource cache
+func NewNamespaceRuleCache(nsInformer coreinformers.NamespaceInformer, resCache resourcecache.ResourceCache, log logr.Logger) *NamespaceRuleCache {
+	c := newNamespaceRuleCache(nsInformer.Lister(), resCache, log)
+
+	nsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.updateNamespace,
+		DeleteFunc: c.deleteNamespace,
+	})
+
+	if resCache != nil {
+		if gvrC, ok := resCache.GetGVRCache("ConfigMap"); ok {
+			gvrC.GetInformer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.changeConfigMap,
+				UpdateFunc: func(_, cur interface{}) { c.changeConfigMap(cur) },
+				DeleteFunc: c.changeConfigMap,
+			})
+		} else {
+			log.Info("configmaps GVR Cache not found, the ConfigMap changes are checked on lookup")
+		}
+	}
+
+	return c
+}
+
+func newNamespaceRuleCache(nsLister listerv1.NamespaceLister, resCache resourcecache.ResourceCache, log logr.Logger) *NamespaceRuleCache {
+	return &NamespaceRuleCache{
+		nsLister: nsLister,
+		resCache: resCache,
+		entries:  make(map[namespaceRuleKey]namespaceRuleEntry),
+		log:      log,
+	}
+}
+
+// get returns the cached response of the rule for the namespace of the resource. It also returns
+// the current resource version of the namespace, to be stored with the response computed on a
+// cache miss, so that a namespace change during the evaluation invalidates it.
+func (c *NamespaceRuleCache) get(ctx *PolicyContext, rule kyverno.Rule) (resp *response.RuleResponse, found bool, namespaceVersion string) {
+	if c == nil {
+		return nil, false, ""
+	}
+
+	namespace := ctx.namespace()
+	if namespace == "" {
+		return nil, false, ""
+	}
+
+	namespaceVersion, ok := c.namespaceVersion(namespace)
+	if !ok {
+		return nil, false, ""
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[newNamespaceRuleKey(ctx, rule, namespace)]
+	c.mu.RUnlock()
+	if !ok || entry.policyVersion != ctx.Policy.ResourceVersion || entry.namespaceVersion != namespaceVersion {
+		return nil, false, namespaceVersion
+	}
+
+	for key, version := range entry.configMaps {
+		if current, ok := c.configMapVersion(key); !ok || current != version {
+			return nil, false, namespaceVersion
+		}
+	}
+
+	return copyRuleResponse(entry.response), true, namespaceVersion
+}
+
+// set caches the response of the rule for the namespace of the resource. The error responses
+// are not cached, the rule is evaluated again on the next request.
+func (c *NamespaceRuleCache) set(ctx *PolicyContext, rule kyverno.Rule, namespaceVersion string, resp *response.RuleResponse) {
+	if c == nil {
+		return
+	}
+
+	namespace := ctx.namespace()
+	if namespace == "" || namespaceVersion == "" || (resp != nil && resp.Error) {
+		return
+	}
+
+	configMaps, err := c.contextConfigMaps(ctx, rule)
+	if err != nil {
+		c.log.V(4).Info("failed to resolve the ConfigMaps of the rule context, the response is not cached", "rule", rule.Name, "reason", err.Error())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[newNamespaceRuleKey(ctx, rule, namespace)] = namespaceRuleEntry{
+		policyVersion:    ctx.Policy.ResourceVersion,
+		namespaceVersion: namespaceVersion,
+		configMaps:       configMaps,
+		response:         copyRuleResponse(resp),
+	}
+}
+
+// contextConfigMaps returns the resource versions of the ConfigMaps loaded in the rule context
+func (c *NamespaceRuleCache) contextConfigMaps(ctx *PolicyContext, rule kyverno.Rule) (map[string]string, error) {
+	configMaps := make(map[string]string)
+	for _, entry := range rule.Context {
+		if entry.ConfigMap == nil {
+			continue
+		}
+
+		name, err := variables.SubstituteAll(c.log, ctx.JSONContext, entry.ConfigMap.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		namespace, err := variables.SubstituteAll(c.log, ctx.JSONContext, entry.ConfigMap.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		if namespace == "" {
+			namespace = "default"
+		}
+
+		version, err := ctx.JSONContext.Query(entry.Name + ".metadata.resourceVersion")
+		if err != nil {
+			return nil, err
+		}
+
+		// a ConfigMap without a version never matches the cache, the response is computed again
+		versionStr, _ := version.(string)
+		configMaps[fmt.Sprintf("%s/%s", namespace, name)] = versionStr
+	}
+
+	return configMaps, nil
+}
+
+func (c *NamespaceRuleCache) namespaceVersion(namespace string) (string, bool) {
+	if c.nsLister == nil {
+		return "", false
+	}
+
+	ns, err := c.nsLister.Get(namespace)
+	if err != nil {
+		return "", false
+	}
+
+	return ns.GetResourceVersion(), true
+}
+
+func (c *NamespaceRuleCache) configMapVersion(key string) (string, bool) {
+	if c.resCache == nil {
+		return "", false
+	}
+
+	gvrC, ok := c.resCache.GetGVRCache("ConfigMap")
+	if !ok {
+		return "", false
+	}
+
+	obj, err := gvrC.Lister().Get(key)
+	if err != nil {
+		return "", false
+	}
+
+	return obj.GetResourceVersion(), true
+}
+
+func (c *NamespaceRuleCache) updateNamespace(old, cur interface{}) {
+	oldNs, ok := old.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+
+	curNs, ok := cur.(*corev1.Namespace)
+	if !ok || oldNs.GetResourceVersion() == curNs.GetResourceVersion() {
+		return
+	}
+
+	c.invalidateNamespace(curNs.GetName())
+}
+
+func (c *NamespaceRuleCache) deleteNamespace(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		c.invalidateNamespace(tombstone.Key)
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	c.invalidateNamespace(key)
+}
+
+func (c *NamespaceRuleCache) changeConfigMap(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		c.invalidateConfigMap(tombstone.Key)
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	c.invalidateConfigMap(key)
+}
+
+// invalidateNamespace removes the responses cached for the namespace
+func (c *NamespaceRuleCache) invalidateNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.namespace == namespace {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateConfigMap removes the responses computed with the ConfigMap
+func (c *NamespaceRuleCache) invalidateConfigMap(configMap string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if _, ok := entry.configMaps[configMap]; ok {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func newNamespaceRuleKey(ctx *PolicyContext, rule kyverno.Rule, namespace string) namespaceRuleKey {
+	policy := ctx.Policy.Name
+	if ctx.Policy.Namespace != "" {
+		policy = ctx.Policy.Namespace + "/" + ctx.Policy.Name
+	}
+
+	return namespaceRuleKey{policy: policy, rule: rule.Name, namespace: namespace, locale: ctx.Locale + "/" + ctx.DefaultLocale}
+}
+
+// copyRuleResponse copies the response, the responses are appended to the engine responses of
+// several resources
+func copyRuleResponse(resp *response.RuleResponse) *response.RuleResponse {
+	if resp == nil {
+		return nil
+	}
+
+	respCopy := *resp
+	if resp.Properties != nil {
+		respCopy.Properties = make(map[string]string, len(resp.Properties))
+		for k, v := range resp.Properties {
+			respCopy.Properties[k] = v
+		}
+	}
+
+	return &respCopy
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/kyverno/store"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newNamespace(name, resourceVersion string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: resourceVersion, Labels: labels}}
+}
+
+func newSettingsConfigMap(t *testing.T, resourceVersion, blocked string) interface{} {
+	obj, err := utils.ConvertToUnstructured([]byte(fmt.Sprintf(`{
+		"apiVersion": "v1",
+		"kind": "ConfigMap",
+		"metadata": {"name": "settings", "namespace": "kyverno", "resourceVersion": "%s"},
+		"data": {"blocked": "%s"}
+	}`, resourceVersion, blocked)))
+	assert.NilError(t, err)
+	return obj
+}
+
+// unmockStore loads the context entries from the resource cache for the test, the CLI tests of
+// the package mock them with the store
+func unmockStore(t *testing.T) {
+	mock := store.GetMock()
+	store.SetMock(false)
+	t.Cleanup(func() { store.SetMock(mock) })
+}
+
+func Test_NamespaceRuleCache(t *testing.T) {
+	unmockStore(t)
+
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "blocked-namespaces", "resourceVersion": "1"},
+		"spec": {
+			"rules": [
+				{
+					"name": "check-namespace",
+					"scope": "Namespace",
+					"context": [{"name": "settings", "configMap": {"name": "settings", "namespace": "kyverno"}}],
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "the namespace is blocked",
+						"deny": {"conditions": [{"key": "{{request.namespace}}", "operator": "Equals", "value": "{{settings.data.blocked}}"}]}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, nsIndexer.Add(newNamespace("prod", "10", map[string]string{"team": "web"})))
+
+	configMaps := newFakeGenericCache(t, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, true)
+	assert.NilError(t, configMaps.indexer.Add(newSettingsConfigMap(t, "20", "prod")))
+	resCache := fakeResourceCache{caches: map[string]resourcecache.GenericCache{"ConfigMap": configMaps}}
+
+	nsRuleCache := newNamespaceRuleCache(listerv1.NewNamespaceLister(nsIndexer), resCache, log.Log)
+
+	validate := func(name string) response.RuleResponse {
+		rawResource := []byte(fmt.Sprintf(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "%s", "namespace": "prod"}}`, name))
+		resource, err := utils.ConvertToUnstructured(rawResource)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(rawResource))
+		assert.NilError(t, ctx.AddNamespace("prod"))
+
+		er := Validate(&PolicyContext{
+			Policy:             policy,
+			NewResource:        *resource,
+			JSONContext:        ctx,
+			ResourceCache:      resCache,
+			NamespaceRuleCache: nsRuleCache,
+		})
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	assert.Assert(t, !validate("web-1").Success)
+	assert.Equal(t, len(nsRuleCache.entries), 1)
+
+	// the ConfigMap data is changed without a new resource version, the cached response is reused
+	// for the other resources of the namespace
+	assert.NilError(t, configMaps.indexer.Update(newSettingsConfigMap(t, "20", "staging")))
+	assert.Assert(t, !validate("web-2").Success)
+
+	// a namespace label change invalidates the response
+	oldNs := newNamespace("prod", "10", map[string]string{"team": "web"})
+	curNs := newNamespace("prod", "11", map[string]string{"team": "api"})
+	assert.NilError(t, nsIndexer.Update(curNs))
+	nsRuleCache.updateNamespace(oldNs, curNs)
+	assert.Equal(t, len(nsRuleCache.entries), 0)
+	assert.Assert(t, validate("web-3").Success)
+
+	// the namespace is updated before the informer event, the resource version is checked on lookup
+	assert.NilError(t, configMaps.indexer.Update(newSettingsConfigMap(t, "20", "prod")))
+	assert.NilError(t, nsIndexer.Update(newNamespace("prod", "12", map[string]string{"team": "web"})))
+	assert.Assert(t, !validate("web-4").Success)
+
+	// a new revision of the ConfigMap invalidates the response
+	assert.NilError(t, configMaps.indexer.Update(newSettingsConfigMap(t, "21", "staging")))
+	assert.Assert(t, validate("web-5").Success)
+	nsRuleCache.changeConfigMap(newSettingsConfigMap(t, "21", "staging"))
+	assert.Equal(t, len(nsRuleCache.entries), 0)
+}
@@ -1,10 +1,16 @@
 package engine
 
 import (
+	"time"
+
+	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	client "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
+	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"github.com/kyverno/kyverno/pkg/userinfo"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -31,6 +37,12 @@ type PolicyContext struct {
 
 	ExcludeResourceFunc func(kind, namespace, name string) bool
 
+	// PlatformFieldManagers are the field managers of the platform mutations, ignored by the rules with ignorePlatformMutations
+	PlatformFieldManagers []string
+
+	// SensitiveKinds are the kinds, in addition to Secret, whose data values are masked in the messages, reports and logs
+	SensitiveKinds []string
+
 	// ResourceCache provides listers to resources. Currently Supports Configmap
 	ResourceCache resourcecache.ResourceCache
 
@@ -39,4 +51,105 @@ type PolicyContext struct {
 
 	// NamespaceLabels stores the label of namespace to be processed by namespace selector
 	NamespaceLabels map[string]string
+
+	// EnforcementThreshold is the enforcement threshold annotation of the namespace, the enforce
+	// policies allowing namespace thresholds are audited if their severity is below it
+	EnforcementThreshold string
+
+	// Time is the time the time windows of the rules are evaluated against, e.g. the admission
+	// time or the scan time. The current time is used if not set.
+	Time time.Time
+
+	// ReportOutsideTimeWindows adds a skipped response for the rules outside of their time windows
+	ReportOutsideTimeWindows bool
+
+	// MatchOriginalUser checks the subjects of match and exclude against the user impersonating the
+	// requesting user, the roles and cluster roles are the ones of the requesting user
+	MatchOriginalUser bool
+
+	// CorrelationID identifies the admission request in the logs, events, reports and audit events
+	CorrelationID string
+
+	// Locale is the locale of the rule messages displayed to the requester, e.g. "de". The default
+	// locale is used if not set.
+	Locale string
+
+	// DefaultLocale is the locale of the reported rule messages, the message field of the rules is
+	// used if not set
+	DefaultLocale string
+
+	// NamespaceRuleCache caches the responses of the namespace scoped rules, the rules are applied
+	// to each resource if not set
+	NamespaceRuleCache *NamespaceRuleCache
+
+	// Exceptions are the policy exceptions applying to the namespace of the resource, the validate and
+	// verifyImages rules they exempt are skipped
+	Exceptions []*kyverno.PolicyException
+
+	// Trace records the evaluation of the rules for the admission request, the evaluation is not
+	// traced if not set
+	Trace *Trace
+
+	// contextResolutions are the resolutions of the context entries with fallbacks of the rule
+	// being processed, by context entry name
+	contextResolutions map[string]string
+}
+
+// namespace returns the namespace of the resource, the prior resource is used for a delete
+func (ctx *PolicyContext) namespace() string {
+	if namespace := ctx.NewResource.GetNamespace(); namespace != "" {
+		return namespace
+	}
+
+	return ctx.OldResource.GetNamespace()
+}
+
+// matchInfo returns the admission request information the match and exclude blocks are checked against
+func (ctx *PolicyContext) matchInfo() kyverno.RequestInfo {
+	if !ctx.MatchOriginalUser {
+		return ctx.AdmissionInfo
+	}
+
+	info := *ctx.AdmissionInfo.DeepCopy()
+	info.AdmissionUserInfo = userinfo.OriginalUser(info.AdmissionUserInfo)
+	return info
+}
+
+// logger adds the correlation ID of the admission request to the logger
+func (ctx *PolicyContext) logger(logger logr.Logger) logr.Logger {
+	if ctx.CorrelationID == "" {
+		return logger
+	}
+
+	return logger.WithValues("correlationID", ctx.CorrelationID)
+}
+
+// masker returns the masker of the data values of the resources of the policy context, and
+// of the given resources, e.g. the patched resource. It is nil if no resource is sensitive.
+func (ctx *PolicyContext) masker(resources ...unstructured.Unstructured) *mask.Masker {
+	return mask.New(ctx.SensitiveKinds, append([]unstructured.Unstructured{ctx.NewResource, ctx.OldResource}, resources...)...)
+}
+
+// setContextResolution records the resolution of the context entry of the rule being processed
+func (ctx *PolicyContext) setContextResolution(entryName, resolution string) {
+	if ctx.contextResolutions == nil {
+		ctx.contextResolutions = make(map[string]string)
+	}
+
+	ctx.contextResolutions[entryName] = resolution
+}
+
+// setContextResolutions records on the rule response the resolutions of the context entries of the rule
+func (ctx *PolicyContext) setContextResolutions(ruleResp *response.RuleResponse) {
+	if ruleResp == nil || len(ctx.contextResolutions) == 0 {
+		return
+	}
+
+	if ruleResp.Properties == nil {
+		ruleResp.Properties = make(map[string]string)
+	}
+
+	for entryName, resolution := range ctx.contextResolutions {
+		ruleResp.Properties[response.RulePropertyContextPrefix+entryName] = resolution
+	}
 }
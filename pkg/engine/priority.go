@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"sort"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+// SortPolicies sorts the policies in the order their mutate rules are applied, in increasing order of
+// priority and, for the same priority, by name. The namespaced policies are sorted by namespace/name,
+// so that the order of the policies, and the patched resource, do not depend on the order of the cache.
+func SortPolicies(policies []*kyverno.ClusterPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		return appliedBefore(policies[i], policies[j])
+	})
+}
+
+// SortPolicyInterfaces sorts the cluster policies and the namespaced policies like SortPolicies
+func SortPolicyInterfaces(policies []kyverno.PolicyInterface) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		return appliedBefore(policies[i], policies[j])
+	})
+}
+
+// appliedBefore checks if the mutate rules of the first policy are applied before the rules of the second one
+func appliedBefore(p1, p2 kyverno.PolicyInterface) bool {
+	if priority1, priority2 := p1.GetSpec().Priority, p2.GetSpec().Priority; priority1 != priority2 {
+		return priority1 < priority2
+	}
+
+	return policyInterfaceKey(p1) < policyInterfaceKey(p2)
+}
+
+// policyInterfaceKey returns the namespace and name of the policy, or the name of the cluster policy
+func policyInterfaceKey(policy kyverno.PolicyInterface) string {
+	if policy.GetNamespace() == "" {
+		return policy.GetName()
+	}
+
+	return policy.GetNamespace() + "/" + policy.GetName()
+}
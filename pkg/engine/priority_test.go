@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_SortPolicies(t *testing.T) {
+	policy := func(namespace, name string, priority int) *kyverno.ClusterPolicy {
+		return &kyverno.ClusterPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       kyverno.Spec{Priority: priority},
+		}
+	}
+
+	policies := []*kyverno.ClusterPolicy{
+		policy("", "team-override", 10),
+		policy("prod", "add-labels", 0),
+		policy("", "set-defaults", -5),
+		policy("", "add-labels", 0),
+		policy("", "add-annotations", 0),
+	}
+
+	SortPolicies(policies)
+
+	var keys []string
+	for _, p := range policies {
+		keys = append(keys, policyKey(*p))
+	}
+
+	assert.DeepEqual(t, keys, []string{"set-defaults", "add-annotations", "add-labels", "prod/add-labels", "team-override"})
+}
+
+func Test_SortPolicyInterfaces(t *testing.T) {
+	nsPolicy := &kyverno.Policy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "prod", Name: "add-labels"},
+		Spec:       kyverno.Spec{Priority: -1},
+	}
+
+	policies := []kyverno.PolicyInterface{
+		&kyverno.ClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: "set-defaults"}, Spec: kyverno.Spec{Priority: -5}},
+		&kyverno.ClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: "add-labels"}},
+		nsPolicy,
+	}
+
+	SortPolicyInterfaces(policies)
+
+	var keys []string
+	for _, p := range policies {
+		keys = append(keys, policyInterfaceKey(p))
+	}
+
+	assert.DeepEqual(t, keys, []string{"set-defaults", "prod/add-labels", "add-labels"})
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	gojmespath "github.com/jmespath/go-jmespath"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	jmespath "github.com/kyverno/kyverno/pkg/engine/jmespath"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// validateReferences fails the resource if the objects it references do not exist, the referenced
+// objects are looked up in the resource cache. The deleted resources are not checked.
+func validateReferences(ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	resource := ctx.NewResource
+	if reflect.DeepEqual(resource, unstructured.Unstructured{}) {
+		return nil
+	}
+
+	if ctx.ResourceCache == nil {
+		ruleResp := ruleError(rule, "failed to check the references", fmt.Errorf("the resource cache is not available"))
+		return &ruleResp
+	}
+
+	var missing []string
+	for _, ref := range rule.Validation.References {
+		names, err := referencedNames(resource, ref.Path)
+		if err != nil {
+			ruleResp := ruleError(rule, "failed to check the references", err)
+			return &ruleResp
+		}
+
+		if len(names) == 0 {
+			if !ref.AllowMissing {
+				missing = append(missing, fmt.Sprintf("no %s referenced at %s", ref.Kind, ref.Path))
+			}
+			continue
+		}
+
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = resource.GetNamespace()
+		}
+
+		for _, name := range names {
+			reference, found, err := lookupReference(ctx, ref.Kind, namespace, name)
+			if err != nil {
+				ruleResp := ruleError(rule, "failed to check the references", err)
+				return &ruleResp
+			}
+
+			if !found {
+				missing = append(missing, reference)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return &response.RuleResponse{
+			Name:    rule.Name,
+			Type:    utils.Validation.String(),
+			Message: fmt.Sprintf("the objects referenced by %s %s exist", resource.GetKind(), resource.GetName()),
+			Success: true,
+		}
+	}
+
+	message := "validation error: missing references: " + strings.Join(missing, ", ")
+	if rule.Validation.Message != "" {
+		message = fmt.Sprintf("validation error: %s. missing references: %s", strings.TrimSuffix(rule.Validation.Message, "."), strings.Join(missing, ", "))
+	}
+
+	return &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Validation.String(),
+		Message: message,
+		Success: false,
+	}
+}
+
+// referencedNames returns the distinct names the JMESPath expression returns on the resource,
+// the empty values and the absent fields are skipped
+func referencedNames(resource unstructured.Unstructured, path string) ([]string, error) {
+	jp, err := jmespath.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JMESPath: %s, error: %v", path, err)
+	}
+
+	result, err := jp.Search(resource.Object)
+	if err != nil {
+		// an absent field references nothing
+		if _, ok := err.(gojmespath.NotFoundError); ok {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to search JMESPath: %s, error: %v", path, err)
+	}
+
+	var values []interface{}
+	switch r := result.(type) {
+	case nil:
+	case []interface{}:
+		values = r
+	default:
+		values = []interface{}{r}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("JMESPath %s returns %v, expected a name or a list of names", path, value)
+		}
+
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// lookupReference looks up the referenced object in the resource cache, it returns the
+// reference as "<kind> [<namespace>/]<name>"
+func lookupReference(ctx *PolicyContext, kind, namespace, name string) (string, bool, error) {
+	lister, ok := ctx.ResourceCache.GetGVRCache(kind)
+	if !ok {
+		var err error
+		if lister, err = ctx.ResourceCache.CreateGVKInformer(kind); err != nil {
+			return "", false, err
+		}
+	}
+
+	var err error
+	reference := fmt.Sprintf("%s %s", kind, name)
+	if lister.IsNamespaced() {
+		reference = fmt.Sprintf("%s %s/%s", kind, namespace, name)
+		_, err = lister.Lister().Namespace(namespace).Get(name)
+	} else {
+		_, err = lister.Lister().Get(name)
+	}
+
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return reference, false, nil
+		}
+
+		return reference, false, fmt.Errorf("failed to get %s: %v", reference, err)
+	}
+
+	return reference, true, nil
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeResourceCache serves the listers of the kinds and of the API call resources it is created with
+type fakeResourceCache struct {
+	caches        map[string]resourcecache.GenericCache
+	apiCallCaches map[schema.GroupVersionResource]resourcecache.GenericCache
+}
+
+func (f fakeResourceCache) CreateInformers(gvks ...string) []error { return nil }
+
+func (f fakeResourceCache) CreateGVKInformer(gvk string) (resourcecache.GenericCache, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fakeResourceCache) StopResourceInformer(gvk string) {}
+
+func (f fakeResourceCache) GetGVRCache(gvk string) (resourcecache.GenericCache, bool) {
+	gc, ok := f.caches[gvk]
+	return gc, ok
+}
+
+func (f fakeResourceCache) GetAPICallCache(gvr schema.GroupVersionResource) (resourcecache.GenericCache, bool) {
+	gc, ok := f.apiCallCaches[gvr]
+	return gc, ok
+}
+
+// fakeGenericCache lists the objects of its indexer
+type fakeGenericCache struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	indexer    cache.Indexer
+}
+
+func newFakeGenericCache(t *testing.T, gvr schema.GroupVersionResource, namespaced bool, objects ...string) *fakeGenericCache {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, object := range objects {
+		obj, err := utils.ConvertToUnstructured([]byte(object))
+		assert.NilError(t, err)
+		assert.NilError(t, indexer.Add(obj))
+	}
+
+	return &fakeGenericCache{gvr: gvr, namespaced: namespaced, indexer: indexer}
+}
+
+func (f *fakeGenericCache) StopInformer() {}
+
+func (f *fakeGenericCache) IsNamespaced() bool { return f.namespaced }
+
+func (f *fakeGenericCache) Lister() dynamiclister.Lister { return dynamiclister.New(f.indexer, f.gvr) }
+
+func (f *fakeGenericCache) NamespacedLister(namespace string) dynamiclister.NamespaceLister {
+	return f.Lister().Namespace(namespace)
+}
+
+func (f *fakeGenericCache) GVR() schema.GroupVersionResource { return f.gvr }
+
+func (f *fakeGenericCache) GetInformer() cache.SharedIndexInformer { return nil }
+
+func Test_Validate_References(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "ingress-references"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-references",
+					"match": {"resources": {"kinds": ["Ingress"]}},
+					"validate": {
+						"message": "the Ingress references missing objects",
+						"references": [
+							{"path": "spec.tls[].secretName", "kind": "Secret"},
+							{"path": "spec.ingressClassName", "kind": "IngressClass", "allowMissing": true}
+						]
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resCache := fakeResourceCache{caches: map[string]resourcecache.GenericCache{
+		"Secret": newFakeGenericCache(t, schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, true,
+			`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "tls-web", "namespace": "prod"}}`,
+			`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "tls-api", "namespace": "staging"}}`),
+		"IngressClass": newFakeGenericCache(t, schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingressclasses"}, false,
+			`{"apiVersion": "networking.k8s.io/v1", "kind": "IngressClass", "metadata": {"name": "nginx"}}`),
+	}}
+
+	validate := func(rawResource string) response.RuleResponse {
+		resource, err := utils.ConvertToUnstructured([]byte(rawResource))
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource([]byte(rawResource)))
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx, ResourceCache: resCache})
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	// one existing and one missing Secret, only the missing one is named
+	rule := validate(`{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind": "Ingress",
+		"metadata": {"name": "web", "namespace": "prod"},
+		"spec": {
+			"ingressClassName": "nginx",
+			"tls": [{"secretName": "tls-web"}, {"secretName": "tls-missing"}, {"secretName": "tls-web"}]
+		}
+	}`)
+	assert.Assert(t, !rule.Success)
+	assert.Assert(t, !rule.Error)
+	assert.Equal(t, rule.Message, "validation error: the Ingress references missing objects. missing references: Secret prod/tls-missing")
+
+	// the Secrets are looked up in the namespace of the resource, the IngressClass is cluster-wide
+	rule = validate(`{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind": "Ingress",
+		"metadata": {"name": "web", "namespace": "staging"},
+		"spec": {
+			"ingressClassName": "traefik",
+			"tls": [{"secretName": "tls-web"}, {"secretName": "tls-api"}]
+		}
+	}`)
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: the Ingress references missing objects. missing references: Secret staging/tls-web, IngressClass traefik")
+
+	rule = validate(`{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind": "Ingress",
+		"metadata": {"name": "web", "namespace": "prod"},
+		"spec": {"ingressClassName": "nginx", "tls": [{"secretName": "tls-web"}]}
+	}`)
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the IngressClass may be omitted, the Secrets may not
+	rule = validate(`{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "web", "namespace": "prod"}, "spec": {}}`)
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: the Ingress references missing objects. missing references: no Secret referenced at spec.tls[].secretName")
+
+	policy.Spec.Rules[0].Validation.References[0].AllowMissing = true
+	rule = validate(`{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "web", "namespace": "prod"}, "spec": {}}`)
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the namespace of the reference overrides the namespace of the resource
+	policy.Spec.Rules[0].Validation.References[0].Namespace = "staging"
+	rule = validate(`{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind": "Ingress",
+		"metadata": {"name": "api", "namespace": "prod"},
+		"spec": {"tls": [{"secretName": "tls-api"}]}
+	}`)
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the kinds without a lister fail the rule with an error
+	policy.Spec.Rules[0].Validation.References[0].Kind = "ConfigMap"
+	rule = validate(`{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind": "Ingress",
+		"metadata": {"name": "api", "namespace": "prod"},
+		"spec": {"tls": [{"secretName": "tls-api"}]}
+	}`)
+	assert.Assert(t, rule.Error, rule.Message)
+}
+
+func Test_ReferencedNames(t *testing.T) {
+	resource := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"name":  "single",
+			"empty": "",
+			"list":  []interface{}{"a", "", nil, "b", "a"},
+			"count": int64(1),
+		},
+	}}
+
+	names, err := referencedNames(resource, "spec.name")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"single"})
+
+	names, err = referencedNames(resource, "spec.list")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"a", "b"})
+
+	names, err = referencedNames(resource, "spec.empty")
+	assert.NilError(t, err)
+	assert.Equal(t, len(names), 0)
+
+	names, err = referencedNames(resource, "spec.unknown")
+	assert.NilError(t, err)
+	assert.Equal(t, len(names), 0)
+
+	_, err = referencedNames(resource, "spec.count")
+	assert.ErrorContains(t, err, "expected a name or a list of names")
+}
@@ -2,6 +2,7 @@ package response
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -27,6 +28,8 @@ type PolicyResponse struct {
 	Rules []RuleResponse `json:"rules"`
 	// ValidationFailureAction: audit (default) or enforce
 	ValidationFailureAction string
+	// CorrelationID identifies the admission request the policy is applied in
+	CorrelationID string `json:"correlationID,omitempty"`
 }
 
 //PolicySpec policy
@@ -66,21 +69,165 @@ type PolicyStats struct {
 type RuleResponse struct {
 	// rule name specified in policy
 	Name string `json:"name"`
+	// rule ID and documentation URL specified in policy
+	ID               string `json:"id,omitempty"`
+	DocumentationURL string `json:"documentationURL,omitempty"`
 	// rule type (Mutation,Generation,Validation) for Kyverno Policy
 	Type string `json:"type"`
 	// message response from the rule application
 	Message string `json:"message"`
+	// message response in the locale of the request, if the rule has a variant for it
+	LocalizedMessage string `json:"localizedMessage,omitempty"`
 	// JSON patches, for mutation rules
 	Patches [][]byte `json:"patches,omitempty"`
+	// paths of the resource fields failing the validation patterns, e.g. "/spec/replicas/"
+	FailedPaths []string `json:"failedPaths,omitempty"`
+	// the failed checks of a failed validate rule, e.g. the failing elements of its foreach lists
+	Violations []Violation `json:"violations,omitempty"`
 	// success/fail
 	Success bool `json:"success"`
+	// the rule could not be processed, e.g. a context entry failed to load
+	Error bool `json:"error,omitempty"`
+	// the rule is not applied, e.g. outside of its time windows
+	Skipped bool `json:"skipped,omitempty"`
+	// additional properties reported with the rule result, e.g. the applied validation failure action
+	Properties map[string]string `json:"properties,omitempty"`
 	// statistics
 	RuleStats `json:",inline"`
 }
 
+// Violation is a failed check of a validate rule
+type Violation struct {
+	// Rule is the name of the rule
+	Rule string `json:"rule"`
+	// Check is the failed check, i.e. "pattern", "anyPattern[<index>]" or "deny"
+	Check string `json:"check"`
+	// Path is the path of the resource field failing the pattern, e.g. "/spec/containers/0/image/"
+	Path string `json:"path,omitempty"`
+	// List is the foreach list of the failing element
+	List string `json:"list,omitempty"`
+	// Element is the index of the failing element in the foreach list
+	Element *int `json:"element,omitempty"`
+	// ElementName is the name of the failing element, e.g. the name of a container
+	ElementName string `json:"elementName,omitempty"`
+	// Message is the message of the rule, with the variables resolved for the failing element
+	Message string `json:"message,omitempty"`
+}
+
+// String returns the violation in a single line, e.g.
+// "rule check-tag: pattern failed for element 1 (sidecar) of request.object.spec.containers at path /image/: sidecar uses a latest tag"
+func (v Violation) String() string {
+	msg := fmt.Sprintf("rule %s: %s failed", v.Rule, v.Check)
+	if v.Element != nil {
+		msg += fmt.Sprintf(" for element %d", *v.Element)
+		if v.ElementName != "" {
+			msg += fmt.Sprintf(" (%s)", v.ElementName)
+		}
+		msg += " of " + v.List
+	}
+
+	if v.Path != "" {
+		msg += " at path " + v.Path
+	}
+
+	if v.Message != "" {
+		msg += ": " + v.Message
+	}
+
+	return msg
+}
+
+// RulePropertyValidationFailureAction is the rule property set to the validation failure action
+// applied to the resource while an enforce policy is rolled out to a percentage of the namespaces,
+// or if the policy allows namespace enforcement thresholds
+const RulePropertyValidationFailureAction = "kyverno.io/validationFailureAction"
+
+// RulePropertyID and RulePropertyDocumentationURL are the rule properties set to
+// the rule ID and documentation URL in the policy reports
+const (
+	RulePropertyID               = "kyverno.io/ruleID"
+	RulePropertyDocumentationURL = "kyverno.io/documentationURL"
+)
+
+// RulePropertyTimeWindow and RulePropertyEvaluationTime are the rule properties set to the time
+// window mode, inside or outside of the time windows of the rule, and the time it is evaluated at
+const (
+	RulePropertyTimeWindow     = "kyverno.io/timeWindow"
+	RulePropertyEvaluationTime = "kyverno.io/evaluationTime"
+
+	TimeWindowInside  = "inside"
+	TimeWindowOutside = "outside"
+)
+
+// RulePropertyCorrelationID is the rule property set to the correlation ID of the admission request
+const RulePropertyCorrelationID = "correlationID"
+
+// RulePropertyResourceAge is the rule property set to the age of the resource at the scan time,
+// for the rules matching the resources by their minimum age
+const RulePropertyResourceAge = "kyverno.io/resourceAge"
+
+// RulePropertySkipReason and RulePropertyDeletionTimestamp are the rule properties set on the rules skipped
+// as the resource is being deleted, to the skip reason and the deletion timestamp of the resource
+const (
+	RulePropertySkipReason        = "kyverno.io/skipReason"
+	RulePropertyDeletionTimestamp = "kyverno.io/deletionTimestamp"
+
+	SkipReasonDeleting = "deleting"
+)
+
+// RulePropertyPolicyException is the rule property set on the rules skipped as a policy exception exempts
+// the resource, to the namespace and the name of the policy exception
+const (
+	RulePropertyPolicyException = "kyverno.io/policyException"
+
+	SkipReasonException = "exception"
+)
+
+// RulePropertyLocale is the rule property set to the locale of the message displayed to the requester
+const RulePropertyLocale = "kyverno.io/locale"
+
+// RulePropertyMutateDryRun and RulePropertyPatches are the rule properties set on the mutate rules
+// of the policies in dry run, and to the summary of the patches that are not applied
+const (
+	RulePropertyMutateDryRun = "kyverno.io/mutateDryRun"
+	RulePropertyPatches      = "kyverno.io/patches"
+)
+
+// RulePropertyShadowEnforce is the rule property set on the validate rules of the enforce policies
+// evaluated in shadow mode, the failed rules would deny the request once the policy is enforced
+const RulePropertyShadowEnforce = "kyverno.io/shadowEnforce"
+
+// RulePropertySeverity is the rule property set to the severity computed from the attestations of the verified
+// images, it replaces the severity of the policy in the policy reports
+const RulePropertySeverity = "kyverno.io/severity"
+
+// RulePropertyContextPrefix prefixes the rule properties set to the resolution of the context entries
+// with fallbacks or optional, e.g. "kyverno.io/context.config": "prod/web-config: not found, prod/default-config: found"
+const RulePropertyContextPrefix = "kyverno.io/context."
+
 //ToString ...
 func (rr RuleResponse) ToString() string {
-	return fmt.Sprintf("rule %s (%s): %v", rr.Name, rr.Type, rr.Message)
+	return fmt.Sprintf("rule %s (%s): %v", rr.Name, rr.Type, rr.DocumentedMessage())
+}
+
+// DocumentedMessage returns the message followed by the rule ID and documentation URL, e.g.
+// "validation error: label 'team' is required. Rule check-team failed at path /metadata/labels/team/
+// (SEC-014, see https://example.com/controls/SEC-014)"
+func (rr RuleResponse) DocumentedMessage() string {
+	var refs []string
+	if rr.ID != "" {
+		refs = append(refs, rr.ID)
+	}
+
+	if rr.DocumentationURL != "" {
+		refs = append(refs, "see "+rr.DocumentationURL)
+	}
+
+	if len(refs) == 0 {
+		return rr.Message
+	}
+
+	return fmt.Sprintf("%s (%s)", rr.Message, strings.Join(refs, ", "))
 }
 
 //RuleStats stores the statistics for the single rule application
@@ -111,6 +258,17 @@ func (er EngineResponse) IsFailed() bool {
 	return true
 }
 
+// GetViolations returns the violations of the failed rules
+func (er EngineResponse) GetViolations() []Violation {
+	var violations []Violation
+	for _, r := range er.PolicyResponse.Rules {
+		if !r.Success {
+			violations = append(violations, r.Violations...)
+		}
+	}
+	return violations
+}
+
 //GetPatches returns all the patches joined
 func (er EngineResponse) GetPatches() [][]byte {
 	var patches [][]byte
@@ -144,10 +302,32 @@ func (er EngineResponse) GetResourceSpec() ResourceSpec {
 	}
 }
 
+// GetRuleDocumentation returns the distinct IDs and documentation URLs of the failed or successful rules
+func (er EngineResponse) GetRuleDocumentation(success bool) (ids, urls []string) {
+	seen := make(map[string]bool)
+	for _, r := range er.PolicyResponse.Rules {
+		if r.Success != success || r.Skipped {
+			continue
+		}
+
+		if r.ID != "" && !seen[r.ID] {
+			seen[r.ID] = true
+			ids = append(ids, r.ID)
+		}
+
+		if r.DocumentationURL != "" && !seen[r.DocumentationURL] {
+			seen[r.DocumentationURL] = true
+			urls = append(urls, r.DocumentationURL)
+		}
+	}
+
+	return ids, urls
+}
+
 func (er EngineResponse) getRules(success bool) []string {
 	var rules []string
 	for _, r := range er.PolicyResponse.Rules {
-		if r.Success == success {
+		if r.Success == success && !r.Skipped {
 			rules = append(rules, r.Name)
 		}
 	}
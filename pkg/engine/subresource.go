@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"strings"
+
+	pkgcommon "github.com/kyverno/kyverno/pkg/common"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// checkKinds returns true if the resource matches one of the kinds, or if the subresource of the admission
+// request, e.g. "pods/exec", matches one of the kinds qualified with a subresource. The requests of the
+// subresources whose object is the resource, e.g. "pods/status", are also matched by the kind of the resource.
+func checkKinds(kinds []string, resource unstructured.Unstructured, subResource string) bool {
+	if checkKind(kinds, resource) {
+		return true
+	}
+
+	if subResource == "" {
+		return false
+	}
+
+	for _, kind := range kinds {
+		if checkSubResource(kind, subResource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkSubResource returns true if the kind is qualified with the subresource of the request, e.g. the
+// kinds "pods/exec", "Pod/exec" and "v1/pods/exec" match the request of the subresource "pods/exec"
+func checkSubResource(kind, subResource string) bool {
+	_, resource, sub := pkgcommon.ParseKind(kind)
+	if sub == "" {
+		return false
+	}
+
+	_, requestResource, requestSub := pkgcommon.ParseKind(subResource)
+	return strings.EqualFold(sub, requestSub) && pkgcommon.SingularKind(resource) == pkgcommon.SingularKind(requestResource)
+}
@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// the time zones are loaded from the embedded tz database if the image has no zoneinfo
+	_ "time/tzdata"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+)
+
+const minutesPerDay = 24 * 60
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// timeWindow is a parsed kyverno.TimeWindow, the start and end are minutes of the day
+type timeWindow struct {
+	days     [7]bool
+	start    int
+	end      int
+	location *time.Location
+}
+
+// parseTimeWindow parses the window, and returns the path of the invalid field on errors
+func parseTimeWindow(window kyverno.TimeWindow) (*timeWindow, string, error) {
+	w := &timeWindow{location: time.UTC}
+
+	if len(window.Days) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+
+	for i, days := range window.Days {
+		if err := w.addDays(days); err != nil {
+			return nil, fmt.Sprintf("days[%d]", i), err
+		}
+	}
+
+	var err error
+	if w.start, err = parseTimeOfDay(window.Start); err != nil || w.start == minutesPerDay {
+		return nil, "start", fmt.Errorf("invalid start %q, expected a time of day in the HH:MM format", window.Start)
+	}
+
+	if w.end, err = parseTimeOfDay(window.End); err != nil {
+		return nil, "end", fmt.Errorf("invalid end %q, expected a time of day in the HH:MM format", window.End)
+	}
+
+	if w.start == w.end {
+		return nil, "end", fmt.Errorf("the window is empty, the end %q is equal to the start", window.End)
+	}
+
+	if window.TimeZone != "" {
+		if w.location, err = time.LoadLocation(window.TimeZone); err != nil {
+			return nil, "timeZone", fmt.Errorf("invalid time zone %q: %v", window.TimeZone, err)
+		}
+	}
+
+	return w, "", nil
+}
+
+// addDays adds a day, e.g. "Mon", or a range of days, e.g. "Mon-Fri" or "Fri-Mon"
+func (w *timeWindow) addDays(days string) error {
+	bounds := strings.SplitN(days, "-", 2)
+	first, ok := weekdays[strings.ToLower(strings.TrimSpace(bounds[0]))]
+	if !ok {
+		return fmt.Errorf("invalid day %q, expected a day of the week, e.g. Mon, or a range of days, e.g. Mon-Fri", days)
+	}
+
+	last := first
+	if len(bounds) == 2 {
+		if last, ok = weekdays[strings.ToLower(strings.TrimSpace(bounds[1]))]; !ok {
+			return fmt.Errorf("invalid day %q, expected a day of the week, e.g. Mon, or a range of days, e.g. Mon-Fri", days)
+		}
+	}
+
+	for day := first; ; day = (day + 1) % 7 {
+		w.days[day] = true
+		if day == last {
+			return nil
+		}
+	}
+}
+
+// parseTimeOfDay parses a time of day in the HH:MM format, from 00:00 to 24:00, and returns the minutes
+func parseTimeOfDay(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+
+	if hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > minutesPerDay {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	return hours*60 + minutes, nil
+}
+
+// contains returns true if the time is in the window. The time is compared with the window in the
+// local time of the window time zone, so a window follows the daylight saving time transitions.
+func (w *timeWindow) contains(t time.Time) bool {
+	local := t.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+
+	if w.start < w.end {
+		return w.days[day] && minute >= w.start && minute < w.end
+	}
+
+	// the window spans midnight, the days are the days the window starts on
+	previousDay := (day + 6) % 7
+	return (w.days[day] && minute >= w.start) || (w.days[previousDay] && minute < w.end)
+}
+
+// ValidateTimeWindows checks the time windows of a rule, and returns the path of the invalid field on errors
+func ValidateTimeWindows(windows []kyverno.TimeWindow) (string, error) {
+	for i, window := range windows {
+		if _, path, err := parseTimeWindow(window); err != nil {
+			return fmt.Sprintf("timeWindows[%d].%s", i, path), err
+		}
+	}
+
+	return "", nil
+}
+
+// InTimeWindows returns true if the time is in one of the windows, or if there are no windows
+func InTimeWindows(windows []kyverno.TimeWindow, t time.Time) (bool, error) {
+	if len(windows) == 0 {
+		return true, nil
+	}
+
+	for i, window := range windows {
+		w, path, err := parseTimeWindow(window)
+		if err != nil {
+			return false, fmt.Errorf("timeWindows[%d].%s: %v", i, path, err)
+		}
+
+		if w.contains(t) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// evaluationTime returns the time the time windows are evaluated against
+func (ctx *PolicyContext) evaluationTime() time.Time {
+	if ctx.Time.IsZero() {
+		return time.Now()
+	}
+
+	return ctx.Time
+}
+
+// checkTimeWindows returns true if the rule is applied at the evaluation time of the policy context.
+// A response is returned for a rule that is not applied if its time windows cannot be evaluated, or if
+// the policy context reports the rules outside of their time windows.
+func checkTimeWindows(log logr.Logger, rule kyverno.Rule, ctx *PolicyContext, ruleType string) (bool, *response.RuleResponse) {
+	if len(rule.TimeWindows) == 0 {
+		return true, nil
+	}
+
+	t := ctx.evaluationTime()
+	inside, err := InTimeWindows(rule.TimeWindows, t)
+	if err != nil {
+		log.Error(err, "failed to evaluate the time windows")
+		ruleResp := ruleError(rule, "failed to evaluate the time windows", err)
+		ruleResp.Type = ruleType
+		return false, &ruleResp
+	}
+
+	if inside {
+		return true, nil
+	}
+
+	log.V(3).Info("rule is outside of its time windows", "time", t.Format(time.RFC3339))
+	if !ctx.ReportOutsideTimeWindows {
+		return false, nil
+	}
+
+	return false, &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    ruleType,
+		Message: fmt.Sprintf("rule %s is not applied outside of its time windows", rule.Name),
+		Success: true,
+		Skipped: true,
+		Properties: map[string]string{
+			response.RulePropertyTimeWindow:     response.TimeWindowOutside,
+			response.RulePropertyEvaluationTime: t.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// setTimeWindowMode records on the responses of the rules with time windows that the rules are
+// applied inside of their time windows, e.g. for the background scan reports
+func setTimeWindowMode(ctx *PolicyContext, resp *response.EngineResponse) {
+	rules := make(map[string]bool, len(ctx.Policy.Spec.Rules))
+	for _, rule := range ctx.Policy.Spec.Rules {
+		rules[rule.Name] = len(rule.TimeWindows) > 0
+	}
+
+	t := ctx.evaluationTime().UTC().Format(time.RFC3339)
+	for i := range resp.PolicyResponse.Rules {
+		ruleResp := &resp.PolicyResponse.Rules[i]
+		if !rules[ruleResp.Name] || ruleResp.Skipped {
+			continue
+		}
+
+		if ruleResp.Properties == nil {
+			ruleResp.Properties = make(map[string]string)
+		}
+		ruleResp.Properties[response.RulePropertyTimeWindow] = response.TimeWindowInside
+		ruleResp.Properties[response.RulePropertyEvaluationTime] = t
+	}
+}
@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+)
+
+func utcTime(t *testing.T, value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	assert.NilError(t, err)
+	return parsed
+}
+
+func Test_InTimeWindows_BusinessHours_DST(t *testing.T) {
+	windows := []kyverno.TimeWindow{{Days: []string{"Mon-Fri"}, Start: "09:00", End: "17:00", TimeZone: "Europe/Berlin"}}
+
+	testcases := []struct {
+		time   string
+		inside bool
+	}{
+		// Friday before the transition to summer time, CET is UTC+1
+		{time: "2021-03-26T07:30:00Z", inside: false},
+		{time: "2021-03-26T08:30:00Z", inside: true},
+		{time: "2021-03-26T15:59:00Z", inside: true},
+		{time: "2021-03-26T16:00:00Z", inside: false},
+		// Monday after the transition, CEST is UTC+2
+		{time: "2021-03-29T07:00:00Z", inside: true},
+		{time: "2021-03-29T06:59:00Z", inside: false},
+		{time: "2021-03-29T14:59:00Z", inside: true},
+		{time: "2021-03-29T15:00:00Z", inside: false},
+		// weekend
+		{time: "2021-03-27T10:00:00Z", inside: false},
+	}
+
+	for _, tc := range testcases {
+		inside, err := InTimeWindows(windows, utcTime(t, tc.time))
+		assert.NilError(t, err)
+		assert.Equal(t, inside, tc.inside, tc.time)
+	}
+}
+
+func Test_InTimeWindows_DSTTransitions(t *testing.T) {
+	windows := []kyverno.TimeWindow{{Days: []string{"Sun"}, Start: "02:00", End: "03:00", TimeZone: "Europe/Berlin"}}
+
+	testcases := []struct {
+		time   string
+		inside bool
+	}{
+		// the clocks skip from 02:00 CET to 03:00 CEST, the window does not occur
+		{time: "2021-03-28T00:59:00Z", inside: false},
+		{time: "2021-03-28T01:00:00Z", inside: false},
+		// the clocks go back from 03:00 CEST to 02:00 CET, the window occurs twice
+		{time: "2021-10-31T00:30:00Z", inside: true},
+		{time: "2021-10-31T01:30:00Z", inside: true},
+		{time: "2021-10-31T02:00:00Z", inside: false},
+	}
+
+	for _, tc := range testcases {
+		inside, err := InTimeWindows(windows, utcTime(t, tc.time))
+		assert.NilError(t, err)
+		assert.Equal(t, inside, tc.inside, tc.time)
+	}
+}
+
+func Test_InTimeWindows_Midnight(t *testing.T) {
+	windows := []kyverno.TimeWindow{
+		// weeknights, the window of Friday ends on Saturday morning
+		{Days: []string{"Mon", "Tuesday", "wed", "Thu", "Fri"}, Start: "17:00", End: "09:00"},
+		// the window of Sunday ends at midnight
+		{Days: []string{"Sun"}, Start: "00:00", End: "24:00"},
+	}
+
+	testcases := []struct {
+		time   string
+		inside bool
+	}{
+		{time: "2021-03-26T23:00:00Z", inside: true},  // Friday night
+		{time: "2021-03-27T08:59:00Z", inside: true},  // Saturday morning
+		{time: "2021-03-27T09:00:00Z", inside: false}, // Saturday
+		{time: "2021-03-27T23:00:00Z", inside: false}, // Saturday night
+		{time: "2021-03-28T00:00:00Z", inside: true},  // Sunday
+		{time: "2021-03-28T23:59:00Z", inside: true},  // Sunday night
+		{time: "2021-03-29T08:00:00Z", inside: false}, // Monday morning, Sunday has no night window
+		{time: "2021-03-29T12:00:00Z", inside: false}, // Monday
+		{time: "2021-03-29T17:00:00Z", inside: true},  // Monday night
+		{time: "2021-03-30T08:00:00Z", inside: true},  // Tuesday morning
+	}
+
+	for _, tc := range testcases {
+		inside, err := InTimeWindows(windows, utcTime(t, tc.time))
+		assert.NilError(t, err)
+		assert.Equal(t, inside, tc.inside, tc.time)
+	}
+}
+
+func Test_InTimeWindows_DayRanges(t *testing.T) {
+	windows := []kyverno.TimeWindow{{Days: []string{"Fri-Mon"}, Start: "00:00", End: "24:00"}}
+
+	for day, inside := range map[string]bool{
+		"2021-03-26T12:00:00Z": true,  // Friday
+		"2021-03-27T12:00:00Z": true,  // Saturday
+		"2021-03-29T12:00:00Z": true,  // Monday
+		"2021-03-30T12:00:00Z": false, // Tuesday
+		"2021-03-25T12:00:00Z": false, // Thursday
+	} {
+		result, err := InTimeWindows(windows, utcTime(t, day))
+		assert.NilError(t, err)
+		assert.Equal(t, result, inside, day)
+	}
+
+	// no windows
+	inside, err := InTimeWindows(nil, time.Now())
+	assert.NilError(t, err)
+	assert.Assert(t, inside)
+}
+
+func Test_ValidateTimeWindows(t *testing.T) {
+	testcases := []struct {
+		window kyverno.TimeWindow
+		path   string
+		err    string
+	}{
+		{
+			window: kyverno.TimeWindow{Start: "09:00", End: "17:00", TimeZone: "Mars/Olympus_Mons"},
+			path:   "timeWindows[1].timeZone",
+			err:    `invalid time zone "Mars/Olympus_Mons"`,
+		},
+		{
+			window: kyverno.TimeWindow{Start: "9:00", End: "17:00"},
+			path:   "timeWindows[1].start",
+			err:    `invalid start "9:00"`,
+		},
+		{
+			window: kyverno.TimeWindow{Start: "24:00", End: "09:00"},
+			path:   "timeWindows[1].start",
+			err:    `invalid start "24:00"`,
+		},
+		{
+			window: kyverno.TimeWindow{Start: "09:00", End: "24:01"},
+			path:   "timeWindows[1].end",
+			err:    `invalid end "24:01"`,
+		},
+		{
+			window: kyverno.TimeWindow{Start: "09:00", End: "09:00"},
+			path:   "timeWindows[1].end",
+			err:    "the window is empty",
+		},
+		{
+			window: kyverno.TimeWindow{Days: []string{"Mon", "Funday"}, Start: "09:00", End: "17:00"},
+			path:   "timeWindows[1].days[1]",
+			err:    `invalid day "Funday"`,
+		},
+		{
+			window: kyverno.TimeWindow{Days: []string{"Mon-"}, Start: "09:00", End: "17:00"},
+			path:   "timeWindows[1].days[0]",
+			err:    `invalid day "Mon-"`,
+		},
+	}
+
+	valid := kyverno.TimeWindow{Days: []string{"Sat", "Sun"}, Start: "00:00", End: "24:00", TimeZone: "America/New_York"}
+	path, err := ValidateTimeWindows([]kyverno.TimeWindow{valid})
+	assert.NilError(t, err)
+	assert.Equal(t, path, "")
+
+	for _, tc := range testcases {
+		path, err := ValidateTimeWindows([]kyverno.TimeWindow{valid, tc.window})
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+
+		_, err = InTimeWindows([]kyverno.TimeWindow{tc.window}, time.Now())
+		assert.ErrorContains(t, err, tc.err)
+	}
+}
+
+func Test_Validate_TimeWindows(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "deny-prod-deletes"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "business-hours",
+					"match": {"resources": {"kinds": ["Deployment"]}},
+					"timeWindows": [
+						{"days": ["Mon-Fri"], "start": "09:00", "end": "17:00", "timeZone": "Europe/Berlin"}
+					],
+					"validate": {
+						"message": "the label 'team' is required during business hours",
+						"pattern": {"metadata": {"labels": {"team": "?*"}}}
+					}
+				}
+			]
+		}
+	}`)
+
+	rawResource := []byte(`{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "web", "namespace": "prod"}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	newPolicyContext := func(now string, report bool) *PolicyContext {
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(rawResource))
+		return &PolicyContext{
+			Policy:                   policy,
+			NewResource:              *resource,
+			JSONContext:              ctx,
+			Time:                     utcTime(t, now),
+			ReportOutsideTimeWindows: report,
+		}
+	}
+
+	// Monday 10:30 CEST
+	er := Validate(newPolicyContext("2021-03-29T08:30:00Z", true))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Success)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyTimeWindow], response.TimeWindowInside)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyEvaluationTime], "2021-03-29T08:30:00Z")
+
+	// Monday 18:30 CEST, the rule is skipped
+	er = Validate(newPolicyContext("2021-03-29T16:30:00Z", false))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 0)
+
+	// the skipped rule is reported, e.g. by the background scans
+	er = Validate(newPolicyContext("2021-03-29T16:30:00Z", true))
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.IsSuccessful())
+	assert.Assert(t, er.PolicyResponse.Rules[0].Skipped)
+	assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyTimeWindow], response.TimeWindowOutside)
+	assert.Equal(t, len(er.GetSuccessRules()), 0)
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+)
+
+// maxTracedValueLength is the length the values of the traced variables are truncated to
+const maxTracedValueLength = 256
+
+// Rule trace results, in addition to the results of the rule responses
+const (
+	TraceResultSkipped = "skip"
+	TraceResultPass    = "pass"
+	TraceResultFail    = "fail"
+	TraceResultError   = "error"
+)
+
+// Trace records the evaluation of the policies for an admission request, i.e. the policies fetched from
+// the policy cache, the rules applied or skipped and why, the variables they resolve and their duration.
+// The trace is shared by the webhooks the request is sent to, a nil trace records nothing.
+type Trace struct {
+	mutex         sync.Mutex
+	correlationID string
+	operation     string
+	resource      response.ResourceSpec
+	time          time.Time
+	lookups       []PolicyLookup
+	rules         []RuleTrace
+}
+
+// PolicyLookup is the lookup of the policies of a type in the policy cache
+type PolicyLookup struct {
+	// PolicyType is the type of the policies, e.g. ValidateEnforce
+	PolicyType string `json:"policyType"`
+
+	// Policies are the policies returned by the cache, by namespace/name for the namespaced policies
+	Policies []string `json:"policies"`
+}
+
+// RuleTrace is the evaluation of a rule
+type RuleTrace struct {
+	Policy string `json:"policy"`
+	Rule   string `json:"rule"`
+	Type   string `json:"type"`
+
+	// Result is skip, pass, fail or error
+	Result string `json:"result"`
+
+	// Reason is the reason the rule is skipped, or the message of the rule response
+	Reason string `json:"reason,omitempty"`
+
+	// Variables are the variables resolved for the rule, in the order they are first resolved
+	Variables []VariableResolution `json:"variables,omitempty"`
+
+	// Duration is the time spent evaluating the rule, e.g. "1.2ms"
+	Duration string `json:"duration"`
+
+	trace       *Trace
+	start       time.Time
+	jsonContext *context.Context
+	masker      *mask.Masker
+	resolved    map[string]bool
+}
+
+// VariableResolution is the result of a variable resolved for a rule
+type VariableResolution struct {
+	Variable string `json:"variable"`
+	Value    string `json:"value,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewTrace returns the trace of an admission request
+func NewTrace(correlationID, operation string, resource response.ResourceSpec) *Trace {
+	return &Trace{
+		correlationID: correlationID,
+		operation:     operation,
+		resource:      resource,
+		time:          time.Now(),
+	}
+}
+
+// CorrelationID returns the correlation ID of the traced admission request
+func (t *Trace) CorrelationID() string {
+	return t.correlationID
+}
+
+// AddLookup records the policies of a type fetched from the policy cache
+func (t *Trace) AddLookup(policyType string, policies []*kyverno.ClusterPolicy) {
+	if t == nil {
+		return
+	}
+
+	names := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		names = append(names, policyKey(*policy))
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.lookups = append(t.lookups, PolicyLookup{PolicyType: policyType, Policies: names})
+}
+
+// MarshalJSON returns the trace as JSON, the rules being evaluated are not included
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return json.Marshal(struct {
+		CorrelationID string                `json:"correlationID"`
+		Operation     string                `json:"operation"`
+		Resource      response.ResourceSpec `json:"resource"`
+		Time          time.Time             `json:"time"`
+		Lookups       []PolicyLookup        `json:"lookups,omitempty"`
+		Rules         []RuleTrace           `json:"rules,omitempty"`
+	}{
+		CorrelationID: t.correlationID,
+		Operation:     t.operation,
+		Resource:      t.resource,
+		Time:          t.time,
+		Lookups:       t.lookups,
+		Rules:         t.rules,
+	})
+}
+
+// traceRule starts the trace of a rule, the variables resolved for the rule are recorded until the
+// rule trace ends. It returns nil if the request is not traced.
+func (ctx *PolicyContext) traceRule(rule kyverno.Rule, ruleType string) *RuleTrace {
+	if ctx.Trace == nil {
+		return nil
+	}
+
+	rt := &RuleTrace{
+		Policy:      policyKey(ctx.Policy),
+		Rule:        rule.Name,
+		Type:        ruleType,
+		trace:       ctx.Trace,
+		start:       time.Now(),
+		jsonContext: ctx.JSONContext,
+		masker:      ctx.masker(),
+		resolved:    make(map[string]bool),
+	}
+
+	if rt.jsonContext != nil {
+		rt.jsonContext.SetQueryRecorder(rt.recordQuery)
+	}
+
+	return rt
+}
+
+// recordQuery records the first resolution of each variable, the values of the sensitive resources are masked
+func (rt *RuleTrace) recordQuery(query string, result interface{}, err error) {
+	if rt.resolved[query] {
+		return
+	}
+
+	rt.resolved[query] = true
+	resolution := VariableResolution{Variable: query}
+	if err != nil {
+		resolution.Error = rt.masker.String(err.Error())
+	} else if raw, err := json.Marshal(result); err == nil {
+		value := rt.masker.String(string(raw))
+		if len(value) > maxTracedValueLength {
+			value = value[:maxTracedValueLength] + "..."
+		}
+		resolution.Value = value
+	}
+
+	rt.Variables = append(rt.Variables, resolution)
+}
+
+// skip ends the trace of a rule that is not applied
+func (rt *RuleTrace) skip(reason string) {
+	if rt == nil {
+		return
+	}
+
+	rt.Result = TraceResultSkipped
+	rt.Reason = reason
+	rt.end()
+}
+
+// done ends the trace of a rule with its response, a nil response is a rule that is not applied
+func (rt *RuleTrace) done(ruleResp *response.RuleResponse, reason string) {
+	if rt == nil {
+		return
+	}
+
+	if ruleResp == nil {
+		rt.skip(reason)
+		return
+	}
+
+	switch {
+	case ruleResp.Skipped:
+		rt.Result = TraceResultSkipped
+	case ruleResp.Error:
+		rt.Result = TraceResultError
+	case ruleResp.Success:
+		rt.Result = TraceResultPass
+	default:
+		rt.Result = TraceResultFail
+	}
+
+	rt.Reason = rt.masker.String(ruleResp.Message)
+	rt.end()
+}
+
+func (rt *RuleTrace) end() {
+	if rt.jsonContext != nil {
+		rt.jsonContext.SetQueryRecorder(nil)
+	}
+
+	rt.Duration = time.Since(rt.start).String()
+	rt.trace.mutex.Lock()
+	defer rt.trace.mutex.Unlock()
+	rt.trace.rules = append(rt.trace.rules, *rt)
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+)
+
+func Test_Validate_Trace(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "pod-checks"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-deployments",
+					"match": {"resources": {"kinds": ["Deployment"]}},
+					"validate": {"message": "denied", "deny": {}}
+				},
+				{
+					"name": "check-staging",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"preconditions": {"all": [{"key": "{{request.object.metadata.namespace}}", "operator": "Equals", "value": "staging"}]},
+					"validate": {"message": "denied", "deny": {}}
+				},
+				{
+					"name": "check-name",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "pod {{request.object.metadata.name}} is denied",
+						"deny": {"conditions": [{"key": "{{request.object.metadata.name}}", "operator": "Equals", "value": "web"}]}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(forEachPodRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(forEachPodRaw))
+
+	trace := NewTrace("uid", "CREATE", response.ResourceSpec{Kind: "Pod", Namespace: "prod", Name: "web"})
+	trace.AddLookup("ValidateEnforce", []*kyverno.ClusterPolicy{&policy})
+
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx, Trace: trace})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+
+	assert.DeepEqual(t, trace.lookups, []PolicyLookup{{PolicyType: "ValidateEnforce", Policies: []string{"pod-checks"}}})
+	assert.Equal(t, len(trace.rules), 3)
+
+	notMatched := trace.rules[0]
+	assert.Equal(t, notMatched.Rule, "check-deployments")
+	assert.Equal(t, notMatched.Result, TraceResultSkipped)
+	assert.Assert(t, strings.HasPrefix(notMatched.Reason, "resource does not match"), notMatched.Reason)
+
+	precondition := trace.rules[1]
+	assert.Equal(t, precondition.Rule, "check-staging")
+	assert.Equal(t, precondition.Result, TraceResultSkipped)
+	assert.Assert(t, hasVariable(precondition, "request.object.metadata.namespace", `"prod"`))
+
+	denied := trace.rules[2]
+	assert.Equal(t, denied.Policy, "pod-checks")
+	assert.Equal(t, denied.Type, utils.Validation.String())
+	assert.Equal(t, denied.Result, TraceResultFail)
+	assert.Equal(t, denied.Reason, "pod web is denied")
+	assert.Assert(t, hasVariable(denied, "request.object.metadata.name", `"web"`))
+	assert.Assert(t, denied.Duration != "")
+
+	// the variables resolved after the rule evaluation are not recorded
+	_, err = ctx.Query("request.object.kind")
+	assert.NilError(t, err)
+	assert.Assert(t, !hasVariable(trace.rules[2], "request.object.kind", `"Pod"`))
+
+	raw, err := json.Marshal(trace)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(raw), `"correlationID":"uid"`), string(raw))
+}
+
+func Test_Validate_NotTraced(t *testing.T) {
+	var trace *Trace
+	trace.AddLookup("ValidateEnforce", nil)
+
+	ctx := &PolicyContext{}
+	assert.Assert(t, ctx.traceRule(kyverno.Rule{Name: "rule"}, utils.Validation.String()) == nil)
+}
+
+func hasVariable(rule RuleTrace, variable, value string) bool {
+	for _, resolution := range rule.Variables {
+		if resolution.Variable == variable && resolution.Value == value {
+			return true
+		}
+	}
+
+	return false
+}
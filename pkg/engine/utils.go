@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/wildcards"
+	"github.com/kyverno/kyverno/pkg/userinfo"
 	"github.com/kyverno/kyverno/pkg/utils"
 	"github.com/minio/pkg/wildcard"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -28,19 +31,25 @@ type EngineStats struct {
 	RulesAppliedCount int
 }
 
+// checkKind returns true if the resource matches one of the kinds. The kinds can be wildcards, "*" matches
+// all kinds, "apps/*" and "apps/v1/*" the kinds of the group, "v1/*" the kinds of the version.
 func checkKind(kinds []string, resource unstructured.Unstructured) bool {
 	for _, kind := range kinds {
 		SplitGVK := strings.Split(kind, "/")
 		if len(SplitGVK) == 1 {
-			if resource.GetKind() == kind {
+			if kind == "*" || resource.GetKind() == kind {
 				return true
 			}
 		} else if len(SplitGVK) == 2 {
-			if resource.GroupVersionKind().Kind == SplitGVK[1] && resource.GroupVersionKind().Version == SplitGVK[0] {
+			if SplitGVK[1] == "*" {
+				if SplitGVK[0] == "*" || resource.GroupVersionKind().Version == SplitGVK[0] || resource.GroupVersionKind().Group == SplitGVK[0] {
+					return true
+				}
+			} else if resource.GroupVersionKind().Kind == SplitGVK[1] && resource.GroupVersionKind().Version == SplitGVK[0] {
 				return true
 			}
 		} else {
-			if resource.GroupVersionKind().Group == SplitGVK[0] && resource.GroupVersionKind().Kind == SplitGVK[2] && (resource.GroupVersionKind().Version == SplitGVK[1] || resource.GroupVersionKind().Version == "*") {
+			if resource.GroupVersionKind().Group == SplitGVK[0] && (resource.GroupVersionKind().Kind == SplitGVK[2] || SplitGVK[2] == "*") && (resource.GroupVersionKind().Version == SplitGVK[1] || SplitGVK[1] == "*" || resource.GroupVersionKind().Version == "*") {
 				return true
 			}
 		}
@@ -111,20 +120,24 @@ func checkSelector(labelSelector *metav1.LabelSelector, resourceLabels map[strin
 // 		Kinds      []string
 // 		Name       string
 // 		Namespaces []string
+// 		Annotations map[string]string
 // 		Selector
+// 		Operations []string
+// 		DryRun
 // UserInfo:
 // 		Roles        []string
 // 		ClusterRoles []string
 // 		Subjects     []rbacv1.Subject
+// 		SubjectsKind string
 // To filter out the targeted resources with ResourceDescription, the check
 // should be: AND across attributes but an OR inside attributes that of type list
 // To filter out the targeted resources with UserInfo, the check
-// should be: OR (across & inside) attributes
+// should be: OR (across & inside) attributes, except SubjectsKind which is ANDed
 func doesResourceMatchConditionBlock(conditionBlock kyverno.ResourceDescription, userInfo kyverno.UserInfo, admissionInfo kyverno.RequestInfo, resource unstructured.Unstructured, dynamicConfig []string, namespaceLabels map[string]string) []error {
 	var errs []error
 
 	if len(conditionBlock.Kinds) > 0 {
-		if !checkKind(conditionBlock.Kinds, resource) {
+		if !checkKinds(conditionBlock.Kinds, resource, admissionInfo.SubResource) {
 			errs = append(errs, fmt.Errorf("kind does not match %v", conditionBlock.Kinds))
 		}
 	}
@@ -182,6 +195,24 @@ func doesResourceMatchConditionBlock(conditionBlock kyverno.ResourceDescription,
 		}
 	}
 
+	if len(conditionBlock.Operations) > 0 {
+		if !utils.ContainsString(conditionBlock.Operations, admissionInfo.Operation) {
+			errs = append(errs, fmt.Errorf("operation does not match %v", conditionBlock.Operations))
+		}
+	}
+
+	if conditionBlock.DryRun != "" && conditionBlock.DryRun != kyverno.DryRunAny {
+		if !checkDryRun(conditionBlock.DryRun, admissionInfo.DryRun) {
+			errs = append(errs, fmt.Errorf("dry-run does not match %s", conditionBlock.DryRun))
+		}
+	}
+
+	if userInfo.SubjectsKind != "" {
+		if !checkSubjectsKind(userInfo, admissionInfo.AdmissionUserInfo) {
+			errs = append(errs, fmt.Errorf("user info does not match subjects kind %s", userInfo.SubjectsKind))
+		}
+	}
+
 	keys := append(admissionInfo.AdmissionUserInfo.Groups, admissionInfo.AdmissionUserInfo.Username)
 	var userInfoErrors []error
 	var checkedItem int
@@ -255,6 +286,37 @@ func matchSubjects(ruleSubjects []rbacv1.Subject, userInfo authenticationv1.User
 	return false
 }
 
+// checkDryRun checks the dry-run of the request, a request of unknown dry-run does not match
+func checkDryRun(dryRun kyverno.DryRunMatch, requestDryRun *bool) bool {
+	if requestDryRun == nil {
+		return false
+	}
+
+	return string(dryRun) == strconv.FormatBool(*requestDryRun)
+}
+
+// checkSubjectsKind checks the kind of the requestor, the requests of the Group kind are
+// matched by one of the Group subjects
+func checkSubjectsKind(userInfo kyverno.UserInfo, admissionUserInfo authenticationv1.UserInfo) bool {
+	isServiceAccount := strings.HasPrefix(admissionUserInfo.Username, userinfo.SaPrefix)
+	switch userInfo.SubjectsKind {
+	case "ServiceAccount":
+		return isServiceAccount
+	case "User":
+		return admissionUserInfo.Username != "" && !isServiceAccount
+	case "Group":
+		var groups []rbacv1.Subject
+		for _, subject := range userInfo.Subjects {
+			if subject.Kind == "Group" {
+				groups = append(groups, subject)
+			}
+		}
+		return matchSubjects(groups, admissionUserInfo, nil)
+	}
+
+	return false
+}
+
 //MatchesResourceDescription checks if the resource matches resource description of the rule or not
 func MatchesResourceDescription(resourceRef unstructured.Unstructured, ruleRef kyverno.Rule, admissionInfoRef kyverno.RequestInfo, dynamicConfig []string, namespaceLabels map[string]string) error {
 
@@ -373,3 +435,18 @@ func ManagedPodResource(policy kyverno.ClusterPolicy, resource unstructured.Unst
 
 	return false
 }
+
+// setRuleDocumentation sets the ID and documentation URL of the rules on the rule responses
+func setRuleDocumentation(policy kyverno.ClusterPolicy, resp *response.EngineResponse) {
+	rules := make(map[string]kyverno.Rule, len(policy.Spec.Rules))
+	for _, rule := range policy.Spec.Rules {
+		rules[rule.Name] = rule
+	}
+
+	for i := range resp.PolicyResponse.Rules {
+		if rule, ok := rules[resp.PolicyResponse.Rules[i].Name]; ok {
+			resp.PolicyResponse.Rules[i].ID = rule.ID
+			resp.PolicyResponse.Rules[i].DocumentationURL = rule.DocumentationURL
+		}
+	}
+}
@@ -31,22 +31,23 @@ func (ri RuleType) String() string {
 }
 
 // ApplyPatches patches given resource with given patches and returns patched document
-// return original resource if any error occurs
+// return original resource if any error occurs. The patches are not logged, they may
+// include sensitive data; the callers log them with the masking loggers.
 func ApplyPatches(resource []byte, patches [][]byte) ([]byte, error) {
 	joinedPatches := JoinPatches(patches)
 	patch, err := jsonpatch.DecodePatch(joinedPatches)
 	if err != nil {
-		log.Log.V(4).Info("failed to decode JSON patch", "patch", patch)
+		log.Log.V(4).Info("failed to decode JSON patch", "patches", len(patches))
 		return resource, err
 	}
 
 	patchedDocument, err := patch.Apply(resource)
 	if err != nil {
-		log.Log.V(4).Info("failed to apply JSON patch", "patch", patch)
+		log.Log.V(4).Info("failed to apply JSON patch", "patches", len(patches))
 		return resource, err
 	}
 
-	log.Log.V(4).Info("applied JSON patch", "patch", patch)
+	log.Log.V(4).Info("applied JSON patch", "patches", len(patches))
 	return patchedDocument, err
 }
 
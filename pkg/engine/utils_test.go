@@ -8,6 +8,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"gotest.tools/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestMatchesResourceDescription(t *testing.T) {
@@ -690,3 +691,227 @@ func TestManagedPodResource(t *testing.T) {
 		assert.Equal(t, res, tc.expectedResult, "test %d/%s failed, expect %v, got %v", i+1, tc.name, tc.expectedResult, res)
 	}
 }
+
+func TestMatchesResourceDescription_AdmissionRequest(t *testing.T) {
+	dryRun, notDryRun := true, false
+	user := kyverno.RequestInfo{Operation: "CREATE", DryRun: &notDryRun}
+	user.AdmissionUserInfo.Username = "alice"
+	user.AdmissionUserInfo.Groups = []string{"developers", "system:authenticated"}
+	serviceAccount := kyverno.RequestInfo{Operation: "DELETE", DryRun: &dryRun}
+	serviceAccount.AdmissionUserInfo.Username = "system:serviceaccount:ci:deployer"
+
+	testCases := []struct {
+		name          string
+		rule          []byte
+		admissionInfo kyverno.RequestInfo
+		match         bool
+	}{
+		{
+			name:          "operation-matched",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"operations":["CREATE","UPDATE"]}}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			name:          "operation-not-matched",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"operations":["CREATE","UPDATE"]}}}`),
+			admissionInfo: serviceAccount,
+			match:         false,
+		},
+		{
+			name:          "operation-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"operations":["DELETE"]}}}`),
+			admissionInfo: serviceAccount,
+			match:         false,
+		},
+		{
+			name:          "operation-not-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"operations":["DELETE"]}}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			// the operation is not known in the background, the rules restricted to operations do not match
+			name:  "operation-background",
+			rule:  []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"operations":["UPDATE"]}}}`),
+			match: false,
+		},
+		{
+			name:  "dry-run-false-background",
+			rule:  []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":false}}}`),
+			match: false,
+		},
+		{
+			name:  "dry-run-any-background",
+			rule:  []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":"any"}}}`),
+			match: true,
+		},
+		{
+			name:  "operation-excluded-background",
+			rule:  []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"operations":["CREATE"]}}}`),
+			match: true,
+		},
+		{
+			name:          "dry-run-true",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":true}}}`),
+			admissionInfo: serviceAccount,
+			match:         true,
+		},
+		{
+			name:          "dry-run-true-not-matched",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":true}}}`),
+			admissionInfo: user,
+			match:         false,
+		},
+		{
+			name:          "dry-run-false",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":"false"}}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			name:          "dry-run-any",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"],"dryRun":"any"}}}`),
+			admissionInfo: serviceAccount,
+			match:         true,
+		},
+		{
+			name:          "dry-run-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"dryRun":true}}}`),
+			admissionInfo: serviceAccount,
+			match:         false,
+		},
+		{
+			name:          "dry-run-not-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"dryRun":true}}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			name:          "subjects-kind-service-account",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: serviceAccount,
+			match:         true,
+		},
+		{
+			name:          "subjects-kind-service-account-not-matched",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: user,
+			match:         false,
+		},
+		{
+			name:          "subjects-kind-user",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"subjectsKind":"User"}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			name:          "subjects-kind-group",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"subjectsKind":"Group","subjects":[{"kind":"Group","name":"developers"}]}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			name:          "subjects-kind-group-not-matched",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"subjectsKind":"Group","subjects":[{"kind":"Group","name":"admins"}]}}`),
+			admissionInfo: user,
+			match:         false,
+		},
+		{
+			// the subjects kind is ANDed with the other user info fields
+			name:          "subjects-kind-and-roles",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]},"clusterRoles":["admin"],"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: kyverno.RequestInfo{ClusterRoles: []string{"admin"}, AdmissionUserInfo: user.AdmissionUserInfo},
+			match:         false,
+		},
+		{
+			name:          "subjects-kind-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: serviceAccount,
+			match:         false,
+		},
+		{
+			name:          "subjects-kind-not-excluded",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: user,
+			match:         true,
+		},
+		{
+			// the deletes of the service accounts are excluded unless they are dry-run
+			name:          "combined-exclude",
+			rule:          []byte(`{"name":"test","match":{"resources":{"kinds":["Pod"]}},"exclude":{"resources":{"operations":["DELETE"],"dryRun":false},"subjectsKind":"ServiceAccount"}}`),
+			admissionInfo: serviceAccount,
+			match:         true,
+		},
+	}
+
+	resource, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test","namespace":"default"}}`))
+	assert.NilError(t, err)
+
+	for _, tc := range testCases {
+		var rule kyverno.Rule
+		assert.NilError(t, json.Unmarshal(tc.rule, &rule), tc.name)
+
+		err := MatchesResourceDescription(*resource, rule, tc.admissionInfo, []string{}, nil)
+		assert.Equal(t, err == nil, tc.match, "%s: %v", tc.name, err)
+	}
+}
+
+func Test_CheckKind_Wildcards(t *testing.T) {
+	pod, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"}}`))
+	assert.NilError(t, err)
+	deployment, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test"}}`))
+	assert.NilError(t, err)
+
+	testCases := []struct {
+		kind       string
+		pod        bool
+		deployment bool
+	}{
+		{kind: "*", pod: true, deployment: true},
+		{kind: "*/*", pod: true, deployment: true},
+		{kind: "v1/*", pod: true, deployment: true},
+		{kind: "apps/*", pod: false, deployment: true},
+		{kind: "apps/v1/*", pod: false, deployment: true},
+		{kind: "apps/v1beta1/*", pod: false, deployment: false},
+		{kind: "apps/*/Deployment", pod: false, deployment: true},
+		{kind: "batch/*", pod: false, deployment: false},
+		{kind: "Pod", pod: true, deployment: false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, checkKind([]string{tc.kind}, *pod), tc.pod, tc.kind)
+		assert.Equal(t, checkKind([]string{tc.kind}, *deployment), tc.deployment, tc.kind)
+	}
+}
+
+func TestCheckKinds_SubResource(t *testing.T) {
+	pod, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"}}`))
+	assert.NilError(t, err)
+	execOptions, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"v1","kind":"PodExecOptions","command":["sh"]}`))
+	assert.NilError(t, err)
+
+	testCases := []struct {
+		kind        string
+		resource    unstructured.Unstructured
+		subResource string
+		matches     bool
+	}{
+		{kind: "pods/exec", resource: *execOptions, subResource: "pods/exec", matches: true},
+		{kind: "Pod/exec", resource: *execOptions, subResource: "pods/exec", matches: true},
+		{kind: "v1/pods/exec", resource: *execOptions, subResource: "pods/exec", matches: true},
+		{kind: "*/pods/exec", resource: *execOptions, subResource: "pods/exec", matches: true},
+		{kind: "pods/exec", resource: *pod, subResource: "", matches: false},
+		{kind: "pods/exec", resource: *pod, subResource: "pods/ephemeralcontainers", matches: false},
+		{kind: "Pod", resource: *execOptions, subResource: "pods/exec", matches: false},
+		{kind: "pods/ephemeralcontainers", resource: *pod, subResource: "pods/ephemeralcontainers", matches: true},
+		{kind: "services/status", resource: *pod, subResource: "pods/status", matches: false},
+		// the object of the status subresource is the resource
+		{kind: "Pod", resource: *pod, subResource: "pods/status", matches: true},
+		{kind: "apps/*", resource: *pod, subResource: "pods/status", matches: false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, checkKinds([]string{tc.kind}, tc.resource, tc.subResource), tc.matches, "%s %s", tc.kind, tc.subResource)
+	}
+}
@@ -0,0 +1,98 @@
+package validate
+
+import (
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/engine/anchor"
+	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
+	"github.com/kyverno/kyverno/pkg/engine/common"
+)
+
+// checkGlobalAnchors returns false if a global anchor of the pattern, e.g. "<(hostPath)", is not satisfied
+// by the resource. Unlike a condition anchor, which only applies to the fields of its own map, a global
+// anchor gates the whole pattern: it is satisfied if one of the resource elements it applies to has the
+// field of the anchor and the value of the field matches the pattern of the anchor.
+func checkGlobalAnchors(log logr.Logger, resource, pattern interface{}) bool {
+	satisfied := make(map[string]bool)
+	evaluateGlobalAnchors(log, resource, pattern, "/", satisfied)
+	for path, ok := range satisfied {
+		if !ok {
+			log.V(3).Info("global anchor is not satisfied, skipping the pattern", "path", path)
+			return false
+		}
+	}
+
+	return true
+}
+
+// evaluateGlobalAnchors records, by path in the pattern, if the global anchors are satisfied by the resource
+func evaluateGlobalAnchors(log logr.Logger, resourceElement, patternElement interface{}, path string, satisfied map[string]bool) {
+	switch typedPattern := patternElement.(type) {
+	case map[string]interface{}:
+		if anchor.IsSelectionMap(typedPattern) {
+			resourceArray, _ := resourceElement.([]interface{})
+			for key, value := range typedPattern {
+				if !commonAnchors.IsSelectionAnchor(key) {
+					continue
+				}
+
+				currentPath := path + key + "/"
+				selected := anchor.SelectedElements(resourceArray, key)
+				if len(selected) == 0 {
+					evaluateGlobalAnchors(log, nil, value, currentPath, satisfied)
+				}
+				for _, resource := range selected {
+					evaluateGlobalAnchors(log, resource, value, currentPath, satisfied)
+				}
+			}
+			return
+		}
+
+		resourceMap, _ := resourceElement.(map[string]interface{})
+		for key, value := range typedPattern {
+			anchorKey, _ := commonAnchors.RemoveAnchor(key)
+			currentPath := path + key + "/"
+			if !commonAnchors.IsGlobalAnchor(key) {
+				evaluateGlobalAnchors(log, resourceMap[anchorKey], value, currentPath, satisfied)
+				continue
+			}
+
+			if satisfied[currentPath] {
+				continue
+			}
+
+			satisfied[currentPath] = false
+			if resourceValue, ok := resourceMap[anchorKey]; ok {
+				_, err := validateResourceElement(log, resourceValue, value, value, currentPath, common.NewAnchorMap())
+				satisfied[currentPath] = err == nil || common.IsConditionalAnchorError(err.Error())
+			}
+		}
+
+	case []interface{}:
+		if len(typedPattern) == 0 {
+			return
+		}
+
+		resourceArray, _ := resourceElement.([]interface{})
+		// the first map of a pattern list applies to each element of the resource list
+		if _, ok := typedPattern[0].(map[string]interface{}); ok {
+			currentPath := path + "0/"
+			if len(resourceArray) == 0 {
+				evaluateGlobalAnchors(log, nil, typedPattern[0], currentPath, satisfied)
+			}
+			for _, resource := range resourceArray {
+				evaluateGlobalAnchors(log, resource, typedPattern[0], currentPath, satisfied)
+			}
+			return
+		}
+
+		for i, pattern := range typedPattern {
+			var resource interface{}
+			if i < len(resourceArray) {
+				resource = resourceArray[i]
+			}
+			evaluateGlobalAnchors(log, resource, pattern, path+strconv.Itoa(i)+"/", satisfied)
+		}
+	}
+}
@@ -15,7 +15,23 @@ import (
 
 // ValidateResourceWithPattern is a start of element-by-element validation process
 // It assumes that validation is started from root, so "/" is passed
+// The pattern is skipped if one of its global anchors is not satisfied by the resource
+// Resources exceeding the complexity limits fail with an error wrapping common.ErrResourceTooComplex
+// if the limits are enforced
 func ValidateResourceWithPattern(log logr.Logger, resource, pattern interface{}) (string, error) {
+	limits := common.GetComplexityLimits()
+	if err := common.CheckComplexity(resource, limits); err != nil {
+		if limits.Enforce {
+			log.V(3).Info("resource exceeds the complexity limits", "reason", err.Error())
+			return "/", err
+		}
+		log.Info("validating a resource exceeding the complexity limits", "reason", err.Error())
+	}
+
+	if !checkGlobalAnchors(log, resource, pattern) {
+		return "", nil
+	}
+
 	// newAnchorMap - to check anchor key has values
 	ac := common.NewAnchorMap()
 	elemPath, err := validateResourceElement(log, resource, pattern, pattern, "/", ac)
@@ -39,6 +55,16 @@ func validateResourceElement(log logr.Logger, resourceElement, patternElement, o
 	switch typedPatternElement := patternElement.(type) {
 	// map
 	case map[string]interface{}:
+		// the selection anchors select the elements of a list
+		if anchor.IsSelectionMap(typedPatternElement) {
+			switch typedResourceElement := resourceElement.(type) {
+			case []interface{}:
+				return anchor.ValidateSelection(validateResourceElement, typedResourceElement, typedPatternElement, originPattern, path, ac)
+			case nil:
+				return anchor.ValidateSelection(validateResourceElement, nil, typedPatternElement, originPattern, path, ac)
+			}
+		}
+
 		typedResourceElement, ok := resourceElement.(map[string]interface{})
 		if !ok {
 			log.V(4).Info("Pattern and resource have different structures.", "path", path, "expected", fmt.Sprintf("%T", patternElement), "current", fmt.Sprintf("%T", resourceElement))
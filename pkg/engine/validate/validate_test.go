@@ -2,6 +2,7 @@ package validate
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -1522,3 +1523,178 @@ func TestConditionalAnchorWithMultiplePatterns(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectionAnchor(t *testing.T) {
+	limitsPattern := []byte(`{"spec": {"containers": {"+(name=istio-proxy)": {"resources": {"limits": {"memory": "256Mi"}}}}}}`)
+
+	testCases := []struct {
+		name     string
+		pattern  []byte
+		resource []byte
+		err      string
+	}{
+		{
+			name:     "present-compliant",
+			pattern:  limitsPattern,
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx"}, {"name": "istio-proxy", "image": "proxyv2", "resources": {"limits": {"memory": "256Mi"}}}]}}`),
+		},
+		{
+			name:     "present-violating",
+			pattern:  limitsPattern,
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx"}, {"name": "istio-proxy", "image": "proxyv2", "resources": {"limits": {"memory": "1Gi"}}}]}}`),
+			err:      "element +(name=istio-proxy): Validation rule failed at '/spec/containers/1/resources/limits/memory/' to validate value '1Gi' with pattern '256Mi'",
+		},
+		{
+			name:     "absent",
+			pattern:  limitsPattern,
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx", "resources": {"limits": {"memory": "256Mi"}}}]}}`),
+			err:      "Validation rule failed at /spec/containers/, element name=istio-proxy is not present",
+		},
+		{
+			name:     "absent-list",
+			pattern:  limitsPattern,
+			resource: []byte(`{"spec": {}}`),
+			err:      "Validation rule failed at /spec/containers/, element name=istio-proxy is not present",
+		},
+		{
+			name:     "multiple-fields",
+			pattern:  []byte(`{"spec": {"containers": {"+(name=istio-proxy, image=proxyv2)": {"resources": {"limits": {"memory": "?*"}}}}}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "istio-proxy", "image": "proxyv1"}, {"name": "istio-proxy", "image": "proxyv2", "resources": {"limits": {"memory": "256Mi"}}}]}}`),
+		},
+		{
+			name:     "multiple-selectors",
+			pattern:  []byte(`{"spec": {"containers": {"+(name=istio-proxy)": {"resources": {"limits": {"memory": "?*"}}}, "+(name=nginx)": {"image": "nginx:*"}}}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx"}, {"name": "istio-proxy", "image": "proxyv2", "resources": {"limits": {"memory": "256Mi"}}}]}}`),
+			err:      "element +(name=nginx): Validation rule failed at '/spec/containers/0/image/' to validate value 'nginx' with pattern 'nginx:*'",
+		},
+		{
+			name:     "numeric-field",
+			pattern:  []byte(`{"spec": {"containers": [{"ports": {"+(containerPort=8080)": {"protocol": "TCP"}}}]}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "ports": [{"containerPort": 80, "protocol": "UDP"}, {"containerPort": 8080, "protocol": "TCP"}]}]}}`),
+		},
+		{
+			name:     "negated-selector-absent",
+			pattern:  []byte(`{"spec": {"containers": {"X(name=debug)": null}}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx"}]}}`),
+		},
+		{
+			name:     "negated-selector-present",
+			pattern:  []byte(`{"spec": {"containers": {"X(name=debug)": null}}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "image": "nginx"}, {"name": "debug", "image": "busybox"}]}}`),
+			err:      "Validation rule failed at /spec/containers/1/, element name=debug is disallowed",
+		},
+		{
+			name:     "negation-in-selected-element",
+			pattern:  []byte(`{"spec": {"containers": {"+(name=istio-proxy)": {"securityContext": {"X(privileged)": null}}}}}`),
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx", "securityContext": {"privileged": true}}, {"name": "istio-proxy", "securityContext": {"privileged": true}}]}}`),
+			err:      "element +(name=istio-proxy): Validation rule failed at /spec/containers/1/securityContext/privileged/, field privileged is disallowed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var pattern, resource interface{}
+		assert.NilError(t, json.Unmarshal(testCase.pattern, &pattern))
+		assert.NilError(t, json.Unmarshal(testCase.resource, &resource))
+
+		_, err := ValidateResourceWithPattern(log.Log, resource, pattern)
+		if testCase.err == "" {
+			assert.NilError(t, err, testCase.name)
+		} else {
+			assert.Error(t, err, testCase.err, testCase.name)
+		}
+	}
+}
+
+func TestGlobalAnchor(t *testing.T) {
+	hostPathPattern := []byte(`{"spec": {"volumes": [{"<(hostPath)": {"path": "/var/*"}}], "containers": [{"securityContext": {"readOnlyRootFilesystem": true}}]}}`)
+
+	testCases := []struct {
+		name     string
+		pattern  []byte
+		resource []byte
+		err      string
+	}{
+		{
+			name:     "satisfied-compliant",
+			pattern:  hostPathPattern,
+			resource: []byte(`{"spec": {"volumes": [{"name": "logs", "hostPath": {"path": "/var/log"}}], "containers": [{"name": "nginx", "securityContext": {"readOnlyRootFilesystem": true}}]}}`),
+		},
+		{
+			name:     "satisfied-violating",
+			pattern:  hostPathPattern,
+			resource: []byte(`{"spec": {"volumes": [{"name": "config", "configMap": {"name": "config"}}, {"name": "logs", "hostPath": {"path": "/var/log"}}], "containers": [{"name": "nginx", "securityContext": {"readOnlyRootFilesystem": false}}]}}`),
+			err:      "Validation rule failed at '/spec/containers/0/securityContext/readOnlyRootFilesystem/' to validate value 'false' with pattern 'true'",
+		},
+		{
+			name:     "field-absent",
+			pattern:  hostPathPattern,
+			resource: []byte(`{"spec": {"volumes": [{"name": "config", "configMap": {"name": "config"}}], "containers": [{"name": "nginx", "securityContext": {"readOnlyRootFilesystem": false}}]}}`),
+		},
+		{
+			name:     "value-not-matching",
+			pattern:  hostPathPattern,
+			resource: []byte(`{"spec": {"volumes": [{"name": "certs", "hostPath": {"path": "/etc/ssl"}}], "containers": [{"name": "nginx", "securityContext": {"readOnlyRootFilesystem": false}}]}}`),
+		},
+		{
+			name:     "list-absent",
+			pattern:  hostPathPattern,
+			resource: []byte(`{"spec": {"containers": [{"name": "nginx"}]}}`),
+		},
+		{
+			name:     "parent-field",
+			pattern:  []byte(`{"metadata": {"labels": {"tier": "system"}}, "spec": {"containers": [{"securityContext": {"<(privileged)": true}}]}}`),
+			resource: []byte(`{"metadata": {"labels": {"tier": "web"}}, "spec": {"containers": [{"name": "nginx"}, {"name": "agent", "securityContext": {"privileged": true}}]}}`),
+			err:      "Validation rule failed at '/metadata/labels/tier/' to validate value 'web' with pattern 'system'",
+		},
+		{
+			name:     "selected-element",
+			pattern:  []byte(`{"metadata": {"labels": {"sidecar": "istio"}}, "spec": {"containers": {"+(name=istio-proxy)": {"<(image)": "proxyv2*"}}}}`),
+			resource: []byte(`{"metadata": {"labels": {}}, "spec": {"containers": [{"name": "nginx", "image": "proxyv2"}, {"name": "istio-proxy", "image": "proxyv1"}]}}`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		var pattern, resource interface{}
+		assert.NilError(t, json.Unmarshal(testCase.pattern, &pattern))
+		assert.NilError(t, json.Unmarshal(testCase.resource, &resource))
+
+		_, err := ValidateResourceWithPattern(log.Log, resource, pattern)
+		if testCase.err == "" {
+			assert.NilError(t, err, testCase.name)
+		} else {
+			assert.Error(t, err, testCase.err, testCase.name)
+		}
+	}
+}
+
+func Test_ValidateResourceWithPattern_ComplexityLimits(t *testing.T) {
+	defer common.SetComplexityLimits(common.GetComplexityLimits())
+
+	items := make([]interface{}, 50)
+	for i := range items {
+		items[i] = map[string]interface{}{"name": "item"}
+	}
+	resource := map[string]interface{}{"spec": map[string]interface{}{"items": items}}
+	pattern := map[string]interface{}{"spec": map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "?*"}}}}
+
+	common.SetComplexityLimits(common.DefaultComplexityLimits)
+	_, err := ValidateResourceWithPattern(log.Log, resource, pattern)
+	assert.NilError(t, err)
+
+	common.SetComplexityLimits(common.ComplexityLimits{MaxElements: 10, Enforce: true})
+	path, err := ValidateResourceWithPattern(log.Log, resource, pattern)
+	assert.Assert(t, errors.Is(err, common.ErrResourceTooComplex))
+	assert.Equal(t, path, "/")
+
+	common.SetComplexityLimits(common.ComplexityLimits{MaxDepth: 2, Enforce: true})
+	_, err = ValidateResourceWithPattern(log.Log, resource, pattern)
+	assert.Assert(t, errors.Is(err, common.ErrResourceTooComplex))
+
+	common.SetComplexityLimits(common.ComplexityLimits{MaxNodes: 20, Enforce: true})
+	_, err = ValidateResourceWithPattern(log.Log, resource, pattern)
+	assert.Assert(t, errors.Is(err, common.ErrResourceTooComplex))
+
+	common.SetComplexityLimits(common.ComplexityLimits{MaxNodes: 20})
+	_, err = ValidateResourceWithPattern(log.Log, resource, pattern)
+	assert.NilError(t, err)
+}
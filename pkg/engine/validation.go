@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -11,10 +12,12 @@ import (
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/engine/common"
 	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/deprecations"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"github.com/kyverno/kyverno/pkg/engine/validate"
 	"github.com/kyverno/kyverno/pkg/engine/variables"
+	kyvernoutils "github.com/kyverno/kyverno/pkg/utils"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -43,7 +46,7 @@ func buildLogger(ctx *PolicyContext) logr.Logger {
 		logger = logger.WithValues("kind", ctx.NewResource.GetKind(), "namespace", ctx.NewResource.GetNamespace(), "name", ctx.NewResource.GetName())
 	}
 
-	return logger
+	return ctx.masker().Logger(ctx.logger(logger))
 }
 
 func buildResponse(logger logr.Logger, ctx *PolicyContext, resp *response.EngineResponse, startTime time.Time) {
@@ -67,7 +70,23 @@ func buildResponse(logger logr.Logger, ctx *PolicyContext, resp *response.Engine
 	resp.PolicyResponse.Resource.Namespace = resp.PatchedResource.GetNamespace()
 	resp.PolicyResponse.Resource.Kind = resp.PatchedResource.GetKind()
 	resp.PolicyResponse.Resource.APIVersion = resp.PatchedResource.GetAPIVersion()
-	resp.PolicyResponse.ValidationFailureAction = ctx.Policy.Spec.ValidationFailureAction
+	resp.PolicyResponse.ValidationFailureAction = ctx.Policy.GetValidationFailureActionForThreshold(resp.PatchedResource.GetNamespace(), ctx.EnforcementThreshold)
+	resp.PolicyResponse.CorrelationID = ctx.CorrelationID
+	setRuleDocumentation(ctx.Policy, resp)
+	setTimeWindowMode(ctx, resp)
+	setResourceAge(ctx, resp)
+	if ctx.Policy.IsRollingOut() || ctx.Policy.AllowsNamespaceThresholds() {
+		// reports show which mode applied to the resource during the rollout or with the namespace threshold
+		for i := range resp.PolicyResponse.Rules {
+			if resp.PolicyResponse.Rules[i].Properties == nil {
+				resp.PolicyResponse.Rules[i].Properties = make(map[string]string)
+			}
+			resp.PolicyResponse.Rules[i].Properties[response.RulePropertyValidationFailureAction] = resp.PolicyResponse.ValidationFailureAction
+		}
+	}
+
+	// the rules are evaluated on the data of the sensitive resources, the messages and reports must not include it
+	ctx.masker(resp.PatchedResource).Response(resp)
 	resp.PolicyResponse.ProcessingTime = time.Since(startTime)
 	resp.PolicyResponse.PolicyExecutionTimestamp = startTime.Unix()
 }
@@ -87,96 +106,285 @@ func validateResource(log logr.Logger, ctx *PolicyContext) *response.EngineRespo
 	defer ctx.JSONContext.Restore()
 
 	for _, rule := range ctx.Policy.Spec.Rules {
-		var err error
-
 		if !rule.HasValidate() {
 			continue
 		}
 
 		log = log.WithValues("rule", rule.Name)
+		trace := ctx.traceRule(rule, utils.Validation.String())
 
-		if !matches(log, rule, ctx) {
+		if err := matchError(rule, ctx); err != nil {
+			log.V(4).Info("resource does not match rule", "reason", err.Error())
+			trace.skip(fmt.Sprintf("resource does not match: %v", err))
 			continue
 		}
 
-		ctx.JSONContext.Restore()
-		if err := LoadContext(log, rule.Context, ctx.ResourceCache, ctx, rule.Name); err != nil {
-			if _, ok := err.(gojmespath.NotFoundError); ok {
-				log.V(3).Info("failed to load context", "reason", err.Error())
-			} else {
-				log.Error(err, "failed to load context")
-			}
+		if !matchesRuleMinimumAge(log, rule, ctx) {
+			trace.skip("resource is younger than the minimum age")
 			continue
 		}
 
-		log.V(3).Info("matched validate rule")
-
-		// operate on the copy of the conditions, as we perform variable substitution
-		preconditionsCopy, err := copyConditions(rule.AnyAllConditions)
-		if err != nil {
-			log.V(2).Info("wrongfully configured data", "reason", err.Error())
+		if apply, ruleResp := checkDeleting(log, rule, ctx, utils.Validation.String()); !apply {
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			trace.done(ruleResp, "")
 			continue
 		}
 
-		// evaluate pre-conditions
-		if !variables.EvaluateConditions(log, ctx.JSONContext, preconditionsCopy, true) {
-			log.V(4).Info("resource fails the preconditions")
+		if apply, ruleResp := checkTimeWindows(log, rule, ctx, utils.Validation.String()); !apply {
+			if ruleResp != nil {
+				resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			}
+			trace.done(ruleResp, "rule is outside of its time windows")
 			continue
 		}
 
-		if rule, err = variables.SubstituteAllInRule(log, ctx.JSONContext, rule); err != nil {
-			ruleResp := response.RuleResponse{
-				Name:    rule.Name,
-				Type:    utils.Validation.String(),
-				Message: fmt.Sprintf("variable substitution failed for rule %s: %s", rule.Name, err.Error()),
-				Success: true,
-			}
-
-			incrementAppliedCount(resp)
-			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResp)
-
-			switch err.(type) {
-			case gojmespath.NotFoundError:
-				log.V(2).Info("failed to substitute variables, skip current rule", "info", err.Error(), "rule name", rule.Name)
-			default:
-				log.Error(err, "failed to substitute variables, skip current rule", "rule name", rule.Name)
-			}
+		if apply, ruleResp := checkExceptions(log, rule, ctx, utils.Validation.String()); !apply {
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
+			trace.done(ruleResp, "")
 			continue
 		}
 
-		if rule.Validation.Pattern != nil || rule.Validation.AnyPattern != nil {
-			ruleResponse := validateResourceWithRule(log, ctx, rule)
-			if ruleResponse != nil {
-				if !common.IsConditionalAnchorError(ruleResponse.Message) {
+		var namespaceVersion string
+		if rule.IsNamespaceScoped() {
+			cachedResp, found, version := ctx.NamespaceRuleCache.get(ctx, rule)
+			if found {
+				log.V(4).Info("reusing the rule response of the namespace", "namespace", ctx.namespace())
+				if cachedResp != nil {
 					incrementAppliedCount(resp)
-					resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResponse)
+					resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *cachedResp)
 				}
-			}
-		} else if rule.Validation.Deny != nil {
-			denyConditionsCopy, err := copyConditions(rule.Validation.Deny.AnyAllConditions)
-			if err != nil {
-				log.V(2).Info("wrongfully configured data", "reason", err.Error())
+				trace.done(cachedResp, "rule response of the namespace is reused")
 				continue
 			}
-			deny := variables.EvaluateConditions(log, ctx.JSONContext, denyConditionsCopy, false)
-			ruleResp := response.RuleResponse{
-				Name:    rule.Name,
-				Type:    utils.Validation.String(),
-				Message: rule.Validation.Message,
-				Success: !deny,
-			}
 
+			namespaceVersion = version
+		}
+
+		ruleResp := processValidationRule(log, ctx, rule)
+		ctx.setContextResolutions(ruleResp)
+		if rule.IsNamespaceScoped() {
+			ctx.NamespaceRuleCache.set(ctx, rule, namespaceVersion, ruleResp)
+		}
+
+		if ruleResp != nil {
 			incrementAppliedCount(resp)
-			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, ruleResp)
+			resp.PolicyResponse.Rules = append(resp.PolicyResponse.Rules, *ruleResp)
 		}
+		trace.done(ruleResp, "context entries not found or resource fails the preconditions")
 	}
 
 	return resp
 }
 
+// processValidationRule applies the validate rule on the resource, it returns nil if the rule
+// does not apply, e.g. the resource fails the preconditions
+func processValidationRule(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	ctx.JSONContext.Restore()
+	if err := LoadContext(log, rule.Context, ctx.ResourceCache, ctx, rule.Name); err != nil {
+		if _, ok := err.(gojmespath.NotFoundError); ok {
+			log.V(3).Info("failed to load context", "reason", err.Error())
+			return nil
+		}
+
+		log.Error(err, "failed to load context")
+		ruleResp := ruleError(rule, "failed to load context", err)
+		return &ruleResp
+	}
+
+	log.V(3).Info("matched validate rule")
+
+	// operate on the copy of the conditions, as we perform variable substitution
+	preconditionsCopy, err := copyConditions(rule.AnyAllConditions)
+	if err != nil {
+		log.V(2).Info("wrongfully configured data", "reason", err.Error())
+		return nil
+	}
+
+	// evaluate pre-conditions
+	if !variables.EvaluateConditions(log, ctx.JSONContext, preconditionsCopy, true) {
+		log.V(4).Info("resource fails the preconditions")
+		return nil
+	}
+
+	if rule.HasValidateForEach() {
+		// the variables are substituted for each element
+		return validateForEach(log, ctx, rule)
+	}
+
+	deny := rule.Validation.Deny
+	message, messages := rule.Validation.Message, rule.Validation.Messages
+	rule.Validation.Message, rule.Validation.Messages = "", nil
+	if rule, err = variables.SubstituteAllInRule(log, ctx.JSONContext, rule); err != nil {
+		ruleResp := response.RuleResponse{
+			Name:    rule.Name,
+			Type:    utils.Validation.String(),
+			Message: fmt.Sprintf("variable substitution failed for rule %s: %s", rule.Name, err.Error()),
+			Success: true,
+		}
+
+		switch err.(type) {
+		case gojmespath.NotFoundError:
+			log.V(2).Info("failed to substitute variables, skip current rule", "info", err.Error(), "rule name", rule.Name)
+		default:
+			log.Error(err, "failed to substitute variables, skip current rule", "rule name", rule.Name)
+			ruleResp = ruleError(rule, "variable substitution failed", err)
+		}
+
+		return &ruleResp
+	}
+	restoreDenyConditions(&rule.Validation, deny)
+	substituteMessages(log, ctx.JSONContext, &rule.Validation, message, messages)
+
+	// the default message is reported, the message for the locale of the request is displayed
+	defaultMessage, message, locale := localizedMessages(ctx, rule.Validation)
+	rule.Validation.Message = defaultMessage
+
+	ruleResp := applyValidationRule(log, ctx, rule)
+	localizeRuleResponse(ruleResp, defaultMessage, message, locale)
+	return ruleResp
+}
+
+// restoreDenyConditions restores the deny conditions of a substituted validation, the variables of
+// the conditions are substituted once when they are evaluated so that the escaped variables are kept
+func restoreDenyConditions(validation *kyverno.Validation, deny *kyverno.Deny) {
+	if validation.Deny != nil && deny != nil {
+		validation.Deny.AnyAllConditions = deny.AnyAllConditions
+	}
+}
+
+// substituteMessages sets the messages of a substituted validation with their variables resolved. The
+// messages are substituted apart from the rule, so that a variable of a message that is not resolved,
+// e.g. a field that the failing resource does not set, is kept as is rather than skipping the rule.
+func substituteMessages(log logr.Logger, ctx context.EvalInterface, validation *kyverno.Validation, message string, messages map[string]string) {
+	validation.Message = substituteMessage(log, ctx, message)
+	if len(messages) == 0 {
+		validation.Messages = messages
+		return
+	}
+
+	validation.Messages = make(map[string]string, len(messages))
+	for locale, variant := range messages {
+		validation.Messages[locale] = substituteMessage(log, ctx, variant)
+	}
+}
+
+func substituteMessage(log logr.Logger, ctx context.EvalInterface, message string) string {
+	if !strings.Contains(message, "{{") {
+		return message
+	}
+
+	substituted, err := variables.SubstituteAll(log, ctx, message)
+	if err != nil {
+		log.V(3).Info("failed to substitute the variables of the message", "message", message, "reason", err.Error())
+		return message
+	}
+
+	if s, ok := substituted.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(substituted)
+}
+
+// applyValidationRule applies the validate rule, with the variables substituted, on the resource
+func applyValidationRule(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	if rule.Validation.Pattern != nil || rule.Validation.AnyPattern != nil {
+		ruleResponse := validateResourceWithRule(log, ctx, rule)
+		if ruleResponse != nil && common.IsConditionalAnchorError(ruleResponse.Message) {
+			return nil
+		}
+		return ruleResponse
+	} else if rule.Validation.Deny != nil {
+		denyConditionsCopy, err := copyConditions(rule.Validation.Deny.AnyAllConditions)
+		if err != nil {
+			log.V(2).Info("wrongfully configured data", "reason", err.Error())
+			return nil
+		}
+		deny := variables.EvaluateConditions(log, ctx.JSONContext, denyConditionsCopy, false)
+		ruleResp := response.RuleResponse{
+			Name:    rule.Name,
+			Type:    utils.Validation.String(),
+			Message: rule.Validation.Message,
+			Success: !deny,
+		}
+
+		if deny {
+			ruleResp.Violations = []response.Violation{{Rule: rule.Name, Check: "deny", Message: rule.Validation.Message}}
+		}
+
+		return &ruleResp
+	} else if rule.Validation.Deprecations != nil {
+		return validateDeprecations(ctx, rule)
+	} else if len(rule.Validation.References) > 0 {
+		return validateReferences(ctx, rule)
+	} else if rule.Validation.CEL != nil {
+		return validateCEL(ctx, rule)
+	}
+
+	return nil
+}
+
+// validateDeprecations fails the resource if its API version is removed in the target Kubernetes version.
+// The deleted resources are not checked.
+func validateDeprecations(ctx *PolicyContext, rule kyverno.Rule) *response.RuleResponse {
+	resource := ctx.NewResource
+	if reflect.DeepEqual(resource, unstructured.Unstructured{}) {
+		return nil
+	}
+
+	targetVersion := rule.Validation.Deprecations.TargetVersion
+	if targetVersion == "" {
+		targetVersion = deprecations.DefaultTargetVersion()
+	}
+
+	if targetVersion == "" {
+		ruleResp := ruleError(rule, "failed to check the removed APIs", fmt.Errorf("the version of the cluster is not known, set the targetVersion"))
+		return &ruleResp
+	}
+
+	removal, err := deprecations.Find(resource.GetAPIVersion(), resource.GetKind(), targetVersion)
+	if err != nil {
+		ruleResp := ruleError(rule, "failed to check the removed APIs", err)
+		return &ruleResp
+	}
+
+	if removal == nil {
+		return &response.RuleResponse{
+			Name:    rule.Name,
+			Type:    utils.Validation.String(),
+			Message: fmt.Sprintf("%s %s is served in Kubernetes %s", resource.GetAPIVersion(), resource.GetKind(), targetVersion),
+			Success: true,
+		}
+	}
+
+	message := "validation error: " + removal.Message()
+	if rule.Validation.Message != "" {
+		message = fmt.Sprintf("validation error: %s. %s", strings.TrimSuffix(rule.Validation.Message, "."), removal.Message())
+	}
+
+	return &response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Validation.String(),
+		Message: message,
+		Success: false,
+	}
+}
+
+// ruleError builds the response of a rule that could not be processed.
+// The rule fails, unless the error is ignored according to the policy failurePolicy.
+func ruleError(rule kyverno.Rule, msg string, err error) response.RuleResponse {
+	return response.RuleResponse{
+		Name:    rule.Name,
+		Type:    utils.Validation.String(),
+		Message: fmt.Sprintf("%s for rule %s: %s", msg, rule.Name, err.Error()),
+		Success: false,
+		Error:   true,
+	}
+}
+
 func validateResourceWithRule(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule) (resp *response.RuleResponse) {
 	if reflect.DeepEqual(ctx.OldResource, unstructured.Unstructured{}) {
-		resp := validatePatterns(log, ctx.JSONContext, ctx.NewResource, rule)
+		resp := validatePatterns(log, ctx.JSONContext, withoutPlatformMutations(log, ctx, rule, ctx.NewResource), rule)
 		return &resp
 	}
 
@@ -185,8 +393,8 @@ func validateResourceWithRule(log logr.Logger, ctx *PolicyContext, rule kyverno.
 		return nil
 	}
 
-	oldResp := validatePatterns(log, ctx.JSONContext, ctx.OldResource, rule)
-	newResp := validatePatterns(log, ctx.JSONContext, ctx.NewResource, rule)
+	oldResp := validatePatterns(log, ctx.JSONContext, withoutPlatformMutations(log, ctx, rule, ctx.OldResource), rule)
+	newResp := validatePatterns(log, ctx.JSONContext, withoutPlatformMutations(log, ctx, rule, ctx.NewResource), rule)
 	if isSameRuleResponse(oldResp, newResp) {
 		log.V(3).Info("skipping modified resource as validation results have not changed")
 		return nil
@@ -195,22 +403,46 @@ func validateResourceWithRule(log logr.Logger, ctx *PolicyContext, rule kyverno.
 	return &newResp
 }
 
+// withoutPlatformMutations returns the resource without the fields owned by the platform field managers
+// if the rule ignores the platform mutations, the patterns are evaluated against the resource otherwise
+func withoutPlatformMutations(log logr.Logger, ctx *PolicyContext, rule kyverno.Rule, resource unstructured.Unstructured) unstructured.Unstructured {
+	if !rule.MatchResources.IgnorePlatformMutations || len(ctx.PlatformFieldManagers) == 0 {
+		return resource
+	}
+
+	projected, err := kyvernoutils.RemoveManagedFields(resource, ctx.PlatformFieldManagers)
+	if err != nil {
+		log.Error(err, "failed to remove the fields of the platform field managers, validating the resource as is")
+		return resource
+	}
+
+	return projected
+}
+
 // matches checks if either the new or old resource satisfies the filter conditions defined in the rule
 func matches(logger logr.Logger, rule kyverno.Rule, ctx *PolicyContext) bool {
-	err := MatchesResourceDescription(ctx.NewResource, rule, ctx.AdmissionInfo, ctx.ExcludeGroupRole, ctx.NamespaceLabels)
+	if err := matchError(rule, ctx); err != nil {
+		logger.V(4).Info("resource does not match rule", "reason", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// matchError returns why neither the new nor the old resource satisfies the filter conditions defined in the rule
+func matchError(rule kyverno.Rule, ctx *PolicyContext) error {
+	err := MatchesResourceDescription(ctx.NewResource, rule, ctx.matchInfo(), ctx.ExcludeGroupRole, ctx.NamespaceLabels)
 	if err == nil {
-		return true
+		return nil
 	}
 
 	if !reflect.DeepEqual(ctx.OldResource, unstructured.Unstructured{}) {
-		err := MatchesResourceDescription(ctx.OldResource, rule, ctx.AdmissionInfo, ctx.ExcludeGroupRole, ctx.NamespaceLabels)
-		if err == nil {
-			return true
+		if err := MatchesResourceDescription(ctx.OldResource, rule, ctx.matchInfo(), ctx.ExcludeGroupRole, ctx.NamespaceLabels); err == nil {
+			return nil
 		}
 	}
 
-	logger.V(4).Info("resource does not match rule", "reason", err.Error())
-	return false
+	return err
 }
 
 func isSameRuleResponse(r1 response.RuleResponse, r2 response.RuleResponse) bool {
@@ -253,7 +485,13 @@ func validatePatterns(log logr.Logger, ctx context.EvalInterface, resource unstr
 		if path, err := validate.ValidateResourceWithPattern(logger, resource.Object, pattern); err != nil {
 			logger.V(3).Info("validation failed", "path", path, "error", err.Error())
 			resp.Success = false
+			if errors.Is(err, common.ErrResourceTooComplex) {
+				resp.Message = err.Error()
+				return resp
+			}
 			resp.Message = buildErrorMessage(rule, path)
+			resp.FailedPaths = []string{path}
+			resp.Violations = []response.Violation{{Rule: rule.Name, Check: "pattern", Path: path, Message: rule.Validation.Message}}
 			return resp
 		}
 
@@ -265,6 +503,8 @@ func validatePatterns(log logr.Logger, ctx context.EvalInterface, resource unstr
 
 	if validationRule.AnyPattern != nil {
 		var failedAnyPatternsErrors []error
+		var failedPaths []string
+		var violations []response.Violation
 		var err error
 
 		anyPatterns, err := rule.Validation.DeserializeAnyPattern()
@@ -282,9 +522,17 @@ func validatePatterns(log logr.Logger, ctx context.EvalInterface, resource unstr
 				return resp
 			}
 
+			if errors.Is(err, common.ErrResourceTooComplex) {
+				resp.Success = false
+				resp.Message = err.Error()
+				return resp
+			}
+
 			logger.V(4).Info("validation rule failed", "anyPattern[%d]", idx, "path", path)
 			patternErr := fmt.Errorf("Rule %s[%d] failed at path %s.", rule.Name, idx, path)
 			failedAnyPatternsErrors = append(failedAnyPatternsErrors, patternErr)
+			failedPaths = append(failedPaths, path)
+			violations = append(violations, response.Violation{Rule: rule.Name, Check: fmt.Sprintf("anyPattern[%d]", idx), Path: path, Message: rule.Validation.Message})
 		}
 
 		// Any Pattern validation errors
@@ -298,6 +546,8 @@ func validatePatterns(log logr.Logger, ctx context.EvalInterface, resource unstr
 
 			resp.Success = false
 			resp.Message = buildAnyPatternErrorMessage(rule, errorStr)
+			resp.FailedPaths = failedPaths
+			resp.Violations = violations
 			return resp
 		}
 	}
@@ -2,15 +2,21 @@ package engine
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/deprecations"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
+	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"github.com/kyverno/kyverno/pkg/kyverno/store"
+	"github.com/kyverno/kyverno/pkg/userinfo"
 	utils2 "github.com/kyverno/kyverno/pkg/utils"
 	"gotest.tools/assert"
 	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 )
 
 func TestGetAnchorsFromMap_ThereAreAnchors(t *testing.T) {
@@ -2242,3 +2248,396 @@ func TestValidate_context_variable_substitution_CLI(t *testing.T) {
 	}
 	assert.Assert(t, !er.IsSuccessful())
 }
+
+func Test_ValidateParseJsonAnnotation(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"limit-replicas-setting"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-replicas-setting","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"replicas setting must not be greater than 3","deny":{"conditions":{"all":[{"key":"{{ parse_json(request.object.metadata.annotations.\"config.kyverno.io/settings\").replicas }}","operator":"GreaterThan","value":3}]}}}}]}}`)
+
+	tests := []struct {
+		name            string
+		settings        string
+		expectedSuccess bool
+		expectedError   bool
+	}{
+		{name: "compliant", settings: `{\"replicas\": 2, \"tier\": \"backend\"}`, expectedSuccess: true},
+		{name: "violation", settings: `{\"replicas\": 5, \"tier\": \"backend\"}`, expectedSuccess: false},
+		{name: "invalid-json", settings: `{\"replicas\": `, expectedSuccess: false, expectedError: true},
+	}
+
+	for _, test := range tests {
+		resourceRaw := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"default","annotations":{"config.kyverno.io/settings":"` + test.settings + `"}},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`)
+
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		resourceUnstructured, err := utils.ConvertToUnstructured(resourceRaw)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(resourceRaw))
+
+		policyContext := &PolicyContext{
+			Policy:      policy,
+			JSONContext: ctx,
+			NewResource: *resourceUnstructured}
+		er := Validate(policyContext)
+
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1, test.name)
+		assert.Equal(t, er.PolicyResponse.Rules[0].Success, test.expectedSuccess, test.name)
+		assert.Equal(t, er.PolicyResponse.Rules[0].Error, test.expectedError, test.name)
+	}
+}
+
+func Test_Validate_Rollout_Percentage(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label team is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	// team-b is assigned to bucket 27 and team-a to bucket 46
+	tests := []struct {
+		percentage     *int
+		namespace      string
+		expectedAction string
+	}{
+		{percentage: nil, namespace: "team-a", expectedAction: "enforce"},
+		{percentage: intPtr(0), namespace: "team-b", expectedAction: "audit"},
+		{percentage: intPtr(30), namespace: "team-b", expectedAction: "enforce"},
+		{percentage: intPtr(30), namespace: "team-a", expectedAction: "audit"},
+		{percentage: intPtr(100), namespace: "team-a", expectedAction: "enforce"},
+	}
+
+	for _, test := range tests {
+		resourceRaw := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"` + test.namespace + `"},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`)
+
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		policy.Spec.RolloutPercentage = test.percentage
+		resourceUnstructured, err := utils.ConvertToUnstructured(resourceRaw)
+		assert.NilError(t, err)
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resourceUnstructured, JSONContext: context.NewContext()})
+		assert.Equal(t, er.PolicyResponse.ValidationFailureAction, test.expectedAction, test.namespace)
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+
+		// the applied mode is recorded in the rule properties while the policy is rolled out
+		if test.percentage == nil {
+			assert.Assert(t, er.PolicyResponse.Rules[0].Properties == nil)
+		} else {
+			assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyValidationFailureAction], test.expectedAction, test.namespace)
+		}
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func Test_Validate_Enforcement_Threshold(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label team is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+	resourceRaw := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"team-a"},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`)
+
+	allow, deny := true, false
+	tests := []struct {
+		name           string
+		allow          *bool
+		severity       string
+		threshold      string
+		expectedAction string
+	}{
+		{name: "no threshold", allow: &allow, severity: "medium", threshold: "", expectedAction: "enforce"},
+		{name: "below threshold", allow: &allow, severity: "medium", threshold: "high", expectedAction: "audit"},
+		{name: "at threshold", allow: &allow, severity: "high", threshold: "high", expectedAction: "enforce"},
+		{name: "above threshold", allow: &allow, severity: "critical", threshold: "medium", expectedAction: "enforce"},
+		{name: "low severity", allow: &allow, severity: "low", threshold: "medium", expectedAction: "audit"},
+		{name: "critical threshold", allow: &allow, severity: "high", threshold: "critical", expectedAction: "audit"},
+		{name: "unknown threshold", allow: &allow, severity: "low", threshold: "strict", expectedAction: "enforce"},
+		{name: "missing severity", allow: &allow, severity: "", threshold: "critical", expectedAction: "enforce"},
+		{name: "unknown severity", allow: &allow, severity: "minor", threshold: "critical", expectedAction: "enforce"},
+		{name: "not opted in", allow: nil, severity: "low", threshold: "critical", expectedAction: "enforce"},
+		{name: "opted out", allow: &deny, severity: "low", threshold: "critical", expectedAction: "enforce"},
+	}
+
+	for _, test := range tests {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		policy.Spec.AllowNamespaceThresholds = test.allow
+		if test.severity != "" {
+			policy.SetAnnotations(map[string]string{kyverno.SeverityAnnotation: test.severity})
+		}
+		resourceUnstructured, err := utils.ConvertToUnstructured(resourceRaw)
+		assert.NilError(t, err)
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resourceUnstructured, JSONContext: context.NewContext(), EnforcementThreshold: test.threshold})
+		assert.Equal(t, er.PolicyResponse.ValidationFailureAction, test.expectedAction, test.name)
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		assert.Assert(t, !er.PolicyResponse.Rules[0].Success, test.name)
+
+		// the effective action is recorded in the rule properties of the policies allowing thresholds
+		if policy.AllowsNamespaceThresholds() {
+			assert.Equal(t, er.PolicyResponse.Rules[0].Properties[response.RulePropertyValidationFailureAction], test.expectedAction, test.name)
+		} else {
+			assert.Assert(t, er.PolicyResponse.Rules[0].Properties == nil, test.name)
+		}
+	}
+}
+
+func Test_Validate_Enforcement_Threshold_Rollout(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team","annotations":{"policies.kyverno.io/severity":"critical"}},"spec":{"validationFailureAction":"enforce","allowNamespaceThresholds":true,"rolloutPercentage":30,"rules":[{"name":"check-team","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label team is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	// the threshold does not enforce the policy in the namespaces it is not rolled out to
+	assert.Equal(t, policy.GetValidationFailureActionForThreshold("team-a", "medium"), "audit")
+	assert.Equal(t, policy.GetValidationFailureActionForThreshold("team-b", "medium"), "enforce")
+}
+
+func Test_Validate_IgnorePlatformMutations(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"restrict-run-as-user"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-run-as-user","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"runAsUser must be 1000","pattern":{"spec":{"containers":[{"=(securityContext)":{"=(runAsUser)":1000}}]}}}}]}}`)
+
+	// the securityContext is set by the platform, e.g. by the OpenShift SCC admission
+	resourceRaw := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"default","managedFields":[{"manager":"kubectl-client-side-apply","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{".":{},"f:image":{},"f:name":{}}}}}},{"manager":"openshift-scc","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{"f:securityContext":{".":{},"f:runAsUser":{}}}}}}}]},"spec":{"containers":[{"name":"nginx","image":"nginx","securityContext":{"runAsUser":1000680000}}]}}`)
+
+	tests := []struct {
+		name                    string
+		ignorePlatformMutations bool
+		platformFieldManagers   []string
+		expectedSuccess         bool
+	}{
+		{name: "platform-mutations", ignorePlatformMutations: false, platformFieldManagers: []string{"openshift-*"}, expectedSuccess: false},
+		{name: "ignore-platform-mutations", ignorePlatformMutations: true, platformFieldManagers: []string{"openshift-*"}, expectedSuccess: true},
+		{name: "no-platform-field-managers", ignorePlatformMutations: true, platformFieldManagers: nil, expectedSuccess: false},
+	}
+
+	for _, test := range tests {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		policy.Spec.Rules[0].MatchResources.IgnorePlatformMutations = test.ignorePlatformMutations
+		resourceUnstructured, err := utils.ConvertToUnstructured(resourceRaw)
+		assert.NilError(t, err)
+
+		er := Validate(&PolicyContext{
+			Policy:                policy,
+			NewResource:           *resourceUnstructured,
+			JSONContext:           context.NewContext(),
+			PlatformFieldManagers: test.platformFieldManagers,
+		})
+
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1, test.name)
+		assert.Equal(t, er.PolicyResponse.Rules[0].Success, test.expectedSuccess, test.name)
+	}
+}
+
+func Test_Validate_RuleDocumentation(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team-label","id":"SEC-014","documentationURL":"https://controls.example.com/SEC-014","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+	resourceRaw := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"default"},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+	resourceUnstructured, err := utils.ConvertToUnstructured(resourceRaw)
+	assert.NilError(t, err)
+
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resourceUnstructured, JSONContext: context.NewContext()})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+
+	rule := er.PolicyResponse.Rules[0]
+	assert.Equal(t, rule.ID, "SEC-014")
+	assert.Equal(t, rule.DocumentationURL, "https://controls.example.com/SEC-014")
+	assert.Assert(t, strings.HasSuffix(rule.DocumentedMessage(), " (SEC-014, see https://controls.example.com/SEC-014)"), rule.DocumentedMessage())
+
+	ids, urls := er.GetRuleDocumentation(false)
+	assert.DeepEqual(t, ids, []string{"SEC-014"})
+	assert.DeepEqual(t, urls, []string{"https://controls.example.com/SEC-014"})
+
+	// the message is unchanged for a rule without ID and documentation URL
+	rule.ID, rule.DocumentationURL = "", ""
+	assert.Equal(t, rule.DocumentedMessage(), rule.Message)
+}
+
+func Test_Validate_Secret_Masked(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "secret-rotation"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "require-rotation",
+					"match": {"resources": {"kinds": ["Secret"]}},
+					"validate": {
+						"message": "the password {{request.object.data.password}} ({{base64_decode(request.object.data.password)}}) must be rotated",
+						"pattern": {"metadata": {"labels": {"rotated": "true"}}}
+					}
+				}
+			]
+		}
+	}`)
+
+	// the password is "hunter2-super-secret"
+	rawResource := []byte(`{
+		"apiVersion": "v1",
+		"kind": "Secret",
+		"metadata": {"name": "db", "namespace": "prod"},
+		"data": {"password": "aHVudGVyMi1zdXBlci1zZWNyZXQ="}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(rawResource))
+
+	er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, !er.PolicyResponse.Rules[0].Success)
+
+	// the messages and properties are reported in the events and the policy reports
+	raw, err := json.Marshal(er.PolicyResponse)
+	assert.NilError(t, err)
+	for _, value := range []string{"hunter2-super-secret", "aHVudGVyMi1zdXBlci1zZWNyZXQ="} {
+		assert.Assert(t, !strings.Contains(string(raw), value), string(raw))
+	}
+	assert.Assert(t, strings.Contains(er.PolicyResponse.Rules[0].Message, mask.Masked("hunter2-super-secret")), er.PolicyResponse.Rules[0].Message)
+
+	// the same value is not masked on other kinds
+	rawResource = []byte(strings.Replace(string(rawResource), `"kind": "Secret"`, `"kind": "ConfigMap"`, 1))
+	resource, err = utils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+	policy.Spec.Rules[0].MatchResources.Kinds = []string{"ConfigMap"}
+	ctx = context.NewContext()
+	assert.NilError(t, ctx.AddResource(rawResource))
+
+	er = Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Assert(t, strings.Contains(er.PolicyResponse.Rules[0].Message, "hunter2-super-secret"))
+
+	// unless the kind is configured as sensitive
+	er = Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx, SensitiveKinds: []string{"ConfigMap"}})
+	assert.Assert(t, !strings.Contains(er.PolicyResponse.Rules[0].Message, "hunter2-super-secret"))
+}
+
+func Test_Validate_Deprecations(t *testing.T) {
+	// the version of the cluster set by the previous runs is not known
+	deprecations.ClearClusterVersion()
+	t.Cleanup(deprecations.ClearClusterVersion)
+
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "removed-apis"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-removed-apis",
+					"match": {"resources": {"kinds": ["Ingress", "CronJob"]}},
+					"validate": {
+						"message": "the API is removed in the next upgrade",
+						"deprecations": {"targetVersion": "1.25"}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	validate := func(rawResource string) response.RuleResponse {
+		resource, err := utils.ConvertToUnstructured([]byte(rawResource))
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource([]byte(rawResource)))
+
+		er := Validate(&PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+		assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+		return er.PolicyResponse.Rules[0]
+	}
+
+	ingress := `{"apiVersion": "extensions/v1beta1", "kind": "Ingress", "metadata": {"name": "web", "namespace": "prod"}}`
+	cronJob := `{"apiVersion": "batch/v1beta1", "kind": "CronJob", "metadata": {"name": "backup", "namespace": "prod"}}`
+
+	rule := validate(ingress)
+	assert.Assert(t, !rule.Success)
+	assert.Equal(t, rule.Message, "validation error: the API is removed in the next upgrade. extensions/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress instead")
+
+	rule = validate(cronJob)
+	assert.Assert(t, !rule.Success)
+	assert.Assert(t, strings.HasSuffix(rule.Message, "batch/v1beta1 CronJob is removed in Kubernetes 1.25, use batch/v1 CronJob instead"), rule.Message)
+
+	rule = validate(`{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "web", "namespace": "prod"}}`)
+	assert.Assert(t, rule.Success, rule.Message)
+
+	// the target version defaults to the minor version after the version of the cluster
+	policy.Spec.Rules[0].Validation.Deprecations.TargetVersion = ""
+	rule = validate(cronJob)
+	assert.Assert(t, rule.Error, rule.Message)
+
+	assert.NilError(t, deprecations.SetClusterVersion("1", "23"))
+	rule = validate(cronJob)
+	assert.Assert(t, rule.Success, rule.Message)
+
+	assert.NilError(t, deprecations.SetClusterVersion("1", "24"))
+	rule = validate(cronJob)
+	assert.Assert(t, !rule.Success, rule.Message)
+}
+
+func Test_Validate_Impersonation_MatchOriginalUser(t *testing.T) {
+	policyRaw := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "only-ci-deploys-to-prod"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"background": false,
+			"rules": [{
+				"name": "only-ci",
+				"match": {"resources": {"kinds": ["Pod"], "namespaces": ["prod"]}},
+				"exclude": {"subjects": [{"kind": "ServiceAccount", "name": "deployer", "namespace": "ci"}]},
+				"validate": {
+					"message": "only CI deploys to prod, impersonated by '{{request.userInfo.impersonatedBy.username || 'nobody'}}'",
+					"deny": {}
+				}
+			}]
+		}
+	}`)
+	resourceRaw := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "namespace": "prod"}, "spec": {"containers": [{"name": "web", "image": "nginx"}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+	resource, err := utils.ConvertToUnstructured(resourceRaw)
+	assert.NilError(t, err)
+
+	validate := func(userInfo authenticationv1.UserInfo, matchOriginalUser bool) *response.EngineResponse {
+		info := kyverno.RequestInfo{AdmissionUserInfo: userInfo}
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(resourceRaw))
+		assert.NilError(t, ctx.AddUserInfo(info))
+		return Validate(&PolicyContext{Policy: policy, NewResource: *resource, AdmissionInfo: info, JSONContext: ctx, MatchOriginalUser: matchOriginalUser})
+	}
+
+	ci := authenticationv1.UserInfo{Username: "system:serviceaccount:ci:deployer", Groups: []string{"system:serviceaccounts"}}
+	impersonated := *ci.DeepCopy()
+	impersonated.Extra = map[string]authenticationv1.ExtraValue{
+		userinfo.OriginalUserExtraKey:   {"alice"},
+		userinfo.OriginalGroupsExtraKey: {"admins"},
+	}
+
+	// without the option the impersonated service account is excluded
+	assert.Equal(t, len(validate(impersonated, false).PolicyResponse.Rules), 0)
+
+	// with the option the original user is matched and denied
+	resp := validate(impersonated, true)
+	assert.Equal(t, len(resp.PolicyResponse.Rules), 1)
+	assert.Equal(t, resp.PolicyResponse.Rules[0].Success, false)
+	assert.Equal(t, resp.PolicyResponse.Rules[0].Message, "only CI deploys to prod, impersonated by 'alice'")
+
+	// the requests without impersonation behave as before, with and without the option
+	for _, matchOriginalUser := range []bool{false, true} {
+		assert.Equal(t, len(validate(ci, matchOriginalUser).PolicyResponse.Rules), 0)
+
+		resp := validate(authenticationv1.UserInfo{Username: "alice", Groups: []string{"admins"}}, matchOriginalUser)
+		assert.Equal(t, len(resp.PolicyResponse.Rules), 1)
+		assert.Equal(t, resp.PolicyResponse.Rules[0].Success, false)
+	}
+}
@@ -18,6 +18,11 @@ import (
 )
 
 var RegexVariables = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// RegexEscpVariables represents the escaped variables \{{}}, they are not substituted and the
+// escaping backslash is removed so that a literal {{}} can be used in the policies
+var RegexEscpVariables = regexp.MustCompile(`\\\{\{[^{}]*\}\}`)
+
 var RegexReferences = regexp.MustCompile(`\$\(.[^\ ]*\)`)
 
 // IsVariable returns true if the element contains a 'valid' variable {{}}
@@ -95,7 +100,7 @@ func validateBackgroundModeVars(log logr.Logger, ctx context.EvalInterface) json
 		if !ok {
 			return data.Element, nil
 		}
-		vars := RegexVariables.FindAllString(value, -1)
+		vars := RegexVariables.FindAllString(hideEscapedVariables(value), -1)
 		for _, v := range vars {
 			variable := replaceBracesAndTrimSpaces(v)
 
@@ -171,6 +176,7 @@ func substituteVariablesIfAny(log logr.Logger, ctx context.EvalInterface) jsonUt
 			return data.Element, nil
 		}
 
+		value = hideEscapedVariables(value)
 		vars := RegexVariables.FindAllString(value, -1)
 		for len(vars) > 0 {
 			originalPattern := value
@@ -214,10 +220,26 @@ func substituteVariablesIfAny(log logr.Logger, ctx context.EvalInterface) jsonUt
 			vars = RegexVariables.FindAllString(value, -1)
 		}
 
-		return value, nil
+		return unescapeVariables(value), nil
+	})
+}
+
+// escapedVariableOpening replaces the opening braces of the escaped variables during the
+// substitution, it has no braces so that the escaped variables are not matched
+const escapedVariableOpening = "\x00escaped-variable\x00"
+
+// hideEscapedVariables replaces the escaped variables so that they are not substituted
+func hideEscapedVariables(value string) string {
+	return RegexEscpVariables.ReplaceAllStringFunc(value, func(v string) string {
+		return escapedVariableOpening + strings.TrimPrefix(v, "\\{{")
 	})
 }
 
+// unescapeVariables restores the escaped variables as literal {{}}
+func unescapeVariables(value string) string {
+	return strings.ReplaceAll(value, escapedVariableOpening, "{{")
+}
+
 // getJMESPath converts path to JMES format
 func getJMESPath(rawPath string) string {
 	tokens := strings.Split(rawPath, "/")[3:] // skip empty element and two non-resource (like mutate.overlay)
@@ -926,6 +926,68 @@ func Test_SubstituteStringInString(t *testing.T) {
 	assert.DeepEqual(t, expected, content)
 }
 
+func Test_SubstituteEscapedVariables(t *testing.T) {
+	patternRaw := []byte(`
+	{
+		"spec": {
+			"content": "{{ request.object.simple_object_string }} \\{{ request.object.simple_object_string }}",
+			"template": "\\{{ .Values.name }}"
+		}
+	}
+	`)
+
+	var pattern interface{}
+	assert.NilError(t, json.Unmarshal(patternRaw, &pattern))
+
+	ctx := context.NewContext()
+	ctx.AddResource(variableObject)
+
+	resolved, err := SubstituteAll(log.Log, ctx, pattern)
+	assert.NilError(t, err)
+
+	spec := resolved.(map[string]interface{})["spec"].(map[string]interface{})
+	assert.Equal(t, spec["content"], "example {{ request.object.simple_object_string }}")
+	assert.Equal(t, spec["template"], "{{ .Values.name }}")
+}
+
+func Test_SubstituteAllInRule_Mutations(t *testing.T) {
+	ruleRaw := []byte(`
+	{
+		"name": "mutate",
+		"mutate": {
+			"patchesJson6902": "- op: add\n  path: /metadata/labels/{{ request.object.simple_object_string }}\n  value: \"{{ request.object.simple_object_string }}\"",
+			"patchStrategicMerge": {"metadata": {"annotations": {"name": "{{ request.object.simple_object_string }}"}}}
+		},
+		"generate": {
+			"kind": "ConfigMap",
+			"name": "{{ request.object.simple_object_string }}-config",
+			"namespace": "default",
+			"data": {"data": {"name": "{{ request.object.simple_object_string }}", "template": "\\{{ name }}"}}
+		}
+	}
+	`)
+
+	var rule v1.Rule
+	assert.NilError(t, json.Unmarshal(ruleRaw, &rule))
+
+	ctx := context.NewContext()
+	ctx.AddResource(variableObject)
+
+	rule, err := SubstituteAllInRule(log.Log, ctx, rule)
+	assert.NilError(t, err)
+
+	assert.Equal(t, rule.Mutation.PatchesJSON6902, "- op: add\n  path: /metadata/labels/example\n  value: \"example\"")
+	assert.Equal(t, rule.Generation.Name, "example-config")
+
+	patch, err := json.Marshal(rule.Mutation.PatchStrategicMerge)
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `{"metadata":{"annotations":{"name":"example"}}}`)
+
+	data, err := json.Marshal(rule.Generation.Data)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"data":{"name":"example","template":"{{ name }}"}}`)
+}
+
 func Test_ReferenceSubstitution(t *testing.T) {
 	jsonRaw := []byte(`
 	{
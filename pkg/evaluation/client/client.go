@@ -0,0 +1,72 @@
+// Package client evaluates resources against the policies of a cluster with the evaluation
+// service of Kyverno, e.g. from a CI system.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	evaluationv1 "github.com/kyverno/kyverno/pkg/evaluation/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Client calls the evaluation service
+type Client struct {
+	conn   *grpc.ClientConn
+	client evaluationv1.EvaluationClient
+}
+
+// New returns a client of the evaluation service at the address, e.g. "kyverno.example.com:9444",
+// with the TLS configuration of the client certificate
+func New(addr string, tlsConfig *tls.Config) (*Client, error) {
+	return Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+}
+
+// Dial returns a client of the evaluation service at the address with the dial options
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial evaluation service %s: %v", addr, err)
+	}
+	return &Client{conn: conn, client: evaluationv1.NewEvaluationClient(conn)}, nil
+}
+
+// NewTLSConfig returns the TLS configuration of the client certificate and key files, signed
+// by the root CA of Kyverno, and the root CA file to verify the server certificate.
+// The serverName is the name in the server certificate, e.g. "kyverno-svc.kyverno.svc".
+func NewTLSConfig(certFile, keyFile, caFile, serverName string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %v", err)
+	}
+
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificate found in root CA file %s", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// Evaluate evaluates the resource of the request against the policies of the cluster
+func (c *Client) Evaluate(ctx context.Context, req *evaluationv1.EvaluateRequest) (*evaluationv1.EvaluateResponse, error) {
+	return c.client.Evaluate(ctx, req)
+}
+
+// Close closes the connection to the evaluation service
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
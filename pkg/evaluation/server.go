@@ -0,0 +1,435 @@
+// Package evaluation serves the evaluation service, which evaluates resources against the cached
+// policies for callers outside of the cluster, e.g. CI systems, with the same engine as the
+// admission requests.
+package evaluation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	client "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/engine"
+	enginectx "github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	evaluationv1 "github.com/kyverno/kyverno/pkg/evaluation/v1"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	ktls "github.com/kyverno/kyverno/pkg/tls"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+)
+
+// minLimiterIdleTimeout is the minimum time after which the rate limiter of an idle caller is evicted
+const minLimiterIdleTimeout = 10 * time.Minute
+
+// Server evaluates resources against the policies of the policy cache
+type Server struct {
+	evaluationv1.UnimplementedEvaluationServer
+
+	pCache        policycache.Interface
+	configHandler config.Interface
+	client        *client.Client
+	resCache      resourcecache.ResourceCache
+	nsLister      listerv1.NamespaceLister
+	server        *grpc.Server
+	log           logr.Logger
+
+	// limit and burst are the rate limit of the requests of each caller, the
+	// requests are not limited if limit is 0
+	limit        rate.Limit
+	burst        int
+	limiters     map[string]*callerLimiter
+	lastEviction time.Time
+	mux          sync.Mutex
+}
+
+// callerLimiter is the rate limiter of a caller and the time of its last request
+type callerLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// NewServer returns the evaluation service with the transport credentials, see ServerCredentials.
+// The requests of each caller are limited to rateLimit per second with bursts of burst requests.
+// The enforcement thresholds of the namespaces are read from the namespace lister, if not nil.
+func NewServer(
+	creds credentials.TransportCredentials,
+	pCache policycache.Interface,
+	configHandler config.Interface,
+	client *client.Client,
+	resCache resourcecache.ResourceCache,
+	nsLister listerv1.NamespaceLister,
+	rateLimit float64,
+	burst int,
+	log logr.Logger,
+) *Server {
+	s := &Server{
+		pCache:        pCache,
+		configHandler: configHandler,
+		client:        client,
+		resCache:      resCache,
+		nsLister:      nsLister,
+		log:           log,
+		limit:         rate.Limit(rateLimit),
+		burst:         burst,
+		limiters:      make(map[string]*callerLimiter),
+	}
+
+	s.server = grpc.NewServer(grpc.Creds(creds))
+	evaluationv1.RegisterEvaluationServer(s.server, s)
+	return s
+}
+
+// ServerCredentials returns the mutual TLS credentials of the evaluation service, the server
+// certificate is the TLS pair of the webhook server and the client certificates must be
+// signed by the root CA
+func ServerCredentials(tlsPair *ktls.PemPair, rootCA []byte) (credentials.TransportCredentials, error) {
+	pair, err := tls.X509KeyPair(tlsPair.Certificate, tlsPair.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(rootCA) {
+		return nil, fmt.Errorf("no certificate found in the root CA")
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// Run serves the evaluation service on the address until the stop channel is closed
+func (s *Server) Run(addr string, stopCh <-chan struct{}) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.log.Error(err, "failed to listen", "addr", addr)
+		return
+	}
+
+	go func() {
+		<-stopCh
+		s.server.GracefulStop()
+	}()
+
+	s.log.Info("serving evaluation service", "addr", addr)
+	s.Serve(lis)
+}
+
+// Serve serves the evaluation service on the listener
+func (s *Server) Serve(lis net.Listener) {
+	if err := s.server.Serve(lis); err != nil {
+		s.log.Error(err, "failed to serve evaluation service")
+	}
+}
+
+// Stop stops the evaluation service
+func (s *Server) Stop() {
+	s.server.Stop()
+}
+
+// Evaluate applies the mutate and validate rules of the cached policies to the resource, as
+// for an admission request creating it. Each call is logged with the caller for auditing.
+func (s *Server) Evaluate(ctx context.Context, req *evaluationv1.EvaluateRequest) (*evaluationv1.EvaluateResponse, error) {
+	caller := callerName(ctx)
+	logger := s.log.WithValues("caller", caller, "policySelector", req.PolicySelector)
+
+	if !s.allow(caller, time.Now()) {
+		logger.Info("evaluation request rejected", "reason", "rate limit exceeded")
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	selector, err := labels.Parse(req.PolicySelector)
+	if err != nil {
+		logger.Info("evaluation request rejected", "reason", err.Error())
+		return nil, status.Errorf(codes.InvalidArgument, "invalid policy selector: %v", err)
+	}
+
+	var resource unstructured.Unstructured
+	if err := resource.UnmarshalJSON([]byte(req.Resource)); err != nil {
+		logger.Info("evaluation request rejected", "reason", err.Error())
+		return nil, status.Errorf(codes.InvalidArgument, "invalid resource: %v", err)
+	}
+
+	logger = logger.WithValues("kind", resource.GetKind(), "namespace", resource.GetNamespace(), "name", resource.GetName())
+	resp, err := s.evaluate(selector, resource, req)
+	if err != nil {
+		logger.Error(err, "failed to evaluate resource")
+		return nil, status.Errorf(codes.Internal, "failed to evaluate resource: %v", err)
+	}
+
+	logger.Info("evaluated resource", "results", len(resp.Results), "blocked", resp.Blocked)
+	return resp, nil
+}
+
+// evaluate applies the mutate policies and then the validate policies to the patched resource,
+// as the admission webhooks do
+func (s *Server) evaluate(selector labels.Selector, resource unstructured.Unstructured, req *evaluationv1.EvaluateRequest) (*evaluationv1.EvaluateResponse, error) {
+	policyContext, err := s.newPolicyContext(resource, req)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, namespace := resource.GetKind(), resource.GetNamespace()
+	resp := &evaluationv1.EvaluateResponse{}
+
+	// the enforce policies are audited in the namespaces whose threshold is above their severity, as in admission
+	threshold := s.enforcementThreshold(kind, namespace)
+	policyContext.EnforcementThreshold = threshold
+
+	for _, policy := range s.policies(selector, kind, namespace, policycache.Mutate) {
+		policyContext.Policy = *policy
+		engineResponse := engine.Mutate(policyContext)
+		dryRun := policy.IsMutateDryRun() || s.configHandler.GetMutateDryRun()
+		if !dryRun {
+			policyContext.NewResource = engineResponse.PatchedResource
+		}
+		resp.Results = append(resp.Results, ruleResults(policy, engineResponse, namespace, threshold, dryRun)...)
+	}
+
+	// the validating webhooks receive the resource patched by the mutating webhooks
+	patchedResource, err := policyContext.NewResource.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	if err := policyContext.JSONContext.AddResource(patchedResource); err != nil {
+		return nil, fmt.Errorf("failed to load the patched resource in the context: %v", err)
+	}
+
+	policies := s.policies(selector, kind, namespace, policycache.ValidateEnforce)
+	policies = append(policies, s.policies(selector, kind, namespace, policycache.ValidateAudit)...)
+	for _, policy := range uniquePolicies(policies) {
+		policyContext.Policy = *policy
+		engineResponse := engine.Validate(policyContext)
+		results := ruleResults(policy, engineResponse, namespace, threshold, false)
+		shadowEnforce := policy.IsShadowEnforce() || s.configHandler.GetShadowEnforce()
+		for _, result := range results {
+			if result.Status == report.StatusFail && result.ValidationFailureAction == "enforce" && !shadowEnforce {
+				resp.Blocked = true
+			}
+		}
+		resp.Results = append(resp.Results, results...)
+	}
+
+	resp.PatchedResource = string(patchedResource)
+	return resp, nil
+}
+
+// newPolicyContext returns the policy context of an admission request creating the resource
+func (s *Server) newPolicyContext(resource unstructured.Unstructured, req *evaluationv1.EvaluateRequest) (*engine.PolicyContext, error) {
+	raw, err := resource.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := req.UserInfo
+	if userInfo == nil {
+		userInfo = &evaluationv1.UserInfo{}
+	}
+
+	gvk := resource.GroupVersionKind()
+	request := &v1beta1.AdmissionRequest{
+		UID:       types.UID(fmt.Sprintf("evaluation-%d", time.Now().UnixNano())),
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Namespace: resource.GetNamespace(),
+		Name:      resource.GetName(),
+		Operation: v1beta1.Create,
+		UserInfo: authenticationv1.UserInfo{
+			Username: userInfo.Username,
+			Groups:   userInfo.Groups,
+		},
+		Object: runtime.RawExtension{Raw: raw},
+	}
+
+	requestInfo := kyverno.RequestInfo{
+		Roles:             userInfo.Roles,
+		ClusterRoles:      userInfo.ClusterRoles,
+		AdmissionUserInfo: request.UserInfo,
+		Operation:         string(request.Operation),
+		DryRun:            request.DryRun,
+	}
+
+	ctx := enginectx.NewContext()
+	if err := ctx.AddRequest(request); err != nil {
+		return nil, fmt.Errorf("failed to load the request in the context: %v", err)
+	}
+	if err := ctx.AddUserInfo(requestInfo); err != nil {
+		return nil, fmt.Errorf("failed to load the user info in the context: %v", err)
+	}
+	if err := ctx.AddServiceAccount(requestInfo.AdmissionUserInfo.Username); err != nil {
+		return nil, fmt.Errorf("failed to load the service account in the context: %v", err)
+	}
+	if err := ctx.AddImageInfo(&resource); err != nil {
+		return nil, fmt.Errorf("failed to load the image information in the context: %v", err)
+	}
+
+	return &engine.PolicyContext{
+		NewResource:         resource,
+		AdmissionInfo:       requestInfo,
+		ExcludeGroupRole:    s.configHandler.GetExcludeGroupRole(),
+		ExcludeResourceFunc: s.configHandler.ToFilter,
+		SensitiveKinds:      s.configHandler.GetSensitiveKinds(),
+		MatchOriginalUser:   s.configHandler.GetMatchOriginalUser(),
+		ResourceCache:       s.resCache,
+		JSONContext:         ctx,
+		Client:              s.client,
+		NamespaceLabels:     req.NamespaceLabels,
+		Time:                time.Now(),
+		DefaultLocale:       s.configHandler.GetDefaultLocale(),
+	}, nil
+}
+
+// policies returns the cached policies of the policy type for the resource, whose labels match the selector
+func (s *Server) policies(selector labels.Selector, kind, namespace string, pkey policycache.PolicyType) []*kyverno.ClusterPolicy {
+	var policies []*kyverno.ClusterPolicy
+	for _, policy := range s.pCache.GetPolicies(pkey, kind, namespace) {
+		if selector.Matches(labels.Set(policy.GetLabels())) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// enforcementThreshold returns the enforcement threshold of the namespace of the resource
+func (s *Server) enforcementThreshold(kind, namespace string) string {
+	if s.nsLister == nil {
+		return ""
+	}
+
+	return common.GetNamespaceEnforcementThreshold(kind, namespace, s.nsLister, s.log)
+}
+
+// allow checks the rate limit of the caller at the time
+func (s *Server) allow(caller string, now time.Time) bool {
+	if s.limit == 0 {
+		return true
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.evictIdleLimiters(now)
+
+	limiter, ok := s.limiters[caller]
+	if !ok {
+		limiter = &callerLimiter{Limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[caller] = limiter
+	}
+	limiter.lastSeen = now
+	return limiter.AllowN(now, 1)
+}
+
+// limiterIdleTimeout returns the time after which the rate limiter of an idle caller is evicted,
+// it is at least the time to refill the burst so that the eviction does not relax the limit
+func (s *Server) limiterIdleTimeout() time.Duration {
+	refill := time.Duration(float64(s.burst) / float64(s.limit) * float64(time.Second))
+	if refill > minLimiterIdleTimeout {
+		return refill
+	}
+	return minLimiterIdleTimeout
+}
+
+// evictIdleLimiters removes the rate limiters of the idle callers, the limiters are checked
+// at most once per idle timeout
+func (s *Server) evictIdleLimiters(now time.Time) {
+	timeout := s.limiterIdleTimeout()
+	if now.Sub(s.lastEviction) < timeout {
+		return
+	}
+
+	s.lastEviction = now
+	for caller, limiter := range s.limiters {
+		if now.Sub(limiter.lastSeen) >= timeout {
+			delete(s.limiters, caller)
+		}
+	}
+}
+
+// callerName returns the common name of the client certificate of the caller, or its address
+// if the connection is not authenticated
+func callerName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		return tlsInfo.State.PeerCertificates[0].Subject.CommonName
+	}
+	return p.Addr.String()
+}
+
+// ruleResults converts the rule responses of the engine response, the patches of the mutate
+// rules in dry run are reported with the status warn
+func ruleResults(policy *kyverno.ClusterPolicy, engineResponse *response.EngineResponse, namespace, threshold string, dryRun bool) []*evaluationv1.RuleResult {
+	var results []*evaluationv1.RuleResult
+	for _, rule := range engineResponse.PolicyResponse.Rules {
+		result := &evaluationv1.RuleResult{
+			Policy:  engineResponse.PolicyResponse.Policy.Name,
+			Rule:    rule.Name,
+			Type:    rule.Type,
+			Status:  report.StatusFail,
+			Message: rule.Message,
+		}
+		if engineResponse.PolicyResponse.Policy.Namespace != "" {
+			result.Policy = engineResponse.PolicyResponse.Policy.Namespace + "/" + result.Policy
+		}
+
+		switch {
+		case rule.Error:
+			result.Status = report.StatusError
+		case rule.Skipped:
+			result.Status = report.StatusSkip
+		case rule.Success && dryRun && len(rule.Patches) > 0:
+			result.Status = report.StatusWarn
+		case rule.Success:
+			result.Status = report.StatusPass
+		}
+
+		if rule.Type == utils.Validation.String() {
+			result.ValidationFailureAction = policy.GetValidationFailureActionForThreshold(namespace, threshold)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// uniquePolicies removes the duplicated policies, e.g. the enforce policies rolled out to a
+// percentage of the namespaces are cached for both enforce and audit
+func uniquePolicies(policies []*kyverno.ClusterPolicy) []*kyverno.ClusterPolicy {
+	seen := make(map[string]bool)
+	var unique []*kyverno.ClusterPolicy
+	for _, policy := range policies {
+		key := policy.GetNamespace() + "/" + policy.GetName()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, policy)
+	}
+	return unique
+}
@@ -0,0 +1,205 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/config"
+	evaluationclient "github.com/kyverno/kyverno/pkg/evaluation/client"
+	evaluationv1 "github.com/kyverno/kyverno/pkg/evaluation/v1"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakePolicyCache returns the policies of each policy type, for all kinds and namespaces
+type fakePolicyCache struct {
+	policycache.Interface
+	policies map[policycache.PolicyType][]*kyverno.ClusterPolicy
+}
+
+func (c fakePolicyCache) GetPolicies(pkey policycache.PolicyType, kind, nspace string) []*kyverno.ClusterPolicy {
+	return c.policies[pkey]
+}
+
+const requireLabelsPolicy = `{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {"name": "require-labels", "labels": {"team": "platform"}},
+	"spec": {
+		"validationFailureAction": "enforce",
+		"rules": [{
+			"name": "check-app-label",
+			"match": {"resources": {"kinds": ["Pod"]}},
+			"validate": {
+				"message": "label app is required",
+				"pattern": {"metadata": {"labels": {"app": "?*"}}}
+			}
+		}]
+	}
+}`
+
+const testPod = `{
+	"apiVersion": "v1",
+	"kind": "Pod",
+	"metadata": {"name": "nginx", "namespace": "default"%s},
+	"spec": {"containers": [{"name": "nginx", "image": "nginx:1.21"}]}
+}`
+
+func newTestPolicy(t *testing.T) *kyverno.ClusterPolicy {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal([]byte(requireLabelsPolicy), &policy))
+	return &policy
+}
+
+func newTestClient(t *testing.T, rateLimit float64, burst int) *evaluationclient.Client {
+	return newTestClientWith(t, newTestPolicy(t), nil, rateLimit, burst)
+}
+
+func newTestClientWith(t *testing.T, policy *kyverno.ClusterPolicy, nsLister listerv1.NamespaceLister, rateLimit float64, burst int) *evaluationclient.Client {
+	pCache := fakePolicyCache{
+		policies: map[policycache.PolicyType][]*kyverno.ClusterPolicy{
+			policycache.ValidateEnforce: {policy},
+		},
+	}
+
+	server := NewServer(insecure.NewCredentials(), pCache, &config.ConfigData{}, nil, nil, nsLister, rateLimit, burst, log.Log)
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	c, err := evaluationclient.Dial("bufnet", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NilError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func Test_Evaluate_Blocked(t *testing.T) {
+	c := newTestClient(t, 0, 0)
+
+	resp, err := c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{Resource: fmt.Sprintf(testPod, "")})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.Blocked, true)
+	assert.Equal(t, len(resp.Results), 1)
+	assert.Equal(t, resp.Results[0].Policy, "require-labels")
+	assert.Equal(t, resp.Results[0].Rule, "check-app-label")
+	assert.Equal(t, resp.Results[0].Status, report.StatusFail)
+	assert.Equal(t, resp.Results[0].ValidationFailureAction, "enforce")
+}
+
+func Test_Evaluate_Pass(t *testing.T) {
+	c := newTestClient(t, 0, 0)
+
+	resp, err := c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{Resource: fmt.Sprintf(testPod, `, "labels": {"app": "nginx"}`)})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.Blocked, false)
+	assert.Equal(t, len(resp.Results), 1)
+	assert.Equal(t, resp.Results[0].Status, report.StatusPass)
+}
+
+func Test_Evaluate_PolicySelector(t *testing.T) {
+	c := newTestClient(t, 0, 0)
+
+	resp, err := c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{
+		PolicySelector: "team=security",
+		Resource:       fmt.Sprintf(testPod, ""),
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.Blocked, false)
+	assert.Equal(t, len(resp.Results), 0)
+}
+
+func Test_Evaluate_InvalidArgument(t *testing.T) {
+	c := newTestClient(t, 0, 0)
+
+	_, err := c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{Resource: "not a resource"})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	_, err = c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{PolicySelector: "team in (", Resource: fmt.Sprintf(testPod, "")})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
+
+func Test_Evaluate_RateLimit(t *testing.T) {
+	c := newTestClient(t, 0.001, 2)
+	req := &evaluationv1.EvaluateRequest{Resource: fmt.Sprintf(testPod, "")}
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Evaluate(context.Background(), req)
+		assert.NilError(t, err)
+	}
+
+	_, err := c.Evaluate(context.Background(), req)
+	assert.Equal(t, status.Code(err), codes.ResourceExhausted)
+}
+
+func Test_Evaluate_EnforcementThreshold(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, indexer.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{kyverno.EnforcementThresholdAnnotation: "high"},
+	}}))
+
+	// the low severity policy is audited in the namespace, as in admission
+	policy := newTestPolicy(t)
+	allow := true
+	policy.Spec.AllowNamespaceThresholds = &allow
+	policy.SetAnnotations(map[string]string{kyverno.SeverityAnnotation: "low"})
+	c := newTestClientWith(t, policy, listerv1.NewNamespaceLister(indexer), 0, 0)
+
+	resp, err := c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{Resource: fmt.Sprintf(testPod, "")})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.Blocked, false)
+	assert.Equal(t, len(resp.Results), 1)
+	assert.Equal(t, resp.Results[0].Status, report.StatusFail)
+	assert.Equal(t, resp.Results[0].ValidationFailureAction, "audit")
+
+	// the high severity policy is enforced
+	policy = newTestPolicy(t)
+	policy.Spec.AllowNamespaceThresholds = &allow
+	policy.SetAnnotations(map[string]string{kyverno.SeverityAnnotation: "high"})
+	c = newTestClientWith(t, policy, listerv1.NewNamespaceLister(indexer), 0, 0)
+
+	resp, err = c.Evaluate(context.Background(), &evaluationv1.EvaluateRequest{Resource: fmt.Sprintf(testPod, "")})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.Blocked, true)
+	assert.Equal(t, resp.Results[0].ValidationFailureAction, "enforce")
+}
+
+func Test_RateLimit_Evict_Idle_Callers(t *testing.T) {
+	s := NewServer(insecure.NewCredentials(), fakePolicyCache{}, &config.ConfigData{}, nil, nil, nil, 1, 1, log.Log)
+	now := time.Now()
+
+	assert.Assert(t, s.allow("ci-a", now))
+	assert.Assert(t, !s.allow("ci-a", now))
+	assert.Assert(t, s.allow("ci-b", now.Add(time.Minute)))
+	assert.Equal(t, len(s.limiters), 2)
+
+	// the limiters of the callers idle for the timeout are evicted
+	later := now.Add(minLimiterIdleTimeout + 30*time.Second)
+	assert.Assert(t, s.allow("ci-c", later))
+	assert.Equal(t, len(s.limiters), 2)
+	_, ok := s.limiters["ci-a"]
+	assert.Assert(t, !ok)
+
+	// the idle timeout is at least the time to refill the burst
+	s = NewServer(insecure.NewCredentials(), fakePolicyCache{}, &config.ConfigData{}, nil, nil, nil, 0.001, 2, log.Log)
+	assert.Equal(t, s.limiterIdleTimeout(), 2000*time.Second)
+}
@@ -0,0 +1,3 @@
+// Package v1 contains the messages and the service stubs of the evaluation service, generated
+// from evaluation.proto with protoc-gen-go and protoc-gen-go-grpc.
+package v1
@@ -22,3 +22,20 @@ func TestIncorrectArgs(t *testing.T) {
 	_, err := getEventMsg(FPolicyApply, resourceName, "extra_args1", "extra_args2")
 	assert.Error(t, err, "message expects 2 arguments, but 3 arguments passed")
 }
+
+func Test_Info_annotations(t *testing.T) {
+	info := Info{Kind: "Pod", Name: "nginx", Namespace: "test"}
+	assert.Equal(t, len(info.annotations()), 0)
+
+	info.SetRuleDocumentation([]string{"SEC-014", "SEC-015"}, []string{"https://controls.example.com/SEC-014"})
+	assert.DeepEqual(t, info.annotations(), map[string]string{
+		AnnotationRuleIDs:           "SEC-014,SEC-015",
+		AnnotationDocumentationURLs: "https://controls.example.com/SEC-014",
+	})
+
+	info.CorrelationID = "705ab4f5-6393-11e8-b7cc-42010a800002"
+	assert.Equal(t, info.annotations()[AnnotationCorrelationID], "705ab4f5-6393-11e8-b7cc-42010a800002")
+
+	info.Violations = `[{"rule":"check-tag","check":"deny"}]`
+	assert.Equal(t, info.annotations()[AnnotationViolations], `[{"rule":"check-tag","check":"deny"}]`)
+}
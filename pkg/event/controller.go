@@ -8,6 +8,8 @@ import (
 	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
 	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
 	client "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/metrics/internalqueue"
 	"github.com/kyverno/kyverno/pkg/resourcecache"
 	v1 "k8s.io/api/core/v1"
 	errors "k8s.io/apimachinery/pkg/api/errors"
@@ -51,7 +53,7 @@ type Interface interface {
 }
 
 //NewEventGenerator to generate a new event controller
-func NewEventGenerator(client *client.Client, cpInformer kyvernoinformer.ClusterPolicyInformer, pInformer kyvernoinformer.PolicyInformer, resCache resourcecache.ResourceCache, log logr.Logger) *Generator {
+func NewEventGenerator(client *client.Client, cpInformer kyvernoinformer.ClusterPolicyInformer, pInformer kyvernoinformer.PolicyInformer, resCache resourcecache.ResourceCache, promConfig *metrics.PromConfig, log logr.Logger) *Generator {
 
 	gen := Generator{
 		client:               client,
@@ -59,7 +61,7 @@ func NewEventGenerator(client *client.Client, cpInformer kyvernoinformer.Cluster
 		cpSynced:             cpInformer.Informer().HasSynced,
 		pLister:              pInformer.Lister(),
 		pSynced:              pInformer.Informer().HasSynced,
-		queue:                workqueue.NewNamedRateLimitingQueue(rateLimiter(), eventWorkQueueName),
+		queue:                internalqueue.NewQueue(workqueue.NewNamedRateLimitingQueue(rateLimiter(), eventWorkQueueName), internalqueue.Events, promConfig, log),
 		policyCtrRecorder:    initRecorder(client, PolicyController, log),
 		admissionCtrRecorder: initRecorder(client, AdmissionController, log),
 		genPolicyRecorder:    initRecorder(client, GeneratePolicyController, log),
@@ -125,6 +127,8 @@ func (gen *Generator) Run(workers int, stopCh <-chan struct{}) {
 	for i := 0; i < workers; i++ {
 		go wait.Until(gen.runWorker, time.Second, stopCh)
 	}
+
+	go internalqueue.Run(gen.queue, stopCh)
 	<-stopCh
 }
 
@@ -217,15 +221,23 @@ func (gen *Generator) syncHandler(key Info) error {
 	}
 
 	// based on the source of event generation, use different event recorders
+	var recorder record.EventRecorder
 	switch key.Source {
 	case AdmissionController:
-		gen.admissionCtrRecorder.Event(robj, eventType, key.Reason, key.Message)
+		recorder = gen.admissionCtrRecorder
 	case PolicyController:
-		gen.policyCtrRecorder.Event(robj, eventType, key.Reason, key.Message)
+		recorder = gen.policyCtrRecorder
 	case GeneratePolicyController:
-		gen.genPolicyRecorder.Event(robj, eventType, key.Reason, key.Message)
+		recorder = gen.genPolicyRecorder
 	default:
 		logger.Info("info.source not defined for the request")
+		return nil
+	}
+
+	if annotations := key.annotations(); len(annotations) > 0 {
+		recorder.AnnotatedEventf(robj, annotations, eventType, key.Reason, "%s", key.Message)
+	} else {
+		recorder.Event(robj, eventType, key.Reason, key.Message)
 	}
 	return nil
 }
@@ -10,6 +10,12 @@ const (
 	PolicyApplied
 	//PolicyFailed policy failed
 	PolicyFailed
+	//PolicyBudgetExceeded the policies of an admission request exceeded the evaluation budget
+	PolicyBudgetExceeded
+	//GenerateRequestFailed a generate request failed after the maximum number of retries
+	GenerateRequestFailed
+	//PolicyConflict the patches of a mutate policy override the patches of another policy
+	PolicyConflict
 )
 
 func (r Reason) String() string {
@@ -17,5 +23,8 @@ func (r Reason) String() string {
 		"PolicyViolation",
 		"PolicyApplied",
 		"PolicyFailed",
+		"PolicyBudgetExceeded",
+		"GenerateRequestFailed",
+		"PolicyConflict",
 	}[r]
 }
@@ -1,5 +1,7 @@
 package event
 
+import "strings"
+
 const eventWorkQueueName = "kyverno-events"
 
 const workQueueRetryLimit = 10
@@ -12,4 +14,57 @@ type Info struct {
 	Reason    string
 	Message   string
 	Source    Source
+
+	// RuleIDs and DocumentationURLs are the comma separated IDs and documentation URLs
+	// of the rules the event is about, they are set as annotations on the event
+	RuleIDs           string
+	DocumentationURLs string
+
+	// CorrelationID identifies the admission request the event is generated in, it is set as an annotation on the event
+	CorrelationID string
+
+	// Violations is the JSON array of the failed checks of the rules, it is set as an annotation on the event
+	Violations string
+}
+
+const (
+	// AnnotationRuleIDs is the event annotation set to the IDs of the rules
+	AnnotationRuleIDs = "kyverno.io/rule-ids"
+
+	// AnnotationDocumentationURLs is the event annotation set to the documentation URLs of the rules
+	AnnotationDocumentationURLs = "kyverno.io/documentation-urls"
+
+	// AnnotationCorrelationID is the event annotation set to the correlation ID of the admission request
+	AnnotationCorrelationID = "kyverno.io/correlation-id"
+
+	// AnnotationViolations is the event annotation set to the violations of the failed rules
+	AnnotationViolations = "kyverno.io/violations"
+)
+
+// SetRuleDocumentation sets the IDs and documentation URLs of the rules
+func (i *Info) SetRuleDocumentation(ids, urls []string) {
+	i.RuleIDs = strings.Join(ids, ",")
+	i.DocumentationURLs = strings.Join(urls, ",")
+}
+
+// annotations returns the annotations of the event
+func (i Info) annotations() map[string]string {
+	annotations := make(map[string]string)
+	if i.RuleIDs != "" {
+		annotations[AnnotationRuleIDs] = i.RuleIDs
+	}
+
+	if i.DocumentationURLs != "" {
+		annotations[AnnotationDocumentationURLs] = i.DocumentationURLs
+	}
+
+	if i.CorrelationID != "" {
+		annotations[AnnotationCorrelationID] = i.CorrelationID
+	}
+
+	if i.Violations != "" {
+		annotations[AnnotationViolations] = i.Violations
+	}
+
+	return annotations
 }
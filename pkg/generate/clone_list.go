@@ -0,0 +1,92 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	pkgcommon "github.com/kyverno/kyverno/pkg/common"
+	dclient "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corelister "k8s.io/client-go/listers/core/v1"
+)
+
+// cloneListRuleLabel is set on the resources cloned by a cloneList, its value is the name of the rule
+const cloneListRuleLabel = "generate.kyverno.io/clone-list-rule"
+
+// applyCloneList clones the resources selected by the cloneList of the generate rule into the namespace
+// of the rule, each source is cloned with its name. With synchronize, the copies of the sources that are
+// no longer selected are deleted.
+func applyCloneList(log logr.Logger, client *dclient.Client, quotaLister corelister.ResourceQuotaLister, rule kyverno.Rule, resource unstructured.Unstructured, ctx context.EvalInterface, policy string, gr kyverno.GenerateRequest) ([]kyverno.ResourceSpec, error) {
+	cloneList := rule.Generation.CloneList
+	var genResources []kyverno.ResourceSpec
+	for _, gvk := range cloneList.Kinds {
+		apiVersion, kind := pkgcommon.GetKindFromGVK(gvk)
+		sources, err := client.ListResource(apiVersion, kind, cloneList.Namespace, cloneList.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the clone sources %s in namespace %s: %v", gvk, cloneList.Namespace, err)
+		}
+
+		for _, source := range sources.Items {
+			genResource, err := applyRule(log, client, quotaLister, cloneSourceRule(rule, source), resource, ctx, policy, gr)
+			if err != nil {
+				return nil, err
+			}
+
+			genResources = append(genResources, genResource)
+		}
+	}
+
+	if rule.Generation.Synchronize {
+		deleteStaleClones(log, client, rule, policy, gr, genResources)
+	}
+
+	return genResources, nil
+}
+
+// cloneSourceRule returns a copy of the rule cloning the source into the namespace of the rule
+func cloneSourceRule(rule kyverno.Rule, source unstructured.Unstructured) kyverno.Rule {
+	sourceRule := *rule.DeepCopy()
+	sourceRule.Generation.APIVersion = source.GetAPIVersion()
+	sourceRule.Generation.Kind = source.GetKind()
+	sourceRule.Generation.Name = source.GetName()
+	sourceRule.Generation.Clone = kyverno.CloneFrom{Namespace: source.GetNamespace(), Name: source.GetName()}
+	return sourceRule
+}
+
+// deleteStaleClones deletes the resources previously cloned by the cloneList of the rule that are not
+// in the generated resources, i.e. their source is deleted or no longer matches the selector
+func deleteStaleClones(log logr.Logger, client *dclient.Client, rule kyverno.Rule, policy string, gr kyverno.GenerateRequest, genResources []kyverno.ResourceSpec) {
+	generated := make(map[kyverno.ResourceSpec]bool, len(genResources))
+	for _, genResource := range genResources {
+		generated[genResource] = true
+	}
+
+	for _, previous := range gr.Status.GeneratedResources {
+		if generated[previous] || previous.Namespace != rule.Generation.Namespace {
+			continue
+		}
+
+		obj, err := client.GetResource(previous.APIVersion, previous.Kind, previous.Namespace, previous.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Error(err, "failed to get the cloned resource", "kind", previous.Kind, "namespace", previous.Namespace, "name", previous.Name)
+			}
+			continue
+		}
+
+		labels := obj.GetLabels()
+		if labels["policy.kyverno.io/policy-name"] != policy || labels[cloneListRuleLabel] != rule.Name || labels["policy.kyverno.io/synchronize"] != "enable" {
+			continue
+		}
+
+		if err := client.DeleteResource(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName(), false); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to delete the stale cloned resource", "kind", previous.Kind, "namespace", previous.Namespace, "name", previous.Name)
+			continue
+		}
+
+		log.V(2).Info("deleted the cloned resource, its source is no longer selected", "kind", previous.Kind, "namespace", previous.Namespace, "name", previous.Name)
+	}
+}
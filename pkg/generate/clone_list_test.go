@@ -0,0 +1,129 @@
+package generate
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	client "github.com/kyverno/kyverno/pkg/dclient"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newCloneListTestClient(t *testing.T, objects ...runtime.Object) *client.Client {
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+		{Version: "v1", Resource: "secrets"}:    "SecretList",
+	}
+
+	c, err := client.NewMockClient(runtime.NewScheme(), gvrToListKind, objects...)
+	assert.NilError(t, err)
+	c.SetDiscovery(client.NewFakeDiscoveryClient(nil))
+	return c
+}
+
+func Test_ApplyCloneList(t *testing.T) {
+	gameConfig := newConfigMap("default", "game-config", "1", "a")
+	gameConfig.SetLabels(map[string]string{"app": "game"})
+	gameUI := newConfigMap("default", "game-ui", "1", "b")
+	gameUI.SetLabels(map[string]string{"app": "game"})
+	other := newConfigMap("default", "other", "1", "c")
+
+	// the copy of a source that no longer matches the selector
+	stale := newConfigMap("team-a", "game-old", "1", "d")
+	stale.SetLabels(map[string]string{
+		"policy.kyverno.io/policy-name": "sync-game",
+		"policy.kyverno.io/synchronize": "enable",
+		cloneListRuleLabel:              "clone-game",
+	})
+
+	c := newCloneListTestClient(t, gameConfig, gameUI, other, stale)
+
+	rule := kyverno.Rule{
+		Name: "clone-game",
+		Generation: kyverno.Generation{
+			ResourceSpec: kyverno.ResourceSpec{Namespace: "team-a"},
+			Synchronize:  true,
+			CloneList: kyverno.CloneList{
+				Namespace: "default",
+				Kinds:     []string{"ConfigMap"},
+				Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "game"}},
+			},
+		},
+	}
+
+	trigger := unstructured.Unstructured{}
+	trigger.SetAPIVersion("v1")
+	trigger.SetKind("Namespace")
+	trigger.SetName("team-a")
+
+	gr := kyverno.GenerateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "gr-1"},
+		Status: kyverno.GenerateRequestStatus{
+			GeneratedResources: []kyverno.ResourceSpec{
+				{APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "game-config"},
+				{APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "game-old"},
+			},
+		},
+	}
+
+	genResources, err := applyCloneList(log.Log, c, nil, rule, trigger, context.NewContext(), "sync-game", gr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, genResources, []kyverno.ResourceSpec{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "game-config"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "game-ui"},
+	})
+
+	for _, name := range []string{"game-config", "game-ui"} {
+		obj, err := c.GetResource("v1", "ConfigMap", "team-a", name)
+		assert.NilError(t, err)
+		assert.Equal(t, obj.GetLabels()[cloneListRuleLabel], "clone-game")
+		assert.Equal(t, obj.GetLabels()["policy.kyverno.io/synchronize"], "enable")
+	}
+
+	// the resources that do not match the selector are not cloned, and the stale copy is deleted
+	_, err = c.GetResource("v1", "ConfigMap", "team-a", "other")
+	assert.Assert(t, apierrors.IsNotFound(err))
+	_, err = c.GetResource("v1", "ConfigMap", "team-a", "game-old")
+	assert.Assert(t, apierrors.IsNotFound(err))
+}
+
+func Test_CloneList_Sources(t *testing.T) {
+	policy := newCloneSourceTestPolicy()
+	policy.Spec.Rules = append(policy.Spec.Rules, kyverno.Rule{
+		Name: "clone-game",
+		Generation: kyverno.Generation{
+			ResourceSpec: kyverno.ResourceSpec{Namespace: "{{request.object.metadata.name}}"},
+			CloneList:    kyverno.CloneList{Namespace: "default", Kinds: []string{"ConfigMap", "v1/Secret"}},
+		},
+	})
+
+	kinds, sources := getCloneSources(policy)
+	assert.DeepEqual(t, kinds, []string{"ConfigMap", "v1/Secret"})
+	assert.DeepEqual(t, sources, []string{
+		cloneSourceKey("ConfigMap", "default", "game-config"),
+		cloneListKey("ConfigMap", "default"),
+		cloneListKey("Secret", "default"),
+	})
+
+	c := newCloneSourceTestController(t, policy)
+	gr := newCloneSourceTestGR("gr-1", "ns-1")
+	c.indexGenerateRequest(gr)
+	key := gr.GetNamespace() + "/" + gr.GetName()
+
+	// a new resource in the source namespace may be selected by the cloneList
+	c.addCloneSource(newConfigMap("default", "game-ui", "1", "a"))
+	assert.DeepEqual(t, drainQueue(c), []string{key})
+
+	c.addCloneSource(newConfigMap("prod", "game-ui", "1", "a"))
+	assert.Equal(t, c.queue.Len(), 0)
+
+	// any update in the source namespace re-queues the generate request once
+	c.updateCloneSource(newConfigMap("default", "game-config", "1", "a"), newConfigMap("default", "game-config", "2", "b"))
+	assert.DeepEqual(t, drainQueue(c), []string{key})
+}
@@ -0,0 +1,276 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	pkgcommon "github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/event"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cloneSourceIndex maps clone sources to the generate requests whose rules clone them,
+// so that a change to a source can be propagated to all generated copies.
+type cloneSourceIndex struct {
+	sync.RWMutex
+
+	// grKeys stores generate request keys by clone source key
+	grKeys map[string]map[string]bool
+
+	// sources stores clone source keys by generate request key
+	sources map[string][]string
+}
+
+func newCloneSourceIndex() *cloneSourceIndex {
+	return &cloneSourceIndex{
+		grKeys:  make(map[string]map[string]bool),
+		sources: make(map[string][]string),
+	}
+}
+
+// cloneSourceKey returns the index key of a clone source, i.e. <kind>/<namespace>/<name>
+func cloneSourceKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// cloneListKey returns the index key of the sources of a cloneList, the selector is not indexed
+// so the generate requests are re-queued for all the resources of the kind in the namespace
+func cloneListKey(kind, namespace string) string {
+	return cloneSourceKey(kind, namespace, "*")
+}
+
+// set replaces the clone sources of a generate request
+func (idx *cloneSourceIndex) set(grKey string, sources []string) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.removeLocked(grKey)
+	if len(sources) == 0 {
+		return
+	}
+
+	for _, source := range sources {
+		if idx.grKeys[source] == nil {
+			idx.grKeys[source] = make(map[string]bool)
+		}
+		idx.grKeys[source][grKey] = true
+	}
+	idx.sources[grKey] = sources
+}
+
+// remove deletes a generate request from the index
+func (idx *cloneSourceIndex) remove(grKey string) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.removeLocked(grKey)
+}
+
+func (idx *cloneSourceIndex) removeLocked(grKey string) {
+	for _, source := range idx.sources[grKey] {
+		delete(idx.grKeys[source], grKey)
+		if len(idx.grKeys[source]) == 0 {
+			delete(idx.grKeys, source)
+		}
+	}
+	delete(idx.sources, grKey)
+}
+
+// get returns the sorted keys of the generate requests that clone one of the sources
+func (idx *cloneSourceIndex) get(sources ...string) []string {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	seen := make(map[string]bool)
+	grKeys := make([]string, 0)
+	for _, source := range sources {
+		for grKey := range idx.grKeys[source] {
+			if !seen[grKey] {
+				seen[grKey] = true
+				grKeys = append(grKeys, grKey)
+			}
+		}
+	}
+	sort.Strings(grKeys)
+	return grKeys
+}
+
+// getCloneSources returns the kinds and the index keys of the clone sources in the generate rules of a policy
+func getCloneSources(policy *kyverno.ClusterPolicy) (kinds []string, sources []string) {
+	seenKinds := make(map[string]bool)
+	seenSources := make(map[string]bool)
+	for _, rule := range policy.Spec.Rules {
+		if rule.HasCloneList() {
+			for _, gvk := range rule.Generation.CloneList.Kinds {
+				_, kind := pkgcommon.GetKindFromGVK(gvk)
+				source := cloneListKey(kind, rule.Generation.CloneList.Namespace)
+				if !seenSources[source] {
+					seenSources[source] = true
+					sources = append(sources, source)
+				}
+
+				if !seenKinds[gvk] {
+					seenKinds[gvk] = true
+					kinds = append(kinds, gvk)
+				}
+			}
+			continue
+		}
+
+		clone := rule.Generation.Clone
+		if !rule.HasGenerate() || clone.Name == "" {
+			continue
+		}
+
+		source := cloneSourceKey(rule.Generation.Kind, clone.Namespace, clone.Name)
+		if !seenSources[source] {
+			seenSources[source] = true
+			sources = append(sources, source)
+		}
+
+		if !seenKinds[rule.Generation.Kind] {
+			seenKinds[rule.Generation.Kind] = true
+			kinds = append(kinds, rule.Generation.Kind)
+		}
+	}
+
+	return kinds, sources
+}
+
+// indexGenerateRequest updates the clone source index for a generate request and
+// starts watching the kinds of its clone sources and of its synchronized resources
+func (c *Controller) indexGenerateRequest(gr *kyverno.GenerateRequest) {
+	key, err := cache.MetaNamespaceKeyFunc(gr)
+	if err != nil {
+		c.log.Error(err, "failed to extract name")
+		return
+	}
+
+	policy, err := c.policyLister.Get(gr.Spec.Policy)
+	if err != nil {
+		c.cloneSources.remove(key)
+		return
+	}
+
+	kinds, sources := getCloneSources(policy)
+	c.cloneSources.set(key, sources)
+	for _, kind := range kinds {
+		c.watchCloneSourceKind(kind)
+	}
+
+	for _, kind := range synchronizedKinds(policy) {
+		c.watchGeneratedKind(kind)
+	}
+}
+
+// watchCloneSourceKind registers the clone source handlers for a kind once
+func (c *Controller) watchCloneSourceKind(kind string) {
+	c.watchedKindsLock.Lock()
+	defer c.watchedKindsLock.Unlock()
+
+	if c.watchedKinds[kind] {
+		return
+	}
+
+	gc, err := c.resCache.CreateGVKInformer(kind)
+	if err != nil {
+		c.log.Error(err, "failed to watch clone source", "kind", kind)
+		return
+	}
+
+	gc.GetInformer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.addCloneSource,
+		UpdateFunc: c.updateCloneSource,
+		DeleteFunc: c.deleteCloneSource,
+	})
+	c.watchedKinds[kind] = true
+}
+
+// addCloneSource re-queues the generate requests whose cloneList may select the new resource
+func (c *Controller) addCloneSource(obj interface{}) {
+	r := obj.(*unstructured.Unstructured)
+	source := cloneListKey(r.GetKind(), r.GetNamespace())
+	grKeys := c.cloneSources.get(source)
+	if len(grKeys) == 0 {
+		return
+	}
+
+	c.log.V(4).Info("clone source added, re-syncing cloned resources", "source", source, "generateRequests", len(grKeys))
+	for _, key := range grKeys {
+		c.queue.Add(key)
+	}
+}
+
+// updateCloneSource re-queues all generate requests that clone the updated source,
+// so that the generated copies are synchronized with it
+func (c *Controller) updateCloneSource(old, cur interface{}) {
+	oldR := old.(*unstructured.Unstructured)
+	curR := cur.(*unstructured.Unstructured)
+	if oldR.GetResourceVersion() == curR.GetResourceVersion() {
+		return
+	}
+
+	source := cloneSourceKey(curR.GetKind(), curR.GetNamespace(), curR.GetName())
+	grKeys := c.cloneSources.get(source, cloneListKey(curR.GetKind(), curR.GetNamespace()))
+	if len(grKeys) == 0 {
+		return
+	}
+
+	c.log.V(4).Info("clone source updated, re-syncing generated resources", "source", source, "generateRequests", len(grKeys))
+	for _, key := range grKeys {
+		c.queue.Add(key)
+	}
+}
+
+// deleteCloneSource handles the deletion of a clone source. Generated copies are left in place,
+// and an event is reported on each policy that clones the source. The generate requests whose
+// cloneList may select the source are re-queued, the synchronized copies of the source are deleted.
+func (c *Controller) deleteCloneSource(obj interface{}) {
+	r, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			c.log.Info("couldn't get object from tombstone", "obj", obj)
+			return
+		}
+
+		r, ok = tombstone.Obj.(*unstructured.Unstructured)
+		if !ok {
+			c.log.Info("tombstone contained object that is not a resource", "obj", obj)
+			return
+		}
+	}
+
+	for _, key := range c.cloneSources.get(cloneListKey(r.GetKind(), r.GetNamespace())) {
+		c.queue.Add(key)
+	}
+
+	source := cloneSourceKey(r.GetKind(), r.GetNamespace(), r.GetName())
+	policies := make(map[string]bool)
+	for _, key := range c.cloneSources.get(source) {
+		_, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+
+		gr, err := c.grLister.Get(name)
+		if err != nil {
+			continue
+		}
+		policies[gr.Spec.Policy] = true
+	}
+
+	for policy := range policies {
+		c.log.Info("clone source deleted, generated resources are left in place", "source", source, "policy", policy)
+		c.eventGen.Add(event.Info{
+			Kind:    "ClusterPolicy",
+			Name:    policy,
+			Reason:  event.PolicyFailed.String(),
+			Source:  event.GeneratePolicyController,
+			Message: fmt.Sprintf("clone source %s %s/%s was deleted, generated resources are not synchronized", r.GetKind(), r.GetNamespace(), r.GetName()),
+		})
+	}
+}
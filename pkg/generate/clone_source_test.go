@@ -0,0 +1,154 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type dummyResourceCache struct{}
+
+func (dummyResourceCache) CreateInformers(gvks ...string) []error { return nil }
+
+func (dummyResourceCache) CreateGVKInformer(gvk string) (resourcecache.GenericCache, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (dummyResourceCache) StopResourceInformer(gvk string) {}
+
+func (dummyResourceCache) GetGVRCache(gvk string) (resourcecache.GenericCache, bool) {
+	return nil, false
+}
+
+func (dummyResourceCache) GetAPICallCache(gvr schema.GroupVersionResource) (resourcecache.GenericCache, bool) {
+	return nil, false
+}
+
+func newCloneSourceTestController(t *testing.T, policy *kyverno.ClusterPolicy) *Controller {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, indexer.Add(policy))
+
+	return &Controller{
+		queue:                 workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "generate-request-test"),
+		policyLister:          kyvernolister.NewClusterPolicyLister(indexer),
+		log:                   log.Log,
+		resCache:              dummyResourceCache{},
+		cloneSources:          newCloneSourceIndex(),
+		watchedKinds:          make(map[string]bool),
+		watchedGeneratedKinds: make(map[string]bool),
+	}
+}
+
+func newCloneSourceTestPolicy() *kyverno.ClusterPolicy {
+	return &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "sync-configmap"},
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{
+					Name: "clone-configmap",
+					Generation: kyverno.Generation{
+						ResourceSpec: kyverno.ResourceSpec{Kind: "ConfigMap", Name: "game-config", Namespace: "{{request.object.metadata.name}}"},
+						Synchronize:  true,
+						Clone:        kyverno.CloneFrom{Namespace: "default", Name: "game-config"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newCloneSourceTestGR(name, namespace string) *kyverno.GenerateRequest {
+	return &kyverno.GenerateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: config.KyvernoNamespace},
+		Spec: kyverno.GenerateRequestSpec{
+			Policy:   "sync-configmap",
+			Resource: kyverno.ResourceSpec{Kind: "Namespace", Name: namespace},
+		},
+	}
+}
+
+func newConfigMap(namespace, name, resourceVersion, data string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       namespace,
+			"resourceVersion": resourceVersion,
+		},
+		"data": map[string]interface{}{
+			"game": data,
+		},
+	}}
+}
+
+func drainQueue(c *Controller) []string {
+	var keys []string
+	for c.queue.Len() > 0 {
+		key, _ := c.queue.Get()
+		keys = append(keys, key.(string))
+		c.queue.Done(key)
+		c.queue.Forget(key)
+	}
+	return keys
+}
+
+func Test_CloneSource_Update_Fans_Out(t *testing.T) {
+	c := newCloneSourceTestController(t, newCloneSourceTestPolicy())
+
+	var expected []string
+	for i := 1; i <= 3; i++ {
+		gr := newCloneSourceTestGR(fmt.Sprintf("gr-%d", i), fmt.Sprintf("ns-%d", i))
+		c.indexGenerateRequest(gr)
+		expected = append(expected, config.KyvernoNamespace+"/"+gr.GetName())
+	}
+
+	// periodic resync does not re-queue the generate requests
+	c.updateCloneSource(newConfigMap("default", "game-config", "1", "a"), newConfigMap("default", "game-config", "1", "a"))
+	assert.Equal(t, c.queue.Len(), 0)
+
+	// an unrelated config map does not re-queue the generate requests
+	c.updateCloneSource(newConfigMap("default", "other", "1", "a"), newConfigMap("default", "other", "2", "b"))
+	assert.Equal(t, c.queue.Len(), 0)
+
+	// a source data change re-queues the generate request of every target namespace
+	c.updateCloneSource(newConfigMap("default", "game-config", "1", "a"), newConfigMap("default", "game-config", "2", "b"))
+	assert.DeepEqual(t, drainQueue(c), expected)
+
+	// deleted generate requests are removed from the index
+	c.cloneSources.remove(expected[0])
+	c.updateCloneSource(newConfigMap("default", "game-config", "2", "b"), newConfigMap("default", "game-config", "3", "c"))
+	assert.DeepEqual(t, drainQueue(c), expected[1:])
+}
+
+func Test_CloneSource_Index(t *testing.T) {
+	idx := newCloneSourceIndex()
+	source := cloneSourceKey("ConfigMap", "default", "game-config")
+	other := cloneSourceKey("Secret", "default", "regcred")
+
+	idx.set("kyverno/gr-1", []string{source})
+	idx.set("kyverno/gr-2", []string{source, other})
+	assert.DeepEqual(t, idx.get(source), []string{"kyverno/gr-1", "kyverno/gr-2"})
+	assert.DeepEqual(t, idx.get(other), []string{"kyverno/gr-2"})
+
+	// the policy no longer clones the config map
+	idx.set("kyverno/gr-2", []string{other})
+	assert.DeepEqual(t, idx.get(source), []string{"kyverno/gr-1"})
+
+	idx.remove("kyverno/gr-2")
+	assert.Equal(t, len(idx.get(other)), 0)
+	assert.Equal(t, len(idx.grKeys), 1)
+	assert.Equal(t, len(idx.sources), 1)
+}
@@ -64,3 +64,42 @@ func (e *ConfigNotFound) Error() string {
 func NewConfigNotFound(config interface{}, kind, namespace, name string) *ConfigNotFound {
 	return &ConfigNotFound{config: config, kind: kind, namespace: namespace, name: name}
 }
+
+// QuotaExceeded stores the resource that would exceed a ResourceQuota of its namespace
+type QuotaExceeded struct {
+	kind      string
+	namespace string
+	name      string
+	quota     string
+	resource  string
+	requested string
+	available string
+}
+
+func (e *QuotaExceeded) Error() string {
+	return fmt.Sprintf("resource %s/%s/%s exceeds ResourceQuota %s: requested %s=%s, available %s=%s", e.kind, e.namespace, e.name, e.quota, e.resource, e.requested, e.resource, e.available)
+}
+
+// NewQuotaExceeded returns a new QuotaExceeded error
+func NewQuotaExceeded(kind, namespace, name, quota, resource, requested, available string) *QuotaExceeded {
+	return &QuotaExceeded{kind: kind, namespace: namespace, name: name, quota: quota, resource: resource, requested: requested, available: available}
+}
+
+// InvalidRule stores the error of a rule that cannot be applied as it is invalid, e.g. a variable cannot be resolved
+type InvalidRule struct {
+	rule string
+	err  error
+}
+
+func (e *InvalidRule) Error() string {
+	return fmt.Sprintf("invalid rule %s: %v", e.rule, e.err)
+}
+
+func (e *InvalidRule) Unwrap() error {
+	return e.err
+}
+
+// NewInvalidRule returns a new InvalidRule error
+func NewInvalidRule(rule string, err error) *InvalidRule {
+	return &InvalidRule{rule: rule, err: err}
+}
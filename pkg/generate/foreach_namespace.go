@@ -0,0 +1,333 @@
+package generate
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// forEachNamespaceLabel marks the generate requests created for the namespaces matching the
+// selector of a forEachNamespace rule, rather than for an admission request
+const forEachNamespaceLabel = "generate.kyverno.io/for-each-namespace"
+
+// forEachNamespacePlan lists the changes to the generate requests of the forEachNamespace rules
+// of a policy
+type forEachNamespacePlan struct {
+	// create are the names of the namespaces a generate request is created for
+	create []string
+
+	// remove are the generate requests of the namespaces that no longer match, the generated
+	// resources are deleted with them
+	remove []*kyverno.GenerateRequest
+}
+
+// planForEachNamespace returns the generate requests to create for the namespaces matching the
+// selector of a forEachNamespace rule of the policy, and the ones to remove for the namespaces
+// matching none. The generate requests are the ones of the policy triggered by a namespace.
+// The generate requests created on the admission of a namespace are only removed if all the
+// generate rules of the policy are forEachNamespace rules, as other rules may apply.
+func planForEachNamespace(policy *kyverno.ClusterPolicy, namespaces []unstructured.Unstructured, grs []*kyverno.GenerateRequest, log logr.Logger) forEachNamespacePlan {
+	existing := make(map[string][]*kyverno.GenerateRequest)
+	for _, gr := range grs {
+		if gr.Spec.Policy != policy.Name || gr.Spec.Resource.Kind != "Namespace" {
+			continue
+		}
+
+		existing[gr.Spec.Resource.Name] = append(existing[gr.Spec.Resource.Name], gr)
+	}
+
+	onlyForEachNamespace := true
+	for _, rule := range policy.Spec.Rules {
+		if rule.HasGenerate() && !rule.IsForEachNamespace() {
+			onlyForEachNamespace = false
+		}
+	}
+
+	var plan forEachNamespacePlan
+	for _, namespace := range namespaces {
+		name := namespace.GetName()
+		if namespace.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		if matchesForEachNamespace(policy, namespace, log) {
+			if len(existing[name]) == 0 {
+				plan.create = append(plan.create, name)
+			}
+			continue
+		}
+
+		for _, gr := range existing[name] {
+			if onlyForEachNamespace || gr.GetLabels()[forEachNamespaceLabel] == "true" {
+				plan.remove = append(plan.remove, gr)
+			}
+		}
+	}
+
+	sort.Strings(plan.create)
+	sort.Slice(plan.remove, func(i, j int) bool {
+		return plan.remove[i].Name < plan.remove[j].Name
+	})
+
+	return plan
+}
+
+// matchesForEachNamespace checks if the namespace matches the selector of a forEachNamespace rule of the policy
+func matchesForEachNamespace(policy *kyverno.ClusterPolicy, namespace unstructured.Unstructured, log logr.Logger) bool {
+	for _, rule := range policy.Spec.Rules {
+		if !rule.IsForEachNamespace() {
+			continue
+		}
+
+		ok, err := engine.MatchesForEachNamespace(rule, namespace)
+		if err != nil {
+			log.Error(err, "failed to match the forEachNamespace selector", "policy", policy.Name, "rule", rule.Name)
+			continue
+		}
+
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// syncForEachNamespace creates and removes the generate requests of the forEachNamespace rules
+// of the policy, for the namespaces entering and leaving their selectors
+func (c *Controller) syncForEachNamespace(key string) error {
+	logger := c.log.WithValues("policy", key)
+
+	policy, err := c.policyLister.Get(key)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the generate requests of a deleted policy are removed by the cleanup controller
+			return nil
+		}
+
+		return err
+	}
+
+	objs, err := c.nsInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	namespaces := make([]unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if ns, ok := obj.(*unstructured.Unstructured); ok {
+			namespaces = append(namespaces, *ns)
+		}
+	}
+
+	// the generate requests are listed from the API server, the ones created by the previous sync
+	// may not be in the informer cache yet
+	grList, err := c.kyvernoClient.KyvernoV1().GenerateRequests(config.KyvernoNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"generate.kyverno.io/policy-name": policy.Name}).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	grs := make([]*kyverno.GenerateRequest, 0, len(grList.Items))
+	for i := range grList.Items {
+		grs = append(grs, &grList.Items[i])
+	}
+
+	// while the background processing is paused the plan is continued once resumed, the generate
+	// requests are created or removed at most once as the plan only lists the missing and stale ones
+	plan := planForEachNamespace(policy, namespaces, grs, logger)
+	for _, namespace := range plan.create {
+		if !c.backgroundGate.Wait() {
+			return nil
+		}
+
+		if err := c.createForEachNamespaceRequest(policy.Name, namespace); err != nil {
+			logger.Error(err, "failed to create generate request", "namespace", namespace)
+			return err
+		}
+
+		logger.V(3).Info("created generate request for the namespace", "namespace", namespace)
+	}
+
+	for _, gr := range plan.remove {
+		if !c.backgroundGate.Wait() {
+			return nil
+		}
+
+		if err := c.removeForEachNamespaceRequest(gr, logger); err != nil {
+			logger.Error(err, "failed to remove generate request", "name", gr.Name, "namespace", gr.Spec.Resource.Name)
+			return err
+		}
+
+		logger.V(3).Info("removed generate request of the namespace", "name", gr.Name, "namespace", gr.Spec.Resource.Name)
+	}
+
+	return nil
+}
+
+func (c *Controller) createForEachNamespaceRequest(policy, namespace string) error {
+	gr := &kyverno.GenerateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "gr-",
+			Namespace:    config.KyvernoNamespace,
+			Labels: map[string]string{
+				"generate.kyverno.io/policy-name":        policy,
+				"generate.kyverno.io/resource-name":      namespace,
+				"generate.kyverno.io/resource-kind":      "Namespace",
+				"generate.kyverno.io/resource-namespace": "",
+				forEachNamespaceLabel:                    "true",
+			},
+		},
+		Spec: kyverno.GenerateRequestSpec{
+			Policy: policy,
+			Resource: kyverno.ResourceSpec{
+				APIVersion: "v1",
+				Kind:       "Namespace",
+				Name:       namespace,
+			},
+		},
+	}
+
+	_, err := c.kyvernoClient.KyvernoV1().GenerateRequests(config.KyvernoNamespace).Create(context.TODO(), gr, metav1.CreateOptions{})
+	return err
+}
+
+// removeForEachNamespaceRequest deletes the resources generated for the namespace by the policy,
+// whether they are synchronized or not, and then the generate request
+func (c *Controller) removeForEachNamespaceRequest(gr *kyverno.GenerateRequest, log logr.Logger) error {
+	for _, resource := range gr.Status.GeneratedResources {
+		r, err := c.client.GetResource(resource.APIVersion, resource.Kind, resource.Namespace, resource.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if r.GetLabels()["policy.kyverno.io/policy-name"] != gr.Spec.Policy {
+			log.V(4).Info("skipping resource not generated by the policy", "kind", r.GetKind(), "namespace", r.GetNamespace(), "name", r.GetName())
+			continue
+		}
+
+		if err := c.client.DeleteResource(r.GetAPIVersion(), r.GetKind(), r.GetNamespace(), r.GetName(), false); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	err := c.kyvernoClient.KyvernoV1().GenerateRequests(config.KyvernoNamespace).Delete(context.TODO(), gr.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Controller) addForEachNamespacePolicy(obj interface{}) {
+	policy, ok := obj.(*kyverno.ClusterPolicy)
+	if !ok || !policy.HasForEachNamespace() {
+		return
+	}
+
+	c.enqueueForEachNamespace(policy)
+}
+
+// updateForEachNamespacePolicy syncs the policies with forEachNamespace rules, and the
+// policies the forEachNamespace rules are removed from
+func (c *Controller) updateForEachNamespacePolicy(old, cur interface{}) {
+	oldP, ok := old.(*kyverno.ClusterPolicy)
+	if !ok {
+		return
+	}
+
+	curP, ok := cur.(*kyverno.ClusterPolicy)
+	if !ok || oldP.ResourceVersion == curP.ResourceVersion {
+		return
+	}
+
+	if oldP.HasForEachNamespace() || curP.HasForEachNamespace() {
+		c.enqueueForEachNamespace(curP)
+	}
+}
+
+// addNamespace and updateNamespace sync the policies with forEachNamespace rules, the namespace
+// may enter or leave their selectors
+func (c *Controller) addNamespace(obj interface{}) {
+	c.enqueueForEachNamespacePolicies()
+}
+
+func (c *Controller) updateNamespace(old, cur interface{}) {
+	oldNs, ok := old.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	curNs, ok := cur.(*unstructured.Unstructured)
+	if !ok || labels.Equals(oldNs.GetLabels(), curNs.GetLabels()) {
+		return
+	}
+
+	c.enqueueForEachNamespacePolicies()
+}
+
+func (c *Controller) enqueueForEachNamespacePolicies() {
+	policies, err := c.policyLister.List(labels.Everything())
+	if err != nil {
+		c.log.Error(err, "failed to list policies")
+		return
+	}
+
+	for _, policy := range policies {
+		if policy.HasForEachNamespace() {
+			c.enqueueForEachNamespace(policy)
+		}
+	}
+}
+
+func (c *Controller) enqueueForEachNamespace(policy *kyverno.ClusterPolicy) {
+	key, err := cache.MetaNamespaceKeyFunc(policy)
+	if err != nil {
+		c.log.Error(err, "failed to extract name")
+		return
+	}
+
+	c.forEachNamespaceQueue.Add(key)
+}
+
+func (c *Controller) forEachNamespaceWorker() {
+	for c.processNextForEachNamespace() {
+	}
+}
+
+func (c *Controller) processNextForEachNamespace() bool {
+	key, quit := c.forEachNamespaceQueue.Get()
+	if quit {
+		return false
+	}
+
+	defer c.forEachNamespaceQueue.Done(key)
+	err := c.syncForEachNamespace(key.(string))
+	if err == nil {
+		c.forEachNamespaceQueue.Forget(key)
+		return true
+	}
+
+	if c.forEachNamespaceQueue.NumRequeues(key) < maxRetries {
+		c.log.V(3).Info("retrying forEachNamespace sync", "policy", key, "error", err.Error())
+		c.forEachNamespaceQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.log.Error(err, "failed to sync forEachNamespace rules", "policy", key)
+	c.forEachNamespaceQueue.Forget(key)
+	return true
+}
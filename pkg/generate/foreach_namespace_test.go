@@ -0,0 +1,139 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newForEachNamespaceTestPolicy(t *testing.T) *kyverno.ClusterPolicy {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "team-rolebinding"},
+		"spec": {
+			"rules": [
+				{
+					"name": "generate-rolebinding",
+					"match": {"resources": {"kinds": ["Namespace"]}},
+					"generate": {
+						"kind": "RoleBinding",
+						"name": "team-edit",
+						"namespace": "{{request.object.metadata.name}}",
+						"synchronize": true,
+						"forEachNamespace": {"selector": {"matchExpressions": [{"key": "team", "operator": "Exists"}]}},
+						"data": {"roleRef": {"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "edit"}}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+	return &policy
+}
+
+func newTestNamespace(name string, labels map[string]string) unstructured.Unstructured {
+	ns := unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(name)
+	ns.SetLabels(labels)
+	return ns
+}
+
+func newTestNamespaceRequest(name, policy, namespace string, forEachNamespace bool) *kyverno.GenerateRequest {
+	gr := &kyverno.GenerateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kyverno.GenerateRequestSpec{
+			Policy:   policy,
+			Resource: kyverno.ResourceSpec{APIVersion: "v1", Kind: "Namespace", Name: namespace},
+		},
+	}
+
+	if forEachNamespace {
+		gr.SetLabels(map[string]string{forEachNamespaceLabel: "true"})
+	}
+
+	return gr
+}
+
+func requestNames(grs []*kyverno.GenerateRequest) []string {
+	names := []string{}
+	for _, gr := range grs {
+		names = append(names, gr.Name)
+	}
+	return names
+}
+
+func Test_PlanForEachNamespace(t *testing.T) {
+	policy := newForEachNamespaceTestPolicy(t)
+
+	// the policy is created, a generate request is created for each existing namespace matching the selector
+	namespaces := []unstructured.Unstructured{
+		newTestNamespace("web", map[string]string{"team": "web"}),
+		newTestNamespace("api", map[string]string{"team": "api"}),
+		newTestNamespace("default", nil),
+	}
+
+	plan := planForEachNamespace(policy, namespaces, nil, log.Log)
+	assert.DeepEqual(t, plan.create, []string{"api", "web"})
+	assert.Equal(t, len(plan.remove), 0)
+
+	// the namespaces with a generate request are in sync
+	grs := []*kyverno.GenerateRequest{
+		newTestNamespaceRequest("gr-web", policy.Name, "web", true),
+		newTestNamespaceRequest("gr-api", policy.Name, "api", true),
+	}
+
+	plan = planForEachNamespace(policy, namespaces, grs, log.Log)
+	assert.Equal(t, len(plan.create), 0)
+	assert.Equal(t, len(plan.remove), 0)
+
+	// a namespace gains the label later
+	namespaces[2] = newTestNamespace("default", map[string]string{"team": "platform"})
+	plan = planForEachNamespace(policy, namespaces, grs, log.Log)
+	assert.DeepEqual(t, plan.create, []string{"default"})
+	assert.Equal(t, len(plan.remove), 0)
+
+	// a namespace loses the label, its generate request is removed with the generated resources
+	namespaces[0] = newTestNamespace("web", map[string]string{"owner": "web"})
+	grs = append(grs, newTestNamespaceRequest("gr-default", policy.Name, "default", true))
+	plan = planForEachNamespace(policy, namespaces, grs, log.Log)
+	assert.Equal(t, len(plan.create), 0)
+	assert.DeepEqual(t, requestNames(plan.remove), []string{"gr-web"})
+}
+
+func Test_PlanForEachNamespace_AdmissionRequests(t *testing.T) {
+	policy := newForEachNamespaceTestPolicy(t)
+	namespaces := []unstructured.Unstructured{
+		newTestNamespace("web", map[string]string{"team": "web"}),
+		newTestNamespace("batch", nil),
+	}
+
+	// the generate request created on the admission of the namespace is reused
+	grs := []*kyverno.GenerateRequest{
+		newTestNamespaceRequest("gr-web", policy.Name, "web", false),
+		newTestNamespaceRequest("gr-batch", policy.Name, "batch", false),
+		newTestNamespaceRequest("gr-other", "other-policy", "batch", true),
+	}
+
+	plan := planForEachNamespace(policy, namespaces, grs, log.Log)
+	assert.Equal(t, len(plan.create), 0)
+	assert.DeepEqual(t, requestNames(plan.remove), []string{"gr-batch"})
+
+	// with another generate rule in the policy, only the forEachNamespace generate requests are removed
+	otherRule := policy.Spec.Rules[0]
+	otherRule.Name = "generate-configmap"
+	otherRule.Generation.ForEachNamespace = nil
+	policy.Spec.Rules = append(policy.Spec.Rules, otherRule)
+
+	plan = planForEachNamespace(policy, namespaces, grs, log.Log)
+	assert.Equal(t, len(plan.remove), 0)
+}
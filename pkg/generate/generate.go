@@ -23,6 +23,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	corelister "k8s.io/client-go/listers/core/v1"
 )
 
 func (c *Controller) processGR(gr *kyverno.GenerateRequest) error {
@@ -55,9 +56,15 @@ func (c *Controller) processGR(gr *kyverno.GenerateRequest) error {
 			return nil
 		}
 
-		// 3 - Report failure Events
-		events := failedEvents(err, *gr, *resource)
-		c.eventGen.Add(events...)
+		// 3 - Report failure Events once the request is not retried anymore, a resource that does not fit into the quota is not a failure
+		var quotaErr *QuotaExceeded
+		if errors.As(err, &quotaErr) {
+			logger.V(2).Info("skipping generate request, the generated resource exceeds the namespace quota", "details", err.Error())
+		} else if failedAttempts(gr.Status) >= GetRetryPolicy().MaxRetries {
+			c.deadLetter(logger, *gr, *resource, err)
+		} else {
+			logger.V(2).Info("failed to process generate request, retrying", "retries", failedAttempts(gr.Status), "reason", failureReason(err), "details", err.Error())
+		}
 	}
 
 	// 4 - Update Status
@@ -133,6 +140,8 @@ func (c *Controller) applyGenerate(resource unstructured.Unstructured, gr kyvern
 		AdmissionInfo:       gr.Spec.Context.UserRequestInfo,
 		ExcludeGroupRole:    c.Config.GetExcludeGroupRole(),
 		ExcludeResourceFunc: c.Config.ToFilter,
+		SensitiveKinds:      c.Config.GetSensitiveKinds(),
+		MatchOriginalUser:   c.Config.GetMatchOriginalUser(),
 		ResourceCache:       c.resCache,
 		JSONContext:         ctx,
 		NamespaceLabels:     namespaceLabels,
@@ -179,8 +188,23 @@ func (c *Controller) applyGenerate(resource unstructured.Unstructured, gr kyvern
 }
 
 func updateStatus(statusControl StatusControlInterface, gr kyverno.GenerateRequest, err error, genResources []kyverno.ResourceSpec) error {
+	var quotaErr *QuotaExceeded
+	if errors.As(err, &quotaErr) {
+		return statusControl.Skipped(gr, err.Error(), genResources)
+	}
+
+	// a failed attempt is retried with a backoff until the maximum number of retries
 	if err != nil {
-		return statusControl.Failed(gr, err.Error(), genResources)
+		reason := failureReason(err)
+		if failedAttempts(gr.Status) >= GetRetryPolicy().MaxRetries {
+			return statusControl.Failed(gr, err.Error(), reason, genResources)
+		}
+
+		if statusErr := statusControl.Retrying(gr, err.Error(), reason, genResources); statusErr != nil {
+			return statusErr
+		}
+
+		return &retryError{err: err}
 	}
 
 	// Generate request successfully processed
@@ -212,7 +236,8 @@ func (c *Controller) applyGeneratePolicy(log logr.Logger, policyContext *engine.
 		processExisting := false
 		var genResource kyverno.ResourceSpec
 
-		if len(rule.MatchResources.Kinds) > 0 {
+		// the forEachNamespace rules are applied to the existing namespaces
+		if len(rule.MatchResources.Kinds) > 0 && !rule.IsForEachNamespace() {
 			if len(rule.MatchResources.Annotations) == 0 && rule.MatchResources.Selector == nil {
 				rcreationTime := resource.GetCreationTimestamp()
 				pcreationTime := policy.GetCreationTimestamp()
@@ -228,18 +253,30 @@ func (c *Controller) applyGeneratePolicy(log logr.Logger, policyContext *engine.
 
 		if rule, err = variables.SubstituteAllInRule(log, policyContext.JSONContext, rule); err != nil {
 			log.Error(err, "variable substitution failed for rule %s", rule.Name)
-			return nil, err
+			return nil, NewInvalidRule(rule.Name, err)
 		}
 
 		if !processExisting {
-			genResource, err = applyRule(log, c.client, rule, resource, jsonContext, policy.Name, gr)
+			var ruleResources []kyverno.ResourceSpec
+			if rule.HasCloneList() {
+				ruleResources, err = applyCloneList(log, c.client, c.quotaLister, rule, resource, jsonContext, policy.Name, gr)
+			} else {
+				genResource, err = applyRule(log, c.client, c.quotaLister, rule, resource, jsonContext, policy.Name, gr)
+				ruleResources = []kyverno.ResourceSpec{genResource}
+			}
+
 			if err != nil {
+				var quotaErr *QuotaExceeded
+				if errors.As(err, &quotaErr) {
+					return nil, err
+				}
+
 				log.Error(err, "failed to apply generate rule", "policy", policy.Name,
 					"rule", rule.Name, "resource", resource.GetName(), "suggestion", "users need to grant Kyverno's service account additional privileges")
 				return nil, err
 			}
 			ruleNameToProcessingTime[rule.Name] = time.Since(startTime)
-			genResources = append(genResources, genResource)
+			genResources = append(genResources, ruleResources...)
 		}
 	}
 
@@ -266,7 +303,7 @@ func getResourceInfo(object map[string]interface{}) (kind, name, namespace, apiv
 	return
 }
 
-func applyRule(log logr.Logger, client *dclient.Client, rule kyverno.Rule, resource unstructured.Unstructured, ctx context.EvalInterface, policy string, gr kyverno.GenerateRequest) (kyverno.ResourceSpec, error) {
+func applyRule(log logr.Logger, client *dclient.Client, quotaLister corelister.ResourceQuotaLister, rule kyverno.Rule, resource unstructured.Unstructured, ctx context.EvalInterface, policy string, gr kyverno.GenerateRequest) (kyverno.ResourceSpec, error) {
 	var rdata map[string]interface{}
 	var err error
 	var mode ResourceMode
@@ -335,11 +372,18 @@ func applyRule(log logr.Logger, client *dclient.Client, rule kyverno.Rule, resou
 	// "kyverno.io/generated-by-namespace": namespace (trigger resource)
 	// "kyverno.io/generated-by-name": name (trigger resource)
 	manageLabels(newResource, resource)
+	if rule.Generation.OwnerReference && !setOwnerReference(newResource, resource) {
+		logger.V(2).Info("the owner reference is not set, the namespaced trigger resource cannot own a resource of another namespace",
+			"triggerNamespace", resource.GetNamespace())
+	}
 	// Add Synchronize label
 	label := newResource.GetLabels()
 	label["policy.kyverno.io/policy-name"] = policy
 	label["policy.kyverno.io/gr-name"] = gr.Name
 	delete(label, "generate.kyverno.io/clone-policy-name")
+	if rule.HasCloneList() {
+		label[cloneListRuleLabel] = rule.Name
+	}
 	if mode == Create {
 		if rule.Generation.Synchronize {
 			label["policy.kyverno.io/synchronize"] = "enable"
@@ -350,6 +394,12 @@ func applyRule(log logr.Logger, client *dclient.Client, rule kyverno.Rule, resou
 		// Reset resource version
 		newResource.SetResourceVersion("")
 		newResource.SetLabels(label)
+
+		// Check that the resource fits into the namespace quota
+		if err := checkResourceQuota(quotaLister, newResource); err != nil {
+			return noGenResource, err
+		}
+
 		// Create the resource
 		_, err = client.CreateResource(genAPIVersion, genKind, genNamespace, newResource, false)
 		if err != nil {
@@ -420,7 +470,7 @@ func manageClone(log logr.Logger, apiVersion, kind, namespace, name, policy stri
 	// check if the resource as reference in clone exists?
 	obj, err := client.GetResource(apiVersion, kind, rNamespace, rName)
 	if err != nil {
-		return nil, Skip, fmt.Errorf("source resource %s %s/%s/%s not found. %v", apiVersion, kind, rNamespace, rName, err)
+		return nil, Skip, fmt.Errorf("source resource %s %s/%s/%s not found. %w", apiVersion, kind, rNamespace, rName, err)
 	}
 
 	// check if resource to be generated exists
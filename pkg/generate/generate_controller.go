@@ -1,19 +1,24 @@
 package generate
 
 import (
+	"errors"
 	"reflect"
+	"sync"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/background"
 	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
 	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
 	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/config"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/metrics/internalqueue"
 	"github.com/kyverno/kyverno/pkg/resourcecache"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -21,6 +26,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelister "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -48,6 +55,9 @@ type Controller struct {
 	// GR that need to be synced
 	queue workqueue.RateLimitingInterface
 
+	// policies whose forEachNamespace rules need to be synced
+	forEachNamespaceQueue workqueue.RateLimitingInterface
+
 	// policyLister can list/get cluster policy from the shared informer's store
 	policyLister kyvernolister.ClusterPolicyLister
 
@@ -60,6 +70,12 @@ type Controller struct {
 	// grSynced returns true if the Generate Request store has been synced at least once
 	grSynced cache.InformerSynced
 
+	// quotaLister can list resource quotas from the shared informer's store
+	quotaLister corelister.ResourceQuotaLister
+
+	// quotaSynced returns true if the ResourceQuota store has been synced at least once
+	quotaSynced cache.InformerSynced
+
 	// dynamic shared informer factory
 	dynamicInformer dynamicinformer.DynamicSharedInformerFactory
 
@@ -70,6 +86,22 @@ type Controller struct {
 
 	Config   config.Interface
 	resCache resourcecache.ResourceCache
+
+	// cloneSources maps clone sources to the generate requests that clone them
+	cloneSources *cloneSourceIndex
+
+	// watchedKinds stores the kinds of clone sources that are watched
+	watchedKinds     map[string]bool
+	watchedKindsLock sync.Mutex
+
+	// watchedGeneratedKinds stores the kinds of synchronized generated resources that are watched
+	watchedGeneratedKinds map[string]bool
+
+	// backgroundGate pauses the generate requests of the existing namespaces
+	backgroundGate *background.Gate
+
+	// promConfig reports the generate requests that failed after the maximum number of retries
+	promConfig *metrics.PromConfig
 }
 
 //NewController returns an instance of the Generate-Request Controller
@@ -79,11 +111,14 @@ func NewController(
 	client *dclient.Client,
 	policyInformer kyvernoinformer.ClusterPolicyInformer,
 	grInformer kyvernoinformer.GenerateRequestInformer,
+	quotaInformer coreinformers.ResourceQuotaInformer,
 	eventGen event.Interface,
 	dynamicInformer dynamicinformer.DynamicSharedInformerFactory,
 	log logr.Logger,
 	dynamicConfig config.Interface,
 	resourceCache resourcecache.ResourceCache,
+	promConfig *metrics.PromConfig,
+	backgroundGate *background.Gate,
 ) (*Controller, error) {
 
 	c := Controller{
@@ -91,13 +126,20 @@ func NewController(
 		kyvernoClient:   kyvernoClient,
 		policyInformer:  policyInformer,
 		eventGen:        eventGen,
-		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "generate-request"),
+		queue:           internalqueue.NewQueue(workqueue.NewNamedRateLimitingQueue(newRetryRateLimiter(GetRetryPolicy()), "generate-request"), internalqueue.UpdateRequests, promConfig, log),
 		dynamicInformer: dynamicInformer,
 		log:             log,
 		Config:          dynamicConfig,
 		resCache:        resourceCache,
+		cloneSources:    newCloneSourceIndex(),
+		watchedKinds:    make(map[string]bool),
+		backgroundGate:  backgroundGate,
+		promConfig:      promConfig,
+
+		watchedGeneratedKinds: make(map[string]bool),
 	}
 
+	c.forEachNamespaceQueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "generate-for-each-namespace")
 	c.statusControl = StatusControl{client: kyvernoClient}
 
 	c.policySynced = policyInformer.Informer().HasSynced
@@ -109,8 +151,16 @@ func NewController(
 		DeleteFunc: c.deleteGR,
 	})
 
+	// skipped generate requests are retried when quota is freed up
+	c.quotaSynced = quotaInformer.Informer().HasSynced
+	quotaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.updateResourceQuota,
+		DeleteFunc: c.deleteResourceQuota,
+	})
+
 	c.policyLister = policyInformer.Lister()
 	c.grLister = grInformer.Lister().GenerateRequests(config.KyvernoNamespace)
+	c.quotaLister = quotaInformer.Lister()
 
 	gvr, err := client.DiscoveryClient.GetGVRFromKind("Namespace")
 	if err != nil {
@@ -126,9 +176,10 @@ func NewController(
 func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer c.queue.ShutDown()
+	defer c.forEachNamespaceQueue.ShutDown()
 	defer c.log.Info("shutting down")
 
-	if !cache.WaitForCacheSync(stopCh, c.policySynced, c.grSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.policySynced, c.grSynced, c.quotaSynced) {
 		c.log.Info("failed to sync informer cache")
 		return
 	}
@@ -138,14 +189,29 @@ func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
 		// Deletion of policy will be handled by cleanup controller
 	})
 
+	// the forEachNamespace rules are applied when the policy is created, including the existing
+	// policies on startup, and when the namespace labels change
+	c.policyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.addForEachNamespacePolicy,
+		UpdateFunc: c.updateForEachNamespacePolicy,
+	})
+
 	c.nsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: c.updateGenericResource,
 	})
 
+	c.nsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.addNamespace,
+		UpdateFunc: c.updateNamespace,
+	})
+
 	for i := 0; i < workers; i++ {
 		go wait.Until(c.worker, time.Second, stopCh)
 	}
 
+	go wait.Until(c.forEachNamespaceWorker, time.Second, stopCh)
+
+	go internalqueue.Run(c.queue, stopCh)
 	<-stopCh
 }
 
@@ -181,7 +247,9 @@ func (c *Controller) handleErr(err error, key interface{}) {
 		return
 	}
 
-	if c.queue.NumRequeues(key) < maxRetries {
+	// the failed attempts recorded in the status are retried until the request is Failed
+	var retryErr *retryError
+	if errors.As(err, &retryErr) || c.queue.NumRequeues(key) < maxRetries {
 		logger.V(3).Info("retrying generate request", "key", key, "error", err.Error())
 		c.queue.AddRateLimited(key)
 		return
@@ -215,6 +283,7 @@ func (c *Controller) syncGenerateRequest(key string) error {
 		return err
 	}
 
+	c.indexGenerateRequest(gr)
 	return c.processGR(gr)
 }
 
@@ -305,7 +374,12 @@ func (c *Controller) updateGR(old, cur interface{}) {
 	}
 	// only process the ones that are in "Pending"/"Completed" state
 	// if the Generate Request fails due to incorrect policy, it will be requeued during policy update
-	if curGr.Status.State == kyverno.Failed {
+	// if the Generate Request is skipped due to quota, it will be requeued when quota is freed up
+	if curGr.Status.State == kyverno.Failed || curGr.Status.State == kyverno.Skipped {
+		return
+	}
+	// a failed attempt is re-queued with a backoff, not when its status is updated
+	if curGr.Status.State == kyverno.Pending && curGr.Status.Retries != oldGr.Status.Retries {
 		return
 	}
 	c.enqueueGenerateRequest(curGr)
@@ -320,13 +394,17 @@ func (c *Controller) deleteGR(obj interface{}) {
 			logger.Info("Couldn't get object from tombstone", "obj", obj)
 			return
 		}
-		_, ok = tombstone.Obj.(*kyverno.GenerateRequest)
+		gr, ok = tombstone.Obj.(*kyverno.GenerateRequest)
 		if !ok {
 			logger.Info("tombstone contained object that is not a Generate Request CR", "obj", obj)
 			return
 		}
 	}
 
+	if key, err := cache.MetaNamespaceKeyFunc(gr); err == nil {
+		c.cloneSources.remove(key)
+	}
+
 	for _, resource := range gr.Status.GeneratedResources {
 		r, err := c.client.GetResource(resource.APIVersion, resource.Kind, resource.Namespace, resource.Name)
 		if err != nil && !apierrors.IsNotFound(err) {
@@ -0,0 +1,262 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	corelister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podSpecPaths are the paths to the pod spec of the kinds that consume compute resource quota
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// checkResourceQuota returns a QuotaExceeded error if the resource to be generated
+// does not fit into the remaining quota of its namespace
+func checkResourceQuota(quotaLister corelister.ResourceQuotaLister, obj *unstructured.Unstructured) error {
+	if quotaLister == nil || obj.GetNamespace() == "" {
+		return nil
+	}
+
+	usage, err := quotaUsage(obj)
+	if err != nil || len(usage) == 0 {
+		return err
+	}
+
+	quotas, err := quotaLister.ResourceQuotas(obj.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list ResourceQuotas in namespace %s: %v", obj.GetNamespace(), err)
+	}
+
+	for _, quota := range quotas {
+		available := availableQuota(quota)
+		for _, name := range sortedResourceNames(usage) {
+			remaining, ok := available[name]
+			if !ok {
+				continue
+			}
+
+			requested := usage[name]
+			if requested.Cmp(remaining) > 0 {
+				return NewQuotaExceeded(obj.GetKind(), obj.GetNamespace(), obj.GetName(), quota.GetName(), string(name), requested.String(), remaining.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// quotaUsage returns the compute resources and pods the resource consumes from a ResourceQuota
+// once all its pods are created. Kinds without a pod template do not consume compute resources.
+func quotaUsage(obj *unstructured.Unstructured) (corev1.ResourceList, error) {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil, nil
+	}
+
+	specMap, found, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var spec corev1.PodSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(specMap, &spec); err != nil {
+		return nil, fmt.Errorf("failed to convert the pod spec of %s/%s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	pods := podCount(obj)
+	usage := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(pods, resource.DecimalSI)}
+	for name, quantity := range podUsage(&spec) {
+		usage[name] = *resource.NewMilliQuantity(quantity.MilliValue()*pods, quantity.Format)
+	}
+
+	return usage, nil
+}
+
+// podCount returns the number of pods the resource runs, DaemonSets and CronJobs count as a single pod
+func podCount(obj *unstructured.Unstructured) int64 {
+	field := "replicas"
+	if obj.GetKind() == "Job" {
+		field = "parallelism"
+	}
+
+	count, found, err := unstructured.NestedInt64(obj.Object, "spec", field)
+	if err != nil || !found {
+		return 1
+	}
+	return count
+}
+
+// podUsage returns the quota usage of a single pod, init containers run sequentially before the containers
+func podUsage(spec *corev1.PodSpec) corev1.ResourceList {
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResources(requests, containerRequests(container))
+		addResources(limits, container.Resources.Limits)
+	}
+
+	for _, container := range spec.InitContainers {
+		maxResources(requests, containerRequests(container))
+		maxResources(limits, container.Resources.Limits)
+	}
+
+	usage := corev1.ResourceList{}
+	for name, quantity := range requests {
+		usage[corev1.ResourceName("requests."+string(name))] = quantity
+		if name == corev1.ResourceCPU || name == corev1.ResourceMemory || name == corev1.ResourceEphemeralStorage {
+			usage[name] = quantity
+		}
+	}
+
+	for name, quantity := range limits {
+		usage[corev1.ResourceName("limits."+string(name))] = quantity
+	}
+
+	return usage
+}
+
+// containerRequests returns the container requests, requests default to the limits
+func containerRequests(container corev1.Container) corev1.ResourceList {
+	requests := container.Resources.Requests.DeepCopy()
+	if requests == nil {
+		requests = corev1.ResourceList{}
+	}
+
+	for name, quantity := range container.Resources.Limits {
+		if _, ok := requests[name]; !ok {
+			requests[name] = quantity.DeepCopy()
+		}
+	}
+	return requests
+}
+
+func addResources(total, list corev1.ResourceList) {
+	for name, quantity := range list {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+func maxResources(total, list corev1.ResourceList) {
+	for name, quantity := range list {
+		if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+			total[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// availableQuota returns the remaining quota, i.e. the hard limits minus the used resources
+func availableQuota(quota *corev1.ResourceQuota) corev1.ResourceList {
+	hard := quota.Status.Hard
+	if len(hard) == 0 {
+		hard = quota.Spec.Hard
+	}
+
+	available := corev1.ResourceList{}
+	for name, limit := range hard {
+		remaining := limit.DeepCopy()
+		if used, ok := quota.Status.Used[name]; ok {
+			remaining.Sub(used)
+		}
+
+		if remaining.Sign() < 0 {
+			remaining = *resource.NewQuantity(0, limit.Format)
+		}
+		available[name] = remaining
+	}
+
+	return available
+}
+
+// quotaFreed checks if more of any resource is available in the current quota than in the old one
+func quotaFreed(old, cur *corev1.ResourceQuota) bool {
+	oldAvailable, curAvailable := availableQuota(old), availableQuota(cur)
+	for name, remaining := range curAvailable {
+		if oldRemaining, ok := oldAvailable[name]; ok && remaining.Cmp(oldRemaining) > 0 {
+			return true
+		}
+	}
+
+	// a removed limit no longer restricts the generated resources
+	for name := range oldAvailable {
+		if _, ok := curAvailable[name]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func (c *Controller) updateResourceQuota(old, cur interface{}) {
+	oldQuota := old.(*corev1.ResourceQuota)
+	curQuota := cur.(*corev1.ResourceQuota)
+	if oldQuota.ResourceVersion == curQuota.ResourceVersion || !quotaFreed(oldQuota, curQuota) {
+		return
+	}
+
+	c.enqueueSkippedGenerateRequests(curQuota.GetNamespace())
+}
+
+func (c *Controller) deleteResourceQuota(obj interface{}) {
+	quota, ok := obj.(*corev1.ResourceQuota)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			c.log.Info("Couldn't get object from tombstone", "obj", obj)
+			return
+		}
+
+		quota, ok = tombstone.Obj.(*corev1.ResourceQuota)
+		if !ok {
+			c.log.Info("tombstone contained object that is not a ResourceQuota", "obj", obj)
+			return
+		}
+	}
+
+	c.enqueueSkippedGenerateRequests(quota.GetNamespace())
+}
+
+// enqueueSkippedGenerateRequests re-evaluates the generate requests that were skipped
+// as their resources did not fit into the quota of the namespace
+func (c *Controller) enqueueSkippedGenerateRequests(namespace string) {
+	grs, err := c.grLister.List(labels.Everything())
+	if err != nil {
+		c.log.Error(err, "failed to list generate requests")
+		return
+	}
+
+	for _, gr := range grs {
+		if gr.Status.State != kyverno.Skipped {
+			continue
+		}
+
+		c.log.V(4).Info("resource quota changed, re-evaluating skipped generate request", "name", gr.Name, "quotaNamespace", namespace)
+		c.enqueueGenerateRequest(gr)
+	}
+}
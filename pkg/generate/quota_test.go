@@ -0,0 +1,178 @@
+package generate
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corelister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakeStatusControl struct {
+	state   kyverno.GenerateRequestState
+	message string
+	reason  kyverno.FailureReason
+}
+
+func (f *fakeStatusControl) Failed(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error {
+	f.state, f.message, f.reason = kyverno.Failed, message, reason
+	return nil
+}
+
+func (f *fakeStatusControl) Retrying(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error {
+	f.state, f.message, f.reason = kyverno.Pending, message, reason
+	return nil
+}
+
+func (f *fakeStatusControl) Success(gr kyverno.GenerateRequest, genResources []kyverno.ResourceSpec) error {
+	f.state, f.message = kyverno.Completed, ""
+	return nil
+}
+
+func (f *fakeStatusControl) Skipped(gr kyverno.GenerateRequest, message string, genResources []kyverno.ResourceSpec) error {
+	f.state, f.message = kyverno.Skipped, message
+	return nil
+}
+
+func newQuotaTestDeployment(replicas int64, cpu string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "team-a",
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":  "nginx",
+							"image": "nginx",
+							"resources": map[string]interface{}{
+								"requests": map[string]interface{}{"cpu": cpu, "memory": "64Mi"},
+								"limits":   map[string]interface{}{"cpu": cpu},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func newQuotaTestQuota(resourceVersion, hardCPU, usedCPU string) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "team-a", ResourceVersion: resourceVersion},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceRequestsCPU: resource.MustParse(hardCPU),
+				corev1.ResourcePods:        resource.MustParse("10"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceRequestsCPU: resource.MustParse(usedCPU),
+				corev1.ResourcePods:        resource.MustParse("2"),
+			},
+		},
+	}
+}
+
+func newQuotaTestLister(t *testing.T, quotas ...*corev1.ResourceQuota) corelister.ResourceQuotaLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, quota := range quotas {
+		assert.NilError(t, indexer.Add(quota))
+	}
+	return corelister.NewResourceQuotaLister(indexer)
+}
+
+func Test_Quota_Usage(t *testing.T) {
+	usage, err := quotaUsage(newQuotaTestDeployment(3, "250m"))
+	assert.NilError(t, err)
+	assert.Equal(t, usage.Pods().String(), "3")
+	for _, name := range []corev1.ResourceName{corev1.ResourceRequestsCPU, corev1.ResourceCPU, corev1.ResourceLimitsCPU} {
+		quantity := usage[name]
+		assert.Equal(t, quantity.MilliValue(), int64(750), string(name))
+	}
+	memory := usage[corev1.ResourceRequestsMemory]
+	assert.Equal(t, memory.Value(), int64(3*64*1024*1024))
+
+	// kinds without a pod template do not consume compute quota
+	usage, err = quotaUsage(newConfigMap("team-a", "game-config", "1", "a"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(usage), 0)
+}
+
+func Test_Quota_Fits(t *testing.T) {
+	lister := newQuotaTestLister(t, newQuotaTestQuota("1", "2", "1"))
+	assert.NilError(t, checkResourceQuota(lister, newQuotaTestDeployment(2, "500m")))
+
+	// no quota in the namespace
+	assert.NilError(t, checkResourceQuota(newQuotaTestLister(t), newQuotaTestDeployment(100, "1")))
+
+	status := &fakeStatusControl{}
+	assert.NilError(t, updateStatus(status, kyverno.GenerateRequest{}, nil, nil))
+	assert.Equal(t, status.state, kyverno.Completed)
+}
+
+func Test_Quota_Does_Not_Fit(t *testing.T) {
+	lister := newQuotaTestLister(t, newQuotaTestQuota("1", "2", "1500m"))
+	err := checkResourceQuota(lister, newQuotaTestDeployment(2, "500m"))
+	assert.Error(t, err, "resource Deployment/team-a/web exceeds ResourceQuota compute: requested requests.cpu=1, available requests.cpu=500m")
+
+	// the generate request is skipped instead of failed
+	status := &fakeStatusControl{}
+	assert.NilError(t, updateStatus(status, kyverno.GenerateRequest{}, err, nil))
+	assert.Equal(t, status.state, kyverno.Skipped)
+	assert.Equal(t, status.message, err.Error())
+
+	// over-used quota has no resources available
+	lister = newQuotaTestLister(t, newQuotaTestQuota("1", "2", "3"))
+	err = checkResourceQuota(lister, newQuotaTestDeployment(1, "100m"))
+	assert.Error(t, err, "resource Deployment/team-a/web exceeds ResourceQuota compute: requested requests.cpu=100m, available requests.cpu=0")
+}
+
+func Test_Quota_Freed_Requeues_Skipped(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, state := range map[string]kyverno.GenerateRequestState{"gr-skipped": kyverno.Skipped, "gr-completed": kyverno.Completed, "gr-failed": kyverno.Failed} {
+		assert.NilError(t, indexer.Add(&kyverno.GenerateRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: config.KyvernoNamespace},
+			Status:     kyverno.GenerateRequestStatus{State: state},
+		}))
+	}
+
+	c := &Controller{
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "generate-request-test"),
+		grLister: kyvernolister.NewGenerateRequestLister(indexer).GenerateRequests(config.KyvernoNamespace),
+		log:      log.Log,
+	}
+
+	full := newQuotaTestQuota("1", "2", "1500m")
+	assert.Assert(t, checkResourceQuota(newQuotaTestLister(t, full), newQuotaTestDeployment(2, "500m")) != nil)
+
+	// periodic resync and more usage do not re-queue the generate requests
+	c.updateResourceQuota(full, full)
+	c.updateResourceQuota(full, newQuotaTestQuota("2", "2", "1800m"))
+	assert.Equal(t, c.queue.Len(), 0)
+
+	// freed quota re-queues the skipped generate requests, and the resource fits
+	freed := newQuotaTestQuota("3", "2", "500m")
+	c.updateResourceQuota(full, freed)
+	assert.DeepEqual(t, drainQueue(c), []string{config.KyvernoNamespace + "/gr-skipped"})
+	assert.NilError(t, checkResourceQuota(newQuotaTestLister(t, freed), newQuotaTestDeployment(2, "500m")))
+
+	// a raised limit or a deleted quota re-queues them as well
+	c.updateResourceQuota(full, newQuotaTestQuota("4", "4", "1500m"))
+	assert.DeepEqual(t, drainQueue(c), []string{config.KyvernoNamespace + "/gr-skipped"})
+	c.deleteResourceQuota(full)
+	assert.DeepEqual(t, drainQueue(c), []string{config.KyvernoNamespace + "/gr-skipped"})
+}
@@ -0,0 +1,127 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/event"
+	prom "github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// RetryPolicy configures the retries of the generate requests that fail to be processed
+type RetryPolicy struct {
+	// MaxRetries is the number of failed attempts after which a generate request is Failed
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry, the delay doubles with each retry
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between two attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries a generate request for about 15 minutes
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 10,
+	BaseDelay:  time.Second,
+	MaxDelay:   5 * time.Minute,
+}
+
+var (
+	retryPolicy    = DefaultRetryPolicy
+	retryPolicyMux sync.RWMutex
+)
+
+// SetRetryPolicy sets the retry policy of the generate requests, it must be set before the controller is created
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicyMux.Lock()
+	defer retryPolicyMux.Unlock()
+	retryPolicy = policy
+}
+
+// GetRetryPolicy returns the retry policy of the generate requests
+func GetRetryPolicy() RetryPolicy {
+	retryPolicyMux.RLock()
+	defer retryPolicyMux.RUnlock()
+	return retryPolicy
+}
+
+// newRetryRateLimiter returns the exponential backoff of the generate requests
+func newRetryRateLimiter(policy RetryPolicy) workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(policy.BaseDelay, policy.MaxDelay)
+}
+
+// retryError is returned for a failed attempt recorded in the status of the generate request, so
+// that the request is re-queued with a backoff. It does not unwrap the error of the attempt, a
+// missing clone source must not drop the request from the queue.
+type retryError struct {
+	err error
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+// failedAttempts returns the number of failed attempts including a new failure. A Failed request
+// that is processed again, as its policy or trigger resource changed, starts a new series of retries.
+func failedAttempts(status kyverno.GenerateRequestStatus) int {
+	if status.State == kyverno.Failed {
+		return 1
+	}
+
+	return status.Retries + 1
+}
+
+// failureReason classifies the error of a failed attempt
+func failureReason(err error) kyverno.FailureReason {
+	var invalidRule *InvalidRule
+	switch {
+	case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		return kyverno.PermissionDenied
+	case apierrors.IsNotFound(err):
+		return kyverno.ResourceNotFound
+	case apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err):
+		return kyverno.Conflict
+	case apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) || errors.As(err, &invalidRule):
+		return kyverno.InvalidPolicy
+	case apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsTooManyRequests(err):
+		return kyverno.APIServerError
+	default:
+		return kyverno.UnknownError
+	}
+}
+
+// deadLetter reports a generate request that failed after the maximum number of retries, with
+// events on the trigger resource and on the policy, and with the failed generate requests metric
+func (c *Controller) deadLetter(logger logr.Logger, gr kyverno.GenerateRequest, resource unstructured.Unstructured, err error) {
+	reason := failureReason(err)
+	retries := failedAttempts(gr.Status)
+	logger.Error(err, "generate request failed after the maximum number of retries", "retries", retries, "reason", reason)
+
+	c.eventGen.Add(failedEvents(err, gr, resource)...)
+	c.eventGen.Add(event.Info{
+		Kind:    "ClusterPolicy",
+		Name:    gr.Spec.Policy,
+		Reason:  event.GenerateRequestFailed.String(),
+		Source:  event.GeneratePolicyController,
+		Message: fmt.Sprintf("generate request %s for %s %s/%s failed after %d retries (%s): %v", gr.Name, resource.GetKind(), resource.GetNamespace(), resource.GetName(), retries, reason, err),
+	})
+
+	if c.promConfig == nil {
+		return
+	}
+
+	c.promConfig.Metrics.GenerateRequestsFailed.With(prom.Labels{
+		"policy_name":        gr.Spec.Policy,
+		"resource_kind":      resource.GetKind(),
+		"resource_namespace": resource.GetNamespace(),
+		"reason":             string(reason),
+	}).Inc()
+}
@@ -0,0 +1,70 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_failureReason(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	testcases := []struct {
+		err    error
+		reason kyverno.FailureReason
+	}{
+		{apierrors.NewForbidden(gr, "config", errors.New("denied")), kyverno.PermissionDenied},
+		{fmt.Errorf("source resource v1 ConfigMap/default/config not found. %w", apierrors.NewNotFound(gr, "config")), kyverno.ResourceNotFound},
+		{apierrors.NewConflict(gr, "config", errors.New("changed")), kyverno.Conflict},
+		{NewInvalidRule("generate-config", errors.New("variable request.object.foo could not be resolved")), kyverno.InvalidPolicy},
+		{apierrors.NewServiceUnavailable("unavailable"), kyverno.APIServerError},
+		{errors.New("failed"), kyverno.UnknownError},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, failureReason(tc.err), tc.reason, tc.err.Error())
+	}
+}
+
+func Test_updateStatus_Retries(t *testing.T) {
+	defer SetRetryPolicy(GetRetryPolicy())
+	SetRetryPolicy(RetryPolicy{MaxRetries: 3})
+
+	err := apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "config", errors.New("denied"))
+
+	// the failed attempts are retried with a backoff
+	status := &fakeStatusControl{}
+	gr := kyverno.GenerateRequest{Status: kyverno.GenerateRequestStatus{State: kyverno.Pending, Retries: 1}}
+	var retryErr *retryError
+	assert.Assert(t, errors.As(updateStatus(status, gr, err, nil), &retryErr))
+	assert.Equal(t, status.state, kyverno.Pending)
+	assert.Equal(t, status.reason, kyverno.PermissionDenied)
+	assert.Assert(t, !apierrors.IsForbidden(retryErr))
+
+	// the request is Failed after the maximum number of retries
+	gr.Status.Retries = 2
+	assert.NilError(t, updateStatus(status, gr, err, nil))
+	assert.Equal(t, status.state, kyverno.Failed)
+	assert.Equal(t, status.message, err.Error())
+
+	// a Failed request processed again starts a new series of retries
+	gr.Status = kyverno.GenerateRequestStatus{State: kyverno.Failed, Retries: 3}
+	assert.Equal(t, failedAttempts(gr.Status), 1)
+	assert.Assert(t, updateStatus(status, gr, err, nil) != nil)
+	assert.Equal(t, status.state, kyverno.Pending)
+}
+
+func Test_setFailure(t *testing.T) {
+	status := kyverno.GenerateRequestStatus{State: kyverno.Pending, Retries: 4}
+	setFailure(&status, "failed", kyverno.Conflict)
+	assert.Equal(t, status.Retries, 5)
+	assert.Equal(t, status.FailureReason, kyverno.Conflict)
+	assert.Assert(t, status.LastFailureTime != nil)
+
+	clearFailure(&status)
+	assert.DeepEqual(t, status, kyverno.GenerateRequestStatus{State: kyverno.Pending, Message: "failed"})
+}
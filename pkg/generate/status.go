@@ -13,8 +13,10 @@ import (
 
 //StatusControlInterface provides interface to update status subresource
 type StatusControlInterface interface {
-	Failed(gr kyverno.GenerateRequest, message string, genResources []kyverno.ResourceSpec) error
+	Failed(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error
+	Retrying(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error
 	Success(gr kyverno.GenerateRequest, genResources []kyverno.ResourceSpec) error
+	Skipped(gr kyverno.GenerateRequest, message string, genResources []kyverno.ResourceSpec) error
 }
 
 // StatusControl is default implementaation of GRStatusControlInterface
@@ -22,10 +24,10 @@ type StatusControl struct {
 	client kyvernoclient.Interface
 }
 
-//Failed sets gr status.state to failed with message
-func (sc StatusControl) Failed(gr kyverno.GenerateRequest, message string, genResources []kyverno.ResourceSpec) error {
+//Failed sets gr status.state to failed with message, the request is not retried anymore
+func (sc StatusControl) Failed(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error {
+	setFailure(&gr.Status, message, reason)
 	gr.Status.State = kyverno.Failed
-	gr.Status.Message = message
 	// Update Generated Resources
 	gr.Status.GeneratedResources = genResources
 	_, err := sc.client.KyvernoV1().GenerateRequests(config.KyvernoNamespace).UpdateStatus(context.TODO(), &gr, v1.UpdateOptions{})
@@ -37,10 +39,28 @@ func (sc StatusControl) Failed(gr kyverno.GenerateRequest, message string, genRe
 	return nil
 }
 
-// Success sets the gr status.state to completed and clears message
+// Retrying keeps the gr status.state pending and records the failed attempt, the request is retried with a backoff
+func (sc StatusControl) Retrying(gr kyverno.GenerateRequest, message string, reason kyverno.FailureReason, genResources []kyverno.ResourceSpec) error {
+	setFailure(&gr.Status, message, reason)
+	gr.Status.State = kyverno.Pending
+	// Update Generated Resources
+	gr.Status.GeneratedResources = genResources
+
+	_, err := sc.client.KyvernoV1().GenerateRequests(config.KyvernoNamespace).UpdateStatus(context.TODO(), &gr, v1.UpdateOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Log.Error(err, "failed to update generate request status", "name", gr.Name)
+		return err
+	}
+
+	log.Log.V(3).Info("updated generate request status", "name", gr.Name, "status", string(kyverno.Pending), "retries", gr.Status.Retries)
+	return nil
+}
+
+// Success sets the gr status.state to completed and clears message and failures
 func (sc StatusControl) Success(gr kyverno.GenerateRequest, genResources []kyverno.ResourceSpec) error {
 	gr.Status.State = kyverno.Completed
 	gr.Status.Message = ""
+	clearFailure(&gr.Status)
 	// Update Generated Resources
 	gr.Status.GeneratedResources = genResources
 
@@ -53,3 +73,37 @@ func (sc StatusControl) Success(gr kyverno.GenerateRequest, genResources []kyver
 	log.Log.V(3).Info("updated generate request status", "name", gr.Name, "status", string(kyverno.Completed))
 	return nil
 }
+
+// Skipped sets the gr status.state to skipped with message
+func (sc StatusControl) Skipped(gr kyverno.GenerateRequest, message string, genResources []kyverno.ResourceSpec) error {
+	gr.Status.State = kyverno.Skipped
+	gr.Status.Message = message
+	clearFailure(&gr.Status)
+	// Update Generated Resources
+	gr.Status.GeneratedResources = genResources
+
+	_, err := sc.client.KyvernoV1().GenerateRequests(config.KyvernoNamespace).UpdateStatus(context.TODO(), &gr, v1.UpdateOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Log.Error(err, "failed to update generate request status", "name", gr.Name)
+		return err
+	}
+
+	log.Log.V(3).Info("updated generate request status", "name", gr.Name, "status", string(kyverno.Skipped))
+	return nil
+}
+
+// setFailure records a failed attempt in the status
+func setFailure(status *kyverno.GenerateRequestStatus, message string, reason kyverno.FailureReason) {
+	now := v1.Now()
+	status.Retries = failedAttempts(*status)
+	status.Message = message
+	status.FailureReason = reason
+	status.LastFailureTime = &now
+}
+
+// clearFailure resets the failed attempts once the request is processed
+func clearFailure(status *kyverno.GenerateRequestStatus) {
+	status.Retries = 0
+	status.FailureReason = ""
+	status.LastFailureTime = nil
+}
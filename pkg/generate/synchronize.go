@@ -0,0 +1,163 @@
+package generate
+
+import (
+	"reflect"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// synchronizedKinds returns the kinds of the resources generated by the synchronized generate rules of a policy
+func synchronizedKinds(policy *kyverno.ClusterPolicy) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, rule := range policy.Spec.Rules {
+		if !rule.HasGenerate() || !rule.Generation.Synchronize {
+			continue
+		}
+
+		ruleKinds := []string{rule.Generation.Kind}
+		if rule.HasCloneList() {
+			ruleKinds = rule.Generation.CloneList.Kinds
+		}
+
+		for _, kind := range ruleKinds {
+			if kind != "" && !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	return kinds
+}
+
+// watchGeneratedKind registers the generated resource handlers for a kind once, so that the changes
+// to the synchronized resources are reverted without waiting for the next resync
+func (c *Controller) watchGeneratedKind(kind string) {
+	c.watchedKindsLock.Lock()
+	defer c.watchedKindsLock.Unlock()
+
+	if c.watchedGeneratedKinds[kind] {
+		return
+	}
+
+	gc, err := c.resCache.CreateGVKInformer(kind)
+	if err != nil {
+		c.log.Error(err, "failed to watch generated resources", "kind", kind)
+		return
+	}
+
+	gc.GetInformer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.updateGeneratedResource,
+		DeleteFunc: c.deleteGeneratedResource,
+	})
+	c.watchedGeneratedKinds[kind] = true
+}
+
+// updateGeneratedResource re-queues the generate request of a synchronized resource that is changed,
+// the generate request overwrites the change. The labels of the previous version are used, so that
+// removing the synchronize label is reverted too.
+func (c *Controller) updateGeneratedResource(old, cur interface{}) {
+	oldR := old.(*unstructured.Unstructured)
+	curR := cur.(*unstructured.Unstructured)
+	if oldR.GetResourceVersion() == curR.GetResourceVersion() || !drifted(oldR, curR) {
+		return
+	}
+
+	if curR.GetDeletionTimestamp() != nil {
+		return
+	}
+
+	c.enqueueGeneratedResource(oldR, "generated resource changed, reverting the change")
+}
+
+// deleteGeneratedResource re-queues the generate request of a synchronized resource that is deleted,
+// the generate request generates the resource again if the trigger resource and the policy still exist
+func (c *Controller) deleteGeneratedResource(obj interface{}) {
+	r, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			c.log.Info("couldn't get object from tombstone", "obj", obj)
+			return
+		}
+
+		r, ok = tombstone.Obj.(*unstructured.Unstructured)
+		if !ok {
+			c.log.Info("tombstone contained object that is not a resource", "obj", obj)
+			return
+		}
+	}
+
+	c.enqueueGeneratedResource(r, "generated resource deleted, generating it again")
+}
+
+// enqueueGeneratedResource queues the generate request of a synchronized resource
+func (c *Controller) enqueueGeneratedResource(r *unstructured.Unstructured, msg string) {
+	labels := r.GetLabels()
+	if labels["app.kubernetes.io/managed-by"] != "kyverno" || labels["policy.kyverno.io/synchronize"] != "enable" {
+		return
+	}
+
+	grName := labels["policy.kyverno.io/gr-name"]
+	if grName == "" {
+		return
+	}
+
+	// the generate request is deleted with the trigger resource or the policy
+	gr, err := c.grLister.Get(grName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			c.log.Error(err, "failed to get generate request", "name", grName)
+		}
+		return
+	}
+
+	c.log.V(3).Info(msg, "kind", r.GetKind(), "namespace", r.GetNamespace(), "name", r.GetName(), "generateRequest", config.KyvernoNamespace+"/"+grName)
+	c.enqueueGenerateRequest(gr)
+}
+
+// drifted returns true if the resource is changed, the changes to the status and to the fields
+// maintained by the API server are ignored
+func drifted(old, cur *unstructured.Unstructured) bool {
+	return !reflect.DeepEqual(syncedContent(old), syncedContent(cur))
+}
+
+func syncedContent(r *unstructured.Unstructured) map[string]interface{} {
+	content := r.DeepCopy().UnstructuredContent()
+	delete(content, "status")
+	for _, field := range []string{"resourceVersion", "generation", "managedFields", "uid", "selfLink", "creationTimestamp"} {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+
+	return content
+}
+
+// setOwnerReference sets the trigger resource as an owner of the generated resource. A namespaced
+// trigger resource can only own the resources of its namespace, false is returned if the owner
+// reference cannot be set.
+func setOwnerReference(generated *unstructured.Unstructured, trigger unstructured.Unstructured) bool {
+	if trigger.GetNamespace() != "" && trigger.GetNamespace() != generated.GetNamespace() {
+		return false
+	}
+
+	owners := generated.GetOwnerReferences()
+	for _, owner := range owners {
+		if owner.UID == trigger.GetUID() {
+			return true
+		}
+	}
+
+	generated.SetOwnerReferences(append(owners, metav1.OwnerReference{
+		APIVersion: trigger.GetAPIVersion(),
+		Kind:       trigger.GetKind(),
+		Name:       trigger.GetName(),
+		UID:        trigger.GetUID(),
+	}))
+	return true
+}
@@ -0,0 +1,91 @@
+package generate
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newGeneratedTestResource(t *testing.T, raw string) *unstructured.Unstructured {
+	var r unstructured.Unstructured
+	assert.NilError(t, r.UnmarshalJSON([]byte(raw)))
+	return &r
+}
+
+func Test_synchronizedKinds(t *testing.T) {
+	policy := &kyverno.ClusterPolicy{
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{Name: "generate-quota", Generation: kyverno.Generation{ResourceSpec: kyverno.ResourceSpec{Kind: "ResourceQuota", Name: "quota"}, Synchronize: true}},
+				{Name: "generate-limits", Generation: kyverno.Generation{ResourceSpec: kyverno.ResourceSpec{Kind: "LimitRange", Name: "limits"}}},
+				{Name: "clone-secrets", Generation: kyverno.Generation{Synchronize: true, CloneList: kyverno.CloneList{Namespace: "default", Kinds: []string{"v1/Secret", "v1/ConfigMap"}}}},
+				{Name: "generate-configmap", Generation: kyverno.Generation{ResourceSpec: kyverno.ResourceSpec{Kind: "v1/ConfigMap", Name: "config"}, Synchronize: true}},
+			},
+		},
+	}
+
+	assert.DeepEqual(t, synchronizedKinds(policy), []string{"ResourceQuota", "v1/Secret", "v1/ConfigMap"})
+}
+
+func Test_drifted(t *testing.T) {
+	old := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "1"}, "data": {"key": "value"}}`)
+
+	cur := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "2", "managedFields": [{"manager": "kubectl"}]}, "data": {"key": "value"}}`)
+	assert.Assert(t, !drifted(old, cur))
+
+	cur = newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "2"}, "data": {"key": "changed"}}`)
+	assert.Assert(t, drifted(old, cur))
+
+	cur = newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "2", "labels": {"team": "dev"}}, "data": {"key": "value"}}`)
+	assert.Assert(t, drifted(old, cur))
+}
+
+func Test_setOwnerReference(t *testing.T) {
+	trigger := *newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "dev", "uid": "ns-uid"}}`)
+	generated := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev"}}`)
+
+	assert.Assert(t, setOwnerReference(generated, trigger))
+	assert.Assert(t, setOwnerReference(generated, trigger))
+	assert.DeepEqual(t, generated.GetOwnerReferences(), []metav1.OwnerReference{{APIVersion: "v1", Kind: "Namespace", Name: "dev", UID: types.UID("ns-uid")}})
+
+	// a namespaced trigger resource cannot own the resources of another namespace
+	trigger = *newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "secret", "namespace": "prod", "uid": "secret-uid"}}`)
+	assert.Assert(t, !setOwnerReference(generated, trigger))
+	assert.Equal(t, len(generated.GetOwnerReferences()), 1)
+}
+
+func Test_enqueueGeneratedResource(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, indexer.Add(&kyverno.GenerateRequest{ObjectMeta: metav1.ObjectMeta{Name: "gr-1", Namespace: config.KyvernoNamespace}}))
+
+	c := newCloneSourceTestController(t, newCloneSourceTestPolicy())
+	c.grLister = kyvernolister.NewGenerateRequestLister(indexer).GenerateRequests(config.KyvernoNamespace)
+
+	old := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "1",
+		"labels": {"app.kubernetes.io/managed-by": "kyverno", "policy.kyverno.io/synchronize": "enable", "policy.kyverno.io/gr-name": "gr-1"}}, "data": {"key": "value"}}`)
+	cur := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev", "resourceVersion": "2"}, "data": {"key": "changed"}}`)
+
+	// the labels of the previous version are used
+	c.updateGeneratedResource(old, cur)
+	assert.Equal(t, c.queue.Len(), 1)
+
+	key, _ := c.queue.Get()
+	assert.Equal(t, key, config.KyvernoNamespace+"/gr-1")
+	c.queue.Done(key)
+
+	// the resources that are not synchronized are not generated again
+	unsynchronized := newGeneratedTestResource(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "dev",
+		"labels": {"app.kubernetes.io/managed-by": "kyverno", "policy.kyverno.io/synchronize": "disable", "policy.kyverno.io/gr-name": "gr-1"}}}`)
+	c.deleteGeneratedResource(unsynchronized)
+	assert.Equal(t, c.queue.Len(), 0)
+
+	c.deleteGeneratedResource(cache.DeletedFinalStateUnknown{Key: "dev/config", Obj: old})
+	assert.Equal(t, c.queue.Len(), 1)
+}
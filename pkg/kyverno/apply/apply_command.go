@@ -14,6 +14,7 @@ import (
 	client "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	"github.com/kyverno/kyverno/pkg/kyverno/format"
 	sanitizederror "github.com/kyverno/kyverno/pkg/kyverno/sanitizedError"
 	"github.com/kyverno/kyverno/pkg/kyverno/store"
 	"github.com/kyverno/kyverno/pkg/openapi"
@@ -31,6 +32,12 @@ type resultCounts struct {
 	warn  int
 	error int
 	skip  int
+
+	// coverage is the coverage summary, built when requested
+	coverage *coverageReport
+
+	// results are the results of the validate rules, printed with --output-format
+	results []format.Result
 }
 
 type Resource struct {
@@ -60,6 +67,22 @@ To apply on a resource:
 To apply on a cluster:
 	kyverno apply /path/to/policy.yaml /path/to/folderOfPolicies --cluster
 
+To apply on the resources built from a kustomize directory:
+	kyverno apply /path/to/policy.yaml --kustomize=/path/to/overlays/prod
+
+To apply on the resources rendered from a Helm chart, the helm binary is required:
+	kyverno apply /path/to/policy.yaml --helm-chart=/path/to/chart --helm-values=/path/to/values.yaml --helm-set=replicaCount=3
+
+To print the results as a SARIF log uploaded to GitHub code scanning, or as a JUnit report read by the CI test reporters:
+	kyverno apply /path/to/folderOfPolicies --resource=/path/to/folderOfResources --output-format=sarif > results.sarif
+	kyverno apply /path/to/folderOfPolicies --resource=/path/to/folderOfResources --output-format=junit > results.xml
+
+To replay the admission reviews dumped by the webhooks with --admission-dump-dir, the mutate policies are applied before the validate policies:
+	kyverno apply /path/to/folderOfPolicies --admission-review=/path/to/admission-review.json --admission-review=/path/to/dump-dir -f /path/to/values.yaml
+
+To summarize how many resources each rule matches and flags, and the kinds no policy matches:
+	kyverno apply /path/to/folderOfPolicies --resource=/path/to/folderOfResources --coverage --coverage-output=json
+
 
 To apply policy with variables:
 
@@ -94,6 +117,11 @@ To apply policy with variables:
 					values:
 					<variable1 in policy2>: <value>
 					<variable2 in policy2>: <value>
+				rules:
+				- name: <rule1 name>
+					values:
+					<context entry1 in rule1>: <value>
+					<context entry2 in rule1>.data.<key>: <value>
 		namespaceSelector:
 			- name: <namespace1 name>
 			labels:
@@ -101,15 +129,28 @@ To apply policy with variables:
 			- name: <namespace2 name>
 			labels:
 				<label key>: <label value>
+		userInfo:
+			roles:
+			- <namespace>:<role name>
+			clusterRoles:
+			- <cluster role name>
+			userInfo:
+				username: <user name>
+				groups:
+				- <group name>
+
+	The values of the context entries of the rules, the namespace labels and the user info are used in place of
+	the cluster state. The variables which cannot be resolved from the values are listed in the error.
 
 More info: https://kyverno.io/docs/kyverno-cli/
 `
 
 func Command() *cobra.Command {
 	var cmd *cobra.Command
-	var resourcePaths []string
-	var cluster, policyReport, stdin bool
-	var mutateLogPath, variablesString, valuesFile, namespace string
+	var resourcePaths, admissionReviews []string
+	var sources resourceSources
+	var cluster, policyReport, stdin, coverage bool
+	var mutateLogPath, variablesString, valuesFile, namespace, evaluationTime, coverageOutput, outputFormat string
 
 	cmd = &cobra.Command{
 		Use:     "apply",
@@ -125,17 +166,74 @@ func Command() *cobra.Command {
 				}
 			}()
 
-			validateEngineResponses, rc, resources, skippedPolicies, err := applyCommandHelper(resourcePaths, cluster, policyReport, mutateLogPath, variablesString, valuesFile, namespace, policyPaths, stdin)
+			if coverageOutput != coverageTable && coverageOutput != coverageJSON {
+				return sanitizederror.NewWithError(fmt.Sprintf("invalid coverage output format %s, supported formats are %s and %s", coverageOutput, coverageTable, coverageJSON), nil)
+			}
+
+			t, err := common.ParseEvaluationTime(evaluationTime)
+			if err != nil {
+				return err
+			}
+
+			if len(admissionReviews) > 0 {
+				if len(resourcePaths) > 0 || !sources.empty() || cluster {
+					return sanitizederror.NewWithError("the admission-review flag cannot be used with resources or the cluster", nil)
+				}
+
+				denied, err := applyAdmissionReviews(os.Stdout, policyPaths, admissionReviews, valuesFile, t)
+				if err != nil {
+					return err
+				}
+
+				if denied > 0 {
+					os.Exit(1)
+				}
+				return nil
+			}
+
+			stdout := os.Stdout
+			if outputFormat != "" {
+				if _, err := format.Get(outputFormat); err != nil {
+					return sanitizederror.NewWithError(err.Error(), nil)
+				}
+
+				if policyReport {
+					return sanitizederror.NewWithError("the output-format and policy-report flags cannot be used together", nil)
+				}
+
+				// stdout is reserved for the results, progress and engine output are printed to stderr
+				os.Stdout = os.Stderr
+				defer func() { os.Stdout = stdout }()
+			}
+
+			validateEngineResponses, rc, resources, skippedPolicies, err := applyCommandHelper(resourcePaths, sources, cluster, policyReport, mutateLogPath, variablesString, valuesFile, namespace, policyPaths, stdin, coverage, t)
 			if err != nil {
 				return err
 			}
 
+			if rc.coverage != nil {
+				if err := printCoverage(os.Stdout, rc.coverage, coverageOutput); err != nil {
+					return sanitizederror.NewWithError("failed to print the coverage", err)
+				}
+			}
+
+			if outputFormat != "" {
+				if err := format.Print(stdout, outputFormat, rc.results); err != nil {
+					return sanitizederror.NewWithError(fmt.Sprintf("failed to print the %s results", outputFormat), err)
+				}
+			}
+
 			printReportOrViolation(policyReport, validateEngineResponses, rc, resourcePaths, len(resources), skippedPolicies, stdin)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringArrayVarP(&resourcePaths, "resource", "r", []string{}, "Path to resource files")
+	cmd.Flags().StringArrayVarP(&sources.kustomizeDirs, "kustomize", "", []string{}, "Path to kustomize directories, the resources built are applied")
+	cmd.Flags().StringArrayVarP(&sources.helmCharts, "helm-chart", "", []string{}, "Path or reference of Helm charts, the resources rendered with helm template are applied")
+	cmd.Flags().StringArrayVarP(&sources.helmValues, "helm-values", "", []string{}, "Values files the Helm charts are rendered with")
+	cmd.Flags().StringArrayVarP(&sources.helmSetArgs, "helm-set", "", []string{}, "Values the Helm charts are rendered with, in the key=value format")
+	cmd.Flags().StringArrayVarP(&admissionReviews, "admission-review", "", []string{}, "Path to AdmissionReview JSON files, or directories of them, replayed through the policies as admission requests, e.g. the admission reviews dumped with --admission-dump-dir")
 	cmd.Flags().BoolVarP(&cluster, "cluster", "c", false, "Checks if policies should be applied to cluster in the current context")
 	cmd.Flags().StringVarP(&mutateLogPath, "output", "o", "", "Prints the mutated resources in provided file/directory")
 	cmd.Flags().StringVarP(&variablesString, "set", "s", "", "Variables that are required")
@@ -143,11 +241,15 @@ func Command() *cobra.Command {
 	cmd.Flags().BoolVarP(&policyReport, "policy-report", "", false, "Generates policy report when passed (default policyviolation r")
 	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Optional Policy parameter passed with cluster flag")
 	cmd.Flags().BoolVarP(&stdin, "stdin", "i", false, "Optional mutate policy parameter to pipe directly through to kubectl")
+	cmd.Flags().StringVarP(&evaluationTime, "time", "", "", "Time the time windows of the rules are evaluated against, in the RFC 3339 format (default current time)")
+	cmd.Flags().BoolVarP(&coverage, "coverage", "", false, "Prints how many resources each validate rule matches, passes, fails and skips, and the kinds no policy matches")
+	cmd.Flags().StringVarP(&coverageOutput, "coverage-output", "", coverageTable, "Output format of the coverage, table or json")
+	cmd.Flags().StringVarP(&outputFormat, "output-format", "", "", "Prints the results of the validate rules in the format: "+strings.Join(format.Names(), ", "))
 	return cmd
 }
 
-func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool, mutateLogPath string,
-	variablesString string, valuesFile string, namespace string, policyPaths []string, stdin bool) (validateEngineResponses []*response.EngineResponse, rc *resultCounts, resources []*unstructured.Unstructured, skippedPolicies []SkippedPolicy, err error) {
+func applyCommandHelper(resourcePaths []string, sources resourceSources, cluster bool, policyReport bool, mutateLogPath string,
+	variablesString string, valuesFile string, namespace string, policyPaths []string, stdin bool, coverage bool, evaluationTime time.Time) (validateEngineResponses []*response.EngineResponse, rc *resultCounts, resources []*unstructured.Unstructured, skippedPolicies []SkippedPolicy, err error) {
 
 	store.SetMock(true)
 	kubernetesConfig := genericclioptions.NewConfigFlags(true)
@@ -196,7 +298,7 @@ func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool,
 		os.Exit(1)
 	}
 
-	if len(resourcePaths) == 0 && !cluster {
+	if len(resourcePaths) == 0 && sources.empty() && !cluster {
 		return validateEngineResponses, rc, resources, skippedPolicies, sanitizederror.NewWithError(fmt.Sprintf("resource file(s) or cluster required"), err)
 	}
 
@@ -227,11 +329,19 @@ func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool,
 		}
 	}
 
-	resources, err = common.GetResourceAccordingToResourcePath(fs, resourcePaths, cluster, mutatedPolicies, dClient, namespace, policyReport, false, "")
+	if len(resourcePaths) > 0 || cluster {
+		resources, err = common.GetResourceAccordingToResourcePath(fs, resourcePaths, cluster, mutatedPolicies, dClient, namespace, policyReport, false, "")
+		if err != nil {
+			fmt.Printf("Error: failed to load resources\nCause: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rendered, err := sources.render()
 	if err != nil {
-		fmt.Printf("Error: failed to load resources\nCause: %s\n", err)
-		os.Exit(1)
+		return validateEngineResponses, rc, resources, skippedPolicies, sanitizederror.NewWithError("failed to render resources", err)
 	}
+	resources = append(resources, rendered...)
 
 	msgPolicies := "1 policy"
 	if len(mutatedPolicies) > 1 {
@@ -273,6 +383,7 @@ func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool,
 				Variable: variable,
 			}
 			skippedPolicies = append(skippedPolicies, skipPolicy)
+			rc.results = append(rc.results, skippedResults(skipPolicy)...)
 			log.Log.V(3).Info(fmt.Sprintf("skipping policy %s", policy.Name), "error", fmt.Sprintf("policy have variable - %s", variable))
 			continue
 		}
@@ -288,11 +399,18 @@ func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool,
 				thisPolicyResourceValues[k] = v
 			}
 
-			if len(common.PolicyHasVariables(*policy)) > 0 && len(thisPolicyResourceValues) == 0 && len(store.GetContext().Policies) == 0 {
-				return validateEngineResponses, rc, resources, skippedPolicies, sanitizederror.NewWithError(fmt.Sprintf("policy %s have variables. pass the values for the variables using set/values_file flag", policy.Name), err)
+			if len(matches) > 0 {
+				unresolved, err := common.UnresolvedVariables(policy, resource, thisPolicyResourceValues)
+				if err != nil {
+					return validateEngineResponses, rc, resources, skippedPolicies, sanitizederror.NewWithError(fmt.Sprintf("failed to resolve the variables of policy %s", policy.Name), err)
+				}
+
+				if len(unresolved) > 0 {
+					return validateEngineResponses, rc, resources, skippedPolicies, common.UnresolvedVariablesError(policy, resource, unresolved)
+				}
 			}
 
-			ers, validateErs, responseError, rcErs, err := common.ApplyPolicyOnResource(policy, resource, mutateLogPath, mutateLogPathIsDir, thisPolicyResourceValues, policyReport, namespaceSelectorMap, stdin)
+			ers, validateErs, responseError, rcErs, err := common.ApplyPolicyOnResource(policy, resource, mutateLogPath, mutateLogPathIsDir, thisPolicyResourceValues, policyReport, namespaceSelectorMap, stdin, evaluationTime)
 			if err != nil {
 				return validateEngineResponses, rc, resources, skippedPolicies, sanitizederror.NewWithError(fmt.Errorf("failed to apply policy %v on resource %v", policy.Name, resource.GetName()).Error(), err)
 			}
@@ -306,9 +424,14 @@ func applyCommandHelper(resourcePaths []string, cluster bool, policyReport bool,
 			}
 			engineResponses = append(engineResponses, ers...)
 			validateEngineResponses = append(validateEngineResponses, validateErs)
+			rc.results = append(rc.results, buildResults(policy, resource, validateErs)...)
 		}
 	}
 
+	if coverage {
+		rc.coverage = buildCoverage(mutatedPolicies, resources, validateEngineResponses, namespaceSelectorMap)
+	}
+
 	return validateEngineResponses, rc, resources, skippedPolicies, nil
 }
 
@@ -2,6 +2,7 @@ package apply
 
 import (
 	"testing"
+	"time"
 
 	preport "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
 	"gotest.tools/assert"
@@ -56,7 +57,7 @@ func Test_Apply(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		validateEngineResponses, _, _, skippedPolicies, _ := applyCommandHelper(tc.ResourcePaths, false, true, "", "", "", "", tc.PolicyPaths, false)
+		validateEngineResponses, _, _, skippedPolicies, _ := applyCommandHelper(tc.ResourcePaths, resourceSources{}, false, true, "", "", "", "", tc.PolicyPaths, false, false, time.Time{})
 		resps := buildPolicyReports(validateEngineResponses, skippedPolicies)
 		for i, resp := range resps {
 			compareSummary(tc.expectedPolicyReports[i].Summary, resp.UnstructuredContent()["summary"].(map[string]interface{}))
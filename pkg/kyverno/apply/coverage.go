@@ -0,0 +1,155 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/lensesio/tableprinter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// output formats of the coverage summary
+const (
+	coverageTable = "table"
+	coverageJSON  = "json"
+)
+
+// coverageReport summarizes how many of the input resources each validate rule matches and flags,
+// and the kinds of the input resources no rule matches
+type coverageReport struct {
+	Resources      int              `json:"resources"`
+	Policies       []policyCoverage `json:"policies"`
+	UnmatchedKinds []string         `json:"unmatchedKinds"`
+}
+
+type policyCoverage struct {
+	Name  string         `json:"name"`
+	Rules []ruleCoverage `json:"rules"`
+}
+
+// ruleCoverage counts the resources matched by a rule, a matched resource without a result,
+// e.g. failing the preconditions or of a skipped policy, is skipped
+type ruleCoverage struct {
+	Name    string `json:"name"`
+	Matched int    `json:"matched"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+type coverageRow struct {
+	Policy  string `header:"policy"`
+	Rule    string `header:"rule"`
+	Matched int    `header:"matched"`
+	Passed  int    `header:"passed"`
+	Failed  int    `header:"failed"`
+	Skipped int    `header:"skipped"`
+}
+
+// buildCoverage simulates the match phase of each rule on each resource, independently of the
+// evaluation of the policies that may stop early, and counts the results of the matched resources
+func buildCoverage(policies []*v1.ClusterPolicy, resources []*unstructured.Unstructured, validateEngineResponses []*response.EngineResponse, namespaceSelectorMap map[string]map[string]string) *coverageReport {
+	results := make(map[string]bool)
+	for _, er := range validateEngineResponses {
+		if er == nil {
+			continue
+		}
+
+		resource := er.PolicyResponse.Resource
+		for _, rule := range er.PolicyResponse.Rules {
+			results[coverageKey(er.PolicyResponse.Policy.Name, rule.Name, resource.Kind, resource.Namespace, resource.Name)] = rule.Success
+		}
+	}
+
+	matchedKinds := make(map[string]bool)
+	report := &coverageReport{Resources: len(resources), Policies: []policyCoverage{}, UnmatchedKinds: []string{}}
+	for _, policy := range policies {
+		pc := policyCoverage{Name: policy.GetName(), Rules: []ruleCoverage{}}
+		for _, rule := range policy.Spec.Rules {
+			rc := ruleCoverage{Name: rule.Name}
+			for _, resource := range resources {
+				namespaceLabels := namespaceSelectorMap[resource.GetNamespace()]
+				if err := engine.MatchesResourceDescription(*resource, rule, v1.RequestInfo{}, nil, namespaceLabels); err != nil {
+					continue
+				}
+
+				matchedKinds[resource.GetKind()] = true
+				if !rule.HasValidate() {
+					continue
+				}
+
+				rc.Matched++
+				success, ok := results[coverageKey(policy.GetName(), rule.Name, resource.GetKind(), resource.GetNamespace(), resource.GetName())]
+				switch {
+				case !ok:
+					rc.Skipped++
+				case success:
+					rc.Passed++
+				default:
+					rc.Failed++
+				}
+			}
+
+			if rule.HasValidate() {
+				pc.Rules = append(pc.Rules, rc)
+			}
+		}
+
+		if len(pc.Rules) > 0 {
+			report.Policies = append(report.Policies, pc)
+		}
+	}
+
+	for _, resource := range resources {
+		kind := resource.GetKind()
+		if !matchedKinds[kind] {
+			matchedKinds[kind] = true
+			report.UnmatchedKinds = append(report.UnmatchedKinds, kind)
+		}
+	}
+
+	sort.Strings(report.UnmatchedKinds)
+	return report
+}
+
+func coverageKey(policy, rule, kind, namespace, name string) string {
+	return strings.Join([]string{policy, rule, kind, namespace, name}, "/")
+}
+
+// printCoverage prints the coverage summary as a table or as JSON
+func printCoverage(w io.Writer, report *coverageReport, output string) error {
+	if output == coverageJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	rows := []coverageRow{}
+	for _, pc := range report.Policies {
+		for _, rc := range pc.Rules {
+			rows = append(rows, coverageRow{Policy: pc.Name, Rule: rc.Name, Matched: rc.Matched, Passed: rc.Passed, Failed: rc.Failed, Skipped: rc.Skipped})
+		}
+	}
+
+	fmt.Fprintln(w, "----------------------------------------------------------------------\nCOVERAGE:\n----------------------------------------------------------------------")
+	printer := tableprinter.New(w)
+	printer.BorderTop, printer.BorderBottom, printer.BorderLeft, printer.BorderRight = true, true, true, true
+	printer.CenterSeparator = "│"
+	printer.ColumnSeparator = "│"
+	printer.RowSeparator = "─"
+	printer.Print(rows)
+
+	unmatched := "none"
+	if len(report.UnmatchedKinds) > 0 {
+		unmatched = strings.Join(report.UnmatchedKinds, ", ")
+	}
+
+	fmt.Fprintf(w, "\nresources: %d, kinds matched by no policy: %s\n", report.Resources, unmatched)
+	return nil
+}
@@ -0,0 +1,60 @@
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func Test_Coverage(t *testing.T) {
+	_, rc, _, _, err := applyCommandHelper([]string{"../../../test/cli/coverage/resources.yaml"}, resourceSources{}, false, false, "", "", "", "", []string{"../../../test/cli/coverage/policies.yaml"}, false, true, time.Time{})
+	assert.NilError(t, err)
+	assert.Assert(t, rc.coverage != nil)
+
+	expected := coverageReport{
+		Resources: 6,
+		Policies: []policyCoverage{
+			{
+				Name: "disallow-latest-tag",
+				Rules: []ruleCoverage{
+					{Name: "require-image-tag", Matched: 2, Passed: 2},
+					{Name: "validate-image-tag", Matched: 2, Passed: 1, Failed: 1},
+				},
+			},
+			{
+				Name:  "require-team-label",
+				Rules: []ruleCoverage{{Name: "check-team-label", Matched: 2, Passed: 1, Failed: 1}},
+			},
+			{
+				// the policy is skipped as the values of its variables are not passed
+				Name:  "restrict-replicas",
+				Rules: []ruleCoverage{{Name: "max-replicas", Matched: 2, Skipped: 2}},
+			},
+		},
+		UnmatchedKinds: []string{"ConfigMap", "Service"},
+	}
+	assert.DeepEqual(t, *rc.coverage, expected)
+
+	var buf bytes.Buffer
+	assert.NilError(t, printCoverage(&buf, rc.coverage, coverageJSON))
+
+	var decoded coverageReport
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.DeepEqual(t, decoded, expected)
+
+	buf.Reset()
+	assert.NilError(t, printCoverage(&buf, rc.coverage, coverageTable))
+	table := buf.String()
+	assert.Assert(t, strings.Contains(table, "validate-image-tag"), table)
+	assert.Assert(t, strings.Contains(table, "resources: 6, kinds matched by no policy: ConfigMap, Service"), table)
+}
+
+func Test_Coverage_Disabled(t *testing.T) {
+	_, rc, _, _, err := applyCommandHelper([]string{"../../../test/cli/coverage/resources.yaml"}, resourceSources{}, false, false, "", "", "", "", []string{"../../../test/cli/coverage/policies.yaml"}, false, false, time.Time{})
+	assert.NilError(t, err)
+	assert.Assert(t, rc.coverage == nil)
+}
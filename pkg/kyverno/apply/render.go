@@ -0,0 +1,134 @@
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	"github.com/kyverno/kyverno/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+// helmSource matches the comment helm template adds with the template of each manifest
+var helmSource = regexp.MustCompile(`(?m)^# Source: (.+)$`)
+
+// resourceSources are the sources the resources are rendered from, in addition to the resource files
+type resourceSources struct {
+	// kustomizeDirs are the kustomize directories built
+	kustomizeDirs []string
+
+	// helmCharts are the Helm charts rendered with the values files and values
+	helmCharts  []string
+	helmValues  []string
+	helmSetArgs []string
+}
+
+func (s resourceSources) empty() bool {
+	return len(s.kustomizeDirs) == 0 && len(s.helmCharts) == 0
+}
+
+// render returns the resources rendered from the kustomize directories and the Helm charts
+func (s resourceSources) render() ([]*unstructured.Unstructured, error) {
+	var resources []*unstructured.Unstructured
+	for _, dir := range s.kustomizeDirs {
+		rendered, err := renderKustomize(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build kustomize directory %s: %v", dir, err)
+		}
+		resources = append(resources, rendered...)
+	}
+
+	for _, chart := range s.helmCharts {
+		rendered, err := renderHelmChart(chart, s.helmValues, s.helmSetArgs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render Helm chart %s: %v", chart, err)
+		}
+		resources = append(resources, rendered...)
+	}
+
+	return resources, nil
+}
+
+// renderKustomize builds the kustomize directory
+func renderKustomize(dir string) ([]*unstructured.Unstructured, error) {
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := resMap.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := common.GetResource(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range resources {
+		common.SetResourceSource(resource, "kustomize "+dir)
+	}
+
+	return resources, nil
+}
+
+// renderHelmChart renders the chart with helm template, the helm binary must be in the path
+func renderHelmChart(chart string, valuesFiles, setArgs []string) ([]*unstructured.Unstructured, error) {
+	helm, err := exec.LookPath("helm")
+	if err != nil {
+		return nil, fmt.Errorf("the helm binary is required to render the charts: %v", err)
+	}
+
+	args := []string{"template", chart}
+	for _, values := range valuesFiles {
+		args = append(args, "--values", values)
+	}
+	for _, set := range setArgs {
+		args = append(args, "--set", set)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helm, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseHelmManifests(chart, stdout.Bytes())
+}
+
+// parseHelmManifests parses the manifests rendered by helm template, the source of each resource
+// is the template it is rendered from
+func parseHelmManifests(chart string, manifests []byte) ([]*unstructured.Unstructured, error) {
+	documents, err := utils.SplitYAMLDocuments(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*unstructured.Unstructured
+	for _, document := range documents {
+		rendered, err := common.GetResource(document)
+		if err != nil {
+			return nil, err
+		}
+
+		source := "helm " + chart
+		if match := helmSource.FindSubmatch(document); match != nil {
+			source = fmt.Sprintf("helm %s", string(match[1]))
+		}
+
+		for _, resource := range rendered {
+			common.SetResourceSource(resource, source)
+		}
+		resources = append(resources, rendered...)
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,52 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	"gotest.tools/assert"
+)
+
+func Test_renderKustomize(t *testing.T) {
+	resources, err := renderKustomize("../../../test/cli/kustomize")
+	assert.NilError(t, err)
+	assert.Equal(t, len(resources), 1)
+
+	resource := resources[0]
+	assert.Equal(t, resource.GetKind(), "Deployment")
+	assert.Equal(t, resource.GetNamespace(), "prod")
+	assert.Equal(t, resource.GetName(), "prod-web")
+	assert.Equal(t, resource.GetLabels()["team"], "payments")
+	assert.Equal(t, common.ResourcePath(resource), "prod/Deployment/prod-web (kustomize ../../../test/cli/kustomize)")
+}
+
+func Test_parseHelmManifests(t *testing.T) {
+	manifests := []byte(`---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: release-name-web
+spec:
+  ports:
+  - port: 80
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: release-name-web
+  namespace: web
+spec:
+  replicas: 3
+---
+# Source: web/templates/empty.yaml
+`)
+
+	resources, err := parseHelmManifests("./charts/web", manifests)
+	assert.NilError(t, err)
+	assert.Equal(t, len(resources), 2)
+
+	assert.Equal(t, common.ResourcePath(resources[0]), "default/Service/release-name-web (helm web/templates/service.yaml)")
+	assert.Equal(t, common.ResourcePath(resources[1]), "web/Deployment/release-name-web (helm web/templates/deployment.yaml)")
+}
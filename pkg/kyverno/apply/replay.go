@@ -0,0 +1,315 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	pkgCommon "github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	sanitizederror "github.com/kyverno/kyverno/pkg/kyverno/sanitizedError"
+	"github.com/kyverno/kyverno/pkg/kyverno/store"
+	"github.com/kyverno/kyverno/pkg/openapi"
+	policy2 "github.com/kyverno/kyverno/pkg/policy"
+	"github.com/kyverno/kyverno/pkg/utils"
+	"k8s.io/api/admission/v1beta1"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// admissionReplay is an admission review replayed through the engine, the mutate policies are applied
+// before the validate policies as in the webhooks
+type admissionReplay struct {
+	request *v1beta1.AdmissionRequest
+
+	// recorded is the response of the dumped admission review, or nil
+	recorded *v1beta1.AdmissionResponse
+
+	mutateResponses   []*response.EngineResponse
+	validateResponses []*response.EngineResponse
+}
+
+// allowed checks if the request is allowed, i.e. no validate rule of an enforce policy fails
+func (r *admissionReplay) allowed() bool {
+	for _, er := range r.validateResponses {
+		if !er.IsSuccessful() && er.PolicyResponse.ValidationFailureAction == pkgCommon.Enforce {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyAdmissionReviews replays the admission reviews through the policies, and returns the number of denied requests
+func applyAdmissionReviews(w io.Writer, policyPaths, reviewPaths []string, valuesFile string, evaluationTime time.Time) (int, error) {
+	store.SetMock(true)
+	fs := memfs.New()
+
+	_, _, namespaceSelectorMap, err := common.GetVariable("", valuesFile, fs, false, "")
+	if err != nil {
+		if !sanitizederror.IsErrorSanitized(err) {
+			return 0, sanitizederror.NewWithError("failed to decode yaml", err)
+		}
+		return 0, err
+	}
+
+	if len(policyPaths) == 0 {
+		return 0, sanitizederror.NewWithError("require policy", nil)
+	}
+
+	policies, err := common.GetPoliciesFromPaths(fs, policyPaths, false, "")
+	if err != nil {
+		return 0, sanitizederror.NewWithError("failed to load policies", err)
+	}
+
+	mutatedPolicies, err := common.MutatePolices(policies)
+	if err != nil {
+		return 0, sanitizederror.NewWithError("failed to mutate policy", err)
+	}
+
+	openAPIController, err := openapi.NewOpenAPIController()
+	if err != nil {
+		return 0, sanitizederror.NewWithError("failed to initialize openAPIController", err)
+	}
+
+	validPolicies := make([]*v1.ClusterPolicy, 0, len(mutatedPolicies))
+	for _, policy := range mutatedPolicies {
+		if err := policy2.Validate(policy, nil, true, openAPIController); err != nil {
+			log.Log.V(3).Info(fmt.Sprintf("skipping policy %v as it is not valid", policy.Name), "error", err)
+			continue
+		}
+		validPolicies = append(validPolicies, policy)
+	}
+
+	reviews, err := loadAdmissionReviews(reviewPaths)
+	if err != nil {
+		return 0, sanitizederror.NewWithError("failed to load the admission reviews", err)
+	}
+
+	fmt.Fprintf(w, "\nreplaying %d admission reviews with %d policies...\n", len(reviews), len(validPolicies))
+	var denied int
+	for _, review := range reviews {
+		replay, err := replayAdmissionReview(review, validPolicies, namespaceSelectorMap, evaluationTime)
+		if err != nil {
+			return denied, sanitizederror.NewWithError(fmt.Sprintf("failed to replay the admission review %s", review.Request.UID), err)
+		}
+
+		printAdmissionReplay(w, replay)
+		if !replay.allowed() {
+			denied++
+		}
+	}
+
+	fmt.Fprintf(w, "\nallowed: %d, denied: %d\n", len(reviews)-denied, denied)
+	return denied, nil
+}
+
+// loadAdmissionReviews reads the admission reviews of the files, and of the JSON files of the directories
+// sorted by name, e.g. the admission reviews dumped by the webhooks with --admission-dump-dir
+func loadAdmissionReviews(paths []string) ([]*v1beta1.AdmissionReview, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	reviews := make([]*v1beta1.AdmissionReview, 0, len(files))
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		review := &v1beta1.AdmissionReview{}
+		if err := json.Unmarshal(raw, review); err != nil {
+			return nil, fmt.Errorf("failed to decode the admission review %s: %v", file, err)
+		}
+
+		if review.Request == nil {
+			return nil, fmt.Errorf("the admission review %s has no request", file)
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+// replayAdmissionReview applies the policies to the admission request. The user info is the one of the request,
+// the roles and the cluster roles of the user and the namespace labels are read from the values file.
+func replayAdmissionReview(review *v1beta1.AdmissionReview, policies []*v1.ClusterPolicy, namespaceSelectorMap map[string]map[string]string, evaluationTime time.Time) (*admissionReplay, error) {
+	request := review.Request
+	newResource, oldResource, err := utils.ExtractResources(nil, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the resource: %v", err)
+	}
+
+	userInfo := store.GetContext().UserInfo
+	userInfo.AdmissionUserInfo = *request.UserInfo.DeepCopy()
+	userInfo.Operation = string(request.Operation)
+	userInfo.DryRun = request.DryRun
+	if request.SubResource != "" {
+		userInfo.SubResource = request.Resource.Resource + "/" + request.SubResource
+	}
+
+	ctx := context.NewContext()
+	if err := ctx.AddRequest(request); err != nil {
+		return nil, fmt.Errorf("failed to add the request to the context: %v", err)
+	}
+
+	if err := ctx.AddUserInfo(userInfo); err != nil {
+		return nil, fmt.Errorf("failed to add the user info to the context: %v", err)
+	}
+
+	if err := ctx.AddServiceAccount(userInfo.AdmissionUserInfo.Username); err != nil {
+		return nil, fmt.Errorf("failed to add the service account to the context: %v", err)
+	}
+
+	if newResource.Object != nil {
+		if err := ctx.AddImageInfo(&newResource); err != nil {
+			return nil, fmt.Errorf("failed to add the images to the context: %v", err)
+		}
+	}
+
+	policies = append([]*v1.ClusterPolicy{}, policies...)
+	engine.SortPolicies(policies)
+
+	replay := &admissionReplay{request: request, recorded: review.Response}
+	policyContext := &engine.PolicyContext{
+		NewResource:     newResource,
+		OldResource:     oldResource,
+		AdmissionInfo:   userInfo,
+		JSONContext:     ctx,
+		NamespaceLabels: namespaceSelectorMap[request.Namespace],
+		Time:            evaluationTime,
+	}
+
+	for _, policy := range policies {
+		if !policy.HasMutate() || newResource.Object == nil {
+			continue
+		}
+
+		policyContext.Policy = *policy
+		er := engine.Mutate(policyContext)
+		if len(er.PolicyResponse.Rules) == 0 {
+			continue
+		}
+
+		if er.IsSuccessful() {
+			policyContext.NewResource = er.PatchedResource
+		}
+		replay.mutateResponses = append(replay.mutateResponses, er)
+	}
+
+	// the validate policies see the mutated resource, as the validating webhooks
+	if len(replay.mutateResponses) > 0 {
+		raw, err := policyContext.NewResource.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the mutated resource: %v", err)
+		}
+
+		if err := ctx.AddResource(raw); err != nil {
+			return nil, fmt.Errorf("failed to add the mutated resource to the context: %v", err)
+		}
+	}
+
+	for _, policy := range policies {
+		policyContext.Policy = *policy
+		er := engine.Validate(policyContext)
+		if len(er.PolicyResponse.Rules) > 0 {
+			replay.validateResponses = append(replay.validateResponses, er)
+		}
+	}
+
+	return replay, nil
+}
+
+// printAdmissionReplay prints the rules applied to the admission request, the replayed decision and the
+// decision recorded in the admission review
+func printAdmissionReplay(w io.Writer, replay *admissionReplay) {
+	request := replay.request
+	resource := request.Name
+	if request.Namespace != "" {
+		resource = request.Namespace + "/" + request.Name
+	}
+
+	fmt.Fprintf(w, "\nadmission review %s: %s %s %s\n", request.UID, request.Operation, request.Kind.Kind, resource)
+	for _, er := range replay.mutateResponses {
+		for _, rule := range er.PolicyResponse.Rules {
+			fmt.Fprintf(w, "  mutate %s/%s: %s\n", er.PolicyResponse.Policy.Name, rule.Name, ruleResult(rule))
+		}
+	}
+
+	for _, er := range replay.validateResponses {
+		for _, rule := range er.PolicyResponse.Rules {
+			fmt.Fprintf(w, "  validate %s/%s (%s): %s\n", er.PolicyResponse.Policy.Name, rule.Name, er.PolicyResponse.ValidationFailureAction, ruleResult(rule))
+		}
+	}
+
+	replayed := decision(replay.allowed())
+	fmt.Fprintf(w, "  replayed: %s\n", replayed)
+	if replay.recorded == nil {
+		return
+	}
+
+	recorded := decision(replay.recorded.Allowed)
+	if replay.recorded.Result != nil && replay.recorded.Result.Message != "" {
+		fmt.Fprintf(w, "  recorded: %s: %s\n", recorded, strings.TrimSpace(replay.recorded.Result.Message))
+	} else {
+		fmt.Fprintf(w, "  recorded: %s\n", recorded)
+	}
+
+	if recorded != replayed {
+		fmt.Fprintf(w, "  the replayed decision differs from the recorded decision\n")
+	}
+}
+
+func ruleResult(rule response.RuleResponse) string {
+	status := "pass"
+	switch {
+	case rule.Skipped:
+		status = "skip"
+	case rule.Error:
+		status = "error"
+	case !rule.Success:
+		status = "fail"
+	}
+
+	if rule.Message == "" {
+		return status
+	}
+
+	return status + ": " + rule.DocumentedMessage()
+}
+
+func decision(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+
+	return "denied"
+}
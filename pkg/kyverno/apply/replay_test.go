@@ -0,0 +1,123 @@
+package apply
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+const replayRequireTeamPolicy = `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: require-team
+spec:
+  validationFailureAction: enforce
+  rules:
+  - name: check-team
+    match:
+      resources:
+        kinds:
+        - Pod
+    validate:
+      message: "the label team is required"
+      pattern:
+        metadata:
+          labels:
+            team: "?*"
+`
+
+const replayAddTeamPolicy = `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: add-team
+spec:
+  rules:
+  - name: add-team
+    match:
+      resources:
+        kinds:
+        - Pod
+    mutate:
+      patchStrategicMerge:
+        metadata:
+          labels:
+            +(team): platform
+`
+
+const replayPodAdmissionReview = `{
+  "apiVersion": "admission.k8s.io/v1beta1",
+  "kind": "AdmissionReview",
+  "request": {
+    "uid": "3f2a",
+    "kind": {"group": "", "version": "v1", "kind": "Pod"},
+    "resource": {"group": "", "version": "v1", "resource": "pods"},
+    "namespace": "prod",
+    "name": "web",
+    "operation": "CREATE",
+    "userInfo": {"username": "system:serviceaccount:prod:deployer"},
+    "object": {
+      "apiVersion": "v1",
+      "kind": "Pod",
+      "metadata": {"name": "web", "namespace": "prod"},
+      "spec": {"containers": [{"name": "web", "image": "nginx:1.21"}]}
+    }
+  },
+  "response": {"uid": "3f2a", "allowed": true}
+}`
+
+func Test_ApplyAdmissionReviews(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	requireTeam := write("require-team.yaml", replayRequireTeamPolicy)
+	addTeam := write("add-team.yaml", replayAddTeamPolicy)
+	review := write("review.json", replayPodAdmissionReview)
+
+	var out bytes.Buffer
+	denied, err := applyAdmissionReviews(&out, []string{requireTeam}, []string{review}, "", time.Time{})
+	assert.NilError(t, err)
+	assert.Equal(t, denied, 1, out.String())
+	assert.Assert(t, strings.Contains(out.String(), "validate require-team/check-team (enforce): fail"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "replayed: denied"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "the replayed decision differs from the recorded decision"), out.String())
+
+	// the validate policies see the resource mutated by the mutate policies
+	out.Reset()
+	denied, err = applyAdmissionReviews(&out, []string{requireTeam, addTeam}, []string{review}, "", time.Time{})
+	assert.NilError(t, err)
+	assert.Equal(t, denied, 0, out.String())
+	assert.Assert(t, strings.Contains(out.String(), "mutate add-team/add-team: pass"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "replayed: allowed"), out.String())
+	assert.Assert(t, !strings.Contains(out.String(), "differs"), out.String())
+}
+
+func Test_LoadAdmissionReviews(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2-review.json", "1-review.json"} {
+		review := strings.Replace(replayPodAdmissionReview, `"3f2a"`, `"`+name[:1]+`"`, 1)
+		assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(review), 0644))
+	}
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a review"), 0644))
+
+	reviews, err := loadAdmissionReviews([]string{dir})
+	assert.NilError(t, err)
+	assert.Equal(t, len(reviews), 2)
+	assert.Equal(t, string(reviews[0].Request.UID), "1")
+	assert.Equal(t, string(reviews[1].Request.UID), "2")
+
+	empty := filepath.Join(dir, "empty.json")
+	assert.NilError(t, ioutil.WriteFile(empty, []byte(`{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview"}`), 0644))
+	_, err = loadAdmissionReviews([]string{empty})
+	assert.ErrorContains(t, err, "has no request")
+}
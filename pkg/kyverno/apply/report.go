@@ -143,6 +143,7 @@ func buildPolicyResults(resps []*response.EngineResponse) map[string][]*report.P
 				result.Rule = rule.Name
 				result.Message = rule.Message
 				result.Status = report.PolicyStatus(rule.Check)
+				result.Data = rule.Properties
 				results[appname] = append(results[appname], &result)
 			}
 		}
@@ -184,6 +185,7 @@ func mergeSucceededResults(results map[string][]*report.PolicyReportResult) map[
 				Rule:      names[1],
 				Resources: v.Resources,
 				Status:    report.PolicyStatus(v.Status),
+				Data:      v.Data,
 			}
 
 			resultsNew[scope] = append(resultsNew[scope], r)
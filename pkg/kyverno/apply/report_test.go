@@ -136,3 +136,38 @@ func Test_calculateSummary(t *testing.T) {
 	assert.Assert(t, summary.Pass == 3)
 	assert.Assert(t, summary.Fail == 3)
 }
+
+func Test_buildPolicyResults_RuleDocumentation(t *testing.T) {
+	resps := []*response.EngineResponse{
+		{
+			PatchedResource: unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind": "Pod",
+					"metadata": map[string]interface{}{
+						"name":      "nginx",
+						"namespace": "test",
+					},
+				},
+			},
+			PolicyResponse: response.PolicyResponse{
+				Policy:   response.PolicySpec{Name: "require-team"},
+				Resource: response.ResourceSpec{Name: "nginx"},
+				Rules: []response.RuleResponse{
+					{
+						Name:             "check-team-label",
+						ID:               "SEC-014",
+						DocumentationURL: "https://controls.example.com/SEC-014",
+						Type:             utils.Validation.String(),
+						Success:          true,
+					},
+				},
+			},
+		},
+	}
+
+	// the rule ID and documentation URL are kept when the passed results are merged
+	results := mergeSucceededResults(buildPolicyResults(resps))
+	assert.Equal(t, len(results["policyreport-ns-test"]), 1)
+	assert.Equal(t, results["policyreport-ns-test"][0].Data[response.RulePropertyID], "SEC-014")
+	assert.Equal(t, results["policyreport-ns-test"][0].Data[response.RulePropertyDocumentationURL], "https://controls.example.com/SEC-014")
+}
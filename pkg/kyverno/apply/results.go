@@ -0,0 +1,66 @@
+package apply
+
+import (
+	"fmt"
+
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	"github.com/kyverno/kyverno/pkg/kyverno/format"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// buildResults returns the results of the validate rules of the policy applied to the resource,
+// printed with --output-format
+func buildResults(policy *v1.ClusterPolicy, resource *unstructured.Unstructured, er *response.EngineResponse) []format.Result {
+	if er == nil {
+		return nil
+	}
+
+	var results []format.Result
+	for _, rule := range er.PolicyResponse.Rules {
+		status := ruleStatus(rule)
+		results = append(results, format.Result{
+			Policy:           policy.GetName(),
+			Rule:             rule.Name,
+			Resource:         common.ResourcePath(resource),
+			File:             common.ResourceFile(resource),
+			Actual:           report.PolicyStatus(status),
+			Result:           status,
+			Message:          rule.Message,
+			DocumentationURL: rule.DocumentationURL,
+		})
+	}
+
+	return results
+}
+
+// skippedResults returns the skip results of the rules of a policy skipped for its variables
+func skippedResults(sp SkippedPolicy) []format.Result {
+	var results []format.Result
+	for _, rule := range sp.Rules {
+		results = append(results, format.Result{
+			Policy:  sp.Name,
+			Rule:    rule.Name,
+			Actual:  report.StatusSkip,
+			Result:  report.StatusSkip,
+			Message: fmt.Sprintf("skipped policy with variables - %s", sp.Variable),
+		})
+	}
+
+	return results
+}
+
+func ruleStatus(rule response.RuleResponse) string {
+	switch {
+	case rule.Skipped:
+		return report.StatusSkip
+	case rule.Error:
+		return report.StatusError
+	case rule.Success:
+		return report.StatusPass
+	default:
+		return report.StatusFail
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-git/go-billy/v5"
@@ -49,6 +50,9 @@ type Rule struct {
 type Values struct {
 	Policies           []Policy            `json:"policies"`
 	NamespaceSelectors []NamespaceSelector `json:"namespaceSelector"`
+
+	// UserInfo is the user, roles and cluster roles the resources are applied with
+	UserInfo v1.RequestInfo `json:"userInfo"`
 }
 
 type Resource struct {
@@ -158,15 +162,11 @@ func PolicyHasVariables(policy v1.ClusterPolicy) [][]string {
 	return matches
 }
 
-// for now forbidden sections are match, exclude and
+// for now forbidden sections are match and exclude, the variables of the patchesJson6902
+// paths are substituted as the other ones
 func ruleForbiddenSectionsHaveVariables(rule v1.Rule) error {
 	var err error
 
-	err = JSONPatchPathHasVariables(rule.Mutation.PatchesJSON6902)
-	if err != nil {
-		return fmt.Errorf("Rule \"%s\" should not have variables in patchesJSON6902 path section", rule.Name)
-	}
-
 	err = objectHasVariables(rule.ExcludeResources)
 	if err != nil {
 		return fmt.Errorf("Rule \"%s\" should not have variables in exclude section", rule.Name)
@@ -180,32 +180,6 @@ func ruleForbiddenSectionsHaveVariables(rule v1.Rule) error {
 	return nil
 }
 
-func JSONPatchPathHasVariables(patch string) error {
-	jsonPatch, err := yaml.ToJSON([]byte(patch))
-	if err != nil {
-		return err
-	}
-
-	decodedPatch, err := jsonpatch.DecodePatch(jsonPatch)
-	if err != nil {
-		return err
-	}
-
-	for _, operation := range decodedPatch {
-		path, err := operation.Path()
-		if err != nil {
-			return err
-		}
-
-		vars := variables.RegexVariables.FindAllString(path, -1)
-		if len(vars) > 0 {
-			return fmt.Errorf("Operation \"%s\" has forbidden variables", operation.Kind())
-		}
-	}
-
-	return nil
-}
-
 func objectHasVariables(object interface{}) error {
 	var err error
 	objectJSON, err := json.Marshal(object)
@@ -230,6 +204,9 @@ func PolicyHasNonAllowedVariables(policy v1.ClusterPolicy) error {
 			return err
 		}
 
+		// the escaped variables are literal {{}}
+		ruleJSON = variables.RegexEscpVariables.ReplaceAll(ruleJSON, nil)
+
 		err = ruleForbiddenSectionsHaveVariables(rule)
 		if err != nil {
 			return err
@@ -238,7 +215,7 @@ func PolicyHasNonAllowedVariables(policy v1.ClusterPolicy) error {
 		matchesAll := RegexVariables.FindAllStringSubmatch(string(ruleJSON), -1)
 		matchesAllowed := AllowedVariables.FindAllStringSubmatch(string(ruleJSON), -1)
 
-		if (len(matchesAll) > len(matchesAllowed)) && len(rule.Context) == 0 {
+		if (len(matchesAll) > len(matchesAllowed)) && len(rule.Context) == 0 && len(rule.ForEachContext()) == 0 {
 			return fmt.Errorf("Rule \"%s\" has forbidden variables. Allowed variables are: {{request.*}}, {{serviceAccountName}}, {{serviceAccountNamespace}}, {{@}} and ones defined by the context", rule.Name)
 		}
 	}
@@ -385,6 +362,7 @@ func GetVariable(variablesString, valuesFile string, fs billy.Filesystem, isGit
 	valuesMapRule := make(map[string]map[string]Rule)
 	namespaceSelectorMap := make(map[string]map[string]string)
 	variables := make(map[string]string)
+	var userInfo v1.RequestInfo
 	var yamlFile []byte
 	var err error
 	if variablesString != "" {
@@ -438,6 +416,8 @@ func GetVariable(variablesString, valuesFile string, fs billy.Filesystem, isGit
 		for _, n := range values.NamespaceSelectors {
 			namespaceSelectorMap[n.Name] = n.Labels
 		}
+
+		userInfo = values.UserInfo
 	}
 
 	storePolices := make([]store.Policy, 0)
@@ -457,6 +437,7 @@ func GetVariable(variablesString, valuesFile string, fs billy.Filesystem, isGit
 
 	store.SetContext(store.Context{
 		Policies: storePolices,
+		UserInfo: userInfo,
 	})
 
 	return variables, valuesMapResource, namespaceSelectorMap, nil
@@ -482,7 +463,8 @@ func MutatePolices(policies []*v1.ClusterPolicy) ([]*v1.ClusterPolicy, error) {
 
 // ApplyPolicyOnResource - function to apply policy on resource
 func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unstructured,
-	mutateLogPath string, mutateLogPathIsDir bool, variables map[string]string, policyReport bool, namespaceSelectorMap map[string]map[string]string, stdin bool) ([]*response.EngineResponse, *response.EngineResponse, bool, bool, error) {
+	mutateLogPath string, mutateLogPathIsDir bool, variables map[string]string, policyReport bool, namespaceSelectorMap map[string]map[string]string, stdin bool,
+	evaluationTime time.Time) ([]*response.EngineResponse, *response.EngineResponse, bool, bool, error) {
 
 	responseError := false
 	rcError := false
@@ -506,7 +488,7 @@ func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unst
 		}
 	}
 
-	resPath := fmt.Sprintf("%s/%s/%s", resource.GetNamespace(), resource.GetKind(), resource.GetName())
+	resPath := ResourcePath(resource)
 	log.Log.V(3).Info("applying policy on resource", "policy", policy.Name, "resource", resPath)
 
 	ctx := context.NewContext()
@@ -515,13 +497,23 @@ func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unst
 		ctx.AddJSON(jsonData)
 	}
 
-	mutateResponse := engine.Mutate(&engine.PolicyContext{Policy: *policy, NewResource: *resource, JSONContext: ctx, NamespaceLabels: namespaceLabels})
+	// the images are parsed as in the webhooks, so that the policies see the same images variable
+	if err := ctx.AddImageInfo(resource); err != nil {
+		log.Log.Error(err, "unable to add image info to variables context", "resource", resPath)
+	}
+
+	userInfo := store.GetContext().UserInfo
+	if err := addUserInfo(ctx, userInfo); err != nil {
+		log.Log.Error(err, "unable to add user info to variables context", "resource", resPath)
+	}
+
+	mutateResponse := engine.Mutate(&engine.PolicyContext{Policy: *policy, NewResource: *resource, JSONContext: ctx, AdmissionInfo: userInfo, NamespaceLabels: namespaceLabels, Time: evaluationTime})
 	engineResponses = append(engineResponses, mutateResponse)
 
 	if !mutateResponse.IsSuccessful() {
 		fmt.Printf("Failed to apply mutate policy %s -> resource %s", policy.Name, resPath)
 		for i, r := range mutateResponse.PolicyResponse.Rules {
-			fmt.Printf("\n%d. %s", i+1, r.Message)
+			fmt.Printf("\n%d. %s", i+1, r.DocumentedMessage())
 		}
 		responseError = true
 	} else {
@@ -559,14 +551,22 @@ func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unst
 		}
 	}
 
-	policyCtx := &engine.PolicyContext{Policy: *policy, NewResource: mutateResponse.PatchedResource, JSONContext: ctx, NamespaceLabels: namespaceLabels}
+	policyCtx := &engine.PolicyContext{
+		Policy:                   *policy,
+		NewResource:              mutateResponse.PatchedResource,
+		JSONContext:              ctx,
+		AdmissionInfo:            userInfo,
+		NamespaceLabels:          namespaceLabels,
+		Time:                     evaluationTime,
+		ReportOutsideTimeWindows: true,
+	}
 	validateResponse := engine.Validate(policyCtx)
 	if !policyReport {
 		if !validateResponse.IsSuccessful() {
 			fmt.Printf("\npolicy %s -> resource %s failed: \n", policy.Name, resPath)
 			for i, r := range validateResponse.PolicyResponse.Rules {
 				if !r.Success {
-					fmt.Printf("%d. %s: %s \n", i+1, r.Name, r.Message)
+					fmt.Printf("%d. %s: %s \n", i+1, r.Name, r.DocumentedMessage())
 				}
 			}
 
@@ -590,7 +590,9 @@ func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unst
 				return false
 			},
 			JSONContext:     context.NewContext(),
+			AdmissionInfo:   userInfo,
 			NamespaceLabels: namespaceLabels,
+			Time:            evaluationTime,
 		}
 		generateResponse := engine.Generate(policyContext)
 		engineResponses = append(engineResponses, generateResponse)
@@ -609,6 +611,21 @@ func ApplyPolicyOnResource(policy *v1.ClusterPolicy, resource *unstructured.Unst
 	return engineResponses, validateResponse, responseError, rcError, nil
 }
 
+// ParseEvaluationTime parses the value of the --time flag, the time the time windows of the
+// rules are evaluated against. The current time is used if the value is empty.
+func ParseEvaluationTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, sanitizederror.NewWithError(fmt.Sprintf("invalid time %s, expected an RFC 3339 time, e.g. 2021-03-29T09:30:00+02:00", value), err)
+	}
+
+	return t, nil
+}
+
 // PrintMutatedOutput - function to print output in provided file or directory
 func PrintMutatedOutput(mutateLogPath string, mutateLogPathIsDir bool, yaml string, fileName string) error {
 	var f *os.File
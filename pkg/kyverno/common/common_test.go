@@ -1,10 +1,17 @@
 package common
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/kyverno/store"
 	ut "github.com/kyverno/kyverno/pkg/utils"
 	"gotest.tools/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
 )
 
 var policyNamespaceSelector = []byte(`{
@@ -85,7 +92,103 @@ func Test_NamespaceSelector(t *testing.T) {
 	for _, tc := range testcases {
 		policyArray, _ := ut.GetPolicy(tc.policy)
 		resourceArray, _ := GetResource(tc.resource)
-		_, validateErs, _, _, _ := ApplyPolicyOnResource(policyArray[0], resourceArray[0], "", false, nil, false, tc.namespaceSelectorMap, false)
+		_, validateErs, _, _, _ := ApplyPolicyOnResource(policyArray[0], resourceArray[0], "", false, nil, false, tc.namespaceSelectorMap, false, time.Time{})
 		assert.Assert(t, tc.success == validateErs.IsSuccessful())
 	}
 }
+
+var policyImageRegistry = []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"restrict-image-registry"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-registry","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"the app image must be registry.example.com:5000/Team/App","deny":{"conditions":{"any":[{"key":"{{ images.containers.app.registry }}","operator":"NotEquals","value":"registry.example.com:5000"},{"key":"{{ images.containers.app.path }}","operator":"NotEquals","value":"Team/App"}]}}}}]}}`)
+
+// Test_ImageInfo_CLI_Webhook checks that the images variable of the CLI is the same as in the webhooks
+func Test_ImageInfo_CLI_Webhook(t *testing.T) {
+	testcases := []struct {
+		image   string
+		success bool
+	}{
+		{image: "registry.example.com:5000/Team/App:v1", success: true},
+		{image: "Registry.Example.COM:5000/Team/App", success: true},
+		{image: "REGISTRY.EXAMPLE.COM:5000/Team/App@sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10", success: true},
+		{image: "registry.example.com:5000/team/app:v1", success: false},
+		{image: "registry.example.com/Team/App:v1", success: false},
+		{image: "Team/App:v1", success: false},
+	}
+
+	policyArray, err := ut.GetPolicy(policyImageRegistry)
+	assert.NilError(t, err)
+
+	for _, tc := range testcases {
+		resourceRaw := []byte(fmt.Sprintf(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"app","namespace":"test"},"spec":{"containers":[{"name":"app","image":"%s"}]}}`, tc.image))
+		resourceArray, err := GetResource(resourceRaw)
+		assert.NilError(t, err)
+
+		_, cliResponse, _, _, err := ApplyPolicyOnResource(policyArray[0], resourceArray[0], "", false, nil, true, nil, false, time.Time{})
+		assert.NilError(t, err)
+		assert.Equal(t, cliResponse.IsSuccessful(), tc.success, tc.image)
+
+		// the webhooks add the resource and its images to the context
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(resourceRaw))
+		assert.NilError(t, ctx.AddImageInfo(resourceArray[0]))
+		webhookResponse := engine.Validate(&engine.PolicyContext{Policy: *policyArray[0], NewResource: *resourceArray[0], JSONContext: ctx})
+		assert.Equal(t, webhookResponse.IsSuccessful(), tc.success, tc.image)
+	}
+}
+
+var policyUnresolvedVariables = []byte(`{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {"name": "restrict-namespaces"},
+	"spec": {
+	  "rules": [
+		{
+		  "name": "check-team",
+		  "match": {"resources": {"kinds": ["Pod"]}},
+		  "context": [{"name": "teams", "configMap": {"name": "teams", "namespace": "kyverno"}}],
+		  "validate": {
+			"message": "{{ request.userInfo.username }} cannot create pods in {{ request.object.metadata.namespace }}",
+			"deny": {
+			  "conditions": [{"key": "{{ request.object.metadata.namespace }}", "operator": "NotIn", "value": "{{ teams.data.namespaces }}"}]
+			}
+		  }
+		},
+		{
+		  "name": "check-images",
+		  "match": {"resources": {"kinds": ["Pod"]}},
+		  "validate": {
+			"message": "the images of {{ request.object.metadata.name }} must be signed",
+			"foreach": [{"list": "request.object.spec.containers", "deny": {"conditions": [{"key": "{{ element.image }}", "operator": "Equals", "value": "\\{{ unsigned }}"}]}}]
+		  }
+		}
+	  ]
+	}
+}`)
+
+func Test_UnresolvedVariables(t *testing.T) {
+	policies, err := ut.GetPolicy(policyUnresolvedVariables)
+	assert.NilError(t, err)
+
+	resources, err := GetResource([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"app","namespace":"dev"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`))
+	assert.NilError(t, err)
+
+	values := map[string]string{"request.object.metadata.namespace": "dev"}
+	defer store.SetContext(store.Context{})
+
+	store.SetContext(store.Context{})
+	unresolved, err := UnresolvedVariables(policies[0], resources[0], values)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, unresolved, []string{"request.object.metadata.name", "request.userInfo.username", "teams.data.namespaces"})
+
+	values["request.object.metadata.name"] = "app"
+	store.SetContext(store.Context{
+		Policies: []store.Policy{
+			{Name: "restrict-namespaces", Rules: []store.Rule{{Name: "check-team", Values: map[string]string{"teams.data.namespaces": "dev"}}}},
+		},
+		UserInfo: v1.RequestInfo{AdmissionUserInfo: authenticationv1.UserInfo{Username: "jane"}},
+	})
+	unresolved, err = UnresolvedVariables(policies[0], resources[0], values)
+	assert.NilError(t, err)
+	assert.Equal(t, len(unresolved), 0)
+
+	err = UnresolvedVariablesError(policies[0], resources[0], []string{"request.userInfo.username"})
+	assert.ErrorContains(t, err, "policy restrict-namespaces -> resource dev/Pod/app: unresolved variables: request.userInfo.username")
+}
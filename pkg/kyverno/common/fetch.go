@@ -108,6 +108,10 @@ func whenClusterIsFalse(resourcePaths []string, policyReport bool) ([]*unstructu
 			return nil, err
 		}
 
+		for _, resource := range getResources {
+			SetResourceFile(resource, resourcePath)
+		}
+
 		resources = append(resources, getResources...)
 	}
 	return resources, nil
@@ -7,8 +7,10 @@ import (
 // RegexVariables represents regex for '{{}}'
 var RegexVariables = regexp.MustCompile(`\{\{[^{}]*\}\}`)
 
-// AllowedVariables represents regex for {{request.}}, {{serviceAccountName}}, {{serviceAccountNamespace}} and {{@}}
-var AllowedVariables = regexp.MustCompile(`\{\{\s*[request\.|serviceAccountName|serviceAccountNamespace|@][^{}]*\}\}`)
+// AllowedVariables represents regex for {{request.}}, {{serviceAccountName}}, {{serviceAccountNamespace}} and {{@}},
+// and for the custom JMESPath functions base64_decode, base64_encode, parse_json, parse_yaml, compare_semver,
+// semver_compare, cidr_contains, path_canonicalize, path_base, path_dir and parse_image applied to them
+var AllowedVariables = regexp.MustCompile(`\{\{\s*(?:[request\.|serviceAccountName|serviceAccountNamespace|@]|base64_decode\(|base64_encode\(|parse_json\(|parse_yaml\(|compare_semver\(|semver_compare\(|cidr_contains\(|path_canonicalize\(|path_base\(|path_dir\(|parse_image\()[^{}]*\}\}`)
 
 // IsHttpRegex represents regex for starts with http:// or https://
 var IsHttpRegex = regexp.MustCompile("^(http|https)://")
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// resourceSources holds the sources of the rendered resources, e.g. the templates of the Helm charts
+var resourceSources sync.Map
+
+// resourceFiles holds the files the resources are read from
+var resourceFiles sync.Map
+
+// SetResourceSource records the source of a rendered resource, it is printed with the results of the resource
+func SetResourceSource(resource *unstructured.Unstructured, source string) {
+	resourceSources.Store(resource, source)
+}
+
+// ResourcePath returns the namespace, kind and name of the resource, and its source if it was rendered
+func ResourcePath(resource *unstructured.Unstructured) string {
+	resPath := fmt.Sprintf("%s/%s/%s", resource.GetNamespace(), resource.GetKind(), resource.GetName())
+	if source, ok := resourceSources.Load(resource); ok {
+		resPath = fmt.Sprintf("%s (%s)", resPath, source)
+	}
+
+	return resPath
+}
+
+// SetResourceFile records the file a resource is read from
+func SetResourceFile(resource *unstructured.Unstructured, path string) {
+	resourceFiles.Store(resource, path)
+}
+
+// ResourceFile returns the file the resource is read from, or an empty string if it is not read from a file
+func ResourceFile(resource *unstructured.Unstructured) string {
+	if path, ok := resourceFiles.Load(resource); ok {
+		return path.(string)
+	}
+
+	return ""
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	gojmespath "github.com/jmespath/go-jmespath"
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	pkgcommon "github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	sanitizederror "github.com/kyverno/kyverno/pkg/kyverno/sanitizedError"
+	"github.com/kyverno/kyverno/pkg/kyverno/store"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// elementVariables are the variables resolved by the engine for each element of the foreach rules
+var elementVariables = []string{"element", "elementIndex", "@"}
+
+// addUserInfo adds the user info of the values file to the context, the user info is not added
+// if it is not set so that the variables of the user info are not resolved
+func addUserInfo(ctx *context.Context, userInfo v1.RequestInfo) error {
+	if reflect.DeepEqual(userInfo, v1.RequestInfo{}) {
+		return nil
+	}
+
+	if err := ctx.AddUserInfo(userInfo); err != nil {
+		return err
+	}
+
+	return ctx.AddServiceAccount(userInfo.AdmissionUserInfo.Username)
+}
+
+// UnresolvedVariables returns the variables of the policy which cannot be resolved for the resource from
+// the values set on the command line, the values of the values file and the user info
+func UnresolvedVariables(policy *v1.ClusterPolicy, resource *unstructured.Unstructured, values map[string]string) ([]string, error) {
+	ctx := context.NewContext()
+	for key, value := range values {
+		if err := ctx.AddJSON(pkgcommon.VariableToJSON(key, value)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.AddImageInfo(resource); err != nil {
+		return nil, err
+	}
+
+	if err := addUserInfo(ctx, store.GetContext().UserInfo); err != nil {
+		return nil, err
+	}
+
+	unresolved := make(map[string]bool)
+	for _, rule := range policy.Spec.Rules {
+		// the context entries are resolved from the values of the rule
+		ctx.Checkpoint()
+		if r := store.GetPolicyRuleFromContext(policy.Name, rule.Name); r != nil {
+			for key, value := range r.Values {
+				if err := ctx.AddJSON(pkgcommon.VariableToJSON(key, value)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		vars, err := ruleVariables(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, variable := range vars {
+			if _, err := ctx.Query(variable); err != nil {
+				if _, ok := err.(gojmespath.NotFoundError); ok {
+					unresolved[variable] = true
+				}
+			}
+		}
+		ctx.Restore()
+	}
+
+	var names []string
+	for variable := range unresolved {
+		names = append(names, variable)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ruleVariables returns the variables of the rule, the variables of the context entries and the
+// variables of the elements of the foreach rules are resolved by the engine
+func ruleVariables(rule v1.Rule) ([]string, error) {
+	rule.Context = nil
+	rule.MatchResources = v1.MatchResources{}
+	rule.ExcludeResources = v1.ExcludeResources{}
+
+	ruleJSON, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	// the escaped variables are literal {{}}
+	ruleJSON = variables.RegexEscpVariables.ReplaceAll(ruleJSON, nil)
+
+	var vars []string
+	for _, match := range RegexVariables.FindAllString(string(ruleJSON), -1) {
+		variable := strings.TrimSpace(match[2 : len(match)-2])
+		if isElementVariable(variable) {
+			continue
+		}
+		vars = append(vars, variable)
+	}
+
+	return vars, nil
+}
+
+func isElementVariable(variable string) bool {
+	for _, v := range elementVariables {
+		if variable == v || strings.HasPrefix(variable, v+".") || strings.HasPrefix(variable, v+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UnresolvedVariablesError is the error of the variables of the policy which cannot be resolved
+func UnresolvedVariablesError(policy *v1.ClusterPolicy, resource *unstructured.Unstructured, unresolved []string) error {
+	return sanitizederror.New(fmt.Sprintf("policy %s -> resource %s: unresolved variables: %s. pass the values using the --set or --values-file flag",
+		policy.GetName(), ResourcePath(resource), strings.Join(unresolved, ", ")))
+}
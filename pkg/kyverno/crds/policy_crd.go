@@ -46,16 +46,44 @@ const PolicyCRD = `
 			  "spec": {
 				"description": "Spec defines policy behaviors and contains one or rules.",
 				"properties": {
+				  "admission": {
+					"description": "Admission controls if rules are applied to admission review requests. Optional. Default value is \"true\". Set it to \"false\" for background only policies, e.g. compliance scans of existing resources.",
+					"type": "boolean"
+				  },
+				  "allowNamespaceThresholds": {
+					"description": "AllowNamespaceThresholds lets the namespaces lower the enforcement of the policy with the \"policies.kyverno.io/enforcement-threshold\" annotation, set to \"critical\", \"high\" or \"medium\". The failures of an enforce policy whose severity is below the threshold of the namespace are audited. Optional. Default value is \"false\".",
+					"type": "boolean"
+				  },
 				  "background": {
 					"description": "Background controls if rules are applied to existing resources during a background scan. Optional. Default value is \"true\". The value must be set to \"false\" if the policy rule uses variables that are only available in the admission review request (e.g. user name).",
 					"type": "boolean"
 				  },
+				  "enabled": {
+					"description": "Enabled controls if the policy is applied. A disabled policy is kept but it is not applied to admission review requests or during background scans, and no results are reported for it. Optional. Default value is \"true\".",
+					"type": "boolean"
+				  },
+				  "failurePolicy": {
+					"description": "FailurePolicy defines how errors while processing the policy rules, e.g. when a context entry cannot be loaded, are handled in admission review requests. With \"Fail\" the rule fails and the request is denied if the policy enforces it. With \"Ignore\" the error is reported and the request is not denied by the policy. When set to \"Fail\" explicitly, the policy is also served by the resource webhooks whose failure policy is \"Fail\", so that the requests it matches are rejected while Kyverno is unavailable. The other policies are served by the webhooks whose failure policy is \"Ignore\". Optional. Default value is \"Fail\".",
+					"enum": [
+					  "Ignore",
+					  "Fail"
+					],
+					"type": "string"
+				  },
+				  "mutateDryRun": {
+					"description": "MutateDryRun evaluates the mutate rules without applying them. The patches are reported in a policy report result with the status \"warn\" but they are not returned in the admission review response. Optional. Default value is \"false\".",
+					"type": "boolean"
+				  },
 				  "rules": {
 					"description": "Rules is a list of Rule instances. A Policy contains multiple rules and each rule can validate, mutate, or generate resources.",
 					"items": {
 					  "schema": {
 						"description": "Rule defines a validation, mutation, or generation control for matching resources. Each rules contains a match declaration to select resources, and an optional exclude declaration to specify which resources to exclude.",
 						"properties": {
+						  "applyToDeleting": {
+							"description": "ApplyToDeleting applies the validate and mutate rule to the resources being deleted, i.e. with a deletion timestamp, e.g. to guard the removal of the finalizers. By default these resources are skipped, so that the updates removing the finalizers are not blocked and the deletions complete.",
+							"type": "boolean"
+						  },
 						  "context": {
 							"description": "Context defines variables and data sources that can be used during rule execution.",
 							"items": {
@@ -63,25 +91,76 @@ const PolicyCRD = `
 								"description": "ContextEntry adds variables and data sources to a rule Context. Either a ConfigMap reference or a APILookup must be provided.",
 								"properties": {
 								  "apiCall": {
-									"description": "APICall defines an HTTP request to the Kubernetes API server. The JSON data retrieved is stored in the context.",
+									"description": "APICall defines an HTTP request to the Kubernetes API server or to a service. The JSON data retrieved is stored in the context.",
 									"properties": {
+									  "cacheTTL": {
+										"description": "CacheTTL is the duration the response is reused for the requests to the same URL, e.g. \"1m\". The responses are not cached if not set.",
+										"type": "string"
+									  },
+									  "failurePolicy": {
+										"description": "FailurePolicy defines how a failed request is handled. With \"Fail\" the rule fails, with \"Ignore\" the context entry is bound to null. Optional. Default value is \"Fail\".",
+										"enum": [
+										  "Ignore",
+										  "Fail"
+										],
+										"type": "string"
+									  },
 									  "jmesPath": {
 										"description": "JMESPath is an optional JSON Match Expression that can be used to transform the JSON response returned from the API server. For example a JMESPath of \"items | length(@)\" applied to the API server response to the URLPath \"/apis/apps/v1/deployments\" will return the total count of deployments across all namespaces.",
 										"type": "string"
 									  },
+									  "service": {
+										"description": "Service is the service the HTTP GET request is sent to, e.g. an in-cluster inventory service. The response must be JSON.",
+										"properties": {
+										  "caBundle": {
+											"description": "CABundle is the PEM encoded CA bundle verifying the certificate of a HTTPS service, the system roots are used if not set.",
+											"type": "string"
+										  },
+										  "url": {
+											"description": "URL is the URL of the request, e.g. \"http://inventory.tools.svc/images/{{request.object.metadata.name}}\". The variables are substituted in the URL.",
+											"type": "string"
+										  }
+										},
+										"required": [
+										  "url"
+										],
+										"type": "object"
+									  },
+									  "timeout": {
+										"description": "Timeout is the timeout of the request to the service, e.g. \"5s\". Optional. Default value is \"10s\".",
+										"type": "string"
+									  },
 									  "urlPath": {
 										"description": "URLPath is the URL path to be used in the HTTP GET request to the Kubernetes API server (e.g. \"/api/v1/namespaces\" or  \"/apis/apps/v1/deployments\"). The format required is the same format used by the 'kubectl get --raw' command.",
 										"type": "string"
 									  }
 									},
-									"required": [
-									  "urlPath"
-									],
 									"type": "object"
 								  },
 								  "configMap": {
 									"description": "ConfigMap is the ConfigMap reference.",
 									"properties": {
+									  "fallbacks": {
+										"description": "Fallbacks are the ConfigMaps tried in order when the ConfigMap is not found.",
+										"items": {
+										  "description": "ConfigMapFallback refers to a ConfigMap tried when the previous ConfigMaps are not found",
+										  "properties": {
+											"name": {
+											  "description": "Name is the ConfigMap name.",
+											  "type": "string"
+											},
+											"namespace": {
+											  "description": "Namespace is the ConfigMap namespace, the namespace of the ConfigMap reference is used if not set.",
+											  "type": "string"
+											}
+										  },
+										  "required": [
+											"name"
+										  ],
+										  "type": "object"
+										},
+										"type": "array"
+									  },
 									  "name": {
 										"description": "Name is the ConfigMap name.",
 										"type": "string"
@@ -89,6 +168,10 @@ const PolicyCRD = `
 									  "namespace": {
 										"description": "Namespace is the ConfigMap namespace.",
 										"type": "string"
+									  },
+									  "optional": {
+										"description": "Optional binds the context entry to null when neither the ConfigMap nor its fallbacks are found, instead of failing the rule.",
+										"type": "boolean"
 									  }
 									},
 									"required": [
@@ -106,6 +189,10 @@ const PolicyCRD = `
 							},
 							"type": "array"
 						  },
+						  "documentationURL": {
+							"description": "DocumentationURL is the URL of the documentation of the rule, it is reported with the ID.",
+							"type": "string"
+						  },
 						  "exclude": {
 							"description": "ExcludeResources defines when this policy rule should not be applied. The exclude criteria can include resource information (e.g. kind, name, namespace, labels) and admission review request information like the name or role.",
 							"properties": {
@@ -125,8 +212,12 @@ const PolicyCRD = `
 									"description": "Annotations is a  map of annotations (key-value pairs of type string). Annotation keys and values support the wildcard characters \"*\" (matches zero or many characters) and \"?\" (matches at least one character).",
 									"type": "object"
 								  },
+								  "dryRun": {
+									"description": "DryRun matches the dry-run admission requests: true, false or any. The dry-run requests are always sent to Kyverno, the webhooks have no side effects on dry-run.",
+									"x-kubernetes-preserve-unknown-fields": true
+								  },
 								  "kinds": {
-									"description": "Kinds is a list of resource kinds.",
+									"description": "Kinds is a list of resource kinds. A kind can be qualified with a subresource, e.g. \"pods/exec\", \"pods/ephemeralcontainers\" or \"services/status\", to match the admission requests of the subresource.",
 									"items": {
 									  "schema": {
 										"type": "string"
@@ -134,6 +225,10 @@ const PolicyCRD = `
 									},
 									"type": "array"
 								  },
+								  "minimumAge": {
+									"description": "MinimumAge matches the resources created at least the duration ago, e.g. \"2160h\" for 90 days. The age is evaluated against the creation timestamp of the resource at the scan time, it is only supported in the match block of the rules of background only policies.",
+									"type": "string"
+								  },
 								  "name": {
 									"description": "Name is the name of the resource. The name supports wildcard characters \"*\" (matches zero or many characters) and \"?\" (at least one character).",
 									"type": "string"
@@ -190,6 +285,15 @@ const PolicyCRD = `
 									},
 									"type": "array"
 								  },
+								  "operations": {
+									"description": "Operations is a list of the admission request operations: CREATE, UPDATE, DELETE or CONNECT. The resource webhooks are only registered for the operations matched by the rules.",
+									"items": {
+									  "schema": {
+										"type": "string"
+									  }
+									},
+									"type": "array"
+								  },
 								  "selector": {
 									"description": "Selector is a label selector. Label keys and values in 'matchLabels' support the wildcard characters '*' (matches zero or many characters) and '?' (matches one character). Wildcards allows writing label selectors like [\"storage.k8s.io/*\": \"*\"]. Note that using [\"*\" : \"*\"] matches any key and value but does not match an empty label set.",
 									"properties": {
@@ -276,6 +380,10 @@ const PolicyCRD = `
 								  }
 								},
 								"type": "array"
+							  },
+							  "subjectsKind": {
+								"description": "SubjectsKind is the kind of the requestor: \"ServiceAccount\" matches the requests of the service accounts, \"User\" the requests of the other users, and \"Group\" the requests matched by one of the Group subjects. The subjects must be of this kind. Unlike the other user info fields, it is combined with them with AND.",
+								"type": "string"
 							  }
 							},
 							"type": "object"
@@ -301,10 +409,121 @@ const PolicyCRD = `
 								},
 								"type": "object"
 							  },
+							  "cloneList": {
+								"description": "CloneList specifies the source resources cloned into the namespace of the generate rule, each resource of the kinds matching the selector in the source namespace is cloned with its name. The kind and the name of the generate rule are not used. Only one of Data, Clone or CloneList can be specified.",
+								"properties": {
+								  "kinds": {
+									"description": "Kinds specifies the kinds of the source resources, e.g. ConfigMap or v1/Secret.",
+									"items": {
+									  "schema": {
+										"type": "string"
+									  }
+									},
+									"type": "array"
+								  },
+								  "namespace": {
+									"description": "Namespace specifies the namespace of the source resources.",
+									"type": "string"
+								  },
+								  "selector": {
+									"description": "Selector is a label selector for the source resources, all the resources of the kinds are cloned if it is not specified.",
+									"properties": {
+									  "matchExpressions": {
+										"description": "matchExpressions is a list of label selector requirements. The requirements are ANDed.",
+										"items": {
+										  "schema": {
+											"description": "A label selector requirement is a selector that contains values, a key, and an operator that relates the key and values.",
+											"properties": {
+											  "key": {
+												"description": "key is the label key that the selector applies to.",
+												"type": "string"
+											  },
+											  "operator": {
+												"description": "operator represents a key's relationship to a set of values. Valid operators are In, NotIn, Exists and DoesNotExist.",
+												"type": "string"
+											  },
+											  "values": {
+												"description": "values is an array of string values. If the operator is In or NotIn, the values array must be non-empty. If the operator is Exists or DoesNotExist, the values array must be empty. This array is replaced during a strategic merge patch.",
+												"items": {
+												  "schema": {
+													"type": "string"
+												  }
+												},
+												"type": "array"
+											  }
+											},
+											"required": [
+											  "key",
+											  "operator"
+											],
+											"type": "object"
+										  }
+										},
+										"type": "array"
+									  },
+									  "matchLabels": {
+										"description": "matchLabels is a map of {key,value} pairs. A single {key,value} in the matchLabels map is equivalent to an element of matchExpressions, whose key field is \"key\", the operator is \"In\", and the values array contains only \"value\". The requirements are ANDed.",
+										"type": "object"
+									  }
+									},
+									"type": "object"
+								  }
+								},
+								"type": "object"
+							  },
 							  "data": {
 								"description": "Data provides the resource declaration used to populate each generated resource. At most one of Data or Clone must be specified. If neither are provided, the generated resource will be created with default data only.",
 								"x-kubernetes-preserve-unknown-fields": true
 							  },
+							  "forEachNamespace": {
+								"description": "ForEachNamespace generates the resource in each existing namespace matching the selector, when the policy is created or updated and when the namespace labels change, instead of on the admission of the matched resources. The generated resources are removed from the namespaces that no longer match.",
+								"properties": {
+								  "selector": {
+									"description": "Selector is a label selector for the namespaces the resource is generated in.",
+									"properties": {
+									  "matchExpressions": {
+										"description": "matchExpressions is a list of label selector requirements. The requirements are ANDed.",
+										"items": {
+										  "schema": {
+											"description": "A label selector requirement is a selector that contains values, a key, and an operator that relates the key and values.",
+											"properties": {
+											  "key": {
+												"description": "key is the label key that the selector applies to.",
+												"type": "string"
+											  },
+											  "operator": {
+												"description": "operator represents a key's relationship to a set of values. Valid operators are In, NotIn, Exists and DoesNotExist.",
+												"type": "string"
+											  },
+											  "values": {
+												"description": "values is an array of string values. If the operator is In or NotIn, the values array must be non-empty. If the operator is Exists or DoesNotExist, the values array must be empty. This array is replaced during a strategic merge patch.",
+												"items": {
+												  "schema": {
+													"type": "string"
+												  }
+												},
+												"type": "array"
+											  }
+											},
+											"required": [
+											  "key",
+											  "operator"
+											],
+											"type": "object"
+										  }
+										},
+										"type": "array"
+									  },
+									  "matchLabels": {
+										"description": "matchLabels is a map of {key,value} pairs. A single {key,value} in the matchLabels map is equivalent to an element of matchExpressions, whose key field is \"key\", the operator is \"In\", and the values array contains only \"value\". The requirements are ANDed.",
+										"type": "object"
+									  }
+									},
+									"type": "object"
+								  }
+								},
+								"type": "object"
+							  },
 							  "kind": {
 								"description": "Kind specifies resource kind.",
 								"type": "string"
@@ -317,6 +536,10 @@ const PolicyCRD = `
 								"description": "Namespace specifies resource namespace.",
 								"type": "string"
 							  },
+							  "ownerReference": {
+								"description": "OwnerReference sets the trigger resource as the owner of the generated resources, so that they are garbage collected when the trigger resource is deleted. The owner reference is only set when the trigger resource is cluster-scoped or in the namespace of the generated resource. Optional. Defaults to \"false\" if not specified.",
+								"type": "boolean"
+							  },
 							  "synchronize": {
 								"description": "Synchronize controls if generated resources should be kept in-sync with their source resource. If Synchronize is set to \"true\" changes to generated resources will be overwritten with resource data from Data or the resource specified in the Clone declaration. Optional. Defaults to \"false\" if not specified.",
 								"type": "boolean"
@@ -324,6 +547,11 @@ const PolicyCRD = `
 							},
 							"type": "object"
 						  },
+						  "id": {
+							"description": "ID identifies the rule in an external catalog of controls, e.g. \"SEC-014\". It is reported with the rule results in the deny messages, events and policy reports.",
+							"maxLength": 63,
+							"type": "string"
+						  },
 						  "match": {
 							"description": "MatchResources defines when this policy rule should be applied. The match criteria can include resource information (e.g. kind, name, namespace, labels) and admission review request information like the user name or role. At least one kind is required.",
 							"properties": {
@@ -336,6 +564,10 @@ const PolicyCRD = `
 								},
 								"type": "array"
 							  },
+							  "ignorePlatformMutations": {
+								"description": "IgnorePlatformMutations evaluates the validation patterns against the resource without the fields owned by the platform field managers, e.g. the defaults set by OpenShift SecurityContextConstraints, so that the fields the platform sets are not reported. The platform field managers are configured in the Kyverno ConfigMap.",
+								"type": "boolean"
+							  },
 							  "resources": {
 								"description": "ResourceDescription contains information about the resource being created or modified. Requires at least one tag to be specified when under MatchResources.",
 								"properties": {
@@ -343,8 +575,12 @@ const PolicyCRD = `
 									"description": "Annotations is a  map of annotations (key-value pairs of type string). Annotation keys and values support the wildcard characters \"*\" (matches zero or many characters) and \"?\" (matches at least one character).",
 									"type": "object"
 								  },
+								  "dryRun": {
+									"description": "DryRun matches the dry-run admission requests: true, false or any. The dry-run requests are always sent to Kyverno, the webhooks have no side effects on dry-run.",
+									"x-kubernetes-preserve-unknown-fields": true
+								  },
 								  "kinds": {
-									"description": "Kinds is a list of resource kinds.",
+									"description": "Kinds is a list of resource kinds. A kind can be qualified with a subresource, e.g. \"pods/exec\", \"pods/ephemeralcontainers\" or \"services/status\", to match the admission requests of the subresource.",
 									"items": {
 									  "schema": {
 										"type": "string"
@@ -352,6 +588,10 @@ const PolicyCRD = `
 									},
 									"type": "array"
 								  },
+								  "minimumAge": {
+									"description": "MinimumAge matches the resources created at least the duration ago, e.g. \"2160h\" for 90 days. The age is evaluated against the creation timestamp of the resource at the scan time, it is only supported in the match block of the rules of background only policies.",
+									"type": "string"
+								  },
 								  "name": {
 									"description": "Name is the name of the resource. The name supports wildcard characters \"*\" (matches zero or many characters) and \"?\" (at least one character).",
 									"type": "string"
@@ -408,6 +648,15 @@ const PolicyCRD = `
 									},
 									"type": "array"
 								  },
+								  "operations": {
+									"description": "Operations is a list of the admission request operations: CREATE, UPDATE, DELETE or CONNECT. The resource webhooks are only registered for the operations matched by the rules.",
+									"items": {
+									  "schema": {
+										"type": "string"
+									  }
+									},
+									"type": "array"
+								  },
 								  "selector": {
 									"description": "Selector is a label selector. Label keys and values in 'matchLabels' support the wildcard characters '*' (matches zero or many characters) and '?' (matches one character). Wildcards allows writing label selectors like [\"storage.k8s.io/*\": \"*\"]. Note that using [\"*\" : \"*\"] matches any key and value but does not match an empty label set.",
 									"properties": {
@@ -494,6 +743,10 @@ const PolicyCRD = `
 								  }
 								},
 								"type": "array"
+							  },
+							  "subjectsKind": {
+								"description": "SubjectsKind is the kind of the requestor: \"ServiceAccount\" matches the requests of the service accounts, \"User\" the requests of the other users, and \"Group\" the requests matched by one of the Group subjects. The subjects must be of this kind. Unlike the other user info fields, it is combined with them with AND.",
+								"type": "string"
 							  }
 							},
 							"type": "object"
@@ -501,6 +754,136 @@ const PolicyCRD = `
 						  "mutate": {
 							"description": "Mutation is used to modify matching resources.",
 							"properties": {
+							  "foreach": {
+								"description": "ForEachMutation applies the patchStrategicMerge patches to each element of the lists selected in the request, e.g. the containers of a pod.",
+								"items": {
+								  "description": "ForEachMutation applies a patch to each element of a list. The element is available in the variable \"element\", and its index in the list in the variable \"elementIndex\".",
+								  "properties": {
+									"context": {
+									  "description": "Context defines variables and data sources loaded for each element.",
+									  "items": {
+										"schema": {
+										  "description": "ContextEntry adds variables and data sources to a rule Context. Either a ConfigMap reference or a APILookup must be provided.",
+										  "properties": {
+											"apiCall": {
+											  "description": "APICall defines an HTTP request to the Kubernetes API server or to a service. The JSON data retrieved is stored in the context.",
+											  "properties": {
+												"cacheTTL": {
+												  "description": "CacheTTL is the duration the response is reused for the requests to the same URL, e.g. \"1m\". The responses are not cached if not set.",
+												  "type": "string"
+												},
+												"failurePolicy": {
+												  "description": "FailurePolicy defines how a failed request is handled. With \"Fail\" the rule fails, with \"Ignore\" the context entry is bound to null. Optional. Default value is \"Fail\".",
+												  "enum": [
+													"Ignore",
+													"Fail"
+												  ],
+												  "type": "string"
+												},
+												"jmesPath": {
+												  "description": "JMESPath is an optional JSON Match Expression that can be used to transform the JSON response returned from the API server. For example a JMESPath of \"items | length(@)\" applied to the API server response to the URLPath \"/apis/apps/v1/deployments\" will return the total count of deployments across all namespaces.",
+												  "type": "string"
+												},
+												"service": {
+												  "description": "Service is the service the HTTP GET request is sent to, e.g. an in-cluster inventory service. The response must be JSON.",
+												  "properties": {
+													"caBundle": {
+													  "description": "CABundle is the PEM encoded CA bundle verifying the certificate of a HTTPS service, the system roots are used if not set.",
+													  "type": "string"
+													},
+													"url": {
+													  "description": "URL is the URL of the request, e.g. \"http://inventory.tools.svc/images/{{request.object.metadata.name}}\". The variables are substituted in the URL.",
+													  "type": "string"
+													}
+												  },
+												  "required": [
+													"url"
+												  ],
+												  "type": "object"
+												},
+												"timeout": {
+												  "description": "Timeout is the timeout of the request to the service, e.g. \"5s\". Optional. Default value is \"10s\".",
+												  "type": "string"
+												},
+												"urlPath": {
+												  "description": "URLPath is the URL path to be used in the HTTP GET request to the Kubernetes API server (e.g. \"/api/v1/namespaces\" or  \"/apis/apps/v1/deployments\"). The format required is the same format used by the 'kubectl get --raw' command.",
+												  "type": "string"
+												}
+											  },
+											  "type": "object"
+											},
+											"configMap": {
+											  "description": "ConfigMap is the ConfigMap reference.",
+											  "properties": {
+												"fallbacks": {
+												  "description": "Fallbacks are the ConfigMaps tried in order when the ConfigMap is not found.",
+												  "items": {
+													"description": "ConfigMapFallback refers to a ConfigMap tried when the previous ConfigMaps are not found",
+													"properties": {
+													  "name": {
+														"description": "Name is the ConfigMap name.",
+														"type": "string"
+													  },
+													  "namespace": {
+														"description": "Namespace is the ConfigMap namespace, the namespace of the ConfigMap reference is used if not set.",
+														"type": "string"
+													  }
+													},
+													"required": [
+													  "name"
+													],
+													"type": "object"
+												  },
+												  "type": "array"
+												},
+												"name": {
+												  "description": "Name is the ConfigMap name.",
+												  "type": "string"
+												},
+												"namespace": {
+												  "description": "Namespace is the ConfigMap namespace.",
+												  "type": "string"
+												},
+												"optional": {
+												  "description": "Optional binds the context entry to null when neither the ConfigMap nor its fallbacks are found, instead of failing the rule.",
+												  "type": "boolean"
+												}
+											  },
+											  "required": [
+												"name"
+											  ],
+											  "type": "object"
+											},
+											"name": {
+											  "description": "Name is the variable name.",
+											  "type": "string"
+											}
+										  },
+										  "type": "object"
+										}
+									  },
+									  "type": "array"
+									},
+									"list": {
+									  "description": "List is a JMESPath expression returning the list of elements, e.g. \"request.object.spec.containers\".",
+									  "type": "string"
+									},
+									"patchStrategicMerge": {
+									  "description": "PatchStrategicMerge is a strategic merge patch applied to the resource for each element, e.g. a patch of the container named \"{{element.name}}\".",
+									  "x-kubernetes-preserve-unknown-fields": true
+									},
+									"preconditions": {
+									  "description": "Preconditions select the elements that are patched, they can reference the element variables.",
+									  "x-kubernetes-preserve-unknown-fields": true
+									}
+								  },
+								  "required": [
+									"list"
+								  ],
+								  "type": "object"
+								},
+								"type": "array"
+							  },
 							  "overlay": {
 								"description": "Overlay specifies an overlay pattern to modify resources. DEPRECATED. Use PatchStrategicMerge instead. Scheduled for removal in release 1.5+.",
 								"x-kubernetes-preserve-unknown-fields": true
@@ -538,6 +921,10 @@ const PolicyCRD = `
 							  "patchesJson6902": {
 								"description": "PatchesJSON6902 is a list of RFC 6902 JSON Patch declarations used to modify resources. See https://tools.ietf.org/html/rfc6902 and https://kubectl.docs.kubernetes.io/references/kustomize/patchesjson6902/.",
 								"type": "string"
+							  },
+							  "preserveExisting": {
+								"description": "PreserveExisting only fills in the fields the resource leaves empty, the fields already set in the resource are removed from the patchStrategicMerge or overlay patch. The elements of the lists are matched by name. Optional. Default value is \"false\".",
+								"type": "boolean"
 							  }
 							},
 							"type": "object"
@@ -551,6 +938,47 @@ const PolicyCRD = `
 							"description": "AnyAllConditions enable variable-based conditional rule execution. This is useful for finer control of when an rule is applied. A condition can reference object data using JMESPath notation. This too can be made to happen in a logical-manner where in some situation all the conditions need to pass and in some other situation, atleast one condition is enough to pass. For the sake of backwards compatibility, it can be populated with []kyverno.Condition.",
 							"x-kubernetes-preserve-unknown-fields": true
 						  },
+						  "scope": {
+							"description": "Scope is the scope of the rule results, Resource or Namespace. The results of the rules with the Namespace scope depend only on the namespace of the resource and are cached by namespace until the namespace or the referenced ConfigMaps change. Defaults to Resource.",
+							"enum": [
+							  "Resource",
+							  "Namespace"
+							],
+							"type": "string"
+						  },
+						  "timeWindows": {
+							"description": "TimeWindows restrict the rule to the time windows, the rule is applied if the admission time, or the scan time of the background scans, is in one of the windows.",
+							"items": {
+							  "description": "TimeWindow is a weekly recurring time range, e.g. Mon-Fri 09:00-17:00 in Europe/Berlin.",
+							  "properties": {
+								"days": {
+								  "description": "Days are the days of the week the window starts on, e.g. \"Mon\", \"Tuesday\" or \"Mon-Fri\". Defaults to every day.",
+								  "items": {
+									"type": "string"
+								  },
+								  "type": "array"
+								},
+								"end": {
+								  "description": "End is the time of day the window ends at, in the HH:MM format. A window that ends before it starts spans midnight, e.g. 17:00-09:00 ends on the next day. Use \"24:00\" to end at midnight.",
+								  "type": "string"
+								},
+								"start": {
+								  "description": "Start is the time of day the window starts at, in the HH:MM format.",
+								  "type": "string"
+								},
+								"timeZone": {
+								  "description": "TimeZone is the IANA time zone of the window, e.g. \"Europe/Berlin\". The local time is used, daylight saving time transitions shift the window. Defaults to UTC.",
+								  "type": "string"
+								}
+							  },
+							  "required": [
+								"end",
+								"start"
+							  ],
+							  "type": "object"
+							},
+							"type": "array"
+						  },
 						  "validate": {
 							"description": "Validation is used to validate matching resources.",
 							"properties": {
@@ -558,6 +986,36 @@ const PolicyCRD = `
 								"description": "AnyPattern specifies list of validation patterns. At least one of the patterns must be satisfied for the validation rule to succeed.",
 								"x-kubernetes-preserve-unknown-fields": true
 							  },
+							  "cel": {
+								"description": "CEL checks the request with CEL expressions, like a ValidatingAdmissionPolicy. The rule fails if one of the expressions is false.",
+								"properties": {
+								  "expressions": {
+									"description": "Expressions are the CEL expressions checked in order. The variables \"object\", \"oldObject\" and \"request\" are the new object, the old object and the admission request. \"object\" is null for the DELETE requests and \"oldObject\" is null for the CREATE requests.",
+									"items": {
+									  "description": "CELExpression is a CEL expression returning a boolean, e.g. \"object.spec.replicas <= 5\".",
+									  "properties": {
+										"expression": {
+										  "description": "Expression is the CEL expression, the resource fails if it is false.",
+										  "type": "string"
+										},
+										"message": {
+										  "description": "Message is displayed when the expression is false. Defaults to \"failed expression: <expression>\".",
+										  "type": "string"
+										}
+									  },
+									  "required": [
+										"expression"
+									  ],
+									  "type": "object"
+									},
+									"type": "array"
+								  }
+								},
+								"required": [
+								  "expressions"
+								],
+								"type": "object"
+							  },
 							  "deny": {
 								"description": "Deny defines conditions to fail the validation rule.",
 								"properties": {
@@ -568,13 +1026,204 @@ const PolicyCRD = `
 								},
 								"type": "object"
 							  },
+							  "deprecations": {
+								"description": "Deprecations fails the resources that use an API version removed in the target Kubernetes version, e.g. an Ingress in extensions/v1beta1, and names the replacement API in the message.",
+								"properties": {
+								  "targetVersion": {
+									"description": "TargetVersion is the Kubernetes version the resources are checked against, e.g. \"1.25\". Defaults to the minor version after the version of the cluster.",
+									"type": "string"
+								  }
+								},
+								"type": "object"
+							  },
+							  "foreach": {
+								"description": "ForEachValidation applies the validate checks to each element of the lists selected in the request, e.g. the containers of a pod. The rule fails if one of the elements fails.",
+								"items": {
+								  "description": "ForEachValidation applies a pattern, an anyPattern or deny conditions to each element of a list. The element is available in the variable \"element\", and its index in the list in the variable \"elementIndex\".",
+								  "properties": {
+									"anyPattern": {
+									  "description": "AnyPattern specifies a list of patterns, each element must satisfy at least one of them.",
+									  "x-kubernetes-preserve-unknown-fields": true
+									},
+									"context": {
+									  "description": "Context defines variables and data sources loaded for each element.",
+									  "items": {
+										"schema": {
+										  "description": "ContextEntry adds variables and data sources to a rule Context. Either a ConfigMap reference or a APILookup must be provided.",
+										  "properties": {
+											"apiCall": {
+											  "description": "APICall defines an HTTP request to the Kubernetes API server or to a service. The JSON data retrieved is stored in the context.",
+											  "properties": {
+												"cacheTTL": {
+												  "description": "CacheTTL is the duration the response is reused for the requests to the same URL, e.g. \"1m\". The responses are not cached if not set.",
+												  "type": "string"
+												},
+												"failurePolicy": {
+												  "description": "FailurePolicy defines how a failed request is handled. With \"Fail\" the rule fails, with \"Ignore\" the context entry is bound to null. Optional. Default value is \"Fail\".",
+												  "enum": [
+													"Ignore",
+													"Fail"
+												  ],
+												  "type": "string"
+												},
+												"jmesPath": {
+												  "description": "JMESPath is an optional JSON Match Expression that can be used to transform the JSON response returned from the API server. For example a JMESPath of \"items | length(@)\" applied to the API server response to the URLPath \"/apis/apps/v1/deployments\" will return the total count of deployments across all namespaces.",
+												  "type": "string"
+												},
+												"service": {
+												  "description": "Service is the service the HTTP GET request is sent to, e.g. an in-cluster inventory service. The response must be JSON.",
+												  "properties": {
+													"caBundle": {
+													  "description": "CABundle is the PEM encoded CA bundle verifying the certificate of a HTTPS service, the system roots are used if not set.",
+													  "type": "string"
+													},
+													"url": {
+													  "description": "URL is the URL of the request, e.g. \"http://inventory.tools.svc/images/{{request.object.metadata.name}}\". The variables are substituted in the URL.",
+													  "type": "string"
+													}
+												  },
+												  "required": [
+													"url"
+												  ],
+												  "type": "object"
+												},
+												"timeout": {
+												  "description": "Timeout is the timeout of the request to the service, e.g. \"5s\". Optional. Default value is \"10s\".",
+												  "type": "string"
+												},
+												"urlPath": {
+												  "description": "URLPath is the URL path to be used in the HTTP GET request to the Kubernetes API server (e.g. \"/api/v1/namespaces\" or  \"/apis/apps/v1/deployments\"). The format required is the same format used by the 'kubectl get --raw' command.",
+												  "type": "string"
+												}
+											  },
+											  "type": "object"
+											},
+											"configMap": {
+											  "description": "ConfigMap is the ConfigMap reference.",
+											  "properties": {
+												"fallbacks": {
+												  "description": "Fallbacks are the ConfigMaps tried in order when the ConfigMap is not found.",
+												  "items": {
+													"description": "ConfigMapFallback refers to a ConfigMap tried when the previous ConfigMaps are not found",
+													"properties": {
+													  "name": {
+														"description": "Name is the ConfigMap name.",
+														"type": "string"
+													  },
+													  "namespace": {
+														"description": "Namespace is the ConfigMap namespace, the namespace of the ConfigMap reference is used if not set.",
+														"type": "string"
+													  }
+													},
+													"required": [
+													  "name"
+													],
+													"type": "object"
+												  },
+												  "type": "array"
+												},
+												"name": {
+												  "description": "Name is the ConfigMap name.",
+												  "type": "string"
+												},
+												"namespace": {
+												  "description": "Namespace is the ConfigMap namespace.",
+												  "type": "string"
+												},
+												"optional": {
+												  "description": "Optional binds the context entry to null when neither the ConfigMap nor its fallbacks are found, instead of failing the rule.",
+												  "type": "boolean"
+												}
+											  },
+											  "required": [
+												"name"
+											  ],
+											  "type": "object"
+											},
+											"name": {
+											  "description": "Name is the variable name.",
+											  "type": "string"
+											}
+										  },
+										  "type": "object"
+										}
+									  },
+									  "type": "array"
+									},
+									"deny": {
+									  "description": "Deny defines conditions that fail the rule for an element.",
+									  "properties": {
+										"conditions": {
+										  "description": "specifies the set of conditions to deny in a logical manner For the sake of backwards compatibility, it can be populated with []kyverno.Condition.",
+										  "x-kubernetes-preserve-unknown-fields": true
+										}
+									  },
+									  "type": "object"
+									},
+									"list": {
+									  "description": "List is a JMESPath expression returning the list of elements, e.g. \"request.object.spec.containers\".",
+									  "type": "string"
+									},
+									"pattern": {
+									  "description": "Pattern specifies an overlay-style pattern checked on each element.",
+									  "x-kubernetes-preserve-unknown-fields": true
+									},
+									"preconditions": {
+									  "description": "Preconditions select the elements that are checked, they can reference the element variables.",
+									  "x-kubernetes-preserve-unknown-fields": true
+									}
+								  },
+								  "required": [
+									"list"
+								  ],
+								  "type": "object"
+								},
+								"type": "array"
+							  },
 							  "message": {
 								"description": "Message specifies a custom message to be displayed on failure.",
 								"type": "string"
 							  },
+							  "messages": {
+								"additionalProperties": {
+								  "type": "string"
+								},
+								"description": "Messages are the variants of the message by locale, e.g. \"de\" or \"fr-CA\". The variant of the locale of the request is displayed on failure, and the variant of the default locale is reported. Message is used for the locales without a variant.",
+								"type": "object"
+							  },
 							  "pattern": {
 								"description": "Pattern specifies an overlay-style pattern used to check resources.",
 								"x-kubernetes-preserve-unknown-fields": true
+							  },
+							  "references": {
+								"description": "References fails the resources that reference objects that do not exist, e.g. an Ingress referencing a missing TLS Secret, and names each missing reference in the message.",
+								"items": {
+								  "description": "Reference selects the names of the objects referenced by a resource, the referenced objects must exist.",
+								  "properties": {
+									"allowMissing": {
+									  "description": "AllowMissing passes the resources that do not reference any object at the path, e.g. the Ingresses without TLS. By default, a resource must reference an object.",
+									  "type": "boolean"
+									},
+									"kind": {
+									  "description": "Kind is the kind of the referenced objects, e.g. \"Secret\".",
+									  "type": "string"
+									},
+									"namespace": {
+									  "description": "Namespace is the namespace of the referenced objects. Defaults to the namespace of the resource, it is ignored for cluster-wide kinds.",
+									  "type": "string"
+									},
+									"path": {
+									  "description": "Path is a JMESPath expression on the resource returning the name, or a list of names, of the referenced objects, e.g. \"spec.tls[].secretName\".",
+									  "type": "string"
+									}
+								  },
+								  "required": [
+									"kind",
+									"path"
+								  ],
+								  "type": "object"
+								},
+								"type": "array"
 							  }
 							},
 							"type": "object"
@@ -585,9 +1234,38 @@ const PolicyCRD = `
 					},
 					"type": "array"
 				  },
+				  "mutateExistingOnPolicyUpdate": {
+					"description": "MutateExistingOnPolicyUpdate controls if the mutate rules are applied to the existing resources when the policy is created or updated. The matching resources are patched in the background, the policy must be processed in the background. Optional. Default value is \"false\".",
+					"type": "boolean"
+				  },
+				  "priority": {
+					"description": "Priority orders the mutate policies applied to a resource. The policies are applied in increasing order of priority, and the policies of the same priority in order of name, so that the patches of a policy override the patches of the policies with a lower priority to the same fields. Optional. Default value is \"0\".",
+					"type": "integer"
+				  },
+				  "rolloutPercentage": {
+					"description": "RolloutPercentage gradually rolls out an enforce policy by namespace. The policy is enforced in the namespaces whose name hashes below the percentage and audited in the other namespaces. The assignment of a namespace is deterministic. Optional. By default the validationFailureAction applies to all namespaces.",
+					"maximum": 100,
+					"minimum": 0,
+					"type": "integer"
+				  },
+				  "schedule": {
+					"description": "Schedule is the cron schedule of the background scans of the policy in the standard five fields format, e.g. \"0 */6 * * *\" to scan the existing resources every 6 hours. The policy is also scanned when it is created or updated. Optional. By default the policy is scanned at the interval set with the \"--backgroundScanInterval\" flag.",
+					"type": "string"
+				  },
+				  "shadowEnforce": {
+					"description": "ShadowEnforce evaluates the validate rules of an enforce policy without denying the requests. The failures are reported in the policy reports and the events as for an audit policy, to measure the requests that would be denied before the policy is enforced. Optional. Default value is \"false\".",
+					"type": "boolean"
+				  },
 				  "validationFailureAction": {
 					"description": "ValidationFailureAction controls if a validation policy rule failure should disallow the admission review request (enforce), or allow (audit) the admission review request and report an error in a policy report. Optional. The default value is \"audit\".",
 					"type": "string"
+				  },
+				  "webhookTimeoutSeconds": {
+					"description": "WebhookTimeoutSeconds is the timeout of the resource webhook calls for the policy. The webhooks serving several policies use the largest timeout of their policies. Optional. By default the timeout set with the \"--webhooktimeout\" flag is used.",
+					"format": "int32",
+					"maximum": 30,
+					"minimum": 1,
+					"type": "integer"
 				  }
 				},
 				"type": "object"
@@ -0,0 +1,80 @@
+package create
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kyverno/kyverno/pkg/admissionpolicy"
+	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	sanitizederror "github.com/kyverno/kyverno/pkg/kyverno/sanitizedError"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// Command returns create command
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Creates Kubernetes resources from kyverno policies",
+	}
+
+	cmd.AddCommand(vapCommand())
+	return cmd
+}
+
+// vapCommand returns the command converting the CEL validate rules of policies into
+// ValidatingAdmissionPolicies and bindings
+func vapCommand() *cobra.Command {
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:     "vap",
+		Aliases: []string{"validatingadmissionpolicy"},
+		Short:   "Generates the ValidatingAdmissionPolicies and bindings of kyverno policies with CEL validate rules",
+		Example: "kyverno create vap /path/to/policy.yaml /path/to/folderOfPolicies -o vap.yaml",
+		RunE: func(cmd *cobra.Command, policyPaths []string) error {
+			if len(policyPaths) == 0 {
+				return sanitizederror.New("policy file(s) required")
+			}
+
+			policies, errs := common.GetPolicies(policyPaths)
+			if len(errs) > 0 {
+				return sanitizederror.NewWithErrors("failed to read policies", errs)
+			}
+
+			out := io.Writer(os.Stdout)
+			if outputFile != "" {
+				file, err := os.Create(outputFile)
+				if err != nil {
+					return sanitizederror.NewWithError("failed to create output file", err)
+				}
+				defer file.Close()
+				out = file
+			}
+
+			for _, policy := range policies {
+				objects, err := admissionpolicy.Generate(policy, admissionpolicy.SchemeResolver)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				for _, obj := range objects {
+					data, err := yaml.Marshal(obj.Object)
+					if err != nil {
+						return sanitizederror.NewWithError(fmt.Sprintf("failed to marshal %s %s", obj.GetKind(), obj.GetName()), err)
+					}
+					fmt.Fprintf(out, "---\n%s", data)
+				}
+			}
+
+			if len(errs) > 0 {
+				return sanitizederror.NewWithErrors("some policies cannot be converted", errs)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Writes the resources to the file instead of the standard output")
+	return cmd
+}
@@ -0,0 +1,140 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+)
+
+// Result is the result of a rule applied to a resource, the results of the apply and test commands
+// are printed in the output formats from this model
+type Result struct {
+	// Test is the name of the test case, only set by the test command
+	Test     string `json:"test,omitempty"`
+	Policy   string `json:"policy"`
+	Rule     string `json:"rule"`
+	Resource string `json:"resource"`
+	// File is the file the resource is read from, if any
+	File string `json:"file,omitempty"`
+	// Expected is the expected status of a test case
+	Expected report.PolicyStatus `json:"expected,omitempty"`
+	// Actual is the status of the rule applied to the resource
+	Actual report.PolicyStatus `json:"actual,omitempty"`
+	// Result is pass, fail, warn, error or skip
+	Result string `json:"result"`
+	// Reason explains a fail result without an actual result, e.g. "Rule not found"
+	Reason string `json:"reason,omitempty"`
+	// Message is the message of the rule
+	Message string `json:"message,omitempty"`
+	// DocumentationURL is the documentation of the rule
+	DocumentationURL string `json:"documentationURL,omitempty"`
+}
+
+// Formatter prints the results in an output format
+type Formatter interface {
+	Format(w io.Writer, results []Result) error
+}
+
+// FormatterFunc is a function printing the results
+type FormatterFunc func(w io.Writer, results []Result) error
+
+// Format calls the function
+func (f FormatterFunc) Format(w io.Writer, results []Result) error {
+	return f(w, results)
+}
+
+// output formats of the results
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+	SARIF = "sarif"
+	JUnit = "junit"
+)
+
+var (
+	mutex      sync.RWMutex
+	formatters = map[string]Formatter{
+		Table: FormatterFunc(formatTable),
+		JSON:  FormatterFunc(formatJSON),
+		YAML:  FormatterFunc(formatYAML),
+		SARIF: FormatterFunc(formatSARIF),
+		JUnit: FormatterFunc(formatJUnit),
+	}
+)
+
+// Register adds an output format, or replaces the formatter of an existing one
+func Register(name string, formatter Formatter) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	formatters[name] = formatter
+}
+
+// Get returns the formatter of the output format
+func Get(name string) (Formatter, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	formatter, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid output format %s, supported formats are %s", name, strings.Join(names(), ", "))
+	}
+
+	return formatter, nil
+}
+
+// Names returns the names of the output formats
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return names()
+}
+
+func names() []string {
+	var list []string
+	for name := range formatters {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// Print prints the results in the output format
+func Print(w io.Writer, name string, results []Result) error {
+	formatter, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	if results == nil {
+		results = []Result{}
+	}
+
+	return formatter.Format(w, results)
+}
+
+// Failed returns true if a result is a failure or an error
+func Failed(result Result) bool {
+	return result.Result == report.StatusFail || result.Result == report.StatusError
+}
+
+// message returns the message of the result, the expected and actual status of a failed test case
+func message(result Result) string {
+	if result.Test == "" {
+		return result.Message
+	}
+
+	if result.Reason != "" {
+		return result.Reason
+	}
+
+	if result.Actual != result.Expected {
+		return fmt.Sprintf("expected %s, got %s", result.Expected, result.Actual)
+	}
+
+	return result.Message
+}
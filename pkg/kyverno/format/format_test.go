@@ -0,0 +1,102 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"gotest.tools/assert"
+)
+
+var testResults = []Result{
+	{Policy: "require-labels", Rule: "check-app", Resource: "default/Pod/nginx", File: "resources/pod.yaml", Actual: report.StatusPass, Result: report.StatusPass},
+	{Policy: "require-labels", Rule: "check-team", Resource: "default/Pod/nginx", File: "resources/pod.yaml", Actual: report.StatusFail, Result: report.StatusFail,
+		Message: "label team is required", DocumentationURL: "https://example.com/require-labels"},
+	{Policy: "disallow-latest", Rule: "check-tag", Resource: "default/Pod/nginx", Actual: report.StatusError, Result: report.StatusError, Message: "failed to load context"},
+	{Policy: "disallow-latest", Rule: "check-tag", Resource: "default/Pod/redis", Actual: report.StatusSkip, Result: report.StatusSkip},
+}
+
+func Test_Get(t *testing.T) {
+	for _, name := range []string{Table, JSON, YAML, SARIF, JUnit} {
+		_, err := Get(name)
+		assert.NilError(t, err, name)
+	}
+
+	_, err := Get("html")
+	assert.ErrorContains(t, err, "invalid output format html, supported formats are json, junit, sarif, table, yaml")
+
+	Register("count", FormatterFunc(func(w io.Writer, results []Result) error {
+		_, err := w.Write([]byte{byte('0' + len(results))})
+		return err
+	}))
+	defer func() {
+		mutex.Lock()
+		delete(formatters, "count")
+		mutex.Unlock()
+	}()
+
+	var buf bytes.Buffer
+	assert.NilError(t, Print(&buf, "count", testResults))
+	assert.Equal(t, buf.String(), "4")
+}
+
+func Test_formatSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NilError(t, Print(&buf, SARIF, testResults))
+
+	var log sarifLog
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, log.Version, "2.1.0")
+	assert.Equal(t, len(log.Runs), 1)
+
+	run := log.Runs[0]
+	assert.Equal(t, run.Tool.Driver.Name, "kyverno")
+	assert.DeepEqual(t, run.Tool.Driver.Rules, []sarifRule{
+		{ID: "require-labels/check-team", Name: "check-team", ShortDescription: sarifMessage{Text: "rule check-team of policy require-labels"}, HelpURI: "https://example.com/require-labels"},
+		{ID: "disallow-latest/check-tag", Name: "check-tag", ShortDescription: sarifMessage{Text: "rule check-tag of policy disallow-latest"}},
+	})
+
+	// the passed and skipped results are not reported
+	assert.Equal(t, len(run.Results), 2)
+	assert.Equal(t, run.Results[0].RuleID, "require-labels/check-team")
+	assert.Equal(t, run.Results[0].Level, "error")
+	assert.Equal(t, run.Results[0].Message.Text, "label team is required")
+	assert.Equal(t, run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, "resources/pod.yaml")
+	assert.Equal(t, run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName, "default/Pod/nginx")
+	assert.Equal(t, run.Results[1].RuleIndex, 1)
+	assert.Assert(t, run.Results[1].Locations[0].PhysicalLocation == nil)
+}
+
+func Test_formatJUnit(t *testing.T) {
+	results := append([]Result{
+		{Test: "test-labels", Policy: "require-labels", Rule: "check-app", Resource: "nginx", Expected: report.StatusPass, Actual: report.StatusFail, Result: report.StatusFail},
+		{Test: "test-labels", Policy: "require-labels", Rule: "check-team", Resource: "nginx", Expected: report.StatusPass, Result: report.StatusFail, Reason: "Rule not found"},
+	}, testResults...)
+
+	var buf bytes.Buffer
+	assert.NilError(t, Print(&buf, JUnit, results))
+
+	var suites junitTestSuites
+	assert.NilError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	assert.Equal(t, suites.Tests, 6)
+	assert.Equal(t, suites.Failures, 3)
+	assert.Equal(t, suites.Errors, 1)
+	assert.Equal(t, suites.Skipped, 1)
+	assert.Equal(t, len(suites.Suites), 3)
+
+	suite := suites.Suites[0]
+	assert.Equal(t, suite.Name, "test-labels")
+	assert.Equal(t, suite.TestCases[0].Name, "check-app nginx")
+	assert.Equal(t, suite.TestCases[0].ClassName, "require-labels")
+	assert.Equal(t, suite.TestCases[0].Failure.Message, "expected pass, got fail")
+	assert.Equal(t, suite.TestCases[1].Failure.Message, "Rule not found")
+
+	suite = suites.Suites[2]
+	assert.Equal(t, suite.Name, "disallow-latest")
+	assert.Equal(t, suite.Errors, 1)
+	assert.Equal(t, suite.TestCases[0].Error.Message, "failed to load context")
+	assert.Assert(t, suite.TestCases[1].Skipped != nil)
+}
@@ -0,0 +1,102 @@
+package format
+
+import (
+	"encoding/xml"
+	"io"
+
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+)
+
+// junitTestSuites is the JUnit XML report read by the CI test reporters
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// formatJUnit prints the results as a JUnit XML report, the results are grouped in a test suite per
+// test case of the test command, or per policy of the apply command
+func formatJUnit(w io.Writer, results []Result) error {
+	suites := junitTestSuites{Name: "kyverno"}
+	suiteIndexes := make(map[string]int)
+	for _, result := range results {
+		name := result.Test
+		if name == "" {
+			name = result.Policy
+		}
+
+		index, ok := suiteIndexes[name]
+		if !ok {
+			index = len(suites.Suites)
+			suiteIndexes[name] = index
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: name})
+		}
+		suite := &suites.Suites[index]
+
+		testCase := junitTestCase{
+			Name:      result.Rule + " " + result.Resource,
+			ClassName: result.Policy,
+		}
+
+		switch result.Result {
+		case report.StatusFail:
+			testCase.Failure = &junitMessage{Message: message(result), Type: result.Result}
+			suite.Failures++
+		case report.StatusError:
+			testCase.Error = &junitMessage{Message: message(result), Type: result.Result}
+			suite.Errors++
+		case report.StatusSkip:
+			testCase.Skipped = &junitMessage{Message: message(result)}
+			suite.Skipped++
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, suite := range suites.Suites {
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,57 @@
+package format
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/lensesio/tableprinter"
+	"sigs.k8s.io/yaml"
+)
+
+type tableRow struct {
+	ID       int    `header:"#"`
+	Policy   string `header:"policy"`
+	Rule     string `header:"rule"`
+	Resource string `header:"resource"`
+	Result   string `header:"result"`
+	Message  string `header:"message"`
+}
+
+func formatTable(w io.Writer, results []Result) error {
+	rows := []tableRow{}
+	for i, result := range results {
+		rows = append(rows, tableRow{
+			ID:       i + 1,
+			Policy:   result.Policy,
+			Rule:     result.Rule,
+			Resource: result.Resource,
+			Result:   result.Result,
+			Message:  message(result),
+		})
+	}
+
+	printer := tableprinter.New(w)
+	printer.BorderTop, printer.BorderBottom, printer.BorderLeft, printer.BorderRight = true, true, true, true
+	printer.CenterSeparator = "│"
+	printer.ColumnSeparator = "│"
+	printer.RowSeparator = "─"
+	printer.RowCharLimit = 300
+	printer.Print(rows)
+	return nil
+}
+
+func formatJSON(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func formatYAML(w io.Writer, results []Result) error {
+	raw, err := yaml.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(raw)
+	return err
+}
@@ -1,5 +1,9 @@
 package store
 
+import (
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
 var Mock bool
 var ContextVar Context
 
@@ -43,6 +47,9 @@ func GetPolicyRuleFromContext(policyName string, ruleName string) *Rule {
 
 type Context struct {
 	Policies []Policy `json:"policies"`
+
+	// UserInfo is the user, roles and cluster roles of the admission requests
+	UserInfo kyverno.RequestInfo `json:"userInfo"`
 }
 
 type Policy struct {
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/go-git/go-billy/v5"
@@ -21,6 +22,7 @@ import (
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"github.com/kyverno/kyverno/pkg/kyverno/common"
+	"github.com/kyverno/kyverno/pkg/kyverno/format"
 	sanitizederror "github.com/kyverno/kyverno/pkg/kyverno/sanitizedError"
 	"github.com/kyverno/kyverno/pkg/kyverno/store"
 	"github.com/kyverno/kyverno/pkg/openapi"
@@ -37,7 +39,7 @@ import (
 // Command returns version command
 func Command() *cobra.Command {
 	var cmd *cobra.Command
-	var valuesFile, fileName string
+	var valuesFile, fileName, output, evaluationTime string
 	cmd = &cobra.Command{
 		Use:   "test",
 		Short: "run tests from directory",
@@ -50,7 +52,14 @@ func Command() *cobra.Command {
 					}
 				}
 			}()
-			_, err = testCommandExecute(dirPath, valuesFile, fileName)
+			if _, err := format.Get(output); err != nil {
+				return sanitizederror.NewWithError(err.Error(), nil)
+			}
+			t, err := common.ParseEvaluationTime(evaluationTime)
+			if err != nil {
+				return err
+			}
+			_, err = testCommandExecute(dirPath, valuesFile, fileName, output, t)
 			if err != nil {
 				log.Log.V(3).Info("a directory is required")
 				return err
@@ -59,9 +68,25 @@ func Command() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&fileName, "file-name", "f", "test.yaml", "test filename")
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format of the test results: "+strings.Join(format.Names(), ", "))
+	cmd.Flags().StringVarP(&evaluationTime, "time", "", "", "time the time windows of the rules are evaluated against, in the RFC 3339 format (default current time)")
 	return cmd
 }
 
+// output formats of the test results, the table is printed for each test case and the other
+// formats are printed for all test cases
+const (
+	outputTable = format.Table
+	outputJSON  = format.JSON
+)
+
+// reasons of a failed test result when no result is found for the expected result
+const (
+	reasonPolicyNotFound   = "Policy not found"
+	reasonRuleNotFound     = "Rule not found"
+	reasonResourceNotFound = "Resource not found"
+)
+
 type Test struct {
 	Name      string        `json:"name"`
 	Policies  []string      `json:"policies"`
@@ -111,12 +136,26 @@ type resultCounts struct {
 	skip int
 	pass int
 	fail int
+
+	// reports are the results of all test cases, printed with the output formats other than table
+	reports []ResultReport
 }
 
-func testCommandExecute(dirPath []string, valuesFile string, fileName string) (rc *resultCounts, err error) {
+// ResultReport is the machine-readable result of a test case
+type ResultReport = format.Result
+
+func testCommandExecute(dirPath []string, valuesFile string, fileName string, output string, evaluationTime time.Time) (rc *resultCounts, err error) {
 	var errors []error
 	fs := memfs.New()
 	rc = &resultCounts{}
+
+	stdout := os.Stdout
+	if output != outputTable {
+		// stdout is reserved for the report, progress and engine output are printed to stderr
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+
 	var testYamlCount int
 	if len(dirPath) == 0 {
 		return rc, sanitizederror.NewWithError(fmt.Sprintf("a directory is required"), err)
@@ -165,7 +204,7 @@ func testCommandExecute(dirPath []string, valuesFile string, fileName string) (r
 					errors = append(errors, sanitizederror.NewWithError("failed to convert to JSON", err))
 					continue
 				}
-				if err := applyPoliciesFromPath(fs, policyBytes, valuesFile, true, policyresoucePath, rc); err != nil {
+				if err := applyPoliciesFromPath(fs, policyBytes, valuesFile, true, policyresoucePath, output, rc, evaluationTime); err != nil {
 					return rc, sanitizederror.NewWithError("failed to apply test command", err)
 				}
 			}
@@ -175,7 +214,7 @@ func testCommandExecute(dirPath []string, valuesFile string, fileName string) (r
 		}
 	} else {
 		path := filepath.Clean(dirPath[0])
-		errors = getLocalDirTestFiles(fs, path, fileName, valuesFile, rc)
+		errors = getLocalDirTestFiles(fs, path, fileName, valuesFile, output, rc, evaluationTime)
 	}
 	if len(errors) > 0 && log.Log.V(1).Enabled() {
 		fmt.Printf("ignoring errors: \n")
@@ -183,6 +222,11 @@ func testCommandExecute(dirPath []string, valuesFile string, fileName string) (r
 			fmt.Printf("    %v \n", e.Error())
 		}
 	}
+	if output != outputTable {
+		if err := format.Print(stdout, output, rc.reports); err != nil {
+			return rc, sanitizederror.NewWithError(fmt.Sprintf("failed to print the %s report", output), err)
+		}
+	}
 	if rc.fail > 0 {
 		os.Exit(1)
 	}
@@ -190,7 +234,7 @@ func testCommandExecute(dirPath []string, valuesFile string, fileName string) (r
 	return rc, nil
 }
 
-func getLocalDirTestFiles(fs billy.Filesystem, path, fileName, valuesFile string, rc *resultCounts) []error {
+func getLocalDirTestFiles(fs billy.Filesystem, path, fileName, valuesFile, output string, rc *resultCounts, evaluationTime time.Time) []error {
 	var errors []error
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -198,7 +242,7 @@ func getLocalDirTestFiles(fs billy.Filesystem, path, fileName, valuesFile string
 	}
 	for _, file := range files {
 		if file.IsDir() {
-			getLocalDirTestFiles(fs, filepath.Join(path, file.Name()), fileName, valuesFile, rc)
+			getLocalDirTestFiles(fs, filepath.Join(path, file.Name()), fileName, valuesFile, output, rc, evaluationTime)
 			continue
 		}
 		if strings.Contains(file.Name(), fileName) {
@@ -212,7 +256,7 @@ func getLocalDirTestFiles(fs billy.Filesystem, path, fileName, valuesFile string
 				errors = append(errors, sanitizederror.NewWithError("failed to convert json", err))
 				continue
 			}
-			if err := applyPoliciesFromPath(fs, valuesBytes, valuesFile, false, path, rc); err != nil {
+			if err := applyPoliciesFromPath(fs, valuesBytes, valuesFile, false, path, output, rc, evaluationTime); err != nil {
 				errors = append(errors, sanitizederror.NewWithError(fmt.Sprintf("failed to apply test command from file %s", file.Name()), err))
 				continue
 			}
@@ -221,6 +265,8 @@ func getLocalDirTestFiles(fs billy.Filesystem, path, fileName, valuesFile string
 	return errors
 }
 
+// buildPolicyResults returns the actual results of the mutate and validate rules for the expected results,
+// keyed by <policy>-<rule>-<resource>. An expected rule that is not applied on the resource is skipped.
 func buildPolicyResults(resps []*response.EngineResponse, testResults []TestResults) map[string]report.PolicyReportResult {
 	results := make(map[string]report.PolicyReportResult)
 	infos := policyreport.GeneratePRsFromEngineResponse(resps, log.Log)
@@ -230,23 +276,24 @@ func buildPolicyResults(resps []*response.EngineResponse, testResults []TestResu
 
 		var rules []string
 		for _, rule := range resp.PolicyResponse.Rules {
-			rules = append(rules, rule.Name)
-		}
-		result := report.PolicyReportResult{
-			Policy: policyName,
-			Resources: []*corev1.ObjectReference{
-				{
-					Name: resourceName,
-				},
-			},
+			rules = append(rules, trimAutogenPrefix(rule.Name))
 		}
+
 		for _, test := range testResults {
 			if test.Policy == policyName && test.Resource == resourceName {
+				result := report.PolicyReportResult{
+					Policy: policyName,
+					Resources: []*corev1.ObjectReference{
+						{
+							Name: resourceName,
+						},
+					},
+				}
 				if !util.ContainsString(rules, test.Rule) {
 					result.Status = report.StatusSkip
 				}
 				resultsKey := fmt.Sprintf("%s-%s-%s", test.Policy, test.Rule, test.Resource)
-				if _, ok := results[resultsKey]; !ok {
+				if val, ok := results[resultsKey]; !ok || val.Status == report.StatusSkip {
 					results[resultsKey] = result
 				}
 			}
@@ -255,13 +302,10 @@ func buildPolicyResults(resps []*response.EngineResponse, testResults []TestResu
 	for _, info := range infos {
 		for _, infoResult := range info.Results {
 			for _, rule := range infoResult.Rules {
-				if rule.Type != utils.Validation.String() {
+				if rule.Type != utils.Validation.String() && rule.Type != utils.Mutation.String() {
 					continue
 				}
-				ruleName := strings.ReplaceAll(rule.Name, "autogen-", "")
-				if strings.Contains(rule.Name, "autogen-cronjob") {
-					ruleName = strings.ReplaceAll(rule.Name, "autogen-cronjob-", "")
-				}
+				ruleName := trimAutogenPrefix(rule.Name)
 				var result report.PolicyReportResult
 				resultsKey := fmt.Sprintf("%s-%s-%s", info.PolicyName, ruleName, infoResult.Resource.Name)
 				if val, ok := results[resultsKey]; ok {
@@ -278,6 +322,26 @@ func buildPolicyResults(resps []*response.EngineResponse, testResults []TestResu
 	return results
 }
 
+func trimAutogenPrefix(ruleName string) string {
+	if strings.HasPrefix(ruleName, "autogen-cronjob-") {
+		return strings.TrimPrefix(ruleName, "autogen-cronjob-")
+	}
+	return strings.TrimPrefix(ruleName, "autogen-")
+}
+
+// buildPolicyRules returns the rule names of the policies, keyed by policy name
+func buildPolicyRules(policies []*v1.ClusterPolicy) map[string]map[string]bool {
+	policyRules := make(map[string]map[string]bool, len(policies))
+	for _, policy := range policies {
+		rules := make(map[string]bool, len(policy.Spec.Rules))
+		for _, rule := range policy.Spec.Rules {
+			rules[trimAutogenPrefix(rule.Name)] = true
+		}
+		policyRules[policy.GetName()] = rules
+	}
+	return policyRules
+}
+
 func getPolicyResourceFullPath(path []string, policyResourcePath string, isGit bool) []string {
 	var pol []string
 	if !isGit {
@@ -289,7 +353,7 @@ func getPolicyResourceFullPath(path []string, policyResourcePath string, isGit b
 	return path
 }
 
-func applyPoliciesFromPath(fs billy.Filesystem, policyBytes []byte, valuesFile string, isGit bool, policyResourcePath string, rc *resultCounts) (err error) {
+func applyPoliciesFromPath(fs billy.Filesystem, policyBytes []byte, valuesFile string, isGit bool, policyResourcePath string, output string, rc *resultCounts, evaluationTime time.Time) (err error) {
 	openAPIController, err := openapi.NewOpenAPIController()
 	engineResponses := make([]*response.EngineResponse, 0)
 	validateEngineResponses := make([]*response.EngineResponse, 0)
@@ -389,7 +453,7 @@ func applyPoliciesFromPath(fs billy.Filesystem, policyBytes []byte, valuesFile s
 				return sanitizederror.NewWithError(fmt.Sprintf("policy %s have variables. pass the values for the variables using set/values_file flag", policy.Name), err)
 			}
 
-			ers, validateErs, _, _, err := common.ApplyPolicyOnResource(policy, resource, "", false, thisPolicyResourceValues, true, namespaceSelectorMap, false)
+			ers, validateErs, _, _, err := common.ApplyPolicyOnResource(policy, resource, "", false, thisPolicyResourceValues, true, namespaceSelectorMap, false, evaluationTime)
 			if err != nil {
 				return sanitizederror.NewWithError(fmt.Errorf("failed to apply policy %v on resource %v", policy.Name, resource.GetName()).Error(), err)
 			}
@@ -397,15 +461,15 @@ func applyPoliciesFromPath(fs billy.Filesystem, policyBytes []byte, valuesFile s
 			validateEngineResponses = append(validateEngineResponses, validateErs)
 		}
 	}
-	resultsMap := buildPolicyResults(validateEngineResponses, values.Results)
-	resultErr := printTestResult(resultsMap, values.Results, rc)
+	resultsMap := buildPolicyResults(append(engineResponses, validateEngineResponses...), values.Results)
+	resultErr := printTestResult(values.Name, resultsMap, buildPolicyRules(mutatedPolicies), values.Results, output, rc)
 	if resultErr != nil {
 		return sanitizederror.NewWithError("Unable to genrate result. Error:", resultErr)
 	}
 	return
 }
 
-func printTestResult(resps map[string]report.PolicyReportResult, testResults []TestResults, rc *resultCounts) error {
+func printTestResult(testName string, resps map[string]report.PolicyReportResult, policyRules map[string]map[string]bool, testResults []TestResults, output string, rc *resultCounts) error {
 	printer := tableprinter.New(os.Stdout)
 	table := []*Table{}
 	boldGreen := color.New(color.FgGreen).Add(color.Bold)
@@ -416,29 +480,47 @@ func printTestResult(resps map[string]report.PolicyReportResult, testResults []T
 		res := new(Table)
 		res.ID = i + 1
 		res.Resource = boldFgCyan.Sprintf(v.Resource) + " with " + boldFgCyan.Sprintf(v.Policy) + "/" + boldFgCyan.Sprintf(v.Rule)
+		resultReport := ResultReport{
+			Test:     testName,
+			Policy:   v.Policy,
+			Rule:     v.Rule,
+			Resource: v.Resource,
+			Expected: v.Status,
+		}
+
 		resultKey := fmt.Sprintf("%s-%s-%s", v.Policy, v.Rule, v.Resource)
-		var testRes report.PolicyReportResult
-		if val, ok := resps[resultKey]; ok {
-			testRes = val
-		} else {
-			res.Result = boldYellow.Sprintf("Not found")
+		testRes, found := resps[resultKey]
+		if reason := notFoundReason(policyRules, v, found); reason != "" {
+			res.Result = boldYellow.Sprint(reason)
 			rc.fail++
 			table = append(table, res)
+			resultReport.Result = "fail"
+			resultReport.Reason = reason
+			rc.reports = append(rc.reports, resultReport)
 			continue
 		}
+
+		resultReport.Actual = testRes.Status
 		if testRes.Status == v.Status {
 			if testRes.Status == report.StatusSkip {
 				res.Result = boldGreen.Sprintf("Skip")
+				resultReport.Result = "skip"
 				rc.skip++
 			} else {
 				res.Result = boldGreen.Sprintf("Pass")
+				resultReport.Result = "pass"
 				rc.pass++
 			}
 		} else {
 			res.Result = boldRed.Sprintf("Fail")
+			resultReport.Result = "fail"
 			rc.fail++
 		}
 		table = append(table, res)
+		rc.reports = append(rc.reports, resultReport)
+	}
+	if output != outputTable {
+		return nil
 	}
 	printer.BorderTop, printer.BorderBottom, printer.BorderLeft, printer.BorderRight = true, true, true, true
 	printer.CenterSeparator = "│"
@@ -453,3 +535,19 @@ func printTestResult(resps map[string]report.PolicyReportResult, testResults []T
 	printer.Print(table)
 	return nil
 }
+
+// notFoundReason returns why there is no result for the expected result, or an empty string if a result is found.
+// A policy or rule that does not exist is reported even if the expected result is skip.
+func notFoundReason(policyRules map[string]map[string]bool, expected TestResults, found bool) string {
+	rules, ok := policyRules[expected.Policy]
+	if !ok {
+		return reasonPolicyNotFound
+	}
+	if !rules[expected.Rule] {
+		return reasonRuleNotFound
+	}
+	if !found {
+		return reasonResourceNotFound
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/kyverno/format"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestEngineResponse(policy, resource string, rules ...response.RuleResponse) *response.EngineResponse {
+	er := &response.EngineResponse{}
+	er.PolicyResponse.Policy.Name = policy
+	er.PolicyResponse.Resource.Kind = "Pod"
+	er.PolicyResponse.Resource.Name = resource
+	er.PolicyResponse.Rules = rules
+	er.PatchedResource.SetKind("Pod")
+	er.PatchedResource.SetName(resource)
+	return er
+}
+
+func Test_Print_Test_Results(t *testing.T) {
+	policies := []*v1.ClusterPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "add-team-label"},
+			Spec: v1.Spec{
+				Rules: []v1.Rule{{Name: "add-default-team"}, {Name: "require-app-label"}},
+			},
+		},
+	}
+
+	resps := []*response.EngineResponse{
+		newTestEngineResponse("add-team-label", "test-pod",
+			response.RuleResponse{Name: "add-default-team", Type: utils.Mutation.String(), Success: true}),
+		newTestEngineResponse("add-team-label", "test-pod",
+			response.RuleResponse{Name: "require-app-label", Type: utils.Validation.String(), Success: false}),
+	}
+
+	testResults := []TestResults{
+		{Policy: "add-team-label", Rule: "add-default-team", Resource: "test-pod", Status: report.StatusPass},
+		{Policy: "add-team-label", Rule: "require-app-label", Resource: "test-pod", Status: report.StatusPass},
+		{Policy: "add-team-label", Rule: "missing-rule", Resource: "test-pod", Status: report.StatusSkip},
+		{Policy: "missing-policy", Rule: "add-default-team", Resource: "test-pod", Status: report.StatusPass},
+		{Policy: "add-team-label", Rule: "add-default-team", Resource: "missing-pod", Status: report.StatusPass},
+	}
+
+	rc := &resultCounts{}
+	results := buildPolicyResults(resps, testResults)
+	assert.NilError(t, printTestResult("test-mutate", results, buildPolicyRules(policies), testResults, outputJSON, rc))
+	assert.Equal(t, rc.pass, 1)
+	assert.Equal(t, rc.fail, 4)
+
+	var buf bytes.Buffer
+	assert.NilError(t, format.Print(&buf, outputJSON, rc.reports))
+
+	var reports []ResultReport
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &reports))
+	assert.DeepEqual(t, reports, []ResultReport{
+		{Test: "test-mutate", Policy: "add-team-label", Rule: "add-default-team", Resource: "test-pod", Expected: report.StatusPass, Actual: report.StatusPass, Result: "pass"},
+		{Test: "test-mutate", Policy: "add-team-label", Rule: "require-app-label", Resource: "test-pod", Expected: report.StatusPass, Actual: report.StatusFail, Result: "fail"},
+		{Test: "test-mutate", Policy: "add-team-label", Rule: "missing-rule", Resource: "test-pod", Expected: report.StatusSkip, Result: "fail", Reason: reasonRuleNotFound},
+		{Test: "test-mutate", Policy: "missing-policy", Rule: "add-default-team", Resource: "test-pod", Expected: report.StatusPass, Result: "fail", Reason: reasonPolicyNotFound},
+		{Test: "test-mutate", Policy: "add-team-label", Rule: "add-default-team", Resource: "missing-pod", Expected: report.StatusPass, Result: "fail", Reason: reasonResourceNotFound},
+	})
+}
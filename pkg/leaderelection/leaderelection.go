@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/metrics"
 	"github.com/pkg/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection"
@@ -46,10 +48,14 @@ type Config struct {
 	leaderElectionCfg leaderelection.LeaderElectionConfig
 	leaderElector     *leaderelection.LeaderElector
 	isLeader          int64
+	promConfig        *metrics.PromConfig
 	log               logr.Logger
 }
 
-func New(name, namespace string, kubeClient kubernetes.Interface, startWork, stopWork func(), log logr.Logger) (Interface, error) {
+// New returns the leader election of the lease, the leader runs startWork and runs stopWork once it
+// loses the leadership. The leadership is exposed with the kyverno_leader_election_leader metric, unless
+// promConfig is nil.
+func New(name, namespace string, kubeClient kubernetes.Interface, startWork, stopWork func(), promConfig *metrics.PromConfig, log logr.Logger) (Interface, error) {
 	id, err := os.Hostname()
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting host name: %s/%s", namespace, name)
@@ -79,6 +85,7 @@ func New(name, namespace string, kubeClient kubernetes.Interface, startWork, sto
 		lock:       lock,
 		startWork:  startWork,
 		stopWork:   stopWork,
+		promConfig: promConfig,
 		log:        log,
 	}
 
@@ -91,6 +98,7 @@ func New(name, namespace string, kubeClient kubernetes.Interface, startWork, sto
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				atomic.StoreInt64(&e.isLeader, 1)
+				e.setLeaderMetric(1)
 				e.log.WithValues("id", e.lock.Identity()).Info("started leading")
 
 				if e.startWork != nil {
@@ -100,6 +108,7 @@ func New(name, namespace string, kubeClient kubernetes.Interface, startWork, sto
 
 			OnStoppedLeading: func() {
 				atomic.StoreInt64(&e.isLeader, 0)
+				e.setLeaderMetric(0)
 				e.log.WithValues("id", e.lock.Identity()).Info("leadership lost, stopped leading")
 				if e.stopWork != nil {
 					e.stopWork()
@@ -124,9 +133,19 @@ func New(name, namespace string, kubeClient kubernetes.Interface, startWork, sto
 		e.leaderElectionCfg.WatchDog.SetLeaderElection(e.leaderElector)
 	}
 
+	// the followers expose the metric too
+	e.setLeaderMetric(0)
 	return e, nil
 }
 
+func (e *Config) setLeaderMetric(value float64) {
+	if e.promConfig == nil {
+		return
+	}
+
+	e.promConfig.Metrics.LeaderElectionLeader.With(prom.Labels{"lease": e.name}).Set(value)
+}
+
 func (e *Config) Name() string {
 	return e.name
 }
@@ -0,0 +1,49 @@
+package leaderelection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_LeaderElection_Metric(t *testing.T) {
+	promConfig := metrics.NewPromConfig()
+	leader := promConfig.Metrics.LeaderElectionLeader.With(prom.Labels{"lease": "kyverno"})
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	le, err := New("kyverno", "kyverno", fake.NewSimpleClientset(), func() { close(started) }, func() { close(stopped) }, promConfig, log.Log)
+	assert.NilError(t, err)
+
+	// the followers expose the metric
+	assert.Equal(t, testutil.ToFloat64(leader), float64(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go le.Run(ctx)
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the leadership is not acquired")
+	}
+	assert.Assert(t, le.IsLeader())
+	assert.Equal(t, testutil.ToFloat64(leader), float64(1))
+
+	// the leadership is released on shutdown
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the leadership is not released")
+	}
+	assert.Assert(t, !le.IsLeader())
+	assert.Equal(t, testutil.ToFloat64(leader), float64(0))
+}
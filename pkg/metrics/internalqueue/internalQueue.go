@@ -0,0 +1,163 @@
+package internalqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// reportPeriod is the period the age of the oldest item is reported at
+const reportPeriod = 10 * time.Second
+
+var (
+	mutex               sync.RWMutex
+	ageWarningThreshold = 5 * time.Minute
+)
+
+// SetAgeWarningThreshold sets the age of the oldest item a warning is logged at, 0 disables the warning
+func SetAgeWarningThreshold(threshold time.Duration) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	ageWarningThreshold = threshold
+}
+
+// AgeWarningThreshold returns the age of the oldest item a warning is logged at
+func AgeWarningThreshold() time.Duration {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return ageWarningThreshold
+}
+
+// Queue is a rate limiting queue that timestamps the enqueued items, it reports the depth
+// of the queue and the age of its oldest item. An item re-enqueued with a delay is
+// timestamped when it is re-enqueued.
+type Queue struct {
+	workqueue.RateLimitingInterface
+
+	name    QueueName
+	metrics *PromMetrics
+	clock   clock.Clock
+	log     logr.Logger
+
+	mutex    sync.Mutex
+	enqueued map[interface{}]time.Time
+	warned   bool
+}
+
+// NewQueue returns the queue tracking the items of the rate limiting queue, the metrics
+// are not reported if the prometheus configuration is nil
+func NewQueue(queue workqueue.RateLimitingInterface, name QueueName, promConfig *metrics.PromConfig, log logr.Logger) *Queue {
+	return newQueue(queue, name, promConfig, clock.RealClock{}, log)
+}
+
+func newQueue(queue workqueue.RateLimitingInterface, name QueueName, promConfig *metrics.PromConfig, c clock.Clock, log logr.Logger) *Queue {
+	q := &Queue{
+		RateLimitingInterface: queue,
+		name:                  name,
+		clock:                 c,
+		log:                   log.WithValues("queue", string(name)),
+		enqueued:              make(map[interface{}]time.Time),
+	}
+
+	if promConfig != nil {
+		pm := ParsePromMetrics(*promConfig.Metrics)
+		q.metrics = &pm
+	}
+
+	return q
+}
+
+// Add timestamps and adds the item
+func (q *Queue) Add(item interface{}) {
+	q.enqueue(item)
+	q.RateLimitingInterface.Add(item)
+}
+
+// AddRateLimited timestamps and adds the item after the rate limiter says it's ok
+func (q *Queue) AddRateLimited(item interface{}) {
+	q.enqueue(item)
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+// AddAfter timestamps and adds the item after the duration
+func (q *Queue) AddAfter(item interface{}, duration time.Duration) {
+	q.enqueue(item)
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+// Get returns the next item and removes its timestamp
+func (q *Queue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+	if !shutdown {
+		q.mutex.Lock()
+		delete(q.enqueued, item)
+		q.setDepth()
+		q.mutex.Unlock()
+	}
+
+	return item, shutdown
+}
+
+// enqueue timestamps the item, the item keeps its timestamp if it is waiting already
+func (q *Queue) enqueue(item interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.enqueued[item]; !ok {
+		q.enqueued[item] = q.clock.Now()
+	}
+
+	q.setDepth()
+}
+
+func (q *Queue) setDepth() {
+	if q.metrics == nil {
+		return
+	}
+
+	q.metrics.InternalQueueDepth.With(prom.Labels{"queue": string(q.name)}).Set(float64(len(q.enqueued)))
+}
+
+// Report reports the depth of the queue and the age of its oldest item, a warning is logged
+// once when the age exceeds the threshold, and again after the queue caught up
+func (q *Queue) Report() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var age time.Duration
+	now := q.clock.Now()
+	for _, enqueued := range q.enqueued {
+		if a := now.Sub(enqueued); a > age {
+			age = a
+		}
+	}
+
+	q.setDepth()
+	if q.metrics != nil {
+		q.metrics.InternalQueueOldestItemAge.With(prom.Labels{"queue": string(q.name)}).Set(age.Seconds())
+	}
+
+	threshold := AgeWarningThreshold()
+	if threshold <= 0 || age <= threshold {
+		q.warned = false
+		return
+	}
+
+	if !q.warned {
+		q.log.Info("warning: the oldest item of the queue exceeds the age threshold, the queue is growing a backlog", "depth", len(q.enqueued), "age", age.String(), "threshold", threshold.String())
+		q.warned = true
+	}
+}
+
+// Run reports the metrics of the queue until the stop channel is closed, the queues
+// that are not tracked are not reported
+func Run(queue workqueue.Interface, stopCh <-chan struct{}) {
+	if q, ok := queue.(*Queue); ok {
+		wait.Until(q.Report, reportPeriod, stopCh)
+	}
+}
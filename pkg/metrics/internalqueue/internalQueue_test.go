@@ -0,0 +1,137 @@
+package internalqueue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func Test_Queue_Metrics(t *testing.T) {
+	promConfig := metrics.NewPromConfig()
+	fakeClock := clock.NewFakeClock(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	depth := func(name QueueName) float64 {
+		return testutil.ToFloat64(promConfig.Metrics.InternalQueueDepth.With(prom.Labels{"queue": string(name)}))
+	}
+
+	age := func(name QueueName) float64 {
+		return testutil.ToFloat64(promConfig.Metrics.InternalQueueOldestItemAge.With(prom.Labels{"queue": string(name)}))
+	}
+
+	for _, name := range []QueueName{Reports, UpdateRequests, Events} {
+		q := newQueue(workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), string(name)), name, promConfig, fakeClock, &recordingLogger{})
+
+		q.Add("first")
+		fakeClock.Step(30 * time.Second)
+		q.Add("second")
+
+		// an item waiting already keeps its timestamp
+		q.Add("first")
+		fakeClock.Step(30 * time.Second)
+
+		q.Report()
+		assert.Equal(t, depth(name), float64(2), name)
+		assert.Equal(t, age(name), float64(60), name)
+
+		// the oldest item is processed
+		item, _ := q.Get()
+		assert.Equal(t, item, "first")
+		q.Done(item)
+
+		q.Report()
+		assert.Equal(t, depth(name), float64(1), name)
+		assert.Equal(t, age(name), float64(30), name)
+
+		item, _ = q.Get()
+		q.Done(item)
+
+		q.Report()
+		assert.Equal(t, depth(name), float64(0), name)
+		assert.Equal(t, age(name), float64(0), name)
+		q.ShutDown()
+	}
+}
+
+func Test_Queue_AgeWarning(t *testing.T) {
+	defer SetAgeWarningThreshold(AgeWarningThreshold())
+	SetAgeWarningThreshold(time.Minute)
+
+	fakeClock := clock.NewFakeClock(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	logger := &recordingLogger{}
+	q := newQueue(workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "events"), Events, nil, fakeClock, logger)
+	defer q.ShutDown()
+
+	q.Add("event")
+	fakeClock.Step(time.Minute)
+	q.Report()
+	assert.Equal(t, len(logger.lines), 0)
+
+	// the warning is logged once while the age exceeds the threshold
+	fakeClock.Step(time.Second)
+	q.Report()
+	q.Report()
+	assert.Equal(t, len(logger.lines), 1)
+	assert.Assert(t, strings.Contains(logger.lines[0], "exceeds the age threshold"), logger.lines[0])
+	assert.Assert(t, strings.Contains(logger.lines[0], "queue events"), logger.lines[0])
+
+	// and again after the queue caught up
+	item, _ := q.Get()
+	q.Done(item)
+	q.Report()
+	q.Add("event")
+	fakeClock.Step(2 * time.Minute)
+	q.Report()
+	assert.Equal(t, len(logger.lines), 2)
+
+	// the warning is disabled
+	SetAgeWarningThreshold(0)
+	item, _ = q.Get()
+	q.Done(item)
+	q.Report()
+	q.Add("event")
+	fakeClock.Step(time.Hour)
+	q.Report()
+	assert.Equal(t, len(logger.lines), 2)
+}
+
+// recordingLogger records the messages and values
+type recordingLogger struct {
+	lines  []string
+	values []interface{}
+}
+
+var _ logr.Logger = &recordingLogger{}
+
+func (l *recordingLogger) Enabled() bool {
+	return true
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("%s %v %v", msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("%v %s %v %v", err, msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) V(level int) logr.Logger {
+	return l
+}
+
+func (l *recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.values = append(l.values, keysAndValues...)
+	return l
+}
+
+func (l *recordingLogger) WithName(name string) logr.Logger {
+	return l
+}
@@ -0,0 +1,15 @@
+package internalqueue
+
+import (
+	"github.com/kyverno/kyverno/pkg/metrics"
+)
+
+type QueueName string
+
+const (
+	Reports        QueueName = "reports"
+	UpdateRequests QueueName = "updaterequests"
+	Events         QueueName = "events"
+)
+
+type PromMetrics metrics.PromMetrics
@@ -15,6 +15,22 @@ type PromMetrics struct {
 	PolicyChanges              *prom.GaugeVec
 	PolicyRuleExecutionLatency *prom.GaugeVec
 	AdmissionReviewLatency     *prom.GaugeVec
+	InternalQueueDepth         *prom.GaugeVec
+	InternalQueueOldestItemAge *prom.GaugeVec
+	PolicyCacheMisses          *prom.CounterVec
+	PolicyCacheSize            *prom.GaugeVec
+	PolicyCacheKindSize        *prom.GaugeVec
+	PolicyCacheLookups         *prom.CounterVec
+	PolicyCacheLookupLatency   *prom.HistogramVec
+	AdmissionReviewEncodings   *prom.CounterVec
+	CanaryAdmissionSuccess     prom.Gauge
+	CanaryAdmissionLatency     *prom.HistogramVec
+	AdmissionBudgetExceeded    *prom.CounterVec
+	BackgroundScanPaused       prom.Gauge
+	RuleSkippedDeleting        *prom.CounterVec
+	PolicyRuleAdmissionLatency *prom.HistogramVec
+	GenerateRequestsFailed     *prom.CounterVec
+	LeaderElectionLeader       *prom.GaugeVec
 }
 
 func NewPromConfig() *PromConfig {
@@ -86,12 +102,183 @@ func NewPromConfig() *PromConfig {
 		admissionReviewLatency,
 	)
 
+	internalQueueLabels := []string{
+		"queue",
+	}
+	internalQueueDepthMetric := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "kyverno_internal_queue_depth",
+			Help: "can be used to track the number of items waiting in the internal queues of Kyverno, i.e. the report change requests, the update requests and the events.",
+		},
+		internalQueueLabels,
+	)
+	internalQueueOldestItemAgeMetric := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "kyverno_internal_queue_oldest_item_age_seconds",
+			Help: "can be used to track the age (in seconds) of the oldest item waiting in the internal queues of Kyverno, a growing age indicates a backlog.",
+		},
+		internalQueueLabels,
+	)
+
+	policyCacheMissesMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_policy_cache_misses_total",
+			Help: "can be used to track the cached policies that are skipped as the policy listers do not return them, i.e. the deleted policies and the nil lister results.",
+		},
+		[]string{
+			"policy_type", "policy_namespace", "policy_name", "reason",
+		},
+	)
+
+	policyCacheSizeMetric := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "kyverno_policy_cache_policies",
+			Help: "can be used to track the number of policies in the policy cache by policy type, i.e. mutate, validate_enforce, validate_audit, generate and verify_images, including the suspended and disabled policies.",
+		},
+		[]string{
+			"policy_type",
+		},
+	)
+
+	policyCacheKindSizeMetric := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "kyverno_policy_cache_kind_policies",
+			Help: "can be used to track the number of policies in the policy cache by policy type and by the normalized kind they match, e.g. pod, pod/exec, apps/* or *.",
+		},
+		[]string{
+			"policy_type", "kind",
+		},
+	)
+
+	policyCacheLookupsMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_policy_cache_lookups_total",
+			Help: "can be used to track the lookups of the policy cache by policy type, and by result, i.e. hit if policies are returned and miss otherwise.",
+		},
+		[]string{
+			"policy_type", "result",
+		},
+	)
+
+	policyCacheLookupLatencyMetric := prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "kyverno_policy_cache_lookup_latency_milliseconds",
+			Help:    "can be used to track the latencies (in milliseconds) of the lookups of the policy cache by policy type, including the copies of the policies returned by the listers.",
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 100},
+		},
+		[]string{
+			"policy_type",
+		},
+	)
+
+	admissionReviewEncodingsMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_admission_review_encodings_total",
+			Help: "can be used to track the encodings negotiated for the admission reviews, i.e. json, json+gzip, protobuf and protobuf+gzip, of the requests and the responses.",
+		},
+		[]string{
+			"request_encoding", "response_encoding",
+		},
+	)
+
+	canaryAdmissionSuccessMetric := prom.NewGauge(
+		prom.GaugeOpts{
+			Name: "kyverno_canary_admission_success",
+			Help: "can be used to track the result of the last canary admission request, 1 if it was admitted and processed by the Kyverno webhook, 0 if it was rejected, timed out or bypassed the webhook.",
+		},
+	)
+
+	canaryAdmissionLatencyMetric := prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "kyverno_canary_admission_latency_milliseconds",
+			Help:    "can be used to track the end-to-end latencies (in milliseconds) of the canary admission requests, by result, i.e. success, rejected, timeout and bypassed.",
+			Buckets: []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000},
+		},
+		[]string{
+			"result",
+		},
+	)
+
+	admissionBudgetExceededMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_admission_budget_exceeded_total",
+			Help: "can be used to track the admission requests exceeding the policy evaluation budget of the validating webhook, by the exceeded limit, i.e. policies or time, and the overflow action applied to the remaining enforce policies, i.e. deny, allow or none.",
+		},
+		[]string{
+			"resource_kind", "resource_namespace", "resource_request_operation", "budget", "overflow_action",
+		},
+	)
+
+	backgroundScanPausedMetric := prom.NewGauge(
+		prom.GaugeOpts{
+			Name: "kyverno_background_scan_paused",
+			Help: "can be used to track if the background processing is paused in the Kyverno ConfigMap, 1 if the background scans, the generate requests of the existing namespaces and the full reconciliations of the policy reports are paused, 0 otherwise.",
+		},
+	)
+
+	ruleSkippedDeletingMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_policy_rule_skipped_deleting_total",
+			Help: "can be used to track the validate and mutate rules skipped in the admission requests as the resource is being deleted, i.e. has a deletion timestamp, and the rule does not set applyToDeleting.",
+		},
+		[]string{
+			"policy_namespace", "policy_name", "rule_name", "resource_kind", "resource_request_operation",
+		},
+	)
+
+	policyRuleAdmissionLatencyMetric := prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "kyverno_policy_rule_admission_latency_milliseconds",
+			Help:    "can be used to track the latencies (in milliseconds) of the execution of the rules in the admission requests by policy, rule, rule type and request operation, to find the slow policies. The observations carry the correlation ID of the admission request as an exemplar in the OpenMetrics format.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500},
+		},
+		[]string{
+			"policy_namespace", "policy_name", "rule_name", "rule_type", "resource_request_operation",
+		},
+	)
+
+	generateRequestsFailedMetric := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "kyverno_generate_requests_failed_total",
+			Help: "can be used to track the generate requests that failed after the maximum number of retries and are not retried anymore until the policy or the trigger resource changes, by policy and by failure reason, e.g. PermissionDenied or ResourceNotFound.",
+		},
+		[]string{
+			"policy_name", "resource_kind", "resource_namespace", "reason",
+		},
+	)
+
+	leaderElectionLeaderMetric := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "kyverno_leader_election_leader",
+			Help: "can be used to track the leadership of the replicas by lease, e.g. kyverno for the background controllers. 1 means the replica is the leader and runs the controllers of the lease, 0 means it is a follower.",
+		},
+		[]string{
+			"lease",
+		},
+	)
+
 	pc.Metrics = &PromMetrics{
 		PolicyRuleResults:          policyRuleResultsMetric,
 		PolicyRuleInfo:             policyRuleInfoMetric,
 		PolicyChanges:              policyChangesMetric,
 		PolicyRuleExecutionLatency: policyRuleExecutionLatencyMetric,
 		AdmissionReviewLatency:     admissionReviewLatencyMetric,
+		InternalQueueDepth:         internalQueueDepthMetric,
+		InternalQueueOldestItemAge: internalQueueOldestItemAgeMetric,
+		PolicyCacheMisses:          policyCacheMissesMetric,
+		PolicyCacheSize:            policyCacheSizeMetric,
+		PolicyCacheKindSize:        policyCacheKindSizeMetric,
+		PolicyCacheLookups:         policyCacheLookupsMetric,
+		PolicyCacheLookupLatency:   policyCacheLookupLatencyMetric,
+		AdmissionReviewEncodings:   admissionReviewEncodingsMetric,
+		CanaryAdmissionSuccess:     canaryAdmissionSuccessMetric,
+		CanaryAdmissionLatency:     canaryAdmissionLatencyMetric,
+		AdmissionBudgetExceeded:    admissionBudgetExceededMetric,
+		BackgroundScanPaused:       backgroundScanPausedMetric,
+		RuleSkippedDeleting:        ruleSkippedDeletingMetric,
+		PolicyRuleAdmissionLatency: policyRuleAdmissionLatencyMetric,
+		GenerateRequestsFailed:     generateRequestsFailedMetric,
+		LeaderElectionLeader:       leaderElectionLeaderMetric,
 	}
 
 	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyRuleResults)
@@ -99,6 +286,22 @@ func NewPromConfig() *PromConfig {
 	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyChanges)
 	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyRuleExecutionLatency)
 	pc.MetricsRegistry.MustRegister(pc.Metrics.AdmissionReviewLatency)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.InternalQueueDepth)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.InternalQueueOldestItemAge)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyCacheMisses)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyCacheSize)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyCacheKindSize)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyCacheLookups)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyCacheLookupLatency)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.AdmissionReviewEncodings)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.CanaryAdmissionSuccess)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.CanaryAdmissionLatency)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.AdmissionBudgetExceeded)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.BackgroundScanPaused)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.RuleSkippedDeleting)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.PolicyRuleAdmissionLatency)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.GenerateRequestsFailed)
+	pc.MetricsRegistry.MustRegister(pc.Metrics.LeaderElectionLeader)
 
 	return pc
 }
@@ -35,7 +35,7 @@ func (pm PromMetrics) registerPolicyChangesMetric(
 func (pm PromMetrics) RegisterPolicy(policy interface{}, policyChangeType PolicyChangeType, policyChangeTimestamp int64) error {
 	switch inputPolicy := policy.(type) {
 	case *kyverno.ClusterPolicy:
-		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.GetValidationFailureAction())
 		if err != nil {
 			return err
 		}
@@ -48,7 +48,7 @@ func (pm PromMetrics) RegisterPolicy(policy interface{}, policyChangeType Policy
 		}
 		return nil
 	case *kyverno.Policy:
-		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+		policyValidationMode, err := metrics.ParsePolicyValidationMode((*kyverno.ClusterPolicy)(inputPolicy).GetValidationFailureAction())
 		if err != nil {
 			return err
 		}
@@ -55,11 +55,36 @@ func (pm PromMetrics) registerPolicyRuleResultsMetric(
 	return nil
 }
 
+// observePolicyRuleAdmissionLatency observes the execution latency of the rule in an admission request, the
+// correlation ID of the request is attached as an exemplar to find the slow requests in the logs and the events
+func (pm PromMetrics) observePolicyRuleAdmissionLatency(
+	policyNamespace, policyName, ruleName string,
+	ruleType metrics.RuleType,
+	resourceRequestOperation metrics.ResourceRequestOperation,
+	correlationID string,
+	ruleExecutionLatencyInMs float64,
+) {
+	observer := pm.PolicyRuleAdmissionLatency.With(prom.Labels{
+		"policy_namespace":           policyNamespace,
+		"policy_name":                policyName,
+		"rule_name":                  ruleName,
+		"rule_type":                  string(ruleType),
+		"resource_request_operation": string(resourceRequestOperation),
+	})
+
+	if exemplarObserver, ok := observer.(prom.ExemplarObserver); ok && correlationID != "" {
+		exemplarObserver.ObserveWithExemplar(ruleExecutionLatencyInMs, prom.Labels{"correlation_id": correlationID})
+		return
+	}
+
+	observer.Observe(ruleExecutionLatencyInMs)
+}
+
 //policy - policy related data
 //engineResponse - resource and rule related data
 func (pm PromMetrics) ProcessEngineResponse(policy kyverno.ClusterPolicy, engineResponse response.EngineResponse, executionCause metrics.RuleExecutionCause, generateRuleLatencyType string, resourceRequestOperation metrics.ResourceRequestOperation, mainRequestTriggerTimestamp int64) error {
 
-	policyValidationMode, err := metrics.ParsePolicyValidationMode(policy.Spec.ValidationFailureAction)
+	policyValidationMode, err := metrics.ParsePolicyValidationMode(policy.GetValidationFailureAction())
 	if err != nil {
 		return err
 	}
@@ -112,6 +137,10 @@ func (pm PromMetrics) ProcessEngineResponse(policy kyverno.ClusterPolicy, engine
 		); err != nil {
 			return err
 		}
+
+		if executionCause == metrics.AdmissionRequest && pm.PolicyRuleAdmissionLatency != nil {
+			pm.observePolicyRuleAdmissionLatency(policyNamespace, policyName, ruleName, ruleType, resourceRequestOperation, engineResponse.PolicyResponse.CorrelationID, ruleExecutionLatencyInMs)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,80 @@
+package policyruleexecutionlatency
+
+import (
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_PolicyRuleAdmissionLatency(t *testing.T) {
+	promConfig := metrics.NewPromConfig()
+	policy := kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels"},
+		Spec:       kyverno.Spec{ValidationFailureAction: "audit"},
+	}
+	engineResponse := response.EngineResponse{
+		PolicyResponse: response.PolicyResponse{
+			CorrelationID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+			Rules: []response.RuleResponse{
+				{
+					Name:      "check-app-label",
+					Type:      "Validation",
+					Success:   true,
+					RuleStats: response.RuleStats{ProcessingTime: 3 * time.Millisecond},
+				},
+			},
+		},
+	}
+
+	pm := ParsePromMetrics(*promConfig.Metrics)
+	assert.NilError(t, pm.ProcessEngineResponse(policy, engineResponse, metrics.AdmissionRequest, "", metrics.ResourceCreated, 0))
+	// the background scans are not admission requests
+	assert.NilError(t, pm.ProcessEngineResponse(policy, engineResponse, metrics.BackgroundScan, "", metrics.ResourceCreated, 0))
+
+	families, err := promConfig.MetricsRegistry.Gather()
+	assert.NilError(t, err)
+
+	var found bool
+	for _, family := range families {
+		if family.GetName() != "kyverno_policy_rule_admission_latency_milliseconds" {
+			continue
+		}
+
+		found = true
+		assert.Equal(t, len(family.GetMetric()), 1)
+		metric := family.GetMetric()[0]
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.DeepEqual(t, labels, map[string]string{
+			"policy_namespace":           "-",
+			"policy_name":                "require-labels",
+			"rule_name":                  "check-app-label",
+			"rule_type":                  "validate",
+			"resource_request_operation": "create",
+		})
+
+		histogram := metric.GetHistogram()
+		assert.Equal(t, histogram.GetSampleCount(), uint64(1))
+		assert.Equal(t, histogram.GetSampleSum(), float64(3))
+
+		// the correlation ID is attached to the bucket of the observation
+		var exemplars int
+		for _, bucket := range histogram.GetBucket() {
+			if exemplar := bucket.GetExemplar(); exemplar != nil {
+				exemplars++
+				assert.Equal(t, exemplar.GetLabel()[0].GetName(), "correlation_id")
+				assert.Equal(t, exemplar.GetLabel()[0].GetValue(), "705ab4f5-6393-11e8-b7cc-42010a800002")
+				assert.Equal(t, exemplar.GetValue(), float64(3))
+			}
+		}
+		assert.Equal(t, exemplars, 1)
+	}
+	assert.Assert(t, found)
+}
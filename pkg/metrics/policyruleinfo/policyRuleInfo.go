@@ -46,7 +46,7 @@ func (pm PromMetrics) registerPolicyRuleInfoMetric(
 func (pm PromMetrics) AddPolicy(policy interface{}) error {
 	switch inputPolicy := policy.(type) {
 	case *kyverno.ClusterPolicy:
-		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.GetValidationFailureAction())
 		if err != nil {
 			return err
 		}
@@ -65,7 +65,7 @@ func (pm PromMetrics) AddPolicy(policy interface{}) error {
 		}
 		return nil
 	case *kyverno.Policy:
-		policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+		policyValidationMode, err := metrics.ParsePolicyValidationMode((*kyverno.ClusterPolicy)(inputPolicy).GetValidationFailureAction())
 		if err != nil {
 			return err
 		}
@@ -92,7 +92,7 @@ func (pm PromMetrics) RemovePolicy(policy interface{}) error {
 	switch inputPolicy := policy.(type) {
 	case *kyverno.ClusterPolicy:
 		for _, rule := range inputPolicy.Spec.Rules {
-			policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+			policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.GetValidationFailureAction())
 			if err != nil {
 				return err
 			}
@@ -110,7 +110,7 @@ func (pm PromMetrics) RemovePolicy(policy interface{}) error {
 		return nil
 	case *kyverno.Policy:
 		for _, rule := range inputPolicy.Spec.Rules {
-			policyValidationMode, err := metrics.ParsePolicyValidationMode(inputPolicy.Spec.ValidationFailureAction)
+			policyValidationMode, err := metrics.ParsePolicyValidationMode((*kyverno.ClusterPolicy)(inputPolicy).GetValidationFailureAction())
 			if err != nil {
 				return err
 			}
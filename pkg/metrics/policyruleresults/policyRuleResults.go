@@ -53,7 +53,7 @@ func (pm PromMetrics) registerPolicyRuleResultsMetric(
 //engineResponse - resource and rule related data
 func (pm PromMetrics) ProcessEngineResponse(policy kyverno.ClusterPolicy, engineResponse response.EngineResponse, executionCause metrics.RuleExecutionCause, resourceRequestOperation metrics.ResourceRequestOperation, mainRequestTriggerTimestamp int64) error {
 
-	policyValidationMode, err := metrics.ParsePolicyValidationMode(policy.Spec.ValidationFailureAction)
+	policyValidationMode, err := metrics.ParsePolicyValidationMode(policy.GetValidationFailureAction())
 	if err != nil {
 		return err
 	}
@@ -13,6 +13,7 @@ import (
 	client "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/engine"
 	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/resourcecache"
 	"github.com/kyverno/kyverno/pkg/utils"
@@ -21,8 +22,9 @@ import (
 
 // applyPolicy applies policy on a resource
 func applyPolicy(policy kyverno.ClusterPolicy, resource unstructured.Unstructured,
-	logger logr.Logger, excludeGroupRole []string, resCache resourcecache.ResourceCache,
-	client *client.Client, namespaceLabels map[string]string) (responses []*response.EngineResponse) {
+	logger logr.Logger, excludeGroupRole, sensitiveKinds []string, resCache resourcecache.ResourceCache,
+	client *client.Client, namespaceLabels map[string]string, enforcementThreshold string, defaultLocale string,
+	exceptions []*kyverno.PolicyException) (responses []*response.EngineResponse) {
 
 	startTime := time.Now()
 	defer func() {
@@ -54,7 +56,8 @@ func applyPolicy(policy kyverno.ClusterPolicy, resource unstructured.Unstructure
 		logger.Error(err, "unable to add image info to variables context")
 	}
 
-	engineResponseMutation, err = mutation(policy, resource, logger, resCache, ctx, namespaceLabels)
+	// the time windows of the rules are evaluated against the scan time
+	engineResponseMutation, err = mutation(policy, resource, logger, resCache, ctx, namespaceLabels, sensitiveKinds, startTime)
 	if err != nil {
 		logger.Error(err, "failed to process mutation rule")
 	}
@@ -63,10 +66,20 @@ func applyPolicy(policy kyverno.ClusterPolicy, resource unstructured.Unstructure
 		Policy:           policy,
 		NewResource:      resource,
 		ExcludeGroupRole: excludeGroupRole,
+		SensitiveKinds:   sensitiveKinds,
 		ResourceCache:    resCache,
 		JSONContext:      ctx,
 		Client:           client,
 		NamespaceLabels:  namespaceLabels,
+		Time:             startTime,
+		DefaultLocale:    defaultLocale,
+		Exceptions:       exceptions,
+
+		// the reports show the action of the namespace enforcement threshold
+		EnforcementThreshold: enforcementThreshold,
+
+		// the reports show which mode applied to the rules with time windows
+		ReportOutsideTimeWindows: true,
 	}
 
 	engineResponseValidation = engine.Validate(policyCtx)
@@ -75,7 +88,7 @@ func applyPolicy(policy kyverno.ClusterPolicy, resource unstructured.Unstructure
 	return engineResponses
 }
 
-func mutation(policy kyverno.ClusterPolicy, resource unstructured.Unstructured, log logr.Logger, resCache resourcecache.ResourceCache, jsonContext *context.Context, namespaceLabels map[string]string) (*response.EngineResponse, error) {
+func mutation(policy kyverno.ClusterPolicy, resource unstructured.Unstructured, log logr.Logger, resCache resourcecache.ResourceCache, jsonContext *context.Context, namespaceLabels map[string]string, sensitiveKinds []string, scanTime time.Time) (*response.EngineResponse, error) {
 
 	policyContext := &engine.PolicyContext{
 		Policy:          policy,
@@ -83,6 +96,8 @@ func mutation(policy kyverno.ClusterPolicy, resource unstructured.Unstructured,
 		ResourceCache:   resCache,
 		JSONContext:     jsonContext,
 		NamespaceLabels: namespaceLabels,
+		SensitiveKinds:  sensitiveKinds,
+		Time:            scanTime,
 	}
 
 	engineResponse := engine.Mutate(policyContext)
@@ -96,6 +111,9 @@ func mutation(policy kyverno.ClusterPolicy, resource unstructured.Unstructured,
 		log.V(4).Info("resource already satisfies the policy")
 		return engineResponse, nil
 	}
+
+	// the patches are logged on errors
+	log = mask.New(sensitiveKinds, resource, engineResponse.PatchedResource).Logger(log)
 	return getFailedOverallRuleInfo(resource, engineResponse, log)
 }
 
@@ -26,9 +26,15 @@ func ContainsVariablesOtherThanObject(policy kyverno.ClusterPolicy) error {
 		}
 
 		filterVars := []string{"request.object", "request.namespace", "images"}
+		if rule.HasValidateForEach() || rule.HasMutateForEach() {
+			filterVars = append(filterVars, "element")
+		}
+		if rule.HasVerifyImages() {
+			filterVars = append(filterVars, "attestation")
+		}
 		ctx := context.NewContext(filterVars...)
 
-		for _, contextEntry := range rule.Context {
+		for _, contextEntry := range append(append([]kyverno.ContextEntry{}, rule.Context...), rule.ForEachContext()...) {
 			if contextEntry.APICall != nil {
 				ctx.AddBuiltInVars(contextEntry.Name)
 			}
@@ -119,6 +125,9 @@ func userInfoDefined(ui kyverno.UserInfo) string {
 	if len(ui.Subjects) > 0 {
 		return "subjects"
 	}
+	if ui.SubjectsKind != "" {
+		return "subjectsKind"
+	}
 	return ""
 }
 
@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
 	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func Test_Validation_valid_backgroundPolicy(t *testing.T) {
@@ -135,3 +137,43 @@ func Test_Validation_invalid_backgroundPolicy(t *testing.T) {
 	err = ContainsVariablesOtherThanObject(policy)
 	assert.Assert(t, strings.Contains(err.Error(), "variable serviceAccountName cannot be used, allowed variables: [request.object request.namespace images mycm]"))
 }
+
+func Test_Background_Scan_Admission_Match(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "require-team"},
+		"spec": {
+			"rules": [
+				{
+					"name": "check-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {"message": "label 'team' is required", "pattern": {"metadata": {"labels": {"team": "?*"}}}}
+				},
+				{
+					"name": "check-team-update",
+					"match": {"resources": {"kinds": ["Pod"], "operations": ["UPDATE"]}},
+					"validate": {"message": "label 'team' is required", "pattern": {"metadata": {"labels": {"team": "?*"}}}}
+				},
+				{
+					"name": "check-team-not-dry-run",
+					"match": {"resources": {"kinds": ["Pod"], "dryRun": false}},
+					"validate": {"message": "label 'team' is required", "pattern": {"metadata": {"labels": {"team": "?*"}}}}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+	resource, err := utils.ConvertToUnstructured([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"prod"},"spec":{"containers":[{"name":"web","image":"nginx"}]}}`))
+	assert.NilError(t, err)
+
+	// the background scans have no admission request, the rules restricted to operations or dry-run are not applied
+	engineResponses := applyPolicy(policy, *resource, log.Log, nil, nil, nil, nil, nil, "", "", nil)
+	assert.Equal(t, len(engineResponses), 1)
+	rules := engineResponses[0].PolicyResponse.Rules
+	assert.Equal(t, len(rules), 1)
+	assert.Equal(t, rules[0].Name, "check-team")
+	assert.Assert(t, !rules[0].Success)
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
 	"github.com/kyverno/kyverno/pkg/utils"
 	"github.com/minio/pkg/wildcard"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -45,16 +47,22 @@ func transformResource(resource unstructured.Unstructured) []byte {
 func convertPoliciesToClusterPolicies(nsPolicies []*kyverno.Policy) []*kyverno.ClusterPolicy {
 	var cpols []*kyverno.ClusterPolicy
 	for _, pol := range nsPolicies {
-		cpol := kyverno.ClusterPolicy(*pol)
-		cpols = append(cpols, &cpol)
+		if cpol := ConvertPolicyToClusterPolicy(pol); cpol != nil {
+			cpols = append(cpols, cpol)
+		}
 	}
 	return cpols
 }
 
 // ConvertPolicyToClusterPolicy - convert Policy to ClusterPolicy
+// The namespace, labels and annotations are kept, and the kind remains Policy, see Policy.AsClusterPolicy.
+// It returns nil for a nil Policy.
 func ConvertPolicyToClusterPolicy(nsPolicies *kyverno.Policy) *kyverno.ClusterPolicy {
-	cpol := kyverno.ClusterPolicy(*nsPolicies)
-	return &cpol
+	if nsPolicies == nil {
+		return nil
+	}
+
+	return nsPolicies.AsClusterPolicy()
 }
 
 func ParseNamespacedPolicy(key string) (string, string, bool) {
@@ -196,7 +204,7 @@ func (pc *PolicyController) getResourceList(kind, namespace string, labelSelecto
 }
 
 // GetResourcesPerNamespace ...
-func (pc *PolicyController) getResourcesPerNamespace(kind string, namespace string, rule kyverno.Rule, log logr.Logger) map[string]unstructured.Unstructured {
+func (pc *PolicyController) getResourcesPerNamespace(kind string, namespace string, rule kyverno.Rule, scanTime time.Time, log logr.Logger) map[string]unstructured.Unstructured {
 	resourceMap := map[string]unstructured.Unstructured{}
 
 	if kind == "Namespace" {
@@ -207,13 +215,13 @@ func (pc *PolicyController) getResourcesPerNamespace(kind string, namespace stri
 	switch typedList := list.(type) {
 	case []*unstructured.Unstructured:
 		for _, r := range typedList {
-			if pc.match(*r, rule) {
+			if pc.match(*r, rule, scanTime) {
 				resourceMap[string(r.GetUID())] = *r
 			}
 		}
 	case *unstructured.UnstructuredList:
 		for _, r := range typedList.Items {
-			if pc.match(r, rule) {
+			if pc.match(r, rule, scanTime) {
 				resourceMap[string(r.GetUID())] = r
 			}
 		}
@@ -224,8 +232,9 @@ func (pc *PolicyController) getResourcesPerNamespace(kind string, namespace stri
 	return resourceMap
 }
 
-func (pc *PolicyController) match(r unstructured.Unstructured, rule kyverno.Rule) bool {
-	if r.GetDeletionTimestamp() != nil {
+// match returns true if the resource is matched by the rule at the scan time
+func (pc *PolicyController) match(r unstructured.Unstructured, rule kyverno.Rule, scanTime time.Time) bool {
+	if engine.SkipsDeletingResource(rule, r, "") {
 		return false
 	}
 
@@ -246,6 +255,11 @@ func (pc *PolicyController) match(r unstructured.Unstructured, rule kyverno.Rule
 		return false
 	}
 
+	// the resources younger than the minimum age are matched in a later scan
+	if ok, _ := engine.MatchesMinimumAge(r, rule.MatchResources.MinimumAge, scanTime); !ok {
+		return false
+	}
+
 	return true
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
 )
@@ -56,6 +57,13 @@ func validateMap(patternMap map[string]interface{}, path string, supportedAnchor
 				}
 			}
 		}
+
+		// the keys of the resources do not contain parentheses, such a key is a mistyped anchor,
+		// e.g. "=(name" or "^ (name)"
+		if !matched && len(supportedAnchors) > 0 && strings.ContainsAny(key, "()") {
+			return path + "/" + key, fmt.Errorf("Malformed anchor %s, anchors are written as (key), =(key), ^(key), X(key), +(key) or <(key) without spaces", key)
+		}
+
 		// lets validate the values now :)
 		if errPath, err := ValidatePattern(value, path+"/"+key, supportedAnchors); err != nil {
 			return errPath, err
@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+)
+
+func Test_ConvertPolicyToClusterPolicy(t *testing.T) {
+	assert.Assert(t, ConvertPolicyToClusterPolicy(nil) == nil)
+
+	// the Policies returned by the listers have an empty TypeMeta
+	var nsPolicy kyverno.Policy
+	assert.NilError(t, json.Unmarshal([]byte(`{
+		"metadata": {
+			"name": "require-labels",
+			"namespace": "team-a",
+			"labels": {"owner": "team-a"},
+			"annotations": {"policies.kyverno.io/category": "Best Practices"}
+		},
+		"spec": {"rules": [{"name": "check-labels", "match": {"resources": {"kinds": ["Pod"]}}}]}
+	}`), &nsPolicy))
+
+	policy := ConvertPolicyToClusterPolicy(&nsPolicy)
+	assert.Equal(t, policy.Kind, "Policy")
+	assert.Equal(t, policy.APIVersion, "kyverno.io/v1")
+	assert.Equal(t, policy.GetName(), "require-labels")
+	assert.Equal(t, policy.GetNamespace(), "team-a")
+	assert.DeepEqual(t, policy.GetLabels(), map[string]string{"owner": "team-a"})
+	assert.DeepEqual(t, policy.GetAnnotations(), map[string]string{"policies.kyverno.io/category": "Best Practices"})
+	assert.Equal(t, len(policy.Spec.Rules), 1)
+
+	// the source Policy is not changed
+	assert.Equal(t, nsPolicy.Kind, "")
+
+	// a Policy with minimal metadata
+	policy = ConvertPolicyToClusterPolicy(&kyverno.Policy{})
+	assert.Equal(t, policy.Kind, "Policy")
+	assert.Equal(t, len(policy.Spec.Rules), 0)
+
+	// the nil Policies are skipped
+	policies := convertPoliciesToClusterPolicies([]*kyverno.Policy{nil, &nsPolicy})
+	assert.Equal(t, len(policies), 1)
+	assert.Equal(t, policies[0].GetNamespace(), "team-a")
+}
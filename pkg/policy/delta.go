@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+// scanDelta is the part of a policy to re-evaluate in the background scan. A nil delta is a full scan
+// of the policy, otherwise only the changed rules are scanned: the resources of the kinds and namespaces
+// matched by the changed rules are re-evaluated, the report results of the other resources are untouched.
+type scanDelta struct {
+	rules map[string]bool
+}
+
+// includes checks if the rule is scanned
+func (d *scanDelta) includes(rule string) bool {
+	return d == nil || d.rules[rule]
+}
+
+// kinds returns the distinct kinds matched by the scanned rules of the policy
+func (d *scanDelta) kinds(policy *kyverno.ClusterPolicy) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, rule := range policy.Spec.Rules {
+		if !rule.HasValidate() || !d.includes(rule.Name) {
+			continue
+		}
+
+		for _, kind := range rule.MatchResources.Kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	sort.Strings(kinds)
+	return kinds
+}
+
+// changedRules returns the rules of the current policy that are added or changed by the update.
+// A change of the spec outside of the rules, e.g. the background mode or the failure action,
+// affects all rules and returns full.
+func changedRules(old, cur *kyverno.ClusterPolicy) (rules map[string]bool, full bool) {
+	oldSpec, curSpec := old.Spec, cur.Spec
+	oldSpec.Rules, curSpec.Rules = nil, nil
+	if !reflect.DeepEqual(oldSpec, curSpec) {
+		return nil, true
+	}
+
+	oldRules := make(map[string]kyverno.Rule, len(old.Spec.Rules))
+	for _, rule := range old.Spec.Rules {
+		oldRules[rule.Name] = rule
+	}
+
+	rules = make(map[string]bool)
+	for _, rule := range cur.Spec.Rules {
+		if oldRule, ok := oldRules[rule.Name]; !ok || !reflect.DeepEqual(oldRule, rule) {
+			rules[rule.Name] = true
+		}
+	}
+
+	return rules, false
+}
+
+// scanDeltas holds the pending deltas of the queued policies. The deltas of the updates queued before the
+// policy is synced are merged, and a full scan supersedes the deltas.
+type scanDeltas struct {
+	mutex  sync.Mutex
+	deltas map[string]*scanDelta
+}
+
+func newScanDeltas() *scanDeltas {
+	return &scanDeltas{deltas: make(map[string]*scanDelta)}
+}
+
+// full records a full scan of the policy
+func (s *scanDeltas) full(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deltas[key] = nil
+}
+
+// add records a scan of the rules of the policy, merged with the pending delta
+func (s *scanDeltas) add(key string, rules map[string]bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delta, ok := s.deltas[key]
+	if ok && delta == nil {
+		return
+	}
+
+	if !ok {
+		delta = &scanDelta{rules: make(map[string]bool)}
+		s.deltas[key] = delta
+	}
+
+	for rule := range rules {
+		delta.rules[rule] = true
+	}
+}
+
+// take returns and removes the pending delta of the policy, a policy without pending delta is fully scanned
+func (s *scanDeltas) take(key string) *scanDelta {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delta := s.deltas[key]
+	delete(s.deltas, key)
+	return delta
+}
+
+// forget removes the pending delta of a deleted policy
+func (s *scanDeltas) forget(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.deltas, key)
+}
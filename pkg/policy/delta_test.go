@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var deltaPolicy = []byte(`{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {"name": "require-labels"},
+	"spec": {
+		"validationFailureAction": "audit",
+		"background": true,
+		"rules": [
+			{
+				"name": "pod-team",
+				"match": {"resources": {"kinds": ["Pod"]}},
+				"validate": {"message": "label 'team' is required", "pattern": {"metadata": {"labels": {"team": "?*"}}}}
+			},
+			{
+				"name": "configmap-owner",
+				"match": {"resources": {"kinds": ["ConfigMap"]}},
+				"validate": {"message": "label 'owner' is required", "pattern": {"metadata": {"labels": {"owner": "?*"}}}}
+			}
+		]
+	}
+}`)
+
+func newDeltaPolicy(t testing.TB) *kyverno.ClusterPolicy {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(deltaPolicy, &policy))
+	return &policy
+}
+
+// updatePodRule changes the pattern of the Pod-only rule
+func updatePodRule(policy *kyverno.ClusterPolicy) *kyverno.ClusterPolicy {
+	cur := policy.DeepCopy()
+	cur.Spec.Rules[0].Validation.Message = "label 'team' is required on pods"
+	return cur
+}
+
+func Test_ChangedRules(t *testing.T) {
+	old := newDeltaPolicy(t)
+
+	// updating the Pod-only rule scans the pods only
+	rules, full := changedRules(old, updatePodRule(old))
+	assert.Assert(t, !full)
+	assert.DeepEqual(t, rules, map[string]bool{"pod-team": true})
+	assert.DeepEqual(t, (&scanDelta{rules: rules}).kinds(old), []string{"Pod"})
+
+	// an added rule is scanned, a removed rule is not
+	cur := old.DeepCopy()
+	cur.Spec.Rules[1].Name = "configmap-team"
+	rules, full = changedRules(old, cur)
+	assert.Assert(t, !full)
+	assert.DeepEqual(t, rules, map[string]bool{"configmap-team": true})
+
+	// no rule changed
+	rules, full = changedRules(old, old.DeepCopy())
+	assert.Assert(t, !full)
+	assert.Equal(t, len(rules), 0)
+
+	// a change outside of the rules affects all rules
+	cur = old.DeepCopy()
+	cur.Spec.ValidationFailureAction = "enforce"
+	_, full = changedRules(old, cur)
+	assert.Assert(t, full)
+
+	var delta *scanDelta
+	assert.DeepEqual(t, delta.kinds(old), []string{"ConfigMap", "Pod"})
+}
+
+func Test_ScanDeltas(t *testing.T) {
+	scans := newScanDeltas()
+
+	// the deltas of the updates are merged until the policy is synced
+	scans.add("require-labels", map[string]bool{"pod-team": true})
+	scans.add("require-labels", map[string]bool{"configmap-owner": true})
+	delta := scans.take("require-labels")
+	assert.Assert(t, delta != nil)
+	assert.DeepEqual(t, delta.rules, map[string]bool{"pod-team": true, "configmap-owner": true})
+
+	// a policy without pending delta is fully scanned
+	assert.Assert(t, scans.take("require-labels") == nil)
+
+	// a full scan supersedes the deltas
+	scans.add("require-labels", map[string]bool{"pod-team": true})
+	scans.full("require-labels")
+	scans.add("require-labels", map[string]bool{"configmap-owner": true})
+	assert.Assert(t, scans.take("require-labels") == nil)
+
+	// the delta of a deleted policy is dropped
+	scans.add("require-labels", map[string]bool{"pod-team": true})
+	scans.forget("require-labels")
+	assert.Assert(t, scans.take("require-labels") == nil)
+}
+
+// newScanResources returns the synthetic resources of the background scan, the pods are a tenth of the resources
+func newScanResources(pods, configMaps int) map[string][]unstructured.Unstructured {
+	resources := make(map[string][]unstructured.Unstructured)
+	for kind, count := range map[string]int{"Pod": pods, "ConfigMap": configMaps} {
+		for i := 0; i < count; i++ {
+			resources[kind] = append(resources[kind], unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       kind,
+				"metadata": map[string]interface{}{
+					"name":      fmt.Sprintf("%s-%d", kind, i),
+					"namespace": "default",
+					"labels":    map[string]interface{}{"team": "payments"},
+				},
+			}})
+		}
+	}
+
+	return resources
+}
+
+// scan applies the policy to the resources matched by the rules in the delta like processExistingResources,
+// the results replace the results of the scanned resources and the number of evaluations is returned
+func scan(t testing.TB, policy *kyverno.ClusterPolicy, delta *scanDelta, resources map[string][]unstructured.Unstructured, results map[string]*response.EngineResponse) int {
+	evaluations := 0
+	for _, kind := range delta.kinds(policy) {
+		for _, resource := range resources[kind] {
+			ctx := context.NewContext()
+			assert.NilError(t, ctx.AddResource(transformResource(resource)))
+			results[kind+"/"+resource.GetName()] = engine.Validate(&engine.PolicyContext{Policy: *policy, NewResource: resource, JSONContext: ctx})
+			evaluations++
+		}
+	}
+
+	return evaluations
+}
+
+func Test_DeltaScan_PodOnlyUpdate(t *testing.T) {
+	old := newDeltaPolicy(t)
+	resources := newScanResources(10, 90)
+	results := make(map[string]*response.EngineResponse)
+	assert.Equal(t, scan(t, old, nil, resources, results), 100)
+
+	configMapResults := make(map[string]*response.EngineResponse)
+	for key, result := range results {
+		if result.PolicyResponse.Resource.Kind == "ConfigMap" {
+			configMapResults[key] = result
+		}
+	}
+	assert.Equal(t, len(configMapResults), 90)
+
+	// updating the Pod-only rule re-evaluates the pods only
+	cur := updatePodRule(old)
+	rules, full := changedRules(old, cur)
+	assert.Assert(t, !full)
+	assert.Equal(t, scan(t, cur, &scanDelta{rules: rules}, resources, results), 10)
+
+	// the ConfigMap results are untouched
+	for key, result := range configMapResults {
+		assert.Assert(t, results[key] == result, key)
+	}
+
+	for key, result := range results {
+		if result.PolicyResponse.Resource.Kind == "Pod" {
+			assert.Equal(t, result.PolicyResponse.Rules[0].Message, "validation rule 'pod-team' passed.", key)
+		}
+	}
+}
+
+func benchmarkScan(b *testing.B, partial bool) {
+	old := newDeltaPolicy(b)
+	cur := updatePodRule(old)
+	resources := newScanResources(100, 900)
+
+	var delta *scanDelta
+	if partial {
+		rules, _ := changedRules(old, cur)
+		delta = &scanDelta{rules: rules}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scan(b, cur, delta, resources, make(map[string]*response.EngineResponse))
+	}
+}
+
+// BenchmarkScan_Full and BenchmarkScan_Delta compare the scans after the update of the Pod-only rule,
+// the delta scan takes about a tenth of the full scan
+func BenchmarkScan_Full(b *testing.B) {
+	benchmarkScan(b, false)
+}
+
+func BenchmarkScan_Delta(b *testing.B) {
+	benchmarkScan(b, true)
+}
@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"reflect"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// policyReasonDisabled is the Disabled condition reason for policies with spec.enabled set to "false"
+	policyReasonDisabled = "PolicyDisabled"
+
+	// policyReasonEnabled is the event reason reported when a disabled policy is enabled again
+	policyReasonEnabled = "PolicyEnabled"
+)
+
+// setDisabledCondition sets or removes the Disabled condition according to the enabled flag.
+// It returns true if the status changed.
+func setDisabledCondition(status *kyverno.PolicyStatus, enabled bool) bool {
+	old := status.DeepCopy()
+
+	if enabled {
+		meta.RemoveStatusCondition(&status.Conditions, kyverno.PolicyConditionDisabled)
+	} else {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kyverno.PolicyConditionDisabled,
+			Status:  metav1.ConditionTrue,
+			Reason:  policyReasonDisabled,
+			Message: "policy is disabled by spec.enabled",
+		})
+	}
+
+	if len(status.Conditions) == 0 {
+		status.Conditions = nil
+	}
+
+	return !reflect.DeepEqual(*old, *status)
+}
+
+// enqueuePolicyStatus queues the policy to reconcile its Disabled condition
+func (pc *PolicyController) enqueuePolicyStatus(policy *kyverno.ClusterPolicy) {
+	key, err := cache.MetaNamespaceKeyFunc(policy)
+	if err != nil {
+		pc.log.Error(err, "failed to enqueue policy")
+		return
+	}
+	pc.statusQueue.Add(key)
+}
+
+func (pc *PolicyController) statusWorker() {
+	for pc.processNextPolicyStatus() {
+	}
+}
+
+func (pc *PolicyController) processNextPolicyStatus() bool {
+	key, quit := pc.statusQueue.Get()
+	if quit {
+		return false
+	}
+	defer pc.statusQueue.Done(key)
+
+	err := pc.syncEnabledCondition(key.(string))
+	if err == nil {
+		pc.statusQueue.Forget(key)
+		return true
+	}
+
+	if pc.statusQueue.NumRequeues(key) < maxRetries {
+		pc.log.Error(err, "failed to sync policy status", "key", key)
+		pc.statusQueue.AddRateLimited(key)
+		return true
+	}
+
+	utilruntime.HandleError(err)
+	pc.log.V(2).Info("dropping policy status out of queue", "key", key)
+	pc.statusQueue.Forget(key)
+	return true
+}
+
+// syncEnabledCondition reconciles the Disabled condition of the policy of the key and reports
+// an event on the policy when it is disabled or enabled again
+func (pc *PolicyController) syncEnabledCondition(key string) error {
+	p, err := pc.getPolicy(key)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if p == nil {
+		return nil
+	}
+
+	logger := pc.log.WithValues("namespace", p.GetNamespace(), "name", p.GetName())
+
+	policy := p.DeepCopy()
+	if !setDisabledCondition(&policy.Status, policy.IsEnabled()) {
+		return nil
+	}
+
+	obj, err := common.UpdatePolicyStatus(pc.kyvernoClient, policy)
+	if err != nil {
+		return err
+	}
+
+	if policy.IsEnabled() {
+		logger.Info("policy enabled")
+		pc.eventRecorder.Event(obj, v1.EventTypeNormal, policyReasonEnabled, "policy is enabled")
+	} else {
+		logger.Info("policy disabled")
+		pc.eventRecorder.Event(obj, v1.EventTypeNormal, policyReasonDisabled, "policy is disabled")
+	}
+
+	return nil
+}
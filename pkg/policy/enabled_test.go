@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	lv1 "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_Set_Disabled_Condition(t *testing.T) {
+	status := &kyverno.PolicyStatus{}
+
+	// enabled policies have no condition
+	assert.Assert(t, !setDisabledCondition(status, true))
+	assert.Assert(t, status.Conditions == nil)
+
+	// disable
+	assert.Assert(t, setDisabledCondition(status, false))
+	assert.Assert(t, meta.IsStatusConditionTrue(status.Conditions, kyverno.PolicyConditionDisabled))
+	assert.Assert(t, !setDisabledCondition(status, false))
+
+	// enable, other conditions are kept
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:   kyverno.PolicyConditionSuspended,
+		Status: metav1.ConditionTrue,
+		Reason: policySetReasonDisabled,
+	})
+	assert.Assert(t, setDisabledCondition(status, true))
+	assert.Assert(t, meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionDisabled) == nil)
+	assert.Equal(t, len(status.Conditions), 1)
+}
+
+func Test_Enqueue_Policy_Status(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pc := &PolicyController{
+		pLister:     lv1.NewClusterPolicyLister(indexer),
+		npLister:    lv1.NewPolicyLister(nsIndexer),
+		statusQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "policystatus"),
+		log:         log.Log,
+	}
+	defer pc.statusQueue.ShutDown()
+
+	policy := &kyverno.ClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: "require-labels"}}
+	assert.NilError(t, indexer.Add(policy))
+	nsPolicy := &kyverno.Policy{ObjectMeta: metav1.ObjectMeta{Name: "require-team", Namespace: "team-a"}}
+	assert.NilError(t, nsIndexer.Add(nsPolicy))
+
+	// the status is reconciled by the worker, the event handlers only queue the policies
+	pc.enqueuePolicyStatus(policy)
+	pc.enqueuePolicyStatus(ConvertPolicyToClusterPolicy(nsPolicy))
+	pc.enqueuePolicyStatus(policy)
+	assert.Equal(t, pc.statusQueue.Len(), 2)
+
+	for _, expected := range []string{"require-labels", "team-a/require-team"} {
+		key, _ := pc.statusQueue.Get()
+		assert.Equal(t, key, expected)
+
+		// the enabled policies without a Disabled condition are not updated
+		assert.NilError(t, pc.syncEnabledCondition(key.(string)))
+		pc.statusQueue.Done(key)
+	}
+
+	// the deleted policies are skipped
+	assert.NilError(t, pc.syncEnabledCondition("require-owner"))
+	assert.NilError(t, pc.syncEnabledCondition("team-a/require-owner"))
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,15 +18,23 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func (pc *PolicyController) processExistingResources(policy *kyverno.ClusterPolicy, backgroundScanTimestamp int64) {
+// processExistingResources applies the policy to the existing resources matched by the rules in the delta,
+// a nil delta applies the policy to the resources matched by all rules
+func (pc *PolicyController) processExistingResources(policy *kyverno.ClusterPolicy, delta *scanDelta, backgroundScanTimestamp int64) {
 	logger := pc.log.WithValues("policy", policy.Name)
-	logger.V(4).Info("applying policy to existing resources")
+	logger.V(4).Info("applying policy to existing resources", "full", delta == nil, "kinds", delta.kinds(policy))
+
+	// the rules using fields unknown to this version are not applied
+	if len(policy.Status.UnsupportedRules) > 0 {
+		policy = policy.DeepCopy()
+		policy.RemoveUnsupportedRules()
+	}
 
 	// Parse through all the resources drops the cache after configured rebuild time
 	pc.rm.Drop()
 
 	for _, rule := range policy.Spec.Rules {
-		if !rule.HasValidate() {
+		if !rule.HasValidate() || !delta.includes(rule.Name) {
 			continue
 		}
 
@@ -45,6 +54,10 @@ func (pc *PolicyController) processExistingResources(policy *kyverno.ClusterPoli
 			metricRegisteredTracker := false
 
 			if !namespaced {
+				if !pc.backgroundGate.Wait() {
+					return
+				}
+
 				pc.applyAndReportPerNamespace(policy, k, "", rule, logger.WithValues("kind", k), backgroundScanTimestamp, &metricRegisteredTracker)
 				continue
 			}
@@ -54,6 +67,11 @@ func (pc *PolicyController) processExistingResources(policy *kyverno.ClusterPoli
 				// for kind: Policy, consider only the namespace which the policy belongs to.
 				// for kind: ClusterPolicy, consider all the namespaces.
 				if policy.Namespace == ns || policy.Namespace == "" {
+					// a paused scan continues with this namespace once resumed
+					if !pc.backgroundGate.Wait() {
+						return
+					}
+
 					pc.applyAndReportPerNamespace(policy, k, ns, rule, logger.WithValues("kind", k).WithValues("ns", ns), backgroundScanTimestamp, &metricRegisteredTracker)
 				}
 			}
@@ -73,29 +91,62 @@ func (pc *PolicyController) registerResource(gvk string) (err error) {
 }
 
 func (pc *PolicyController) applyAndReportPerNamespace(policy *kyverno.ClusterPolicy, kind string, ns string, rule kyverno.Rule, logger logr.Logger, backgroundScanTimestamp int64, metricAlreadyRegistered *bool) {
-	rMap := pc.getResourcesPerNamespace(kind, ns, rule, logger)
+	rMap := pc.getResourcesPerNamespace(kind, ns, rule, time.Unix(backgroundScanTimestamp, 0), logger)
 	excludeAutoGenResources(*policy, rMap, logger)
 	if len(rMap) == 0 {
 		return
 	}
 
-	var engineResponses []*response.EngineResponse
-	for _, resource := range rMap {
-		responses := pc.applyPolicy(policy, resource, logger)
-		engineResponses = append(engineResponses, responses...)
+	for i, chunk := range chunkResources(rMap, pc.scanOptions.ChunkSize) {
+		// a paused scan continues with the next chunk once resumed
+		if i > 0 && !pc.backgroundGate.Wait() {
+			return
+		}
+
+		var engineResponses []*response.EngineResponse
+		for _, resource := range chunk {
+			responses := pc.applyPolicy(policy, resource, logger)
+			engineResponses = append(engineResponses, responses...)
+		}
+
+		if !*metricAlreadyRegistered && len(engineResponses) > 0 {
+			for _, engineResponse := range engineResponses {
+				// registering the kyverno_policy_rule_results_info metric concurrently
+				go pc.registerPolicyRuleResultsMetricValidationBS(logger, *policy, *engineResponse, backgroundScanTimestamp)
+				// registering the kyverno_policy_rule_execution_latency_milliseconds metric concurrently
+				go pc.registerPolicyRuleExecutionLatencyMetricValidateBS(logger, *policy, *engineResponse, backgroundScanTimestamp)
+			}
+			*metricAlreadyRegistered = true
+		}
+
+		pc.report(engineResponses, logger)
 	}
+}
 
-	if !*metricAlreadyRegistered && len(engineResponses) > 0 {
-		for _, engineResponse := range engineResponses {
-			// registering the kyverno_policy_rule_results_info metric concurrently
-			go pc.registerPolicyRuleResultsMetricValidationBS(logger, *policy, *engineResponse, backgroundScanTimestamp)
-			// registering the kyverno_policy_rule_execution_latency_milliseconds metric concurrently
-			go pc.registerPolicyRuleExecutionLatencyMetricValidateBS(logger, *policy, *engineResponse, backgroundScanTimestamp)
+// chunkResources splits the resources into chunks of at most size resources, sorted by namespace
+// and name. The resources are returned in a single chunk if the size is 0.
+func chunkResources(resources map[string]unstructured.Unstructured, size int) [][]unstructured.Unstructured {
+	sorted := make([]unstructured.Unstructured, 0, len(resources))
+	for _, resource := range resources {
+		sorted = append(sorted, resource)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].GetNamespace() != sorted[j].GetNamespace() {
+			return sorted[i].GetNamespace() < sorted[j].GetNamespace()
 		}
-		*metricAlreadyRegistered = true
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	if size <= 0 || len(sorted) <= size {
+		return [][]unstructured.Unstructured{sorted}
 	}
 
-	pc.report(engineResponses, logger)
+	var chunks [][]unstructured.Unstructured
+	for len(sorted) > size {
+		chunks = append(chunks, sorted[:size])
+		sorted = sorted[size:]
+	}
+	return append(chunks, sorted)
 }
 
 func (pc *PolicyController) registerPolicyRuleResultsMetricValidationBS(logger logr.Logger, policy kyverno.ClusterPolicy, engineResponse response.EngineResponse, backgroundScanTimestamp int64) {
@@ -117,7 +168,9 @@ func (pc *PolicyController) applyPolicy(policy *kyverno.ClusterPolicy, resource
 	}
 
 	namespaceLabels := common.GetNamespaceSelectorsFromNamespaceLister(resource.GetKind(), resource.GetNamespace(), pc.nsLister, logger)
-	engineResponse := applyPolicy(*policy, resource, logger, pc.configHandler.GetExcludeGroupRole(), pc.resCache, pc.client, namespaceLabels)
+	enforcementThreshold := common.GetNamespaceEnforcementThreshold(resource.GetKind(), resource.GetNamespace(), pc.nsLister, logger)
+	exceptions := common.GetPolicyExceptions(resource.GetNamespace(), pc.pexLister, logger)
+	engineResponse := applyPolicy(*policy, resource, logger, pc.configHandler.GetExcludeGroupRole(), pc.configHandler.GetSensitiveKinds(), pc.resCache, pc.client, namespaceLabels, enforcementThreshold, pc.configHandler.GetDefaultLocale(), exceptions)
 	engineResponses = append(engineResponses, engineResponse...)
 
 	// post-processing, register the resource as processed
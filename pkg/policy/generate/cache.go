@@ -0,0 +1,120 @@
+package generate
+
+import (
+	"sync"
+	"time"
+)
+
+// accessCacheTTL is the time the result of an access check is reused. The checks of the
+// policies admitted in a burst, e.g. by a GitOps sync, share the SelfSubjectAccessReviews,
+// a change of the Kyverno RBAC is observed by the policies admitted after the TTL.
+const accessCacheTTL = 10 * time.Second
+
+// sharedAccessCache is shared by the generate checkers of all the policy admissions
+var sharedAccessCache = newAccessCache(accessCacheTTL)
+
+type accessKey struct {
+	verb      string
+	kind      string
+	namespace string
+}
+
+type accessResult struct {
+	allowed bool
+	expires time.Time
+}
+
+// accessCache stores the results of the access checks for a short time
+type accessCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	results map[accessKey]accessResult
+	now     func() time.Time
+}
+
+func newAccessCache(ttl time.Duration) *accessCache {
+	return &accessCache{
+		ttl:     ttl,
+		results: make(map[accessKey]accessResult),
+		now:     time.Now,
+	}
+}
+
+func (c *accessCache) get(key accessKey) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, ok := c.results[key]
+	if !ok {
+		return false, false
+	}
+
+	if c.now().After(result.expires) {
+		delete(c.results, key)
+		return false, false
+	}
+
+	return result.allowed, true
+}
+
+func (c *accessCache) set(key accessKey, allowed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, result := range c.results {
+		if now.After(result.expires) {
+			delete(c.results, k)
+		}
+	}
+
+	c.results[key] = accessResult{allowed: allowed, expires: now.Add(c.ttl)}
+}
+
+// cachedAuth reuses the results of the access checks of the operations, the failed checks are not cached
+type cachedAuth struct {
+	operations Operations
+	cache      *accessCache
+}
+
+func newCachedAuth(operations Operations, cache *accessCache) *cachedAuth {
+	return &cachedAuth{
+		operations: operations,
+		cache:      cache,
+	}
+}
+
+func (a *cachedAuth) canI(verb, kind, namespace string, check func(kind, namespace string) (bool, error)) (bool, error) {
+	key := accessKey{verb: verb, kind: kind, namespace: namespace}
+	if allowed, ok := a.cache.get(key); ok {
+		return allowed, nil
+	}
+
+	allowed, err := check(kind, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	a.cache.set(key, allowed)
+	return allowed, nil
+}
+
+// CanICreate returns 'true' if self can 'create' resource
+func (a *cachedAuth) CanICreate(kind, namespace string) (bool, error) {
+	return a.canI("create", kind, namespace, a.operations.CanICreate)
+}
+
+// CanIUpdate returns 'true' if self can 'update' resource
+func (a *cachedAuth) CanIUpdate(kind, namespace string) (bool, error) {
+	return a.canI("update", kind, namespace, a.operations.CanIUpdate)
+}
+
+// CanIDelete returns 'true' if self can 'delete' resource
+func (a *cachedAuth) CanIDelete(kind, namespace string) (bool, error) {
+	return a.canI("delete", kind, namespace, a.operations.CanIDelete)
+}
+
+// CanIGet returns 'true' if self can 'get' resource
+func (a *cachedAuth) CanIGet(kind, namespace string) (bool, error) {
+	return a.canI("get", kind, namespace, a.operations.CanIGet)
+}
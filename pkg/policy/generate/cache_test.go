@@ -0,0 +1,136 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// apiCallLatency simulates the round trip of a call to the API server
+const apiCallLatency = 2 * time.Millisecond
+
+// countingAuth answers the access checks like a SelfSubjectAccessReview, each check resolves the
+// kind with the discovery client and creates a review, i.e. makes two calls to the API server
+type countingAuth struct {
+	denied   map[string]bool
+	failures int
+	latency  time.Duration
+	calls    int
+}
+
+func (a *countingAuth) canI(verb, kind string) (bool, error) {
+	a.calls += 2
+	time.Sleep(a.latency)
+	if a.failures > 0 {
+		a.failures--
+		return false, errors.New("the server is currently unable to handle the request")
+	}
+	return !a.denied[verb+"/"+kind], nil
+}
+
+func (a *countingAuth) CanICreate(kind, namespace string) (bool, error) {
+	return a.canI("create", kind)
+}
+
+func (a *countingAuth) CanIUpdate(kind, namespace string) (bool, error) {
+	return a.canI("update", kind)
+}
+
+func (a *countingAuth) CanIDelete(kind, namespace string) (bool, error) {
+	return a.canI("delete", kind)
+}
+
+func (a *countingAuth) CanIGet(kind, namespace string) (bool, error) {
+	return a.canI("get", kind)
+}
+
+func Test_cachedAuth(t *testing.T) {
+	operations := &countingAuth{denied: map[string]bool{"delete/Secret": true}}
+	cache := newAccessCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	auth := newCachedAuth(operations, cache)
+
+	for i := 0; i < 3; i++ {
+		ok, err := auth.CanICreate("Secret", "default")
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+
+		ok, err = auth.CanIDelete("Secret", "default")
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	}
+	assert.Equal(t, operations.calls, 4)
+
+	// the checks of other verbs, kinds and namespaces are not shared
+	_, _ = auth.CanIGet("Secret", "default")
+	_, _ = auth.CanICreate("ConfigMap", "default")
+	_, _ = auth.CanICreate("Secret", "kube-system")
+	assert.Equal(t, operations.calls, 10)
+
+	// the results expire after the TTL
+	now = now.Add(2 * time.Minute)
+	_, _ = auth.CanICreate("Secret", "default")
+	assert.Equal(t, operations.calls, 12)
+}
+
+func Test_cachedAuth_Failure(t *testing.T) {
+	operations := &countingAuth{failures: 1}
+	auth := newCachedAuth(operations, newAccessCache(time.Minute))
+
+	_, err := auth.CanICreate("Secret", "default")
+	assert.ErrorContains(t, err, "unable to handle the request")
+
+	ok, err := auth.CanICreate("Secret", "default")
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, operations.calls, 4)
+}
+
+// syncPolicies simulates a GitOps sync that admits the policies sequentially, each admission
+// validates the generate rule of the policy
+func syncPolicies(b *testing.B, policies int, newAuth func() Operations) {
+	kinds := []string{"NetworkPolicy", "ConfigMap", "ResourceQuota", "LimitRange"}
+	for i := 0; i < policies; i++ {
+		g := Generate{
+			rule: kyverno.Generation{
+				ResourceSpec: kyverno.ResourceSpec{Kind: kinds[i%len(kinds)], Name: fmt.Sprintf("generated-%d", i), Namespace: "default"},
+				Clone:        kyverno.CloneFrom{Namespace: "default", Name: "source"},
+			},
+			authCheck: newAuth(),
+			log:       log.Log,
+		}
+
+		if _, err := g.Validate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkValidate_GitOpsSync compares the API calls and the wall time of the validation of
+// 80 policies admitted in one sync, with and without the shared access cache
+func BenchmarkValidate_GitOpsSync(b *testing.B) {
+	const policies = 80
+
+	b.Run("uncached", func(b *testing.B) {
+		operations := &countingAuth{latency: apiCallLatency}
+		for i := 0; i < b.N; i++ {
+			syncPolicies(b, policies, func() Operations { return operations })
+		}
+		b.ReportMetric(float64(operations.calls)/float64(b.N), "apicalls/sync")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		operations := &countingAuth{latency: apiCallLatency}
+		for i := 0; i < b.N; i++ {
+			cache := newAccessCache(accessCacheTTL)
+			syncPolicies(b, policies, func() Operations { return newCachedAuth(operations, cache) })
+		}
+		b.ReportMetric(float64(operations.calls)/float64(b.N), "apicalls/sync")
+	})
+}
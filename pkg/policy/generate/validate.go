@@ -6,10 +6,12 @@ import (
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	pkgcommon "github.com/kyverno/kyverno/pkg/common"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
 	"github.com/kyverno/kyverno/pkg/engine/variables"
 	"github.com/kyverno/kyverno/pkg/policy/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Generate provides implementation to validate 'generate' rule
@@ -26,7 +28,7 @@ type Generate struct {
 func NewGenerateFactory(client *dclient.Client, rule kyverno.Generation, log logr.Logger) *Generate {
 	g := Generate{
 		rule:      rule,
-		authCheck: NewAuth(client, log),
+		authCheck: newCachedAuth(NewAuth(client, log), sharedAccessCache),
 		log:       log,
 	}
 
@@ -40,6 +42,21 @@ func (g *Generate) Validate() (string, error) {
 		return "", fmt.Errorf("only one of data or clone can be specified")
 	}
 
+	if !reflect.DeepEqual(rule.CloneList, kyverno.CloneList{}) {
+		if rule.Data != nil || rule.Clone != (kyverno.CloneFrom{}) {
+			return "", fmt.Errorf("only one of data, clone or cloneList can be specified")
+		}
+
+		if rule.Namespace == "" {
+			return "namespace", fmt.Errorf("namespace cannot be empty")
+		}
+
+		if path, err := g.validateCloneList(rule.CloneList, rule.Namespace); err != nil {
+			return fmt.Sprintf("cloneList.%s", path), err
+		}
+		return "", nil
+	}
+
 	kind, name, namespace := rule.Kind, rule.Name, rule.Namespace
 
 	if name == "" {
@@ -96,6 +113,41 @@ func (g *Generate) validateClone(c kyverno.CloneFrom, kind string) (string, erro
 	return "", nil
 }
 
+// validateCloneList checks the source namespace, the kinds and the selector of the cloneList,
+// and that kyverno can read the sources and generate their copies
+func (g *Generate) validateCloneList(c kyverno.CloneList, namespace string) (string, error) {
+	if c.Namespace == "" {
+		return "namespace", fmt.Errorf("namespace cannot be empty")
+	}
+
+	if len(c.Kinds) == 0 {
+		return "kinds", fmt.Errorf("kinds cannot be empty")
+	}
+
+	if c.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(c.Selector); err != nil {
+			return "selector", fmt.Errorf("invalid selector: %v", err)
+		}
+	}
+
+	for i, gvk := range c.Kinds {
+		_, kind := pkgcommon.GetKindFromGVK(gvk)
+		if kind == "" {
+			return fmt.Sprintf("kinds[%d]", i), fmt.Errorf("kind cannot be empty")
+		}
+
+		if path, err := g.validateClone(kyverno.CloneFrom{Namespace: c.Namespace, Name: "*"}, kind); err != nil {
+			return path, err
+		}
+
+		if err := g.canIGenerate(kind, namespace); err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
 //canIGenerate returns a error if kyverno cannot perform operations
 func (g *Generate) canIGenerate(kind, namespace string) error {
 	// Skip if there is variable defined
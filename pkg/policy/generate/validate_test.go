@@ -87,3 +87,32 @@ func Test_Validate_Generate_HasAnchors(t *testing.T) {
 		assert.Assert(t, err != nil)
 	}
 }
+
+func Test_Validate_Generate_CloneList(t *testing.T) {
+	testcases := []struct {
+		generate string
+		path     string
+		err      string
+	}{
+		{generate: `{"namespace": "{{request.object.metadata.name}}", "synchronize": true, "cloneList": {"namespace": "default", "kinds": ["ConfigMap", "v1/Secret"], "selector": {"matchLabels": {"app": "game"}}}}`},
+		{generate: `{"namespace": "team-a", "cloneList": {"kinds": ["ConfigMap"]}}`, path: "cloneList.namespace", err: "namespace cannot be empty"},
+		{generate: `{"namespace": "team-a", "cloneList": {"namespace": "default"}}`, path: "cloneList.kinds", err: "kinds cannot be empty"},
+		{generate: `{"namespace": "team-a", "cloneList": {"namespace": "default", "kinds": ["ConfigMap"], "selector": {"matchExpressions": [{"key": "app", "operator": "Matches"}]}}}`, path: "cloneList.selector", err: "invalid selector"},
+		{generate: `{"cloneList": {"namespace": "default", "kinds": ["ConfigMap"]}}`, path: "namespace", err: "namespace cannot be empty"},
+		{generate: `{"namespace": "team-a", "clone": {"namespace": "default", "name": "game"}, "cloneList": {"namespace": "default", "kinds": ["ConfigMap"]}}`, err: "only one of data, clone or cloneList can be specified"},
+	}
+
+	for _, tc := range testcases {
+		var genRule kyverno.Generation
+		assert.NilError(t, json.Unmarshal([]byte(tc.generate), &genRule))
+
+		path, err := NewFakeGenerate(genRule).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.generate)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
@@ -0,0 +1,234 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// policyReasonKindNotFound is the KindNotFound condition and event reason
+	policyReasonKindNotFound = "KindNotFound"
+
+	// kindNotFoundResyncPeriod is the period to re-check the policies with unknown kinds
+	kindNotFoundResyncPeriod = time.Minute
+)
+
+// kindSet holds the kinds of the API resources known to the API server
+type kindSet struct {
+	// kinds stores the kind, plural and singular names of the resources
+	kinds map[string]bool
+
+	// groupVersionKinds stores the names qualified with the group version, e.g. "apps/v1/Deployment"
+	groupVersionKinds map[string]bool
+
+	// groupKinds stores the names qualified with the group, used for any version, e.g. "apps/*/Deployment"
+	groupKinds map[string]bool
+
+	// failedGroups stores the groups that could not be discovered, their kinds are considered known
+	failedGroups map[string]bool
+}
+
+// discoverKinds returns the kinds known to the API server. Groups that fail discovery,
+// e.g. an unavailable metrics server, do not fail the discovery of the other groups.
+func discoverKinds(dc discovery.ServerResourcesInterface) (*kindSet, error) {
+	_, resourceLists, err := dc.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	set := &kindSet{
+		kinds:             make(map[string]bool),
+		groupVersionKinds: make(map[string]bool),
+		groupKinds:        make(map[string]bool),
+		failedGroups:      make(map[string]bool),
+	}
+
+	if err != nil {
+		for gv := range err.(*discovery.ErrGroupDiscoveryFailed).Groups {
+			set.failedGroups[gv.Group] = true
+		}
+	}
+
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, resource := range resourceList.APIResources {
+			// skip the sub-resources like deployments/status
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+
+			for _, name := range []string{resource.Kind, resource.Name, resource.SingularName} {
+				if name == "" {
+					continue
+				}
+
+				set.kinds[name] = true
+				set.groupVersionKinds[resourceList.GroupVersion+"/"+name] = true
+				set.groupKinds[gv.Group+"/"+name] = true
+			}
+		}
+	}
+
+	return set, nil
+}
+
+// has checks if the kind is known. The kind can be qualified with a version, e.g. "v1/Pod",
+// with a group and version, e.g. "apps/v1/Deployment", or with a subresource, e.g. "pods/exec".
+func (s *kindSet) has(gvk string) bool {
+	// the subresource is not checked, only its parent kind
+	apiVersion, kind, _ := common.ParseKind(gvk)
+	if kind == "*" {
+		return true
+	}
+
+	if apiVersion == "" || apiVersion == "*" {
+		return s.kinds[kind]
+	}
+
+	group, version := "", apiVersion
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		group, version = apiVersion[:i], apiVersion[i+1:]
+	}
+
+	if s.failedGroups[group] {
+		return true
+	}
+
+	if version == "*" {
+		return s.groupKinds[group+"/"+kind]
+	}
+
+	return s.groupVersionKinds[apiVersion+"/"+kind]
+}
+
+// unknownKinds returns the distinct match kinds of the policy rules that are not known to the API server
+func unknownKinds(policy *kyverno.ClusterPolicy, known *kindSet) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, rule := range policy.Spec.Rules {
+		for _, kind := range rule.MatchResources.Kinds {
+			if seen[kind] {
+				continue
+			}
+
+			seen[kind] = true
+			if !known.has(kind) {
+				unknown = append(unknown, kind)
+			}
+		}
+	}
+
+	return unknown
+}
+
+// setKindNotFoundCondition sets or removes the KindNotFound condition according to the unknown kinds.
+// It returns true if the status changed.
+func setKindNotFoundCondition(status *kyverno.PolicyStatus, unknown []string) bool {
+	old := status.DeepCopy()
+
+	if len(unknown) == 0 {
+		meta.RemoveStatusCondition(&status.Conditions, kyverno.PolicyConditionKindNotFound)
+	} else {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kyverno.PolicyConditionKindNotFound,
+			Status:  metav1.ConditionTrue,
+			Reason:  policyReasonKindNotFound,
+			Message: kindNotFoundMessage(unknown),
+		})
+	}
+
+	if len(status.Conditions) == 0 {
+		status.Conditions = nil
+	}
+
+	return !reflect.DeepEqual(*old, *status)
+}
+
+func kindNotFoundMessage(unknown []string) string {
+	return fmt.Sprintf("the policy rules match kinds that are not found in the cluster: %s", strings.Join(unknown, ", "))
+}
+
+// syncKindNotFoundCondition checks the match kinds of the policy rules against the API resources
+// known to the API server, and reports a warning event on the policy when kinds are not found
+func (pc *PolicyController) syncKindNotFoundCondition(p *kyverno.ClusterPolicy) {
+	logger := pc.log.WithValues("namespace", p.GetNamespace(), "name", p.GetName())
+
+	discoveryCache := pc.client.DiscoveryClient.DiscoveryCache()
+	known, err := discoverKinds(discoveryCache)
+	if err != nil {
+		logger.Error(err, "failed to discover API resources, skipping the check of matched kinds")
+		return
+	}
+
+	unknown := unknownKinds(p, known)
+	if len(unknown) > 0 && !discoveryCache.Fresh() {
+		// the kinds may be installed since the discovery cache was populated, e.g. CRDs
+		discoveryCache.Invalidate()
+		if known, err = discoverKinds(discoveryCache); err != nil {
+			logger.Error(err, "failed to discover API resources, skipping the check of matched kinds")
+			return
+		}
+		unknown = unknownKinds(p, known)
+	}
+
+	policy := p.DeepCopy()
+	if !setKindNotFoundCondition(&policy.Status, unknown) {
+		return
+	}
+
+	obj, err := common.UpdatePolicyStatus(pc.kyvernoClient, policy)
+	if err != nil {
+		logger.Error(err, "failed to update policy status")
+		return
+	}
+
+	if len(unknown) > 0 {
+		logger.Info("policy rules match unknown kinds", "kinds", unknown)
+		pc.eventRecorder.Event(obj, v1.EventTypeWarning, policyReasonKindNotFound, kindNotFoundMessage(unknown))
+	} else {
+		logger.Info("all kinds matched by the policy rules are found")
+	}
+}
+
+// resyncKindNotFoundConditions re-checks the policies with unknown kinds,
+// the condition is cleared when the kinds are installed, e.g. by a CRD
+func (pc *PolicyController) resyncKindNotFoundConditions() {
+	policies, err := pc.pLister.List(labels.Everything())
+	if err != nil {
+		pc.log.Error(err, "failed to list policies")
+		return
+	}
+
+	for _, policy := range policies {
+		if policy != nil && meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionKindNotFound) != nil {
+			pc.syncKindNotFoundCondition(policy)
+		}
+	}
+
+	nsPolicies, err := pc.npLister.List(labels.Everything())
+	if err != nil {
+		pc.log.Error(err, "failed to list namespaced policies")
+		return
+	}
+
+	for _, nsPolicy := range nsPolicies {
+		if nsPolicy != nil && meta.FindStatusCondition(nsPolicy.Status.Conditions, kyverno.PolicyConditionKindNotFound) != nil {
+			pc.syncKindNotFoundCondition(ConvertPolicyToClusterPolicy(nsPolicy))
+		}
+	}
+}
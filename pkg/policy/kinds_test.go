@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newKindsTestDiscovery(resources ...*metav1.APIResourceList) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}}
+}
+
+var coreResources = &metav1.APIResourceList{
+	GroupVersion: "v1",
+	APIResources: []metav1.APIResource{
+		{Name: "pods", SingularName: "pod", Kind: "Pod"},
+		{Name: "pods/exec", Kind: "PodExecOptions"},
+		{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap"},
+	},
+}
+
+var appsResources = &metav1.APIResourceList{
+	GroupVersion: "apps/v1",
+	APIResources: []metav1.APIResource{
+		{Name: "deployments", SingularName: "deployment", Kind: "Deployment"},
+	},
+}
+
+var crdResources = &metav1.APIResourceList{
+	GroupVersion: "cert-manager.io/v1",
+	APIResources: []metav1.APIResource{
+		{Name: "certificates", SingularName: "certificate", Kind: "Certificate"},
+	},
+}
+
+func newKindsTestPolicy(kinds ...string) *kyverno.ClusterPolicy {
+	return &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels"},
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{Name: "check-labels", MatchResources: kyverno.MatchResources{ResourceDescription: kyverno.ResourceDescription{Kinds: kinds}}},
+				{Name: "check-team", MatchResources: kyverno.MatchResources{ResourceDescription: kyverno.ResourceDescription{Kinds: kinds}}},
+			},
+		},
+	}
+}
+
+func Test_Unknown_Kinds(t *testing.T) {
+	known, err := discoverKinds(newKindsTestDiscovery(coreResources, appsResources))
+	assert.NilError(t, err)
+
+	tests := map[string]bool{
+		"Pod":                 true,
+		"pods":                true,
+		"v1/Pod":              true,
+		"Deployment":          true,
+		"apps/v1/Deployment":  true,
+		"apps/*/Deployment":   true,
+		"pods/exec":           true,
+		"*":                   true,
+		"*/Pod":               true,
+		"apps/*":              true,
+		"v1/pods/exec":        true,
+		"Deplyoment":          false,
+		"apps/v2/Deployment":  false,
+		"batch/v1/Deployment": false,
+		"v1/Deployment":       false,
+		"Certificate":         false,
+	}
+
+	for kind, expected := range tests {
+		assert.Equal(t, known.has(kind), expected, kind)
+	}
+
+	// kinds are reported once, in rule order
+	assert.DeepEqual(t, unknownKinds(newKindsTestPolicy("Pod", "Deplyoment", "cert-manager.io/v1/Certificate"), known), []string{"Deplyoment", "cert-manager.io/v1/Certificate"})
+	assert.Assert(t, unknownKinds(newKindsTestPolicy("Pod", "apps/v1/Deployment"), known) == nil)
+
+	// the kind is known once the CRD is installed
+	known, err = discoverKinds(newKindsTestDiscovery(coreResources, appsResources, crdResources))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, unknownKinds(newKindsTestPolicy("Pod", "Deplyoment", "cert-manager.io/v1/Certificate"), known), []string{"Deplyoment"})
+}
+
+func Test_Set_Kind_Not_Found_Condition(t *testing.T) {
+	status := &kyverno.PolicyStatus{}
+
+	// all kinds are known
+	assert.Assert(t, !setKindNotFoundCondition(status, nil))
+	assert.Assert(t, status.Conditions == nil)
+
+	// unknown kinds
+	assert.Assert(t, setKindNotFoundCondition(status, []string{"Deplyoment", "Certificate"}))
+	condition := meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionKindNotFound)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "KindNotFound")
+	assert.Equal(t, condition.Message, "the policy rules match kinds that are not found in the cluster: Deplyoment, Certificate")
+	assert.Assert(t, !setKindNotFoundCondition(status, []string{"Deplyoment", "Certificate"}))
+
+	// the CRD is installed
+	assert.Assert(t, setKindNotFoundCondition(status, []string{"Deplyoment"}))
+	assert.Equal(t, meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionKindNotFound).Message, "the policy rules match kinds that are not found in the cluster: Deplyoment")
+
+	// the typo is fixed, other conditions are kept
+	setDisabledCondition(status, false)
+	assert.Assert(t, setKindNotFoundCondition(status, nil))
+	assert.Assert(t, meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionKindNotFound) == nil)
+	assert.Equal(t, len(status.Conditions), 1)
+}
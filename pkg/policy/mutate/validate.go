@@ -3,9 +3,11 @@ package mutate
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
+	"github.com/kyverno/kyverno/pkg/engine/jmespath"
 	"github.com/kyverno/kyverno/pkg/policy/common"
 )
 
@@ -41,6 +43,36 @@ func (m *Mutate) Validate() (string, error) {
 			return path, err
 		}
 	}
+
+	if len(rule.ForEachMutation) > 0 {
+		if rule.Overlay != nil || len(rule.Patches) != 0 || rule.PatchStrategicMerge != nil || rule.PatchesJSON6902 != "" {
+			return "foreach", errors.New("foreach cannot be combined with overlay, patches, patchStrategicMerge or patchesJson6902")
+		}
+
+		for i, foreach := range rule.ForEachMutation {
+			if path, err := validateForEach(foreach); err != nil {
+				return fmt.Sprintf("foreach[%d].%s", i, path), err
+			}
+		}
+	}
+	return "", nil
+}
+
+// validateForEach checks the list and the patch of the foreach declaration
+func validateForEach(foreach kyverno.ForEachMutation) (string, error) {
+	if foreach.List == "" {
+		return "list", errors.New("list must be specified")
+	}
+
+	list := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(foreach.List), "{{"), "}}"))
+	if _, err := jmespath.New(list); err != nil {
+		return "list", fmt.Errorf("invalid JMESPath %s: %v", foreach.List, err)
+	}
+
+	if foreach.PatchStrategicMerge == nil {
+		return "patchStrategicMerge", errors.New("patchStrategicMerge must be specified")
+	}
+
 	return "", nil
 }
 
@@ -149,3 +149,31 @@ func Test_Validate_Mutate_Unsupported(t *testing.T) {
 		assert.Assert(t, err != nil)
 	}
 }
+
+func Test_Validate_Mutate_ForEach(t *testing.T) {
+	testcases := []struct {
+		mutate string
+		path   string
+		err    string
+	}{
+		{mutate: `{"foreach": [{"list": "request.object.spec.containers", "patchStrategicMerge": {"spec": {"containers": [{"name": "{{element.name}}", "imagePullPolicy": "Always"}]}}}]}`},
+		{mutate: `{"foreach": [{"patchStrategicMerge": {"metadata": {"labels": {"app": "web"}}}}]}`, path: "foreach[0].list", err: "list must be specified"},
+		{mutate: `{"foreach": [{"list": "request.object.spec.containers[", "patchStrategicMerge": {"metadata": {"labels": {"app": "web"}}}}]}`, path: "foreach[0].list", err: "invalid JMESPath"},
+		{mutate: `{"foreach": [{"list": "request.object.spec.containers"}]}`, path: "foreach[0].patchStrategicMerge", err: "patchStrategicMerge must be specified"},
+		{mutate: `{"foreach": [{"list": "request.object.spec.containers", "patchStrategicMerge": {}}], "patchStrategicMerge": {}}`, path: "foreach", err: "foreach cannot be combined"},
+	}
+
+	for _, tc := range testcases {
+		var mutate kyverno.Mutation
+		assert.NilError(t, json.Unmarshal([]byte(tc.mutate), &mutate))
+
+		path, err := NewMutateFactory(mutate).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.mutate)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
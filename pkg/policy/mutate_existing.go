@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// policyReasonMutateExistingSucceeded is the MutateExisting condition reason when all the matching resources are patched
+	policyReasonMutateExistingSucceeded = "MutateExistingSucceeded"
+
+	// policyReasonMutateExistingFailed is the MutateExisting condition reason when some matching resources are not patched
+	policyReasonMutateExistingFailed = "MutateExistingFailed"
+)
+
+// mutateExistingResult counts the existing resources processed by the mutate rules of a policy
+type mutateExistingResult struct {
+	// matched is the number of resources matched by the mutate rules
+	matched int
+
+	// mutated is the number of resources patched
+	mutated int
+
+	// failed is the number of resources whose mutation failed, err is the first failure
+	failed int
+	err    error
+}
+
+// fail counts a failed resource
+func (r *mutateExistingResult) fail(err error) {
+	r.failed++
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// mutateExistingPending checks if the mutate rules of the current generation of the policy are not yet
+// applied to the existing resources, the background scans do not patch the resources again
+func mutateExistingPending(policy *kyverno.ClusterPolicy) bool {
+	if !policy.MutateExistingOnPolicyUpdate() {
+		return false
+	}
+
+	condition := meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionMutateExisting)
+	return condition == nil || condition.ObservedGeneration != policy.GetGeneration()
+}
+
+// mutateExistingResources applies the mutate rules in the delta to the existing resources they match,
+// and reports the result in the MutateExisting condition of the policy
+func (pc *PolicyController) mutateExistingResources(policy *kyverno.ClusterPolicy, delta *scanDelta) {
+	logger := pc.log.WithValues("policy", policy.Name)
+
+	mutatePolicy := policy.DeepCopy()
+	if len(mutatePolicy.Status.UnsupportedRules) > 0 {
+		mutatePolicy.RemoveUnsupportedRules()
+	}
+
+	var rules []kyverno.Rule
+	for _, rule := range mutatePolicy.Spec.Rules {
+		if rule.HasMutate() && delta.includes(rule.Name) {
+			rules = append(rules, rule)
+		}
+	}
+	mutatePolicy.Spec.Rules = rules
+	logger.V(2).Info("mutating existing resources", "rules", len(rules))
+
+	result := &mutateExistingResult{}
+	processed := make(map[string]bool)
+	for _, rule := range rules {
+		for _, k := range rule.MatchResources.Kinds {
+			namespaced, err := pc.rm.GetScope(k)
+			if err != nil {
+				if err := pc.registerResource(k); err != nil {
+					logger.Error(err, "failed to find resource", "kind", k)
+					continue
+				}
+
+				namespaced, _ = pc.rm.GetScope(k)
+			}
+
+			namespaces := []string{""}
+			if namespaced {
+				namespaces = pc.getNamespacesForRule(&rule, logger.WithValues("kind", k))
+			}
+
+			for _, ns := range namespaces {
+				if namespaced && policy.Namespace != "" && policy.Namespace != ns {
+					continue
+				}
+
+				if !pc.backgroundGate.Wait() {
+					return
+				}
+
+				rMap := pc.getResourcesPerNamespace(k, ns, rule, time.Now(), logger)
+				excludeAutoGenResources(*mutatePolicy, rMap, logger)
+				for uid, resource := range rMap {
+					// a resource matched by several rules is patched once by all of them
+					if processed[uid] {
+						continue
+					}
+
+					processed[uid] = true
+					pc.mutateExistingResource(mutatePolicy, resource, result, logger)
+				}
+			}
+		}
+	}
+
+	pc.syncMutateExistingCondition(policy, result)
+}
+
+// mutateExistingResource patches the resource with the mutate rules of the policy, and reports an event on
+// the resource when it is patched or when the mutation fails
+func (pc *PolicyController) mutateExistingResource(policy *kyverno.ClusterPolicy, resource unstructured.Unstructured, result *mutateExistingResult, logger logr.Logger) {
+	logger = logger.WithValues("kind", resource.GetKind(), "namespace", resource.GetNamespace(), "name", resource.GetName())
+
+	ctx := context.NewContext()
+	if err := ctx.AddResource(transformResource(resource)); err != nil {
+		logger.Error(err, "failed to add resource to ctx")
+		return
+	}
+
+	if err := ctx.AddNamespace(resource.GetNamespace()); err != nil {
+		logger.Error(err, "failed to add namespace to ctx")
+	}
+
+	if err := ctx.AddImageInfo(&resource); err != nil {
+		logger.Error(err, "unable to add image info to variables context")
+	}
+
+	policyContext := &engine.PolicyContext{
+		Policy:           *policy,
+		NewResource:      resource,
+		ExcludeGroupRole: pc.configHandler.GetExcludeGroupRole(),
+		SensitiveKinds:   pc.configHandler.GetSensitiveKinds(),
+		ResourceCache:    pc.resCache,
+		JSONContext:      ctx,
+		Client:           pc.client,
+		NamespaceLabels:  common.GetNamespaceSelectorsFromNamespaceLister(resource.GetKind(), resource.GetNamespace(), pc.nsLister, logger),
+		Time:             time.Now(),
+	}
+
+	engineResponse := engine.Mutate(policyContext)
+	if len(engineResponse.PolicyResponse.Rules) == 0 {
+		return
+	}
+
+	result.matched++
+	if !engineResponse.IsSuccessful() {
+		var messages []string
+		for _, rule := range engineResponse.PolicyResponse.Rules {
+			if !rule.Success {
+				messages = append(messages, fmt.Sprintf("%s: %s", rule.Name, rule.Message))
+			}
+		}
+
+		err := fmt.Errorf("failed to mutate %s %s/%s: %s", resource.GetKind(), resource.GetNamespace(), resource.GetName(), strings.Join(messages, "; "))
+		logger.V(2).Info("failed to mutate the existing resource", "rules", engineResponse.GetFailedRules())
+		result.fail(err)
+		pc.eventGen.Add(mutateExistingEvent(policy, resource, event.PolicyFailed, fmt.Sprintf("policy %s failed to mutate the existing resource: %s", policy.Name, strings.Join(messages, "; "))))
+		return
+	}
+
+	patches := engineResponse.GetPatches()
+	if len(patches) == 0 {
+		logger.V(4).Info("resource already satisfies the policy")
+		return
+	}
+
+	if _, err := pc.client.PatchResource(resource.GetAPIVersion(), resource.GetKind(), resource.GetNamespace(), resource.GetName(), utils.JoinPatches(patches)); err != nil {
+		logger.Error(err, "failed to patch the existing resource")
+		result.fail(fmt.Errorf("failed to patch %s %s/%s: %v", resource.GetKind(), resource.GetNamespace(), resource.GetName(), err))
+		pc.eventGen.Add(mutateExistingEvent(policy, resource, event.PolicyFailed, fmt.Sprintf("policy %s failed to patch the existing resource: %v", policy.Name, err)))
+		return
+	}
+
+	var mutatedRules []string
+	for _, rule := range engineResponse.PolicyResponse.Rules {
+		if len(rule.Patches) > 0 {
+			mutatedRules = append(mutatedRules, rule.Name)
+		}
+	}
+
+	result.mutated++
+	logger.V(2).Info("mutated the existing resource", "rules", mutatedRules)
+	pc.eventGen.Add(mutateExistingEvent(policy, resource, event.PolicyApplied, fmt.Sprintf("policy %s mutated the existing resource with the rules %s", policy.Name, strings.Join(mutatedRules, ", "))))
+}
+
+func mutateExistingEvent(policy *kyverno.ClusterPolicy, resource unstructured.Unstructured, reason event.Reason, message string) event.Info {
+	return event.Info{
+		Kind:      resource.GetKind(),
+		Namespace: resource.GetNamespace(),
+		Name:      resource.GetName(),
+		Reason:    reason.String(),
+		Source:    event.PolicyController,
+		Message:   message,
+	}
+}
+
+// setMutateExistingCondition sets the MutateExisting condition with the result of the mutation of the
+// existing resources by the generation of the policy
+func setMutateExistingCondition(status *kyverno.PolicyStatus, generation int64, result *mutateExistingResult) {
+	condition := metav1.Condition{
+		Type:               kyverno.PolicyConditionMutateExisting,
+		Status:             metav1.ConditionTrue,
+		Reason:             policyReasonMutateExistingSucceeded,
+		ObservedGeneration: generation,
+		Message:            fmt.Sprintf("%d of %d matching resources mutated", result.mutated, result.matched),
+	}
+
+	if result.failed > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = policyReasonMutateExistingFailed
+		condition.Message = fmt.Sprintf("%d of %d matching resources mutated, %d failed: %v", result.mutated, result.matched, result.failed, result.err)
+	}
+
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// syncMutateExistingCondition updates the MutateExisting condition of the policy and reports an event on the policy
+func (pc *PolicyController) syncMutateExistingCondition(p *kyverno.ClusterPolicy, result *mutateExistingResult) {
+	logger := pc.log.WithValues("namespace", p.GetNamespace(), "name", p.GetName())
+
+	policy := p.DeepCopy()
+	setMutateExistingCondition(&policy.Status, policy.GetGeneration(), result)
+	obj, err := common.UpdatePolicyStatus(pc.kyvernoClient, policy)
+	if err != nil {
+		logger.Error(err, "failed to update policy status")
+		return
+	}
+
+	condition := meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionMutateExisting)
+	logger.Info("mutated existing resources", "matched", result.matched, "mutated", result.mutated, "failed", result.failed)
+	if result.failed > 0 {
+		pc.eventRecorder.Event(obj, v1.EventTypeWarning, condition.Reason, condition.Message)
+	} else {
+		pc.eventRecorder.Event(obj, v1.EventTypeNormal, condition.Reason, condition.Message)
+	}
+}
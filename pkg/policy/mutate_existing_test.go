@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_MutateExisting_Pending(t *testing.T) {
+	policy := &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "add-labels", Generation: 1},
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{Name: "add-labels", Mutation: kyverno.Mutation{PatchStrategicMerge: map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}}}}},
+			},
+		},
+	}
+
+	// the existing resources are not mutated by default
+	assert.Assert(t, !mutateExistingPending(policy))
+
+	policy.Spec.MutateExistingOnPolicyUpdate = true
+	assert.Assert(t, mutateExistingPending(policy))
+
+	// the generation is processed once
+	setMutateExistingCondition(&policy.Status, policy.GetGeneration(), &mutateExistingResult{matched: 2, mutated: 1})
+	assert.Assert(t, !mutateExistingPending(policy))
+
+	policy.SetGeneration(2)
+	assert.Assert(t, mutateExistingPending(policy))
+}
+
+func Test_Set_MutateExisting_Condition(t *testing.T) {
+	status := &kyverno.PolicyStatus{}
+
+	setMutateExistingCondition(status, 1, &mutateExistingResult{matched: 3, mutated: 2})
+	condition := meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionMutateExisting)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, policyReasonMutateExistingSucceeded)
+	assert.Equal(t, condition.ObservedGeneration, int64(1))
+	assert.Equal(t, condition.Message, "2 of 3 matching resources mutated")
+
+	result := &mutateExistingResult{matched: 3, mutated: 1}
+	result.fail(errors.New("failed to patch Pod default/web"))
+	result.fail(errors.New("failed to patch Pod default/db"))
+	setMutateExistingCondition(status, 2, result)
+	condition = meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionMutateExisting)
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, policyReasonMutateExistingFailed)
+	assert.Equal(t, condition.ObservedGeneration, int64(2))
+	assert.Equal(t, condition.Message, "1 of 3 matching resources mutated, 2 failed: failed to patch Pod default/web")
+	assert.Equal(t, len(status.Conditions), 1)
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// policySetKey is the single work queue key used to reconcile policy sets.
+// Membership is resolved across all sets at once, so events are coalesced into one key.
+const policySetKey = "policysets"
+
+// policySetReasonDisabled is the Suspended condition reason for members of a disabled PolicySet
+const policySetReasonDisabled = "PolicySetDisabled"
+
+func (pc *PolicyController) addPolicySet(obj interface{}) {
+	ps := obj.(*kyverno.PolicySet)
+	pc.log.V(4).Info("policy set created", "name", ps.Name)
+	pc.enqueuePolicySets()
+}
+
+func (pc *PolicyController) updatePolicySet(old, cur interface{}) {
+	oldPS := old.(*kyverno.PolicySet)
+	curPS := cur.(*kyverno.PolicySet)
+	if reflect.DeepEqual(oldPS.Spec, curPS.Spec) {
+		return
+	}
+
+	pc.log.V(4).Info("policy set updated", "name", curPS.Name)
+	pc.enqueuePolicySets()
+}
+
+func (pc *PolicyController) deletePolicySet(obj interface{}) {
+	ps, ok := obj.(*kyverno.PolicySet)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			pc.log.Info("couldn't get object from tombstone", "obj", obj)
+			return
+		}
+
+		ps, ok = tombstone.Obj.(*kyverno.PolicySet)
+		if !ok {
+			pc.log.Info("tombstone container object that is not a policy set", "obj", obj)
+			return
+		}
+	}
+
+	pc.log.V(4).Info("policy set deleted", "name", ps.Name)
+	pc.enqueuePolicySets()
+}
+
+func (pc *PolicyController) enqueuePolicySets() {
+	pc.psQueue.Add(policySetKey)
+}
+
+func (pc *PolicyController) policySetWorker() {
+	for pc.processNextPolicySet() {
+	}
+}
+
+func (pc *PolicyController) processNextPolicySet() bool {
+	key, quit := pc.psQueue.Get()
+	if quit {
+		return false
+	}
+	defer pc.psQueue.Done(key)
+
+	err := pc.syncPolicySets()
+	if err == nil {
+		pc.psQueue.Forget(key)
+		return true
+	}
+
+	if pc.psQueue.NumRequeues(key) < maxRetries {
+		pc.log.Error(err, "failed to sync policy sets")
+		pc.psQueue.AddRateLimited(key)
+		return true
+	}
+
+	utilruntime.HandleError(err)
+	pc.log.V(2).Info("dropping policy sets out of queue")
+	pc.psQueue.Forget(key)
+	return true
+}
+
+// syncPolicySets resolves the members of all policy sets and reconciles
+// the status of member policies and policy sets
+func (pc *PolicyController) syncPolicySets() error {
+	logger := pc.log.WithName("syncPolicySets")
+	startTime := time.Now()
+	logger.V(4).Info("started syncing policy sets", "startTime", startTime)
+	defer func() {
+		logger.V(4).Info("finished syncing policy sets", "processingTime", time.Since(startTime).String())
+	}()
+
+	sets, err := pc.psLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	policies, err := pc.pLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	owners := resolvePolicySetMembers(sets, policies, logger)
+
+	var lastErr error
+	for _, p := range policies {
+		policy := p.DeepCopy()
+		if !applyPolicySet(policy, owners[policy.GetName()]) {
+			continue
+		}
+
+		logger.V(3).Info("updating policy set status of policy", "policy", policy.GetName(), "policySet", policy.Status.PolicySet)
+		if _, err := pc.kyvernoClient.KyvernoV1().ClusterPolicies().UpdateStatus(context.TODO(), policy, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update policy status", "policy", policy.GetName())
+			lastErr = err
+		}
+	}
+
+	members := make(map[string][]string)
+	for policyName, ps := range owners {
+		members[ps.GetName()] = append(members[ps.GetName()], policyName)
+	}
+
+	for _, s := range sets {
+		names := members[s.GetName()]
+		sort.Strings(names)
+		if reflect.DeepEqual(s.Status.Members, names) {
+			continue
+		}
+
+		ps := s.DeepCopy()
+		ps.Status.Members = names
+		if _, err := pc.kyvernoClient.KyvernoV1().PolicySets().UpdateStatus(context.TODO(), ps, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update policy set status", "policySet", ps.GetName())
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// resolvePolicySetMembers returns the owning policy set of each selected policy, keyed by policy name.
+// A policy selected by more than one set is assigned to the first set by name.
+func resolvePolicySetMembers(sets []*kyverno.PolicySet, policies []*kyverno.ClusterPolicy, logger logr.Logger) map[string]*kyverno.PolicySet {
+	sorted := make([]*kyverno.PolicySet, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	owners := make(map[string]*kyverno.PolicySet)
+	for _, ps := range sorted {
+		if ps.Spec.PolicySelector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(ps.Spec.PolicySelector)
+		if err != nil {
+			logger.Error(err, "invalid policy selector", "policySet", ps.GetName())
+			continue
+		}
+
+		if selector.Empty() {
+			continue
+		}
+
+		for _, p := range policies {
+			if !selector.Matches(labels.Set(p.GetLabels())) {
+				continue
+			}
+
+			if owner, ok := owners[p.GetName()]; ok {
+				logger.Info("policy is selected by multiple policy sets, ignoring", "policy", p.GetName(), "policySet", ps.GetName(), "owner", owner.GetName())
+				continue
+			}
+
+			owners[p.GetName()] = ps
+		}
+	}
+
+	return owners
+}
+
+// applyPolicySet sets the effective settings of the policy set on the policy status.
+// A nil policy set clears any previously applied settings. It returns true if the status changed.
+func applyPolicySet(policy *kyverno.ClusterPolicy, ps *kyverno.PolicySet) bool {
+	old := policy.Status.DeepCopy()
+
+	if ps == nil {
+		policy.Status.PolicySet = ""
+		policy.Status.ValidationFailureAction = ""
+	} else {
+		policy.Status.PolicySet = ps.GetName()
+		policy.Status.ValidationFailureAction = ps.Spec.ValidationFailureAction
+	}
+
+	if ps != nil && !ps.IsEnabled() {
+		meta.SetStatusCondition(&policy.Status.Conditions, metav1.Condition{
+			Type:    kyverno.PolicyConditionSuspended,
+			Status:  metav1.ConditionTrue,
+			Reason:  policySetReasonDisabled,
+			Message: "policy set " + ps.GetName() + " is disabled",
+		})
+	} else if c := meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionSuspended); c != nil && c.Reason == policySetReasonDisabled {
+		meta.RemoveStatusCondition(&policy.Status.Conditions, kyverno.PolicyConditionSuspended)
+	}
+
+	if len(policy.Status.Conditions) == 0 {
+		policy.Status.Conditions = nil
+	}
+
+	return !reflect.DeepEqual(*old, policy.Status)
+}
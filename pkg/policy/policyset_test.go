@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newPolicySetTestPolicy(name string, labels map[string]string, action string) *kyverno.ClusterPolicy {
+	return &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       kyverno.Spec{ValidationFailureAction: action},
+	}
+}
+
+func newTestPolicySet(name string, matchLabels map[string]string, action string, enabled bool) *kyverno.PolicySet {
+	return &kyverno.PolicySet{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kyverno.PolicySetSpec{
+			PolicySelector:          &metav1.LabelSelector{MatchLabels: matchLabels},
+			ValidationFailureAction: action,
+			Enabled:                 &enabled,
+		},
+	}
+}
+
+func Test_PolicySet_Resolve_Members(t *testing.T) {
+	policies := []*kyverno.ClusterPolicy{
+		newPolicySetTestPolicy("p1", map[string]string{"team": "a"}, "audit"),
+		newPolicySetTestPolicy("p2", map[string]string{"team": "b"}, "audit"),
+		newPolicySetTestPolicy("p3", nil, "audit"),
+	}
+
+	sets := []*kyverno.PolicySet{
+		newTestPolicySet("set-b", map[string]string{"team": "a"}, "", true),
+		newTestPolicySet("set-a", map[string]string{"team": "a"}, "", true),
+		newTestPolicySet("set-empty", nil, "", true),
+	}
+
+	owners := resolvePolicySetMembers(sets, policies, log.Log)
+	assert.Equal(t, len(owners), 1)
+	// the first set by name wins
+	assert.Equal(t, owners["p1"].GetName(), "set-a")
+}
+
+func Test_PolicySet_Suspend_Resume(t *testing.T) {
+	policy := newPolicySetTestPolicy("p1", map[string]string{"team": "a"}, "audit")
+	ps := newTestPolicySet("set-a", map[string]string{"team": "a"}, "", false)
+
+	// suspend
+	assert.Assert(t, applyPolicySet(policy, ps))
+	assert.Assert(t, policy.IsSuspended())
+	assert.Equal(t, policy.Status.PolicySet, "set-a")
+	assert.Assert(t, !applyPolicySet(policy, ps))
+
+	// resume
+	enabled := true
+	ps.Spec.Enabled = &enabled
+	assert.Assert(t, applyPolicySet(policy, ps))
+	assert.Assert(t, !policy.IsSuspended())
+	assert.Assert(t, policy.Status.Conditions == nil)
+
+	// suspend again and remove the policy from the set
+	enabled = false
+	assert.Assert(t, applyPolicySet(policy, ps))
+	assert.Assert(t, policy.IsSuspended())
+	assert.Assert(t, applyPolicySet(policy, nil))
+	assert.Assert(t, !policy.IsSuspended())
+	assert.Equal(t, policy.Status.PolicySet, "")
+}
+
+func Test_PolicySet_Validation_Failure_Action_Override(t *testing.T) {
+	policy := newPolicySetTestPolicy("p1", map[string]string{"team": "a"}, "audit")
+	ps := newTestPolicySet("set-a", map[string]string{"team": "a"}, "enforce", true)
+
+	assert.Assert(t, applyPolicySet(policy, ps))
+	assert.Equal(t, policy.GetValidationFailureAction(), "enforce")
+	assert.Equal(t, policy.Spec.ValidationFailureAction, "audit")
+
+	// no override keeps the policy setting
+	ps.Spec.ValidationFailureAction = ""
+	assert.Assert(t, applyPolicySet(policy, ps))
+	assert.Equal(t, policy.GetValidationFailureAction(), "audit")
+
+	ps.Spec.ValidationFailureAction = "enforce"
+	applyPolicySet(policy, ps)
+	assert.Assert(t, applyPolicySet(policy, nil))
+	assert.Equal(t, policy.GetValidationFailureAction(), "audit")
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	v1alpha1 "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
 	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
 	changerequestlister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1alpha1"
@@ -37,38 +38,41 @@ func (pc *PolicyController) report(engineResponses []*response.EngineResponse, l
 	logger.V(4).Info("added a request to RCR generator", "key", info.ToKey())
 }
 
-// forceReconciliation forces a background scan by adding all policies to the workqueue
+// forceReconciliation forces a background scan by adding all policies to the workqueue.
+// The scans are skipped while the background processing is paused, the reconciliations requested
+// meanwhile are performed once it resumes.
 func (pc *PolicyController) forceReconciliation(reconcileCh <-chan bool, stopCh <-chan struct{}) {
 	logger := pc.log.WithName("forceReconciliation")
-	ticker := time.NewTicker(pc.reconcilePeriod)
+	ticker := time.NewTicker(pc.scanOptions.Interval)
 
+	var pending, pendingErase bool
 	for {
 		select {
 		case <-ticker.C:
-			logger.Info("performing the background scan", "scan interval", pc.reconcilePeriod.String())
-			if err := pc.policyReportEraser.CleanupReportChangeRequests(cleanupReportChangeRequests); err != nil {
-				logger.Error(err, "failed to cleanup report change requests")
-			}
-
-			if err := pc.policyReportEraser.EraseResultsEntries(eraseResultsEntries); err != nil {
-				logger.Error(err, "continue reconciling policy reports")
+			if pc.backgroundGate.Paused() {
+				logger.Info("background processing is paused, skipping the background scan")
+				continue
 			}
 
-			pc.requeuePolicies()
+			logger.Info("performing the background scan", "scan interval", pc.scanOptions.Interval.String())
+			pc.reconcile(true, logger)
 
 		case erase := <-reconcileCh:
-			logger.Info("received the reconcile signal, reconciling policy report")
-			if err := pc.policyReportEraser.CleanupReportChangeRequests(cleanupReportChangeRequests); err != nil {
-				logger.Error(err, "failed to cleanup report change requests")
+			if pc.backgroundGate.Paused() {
+				logger.Info("background processing is paused, the policy report is reconciled once resumed")
+				pending, pendingErase = true, pendingErase || erase
+				continue
 			}
 
-			if erase {
-				if err := pc.policyReportEraser.EraseResultsEntries(eraseResultsEntries); err != nil {
-					logger.Error(err, "continue reconciling policy reports")
-				}
-			}
+			logger.Info("received the reconcile signal, reconciling policy report")
+			pc.reconcile(erase, logger)
 
-			pc.requeuePolicies()
+		case <-pc.backgroundGate.Resumed():
+			if pending {
+				logger.Info("background processing is resumed, reconciling policy report")
+				pc.reconcile(pendingErase, logger)
+				pending, pendingErase = false, false
+			}
 
 		case <-stopCh:
 			return
@@ -76,6 +80,24 @@ func (pc *PolicyController) forceReconciliation(reconcileCh <-chan bool, stopCh
 	}
 }
 
+// reconcile cleans up the report change requests, erases the results of the reports if requested,
+// and adds the policies without a schedule to the workqueue. The results of the policies with a
+// schedule are kept until their next scheduled scan.
+func (pc *PolicyController) reconcile(erase bool, logger logr.Logger) {
+	if err := pc.policyReportEraser.CleanupReportChangeRequests(cleanupReportChangeRequests); err != nil {
+		logger.Error(err, "failed to cleanup report change requests")
+	}
+
+	policies := pc.backgroundPolicies()
+	if erase {
+		if err := pc.policyReportEraser.EraseResultsEntries(eraseResultsEntries(scheduledPolicies(policies))); err != nil {
+			logger.Error(err, "continue reconciling policy reports")
+		}
+	}
+
+	pc.requeuePolicies(policies)
+}
+
 func cleanupReportChangeRequests(pclient *kyvernoclient.Clientset, rcrLister changerequestlister.ReportChangeRequestLister, crcrLister changerequestlister.ClusterReportChangeRequestLister) error {
 	var errors []string
 
@@ -99,15 +121,23 @@ func cleanupReportChangeRequests(pclient *kyvernoclient.Clientset, rcrLister cha
 	return fmt.Errorf("%v", strings.Join(errors, ";"))
 }
 
-func eraseResultsEntries(pclient *kyvernoclient.Clientset, reportLister policyreportlister.PolicyReportLister, clusterReportLister policyreportlister.ClusterPolicyReportLister) error {
+// eraseResultsEntries returns the eraser of the report results, except the results of the
+// kept policies. The keys of the namespaced policies are prefixed with their namespace.
+func eraseResultsEntries(keep map[string]bool) policyreport.EraseResultsEntries {
+	return func(pclient *kyvernoclient.Clientset, reportLister policyreportlister.PolicyReportLister, clusterReportLister policyreportlister.ClusterPolicyReportLister) error {
+		return eraseResults(keep, pclient, reportLister, clusterReportLister)
+	}
+}
+
+func eraseResults(keep map[string]bool, pclient *kyvernoclient.Clientset, reportLister policyreportlister.PolicyReportLister, clusterReportLister policyreportlister.ClusterPolicyReportLister) error {
 	var errors []string
 
 	if polrs, err := reportLister.List(labels.Everything()); err != nil {
 		errors = append(errors, err.Error())
 	} else {
 		for _, polr := range polrs {
-			polr.Results = []*v1alpha1.PolicyReportResult{}
-			polr.Summary = v1alpha1.PolicyReportSummary{}
+			polr.Results = keptResults(polr.Results, polr.GetNamespace(), keep)
+			polr.Summary = policyreport.CalculateSummary(polr.Results)
 			if _, err = pclient.Wgpolicyk8sV1alpha1().PolicyReports(polr.GetNamespace()).Update(context.TODO(), polr, metav1.UpdateOptions{}); err != nil {
 				errors = append(errors, fmt.Sprintf("%s/%s/%s: %v", polr.Kind, polr.Namespace, polr.Name, err))
 			}
@@ -118,8 +148,8 @@ func eraseResultsEntries(pclient *kyvernoclient.Clientset, reportLister policyre
 		errors = append(errors, err.Error())
 	} else {
 		for _, cpolr := range cpolrs {
-			cpolr.Results = []*v1alpha1.PolicyReportResult{}
-			cpolr.Summary = v1alpha1.PolicyReportSummary{}
+			cpolr.Results = keptResults(cpolr.Results, "", keep)
+			cpolr.Summary = policyreport.CalculateSummary(cpolr.Results)
 			if _, err = pclient.Wgpolicyk8sV1alpha1().ClusterPolicyReports().Update(context.TODO(), cpolr, metav1.UpdateOptions{}); err != nil {
 				errors = append(errors, fmt.Sprintf("%s/%s: %v", cpolr.Kind, cpolr.Name, err))
 			}
@@ -133,14 +163,28 @@ func eraseResultsEntries(pclient *kyvernoclient.Clientset, reportLister policyre
 	return fmt.Errorf("failed to erase results entries %v", strings.Join(errors, ";"))
 }
 
-func (pc *PolicyController) requeuePolicies() {
-	logger := pc.log.WithName("requeuePolicies")
+// keptResults returns the results of the kept policies in the report of the namespace
+func keptResults(results []*v1alpha1.PolicyReportResult, namespace string, keep map[string]bool) []*v1alpha1.PolicyReportResult {
+	kept := []*v1alpha1.PolicyReportResult{}
+	for _, result := range results {
+		if keep[result.Policy] || (namespace != "" && keep[namespace+"/"+result.Policy]) {
+			kept = append(kept, result)
+		}
+	}
+
+	return kept
+}
+
+// backgroundPolicies returns the cluster policies and the namespaced policies processed in the background
+func (pc *PolicyController) backgroundPolicies() []*kyverno.ClusterPolicy {
+	logger := pc.log.WithName("backgroundPolicies")
+	var policies []*kyverno.ClusterPolicy
 	if cpols, err := pc.pLister.List(labels.Everything()); err == nil {
 		for _, cpol := range cpols {
 			if !pc.canBackgroundProcess(cpol) {
 				continue
 			}
-			pc.enqueuePolicy(cpol)
+			policies = append(policies, cpol)
 		}
 	} else {
 		logger.Error(err, "unable to list ClusterPolicies")
@@ -149,7 +193,7 @@ func (pc *PolicyController) requeuePolicies() {
 	namespaces, err := pc.nsLister.List(labels.Everything())
 	if err != nil {
 		logger.Error(err, "unable to list namespaces")
-		return
+		return policies
 	}
 
 	for _, ns := range namespaces {
@@ -161,11 +205,24 @@ func (pc *PolicyController) requeuePolicies() {
 
 		for _, p := range pols {
 			pol := ConvertPolicyToClusterPolicy(p)
-			if !pc.canBackgroundProcess(pol) {
+			if pol == nil || !pc.canBackgroundProcess(pol) {
 				continue
 			}
-			pc.enqueuePolicy(pol)
+			policies = append(policies, pol)
+		}
+	}
+
+	return policies
+}
+
+// requeuePolicies queues the policies without a schedule for a full background scan, the scans
+// are spread over the jitter of the scan interval
+func (pc *PolicyController) requeuePolicies(policies []*kyverno.ClusterPolicy) {
+	for _, policy := range policies {
+		if policy.Spec.Schedule != "" {
+			continue
 		}
+		pc.enqueuePolicyAfter(policy, pc.jitter(pc.scanOptions.Interval))
 	}
 }
 
@@ -188,6 +245,7 @@ func generateSuccessEvents(log logr.Logger, ers []*response.EngineResponse) (eve
 			e.Reason = event.PolicyApplied.String()
 			e.Source = event.PolicyController
 			e.Message = fmt.Sprintf("rules '%v' successfully applied on resource '%s/%s/%s'", er.GetSuccessRules(), er.PolicyResponse.Resource.Kind, er.PolicyResponse.Resource.Namespace, er.PolicyResponse.Resource.Name)
+			e.SetRuleDocumentation(er.GetRuleDocumentation(true))
 			eventInfos = append(eventInfos, e)
 		}
 	}
@@ -219,7 +277,9 @@ func generateFailEventsPerEr(log logr.Logger, er *response.EngineResponse) []eve
 		e.Name = er.PolicyResponse.Resource.Name
 		e.Reason = event.PolicyViolation.String()
 		e.Source = event.PolicyController
-		e.Message = fmt.Sprintf("policy '%s' (%s) rule '%s' failed. %v", er.PolicyResponse.Policy.Name, rule.Type, rule.Name, rule.Message)
+		e.Message = fmt.Sprintf("policy '%s' (%s) rule '%s' failed. %v", er.PolicyResponse.Policy.Name, rule.Type, rule.Name, rule.DocumentedMessage())
+		e.RuleIDs = rule.ID
+		e.DocumentationURLs = rule.DocumentationURL
 		eventInfos = append(eventInfos, e)
 	}
 
@@ -237,6 +297,7 @@ func generateFailEventsPerEr(log logr.Logger, er *response.EngineResponse) []eve
 		e.Reason = event.PolicyApplied.String()
 		e.Source = event.PolicyController
 		e.Message = fmt.Sprintf("rules '%v' successfully applied on resource '%s/%s/%s'", er.GetSuccessRules(), er.PolicyResponse.Resource.Kind, er.PolicyResponse.Resource.Namespace, er.PolicyResponse.Resource.Name)
+		e.SetRuleDocumentation(er.GetRuleDocumentation(true))
 		eventInfos = append(eventInfos, e)
 	}
 
@@ -254,6 +315,7 @@ func generateFailEventsPerEr(log logr.Logger, er *response.EngineResponse) []eve
 		e.Reason = event.PolicyViolation.String()
 		e.Source = event.PolicyController
 		e.Message = fmt.Sprintf("rules '%v' not satisfied on resource '%s/%s/%s'", er.GetFailedRules(), er.PolicyResponse.Resource.Kind, er.PolicyResponse.Resource.Namespace, er.PolicyResponse.Resource.Name)
+		e.SetRuleDocumentation(er.GetRuleDocumentation(false))
 		eventInfos = append(eventInfos, e)
 	}
 	return eventInfos
@@ -273,6 +335,7 @@ func mergePvInfos(infos []policyreport.Info) policyreport.Info {
 	}
 
 	aggregatedInfo.PolicyName = infos[0].PolicyName
+	aggregatedInfo.PolicyNamespace = infos[0].PolicyNamespace
 	aggregatedInfo.Namespace = infos[0].Namespace
 	aggregatedInfo.Results = results
 	return aggregatedInfo
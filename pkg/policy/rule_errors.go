@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+// RuleError is an error of a rule of a policy, the path is relative to the rule
+type RuleError struct {
+	Index   int
+	Rule    string
+	Path    string
+	Message string
+}
+
+// Field returns the path of the error in the policy
+func (e RuleError) Field() string {
+	if e.Path == "" {
+		return fmt.Sprintf("spec.rules[%d]", e.Index)
+	}
+
+	return fmt.Sprintf("spec.rules[%d].%s", e.Index, e.Path)
+}
+
+func (e RuleError) Error() string {
+	return fmt.Sprintf("rule %s: path: %s: %s", e.Rule, e.Field(), e.Message)
+}
+
+// RuleErrors are the errors of all the rules of a policy, they are reported at once so that a
+// policy is fixed in one go
+type RuleErrors []RuleError
+
+func (e RuleErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// checkRules checks the variables and the match and exclude blocks of all the rules of a policy, the
+// references of the variables are not checked by the CLI
+func checkRules(p kyverno.ClusterPolicy, mock bool) error {
+	var errs RuleErrors
+	for i, rule := range p.Spec.Rules {
+		var ruleErrs []RuleError
+		ruleErrs = append(ruleErrs, validateVariables(rule, !mock)...)
+		if path, err := validateReachable(p, rule); err != nil {
+			ruleErrs = append(ruleErrs, RuleError{Path: path, Message: err.Error()})
+		}
+
+		for _, err := range ruleErrs {
+			err.Index, err.Rule = i, rule.Name
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/robfig/cron/v3"
+	"k8s.io/client-go/tools/cache"
+)
+
+// scheduleCheckPeriod is the period of the checks of the due scheduled scans, the finest
+// resolution of the cron schedules is a minute
+const scheduleCheckPeriod = time.Minute
+
+// ScanOptions configures the background scans
+type ScanOptions struct {
+	// Interval is the interval of the background scans of the policies without a schedule
+	Interval time.Duration
+
+	// ChunkSize is the maximum number of resources evaluated and reported at once, the resources
+	// of a namespace are evaluated at once if 0
+	ChunkSize int
+
+	// Jitter is the maximum fraction of the scan interval the scans of the policies are randomly
+	// delayed by, so that they are spread over the interval. The scans are not delayed if 0.
+	Jitter float64
+}
+
+// Validate checks the scan options
+func (o ScanOptions) Validate() error {
+	if o.Interval <= 0 {
+		return fmt.Errorf("invalid background scan interval %s, expect a positive duration", o.Interval)
+	}
+
+	if o.ChunkSize < 0 {
+		return fmt.Errorf("invalid background scan chunk size %d, expect a positive number or 0", o.ChunkSize)
+	}
+
+	if o.Jitter < 0 || o.Jitter > 1 {
+		return fmt.Errorf("invalid background scan jitter %v, expect a value between 0 and 1", o.Jitter)
+	}
+
+	return nil
+}
+
+// scanSchedules holds the last scheduled scans of the policies with a schedule
+type scanSchedules struct {
+	mutex sync.Mutex
+	last  map[string]time.Time
+}
+
+func newScanSchedules() *scanSchedules {
+	return &scanSchedules{last: make(map[string]time.Time)}
+}
+
+// due returns true if the next scan of the policy after its last scan is due at the time, and
+// records the scan. The first check of a policy records the time, the policies are scanned
+// when they are added.
+func (s *scanSchedules) due(key string, schedule cron.Schedule, now time.Time) (bool, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	last, ok := s.last[key]
+	if !ok {
+		s.last[key] = now
+		return false, 0
+	}
+
+	next := schedule.Next(last)
+	if next.After(now) {
+		return false, 0
+	}
+
+	s.last[key] = now
+	return true, schedule.Next(next).Sub(next)
+}
+
+// forget removes the last scan of a deleted policy
+func (s *scanSchedules) forget(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.last, key)
+}
+
+// parseSchedule parses the cron schedule of the background scans of the policy
+func parseSchedule(policy *kyverno.ClusterPolicy) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(policy.Spec.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %s: %v", policy.Spec.Schedule, err)
+	}
+
+	return schedule, nil
+}
+
+// enqueueScheduledPolicies queues the policies whose scheduled scan is due
+func (pc *PolicyController) enqueueScheduledPolicies() {
+	logger := pc.log.WithName("enqueueScheduledPolicies")
+	if pc.backgroundGate.Paused() {
+		return
+	}
+
+	now := time.Now()
+	for _, policy := range pc.backgroundPolicies() {
+		if policy.Spec.Schedule == "" {
+			continue
+		}
+
+		schedule, err := parseSchedule(policy)
+		if err != nil {
+			logger.Error(err, "failed to parse the schedule of the background scans", "policy", policy.GetName())
+			continue
+		}
+
+		key, err := cache.MetaNamespaceKeyFunc(policy)
+		if err != nil {
+			logger.Error(err, "failed to enqueue policy")
+			continue
+		}
+
+		if due, interval := pc.schedules.due(key, schedule, now); due {
+			logger.V(2).Info("performing the scheduled background scan", "policy", key, "schedule", policy.Spec.Schedule)
+			pc.enqueuePolicyAfter(policy, pc.jitter(interval))
+		}
+	}
+}
+
+// enqueuePolicyAfter queues the policy for a full background scan after the delay
+func (pc *PolicyController) enqueuePolicyAfter(policy *kyverno.ClusterPolicy, delay time.Duration) {
+	if delay <= 0 {
+		pc.enqueuePolicy(policy)
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(policy)
+	if err != nil {
+		pc.log.Error(err, "failed to enqueue policy")
+		return
+	}
+	pc.scans.full(key)
+	pc.queue.AddAfter(key, delay)
+}
+
+// jitter returns a random delay up to the jitter fraction of the interval
+func (pc *PolicyController) jitter(interval time.Duration) time.Duration {
+	if pc.scanOptions.Jitter <= 0 || interval <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Float64() * pc.scanOptions.Jitter * float64(interval))
+}
+
+// scheduledPolicies returns the keys of the policies scanned on their own schedule, the
+// namespaced policies are prefixed with their namespace
+func scheduledPolicies(policies []*kyverno.ClusterPolicy) map[string]bool {
+	scheduled := make(map[string]bool)
+	for _, policy := range policies {
+		if policy.Spec.Schedule == "" {
+			continue
+		}
+
+		if key, err := cache.MetaNamespaceKeyFunc(policy); err == nil {
+			scheduled[key] = true
+		}
+	}
+
+	return scheduled
+}
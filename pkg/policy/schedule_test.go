@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_scanSchedules_due(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 */6 * * *")
+	assert.NilError(t, err)
+
+	start, err := time.Parse(time.RFC3339, "2022-03-01T10:30:00Z")
+	assert.NilError(t, err)
+
+	schedules := newScanSchedules()
+
+	// the first check records the time of the scan on add
+	due, _ := schedules.due("require-labels", schedule, start)
+	assert.Assert(t, !due)
+
+	due, _ = schedules.due("require-labels", schedule, start.Add(time.Hour))
+	assert.Assert(t, !due)
+
+	due, interval := schedules.due("require-labels", schedule, start.Add(2*time.Hour))
+	assert.Assert(t, due)
+	assert.Equal(t, interval, 6*time.Hour)
+
+	// the scan is recorded
+	due, _ = schedules.due("require-labels", schedule, start.Add(2*time.Hour+time.Minute))
+	assert.Assert(t, !due)
+
+	due, _ = schedules.due("require-labels", schedule, start.Add(7*time.Hour+30*time.Minute))
+	assert.Assert(t, due)
+
+	schedules.forget("require-labels")
+	due, _ = schedules.due("require-labels", schedule, start.Add(24*time.Hour))
+	assert.Assert(t, !due)
+}
+
+func Test_ScanOptions_Validate(t *testing.T) {
+	testCases := []struct {
+		options     ScanOptions
+		expectedErr bool
+	}{
+		{options: ScanOptions{Interval: time.Hour}, expectedErr: false},
+		{options: ScanOptions{Interval: time.Hour, ChunkSize: 100, Jitter: 0.5}, expectedErr: false},
+		{options: ScanOptions{}, expectedErr: true},
+		{options: ScanOptions{Interval: time.Hour, ChunkSize: -1}, expectedErr: true},
+		{options: ScanOptions{Interval: time.Hour, Jitter: 1.5}, expectedErr: true},
+	}
+
+	for _, test := range testCases {
+		err := test.options.Validate()
+		assert.Equal(t, err != nil, test.expectedErr, fmt.Sprintf("%+v: %v", test.options, err))
+	}
+}
+
+func Test_chunkResources(t *testing.T) {
+	resources := map[string]unstructured.Unstructured{}
+	for i := 0; i < 5; i++ {
+		var resource unstructured.Unstructured
+		resource.SetKind("Pod")
+		resource.SetNamespace("default")
+		resource.SetName(fmt.Sprintf("pod-%d", i))
+		resources[fmt.Sprintf("uid-%d", i)] = resource
+	}
+
+	chunks := chunkResources(resources, 2)
+	assert.Equal(t, len(chunks), 3)
+	assert.Equal(t, len(chunks[0]), 2)
+	assert.Equal(t, len(chunks[2]), 1)
+	assert.Equal(t, chunks[0][0].GetName(), "pod-0")
+	assert.Equal(t, chunks[2][0].GetName(), "pod-4")
+
+	chunks = chunkResources(resources, 0)
+	assert.Equal(t, len(chunks), 1)
+	assert.Equal(t, len(chunks[0]), 5)
+}
+
+func Test_keptResults(t *testing.T) {
+	results := []*v1alpha1.PolicyReportResult{
+		{Policy: "require-labels", Rule: "check-labels"},
+		{Policy: "disallow-latest", Rule: "check-tag"},
+		{Policy: "restrict-replicas", Rule: "check-replicas"},
+	}
+	keep := map[string]bool{"require-labels": true, "prod/restrict-replicas": true}
+
+	kept := keptResults(results, "prod", keep)
+	assert.Equal(t, len(kept), 2)
+	assert.Equal(t, kept[0].Policy, "require-labels")
+	assert.Equal(t, kept[1].Policy, "restrict-replicas")
+
+	kept = keptResults(results, "dev", keep)
+	assert.Equal(t, len(kept), 1)
+
+	kept = keptResults(results, "", nil)
+	assert.Equal(t, len(kept), 0)
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	gojmespath "github.com/jmespath/go-jmespath"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"sigs.k8s.io/yaml"
+)
+
+// TestResourceAnnotation holds a sample manifest, in YAML or JSON, the expressions of the policy
+// are evaluated against when the policy is admitted
+const TestResourceAnnotation = "policies.kyverno.io/test-resource"
+
+// maxTestResourceSize caps the size of the sample manifest
+const maxTestResourceSize = 32 * 1024
+
+// ValidateTestResource evaluates the expressions of the context entries, preconditions and deny
+// conditions on the sample manifest of the policy annotation. It returns a warning for each
+// expression that fails or returns null, e.g. because of a typo in a path. Only the expressions
+// on request.object are evaluated, the other variables are not known without an admission request.
+func ValidateTestResource(policy *kyverno.ClusterPolicy, log logr.Logger) []string {
+	sample, ok := policy.GetAnnotations()[TestResourceAnnotation]
+	if !ok {
+		return nil
+	}
+
+	if len(sample) > maxTestResourceSize {
+		return []string{fmt.Sprintf("%s: the test resource exceeds %d bytes, the expressions are not evaluated", TestResourceAnnotation, maxTestResourceSize)}
+	}
+
+	resource, err := yaml.YAMLToJSON([]byte(sample))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: failed to parse the test resource: %v", TestResourceAnnotation, err)}
+	}
+
+	ctx := context.NewContext()
+	if err := ctx.AddResource(resource); err != nil {
+		return []string{fmt.Sprintf("%s: failed to load the test resource: %v", TestResourceAnnotation, err)}
+	}
+
+	var warnings []string
+	for i, rule := range policy.Spec.Rules {
+		sections := []testResourceSection{
+			{path: "context", value: rule.Context},
+			{path: "preconditions", value: rule.AnyAllConditions},
+		}
+
+		if rule.Validation.Deny != nil {
+			sections = append(sections, testResourceSection{path: "validate.deny.conditions", value: rule.Validation.Deny.AnyAllConditions})
+		}
+
+		for _, section := range sections {
+			for _, expression := range testResourceExpressions(rule, section.value, log) {
+				if warning := evaluateTestExpression(ctx, expression); warning != "" {
+					warnings = append(warnings, fmt.Sprintf("spec.rules[%d].%s: %s", i, section.path, warning))
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+// testResourceSection is a part of a rule, e.g. the preconditions, with its path in the warnings
+type testResourceSection struct {
+	path  string
+	value interface{}
+}
+
+// testResourceExpressions returns the expressions on request.object of the variables in the value,
+// the expressions using the context entries of the rule are skipped as they are not loaded
+func testResourceExpressions(rule kyverno.Rule, value interface{}, log logr.Logger) []string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.V(4).Info("failed to marshal the rule", "rule", rule.Name, "reason", err.Error())
+		return nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		log.V(4).Info("failed to unmarshal the rule", "rule", rule.Name, "reason", err.Error())
+		return nil
+	}
+
+	var expressions []string
+	for _, str := range collectStrings(document) {
+		for _, variable := range variables.RegexVariables.FindAllString(str, -1) {
+			expression := strings.TrimSpace(variable[2 : len(variable)-2])
+			if !strings.Contains(expression, "request.object") || usesContextEntry(rule, expression) {
+				continue
+			}
+
+			expressions = append(expressions, expression)
+		}
+	}
+
+	return expressions
+}
+
+// collectStrings returns the string values of the document, in order
+func collectStrings(document interface{}) []string {
+	switch typed := document.(type) {
+	case string:
+		return []string{typed}
+	case []interface{}:
+		var strs []string
+		for _, item := range typed {
+			strs = append(strs, collectStrings(item)...)
+		}
+		return strs
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var strs []string
+		for _, key := range keys {
+			strs = append(strs, collectStrings(typed[key])...)
+		}
+		return strs
+	}
+
+	return nil
+}
+
+func usesContextEntry(rule kyverno.Rule, expression string) bool {
+	for _, entry := range rule.Context {
+		if regexp.MustCompile(`(?:^|[^.\w])` + regexp.QuoteMeta(entry.Name) + `\b`).MatchString(expression) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluateTestExpression returns a warning if the expression fails or returns null
+func evaluateTestExpression(ctx *context.Context, expression string) string {
+	result, err := ctx.Query(expression)
+	if _, ok := err.(gojmespath.NotFoundError); ok {
+		// an absent field is not found rather than null
+		result, err = nil, nil
+	}
+
+	if err != nil {
+		return fmt.Sprintf("expression %q failed on the test resource: %v", expression, err)
+	}
+
+	if result == nil {
+		return fmt.Sprintf("expression %q returns null for the test resource", expression)
+	}
+
+	return ""
+}
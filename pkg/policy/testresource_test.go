@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const testResourcePod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  namespace: web
+  labels:
+    app: nginx
+spec:
+  containers:
+  - name: nginx
+    image: nginx:1.21
+`
+
+func newTestResourcePolicy(t *testing.T, labelPath string) *kyverno.ClusterPolicy {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "check-registry"},
+		"spec": {
+			"background": false,
+			"rules": [
+				{
+					"name": "check-registry",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"context": [{"name": "settings", "configMap": {"name": "{{request.object.metadata.namespace}}-settings", "namespace": "kyverno"}}],
+					"preconditions": {"all": [{"key": "{{` + labelPath + `}}", "operator": "Equals", "value": "nginx"}]},
+					"validate": {
+						"message": "image registry is not allowed",
+						"deny": {"conditions": [{"key": "{{request.object.spec.containers[0].image}}", "operator": "NotIn", "value": "{{settings.data.allowed}}"}]}
+					}
+				}
+			]
+		}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+	policy.SetAnnotations(map[string]string{TestResourceAnnotation: testResourcePod})
+	return &policy
+}
+
+func Test_ValidateTestResource(t *testing.T) {
+	// the typo in the label path returns null for the sample
+	warnings := ValidateTestResource(newTestResourcePolicy(t, "request.object.metadata.lables.app"), log.Log)
+	assert.Equal(t, len(warnings), 1)
+	assert.Assert(t, strings.HasPrefix(warnings[0], "spec.rules[0].preconditions: "))
+	assert.Assert(t, strings.Contains(warnings[0], `"request.object.metadata.lables.app" returns null`))
+
+	// the expressions return values for the sample, the context entry variables are not evaluated
+	warnings = ValidateTestResource(newTestResourcePolicy(t, "request.object.metadata.labels.app"), log.Log)
+	assert.Equal(t, len(warnings), 0)
+
+	// no expression is evaluated without a sample, or with a sample exceeding the size limit
+	policy := newTestResourcePolicy(t, "request.object.metadata.lables.app")
+	policy.SetAnnotations(nil)
+	assert.Equal(t, len(ValidateTestResource(policy, log.Log)), 0)
+
+	policy.SetAnnotations(map[string]string{TestResourceAnnotation: testResourcePod + strings.Repeat("#", maxTestResourceSize)})
+	warnings = ValidateTestResource(policy, log.Log)
+	assert.Equal(t, len(warnings), 1)
+	assert.Assert(t, strings.Contains(warnings[0], "exceeds"))
+}
@@ -0,0 +1,321 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// policyReasonUnsupportedFields is the Ignored-Unsupported condition and event reason
+const policyReasonUnsupportedFields = "UnsupportedFields"
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// rulePathRegex matches the paths of the fields of a rule, e.g. "spec.rules[1].match.any"
+	rulePathRegex = regexp.MustCompile(`^spec\.rules\[([0-9]+)\]`)
+)
+
+// UnsupportedRule is a policy rule using fields unknown to this version of Kyverno
+type UnsupportedRule struct {
+	Name string
+
+	// Fields are the paths of the unknown fields, e.g. "spec.rules[1].match.any"
+	Fields []string
+}
+
+// UnsupportedFields strictly decodes the spec of the raw policy and returns the rules using fields
+// unknown to this version of Kyverno, e.g. fields of a newer version, and the unknown fields of the
+// spec outside of the rules. Unlike the decoding of the policy, which drops the unknown fields, all
+// the unknown fields are reported with their path.
+func UnsupportedFields(raw []byte) ([]UnsupportedRule, []string, error) {
+	var policy map[string]interface{}
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode policy: %v", err)
+	}
+
+	spec, ok := policy["spec"]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var rules []UnsupportedRule
+	var fields []string
+	ruleIndexes := make(map[int]int)
+	for _, path := range unknownFields(spec, reflect.TypeOf(kyverno.Spec{}), "spec") {
+		match := rulePathRegex.FindStringSubmatch(path)
+		if match == nil {
+			fields = append(fields, path)
+			continue
+		}
+
+		index, _ := strconv.Atoi(match[1])
+		i, ok := ruleIndexes[index]
+		if !ok {
+			i = len(rules)
+			ruleIndexes[index] = i
+			rules = append(rules, UnsupportedRule{Name: ruleName(spec, index)})
+		}
+		rules[i].Fields = append(rules[i].Fields, path)
+	}
+
+	return rules, fields, nil
+}
+
+// unknownFields returns the paths of the fields of the JSON value that are not fields of the type.
+// The values of the types decoding themselves, e.g. the free-form patterns, are not checked.
+func unknownFields(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(obj) {
+			fieldType, ok := fields[key]
+			if !ok {
+				unknown = append(unknown, path+"."+key)
+				continue
+			}
+			unknown = append(unknown, unknownFields(obj[key], fieldType, path+"."+key)...)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+
+		for i, item := range items {
+			unknown = append(unknown, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		for _, key := range sortedKeys(obj) {
+			unknown = append(unknown, unknownFields(obj[key], t.Elem(), path+"."+key)...)
+		}
+	}
+
+	return unknown
+}
+
+// jsonFields returns the types of the fields of the struct by their JSON name,
+// including the fields of the embedded structs without a JSON name
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				for n, ft := range jsonFields(embedded) {
+					fields[n] = ft
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ruleName returns the name of the rule at the index of the spec rules
+func ruleName(spec interface{}, index int) string {
+	specMap, _ := spec.(map[string]interface{})
+	rules, _ := specMap["rules"].([]interface{})
+	if index < len(rules) {
+		if rule, ok := rules[index].(map[string]interface{}); ok {
+			if name, ok := rule["name"].(string); ok {
+				return name
+			}
+		}
+	}
+	return fmt.Sprintf("rules[%d]", index)
+}
+
+// UnsupportedFieldsMessage returns the message listing the unknown fields of the policy
+func UnsupportedFieldsMessage(rules []UnsupportedRule, fields []string) string {
+	var details []string
+	for _, rule := range rules {
+		details = append(details, fmt.Sprintf("rule %s: %s", rule.Name, strings.Join(rule.Fields, ", ")))
+	}
+	if len(fields) > 0 {
+		details = append(details, strings.Join(fields, ", "))
+	}
+
+	return fmt.Sprintf("the policy uses fields that are not supported by this version of Kyverno, %s", strings.Join(details, "; "))
+}
+
+// CheckUnsupportedFields checks the raw policy for fields unknown to this version according to the
+// unsupportedPolicyFields mode. With "reject" an error lists the unknown fields. With "ignore" the rules
+// using unknown fields are removed from the policy and listed in its status, so that the other rules
+// are validated, and a warning is returned. The unknown fields outside of the rules are always rejected.
+func CheckUnsupportedFields(policy *kyverno.ClusterPolicy, raw []byte, mode string) (string, error) {
+	rules, fields, err := UnsupportedFields(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if len(rules) == 0 && len(fields) == 0 {
+		return "", nil
+	}
+
+	if len(fields) > 0 || mode != config.UnsupportedPolicyFieldsIgnore {
+		return "", errors.New(UnsupportedFieldsMessage(rules, fields))
+	}
+
+	policy.Status.UnsupportedRules = unsupportedRuleNames(rules)
+	policy.RemoveUnsupportedRules()
+	return unsupportedConditionMessage(rules), nil
+}
+
+// unsupportedRuleNames returns the names of the rules
+func unsupportedRuleNames(rules []UnsupportedRule) []string {
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+// setUnsupportedCondition sets or removes the Ignored-Unsupported condition and the unsupported
+// rules of the status according to the rules using unknown fields. It returns true if the status changed.
+func setUnsupportedCondition(status *kyverno.PolicyStatus, rules []UnsupportedRule) bool {
+	old := status.DeepCopy()
+
+	status.UnsupportedRules = unsupportedRuleNames(rules)
+	if len(rules) == 0 {
+		meta.RemoveStatusCondition(&status.Conditions, kyverno.PolicyConditionIgnoredUnsupported)
+	} else {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kyverno.PolicyConditionIgnoredUnsupported,
+			Status:  metav1.ConditionTrue,
+			Reason:  policyReasonUnsupportedFields,
+			Message: unsupportedConditionMessage(rules),
+		})
+	}
+
+	if len(status.Conditions) == 0 {
+		status.Conditions = nil
+	}
+
+	return !reflect.DeepEqual(*old, *status)
+}
+
+func unsupportedConditionMessage(rules []UnsupportedRule) string {
+	return fmt.Sprintf("the rules %s are ignored, %s", strings.Join(unsupportedRuleNames(rules), ", "), UnsupportedFieldsMessage(rules, nil))
+}
+
+// syncUnsupportedCondition checks the stored policy for rules using fields unknown to this version,
+// e.g. policies authored for a newer version and admitted with unsupportedPolicyFields set to "ignore".
+// The typed policy of the listers does not have the unknown fields, the policy is read from the API server.
+func (pc *PolicyController) syncUnsupportedCondition(p *kyverno.ClusterPolicy) {
+	logger := pc.log.WithValues("namespace", p.GetNamespace(), "name", p.GetName())
+
+	kind := p.GetKind()
+	obj, err := pc.client.GetResource("kyverno.io/v1", kind, p.GetNamespace(), p.GetName())
+	if err != nil {
+		logger.Error(err, "failed to get policy, skipping the check of unsupported fields")
+		return
+	}
+
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		logger.Error(err, "failed to encode policy, skipping the check of unsupported fields")
+		return
+	}
+
+	rules, _, err := UnsupportedFields(raw)
+	if err != nil {
+		logger.Error(err, "failed to check the unsupported fields of the policy")
+		return
+	}
+
+	// the status is updated from the policy read from the API server, it includes the
+	// changes of the previous status updates
+	policy := &kyverno.ClusterPolicy{}
+	if kind == "ClusterPolicy" {
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, policy)
+	} else {
+		nsPolicy := &kyverno.Policy{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, nsPolicy)
+		policy = ConvertPolicyToClusterPolicy(nsPolicy)
+	}
+
+	if err != nil {
+		logger.Error(err, "failed to convert policy, skipping the check of unsupported fields")
+		return
+	}
+
+	if !setUnsupportedCondition(&policy.Status, rules) {
+		return
+	}
+
+	updated, err := common.UpdatePolicyStatus(pc.kyvernoClient, policy)
+	if err != nil {
+		logger.Error(err, "failed to update policy status")
+		return
+	}
+
+	if len(rules) > 0 {
+		logger.Info("policy rules use unsupported fields and are ignored", "rules", policy.Status.UnsupportedRules)
+		pc.eventRecorder.Event(updated, v1.EventTypeWarning, policyReasonUnsupportedFields, unsupportedConditionMessage(rules))
+	} else {
+		logger.Info("all policy rules are supported")
+	}
+}
@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/openapi"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// mixedPolicyRaw has supported rules, including a foreach rule, and a rule using fields of a newer version
+var mixedPolicyRaw = []byte(`{
+	"apiVersion": "kyverno.io/v1",
+	"kind": "ClusterPolicy",
+	"metadata": {"name": "require-labels"},
+	"spec": {
+		"validationFailureAction": "enforce",
+		"background": false,
+		"rules": [
+			{
+				"name": "check-team",
+				"match": {"resources": {"kinds": ["Pod"]}, "roles": ["default:admin"]},
+				"validate": {"message": "label team is required", "pattern": {"metadata": {"labels": {"team": "?*", "unknown": "?*"}}}}
+			},
+			{
+				"name": "check-images",
+				"match": {"resources": {"kinds": ["Pod"]}},
+				"validate": {"message": "images must be pinned", "foreach": [{"list": "request.object.spec.containers", "pattern": {"image": "*:*"}}]}
+			},
+			{
+				"name": "check-owner",
+				"match": {"any": [{"resources": {"kinds": ["Deployment"]}}], "resources": {"kinds": ["Deployment"], "namespaceSelectors": {}}},
+				"validate": {"message": "label owner is required", "pattern": {"metadata": {"labels": {"owner": "?*"}}}}
+			}
+		]
+	}
+}`)
+
+func Test_UnsupportedFields(t *testing.T) {
+	rules, fields, err := UnsupportedFields(mixedPolicyRaw)
+	assert.NilError(t, err)
+	assert.Equal(t, len(fields), 0)
+
+	// the free-form patterns and the inlined user info are not unknown fields
+	assert.DeepEqual(t, rules, []UnsupportedRule{
+		{Name: "check-owner", Fields: []string{"spec.rules[2].match.any", "spec.rules[2].match.resources.namespaceSelectors"}},
+	})
+
+	// the unknown fields outside of the rules are reported separately
+	rules, fields, err = UnsupportedFields([]byte(`{"spec": {"generateExistingOnPolicyUpdate": true, "rules": [{"name": "check-team", "match": {"resources": {"kinds": ["Pod"]}}}]}}`))
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 0)
+	assert.DeepEqual(t, fields, []string{"spec.generateExistingOnPolicyUpdate"})
+
+	_, _, err = UnsupportedFields([]byte(`{"spec": `))
+	assert.ErrorContains(t, err, "failed to decode policy")
+}
+
+func Test_CheckUnsupportedFields_Reject(t *testing.T) {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(mixedPolicyRaw, &policy))
+
+	warning, err := CheckUnsupportedFields(&policy, mixedPolicyRaw, config.UnsupportedPolicyFieldsReject)
+	assert.Equal(t, warning, "")
+	assert.Error(t, err, "the policy uses fields that are not supported by this version of Kyverno, "+
+		"rule check-owner: spec.rules[2].match.any, spec.rules[2].match.resources.namespaceSelectors")
+
+	// the policy is not changed
+	assert.Equal(t, len(policy.Spec.Rules), 3)
+	assert.Assert(t, policy.Status.UnsupportedRules == nil)
+
+	// reject is the default
+	_, err = CheckUnsupportedFields(&policy, mixedPolicyRaw, "")
+	assert.Assert(t, err != nil)
+}
+
+func Test_CheckUnsupportedFields_Ignore(t *testing.T) {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(mixedPolicyRaw, &policy))
+
+	warning, err := CheckUnsupportedFields(&policy, mixedPolicyRaw, config.UnsupportedPolicyFieldsIgnore)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(warning, "the rules check-owner are ignored"), warning)
+
+	// the supported rules are kept and validated
+	assert.Equal(t, len(policy.Spec.Rules), 2)
+	assert.Equal(t, policy.Spec.Rules[0].Name, "check-team")
+	assert.Equal(t, policy.Spec.Rules[1].Name, "check-images")
+	assert.DeepEqual(t, policy.Status.UnsupportedRules, []string{"check-owner"})
+	openAPIController, _ := openapi.NewOpenAPIController()
+	assert.NilError(t, Validate(&policy, nil, true, openAPIController))
+
+	// the unknown fields outside of the rules are rejected
+	raw := []byte(`{"spec": {"generateExistingOnPolicyUpdate": true, "rules": [{"name": "check-team", "match": {"resources": {"kinds": ["Pod"]}}}]}}`)
+	_, err = CheckUnsupportedFields(&policy, raw, config.UnsupportedPolicyFieldsIgnore)
+	assert.ErrorContains(t, err, "spec.generateExistingOnPolicyUpdate")
+
+	// a supported policy is not changed
+	supported := []byte(`{"spec": {"rules": [{"name": "check-team", "match": {"resources": {"kinds": ["Pod"]}}}]}}`)
+	warning, err = CheckUnsupportedFields(&policy, supported, config.UnsupportedPolicyFieldsIgnore)
+	assert.NilError(t, err)
+	assert.Equal(t, warning, "")
+}
+
+func Test_Set_Unsupported_Condition(t *testing.T) {
+	status := &kyverno.PolicyStatus{}
+	assert.Assert(t, !setUnsupportedCondition(status, nil))
+	assert.Assert(t, status.Conditions == nil)
+
+	rules := []UnsupportedRule{{Name: "check-owner", Fields: []string{"spec.rules[2].match.any"}}}
+	assert.Assert(t, setUnsupportedCondition(status, rules))
+	assert.Assert(t, meta.IsStatusConditionTrue(status.Conditions, kyverno.PolicyConditionIgnoredUnsupported))
+	assert.DeepEqual(t, status.UnsupportedRules, []string{"check-owner"})
+	assert.Assert(t, !setUnsupportedCondition(status, rules))
+
+	// the rules are applied once the fields are removed
+	assert.Assert(t, setUnsupportedCondition(status, nil))
+	assert.Assert(t, status.Conditions == nil)
+	assert.Assert(t, status.UnsupportedRules == nil)
+}
+
+func Test_RemoveUnsupportedRules(t *testing.T) {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(mixedPolicyRaw, &policy))
+
+	policy.RemoveUnsupportedRules()
+	assert.Equal(t, len(policy.Spec.Rules), 3)
+
+	policy.Status.UnsupportedRules = []string{"check-owner"}
+	policy.RemoveUnsupportedRules()
+	assert.Equal(t, len(policy.Spec.Rules), 2)
+	assert.Equal(t, policy.Spec.Rules[0].Name, "check-team")
+	assert.Equal(t, policy.Spec.Rules[1].Name, "check-images")
+}
@@ -1,20 +1,25 @@
 package policy
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/jmespath/go-jmespath"
 	"github.com/kyverno/kyverno/pkg/engine"
 	"github.com/kyverno/kyverno/pkg/engine/variables"
+	"github.com/kyverno/kyverno/pkg/engine/wildcards"
 	"github.com/kyverno/kyverno/pkg/kyverno/common"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/openapi"
+	pm "github.com/kyverno/kyverno/pkg/policymutation"
 	"github.com/kyverno/kyverno/pkg/utils"
 	"github.com/minio/pkg/wildcard"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -43,12 +48,44 @@ func Validate(policy *kyverno.ClusterPolicy, client *dclient.Client, mock bool,
 	if path, err := validateUniqueRuleName(p); err != nil {
 		return fmt.Errorf("path: spec.%s: %v", path, err)
 	}
+
+	if err := validateFailurePolicy(p.Spec.FailurePolicy); err != nil {
+		return fmt.Errorf("path: spec.failurePolicy: %v", err)
+	}
+
+	if err := validateWebhookTimeoutSeconds(p.Spec.WebhookTimeoutSeconds); err != nil {
+		return fmt.Errorf("path: spec.webhookTimeoutSeconds: %v", err)
+	}
+
+	if err := validateSchedule(p); err != nil {
+		return fmt.Errorf("path: spec.schedule: %v", err)
+	}
+
+	if paths, ok := p.GetAnnotations()[pm.PodControllerTemplatePathsAnnotation]; ok {
+		if _, err := pm.ParseTemplatePaths(paths); err != nil {
+			return fmt.Errorf("path: metadata.annotations.%s: %v", pm.PodControllerTemplatePathsAnnotation, err)
+		}
+	}
+
+	if p.Spec.MutateExistingOnPolicyUpdate && !p.BackgroundProcessingEnabled() {
+		return fmt.Errorf("path: spec.mutateExistingOnPolicyUpdate: the existing resources are mutated in the background, set spec.background to true")
+	}
+
 	if p.Spec.Background == nil || *p.Spec.Background == true {
 		if err := ContainsVariablesOtherThanObject(p); err != nil {
 			return fmt.Errorf("only select variables are allowed in background mode. Set spec.background=false to disable background mode for this policy rule: %s ", err)
 		}
 	}
 
+	if err := checkRules(p, mock); err != nil {
+		return err
+	}
+
+	if !mock {
+		// the kinds are resolved once for all the rules of the policy
+		client = client.RequestScoped()
+	}
+
 	for i, rule := range p.Spec.Rules {
 		if jsonPatchOnPod(rule) {
 			log.Log.V(1).Info("warning: pods managed by workload controllers cannot be mutated using policies. Use the auto-gen feature or write policies that match pod controllers.")
@@ -69,31 +106,45 @@ func Validate(policy *kyverno.ClusterPolicy, client *dclient.Client, mock bool,
 			return fmt.Errorf("path: spec.rules[%d]: %v", i, err)
 		}
 
+		if path, err := validateRuleDocumentation(rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := engine.ValidateTimeWindows(rule.TimeWindows); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validateMinimumAge(p, rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validateAdmissionMatch(p, rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validateRuleScope(rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validateForEachNamespace(rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validatePreserveExisting(rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
+		if path, err := validateVerifyImages(rule); err != nil {
+			return fmt.Errorf("path: spec.rules[%d].%s: %v", i, path, err)
+		}
+
 		// validate Cluster Resources in namespaced policy
 		// For namespaced policy, ClusterResource type field and values are not allowed in match and exclude
 		if !mock && p.ObjectMeta.Namespace != "" {
-			var Empty struct{}
-			clusterResourcesMap := make(map[string]*struct{})
-			// Get all the cluster type kind supported by cluster
-
-			res, err := client.DiscoveryClient.DiscoveryCache().ServerPreferredResources()
+			clusterResources, err := clusterResourceKinds(client)
 			if err != nil {
 				return err
 			}
-			for _, resList := range res {
-				for _, r := range resList.APIResources {
-					if !r.Namespaced {
-						if _, ok := clusterResourcesMap[r.Kind]; !ok {
-							clusterResourcesMap[r.Kind] = &Empty
-						}
-					}
-				}
-			}
-
-			clusterResources := make([]string, 0, len(clusterResourcesMap))
-			for k := range clusterResourcesMap {
-				clusterResources = append(clusterResources, k)
-			}
 			return checkClusterResourceInMatchAndExclude(rule, clusterResources)
 		}
 
@@ -270,6 +321,31 @@ func doMatchAndExcludeConflict(rule kyverno.Rule) bool {
 		}
 	}
 
+	if len(rule.ExcludeResources.Operations) > 0 {
+		matchOperations := rule.MatchResources.Operations
+		if len(matchOperations) == 0 {
+			matchOperations = admissionOperations
+		}
+
+		for _, operation := range matchOperations {
+			if !utils.ContainsString(rule.ExcludeResources.Operations, operation) {
+				return false
+			}
+		}
+	}
+
+	if dryRun := rule.ExcludeResources.DryRun; dryRun != "" && dryRun != kyverno.DryRunAny {
+		if rule.MatchResources.DryRun != dryRun {
+			return false
+		}
+	}
+
+	if rule.ExcludeResources.SubjectsKind != "" {
+		if rule.MatchResources.SubjectsKind != rule.ExcludeResources.SubjectsKind {
+			return false
+		}
+	}
+
 	if rule.ExcludeResources.ResourceDescription.Name != "" {
 		if !wildcard.Match(rule.ExcludeResources.ResourceDescription.Name, rule.MatchResources.ResourceDescription.Name) {
 			return false
@@ -400,6 +476,75 @@ func doMatchAndExcludeConflict(rule kyverno.Rule) bool {
 	return true
 }
 
+// validateReachable checks that the match block of a rule can match a resource: a namespaced policy
+// only applies to the resources of its namespace, and a selector cannot require a label value that
+// it excludes
+func validateReachable(p kyverno.ClusterPolicy, rule kyverno.Rule) (string, error) {
+	rd := rule.MatchResources.ResourceDescription
+	if p.Namespace != "" && len(rd.Namespaces) > 0 {
+		reachable := false
+		for _, namespace := range rd.Namespaces {
+			if wildcard.Match(namespace, p.Namespace) {
+				reachable = true
+				break
+			}
+		}
+
+		if !reachable {
+			return "match.resources.namespaces", fmt.Errorf("the rule never matches, the policy only applies to the resources of its namespace %s", p.Namespace)
+		}
+	}
+
+	if err := validateSelectorSatisfiable(rd.Selector); err != nil {
+		return "match.resources.selector", err
+	}
+
+	if err := validateSelectorSatisfiable(rd.NamespaceSelector); err != nil {
+		return "match.resources.namespaceSelector", err
+	}
+
+	return "", nil
+}
+
+// validateSelectorSatisfiable checks that the match expressions of a selector do not contradict its match
+// labels or each other, the wildcard values are not checked
+func validateSelectorSatisfiable(selector *metav1.LabelSelector) error {
+	if selector == nil {
+		return nil
+	}
+
+	exists := make(map[string]bool)
+	for _, expression := range selector.MatchExpressions {
+		if expression.Operator == metav1.LabelSelectorOpExists || expression.Operator == metav1.LabelSelectorOpIn {
+			exists[expression.Key] = true
+		}
+	}
+
+	for _, expression := range selector.MatchExpressions {
+		value, ok := selector.MatchLabels[expression.Key]
+		if strings.ContainsAny(value, "*?") {
+			continue
+		}
+
+		switch expression.Operator {
+		case metav1.LabelSelectorOpDoesNotExist:
+			if ok || exists[expression.Key] {
+				return fmt.Errorf("the rule never matches, label %s is required to exist and not to exist", expression.Key)
+			}
+		case metav1.LabelSelectorOpIn:
+			if ok && !utils.ContainsString(expression.Values, value) {
+				return fmt.Errorf("the rule never matches, label %s is required to be %s and one of %s", expression.Key, value, strings.Join(expression.Values, ", "))
+			}
+		case metav1.LabelSelectorOpNotIn:
+			if ok && utils.ContainsString(expression.Values, value) {
+				return fmt.Errorf("the rule never matches, label %s is required to be %s and not to be one of %s", expression.Key, value, strings.Join(expression.Values, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
 // isLabelAndAnnotationsString :- Validate if labels and annotations contains only string values
 func isLabelAndAnnotationsString(rule kyverno.Rule) bool {
 	// checkMetadata - Verify if the labels and annotations contains string value inside metadata
@@ -622,6 +767,43 @@ func validateConditionValuesKeyRequestOperation(c kyverno.Condition) (string, er
 	return "", nil
 }
 
+// validateFailurePolicy checks that the failure policy is either empty, Ignore or Fail
+func validateFailurePolicy(failurePolicy kyverno.FailurePolicyType) error {
+	switch failurePolicy {
+	case "", kyverno.Ignore, kyverno.Fail:
+		return nil
+	default:
+		return fmt.Errorf("invalid value %q, supported values are %q and %q", failurePolicy, kyverno.Ignore, kyverno.Fail)
+	}
+}
+
+// validateWebhookTimeoutSeconds checks that the webhook timeout is within the range accepted by the API server
+func validateWebhookTimeoutSeconds(timeoutSeconds *int32) error {
+	if timeoutSeconds == nil {
+		return nil
+	}
+
+	if *timeoutSeconds < 1 || *timeoutSeconds > 30 {
+		return fmt.Errorf("invalid value %d, expect a value between 1 and 30", *timeoutSeconds)
+	}
+
+	return nil
+}
+
+// validateSchedule checks the cron schedule of the background scans
+func validateSchedule(p kyverno.ClusterPolicy) error {
+	if p.Spec.Schedule == "" {
+		return nil
+	}
+
+	if !p.BackgroundProcessingEnabled() {
+		return fmt.Errorf("the schedule applies to the background scans, set spec.background to true")
+	}
+
+	_, err := parseSchedule(&p)
+	return err
+}
+
 // validateUniqueRuleName checks if the rule names are unique across a policy
 func validateUniqueRuleName(p kyverno.ClusterPolicy) (string, error) {
 	var ruleNames []string
@@ -635,6 +817,35 @@ func validateUniqueRuleName(p kyverno.ClusterPolicy) (string, error) {
 	return "", nil
 }
 
+// ruleIDRegex matches the rule IDs, e.g. "SEC-014" or "cis.5.2.1"
+var ruleIDRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+// validateRuleDocumentation checks the format of the rule ID and documentation URL
+func validateRuleDocumentation(r kyverno.Rule) (string, error) {
+	if r.ID != "" {
+		if len(r.ID) > 63 {
+			return "id", fmt.Errorf("invalid rule ID %s: must be no more than 63 characters", r.ID)
+		}
+
+		if !ruleIDRegex.MatchString(r.ID) {
+			return "id", fmt.Errorf("invalid rule ID %s: must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character", r.ID)
+		}
+	}
+
+	if r.DocumentationURL != "" {
+		u, err := url.Parse(r.DocumentationURL)
+		if err != nil {
+			return "documentationURL", fmt.Errorf("invalid documentation URL %s: %v", r.DocumentationURL, err)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "documentationURL", fmt.Errorf("invalid documentation URL %s: must be an absolute http or https URL", r.DocumentationURL)
+		}
+	}
+
+	return "", nil
+}
+
 // validateRuleType checks only one type of rule is defined per rule
 func validateRuleType(r kyverno.Rule) error {
 	ruleTypes := []bool{r.HasMutate(), r.HasValidate(), r.HasGenerate(), r.HasVerifyImages()}
@@ -658,11 +869,13 @@ func validateRuleType(r kyverno.Rule) error {
 }
 
 func validateRuleContext(rule kyverno.Rule) error {
-	if rule.Context == nil || len(rule.Context) == 0 {
+	// the context entries of the foreach declarations are loaded for each element
+	entries := append(append([]kyverno.ContextEntry{}, rule.Context...), rule.ForEachContext()...)
+	if len(entries) == 0 {
 		return nil
 	}
 
-	for _, entry := range rule.Context {
+	for _, entry := range entries {
 		if entry.Name == "" {
 			return fmt.Errorf("a name is required for context entries")
 		}
@@ -701,6 +914,12 @@ func validateConfigMap(entry kyverno.ContextEntry) error {
 		return fmt.Errorf("a namespace is required for configMap context entry")
 	}
 
+	for _, fallback := range entry.ConfigMap.Fallbacks {
+		if fallback.Name == "" {
+			return fmt.Errorf("a name is required for configMap fallbacks")
+		}
+	}
+
 	return nil
 }
 
@@ -713,11 +932,39 @@ func validateAPICall(entry kyverno.ContextEntry) error {
 		return fmt.Errorf("both configMap and apiCall are not allowed in a context entry")
 	}
 
-	// Replace all variables to prevent validation failing on variable keys.
-	urlPath := variables.ReplaceAllVars(entry.APICall.URLPath, func(s string) string { return "kyvernoapicallvariable" })
+	if entry.APICall.Service != nil {
+		if entry.APICall.URLPath != "" {
+			return fmt.Errorf("both urlPath and service are not allowed in apiCall context entry %s", entry.Name)
+		}
 
-	if _, err := engine.NewAPIPath(urlPath); err != nil {
-		return err
+		if err := validateServiceCall(entry.APICall.Service); err != nil {
+			return fmt.Errorf("invalid service in apiCall context entry %s: %v", entry.Name, err)
+		}
+	} else {
+		if entry.APICall.URLPath == "" {
+			return fmt.Errorf("a urlPath or service is required for apiCall context entry %s", entry.Name)
+		}
+
+		// Replace all variables to prevent validation failing on variable keys.
+		urlPath := variables.ReplaceAllVars(entry.APICall.URLPath, func(s string) string { return "kyvernoapicallvariable" })
+
+		if _, err := engine.NewAPIPath(urlPath); err != nil {
+			return err
+		}
+	}
+
+	if entry.APICall.Timeout != nil && entry.APICall.Timeout.Duration <= 0 {
+		return fmt.Errorf("the timeout of apiCall context entry %s must be positive", entry.Name)
+	}
+
+	if entry.APICall.CacheTTL != nil && entry.APICall.CacheTTL.Duration < 0 {
+		return fmt.Errorf("the cacheTTL of apiCall context entry %s must not be negative", entry.Name)
+	}
+
+	switch entry.APICall.FailurePolicy {
+	case "", kyverno.Fail, kyverno.Ignore:
+	default:
+		return fmt.Errorf("invalid failurePolicy %q of apiCall context entry %s, expect %s or %s", entry.APICall.FailurePolicy, entry.Name, kyverno.Fail, kyverno.Ignore)
 	}
 
 	// If JMESPath contains variables, the validation will fail because it's not possible to infer which value
@@ -735,6 +982,26 @@ func validateAPICall(entry kyverno.ContextEntry) error {
 	return nil
 }
 
+// validateServiceCall checks that the URL of the service is a HTTP(S) URL, and that its CA bundle is PEM encoded
+func validateServiceCall(service *kyverno.ServiceCall) error {
+	// Replace all variables to prevent validation failing on variable keys.
+	rawURL := variables.ReplaceAllVars(service.URL, func(s string) string { return "kyvernoapicallvariable" })
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse url %s: %v", service.URL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %s must be a http or https URL", service.URL)
+	}
+
+	if service.CABundle != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(service.CABundle)) {
+		return fmt.Errorf("caBundle must be a PEM encoded certificate")
+	}
+
+	return nil
+}
+
 // validateResourceDescription checks if all necessary fields are present and have values. Also checks a Selector.
 // field type is checked through openapi
 // Returns error if
@@ -839,6 +1106,309 @@ func validateResourceDescription(rd kyverno.ResourceDescription) error {
 	return nil
 }
 
+// validateMinimumAge returns error if the minimum age of the matched resources is set on a rule
+// applied to admission requests, as the resources are created by the requests, or on a rule
+// other than a validate rule of a background policy
+func validateMinimumAge(p kyverno.ClusterPolicy, rule kyverno.Rule) (string, error) {
+	if rule.ExcludeResources.MinimumAge != nil {
+		return "exclude.resources.minimumAge", fmt.Errorf("minimumAge is only supported in match resources")
+	}
+
+	minimumAge := rule.MatchResources.MinimumAge
+	if minimumAge == nil {
+		return "", nil
+	}
+
+	if minimumAge.Duration <= 0 {
+		return "match.resources.minimumAge", fmt.Errorf("minimumAge must be a positive duration, got %s", minimumAge.Duration.String())
+	}
+
+	if !rule.HasValidate() {
+		return "match.resources.minimumAge", fmt.Errorf("minimumAge is only supported in validate rules")
+	}
+
+	if p.AdmissionProcessingEnabled() {
+		return "match.resources.minimumAge", fmt.Errorf("minimumAge is only supported in background only policies, set spec.admission to false")
+	}
+
+	if !p.BackgroundProcessingEnabled() {
+		return "match.resources.minimumAge", fmt.Errorf("minimumAge is only supported in background only policies, set spec.background to true")
+	}
+
+	return "", nil
+}
+
+// admissionOperations are the operations of the admission requests
+var admissionOperations = []string{"CREATE", "UPDATE", "DELETE", "CONNECT"}
+
+// validateAdmissionMatch returns error if the operations, the dry-run or the subjects kind of the match
+// and exclude blocks are invalid, or if the rule cannot match any request with them: the mutate, generate
+// and verifyImages rules only receive the CREATE and UPDATE requests, and the background scans neither
+// delete nor dry-run the resources
+func validateAdmissionMatch(p kyverno.ClusterPolicy, rule kyverno.Rule) (string, error) {
+	blocks := []struct {
+		path        string
+		description kyverno.ResourceDescription
+		userInfo    kyverno.UserInfo
+	}{
+		{"match", rule.MatchResources.ResourceDescription, rule.MatchResources.UserInfo},
+		{"exclude", rule.ExcludeResources.ResourceDescription, rule.ExcludeResources.UserInfo},
+	}
+
+	for _, block := range blocks {
+		for _, operation := range block.description.Operations {
+			if !utils.ContainsString(admissionOperations, operation) {
+				return block.path + ".resources.operations", fmt.Errorf("invalid operation %q, expect one of %v", operation, admissionOperations)
+			}
+		}
+
+		switch block.description.DryRun {
+		case "", kyverno.DryRunTrue, kyverno.DryRunFalse, kyverno.DryRunAny:
+		default:
+			return block.path + ".resources.dryRun", fmt.Errorf("invalid dryRun %q, expect true, false or any", block.description.DryRun)
+		}
+
+		if path, err := validateSubjectsKind(block.userInfo); err != nil {
+			return block.path + "." + path, err
+		}
+	}
+
+	operations := rule.MatchResources.Operations
+	if len(operations) > 0 && (rule.HasMutate() || rule.HasGenerate() || rule.HasVerifyImages()) &&
+		!utils.ContainsString(operations, "CREATE") && !utils.ContainsString(operations, "UPDATE") {
+		return "match.resources.operations", fmt.Errorf("mutate, generate and verifyImages rules only match CREATE and UPDATE requests, got %v", operations)
+	}
+
+	// the background scans have no admission request, the rules restricted to operations or
+	// dry-run requests would match all the existing resources
+	if p.BackgroundProcessingEnabled() {
+		for _, block := range blocks {
+			if len(block.description.Operations) > 0 {
+				return block.path + ".resources.operations", fmt.Errorf("the background scans have no operation, set spec.background to false")
+			}
+
+			if block.description.DryRun == kyverno.DryRunTrue || block.description.DryRun == kyverno.DryRunFalse {
+				return block.path + ".resources.dryRun", fmt.Errorf("the background scans have no dry-run, set spec.background to false")
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// validateSubjectsKind returns error if the subjects kind is invalid, or if it conflicts with the kinds of the subjects
+func validateSubjectsKind(userInfo kyverno.UserInfo) (string, error) {
+	switch userInfo.SubjectsKind {
+	case "":
+		return "", nil
+	case "User", "ServiceAccount", "Group":
+	default:
+		return "subjectsKind", fmt.Errorf("invalid subjectsKind %q, expect User, ServiceAccount or Group", userInfo.SubjectsKind)
+	}
+
+	for _, subject := range userInfo.Subjects {
+		if subject.Kind != userInfo.SubjectsKind {
+			return "subjects", fmt.Errorf("subject %s of kind %s conflicts with subjectsKind %s", subject.Name, subject.Kind, userInfo.SubjectsKind)
+		}
+	}
+
+	if userInfo.SubjectsKind == "Group" && len(userInfo.Subjects) == 0 {
+		return "subjectsKind", fmt.Errorf("subjectsKind Group requires the Group subjects to match")
+	}
+
+	return "", nil
+}
+
+// namespaceScopeVariable matches the variables of the admission request and of the resource that
+// differ between the resources of a namespace
+var namespaceScopeVariable = regexp.MustCompile(`(?:^|[^.\w])(request\.[A-Za-z]+|serviceAccountName|serviceAccountNamespace|images)\b`)
+
+// validateRuleScope returns error if a namespace scoped rule depends on the resource. The response
+// of the rule is cached for the namespace, it can only use the namespace, request.namespace, and
+// the ConfigMaps of its context, in the preconditions and the deny conditions of a validate rule.
+func validateRuleScope(rule kyverno.Rule) (string, error) {
+	switch rule.Scope {
+	case "", kyverno.ResourceScope:
+		return "", nil
+	case kyverno.NamespaceScope:
+	default:
+		return "scope", fmt.Errorf("invalid scope %q, expect %s or %s", rule.Scope, kyverno.ResourceScope, kyverno.NamespaceScope)
+	}
+
+	if !rule.HasValidate() || rule.Validation.Deny == nil {
+		return "scope", fmt.Errorf("scope %s is only supported in validate rules with deny conditions", kyverno.NamespaceScope)
+	}
+
+	if rule.Validation.Pattern != nil || rule.Validation.AnyPattern != nil {
+		return "validate", fmt.Errorf("pattern and anyPattern are not supported with scope %s, use deny conditions", kyverno.NamespaceScope)
+	}
+
+	for _, entry := range rule.Context {
+		if entry.APICall != nil {
+			return "context", fmt.Errorf("context entry %s: apiCall is not supported with scope %s, only configMap entries are cached", entry.Name, kyverno.NamespaceScope)
+		}
+	}
+
+	paths := []string{"context", "preconditions", "validate"}
+	for i, document := range []interface{}{rule.Context, rule.AnyAllConditions, rule.Validation} {
+		path := paths[i]
+		raw, err := json.Marshal(document)
+		if err != nil {
+			return path, err
+		}
+
+		if variables.RegexReferences.Match(raw) {
+			return path, fmt.Errorf("references are not supported with scope %s", kyverno.NamespaceScope)
+		}
+
+		for _, variable := range variables.RegexVariables.FindAllString(string(raw), -1) {
+			for _, match := range namespaceScopeVariable.FindAllStringSubmatch(variable, -1) {
+				if match[1] != "request.namespace" {
+					return path, fmt.Errorf("variable %s depends on the resource, only request.namespace and the context entries are supported with scope %s", variable, kyverno.NamespaceScope)
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// validateForEachNamespace returns error if a forEachNamespace generate rule does not match the
+// namespaces or has an invalid selector, the namespaces are the trigger resources of the rule
+func validateForEachNamespace(rule kyverno.Rule) (string, error) {
+	if !rule.IsForEachNamespace() {
+		return "", nil
+	}
+
+	kinds := rule.MatchResources.Kinds
+	if len(kinds) != 1 || kinds[0] != "Namespace" {
+		return "match.resources.kinds", fmt.Errorf("forEachNamespace requires the rule to match the Namespace kind only")
+	}
+
+	selector := rule.Generation.ForEachNamespace.Selector
+	if selector == nil {
+		return "generate.forEachNamespace.selector", fmt.Errorf("a selector is required")
+	}
+
+	// the wildcards in matchLabels are supported
+	selector = selector.DeepCopy()
+	wildcards.ReplaceInSelector(selector, map[string]string{})
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return "generate.forEachNamespace.selector", fmt.Errorf("invalid selector: %v", err)
+	}
+
+	return "", nil
+}
+
+// validatePreserveExisting checks that preserveExisting is only set on the patchStrategicMerge
+// and overlay mutations, the JSON patches are applied as they are
+func validatePreserveExisting(rule kyverno.Rule) (string, error) {
+	if !rule.HasMutate() || !rule.Mutation.PreserveExisting {
+		return "", nil
+	}
+
+	if rule.Mutation.PatchStrategicMerge == nil && rule.Mutation.Overlay == nil {
+		return "mutate.preserveExisting", fmt.Errorf("preserveExisting requires a patchStrategicMerge or an overlay")
+	}
+
+	return "", nil
+}
+
+// validateVerifyImages checks the keys, the keyless options and the attestations of the image verifications
+func validateVerifyImages(rule kyverno.Rule) (string, error) {
+	for i, imageVerify := range rule.VerifyImages {
+		if imageVerify == nil {
+			continue
+		}
+
+		path := fmt.Sprintf("verifyImages[%d]", i)
+		if imageVerify.Image == "" {
+			return path + ".image", fmt.Errorf("an image pattern is required")
+		}
+
+		if imageVerify.Key != "" && len(imageVerify.Keys) > 0 {
+			return path, fmt.Errorf("key and keys are mutually exclusive")
+		}
+
+		for j, key := range imageVerify.Keys {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Sprintf("%s.keys[%d]", path, j), fmt.Errorf("empty key")
+			}
+		}
+
+		if imageVerify.Count < 0 || imageVerify.Count > len(imageVerify.Keys) {
+			return path + ".count", fmt.Errorf("count must be between 0 and the number of keys (%d), 0 for all the keys", len(imageVerify.Keys))
+		}
+
+		keyless := imageVerify.Key == "" && len(imageVerify.Keys) == 0
+		if !keyless && imageVerify.Subject != "" {
+			return path + ".subject", fmt.Errorf("the subject of the keyless signatures requires no key")
+		}
+
+		if !keyless && imageVerify.Roots != "" {
+			return path + ".roots", fmt.Errorf("the roots of the keyless signatures require no key")
+		}
+
+		if imageVerify.Roots != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(imageVerify.Roots)) {
+			return path + ".roots", fmt.Errorf("no PEM encoded certificate")
+		}
+
+		if imageVerify.Rekor != "" {
+			if u, err := url.Parse(imageVerify.Rekor); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return path + ".rekor", fmt.Errorf("invalid Rekor URL %s: must be an absolute http or https URL", imageVerify.Rekor)
+			}
+		}
+
+		for j, attestation := range imageVerify.Attestations {
+			attestationPath := fmt.Sprintf("%s.attestations[%d]", path, j)
+			if keyless {
+				return attestationPath, fmt.Errorf("the attestations are verified with the keys, a key is required")
+			}
+
+			if attestation.PredicateType == "" {
+				return attestationPath + ".predicateType", fmt.Errorf("a predicate type is required")
+			}
+
+			if attestation.Conditions != nil {
+				if _, err := utils.ApiextensionsJsonToKyvernoConditions(attestation.Conditions); err != nil {
+					return attestationPath + ".conditions", err
+				}
+			}
+
+			if attestation.SeverityFrom != nil {
+				if _, err := jmespath.NewParser().Parse(attestation.SeverityFrom.JMESPath); err != nil {
+					return attestationPath + ".severityFrom.jmesPath", fmt.Errorf("invalid JMESPath %s: %v", attestation.SeverityFrom.JMESPath, err)
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// clusterResourceKinds returns the kinds of the cluster wide resources supported by the cluster
+func clusterResourceKinds(client *dclient.Client) ([]string, error) {
+	res, err := client.DiscoveryClient.DiscoveryCache().ServerPreferredResources()
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]struct{})
+	for _, resList := range res {
+		for _, r := range resList.APIResources {
+			if !r.Namespaced {
+				kinds[r.Kind] = struct{}{}
+			}
+		}
+	}
+
+	clusterResources := make([]string, 0, len(kinds))
+	for k := range kinds {
+		clusterResources = append(clusterResources, k)
+	}
+	return clusterResources, nil
+}
+
 // checkClusterResourceInMatchAndExclude returns false if namespaced ClusterPolicy contains cluster wide resources in
 // Match and Exclude block
 func checkClusterResourceInMatchAndExclude(rule kyverno.Rule, clusterResources []string) error {
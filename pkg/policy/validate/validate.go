@@ -2,9 +2,13 @@ package validate
 
 import (
 	"fmt"
+	"strings"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	commonAnchors "github.com/kyverno/kyverno/pkg/engine/anchor/common"
+	"github.com/kyverno/kyverno/pkg/engine/cel"
+	"github.com/kyverno/kyverno/pkg/engine/deprecations"
+	"github.com/kyverno/kyverno/pkg/engine/jmespath"
 	"github.com/kyverno/kyverno/pkg/policy/common"
 )
 
@@ -31,7 +35,7 @@ func (v *Validate) Validate() (string, error) {
 	}
 
 	if rule.Pattern != nil {
-		if path, err := common.ValidatePattern(rule.Pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor}); err != nil {
+		if path, err := common.ValidatePattern(rule.Pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor, commonAnchors.IsSelectionAnchor, commonAnchors.IsGlobalAnchor}); err != nil {
 			return fmt.Sprintf("pattern.%s", path), err
 		}
 	}
@@ -42,24 +46,125 @@ func (v *Validate) Validate() (string, error) {
 			return "anyPattern", fmt.Errorf("failed to deserialize anyPattern, expect array: %v", err)
 		}
 		for i, pattern := range anyPattern {
-			if path, err := common.ValidatePattern(pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor}); err != nil {
+			if path, err := common.ValidatePattern(pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor, commonAnchors.IsSelectionAnchor, commonAnchors.IsGlobalAnchor}); err != nil {
 				return fmt.Sprintf("anyPattern[%d].%s", i, path), err
 			}
 		}
 	}
+
+	if rule.Deprecations != nil && rule.Deprecations.TargetVersion != "" {
+		if _, err := deprecations.ParseVersion(rule.Deprecations.TargetVersion); err != nil {
+			return "deprecations.targetVersion", err
+		}
+	}
+
+	for i, ref := range rule.References {
+		if ref.Path == "" {
+			return fmt.Sprintf("references[%d].path", i), fmt.Errorf("path must be specified")
+		}
+
+		if _, err := jmespath.New(ref.Path); err != nil {
+			return fmt.Sprintf("references[%d].path", i), fmt.Errorf("invalid JMESPath %s: %v", ref.Path, err)
+		}
+
+		if ref.Kind == "" {
+			return fmt.Sprintf("references[%d].kind", i), fmt.Errorf("kind must be specified")
+		}
+	}
+
+	if rule.CEL != nil {
+		if len(rule.CEL.Expressions) == 0 {
+			return "cel.expressions", fmt.Errorf("at least one expression must be specified")
+		}
+
+		for i, expression := range rule.CEL.Expressions {
+			if _, err := cel.Compile(expression.Expression); err != nil {
+				return fmt.Sprintf("cel.expressions[%d].expression", i), err
+			}
+		}
+	}
+
+	for i, foreach := range rule.ForEachValidation {
+		if path, err := validateForEach(foreach); err != nil {
+			if path == "" {
+				return fmt.Sprintf("foreach[%d]", i), err
+			}
+			return fmt.Sprintf("foreach[%d].%s", i, path), err
+		}
+	}
+	return "", nil
+}
+
+// validateForEach checks the list of the foreach declaration and that exactly one of pattern, anyPattern
+// or deny is specified
+func validateForEach(foreach kyverno.ForEachValidation) (string, error) {
+	if foreach.List == "" {
+		return "list", fmt.Errorf("list must be specified")
+	}
+
+	list := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(foreach.List), "{{"), "}}"))
+	if _, err := jmespath.New(list); err != nil {
+		return "list", fmt.Errorf("invalid JMESPath %s: %v", foreach.List, err)
+	}
+
+	count := 0
+	for _, set := range []bool{foreach.Pattern != nil, foreach.AnyPattern != nil, foreach.Deny != nil} {
+		if set {
+			count++
+		}
+	}
+
+	if count != 1 {
+		return "", fmt.Errorf("only one of pattern, anyPattern or deny must be specified")
+	}
+
+	if foreach.Pattern != nil {
+		if path, err := common.ValidatePattern(foreach.Pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor, commonAnchors.IsSelectionAnchor, commonAnchors.IsGlobalAnchor}); err != nil {
+			return fmt.Sprintf("pattern.%s", path), err
+		}
+	}
+
+	if foreach.AnyPattern != nil {
+		anyPattern, err := (&kyverno.Validation{AnyPattern: foreach.AnyPattern}).DeserializeAnyPattern()
+		if err != nil {
+			return "anyPattern", fmt.Errorf("failed to deserialize anyPattern, expect array: %v", err)
+		}
+		for i, pattern := range anyPattern {
+			if path, err := common.ValidatePattern(pattern, "/", []commonAnchors.IsAnchor{commonAnchors.IsConditionAnchor, commonAnchors.IsExistenceAnchor, commonAnchors.IsEqualityAnchor, commonAnchors.IsNegationAnchor, commonAnchors.IsSelectionAnchor, commonAnchors.IsGlobalAnchor}); err != nil {
+				return fmt.Sprintf("anyPattern[%d].%s", i, path), err
+			}
+		}
+	}
+
 	return "", nil
 }
 
 // validateOverlayPattern checks one of pattern/anyPattern must exist
 func (v *Validate) validateOverlayPattern() error {
 	rule := v.rule
-	if rule.Pattern == nil && rule.AnyPattern == nil && rule.Deny == nil {
-		return fmt.Errorf("pattern, anyPattern or deny must be specified")
+	if rule.Pattern == nil && rule.AnyPattern == nil && rule.Deny == nil && rule.Deprecations == nil && len(rule.References) == 0 && rule.CEL == nil && len(rule.ForEachValidation) == 0 {
+		return fmt.Errorf("pattern, anyPattern, deny, deprecations, references, cel or foreach must be specified")
 	}
 
 	if rule.Pattern != nil && rule.AnyPattern != nil {
 		return fmt.Errorf("only one operation allowed per validation rule(pattern or anyPattern)")
 	}
 
+	if rule.Deprecations != nil && (rule.Pattern != nil || rule.AnyPattern != nil || rule.Deny != nil) {
+		return fmt.Errorf("deprecations cannot be combined with pattern, anyPattern or deny")
+	}
+
+	if len(rule.References) > 0 && (rule.Pattern != nil || rule.AnyPattern != nil || rule.Deny != nil || rule.Deprecations != nil) {
+		return fmt.Errorf("references cannot be combined with pattern, anyPattern, deny or deprecations")
+	}
+
+	if rule.CEL != nil && (rule.Pattern != nil || rule.AnyPattern != nil || rule.Deny != nil || rule.Deprecations != nil || len(rule.References) > 0) {
+		return fmt.Errorf("cel cannot be combined with pattern, anyPattern, deny, deprecations or references")
+	}
+
+	if len(rule.ForEachValidation) > 0 && (rule.Pattern != nil || rule.AnyPattern != nil || rule.Deny != nil || rule.Deprecations != nil || len(rule.References) > 0 || rule.CEL != nil) {
+		return fmt.Errorf("foreach cannot be combined with pattern, anyPattern, deny, deprecations, references or cel")
+	}
+
 	return nil
 }
@@ -379,3 +379,118 @@ func Test_Validate_Validate_Unsupported(t *testing.T) {
 	}
 
 }
+
+func Test_Validate_Deprecations(t *testing.T) {
+	testcases := []struct {
+		validation string
+		path       string
+		err        string
+	}{
+		{validation: `{"deprecations": {}}`},
+		{validation: `{"deprecations": {"targetVersion": "1.25"}}`},
+		{validation: `{"deprecations": {"targetVersion": "next"}}`, path: "deprecations.targetVersion", err: `invalid Kubernetes version "next"`},
+		{validation: `{"deprecations": {}, "deny": {}}`, err: "deprecations cannot be combined with pattern, anyPattern or deny"},
+	}
+
+	for _, tc := range testcases {
+		var validation kyverno.Validation
+		assert.NilError(t, json.Unmarshal([]byte(tc.validation), &validation))
+
+		path, err := NewValidateFactory(validation).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.validation)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
+
+func Test_Validate_References(t *testing.T) {
+	testcases := []struct {
+		validation string
+		path       string
+		err        string
+	}{
+		{validation: `{"references": [{"path": "spec.tls[].secretName", "kind": "Secret"}]}`},
+		{validation: `{"references": [{"path": "spec.ingressClassName", "kind": "IngressClass", "allowMissing": true}]}`},
+		{validation: `{"references": [{"kind": "Secret"}]}`, path: "references[0].path", err: "path must be specified"},
+		{validation: `{"references": [{"path": "spec.tls[", "kind": "Secret"}]}`, path: "references[0].path", err: "invalid JMESPath spec.tls["},
+		{validation: `{"references": [{"path": "spec.tls[].secretName"}]}`, path: "references[0].kind", err: "kind must be specified"},
+		{validation: `{"references": [{"path": "spec.tls[].secretName", "kind": "Secret"}], "deny": {}}`, err: "references cannot be combined with pattern, anyPattern, deny or deprecations"},
+	}
+
+	for _, tc := range testcases {
+		var validation kyverno.Validation
+		assert.NilError(t, json.Unmarshal([]byte(tc.validation), &validation))
+
+		path, err := NewValidateFactory(validation).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.validation)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
+
+func Test_Validate_CEL(t *testing.T) {
+	testcases := []struct {
+		validation string
+		path       string
+		err        string
+	}{
+		{validation: `{"cel": {"expressions": [{"expression": "object.spec.replicas <= 5", "message": "at most 5 replicas"}]}}`},
+		{validation: `{"cel": {"expressions": [{"expression": "has(object.metadata.labels)"}, {"expression": "request.operation != 'DELETE'"}]}}`},
+		{validation: `{"cel": {"expressions": []}}`, path: "cel.expressions", err: "at least one expression must be specified"},
+		{validation: `{"cel": {"expressions": [{"expression": "true"}, {"expression": "object.spec.replicas <="}]}}`, path: "cel.expressions[1].expression", err: "invalid CEL expression"},
+		{validation: `{"cel": {"expressions": [{"expression": "object.metadata.name + '-a'"}]}}`, path: "cel.expressions[0].expression", err: "must return a boolean"},
+		{validation: `{"cel": {"expressions": [{"expression": "true"}]}, "deny": {}}`, err: "cel cannot be combined with pattern, anyPattern, deny, deprecations or references"},
+	}
+
+	for _, tc := range testcases {
+		var validation kyverno.Validation
+		assert.NilError(t, json.Unmarshal([]byte(tc.validation), &validation))
+
+		path, err := NewValidateFactory(validation).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.validation)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
+
+func Test_Validate_ForEach(t *testing.T) {
+	testcases := []struct {
+		validation string
+		path       string
+		err        string
+	}{
+		{validation: `{"foreach": [{"list": "request.object.spec.containers", "pattern": {"image": "ghcr.io/*"}}]}`},
+		{validation: `{"foreach": [{"list": "{{request.object.spec.containers}}", "deny": {"conditions": [{"key": "{{element.name}}", "operator": "Equals", "value": "debug"}]}}]}`},
+		{validation: `{"foreach": [{"pattern": {"image": "ghcr.io/*"}}]}`, path: "foreach[0].list", err: "list must be specified"},
+		{validation: `{"foreach": [{"list": "request.object.spec.containers[", "pattern": {"image": "ghcr.io/*"}}]}`, path: "foreach[0].list", err: "invalid JMESPath"},
+		{validation: `{"foreach": [{"list": "request.object.spec.containers"}]}`, path: "foreach[0]", err: "only one of pattern, anyPattern or deny must be specified"},
+		{validation: `{"foreach": [{"list": "request.object.spec.containers", "pattern": {"image": "ghcr.io/*"}, "deny": {}}]}`, path: "foreach[0]", err: "only one of pattern, anyPattern or deny must be specified"},
+		{validation: `{"foreach": [{"list": "request.object.spec.containers", "pattern": {"image": "ghcr.io/*"}}], "deny": {}}`, err: "foreach cannot be combined with pattern, anyPattern, deny, deprecations, references or cel"},
+	}
+
+	for _, tc := range testcases {
+		var validation kyverno.Validation
+		assert.NilError(t, json.Unmarshal([]byte(tc.validation), &validation))
+
+		path, err := NewValidateFactory(validation).Validate()
+		if tc.err == "" {
+			assert.NilError(t, err, tc.validation)
+			continue
+		}
+
+		assert.ErrorContains(t, err, tc.err)
+		assert.Equal(t, path, tc.path)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/background"
 	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
 	"github.com/kyverno/kyverno/pkg/client/clientset/versioned/scheme"
 	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
@@ -60,6 +61,7 @@ type PolicyController struct {
 	kyvernoClient *kyvernoclient.Clientset
 	pInformer     kyvernoinformer.ClusterPolicyInformer
 	npInformer    kyvernoinformer.PolicyInformer
+	psInformer    kyvernoinformer.PolicySetInformer
 
 	eventGen      event.Interface
 	eventRecorder record.EventRecorder
@@ -67,24 +69,45 @@ type PolicyController struct {
 	// Policies that need to be synced
 	queue workqueue.RateLimitingInterface
 
+	// scans holds the rules to re-evaluate of the queued policies, the updated policies are scanned partially
+	scans *scanDeltas
+
+	// schedules holds the last scheduled scans of the policies with a schedule
+	schedules *scanSchedules
+
+	// psQueue coalesces policy set and policy label changes that require policy sets to be reconciled
+	psQueue workqueue.RateLimitingInterface
+
+	// statusQueue holds the keys of the policies whose Disabled condition is reconciled
+	statusQueue workqueue.RateLimitingInterface
+
 	// pLister can list/get policy from the shared informer's store
 	pLister kyvernolister.ClusterPolicyLister
 
 	// npLister can list/get namespace policy from the shared informer's store
 	npLister kyvernolister.PolicyLister
 
+	// psLister can list/get policy sets from the shared informer's store
+	psLister kyvernolister.PolicySetLister
+
 	// grLister can list/get generate request from the shared informer's store
 	grLister kyvernolister.GenerateRequestLister
 
 	// nsLister can list/get namespaces from the shared informer's store
 	nsLister listerv1.NamespaceLister
 
+	// pexLister can list policy exceptions from the shared informer's store, it is nil if the policy exceptions are disabled
+	pexLister kyvernolister.PolicyExceptionLister
+
 	// pListerSynced returns true if the cluster policy store has been synced at least once
 	pListerSynced cache.InformerSynced
 
 	// npListerSynced returns true if the namespace policy store has been synced at least once
 	npListerSynced cache.InformerSynced
 
+	// psListerSynced returns true if the policy set store has been synced at least once
+	psListerSynced cache.InformerSynced
+
 	// pvListerSynced returns true if the cluster policy violation store has been synced at least once
 	cpvListerSynced cache.InformerSynced
 
@@ -111,7 +134,11 @@ type PolicyController struct {
 	// resCache - controls creation and fetching of resource informer cache
 	resCache resourcecache.ResourceCache
 
-	reconcilePeriod time.Duration
+	// scanOptions configures the interval, the chunks and the jitter of the background scans
+	scanOptions ScanOptions
+
+	// backgroundGate pauses the background scans and the full reconciliations of the reports
+	backgroundGate *background.Gate
 
 	log logr.Logger
 
@@ -125,6 +152,7 @@ func NewPolicyController(
 	client *client.Client,
 	pInformer kyvernoinformer.ClusterPolicyInformer,
 	npInformer kyvernoinformer.PolicyInformer,
+	psInformer kyvernoinformer.PolicySetInformer,
 	grInformer kyvernoinformer.GenerateRequestInformer,
 	configHandler config.Interface,
 	eventGen event.Interface,
@@ -133,8 +161,10 @@ func NewPolicyController(
 	namespaces informers.NamespaceInformer,
 	log logr.Logger,
 	resCache resourcecache.ResourceCache,
-	reconcilePeriod time.Duration,
-	promConfig *metrics.PromConfig) (*PolicyController, error) {
+	scanOptions ScanOptions,
+	promConfig *metrics.PromConfig,
+	backgroundGate *background.Gate,
+	pexLister kyvernolister.PolicyExceptionLister) (*PolicyController, error) {
 
 	// Event broad caster
 	eventBroadcaster := record.NewBroadcaster()
@@ -150,26 +180,35 @@ func NewPolicyController(
 		kyvernoClient:      kyvernoClient,
 		pInformer:          pInformer,
 		npInformer:         npInformer,
+		psInformer:         psInformer,
 		eventGen:           eventGen,
 		eventRecorder:      eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "policy_controller"}),
 		queue:              workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "policy"),
+		psQueue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "policyset"),
+		statusQueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "policystatus"),
+		scans:              newScanDeltas(),
+		schedules:          newScanSchedules(),
 		configHandler:      configHandler,
 		prGenerator:        prGenerator,
 		policyReportEraser: policyReportEraser,
 		resCache:           resCache,
-		reconcilePeriod:    reconcilePeriod,
+		scanOptions:        scanOptions,
 		promConfig:         promConfig,
+		backgroundGate:     backgroundGate,
+		pexLister:          pexLister,
 		log:                log,
 	}
 
 	pc.pLister = pInformer.Lister()
 	pc.npLister = npInformer.Lister()
+	pc.psLister = psInformer.Lister()
 
 	pc.nsLister = namespaces.Lister()
 	pc.grLister = grInformer.Lister()
 
 	pc.pListerSynced = pInformer.Informer().HasSynced
 	pc.npListerSynced = npInformer.Informer().HasSynced
+	pc.psListerSynced = psInformer.Informer().HasSynced
 
 	pc.nsListerSynced = namespaces.Informer().HasSynced
 	pc.grListerSynced = grInformer.Informer().HasSynced
@@ -260,6 +299,7 @@ func (pc *PolicyController) addPolicy(obj interface{}) {
 	p := obj.(*kyverno.ClusterPolicy)
 
 	logger.Info("policy created", "uid", p.UID, "kind", "ClusterPolicy", "name", p.Name)
+	pc.enqueuePolicySets()
 
 	// register kyverno_policy_rule_info_total metric concurrently
 	go pc.registerPolicyRuleInfoMetricAddPolicy(logger, p)
@@ -275,6 +315,13 @@ func (pc *PolicyController) addPolicy(obj interface{}) {
 		}
 	}
 
+	pc.enqueuePolicyStatus(p)
+	pc.syncKindNotFoundCondition(p)
+	pc.syncUnsupportedCondition(p)
+
+	// resume the cleanup of results of renamed or removed rules, e.g. after a restart
+	pc.policyReportEraser.ResumeStaleRules(p)
+
 	if !pc.canBackgroundProcess(p) {
 		return
 	}
@@ -293,6 +340,10 @@ func (pc *PolicyController) updatePolicy(old, cur interface{}) {
 	// register kyverno_policy_changes_info metric concurrently
 	go pc.registerPolicyChangesMetricUpdatePolicy(logger, oldP, curP, time.Now().Unix())
 
+	if !reflect.DeepEqual(oldP.GetLabels(), curP.GetLabels()) {
+		pc.enqueuePolicySets()
+	}
+
 	if curP.Spec.Background == nil || curP.Spec.ValidationFailureAction == "" || missingAutoGenRules(curP, logger) {
 		pol, _ := common.MutatePolicy(curP, logger)
 		pol.SetGroupVersionKind(schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "ClusterPolicy"})
@@ -302,6 +353,22 @@ func (pc *PolicyController) updatePolicy(old, cur interface{}) {
 		}
 	}
 
+	pc.enqueuePolicyStatus(curP)
+	if !reflect.DeepEqual(oldP.Spec.Rules, curP.Spec.Rules) {
+		pc.syncKindNotFoundCondition(curP)
+	}
+	// the unknown fields are not decoded, a change of the generation may change them
+	if oldP.GetGeneration() != curP.GetGeneration() {
+		pc.syncUnsupportedCondition(curP)
+	}
+	if oldP.IsEnabled() && !curP.IsEnabled() {
+		pc.enqueueRCRDeletedPolicy(curP.Name)
+	}
+
+	if ruleNamesChanged(oldP, curP) {
+		pc.policyReportEraser.CleanupStaleRules(curP)
+	}
+
 	if !pc.canBackgroundProcess(curP) {
 		return
 	}
@@ -313,7 +380,7 @@ func (pc *PolicyController) updatePolicy(old, cur interface{}) {
 	logger.V(2).Info("updating policy", "name", oldP.Name)
 
 	pc.enqueueRCRDeletedRule(oldP, curP)
-	pc.enqueuePolicy(curP)
+	pc.enqueueUpdatedPolicy(oldP, curP)
 }
 
 func (pc *PolicyController) deletePolicy(obj interface{}) {
@@ -339,6 +406,7 @@ func (pc *PolicyController) deletePolicy(obj interface{}) {
 	go pc.registerPolicyChangesMetricDeletePolicy(logger, p, time.Now().Unix())
 
 	logger.Info("policy deleted", "uid", p.UID, "kind", "ClusterPolicy", "name", p.Name)
+	pc.enqueuePolicySets()
 
 	// we process policies that are not set of background processing
 	// as we need to clean up GRs when a policy is deleted
@@ -348,7 +416,7 @@ func (pc *PolicyController) deletePolicy(obj interface{}) {
 	generatePolicyWithClone := pkgCommon.ProcessDeletePolicyForCloneGenerateRule(rules, pc.client, p.GetName(), logger)
 
 	if !generatePolicyWithClone {
-		pc.enqueuePolicy(p)
+		pc.enqueueDeletedPolicy(p)
 		pc.enqueueRCRDeletedPolicy(p.Name)
 	}
 }
@@ -431,6 +499,14 @@ func (pc *PolicyController) addNsPolicy(obj interface{}) {
 			logger.Error(err, "failed to add namespace policy")
 		}
 	}
+
+	pc.enqueuePolicyStatus(pol)
+	pc.syncKindNotFoundCondition(pol)
+	pc.syncUnsupportedCondition(pol)
+
+	// resume the cleanup of results of renamed or removed rules, e.g. after a restart
+	pc.policyReportEraser.ResumeStaleRules(pol)
+
 	if !pc.canBackgroundProcess(pol) {
 		return
 	}
@@ -459,6 +535,22 @@ func (pc *PolicyController) updateNsPolicy(old, cur interface{}) {
 		}
 	}
 
+	pc.enqueuePolicyStatus(ncurP)
+	if !reflect.DeepEqual(oldP.Spec.Rules, curP.Spec.Rules) {
+		pc.syncKindNotFoundCondition(ncurP)
+	}
+	// the unknown fields are not decoded, a change of the generation may change them
+	if oldP.GetGeneration() != curP.GetGeneration() {
+		pc.syncUnsupportedCondition(ncurP)
+	}
+	if ConvertPolicyToClusterPolicy(oldP).IsEnabled() && !ncurP.IsEnabled() {
+		pc.enqueueRCRDeletedPolicy(ncurP.Name)
+	}
+
+	if ruleNamesChanged(ConvertPolicyToClusterPolicy(oldP), ncurP) {
+		pc.policyReportEraser.CleanupStaleRules(ncurP)
+	}
+
 	if !pc.canBackgroundProcess(ncurP) {
 		return
 	}
@@ -470,7 +562,7 @@ func (pc *PolicyController) updateNsPolicy(old, cur interface{}) {
 	logger.V(4).Info("updating namespace policy", "namespace", oldP.Namespace, "name", oldP.Name)
 
 	pc.enqueueRCRDeletedRule(ConvertPolicyToClusterPolicy(oldP), ncurP)
-	pc.enqueuePolicy(ncurP)
+	pc.enqueueUpdatedPolicy(ConvertPolicyToClusterPolicy(oldP), ncurP)
 }
 
 func (pc *PolicyController) deleteNsPolicy(obj interface{}) {
@@ -501,7 +593,7 @@ func (pc *PolicyController) deleteNsPolicy(obj interface{}) {
 
 	// we process policies that are not set of background processing
 	// as we need to clean up GRs when a policy is deleted
-	pc.enqueuePolicy(pol)
+	pc.enqueueDeletedPolicy(pol)
 	pc.enqueueRCRDeletedPolicy(p.Name)
 }
 
@@ -527,12 +619,33 @@ func (pc *PolicyController) enqueueRCRDeletedRule(old, cur *kyverno.ClusterPolic
 	}
 }
 
+// ruleNamesChanged checks if a rule was added, removed or renamed
+func ruleNamesChanged(old, cur *kyverno.ClusterPolicy) bool {
+	if len(old.Spec.Rules) != len(cur.Spec.Rules) {
+		return true
+	}
+
+	curRule := make(map[string]bool)
+	for _, rule := range cur.Spec.Rules {
+		curRule[rule.Name] = true
+	}
+
+	for _, rule := range old.Spec.Rules {
+		if !curRule[rule.Name] {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (pc *PolicyController) enqueueRCRDeletedPolicy(policyName string) {
 	pc.prGenerator.Add(policyreport.Info{
 		PolicyName: policyName,
 	})
 }
 
+// enqueuePolicy queues the policy for a full background scan
 func (pc *PolicyController) enqueuePolicy(policy *kyverno.ClusterPolicy) {
 	logger := pc.log
 	key, err := cache.MetaNamespaceKeyFunc(policy)
@@ -540,6 +653,45 @@ func (pc *PolicyController) enqueuePolicy(policy *kyverno.ClusterPolicy) {
 		logger.Error(err, "failed to enqueue policy")
 		return
 	}
+	pc.scans.full(key)
+	pc.queue.Add(key)
+}
+
+// enqueueUpdatedPolicy queues the updated policy for the background scan of the added and changed rules,
+// the results of the removed rules are cleaned up by enqueueRCRDeletedRule
+func (pc *PolicyController) enqueueUpdatedPolicy(old, cur *kyverno.ClusterPolicy) {
+	logger := pc.log
+	rules, full := changedRules(old, cur)
+	if full {
+		pc.enqueuePolicy(cur)
+		return
+	}
+
+	if len(rules) == 0 {
+		logger.V(4).Info("no rules added or changed, skipping background processing", "namespace", cur.Namespace, "name", cur.Name)
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(cur)
+	if err != nil {
+		logger.Error(err, "failed to enqueue policy")
+		return
+	}
+	pc.scans.add(key, rules)
+	pc.queue.Add(key)
+}
+
+// enqueueDeletedPolicy queues the deleted policy to clean up its generate requests,
+// the deleted policy is not evaluated and its report results are removed by enqueueRCRDeletedPolicy
+func (pc *PolicyController) enqueueDeletedPolicy(policy *kyverno.ClusterPolicy) {
+	logger := pc.log
+	key, err := cache.MetaNamespaceKeyFunc(policy)
+	if err != nil {
+		logger.Error(err, "failed to enqueue policy")
+		return
+	}
+	pc.scans.forget(key)
+	pc.schedules.forget(key)
 	pc.queue.Add(key)
 }
 
@@ -549,11 +701,13 @@ func (pc *PolicyController) Run(workers int, reconcileCh <-chan bool, stopCh <-c
 
 	defer utilruntime.HandleCrash()
 	defer pc.queue.ShutDown()
+	defer pc.psQueue.ShutDown()
+	defer pc.statusQueue.ShutDown()
 
 	logger.Info("starting")
 	defer logger.Info("shutting down")
 
-	if !cache.WaitForCacheSync(stopCh, pc.pListerSynced, pc.npListerSynced, pc.nsListerSynced, pc.grListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, pc.pListerSynced, pc.npListerSynced, pc.psListerSynced, pc.nsListerSynced, pc.grListerSynced) {
 		logger.Info("failed to sync informer cache")
 		return
 	}
@@ -570,10 +724,26 @@ func (pc *PolicyController) Run(workers int, reconcileCh <-chan bool, stopCh <-c
 		DeleteFunc: pc.deleteNsPolicy,
 	})
 
+	pc.psInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    pc.addPolicySet,
+		UpdateFunc: pc.updatePolicySet,
+		DeleteFunc: pc.deletePolicySet,
+	})
+
 	for i := 0; i < workers; i++ {
 		go wait.Until(pc.worker, time.Second, stopCh)
 	}
 
+	go wait.Until(pc.policySetWorker, time.Second, stopCh)
+
+	go wait.Until(pc.statusWorker, time.Second, stopCh)
+
+	// policies matching unknown kinds are re-checked, e.g. after a CRD is installed
+	go wait.Until(pc.resyncKindNotFoundConditions, kindNotFoundResyncPeriod, stopCh)
+
+	// the policies with a schedule are scanned on their own schedule
+	go wait.Until(pc.enqueueScheduledPolicies, scheduleCheckPeriod, stopCh)
+
 	go pc.forceReconciliation(reconcileCh, stopCh)
 
 	<-stopCh
@@ -624,6 +794,7 @@ func (pc *PolicyController) syncPolicy(key string) error {
 		logger.V(4).Info("finished syncing policy", "key", key, "processingTime", time.Since(startTime).String())
 	}()
 
+	delta := pc.scans.take(key)
 	grList, err := pc.grLister.List(labels.Everything())
 	if err != nil {
 		logger.Error(err, "failed to list generate request")
@@ -639,8 +810,21 @@ func (pc *PolicyController) syncPolicy(key string) error {
 		return err
 	}
 
+	if !policy.IsEnabled() {
+		logger.V(4).Info("policy is disabled, skipping background processing", "key", key)
+		return nil
+	}
+
+	if policy.IsSuspended() {
+		logger.V(4).Info("policy is suspended, skipping background processing", "key", key)
+		return nil
+	}
+
 	updateGR(pc.kyvernoClient, policy.Name, grList, logger)
-	pc.processExistingResources(policy, startTime.Unix())
+	pc.processExistingResources(policy, delta, startTime.Unix())
+	if mutateExistingPending(policy) {
+		pc.mutateExistingResources(policy, delta)
+	}
 	return nil
 }
 
@@ -692,6 +876,17 @@ func updateGR(kyvernoClient *kyvernoclient.Clientset, policyKey string, grList [
 	}
 }
 
+// isCustomController returns true if the kind is one of the custom controllers
+func isCustomController(kind string, custom []pm.CustomController) bool {
+	for _, controller := range custom {
+		if controller.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
 func missingAutoGenRules(policy *kyverno.ClusterPolicy, log logr.Logger) bool {
 	var podRuleName []string
 	ruleCount := 1
@@ -710,7 +905,14 @@ func missingAutoGenRules(policy *kyverno.ClusterPolicy, log logr.Logger) bool {
 		if val == "none" {
 			return false
 		}
-		res := strings.Split(val, ",")
+		// a rule is generated for each custom controller
+		custom := pm.CustomControllers(*policy, val, log)
+		var res []string
+		for _, controller := range strings.Split(val, ",") {
+			if !isCustomController(controller, custom) {
+				res = append(res, controller)
+			}
+		}
 
 		if len(res) == 1 {
 			ruleCount = 2
@@ -722,6 +924,7 @@ func missingAutoGenRules(policy *kyverno.ClusterPolicy, log logr.Logger) bool {
 				ruleCount = 2
 			}
 		}
+		ruleCount += len(custom)
 
 		if len(policy.Spec.Rules) != (ruleCount * len(podRuleName)) {
 			return true
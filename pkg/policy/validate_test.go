@@ -3,10 +3,13 @@ package policy
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kyverno/kyverno/pkg/openapi"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
@@ -1357,6 +1360,74 @@ func Test_Validate_ApiCall(t *testing.T) {
 			},
 			expectedResult: nil,
 		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					Service:       &kyverno.ServiceCall{URL: "http://inventory.tools.svc/images/{{request.object.metadata.name}}"},
+					JMESPath:      "owner",
+					Timeout:       &metav1.Duration{Duration: 5 * time.Second},
+					CacheTTL:      &metav1.Duration{Duration: time.Minute},
+					FailurePolicy: kyverno.Ignore,
+				},
+			},
+			expectedResult: nil,
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					URLPath: "/api/v1/namespaces",
+					Service: &kyverno.ServiceCall{URL: "http://inventory.tools.svc/images"},
+				},
+			},
+			expectedResult: "both urlPath and service are not allowed in apiCall context entry inventory",
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name:    "inventory",
+				APICall: &kyverno.APICall{JMESPath: "owner"},
+			},
+			expectedResult: "a urlPath or service is required for apiCall context entry inventory",
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					Service: &kyverno.ServiceCall{URL: "inventory.tools.svc/images"},
+				},
+			},
+			expectedResult: "invalid service in apiCall context entry inventory: url inventory.tools.svc/images must be a http or https URL",
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					Service: &kyverno.ServiceCall{URL: "https://inventory.tools.svc/images", CABundle: "not a certificate"},
+				},
+			},
+			expectedResult: "invalid service in apiCall context entry inventory: caBundle must be a PEM encoded certificate",
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					Service: &kyverno.ServiceCall{URL: "http://inventory.tools.svc/images"},
+					Timeout: &metav1.Duration{},
+				},
+			},
+			expectedResult: "the timeout of apiCall context entry inventory must be positive",
+		},
+		{
+			resource: kyverno.ContextEntry{
+				Name: "inventory",
+				APICall: &kyverno.APICall{
+					Service:       &kyverno.ServiceCall{URL: "http://inventory.tools.svc/images"},
+					FailurePolicy: "Retry",
+				},
+			},
+			expectedResult: `invalid failurePolicy "Retry" of apiCall context entry inventory, expect Fail or Ignore`,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -1413,3 +1484,523 @@ func Test_Wildcards_Kind(t *testing.T) {
 	err = Validate(policy, nil, true, openAPIController)
 	assert.Assert(t, err != nil)
 }
+
+func Test_Validate_FailurePolicy(t *testing.T) {
+	testCases := []struct {
+		failurePolicy string
+		expectedErr   bool
+	}{
+		{failurePolicy: "", expectedErr: false},
+		{failurePolicy: "Ignore", expectedErr: false},
+		{failurePolicy: "Fail", expectedErr: false},
+		{failurePolicy: "ignore", expectedErr: true},
+		{failurePolicy: "Skip", expectedErr: true},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{"failurePolicy":"%s","rules":[{"name":"check-labels","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`, test.failurePolicy))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		assert.Equal(t, err != nil, test.expectedErr, fmt.Sprintf("failurePolicy %q: %v", test.failurePolicy, err))
+	}
+}
+
+func Test_Validate_WebhookTimeoutSeconds(t *testing.T) {
+	testCases := []struct {
+		timeoutSeconds string
+		expectedErr    bool
+	}{
+		{timeoutSeconds: "null", expectedErr: false},
+		{timeoutSeconds: "1", expectedErr: false},
+		{timeoutSeconds: "30", expectedErr: false},
+		{timeoutSeconds: "0", expectedErr: true},
+		{timeoutSeconds: "31", expectedErr: true},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{"webhookTimeoutSeconds":%s,"rules":[{"name":"check-labels","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`, test.timeoutSeconds))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		assert.Equal(t, err != nil, test.expectedErr, fmt.Sprintf("webhookTimeoutSeconds %s: %v", test.timeoutSeconds, err))
+	}
+}
+
+func Test_Validate_Schedule(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		expectedErr bool
+	}{
+		{spec: `"schedule":"0 */6 * * *"`, expectedErr: false},
+		{spec: `"schedule":"@daily"`, expectedErr: false},
+		{spec: `"schedule":"every 6 hours"`, expectedErr: true},
+		{spec: `"schedule":"0 */6 * * *","background":false`, expectedErr: true},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{%s,"rules":[{"name":"check-labels","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`, test.spec))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		assert.Equal(t, err != nil, test.expectedErr, fmt.Sprintf("%s: %v", test.spec, err))
+	}
+}
+
+func Test_Validate_RuleDocumentation(t *testing.T) {
+	testCases := []struct {
+		id               string
+		documentationURL string
+		expectedErr      bool
+	}{
+		{id: "", documentationURL: "", expectedErr: false},
+		{id: "SEC-014", documentationURL: "https://controls.example.com/SEC-014", expectedErr: false},
+		{id: "cis.5.2.1", documentationURL: "", expectedErr: false},
+		{id: "SEC 014", documentationURL: "", expectedErr: true},
+		{id: "-SEC-014", documentationURL: "", expectedErr: true},
+		{id: strings.Repeat("a", 64), documentationURL: "", expectedErr: true},
+		{id: "SEC-014", documentationURL: "controls.example.com/SEC-014", expectedErr: true},
+		{id: "SEC-014", documentationURL: "ftp://controls.example.com/SEC-014", expectedErr: true},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{"rules":[{"name":"check-labels","id":"%s","documentationURL":"%s","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`, test.id, test.documentationURL))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		assert.Equal(t, err != nil, test.expectedErr, fmt.Sprintf("id %q, documentationURL %q: %v", test.id, test.documentationURL, err))
+	}
+}
+
+func Test_Validate_TimeWindows(t *testing.T) {
+	testCases := []struct {
+		window      string
+		expectedErr string
+	}{
+		{window: `{"days":["Mon-Fri"],"start":"17:00","end":"09:00","timeZone":"Europe/Berlin"}`},
+		{window: `{"days":["Sat","Sun"],"start":"00:00","end":"24:00"}`},
+		{window: `{"start":"09:00","end":"17:00","timeZone":"Europe/Atlantis"}`, expectedErr: "path: spec.rules[0].timeWindows[0].timeZone: invalid time zone"},
+		{window: `{"days":["Weekdays"],"start":"09:00","end":"17:00"}`, expectedErr: "path: spec.rules[0].timeWindows[0].days[0]: invalid day"},
+		{window: `{"start":"9am","end":"17:00"}`, expectedErr: "path: spec.rules[0].timeWindows[0].start: invalid start"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{"rules":[{"name":"check-labels","timeWindows":[%s],"match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`, test.window))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, test.window)
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, test.window)
+		}
+	}
+}
+
+func Test_Validate_MinimumAge(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		match       string
+		exclude     string
+		expectedErr string
+	}{
+		{spec: `"admission":false,`, match: `"minimumAge":"2160h"`},
+		{spec: `"admission":false,"background":true,`, match: `"minimumAge":"720h"`},
+		{spec: ``, match: `"minimumAge":"2160h"`, expectedErr: "path: spec.rules[0].match.resources.minimumAge: minimumAge is only supported in background only policies, set spec.admission to false"},
+		{spec: `"admission":true,`, match: `"minimumAge":"2160h"`, expectedErr: "set spec.admission to false"},
+		{spec: `"admission":false,"background":false,`, match: `"minimumAge":"2160h"`, expectedErr: "set spec.background to true"},
+		{spec: `"admission":false,`, match: `"minimumAge":"-1h"`, expectedErr: "path: spec.rules[0].match.resources.minimumAge: minimumAge must be a positive duration"},
+		{spec: `"admission":false,`, match: `"minimumAge":"2160h"`, exclude: `,"exclude":{"resources":{"minimumAge":"24h"}}`, expectedErr: "path: spec.rules[0].exclude.resources.minimumAge"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"stale-configmaps"},"spec":{%s"rules":[{"name":"require-owner","match":{"resources":{"kinds":["ConfigMap"],%s}}%s,"validate":{"message":"label 'owner' is required","pattern":{"metadata":{"labels":{"owner":"?*"}}}}}]}}`, test.spec, test.match, test.exclude))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_MutateExistingOnPolicyUpdate(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		expectedErr string
+	}{
+		{spec: `"mutateExistingOnPolicyUpdate":true,`},
+		{spec: `"mutateExistingOnPolicyUpdate":true,"background":true,`},
+		{spec: `"mutateExistingOnPolicyUpdate":false,"background":false,`},
+		{spec: `"mutateExistingOnPolicyUpdate":true,"background":false,`, expectedErr: "path: spec.mutateExistingOnPolicyUpdate: the existing resources are mutated in the background, set spec.background to true"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"add-labels"},"spec":{%s"rules":[{"name":"add-app-label","match":{"resources":{"kinds":["ConfigMap"]}},"mutate":{"patchStrategicMerge":{"metadata":{"labels":{"app":"web"}}}}}]}}`, test.spec))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_VerifyImages(t *testing.T) {
+	testCases := []struct {
+		verifyImage string
+		expectedErr string
+	}{
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----"`},
+		{verifyImage: `"image":"ghcr.io/acme/*","keys":["-----BEGIN PUBLIC KEY-----","-----BEGIN PUBLIC KEY-----"],"count":1`},
+		{verifyImage: `"image":"ghcr.io/acme/*","subject":"https://github.com/acme/*","rekor":"https://rekor.acme.io"`},
+		{verifyImage: `"image":"ghcr.io/acme/*"`},
+		{verifyImage: `"key":"-----BEGIN PUBLIC KEY-----"`, expectedErr: "path: spec.rules[0].verifyImages[0].image: an image pattern is required"},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","keys":["-----BEGIN PUBLIC KEY-----"]`, expectedErr: "path: spec.rules[0].verifyImages[0]: key and keys are mutually exclusive"},
+		{verifyImage: `"image":"ghcr.io/acme/*","keys":["-----BEGIN PUBLIC KEY-----",""]`, expectedErr: "path: spec.rules[0].verifyImages[0].keys[1]: empty key"},
+		{verifyImage: `"image":"ghcr.io/acme/*","keys":["-----BEGIN PUBLIC KEY-----"],"count":2`, expectedErr: "path: spec.rules[0].verifyImages[0].count"},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","subject":"dev@acme.io"`, expectedErr: "path: spec.rules[0].verifyImages[0].subject"},
+		{verifyImage: `"image":"ghcr.io/acme/*","roots":"not a certificate"`, expectedErr: "path: spec.rules[0].verifyImages[0].roots: no PEM encoded certificate"},
+		{verifyImage: `"image":"ghcr.io/acme/*","rekor":"rekor.acme.io"`, expectedErr: "path: spec.rules[0].verifyImages[0].rekor: invalid Rekor URL"},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","attestations":[{"predicateType":"https://slsa.dev/provenance/v0.1","conditions":{"all":[{"key":"{{attestation.predicate.builder.id}}","operator":"Equals","value":"https://github.com/acme/*"}]}}]`},
+		{verifyImage: `"image":"ghcr.io/acme/*","attestations":[{"predicateType":"https://slsa.dev/provenance/v0.1"}]`, expectedErr: "path: spec.rules[0].verifyImages[0].attestations[0]: the attestations are verified with the keys"},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","attestations":[{"conditions":{"all":[]}}]`, expectedErr: "path: spec.rules[0].verifyImages[0].attestations[0].predicateType: a predicate type is required"},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","attestations":[{"predicateType":"https://cosign.sigstore.dev/attestation/vuln/v1","severityFrom":{"jmesPath":"max(scanner.result[].cvss)","thresholds":{"critical":9,"high":7}}}]`},
+		{verifyImage: `"image":"ghcr.io/acme/*","key":"-----BEGIN PUBLIC KEY-----","attestations":[{"predicateType":"https://cosign.sigstore.dev/attestation/vuln/v1","severityFrom":{"jmesPath":"max(","thresholds":{"critical":9}}}]`, expectedErr: "path: spec.rules[0].verifyImages[0].attestations[0].severityFrom.jmesPath: invalid JMESPath"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"check-images"},"spec":{"background":false,"rules":[{"name":"check-signatures","match":{"resources":{"kinds":["Pod"]}},"verifyImages":[{%s}]}]}}`, test.verifyImage))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_AdmissionMatch(t *testing.T) {
+	validate := `"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}`
+	mutate := `"mutate":{"patchStrategicMerge":{"metadata":{"labels":{"app":"nginx"}}}}`
+
+	testCases := []struct {
+		spec        string
+		resources   string
+		userInfo    string
+		exclude     string
+		action      string
+		expectedErr string
+	}{
+		{spec: `"background":false,`, resources: `,"operations":["CREATE","UPDATE"],"dryRun":false`},
+		{spec: `"background":false,`, resources: `,"dryRun":"any"`, exclude: `,"exclude":{"resources":{"operations":["DELETE"]}}`},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"ServiceAccount","subjects":[{"kind":"ServiceAccount","name":"deployer","namespace":"ci"}]`},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"Group","subjects":[{"kind":"Group","name":"developers"}]`},
+		{resources: `,"dryRun":"any"`},
+		{spec: `"background":false,`, resources: `,"operations":["PATCH"]`, expectedErr: `path: spec.rules[0].match.resources.operations: invalid operation "PATCH"`},
+		{spec: `"background":false,`, resources: `,"dryRun":"maybe"`, expectedErr: `path: spec.rules[0].match.resources.dryRun: invalid dryRun "maybe"`},
+		{spec: `"background":false,`, exclude: `,"exclude":{"resources":{"dryRun":"never"}}`, expectedErr: `path: spec.rules[0].exclude.resources.dryRun`},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"Robot"`, expectedErr: `path: spec.rules[0].match.subjectsKind: invalid subjectsKind "Robot"`},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"ServiceAccount","subjects":[{"kind":"User","name":"alice"}]`, expectedErr: "path: spec.rules[0].match.subjects: subject alice of kind User conflicts with subjectsKind ServiceAccount"},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"Group"`, expectedErr: "subjectsKind Group requires the Group subjects"},
+		{userInfo: `,"subjectsKind":"ServiceAccount"`, expectedErr: "only select variables are allowed in background mode"},
+		{resources: `,"operations":["DELETE"]`, expectedErr: "path: spec.rules[0].match.resources.operations: the background scans have no operation"},
+		{resources: `,"operations":["CREATE"]`, expectedErr: "path: spec.rules[0].match.resources.operations: the background scans have no operation"},
+		{resources: `,"operations":["UPDATE"]`, exclude: `,"exclude":{"resources":{"operations":["CREATE"]}}`, expectedErr: "path: spec.rules[0].match.resources.operations: the background scans have no operation"},
+		{exclude: `,"exclude":{"resources":{"operations":["DELETE"]}}`, expectedErr: "path: spec.rules[0].exclude.resources.operations: the background scans have no operation"},
+		{resources: `,"dryRun":true`, expectedErr: "path: spec.rules[0].match.resources.dryRun: the background scans have no dry-run"},
+		{resources: `,"dryRun":false`, expectedErr: "path: spec.rules[0].match.resources.dryRun: the background scans have no dry-run"},
+		{exclude: `,"exclude":{"resources":{"dryRun":"true"}}`, expectedErr: "path: spec.rules[0].exclude.resources.dryRun: the background scans have no dry-run"},
+		{spec: `"background":false,`, resources: `,"operations":["DELETE","CONNECT"]`, action: mutate, expectedErr: "mutate, generate and verifyImages rules only match CREATE and UPDATE requests"},
+
+		// the exclude block matches all the requests of the match block
+		{spec: `"background":false,`, resources: `,"operations":["CREATE","UPDATE"]`, exclude: `,"exclude":{"resources":{"operations":["UPDATE","CREATE"]}}`, expectedErr: "rule is matching an empty set"},
+		{spec: `"background":false,`, exclude: `,"exclude":{"resources":{"operations":["CREATE","UPDATE","DELETE","CONNECT"]}}`, expectedErr: "rule is matching an empty set"},
+		{spec: `"background":false,`, resources: `,"dryRun":true`, exclude: `,"exclude":{"resources":{"dryRun":"true"}}`, expectedErr: "rule is matching an empty set"},
+		{spec: `"background":false,`, exclude: `,"exclude":{"resources":{"dryRun":"any"}}`, expectedErr: "rule is matching an empty set"},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"ServiceAccount"`, exclude: `,"exclude":{"subjectsKind":"ServiceAccount"}`, expectedErr: "rule is matching an empty set"},
+		{spec: `"background":false,`, userInfo: `,"subjectsKind":"ServiceAccount"`, exclude: `,"exclude":{"resources":{"dryRun":true},"subjectsKind":"ServiceAccount"}`},
+	}
+
+	for _, test := range testCases {
+		action := test.action
+		if action == "" {
+			action = validate
+		}
+
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"protect-pods"},"spec":{%s"rules":[{"name":"check-pods","match":{"resources":{"kinds":["Pod"]%s}%s}%s,%s}]}}`, test.spec, test.resources, test.userInfo, test.exclude, action))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_RuleScope(t *testing.T) {
+	testCases := []struct {
+		scope       string
+		validate    string
+		expectedErr string
+	}{
+		{scope: `Resource`, validate: `"pattern":{"metadata":{"labels":{"app":"?*"}}}`},
+		{scope: `Namespace`, validate: `"deny":{"conditions":[{"key":"{{request.namespace}}","operator":"NotIn","value":"{{settings.data.namespaces}}"}]}`},
+		{scope: `Namespace`, validate: `"deny":{"conditions":[{"key":"{{request.object.metadata.labels.app}}","operator":"Equals","value":""}]}`, expectedErr: "path: spec.rules[0].validate: variable {{request.object.metadata.labels.app}} depends on the resource"},
+		{scope: `Namespace`, validate: `"deny":{"conditions":[{"key":"{{ keys(request.oldObject.metadata.labels) }}","operator":"Equals","value":[]}]}`, expectedErr: "variable {{ keys(request.oldObject.metadata.labels) }} depends on the resource"},
+		{scope: `Namespace`, validate: `"deny":{"conditions":[{"key":"{{serviceAccountName}}","operator":"Equals","value":"default"}]}`, expectedErr: "depends on the resource"},
+		{scope: `Namespace`, validate: `"pattern":{"metadata":{"labels":{"app":"?*"}}}`, expectedErr: "path: spec.rules[0].scope: scope Namespace is only supported in validate rules with deny conditions"},
+		{scope: `Cluster`, validate: `"pattern":{"metadata":{"labels":{"app":"?*"}}}`, expectedErr: "path: spec.rules[0].scope: invalid scope \"Cluster\""},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"allowed-namespaces"},"spec":{"background":false,"rules":[{"name":"check-namespace","scope":"%s","context":[{"name":"settings","configMap":{"name":"settings","namespace":"kyverno"}}],"match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"the namespace is not allowed",%s}}]}}`, test.scope, test.validate))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_ForEachNamespace(t *testing.T) {
+	testCases := []struct {
+		kinds       string
+		forEach     string
+		expectedErr string
+	}{
+		{kinds: `"Namespace"`, forEach: `{"selector":{"matchExpressions":[{"key":"team","operator":"Exists"}]}}`},
+		{kinds: `"Namespace"`, forEach: `{"selector":{"matchLabels":{"team":"*"}}}`},
+		{kinds: `"Pod"`, forEach: `{"selector":{"matchLabels":{"team":"web"}}}`, expectedErr: "path: spec.rules[0].match.resources.kinds: forEachNamespace requires the rule to match the Namespace kind only"},
+		{kinds: `"Namespace"`, forEach: `{}`, expectedErr: "path: spec.rules[0].generate.forEachNamespace.selector: a selector is required"},
+		{kinds: `"Namespace"`, forEach: `{"selector":{"matchExpressions":[{"key":"team","operator":"Matches"}]}}`, expectedErr: "path: spec.rules[0].generate.forEachNamespace.selector: invalid selector"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"team-rolebinding"},"spec":{"rules":[{"name":"generate-rolebinding","match":{"resources":{"kinds":[%s]}},"generate":{"kind":"RoleBinding","name":"team-edit","namespace":"{{request.object.metadata.name}}","forEachNamespace":%s,"data":{"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"ClusterRole","name":"edit"}}}}]}}`, test.kinds, test.forEach))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_PreserveExisting(t *testing.T) {
+	testCases := []struct {
+		mutate      string
+		expectedErr string
+	}{
+		{mutate: `{"preserveExisting":true,"patchStrategicMerge":{"metadata":{"labels":{"team":"default"}}}}`},
+		{mutate: `{"patchesJson6902":"- op: add\n  path: /spec/priority\n  value: 1"}`},
+		{mutate: `{"preserveExisting":true,"patchesJson6902":"- op: add\n  path: /spec/priority\n  value: 1"}`, expectedErr: "path: spec.rules[0].mutate.preserveExisting: preserveExisting requires a patchStrategicMerge or an overlay"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"defaults"},"spec":{"rules":[{"name":"set-defaults","match":{"resources":{"kinds":["Pod"]}},"mutate":%s}]}}`, test.mutate))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_Variables(t *testing.T) {
+	testCases := []struct {
+		validate    string
+		expectedErr string
+	}{
+		{validate: `"deny":{"conditions":[{"key":"{{ length(request.object.spec.containers[?image == 'nginx']) }}","operator":"GreaterThan","value":"{{ settings.data.max }}"}]}`},
+		{validate: `"deny":{"conditions":[{"key":"\\{{ literal }}","operator":"Equals","value":"{{ request.object.metadata.labels.\"app.kubernetes.io/name\" }}"}]}`},
+		{validate: `"deny":{"conditions":[{"key":"{{ request.object.metadata.labels.[app }}","operator":"Equals","value":"web"}]}`, expectedErr: "rule check-containers: path: spec.rules[0].validate.deny.conditions[0].key: invalid JMESPath expression {{ request.object.metadata.labels.[app }}"},
+		{validate: `"deny":{"conditions":[{"key":"{{}}","operator":"Equals","value":"web"}]}`, expectedErr: "path: spec.rules[0].validate.deny.conditions[0].key: variable {{}} is empty"},
+		{validate: `"foreach":[{"list":"request.object.spec.containers[","pattern":{"image":"*:*"}}]`, expectedErr: "path: spec.rules[0].validate.foreach[0].list: invalid JMESPath expression request.object.spec.containers["},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"containers"},"spec":{"background":false,"rules":[{"name":"check-containers","context":[{"name":"settings","configMap":{"name":"settings","namespace":"kyverno"}}],"match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"invalid containers",%s}}]}}`, test.validate))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_VariableReferences(t *testing.T) {
+	rawPolicy := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"containers"},"spec":{"rules":[
+		{"name":"check-replicas","context":[{"name":"settings","configMap":{"name":"settings","namespace":"kyverno"}}],"match":{"resources":{"kinds":["Deployment"]}},
+			"validate":{"message":"at most {{ setings.data.max }} replicas","deny":{"conditions":[{"key":"{{ request.object.spec.replicas }}","operator":"GreaterThan","value":"{{ to_number(settings.data.max) }}"}]}}},
+		{"name":"check-images","match":{"resources":{"kinds":["Pod"]}},
+			"validate":{"message":"invalid images","deny":{"conditions":[{"key":"{{ images.containers.*.registry | [?@ != 'ghcr.io'] | length(@) }}","operator":"GreaterThan","value":"{{ registries.data.count }}"}]}}}
+	]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	// the CLI sets any variable
+	assert.NilError(t, checkRules(policy, true))
+
+	err := checkRules(policy, false)
+	ruleErrs, ok := err.(RuleErrors)
+	assert.Assert(t, ok, err)
+	assert.Equal(t, len(ruleErrs), 2)
+	assert.Equal(t, ruleErrs[0].Field(), "spec.rules[0].validate.message")
+	assert.Equal(t, ruleErrs[0].Rule, "check-replicas")
+	assert.Assert(t, strings.Contains(ruleErrs[0].Message, "variable {{ setings.data.max }} references setings, which is neither a built-in variable"))
+	assert.Equal(t, ruleErrs[1].Field(), "spec.rules[1].validate.deny.conditions[0].value")
+	assert.Assert(t, strings.Contains(err.Error(), "rule check-images: path: spec.rules[1].validate.deny.conditions[0].value: variable {{ registries.data.count }} references registries"))
+}
+
+func Test_variableRoots(t *testing.T) {
+	testCases := []struct {
+		expression string
+		roots      []string
+	}{
+		{expression: `request.object.metadata.name`, roots: []string{"request"}},
+		{expression: `"request".object`, roots: []string{"request"}},
+		{expression: `@`, roots: nil},
+		{expression: `to_upper(request.object.metadata.name)`, roots: []string{"request"}},
+		{expression: `request.object.spec.containers[?name == settings.data.name].image`, roots: []string{"request"}},
+		{expression: `request.operation == 'CREATE' && serviceAccountName != dictionary.data.sa`, roots: []string{"request", "serviceAccountName", "dictionary"}},
+		{expression: `images.containers.*.registry | [?@ != 'ghcr.io'] | length(@)`, roots: []string{"images"}},
+		{expression: `sort_by(element.ports, &containerPort)`, roots: []string{"element"}},
+		{expression: "merge(request.object.metadata.labels, `{\"team\": \"web\"}`)", roots: []string{"request"}},
+	}
+
+	for _, test := range testCases {
+		assert.DeepEqual(t, variableRoots(test.expression), test.roots)
+	}
+}
+
+func Test_Validate_Reachable(t *testing.T) {
+	testCases := []struct {
+		namespace   string
+		match       string
+		expectedErr string
+	}{
+		{namespace: "dev", match: `{"kinds":["Pod"],"namespaces":["dev*"]}`},
+		{namespace: "dev", match: `{"kinds":["Pod"],"namespaces":["prod"]}`, expectedErr: "path: spec.rules[0].match.resources.namespaces: the rule never matches, the policy only applies to the resources of its namespace dev"},
+		{match: `{"kinds":["Pod"],"selector":{"matchLabels":{"app":"web"},"matchExpressions":[{"key":"app","operator":"NotIn","values":["db"]}]}}`},
+		{match: `{"kinds":["Pod"],"namespaceSelector":{"matchLabels":{"team":"web*"},"matchExpressions":[{"key":"team","operator":"In","values":["web-frontend"]}]}}`},
+		{match: `{"kinds":["Pod"],"selector":{"matchLabels":{"app":"web"},"matchExpressions":[{"key":"app","operator":"In","values":["db","cache"]}]}}`, expectedErr: "path: spec.rules[0].match.resources.selector: the rule never matches, label app is required to be web and one of db, cache"},
+		{match: `{"kinds":["Pod"],"namespaceSelector":{"matchExpressions":[{"key":"team","operator":"Exists"},{"key":"team","operator":"DoesNotExist"}]}}`, expectedErr: "path: spec.rules[0].match.resources.namespaceSelector: the rule never matches, label team is required to exist and not to exist"},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"Policy","metadata":{"name":"labels","namespace":"%s"},"spec":{"rules":[{"name":"check-labels","match":{"resources":%s},"validate":{"message":"the label team is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`, test.namespace, test.match))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
+
+func Test_Validate_MalformedAnchor(t *testing.T) {
+	testCases := []struct {
+		pattern     string
+		expectedErr string
+	}{
+		{pattern: `{"spec":{"containers":[{"(name)":"nginx","=(imagePullPolicy)":"Always"}]}}`},
+		{pattern: `{"spec":{"containers":[{"=(imagePullPolicy":"Always"}]}}`, expectedErr: "Malformed anchor =(imagePullPolicy"},
+		{pattern: `{"spec":{"^ (containers)":[{"name":"nginx"}]}}`, expectedErr: "Malformed anchor ^ (containers)"},
+		{pattern: `{"spec":{"volumes":[{"<(hostPath)":{"path":"/var/*"}}],"containers":[{"securityContext":{"readOnlyRootFilesystem":true}}]}}`},
+	}
+
+	for _, test := range testCases {
+		rawPolicy := []byte(fmt.Sprintf(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"containers"},"spec":{"rules":[{"name":"check-containers","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"invalid containers","pattern":%s}}]}}`, test.pattern))
+
+		var policy *kyverno.ClusterPolicy
+		err := json.Unmarshal(rawPolicy, &policy)
+		assert.NilError(t, err)
+
+		openAPIController, _ := openapi.NewOpenAPIController()
+		err = Validate(policy, nil, true, openAPIController)
+		if test.expectedErr == "" {
+			assert.NilError(t, err, string(rawPolicy))
+		} else {
+			assert.ErrorContains(t, err, test.expectedErr, string(rawPolicy))
+		}
+	}
+}
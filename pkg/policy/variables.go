@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/variables"
+)
+
+// builtInVariables are the variables of the context that are not declared by the context entries of the rules
+var builtInVariables = []string{"request", "serviceAccountName", "serviceAccountNamespace", "images", "element", "elementIndex", "attestation"}
+
+// validateVariables checks that the variables of a rule are valid JMESPath expressions, the variables of
+// the match and exclude blocks are rejected separately. If checkReferences is set, the variables may only
+// reference the built-in variables and the context entries of the rule, the CLI sets any variable.
+func validateVariables(rule kyverno.Rule, checkReferences bool) []RuleError {
+	ruleRaw, err := json.Marshal(rule)
+	if err != nil {
+		return []RuleError{{Message: err.Error()}}
+	}
+
+	var ruleMap map[string]interface{}
+	if err := json.Unmarshal(ruleRaw, &ruleMap); err != nil {
+		return []RuleError{{Message: err.Error()}}
+	}
+
+	delete(ruleMap, "match")
+	delete(ruleMap, "exclude")
+
+	allowed := make(map[string]bool)
+	for _, name := range builtInVariables {
+		allowed[name] = true
+	}
+
+	for _, entry := range append(append([]kyverno.ContextEntry{}, rule.Context...), rule.ForEachContext()...) {
+		allowed[entry.Name] = true
+	}
+
+	v := &variableValidator{allowed: allowed, checkReferences: checkReferences}
+	v.walk(ruleMap, "")
+
+	for i, foreach := range rule.Validation.ForEachValidation {
+		v.checkExpression(foreach.List, fmt.Sprintf("validate.foreach[%d].list", i), foreach.List)
+	}
+
+	for i, foreach := range rule.Mutation.ForEachMutation {
+		v.checkExpression(foreach.List, fmt.Sprintf("mutate.foreach[%d].list", i), foreach.List)
+	}
+
+	return v.errs
+}
+
+type variableValidator struct {
+	allowed         map[string]bool
+	checkReferences bool
+	errs            []RuleError
+}
+
+func (v *variableValidator) walk(element interface{}, path string) {
+	switch typed := element.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			v.walk(typed[key], childPath)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			v.walk(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case string:
+		// the escaped variables are literal {{}}
+		value := variables.RegexEscpVariables.ReplaceAllString(typed, "")
+		for _, variable := range variables.RegexVariables.FindAllString(value, -1) {
+			expression := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(variable, "{{"), "}}"))
+			v.checkExpression(expression, path, variable)
+		}
+	}
+}
+
+// checkExpression parses the JMESPath expression of a variable or of a foreach list, and checks the
+// variables it references
+func (v *variableValidator) checkExpression(expression, path, variable string) {
+	if expression == "" {
+		v.errs = append(v.errs, RuleError{Path: path, Message: fmt.Sprintf("variable %s is empty", variable)})
+		return
+	}
+
+	// the nested variables are substituted first, the expression is only known then
+	if strings.Contains(expression, "{{") {
+		return
+	}
+
+	if _, err := jmespath.NewParser().Parse(expression); err != nil {
+		v.errs = append(v.errs, RuleError{Path: path, Message: fmt.Sprintf("invalid JMESPath expression %s: %v", variable, err)})
+		return
+	}
+
+	if !v.checkReferences {
+		return
+	}
+
+	for _, root := range variableRoots(expression) {
+		if !v.allowed[root] {
+			v.errs = append(v.errs, RuleError{Path: path, Message: fmt.Sprintf("variable %s references %s, which is neither a built-in variable (%s) nor a context entry of the rule", variable, root, strings.Join(builtInVariables, ", "))})
+		}
+	}
+}
+
+// variableRoots returns the identifiers a JMESPath expression looks up in the context, i.e. its first
+// identifier and the first identifiers of the arguments of its functions and of its comparisons. The
+// identifiers of the projections, of the filters and after a pipe are relative and are not returned.
+func variableRoots(expression string) []string {
+	var roots []string
+	depth := 0
+	expectRoot := true
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '\'' || c == '`':
+			// raw string and JSON literals
+			i = skipQuoted(expression, i)
+			expectRoot = false
+		case c == '"' || isIdentifierStart(c):
+			var identifier string
+			if c == '"' {
+				end := skipQuoted(expression, i)
+				identifier = strings.Trim(expression[i:end], `"`)
+				i = end
+			} else {
+				start := i
+				for i < len(expression) && isIdentifierChar(expression[i]) {
+					i++
+				}
+				identifier = expression[start:i]
+			}
+
+			// the functions are followed by their arguments
+			next := strings.TrimLeft(expression[i:], " \t\n")
+			if depth == 0 && expectRoot && !strings.HasPrefix(next, "(") {
+				roots = append(roots, identifier)
+			}
+			expectRoot = false
+		case c == '[' || c == '{':
+			depth++
+			expectRoot = false
+			i++
+		case c == ']' || c == '}':
+			depth--
+			i++
+		case c == '|' && strings.HasPrefix(expression[i:], "||"), c == '&' && strings.HasPrefix(expression[i:], "&&"):
+			expectRoot = true
+			i += 2
+		case c == '|':
+			// the expression after a pipe is evaluated against the result of the expression before it
+			if depth == 0 {
+				return roots
+			}
+			i++
+		case c == '(' || c == ',' || c == '!' || c == '=' || c == '<' || c == '>':
+			expectRoot = true
+			i++
+		default:
+			// sub-expressions, wildcards, the current node and expression references are relative
+			expectRoot = false
+			i++
+		}
+	}
+
+	return roots
+}
+
+// skipQuoted returns the index after the closing quote of the quoted string starting at i
+func skipQuoted(expression string, i int) int {
+	quote := expression[i]
+	for j := i + 1; j < len(expression); j++ {
+		if expression[j] == '\\' {
+			j++
+			continue
+		}
+		if expression[j] == quote {
+			return j + 1
+		}
+	}
+
+	return len(expression)
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
@@ -1,13 +1,20 @@
 package policycache
 
 import (
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/metrics"
 	policy2 "github.com/kyverno/kyverno/pkg/policy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type pMap struct {
@@ -19,9 +26,32 @@ type pMap struct {
 	// kindDataMap {"kind": {{"policytype" : {"policyName","nsname/policyName}}},"kind2": {{"policytype" : {"nsname/policyName" }}}}
 	kindDataMap map[string]map[PolicyType][]string
 
+	// groupWildcards stores the keys of the group wildcard buckets of kindDataMap, e.g. "apps/*".
+	// The "*" bucket and the group wildcard buckets are merged into the results of get.
+	groupWildcards map[string]bool
+
 	// nameCacheMap stores the names of all existing policies in dataMap
 	// Policy names are stored as <namespace>/<name>
 	nameCacheMap map[PolicyType]map[string]bool
+
+	// suspendedMap stores the names of cached policies that are disabled, or suspended by a disabled PolicySet.
+	// These policies are kept in the cache but are skipped by get.
+	suspendedMap map[string]bool
+
+	// admissionDisabledMap stores the names of cached policies with spec.admission set to false,
+	// and backgroundDisabledMap the names of cached policies with spec.background set to false.
+	// These policies are skipped by get and getBackground respectively.
+	admissionDisabledMap  map[string]bool
+	backgroundDisabledMap map[string]bool
+
+	// namespaceFilterMap stores the namespaces matched by the rules of the cached policies,
+	// by policy name, policy type and kind key of kindDataMap. The policies without a filter,
+	// e.g. restored from a snapshot, match all namespaces.
+	namespaceFilterMap map[string]map[PolicyType]map[string]*namespaceFilter
+
+	// operationFilterMap stores the admission operations matched by the rules of the cached policies, by
+	// policy name, policy type and kind key of kindDataMap. The policies without a filter match all operations.
+	operationFilterMap map[string]map[PolicyType]map[string]*operationFilter
 }
 
 // policyCache ...
@@ -33,22 +63,125 @@ type policyCache struct {
 
 	// npLister can list/get namespace policy from the shared informer's store
 	npLister kyvernolister.PolicyLister
+
+	// subscribers receive cache change notifications
+	subscribers subscribers
+
+	// restored holds the policies restored from a snapshot until the cache is warmed up
+	restored restoredPolicies
+
+	// shared holds the copies of the listed policies returned to the callers
+	shared sharedPolicies
+
+	// promConfig counts the cached policies the listers do not return, it may be nil
+	promConfig *metrics.PromConfig
+}
+
+// restoredPolicies stores the policies restored from a snapshot by cached policy name,
+// they are returned when the policies are not yet in the informer listers
+type restoredPolicies struct {
+	sync.RWMutex
+	policies map[string]*kyverno.ClusterPolicy
+}
+
+// sharedPolicies stores the copies of the listed policies by cached policy name. A policy is copied once
+// per resourceVersion, without its unsupported rules, and the copy is shared by the callers.
+type sharedPolicies struct {
+	sync.RWMutex
+	policies map[string]sharedPolicy
+}
+
+// sharedPolicy is the copy of a listed policy and the resourceVersion it is copied from
+type sharedPolicy struct {
+	resourceVersion string
+	policy          kyverno.PolicyInterface
+}
+
+// subscriberBufferSize is the number of events buffered for a subscriber,
+// events are dropped when the buffer of a subscriber is full
+const subscriberBufferSize = 100
+
+// subscribers holds the channels of the cache change subscribers
+type subscribers struct {
+	sync.RWMutex
+	channels []chan CacheEvent
 }
 
 // Interface ...
 // Interface get method use for to get policy names and mostly use to test cache testcases
 type Interface interface {
 
-	// Add adds a policy to the cache
-	Add(policy *kyverno.ClusterPolicy)
+	// Add adds a cluster policy or a namespaced policy to the cache
+	Add(policy kyverno.PolicyInterface)
 
 	// Remove removes a policy from the cache
-	Remove(policy *kyverno.ClusterPolicy)
+	Remove(policy kyverno.PolicyInterface)
+
+	// Update replaces the old version of a policy with the new one atomically, the
+	// lookups never miss the policy while it is re-indexed
+	Update(old, new kyverno.PolicyInterface)
 
 	// GetPolicies returns all policies that apply to a namespace, including cluster-wide policies
 	// If the namespace is empty, only cluster-wide policies are returned
+	// The kind can include a subresource, e.g. "pods/exec", to get the policies of the subresource
+	// Policies with spec.admission set to false are not returned
+	// Enforce policies with spec.rolloutPercentage are returned for both ValidateEnforce and ValidateAudit,
+	// the caller selects them by their validation failure action for the request namespace
+	// The returned policies are shared by the callers until they are updated, they must not be modified
 	GetPolicies(pkey PolicyType, kind string, nspace string) []*kyverno.ClusterPolicy
 
+	// GetBackground returns the policies like GetPolicies, for background processing
+	// Policies with spec.background set to false are not returned
+	GetBackground(pkey PolicyType, kind string, nspace string) []*kyverno.ClusterPolicy
+
+	// GetNamespaced returns the policies like GetPolicies, without the cluster-wide policies
+	// Only the namespaced policies of the namespace are returned, none if the namespace is empty
+	GetNamespaced(pkey PolicyType, kind string, nspace string) []*kyverno.ClusterPolicy
+
+	// GetPoliciesForNamespace returns the policies like GetPolicies, without the policies whose rules match other
+	// namespaces by the namespace names or the namespace selector of their match block. The namespace selectors
+	// are evaluated against the namespace labels, they are not evaluated if the labels are nil
+	GetPoliciesForNamespace(pkey PolicyType, kind string, nspace string, nsLabels map[string]string) []*kyverno.ClusterPolicy
+
+	// GetPoliciesForRequest returns the policies like GetPoliciesForNamespace for the namespace of the admission request,
+	// without the policies whose rules match other operations. The policies of the subresource of the request, e.g.
+	// "pods/exec", are returned with the policies of the kind
+	GetPoliciesForRequest(pkey PolicyType, request Request) []*kyverno.ClusterPolicy
+
+	// GetPolicyInterfaces returns the policies like GetPolicies, the namespaced policies are returned as Policy
+	// instead of being converted to ClusterPolicy
+	GetPolicyInterfaces(pkey PolicyType, kind string, nspace string) []kyverno.PolicyInterface
+
+	// GetPolicyInterfacesForRequest returns the policies like GetPoliciesForRequest, the namespaced policies
+	// are returned as Policy instead of being converted to ClusterPolicy
+	GetPolicyInterfacesForRequest(pkey PolicyType, request Request) []kyverno.PolicyInterface
+
+	// GetAll returns the policies of the policy type for all kinds, including the policies
+	// of all namespaces. Policies with spec.admission set to false are not returned
+	GetAll(pkey PolicyType) []*kyverno.ClusterPolicy
+
+	// ListAll returns the sorted, distinct names of all cached policies across kinds and policy types
+	// Namespaced policies are returned as <namespace>/<name>
+	ListAll() []string
+
+	// Warmup indexes all policies from the listers. It is safe to call Add for
+	// policies that are already indexed, so informer add events can follow.
+	Warmup() error
+
+	// Snapshot returns the state of the cache, to be restored on startup
+	Snapshot() *Snapshot
+
+	// Restore restores the policies of the snapshot whose resourceVersion is unchanged. It returns the number
+	// of restored policies, and true if no policy is changed, deleted or created since the snapshot was saved.
+	// The restored policies are reconciled with the listers by Warmup.
+	Restore(snapshot *Snapshot, resourceVersions map[string]string) (int, bool)
+
+	// Subscribe returns a channel that receives an event whenever a policy is added to
+	// or removed from the cache. Events are buffered, and dropped if the subscriber
+	// does not keep up, so that the cache is never blocked by a subscriber. The returned
+	// function cancels the subscription and closes the channel.
+	Subscribe() (<-chan CacheEvent, func())
+
 	get(pkey PolicyType, kind string, nspace string) []string
 }
 
@@ -64,19 +197,69 @@ func newPolicyCache(log logr.Logger, pLister kyvernolister.ClusterPolicyLister,
 
 	return &policyCache{
 		pMap{
-			nameCacheMap: namesCache,
-			kindDataMap:  make(map[string]map[PolicyType][]string),
+			nameCacheMap:          namesCache,
+			kindDataMap:           make(map[string]map[PolicyType][]string),
+			groupWildcards:        make(map[string]bool),
+			suspendedMap:          make(map[string]bool),
+			admissionDisabledMap:  make(map[string]bool),
+			backgroundDisabledMap: make(map[string]bool),
+			namespaceFilterMap:    make(map[string]map[PolicyType]map[string]*namespaceFilter),
+			operationFilterMap:    make(map[string]map[PolicyType]map[string]*operationFilter),
 		},
 		log,
 		pLister,
 		npLister,
+		subscribers{},
+		restoredPolicies{},
+		sharedPolicies{policies: make(map[string]sharedPolicy)},
+		nil,
 	}
 }
 
 // Add a policy to cache
-func (pc *policyCache) Add(policy *kyverno.ClusterPolicy) {
-	pc.pMap.add(policy)
-	pc.Logger.V(4).Info("policy is added to cache", "name", policy.GetName())
+func (pc *policyCache) Add(policy kyverno.PolicyInterface) {
+	pName, kinds := pc.pMap.add(policy)
+	pc.countSize()
+	pc.Logger.V(4).Info("policy is added to cache", "kind", policy.GetKind(), "namespace", policy.GetNamespace(), "name", policy.GetName())
+	pc.publish(CacheEvent{Type: Added, PolicyName: pName, Kinds: kinds})
+}
+
+// Warmup adds all cluster policies and namespaced policies from the listers to the cache
+func (pc *policyCache) Warmup() error {
+	policies, err := pc.pLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	nsPolicies, err := pc.npLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	// the restored policies that are changed are removed before the current policies are added
+	pc.reconcileRestored(policies, nsPolicies)
+
+	for _, policy := range policies {
+		if policy == nil {
+			pc.Logger.Info("warning: skipping nil policy returned by the cluster policy lister")
+			continue
+		}
+		pName, kinds := pc.pMap.add(policy)
+		pc.publish(CacheEvent{Type: Added, PolicyName: pName, Kinds: kinds})
+	}
+
+	for _, nsPolicy := range nsPolicies {
+		if nsPolicy == nil {
+			pc.Logger.Info("warning: skipping nil policy returned by the policy lister")
+			continue
+		}
+		pName, kinds := pc.pMap.add(nsPolicy)
+		pc.publish(CacheEvent{Type: Added, PolicyName: pName, Kinds: kinds})
+	}
+
+	pc.countSize()
+	pc.Logger.V(2).Info("policy cache is warmed up", "policies", len(policies), "namespacedPolicies", len(nsPolicies))
+	return nil
 }
 
 // Get the list of matched policies
@@ -84,26 +267,191 @@ func (pc *policyCache) get(pkey PolicyType, kind, nspace string) []string {
 	return pc.pMap.get(pkey, kind, nspace)
 }
 func (pc *policyCache) GetPolicies(pkey PolicyType, kind, nspace string) []*kyverno.ClusterPolicy {
-	policies := pc.getPolicyObject(pkey, kind, "")
+	return toClusterPolicies(pc.lookup(pkey, kind, nspace, false, nil))
+}
+
+// GetPolicyInterfaces returns the list of matched cluster policies and namespaced policies
+func (pc *policyCache) GetPolicyInterfaces(pkey PolicyType, kind, nspace string) []kyverno.PolicyInterface {
+	return pc.lookup(pkey, kind, nspace, false, nil)
+}
+
+// GetBackground returns the list of matched policies for background processing
+func (pc *policyCache) GetBackground(pkey PolicyType, kind, nspace string) []*kyverno.ClusterPolicy {
+	return toClusterPolicies(pc.lookup(pkey, kind, nspace, true, nil))
+}
+
+// GetNamespaced returns the list of matched namespaced policies of the namespace
+func (pc *policyCache) GetNamespaced(pkey PolicyType, kind, nspace string) []*kyverno.ClusterPolicy {
 	if nspace == "" {
-		return policies
+		return nil
 	}
 
-	nsPolicies := pc.getPolicyObject(pkey, kind, nspace)
-	return append(policies, nsPolicies...)
+	startTime := time.Now()
+	policies := pc.getPolicyObject(pkey, kind, nspace, false, nil)
+	engine.SortPolicyInterfaces(policies)
+	pc.countLookup(pkey, startTime, policies)
+	return toClusterPolicies(policies)
+}
+
+// GetPoliciesForNamespace returns the list of matched policies whose rules may match the resources of the namespace
+func (pc *policyCache) GetPoliciesForNamespace(pkey PolicyType, kind, nspace string, nsLabels map[string]string) []*kyverno.ClusterPolicy {
+	return toClusterPolicies(pc.lookup(pkey, kind, nspace, false, &lookupQuery{namespace: nspace, labels: nsLabels}))
+}
+
+// GetPoliciesForRequest returns the list of matched policies whose rules may match the admission request
+func (pc *policyCache) GetPoliciesForRequest(pkey PolicyType, request Request) []*kyverno.ClusterPolicy {
+	return toClusterPolicies(pc.GetPolicyInterfacesForRequest(pkey, request))
+}
+
+// GetPolicyInterfacesForRequest returns the list of matched cluster policies and namespaced policies whose
+// rules may match the admission request
+func (pc *policyCache) GetPolicyInterfacesForRequest(pkey PolicyType, request Request) []kyverno.PolicyInterface {
+	return pc.lookup(pkey, request.Kind, request.Namespace, false, &lookupQuery{
+		namespace:   request.Namespace,
+		labels:      request.NamespaceLabels,
+		operation:   request.Operation,
+		subResource: request.SubResource,
+	})
+}
+
+// lookup returns the matched cluster policies and the matched policies of the namespace, in the order
+// their mutate rules are applied
+func (pc *policyCache) lookup(pkey PolicyType, kind, nspace string, background bool, query *lookupQuery) []kyverno.PolicyInterface {
+	startTime := time.Now()
+	policies := pc.getPolicyObject(pkey, kind, "", background, query)
+	if nspace != "" {
+		policies = append(policies, pc.getPolicyObject(pkey, kind, nspace, background, query)...)
+	}
+
+	engine.SortPolicyInterfaces(policies)
+
+	pc.countLookup(pkey, startTime, policies)
+	return policies
+}
+
+// toClusterPolicies converts the namespaced policies to ClusterPolicy, as evaluated by the engine
+func toClusterPolicies(policies []kyverno.PolicyInterface) []*kyverno.ClusterPolicy {
+	if policies == nil {
+		return nil
+	}
+
+	clusterPolicies := make([]*kyverno.ClusterPolicy, 0, len(policies))
+	for _, policy := range policies {
+		clusterPolicies = append(clusterPolicies, policy.AsClusterPolicy())
+	}
+
+	return clusterPolicies
+}
+
+// GetAll returns the list of policies of the policy type for all kinds and namespaces
+func (pc *policyCache) GetAll(pkey PolicyType) []*kyverno.ClusterPolicy {
+	var policies []*kyverno.ClusterPolicy
+	for _, policyName := range pc.pMap.getAll(pkey) {
+		if policy := pc.getPolicy(policyName); policy != nil {
+			policies = append(policies, policy.AsClusterPolicy())
+		}
+	}
+
+	return policies
+}
+
+// ListAll returns the names of all cached policies
+func (pc *policyCache) ListAll() []string {
+	return pc.pMap.listAll()
 }
 
 // Remove a policy from cache
-func (pc *policyCache) Remove(policy *kyverno.ClusterPolicy) {
-	pc.pMap.remove(policy)
-	pc.Logger.V(4).Info("policy is removed from cache", "name", policy.GetName())
+func (pc *policyCache) Remove(policy kyverno.PolicyInterface) {
+	pName, kinds := pc.pMap.remove(policy)
+	pc.removeShared(pName)
+	pc.countSize()
+	pc.Logger.V(4).Info("policy is removed from cache", "kind", policy.GetKind(), "namespace", policy.GetNamespace(), "name", policy.GetName())
+	pc.publish(CacheEvent{Type: Removed, PolicyName: pName, Kinds: kinds})
+}
+
+// Update re-indexes a policy under a single write lock, the subscribers are notified
+// that the old version is removed and the new one is added
+func (pc *policyCache) Update(old, new kyverno.PolicyInterface) {
+	oldName, oldKinds, pName, kinds := pc.pMap.update(old, new)
+	pc.countSize()
+	pc.Logger.V(4).Info("policy is updated in cache", "kind", new.GetKind(), "namespace", new.GetNamespace(), "name", new.GetName())
+	pc.publish(CacheEvent{Type: Removed, PolicyName: oldName, Kinds: oldKinds})
+	pc.publish(CacheEvent{Type: Added, PolicyName: pName, Kinds: kinds})
+}
+
+// Subscribe returns a channel to receive cache change notifications, and the function cancelling the subscription
+func (pc *policyCache) Subscribe() (<-chan CacheEvent, func()) {
+	ch := make(chan CacheEvent, subscriberBufferSize)
+
+	pc.subscribers.Lock()
+	pc.subscribers.channels = append(pc.subscribers.channels, ch)
+	pc.subscribers.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { pc.unsubscribe(ch) })
+	}
+
+	return ch, cancel
+}
+
+// unsubscribe removes the channel from the subscribers and closes it, the events
+// are published with the read lock held so that no event is sent once it is closed
+func (pc *policyCache) unsubscribe(ch chan CacheEvent) {
+	pc.subscribers.Lock()
+	defer pc.subscribers.Unlock()
+
+	for i, c := range pc.subscribers.channels {
+		if c == ch {
+			pc.subscribers.channels = append(pc.subscribers.channels[:i], pc.subscribers.channels[i+1:]...)
+			break
+		}
+	}
+
+	close(ch)
 }
 
-func (m *pMap) add(policy *kyverno.ClusterPolicy) {
+// publish sends the event to all subscribers without blocking,
+// it must be called after the cache lock is released
+func (pc *policyCache) publish(event CacheEvent) {
+	pc.subscribers.RLock()
+	defer pc.subscribers.RUnlock()
+
+	for _, ch := range pc.subscribers.channels {
+		select {
+		case ch <- event:
+		default:
+			pc.Logger.V(3).Info("subscriber is not ready, dropping cache event", "type", event.Type, "policy", event.PolicyName)
+		}
+	}
+}
+
+// add indexes the policy and returns the cached policy name and the normalized kinds of its rules
+func (m *pMap) add(policy kyverno.PolicyInterface) (string, []string) {
 	m.Lock()
 	defer m.Unlock()
+	return m.addLocked(policy)
+}
 
-	enforcePolicy := policy.Spec.ValidationFailureAction == "enforce"
+// update removes the old version of the policy and indexes the new one under the same lock,
+// it returns the cached policy names and the normalized kinds of both versions
+func (m *pMap) update(old, new kyverno.PolicyInterface) (string, []string, string, []string) {
+	m.Lock()
+	defer m.Unlock()
+	oldName, oldKinds := m.removeLocked(old)
+	pName, kinds := m.addLocked(new)
+	return oldName, oldKinds, pName, kinds
+}
+
+// addLocked indexes the policy, the caller holds the write lock
+func (m *pMap) addLocked(p kyverno.PolicyInterface) (string, []string) {
+	// the index is built from the spec, the status and the metadata, which both kinds of policies share
+	policy := p.AsClusterPolicy()
+	enforcePolicy := policy.GetValidationFailureAction() == "enforce"
+	// a policy that is rolled out to a percentage of the namespaces is indexed for both
+	// actions, the webhooks select the action per request so that changing the percentage
+	// does not require the cache to be rebuilt
+	rollingOut := policy.IsRollingOut()
 	mutateMap := m.nameCacheMap[Mutate]
 	validateEnforceMap := m.nameCacheMap[ValidateEnforce]
 	validateAuditMap := m.nameCacheMap[ValidateAudit]
@@ -116,16 +464,55 @@ func (m *pMap) add(policy *kyverno.ClusterPolicy) {
 		pName = pSpace + "/" + pName
 	}
 
+	if !policy.IsEnabled() || policy.IsSuspended() {
+		m.suspendedMap[pName] = true
+	} else {
+		delete(m.suspendedMap, pName)
+	}
+
+	if !policy.AdmissionProcessingEnabled() {
+		m.admissionDisabledMap[pName] = true
+	} else {
+		delete(m.admissionDisabledMap, pName)
+	}
+
+	if !policy.BackgroundProcessingEnabled() {
+		m.backgroundDisabledMap[pName] = true
+	} else {
+		delete(m.backgroundDisabledMap, pName)
+	}
+
+	namespaceFilters := make(map[PolicyType]map[string]*namespaceFilter)
+	m.namespaceFilterMap[pName] = namespaceFilters
+	operationFilters := make(map[PolicyType]map[string]*operationFilter)
+	m.operationFilterMap[pName] = operationFilters
+	addNamespaces := func(pkey PolicyType, kind string, rule kyverno.Rule) {
+		if namespaceFilters[pkey] == nil {
+			namespaceFilters[pkey] = make(map[string]*namespaceFilter)
+			operationFilters[pkey] = make(map[string]*operationFilter)
+		}
+		if namespaceFilters[pkey][kind] == nil {
+			namespaceFilters[pkey][kind] = &namespaceFilter{}
+			operationFilters[pkey][kind] = &operationFilter{}
+		}
+		namespaceFilters[pkey][kind].add(rule)
+		operationFilters[pkey][kind].add(rule)
+	}
+
 	for _, rule := range policy.Spec.Rules {
 
 		for _, gvk := range rule.MatchResources.Kinds {
-			_, kind := common.GetKindFromGVK(gvk)
+			kind := cacheKind(gvk)
 			_, ok := m.kindDataMap[kind]
 			if !ok {
 				m.kindDataMap[kind] = make(map[PolicyType][]string)
 			}
+			if isGroupWildcard(kind) {
+				m.groupWildcards[kind] = true
+			}
 
 			if rule.HasMutate() {
+				addNamespaces(Mutate, kind, rule)
 				if !mutateMap[kind+"/"+pName] {
 					mutateMap[kind+"/"+pName] = true
 					mutatePolicy := m.kindDataMap[kind][Mutate]
@@ -136,15 +523,19 @@ func (m *pMap) add(policy *kyverno.ClusterPolicy) {
 
 			if rule.HasValidate() {
 				if enforcePolicy {
+					addNamespaces(ValidateEnforce, kind, rule)
 					if !validateEnforceMap[kind+"/"+pName] {
 						validateEnforceMap[kind+"/"+pName] = true
 						validatePolicy := m.kindDataMap[kind][ValidateEnforce]
 						m.kindDataMap[kind][ValidateEnforce] = append(validatePolicy, pName)
 					}
-					continue
+					if !rollingOut {
+						continue
+					}
 				}
 
 				// ValidateAudit
+				addNamespaces(ValidateAudit, kind, rule)
 				if !validateAuditMap[kind+"/"+pName] {
 					validateAuditMap[kind+"/"+pName] = true
 					validatePolicy := m.kindDataMap[kind][ValidateAudit]
@@ -154,6 +545,7 @@ func (m *pMap) add(policy *kyverno.ClusterPolicy) {
 			}
 
 			if rule.HasGenerate() {
+				addNamespaces(Generate, kind, rule)
 				if !generateMap[kind+"/"+pName] {
 					generateMap[kind+"/"+pName] = true
 					generatePolicy := m.kindDataMap[kind][Generate]
@@ -163,6 +555,7 @@ func (m *pMap) add(policy *kyverno.ClusterPolicy) {
 			}
 
 			if rule.HasVerifyImages() {
+				addNamespaces(VerifyImages, kind, rule)
 				if !imageVerifyMap[kind+"/"+pName] {
 					imageVerifyMap[kind+"/"+pName] = true
 					imageVerifyMapPolicy := m.kindDataMap[kind][VerifyImages]
@@ -178,37 +571,163 @@ func (m *pMap) add(policy *kyverno.ClusterPolicy) {
 	m.nameCacheMap[ValidateAudit] = validateAuditMap
 	m.nameCacheMap[Generate] = generateMap
 	m.nameCacheMap[VerifyImages] = imageVerifyMap
+	return pName, policyKinds(policy)
 }
 
-func (pc *pMap) get(key PolicyType, gvk, namespace string) (names []string) {
+// get returns the names of the matched policies for admission review requests
+func (pc *pMap) get(key PolicyType, gvk, namespace string) []string {
+	return pc.getNames(key, gvk, namespace, false, nil)
+}
+
+// getBackground returns the names of the matched policies for background processing
+func (pc *pMap) getBackground(key PolicyType, gvk, namespace string) []string {
+	return pc.getNames(key, gvk, namespace, true, nil)
+}
+
+// getNames returns the names of the matched policies, sorted by name. If the query is not nil, the policies
+// whose rules do not match the namespace or the operation of the query are skipped, and the policies of the
+// subresource of the query are added. The namespaces are not filtered for the Namespace kind, as its rules
+// match the name of the namespace.
+func (pc *pMap) getNames(key PolicyType, gvk, namespace string, background bool, query *lookupQuery) (names []string) {
 	pc.RLock()
 	defer pc.RUnlock()
-	_, kind := common.GetKindFromGVK(gvk)
-	for _, policyName := range pc.kindDataMap[kind][key] {
-		ns, key, isNamespacedPolicy := policy2.ParseNamespacedPolicy(policyName)
-		if !isNamespacedPolicy && namespace == "" {
-			names = append(names, key)
-		} else {
-			if ns == namespace {
-				names = append(names, policyName)
+
+	disabledMap := pc.admissionDisabledMap
+	if background {
+		disabledMap = pc.backgroundDisabledMap
+	}
+
+	filterNamespaces := query != nil && normalizeKind(gvk) != "namespace"
+	kinds := pc.lookupKinds(gvk)
+	if query != nil && query.subResource != "" {
+		kinds = append(kinds, normalizeKind(query.subResource))
+	}
+
+	seen := make(map[string]bool)
+	for _, kind := range kinds {
+		for _, policyName := range pc.kindDataMap[kind][key] {
+			if seen[policyName] || pc.suspendedMap[policyName] || disabledMap[policyName] {
+				continue
+			}
+
+			// the policy may match in another bucket, e.g. with the rules of another kind
+			if filterNamespaces && !pc.namespaceFilterMap[policyName][key][kind].matches(query) {
+				continue
+			}
+
+			if query != nil && !pc.operationFilterMap[policyName][key][kind].matches(query.operation) {
+				continue
+			}
+			seen[policyName] = true
+
+			ns, key, isNamespacedPolicy := policy2.ParseNamespacedPolicy(policyName)
+			if !isNamespacedPolicy && namespace == "" {
+				names = append(names, key)
+			} else {
+				if ns == namespace {
+					names = append(names, policyName)
+				}
+			}
+		}
+	}
+
+	// policies are appended in the order they are added to the cache, sort them by
+	// the fully-qualified policy name so that mutate rules are applied in a stable order
+	sort.Strings(names)
+	return names
+}
+
+// lookupKinds returns the buckets of kindDataMap holding the policies of the kind, the caller holds the read lock.
+// The policies matching all kinds, and the kinds of a group, are returned for the kinds without a subresource.
+// The group wildcard buckets of other groups are skipped if the kind is qualified with its group and version,
+// e.g. "apps/v1/Deployment" or "v1/Pod", otherwise all of them are returned and the engine matches the group.
+func (pc *pMap) lookupKinds(gvk string) []string {
+	kind := normalizeKind(gvk)
+	if kind == "*" || strings.Contains(kind, "/") {
+		return []string{kind}
+	}
+
+	kinds := []string{kind, "*"}
+	group, ok := parseGroup(gvk)
+	if !ok {
+		for wildcard := range pc.groupWildcards {
+			kinds = append(kinds, wildcard)
+		}
+	} else if group != "" {
+		kinds = append(kinds, group+"/*")
+	}
+
+	return kinds
+}
+
+// getAll returns the sorted, distinct names of the policies of the policy type for admission review requests
+func (pc *pMap) getAll(key PolicyType) []string {
+	pc.RLock()
+	defer pc.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, dataMap := range pc.kindDataMap {
+		for _, policyName := range dataMap[key] {
+			if pc.suspendedMap[policyName] || pc.admissionDisabledMap[policyName] {
+				continue
+			}
+			seen[policyName] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (pc *pMap) listAll() []string {
+	pc.RLock()
+	defer pc.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, dataMap := range pc.kindDataMap {
+		for _, policyNames := range dataMap {
+			for _, policyName := range policyNames {
+				seen[policyName] = true
 			}
 		}
 	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	return names
 }
 
-func (m *pMap) remove(policy *kyverno.ClusterPolicy) {
+// remove removes the policy and returns the cached policy name and the normalized kinds of its rules
+func (m *pMap) remove(policy kyverno.PolicyInterface) (string, []string) {
 	m.Lock()
 	defer m.Unlock()
+	return m.removeLocked(policy)
+}
+
+// removeLocked removes the policy, the caller holds the write lock
+func (m *pMap) removeLocked(p kyverno.PolicyInterface) (string, []string) {
+	policy := p.AsClusterPolicy()
 	var pName = policy.GetName()
 	pSpace := policy.GetNamespace()
 	if pSpace != "" {
 		pName = pSpace + "/" + pName
 	}
 
+	delete(m.suspendedMap, pName)
+	delete(m.admissionDisabledMap, pName)
+	delete(m.backgroundDisabledMap, pName)
+	delete(m.namespaceFilterMap, pName)
+	delete(m.operationFilterMap, pName)
 	for _, rule := range policy.Spec.Rules {
 		for _, gvk := range rule.MatchResources.Kinds {
-			_, kind := common.GetKindFromGVK(gvk)
+			kind := cacheKind(gvk)
 			dataMap := m.kindDataMap[kind]
 			for policyType, policies := range dataMap {
 				var newPolicies []string
@@ -228,22 +747,239 @@ func (m *pMap) remove(policy *kyverno.ClusterPolicy) {
 
 		}
 	}
+
+	return pName, policyKinds(policy)
 }
-func (m *policyCache) getPolicyObject(key PolicyType, gvk string, nspace string) (policyObject []*kyverno.ClusterPolicy) {
-	_, kind := common.GetKindFromGVK(gvk)
-	policyNames := m.pMap.get(key, kind, nspace)
+
+// getPolicyObject returns the matched policies, without the rules using fields unknown to this version.
+// The policies are shared by the callers and must not be modified, see getShared.
+func (m *policyCache) getPolicyObject(key PolicyType, gvk string, nspace string, background bool, query *lookupQuery) (policyObject []kyverno.PolicyInterface) {
+	policyNames := m.pMap.getNames(key, gvk, nspace, background, query)
 	for _, policyName := range policyNames {
-		var policy *kyverno.ClusterPolicy
-		ns, key, isNamespacedPolicy := policy2.ParseNamespacedPolicy(policyName)
-		if !isNamespacedPolicy {
-			policy, _ = m.pLister.Get(key)
-		} else {
-			if ns == nspace {
-				nspolicy, _ := m.npLister.Policies(ns).Get(key)
-				policy = policy2.ConvertPolicyToClusterPolicy(nspolicy)
-			}
+		if policy := m.getPolicy(policyName); policy != nil {
+			policyObject = append(policyObject, policy)
 		}
-		policyObject = append(policyObject, policy)
 	}
 	return policyObject
 }
+
+// getPolicy returns the shared copy of the cached cluster policy or namespaced policy from the listers,
+// or a copy of the restored policy, or nil if it is not found
+func (m *policyCache) getPolicy(policyName string) kyverno.PolicyInterface {
+	ns, key, isNamespacedPolicy := policy2.ParseNamespacedPolicy(policyName)
+	if !isNamespacedPolicy {
+		cpolicy, err := m.pLister.Get(key)
+		if err != nil {
+			if policy := m.getRestored(policyName); policy != nil {
+				policy.RemoveUnsupportedRules()
+				return policy
+			}
+			m.Logger.V(4).Info("cached policy is not found", "name", key, "error", err.Error())
+			m.countMiss(metrics.Cluster, "", key, "not_found")
+			return nil
+		}
+		if cpolicy == nil {
+			m.Logger.Info("warning: skipping cached policy as the lister returned nil", "name", key)
+			m.countMiss(metrics.Cluster, "", key, "nil")
+			return nil
+		}
+		return m.getShared(policyName, cpolicy)
+	}
+
+	nspolicy, err := m.npLister.Policies(ns).Get(key)
+	if err != nil {
+		if policy := m.getRestored(policyName); policy != nil {
+			policy.RemoveUnsupportedRules()
+			return policy
+		}
+		m.Logger.V(4).Info("cached policy is not found", "namespace", ns, "name", key, "error", err.Error())
+		m.countMiss(metrics.Namespaced, ns, key, "not_found")
+		return nil
+	}
+	if nspolicy == nil {
+		m.Logger.Info("warning: skipping cached policy as the lister returned nil", "namespace", ns, "name", key)
+		m.countMiss(metrics.Namespaced, ns, key, "nil")
+		return nil
+	}
+	return m.getShared(policyName, nspolicy)
+}
+
+// getShared returns the copy of the listed policy shared by the callers, the policy is copied again when
+// its resourceVersion changes. The listers return the objects of the informer cache, the unsupported
+// rules are removed from a copy. The policies without a resourceVersion are copied on every lookup.
+func (m *policyCache) getShared(policyName string, policy kyverno.PolicyInterface) kyverno.PolicyInterface {
+	resourceVersion := policy.GetResourceVersion()
+	if resourceVersion != "" {
+		m.shared.RLock()
+		shared, ok := m.shared.policies[policyName]
+		m.shared.RUnlock()
+		if ok && shared.resourceVersion == resourceVersion {
+			return shared.policy
+		}
+	}
+
+	copied := policy.CreateDeepCopy()
+	copied.RemoveUnsupportedRules()
+	if resourceVersion != "" {
+		m.shared.Lock()
+		m.shared.policies[policyName] = sharedPolicy{resourceVersion: resourceVersion, policy: copied}
+		m.shared.Unlock()
+	}
+	return copied
+}
+
+// removeShared drops the shared copy of a removed policy
+func (m *policyCache) removeShared(policyName string) {
+	m.shared.Lock()
+	delete(m.shared.policies, policyName)
+	m.shared.Unlock()
+}
+
+// countMiss counts a cached policy the listers do not return
+func (m *policyCache) countMiss(policyType metrics.PolicyType, namespace, name, reason string) {
+	if m.promConfig == nil {
+		return
+	}
+
+	m.promConfig.Metrics.PolicyCacheMisses.With(prom.Labels{
+		"policy_type":      string(policyType),
+		"policy_namespace": namespace,
+		"policy_name":      name,
+		"reason":           reason,
+	}).Inc()
+}
+
+// sizes returns the number of distinct cached policies by policy type, and by policy type and kind
+func (m *pMap) sizes() (map[PolicyType]int, map[PolicyType]map[string]int) {
+	m.RLock()
+	defer m.RUnlock()
+
+	names := make(map[PolicyType]map[string]bool)
+	kindSizes := make(map[PolicyType]map[string]int)
+	for pkey := range m.nameCacheMap {
+		names[pkey] = make(map[string]bool)
+		kindSizes[pkey] = make(map[string]int)
+	}
+
+	for kind, dataMap := range m.kindDataMap {
+		for pkey, policyNames := range dataMap {
+			if kindSizes[pkey] == nil {
+				continue
+			}
+
+			kindSizes[pkey][kind] = len(policyNames)
+			for _, policyName := range policyNames {
+				names[pkey][policyName] = true
+			}
+		}
+	}
+
+	sizes := make(map[PolicyType]int)
+	for pkey := range names {
+		sizes[pkey] = len(names[pkey])
+	}
+	return sizes, kindSizes
+}
+
+// countSize sets the number of cached policies by policy type and by kind, the kinds without
+// policies are set to zero
+func (m *policyCache) countSize() {
+	if m.promConfig == nil {
+		return
+	}
+
+	sizes, kindSizes := m.pMap.sizes()
+	for pkey, size := range sizes {
+		m.promConfig.Metrics.PolicyCacheSize.With(prom.Labels{"policy_type": pkey.String()}).Set(float64(size))
+	}
+
+	for pkey, kinds := range kindSizes {
+		for kind, size := range kinds {
+			m.promConfig.Metrics.PolicyCacheKindSize.With(prom.Labels{"policy_type": pkey.String(), "kind": kind}).Set(float64(size))
+		}
+	}
+}
+
+// countLookup counts a lookup of the policies of the policy type, and observes its latency
+func (m *policyCache) countLookup(pkey PolicyType, startTime time.Time, policies []kyverno.PolicyInterface) {
+	if m.promConfig == nil {
+		return
+	}
+
+	result := "miss"
+	if len(policies) > 0 {
+		result = "hit"
+	}
+
+	m.promConfig.Metrics.PolicyCacheLookups.With(prom.Labels{"policy_type": pkey.String(), "result": result}).Inc()
+	latency := float64(time.Since(startTime).Microseconds()) / 1000
+	m.promConfig.Metrics.PolicyCacheLookupLatency.With(prom.Labels{"policy_type": pkey.String()}).Observe(latency)
+}
+
+// policyKinds returns the sorted, distinct normalized kinds matched by the policy rules
+func policyKinds(policy *kyverno.ClusterPolicy) []string {
+	seen := make(map[string]bool)
+	kinds := []string{}
+	for _, rule := range policy.Spec.Rules {
+		for _, gvk := range rule.MatchResources.Kinds {
+			kind := cacheKind(gvk)
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	sort.Strings(kinds)
+	return kinds
+}
+
+// normalizeKind returns the canonical form of a kind used to key the cache, i.e. the lower case
+// singular kind, so that "Pod", "pod" and "pods" are stored in the same bucket.
+// The kind can be specified with a group and version, e.g. "apps/v1/Deployment", and with
+// a subresource, e.g. "pods/exec". Subresources are keyed as "<kind>/<subresource>",
+// i.e. "pod/exec", so that they are isolated from the policies of the parent kind.
+func normalizeKind(gvk string) string {
+	_, kind, subresource := common.ParseKind(gvk)
+	kind = common.SingularKind(kind)
+	if subresource != "" {
+		return kind + "/" + strings.ToLower(subresource)
+	}
+	return kind
+}
+
+// cacheKind returns the key of the bucket storing the policies matching the kind. The wildcard kinds
+// are stored in separate buckets, "*" for all kinds, e.g. "*" or "v1/*", and "<group>/*" for the kinds
+// of a group, e.g. "apps/*" or "apps/v1/*". The other kinds are normalized.
+func cacheKind(gvk string) string {
+	apiVersion, kind, _ := common.ParseKind(gvk)
+	if kind != "*" {
+		return normalizeKind(gvk)
+	}
+
+	if group := strings.Split(apiVersion, "/"); len(group) == 2 && group[0] != "*" {
+		return strings.ToLower(group[0]) + "/*"
+	}
+
+	return "*"
+}
+
+// isGroupWildcard returns true if the cache key is the bucket of the kinds of a group, e.g. "apps/*"
+func isGroupWildcard(kind string) bool {
+	return kind != "*" && strings.HasSuffix(kind, "/*")
+}
+
+// parseGroup returns the API group of the kind, and false if the kind is not qualified with its
+// version, e.g. "Pod" or "*/Pod". The group of the kinds qualified with a version only, e.g. "v1/Pod", is empty.
+func parseGroup(gvk string) (string, bool) {
+	apiVersion, _, _ := common.ParseKind(gvk)
+	if apiVersion == "" || apiVersion == "*" {
+		return "", false
+	}
+
+	if group := strings.Split(apiVersion, "/"); len(group) == 2 {
+		return strings.ToLower(group[0]), group[0] != "*"
+	}
+
+	return "", true
+}
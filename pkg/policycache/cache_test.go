@@ -3,13 +3,23 @@ package policycache
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 	"testing"
 
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 
 	lv1 "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	policy2 "github.com/kyverno/kyverno/pkg/policy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -365,7 +375,7 @@ func newNsPolicy(t *testing.T) *kyverno.ClusterPolicy {
 	err := json.Unmarshal(rawPolicy, &policy)
 	assert.NilError(t, err)
 
-	return convertPolicyToClusterPolicy(policy)
+	return policy2.ConvertPolicyToClusterPolicy(policy)
 }
 
 func newGVKPolicy(t *testing.T) *kyverno.ClusterPolicy {
@@ -464,7 +474,7 @@ func newUserTestPolicy(t *testing.T) *kyverno.ClusterPolicy {
 	err := json.Unmarshal(rawPolicy, &policy)
 	assert.NilError(t, err)
 
-	return convertPolicyToClusterPolicy(policy)
+	return policy2.ConvertPolicyToClusterPolicy(policy)
 }
 
 func newgenratePolicy(t *testing.T) *kyverno.ClusterPolicy {
@@ -602,7 +612,7 @@ func newNsMutatePolicy(t *testing.T) *kyverno.ClusterPolicy {
 	err := json.Unmarshal(rawPolicy, &policy)
 	assert.NilError(t, err)
 
-	return convertPolicyToClusterPolicy(policy)
+	return policy2.ConvertPolicyToClusterPolicy(policy)
 }
 
 func Test_Ns_All(t *testing.T) {
@@ -810,6 +820,39 @@ func Test_Generate_Policy(t *testing.T) {
 	}
 }
 
+func Test_VerifyImages_Policy(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "check-image"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "check-image",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"verifyImages": [{"image": "ghcr.io/kyverno/test-verify-image:*", "key": "-----BEGIN PUBLIC KEY-----"}]
+				}
+			]
+		}
+	}`)
+
+	var policy *kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	pCache.Add(policy)
+	pCache.Add(policy)
+
+	// the image verification rules are only indexed for their own policy type
+	assert.DeepEqual(t, pCache.get(VerifyImages, "Pod", ""), []string{"check-image"})
+	assert.Equal(t, len(pCache.get(Mutate, "Pod", "")), 0)
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 0)
+
+	pCache.Remove(policy)
+	assert.Equal(t, len(pCache.get(VerifyImages, "Pod", "")), 0)
+}
+
 func Test_NsMutate_Policy(t *testing.T) {
 	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
 	policy := newMutatePolicy(t)
@@ -834,3 +877,1159 @@ func Test_NsMutate_Policy(t *testing.T) {
 	}
 
 }
+
+func Test_Suspend_Resume_Policy(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policy := newPolicy(t)
+	kind := "Pod"
+	pCache.Add(policy)
+
+	// suspend
+	suspended := policy.DeepCopy()
+	suspended.Status.Conditions = []metav1.Condition{
+		{Type: kyverno.PolicyConditionSuspended, Status: metav1.ConditionTrue, Reason: "PolicySetDisabled"},
+	}
+	pCache.Remove(policy)
+	pCache.Add(suspended)
+	validateEnforce := pCache.get(ValidateEnforce, kind, "")
+	if len(validateEnforce) != 0 {
+		t.Errorf("expected 0 validate enforce policy, found %v", len(validateEnforce))
+	}
+
+	mutate := pCache.get(Mutate, kind, "")
+	if len(mutate) != 0 {
+		t.Errorf("expected 0 mutate policy, found %v", len(mutate))
+	}
+
+	// resume
+	pCache.Remove(suspended)
+	pCache.Add(policy)
+	validateEnforce = pCache.get(ValidateEnforce, kind, "")
+	if len(validateEnforce) != 1 {
+		t.Errorf("expected 1 validate enforce policy, found %v", len(validateEnforce))
+	}
+
+	mutate = pCache.get(Mutate, kind, "")
+	if len(mutate) != 1 {
+		t.Errorf("expected 1 mutate policy, found %v", len(mutate))
+	}
+}
+
+func Test_Effective_Validation_Failure_Action(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policy := newPolicy(t)
+	policy.Spec.ValidationFailureAction = "audit"
+	policy.Status.ValidationFailureAction = "enforce"
+	kind := "Pod"
+	pCache.Add(policy)
+
+	validateEnforce := pCache.get(ValidateEnforce, kind, "")
+	if len(validateEnforce) != 1 {
+		t.Errorf("expected 1 validate enforce policy, found %v", len(validateEnforce))
+	}
+
+	validateAudit := pCache.get(ValidateAudit, kind, "")
+	if len(validateAudit) != 0 {
+		t.Errorf("expected 0 validate audit policy, found %v", len(validateAudit))
+	}
+
+	// clearing the override restores the spec setting
+	pCache.Remove(policy)
+	policy.Status.ValidationFailureAction = ""
+	pCache.Add(policy)
+
+	validateAudit = pCache.get(ValidateAudit, kind, "")
+	if len(validateAudit) != 1 {
+		t.Errorf("expected 1 validate audit policy, found %v", len(validateAudit))
+	}
+}
+
+func Test_List_All(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policy := newPolicy(t)
+	nsPolicy := newNsPolicy(t)
+	mutatePolicy := newMutatePolicy(t)
+	nsMutatePolicy := newNsMutatePolicy(t)
+	generatePolicy := newgenratePolicy(t)
+
+	assert.Equal(t, len(pCache.ListAll()), 0)
+
+	pCache.Add(policy)
+	pCache.Add(nsPolicy)
+	pCache.Add(mutatePolicy)
+	pCache.Add(nsMutatePolicy)
+	pCache.Add(generatePolicy)
+	pCache.Add(policy)
+
+	assert.DeepEqual(t, pCache.ListAll(), []string{
+		"add-networkpolicy",
+		"logger-sidecar",
+		"logger/logger-sidecar",
+		"test-policy",
+		"test/test-policy",
+	})
+
+	pCache.Remove(policy)
+	pCache.Remove(nsMutatePolicy)
+
+	assert.DeepEqual(t, pCache.ListAll(), []string{
+		"add-networkpolicy",
+		"logger-sidecar",
+		"test/test-policy",
+	})
+}
+
+func Test_Disable_Enable_Policy(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policy := newPolicy(t)
+	kind := "Pod"
+	pCache.Add(policy)
+
+	// disable
+	enabled := false
+	disabled := policy.DeepCopy()
+	disabled.Spec.Enabled = &enabled
+	pCache.Remove(policy)
+	pCache.Add(disabled)
+
+	validateEnforce := pCache.get(ValidateEnforce, kind, "")
+	if len(validateEnforce) != 0 {
+		t.Errorf("expected 0 validate enforce policy, found %v", len(validateEnforce))
+	}
+
+	mutate := pCache.get(Mutate, kind, "")
+	if len(mutate) != 0 {
+		t.Errorf("expected 0 mutate policy, found %v", len(mutate))
+	}
+
+	// disabled policies are kept in the cache
+	assert.DeepEqual(t, pCache.ListAll(), []string{policy.GetName()})
+
+	// enable
+	enabled = true
+	pCache.Remove(disabled)
+	pCache.Add(policy)
+
+	validateEnforce = pCache.get(ValidateEnforce, kind, "")
+	if len(validateEnforce) != 1 {
+		t.Errorf("expected 1 validate enforce policy, found %v", len(validateEnforce))
+	}
+
+	mutate = pCache.get(Mutate, kind, "")
+	if len(mutate) != 1 {
+		t.Errorf("expected 1 mutate policy, found %v", len(mutate))
+	}
+}
+
+func Test_Disable_Enable_Policy_GetPolicies(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	policy := newPolicy(t)
+	kind := "Pod"
+	assert.NilError(t, indexer.Add(policy))
+	pCache.Add(policy)
+
+	// the rules computed by the webhook come from the cached policies
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, kind, "")), 1)
+	assert.Equal(t, len(pCache.GetPolicies(Mutate, kind, "test")), 1)
+
+	// disabling the policy shrinks the webhook rules
+	enabled := false
+	disabled := policy.DeepCopy()
+	disabled.Spec.Enabled = &enabled
+	assert.NilError(t, indexer.Update(disabled))
+	pCache.Remove(policy)
+	pCache.Add(disabled)
+
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, kind, "")), 0)
+	assert.Equal(t, len(pCache.GetPolicies(Mutate, kind, "test")), 0)
+
+	// enabling the policy again restores them
+	assert.NilError(t, indexer.Update(policy))
+	pCache.Remove(disabled)
+	pCache.Add(policy)
+
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, kind, "")), 1)
+	assert.Equal(t, len(pCache.GetPolicies(Mutate, kind, "test")), 1)
+}
+
+func Test_Warmup(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	policy := newPolicy(t)
+	nsPolicy := kyverno.Policy(*newNsPolicy(t))
+	assert.NilError(t, indexer.Add(policy))
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+	assert.NilError(t, pCache.Warmup())
+
+	// policies are available without any add event
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "")), 1)
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", nsPolicy.GetNamespace())), 2)
+
+	// add events replayed by the informers and a repeated warmup do not duplicate entries
+	pCache.Add(policy)
+	pCache.Add(newNsPolicy(t))
+	assert.NilError(t, pCache.Warmup())
+
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 1)
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", nsPolicy.GetNamespace())), 1)
+	assert.DeepEqual(t, pCache.ListAll(), []string{policy.GetName(), nsPolicy.GetNamespace() + "/" + nsPolicy.GetName()})
+}
+
+func newKindTestPolicy(name string, kinds ...string) *kyverno.ClusterPolicy {
+	return &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kyverno.Spec{
+			ValidationFailureAction: "enforce",
+			Rules: []kyverno.Rule{
+				{
+					Name:           "check-labels",
+					MatchResources: kyverno.MatchResources{ResourceDescription: kyverno.ResourceDescription{Kinds: kinds}},
+					Validation:     kyverno.Validation{Message: "labels are required"},
+				},
+			},
+		},
+	}
+}
+
+func Test_Kind_Normalization(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	pCache.Add(newKindTestPolicy("canonical", "Pod", "NetworkPolicy", "Ingress", "apps/v1/Deployment"))
+	pCache.Add(newKindTestPolicy("plural", "pods", "networkpolicies", "ingresses", "deployments"))
+
+	for _, kind := range []string{"Pod", "pod", "pods", "PODS", "v1/Pod"} {
+		assert.Equal(t, len(pCache.get(ValidateEnforce, kind, "")), 2, kind)
+	}
+
+	for _, kind := range []string{"NetworkPolicy", "networkpolicy", "networkpolicies", "NetworkPolicies"} {
+		assert.Equal(t, len(pCache.get(ValidateEnforce, kind, "")), 2, kind)
+	}
+
+	for _, kind := range []string{"Ingress", "ingress", "ingresses"} {
+		assert.Equal(t, len(pCache.get(ValidateEnforce, kind, "")), 2, kind)
+	}
+
+	for _, kind := range []string{"Deployment", "deployments", "apps/v1/Deployment"} {
+		assert.Equal(t, len(pCache.get(ValidateEnforce, kind, "")), 2, kind)
+	}
+
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Service", "")), 0)
+
+	// policies are removed from the normalized bucket
+	pCache.Remove(newKindTestPolicy("plural", "pods", "networkpolicies", "ingresses", "deployments"))
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "pods", "")), 1)
+}
+
+func Test_Normalize_Kind(t *testing.T) {
+	tests := map[string]string{
+		"Pod":                       "pod",
+		"pods":                      "pod",
+		"ConfigMap":                 "configmap",
+		"configmaps":                "configmap",
+		"Ingress":                   "ingress",
+		"ingresses":                 "ingress",
+		"IngressClasses":            "ingressclass",
+		"NetworkPolicy":             "networkpolicy",
+		"networkpolicies":           "networkpolicy",
+		"apps/v1/Deployment":        "deployment",
+		"batch/v1beta1/jobs":        "job",
+		"Endpoints":                 "endpoint",
+		"*":                         "*",
+		"v1/Pod":                    "pod",
+		"*/Pod":                     "pod",
+		"pods/exec":                 "pod/exec",
+		"Pod/exec":                  "pod/exec",
+		"v1/pods/exec":              "pod/exec",
+		"deployments/scale":         "deployment/scale",
+		"apps/v1/deployments/scale": "deployment/scale",
+	}
+
+	for kind, expected := range tests {
+		assert.Equal(t, normalizeKind(kind), expected, kind)
+	}
+}
+
+func Test_Subscribe(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	events, cancel := pCache.Subscribe()
+	defer cancel()
+
+	policy := newKindTestPolicy("require-labels", "Pod", "apps/v1/Deployment", "pods")
+	pCache.Add(policy)
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Added, PolicyName: "require-labels", Kinds: []string{"deployment", "pod"}})
+
+	nsPolicy := newKindTestPolicy("require-labels", "Service")
+	nsPolicy.SetNamespace("test")
+	pCache.Add(nsPolicy)
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Added, PolicyName: "test/require-labels", Kinds: []string{"service"}})
+
+	pCache.Remove(policy)
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Removed, PolicyName: "require-labels", Kinds: []string{"deployment", "pod"}})
+
+	pCache.Remove(nsPolicy)
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Removed, PolicyName: "test/require-labels", Kinds: []string{"service"}})
+}
+
+func Test_Subscribe_Does_Not_Block(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	slow, cancelSlow := pCache.Subscribe()
+	defer cancelSlow()
+
+	// the subscriber does not consume events, the cache is not blocked
+	for i := 0; i < subscriberBufferSize+10; i++ {
+		pCache.Add(newKindTestPolicy(fmt.Sprintf("policy-%d", i), "Pod"))
+	}
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), subscriberBufferSize+10)
+	assert.Equal(t, len(slow), subscriberBufferSize)
+
+	// subscribers are independent
+	events, cancel := pCache.Subscribe()
+	defer cancel()
+	pCache.Remove(newKindTestPolicy("policy-0", "Pod"))
+	assert.Equal(t, (<-events).PolicyName, "policy-0")
+}
+
+func Test_Subscribe_Cancel(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	events, cancel := pCache.Subscribe()
+	other, cancelOther := pCache.Subscribe()
+	defer cancelOther()
+
+	// the channel is removed from the subscribers and closed, cancelling twice has no effect
+	cancel()
+	cancel()
+	_, ok := <-events
+	assert.Assert(t, !ok)
+	assert.Equal(t, len(pCache.(*policyCache).subscribers.channels), 1)
+
+	// the events are still published to the other subscribers
+	pCache.Add(newKindTestPolicy("require-labels", "Pod"))
+	assert.Equal(t, (<-other).PolicyName, "require-labels")
+}
+
+func Test_Admission_Background_Flags(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		admission          *bool
+		background         *bool
+		expectedAdmission  int
+		expectedBackground int
+	}{
+		{admission: nil, background: nil, expectedAdmission: 1, expectedBackground: 1},
+		{admission: boolPtr(true), background: boolPtr(true), expectedAdmission: 1, expectedBackground: 1},
+		{admission: boolPtr(true), background: boolPtr(false), expectedAdmission: 1, expectedBackground: 0},
+		{admission: boolPtr(false), background: boolPtr(true), expectedAdmission: 0, expectedBackground: 1},
+		{admission: boolPtr(false), background: boolPtr(false), expectedAdmission: 0, expectedBackground: 0},
+	}
+
+	for i, test := range tests {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+
+		policy := newKindTestPolicy("compliance-scan", "Pod")
+		policy.Spec.Admission = test.admission
+		policy.Spec.Background = test.background
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+
+		assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), test.expectedAdmission, "test %d", i)
+		assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "")), test.expectedAdmission, "test %d", i)
+		assert.Equal(t, len(pCache.(*policyCache).getBackground(ValidateEnforce, "Pod", "")), test.expectedBackground, "test %d", i)
+		assert.Equal(t, len(pCache.GetBackground(ValidateEnforce, "Pod", "")), test.expectedBackground, "test %d", i)
+
+		// the flags are updated when the policy changes
+		updated := policy.DeepCopy()
+		updated.Spec.Admission = nil
+		updated.Spec.Background = nil
+		pCache.Add(updated)
+		assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 1, "test %d", i)
+		assert.Equal(t, len(pCache.(*policyCache).getBackground(ValidateEnforce, "Pod", "")), 1, "test %d", i)
+
+		pCache.Remove(updated)
+		assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 0, "test %d", i)
+		assert.Equal(t, len(pCache.(*policyCache).getBackground(ValidateEnforce, "Pod", "")), 0, "test %d", i)
+	}
+}
+
+func Test_Get_Stable_Order(t *testing.T) {
+	names := []string{"require-labels", "add-default-team", "disallow-latest-tag", "check-registry"}
+	orders := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}, {1, 3, 0, 2}}
+
+	for _, order := range orders {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+		for _, i := range order {
+			policy := newKindTestPolicy(names[i], "Pod", "Deployment")
+			assert.NilError(t, indexer.Add(policy))
+			pCache.Add(policy)
+		}
+
+		for i := 0; i < 3; i++ {
+			assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"add-default-team", "check-registry", "disallow-latest-tag", "require-labels"})
+
+			policies := pCache.GetPolicies(ValidateEnforce, "Deployment", "")
+			assert.Equal(t, len(policies), 4)
+			for j, name := range []string{"add-default-team", "check-registry", "disallow-latest-tag", "require-labels"} {
+				assert.Equal(t, policies[j].GetName(), name, "order %v", order)
+			}
+		}
+	}
+}
+
+func Test_Subresource_Kinds(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+	for _, policy := range []*kyverno.ClusterPolicy{
+		newKindTestPolicy("deny-exec", "pods/exec"),
+		newKindTestPolicy("require-labels", "Pod"),
+		newKindTestPolicy("limit-scale", "apps/v1/deployments/scale"),
+	} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	for _, kind := range []string{"pods/exec", "Pod/exec", "v1/pods/exec"} {
+		assert.DeepEqual(t, pCache.get(ValidateEnforce, kind, ""), []string{"deny-exec"})
+		policies := pCache.GetPolicies(ValidateEnforce, kind, "")
+		assert.Equal(t, len(policies), 1, kind)
+		assert.Equal(t, policies[0].GetName(), "deny-exec", kind)
+	}
+
+	// pod level policies and subresource policies are isolated
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "pods/log", ""), []string(nil))
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "deployments/scale", ""), []string{"limit-scale"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Deployment", ""), []string(nil))
+
+	pCache.Remove(newKindTestPolicy("deny-exec", "pods/exec"))
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "pods/exec", ""), []string(nil))
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"require-labels"})
+}
+
+func Test_Rollout_Percentage(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policy := newKindTestPolicy("require-labels", "Pod")
+	percentage := 30
+	policy.Spec.RolloutPercentage = &percentage
+	pCache.Add(policy)
+
+	// the policy is cached for both actions while it is rolled out
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateAudit, "Pod", ""), []string{"require-labels"})
+
+	// changing the percentage does not re-index the policy, starting or ending the rollout does
+	updated := policy.DeepCopy()
+	*updated.Spec.RolloutPercentage = 100
+	assert.Assert(t, !specChanged(policy, updated))
+	updated.Spec.RolloutPercentage = nil
+	assert.Assert(t, specChanged(policy, updated))
+
+	pCache.Remove(policy)
+	pCache.Add(updated)
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateAudit, "Pod", ""), []string(nil))
+}
+
+func Test_Get_Namespaced(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	clusterPolicy := newKindTestPolicy("require-labels", "Pod")
+	assert.NilError(t, indexer.Add(clusterPolicy))
+	pCache.Add(clusterPolicy)
+
+	for _, ns := range []string{"team-a", "team-b"} {
+		nsPolicy := kyverno.Policy(*newKindTestPolicy("require-team", "Pod"))
+		nsPolicy.SetNamespace(ns)
+		assert.NilError(t, nsIndexer.Add(&nsPolicy))
+		pCache.Add(policy2.ConvertPolicyToClusterPolicy(&nsPolicy))
+	}
+
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var names []string
+		for _, policy := range policies {
+			names = append(names, policy.GetNamespace()+"/"+policy.GetName())
+		}
+		return names
+	}
+
+	// by default cluster-wide policies are included
+	assert.DeepEqual(t, names(pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")), []string{"/require-labels", "team-a/require-team"})
+	assert.DeepEqual(t, names(pCache.GetPolicies(ValidateEnforce, "Pod", "")), []string{"/require-labels"})
+
+	// namespaced only
+	assert.DeepEqual(t, names(pCache.GetNamespaced(ValidateEnforce, "Pod", "team-a")), []string{"team-a/require-team"})
+	assert.DeepEqual(t, names(pCache.GetNamespaced(ValidateEnforce, "Pod", "team-b")), []string{"team-b/require-team"})
+	assert.DeepEqual(t, names(pCache.GetNamespaced(ValidateEnforce, "Pod", "team-c")), []string(nil))
+	assert.DeepEqual(t, names(pCache.GetNamespaced(ValidateEnforce, "Pod", "")), []string(nil))
+}
+
+func Test_GetAll(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	for _, policy := range []*kyverno.ClusterPolicy{
+		newKindTestPolicy("require-labels", "Pod", "Deployment"),
+		newKindTestPolicy("require-owner", "Namespace"),
+	} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	nsPolicy := kyverno.Policy(*newKindTestPolicy("require-team", "Pod"))
+	nsPolicy.SetNamespace("team-a")
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+	pCache.Add(policy2.ConvertPolicyToClusterPolicy(&nsPolicy))
+
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var names []string
+		for _, policy := range policies {
+			names = append(names, policy.GetNamespace()+"/"+policy.GetName())
+		}
+		return names
+	}
+
+	// policies are returned once across kinds, and for all namespaces
+	assert.DeepEqual(t, names(pCache.GetAll(ValidateEnforce)), []string{"/require-labels", "/require-owner", "team-a/require-team"})
+	assert.DeepEqual(t, names(pCache.GetAll(Mutate)), []string(nil))
+
+	// policies with spec.admission set to false are not returned
+	admissionDisabled := newKindTestPolicy("require-owner", "Namespace")
+	admissionDisabled.Spec.Admission = new(bool)
+	pCache.Remove(admissionDisabled)
+	pCache.Add(admissionDisabled)
+	assert.DeepEqual(t, names(pCache.GetAll(ValidateEnforce)), []string{"/require-labels", "team-a/require-team"})
+}
+
+func newCopyTestCache(t testing.TB) (Interface, cache.Indexer, *kyverno.ClusterPolicy, *kyverno.Policy) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	policy := newKindTestPolicy("require-labels", "Pod")
+	policy.SetResourceVersion("1")
+	assert.NilError(t, indexer.Add(policy))
+	pCache.Add(policy)
+
+	nsPolicy := kyverno.Policy(*newKindTestPolicy("require-team", "Pod"))
+	nsPolicy.SetNamespace("team-a")
+	nsPolicy.SetResourceVersion("1")
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+	pCache.Add(policy2.ConvertPolicyToClusterPolicy(&nsPolicy))
+
+	return pCache, indexer, policy, &nsPolicy
+}
+
+func Test_Get_Returns_Shared_Copies(t *testing.T) {
+	pCache, indexer, policy, nsPolicy := newCopyTestCache(t)
+
+	first := pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")
+	second := pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")
+	assert.Equal(t, len(first), 2)
+	assert.Equal(t, len(second), 2)
+
+	// the lookups share the copies, the policies of the informer cache are not returned
+	for i := range first {
+		assert.Assert(t, &first[i].Spec.Rules[0] == &second[i].Spec.Rules[0], first[i].GetName())
+		assert.Assert(t, &first[i].Spec.Rules[0] != &policy.Spec.Rules[0], first[i].GetName())
+		assert.Assert(t, &first[i].Spec.Rules[0] != &nsPolicy.Spec.Rules[0], first[i].GetName())
+	}
+
+	// a new version is copied, the unsupported rules are removed from the copy
+	updated := policy.DeepCopy()
+	updated.SetResourceVersion("2")
+	updated.Spec.Rules = append(updated.Spec.Rules, kyverno.Rule{Name: "check-images", MatchResources: updated.Spec.Rules[0].MatchResources})
+	updated.Status.UnsupportedRules = []string{"check-images"}
+	assert.NilError(t, indexer.Update(updated))
+	pCache.Update(policy, updated)
+
+	policies := pCache.GetPolicies(ValidateEnforce, "Pod", "")
+	assert.Equal(t, len(policies), 1)
+	assert.Assert(t, &policies[0].Spec.Rules[0] != &first[0].Spec.Rules[0])
+	assert.Equal(t, len(policies[0].Spec.Rules), 1)
+	assert.Equal(t, len(updated.Spec.Rules), 2)
+
+	// the copies of the removed policies are dropped
+	pCache.Remove(updated)
+	assert.Equal(t, len(pCache.(*policyCache).shared.policies), 1)
+}
+
+// Test_Get_Shared_Copies_Concurrently is meant to be run with -race, the callers read the shared policies
+// while the policies are updated in the informer cache
+func Test_Get_Shared_Copies_Concurrently(t *testing.T) {
+	pCache, indexer, policy, _ := newCopyTestCache(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				policies := pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")
+				if len(policies) != 2 {
+					t.Errorf("expected 2 policies, found %v", len(policies))
+					return
+				}
+				for _, p := range policies {
+					if p.Spec.Rules[0].Validation.Message != "labels are required" {
+						t.Errorf("policy %s is modified: %s", p.GetName(), p.Spec.Rules[0].Validation.Message)
+					}
+				}
+			}
+		}()
+	}
+
+	old := policy
+	for i := 2; i < 20; i++ {
+		updated := old.DeepCopy()
+		updated.SetResourceVersion(fmt.Sprint(i))
+		assert.NilError(t, indexer.Update(updated))
+		pCache.Update(old, updated)
+		old = updated
+	}
+	wg.Wait()
+}
+
+// Test_Get_Shared_Copies_Unmodified applies the shared policies to a resource like the admission requests,
+// the callers must not modify the policies they share
+func Test_Get_Shared_Copies_Unmodified(t *testing.T) {
+	policyRaw := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "pod-defaults", "resourceVersion": "1"},
+		"spec": {
+			"validationFailureAction": "enforce",
+			"rules": [
+				{
+					"name": "add-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"mutate": {"patchStrategicMerge": {"metadata": {"labels": {"+(team)": "{{request.object.metadata.namespace}}"}}}}
+				},
+				{
+					"name": "check-containers",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "the image of {{element.name}} must be pinned",
+						"foreach": [{"list": "request.object.spec.containers", "pattern": {"image": "!*:latest"}}]
+					}
+				},
+				{
+					"name": "check-owner",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"validate": {
+						"message": "the owner of {{request.object.metadata.name}} is required",
+						"deny": {"conditions": [{"key": "{{request.object.metadata.labels.owner || ''}}", "operator": "Equals", "value": ""}]}
+					}
+				}
+			]
+		}
+	}`)
+	resourceRaw := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "namespace": "prod"}, "spec": {"containers": [{"name": "web", "image": "nginx:latest"}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, indexer.Add(&policy))
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+	pCache.Add(&policy)
+
+	expected := pCache.GetPolicies(Mutate, "Pod", "prod")[0].DeepCopy()
+	for _, pkey := range []PolicyType{Mutate, ValidateEnforce} {
+		for _, shared := range pCache.GetPolicies(pkey, "Pod", "prod") {
+			resource, err := utils.ConvertToUnstructured(resourceRaw)
+			assert.NilError(t, err)
+			ctx := context.NewContext()
+			assert.NilError(t, ctx.AddResource(resourceRaw))
+
+			policyContext := &engine.PolicyContext{Policy: *shared, NewResource: *resource, JSONContext: ctx}
+			if pkey == Mutate {
+				engine.Mutate(policyContext)
+			} else {
+				engine.Validate(policyContext)
+			}
+		}
+	}
+
+	policies := pCache.GetPolicies(ValidateEnforce, "Pod", "prod")
+	assert.Equal(t, len(policies), 1)
+	assert.DeepEqual(t, policies[0], expected)
+}
+
+func BenchmarkGetPolicies(b *testing.B) {
+	pCache, _, _, _ := newCopyTestCache(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")
+	}
+}
+
+// nilLister and nilNsLister return nil policies without an error
+type nilLister struct {
+	dummyLister
+}
+
+func (nilLister) Get(name string) (*kyverno.ClusterPolicy, error) {
+	return nil, nil
+}
+
+func (nilLister) List(selector labels.Selector) (ret []*kyverno.ClusterPolicy, err error) {
+	return []*kyverno.ClusterPolicy{nil}, nil
+}
+
+type nilNsLister struct {
+	dummyNsLister
+}
+
+func (nilNsLister) Policies(name string) lv1.PolicyNamespaceLister {
+	return nilNsLister{}
+}
+
+func (nilNsLister) Get(name string) (*kyverno.Policy, error) {
+	return nil, nil
+}
+
+func (nilNsLister) List(selector labels.Selector) (ret []*kyverno.Policy, err error) {
+	return []*kyverno.Policy{nil}, nil
+}
+
+func Test_Get_NilListerResults(t *testing.T) {
+	pCache := newPolicyCache(log.Log, nilLister{}, nilNsLister{}).(*policyCache)
+	pCache.promConfig = metrics.NewPromConfig()
+
+	policy := newPolicy(t)
+	nsPolicy := newNsPolicy(t)
+	pCache.Add(policy)
+	pCache.Add(nsPolicy)
+
+	// the cached policies are skipped, and counted
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", nsPolicy.GetNamespace())), 0)
+
+	misses := func(policyType metrics.PolicyType, namespace, name string) float64 {
+		return testutil.ToFloat64(pCache.promConfig.Metrics.PolicyCacheMisses.With(prom.Labels{
+			"policy_type":      string(policyType),
+			"policy_namespace": namespace,
+			"policy_name":      name,
+			"reason":           "nil",
+		}))
+	}
+	assert.Equal(t, misses(metrics.Cluster, "", policy.GetName()), float64(1))
+	assert.Equal(t, misses(metrics.Namespaced, nsPolicy.GetNamespace(), nsPolicy.GetName()), float64(1))
+
+	// the nil policies of the listers are not added
+	assert.NilError(t, pCache.Warmup())
+	assert.DeepEqual(t, pCache.ListAll(), []string{policy.GetName(), nsPolicy.GetNamespace() + "/" + nsPolicy.GetName()})
+}
+
+func Test_Get_NamespacedPolicy_Kind(t *testing.T) {
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsPolicy := kyverno.Policy(*newNsPolicy(t))
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+
+	pCache := newPolicyCache(log.Log, dummyLister{}, lv1.NewPolicyLister(nsIndexer))
+	pCache.Add(newNsPolicy(t))
+
+	policies := pCache.GetPolicies(ValidateEnforce, "Pod", nsPolicy.GetNamespace())
+	assert.Equal(t, len(policies), 1)
+	assert.Equal(t, policies[0].Kind, "Policy")
+	assert.Equal(t, policies[0].GetNamespace(), nsPolicy.GetNamespace())
+}
+
+func Test_Delete_Tombstone(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	c := &Controller{Cache: pCache, log: log.Log}
+
+	policy := newPolicy(t)
+	nsPolicy := kyverno.Policy(*newNsPolicy(t))
+	c.addPolicy(policy)
+	c.addNsPolicy(&nsPolicy)
+	assert.Equal(t, len(pCache.ListAll()), 2)
+
+	c.deletePolicy(cache.DeletedFinalStateUnknown{Key: policy.GetName(), Obj: policy})
+	c.deleteNsPolicy(cache.DeletedFinalStateUnknown{Key: nsPolicy.GetNamespace() + "/" + nsPolicy.GetName(), Obj: &nsPolicy})
+	assert.Equal(t, len(pCache.ListAll()), 0)
+
+	// the partial objects are skipped
+	c.deletePolicy(cache.DeletedFinalStateUnknown{Key: "unknown"})
+	c.deleteNsPolicy(nil)
+}
+
+func Test_Update(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	events, cancel := pCache.Subscribe()
+	defer cancel()
+
+	old := newKindTestPolicy("require-labels", "Pod", "Service")
+	pCache.Add(old)
+	<-events
+
+	// the policy is re-indexed for the kinds of the new version
+	cur := newKindTestPolicy("require-labels", "Pod", "apps/v1/Deployment")
+	cur.Spec.ValidationFailureAction = "audit"
+	pCache.Update(old, cur)
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 0)
+	assert.Equal(t, len(pCache.get(ValidateAudit, "Pod", "")), 1)
+	assert.Equal(t, len(pCache.get(ValidateAudit, "Deployment", "")), 1)
+	assert.Equal(t, len(pCache.get(ValidateAudit, "Service", "")), 0)
+	assert.DeepEqual(t, pCache.ListAll(), []string{"require-labels"})
+
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Removed, PolicyName: "require-labels", Kinds: []string{"pod", "service"}})
+	assert.DeepEqual(t, <-events, CacheEvent{Type: Added, PolicyName: "require-labels", Kinds: []string{"deployment", "pod"}})
+}
+
+func Test_Update_Concurrent_Get(t *testing.T) {
+	pCache := newPolicyCache(log.Log, dummyLister{}, dummyNsLister{})
+	policies := []*kyverno.ClusterPolicy{
+		newKindTestPolicy("require-labels", "Pod"),
+		newKindTestPolicy("require-labels", "Pod", "Service"),
+	}
+	pCache.Add(policies[0])
+
+	// the policy matches the pods in both versions, the lookups never miss it while it is updated
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	misses := make(chan int, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var missed int
+		for {
+			select {
+			case <-done:
+				misses <- missed
+				return
+			default:
+			}
+
+			if len(pCache.get(ValidateEnforce, "Pod", "")) != 1 {
+				missed++
+			}
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		pCache.Update(policies[i%2], policies[(i+1)%2])
+	}
+
+	close(done)
+	wg.Wait()
+	assert.Equal(t, <-misses, 0)
+	assert.Equal(t, len(pCache.get(ValidateEnforce, "Pod", "")), 1)
+}
+
+func Test_Wildcard_Kinds(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+	for _, policy := range []*kyverno.ClusterPolicy{
+		newKindTestPolicy("all-kinds", "*"),
+		newKindTestPolicy("apps-kinds", "apps/*"),
+		newKindTestPolicy("batch-kinds", "batch/v1/*"),
+		newKindTestPolicy("require-labels", "Pod", "apps/v1/Deployment"),
+		newKindTestPolicy("deny-exec", "pods/exec"),
+	} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	// the group wildcards are returned for the kinds without a group, the engine matches the group
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Pod", ""), []string{"all-kinds", "apps-kinds", "batch-kinds", "require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "v1/Pod", ""), []string{"all-kinds", "require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "apps/v1/Deployment", ""), []string{"all-kinds", "apps-kinds", "require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "batch/v1/Job", ""), []string{"all-kinds", "batch-kinds"})
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "apps/v1/StatefulSet", "")), 2)
+
+	// the subresources are isolated from the wildcards
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "pods/exec", ""), []string{"deny-exec"})
+
+	// a policy matching a kind and a wildcard is returned once
+	pCache.Add(newKindTestPolicy("apps-kinds", "apps/*", "Deployment"))
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "apps/v1/Deployment", ""), []string{"all-kinds", "apps-kinds", "require-labels"})
+
+	pCache.Remove(newKindTestPolicy("all-kinds", "*"))
+	pCache.Remove(newKindTestPolicy("apps-kinds", "apps/*", "Deployment"))
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "apps/v1/Deployment", ""), []string{"require-labels"})
+	assert.DeepEqual(t, pCache.get(ValidateEnforce, "Service", ""), []string{"batch-kinds"})
+	assert.DeepEqual(t, pCache.ListAll(), []string{"batch-kinds", "deny-exec", "require-labels"})
+}
+
+func Test_Cache_Kind(t *testing.T) {
+	tests := map[string]string{
+		"*":                  "*",
+		"*/*":                "*",
+		"v1/*":               "*",
+		"apps/*":             "apps/*",
+		"apps/v1/*":          "apps/*",
+		"Apps/*/*":           "apps/*",
+		"apps/v1/Deployment": "deployment",
+		"pods/exec":          "pod/exec",
+	}
+
+	for kind, expected := range tests {
+		assert.Equal(t, cacheKind(kind), expected, kind)
+	}
+}
+
+func Test_Get_Policies_For_Namespace(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+
+	allNamespaces := newKindTestPolicy("all-namespaces", "Pod", "Namespace")
+	prodNamespaces := newKindTestPolicy("prod-namespaces", "Pod", "Namespace")
+	prodNamespaces.Spec.Rules[0].MatchResources.Namespaces = []string{"prod-*", "staging"}
+	labeledNamespaces := newKindTestPolicy("labeled-namespaces", "Pod")
+	labeledNamespaces.Spec.Rules[0].MatchResources.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "*"}}
+	// the rules of a policy are merged, the policy matches the namespaces of both rules
+	mixedNamespaces := newKindTestPolicy("mixed-namespaces", "Pod")
+	mixedNamespaces.Spec.Rules[0].MatchResources.Namespaces = []string{"dev"}
+	mixedNamespaces.Spec.Rules = append(mixedNamespaces.Spec.Rules, *prodNamespaces.Spec.Rules[0].DeepCopy())
+
+	for _, policy := range []*kyverno.ClusterPolicy{allNamespaces, prodNamespaces, labeledNamespaces, mixedNamespaces} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var result []string
+		for _, policy := range policies {
+			result = append(result, policy.GetName())
+		}
+		return result
+	}
+
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "prod-eu", map[string]string{})),
+		[]string{"all-namespaces", "mixed-namespaces", "prod-namespaces"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "dev", map[string]string{"team": "web"})),
+		[]string{"all-namespaces", "labeled-namespaces", "mixed-namespaces"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "test", map[string]string{"env": "test"})),
+		[]string{"all-namespaces"})
+
+	// the namespace selectors are not evaluated without the namespace labels
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "test", nil)),
+		[]string{"all-namespaces", "labeled-namespaces"})
+
+	// the rules of the Namespace kind, including the rule mixed-namespaces copies from prod-namespaces,
+	// match the name of the namespace, they are not filtered
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Namespace", "test", map[string]string{})),
+		[]string{"all-namespaces", "mixed-namespaces", "prod-namespaces"})
+
+	// the namespaces are re-indexed on update
+	updated := prodNamespaces.DeepCopy()
+	updated.Spec.Rules[0].MatchResources.Namespaces = []string{"test"}
+	assert.NilError(t, indexer.Update(updated))
+	pCache.Update(prodNamespaces, updated)
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "test", map[string]string{})),
+		[]string{"all-namespaces", "prod-namespaces"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForNamespace(ValidateEnforce, "Pod", "prod-eu", map[string]string{})),
+		[]string{"all-namespaces", "mixed-namespaces"})
+
+	// the lookups without a namespace filter are unchanged
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "test")), 4)
+}
+
+func Test_Cache_Metrics(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{}).(*policyCache)
+	pCache.promConfig = metrics.NewPromConfig()
+
+	size := func(pkey PolicyType) float64 {
+		return testutil.ToFloat64(pCache.promConfig.Metrics.PolicyCacheSize.With(prom.Labels{"policy_type": pkey.String()}))
+	}
+	kindSize := func(pkey PolicyType, kind string) float64 {
+		return testutil.ToFloat64(pCache.promConfig.Metrics.PolicyCacheKindSize.With(prom.Labels{"policy_type": pkey.String(), "kind": kind}))
+	}
+	lookups := func(pkey PolicyType, result string) float64 {
+		return testutil.ToFloat64(pCache.promConfig.Metrics.PolicyCacheLookups.With(prom.Labels{"policy_type": pkey.String(), "result": result}))
+	}
+
+	requireLabels := newKindTestPolicy("require-labels", "Pod", "Service")
+	for _, policy := range []*kyverno.ClusterPolicy{requireLabels, newKindTestPolicy("check-registry", "Pod")} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	assert.Equal(t, size(ValidateEnforce), float64(2))
+	assert.Equal(t, size(Mutate), float64(0))
+	assert.Equal(t, kindSize(ValidateEnforce, "pod"), float64(2))
+	assert.Equal(t, kindSize(ValidateEnforce, "service"), float64(1))
+
+	pCache.Remove(requireLabels)
+	assert.Equal(t, size(ValidateEnforce), float64(1))
+	assert.Equal(t, kindSize(ValidateEnforce, "service"), float64(0))
+
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "default")), 1)
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Service", "")), 0)
+	assert.Equal(t, len(pCache.GetBackground(ValidateEnforce, "Pod", "")), 1)
+	assert.Equal(t, lookups(ValidateEnforce, "hit"), float64(2))
+	assert.Equal(t, lookups(ValidateEnforce, "miss"), float64(1))
+
+	families, err := pCache.promConfig.MetricsRegistry.Gather()
+	assert.NilError(t, err)
+	var observed uint64
+	for _, family := range families {
+		if family.GetName() == "kyverno_policy_cache_lookup_latency_milliseconds" {
+			observed = family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, observed, uint64(3))
+}
+
+func Test_Get_Policies_For_Request(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), dummyNsLister{})
+
+	allOperations := newKindTestPolicy("all-operations", "Pod")
+	createOnly := newKindTestPolicy("create-only", "Pod")
+	createOnly.Spec.Rules[0].MatchResources.Operations = []string{"CREATE"}
+	denyExec := newKindTestPolicy("deny-exec", "pods/exec")
+	denyExec.Spec.Rules[0].MatchResources.Operations = []string{"CONNECT"}
+	statusUpdates := newKindTestPolicy("status-updates", "services/status")
+
+	for _, policy := range []*kyverno.ClusterPolicy{allOperations, createOnly, denyExec, statusUpdates} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var result []string
+		for _, policy := range policies {
+			result = append(result, policy.GetName())
+		}
+		return result
+	}
+
+	request := func(kind, subResource, operation string) Request {
+		return Request{Kind: kind, SubResource: subResource, Namespace: "test", NamespaceLabels: map[string]string{}, Operation: operation}
+	}
+
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("Pod", "", "CREATE"))),
+		[]string{"all-operations", "create-only"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("Pod", "", "UPDATE"))),
+		[]string{"all-operations"})
+
+	// the policies of the subresource are returned with the policies of the requested kind
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("PodExecOptions", "pods/exec", "CONNECT"))),
+		[]string{"deny-exec"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("Pod", "pods/ephemeralcontainers", "UPDATE"))),
+		[]string{"all-operations"})
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("Service", "services/status", "UPDATE"))),
+		[]string{"status-updates"})
+
+	// the operations are re-indexed on update
+	updated := createOnly.DeepCopy()
+	updated.Spec.Rules[0].MatchResources.Operations = []string{"CREATE", "UPDATE"}
+	assert.NilError(t, indexer.Update(updated))
+	pCache.Update(createOnly, updated)
+	assert.DeepEqual(t, names(pCache.GetPoliciesForRequest(ValidateEnforce, request("Pod", "", "UPDATE"))),
+		[]string{"all-operations", "create-only"})
+
+	// all the operations are returned without an operation
+	assert.Equal(t, len(pCache.GetPoliciesForRequest(ValidateEnforce, request("Pod", "", ""))), 2)
+}
+
+func Test_Get_Policies_Priority(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	defaults := newKindTestPolicy("set-defaults", "Pod")
+	defaults.Spec.Priority = -10
+	overrides := newKindTestPolicy("add-labels", "Pod")
+	overrides.Spec.Priority = 10
+	for _, policy := range []*kyverno.ClusterPolicy{newKindTestPolicy("require-labels", "Pod"), defaults, overrides} {
+		assert.NilError(t, indexer.Add(policy))
+		pCache.Add(policy)
+	}
+
+	nsPolicy := kyverno.Policy(*newKindTestPolicy("add-team", "Pod"))
+	nsPolicy.SetNamespace("team-a")
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+	pCache.Add(policy2.ConvertPolicyToClusterPolicy(&nsPolicy))
+
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var names []string
+		for _, policy := range policies {
+			names = append(names, policy.GetNamespace()+"/"+policy.GetName())
+		}
+		return names
+	}
+
+	// the policies are sorted by priority, then by name, the namespaced policies are not applied last
+	assert.DeepEqual(t, names(pCache.GetPolicies(ValidateEnforce, "Pod", "team-a")),
+		[]string{"/set-defaults", "/require-labels", "team-a/add-team", "/add-labels"})
+	assert.DeepEqual(t, names(pCache.GetPolicies(ValidateEnforce, "Pod", "")),
+		[]string{"/set-defaults", "/require-labels", "/add-labels"})
+}
+
+func Test_Get_Policy_Interfaces(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pCache := newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer))
+
+	clusterPolicy := newKindTestPolicy("require-labels", "Pod")
+	assert.NilError(t, indexer.Add(clusterPolicy))
+	pCache.Add(clusterPolicy)
+
+	// the lister returns the policies without TypeMeta
+	nsPolicy := kyverno.Policy(*newKindTestPolicy("require-team", "Pod"))
+	nsPolicy.TypeMeta = metav1.TypeMeta{}
+	nsPolicy.SetNamespace("team-a")
+	nsPolicy.SetLabels(map[string]string{"owner": "team-a"})
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+	pCache.Add(&nsPolicy)
+
+	policies := pCache.GetPolicyInterfaces(ValidateEnforce, "Pod", "team-a")
+	assert.Equal(t, len(policies), 2)
+
+	_, ok := policies[0].(*kyverno.ClusterPolicy)
+	assert.Assert(t, ok)
+	assert.Equal(t, policies[0].GetKind(), "ClusterPolicy")
+	assert.Assert(t, !policies[0].IsNamespaced())
+
+	namespaced, ok := policies[1].(*kyverno.Policy)
+	assert.Assert(t, ok)
+	assert.Equal(t, namespaced.GetKind(), "Policy")
+	assert.Assert(t, namespaced.IsNamespaced())
+	assert.DeepEqual(t, namespaced.GetLabels(), map[string]string{"owner": "team-a"})
+
+	// the returned policies are copies of the lister objects
+	namespaced.GetSpec().Rules = nil
+	assert.Equal(t, len(nsPolicy.Spec.Rules), 1)
+
+	request := Request{Kind: "Pod", Namespace: "team-a", Operation: "CREATE"}
+	assert.Equal(t, len(pCache.GetPolicyInterfacesForRequest(ValidateEnforce, request)), 2)
+
+	// the ClusterPolicy variants return the same policies
+	clusterPolicies := pCache.GetPoliciesForRequest(ValidateEnforce, request)
+	assert.Equal(t, len(clusterPolicies), 2)
+	assert.Equal(t, clusterPolicies[1].GetKind(), "Policy")
+	assert.Equal(t, clusterPolicies[1].Kind, "Policy")
+}
+
+func Test_Update_NsPolicy_Suspended(t *testing.T) {
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pCache := newPolicyCache(log.Log, dummyLister{}, lv1.NewPolicyLister(nsIndexer))
+	c := &Controller{Cache: pCache, log: log.Log}
+
+	nsPolicy := kyverno.Policy(*newNsPolicy(t))
+	assert.NilError(t, nsIndexer.Add(&nsPolicy))
+	c.addNsPolicy(&nsPolicy)
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "test")), 1)
+
+	// the namespaced policies are re-indexed like the cluster policies when they are suspended
+	suspended := nsPolicy.DeepCopy()
+	suspended.Status.Conditions = []metav1.Condition{
+		{Type: kyverno.PolicyConditionSuspended, Status: metav1.ConditionTrue, Reason: "PolicySetDisabled"},
+	}
+	assert.NilError(t, nsIndexer.Update(suspended))
+	c.updateNsPolicy(&nsPolicy, suspended)
+	assert.Equal(t, len(pCache.GetPolicies(ValidateEnforce, "Pod", "test")), 0)
+}
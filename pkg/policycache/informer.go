@@ -1,11 +1,14 @@
 package policycache
 
 import (
+	"errors"
 	"reflect"
+	"sync/atomic"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/metrics"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -19,16 +22,23 @@ type Controller struct {
 	nspSynched cache.InformerSynced
 	Cache      Interface
 	log        logr.Logger
+
+	// warmedUp is set to 1 once the cache is filled by Warmup
+	warmedUp int32
 }
 
 // NewPolicyCacheController create a new PolicyController
 func NewPolicyCacheController(
 	pInformer kyvernoinformer.ClusterPolicyInformer,
 	nspInformer kyvernoinformer.PolicyInformer,
+	promConfig *metrics.PromConfig,
 	log logr.Logger) *Controller {
 
+	pCache := newPolicyCache(log, pInformer.Lister(), nspInformer.Lister()).(*policyCache)
+	pCache.promConfig = promConfig
+
 	pc := Controller{
-		Cache: newPolicyCache(log, pInformer.Lister(), nspInformer.Lister()),
+		Cache: pCache,
 		log:   log,
 	}
 
@@ -52,55 +62,96 @@ func NewPolicyCacheController(
 	return &pc
 }
 
-// convertPolicyToClusterPolicy - convert Policy to ClusterPolicy
-// This will retain the kind of Policy and convert type to ClusterPolicy
-func convertPolicyToClusterPolicy(nsPolicies *kyverno.Policy) *kyverno.ClusterPolicy {
-	cpol := kyverno.ClusterPolicy(*nsPolicies)
-	return &cpol
-}
-
 func (c *Controller) addPolicy(obj interface{}) {
 	p := obj.(*kyverno.ClusterPolicy)
 	c.Cache.Add(p)
 }
 
 func (c *Controller) updatePolicy(old, cur interface{}) {
-	pOld := old.(*kyverno.ClusterPolicy)
-	pNew := cur.(*kyverno.ClusterPolicy)
+	c.update(old.(*kyverno.ClusterPolicy), cur.(*kyverno.ClusterPolicy))
+}
 
-	if reflect.DeepEqual(pOld.Spec, pNew.Spec) {
+// update re-indexes a cluster policy or a namespaced policy if its spec, its suspension or its effective
+// validation failure action changed
+func (c *Controller) update(old, cur kyverno.PolicyInterface) {
+	pOld, pNew := old.AsClusterPolicy(), cur.AsClusterPolicy()
+	if !specChanged(pOld, pNew) &&
+		pOld.IsSuspended() == pNew.IsSuspended() &&
+		pOld.GetValidationFailureAction() == pNew.GetValidationFailureAction() {
 		return
 	}
-	c.Cache.Remove(pOld)
-	c.Cache.Add(pNew)
+	c.Cache.Update(old, cur)
 }
 
 func (c *Controller) deletePolicy(obj interface{}) {
-	p := obj.(*kyverno.ClusterPolicy)
+	p, ok := deletedObject(obj).(*kyverno.ClusterPolicy)
+	if !ok || p == nil {
+		c.log.Info("warning: skipping deleted cluster policy of an unexpected type", "type", reflect.TypeOf(obj))
+		return
+	}
 	c.Cache.Remove(p)
 }
 
 // addNsPolicy - Add Policy to cache
 func (c *Controller) addNsPolicy(obj interface{}) {
 	p := obj.(*kyverno.Policy)
-	c.Cache.Add(convertPolicyToClusterPolicy(p))
+	c.Cache.Add(p)
 }
 
 // updateNsPolicy - Update Policy of cache
 func (c *Controller) updateNsPolicy(old, cur interface{}) {
-	npOld := old.(*kyverno.Policy)
-	npNew := cur.(*kyverno.Policy)
-	if reflect.DeepEqual(npOld.Spec, npNew.Spec) {
-		return
+	c.update(old.(*kyverno.Policy), cur.(*kyverno.Policy))
+}
+
+// specChanged checks if the policy spec changed in a way that requires the policy to be re-indexed.
+// The rollout percentage is read from the lister per request, only starting or ending a rollout
+// changes the index.
+func specChanged(old, cur *kyverno.ClusterPolicy) bool {
+	if old.IsRollingOut() != cur.IsRollingOut() {
+		return true
 	}
-	c.Cache.Remove(convertPolicyToClusterPolicy(npOld))
-	c.Cache.Add(convertPolicyToClusterPolicy(npNew))
+
+	oldSpec, curSpec := old.Spec.DeepCopy(), cur.Spec.DeepCopy()
+	oldSpec.RolloutPercentage, curSpec.RolloutPercentage = nil, nil
+	return !reflect.DeepEqual(oldSpec, curSpec)
 }
 
 // deleteNsPolicy - Delete Policy from cache
 func (c *Controller) deleteNsPolicy(obj interface{}) {
-	p := obj.(*kyverno.Policy)
-	c.Cache.Remove(convertPolicyToClusterPolicy(p))
+	p, ok := deletedObject(obj).(*kyverno.Policy)
+	if !ok || p == nil {
+		c.log.Info("warning: skipping deleted policy of an unexpected type", "type", reflect.TypeOf(obj))
+		return
+	}
+	c.Cache.Remove(p)
+}
+
+// deletedObject returns the last known state of the object of a delete event, the informer passes
+// a tombstone when the deletion was missed
+func deletedObject(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// Warmup fills the cache once the policy informers are synced
+func (c *Controller) Warmup(stopCh <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stopCh, c.pSynched, c.nspSynched) {
+		return errors.New("failed to sync informer cache")
+	}
+
+	if err := c.Cache.Warmup(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&c.warmedUp, 1)
+	return nil
+}
+
+// HasWarmedUp returns true once the cache is filled by Warmup
+func (c *Controller) HasWarmedUp() bool {
+	return atomic.LoadInt32(&c.warmedUp) == 1
 }
 
 // Run waits until policy informer to be synced
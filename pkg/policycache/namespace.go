@@ -0,0 +1,79 @@
+package policycache
+
+import (
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/wildcards"
+	"github.com/minio/pkg/wildcard"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// namespaceFilter stores the namespaces matched by the rules of a policy for a kind, a namespace is matched
+// if it matches one of the namespace names or one of the namespace selectors of the rules. The exclude blocks
+// are not indexed, and a rule matching both namespace names and a namespace selector is indexed by its names,
+// so the filter may match more namespaces than the rules do, the engine matches the rules.
+type namespaceFilter struct {
+	// all is true if one of the rules matches all namespaces
+	all bool
+
+	// namespaces are the namespace names of the rules, they support wildcard characters
+	namespaces []string
+
+	// selectors are the namespace selectors of the rules
+	selectors []*metav1.LabelSelector
+}
+
+// lookupQuery is the namespace of a resource and its labels, the namespace selectors are not
+// evaluated if the labels are nil. It is also the operation and the subresource of the admission
+// request, the operations are not filtered if the operation is empty.
+type lookupQuery struct {
+	namespace string
+	labels    map[string]string
+	operation string
+
+	// subResource is the resource and the subresource of the request, e.g. "pods/exec", the policies
+	// of the subresource are returned with the policies of the kind
+	subResource string
+}
+
+// add adds the namespaces matched by the rule
+func (f *namespaceFilter) add(rule kyverno.Rule) {
+	resources := rule.MatchResources.ResourceDescription
+	switch {
+	case len(resources.Namespaces) > 0:
+		f.namespaces = append(f.namespaces, resources.Namespaces...)
+	case resources.NamespaceSelector != nil:
+		f.selectors = append(f.selectors, resources.NamespaceSelector.DeepCopy())
+	default:
+		f.all = true
+	}
+}
+
+// matches returns true if the rules may match the resources of the namespace
+func (f *namespaceFilter) matches(query *lookupQuery) bool {
+	if f == nil || f.all {
+		return true
+	}
+
+	for _, namespace := range f.namespaces {
+		if wildcard.Match(namespace, query.namespace) {
+			return true
+		}
+	}
+
+	for _, selector := range f.selectors {
+		if query.labels == nil {
+			return true
+		}
+
+		// the wildcards are replaced in a copy, the selector is shared by the lookups
+		labelSelector := selector.DeepCopy()
+		wildcards.ReplaceInSelector(labelSelector, query.labels)
+		s, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil || s.Matches(labels.Set(query.labels)) {
+			return true
+		}
+	}
+
+	return false
+}
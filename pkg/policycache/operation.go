@@ -0,0 +1,42 @@
+package policycache
+
+import (
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+// operationFilter stores the admission operations matched by the rules of a policy for a kind, the
+// exclude blocks are not indexed, the engine matches the rules
+type operationFilter struct {
+	// all is true if one of the rules matches all operations
+	all bool
+
+	// operations are the operations of the rules, e.g. CREATE
+	operations map[string]bool
+}
+
+// add adds the operations matched by the rule
+func (f *operationFilter) add(rule kyverno.Rule) {
+	operations := rule.MatchResources.Operations
+	if len(operations) == 0 {
+		f.all = true
+		return
+	}
+
+	if f.operations == nil {
+		f.operations = make(map[string]bool, len(operations))
+	}
+
+	for _, operation := range operations {
+		f.operations[operation] = true
+	}
+}
+
+// matches returns true if the rules may match the requests of the operation, all operations are
+// matched if the operation is empty
+func (f *operationFilter) matches(operation string) bool {
+	if f == nil || f.all || operation == "" {
+		return true
+	}
+
+	return f.operations[operation]
+}
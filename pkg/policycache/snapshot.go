@@ -0,0 +1,273 @@
+package policycache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/metadata"
+)
+
+// snapshotVersion is the version of the snapshot format, snapshots of other versions are not restored
+const snapshotVersion = 1
+
+// Snapshot is the state of the policy cache. It is saved on shutdown and restored on startup,
+// so that admission requests are served before the policy informers are synced.
+type Snapshot struct {
+	Version int `json:"version"`
+
+	// Index maps the normalized kinds to the names of the policies by policy type
+	Index map[string]map[PolicyType][]string `json:"index"`
+
+	// Suspended, AdmissionDisabled and BackgroundDisabled are the names of the policies skipped by get
+	Suspended          []string `json:"suspended,omitempty"`
+	AdmissionDisabled  []string `json:"admissionDisabled,omitempty"`
+	BackgroundDisabled []string `json:"backgroundDisabled,omitempty"`
+
+	// Policies are the cached policies by name, namespaced policies are named <namespace>/<name>
+	Policies map[string]*kyverno.ClusterPolicy `json:"policies"`
+
+	// WebhookRules are the rules of the resource webhooks derived from the cached policies, by kind
+	// of webhook configuration, suffixed with "/Fail" for the webhooks whose failure policy is Fail
+	WebhookRules map[string][]admregapi.RuleWithOperations `json:"webhookRules,omitempty"`
+}
+
+// SaveSnapshot writes the snapshot to the file, the file is replaced atomically
+func SaveSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to serialize policy cache snapshot: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot reads the snapshot from the file
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse policy cache snapshot: %v", err)
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported policy cache snapshot version %d", snapshot.Version)
+	}
+
+	return &snapshot, nil
+}
+
+// ListResourceVersions returns the resourceVersions of all policies by cached policy name.
+// Only the metadata of the policies is listed, which is cheaper than syncing the informers.
+func ListResourceVersions(client metadata.Interface) (map[string]string, error) {
+	resourceVersions := make(map[string]string)
+	for _, resource := range []string{"clusterpolicies", "policies"} {
+		list, err := client.Resource(kyverno.SchemeGroupVersion.WithResource(resource)).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", resource, err)
+		}
+
+		for _, item := range list.Items {
+			name := item.GetName()
+			if item.GetNamespace() != "" {
+				name = item.GetNamespace() + "/" + name
+			}
+			resourceVersions[name] = item.GetResourceVersion()
+		}
+	}
+
+	return resourceVersions, nil
+}
+
+// Snapshot returns the state of the cache
+func (pc *policyCache) Snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		Version:  snapshotVersion,
+		Policies: make(map[string]*kyverno.ClusterPolicy),
+	}
+
+	for _, name := range pc.ListAll() {
+		if policy := pc.getPolicy(name); policy != nil {
+			snapshot.Policies[name] = policy.AsClusterPolicy()
+		}
+	}
+
+	pc.pMap.RLock()
+	defer pc.pMap.RUnlock()
+
+	// the index only references the policies of the snapshot
+	snapshot.Index = make(map[string]map[PolicyType][]string)
+	for kind, dataMap := range pc.kindDataMap {
+		for pkey, names := range dataMap {
+			for _, name := range names {
+				if snapshot.Policies[name] == nil {
+					continue
+				}
+
+				if snapshot.Index[kind] == nil {
+					snapshot.Index[kind] = make(map[PolicyType][]string)
+				}
+				snapshot.Index[kind][pkey] = append(snapshot.Index[kind][pkey], name)
+			}
+		}
+	}
+
+	snapshot.Suspended = snapshotNames(pc.suspendedMap, snapshot.Policies)
+	snapshot.AdmissionDisabled = snapshotNames(pc.admissionDisabledMap, snapshot.Policies)
+	snapshot.BackgroundDisabled = snapshotNames(pc.backgroundDisabledMap, snapshot.Policies)
+	return snapshot
+}
+
+func snapshotNames(names map[string]bool, policies map[string]*kyverno.ClusterPolicy) []string {
+	var result []string
+	for name := range names {
+		if policies[name] != nil {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Restore restores the policies of the snapshot that are not changed since the snapshot was saved, i.e.
+// whose resourceVersion is the current one. It must be called before the policies are added to the cache.
+// It returns the number of restored policies, and true if the snapshot is complete, i.e. all the
+// policies are restored and no policy is created since the snapshot was saved.
+func (pc *policyCache) Restore(snapshot *Snapshot, resourceVersions map[string]string) (int, bool) {
+	verified := make(map[string]*kyverno.ClusterPolicy)
+	for name, policy := range snapshot.Policies {
+		if resourceVersion, ok := resourceVersions[name]; !ok || resourceVersion != policy.GetResourceVersion() {
+			pc.Logger.V(3).Info("policy is changed since the snapshot was saved, skipping", "name", name)
+			continue
+		}
+		verified[name] = policy
+	}
+
+	complete := len(verified) == len(snapshot.Policies) && len(verified) == len(resourceVersions)
+	if complete {
+		pc.pMap.restore(snapshot)
+	} else {
+		for _, policy := range verified {
+			pc.pMap.add(policy)
+		}
+	}
+
+	pc.restored.Lock()
+	pc.restored.policies = verified
+	pc.restored.Unlock()
+
+	pc.countSize()
+	pc.Logger.V(2).Info("restored policy cache snapshot", "policies", len(verified), "complete", complete)
+	return len(verified), complete
+}
+
+// restore replaces the index with the index of the snapshot
+func (m *pMap) restore(snapshot *Snapshot) {
+	m.Lock()
+	defer m.Unlock()
+
+	for kind, dataMap := range snapshot.Index {
+		m.kindDataMap[kind] = make(map[PolicyType][]string)
+		if isGroupWildcard(kind) {
+			m.groupWildcards[kind] = true
+		}
+		for pkey, names := range dataMap {
+			if m.nameCacheMap[pkey] == nil {
+				continue
+			}
+
+			m.kindDataMap[kind][pkey] = append([]string(nil), names...)
+			for _, name := range names {
+				m.nameCacheMap[pkey][kind+"/"+name] = true
+			}
+		}
+	}
+
+	for _, name := range snapshot.Suspended {
+		m.suspendedMap[name] = true
+	}
+
+	for _, name := range snapshot.AdmissionDisabled {
+		m.admissionDisabledMap[name] = true
+	}
+
+	for _, name := range snapshot.BackgroundDisabled {
+		m.backgroundDisabledMap[name] = true
+	}
+}
+
+// getRestored returns a copy of the restored policy, or nil if it is not restored. The snapshot stores the
+// namespaced policies as ClusterPolicy, they are returned as Policy.
+func (pc *policyCache) getRestored(policyName string) kyverno.PolicyInterface {
+	pc.restored.RLock()
+	defer pc.restored.RUnlock()
+
+	policy := pc.restored.policies[policyName]
+	if policy == nil {
+		return nil
+	}
+
+	if policy.IsNamespaced() {
+		nsPolicy := kyverno.Policy(*policy.DeepCopy())
+		return &nsPolicy
+	}
+	return policy.DeepCopy()
+}
+
+// reconcileRestored removes the restored policies that are deleted or changed according to the listers,
+// and drops the restored policies so that the policies are only read from the listers
+func (pc *policyCache) reconcileRestored(policies []*kyverno.ClusterPolicy, nsPolicies []*kyverno.Policy) {
+	pc.restored.Lock()
+	restored := pc.restored.policies
+	pc.restored.policies = nil
+	pc.restored.Unlock()
+
+	if len(restored) == 0 {
+		return
+	}
+
+	resourceVersions := make(map[string]string, len(policies)+len(nsPolicies))
+	for _, policy := range policies {
+		if policy != nil {
+			resourceVersions[policy.GetName()] = policy.GetResourceVersion()
+		}
+	}
+
+	for _, nsPolicy := range nsPolicies {
+		if nsPolicy != nil {
+			resourceVersions[nsPolicy.GetNamespace()+"/"+nsPolicy.GetName()] = nsPolicy.GetResourceVersion()
+		}
+	}
+
+	for name, policy := range restored {
+		if resourceVersion, ok := resourceVersions[name]; !ok || resourceVersion != policy.GetResourceVersion() {
+			pName, kinds := pc.pMap.remove(policy)
+			pc.publish(CacheEvent{Type: Removed, PolicyName: pName, Kinds: kinds})
+			pc.Logger.V(3).Info("removed stale restored policy", "name", name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package policycache
+
+import (
+	"path/filepath"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	lv1 "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"gotest.tools/assert"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newSnapshotTestCache() (Interface, cache.Indexer, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	return newPolicyCache(log.Log, lv1.NewClusterPolicyLister(indexer), lv1.NewPolicyLister(nsIndexer)), indexer, nsIndexer
+}
+
+func newSnapshotTestPolicies() (*kyverno.ClusterPolicy, *kyverno.ClusterPolicy, *kyverno.Policy) {
+	requireLabels := newKindTestPolicy("require-labels", "Pod", "Deployment")
+	requireLabels.SetResourceVersion("10")
+
+	suspended := newKindTestPolicy("require-owner", "Namespace")
+	suspended.SetResourceVersion("11")
+	suspended.Spec.Enabled = new(bool)
+
+	nsPolicy := kyverno.Policy(*newKindTestPolicy("require-team", "Pod"))
+	nsPolicy.SetNamespace("team-a")
+	nsPolicy.SetResourceVersion("12")
+
+	return requireLabels, suspended, &nsPolicy
+}
+
+func Test_Snapshot_RoundTrip(t *testing.T) {
+	pCache, indexer, nsIndexer := newSnapshotTestCache()
+	requireLabels, suspended, nsPolicy := newSnapshotTestPolicies()
+	assert.NilError(t, indexer.Add(requireLabels))
+	assert.NilError(t, indexer.Add(suspended))
+	assert.NilError(t, nsIndexer.Add(nsPolicy))
+	assert.NilError(t, pCache.Warmup())
+
+	snapshot := pCache.Snapshot()
+	snapshot.WebhookRules = map[string][]admregapi.RuleWithOperations{
+		"ValidatingWebhookConfiguration": {
+			{
+				Operations: []admregapi.OperationType{admregapi.Create},
+				Rule:       admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}},
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "policycache.json")
+	assert.NilError(t, SaveSnapshot(path, snapshot))
+
+	loaded, err := LoadSnapshot(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, loaded.WebhookRules, snapshot.WebhookRules)
+
+	// the informers of the restored cache are not synced
+	restoredCache, _, _ := newSnapshotTestCache()
+	restored, complete := restoredCache.Restore(loaded, map[string]string{
+		"require-labels":      "10",
+		"require-owner":       "11",
+		"team-a/require-team": "12",
+	})
+
+	assert.Equal(t, restored, 3)
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, restoredCache.ListAll(), pCache.ListAll())
+
+	for _, kind := range []string{"Pod", "Deployment", "Namespace"} {
+		assert.DeepEqual(t, restoredCache.get(ValidateEnforce, kind, ""), pCache.get(ValidateEnforce, kind, ""))
+		assert.DeepEqual(t, restoredCache.get(ValidateEnforce, kind, "team-a"), pCache.get(ValidateEnforce, kind, "team-a"))
+	}
+
+	policies := restoredCache.GetPolicies(ValidateEnforce, "Pod", "team-a")
+	assert.Equal(t, len(policies), 2)
+	assert.Equal(t, policies[0].GetName(), "require-labels")
+	assert.Equal(t, policies[1].GetNamespace(), "team-a")
+
+	// the suspended policy is restored but not returned
+	assert.Equal(t, len(restoredCache.GetPolicies(ValidateEnforce, "Namespace", "")), 0)
+}
+
+func Test_Snapshot_StaleEntries(t *testing.T) {
+	pCache, indexer, nsIndexer := newSnapshotTestCache()
+	requireLabels, suspended, nsPolicy := newSnapshotTestPolicies()
+	assert.NilError(t, indexer.Add(requireLabels))
+	assert.NilError(t, indexer.Add(suspended))
+	assert.NilError(t, nsIndexer.Add(nsPolicy))
+	assert.NilError(t, pCache.Warmup())
+
+	snapshot := pCache.Snapshot()
+
+	// require-labels is changed to match Services only, and require-team is deleted
+	restoredCache, restoredIndexer, _ := newSnapshotTestCache()
+	restored, complete := restoredCache.Restore(snapshot, map[string]string{
+		"require-labels": "13",
+		"require-owner":  "11",
+	})
+
+	assert.Equal(t, restored, 1)
+	assert.Assert(t, !complete)
+	assert.Equal(t, len(restoredCache.GetPolicies(ValidateEnforce, "Pod", "team-a")), 0)
+	assert.DeepEqual(t, restoredCache.ListAll(), []string{"require-owner"})
+
+	// a policy created since the snapshot was saved makes the snapshot incomplete
+	otherCache, _, _ := newSnapshotTestCache()
+	_, complete = otherCache.Restore(snapshot, map[string]string{
+		"require-labels":      "10",
+		"require-owner":       "11",
+		"team-a/require-team": "12",
+		"require-probes":      "14",
+	})
+	assert.Assert(t, !complete)
+
+	// the restored policies are reconciled with the listers once the informers are synced
+	changed := newKindTestPolicy("require-labels", "Service")
+	changed.SetResourceVersion("13")
+	assert.NilError(t, restoredIndexer.Add(changed))
+	assert.NilError(t, restoredCache.Warmup())
+
+	assert.DeepEqual(t, restoredCache.ListAll(), []string{"require-labels"})
+	assert.Equal(t, len(restoredCache.GetPolicies(ValidateEnforce, "Service", "")), 1)
+	assert.Equal(t, len(restoredCache.GetPolicies(ValidateEnforce, "Namespace", "")), 0)
+}
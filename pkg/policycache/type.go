@@ -11,3 +11,59 @@ const (
 	Generate
 	VerifyImages
 )
+
+// String returns the name of the policy type used in the metrics labels
+func (t PolicyType) String() string {
+	switch t {
+	case Mutate:
+		return "mutate"
+	case ValidateEnforce:
+		return "validate_enforce"
+	case ValidateAudit:
+		return "validate_audit"
+	case Generate:
+		return "generate"
+	case VerifyImages:
+		return "verify_images"
+	}
+	return "unknown"
+}
+
+// CacheEventType represents types of cache change notifications
+type CacheEventType string
+
+// Types of cache change notifications
+const (
+	Added   CacheEventType = "Added"
+	Removed CacheEventType = "Removed"
+)
+
+// CacheEvent notifies that a policy is added to or removed from the cache
+type CacheEvent struct {
+	Type CacheEventType
+
+	// PolicyName is the name of the policy, namespaced policies are named <namespace>/<name>
+	PolicyName string
+
+	// Kinds are the normalized kinds matched by the policy rules
+	Kinds []string
+}
+
+// Request is the admission request the policies are looked up for
+type Request struct {
+	// Kind is the kind of the requested object, e.g. "Pod"
+	Kind string
+
+	// SubResource is the resource and the subresource of the request, e.g. "pods/exec". It is empty
+	// for the requests of the resources.
+	SubResource string
+
+	// Namespace is the namespace of the request
+	Namespace string
+
+	// NamespaceLabels are the labels of the namespace, the namespace selectors are not evaluated if nil
+	NamespaceLabels map[string]string
+
+	// Operation is the operation of the request, e.g. CREATE
+	Operation string
+}
@@ -0,0 +1,153 @@
+package policymutation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+)
+
+// PodControllerTemplatePathsAnnotation declares the custom pod controllers and the paths of their
+// pod templates, e.g. "argoproj.io/v1alpha1/Rollout=spec.template,Workflow=spec.podTemplate".
+// The rules are generated for the custom controllers set in the autogen-controllers annotation.
+const PodControllerTemplatePathsAnnotation = "pod-policies.kyverno.io/autogen-template-paths"
+
+// defaultTemplatePath is the path of the pod template of the built-in pod controllers
+var defaultTemplatePath = []string{"spec", "template"}
+
+// templateControllers are the built-in pod controllers with the pod template at the default path,
+// the ReplicaSets and ReplicationControllers are not part of "all" as they are usually owned by
+// the other controllers
+var templateControllers = map[string]bool{
+	"DaemonSet":             true,
+	"Deployment":            true,
+	"Job":                   true,
+	"StatefulSet":           true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+}
+
+// CustomController is a pod controller with the pod template at a configured path
+type CustomController struct {
+	Kind string
+	Path []string
+}
+
+// ParseTemplatePaths parses the custom pod controllers of the template paths annotation
+func ParseTemplatePaths(annotation string) ([]CustomController, error) {
+	var controllers []CustomController
+	for _, entry := range strings.Split(annotation, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kind, path := splitTemplatePath(entry)
+		if kind == "" || path == "" {
+			return nil, fmt.Errorf("invalid pod template path %s, expect <kind>=<path>", entry)
+		}
+
+		if kind == "Pod" || kind == "CronJob" || templateControllers[kind] {
+			return nil, fmt.Errorf("invalid pod template path %s, the path of the built-in controller %s cannot be changed", entry, kind)
+		}
+
+		controllers = append(controllers, CustomController{Kind: kind, Path: strings.Split(path, ".")})
+	}
+
+	return controllers, nil
+}
+
+func splitTemplatePath(entry string) (string, string) {
+	i := strings.Index(entry, "=")
+	if i == -1 {
+		return "", ""
+	}
+
+	return strings.TrimSpace(entry[:i]), strings.Trim(strings.TrimSpace(entry[i+1:]), ".")
+}
+
+// CustomControllers returns the custom controllers of the policy set in the controllers
+func CustomControllers(policy kyverno.ClusterPolicy, controllers string, log logr.Logger) []CustomController {
+	annotation, ok := policy.GetAnnotations()[PodControllerTemplatePathsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	declared, err := ParseTemplatePaths(annotation)
+	if err != nil {
+		log.Error(err, "failed to parse the pod template paths", "policy", policy.GetName())
+		return nil
+	}
+
+	selected := strings.Split(controllers, ",")
+	var custom []CustomController
+	for _, controller := range declared {
+		for _, c := range selected {
+			if c == controller.Kind {
+				custom = append(custom, controller)
+				break
+			}
+		}
+	}
+
+	return custom
+}
+
+// stripCustomControllers removes the custom controllers from controllers
+func stripCustomControllers(controllers string, custom []CustomController) string {
+	var newControllers []string
+	for _, c := range strings.Split(controllers, ",") {
+		isCustom := false
+		for _, controller := range custom {
+			if c == controller.Kind {
+				isCustom = true
+				break
+			}
+		}
+
+		if !isCustom {
+			newControllers = append(newControllers, c)
+		}
+	}
+
+	return strings.Join(newControllers, ",")
+}
+
+// generateCustomControllerRule generates the rule for a custom controller, the pod template is at the path of the controller
+func generateCustomControllerRule(rule kyverno.Rule, controller CustomController, log logr.Logger) kyvernoRule {
+	logger := log.WithName("generateCustomControllerRule")
+
+	// the rules which cannot be generated for the built-in controllers are not generated for the custom ones
+	if reflect.DeepEqual(generateRuleForControllers(rule, "Job", logger), kyvernoRule{}) {
+		return kyvernoRule{}
+	}
+
+	kind := controller.Kind[strings.LastIndex(controller.Kind, "/")+1:]
+	name := fmt.Sprintf("autogen-%s-%s", strings.ToLower(kind), rule.Name)
+
+	logger.V(3).Info("generating rule for custom controller", "kind", controller.Kind, "path", strings.Join(controller.Path, "."))
+	return generateRuleForPodTemplate(rule, name, []string{controller.Kind}, controller.Path, logger)
+}
+
+// podTemplate nests the value of the pod template at the path
+func podTemplate(path []string, value interface{}) map[string]interface{} {
+	for i := len(path) - 1; i > 0; i-- {
+		value = map[string]interface{}{path[i]: value}
+	}
+
+	return map[string]interface{}{path[0]: value}
+}
+
+// updateVariablesForPodTemplate shifts the variables of the pod in the rule to the pod template at the path
+func updateVariablesForPodTemplate(pbyte []byte, path []string) []byte {
+	if pbyte == nil {
+		return nil
+	}
+
+	prefix := "request.object." + strings.Join(path, ".")
+	obj := strings.Replace(string(pbyte), "request.object.spec", prefix+".spec", -1)
+	obj = strings.Replace(obj, "request.object.metadata", prefix+".metadata", -1)
+	return []byte(obj)
+}
@@ -259,6 +259,7 @@ func GeneratePodControllerRule(policy kyverno.ClusterPolicy, log logr.Logger) (p
 //          - name or selector is defined
 //          - mixed kinds (Pod + pod controller) is defined
 //          - mutate.Patches/mutate.PatchesJSON6902/validate.deny/generate rule is defined
+//          - validate.foreach/mutate.foreach rule is defined, the lists select the fields of the pods
 // - otherwise it returns all pod controllers
 func CanAutoGen(policy *kyverno.ClusterPolicy, log logr.Logger) (applyAutoGen bool, controllers string) {
 	for _, rule := range policy.Spec.Rules {
@@ -277,7 +278,8 @@ func CanAutoGen(policy *kyverno.ClusterPolicy, log logr.Logger) (applyAutoGen bo
 		}
 
 		if rule.Mutation.Patches != nil || rule.Mutation.PatchesJSON6902 != "" ||
-			rule.Validation.Deny != nil || rule.HasGenerate() {
+			rule.Validation.Deny != nil || rule.HasGenerate() ||
+			rule.HasValidateForEach() || rule.HasMutateForEach() {
 			return false, "none"
 		}
 	}
@@ -291,6 +293,9 @@ func createRuleMap(rules []kyverno.Rule) map[string]kyvernoRule {
 		var jsonFriendlyStruct kyvernoRule
 
 		jsonFriendlyStruct.Name = rule.Name
+		jsonFriendlyStruct.ID = rule.ID
+		jsonFriendlyStruct.DocumentationURL = rule.DocumentationURL
+		jsonFriendlyStruct.TimeWindows = rule.DeepCopy().TimeWindows
 
 		if !reflect.DeepEqual(rule.MatchResources, kyverno.MatchResources{}) {
 			jsonFriendlyStruct.MatchResources = rule.MatchResources.DeepCopy()
@@ -336,6 +341,7 @@ func generateRulePatches(policy kyverno.ClusterPolicy, controllers string, log l
 		ruleIndex[rule.Name] = index
 	}
 
+	custom := CustomControllers(policy, controllers, log)
 	for _, rule := range policy.Spec.Rules {
 		patchPostion := insertIdx
 		convertToPatches := func(genRule kyvernoRule, patchPostion int) []byte {
@@ -377,7 +383,7 @@ func generateRulePatches(policy kyverno.ClusterPolicy, controllers string, log l
 		}
 
 		// handle all other controllers other than CronJob
-		genRule := generateRuleForControllers(rule, stripCronJob(controllers), log)
+		genRule := generateRuleForControllers(rule, stripCronJob(stripCustomControllers(controllers, custom)), log)
 		if !reflect.DeepEqual(genRule, kyvernoRule{}) {
 			pbytes := convertToPatches(genRule, patchPostion)
 			pbytes = updateGenRuleByte(pbytes, "Pod", genRule)
@@ -397,6 +403,21 @@ func generateRulePatches(policy kyverno.ClusterPolicy, controllers string, log l
 				rulePatches = append(rulePatches, pbytes)
 			}
 			insertIdx++
+			patchPostion = insertIdx
+		}
+
+		// handle the custom controllers, it appends an additional rule for each controller
+		for _, controller := range custom {
+			genRule = generateCustomControllerRule(rule, controller, log)
+			if !reflect.DeepEqual(genRule, kyvernoRule{}) {
+				pbytes := convertToPatches(genRule, patchPostion)
+				pbytes = updateVariablesForPodTemplate(pbytes, controller.Path)
+				if pbytes != nil {
+					rulePatches = append(rulePatches, pbytes)
+				}
+				insertIdx++
+				patchPostion = insertIdx
+			}
 		}
 	}
 	return
@@ -413,10 +434,13 @@ func generateRulePatches(policy kyverno.ClusterPolicy, controllers string, log l
 
 type kyvernoRule struct {
 	Name             string                       `json:"name"`
+	ID               string                       `json:"id,omitempty"`
+	DocumentationURL string                       `json:"documentationURL,omitempty"`
 	MatchResources   *kyverno.MatchResources      `json:"match"`
 	ExcludeResources *kyverno.ExcludeResources    `json:"exclude,omitempty"`
 	Context          *[]kyverno.ContextEntry      `json:"context,omitempty"`
 	AnyAllConditions *apiextensions.JSON          `json:"preconditions,omitempty"`
+	TimeWindows      []kyverno.TimeWindow         `json:"timeWindows,omitempty"`
 	Mutation         *kyverno.Mutation            `json:"mutate,omitempty"`
 	Validation       *kyverno.Validation          `json:"validate,omitempty"`
 	VerifyImages     []*kyverno.ImageVerification `json:"verifyImages,omitempty" yaml:"verifyImages,omitempty"`
@@ -445,9 +469,8 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 	if controllers == "all" {
 		skipAutoGeneration = true
 	} else if controllers != "none" && controllers != "all" {
-		controllersList := map[string]int{"DaemonSet": 1, "Deployment": 1, "Job": 1, "StatefulSet": 1}
 		for _, value := range strings.Split(controllers, ",") {
-			if _, ok := controllersList[value]; ok {
+			if templateControllers[value] {
 				controllersValidated = append(controllersValidated, value)
 			}
 		}
@@ -464,14 +487,25 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 		}
 	}
 
-	name := fmt.Sprintf("autogen-%s", rule.Name)
+	return generateRuleForPodTemplate(rule, fmt.Sprintf("autogen-%s", rule.Name), strings.Split(controllers, ","), defaultTemplatePath, log)
+}
+
+// generateRuleForPodTemplate generates the rule for the pod controllers with the pod template at the path
+func generateRuleForPodTemplate(rule kyverno.Rule, name string, controllers []string, path []string, log logr.Logger) kyvernoRule {
+	logger := log.WithName("generateRuleForPodTemplate")
+	match := rule.MatchResources
+	exclude := rule.ExcludeResources
+
 	if len(name) > 63 {
 		name = name[:63]
 	}
 
 	controllerRule := &kyvernoRule{
-		Name:           name,
-		MatchResources: match.DeepCopy(),
+		Name:             name,
+		ID:               rule.ID,
+		DocumentationURL: rule.DocumentationURL,
+		MatchResources:   match.DeepCopy(),
+		TimeWindows:      rule.DeepCopy().TimeWindows,
 	}
 
 	if len(rule.Context) > 0 {
@@ -495,18 +529,14 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 	}
 
 	// overwrite Kinds by pod controllers defined in the annotation
-	controllerRule.MatchResources.Kinds = strings.Split(controllers, ",")
+	controllerRule.MatchResources.Kinds = controllers
 	if len(exclude.Kinds) != 0 {
-		controllerRule.ExcludeResources.Kinds = strings.Split(controllers, ",")
+		controllerRule.ExcludeResources.Kinds = controllers
 	}
 
 	if rule.Mutation.Overlay != nil {
 		newMutation := &kyverno.Mutation{
-			PatchStrategicMerge: map[string]interface{}{
-				"spec": map[string]interface{}{
-					"template": rule.Mutation.Overlay,
-				},
-			},
+			PatchStrategicMerge: podTemplate(path, rule.Mutation.Overlay),
 		}
 
 		controllerRule.Mutation = newMutation.DeepCopy()
@@ -515,11 +545,7 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 
 	if rule.Mutation.PatchStrategicMerge != nil {
 		newMutation := &kyverno.Mutation{
-			PatchStrategicMerge: map[string]interface{}{
-				"spec": map[string]interface{}{
-					"template": rule.Mutation.PatchStrategicMerge,
-				},
-			},
+			PatchStrategicMerge: podTemplate(path, rule.Mutation.PatchStrategicMerge),
 		}
 
 		controllerRule.Mutation = newMutation.DeepCopy()
@@ -528,12 +554,8 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 
 	if rule.Validation.Pattern != nil {
 		newValidate := &kyverno.Validation{
-			Message: variables.FindAndShiftReferences(log, rule.Validation.Message, "spec/template", "pattern"),
-			Pattern: map[string]interface{}{
-				"spec": map[string]interface{}{
-					"template": rule.Validation.Pattern,
-				},
-			},
+			Message: variables.FindAndShiftReferences(log, rule.Validation.Message, strings.Join(path, "/"), "pattern"),
+			Pattern: podTemplate(path, rule.Validation.Pattern),
 		}
 		controllerRule.Validation = newValidate.DeepCopy()
 		return *controllerRule
@@ -547,23 +569,18 @@ func generateRuleForControllers(rule kyverno.Rule, controllers string, log logr.
 		}
 
 		for _, pattern := range anyPatterns {
-			newPattern := map[string]interface{}{
-				"spec": map[string]interface{}{
-					"template": pattern,
-				},
-			}
-
-			patterns = append(patterns, newPattern)
+			patterns = append(patterns, podTemplate(path, pattern))
 		}
 
 		controllerRule.Validation = &kyverno.Validation{
-			Message:    variables.FindAndShiftReferences(log, rule.Validation.Message, "spec/template", "anyPattern"),
+			Message:    variables.FindAndShiftReferences(log, rule.Validation.Message, strings.Join(path, "/"), "anyPattern"),
 			AnyPattern: patterns,
 		}
 		return *controllerRule
 	}
 
-	if rule.VerifyImages != nil {
+	// the images are extracted from the pod templates at the default path
+	if rule.VerifyImages != nil && reflect.DeepEqual(path, defaultTemplatePath) {
 		newVerifyImages := make([]*kyverno.ImageVerification, len(rule.VerifyImages))
 		for i, vi := range rule.VerifyImages {
 			newVerifyImages[i] = vi.DeepCopy()
@@ -266,3 +266,100 @@ func Test_UpdateVariablePath(t *testing.T) {
 
 	assert.DeepEqual(t, rulePatches, expectedPatches)
 }
+
+func Test_RuleDocumentation(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"rules":[{"name":"check-team-label","id":"SEC-014","documentationURL":"https://controls.example.com/SEC-014","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	rulePatches, errs := generateRulePatches(policy, engine.PodControllers, log.Log)
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, len(rulePatches), 2)
+
+	// the generated rules have the ID and documentation URL of the rule
+	for _, rulePatch := range rulePatches {
+		var patch struct {
+			Value kyverno.Rule `json:"value"`
+		}
+		assert.NilError(t, json.Unmarshal(rulePatch, &patch))
+		assert.Assert(t, strings.HasPrefix(patch.Value.Name, "autogen-"))
+		assert.Equal(t, patch.Value.ID, "SEC-014")
+		assert.Equal(t, patch.Value.DocumentationURL, "https://controls.example.com/SEC-014")
+	}
+
+	// the generated rules are not patched again
+	for _, rulePatch := range rulePatches {
+		var patch struct {
+			Value kyverno.Rule `json:"value"`
+		}
+		assert.NilError(t, json.Unmarshal(rulePatch, &patch))
+		policy.Spec.Rules = append(policy.Spec.Rules, patch.Value)
+	}
+
+	rulePatches, errs = generateRulePatches(policy, engine.PodControllers, log.Log)
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, len(rulePatches), 0)
+}
+
+func Test_ReplicaSetAndReplicationController(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"rules":[{"name":"check-team-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	rulePatches, errs := generateRulePatches(policy, "ReplicaSet,ReplicationController,Pod", log.Log)
+	assert.Equal(t, len(errs), 0)
+
+	expectedPatches := [][]byte{
+		[]byte(`{"path":"/spec/rules/1","op":"add","value":{"name":"autogen-check-team-label","match":{"resources":{"kinds":["ReplicaSet","ReplicationController"]}},"validate":{"message":"label 'team' is required","pattern":{"spec":{"template":{"metadata":{"labels":{"team":"?*"}}}}}}}}`),
+	}
+
+	assert.DeepEqual(t, rulePatches, expectedPatches)
+}
+
+func Test_CustomControllers(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team","annotations":{"pod-policies.kyverno.io/autogen-template-paths":"argoproj.io/v1alpha1/Rollout=spec.template, example.com/v1/Sandbox=spec.runtime.podTemplate, Unused=spec.template"}},"spec":{"rules":[{"name":"check-team-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' of {{request.object.metadata.name}} is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	controllers := "Deployment,argoproj.io/v1alpha1/Rollout,example.com/v1/Sandbox"
+	rulePatches, errs := generateRulePatches(policy, controllers, log.Log)
+	assert.Equal(t, len(errs), 0)
+
+	expectedPatches := [][]byte{
+		[]byte(`{"path":"/spec/rules/1","op":"add","value":{"name":"autogen-check-team-label","match":{"resources":{"kinds":["Deployment"]}},"validate":{"message":"label 'team' of {{request.object.spec.template.metadata.name}} is required","pattern":{"spec":{"template":{"metadata":{"labels":{"team":"?*"}}}}}}}}`),
+		[]byte(`{"path":"/spec/rules/2","op":"add","value":{"name":"autogen-rollout-check-team-label","match":{"resources":{"kinds":["argoproj.io/v1alpha1/Rollout"]}},"validate":{"message":"label 'team' of {{request.object.spec.template.metadata.name}} is required","pattern":{"spec":{"template":{"metadata":{"labels":{"team":"?*"}}}}}}}}`),
+		[]byte(`{"path":"/spec/rules/3","op":"add","value":{"name":"autogen-sandbox-check-team-label","match":{"resources":{"kinds":["example.com/v1/Sandbox"]}},"validate":{"message":"label 'team' of {{request.object.spec.runtime.podTemplate.metadata.name}} is required","pattern":{"spec":{"runtime":{"podTemplate":{"metadata":{"labels":{"team":"?*"}}}}}}}}}`),
+	}
+
+	assert.DeepEqual(t, rulePatches, expectedPatches)
+}
+
+func Test_ParseTemplatePaths(t *testing.T) {
+	testCases := []struct {
+		annotation  string
+		expected    []CustomController
+		expectedErr bool
+	}{
+		{annotation: "Rollout=spec.template", expected: []CustomController{{Kind: "Rollout", Path: []string{"spec", "template"}}}},
+		{annotation: "Rollout = spec.template. ,Sandbox=spec.pod", expected: []CustomController{{Kind: "Rollout", Path: []string{"spec", "template"}}, {Kind: "Sandbox", Path: []string{"spec", "pod"}}}},
+		{annotation: "", expected: nil},
+		{annotation: "Rollout", expectedErr: true},
+		{annotation: "Rollout=", expectedErr: true},
+		{annotation: "CronJob=spec.template", expectedErr: true},
+		{annotation: "Deployment=spec.template", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		controllers, err := ParseTemplatePaths(tc.annotation)
+		if tc.expectedErr {
+			assert.Assert(t, err != nil, tc.annotation)
+			continue
+		}
+
+		assert.NilError(t, err, tc.annotation)
+		assert.DeepEqual(t, controllers, tc.expected)
+	}
+}
@@ -3,6 +3,7 @@ package policyreport
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
@@ -29,6 +30,14 @@ const (
 	// there would be a problem if use labels as the value could exceed 63 chars
 	deletedAnnotationResourceName string = "kyverno.io/delete.resource.name"
 	deletedAnnotationResourceKind string = "kyverno.io/delete.resource.kind"
+
+	// the resourceVersion of the policy that produced a result is stored in the result data
+	// it is used to identify results of rules that were removed or renamed since
+	resultDataPolicyResourceVersion string = "kyverno.io/policy.resourceVersion"
+
+	// the namespace of a namespaced Policy is stored in the data of its results, to tell them
+	// apart from the results of a ClusterPolicy with the same name
+	resultDataPolicyNamespace string = "kyverno.io/policy.namespace"
 )
 
 func generatePolicyReportName(ns string) string {
@@ -83,19 +92,24 @@ func NewBuilder(cpolLister kyvernolister.ClusterPolicyLister, polLister kyvernol
 func (builder *requestBuilder) build(info Info) (req *unstructured.Unstructured, err error) {
 	results := []*report.PolicyReportResult{}
 	for _, infoResult := range info.Results {
+		if builder.isPolicyDisabled(info.PolicyName, info.PolicyNamespace) {
+			// no results are reported for a disabled policy
+			break
+		}
+
 		for _, rule := range infoResult.Rules {
-			if rule.Type != utils.Validation.String() {
+			if rule.Type != utils.Validation.String() && !isMutateDryRun(rule) {
 				continue
 			}
 
-			result := builder.buildRCRResult(info.PolicyName, infoResult.Resource, rule)
+			result := builder.buildRCRResult(info.PolicyName, info.PolicyNamespace, infoResult.Resource, rule)
 			results = append(results, result)
 		}
 	}
 
 	if info.Namespace != "" {
 		rr := &request.ReportChangeRequest{
-			Summary: calculateSummary(results),
+			Summary: CalculateSummary(results),
 			Results: results,
 		}
 
@@ -108,7 +122,7 @@ func (builder *requestBuilder) build(info Info) (req *unstructured.Unstructured,
 		set(req, info)
 	} else {
 		rr := &request.ClusterReportChangeRequest{
-			Summary: calculateSummary(results),
+			Summary: CalculateSummary(results),
 			Results: results,
 		}
 
@@ -131,8 +145,12 @@ func (builder *requestBuilder) build(info Info) (req *unstructured.Unstructured,
 	return req, nil
 }
 
-func (builder *requestBuilder) buildRCRResult(policy string, resource response.ResourceSpec, rule kyverno.ViolatedRule) *report.PolicyReportResult {
-	av := builder.fetchAnnotationValues(policy, resource.Namespace)
+func (builder *requestBuilder) buildRCRResult(policy, policyNamespace string, resource response.ResourceSpec, rule kyverno.ViolatedRule) *report.PolicyReportResult {
+	av := builder.fetchAnnotationValues(policy, policyNamespace)
+	if severity, ok := rule.Properties[response.RulePropertySeverity]; ok {
+		// the severity computed from the attestations of the images replaces the severity of the policy
+		av.setSeverityFromString(severity)
+	}
 
 	result := &report.PolicyReportResult{
 		Policy: policy,
@@ -145,9 +163,28 @@ func (builder *requestBuilder) buildRCRResult(policy string, resource response.R
 				UID:        types.UID(resource.UID),
 			},
 		},
-		Scored:   av.scored,
-		Category: av.category,
-		Severity: av.severity,
+		Scored:    av.scored,
+		Category:  av.category,
+		Severity:  av.severity,
+		Timestamp: metav1.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	if resourceVersion := builder.fetchPolicyResourceVersion(policy, policyNamespace); resourceVersion != "" {
+		result.Data = map[string]string{resultDataPolicyResourceVersion: resourceVersion}
+	}
+
+	if policyNamespace != "" {
+		if result.Data == nil {
+			result.Data = make(map[string]string)
+		}
+		result.Data[resultDataPolicyNamespace] = policyNamespace
+	}
+
+	for key, value := range rule.Properties {
+		if result.Data == nil {
+			result.Data = make(map[string]string)
+		}
+		result.Data[key] = value
 	}
 
 	result.Rule = rule.Name
@@ -159,6 +196,12 @@ func (builder *requestBuilder) buildRCRResult(policy string, resource response.R
 	return result
 }
 
+// isMutateDryRun checks if the rule is a mutate rule of a policy in dry run, the patches
+// it would apply are reported
+func isMutateDryRun(rule kyverno.ViolatedRule) bool {
+	return rule.Type == utils.Mutation.String() && rule.Properties[response.RulePropertyMutateDryRun] == "true"
+}
+
 func set(obj *unstructured.Unstructured, info Info) {
 	obj.SetAPIVersion(request.SchemeGroupVersion.Group + "/" + request.SchemeGroupVersion.Version)
 
@@ -210,7 +253,8 @@ func setRequestLabels(req *unstructured.Unstructured, info Info) bool {
 	return false
 }
 
-func calculateSummary(results []*report.PolicyReportResult) (summary report.PolicyReportSummary) {
+// CalculateSummary counts the results by status
+func CalculateSummary(results []*report.PolicyReportResult) (summary report.PolicyReportSummary) {
 	for _, res := range results {
 		switch string(res.Status) {
 		case report.StatusPass:
@@ -230,8 +274,9 @@ func calculateSummary(results []*report.PolicyReportResult) (summary report.Poli
 
 func buildPVInfo(er *response.EngineResponse) Info {
 	info := Info{
-		PolicyName: er.PolicyResponse.Policy.Name,
-		Namespace:  er.PatchedResource.GetNamespace(),
+		PolicyName:      er.PolicyResponse.Policy.Name,
+		PolicyNamespace: er.PolicyResponse.Policy.Namespace,
+		Namespace:       er.PatchedResource.GetNamespace(),
 		Results: []EngineResponseResult{
 			{
 				Resource: er.GetResourceSpec(),
@@ -246,12 +291,20 @@ func buildViolatedRules(er *response.EngineResponse) []kyverno.ViolatedRule {
 	var violatedRules []kyverno.ViolatedRule
 	for _, rule := range er.PolicyResponse.Rules {
 		vrule := kyverno.ViolatedRule{
-			Name:    rule.Name,
-			Type:    rule.Type,
-			Message: rule.Message,
+			Name:       rule.Name,
+			Type:       rule.Type,
+			Message:    rule.Message,
+			Properties: ruleProperties(rule, er.PolicyResponse.CorrelationID),
 		}
 		vrule.Check = report.StatusFail
-		if rule.Success {
+		if rule.Error {
+			vrule.Check = report.StatusError
+		} else if rule.Skipped {
+			vrule.Check = report.StatusSkip
+		} else if rule.Success && rule.Properties[response.RulePropertyPatches] != "" {
+			// the patches of a mutate rule in dry run are not applied
+			vrule.Check = report.StatusWarn
+		} else if rule.Success {
 			vrule.Check = report.StatusPass
 		}
 		violatedRules = append(violatedRules, vrule)
@@ -259,6 +312,33 @@ func buildViolatedRules(er *response.EngineResponse) []kyverno.ViolatedRule {
 	return violatedRules
 }
 
+// ruleProperties returns the properties of the rule result, including the rule ID, documentation URL
+// and the correlation ID of the admission request
+func ruleProperties(rule response.RuleResponse, correlationID string) map[string]string {
+	if rule.ID == "" && rule.DocumentationURL == "" && correlationID == "" {
+		return rule.Properties
+	}
+
+	properties := make(map[string]string, len(rule.Properties)+3)
+	for key, value := range rule.Properties {
+		properties[key] = value
+	}
+
+	if rule.ID != "" {
+		properties[response.RulePropertyID] = rule.ID
+	}
+
+	if rule.DocumentationURL != "" {
+		properties[response.RulePropertyDocumentationURL] = rule.DocumentationURL
+	}
+
+	if correlationID != "" {
+		properties[response.RulePropertyCorrelationID] = correlationID
+	}
+
+	return properties
+}
+
 const categoryLabel string = "policies.kyverno.io/category"
 const severityLabel string = "policies.kyverno.io/severity"
 const scoredLabel string = "policies.kyverno.io/scored"
@@ -271,6 +351,8 @@ type annotationValues struct {
 
 func (av *annotationValues) setSeverityFromString(severity string) {
 	switch severity {
+	case report.SeverityCritical:
+		av.severity = report.SeverityCritical
 	case report.SeverityHigh:
 		av.severity = report.SeverityHigh
 	case report.SeverityMedium:
@@ -280,9 +362,9 @@ func (av *annotationValues) setSeverityFromString(severity string) {
 	}
 }
 
-func (builder *requestBuilder) fetchAnnotationValues(policy, ns string) annotationValues {
+func (builder *requestBuilder) fetchAnnotationValues(policy, policyNamespace string) annotationValues {
 	av := annotationValues{}
-	ann := builder.fetchAnnotations(policy, ns)
+	ann := builder.fetchAnnotations(policy, policyNamespace)
 
 	if category, ok := ann[categoryLabel]; ok {
 		av.category = category
@@ -303,22 +385,50 @@ func (builder *requestBuilder) fetchAnnotationValues(policy, ns string) annotati
 	return av
 }
 
-func (builder *requestBuilder) fetchAnnotations(policy, ns string) map[string]string {
-	cpol, err := builder.cpolLister.Get(policy)
-	if err == nil {
-		if ann := cpol.GetAnnotations(); ann != nil {
+func (builder *requestBuilder) fetchAnnotations(policy, policyNamespace string) map[string]string {
+	if pol := builder.fetchPolicy(policy, policyNamespace); pol != nil {
+		if ann := pol.GetAnnotations(); ann != nil {
 			return ann
 		}
 	}
 
-	pol, err := builder.polLister.Policies(ns).Get(policy)
-	if err == nil {
-		if ann := pol.GetAnnotations(); ann != nil {
-			return ann
+	return make(map[string]string, 0)
+}
+
+func (builder *requestBuilder) fetchPolicyResourceVersion(policy, policyNamespace string) string {
+	if pol := builder.fetchPolicy(policy, policyNamespace); pol != nil {
+		return pol.GetResourceVersion()
+	}
+
+	return ""
+}
+
+// isPolicyDisabled checks if the policy is disabled by spec.enabled
+func (builder *requestBuilder) isPolicyDisabled(policy, policyNamespace string) bool {
+	pol := builder.fetchPolicy(policy, policyNamespace)
+	if pol == nil {
+		return false
+	}
+
+	return pol.GetSpec().Enabled != nil && !*pol.GetSpec().Enabled
+}
+
+// fetchPolicy returns the Policy of the namespace for the results of a namespaced policy, the ClusterPolicy
+// otherwise, or nil if it is not found
+func (builder *requestBuilder) fetchPolicy(policy, policyNamespace string) kyverno.PolicyInterface {
+	if policyNamespace != "" {
+		pol, err := builder.polLister.Policies(policyNamespace).Get(policy)
+		if err != nil {
+			return nil
 		}
+		return pol
 	}
 
-	return make(map[string]string, 0)
+	cpol, err := builder.cpolLister.Get(policy)
+	if err != nil {
+		return nil
+	}
+	return cpol
 }
 
 func isResourceDeletion(info Info) bool {
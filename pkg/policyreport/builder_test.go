@@ -0,0 +1,253 @@
+package policyreport
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestInfo(policy, namespace string) Info {
+	return Info{
+		PolicyName: policy,
+		Namespace:  namespace,
+		Results: []EngineResponseResult{
+			{
+				Resource: response.ResourceSpec{Kind: "Pod", Namespace: namespace, Name: "nginx"},
+				Rules: []kyverno.ViolatedRule{
+					{Name: "check-labels", Type: "Validation", Message: "label is required", Check: "fail"},
+				},
+			},
+		},
+	}
+}
+
+func getResults(t *testing.T, req *unstructured.Unstructured) []interface{} {
+	results, _, err := unstructured.NestedSlice(req.Object, "results")
+	assert.NilError(t, err)
+	return results
+}
+
+func Test_Build_Disabled_Policy(t *testing.T) {
+	cpolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	polIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	builder := NewBuilder(kyvernolister.NewClusterPolicyLister(cpolIndexer), kyvernolister.NewPolicyLister(polIndexer))
+
+	enabled := false
+	cpol := &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels"},
+		Spec:       kyverno.Spec{Enabled: &enabled},
+	}
+	pol := &kyverno.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-ns-labels", Namespace: "test"},
+		Spec:       kyverno.Spec{Enabled: &enabled},
+	}
+	assert.NilError(t, cpolIndexer.Add(cpol))
+	assert.NilError(t, polIndexer.Add(pol))
+
+	// no results are reported while the policies are disabled
+	req, err := builder.build(newTestInfo("require-labels", ""))
+	assert.NilError(t, err)
+	assert.Assert(t, req == nil)
+
+	nsInfo := newTestInfo("require-ns-labels", "test")
+	nsInfo.PolicyNamespace = "test"
+	req, err = builder.build(nsInfo)
+	assert.NilError(t, err)
+	assert.Assert(t, req == nil)
+
+	// the results of a ClusterPolicy are reported while a Policy with the same name is disabled
+	req, err = builder.build(newTestInfo("require-ns-labels", "test"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(getResults(t, req)), 1)
+
+	// results of a disabled policy can still be removed
+	req, err = builder.build(Info{PolicyName: "require-labels"})
+	assert.NilError(t, err)
+	assert.Equal(t, req.GetLabels()[deletedLabelPolicy], "require-labels")
+
+	// results are reported again once the policies are enabled
+	enabled = true
+	req, err = builder.build(newTestInfo("require-labels", ""))
+	assert.NilError(t, err)
+	assert.Equal(t, len(getResults(t, req)), 1)
+
+	req, err = builder.build(nsInfo)
+	assert.NilError(t, err)
+	assert.Equal(t, len(getResults(t, req)), 1)
+}
+
+func Test_Build_Rule_Documentation(t *testing.T) {
+	cpolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	polIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	builder := NewBuilder(kyvernolister.NewClusterPolicyLister(cpolIndexer), kyvernolister.NewPolicyLister(polIndexer))
+
+	er := &response.EngineResponse{
+		PatchedResource: unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Pod",
+			"metadata": map[string]interface{}{"name": "nginx", "namespace": "test"},
+		}},
+		PolicyResponse: response.PolicyResponse{
+			Policy:   response.PolicySpec{Name: "require-team"},
+			Resource: response.ResourceSpec{Kind: "Pod", Namespace: "test", Name: "nginx"},
+			Rules: []response.RuleResponse{
+				{
+					Name:             "check-team-label",
+					ID:               "SEC-014",
+					DocumentationURL: "https://controls.example.com/SEC-014",
+					Type:             "Validation",
+					Message:          "label 'team' is required",
+				},
+			},
+		},
+	}
+
+	infos := GeneratePRsFromEngineResponse([]*response.EngineResponse{er}, log.Log)
+	assert.Equal(t, len(infos), 1)
+
+	req, err := builder.build(infos[0])
+	assert.NilError(t, err)
+
+	results := getResults(t, req)
+	assert.Equal(t, len(results), 1)
+
+	data, _, err := unstructured.NestedStringMap(results[0].(map[string]interface{}), "data")
+	assert.NilError(t, err)
+	assert.Equal(t, data[response.RulePropertyID], "SEC-014")
+	assert.Equal(t, data[response.RulePropertyDocumentationURL], "https://controls.example.com/SEC-014")
+
+	// the rule properties of the engine response are not modified
+	assert.Assert(t, er.PolicyResponse.Rules[0].Properties == nil)
+}
+
+func Test_Build_Skipped_Rule(t *testing.T) {
+	er := &response.EngineResponse{
+		PolicyResponse: response.PolicyResponse{
+			Policy:   response.PolicySpec{Name: "deny-prod-deletes"},
+			Resource: response.ResourceSpec{Kind: "Deployment", Namespace: "prod", Name: "web"},
+			Rules: []response.RuleResponse{
+				{
+					Name:    "business-hours",
+					Type:    "Validation",
+					Message: "rule business-hours is not applied outside of its time windows",
+					Success: true,
+					Skipped: true,
+					Properties: map[string]string{
+						response.RulePropertyTimeWindow: response.TimeWindowOutside,
+					},
+				},
+				{
+					Name:    "require-team",
+					Type:    "Validation",
+					Success: true,
+					Properties: map[string]string{
+						response.RulePropertyTimeWindow: response.TimeWindowInside,
+					},
+				},
+			},
+		},
+	}
+
+	rules := buildViolatedRules(er)
+	assert.Equal(t, len(rules), 2)
+	assert.Equal(t, rules[0].Check, "skip")
+	assert.Equal(t, rules[0].Properties[response.RulePropertyTimeWindow], response.TimeWindowOutside)
+	assert.Equal(t, rules[1].Check, "pass")
+	assert.Equal(t, rules[1].Properties[response.RulePropertyTimeWindow], response.TimeWindowInside)
+}
+
+func Test_Build_Mutate_DryRun(t *testing.T) {
+	cpolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	polIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	builder := NewBuilder(kyvernolister.NewClusterPolicyLister(cpolIndexer), kyvernolister.NewPolicyLister(polIndexer))
+
+	info := newTestInfo("add-team-label", "test")
+	info.Results[0].Rules = []kyverno.ViolatedRule{
+		{Name: "add-team", Type: "Mutation", Check: "warn", Properties: map[string]string{
+			response.RulePropertyMutateDryRun: "true",
+			response.RulePropertyPatches:      "add /metadata/labels/team",
+		}},
+		{Name: "add-owner", Type: "Mutation", Check: "pass"},
+	}
+
+	// only the mutate rules in dry run are reported
+	req, err := builder.build(info)
+	assert.NilError(t, err)
+
+	results := getResults(t, req)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].(map[string]interface{})["status"], "warn")
+
+	summary, _, err := unstructured.NestedInt64(req.Object, "summary", "warn")
+	assert.NilError(t, err)
+	assert.Equal(t, summary, int64(1))
+}
+
+func Test_Build_Attestation_Severity(t *testing.T) {
+	cpolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	polIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	builder := NewBuilder(kyvernolister.NewClusterPolicyLister(cpolIndexer), kyvernolister.NewPolicyLister(polIndexer))
+
+	cpol := &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "check-vulnerabilities", Annotations: map[string]string{severityLabel: "medium"}},
+	}
+	assert.NilError(t, cpolIndexer.Add(cpol))
+
+	info := newTestInfo("check-vulnerabilities", "")
+	info.Results[0].Rules = []kyverno.ViolatedRule{
+		{Name: "scan-critical", Type: "Validation", Check: "fail", Properties: map[string]string{
+			response.RulePropertySeverity: "critical",
+		}},
+		{Name: "scan-unscored", Type: "Validation", Check: "fail"},
+	}
+
+	// the severity computed from the attestations replaces the severity of the policy
+	req, err := builder.build(info)
+	assert.NilError(t, err)
+
+	results := getResults(t, req)
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0].(map[string]interface{})["severity"], "critical")
+	assert.Equal(t, results[1].(map[string]interface{})["severity"], "medium")
+}
+
+func Test_Build_Policy_Kind(t *testing.T) {
+	cpolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	polIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	builder := NewBuilder(kyvernolister.NewClusterPolicyLister(cpolIndexer), kyvernolister.NewPolicyLister(polIndexer))
+
+	assert.NilError(t, cpolIndexer.Add(&kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels", ResourceVersion: "10", Annotations: map[string]string{severityLabel: "low"}},
+	}))
+	assert.NilError(t, polIndexer.Add(&kyverno.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels", Namespace: "test", ResourceVersion: "20", Annotations: map[string]string{severityLabel: "high"}},
+	}))
+
+	// the results of the Policy carry its resourceVersion and namespace, and its annotations
+	info := newTestInfo("require-labels", "test")
+	info.PolicyNamespace = "test"
+	req, err := builder.build(info)
+	assert.NilError(t, err)
+
+	result := getResults(t, req)[0].(map[string]interface{})
+	data, _, err := unstructured.NestedStringMap(result, "data")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, map[string]string{resultDataPolicyResourceVersion: "20", resultDataPolicyNamespace: "test"})
+	assert.Equal(t, result["severity"], "high")
+
+	// the results of the ClusterPolicy with the same name in the namespace
+	req, err = builder.build(newTestInfo("require-labels", "test"))
+	assert.NilError(t, err)
+
+	result = getResults(t, req)[0].(map[string]interface{})
+	data, _, err = unstructured.NestedStringMap(result, "data")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, map[string]string{resultDataPolicyResourceVersion: "10"})
+	assert.Equal(t, result["severity"], "low")
+}
@@ -0,0 +1,210 @@
+package policyreport
+
+import (
+	"fmt"
+
+	changerequest "github.com/kyverno/kyverno/pkg/api/kyverno/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// checkpointLabel marks the change requests persisting the pending results of the result store,
+// they are owned by the leader and are not aggregated as the other change requests
+const checkpointLabel string = "kyverno.io/checkpoint"
+
+func checkpointName(key string) string {
+	if key == "" {
+		return "crcr-checkpoint"
+	}
+	return "rcr-checkpoint-" + key
+}
+
+func isCheckpoint(obj metav1.Object) bool {
+	return obj.GetLabels()[checkpointLabel] == "true"
+}
+
+// newCheckpoint merges the pending requests of a report into its checkpoint
+func newCheckpoint(key string, requests []*unstructured.Unstructured) *unstructured.Unstructured {
+	checkpoint := requests[0].DeepCopy()
+	for _, request := range requests[1:] {
+		merge(checkpoint, request.DeepCopy())
+	}
+
+	checkpoint.SetGenerateName("")
+	checkpoint.SetName(checkpointName(key))
+	checkpoint.SetLabels(map[string]string{
+		resourceLabelNamespace: key,
+		checkpointLabel:        "true",
+	})
+	return checkpoint
+}
+
+// checkpoint persists the pending requests of the result store, one checkpoint per report.
+// The checkpoints of the reports without pending requests are deleted: their results
+// were reconciled into the reports.
+// A request leaves the store only once reconciled, and a checkpoint is only replaced by the
+// pending requests, so the results are delivered at least once unless the leader crashes
+// before their first checkpoint, in which case the next background scan regenerates them.
+func (g *ReportGenerator) checkpoint() {
+	g.checkpointLock.Lock()
+	defer g.checkpointLock.Unlock()
+
+	pending := g.store.snapshot()
+	for key, requests := range pending {
+		if err := g.writeCheckpoint(newCheckpoint(key, requests)); err != nil {
+			g.log.Error(err, "failed to write checkpoint", "key", key)
+			continue
+		}
+		g.checkpoints[key] = true
+	}
+
+	for key := range g.checkpoints {
+		if _, ok := pending[key]; ok {
+			continue
+		}
+
+		kind, namespace := "ReportChangeRequest", config.KyvernoNamespace
+		if key == "" {
+			kind, namespace = "ClusterReportChangeRequest", ""
+		}
+
+		apiVersion := changerequest.SchemeGroupVersion.String()
+		if err := g.dclient.DeleteResource(apiVersion, kind, namespace, checkpointName(key), false); err != nil && !apierrors.IsNotFound(err) {
+			g.log.Error(err, "failed to delete checkpoint", "key", key)
+			continue
+		}
+		delete(g.checkpoints, key)
+	}
+}
+
+// writeCheckpoint creates or replaces the checkpoint
+func (g *ReportGenerator) writeCheckpoint(checkpoint *unstructured.Unstructured) error {
+	old, err := g.dclient.GetResource(checkpoint.GetAPIVersion(), checkpoint.GetKind(), checkpoint.GetNamespace(), checkpoint.GetName())
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get checkpoint %s: %v", checkpoint.GetName(), err)
+		}
+
+		if _, err := g.dclient.CreateResource(checkpoint.GetAPIVersion(), checkpoint.GetKind(), checkpoint.GetNamespace(), checkpoint, false); err != nil {
+			return fmt.Errorf("failed to create checkpoint %s: %v", checkpoint.GetName(), err)
+		}
+		return nil
+	}
+
+	checkpoint.SetResourceVersion(old.GetResourceVersion())
+	if _, err := g.dclient.UpdateResource(checkpoint.GetAPIVersion(), checkpoint.GetKind(), checkpoint.GetNamespace(), checkpoint, false); err != nil {
+		return fmt.Errorf("failed to update checkpoint %s: %v", checkpoint.GetName(), err)
+	}
+	return nil
+}
+
+// restoreCheckpoints adds the results of the checkpoints of a previous leader to the result store.
+// The results already reconciled into the reports, with a timestamp not older than the reported
+// result, are dropped: the previous leader may have crashed after reconciling them and before
+// replacing the checkpoint.
+func (g *ReportGenerator) restoreCheckpoints() error {
+	g.checkpointLock.Lock()
+	defer g.checkpointLock.Unlock()
+
+	selector := labels.SelectorFromSet(labels.Set{checkpointLabel: "true"})
+	var checkpoints []*unstructured.Unstructured
+
+	rcrs, err := g.reportChangeRequestLister.ReportChangeRequests(config.KyvernoNamespace).List(selector)
+	if err != nil {
+		return fmt.Errorf("unable to list reportChangeRequest checkpoints: %v", err)
+	}
+	for _, rcr := range rcrs {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rcr)
+		if err != nil {
+			return fmt.Errorf("unable to convert reportChangeRequest: %v", err)
+		}
+		checkpoint := &unstructured.Unstructured{Object: obj}
+		checkpoint.SetAPIVersion(changerequest.SchemeGroupVersion.String())
+		checkpoint.SetKind("ReportChangeRequest")
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	crcrs, err := g.clusterReportChangeRequestLister.List(selector)
+	if err != nil {
+		return fmt.Errorf("unable to list clusterReportChangeRequest checkpoints: %v", err)
+	}
+	for _, crcr := range crcrs {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crcr)
+		if err != nil {
+			return fmt.Errorf("unable to convert clusterReportChangeRequest: %v", err)
+		}
+		checkpoint := &unstructured.Unstructured{Object: obj}
+		checkpoint.SetAPIVersion(changerequest.SchemeGroupVersion.String())
+		checkpoint.SetKind("ClusterReportChangeRequest")
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	for _, checkpoint := range checkpoints {
+		key := storeKey(checkpoint)
+		g.checkpoints[key] = true
+
+		restored, err := g.dropReconciledResults(key, checkpoint)
+		if err != nil {
+			return err
+		}
+
+		if restored {
+			// the checkpoint itself is deleted by the next checkpoint once its results are reconciled
+			checkpoint.SetName("")
+			checkpoint.SetResourceVersion("")
+			checkpoint.SetLabels(map[string]string{resourceLabelNamespace: key})
+			g.store.restore(checkpoint)
+		}
+		g.log.V(2).Info("restored checkpoint", "name", checkpointName(key), "restored", restored)
+	}
+
+	return nil
+}
+
+// dropReconciledResults removes the results of the checkpoint which are reported with the same
+// or a newer timestamp, it returns false if no result is left
+func (g *ReportGenerator) dropReconciledResults(key string, checkpoint *unstructured.Unstructured) (bool, error) {
+	chunks, err := g.listReportChunks(key)
+	if err != nil {
+		return false, err
+	}
+
+	reported := make(map[string]int64)
+	for _, chunk := range chunks {
+		results, _ := chunk.Object["results"].([]interface{})
+		for _, result := range results {
+			resultMap, ok := result.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if hashKey, ok := generateHashKey(resultMap, deletedResource{}); ok {
+				reported[hashKey], _, _ = unstructured.NestedInt64(resultMap, "timestamp", "seconds")
+			}
+		}
+	}
+
+	results, _ := checkpoint.Object["results"].([]interface{})
+	kept := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		resultMap, ok := result.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if hashKey, ok := generateHashKey(resultMap, deletedResource{}); ok {
+			timestamp, _, _ := unstructured.NestedInt64(resultMap, "timestamp", "seconds")
+			if reportedTimestamp, ok := reported[hashKey]; ok && reportedTimestamp >= timestamp {
+				continue
+			}
+		}
+		kept = append(kept, result)
+	}
+
+	checkpoint.Object["results"] = kept
+	checkpoint.Object["summary"] = updateSummary(kept)
+	return len(kept) != 0, nil
+}
@@ -0,0 +1,255 @@
+package policyreport
+
+import (
+	"fmt"
+	"testing"
+
+	changerequest "github.com/kyverno/kyverno/pkg/api/kyverno/v1alpha1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	requestlister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1alpha1"
+	policyreportlister "github.com/kyverno/kyverno/pkg/client/listers/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/config"
+	client "github.com/kyverno/kyverno/pkg/dclient"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newCheckpointTestClient(t *testing.T) *client.Client {
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		{Group: "kyverno.io", Version: "v1alpha1", Resource: "reportchangerequests"}:        "ReportChangeRequestList",
+		{Group: "kyverno.io", Version: "v1alpha1", Resource: "clusterreportchangerequests"}: "ClusterReportChangeRequestList",
+		{Group: "wgpolicyk8s.io", Version: "v1alpha1", Resource: "policyreports"}:           "PolicyReportList",
+		{Group: "wgpolicyk8s.io", Version: "v1alpha1", Resource: "clusterpolicyreports"}:    "ClusterPolicyReportList",
+	}
+
+	var resources []schema.GroupVersionResource
+	for gvr := range gvrToListKind {
+		resources = append(resources, gvr)
+	}
+
+	c, err := client.NewMockClient(runtime.NewScheme(), gvrToListKind)
+	assert.NilError(t, err)
+	c.SetDiscovery(client.NewFakeDiscoveryClient(resources))
+	return c
+}
+
+// newCheckpointTestGenerator returns the report generator of a new leader, its listers are
+// synced with the objects of the client as the informers would be
+func newCheckpointTestGenerator(t *testing.T, c *client.Client) *ReportGenerator {
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, nsIndexer.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}))
+
+	g := &ReportGenerator{
+		dclient:     c,
+		nsLister:    listerv1.NewNamespaceLister(nsIndexer),
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), prWorkQueueName),
+		staleRules:  make(map[string]staleRuleCleanup),
+		store:       NewResultStore(),
+		checkpoints: make(map[string]bool),
+		log:         log.Log,
+	}
+
+	syncCheckpointTestListers(t, g, c)
+	return g
+}
+
+func syncCheckpointTestListers(t *testing.T, g *ReportGenerator, c *client.Client) {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	rcrIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+	crcrIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	reportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+	clusterReportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	add := func(indexer cache.Indexer, kind, namespace string, newObj func() interface{}) {
+		list, err := c.ListResource("", kind, namespace, nil)
+		assert.NilError(t, err)
+		for _, item := range list.Items {
+			obj := newObj()
+			assert.NilError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, obj))
+			assert.NilError(t, indexer.Add(obj))
+		}
+	}
+
+	add(rcrIndexer, "ReportChangeRequest", config.KyvernoNamespace, func() interface{} { return &changerequest.ReportChangeRequest{} })
+	add(crcrIndexer, "ClusterReportChangeRequest", "", func() interface{} { return &changerequest.ClusterReportChangeRequest{} })
+	add(reportIndexer, "PolicyReport", "test", func() interface{} { return &report.PolicyReport{} })
+	add(clusterReportIndexer, "ClusterPolicyReport", "", func() interface{} { return &report.ClusterPolicyReport{} })
+
+	g.reportChangeRequestLister = requestlister.NewReportChangeRequestLister(rcrIndexer)
+	g.clusterReportChangeRequestLister = requestlister.NewClusterReportChangeRequestLister(crcrIndexer)
+	g.reportLister = policyreportlister.NewPolicyReportLister(reportIndexer)
+	g.clusterReportLister = policyreportlister.NewClusterPolicyReportLister(clusterReportIndexer)
+}
+
+func newCheckpointTestRequest(t *testing.T, name string, status report.PolicyStatus, timestamp int64) *unstructured.Unstructured {
+	result := newStaleTestResult("require-labels", "check-labels", name, status, "1")
+	result.Timestamp = metav1.Timestamp{Seconds: timestamp}
+	results := []*report.PolicyReportResult{result}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&changerequest.ReportChangeRequest{
+		Summary: CalculateSummary(results),
+		Results: results,
+	})
+	assert.NilError(t, err)
+
+	req := &unstructured.Unstructured{Object: obj}
+	set(req, Info{Namespace: "test"})
+	return req
+}
+
+// startCheckpointTestStore starts the result store of the leader
+func startCheckpointTestStore(g *ReportGenerator) {
+	g.store.start(func(key string) { g.queue.Add(key) })
+}
+
+// syncCheckpointTestReport aggregates the report of the key queued by the result store
+func syncCheckpointTestReport(t *testing.T, g *ReportGenerator, c *client.Client) {
+	syncCheckpointTestListers(t, g, c)
+	key, _ := g.queue.Get()
+	defer g.queue.Done(key)
+	assert.Equal(t, key, "test")
+
+	_, err := g.syncHandler(key.(string))
+	assert.NilError(t, err)
+}
+
+// reportedResults returns the status@timestamp of the reported results by resource name
+func reportedResults(t *testing.T, c *client.Client) map[string]string {
+	polr, err := c.GetResource("", "PolicyReport", "test", generatePolicyReportChunkName("test", 0))
+	assert.NilError(t, err)
+
+	reported := make(map[string]string)
+	results, _, _ := unstructured.NestedSlice(polr.Object, "results")
+	for _, result := range results {
+		resultMap := result.(map[string]interface{})
+		resources := resultMap["resources"].([]interface{})
+		timestamp, _, _ := unstructured.NestedInt64(resultMap, "timestamp", "seconds")
+		reported[resources[0].(map[string]interface{})["name"].(string)] = fmt.Sprintf("%s@%d", resultMap["status"], timestamp)
+	}
+	return reported
+}
+
+func checkpointExists(t *testing.T, c *client.Client) bool {
+	_, err := c.GetResource("", "ReportChangeRequest", config.KyvernoNamespace, checkpointName("test"))
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+
+	assert.NilError(t, err)
+	return true
+}
+
+func Test_ResultStore_Inactive(t *testing.T) {
+	var nilStore *ResultStore
+	assert.Assert(t, !nilStore.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+
+	// the replicas which are not the leader create change requests
+	store := NewResultStore()
+	assert.Assert(t, !store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+
+	var queued []string
+	store.start(func(key string) { queued = append(queued, key) })
+	assert.Assert(t, store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+	assert.DeepEqual(t, queued, []string{"test"})
+
+	store.stop()
+	assert.Assert(t, !store.add(newCheckpointTestRequest(t, "pod-2", report.StatusFail, 1)))
+}
+
+func Test_ResultStore_Remove_Keeps_Later_Requests(t *testing.T) {
+	store := NewResultStore()
+	store.start(func(string) {})
+	store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1))
+
+	lastID, requests := store.pending("test")
+	assert.Equal(t, len(requests), 1)
+
+	// a request added while the report is reconciled is not removed with it
+	store.add(newCheckpointTestRequest(t, "pod-2", report.StatusFail, 1))
+	store.remove("test", lastID)
+
+	_, requests = store.pending("test")
+	assert.Equal(t, len(requests), 1)
+	assert.Equal(t, len(store.snapshot()["test"]), 1)
+}
+
+func Test_Checkpoint_Leader_Crash_Between_Checkpoints(t *testing.T) {
+	c := newCheckpointTestClient(t)
+
+	leader := newCheckpointTestGenerator(t, c)
+	startCheckpointTestStore(leader)
+	assert.Assert(t, leader.store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+	leader.checkpoint()
+	assert.Assert(t, checkpointExists(t, c))
+
+	// the leader crashes after pod-2 is admitted, before the next checkpoint and the aggregation
+	assert.Assert(t, leader.store.add(newCheckpointTestRequest(t, "pod-2", report.StatusFail, 2)))
+
+	next := newCheckpointTestGenerator(t, c)
+	assert.NilError(t, next.restoreCheckpoints())
+	startCheckpointTestStore(next)
+	syncCheckpointTestReport(t, next, c)
+
+	// the checkpointed result is recovered
+	assert.DeepEqual(t, reportedResults(t, c), map[string]string{"pod-1": "fail@1"})
+
+	// the result of pod-2 is regenerated by the next background scan
+	assert.Assert(t, next.store.add(newCheckpointTestRequest(t, "pod-2", report.StatusFail, 3)))
+	syncCheckpointTestReport(t, next, c)
+	assert.DeepEqual(t, reportedResults(t, c), map[string]string{"pod-1": "fail@1", "pod-2": "fail@3"})
+
+	// the results are reconciled, the checkpoint is deleted
+	next.checkpoint()
+	assert.Assert(t, !checkpointExists(t, c))
+}
+
+func Test_Checkpoint_Leader_Crash_After_Aggregation(t *testing.T) {
+	c := newCheckpointTestClient(t)
+
+	leader := newCheckpointTestGenerator(t, c)
+	startCheckpointTestStore(leader)
+	assert.Assert(t, leader.store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+	leader.checkpoint()
+
+	// pod-1 is fixed and the report is reconciled, the leader crashes before the next checkpoint
+	assert.Assert(t, leader.store.add(newCheckpointTestRequest(t, "pod-1", report.StatusPass, 2)))
+	syncCheckpointTestReport(t, leader, c)
+	assert.DeepEqual(t, reportedResults(t, c), map[string]string{"pod-1": "pass@2"})
+	assert.Assert(t, checkpointExists(t, c))
+
+	// the outdated checkpointed result does not override the reported result
+	next := newCheckpointTestGenerator(t, c)
+	assert.NilError(t, next.restoreCheckpoints())
+	assert.Equal(t, len(next.store.snapshot()), 0)
+	startCheckpointTestStore(next)
+	assert.Equal(t, next.queue.Len(), 0)
+	assert.DeepEqual(t, reportedResults(t, c), map[string]string{"pod-1": "pass@2"})
+
+	next.checkpoint()
+	assert.Assert(t, !checkpointExists(t, c))
+}
+
+func Test_Aggregate_Skips_Checkpoints(t *testing.T) {
+	c := newCheckpointTestClient(t)
+
+	leader := newCheckpointTestGenerator(t, c)
+	startCheckpointTestStore(leader)
+	assert.Assert(t, leader.store.add(newCheckpointTestRequest(t, "pod-1", report.StatusFail, 1)))
+	leader.checkpoint()
+	syncCheckpointTestReport(t, leader, c)
+
+	// the checkpoint is replaced by the next checkpoint, not deleted by the aggregation
+	assert.Assert(t, checkpointExists(t, c))
+	assert.Equal(t, len(leader.store.snapshot()), 0)
+	leader.checkpoint()
+	assert.Assert(t, !checkpointExists(t, c))
+}
@@ -0,0 +1,147 @@
+package policyreport
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// generatePolicyReportChunkName returns the name of a report chunk.
+// The first chunk keeps the name of the unchunked report, following chunks are suffixed with their index,
+// i.e. polr-ns-<namespace>-<index> and clusterpolicyreport-<index>
+func generatePolicyReportChunkName(ns string, index int) string {
+	name := generatePolicyReportName(ns)
+	if index == 0 {
+		return name
+	}
+
+	suffix := fmt.Sprintf("-%d", index)
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+
+	return name + suffix
+}
+
+// policyReportChunkIndex returns the chunk index of a report,
+// it returns false if the report is not a chunk of the namespace / cluster report
+func policyReportChunkIndex(ns, name string) (int, bool) {
+	if name == generatePolicyReportName(ns) {
+		return 0, true
+	}
+
+	i := strings.LastIndex(name, "-")
+	if i == -1 {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(name[i+1:])
+	if err != nil || index < 1 {
+		return 0, false
+	}
+
+	if generatePolicyReportChunkName(ns, index) != name {
+		return 0, false
+	}
+
+	return index, true
+}
+
+// IsGeneratedReport returns true if the report of the namespace, or the cluster report if the
+// namespace is empty, is generated by Kyverno, i.e. it is the report or one of its chunks
+func IsGeneratedReport(ns, name string) bool {
+	_, ok := policyReportChunkIndex(ns, name)
+	return ok
+}
+
+// pruneResults removes pass results found before the retention period.
+// Results with other statuses and results without a timestamp are kept.
+// A retention of 0 keeps all results.
+func pruneResults(results []interface{}, retention time.Duration, now time.Time) []interface{} {
+	if retention <= 0 {
+		return results
+	}
+
+	deadline := now.Add(-retention).Unix()
+	pruned := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		if isExpiredPassResult(result, deadline) {
+			continue
+		}
+		pruned = append(pruned, result)
+	}
+
+	return pruned
+}
+
+func isExpiredPassResult(result interface{}, deadline int64) bool {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok || resultMap["status"] != report.StatusPass {
+		return false
+	}
+
+	seconds, found, err := unstructured.NestedInt64(resultMap, "timestamp", "seconds")
+	if err != nil || !found || seconds == 0 {
+		return false
+	}
+
+	return seconds < deadline
+}
+
+// splitResults sorts the results and splits them into chunks of at most limit results,
+// so that a result stays in the same chunk as long as the results before it are unchanged.
+// A limit of 0 disables chunking. At least one chunk is returned.
+func splitResults(results []interface{}, limit int) [][]interface{} {
+	type keyedResult struct {
+		key    string
+		result interface{}
+	}
+
+	keyed := make([]keyedResult, len(results))
+	for i, result := range results {
+		keyed[i] = keyedResult{key: resultKey(result), result: result}
+	}
+
+	sort.SliceStable(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+
+	sorted := make([]interface{}, len(keyed))
+	for i := range keyed {
+		sorted[i] = keyed[i].result
+	}
+
+	if limit <= 0 || len(sorted) <= limit {
+		return [][]interface{}{sorted}
+	}
+
+	chunks := make([][]interface{}, 0, (len(sorted)+limit-1)/limit)
+	for start := 0; start < len(sorted); start += limit {
+		end := start + limit
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		chunks = append(chunks, sorted[start:end])
+	}
+
+	return chunks
+}
+
+func resultKey(result interface{}) string {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	if resources, ok := resultMap["resources"].([]interface{}); !ok || len(resources) == 0 {
+		return fmt.Sprintf("%s-%s", resultMap["policy"], resultMap["rule"])
+	}
+
+	key, _ := generateHashKey(resultMap, deletedResource{})
+	return key
+}
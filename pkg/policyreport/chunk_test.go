@@ -0,0 +1,174 @@
+package policyreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// etcdObjectSizeLimit is the default maximum size of an object stored in etcd
+const etcdObjectSizeLimit = 1536 * 1024
+
+func newTestResult(t *testing.T, policy, rule, name string, status report.PolicyStatus, timestamp int64) interface{} {
+	result := &report.PolicyReportResult{
+		Policy:  policy,
+		Rule:    rule,
+		Message: "validation error: label app.kubernetes.io/name is required. Rule check-for-labels failed at path /metadata/labels/",
+		Resources: []*v1.ObjectReference{
+			{APIVersion: "v1", Kind: "Pod", Namespace: "test", Name: name},
+		},
+		Status:    status,
+		Scored:    true,
+		Timestamp: metav1.Timestamp{Seconds: timestamp},
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(result)
+	assert.NilError(t, err)
+	return obj
+}
+
+func newTestResults(t *testing.T, count int) []interface{} {
+	results := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		status := report.PolicyStatus(report.StatusPass)
+		if i%3 == 0 {
+			status = report.StatusFail
+		}
+		results = append(results, newTestResult(t, "require-labels", "check-for-labels", fmt.Sprintf("pod-%d", i), status, 1))
+	}
+	return results
+}
+
+func Test_Split_Results(t *testing.T) {
+	total := 50000
+	limit := 1000
+	chunks := splitResults(newTestResults(t, total), limit)
+	assert.Equal(t, len(chunks), total/limit)
+
+	var count, pass, fail int64
+	for index, chunk := range chunks {
+		assert.Assert(t, len(chunk) <= limit)
+
+		polr := map[string]interface{}{
+			"apiVersion": report.SchemeGroupVersion.String(),
+			"kind":       "PolicyReport",
+			"metadata": map[string]interface{}{
+				"name":      generatePolicyReportChunkName("test", index),
+				"namespace": "test",
+			},
+			"results": chunk,
+			"summary": updateSummary(chunk),
+		}
+
+		raw, err := json.Marshal(polr)
+		assert.NilError(t, err)
+		assert.Assert(t, len(raw) < etcdObjectSizeLimit, "chunk %d has %d bytes", index, len(raw))
+
+		summary := polr["summary"].(map[string]interface{})
+		pass += summary[report.StatusPass].(int64)
+		fail += summary[report.StatusFail].(int64)
+		count += int64(len(chunk))
+	}
+
+	assert.Equal(t, count, int64(total))
+	assert.Equal(t, pass+fail, int64(total))
+	assert.Equal(t, fail, int64((total+2)/3))
+}
+
+func Test_Split_Results_Without_Limit(t *testing.T) {
+	chunks := splitResults(newTestResults(t, 10), 0)
+	assert.Equal(t, len(chunks), 1)
+	assert.Equal(t, len(chunks[0]), 10)
+
+	chunks = splitResults(nil, 5)
+	assert.Equal(t, len(chunks), 1)
+	assert.Equal(t, len(chunks[0]), 0)
+}
+
+func Test_Rescan_Replaces_Results_In_Chunks(t *testing.T) {
+	total := 5000
+	chunks := splitResults(newTestResults(t, total), 1000)
+
+	oldResults := []interface{}{}
+	for _, chunk := range chunks {
+		oldResults = append(oldResults, chunk...)
+	}
+
+	// re-scan a resource whose result is stored in a later chunk
+	rescanned := chunks[3][10].(map[string]interface{})
+	name := rescanned["resources"].([]interface{})[0].(map[string]interface{})["name"].(string)
+	newReport := map[string]interface{}{
+		"results": []interface{}{newTestResult(t, "require-labels", "check-for-labels", name, report.StatusError, 2)},
+	}
+
+	obj, _, err := updateResults(map[string]interface{}{"results": oldResults}, newReport, nil)
+	assert.NilError(t, err)
+
+	results := obj["results"].([]interface{})
+	assert.Equal(t, len(results), total)
+
+	chunks = splitResults(results, 1000)
+	assert.Equal(t, len(chunks), 5)
+
+	var errors int64
+	for _, chunk := range chunks {
+		errors += updateSummary(chunk)[report.StatusError].(int64)
+	}
+	assert.Equal(t, errors, int64(1))
+	assert.Equal(t, chunks[3][10].(map[string]interface{})["status"], report.StatusError)
+}
+
+func Test_Prune_Results(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour).Unix()
+	recent := now.Add(-time.Hour).Unix()
+
+	results := []interface{}{
+		newTestResult(t, "p", "r", "old-pass", report.StatusPass, old),
+		newTestResult(t, "p", "r", "old-fail", report.StatusFail, old),
+		newTestResult(t, "p", "r", "old-error", report.StatusError, old),
+		newTestResult(t, "p", "r", "recent-pass", report.StatusPass, recent),
+		newTestResult(t, "p", "r", "unknown-pass", report.StatusPass, 0),
+	}
+
+	assert.Equal(t, len(pruneResults(results, 0, now)), 5)
+
+	pruned := pruneResults(results, 24*time.Hour, now)
+	assert.Equal(t, len(pruned), 4)
+	for _, result := range pruned {
+		name := result.(map[string]interface{})["resources"].([]interface{})[0].(map[string]interface{})["name"]
+		assert.Assert(t, name != "old-pass")
+	}
+}
+
+func Test_Policy_Report_Chunk_Name(t *testing.T) {
+	assert.Equal(t, generatePolicyReportChunkName("test", 0), "polr-ns-test")
+	assert.Equal(t, generatePolicyReportChunkName("test", 2), "polr-ns-test-2")
+	assert.Equal(t, generatePolicyReportChunkName("", 1), "clusterpolicyreport-1")
+
+	long := "a-very-long-namespace-name-that-exceeds-the-report-name-limit"
+	name := generatePolicyReportChunkName(long, 12)
+	assert.Equal(t, len(name), 63)
+
+	for _, ns := range []string{"test", "", long} {
+		for _, index := range []int{0, 1, 12} {
+			i, ok := policyReportChunkIndex(ns, generatePolicyReportChunkName(ns, index))
+			assert.Assert(t, ok)
+			assert.Equal(t, i, index)
+		}
+	}
+
+	_, ok := policyReportChunkIndex("test", "polr-ns-test-0")
+	assert.Assert(t, !ok)
+	_, ok = policyReportChunkIndex("test", "custom-report")
+	assert.Assert(t, !ok)
+	_, ok = policyReportChunkIndex("test", "polr-ns-other-1")
+	assert.Assert(t, !ok)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/cornelk/hashmap"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	changerequest "github.com/kyverno/kyverno/pkg/api/kyverno/v1alpha1"
 	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
 	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
@@ -17,6 +18,8 @@ import (
 type PolicyReportEraser interface {
 	CleanupReportChangeRequests(cleanup CleanupReportChangeRequests) error
 	EraseResultsEntries(erase EraseResultsEntries) error
+	CleanupStaleRules(policy *kyverno.ClusterPolicy)
+	ResumeStaleRules(policy *kyverno.ClusterPolicy)
 }
 
 type CleanupReportChangeRequests = func(pclient *kyvernoclient.Clientset, rcrLister changerequestlister.ReportChangeRequestLister, crcrLister changerequestlister.ClusterReportChangeRequestLister) error
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -65,6 +66,31 @@ type ReportGenerator struct {
 
 	queue workqueue.RateLimitingInterface
 
+	// maxReportResults is the maximum number of results in a single report,
+	// reports with more results are split into chunks. 0 disables chunking.
+	maxReportResults int
+
+	// passResultRetention is the period after which pass results are removed from reports.
+	// 0 keeps pass results.
+	passResultRetention time.Duration
+
+	// staleRules stores pending stale rule cleanups by policy key, and resumedStaleRules the cleanups
+	// of the added policies enqueued only if the reports hold stale results
+	staleRules        map[string]staleRuleCleanup
+	resumedStaleRules map[string]staleRuleCleanup
+	staleRulesLock    sync.Mutex
+
+	// store buffers the results of the leader in memory, they are aggregated with the
+	// change requests created by the other replicas. nil disables the store.
+	store *ResultStore
+
+	// checkpointInterval is the interval of the checkpoints of the pending results of the store
+	checkpointInterval time.Duration
+
+	// checkpoints stores the report keys with a checkpoint
+	checkpoints    map[string]bool
+	checkpointLock sync.Mutex
+
 	// ReconcileCh sends a signal to policy controller to force the reconciliation of policy report
 	// if send true, the reports' results will be erased, this is used to recover from the invalid records
 	ReconcileCh chan bool
@@ -82,6 +108,10 @@ func NewReportGenerator(
 	reportReqInformer requestinformer.ReportChangeRequestInformer,
 	clusterReportReqInformer requestinformer.ClusterReportChangeRequestInformer,
 	namespace informers.NamespaceInformer,
+	maxReportResults int,
+	passResultRetention time.Duration,
+	store *ResultStore,
+	checkpointInterval time.Duration,
 	log logr.Logger) (*ReportGenerator, error) {
 
 	gen := &ReportGenerator{
@@ -92,6 +122,13 @@ func NewReportGenerator(
 		reportReqInformer:        reportReqInformer,
 		clusterReportReqInformer: clusterReportReqInformer,
 		queue:                    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), prWorkQueueName),
+		maxReportResults:         maxReportResults,
+		passResultRetention:      passResultRetention,
+		staleRules:               make(map[string]staleRuleCleanup),
+		resumedStaleRules:        make(map[string]staleRuleCleanup),
+		store:                    store,
+		checkpointInterval:       checkpointInterval,
+		checkpoints:              make(map[string]bool),
 		ReconcileCh:              make(chan bool, 10),
 		log:                      log,
 	}
@@ -116,6 +153,7 @@ const deletedPolicyKey string = "deletedpolicy"
 // - <namespace name> for the resource
 // - "" for cluster wide resource
 // - "deletedpolicy/policyName/ruleName(optional)" for a deleted policy or rule
+// - "stalerules/policyKey" for the results of removed or renamed rules
 func generateCacheKey(changeRequest interface{}) string {
 	if request, ok := changeRequest.(*changerequest.ReportChangeRequest); ok {
 		label := request.GetLabels()
@@ -144,6 +182,10 @@ func generateCacheKey(changeRequest interface{}) string {
 }
 
 func (g *ReportGenerator) addReportChangeRequest(obj interface{}) {
+	if isCheckpoint(obj.(*changerequest.ReportChangeRequest)) {
+		return
+	}
+
 	key := generateCacheKey(obj)
 	g.queue.Add(key)
 }
@@ -151,7 +193,7 @@ func (g *ReportGenerator) addReportChangeRequest(obj interface{}) {
 func (g *ReportGenerator) updateReportChangeRequest(old interface{}, cur interface{}) {
 	oldReq := old.(*changerequest.ReportChangeRequest)
 	curReq := cur.(*changerequest.ReportChangeRequest)
-	if reflect.DeepEqual(oldReq.Results, curReq.Results) {
+	if isCheckpoint(curReq) || reflect.DeepEqual(oldReq.Results, curReq.Results) {
 		return
 	}
 
@@ -160,6 +202,10 @@ func (g *ReportGenerator) updateReportChangeRequest(old interface{}, cur interfa
 }
 
 func (g *ReportGenerator) addClusterReportChangeRequest(obj interface{}) {
+	if isCheckpoint(obj.(*changerequest.ClusterReportChangeRequest)) {
+		return
+	}
+
 	key := generateCacheKey(obj)
 	g.queue.Add(key)
 }
@@ -168,7 +214,7 @@ func (g *ReportGenerator) updateClusterReportChangeRequest(old interface{}, cur
 	oldReq := old.(*changerequest.ClusterReportChangeRequest)
 	curReq := cur.(*changerequest.ClusterReportChangeRequest)
 
-	if reflect.DeepEqual(oldReq.Results, curReq.Results) {
+	if isCheckpoint(curReq) || reflect.DeepEqual(oldReq.Results, curReq.Results) {
 		return
 	}
 
@@ -221,11 +267,28 @@ func (g *ReportGenerator) Run(workers int, stopCh <-chan struct{}) {
 			DeleteFunc: g.deleteClusterPolicyReport,
 		})
 
+	if g.store != nil {
+		if err := g.restoreCheckpoints(); err != nil {
+			logger.Error(err, "failed to restore checkpoints")
+		}
+
+		g.store.start(func(key string) { g.queue.Add(key) })
+		if g.checkpointInterval > 0 {
+			go wait.Until(g.checkpoint, g.checkpointInterval, stopCh)
+		}
+	}
+
 	for i := 0; i < workers; i++ {
 		go wait.Until(g.runWorker, time.Second, stopCh)
 	}
 
 	<-stopCh
+
+	if g.store != nil {
+		// the results added from now on are created as change requests
+		g.store.stop()
+		g.checkpoint()
+	}
 }
 
 func (g *ReportGenerator) runWorker() {
@@ -284,88 +347,30 @@ func (g *ReportGenerator) syncHandler(key string) (aggregatedRequests interface{
 		return g.removePolicyEntryFromReport(policy, rule)
 	}
 
-	namespace := key
-	new, aggregatedRequests, err := g.aggregateReports(namespace)
-	if err != nil {
-		return aggregatedRequests, fmt.Errorf("failed to aggregate reportChangeRequest results %v", err)
+	if key == staleRulesScanKey {
+		return nil, g.findStaleRules()
 	}
 
-	var old interface{}
-	if old, err = g.createReportIfNotPresent(namespace, new, aggregatedRequests); err != nil {
-		return aggregatedRequests, err
+	if policyKey, ok := isStaleRulesKey(key); ok {
+		return nil, g.removeStaleRuleResults(policyKey)
 	}
-	if old == nil {
-		g.cleanupReportRequests(aggregatedRequests)
-		return nil, nil
+
+	namespace := key
+	lastID, stored := g.store.pending(namespace)
+	new, aggregatedRequests, err := g.aggregateReports(namespace, stored)
+	if err != nil {
+		return aggregatedRequests, fmt.Errorf("failed to aggregate reportChangeRequest results %v", err)
 	}
 
-	if err := g.updateReport(old, new, aggregatedRequests); err != nil {
+	if err := g.reconcileReportChunks(namespace, new, aggregatedRequests); err != nil {
 		return aggregatedRequests, err
 	}
 
 	g.cleanupReportRequests(aggregatedRequests)
+	g.store.remove(namespace, lastID)
 	return nil, nil
 }
 
-// createReportIfNotPresent creates cluster / policyReport if not present
-// return the existing report if exist
-func (g *ReportGenerator) createReportIfNotPresent(namespace string, new *unstructured.Unstructured, aggregatedRequests interface{}) (report interface{}, err error) {
-	log := g.log.WithName("createReportIfNotPresent")
-	obj, hasDuplicate, err := updateResults(new.UnstructuredContent(), new.UnstructuredContent(), nil)
-	if hasDuplicate && err != nil {
-		g.log.Error(err, "failed to remove duplicate results", "policy report", new.GetName())
-	} else {
-		new.Object = obj
-	}
-
-	if namespace != "" {
-		ns, err := g.nsLister.Get(namespace)
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-				return nil, nil
-			}
-			return nil, fmt.Errorf("failed to fetch namespace: %v", err)
-		}
-
-		if ns.GetDeletionTimestamp() != nil {
-			return nil, nil
-		}
-
-		report, err = g.reportLister.PolicyReports(namespace).Get(generatePolicyReportName((namespace)))
-		if err != nil {
-			if apierrors.IsNotFound(err) && new != nil {
-				if _, err := g.dclient.CreateResource(new.GetAPIVersion(), new.GetKind(), new.GetNamespace(), new, false); err != nil {
-					return nil, fmt.Errorf("failed to create policyReport: %v", err)
-				}
-
-				log.V(2).Info("successfully created policyReport", "namespace", new.GetNamespace(), "name", new.GetName())
-				g.cleanupReportRequests(aggregatedRequests)
-				return nil, nil
-			}
-
-			return nil, fmt.Errorf("unable to get policyReport: %v", err)
-		}
-	} else {
-		report, err = g.clusterReportLister.Get(generatePolicyReportName((namespace)))
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-				if new != nil {
-					if _, err := g.dclient.CreateResource(new.GetAPIVersion(), new.GetKind(), new.GetNamespace(), new, false); err != nil {
-						return nil, fmt.Errorf("failed to create ClusterPolicyReport: %v", err)
-					}
-
-					log.V(2).Info("successfully created ClusterPolicyReport")
-					g.cleanupReportRequests(aggregatedRequests)
-					return nil, nil
-				}
-				return nil, nil
-			}
-			return nil, fmt.Errorf("unable to get ClusterPolicyReport: %v", err)
-		}
-	}
-	return report, nil
-}
-
 func (g *ReportGenerator) removePolicyEntryFromReport(policyName, ruleName string) (aggregatedRequests interface{}, err error) {
 	if err := g.removeFromClusterPolicyReport(policyName, ruleName); err != nil {
 		return nil, err
@@ -408,7 +413,7 @@ func (g *ReportGenerator) removeFromClusterPolicyReport(policyName, ruleName str
 			newRes = append(newRes, result)
 		}
 		cpolr.Results = newRes
-		cpolr.Summary = calculateSummary(newRes)
+		cpolr.Summary = CalculateSummary(newRes)
 		gv := report.SchemeGroupVersion
 		cpolr.SetGroupVersionKind(schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: "ClusterPolicyReport"})
 		if _, err := g.dclient.UpdateResource("", "ClusterPolicyReport", "", cpolr, false); err != nil {
@@ -445,7 +450,7 @@ func (g *ReportGenerator) removeFromPolicyReport(policyName, ruleName string) er
 		}
 
 		r.Results = newRes
-		r.Summary = calculateSummary(newRes)
+		r.Summary = CalculateSummary(newRes)
 		gv := report.SchemeGroupVersion
 		gvk := schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: "PolicyReport"}
 		r.SetGroupVersionKind(gvk)
@@ -456,16 +461,32 @@ func (g *ReportGenerator) removeFromPolicyReport(policyName, ruleName string) er
 	return nil
 }
 
-// aggregateReports aggregates cluster / report change requests to a policy report
-func (g *ReportGenerator) aggregateReports(namespace string) (
+// aggregateReports aggregates cluster / report change requests, and the requests of the result store,
+// to a policy report. The requests of the store are aggregated last, they are the most recent.
+func (g *ReportGenerator) aggregateReports(namespace string, stored []*unstructured.Unstructured) (
 	report *unstructured.Unstructured, aggregatedRequests interface{}, err error) {
 
 	if namespace == "" {
-		requests, err := g.clusterReportChangeRequestLister.List(labels.Everything())
+		listed, err := g.clusterReportChangeRequestLister.List(labels.Everything())
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to list ClusterReportChangeRequests within: %v", err)
 		}
 
+		requests := []*changerequest.ClusterReportChangeRequest{}
+		for _, request := range listed {
+			if !isCheckpoint(request) {
+				requests = append(requests, request)
+			}
+		}
+
+		for _, obj := range stored {
+			request := &changerequest.ClusterReportChangeRequest{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, request); err != nil {
+				return nil, nil, fmt.Errorf("unable to convert stored clusterReportChangeRequest: %v", err)
+			}
+			requests = append(requests, request)
+		}
+
 		if report, aggregatedRequests, err = mergeRequests(nil, requests); err != nil {
 			return nil, nil, fmt.Errorf("unable to merge ClusterReportChangeRequests results: %v", err)
 		}
@@ -483,11 +504,26 @@ func (g *ReportGenerator) aggregateReports(namespace string) (
 		}
 
 		selector := labels.SelectorFromSet(labels.Set(map[string]string{resourceLabelNamespace: namespace}))
-		requests, err := g.reportChangeRequestLister.ReportChangeRequests(config.KyvernoNamespace).List(selector)
+		listed, err := g.reportChangeRequestLister.ReportChangeRequests(config.KyvernoNamespace).List(selector)
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to list reportChangeRequests within namespace %s: %v", ns, err)
 		}
 
+		requests := []*changerequest.ReportChangeRequest{}
+		for _, request := range listed {
+			if !isCheckpoint(request) {
+				requests = append(requests, request)
+			}
+		}
+
+		for _, obj := range stored {
+			request := &changerequest.ReportChangeRequest{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, request); err != nil {
+				return nil, nil, fmt.Errorf("unable to convert stored reportChangeRequest: %v", err)
+			}
+			requests = append(requests, request)
+		}
+
 		if report, aggregatedRequests, err = mergeRequests(ns, requests); err != nil {
 			return nil, nil, fmt.Errorf("unable to merge results: %v", err)
 		}
@@ -513,7 +549,7 @@ func mergeRequests(ns *v1.Namespace, requestsGeneral interface{}) (*unstructured
 
 		report := &report.ClusterPolicyReport{
 			Results: results,
-			Summary: calculateSummary(results),
+			Summary: CalculateSummary(results),
 		}
 
 		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(report)
@@ -540,7 +576,7 @@ func mergeRequests(ns *v1.Namespace, requestsGeneral interface{}) (*unstructured
 
 		report := &report.PolicyReport{
 			Results: results,
-			Summary: calculateSummary(results),
+			Summary: CalculateSummary(results),
 		}
 
 		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(report)
@@ -585,60 +621,175 @@ func setReport(reportUnstructured *unstructured.Unstructured, ns *v1.Namespace)
 	})
 }
 
-func (g *ReportGenerator) updateReport(old interface{}, new *unstructured.Unstructured, aggregatedRequests interface{}) (err error) {
+// reconcileReportChunks merges the aggregated results into the existing reports of the namespace,
+// or of the cluster if the namespace is empty. Results are replaced in whichever chunk they were stored,
+// and are split into chunks of at most maxReportResults results.
+func (g *ReportGenerator) reconcileReportChunks(namespace string, new *unstructured.Unstructured, aggregatedRequests interface{}) error {
 	if new == nil {
 		g.log.V(4).Info("empty report to update")
 		return nil
 	}
 
-	oldUnstructured := make(map[string]interface{})
+	if namespace != "" {
+		ns, err := g.nsLister.Get(namespace)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch namespace: %v", err)
+		}
 
-	if oldTyped, ok := old.(*report.ClusterPolicyReport); ok {
-		if oldTyped.GetDeletionTimestamp() != nil {
-			return g.dclient.DeleteResource(oldTyped.APIVersion, "ClusterPolicyReport", oldTyped.Namespace, oldTyped.Name, false)
+		if ns.GetDeletionTimestamp() != nil {
+			return nil
 		}
+	}
+
+	olds, err := g.listReportChunks(namespace)
+	if err != nil {
+		return err
+	}
 
-		if oldUnstructured, err = runtime.DefaultUnstructuredConverter.ToUnstructured(oldTyped); err != nil {
-			return fmt.Errorf("unable to convert clusterPolicyReport: %v", err)
+	oldResults := []interface{}{}
+	for _, old := range olds {
+		if old.GetDeletionTimestamp() != nil {
+			continue
 		}
-		new.SetUID(oldTyped.GetUID())
-		new.SetResourceVersion(oldTyped.GetResourceVersion())
-	} else if oldTyped, ok := old.(*report.PolicyReport); ok {
-		if oldTyped.GetDeletionTimestamp() != nil {
-			return g.dclient.DeleteResource(oldTyped.APIVersion, "PolicyReport", oldTyped.Namespace, oldTyped.Name, false)
+
+		if results, ok := old.Object["results"].([]interface{}); ok {
+			oldResults = append(oldResults, results...)
+		}
+	}
+
+	obj, _, err := updateResults(map[string]interface{}{"results": oldResults}, new.UnstructuredContent(), aggregatedRequests)
+	if err != nil {
+		return fmt.Errorf("failed to update results entry: %v", err)
+	}
+
+	results, _ := obj["results"].([]interface{})
+	results = pruneResults(results, g.passResultRetention, time.Now())
+	chunks := splitResults(results, g.maxReportResults)
+
+	unstructured.RemoveNestedField(new.Object, "results")
+	unstructured.RemoveNestedField(new.Object, "summary")
+	for index, chunk := range chunks {
+		desired := new.DeepCopy()
+		desired.SetName(generatePolicyReportChunkName(namespace, index))
+		desired.Object["results"] = chunk
+		desired.Object["summary"] = updateSummary(chunk)
+
+		if err := g.applyReportChunk(olds[desired.GetName()], desired); err != nil {
+			return err
+		}
+	}
+
+	for name, old := range olds {
+		if index, _ := policyReportChunkIndex(namespace, name); index < len(chunks) {
+			continue
+		}
+
+		if err := g.dclient.DeleteResource(old.GetAPIVersion(), old.GetKind(), old.GetNamespace(), old.GetName(), false); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete policy report chunk %s: %v", name, err)
+		}
+
+		g.log.V(3).Info("successfully deleted policy report chunk", "kind", old.GetKind(), "namespace", old.GetNamespace(), "name", name)
+	}
+
+	return nil
+}
+
+// listReportChunks returns the existing report chunks of the namespace, or of the cluster
+// if the namespace is empty, keyed by name
+func (g *ReportGenerator) listReportChunks(namespace string) (map[string]*unstructured.Unstructured, error) {
+	chunks := make(map[string]*unstructured.Unstructured)
+
+	if namespace == "" {
+		cpolrs, err := g.clusterReportLister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list ClusterPolicyReport: %v", err)
 		}
 
-		if oldUnstructured, err = runtime.DefaultUnstructuredConverter.ToUnstructured(oldTyped); err != nil {
-			return fmt.Errorf("unable to convert policyReport: %v", err)
+		for _, cpolr := range cpolrs {
+			if _, ok := policyReportChunkIndex(namespace, cpolr.GetName()); !ok {
+				continue
+			}
+
+			obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cpolr)
+			if err != nil {
+				return nil, fmt.Errorf("unable to convert clusterPolicyReport: %v", err)
+			}
+
+			chunk := &unstructured.Unstructured{Object: obj}
+			chunk.SetAPIVersion(report.SchemeGroupVersion.String())
+			chunk.SetKind("ClusterPolicyReport")
+			chunks[chunk.GetName()] = chunk
 		}
 
-		new.SetUID(oldTyped.GetUID())
-		new.SetResourceVersion(oldTyped.GetResourceVersion())
+		return chunks, nil
 	}
 
-	obj, _, err := updateResults(oldUnstructured, new.UnstructuredContent(), aggregatedRequests)
+	polrs, err := g.reportLister.PolicyReports(namespace).List(labels.Everything())
 	if err != nil {
-		return fmt.Errorf("failed to update results entry: %v", err)
+		return nil, fmt.Errorf("unable to list policyReport: %v", err)
 	}
-	new.Object = obj
 
-	if !hasResultsChanged(oldUnstructured, new.UnstructuredContent()) {
+	for _, polr := range polrs {
+		if _, ok := policyReportChunkIndex(namespace, polr.GetName()); !ok {
+			continue
+		}
+
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(polr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert policyReport: %v", err)
+		}
+
+		chunk := &unstructured.Unstructured{Object: obj}
+		chunk.SetAPIVersion(report.SchemeGroupVersion.String())
+		chunk.SetKind("PolicyReport")
+		chunks[chunk.GetName()] = chunk
+	}
+
+	return chunks, nil
+}
+
+// applyReportChunk creates the report chunk if it does not exist, or updates it if its results changed
+func (g *ReportGenerator) applyReportChunk(old, new *unstructured.Unstructured) error {
+	if old == nil {
+		if _, err := g.dclient.CreateResource(new.GetAPIVersion(), new.GetKind(), new.GetNamespace(), new, false); err != nil {
+			return fmt.Errorf("failed to create %s: %v", new.GetKind(), err)
+		}
+
+		g.log.V(2).Info("successfully created policy report", "kind", new.GetKind(), "namespace", new.GetNamespace(), "name", new.GetName())
+		return nil
+	}
+
+	if old.GetDeletionTimestamp() != nil {
+		return g.dclient.DeleteResource(old.GetAPIVersion(), old.GetKind(), old.GetNamespace(), old.GetName(), false)
+	}
+
+	if !hasResultsChanged(old.UnstructuredContent(), new.UnstructuredContent()) {
 		g.log.V(4).Info("unchanged policy report", "kind", new.GetKind(), "namespace", new.GetNamespace(), "name", new.GetName())
 		return nil
 	}
 
-	if _, err = g.dclient.UpdateResource(new.GetAPIVersion(), new.GetKind(), new.GetNamespace(), new, false); err != nil {
+	new.SetUID(old.GetUID())
+	new.SetResourceVersion(old.GetResourceVersion())
+	if _, err := g.dclient.UpdateResource(new.GetAPIVersion(), new.GetKind(), new.GetNamespace(), new, false); err != nil {
 		return fmt.Errorf("failed to update policy report: %v", err)
 	}
 
 	g.log.V(3).Info("successfully updated policy report", "kind", new.GetKind(), "namespace", new.GetNamespace(), "name", new.GetName())
-	return
+	return nil
 }
 
+// cleanupReportRequests deletes the aggregated change requests, the requests of the result store
+// have no name and are removed from the store instead
 func (g *ReportGenerator) cleanupReportRequests(requestsGeneral interface{}) {
 	defer g.log.V(5).Info("successfully cleaned up report requests")
 	if requests, ok := requestsGeneral.([]*changerequest.ReportChangeRequest); ok {
 		for _, request := range requests {
+			if request.Name == "" {
+				continue
+			}
 			if err := g.dclient.DeleteResource(request.APIVersion, "ReportChangeRequest", config.KyvernoNamespace, request.Name, false); err != nil {
 				if !apierrors.IsNotFound(err) {
 					g.log.Error(err, "failed to delete report request")
@@ -649,6 +800,9 @@ func (g *ReportGenerator) cleanupReportRequests(requestsGeneral interface{}) {
 
 	if requests, ok := requestsGeneral.([]*changerequest.ClusterReportChangeRequest); ok {
 		for _, request := range requests {
+			if request.Name == "" {
+				continue
+			}
 			if err := g.dclient.DeleteResource(request.APIVersion, "ClusterReportChangeRequest", "", request.Name, false); err != nil {
 				if !apierrors.IsNotFound(err) {
 					g.log.Error(err, "failed to delete clusterReportChangeRequest")
@@ -17,6 +17,8 @@ import (
 	requestlister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1alpha1"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/metrics/internalqueue"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
@@ -57,6 +59,9 @@ type Generator struct {
 
 	requestCreator creator
 
+	// store aggregates the results in memory on the leader, nil disables the store
+	store *ResultStore
+
 	log logr.Logger
 }
 
@@ -67,6 +72,8 @@ func NewReportChangeRequestGenerator(client *policyreportclient.Clientset,
 	clusterReportReqInformer requestinformer.ClusterReportChangeRequestInformer,
 	cpolInformer kyvernoinformer.ClusterPolicyInformer,
 	polInformer kyvernoinformer.PolicyInformer,
+	promConfig *metrics.PromConfig,
+	store *ResultStore,
 	log logr.Logger) *Generator {
 	gen := Generator{
 		dclient:                          dclient,
@@ -78,9 +85,10 @@ func NewReportChangeRequestGenerator(client *policyreportclient.Clientset,
 		cpolListerSynced:                 cpolInformer.Informer().HasSynced,
 		polLister:                        polInformer.Lister(),
 		polListerSynced:                  polInformer.Informer().HasSynced,
-		queue:                            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), workQueueName),
+		queue:                            internalqueue.NewQueue(workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), workQueueName), internalqueue.Reports, promConfig, log),
 		dataStore:                        newDataStore(),
 		requestCreator:                   newChangeRequestCreator(dclient, 3*time.Second, log.WithName("requestCreator")),
+		store:                            store,
 		log:                              log,
 	}
 
@@ -121,10 +129,12 @@ func (ds *dataStore) delete(keyHash string) {
 
 // Info stores the policy application results for all matched resources
 // Namespace is set to empty "" if resource is cluster wide resource
+// PolicyNamespace is set to the namespace of a namespaced Policy, and empty for a ClusterPolicy
 type Info struct {
-	PolicyName string
-	Namespace  string
-	Results    []EngineResponseResult
+	PolicyName      string
+	PolicyNamespace string
+	Namespace       string
+	Results         []EngineResponseResult
 }
 
 type EngineResponseResult struct {
@@ -133,8 +143,13 @@ type EngineResponseResult struct {
 }
 
 func (i Info) ToKey() string {
+	policy := i.PolicyName
+	if i.PolicyNamespace != "" {
+		policy = i.PolicyNamespace + "/" + i.PolicyName
+	}
+
 	keys := []string{
-		i.PolicyName,
+		policy,
 		i.Namespace,
 		strconv.Itoa(len(i.Results)),
 	}
@@ -187,6 +202,7 @@ func (gen *Generator) Run(workers int, stopCh <-chan struct{}) {
 	}
 
 	go gen.requestCreator.run(stopCh)
+	go internalqueue.Run(gen.queue, stopCh)
 
 	<-stopCh
 }
@@ -270,6 +286,12 @@ func (gen *Generator) syncHandler(info Info) error {
 		return nil
 	}
 
+	// the results are stored in memory on the leader, the other replicas and the
+	// deletion requests go through the report change requests
+	if !isDeleteRequest(reportReq) && gen.store.add(reportReq) {
+		return nil
+	}
+
 	gen.requestCreator.add(reportReq)
 	return nil
 }
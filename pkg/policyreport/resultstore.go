@@ -0,0 +1,155 @@
+package policyreport
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResultStore buffers the report change requests of the leader in memory, the report generator
+// aggregates them into the policy reports without creating ReportChangeRequests.
+// The requests are removed from the store once reconciled into the reports, and the pending
+// requests are periodically persisted in checkpoint change requests by the report generator.
+//
+// The store is active on the leader only, the other replicas, and the leader before the
+// store is started, create ReportChangeRequests as the fallback.
+type ResultStore struct {
+	mutex  sync.Mutex
+	active bool
+	seq    uint64
+
+	// requests stores the pending requests by report key, in the order they were added
+	requests map[string][]storedRequest
+
+	// enqueue queues the report key for aggregation
+	enqueue func(key string)
+}
+
+type storedRequest struct {
+	id      uint64
+	request *unstructured.Unstructured
+}
+
+// NewResultStore returns an inactive result store
+func NewResultStore() *ResultStore {
+	return &ResultStore{
+		requests: make(map[string][]storedRequest),
+	}
+}
+
+// add stores the request and queues its report for aggregation, it returns false if
+// the store is not active and the request must be created as a change request
+func (s *ResultStore) add(request *unstructured.Unstructured) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	if !s.active {
+		s.mutex.Unlock()
+		return false
+	}
+
+	key := storeKey(request)
+	s.push(key, request)
+	enqueue := s.enqueue
+	s.mutex.Unlock()
+
+	enqueue(key)
+	return true
+}
+
+// restore stores a request persisted by a previous leader, it is queued once the store is started
+func (s *ResultStore) restore(request *unstructured.Unstructured) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.push(storeKey(request), request)
+}
+
+func (s *ResultStore) push(key string, request *unstructured.Unstructured) {
+	s.seq++
+	s.requests[key] = append(s.requests[key], storedRequest{id: s.seq, request: request})
+}
+
+// start activates the store and queues the reports of the pending requests
+func (s *ResultStore) start(enqueue func(key string)) {
+	s.mutex.Lock()
+	s.active = true
+	s.enqueue = enqueue
+	keys := make([]string, 0, len(s.requests))
+	for key := range s.requests {
+		keys = append(keys, key)
+	}
+	s.mutex.Unlock()
+
+	for _, key := range keys {
+		enqueue(key)
+	}
+}
+
+// stop deactivates the store, the pending requests are kept for the last checkpoint
+func (s *ResultStore) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.active = false
+}
+
+// pending returns the pending requests of the report and the id of the last one,
+// to remove them once reconciled
+func (s *ResultStore) pending(key string) (lastID uint64, requests []*unstructured.Unstructured) {
+	if s == nil {
+		return 0, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, stored := range s.requests[key] {
+		requests = append(requests, stored.request)
+		lastID = stored.id
+	}
+	return lastID, requests
+}
+
+// remove removes the pending requests of the report up to lastID, the requests
+// added since they were returned by pending are kept
+func (s *ResultStore) remove(key string, lastID uint64) {
+	if s == nil || lastID == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := s.requests[key]
+	i := 0
+	for i < len(stored) && stored[i].id <= lastID {
+		i++
+	}
+
+	if i == len(stored) {
+		delete(s.requests, key)
+		return
+	}
+	s.requests[key] = stored[i:]
+}
+
+// snapshot returns the pending requests by report key
+func (s *ResultStore) snapshot() map[string][]*unstructured.Unstructured {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := make(map[string][]*unstructured.Unstructured, len(s.requests))
+	for key, stored := range s.requests {
+		for _, r := range stored {
+			snapshot[key] = append(snapshot[key], r.request)
+		}
+	}
+	return snapshot
+}
+
+// storeKey returns the key of the report of the request, the namespace
+// of a ReportChangeRequest or "" for a ClusterReportChangeRequest
+func storeKey(request *unstructured.Unstructured) string {
+	if request.GetKind() == "ClusterReportChangeRequest" {
+		return ""
+	}
+	return request.GetLabels()[resourceLabelNamespace]
+}
@@ -0,0 +1,261 @@
+package policyreport
+
+import (
+	"fmt"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// staleRulesKey is the queue key prefix of a stale rule cleanup, i.e. "stalerules/<policy key>"
+const staleRulesKey string = "stalerules"
+
+// staleRulesScanKey is the queue key of the search of the stale results of the resumed policies
+const staleRulesScanKey string = staleRulesKey + "/"
+
+// staleRuleCleanup holds the current rule set of a policy, results of other rules of the policy are stale
+type staleRuleCleanup struct {
+	policy          string
+	namespace       string
+	resourceVersion string
+	rules           map[string]bool
+}
+
+func newStaleRuleCleanup(policy *kyverno.ClusterPolicy) staleRuleCleanup {
+	rules := make(map[string]bool, len(policy.Spec.Rules))
+	for _, rule := range policy.Spec.Rules {
+		rules[rule.Name] = true
+	}
+
+	return staleRuleCleanup{
+		policy:          policy.GetName(),
+		namespace:       policy.GetNamespace(),
+		resourceVersion: policy.GetResourceVersion(),
+		rules:           rules,
+	}
+}
+
+// isStale checks if the result was produced by a rule that no longer exists in the policy
+func (c staleRuleCleanup) isStale(result *report.PolicyReportResult) bool {
+	if result.Policy != c.policy || result.Rule == "" {
+		return false
+	}
+
+	// the namespaced reports hold the results of the ClusterPolicy and of the Policy of the namespace
+	// with the same name, the results of a Policy are marked with its namespace
+	if result.Data[resultDataPolicyNamespace] != c.namespace {
+		return false
+	}
+
+	// the result was produced by the current policy
+	if c.resourceVersion != "" && result.Data[resultDataPolicyResourceVersion] == c.resourceVersion {
+		return false
+	}
+
+	if c.rules[result.Rule] {
+		return false
+	}
+
+	// results of auto-generated rules are kept as long as their source rule exists
+	for _, prefix := range []string{"autogen-cronjob-", "autogen-"} {
+		if strings.HasPrefix(result.Rule, prefix) && c.rules[strings.TrimPrefix(result.Rule, prefix)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// removeStaleResults returns the results without stale results, and true if any result was removed
+func (c staleRuleCleanup) removeStaleResults(results []*report.PolicyReportResult) ([]*report.PolicyReportResult, bool) {
+	newRes := make([]*report.PolicyReportResult, 0, len(results))
+	for _, result := range results {
+		if c.isStale(result) {
+			continue
+		}
+		newRes = append(newRes, result)
+	}
+
+	return newRes, len(newRes) != len(results)
+}
+
+// CleanupStaleRules enqueues the removal of results of rules that no longer exist in the policy,
+// e.g. after a rule is renamed. Calling it again for the same policy resumes a pending cleanup.
+func (g *ReportGenerator) CleanupStaleRules(policy *kyverno.ClusterPolicy) {
+	key := stalePolicyKey(policy.GetNamespace(), policy.GetName())
+
+	g.staleRulesLock.Lock()
+	g.staleRules[key] = newStaleRuleCleanup(policy)
+	g.staleRulesLock.Unlock()
+
+	g.queue.Add(staleRulesKey + "/" + key)
+}
+
+// ResumeStaleRules enqueues the removal of the results of rules that no longer exist in the policy only if the
+// reports hold such results, e.g. of a rule renamed while Kyverno was not running. The policies added at once,
+// as on startup, are searched in a single pass over the reports.
+func (g *ReportGenerator) ResumeStaleRules(policy *kyverno.ClusterPolicy) {
+	g.staleRulesLock.Lock()
+	g.resumedStaleRules[stalePolicyKey(policy.GetNamespace(), policy.GetName())] = newStaleRuleCleanup(policy)
+	g.staleRulesLock.Unlock()
+
+	g.queue.Add(staleRulesScanKey)
+}
+
+func stalePolicyKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return namespace + "/" + name
+}
+
+func isStaleRulesKey(key string) (policyKey string, ok bool) {
+	if !strings.HasPrefix(key, staleRulesKey+"/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(key, staleRulesKey+"/"), true
+}
+
+// findStaleRules searches the reports for stale results of the resumed policies, and enqueues the cleanup
+// of the policies with stale results
+func (g *ReportGenerator) findStaleRules() error {
+	g.staleRulesLock.Lock()
+	resumed := g.resumedStaleRules
+	g.resumedStaleRules = make(map[string]staleRuleCleanup)
+	g.staleRulesLock.Unlock()
+
+	if len(resumed) == 0 {
+		return nil
+	}
+
+	var reports [][]*report.PolicyReportResult
+	cpolrs, err := g.clusterReportLister.List(labels.Everything())
+	if err == nil {
+		for _, cpolr := range cpolrs {
+			reports = append(reports, cpolr.Results)
+		}
+
+		var polrs []*report.PolicyReport
+		if polrs, err = g.reportLister.List(labels.Everything()); err == nil {
+			for _, polr := range polrs {
+				reports = append(reports, polr.Results)
+			}
+		}
+	}
+
+	if err != nil {
+		// the search is retried, the policies resumed in the meantime are newer
+		g.staleRulesLock.Lock()
+		for key, cleanup := range resumed {
+			if _, ok := g.resumedStaleRules[key]; !ok {
+				g.resumedStaleRules[key] = cleanup
+			}
+		}
+		g.staleRulesLock.Unlock()
+		return fmt.Errorf("failed to list the reports %v", err)
+	}
+
+	stale := make(map[string]bool)
+	for _, results := range reports {
+		for _, result := range results {
+			key := stalePolicyKey(result.Data[resultDataPolicyNamespace], result.Policy)
+			if cleanup, ok := resumed[key]; ok && !stale[key] && cleanup.isStale(result) {
+				stale[key] = true
+			}
+		}
+	}
+
+	for key := range stale {
+		g.staleRulesLock.Lock()
+		// a cleanup enqueued by an update of the policy is newer
+		if _, ok := g.staleRules[key]; !ok {
+			g.staleRules[key] = resumed[key]
+		}
+		g.staleRulesLock.Unlock()
+
+		g.log.V(3).Info("resuming the cleanup of stale results", "policy", key)
+		g.queue.Add(staleRulesKey + "/" + key)
+	}
+
+	return nil
+}
+
+// removeStaleRuleResults removes the stale results of a policy from the reports.
+// Reports are updated one at a time, if an update fails the cleanup is retried
+// and reports that no longer contain stale results are skipped.
+func (g *ReportGenerator) removeStaleRuleResults(policyKey string) error {
+	g.staleRulesLock.Lock()
+	cleanup, ok := g.staleRules[policyKey]
+	g.staleRulesLock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	logger := g.log.WithValues("policy", policyKey)
+	gv := report.SchemeGroupVersion
+
+	polrs := []*report.PolicyReport{}
+	if cleanup.namespace == "" {
+		cpolrs, err := g.clusterReportLister.List(labels.Everything())
+		if err != nil {
+			return fmt.Errorf("failed to list clusterPolicyReport %v", err)
+		}
+
+		for _, cpolr := range cpolrs {
+			results, changed := cleanup.removeStaleResults(cpolr.Results)
+			if !changed {
+				continue
+			}
+
+			cpolr = cpolr.DeepCopy()
+			cpolr.Results = results
+			cpolr.Summary = CalculateSummary(results)
+			cpolr.SetGroupVersionKind(schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: "ClusterPolicyReport"})
+			if _, err := g.dclient.UpdateResource("", "ClusterPolicyReport", "", cpolr, false); err != nil {
+				return fmt.Errorf("failed to update clusterPolicyReport %s %v", cpolr.Name, err)
+			}
+
+			logger.V(3).Info("removed stale results", "kind", "ClusterPolicyReport", "name", cpolr.GetName())
+		}
+
+		if polrs, err = g.reportLister.List(labels.Everything()); err != nil {
+			return fmt.Errorf("unable to list policyReport %v", err)
+		}
+	} else {
+		var err error
+		if polrs, err = g.reportLister.PolicyReports(cleanup.namespace).List(labels.Everything()); err != nil {
+			return fmt.Errorf("unable to list policyReport for namespace %s %v", cleanup.namespace, err)
+		}
+	}
+
+	for _, polr := range polrs {
+		results, changed := cleanup.removeStaleResults(polr.Results)
+		if !changed {
+			continue
+		}
+
+		polr = polr.DeepCopy()
+		polr.Results = results
+		polr.Summary = CalculateSummary(results)
+		polr.SetGroupVersionKind(schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: "PolicyReport"})
+		if _, err := g.dclient.UpdateResource("", "PolicyReport", polr.GetNamespace(), polr, false); err != nil {
+			return fmt.Errorf("failed to update PolicyReport %s %v", polr.GetName(), err)
+		}
+
+		logger.V(3).Info("removed stale results", "kind", "PolicyReport", "namespace", polr.GetNamespace(), "name", polr.GetName())
+	}
+
+	// the cleanup is done, unless the policy changed again in the meantime
+	g.staleRulesLock.Lock()
+	if current, ok := g.staleRules[policyKey]; ok && current.resourceVersion == cleanup.resourceVersion {
+		delete(g.staleRules, policyKey)
+	}
+	g.staleRulesLock.Unlock()
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package policyreport
+
+import (
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	policyreportlister "github.com/kyverno/kyverno/pkg/client/listers/policyreport/v1alpha1"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newStaleTestPolicy(resourceVersion string, rules ...string) *kyverno.ClusterPolicy {
+	policy := &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-labels", ResourceVersion: resourceVersion},
+	}
+
+	for _, rule := range rules {
+		policy.Spec.Rules = append(policy.Spec.Rules, kyverno.Rule{Name: rule})
+	}
+	return policy
+}
+
+func newStaleTestResult(policy, rule, name string, status report.PolicyStatus, resourceVersion string) *report.PolicyReportResult {
+	return &report.PolicyReportResult{
+		Policy: policy,
+		Rule:   rule,
+		Resources: []*v1.ObjectReference{
+			{APIVersion: "v1", Kind: "Pod", Namespace: "test", Name: name},
+		},
+		Status: status,
+		Data:   map[string]string{resultDataPolicyResourceVersion: resourceVersion},
+	}
+}
+
+func Test_Remove_Results_Of_Renamed_Rule(t *testing.T) {
+	results := []*report.PolicyReportResult{
+		newStaleTestResult("require-labels", "check-labels", "pod-1", report.StatusFail, "1"),
+		newStaleTestResult("require-labels", "check-labels", "pod-2", report.StatusPass, "1"),
+		newStaleTestResult("require-labels", "autogen-check-labels", "deploy-1", report.StatusFail, "1"),
+		newStaleTestResult("require-labels", "check-image", "pod-1", report.StatusPass, "1"),
+		newStaleTestResult("require-labels", "check-app-label", "pod-1", report.StatusFail, "2"),
+		newStaleTestResult("disallow-latest-tag", "check-labels", "pod-1", report.StatusFail, "1"),
+	}
+
+	summary := CalculateSummary(results)
+	assert.Equal(t, summary.Fail, 4)
+	assert.Equal(t, summary.Pass, 2)
+
+	// check-labels is renamed to check-app-label
+	cleanup := newStaleRuleCleanup(newStaleTestPolicy("2", "check-app-label", "check-image"))
+	newRes, changed := cleanup.removeStaleResults(results)
+	assert.Assert(t, changed)
+	assert.Equal(t, len(newRes), 3)
+	for _, result := range newRes {
+		assert.Assert(t, !(result.Policy == "require-labels" && result.Rule == "check-labels"))
+		assert.Assert(t, result.Rule != "autogen-check-labels")
+	}
+
+	summary = CalculateSummary(newRes)
+	assert.Equal(t, summary.Fail, 2)
+	assert.Equal(t, summary.Pass, 1)
+
+	// the cleanup is idempotent
+	_, changed = cleanup.removeStaleResults(newRes)
+	assert.Assert(t, !changed)
+}
+
+func Test_Keep_Results_Of_Current_Rules(t *testing.T) {
+	cleanup := newStaleRuleCleanup(newStaleTestPolicy("2", "check-labels"))
+
+	// results of auto-generated rules are kept while the source rule exists
+	assert.Assert(t, !cleanup.isStale(newStaleTestResult("require-labels", "autogen-check-labels", "deploy-1", report.StatusFail, "1")))
+	assert.Assert(t, !cleanup.isStale(newStaleTestResult("require-labels", "autogen-cronjob-check-labels", "cronjob-1", report.StatusFail, "1")))
+
+	// results produced by the current policy version are kept
+	assert.Assert(t, !cleanup.isStale(newStaleTestResult("require-labels", "check-other", "pod-1", report.StatusFail, "2")))
+
+	// results of other policies are kept
+	assert.Assert(t, !cleanup.isStale(newStaleTestResult("disallow-latest-tag", "check-image", "pod-1", report.StatusFail, "1")))
+
+	assert.Assert(t, cleanup.isStale(newStaleTestResult("require-labels", "check-other", "pod-1", report.StatusFail, "1")))
+}
+
+func Test_Stale_Rule_Cleanup_Policy_Kind(t *testing.T) {
+	clusterResult := newStaleTestResult("require-labels", "check-labels", "pod-1", report.StatusFail, "1")
+	nsResult := newStaleTestResult("require-labels", "check-labels", "pod-1", report.StatusFail, "1")
+	nsResult.Data[resultDataPolicyNamespace] = "test"
+
+	// the cleanup of the ClusterPolicy keeps the results of the Policy with the same name
+	cleanup := newStaleRuleCleanup(newStaleTestPolicy("2", "check-app-label"))
+	assert.Assert(t, cleanup.isStale(clusterResult))
+	assert.Assert(t, !cleanup.isStale(nsResult))
+
+	// the cleanup of the Policy keeps the results of the ClusterPolicy with the same name
+	policy := newStaleTestPolicy("3", "check-app-label")
+	policy.SetNamespace("test")
+	cleanup = newStaleRuleCleanup(policy)
+	assert.Assert(t, !cleanup.isStale(clusterResult))
+	assert.Assert(t, cleanup.isStale(nsResult))
+
+	otherResult := newStaleTestResult("require-labels", "check-labels", "pod-1", report.StatusFail, "1")
+	otherResult.Data[resultDataPolicyNamespace] = "prod"
+	assert.Assert(t, !cleanup.isStale(otherResult))
+}
+
+func Test_Stale_Rule_Cleanup_Completes(t *testing.T) {
+	reportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	clusterReportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, reportIndexer.Add(&report.PolicyReport{
+		ObjectMeta: metav1.ObjectMeta{Name: "polr-ns-test", Namespace: "test"},
+		Results: []*report.PolicyReportResult{
+			newStaleTestResult("require-labels", "check-app-label", "pod-1", report.StatusFail, "2"),
+		},
+	}))
+
+	g := &ReportGenerator{
+		reportLister:        policyreportlister.NewPolicyReportLister(reportIndexer),
+		clusterReportLister: policyreportlister.NewClusterPolicyReportLister(clusterReportIndexer),
+		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), prWorkQueueName),
+		staleRules:          make(map[string]staleRuleCleanup),
+		log:                 log.Log,
+	}
+
+	g.CleanupStaleRules(newStaleTestPolicy("2", "check-app-label"))
+	key, _ := g.queue.Get()
+	policyKey, ok := isStaleRulesKey(key.(string))
+	assert.Assert(t, ok)
+	assert.Equal(t, policyKey, "require-labels")
+
+	// reports without stale results are not updated
+	assert.NilError(t, g.removeStaleRuleResults(policyKey))
+	assert.Equal(t, len(g.staleRules), 0)
+
+	// a completed cleanup is a no-op
+	assert.NilError(t, g.removeStaleRuleResults(policyKey))
+}
+
+func Test_Resume_Stale_Rules(t *testing.T) {
+	reportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	clusterReportIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NilError(t, reportIndexer.Add(&report.PolicyReport{
+		ObjectMeta: metav1.ObjectMeta{Name: "polr-ns-test", Namespace: "test"},
+		Results: []*report.PolicyReportResult{
+			newStaleTestResult("require-labels", "check-labels", "pod-1", report.StatusFail, "1"),
+			newStaleTestResult("disallow-latest-tag", "check-image", "pod-1", report.StatusFail, "1"),
+		},
+	}))
+
+	g := &ReportGenerator{
+		reportLister:        policyreportlister.NewPolicyReportLister(reportIndexer),
+		clusterReportLister: policyreportlister.NewClusterPolicyReportLister(clusterReportIndexer),
+		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), prWorkQueueName),
+		staleRules:          make(map[string]staleRuleCleanup),
+		resumedStaleRules:   make(map[string]staleRuleCleanup),
+		log:                 log.Log,
+	}
+
+	// the added policies are searched in a single pass
+	g.ResumeStaleRules(newStaleTestPolicy("2", "check-app-label"))
+	unchanged := newStaleTestPolicy("1", "check-image")
+	unchanged.SetName("disallow-latest-tag")
+	g.ResumeStaleRules(unchanged)
+	assert.Equal(t, g.queue.Len(), 1)
+
+	key, _ := g.queue.Get()
+	assert.Equal(t, key, staleRulesScanKey)
+	assert.NilError(t, g.findStaleRules())
+	g.queue.Done(key)
+
+	// only the cleanup of the policy with stale results is enqueued
+	assert.Equal(t, g.queue.Len(), 1)
+	key, _ = g.queue.Get()
+	policyKey, ok := isStaleRulesKey(key.(string))
+	assert.Assert(t, ok)
+	assert.Equal(t, policyKey, "require-labels")
+	assert.Equal(t, len(g.staleRules), 1)
+	assert.Equal(t, len(g.resumedStaleRules), 0)
+}
@@ -0,0 +1,156 @@
+package resourcecache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// APICallCacheConfig declares the resources cached for the API calls of the context entries
+type APICallCacheConfig struct {
+	// Resources are the group, version and resource of the cached resources, e.g. "networking.k8s.io/v1/ingresses"
+	// or "v1/services"
+	Resources []string
+
+	// MaxObjects is the maximum number of objects cached for a resource. A resource exceeding it is no longer
+	// cached and its API calls are served by the API server. The limit is disabled if set to 0.
+	MaxObjects int
+}
+
+// apiCallCache is the informer of a resource cached for the API calls. The API calls are served by the API
+// server until the informer is synced, and again once the resource exceeds the maximum number of objects.
+type apiCallCache struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	maxObjects int
+
+	mux      sync.RWMutex
+	informer informers.GenericInformer
+	objects  int
+	stopCh   chan struct{}
+
+	log logr.Logger
+}
+
+func newAPICallCache(gvr schema.GroupVersionResource, namespaced bool, maxObjects int, informer informers.GenericInformer, log logr.Logger) *apiCallCache {
+	c := &apiCallCache{
+		gvr:        gvr,
+		namespaced: namespaced,
+		maxObjects: maxObjects,
+		informer:   informer,
+		stopCh:     make(chan struct{}),
+		log:        log.WithValues("resource", gvr.String()),
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.count(1) },
+		DeleteFunc: func(interface{}) { c.count(-1) },
+	})
+
+	return c
+}
+
+// count counts the cached objects, the informer is stopped and released when they exceed the maximum
+func (c *apiCallCache) count(delta int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.objects += delta
+	if c.maxObjects > 0 && c.objects > c.maxObjects && c.informer != nil {
+		c.log.Info("resource exceeds the maximum number of objects of the API call cache, the API calls are served by the API server", "maxObjects", c.maxObjects)
+		c.stop()
+	}
+}
+
+// synced returns the cache of the resource, if its informer is running and synced
+func (c *apiCallCache) synced() (GenericCache, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	if c.informer == nil || !c.informer.Informer().HasSynced() {
+		return nil, false
+	}
+
+	return NewGVRCache(c.gvr, c.namespaced, c.stopCh, c.informer), true
+}
+
+// stop stops the informer and releases the cached objects, the lock must be held
+func (c *apiCallCache) stop() {
+	if c.informer == nil {
+		return
+	}
+
+	close(c.stopCh)
+	c.informer = nil
+}
+
+// createAPICallCaches creates and starts the informers of the resources cached for the API calls
+func (resc *resourceCache) createAPICallCaches(config APICallCacheConfig) []error {
+	var errs []error
+	for _, resource := range config.Resources {
+		resource = strings.TrimSpace(resource)
+		if resource == "" {
+			continue
+		}
+
+		gv, name, err := parseGroupVersionResource(resource)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		apiResource, gvr, err := resc.dclient.DiscoveryClient.FindResource(gv, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot find API resource %s: %v", resource, err))
+			continue
+		}
+
+		informer := newAPICallInformer(resc.dclient.GetDynamicInterface(), gvr)
+		c := newAPICallCache(gvr, apiResource.Namespaced, config.MaxObjects, informer, resc.log)
+		resc.apiCallCaches[gvr] = c
+		go informer.Informer().Run(c.stopCh)
+		resc.log.V(2).Info("caching resource for the API calls", "resource", gvr.String(), "maxObjects", config.MaxObjects)
+	}
+
+	return errs
+}
+
+// newAPICallInformer returns an informer of the resource that is not shared, so that its objects are
+// released when it is stopped
+func newAPICallInformer(client dynamic.Interface, gvr schema.GroupVersionResource) informers.GenericInformer {
+	return dynamicinformer.NewFilteredDynamicInformer(client, gvr, metav1.NamespaceAll, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+}
+
+// GetAPICallCache returns the cache of the resource for the API calls, if the resource is cached and synced
+func (resc *resourceCache) GetAPICallCache(gvr schema.GroupVersionResource) (GenericCache, bool) {
+	c, ok := resc.apiCallCaches[gvr]
+	if !ok {
+		return nil, false
+	}
+
+	return c.synced()
+}
+
+// parseGroupVersionResource splits a resource, e.g. "networking.k8s.io/v1/ingresses" or "v1/services", into its
+// group version and its resource name
+func parseGroupVersionResource(resource string) (string, string, error) {
+	i := strings.LastIndex(resource, "/")
+	if i <= 0 || i == len(resource)-1 {
+		return "", "", fmt.Errorf("invalid resource %s, expected group/version/resource or version/resource", resource)
+	}
+
+	if _, err := schema.ParseGroupVersion(resource[:i]); err != nil {
+		return "", "", fmt.Errorf("invalid resource %s: %v", resource, err)
+	}
+
+	return resource[:i], resource[i+1:], nil
+}
@@ -0,0 +1,63 @@
+package resourcecache
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// testInformer is an informer that is not run
+type testInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+func (i testInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i testInformer) Lister() cache.GenericLister { return nil }
+
+func Test_APICallCache_MaxObjects(t *testing.T) {
+	informer := testInformer{cache.NewSharedIndexInformer(&cache.ListWatch{}, &unstructured.Unstructured{}, 0, cache.Indexers{})}
+	c := newAPICallCache(schema.GroupVersionResource{Version: "v1", Resource: "services"}, true, 2, informer, log.Log)
+
+	c.count(1)
+	c.count(1)
+	c.count(-1)
+	c.count(1)
+	assert.Assert(t, c.informer != nil)
+
+	// the informer is released once the resource exceeds the maximum number of objects
+	c.count(1)
+	assert.Assert(t, c.informer == nil)
+	_, ok := c.synced()
+	assert.Assert(t, !ok)
+
+	select {
+	case <-c.stopCh:
+	default:
+		t.Error("expected the informer to be stopped")
+	}
+
+	// the informer is stopped once
+	c.count(1)
+}
+
+func Test_ParseGroupVersionResource(t *testing.T) {
+	gv, resource, err := parseGroupVersionResource("networking.k8s.io/v1/ingresses")
+	assert.NilError(t, err)
+	assert.Equal(t, gv, "networking.k8s.io/v1")
+	assert.Equal(t, resource, "ingresses")
+
+	gv, resource, err = parseGroupVersionResource("v1/services")
+	assert.NilError(t, err)
+	assert.Equal(t, gv, "v1")
+	assert.Equal(t, resource, "services")
+
+	for _, invalid := range []string{"services", "v1/", "/services", "a/b/c/d"} {
+		_, _, err := parseGroupVersionResource(invalid)
+		assert.Assert(t, err != nil, invalid)
+	}
+}
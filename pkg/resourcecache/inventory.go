@@ -0,0 +1,378 @@
+package resourcecache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-logr/logr"
+	gojmespath "github.com/jmespath/go-jmespath"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	jmespath "github.com/kyverno/kyverno/pkg/engine/jmespath"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ResourceReference identifies a resource with a value in an inventory index
+type ResourceReference struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+func (r ResourceReference) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": r.APIVersion,
+		"kind":       r.Kind,
+		"namespace":  r.Namespace,
+		"name":       r.Name,
+	}
+}
+
+// resourceLister lists resources from the API server, it is implemented by the dclient
+type resourceLister interface {
+	ListResource(apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector) (*unstructured.UnstructuredList, error)
+}
+
+// Inventory maintains in-memory indexes of values projected from the resources of a kind, e.g. the
+// hosts of all Ingresses, so that policies can detect collisions without a LIST per admission request.
+// The indexes are declared in the Kyverno ConfigMap and looked up with the lookup_index JMESPath function.
+//
+// The indexes are updated asynchronously from informer events, so resources with the same value that
+// are admitted at the same time are not detected: the lookups are best-effort. Indexes declared with
+// liveLookup list the resources from the API server on every lookup instead, which narrows the window
+// for enforce rules at the cost of a LIST per lookup.
+type Inventory struct {
+	resCache ResourceCache
+	client   resourceLister
+
+	mux     sync.RWMutex
+	indexes map[string]*inventoryIndex
+
+	log logr.Logger
+}
+
+// NewInventory returns an inventory without indexes
+func NewInventory(resCache ResourceCache, client resourceLister, log logr.Logger) *Inventory {
+	return &Inventory{
+		resCache: resCache,
+		client:   client,
+		indexes:  make(map[string]*inventoryIndex),
+		log:      log,
+	}
+}
+
+// Run syncs the indexes with the declarations in the configuration, and again whenever they change
+func (inv *Inventory) Run(configHandler config.Interface, updateCh <-chan bool, stopCh <-chan struct{}) {
+	inv.Sync(configHandler.GetInventoryIndexes())
+	for {
+		select {
+		case <-updateCh:
+			inv.Sync(configHandler.GetInventoryIndexes())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Sync creates, replaces and removes the indexes according to the declarations
+func (inv *Inventory) Sync(declarations []config.InventoryIndex) {
+	inv.mux.RLock()
+	current := inv.indexes
+	inv.mux.RUnlock()
+
+	indexes := make(map[string]*inventoryIndex, len(declarations))
+	for _, declaration := range declarations {
+		if idx, ok := current[declaration.Name]; ok && reflect.DeepEqual(idx.InventoryIndex, declaration) {
+			indexes[declaration.Name] = idx
+			continue
+		}
+
+		idx, err := inv.newIndex(declaration)
+		if err != nil {
+			inv.log.Error(err, "failed to create inventory index", "name", declaration.Name, "kind", declaration.Kind)
+			continue
+		}
+
+		indexes[declaration.Name] = idx
+		inv.log.V(2).Info("created inventory index", "name", declaration.Name, "kind", declaration.Kind, "liveLookup", declaration.LiveLookup)
+	}
+
+	inv.mux.Lock()
+	inv.indexes = indexes
+	inv.mux.Unlock()
+
+	for name, idx := range current {
+		if indexes[name] != idx {
+			idx.stop()
+			inv.log.V(2).Info("removed inventory index", "name", name)
+		}
+	}
+}
+
+func (inv *Inventory) newIndex(declaration config.InventoryIndex) (*inventoryIndex, error) {
+	idx, err := newInventoryIndex(declaration)
+	if err != nil {
+		return nil, err
+	}
+
+	if declaration.LiveLookup {
+		return idx, nil
+	}
+
+	gc, err := inv.resCache.CreateGVKInformer(declaration.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	// the informer replays the existing resources as add events
+	gc.GetInformer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    idx.add,
+		UpdateFunc: idx.update,
+		DeleteFunc: idx.delete,
+	})
+
+	return idx, nil
+}
+
+// Lookup returns the references of the resources with the value in the named index, sorted by namespace and name
+func (inv *Inventory) Lookup(name, value string) ([]ResourceReference, error) {
+	inv.mux.RLock()
+	idx, ok := inv.indexes[name]
+	inv.mux.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("inventory index %s is not configured", name)
+	}
+
+	if idx.LiveLookup {
+		return inv.liveLookup(idx, value)
+	}
+
+	return idx.lookup(value), nil
+}
+
+// LookupIndex returns the references like Lookup, for the lookup_index JMESPath function
+func (inv *Inventory) LookupIndex(name, value string) ([]interface{}, error) {
+	refs, err := inv.Lookup(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		result = append(result, ref.toMap())
+	}
+	return result, nil
+}
+
+func (inv *Inventory) liveLookup(idx *inventoryIndex, value string) ([]ResourceReference, error) {
+	apiVersion, kind := common.GetKindFromGVK(idx.Kind)
+	list, err := inv.client.ListResource(apiVersion, kind, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", idx.Kind, err)
+	}
+
+	var refs []ResourceReference
+	for i := range list.Items {
+		for _, v := range idx.project(&list.Items[i]) {
+			if v == value {
+				refs = append(refs, newResourceReference(&list.Items[i]))
+				break
+			}
+		}
+	}
+
+	sortReferences(refs)
+	return refs, nil
+}
+
+// inventoryIndex maps the values projected from the resources of a kind to the resources
+type inventoryIndex struct {
+	config.InventoryIndex
+
+	query *gojmespath.JMESPath
+
+	mux     sync.RWMutex
+	stopped bool
+
+	// values stores the references of the resources with a value, keyed by the resource key
+	values map[string]map[string]ResourceReference
+
+	// resourceValues stores the values of a resource, to remove them when it is updated or deleted
+	resourceValues map[string][]string
+}
+
+func newInventoryIndex(declaration config.InventoryIndex) (*inventoryIndex, error) {
+	query, err := jmespath.New(declaration.JMESPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jmesPath %s: %v", declaration.JMESPath, err)
+	}
+
+	return &inventoryIndex{
+		InventoryIndex: declaration,
+		query:          query,
+		values:         make(map[string]map[string]ResourceReference),
+		resourceValues: make(map[string][]string),
+	}, nil
+}
+
+func (idx *inventoryIndex) add(obj interface{}) {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	idx.set(resource)
+}
+
+func (idx *inventoryIndex) update(old, cur interface{}) {
+	oldResource, ok := old.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	curResource, ok := cur.(*unstructured.Unstructured)
+	if !ok || oldResource.GetResourceVersion() == curResource.GetResourceVersion() {
+		return
+	}
+
+	idx.set(curResource)
+}
+
+func (idx *inventoryIndex) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		idx.remove(tombstone.Key)
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	idx.remove(key)
+}
+
+// set replaces the indexed values of the resource
+func (idx *inventoryIndex) set(resource *unstructured.Unstructured) {
+	key, err := cache.MetaNamespaceKeyFunc(resource)
+	if err != nil {
+		return
+	}
+
+	values := idx.project(resource)
+	ref := newResourceReference(resource)
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	if idx.stopped {
+		return
+	}
+
+	idx.removeLocked(key)
+	for _, value := range values {
+		if idx.values[value] == nil {
+			idx.values[value] = make(map[string]ResourceReference)
+		}
+		idx.values[value][key] = ref
+	}
+
+	if len(values) > 0 {
+		idx.resourceValues[key] = values
+	}
+}
+
+func (idx *inventoryIndex) remove(key string) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.removeLocked(key)
+}
+
+func (idx *inventoryIndex) removeLocked(key string) {
+	for _, value := range idx.resourceValues[key] {
+		delete(idx.values[value], key)
+		if len(idx.values[value]) == 0 {
+			delete(idx.values, value)
+		}
+	}
+	delete(idx.resourceValues, key)
+}
+
+// stop drops the indexed values, the informer event handlers cannot be removed and are ignored
+func (idx *inventoryIndex) stop() {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.stopped = true
+	idx.values = make(map[string]map[string]ResourceReference)
+	idx.resourceValues = make(map[string][]string)
+}
+
+func (idx *inventoryIndex) lookup(value string) []ResourceReference {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	refs := make([]ResourceReference, 0, len(idx.values[value]))
+	for _, ref := range idx.values[value] {
+		refs = append(refs, ref)
+	}
+
+	sortReferences(refs)
+	return refs
+}
+
+// project returns the distinct scalar values selected by the JMESPath expression, as strings
+func (idx *inventoryIndex) project(resource *unstructured.Unstructured) []string {
+	result, err := idx.query.Search(resource.UnstructuredContent())
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	seen := make(map[string]bool)
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]interface{}:
+			// only scalar values are indexed
+		default:
+			s := fmt.Sprint(v)
+			if !seen[s] {
+				seen[s] = true
+				values = append(values, s)
+			}
+		}
+	}
+
+	collect(result)
+	return values
+}
+
+func newResourceReference(resource *unstructured.Unstructured) ResourceReference {
+	return ResourceReference{
+		APIVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Namespace:  resource.GetNamespace(),
+		Name:       resource.GetName(),
+	}
+}
+
+func sortReferences(refs []ResourceReference) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Namespace != refs[j].Namespace {
+			return refs[i].Namespace < refs[j].Namespace
+		}
+		return refs[i].Name < refs[j].Name
+	})
+}
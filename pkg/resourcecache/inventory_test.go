@@ -0,0 +1,133 @@
+package resourcecache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kyverno/kyverno/pkg/config"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newInventoryTestService(namespace, name, resourceVersion string, nodePorts ...int64) *unstructured.Unstructured {
+	var ports []interface{}
+	for _, nodePort := range nodePorts {
+		ports = append(ports, map[string]interface{}{"port": int64(80), "nodePort": nodePort})
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"namespace":       namespace,
+			"name":            name,
+			"resourceVersion": resourceVersion,
+		},
+		"spec": map[string]interface{}{
+			"type":  "NodePort",
+			"ports": ports,
+		},
+	}}
+}
+
+var nodePortsIndex = config.InventoryIndex{Name: "node-ports", Kind: "Service", JMESPath: "spec.ports[].nodePort"}
+
+func Test_Inventory_Index_Maintenance(t *testing.T) {
+	idx, err := newInventoryIndex(nodePortsIndex)
+	assert.NilError(t, err)
+
+	web := ResourceReference{APIVersion: "v1", Kind: "Service", Namespace: "team-a", Name: "web"}
+	api := ResourceReference{APIVersion: "v1", Kind: "Service", Namespace: "team-b", Name: "api"}
+
+	// add
+	idx.add(newInventoryTestService("team-a", "web", "1", 30080, 30443))
+	idx.add(newInventoryTestService("team-b", "api", "1", 30080))
+	assert.DeepEqual(t, idx.lookup("30080"), []ResourceReference{web, api})
+	assert.DeepEqual(t, idx.lookup("30443"), []ResourceReference{web})
+	assert.DeepEqual(t, idx.lookup("30081"), []ResourceReference{})
+
+	// update replaces the values of the resource
+	idx.update(newInventoryTestService("team-a", "web", "1", 30080, 30443), newInventoryTestService("team-a", "web", "2", 30081))
+	assert.DeepEqual(t, idx.lookup("30080"), []ResourceReference{api})
+	assert.DeepEqual(t, idx.lookup("30443"), []ResourceReference{})
+	assert.DeepEqual(t, idx.lookup("30081"), []ResourceReference{web})
+
+	// resources without values are not indexed
+	idx.update(newInventoryTestService("team-a", "web", "2", 30081), newInventoryTestService("team-a", "web", "3"))
+	assert.DeepEqual(t, idx.lookup("30081"), []ResourceReference{})
+	assert.Equal(t, len(idx.resourceValues), 1)
+
+	// delete, also from a tombstone
+	idx.add(newInventoryTestService("team-a", "web", "4", 30443))
+	idx.delete(newInventoryTestService("team-b", "api", "1", 30080))
+	assert.DeepEqual(t, idx.lookup("30080"), []ResourceReference{})
+	idx.delete(cache.DeletedFinalStateUnknown{Key: "team-a/web", Obj: newInventoryTestService("team-a", "web", "4", 30443)})
+	assert.DeepEqual(t, idx.lookup("30443"), []ResourceReference{})
+	assert.Equal(t, len(idx.values), 0)
+	assert.Equal(t, len(idx.resourceValues), 0)
+
+	// a removed index ignores the informer events
+	idx.stop()
+	idx.add(newInventoryTestService("team-a", "web", "5", 30443))
+	assert.DeepEqual(t, idx.lookup("30443"), []ResourceReference{})
+}
+
+type fakeResourceLister struct {
+	items []unstructured.Unstructured
+	calls int
+}
+
+func (f *fakeResourceLister) ListResource(apiVersion string, kind string, namespace string, lselector *metav1.LabelSelector) (*unstructured.UnstructuredList, error) {
+	f.calls++
+	if kind != "Service" {
+		return nil, fmt.Errorf("the server could not find the requested resource")
+	}
+	return &unstructured.UnstructuredList{Items: f.items}, nil
+}
+
+func Test_Inventory_Lookup(t *testing.T) {
+	client := &fakeResourceLister{}
+	inv := NewInventory(nil, client, log.Log)
+
+	idx, err := newInventoryIndex(nodePortsIndex)
+	assert.NilError(t, err)
+	idx.add(newInventoryTestService("team-a", "web", "1", 30080))
+	inv.indexes[nodePortsIndex.Name] = idx
+
+	result, err := inv.LookupIndex("node-ports", "30080")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, []interface{}{map[string]interface{}{"apiVersion": "v1", "kind": "Service", "namespace": "team-a", "name": "web"}})
+
+	result, err = inv.LookupIndex("node-ports", "30081")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, []interface{}{})
+
+	_, err = inv.LookupIndex("ingress-hosts", "example.com")
+	assert.Error(t, err, "inventory index ingress-hosts is not configured")
+	assert.Equal(t, client.calls, 0)
+
+	// live lookups list the resources, e.g. a service created after the index was updated
+	live := nodePortsIndex
+	live.LiveLookup = true
+	inv.Sync([]config.InventoryIndex{live})
+	client.items = []unstructured.Unstructured{*newInventoryTestService("team-a", "web", "1", 30080), *newInventoryTestService("team-b", "api", "1", 30080, 30443)}
+
+	refs, err := inv.Lookup("node-ports", "30080")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, refs, []ResourceReference{
+		{APIVersion: "v1", Kind: "Service", Namespace: "team-a", Name: "web"},
+		{APIVersion: "v1", Kind: "Service", Namespace: "team-b", Name: "api"},
+	})
+	assert.Equal(t, client.calls, 1)
+
+	// the replaced index is stopped
+	assert.Assert(t, idx.stopped)
+
+	// removed indexes are not available
+	inv.Sync(nil)
+	_, err = inv.Lookup("node-ports", "30080")
+	assert.Error(t, err, "inventory index node-ports is not configured")
+}
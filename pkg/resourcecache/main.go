@@ -6,6 +6,7 @@ import (
 	"github.com/go-logr/logr"
 	dclient "github.com/kyverno/kyverno/pkg/dclient"
 	cmap "github.com/orcaman/concurrent-map"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 )
 
@@ -19,6 +20,7 @@ type ResourceCache interface {
 	CreateGVKInformer(gvk string) (GenericCache, error)
 	StopResourceInformer(gvk string)
 	GetGVRCache(gvk string) (GenericCache, bool)
+	GetAPICallCache(gvr schema.GroupVersionResource) (GenericCache, bool)
 }
 
 type resourceCache struct {
@@ -30,18 +32,22 @@ type resourceCache struct {
 	// it uses resource name as key (i.e., namespaces for Namespace, pods for Pod, clusterpolicies for ClusterPolicy, etc)
 	gvrCache cmap.ConcurrentMap
 
+	// apiCallCaches - stores the informers of the resources cached for the API calls of the context entries
+	apiCallCaches map[schema.GroupVersionResource]*apiCallCache
+
 	log logr.Logger
 }
 
 var KyvernoDefaultInformer = []string{"ConfigMap", "Deployment", "MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"}
 
 // NewResourceCache - initializes the ResourceCache
-func NewResourceCache(dclient *dclient.Client, dInformer dynamicinformer.DynamicSharedInformerFactory, logger logr.Logger) (ResourceCache, error) {
+func NewResourceCache(dclient *dclient.Client, dInformer dynamicinformer.DynamicSharedInformerFactory, apiCallConfig APICallCacheConfig, logger logr.Logger) (ResourceCache, error) {
 	rCache := &resourceCache{
-		dclient:   dclient,
-		gvrCache:  cmap.New(),
-		dinformer: dInformer,
-		log:       logger,
+		dclient:       dclient,
+		gvrCache:      cmap.New(),
+		apiCallCaches: make(map[schema.GroupVersionResource]*apiCallCache),
+		dinformer:     dInformer,
+		log:           logger,
 	}
 
 	errs := rCache.CreateInformers(KyvernoDefaultInformer...)
@@ -49,5 +55,10 @@ func NewResourceCache(dclient *dclient.Client, dInformer dynamicinformer.Dynamic
 		return rCache, fmt.Errorf("failed to register default informers %v", errs)
 	}
 
+	// the API calls of the resources that cannot be cached are served by the API server
+	for _, err := range rCache.createAPICallCaches(apiCallConfig) {
+		logger.Error(err, "failed to cache resource for the API calls")
+	}
+
 	return rCache, nil
 }
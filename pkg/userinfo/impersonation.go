@@ -0,0 +1,47 @@
+package userinfo
+
+import (
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+const (
+	// OriginalUserExtraKey is the userInfo extra holding the user impersonating the requesting user, e.g. with kubectl --as.
+	// The API server does not record the impersonating user in the admission requests, the extra is set by the
+	// authentication proxy or webhook. Granting the impersonation of this extra (userextras/<key>) defeats its purpose.
+	OriginalUserExtraKey = "impersonation.kyverno.io/original-user"
+
+	// OriginalGroupsExtraKey is the userInfo extra holding the groups of the impersonating user
+	OriginalGroupsExtraKey = "impersonation.kyverno.io/original-groups"
+)
+
+// ImpersonatedBy is the user impersonating the requesting user
+type ImpersonatedBy struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// GetImpersonatedBy returns the user impersonating the requesting user, or nil if the request is not impersonated
+func GetImpersonatedBy(userInfo authenticationv1.UserInfo) *ImpersonatedBy {
+	users := userInfo.Extra[OriginalUserExtraKey]
+	if len(users) == 0 || users[0] == "" {
+		return nil
+	}
+
+	return &ImpersonatedBy{
+		Username: users[0],
+		Groups:   []string(userInfo.Extra[OriginalGroupsExtraKey]),
+	}
+}
+
+// OriginalUser returns the user impersonating the requesting user, or the requesting user if the request is not impersonated
+func OriginalUser(userInfo authenticationv1.UserInfo) authenticationv1.UserInfo {
+	impersonatedBy := GetImpersonatedBy(userInfo)
+	if impersonatedBy == nil {
+		return userInfo
+	}
+
+	return authenticationv1.UserInfo{
+		Username: impersonatedBy.Username,
+		Groups:   impersonatedBy.Groups,
+	}
+}
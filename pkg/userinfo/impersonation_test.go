@@ -0,0 +1,29 @@
+package userinfo
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func Test_GetImpersonatedBy(t *testing.T) {
+	userInfo := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:ci:deployer",
+		Groups:   []string{"system:serviceaccounts"},
+		Extra:    map[string]authenticationv1.ExtraValue{"scopes": {"deploy"}},
+	}
+
+	// the request is not impersonated
+	assert.Assert(t, GetImpersonatedBy(userInfo) == nil)
+	assert.DeepEqual(t, OriginalUser(userInfo), userInfo)
+
+	userInfo.Extra[OriginalUserExtraKey] = authenticationv1.ExtraValue{""}
+	assert.Assert(t, GetImpersonatedBy(userInfo) == nil)
+
+	// the request is impersonated
+	userInfo.Extra[OriginalUserExtraKey] = authenticationv1.ExtraValue{"alice"}
+	userInfo.Extra[OriginalGroupsExtraKey] = authenticationv1.ExtraValue{"admins"}
+	assert.DeepEqual(t, GetImpersonatedBy(userInfo), &ImpersonatedBy{Username: "alice", Groups: []string{"admins"}})
+	assert.DeepEqual(t, OriginalUser(userInfo), authenticationv1.UserInfo{Username: "alice", Groups: []string{"admins"}})
+}
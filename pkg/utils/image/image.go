@@ -0,0 +1,121 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/distribution/reference"
+	"github.com/minio/minio/pkg/wildcard"
+)
+
+// DefaultRegistry is the registry of the image references without a registry, e.g. "nginx"
+const DefaultRegistry = "docker.io"
+
+// DefaultTag is the tag of the image references without a tag or digest, e.g. "nginx"
+const DefaultTag = "latest"
+
+// Info is a parsed container image reference
+type Info struct {
+
+	// Registry is the host and optional port of the image registry e.g. `docker.io`, lowercased
+	Registry string `json:"registry,omitempty"`
+
+	// Name is the image name portion e.g. `busybox`
+	Name string `json:"name"`
+
+	// Path is the repository path and image name e.g. `some-repository/busybox`, the case is preserved
+	Path string `json:"path"`
+
+	// Tag is the image tag e.g. `v2`
+	Tag string `json:"tag,omitempty"`
+
+	// Digest is the image digest portion e.g. `sha256:128c6e3534b842a2eec139999b8ce8aa9a2af9907e2b9269550809d18cd832a3`
+	Digest string `json:"digest,omitempty"`
+}
+
+// String returns the normalized reference, e.g. `docker.io/nginx:latest`
+func (i *Info) String() string {
+	image := i.Registry + "/" + i.Path
+	if i.Tag != "" {
+		image = image + ":" + i.Tag
+	}
+
+	if i.Digest != "" {
+		image = image + "@" + i.Digest
+	}
+
+	return image
+}
+
+// Parse parses and normalizes an image reference. The images of the webhooks, the background scans
+// and the CLI are parsed with this function, so that policies see the same values in all of them.
+//
+// The registry host is lowercased, as host names are case-insensitive, and defaults to docker.io.
+// The path and tag are case-preserved. The tag defaults to "latest" only if the reference has neither
+// a tag nor a digest, the images pinned by their digest have no tag.
+func Parse(ref string) (*Info, error) {
+	if ref == "" || strings.TrimSpace(ref) != ref {
+		return nil, fmt.Errorf("bad image: %q", ref)
+	}
+
+	name := ref
+	var tag, digest string
+	if i := strings.Index(name, "@"); i != -1 {
+		name, digest = name[:i], name[i+1:]
+	}
+
+	if i := strings.LastIndex(name, ":"); i != -1 && i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+
+	registry, path := splitRegistry(name)
+	registry = strings.ToLower(registry)
+
+	// the reference grammar requires a lowercase path, the case of the path is preserved in the result
+	if _, err := reference.Parse(registry + "/" + strings.ToLower(path) + ref[len(name):]); err != nil {
+		return nil, fmt.Errorf("bad image: %s: %v", ref, err)
+	}
+
+	if tag == "" && digest == "" {
+		tag = DefaultTag
+	}
+
+	return &Info{
+		Registry: registry,
+		Name:     path[strings.LastIndex(path, "/")+1:],
+		Path:     path,
+		Tag:      tag,
+		Digest:   digest,
+	}, nil
+}
+
+// splitRegistry splits the registry from the path of the image name. The first component of
+// the name is the registry if it has a dot, a port, is localhost or is not lowercase, as Docker does.
+func splitRegistry(name string) (string, string) {
+	i := strings.IndexRune(name, '/')
+	if i == -1 || !isRegistry(name[:i]) {
+		return DefaultRegistry, name
+	}
+
+	return name[:i], name[i+1:]
+}
+
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost" || strings.ToLower(component) != component
+}
+
+// NormalizePattern lowercases the registry host of an image pattern, e.g. `Registry.Example.COM:5000/*`,
+// so that the patterns match the parsed images regardless of the case of the hosts
+func NormalizePattern(pattern string) string {
+	i := strings.IndexRune(pattern, '/')
+	if i == -1 || !isRegistry(pattern[:i]) {
+		return pattern
+	}
+
+	return strings.ToLower(pattern[:i]) + pattern[i:]
+}
+
+// Match checks if the normalized reference of the image matches the wildcard pattern
+func Match(pattern string, info *Info) bool {
+	return wildcard.Match(NormalizePattern(pattern), info.String())
+}
@@ -0,0 +1,135 @@
+package image
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const testDigest = "sha256:31aaf12480bd08c54e7990c6b0e43d775a7a84603d2921a6de4abbc317b2fd10"
+
+func Test_Parse(t *testing.T) {
+	testCases := []struct {
+		ref      string
+		expected Info
+		str      string
+	}{
+		{
+			ref:      "nginx",
+			expected: Info{Registry: "docker.io", Name: "nginx", Path: "nginx", Tag: "latest"},
+			str:      "docker.io/nginx:latest",
+		},
+		{
+			ref:      "library/nginx:1.21",
+			expected: Info{Registry: "docker.io", Name: "nginx", Path: "library/nginx", Tag: "1.21"},
+			str:      "docker.io/library/nginx:1.21",
+		},
+		{
+			// the host is lowercased, the path and the tag are case-preserved
+			ref:      "Registry.Example.COM:5000/Team/App:V1.2",
+			expected: Info{Registry: "registry.example.com:5000", Name: "App", Path: "Team/App", Tag: "V1.2"},
+			str:      "registry.example.com:5000/Team/App:V1.2",
+		},
+		{
+			ref:      "docker.io/Library/NGINX",
+			expected: Info{Registry: "docker.io", Name: "NGINX", Path: "Library/NGINX", Tag: "latest"},
+			str:      "docker.io/Library/NGINX:latest",
+		},
+		{
+			ref:      "localhost/nginx",
+			expected: Info{Registry: "localhost", Name: "nginx", Path: "nginx", Tag: "latest"},
+			str:      "localhost/nginx:latest",
+		},
+		{
+			ref:      "LocalHost:4443/test/nginx",
+			expected: Info{Registry: "localhost:4443", Name: "nginx", Path: "test/nginx", Tag: "latest"},
+			str:      "localhost:4443/test/nginx:latest",
+		},
+		{
+			// the images pinned by their digest have no default tag
+			ref:      "nginx@" + testDigest,
+			expected: Info{Registry: "docker.io", Name: "nginx", Path: "nginx", Digest: testDigest},
+			str:      "docker.io/nginx@" + testDigest,
+		},
+		{
+			ref:      "ghcr.io/org/app:v1.2-21.g5523e95@" + testDigest,
+			expected: Info{Registry: "ghcr.io", Name: "app", Path: "org/app", Tag: "v1.2-21.g5523e95", Digest: testDigest},
+			str:      "ghcr.io/org/app:v1.2-21.g5523e95@" + testDigest,
+		},
+		{
+			ref:      "mcr.microsoft.com/windows/servercore:ltsc2019",
+			expected: Info{Registry: "mcr.microsoft.com", Name: "servercore", Path: "windows/servercore", Tag: "ltsc2019"},
+			str:      "mcr.microsoft.com/windows/servercore:ltsc2019",
+		},
+		{
+			ref:      "MCR.Microsoft.com/dotnet/framework/aspnet:4.8-windowsservercore-ltsc2019",
+			expected: Info{Registry: "mcr.microsoft.com", Name: "aspnet", Path: "dotnet/framework/aspnet", Tag: "4.8-windowsservercore-ltsc2019"},
+			str:      "mcr.microsoft.com/dotnet/framework/aspnet:4.8-windowsservercore-ltsc2019",
+		},
+		{
+			ref:      "registry.example.com/a__b/c-d.e:_tag",
+			expected: Info{Registry: "registry.example.com", Name: "c-d.e", Path: "a__b/c-d.e", Tag: "_tag"},
+			str:      "registry.example.com/a__b/c-d.e:_tag",
+		},
+	}
+
+	for _, tc := range testCases {
+		info, err := Parse(tc.ref)
+		assert.NilError(t, err, tc.ref)
+		assert.DeepEqual(t, *info, tc.expected)
+		assert.Equal(t, info.String(), tc.str, tc.ref)
+
+		// the normalized reference is parsed to the same image
+		normalized, err := Parse(info.String())
+		assert.NilError(t, err, info.String())
+		assert.DeepEqual(t, normalized, info)
+	}
+}
+
+func Test_Parse_Invalid(t *testing.T) {
+	for _, ref := range []string{
+		"",
+		" nginx",
+		"nginx:",
+		"nginx@",
+		"nginx@sha256:123",
+		"nginx:1.21@sha256:123@sha256:456",
+		"registry.example.com/",
+		"registry.example.com//nginx",
+		`C:\images\nginx`,
+		"c:/images/nginx",
+		"registry.example.com:port/nginx",
+		"nginx:tag:tag",
+		"nginx:-tag",
+	} {
+		_, err := Parse(ref)
+		assert.Assert(t, err != nil, ref)
+	}
+}
+
+func Test_Match(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		ref     string
+		match   bool
+	}{
+		{pattern: "docker.io/nginx:*", ref: "nginx", match: true},
+		{pattern: "registry.example.com/*", ref: "Registry.Example.COM/team/app:v1", match: true},
+		{pattern: "Registry.Example.COM:5000/*", ref: "registry.example.com:5000/team/app", match: true},
+		{pattern: "registry.example.com/Team/*", ref: "registry.example.com/Team/app", match: true},
+		{pattern: "registry.example.com/team/*", ref: "registry.example.com/Team/app", match: false},
+		{pattern: "ghcr.io/app:*", ref: "ghcr.io/app@" + testDigest, match: false},
+		{pattern: "ghcr.io/*@*", ref: "ghcr.io/app@" + testDigest, match: true},
+		{pattern: "*", ref: "mcr.microsoft.com/windows/servercore:ltsc2019", match: true},
+	}
+
+	for _, tc := range testCases {
+		info, err := Parse(tc.ref)
+		assert.NilError(t, err, tc.ref)
+		assert.Equal(t, Match(tc.pattern, info), tc.match, tc.pattern+" "+tc.ref)
+	}
+
+	assert.Equal(t, NormalizePattern("Registry.Example.COM/Team/*"), "registry.example.com/Team/*")
+	assert.Equal(t, NormalizePattern("nginx:*"), "nginx:*")
+	assert.Equal(t, NormalizePattern("*"), "*")
+}
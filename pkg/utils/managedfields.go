@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/minio/pkg/wildcard"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RemoveManagedFields returns a copy of the resource without the fields owned by the field managers,
+// according to the managedFields of the resource. A field also owned by another manager is kept.
+// The managers can contain wildcards, e.g. "openshift-*".
+func RemoveManagedFields(resource unstructured.Unstructured, managers []string) (unstructured.Unstructured, error) {
+	owned := make(map[string]interface{})
+	others := make(map[string]interface{})
+	for _, entry := range resource.GetManagedFields() {
+		if entry.FieldsType != "FieldsV1" || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return resource, fmt.Errorf("failed to parse the managed fields of %s: %v", entry.Manager, err)
+		}
+
+		if isFieldManager(entry, managers) {
+			mergeFields(owned, fields)
+		} else {
+			mergeFields(others, fields)
+		}
+	}
+
+	projected := resource.DeepCopy()
+	if len(owned) == 0 {
+		return *projected, nil
+	}
+
+	if err := removeFields(projected.Object, owned, others); err != nil {
+		return resource, err
+	}
+
+	return *projected, nil
+}
+
+func isFieldManager(entry metav1.ManagedFieldsEntry, managers []string) bool {
+	for _, manager := range managers {
+		if wildcard.Match(manager, entry.Manager) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeFields adds the field set src to dst, the sets are in the FieldsV1 format
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		child, _ := value.(map[string]interface{})
+		dstChild, ok := dst[key].(map[string]interface{})
+		if !ok {
+			dstChild = make(map[string]interface{})
+			dst[key] = dstChild
+		}
+		mergeFields(dstChild, child)
+	}
+}
+
+// removeFields removes the fields of the owned set from the object, except the fields of the others set.
+// A field created by the field managers is removed with its children if no other manager owns the field or
+// its children, the owned children of the other fields, e.g. of the metadata, are removed one by one.
+func removeFields(obj map[string]interface{}, owned, others map[string]interface{}) error {
+	for key, value := range owned {
+		if !strings.HasPrefix(key, "f:") {
+			continue
+		}
+
+		name := strings.TrimPrefix(key, "f:")
+		child, ok := obj[name]
+		if !ok {
+			continue
+		}
+
+		ownedChild, _ := value.(map[string]interface{})
+		othersChild, shared := others[key].(map[string]interface{})
+		if !shared && isCreated(ownedChild) {
+			delete(obj, name)
+			continue
+		}
+
+		child, remove, err := removeChildFields(child, ownedChild, othersChild)
+		if err != nil {
+			return err
+		}
+
+		if remove {
+			delete(obj, name)
+		} else {
+			obj[name] = child
+		}
+	}
+
+	return nil
+}
+
+// removeListFields removes the elements of the owned set from the list, the elements of an associative
+// list are identified by their keys ("k:"), and the elements of a set by their value ("v:")
+func removeListFields(list []interface{}, owned, others map[string]interface{}) ([]interface{}, error) {
+	for key, value := range owned {
+		if !strings.HasPrefix(key, "k:") && !strings.HasPrefix(key, "v:") {
+			continue
+		}
+
+		var match interface{}
+		if err := json.Unmarshal([]byte(key[2:]), &match); err != nil {
+			return nil, fmt.Errorf("failed to parse the managed field %s: %v", key, err)
+		}
+
+		ownedChild, _ := value.(map[string]interface{})
+		othersChild, shared := others[key].(map[string]interface{})
+
+		result := make([]interface{}, 0, len(list))
+		for _, element := range list {
+			matched, err := listElementMatches(element, match, strings.HasPrefix(key, "k:"))
+			if err != nil {
+				return nil, err
+			}
+
+			if !matched {
+				result = append(result, element)
+				continue
+			}
+
+			if !shared && isCreated(ownedChild) {
+				continue
+			}
+
+			element, remove, err := removeChildFields(element, ownedChild, othersChild)
+			if err != nil {
+				return nil, err
+			}
+
+			if !remove {
+				result = append(result, element)
+			}
+		}
+
+		list = result
+	}
+
+	return list, nil
+}
+
+// removeChildFields removes the owned fields of a field also owned by other managers, and returns the updated field.
+// It returns true if the field itself must be removed, i.e. it is created by the field managers and is left empty.
+func removeChildFields(child interface{}, owned, others map[string]interface{}) (interface{}, bool, error) {
+	switch typed := child.(type) {
+	case map[string]interface{}:
+		if err := removeFields(typed, owned, others); err != nil {
+			return nil, false, err
+		}
+		return typed, len(typed) == 0 && isCreatedBy(owned, others), nil
+
+	case []interface{}:
+		list, err := removeListFields(typed, owned, others)
+		if err != nil {
+			return nil, false, err
+		}
+		return list, len(list) == 0 && isCreatedBy(owned, others), nil
+	}
+
+	return child, false, nil
+}
+
+// isCreated returns true if the owned set of a field owns the field itself, i.e. the field is a leaf or its "." is owned
+func isCreated(owned map[string]interface{}) bool {
+	_, created := owned["."]
+	return created || len(owned) == 0
+}
+
+// isCreatedBy returns true if the owned set, and not the others set, owns the field itself (".")
+func isCreatedBy(owned, others map[string]interface{}) bool {
+	_, created := owned["."]
+	_, shared := others["."]
+	return created && !shared
+}
+
+func listElementMatches(element, match interface{}, byKeys bool) (bool, error) {
+	if !byKeys {
+		return jsonEqual(element, match)
+	}
+
+	fields, ok := element.(map[string]interface{})
+	keys, ok2 := match.(map[string]interface{})
+	if !ok || !ok2 {
+		return false, nil
+	}
+
+	for name, value := range keys {
+		equal, err := jsonEqual(fields[name], value)
+		if err != nil || !equal {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// jsonEqual compares the values by their JSON encoding, the numbers of the
+// unstructured object and of the managed fields have different types
+func jsonEqual(a, b interface{}) (bool, error) {
+	aBytes, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+
+	bBytes, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+
+	return string(aBytes) == string(bBytes), nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"testing"
+
+	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_RemoveManagedFields(t *testing.T) {
+	resourceRaw := []byte(`{
+		"apiVersion": "v1",
+		"kind": "Pod",
+		"metadata": {
+			"name": "nginx",
+			"namespace": "default",
+			"annotations": {"openshift.io/scc": "restricted"},
+			"managedFields": [
+				{
+					"manager": "kubectl-client-side-apply",
+					"operation": "Update",
+					"apiVersion": "v1",
+					"fieldsType": "FieldsV1",
+					"fieldsV1": {"f:spec": {"f:containers": {"k:{\"name\":\"nginx\"}": {".": {}, "f:image": {}, "f:name": {}, "f:securityContext": {"f:runAsNonRoot": {}}}}}}
+				},
+				{
+					"manager": "openshift-scc",
+					"operation": "Update",
+					"apiVersion": "v1",
+					"fieldsType": "FieldsV1",
+					"fieldsV1": {
+						"f:metadata": {"f:annotations": {".": {}, "f:openshift.io/scc": {}}},
+						"f:spec": {
+							"f:containers": {
+								"k:{\"name\":\"nginx\"}": {"f:securityContext": {".": {}, "f:runAsUser": {}, "f:capabilities": {".": {}, "f:drop": {}}}},
+								"k:{\"name\":\"sidecar\"}": {".": {}, "f:image": {}, "f:name": {}}
+							}
+						}
+					}
+				}
+			]
+		},
+		"spec": {
+			"containers": [
+				{"name": "nginx", "image": "nginx", "securityContext": {"runAsNonRoot": true, "runAsUser": 1000680000, "capabilities": {"drop": ["KILL", "MKNOD"]}}},
+				{"name": "sidecar", "image": "sidecar"}
+			]
+		}
+	}`)
+
+	resource, err := engineutils.ConvertToUnstructured(resourceRaw)
+	assert.NilError(t, err)
+
+	projected, err := RemoveManagedFields(*resource, []string{"openshift-*"})
+	assert.NilError(t, err)
+
+	// the fields set by the user are kept, including the fields of the shared securityContext
+	containers, _, err := unstructured.NestedSlice(projected.Object, "spec", "containers")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, containers, []interface{}{
+		map[string]interface{}{"name": "nginx", "image": "nginx", "securityContext": map[string]interface{}{"runAsNonRoot": true}},
+	})
+
+	// the metadata is not created by the platform, only the annotations are removed
+	_, found, _ := unstructured.NestedMap(projected.Object, "metadata", "annotations")
+	assert.Assert(t, !found)
+	assert.Equal(t, projected.GetName(), "nginx")
+
+	// the resource is not modified
+	_, found, _ = unstructured.NestedFieldNoCopy(resource.Object, "metadata", "annotations", "openshift.io/scc")
+	assert.Assert(t, found)
+
+	// no field is removed without platform field managers
+	projected, err = RemoveManagedFields(*resource, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, projected.Object, resource.Object)
+}
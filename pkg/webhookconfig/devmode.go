@@ -0,0 +1,82 @@
+package webhookconfig
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kyverno/kyverno/pkg/config"
+	errorsapi "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// CheckDevMode validates the out-of-cluster registration. With a server IP the webhooks are registered with
+// a URL instead of the Kyverno service, the API server then sends the admission requests to the local process.
+// This must never happen by accident in a cluster, the URL mode requires the explicit dev mode.
+func CheckDevMode(serverIP string, devMode bool) error {
+	if serverIP == "" {
+		return nil
+	}
+
+	if !devMode {
+		return fmt.Errorf("--serverIP registers the webhooks with the URL https://%s, it requires --devMode", serverIP)
+	}
+
+	// the port is optional, the API server calls the port 443 without it
+	host := serverIP
+	if strings.Contains(serverIP, ":") {
+		h, port, err := net.SplitHostPort(serverIP)
+		if err != nil {
+			return fmt.Errorf("invalid --serverIP %q, expected host:port: %v", serverIP, err)
+		}
+
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("invalid --serverIP %q, expected host:port", serverIP)
+		}
+
+		host = h
+	}
+
+	if host == "" || strings.ContainsAny(host, "/?#@ ") {
+		return fmt.Errorf("invalid --serverIP %q, expected host:port", serverIP)
+	}
+
+	return nil
+}
+
+// webhookConfiguration is the kind and name of a webhook configuration
+type webhookConfiguration struct {
+	kind string
+	name string
+}
+
+// serviceWebhookConfigurations returns the webhook configurations registered with the Kyverno service
+func serviceWebhookConfigurations() []webhookConfiguration {
+	return []webhookConfiguration{
+		{kind: kindMutating, name: config.MutatingWebhookConfigurationName},
+		{kind: kindValidating, name: config.ValidatingWebhookConfigurationName},
+		{kind: kindMutating, name: config.PolicyMutatingWebhookConfigurationName},
+		{kind: kindValidating, name: config.PolicyValidatingWebhookConfigurationName},
+		{kind: kindMutating, name: config.VerifyMutatingWebhookConfigurationName},
+	}
+}
+
+// removeServiceWebhookConfigurations removes the webhook configurations registered with the Kyverno service,
+// the admission requests are sent to both the service and the URL otherwise
+func (wrc *Register) removeServiceWebhookConfigurations() {
+	for _, cfg := range serviceWebhookConfigurations() {
+		logger := wrc.log.WithValues("kind", cfg.kind, "name", cfg.name)
+		err := wrc.client.DeleteResource("", cfg.kind, "", cfg.name, false)
+		if errorsapi.IsNotFound(err) {
+			logger.V(4).Info("webhook configuration not found")
+			continue
+		}
+
+		if err != nil {
+			logger.Error(err, "failed to delete the service webhook configuration")
+			continue
+		}
+
+		logger.Info("service webhook configuration deleted")
+	}
+}
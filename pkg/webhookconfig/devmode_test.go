@@ -0,0 +1,84 @@
+package webhookconfig
+
+import (
+	"testing"
+
+	"github.com/kyverno/kyverno/pkg/config"
+	"gotest.tools/assert"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_CheckDevMode(t *testing.T) {
+	// the webhooks are registered with the service
+	assert.NilError(t, CheckDevMode("", false))
+	assert.NilError(t, CheckDevMode("", true))
+
+	// the URL mode requires the dev mode
+	assert.ErrorContains(t, CheckDevMode("192.168.1.10:9443", false), "requires --devMode")
+	assert.NilError(t, CheckDevMode("192.168.1.10:9443", true))
+	assert.NilError(t, CheckDevMode("192.168.1.10", true))
+	assert.NilError(t, CheckDevMode("kyverno.local:9443", true))
+
+	for _, serverIP := range []string{":9443", "192.168.1.10:", "https://192.168.1.10:9443", "192.168.1.10:9443/mutate"} {
+		assert.ErrorContains(t, CheckDevMode(serverIP, true), "invalid --serverIP", serverIP)
+	}
+}
+
+func Test_ClientConfig_URL(t *testing.T) {
+	caData := []byte("ca-bundle")
+	wrc := &Register{serverIP: "192.168.1.10:9443", timeoutSeconds: 10, log: log.Log}
+
+	mutating := wrc.constructDefaultDebugMutatingWebhookConfig(caData)
+	assert.Equal(t, mutating.Name, config.MutatingWebhookConfigurationDebugName)
+	assertURLClientConfig(t, mutating.Webhooks[0].ClientConfig, "https://192.168.1.10:9443/mutate", caData)
+
+	validating := wrc.constructDefaultDebugValidatingWebhookConfig(caData)
+	assert.Equal(t, validating.Name, config.ValidatingWebhookConfigurationDebugName)
+	assertURLClientConfig(t, validating.Webhooks[0].ClientConfig, "https://192.168.1.10:9443/validate", caData)
+
+	verify := wrc.constructDebugVerifyMutatingWebhookConfig(caData)
+	assert.Equal(t, verify.Name, config.VerifyMutatingWebhookConfigurationDebugName)
+	assertURLClientConfig(t, verify.Webhooks[0].ClientConfig, "https://192.168.1.10:9443/verifymutate", caData)
+
+	// the URL mode uses the debug configurations, the service configurations are removed
+	assert.Equal(t, wrc.getResourceMutatingWebhookConfigName(), config.MutatingWebhookConfigurationDebugName)
+	assert.Equal(t, wrc.getResourceValidatingWebhookConfigName(), config.ValidatingWebhookConfigurationDebugName)
+	for _, cfg := range serviceWebhookConfigurations() {
+		assert.Assert(t, cfg.name != wrc.getResourceMutatingWebhookConfigName() && cfg.name != wrc.getResourceValidatingWebhookConfigName() &&
+			cfg.name != wrc.getPolicyMutatingWebhookConfigurationName() && cfg.name != wrc.getPolicyValidatingWebhookConfigurationName() &&
+			cfg.name != wrc.getVerifyWebhookMutatingWebhookName(), cfg.name)
+	}
+}
+
+func Test_ClientConfig_Service(t *testing.T) {
+	caData := []byte("ca-bundle")
+	wrc := &Register{timeoutSeconds: 10, log: log.Log}
+
+	mutating := generateMutatingWebhook(config.MutatingWebhookName, config.MutatingWebhookServicePath, caData, false, wrc.timeoutSeconds,
+		[]string{"*/*"}, "*", "*", []admregapi.OperationType{admregapi.Create, admregapi.Update})
+	assertServiceClientConfig(t, mutating.ClientConfig, "/mutate", caData)
+
+	validating := generateValidatingWebhook(config.ValidatingWebhookName, config.ValidatingWebhookServicePath, caData, true, wrc.timeoutSeconds,
+		[]string{"*/*"}, "*", "*", []admregapi.OperationType{admregapi.Create, admregapi.Update})
+	assertServiceClientConfig(t, validating.ClientConfig, "/validate", caData)
+
+	assert.Equal(t, wrc.getResourceMutatingWebhookConfigName(), config.MutatingWebhookConfigurationName)
+	assert.Equal(t, wrc.getResourceValidatingWebhookConfigName(), config.ValidatingWebhookConfigurationName)
+}
+
+func assertURLClientConfig(t *testing.T, clientConfig admregapi.WebhookClientConfig, url string, caData []byte) {
+	assert.Assert(t, clientConfig.Service == nil)
+	assert.Assert(t, clientConfig.URL != nil)
+	assert.Equal(t, *clientConfig.URL, url)
+	assert.DeepEqual(t, clientConfig.CABundle, caData)
+}
+
+func assertServiceClientConfig(t *testing.T, clientConfig admregapi.WebhookClientConfig, path string, caData []byte) {
+	assert.Assert(t, clientConfig.URL == nil)
+	assert.Assert(t, clientConfig.Service != nil)
+	assert.Equal(t, clientConfig.Service.Namespace, config.KyvernoNamespace)
+	assert.Equal(t, clientConfig.Service.Name, config.KyvernoServiceName)
+	assert.Equal(t, *clientConfig.Service.Path, path)
+	assert.DeepEqual(t, clientConfig.CABundle, caData)
+}
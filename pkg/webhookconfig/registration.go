@@ -42,6 +42,18 @@ type Register struct {
 	log            logr.Logger
 	debug          bool
 
+	// deregisterOnShutdown removes the webhook configurations when the last available replica shuts down
+	deregisterOnShutdown bool
+
+	// resourceRules are the rules of the resource webhooks by webhook key, the configurations are
+	// registered with the wildcard rule until the rules are set
+	resourceRules map[string][]admregapi.RuleWithOperations
+
+	// resourceTimeouts are the timeouts of the resource webhooks by webhook key, the configurations
+	// are registered with timeoutSeconds until the timeouts are set
+	resourceTimeouts  map[string]int32
+	resourceRulesLock sync.RWMutex
+
 	UpdateWebhookChan chan bool
 }
 
@@ -53,17 +65,78 @@ func NewRegister(
 	serverIP string,
 	webhookTimeout int32,
 	debug bool,
+	deregisterOnShutdown bool,
 	log logr.Logger) *Register {
 	return &Register{
-		clientConfig:      clientConfig,
-		client:            client,
-		resCache:          resCache,
-		serverIP:          serverIP,
-		timeoutSeconds:    webhookTimeout,
-		log:               log.WithName("Register"),
-		debug:             debug,
-		UpdateWebhookChan: make(chan bool),
+		clientConfig:         clientConfig,
+		client:               client,
+		resCache:             resCache,
+		serverIP:             serverIP,
+		timeoutSeconds:       webhookTimeout,
+		log:                  log.WithName("Register"),
+		debug:                debug,
+		deregisterOnShutdown: deregisterOnShutdown,
+		UpdateWebhookChan:    make(chan bool),
+	}
+}
+
+// SetResourceWebhookRules sets the rules of the resource webhook with the key, used when its
+// configuration is registered. The key of the webhook whose failure policy is Ignore is the kind of
+// its configuration, i.e. MutatingWebhookConfiguration or ValidatingWebhookConfiguration, the key of
+// the webhook whose failure policy is Fail is the kind suffixed with "/Fail".
+func (wrc *Register) SetResourceWebhookRules(key string, rules []admregapi.RuleWithOperations) {
+	wrc.resourceRulesLock.Lock()
+	defer wrc.resourceRulesLock.Unlock()
+
+	if wrc.resourceRules == nil {
+		wrc.resourceRules = make(map[string][]admregapi.RuleWithOperations)
+	}
+	wrc.resourceRules[key] = rules
+}
+
+// ResourceWebhookRules returns the rules of the resource webhooks by webhook key
+func (wrc *Register) ResourceWebhookRules() map[string][]admregapi.RuleWithOperations {
+	wrc.resourceRulesLock.RLock()
+	defer wrc.resourceRulesLock.RUnlock()
+
+	rules := make(map[string][]admregapi.RuleWithOperations, len(wrc.resourceRules))
+	for key, webhookRules := range wrc.resourceRules {
+		rules[key] = webhookRules
 	}
+	return rules
+}
+
+// setResourceWebhooks sets the rules and the timeouts of the webhooks of the resource webhook configuration of the kind
+func (wrc *Register) setResourceWebhooks(kind string, webhooks map[admregapi.FailurePolicyType]webhookSettings) {
+	for failurePolicy, settings := range webhooks {
+		key := resourceWebhookKey(kind, failurePolicy)
+		wrc.SetResourceWebhookRules(key, settings.Rules)
+
+		wrc.resourceRulesLock.Lock()
+		if wrc.resourceTimeouts == nil {
+			wrc.resourceTimeouts = make(map[string]int32)
+		}
+		wrc.resourceTimeouts[key] = settings.TimeoutSeconds
+		wrc.resourceRulesLock.Unlock()
+	}
+}
+
+// resourceWebhookRules returns the rules set for the resource webhook with the key
+func (wrc *Register) resourceWebhookRules(key string) ([]admregapi.RuleWithOperations, bool) {
+	wrc.resourceRulesLock.RLock()
+	defer wrc.resourceRulesLock.RUnlock()
+
+	rules, ok := wrc.resourceRules[key]
+	return rules, ok
+}
+
+// resourceWebhookTimeout returns the timeout set for the resource webhook with the key
+func (wrc *Register) resourceWebhookTimeout(key string) (int32, bool) {
+	wrc.resourceRulesLock.RLock()
+	defer wrc.resourceRulesLock.RUnlock()
+
+	timeout, ok := wrc.resourceTimeouts[key]
+	return timeout, ok
 }
 
 // Register clean up the old webhooks and re-creates admission webhooks configs on cluster
@@ -78,6 +151,9 @@ func (wrc *Register) Register() error {
 		}
 	}
 	wrc.removeWebhookConfigurations()
+	if wrc.serverIP != "" {
+		wrc.removeServiceWebhookConfigurations()
+	}
 
 	caData := wrc.readCaData()
 	if caData == nil {
@@ -217,7 +293,8 @@ func (wrc *Register) ValidateWebhookConfigurations(namespace, name string) error
 	return json.Unmarshal([]byte(webhooks), &webhookCfgs)
 }
 
-// cleanupKyvernoResource returns true if Kyverno deployment is terminating
+// cleanupKyvernoResource returns true if Kyverno deployment is terminating, or if this is the last
+// available replica and the webhooks are deregistered on shutdown
 func (wrc *Register) cleanupKyvernoResource() bool {
 	logger := wrc.log.WithName("cleanupKyvernoResource")
 	deploy, err := wrc.client.GetResource("", "Deployment", deployNamespace, deployName)
@@ -241,6 +318,17 @@ func (wrc *Register) cleanupKyvernoResource() bool {
 		return true
 	}
 
+	if wrc.deregisterOnShutdown {
+		// the replica shutting down is still counted, another available replica keeps serving
+		available, _, err := unstructured.NestedInt64(deploy.UnstructuredContent(), "status", "availableReplicas")
+		if err != nil {
+			logger.Error(err, "unable to fetch status.availableReplicas of Kyverno deployment")
+		} else if available <= 1 {
+			logger.Info("last available Kyverno replica is shutting down, cleanup Kyverno resources", "availableReplicas", available)
+			return true
+		}
+	}
+
 	logger.Info("updating Kyverno Pod, won't clean up Kyverno resources")
 	return false
 }
@@ -254,6 +342,17 @@ func (wrc *Register) createResourceMutatingWebhookConfiguration(caData []byte) e
 		config = wrc.constructDefaultMutatingWebhookConfig(caData)
 	}
 
+	for i, webhook := range config.Webhooks {
+		key := resourceWebhookKey(kindMutating, *webhook.FailurePolicy)
+		if rules, ok := wrc.resourceWebhookRules(key); ok {
+			config.Webhooks[i].Rules = rules
+		}
+
+		if timeout, ok := wrc.resourceWebhookTimeout(key); ok {
+			config.Webhooks[i].TimeoutSeconds = &timeout
+		}
+	}
+
 	logger := wrc.log.WithValues("kind", kindMutating, "name", config.Name)
 
 	_, err := wrc.client.CreateResource("", kindMutating, "", *config, false)
@@ -280,6 +379,17 @@ func (wrc *Register) createResourceValidatingWebhookConfiguration(caData []byte)
 		config = wrc.constructDefaultValidatingWebhookConfig(caData)
 	}
 
+	for i, webhook := range config.Webhooks {
+		key := resourceWebhookKey(kindValidating, *webhook.FailurePolicy)
+		if rules, ok := wrc.resourceWebhookRules(key); ok {
+			config.Webhooks[i].Rules = rules
+		}
+
+		if timeout, ok := wrc.resourceWebhookTimeout(key); ok {
+			config.Webhooks[i].TimeoutSeconds = &timeout
+		}
+	}
+
 	logger := wrc.log.WithValues("kind", kindValidating, "name", config.Name)
 
 	_, err := wrc.client.CreateResource("", kindValidating, "", *config, false)
@@ -634,19 +744,18 @@ func (wrc *Register) updateResourceValidatingWebhookConfiguration(nsSelector map
 		return errors.Wrapf(err, "unable to load validatingWebhookConfigurations.webhooks")
 	}
 
-	var webhooks map[string]interface{}
-	var ok bool
-	if webhooksUntyped != nil {
-		webhooks, ok = webhooksUntyped[0].(map[string]interface{})
+	for i, webhookUntyped := range webhooksUntyped {
+		webhook, ok := webhookUntyped.(map[string]interface{})
 		if !ok {
-			return errors.Wrapf(err, "type mismatched, expected map[string]interface{}, got %T", webhooksUntyped[0])
+			return fmt.Errorf("type mismatched, expected map[string]interface{}, got %T", webhookUntyped)
+		}
+
+		if err = unstructured.SetNestedMap(webhook, nsSelector, "namespaceSelector"); err != nil {
+			return errors.Wrapf(err, "unable to set validatingWebhookConfigurations.webhooks[%d].namespaceSelector", i)
 		}
-	}
-	if err = unstructured.SetNestedMap(webhooks, nsSelector, "namespaceSelector"); err != nil {
-		return errors.Wrapf(err, "unable to set validatingWebhookConfigurations.webhooks[0].namespaceSelector")
 	}
 
-	if err = unstructured.SetNestedSlice(resourceValidating.UnstructuredContent(), []interface{}{webhooks}, "webhooks"); err != nil {
+	if err = unstructured.SetNestedSlice(resourceValidating.UnstructuredContent(), webhooksUntyped, "webhooks"); err != nil {
 		return errors.Wrapf(err, "unable to set validatingWebhookConfigurations.webhooks")
 	}
 
@@ -670,19 +779,18 @@ func (wrc *Register) updateResourceMutatingWebhookConfiguration(nsSelector map[s
 		return errors.Wrapf(err, "unable to load mutatingWebhookConfigurations.webhooks")
 	}
 
-	var webhooks map[string]interface{}
-	var ok bool
-	if webhooksUntyped != nil {
-		webhooks, ok = webhooksUntyped[0].(map[string]interface{})
+	for i, webhookUntyped := range webhooksUntyped {
+		webhook, ok := webhookUntyped.(map[string]interface{})
 		if !ok {
-			return errors.Wrapf(err, "type mismatched, expected map[string]interface{}, got %T", webhooksUntyped[0])
+			return fmt.Errorf("type mismatched, expected map[string]interface{}, got %T", webhookUntyped)
+		}
+
+		if err = unstructured.SetNestedMap(webhook, nsSelector, "namespaceSelector"); err != nil {
+			return errors.Wrapf(err, "unable to set mutatingWebhookConfigurations.webhooks[%d].namespaceSelector", i)
 		}
-	}
-	if err = unstructured.SetNestedMap(webhooks, nsSelector, "namespaceSelector"); err != nil {
-		return errors.Wrapf(err, "unable to set mutatingWebhookConfigurations.webhooks[0].namespaceSelector")
 	}
 
-	if err = unstructured.SetNestedSlice(resourceMutating.UnstructuredContent(), []interface{}{webhooks}, "webhooks"); err != nil {
+	if err = unstructured.SetNestedSlice(resourceMutating.UnstructuredContent(), webhooksUntyped, "webhooks"); err != nil {
 		return errors.Wrapf(err, "unable to set mutatingWebhookConfigurations.webhooks")
 	}
 
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"testing"
 
+	client "github.com/kyverno/kyverno/pkg/dclient"
 	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	rest "k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var cert = `
@@ -73,3 +78,30 @@ func TestExtractCA_InvalidFile(t *testing.T) {
 	actual := extractCA(config)
 	assert.Assert(t, actual == nil)
 }
+
+func Test_CleanupKyvernoResource_DeregisterOnShutdown(t *testing.T) {
+	newRegister := func(replicas, availableReplicas int64, deregisterOnShutdown bool) *Register {
+		deploy := &unstructured.Unstructured{}
+		deploy.SetAPIVersion("apps/v1")
+		deploy.SetKind("Deployment")
+		deploy.SetNamespace(deployNamespace)
+		deploy.SetName(deployName)
+		assert.NilError(t, unstructured.SetNestedField(deploy.Object, replicas, "spec", "replicas"))
+		assert.NilError(t, unstructured.SetNestedField(deploy.Object, availableReplicas, "status", "availableReplicas"))
+
+		deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+		c, err := client.NewMockClient(runtime.NewScheme(), map[schema.GroupVersionResource]string{deployments: "DeploymentList"}, deploy)
+		assert.NilError(t, err)
+		c.SetDiscovery(client.NewFakeDiscoveryClient(nil))
+
+		return &Register{client: c, deregisterOnShutdown: deregisterOnShutdown, log: log.Log}
+	}
+
+	// the webhooks are kept while other replicas are available, e.g. during a rolling update
+	assert.Assert(t, !newRegister(2, 2, true).cleanupKyvernoResource())
+	assert.Assert(t, !newRegister(1, 1, false).cleanupKyvernoResource())
+
+	assert.Assert(t, newRegister(1, 1, true).cleanupKyvernoResource())
+	assert.Assert(t, newRegister(2, 1, true).cleanupKyvernoResource())
+	assert.Assert(t, newRegister(0, 1, false).cleanupKyvernoResource())
+}
@@ -31,6 +31,17 @@ func (wrc *Register) constructDefaultDebugMutatingWebhookConfig(caData []byte) *
 				"*",
 				[]admregapi.OperationType{admregapi.Create, admregapi.Update},
 			),
+			failMutatingWebhook(generateDebugMutatingWebhook(
+				config.MutatingFailWebhookName,
+				fmt.Sprintf("https://%s%s", wrc.serverIP, config.MutatingFailWebhookServicePath),
+				caData,
+				true,
+				wrc.timeoutSeconds,
+				nil,
+				"*",
+				"*",
+				nil,
+			)),
 		},
 	}
 }
@@ -47,6 +58,13 @@ func (wrc *Register) constructDefaultMutatingWebhookConfig(caData []byte) *admre
 	reinvoke := admregapi.IfNeededReinvocationPolicy
 	webhookCfg.ReinvocationPolicy = &reinvoke
 
+	failWebhookCfg := failMutatingWebhook(generateMutatingWebhook(
+		config.MutatingFailWebhookName,
+		config.MutatingFailWebhookServicePath,
+		caData, false, wrc.timeoutSeconds,
+		nil, "*", "*", nil))
+	failWebhookCfg.ReinvocationPolicy = &reinvoke
+
 	return &admregapi.MutatingWebhookConfiguration{
 		ObjectMeta: v1.ObjectMeta{
 			Name: config.MutatingWebhookConfigurationName,
@@ -54,10 +72,38 @@ func (wrc *Register) constructDefaultMutatingWebhookConfig(caData []byte) *admre
 				wrc.constructOwner(),
 			},
 		},
-		Webhooks: []admregapi.MutatingWebhook{webhookCfg},
+		Webhooks: []admregapi.MutatingWebhook{webhookCfg, failWebhookCfg},
 	}
 }
 
+// resourceWebhookKey returns the key of the rules of the resource webhook with the failure policy
+// in the resource webhook configuration of the kind
+func resourceWebhookKey(kind string, failurePolicy admregapi.FailurePolicyType) string {
+	if failurePolicy == admregapi.Fail {
+		return kind + "/" + string(admregapi.Fail)
+	}
+
+	return kind
+}
+
+// failMutatingWebhook sets the failure policy Fail on the webhook serving the policies whose
+// failure policy is Fail, it has no rules until the rules of the policies are set
+func failMutatingWebhook(webhook admregapi.MutatingWebhook) admregapi.MutatingWebhook {
+	failurePolicy := admregapi.Fail
+	webhook.FailurePolicy = &failurePolicy
+	webhook.Rules = nil
+	return webhook
+}
+
+// failValidatingWebhook sets the failure policy Fail on the webhook serving the policies whose
+// failure policy is Fail, it has no rules until the rules of the policies are set
+func failValidatingWebhook(webhook admregapi.ValidatingWebhook) admregapi.ValidatingWebhook {
+	failurePolicy := admregapi.Fail
+	webhook.FailurePolicy = &failurePolicy
+	webhook.Rules = nil
+	return webhook
+}
+
 //getResourceMutatingWebhookConfigName returns the webhook configuration name
 func (wrc *Register) getResourceMutatingWebhookConfigName() string {
 	if wrc.serverIP != "" {
@@ -113,6 +159,17 @@ func (wrc *Register) constructDefaultDebugValidatingWebhookConfig(caData []byte)
 				"*",
 				[]admregapi.OperationType{admregapi.Create, admregapi.Update, admregapi.Delete, admregapi.Connect},
 			),
+			failValidatingWebhook(generateDebugValidatingWebhook(
+				config.ValidatingFailWebhookName,
+				fmt.Sprintf("https://%s%s", wrc.serverIP, config.ValidatingFailWebhookServicePath),
+				caData,
+				true,
+				wrc.timeoutSeconds,
+				nil,
+				"*",
+				"*",
+				nil,
+			)),
 		},
 	}
 }
@@ -137,6 +194,17 @@ func (wrc *Register) constructDefaultValidatingWebhookConfig(caData []byte) *adm
 				"*",
 				[]admregapi.OperationType{admregapi.Create, admregapi.Update, admregapi.Delete, admregapi.Connect},
 			),
+			failValidatingWebhook(generateValidatingWebhook(
+				config.ValidatingFailWebhookName,
+				config.ValidatingFailWebhookServicePath,
+				caData,
+				false,
+				wrc.timeoutSeconds,
+				nil,
+				"*",
+				"*",
+				nil,
+			)),
 		},
 	}
 }
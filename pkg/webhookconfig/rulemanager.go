@@ -0,0 +1,415 @@
+package webhookconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernoclient "github.com/kyverno/kyverno/pkg/client/clientset/versioned"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"github.com/pkg/errors"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	errorsapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ruleUpdateDebounce is the delay to batch policy cache changes into a single webhook update
+	ruleUpdateDebounce = time.Second
+
+	// ruleResyncPeriod is the period to re-check the webhook rules, e.g. when the webhook
+	// configurations are modified, or when the CRDs of unresolved kinds are installed
+	ruleResyncPeriod = time.Minute
+
+	// ruleObserveTimeout is the time to wait for the informer to observe a webhook update
+	ruleObserveTimeout = 10 * time.Second
+
+	// policyReasonWebhookConfigured is the Ready condition reason
+	policyReasonWebhookConfigured = "WebhookConfigured"
+)
+
+var (
+	mutatingOperations   = []admregapi.OperationType{admregapi.Create, admregapi.Update}
+	validatingOperations = []admregapi.OperationType{admregapi.Create, admregapi.Update, admregapi.Delete, admregapi.Connect}
+
+	// resourceFailurePolicies are the failure policies of the webhooks of the resource webhook configurations
+	resourceFailurePolicies = []admregapi.FailurePolicyType{admregapi.Ignore, admregapi.Fail}
+)
+
+// webhookSettings are the settings of a resource webhook derived from the policies it serves
+type webhookSettings struct {
+	Rules          []admregapi.RuleWithOperations
+	TimeoutSeconds int32
+}
+
+// RuleManager narrows the rules of the resource webhook configurations to the kinds matched by the
+// cached policies, so that the API server only sends the requests that policies apply to.
+// Each configuration has a webhook whose failure policy is Ignore and a webhook whose failure policy
+// is Fail, the latter serves the policies whose failure policy is set to Fail. The timeout of a webhook
+// is the largest webhook timeout of the policies it serves.
+// The webhook configurations are registered with the wildcard rule, or with the rules restored from
+// the policy cache snapshot, and narrowed once the policy cache is warmed up, then updated whenever
+// a policy is added to or removed from the cache.
+//
+// A policy is not applied to the requests of a kind until the webhook configurations include the kind.
+// The Ready condition is set on the policies only after the updated configurations are observed, so
+// that a policy is not reported ready, e.g. to a CI pipeline, while it can still be bypassed.
+type RuleManager struct {
+	register      *Register
+	pCache        policyGetter
+	cacheEvents   <-chan policycache.CacheEvent
+	unsubscribe   func()
+	cacheSynced   cache.InformerSynced
+	resolver      kindResolver
+	kyvernoClient kyvernoclient.Interface
+	log           logr.Logger
+}
+
+// NewRuleManager returns a new instance of the webhook rule manager. The cacheSynced function
+// must return true once the policy cache is warmed up, the rules are not narrowed before.
+func NewRuleManager(
+	register *Register,
+	pCache policycache.Interface,
+	cacheSynced cache.InformerSynced,
+	resolver kindResolver,
+	kyvernoClient kyvernoclient.Interface,
+	log logr.Logger) *RuleManager {
+	cacheEvents, unsubscribe := pCache.Subscribe()
+	return &RuleManager{
+		register:      register,
+		pCache:        pCache,
+		cacheEvents:   cacheEvents,
+		unsubscribe:   unsubscribe,
+		cacheSynced:   cacheSynced,
+		resolver:      resolver,
+		kyvernoClient: kyvernoClient,
+		log:           log,
+	}
+}
+
+// Run updates the webhook rules when the policy cache changes, and periodically.
+// The subscription to the policy cache is cancelled once stopped.
+func (m *RuleManager) Run(stopCh <-chan struct{}) {
+	logger := m.log
+	logger.Info("starting")
+	defer logger.Info("shutting down")
+	defer m.unsubscribe()
+
+	if !cache.WaitForCacheSync(stopCh, m.cacheSynced) {
+		logger.Info("failed to wait for the policy cache to warm up")
+		return
+	}
+
+	debounce := time.NewTimer(0)
+	defer debounce.Stop()
+
+	resync := time.NewTicker(ruleResyncPeriod)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-m.cacheEvents:
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(ruleUpdateDebounce)
+
+		case <-debounce.C:
+			if err := m.sync(); err != nil {
+				logger.Error(err, "failed to update webhook rules")
+			}
+
+		case <-resync.C:
+			if err := m.sync(); err != nil {
+				logger.Error(err, "failed to update webhook rules")
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// sync updates the resource webhooks to the kinds and the timeouts of the cached policies, and sets
+// the Ready condition on the policies once the updates are observed
+func (m *RuleManager) sync() error {
+	// the webhooks and the ready policies are computed from the same snapshot, policies
+	// added to the cache afterwards are marked ready by the next sync
+	snapshot := takeSnapshot(m.pCache)
+	mutating, validating := m.desiredWebhooks(snapshot)
+
+	if err := m.updateWebhooks(kindMutating, m.register.getResourceMutatingWebhookConfigName(), mutating); err != nil {
+		return err
+	}
+
+	if err := m.updateWebhooks(kindValidating, m.register.getResourceValidatingWebhookConfigName(), validating); err != nil {
+		return err
+	}
+
+	for _, policy := range snapshot.policies() {
+		m.setReady(policy)
+	}
+
+	return nil
+}
+
+// desiredWebhooks returns the settings of the mutating and the validating resource webhooks by failure
+// policy for the policies of the snapshot
+func (m *RuleManager) desiredWebhooks(snapshot policySnapshot) (mutating, validating map[admregapi.FailurePolicyType]webhookSettings) {
+	mutating = make(map[admregapi.FailurePolicyType]webhookSettings, len(resourceFailurePolicies))
+	validating = make(map[admregapi.FailurePolicyType]webhookSettings, len(resourceFailurePolicies))
+	for _, failurePolicy := range resourceFailurePolicies {
+		served := snapshot.served(failurePolicy)
+
+		mutating[failurePolicy] = webhookSettings{
+			Rules: m.buildRules(kindMutating, mutatingOperations, func(operation admregapi.OperationType) []string {
+				return mutatingKinds(served, operation)
+			}),
+			TimeoutSeconds: served.timeoutSeconds(m.register.timeoutSeconds, policycache.Mutate, policycache.VerifyImages, policycache.Generate),
+		}
+
+		validating[failurePolicy] = webhookSettings{
+			Rules: m.buildRules(kindValidating, validatingOperations, func(operation admregapi.OperationType) []string {
+				return validatingKinds(served, operation)
+			}),
+			TimeoutSeconds: served.timeoutSeconds(m.register.timeoutSeconds, policycache.ValidateEnforce, policycache.ValidateAudit, policycache.Generate),
+		}
+	}
+
+	return mutating, validating
+}
+
+// buildRules returns the rules of each operation for the kinds of the requests of the operation,
+// the rules of the operations matching the same kinds are merged
+func (m *RuleManager) buildRules(kind string, operations []admregapi.OperationType, operationKinds func(admregapi.OperationType) []string) []admregapi.RuleWithOperations {
+	var result []admregapi.RuleWithOperations
+	for _, operation := range operations {
+		rules, unresolved := buildRules(operationKinds(operation), m.resolver)
+		if len(unresolved) > 0 {
+			m.log.Info("failed to resolve policy kinds, using the wildcard rule", "kind", kind, "operation", operation, "kinds", unresolved)
+		}
+
+		for _, rule := range rules {
+			result = append(result, admregapi.RuleWithOperations{Operations: []admregapi.OperationType{operation}, Rule: rule})
+		}
+	}
+
+	return optimizeRules(result)
+}
+
+// updateWebhooks sets the rules and the timeouts of the webhooks of the configuration by failure policy,
+// and waits until the update is observed
+func (m *RuleManager) updateWebhooks(kind, name string, desired map[admregapi.FailurePolicyType]webhookSettings) error {
+	logger := m.log.WithValues("kind", kind, "name", name)
+
+	gvrCache, ok := m.register.resCache.GetGVRCache(kind)
+	if !ok {
+		return fmt.Errorf("resource cache is not found for %s", kind)
+	}
+
+	current, err := gvrCache.Lister().Get(name)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get %s %s", kind, name)
+	}
+
+	observed, err := resourceWebhooks(current)
+	if err != nil {
+		return err
+	}
+
+	for failurePolicy := range desired {
+		if _, ok := observed[failurePolicy]; !ok {
+			return fmt.Errorf("%s %s has no webhook with the failure policy %s", kind, name, failurePolicy)
+		}
+	}
+
+	if webhooksEqual(observed, desired) {
+		m.register.setResourceWebhooks(kind, desired)
+		return nil
+	}
+
+	webhookConfig := current.DeepCopy()
+	webhooks, _, err := unstructured.NestedSlice(webhookConfig.UnstructuredContent(), "webhooks")
+	if err != nil {
+		return errors.Wrapf(err, "unable to load %s.webhooks", kind)
+	}
+
+	for i := range webhooks {
+		webhook, ok := webhooks[i].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("type mismatched, expected map[string]interface{}, got %T", webhooks[i])
+		}
+
+		settings, ok := desired[webhookFailurePolicy(webhook["failurePolicy"])]
+		if !ok {
+			continue
+		}
+
+		rulesBytes, err := json.Marshal(settings.Rules)
+		if err != nil {
+			return err
+		}
+
+		var rulesUntyped []interface{}
+		if err := json.Unmarshal(rulesBytes, &rulesUntyped); err != nil {
+			return err
+		}
+
+		webhook["rules"] = rulesUntyped
+		webhook["timeoutSeconds"] = int64(settings.TimeoutSeconds)
+	}
+
+	if err := unstructured.SetNestedSlice(webhookConfig.UnstructuredContent(), webhooks, "webhooks"); err != nil {
+		return errors.Wrapf(err, "unable to set %s.webhooks", kind)
+	}
+
+	if _, err := m.register.client.UpdateResource(webhookConfig.GetAPIVersion(), kind, "", webhookConfig, false); err != nil {
+		return errors.Wrapf(err, "unable to update %s %s", kind, name)
+	}
+
+	// the API server admits requests with the configuration it observes, the informer
+	// observes the update at about the same time
+	err = wait.PollImmediate(100*time.Millisecond, ruleObserveTimeout, func() (bool, error) {
+		current, err := gvrCache.Lister().Get(name)
+		if err != nil {
+			return false, nil
+		}
+
+		observed, err := resourceWebhooks(current)
+		if err != nil {
+			return false, nil
+		}
+
+		return webhooksEqual(observed, desired), nil
+	})
+
+	if err != nil {
+		return errors.Wrapf(err, "the update of %s %s is not observed", kind, name)
+	}
+
+	// the webhook configuration is registered with the rules and timeouts if it is re-created
+	m.register.setResourceWebhooks(kind, desired)
+	logger.Info("updated webhook rules",
+		"rules", len(desired[admregapi.Ignore].Rules), "timeoutSeconds", desired[admregapi.Ignore].TimeoutSeconds,
+		"failRules", len(desired[admregapi.Fail].Rules), "failTimeoutSeconds", desired[admregapi.Fail].TimeoutSeconds)
+	return nil
+}
+
+// resourceWebhooks returns the rules and the timeouts of the webhooks of the configuration by failure policy
+func resourceWebhooks(webhookConfig *unstructured.Unstructured) (map[admregapi.FailurePolicyType]webhookSettings, error) {
+	var config struct {
+		Webhooks []struct {
+			Rules          []admregapi.RuleWithOperations `json:"rules,omitempty"`
+			FailurePolicy  *admregapi.FailurePolicyType   `json:"failurePolicy,omitempty"`
+			TimeoutSeconds *int32                         `json:"timeoutSeconds,omitempty"`
+		} `json:"webhooks,omitempty"`
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(webhookConfig.UnstructuredContent(), &config); err != nil {
+		return nil, errors.Wrapf(err, "failed to convert %s %s", webhookConfig.GetKind(), webhookConfig.GetName())
+	}
+
+	if len(config.Webhooks) == 0 {
+		return nil, fmt.Errorf("%s %s has no webhooks", webhookConfig.GetKind(), webhookConfig.GetName())
+	}
+
+	webhooks := make(map[admregapi.FailurePolicyType]webhookSettings, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		var failurePolicy interface{}
+		if webhook.FailurePolicy != nil {
+			failurePolicy = string(*webhook.FailurePolicy)
+		}
+
+		var timeoutSeconds int32
+		if webhook.TimeoutSeconds != nil {
+			timeoutSeconds = *webhook.TimeoutSeconds
+		}
+
+		webhooks[webhookFailurePolicy(failurePolicy)] = webhookSettings{Rules: webhook.Rules, TimeoutSeconds: timeoutSeconds}
+	}
+
+	return webhooks, nil
+}
+
+// webhookFailurePolicy returns the failure policy of an unstructured webhook, the failure policy
+// of the v1beta1 webhooks defaults to Ignore
+func webhookFailurePolicy(failurePolicy interface{}) admregapi.FailurePolicyType {
+	if value, ok := failurePolicy.(string); ok && value == string(admregapi.Fail) {
+		return admregapi.Fail
+	}
+
+	return admregapi.Ignore
+}
+
+// webhooksEqual compares the rules and the timeouts of the desired webhooks
+func webhooksEqual(observed, desired map[admregapi.FailurePolicyType]webhookSettings) bool {
+	for failurePolicy, settings := range desired {
+		current, ok := observed[failurePolicy]
+		if !ok || current.TimeoutSeconds != settings.TimeoutSeconds || !rulesEqual(current.Rules, settings.Rules) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rulesEqual compares the requests matched by the rules, regardless of their layout and order, so
+// that the configuration is not updated when the rules are unchanged. The scope of the observed
+// rules is defaulted by the API server.
+func rulesEqual(observed, desired []admregapi.RuleWithOperations) bool {
+	return equality.Semantic.DeepEqual(optimizeRules(observed), optimizeRules(desired))
+}
+
+// setReady sets the Ready condition on the policy for its generation. The cached policies are shared
+// by the admission requests, the condition is set on a copy.
+func (m *RuleManager) setReady(policy *kyverno.ClusterPolicy) {
+	policy = policy.DeepCopy()
+	if !setReadyCondition(&policy.Status, policy.GetGeneration()) {
+		return
+	}
+
+	logger := m.log.WithValues("namespace", policy.GetNamespace(), "name", policy.GetName())
+
+	if _, err := common.UpdatePolicyStatus(m.kyvernoClient, policy); err != nil {
+		if errorsapi.IsConflict(err) {
+			// the policy is changed, the next sync will set the condition for the new generation
+			logger.V(3).Info("policy is changed, skipping the update of the Ready condition")
+			return
+		}
+
+		logger.Error(err, "failed to update policy status")
+		return
+	}
+
+	logger.V(2).Info("policy is ready", "generation", policy.GetGeneration())
+}
+
+// setReadyCondition sets the Ready condition for the generation. It returns true if the status changed.
+func setReadyCondition(status *kyverno.PolicyStatus, generation int64) bool {
+	ready := meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionReady)
+	if ready != nil && ready.Status == metav1.ConditionTrue && ready.ObservedGeneration == generation {
+		return false
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               kyverno.PolicyConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             policyReasonWebhookConfigured,
+		Message:            "the resource webhooks are configured for the kinds matched by the policy",
+	})
+
+	return true
+}
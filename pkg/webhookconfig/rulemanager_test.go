@@ -0,0 +1,217 @@
+package webhookconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/client/clientset/versioned/fake"
+	kyvernoinformer "github.com/kyverno/kyverno/pkg/client/informers/externalversions"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	"gotest.tools/assert"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeResourceCache serves the listers of the webhook configurations
+type fakeResourceCache map[string]resourcecache.GenericCache
+
+func (f fakeResourceCache) CreateInformers(gvks ...string) []error { return nil }
+
+func (f fakeResourceCache) CreateGVKInformer(gvk string) (resourcecache.GenericCache, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fakeResourceCache) StopResourceInformer(gvk string) {}
+
+func (f fakeResourceCache) GetGVRCache(gvk string) (resourcecache.GenericCache, bool) {
+	gc, ok := f[gvk]
+	return gc, ok
+}
+
+func (f fakeResourceCache) GetAPICallCache(gvr schema.GroupVersionResource) (resourcecache.GenericCache, bool) {
+	return nil, false
+}
+
+// fakeGenericCache lists the objects of its indexer
+type fakeGenericCache struct {
+	gvr     schema.GroupVersionResource
+	indexer cache.Indexer
+}
+
+func (f *fakeGenericCache) StopInformer() {}
+
+func (f *fakeGenericCache) IsNamespaced() bool { return false }
+
+func (f *fakeGenericCache) Lister() dynamiclister.Lister { return dynamiclister.New(f.indexer, f.gvr) }
+
+func (f *fakeGenericCache) NamespacedLister(namespace string) dynamiclister.NamespaceLister {
+	return f.Lister().Namespace(namespace)
+}
+
+func (f *fakeGenericCache) GVR() schema.GroupVersionResource { return f.gvr }
+
+func (f *fakeGenericCache) GetInformer() cache.SharedIndexInformer { return nil }
+
+// newWebhookConfig returns the webhook configuration of the kind with the webhooks by failure policy
+func newWebhookConfig(t *testing.T, kind, name string, webhooks map[admregapi.FailurePolicyType]webhookSettings) *unstructured.Unstructured {
+	var items []interface{}
+	for _, failurePolicy := range resourceFailurePolicies {
+		settings := webhooks[failurePolicy]
+		rulesBytes, err := json.Marshal(settings.Rules)
+		assert.NilError(t, err)
+
+		var rules []interface{}
+		assert.NilError(t, json.Unmarshal(rulesBytes, &rules))
+		items = append(items, map[string]interface{}{
+			"failurePolicy":  string(failurePolicy),
+			"rules":          rules,
+			"timeoutSeconds": int64(settings.TimeoutSeconds),
+		})
+	}
+
+	webhookConfig := &unstructured.Unstructured{}
+	webhookConfig.SetAPIVersion("admissionregistration.k8s.io/v1beta1")
+	webhookConfig.SetKind(kind)
+	webhookConfig.SetName(name)
+	assert.NilError(t, unstructured.SetNestedSlice(webhookConfig.Object, items, "webhooks"))
+	return webhookConfig
+}
+
+// newTestRuleManager returns a rule manager of the policies cached from the fake clientset, the webhook
+// configurations are up to date so that a sync only sets the Ready condition on the policies
+func newTestRuleManager(t *testing.T, policies ...runtime.Object) (*RuleManager, policycache.Interface, *fake.Clientset) {
+	kyvernoClient := fake.NewSimpleClientset(policies...)
+	factory := kyvernoinformer.NewSharedInformerFactory(kyvernoClient, 0)
+	pCacheController := policycache.NewPolicyCacheController(factory.Kyverno().V1().ClusterPolicies(), factory.Kyverno().V1().Policies(), nil, log.Log)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+	assert.NilError(t, pCacheController.Warmup(stopCh))
+
+	register := &Register{timeoutSeconds: 10, log: log.Log}
+	m := NewRuleManager(register, pCacheController.Cache, func() bool { return true }, testDiscovery, kyvernoClient, log.Log)
+	t.Cleanup(m.unsubscribe)
+
+	mutating, validating := m.desiredWebhooks(takeSnapshot(m.pCache))
+	register.resCache = fakeResourceCache{
+		kindMutating: &fakeGenericCache{
+			gvr:     schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "mutatingwebhookconfigurations"},
+			indexer: newIndexer(t, newWebhookConfig(t, kindMutating, config.MutatingWebhookConfigurationName, mutating)),
+		},
+		kindValidating: &fakeGenericCache{
+			gvr:     schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"},
+			indexer: newIndexer(t, newWebhookConfig(t, kindValidating, config.ValidatingWebhookConfigurationName, validating)),
+		},
+	}
+
+	return m, pCacheController.Cache, kyvernoClient
+}
+
+func newIndexer(t *testing.T, objects ...*unstructured.Unstructured) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, object := range objects {
+		assert.NilError(t, indexer.Add(object))
+	}
+
+	return indexer
+}
+
+// newCachedPolicy returns a policy with a resourceVersion, the policy cache shares a single copy of the
+// policy between the callers until it is updated
+func newCachedPolicy(name string, kinds ...string) *kyverno.ClusterPolicy {
+	policy := newValidatePolicy(name, kinds...)
+	policy.SetResourceVersion("1")
+	policy.SetGeneration(1)
+	return policy
+}
+
+func Test_sync_SetsReady(t *testing.T) {
+	m, _, kyvernoClient := newTestRuleManager(t, newCachedPolicy("require-labels", "Pod"))
+
+	assert.NilError(t, m.sync())
+
+	policy, err := kyvernoClient.KyvernoV1().ClusterPolicies().Get(context.TODO(), "require-labels", metav1.GetOptions{})
+	assert.NilError(t, err)
+	ready := meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionReady)
+	assert.Assert(t, ready != nil)
+	assert.Equal(t, ready.Status, metav1.ConditionTrue)
+	assert.Equal(t, ready.ObservedGeneration, int64(1))
+}
+
+func Test_sync_StatusUpdateFailed(t *testing.T) {
+	m, pCache, kyvernoClient := newTestRuleManager(t, newCachedPolicy("require-labels", "Pod"))
+	kyvernoClient.PrependReactor("update", "clusterpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("the server is currently unable to handle the request")
+	})
+
+	assert.NilError(t, m.sync())
+
+	// the policy is not reported ready, the next sync retries to set the condition
+	cached := pCache.GetAll(policycache.ValidateAudit)
+	assert.Equal(t, len(cached), 1)
+	assert.Assert(t, meta.FindStatusCondition(cached[0].Status.Conditions, kyverno.PolicyConditionReady) == nil)
+}
+
+// Test_sync_Concurrently is meant to be run with -race, the admission requests read the cached policies
+// while the webhooks are synced
+func Test_sync_Concurrently(t *testing.T) {
+	m, pCache, kyvernoClient := newTestRuleManager(t, newCachedPolicy("require-labels", "Pod"), newCachedPolicy("require-owner", "Pod"))
+	kyvernoClient.PrependReactor("update", "clusterpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// the status is never set, every sync sets the condition again
+		return true, nil, errors.New("the server is currently unable to handle the request")
+	})
+
+	done := make(chan struct{})
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err := m.sync(); err != nil {
+				t.Errorf("failed to sync: %v", err)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, policy := range pCache.GetPolicies(policycache.ValidateAudit, "Pod", "") {
+					if meta.FindStatusCondition(policy.Status.Conditions, kyverno.PolicyConditionReady) != nil {
+						t.Errorf("policy %s is modified", policy.GetName())
+					}
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	<-synced
+}
@@ -0,0 +1,211 @@
+package webhookconfig
+
+import (
+	"sort"
+	"strings"
+
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+)
+
+// the fields of a ruleSet
+const (
+	ruleOperations = iota
+	ruleGroups
+	ruleVersions
+	ruleResources
+)
+
+// ruleSet is a webhook rule with the sorted values of its operations, API groups, versions and resources
+type ruleSet struct {
+	values [4][]string
+	scope  admregapi.ScopeType
+}
+
+// key returns the key of the rule without the values of the field
+func (r ruleSet) key(except int) string {
+	parts := []string{string(r.scope)}
+	for field, values := range r.values {
+		if field == except {
+			continue
+		}
+		parts = append(parts, strings.Join(values, ","))
+	}
+	return strings.Join(parts, "|")
+}
+
+// less orders the rules by API groups, versions, resources, operations and scope
+func (r ruleSet) less(other ruleSet) bool {
+	for _, field := range []int{ruleGroups, ruleVersions, ruleResources, ruleOperations} {
+		if c := compareValues(r.values[field], other.values[field]); c != 0 {
+			return c < 0
+		}
+	}
+	return r.scope < other.scope
+}
+
+func compareValues(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// ruleAtom is a webhook rule with a single operation, API group, version and resource
+type ruleAtom struct {
+	operation, group, version, resource string
+	scope                               admregapi.ScopeType
+}
+
+// covers checks if the atom matches all the requests matched by the other atom
+func (a ruleAtom) covers(b ruleAtom) bool {
+	return wildcardCovers(a.operation, b.operation) &&
+		wildcardCovers(a.group, b.group) &&
+		wildcardCovers(a.version, b.version) &&
+		wildcardCovers(string(a.scope), string(b.scope)) &&
+		resourceCovers(a.resource, b.resource)
+}
+
+func wildcardCovers(a, b string) bool {
+	return a == "*" || a == b
+}
+
+// resourceCovers checks if the resource matches all the requests matched by the other resource.
+// Only "*", which matches all resources but not their sub-resources, covers other resources. The
+// entries are not folded into the sub-resource wildcards, e.g. "*/*" or "pods/*", they are kept.
+func resourceCovers(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	_, bSubresource := splitResource(b)
+	return a == "*" && bSubresource == ""
+}
+
+func splitResource(resource string) (string, string) {
+	parts := strings.SplitN(resource, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// optimizeRules returns rules matching the same requests as the rules, in a stable order
+// and with fewer entries for the API server to evaluate on every request.
+// The duplicated entries, and the entries covered by a wildcard, e.g. "pods" if "*" is listed
+// for the same API group, version and operations, are removed. The entries are kept next to the
+// sub-resource wildcards, e.g. "*/*" or "pods/*", they are not folded into them. The entries sharing
+// the API group, version and operations are merged into one rule, then the rules only differing by
+// their API groups, and then by their versions, are merged. The values of the rules are sorted,
+// and the rules are sorted by their values.
+//
+// The rules are equal, so that the webhook configurations are not updated, as long as the
+// requests they match do not change. The unset scope is "*", as defaulted by the API server.
+func optimizeRules(rules []admregapi.RuleWithOperations) []admregapi.RuleWithOperations {
+	atoms := make(map[ruleAtom]bool)
+	for _, rule := range rules {
+		scope := admregapi.AllScopes
+		if rule.Scope != nil {
+			scope = *rule.Scope
+		}
+
+		for _, operation := range rule.Operations {
+			for _, group := range rule.APIGroups {
+				for _, version := range rule.APIVersions {
+					for _, resource := range rule.Resources {
+						atoms[ruleAtom{string(operation), group, version, resource, scope}] = true
+					}
+				}
+			}
+		}
+	}
+
+	// the operations of each API group, version and resource which are not covered by a wildcard
+	type target struct {
+		group, version, resource string
+		scope                    admregapi.ScopeType
+	}
+
+	operations := make(map[target][]string)
+	for atom := range atoms {
+		if isCoveredAtom(atom, atoms) {
+			continue
+		}
+
+		t := target{atom.group, atom.version, atom.resource, atom.scope}
+		operations[t] = append(operations[t], atom.operation)
+	}
+
+	sets := make([]ruleSet, 0, len(operations))
+	for t, ops := range operations {
+		sort.Strings(ops)
+		sets = append(sets, ruleSet{
+			values: [4][]string{ops, {t.group}, {t.version}, {t.resource}},
+			scope:  t.scope,
+		})
+	}
+
+	sets = mergeRules(sets, ruleResources)
+	sets = mergeRules(sets, ruleGroups)
+	sets = mergeRules(sets, ruleVersions)
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].less(sets[j])
+	})
+
+	optimized := make([]admregapi.RuleWithOperations, 0, len(sets))
+	for _, set := range sets {
+		rule := admregapi.RuleWithOperations{
+			Rule: admregapi.Rule{
+				APIGroups:   set.values[ruleGroups],
+				APIVersions: set.values[ruleVersions],
+				Resources:   set.values[ruleResources],
+			},
+		}
+
+		for _, operation := range set.values[ruleOperations] {
+			rule.Operations = append(rule.Operations, admregapi.OperationType(operation))
+		}
+
+		if set.scope != admregapi.AllScopes {
+			scope := set.scope
+			rule.Scope = &scope
+		}
+
+		optimized = append(optimized, rule)
+	}
+
+	return optimized
+}
+
+// isCoveredAtom checks if another atom matches all the requests matched by the atom
+func isCoveredAtom(atom ruleAtom, atoms map[ruleAtom]bool) bool {
+	for other := range atoms {
+		if other != atom && other.covers(atom) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRules merges the rules which only differ by the values of the field
+func mergeRules(sets []ruleSet, field int) []ruleSet {
+	index := make(map[string]int)
+	merged := make([]ruleSet, 0, len(sets))
+	for _, set := range sets {
+		key := set.key(field)
+		if i, ok := index[key]; ok {
+			merged[i].values[field] = append(merged[i].values[field], set.values[field]...)
+			continue
+		}
+
+		index[key] = len(merged)
+		set.values[field] = append([]string{}, set.values[field]...)
+		merged = append(merged, set)
+	}
+
+	for i := range merged {
+		sort.Strings(merged[i].values[field])
+	}
+	return merged
+}
@@ -0,0 +1,230 @@
+package webhookconfig
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+)
+
+var allOperations = []admregapi.OperationType{admregapi.Connect, admregapi.Create, admregapi.Delete, admregapi.Update}
+
+func newRule(operations []admregapi.OperationType, group, version string, resources ...string) admregapi.RuleWithOperations {
+	return admregapi.RuleWithOperations{
+		Operations: operations,
+		Rule: admregapi.Rule{
+			APIGroups:   []string{group},
+			APIVersions: []string{version},
+			Resources:   resources,
+		},
+	}
+}
+
+// perOperationRules returns one rule per resource per operation
+func perOperationRules(group, version string, resources ...string) []admregapi.RuleWithOperations {
+	var rules []admregapi.RuleWithOperations
+	for _, resource := range resources {
+		for _, operation := range allOperations {
+			rules = append(rules, newRule([]admregapi.OperationType{operation}, group, version, resource))
+		}
+	}
+	return rules
+}
+
+// policyRules returns the rules of the kinds matched by policies, as the rule manager builds them
+func policyRules(t *testing.T, operations []admregapi.OperationType, kinds ...string) []admregapi.RuleWithOperations {
+	rules, unresolved := buildRules(kinds, testDiscovery)
+	assert.Equal(t, len(unresolved), 0)
+
+	var result []admregapi.RuleWithOperations
+	for _, rule := range rules {
+		result = append(result, admregapi.RuleWithOperations{Operations: operations, Rule: rule})
+	}
+	return result
+}
+
+// ruleCorpus returns rule sets derived from policies, and the rule sets of the previous layouts
+func ruleCorpus(t *testing.T) map[string][]admregapi.RuleWithOperations {
+	namespaced := admregapi.NamespacedScope
+	scoped := newRule(mutatingOperations, "", "*", "pods")
+	scoped.Scope = &namespaced
+
+	return map[string][]admregapi.RuleWithOperations{
+		"one rule per resource per operation": append(append(perOperationRules("", "v1", "pods", "configmaps"),
+			perOperationRules("apps", "v1", "deployments")...),
+			perOperationRules("cert-manager.io", "v1", "certificates")...),
+		"validate policies": policyRules(t, validatingOperations, "Pod", "Deployment", "ConfigMap", "Certificate"),
+		"mutate policies":   policyRules(t, mutatingOperations, "Pod", "pods/exec", "apps/v1/Deployment"),
+		"wildcard policy":   append(policyRules(t, mutatingOperations, "Pod", "v1/*"), policyRules(t, mutatingOperations, "Deployment")...),
+		"wildcard resource": {
+			newRule(mutatingOperations, "", "*", "*"),
+			newRule(mutatingOperations, "", "*", "pods", "pods/exec"),
+			newRule([]admregapi.OperationType{admregapi.Delete}, "", "*", "pods"),
+		},
+		"wildcard sub-resource": {
+			newRule(validatingOperations, "", "*", "pods/*"),
+			newRule(validatingOperations, "*", "*", "*/exec"),
+			newRule(validatingOperations, "", "v1", "pods/exec", "pods/status", "pods"),
+		},
+		"duplicated groups": {
+			newRule(mutatingOperations, "apps", "v1", "deployments", "deployments/*"),
+			newRule(mutatingOperations, "extensions", "v1", "deployments", "deployments/*"),
+			newRule(mutatingOperations, "apps", "v1beta1", "deployments", "deployments/*"),
+			newRule(mutatingOperations, "extensions", "v1beta1", "deployments/*", "deployments"),
+		},
+		"scope": {
+			scoped,
+			newRule(mutatingOperations, "", "*", "pods"),
+			newRule([]admregapi.OperationType{admregapi.OperationAll}, "", "v1", "configmaps"),
+			newRule(mutatingOperations, "", "v1", "configmaps"),
+		},
+	}
+}
+
+// requestMatches checks if the rule matches the request, as the API server does
+func requestMatches(rule admregapi.RuleWithOperations, operation, group, version, resource string, scope admregapi.ScopeType) bool {
+	matches := func(values []string, value string) bool {
+		for _, v := range values {
+			if v == "*" || v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	operations := make([]string, 0, len(rule.Operations))
+	for _, op := range rule.Operations {
+		operations = append(operations, string(op))
+	}
+
+	if !matches(operations, operation) || !matches(rule.APIGroups, group) || !matches(rule.APIVersions, version) {
+		return false
+	}
+
+	if rule.Scope != nil && *rule.Scope != admregapi.AllScopes && *rule.Scope != scope {
+		return false
+	}
+
+	name, subresource := splitResource(resource)
+	for _, r := range rule.Resources {
+		ruleName, ruleSubresource := splitResource(r)
+		if ruleName != "*" && ruleName != name {
+			continue
+		}
+		if ruleSubresource == "" && subresource == "" {
+			return true
+		}
+		if ruleSubresource != "" && subresource != "" && (ruleSubresource == "*" || ruleSubresource == subresource) {
+			return true
+		}
+	}
+	return false
+}
+
+// assertEquivalent checks that the rules match the same requests, for all the values of the rules
+func assertEquivalent(t *testing.T, name string, rules, optimized []admregapi.RuleWithOperations) {
+	groups := map[string]bool{"other.io": true}
+	versions := map[string]bool{"v2": true}
+	names := map[string]bool{"secrets": true}
+	subresources := map[string]bool{"": true, "scale": true}
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			groups[group] = true
+		}
+		for _, version := range rule.APIVersions {
+			versions[version] = true
+		}
+		for _, resource := range rule.Resources {
+			n, s := splitResource(resource)
+			names[n], subresources[s] = true, true
+		}
+	}
+
+	for _, operation := range allOperations {
+		for group := range groups {
+			for version := range versions {
+				for n := range names {
+					for s := range subresources {
+						resource := strings.TrimSuffix(n+"/"+s, "/")
+						for _, scope := range []admregapi.ScopeType{admregapi.NamespacedScope, admregapi.ClusterScope} {
+							var want, got bool
+							for _, rule := range rules {
+								want = want || requestMatches(rule, string(operation), group, version, resource, scope)
+							}
+							for _, rule := range optimized {
+								got = got || requestMatches(rule, string(operation), group, version, resource, scope)
+							}
+							assert.Equal(t, got, want, fmt.Sprintf("%s: %s %s/%s %s %s", name, operation, group, version, resource, scope))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func Test_optimizeRules_Equivalent(t *testing.T) {
+	for name, rules := range ruleCorpus(t) {
+		optimized := optimizeRules(rules)
+		assertEquivalent(t, name, rules, optimized)
+		assert.Assert(t, len(optimized) <= len(rules), name)
+
+		// the optimized rules are stable
+		assert.DeepEqual(t, optimizeRules(optimized), optimized)
+
+		shuffled := append([]admregapi.RuleWithOperations{}, rules...)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		assert.DeepEqual(t, optimizeRules(shuffled), optimized)
+		assert.Assert(t, rulesEqual(shuffled, optimized), name)
+	}
+}
+
+func Test_optimizeRules_Minimal(t *testing.T) {
+	corpus := ruleCorpus(t)
+
+	assert.DeepEqual(t, optimizeRules(corpus["one rule per resource per operation"]), []admregapi.RuleWithOperations{
+		newRule(allOperations, "", "v1", "configmaps", "pods"),
+		newRule(allOperations, "apps", "v1", "deployments"),
+		newRule(allOperations, "cert-manager.io", "v1", "certificates"),
+	})
+
+	// the entries are not folded into the sub-resource wildcards, pods/exec is kept with pods/*
+	assert.DeepEqual(t, optimizeRules(corpus["mutate policies"]), []admregapi.RuleWithOperations{
+		newRule(mutatingOperations, "", "*", "pods", "pods/*", "pods/exec"),
+		newRule(mutatingOperations, "apps", "*", "deployments", "deployments/*"),
+	})
+
+	assert.DeepEqual(t, optimizeRules(corpus["wildcard policy"]), []admregapi.RuleWithOperations{
+		newRule(mutatingOperations, "*", "*", "*/*"),
+		newRule(mutatingOperations, "apps", "*", "deployments", "deployments/*"),
+	})
+
+	// pods is covered by * for the same operations, not the sub-resources
+	assert.DeepEqual(t, optimizeRules(corpus["wildcard resource"]), []admregapi.RuleWithOperations{
+		newRule([]admregapi.OperationType{admregapi.Create, admregapi.Update}, "", "*", "*", "pods/exec"),
+		newRule([]admregapi.OperationType{admregapi.Delete}, "", "*", "pods"),
+	})
+
+	assert.DeepEqual(t, optimizeRules(corpus["wildcard sub-resource"]), []admregapi.RuleWithOperations{
+		newRule([]admregapi.OperationType{admregapi.Connect, admregapi.Create, admregapi.Delete, admregapi.Update}, "", "*", "pods/*"),
+		newRule([]admregapi.OperationType{admregapi.Connect, admregapi.Create, admregapi.Delete, admregapi.Update}, "", "v1", "pods", "pods/exec", "pods/status"),
+		newRule([]admregapi.OperationType{admregapi.Connect, admregapi.Create, admregapi.Delete, admregapi.Update}, "*", "*", "*/exec"),
+	})
+
+	merged := newRule(mutatingOperations, "apps", "v1", "deployments", "deployments/*")
+	merged.APIGroups = []string{"apps", "extensions"}
+	merged.APIVersions = []string{"v1", "v1beta1"}
+	assert.DeepEqual(t, optimizeRules(corpus["duplicated groups"]), []admregapi.RuleWithOperations{merged})
+
+	assert.DeepEqual(t, optimizeRules(corpus["scope"]), []admregapi.RuleWithOperations{
+		newRule(mutatingOperations, "", "*", "pods"),
+		newRule([]admregapi.OperationType{admregapi.OperationAll}, "", "v1", "configmaps"),
+	})
+}
+
+func Test_optimizeRules_Empty(t *testing.T) {
+	assert.DeepEqual(t, optimizeRules(nil), []admregapi.RuleWithOperations{})
+}
@@ -0,0 +1,265 @@
+package webhookconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"github.com/kyverno/kyverno/pkg/utils"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kindResolver finds the API resource of a kind, it is implemented by the dclient discovery
+type kindResolver interface {
+	FindResource(apiVersion string, kind string) (*metav1.APIResource, schema.GroupVersionResource, error)
+}
+
+// policyGetter returns the cached policies of a policy type, it is implemented by the policy cache
+type policyGetter interface {
+	GetAll(pkey policycache.PolicyType) []*kyverno.ClusterPolicy
+}
+
+// policySnapshot holds the cached policies by policy type
+type policySnapshot map[policycache.PolicyType][]*kyverno.ClusterPolicy
+
+func takeSnapshot(policies policyGetter) policySnapshot {
+	snapshot := make(policySnapshot)
+	for _, pkey := range []policycache.PolicyType{policycache.Mutate, policycache.ValidateEnforce, policycache.ValidateAudit, policycache.Generate, policycache.VerifyImages} {
+		snapshot[pkey] = policies.GetAll(pkey)
+	}
+
+	return snapshot
+}
+
+// policies returns the distinct policies of the snapshot
+func (s policySnapshot) policies() []*kyverno.ClusterPolicy {
+	var result []*kyverno.ClusterPolicy
+	seen := make(map[string]bool)
+	for _, policies := range s {
+		for _, policy := range policies {
+			key := policy.GetNamespace() + "/" + policy.GetName()
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, policy)
+			}
+		}
+	}
+
+	return result
+}
+
+// served returns the policies of the snapshot served by the resource webhooks with the failure policy.
+// The mutate, verifyImages and enforced validate rules of the policies whose failure policy is set to
+// Fail are served by the Fail webhooks, the other rules by the Ignore webhooks. The generate rules and
+// the audited validate rules are applied in the background and are always served by the Ignore webhooks.
+func (s policySnapshot) served(failurePolicy admregapi.FailurePolicyType) policySnapshot {
+	result := make(policySnapshot, len(s))
+	for pkey, policies := range s {
+		if pkey == policycache.Generate || pkey == policycache.ValidateAudit {
+			if failurePolicy != admregapi.Fail {
+				result[pkey] = policies
+			}
+			continue
+		}
+
+		for _, policy := range policies {
+			if policy.HasWebhookFailurePolicyFail() == (failurePolicy == admregapi.Fail) {
+				result[pkey] = append(result[pkey], policy)
+			}
+		}
+	}
+
+	return result
+}
+
+// timeoutSeconds returns the largest webhook timeout of the policies of the types, the policies without
+// a timeout use the default timeout, which is also returned if there are no policies
+func (s policySnapshot) timeoutSeconds(defaultTimeout int32, pkeys ...policycache.PolicyType) int32 {
+	var result int32
+	for _, pkey := range pkeys {
+		for _, policy := range s[pkey] {
+			timeout := defaultTimeout
+			if policy.Spec.WebhookTimeoutSeconds != nil {
+				timeout = *policy.Spec.WebhookTimeoutSeconds
+			}
+
+			if timeout > result {
+				result = timeout
+			}
+		}
+	}
+
+	if result == 0 {
+		return defaultTimeout
+	}
+
+	return result
+}
+
+// wildcardRule matches all resources and their sub-resources
+var wildcardRule = admregapi.Rule{
+	APIGroups:   []string{"*"},
+	APIVersions: []string{"*"},
+	Resources:   []string{"*/*"},
+}
+
+// mutatingKinds returns the kinds of the requests of the operation sent to the resource mutating webhook.
+// It serves the mutate, generate and verifyImages rules, and the updates of the generated and cloned resources.
+func mutatingKinds(snapshot policySnapshot, operation admregapi.OperationType) []string {
+	kinds := make(map[string]bool)
+	addMatchKinds(kinds, snapshot[policycache.Mutate], kyverno.Rule.HasMutate, operation)
+	addMatchKinds(kinds, snapshot[policycache.VerifyImages], kyverno.Rule.HasVerifyImages, operation)
+	addMatchKinds(kinds, snapshot[policycache.Generate], kyverno.Rule.HasGenerate, operation)
+	addGeneratedKinds(kinds, snapshot[policycache.Generate])
+	return sortedKinds(kinds)
+}
+
+// validatingKinds returns the kinds of the requests of the operation sent to the resource validating webhook.
+// It serves the validate rules, and the deletes of the generated resources.
+func validatingKinds(snapshot policySnapshot, operation admregapi.OperationType) []string {
+	kinds := make(map[string]bool)
+	addMatchKinds(kinds, snapshot[policycache.ValidateEnforce], kyverno.Rule.HasValidate, operation)
+	addMatchKinds(kinds, snapshot[policycache.ValidateAudit], kyverno.Rule.HasValidate, operation)
+	addGeneratedKinds(kinds, snapshot[policycache.Generate])
+	return sortedKinds(kinds)
+}
+
+// addMatchKinds adds the kinds matched by the rules for the operation, the rules without
+// operations match all operations
+func addMatchKinds(kinds map[string]bool, policies []*kyverno.ClusterPolicy, hasRule func(kyverno.Rule) bool, operation admregapi.OperationType) {
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Rules {
+			if !hasRule(rule) {
+				continue
+			}
+
+			operations := rule.MatchResources.Operations
+			if len(operations) > 0 && !utils.ContainsString(operations, string(operation)) {
+				continue
+			}
+
+			for _, kind := range rule.MatchResources.Kinds {
+				kinds[kind] = true
+			}
+		}
+	}
+}
+
+func addGeneratedKinds(kinds map[string]bool, policies []*kyverno.ClusterPolicy) {
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Rules {
+			if rule.HasCloneList() {
+				for _, kind := range rule.Generation.CloneList.Kinds {
+					kinds[kind] = true
+				}
+				continue
+			}
+
+			if !rule.HasGenerate() || rule.Generation.Kind == "" {
+				continue
+			}
+
+			if rule.Generation.APIVersion != "" {
+				kinds[rule.Generation.APIVersion+"/"+rule.Generation.Kind] = true
+			} else {
+				kinds[rule.Generation.Kind] = true
+			}
+		}
+	}
+}
+
+func sortedKinds(kinds map[string]bool) []string {
+	result := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		result = append(result, kind)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// buildRules returns the webhook rules matching the kinds, one rule per API group sorted by group.
+// The kinds are resolved to their resources with the discovery, for all versions of the group.
+// A kind without a sub-resource also matches its sub-resources, like the wildcard rule does.
+//
+// The wildcard rule is returned if any kind is "*", e.g. "v1/*" or "apps/*", or if a kind cannot be resolved so that
+// no request matched by a policy is missed. The kinds that are not resolved are returned.
+func buildRules(kinds []string, resolver kindResolver) ([]admregapi.Rule, []string) {
+	resources := make(map[string]map[string]bool)
+	var unresolved []string
+	for _, kind := range kinds {
+		if _, name, _ := common.ParseKind(kind); name == "*" {
+			return []admregapi.Rule{wildcardRule}, nil
+		}
+
+		group, resource, err := resolveKind(kind, resolver)
+		if err != nil {
+			unresolved = append(unresolved, kind)
+			continue
+		}
+
+		if resources[group] == nil {
+			resources[group] = make(map[string]bool)
+		}
+
+		resources[group][resource] = true
+		if !strings.Contains(resource, "/") {
+			resources[group][resource+"/*"] = true
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return []admregapi.Rule{wildcardRule}, unresolved
+	}
+
+	groups := make([]string, 0, len(resources))
+	for group := range resources {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	rules := make([]admregapi.Rule, 0, len(groups))
+	for _, group := range groups {
+		rules = append(rules, admregapi.Rule{
+			APIGroups:   []string{group},
+			APIVersions: []string{"*"},
+			Resources:   sortedKinds(resources[group]),
+		})
+	}
+
+	return rules, nil
+}
+
+// resolveKind returns the API group and the resource of the kind. The kind can be qualified
+// with a version, e.g. "v1/Pod", with a group and version, e.g. "apps/v1/Deployment" or
+// "apps/*/Deployment", and with a sub-resource, e.g. "pods/exec".
+func resolveKind(gvk string, resolver kindResolver) (string, string, error) {
+	apiVersion, kind, subresource := common.ParseKind(gvk)
+
+	var group string
+	if apiVersion == "*" {
+		apiVersion = ""
+	} else if strings.HasSuffix(apiVersion, "/*") {
+		group, apiVersion = strings.TrimSuffix(apiVersion, "/*"), ""
+	}
+
+	_, gvr, err := resolver.FindResource(apiVersion, kind)
+	if err != nil {
+		return "", "", err
+	}
+
+	if group != "" && gvr.Group != group {
+		return "", "", fmt.Errorf("kind %s not found in group %s", kind, group)
+	}
+
+	if subresource != "" {
+		return gvr.Group, gvr.Resource + "/" + subresource, nil
+	}
+
+	return gvr.Group, gvr.Resource, nil
+}
@@ -0,0 +1,310 @@
+package webhookconfig
+
+import (
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/policycache"
+	"gotest.tools/assert"
+	admregapi "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeDiscovery resolves the kinds of the API resources, like the discovery of the dclient
+type fakeDiscovery struct {
+	resources []schema.GroupVersionResource
+	kinds     []string
+}
+
+func (d fakeDiscovery) FindResource(apiVersion string, kind string) (*metav1.APIResource, schema.GroupVersionResource, error) {
+	for i, gvr := range d.resources {
+		if apiVersion != "" && gvr.GroupVersion().String() != apiVersion {
+			continue
+		}
+
+		if d.kinds[i] == kind || gvr.Resource == kind {
+			return &metav1.APIResource{Name: gvr.Resource, Kind: d.kinds[i]}, gvr, nil
+		}
+	}
+
+	return nil, schema.GroupVersionResource{}, fmt.Errorf("kind '%s' not found in apiVersion '%s'", kind, apiVersion)
+}
+
+var testDiscovery = fakeDiscovery{
+	resources: []schema.GroupVersionResource{
+		{Version: "v1", Resource: "pods"},
+		{Version: "v1", Resource: "configmaps"},
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Group: "cert-manager.io", Version: "v1", Resource: "certificates"},
+	},
+	kinds: []string{"Pod", "ConfigMap", "Deployment", "Certificate"},
+}
+
+// fakePolicyCache returns the policies of each policy type, like the policy cache
+type fakePolicyCache map[policycache.PolicyType][]*kyverno.ClusterPolicy
+
+func (c fakePolicyCache) GetAll(pkey policycache.PolicyType) []*kyverno.ClusterPolicy {
+	return c[pkey]
+}
+
+func newValidatePolicy(name string, kinds ...string) *kyverno.ClusterPolicy {
+	return &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{
+					Name:           "check-labels",
+					MatchResources: kyverno.MatchResources{ResourceDescription: kyverno.ResourceDescription{Kinds: kinds}},
+					Validation:     kyverno.Validation{Message: "labels are required"},
+				},
+			},
+		},
+	}
+}
+
+func Test_buildRules_FirstPodPolicy(t *testing.T) {
+	pCache := fakePolicyCache{}
+
+	rules, unresolved := buildRules(validatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.Equal(t, len(rules), 0)
+	assert.Equal(t, len(unresolved), 0)
+
+	pCache[policycache.ValidateEnforce] = []*kyverno.ClusterPolicy{newValidatePolicy("require-labels", "Pod")}
+
+	rules, unresolved = buildRules(validatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.Equal(t, len(unresolved), 0)
+	assert.DeepEqual(t, rules, []admregapi.Rule{
+		{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods", "pods/*"}},
+	})
+
+	// the validate policy is not sent to the mutating webhook
+	rules, _ = buildRules(mutatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.Equal(t, len(rules), 0)
+}
+
+func Test_buildRules_RemoveLastPolicy(t *testing.T) {
+	pCache := fakePolicyCache{
+		policycache.ValidateEnforce: []*kyverno.ClusterPolicy{newValidatePolicy("require-labels", "Pod", "apps/v1/Deployment")},
+		policycache.ValidateAudit:   []*kyverno.ClusterPolicy{newValidatePolicy("audit-labels", "Pod")},
+	}
+
+	rules, _ := buildRules(validatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.DeepEqual(t, rules, []admregapi.Rule{
+		{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods", "pods/*"}},
+		{APIGroups: []string{"apps"}, APIVersions: []string{"*"}, Resources: []string{"deployments", "deployments/*"}},
+	})
+
+	delete(pCache, policycache.ValidateEnforce)
+	rules, _ = buildRules(validatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.DeepEqual(t, rules, []admregapi.Rule{
+		{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods", "pods/*"}},
+	})
+
+	// the webhook matches no requests once the last policy is removed, not all requests
+	delete(pCache, policycache.ValidateAudit)
+	rules, unresolved := buildRules(validatingKinds(takeSnapshot(pCache), admregapi.Create), testDiscovery)
+	assert.Equal(t, len(rules), 0)
+	assert.Equal(t, len(unresolved), 0)
+}
+
+func Test_buildRules_CustomResourceKind(t *testing.T) {
+	for _, kind := range []string{"Certificate", "certificates", "cert-manager.io/v1/Certificate", "cert-manager.io/*/Certificate"} {
+		rules, unresolved := buildRules([]string{kind}, testDiscovery)
+		assert.Equal(t, len(unresolved), 0, kind)
+		assert.DeepEqual(t, rules, []admregapi.Rule{
+			{APIGroups: []string{"cert-manager.io"}, APIVersions: []string{"*"}, Resources: []string{"certificates", "certificates/*"}},
+		})
+	}
+
+	// the CRD is not installed
+	rules, unresolved := buildRules([]string{"Pod", "Issuer"}, testDiscovery)
+	assert.DeepEqual(t, rules, []admregapi.Rule{wildcardRule})
+	assert.DeepEqual(t, unresolved, []string{"Issuer"})
+
+	// the kind is not in the group
+	rules, unresolved = buildRules([]string{"apps/*/Certificate"}, testDiscovery)
+	assert.DeepEqual(t, rules, []admregapi.Rule{wildcardRule})
+	assert.DeepEqual(t, unresolved, []string{"apps/*/Certificate"})
+}
+
+func Test_buildRules_Wildcard(t *testing.T) {
+	for _, kind := range []string{"*", "v1/*", "apps/v1/*", "apps/*"} {
+		rules, unresolved := buildRules([]string{"Pod", kind}, testDiscovery)
+		assert.DeepEqual(t, rules, []admregapi.Rule{wildcardRule})
+		assert.Equal(t, len(unresolved), 0, kind)
+	}
+}
+
+func Test_buildRules_Subresource(t *testing.T) {
+	rules, _ := buildRules([]string{"pods/exec", "Pod/ephemeralcontainers"}, testDiscovery)
+	assert.DeepEqual(t, rules, []admregapi.Rule{
+		{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods/ephemeralcontainers", "pods/exec"}},
+	})
+}
+
+func Test_kinds_Generate(t *testing.T) {
+	policy := &kyverno.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "add-configmap"},
+		Spec: kyverno.Spec{
+			Rules: []kyverno.Rule{
+				{
+					Name:           "clone-configmap",
+					MatchResources: kyverno.MatchResources{ResourceDescription: kyverno.ResourceDescription{Kinds: []string{"Namespace"}}},
+					Generation: kyverno.Generation{
+						ResourceSpec: kyverno.ResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Name: "config"},
+						Synchronize:  true,
+					},
+				},
+			},
+		},
+	}
+
+	snapshot := takeSnapshot(fakePolicyCache{policycache.Generate: []*kyverno.ClusterPolicy{policy}})
+
+	// the generated resources are sent to both webhooks for synchronization
+	assert.DeepEqual(t, mutatingKinds(snapshot, admregapi.Create), []string{"Namespace", "v1/ConfigMap"})
+	assert.DeepEqual(t, validatingKinds(snapshot, admregapi.Delete), []string{"v1/ConfigMap"})
+}
+
+func Test_kinds_Operations(t *testing.T) {
+	deletePolicy := newValidatePolicy("protect-pods", "Pod")
+	deletePolicy.Spec.Rules[0].MatchResources.Operations = []string{"DELETE"}
+	pCache := fakePolicyCache{
+		policycache.ValidateEnforce: []*kyverno.ClusterPolicy{deletePolicy, newValidatePolicy("require-labels", "ConfigMap")},
+	}
+
+	snapshot := takeSnapshot(pCache)
+	assert.DeepEqual(t, validatingKinds(snapshot, admregapi.Create), []string{"ConfigMap"})
+	assert.DeepEqual(t, validatingKinds(snapshot, admregapi.Delete), []string{"ConfigMap", "Pod"})
+
+	// the pods are only registered for the DELETE operation
+	m := &RuleManager{resolver: testDiscovery, log: log.Log}
+	rules := m.buildRules(kindValidating, validatingOperations, func(operation admregapi.OperationType) []string {
+		return validatingKinds(snapshot, operation)
+	})
+	assert.DeepEqual(t, rules, []admregapi.RuleWithOperations{
+		{
+			Operations: []admregapi.OperationType{admregapi.Connect, admregapi.Create, admregapi.Delete, admregapi.Update},
+			Rule:       admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"configmaps", "configmaps/*"}},
+		},
+		{
+			Operations: []admregapi.OperationType{admregapi.Delete},
+			Rule:       admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods", "pods/*"}},
+		},
+	})
+}
+
+func Test_kinds_FailurePolicy(t *testing.T) {
+	critical := newValidatePolicy("require-labels", "Pod")
+	critical.Spec.FailurePolicy = kyverno.Fail
+	timeout := int32(10)
+	critical.Spec.WebhookTimeoutSeconds = &timeout
+
+	bestEffort := newValidatePolicy("check-configmaps", "ConfigMap")
+	bestEffort.Spec.FailurePolicy = kyverno.Ignore
+	shortTimeout := int32(2)
+	bestEffort.Spec.WebhookTimeoutSeconds = &shortTimeout
+
+	snapshot := takeSnapshot(fakePolicyCache{
+		policycache.ValidateEnforce: []*kyverno.ClusterPolicy{critical, newValidatePolicy("require-annotations", "Deployment")},
+		policycache.ValidateAudit:   []*kyverno.ClusterPolicy{bestEffort},
+	})
+
+	// the policies whose failure policy is set to Fail are served by the Fail webhook only
+	fail := snapshot.served(admregapi.Fail)
+	assert.DeepEqual(t, validatingKinds(fail, admregapi.Create), []string{"Pod"})
+	assert.Equal(t, fail.timeoutSeconds(3, policycache.ValidateEnforce, policycache.ValidateAudit), int32(10))
+
+	// the audit policies are served by the Ignore webhook, the policy without a timeout uses the default timeout
+	ignore := snapshot.served(admregapi.Ignore)
+	assert.DeepEqual(t, validatingKinds(ignore, admregapi.Create), []string{"ConfigMap", "Deployment"})
+	assert.Equal(t, ignore.timeoutSeconds(3, policycache.ValidateEnforce, policycache.ValidateAudit), int32(3))
+	assert.Equal(t, ignore.timeoutSeconds(3, policycache.ValidateAudit), int32(2))
+
+	// the webhook without policies uses the default timeout
+	assert.Equal(t, fail.timeoutSeconds(3, policycache.Mutate), int32(3))
+}
+
+func Test_resourceWebhooks(t *testing.T) {
+	webhookConfig := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "admissionregistration.k8s.io/v1beta1",
+		"kind":       kindValidating,
+		"metadata":   map[string]interface{}{"name": "kyverno-resource-validating-webhook-cfg"},
+		"webhooks": []interface{}{
+			map[string]interface{}{
+				"name":           "validate.kyverno.svc",
+				"failurePolicy":  "Ignore",
+				"timeoutSeconds": int64(3),
+				"rules": []interface{}{
+					map[string]interface{}{"operations": []interface{}{"CREATE"}, "apiGroups": []interface{}{""}, "apiVersions": []interface{}{"*"}, "resources": []interface{}{"pods"}},
+				},
+			},
+			map[string]interface{}{
+				"name":           "validate-fail.kyverno.svc",
+				"failurePolicy":  "Fail",
+				"timeoutSeconds": int64(10),
+			},
+		},
+	}}
+
+	observed, err := resourceWebhooks(webhookConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, len(observed), 2)
+	assert.Equal(t, observed[admregapi.Ignore].TimeoutSeconds, int32(3))
+	assert.Equal(t, len(observed[admregapi.Ignore].Rules), 1)
+	assert.Equal(t, observed[admregapi.Fail].TimeoutSeconds, int32(10))
+
+	desired := map[admregapi.FailurePolicyType]webhookSettings{
+		admregapi.Ignore: {
+			Rules:          []admregapi.RuleWithOperations{{Operations: []admregapi.OperationType{admregapi.Create}, Rule: admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}}}},
+			TimeoutSeconds: 3,
+		},
+		admregapi.Fail: {TimeoutSeconds: 10},
+	}
+	assert.Assert(t, webhooksEqual(observed, desired))
+
+	desired[admregapi.Fail] = webhookSettings{TimeoutSeconds: 5}
+	assert.Assert(t, !webhooksEqual(observed, desired))
+}
+
+func Test_rulesEqual(t *testing.T) {
+	desired := []admregapi.RuleWithOperations{
+		{Operations: mutatingOperations, Rule: admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}}},
+	}
+
+	allScopes := admregapi.AllScopes
+	observed := []admregapi.RuleWithOperations{
+		{Operations: mutatingOperations, Rule: admregapi.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}, Scope: &allScopes}},
+	}
+
+	assert.Assert(t, rulesEqual(observed, desired))
+	assert.Equal(t, *observed[0].Scope, admregapi.AllScopes)
+
+	observed[0].Resources = []string{"*/*"}
+	assert.Assert(t, !rulesEqual(observed, desired))
+
+	// the rules are omitted when the webhook matches no requests
+	assert.Assert(t, rulesEqual(nil, []admregapi.RuleWithOperations{}))
+}
+
+func Test_setReadyCondition(t *testing.T) {
+	var status kyverno.PolicyStatus
+
+	assert.Assert(t, setReadyCondition(&status, 1))
+	ready := meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionReady)
+	assert.Assert(t, ready != nil)
+	assert.Equal(t, ready.Status, metav1.ConditionTrue)
+	assert.Equal(t, ready.ObservedGeneration, int64(1))
+
+	// the condition is not updated for the same generation
+	assert.Assert(t, !setReadyCondition(&status, 1))
+
+	// the condition is updated once the webhooks are configured for a new generation
+	assert.Assert(t, setReadyCondition(&status, 2))
+	assert.Equal(t, meta.FindStatusCondition(status.Conditions, kyverno.PolicyConditionReady).ObservedGeneration, int64(2))
+}
@@ -1,9 +1,15 @@
 package webhooks
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
 	"github.com/kyverno/kyverno/pkg/engine/response"
+	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
 	"gotest.tools/assert"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -98,3 +104,60 @@ func Test_annotation_failed_Patch(t *testing.T) {
 
 	assert.Assert(t, annPatches == nil)
 }
+
+func Test_annotation_Secret_Masked(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "secret-api-key"},
+		"spec": {
+			"rules": [
+				{
+					"name": "add-api-key",
+					"match": {"resources": {"kinds": ["Secret"]}},
+					"mutate": {
+						"patchStrategicMerge": {
+							"stringData": {"apiKey": "{{base64_decode(request.object.data.password)}}-v2"}
+						}
+					}
+				}
+			]
+		}
+	}`)
+
+	// the password is "hunter2-super-secret"
+	rawResource := []byte(`{
+		"apiVersion": "v1",
+		"kind": "Secret",
+		"metadata": {"name": "db", "namespace": "prod"},
+		"data": {"password": "aHVudGVyMi1zdXBlci1zZWNyZXQ="}
+	}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+
+	resource, err := engineutils.ConvertToUnstructured(rawResource)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(rawResource))
+
+	er := engine.Mutate(&engine.PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, len(er.PolicyResponse.Rules), 1)
+	assert.Assert(t, er.PolicyResponse.Rules[0].Success)
+
+	// the engine has full access to the data
+	apiKey, _, _ := unstructured.NestedString(er.PatchedResource.Object, "stringData", "apiKey")
+	assert.Equal(t, apiKey, "hunter2-super-secret-v2")
+
+	raw, err := json.Marshal(er.PolicyResponse.Rules[0].Message)
+	assert.NilError(t, err)
+	annPatches := generateAnnotationPatches([]*response.EngineResponse{er}, log.Log)
+	assert.Assert(t, annPatches != nil)
+
+	for _, output := range []string{string(raw), string(annPatches)} {
+		for _, value := range []string{"hunter2-super-secret", "aHVudGVyMi1zdXBlci1zZWNyZXQ="} {
+			assert.Assert(t, !strings.Contains(output, value), output)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// the limits of the evaluation budget, reported in the budget exceeded metric
+const (
+	budgetPolicies = "policies"
+	budgetTime     = "time"
+)
+
+// evaluationBudget limits the policies evaluated for an admission request, by the webhooks or the audit handler.
+// The evaluation time is measured from the start of the request, it includes the time spent
+// on the RBAC lookups, the variables context and the context entries of the rules.
+type evaluationBudget struct {
+	config.WebhookBudget
+	start     time.Time
+	evaluated int
+}
+
+// newEvaluationBudget returns the budget of the request started at the time, or nil if it is not limited
+func newEvaluationBudget(budget config.WebhookBudget, start time.Time) *evaluationBudget {
+	if budget.MaxPolicies <= 0 && budget.MaxEvaluationTime <= 0 {
+		return nil
+	}
+
+	return &evaluationBudget{WebhookBudget: budget, start: start}
+}
+
+// exceeded returns the exceeded limit, if the next policy must not be evaluated
+func (b *evaluationBudget) exceeded() (string, bool) {
+	if b == nil {
+		return "", false
+	}
+
+	if b.MaxPolicies > 0 && b.evaluated >= b.MaxPolicies {
+		return budgetPolicies, true
+	}
+
+	if b.MaxEvaluationTime > 0 && time.Since(b.start) >= b.MaxEvaluationTime {
+		return budgetTime, true
+	}
+
+	return "", false
+}
+
+// add counts an evaluated policy
+func (b *evaluationBudget) add() {
+	if b != nil {
+		b.evaluated++
+	}
+}
+
+// handleBudgetOverflow handles the policies not evaluated once the budget is exceeded. The policies audited in the
+// namespace of the request are deferred to the audit handler, if any, the audit handler does not evaluate them.
+// The request is denied if enforce policies are not evaluated and the overflow is "deny", the returned message
+// lists them.
+func (v *validationHandler) handleBudgetOverflow(promConfig *metrics.PromConfig, request *v1beta1.AdmissionRequest, policies []*kyverno.ClusterPolicy,
+	limit, threshold string, logger logr.Logger) (string, bool) {
+
+	var deferred, enforce []*kyverno.ClusterPolicy
+	var enforceNames []string
+	for _, policy := range policies {
+		if policy.GetValidationFailureActionForThreshold(request.Namespace, threshold) == common.Enforce && !v.isShadowEnforce(policy) {
+			enforce = append(enforce, policy)
+			enforceNames = append(enforceNames, policyKey(policy))
+		} else {
+			deferred = append(deferred, policy)
+		}
+	}
+
+	if len(deferred) > 0 && v.auditHandler != nil {
+		v.auditHandler.AddDeferred(request.DeepCopy(), deferred)
+	}
+
+	overflow := "none"
+	if len(enforce) > 0 {
+		overflow = v.budget.Overflow
+	}
+
+	logger.Info("policy evaluation budget exceeded", "budget", limit, "evaluated", v.budget.evaluated,
+		"deferred", len(deferred), "notEvaluated", enforceNames, "overflow", overflow)
+	countBudgetExceeded(promConfig, request, limit, overflow)
+
+	deferredResult := "evaluated in the background"
+	if v.auditHandler == nil {
+		deferredResult = "not evaluated"
+	}
+
+	message := fmt.Sprintf("the policy evaluation budget (%s) of the request is exceeded after %d policies, %d audit policies are %s",
+		limit, v.budget.evaluated, len(deferred), deferredResult)
+	if len(enforce) > 0 {
+		result := "denied"
+		if overflow == config.WebhookBudgetOverflowAllow {
+			result = "allowed"
+		}
+		message += fmt.Sprintf(", the enforce policies %s are not evaluated and the request is %s", strings.Join(enforceNames, ", "), result)
+	}
+
+	if request.Namespace != "" {
+		v.eventGen.Add(budgetExceededEvent(request, message))
+	}
+
+	if len(enforce) == 0 || overflow == config.WebhookBudgetOverflowAllow {
+		return "", false
+	}
+
+	return message, true
+}
+
+// handleMutateBudgetOverflow reports the mutate policies not applied once the budget is exceeded. The request is
+// admitted with the patches of the policies applied within the budget.
+func (ws *WebhookServer) handleMutateBudgetOverflow(request *v1beta1.AdmissionRequest, policies []*kyverno.ClusterPolicy, budget *evaluationBudget,
+	limit string, logger logr.Logger) {
+
+	var names []string
+	for _, policy := range policies {
+		if policy.HasMutate() {
+			names = append(names, policyKey(policy))
+		}
+	}
+
+	logger.Info("policy evaluation budget exceeded", "budget", limit, "evaluated", budget.evaluated, "notApplied", names)
+	countBudgetExceeded(ws.promConfig, request, limit, "none")
+
+	if request.Namespace != "" {
+		message := fmt.Sprintf("the policy evaluation budget (%s) of the request is exceeded after %d policies, the mutate policies %s are not applied",
+			limit, budget.evaluated, strings.Join(names, ", "))
+		ws.eventGen.Add(budgetExceededEvent(request, message))
+	}
+}
+
+// budgetExceededEvent returns the event created on the namespace of the request exceeding the budget
+func budgetExceededEvent(request *v1beta1.AdmissionRequest, message string) event.Info {
+	return event.Info{
+		Kind:          "Namespace",
+		Name:          request.Namespace,
+		Reason:        event.PolicyBudgetExceeded.String(),
+		Source:        event.AdmissionController,
+		Message:       fmt.Sprintf("%s %s: %s", request.Kind.Kind, request.Name, message),
+		CorrelationID: correlationID(request),
+	}
+}
+
+// policyKey returns the namespace and name of the policy, or the name of the cluster policy
+func policyKey(policy *kyverno.ClusterPolicy) string {
+	if policy.GetNamespace() == "" {
+		return policy.GetName()
+	}
+	return policy.GetNamespace() + "/" + policy.GetName()
+}
+
+// countBudgetExceeded counts the admission requests exceeding the policy evaluation budget
+func countBudgetExceeded(promConfig *metrics.PromConfig, request *v1beta1.AdmissionRequest, limit, overflow string) {
+	if promConfig == nil {
+		return
+	}
+
+	promConfig.Metrics.AdmissionBudgetExceeded.With(prom.Labels{
+		"resource_kind":              request.Kind.Kind,
+		"resource_namespace":         request.Namespace,
+		"resource_request_operation": strings.ToLower(string(request.Operation)),
+		"budget":                     limit,
+		"overflow_action":            overflow,
+	}).Inc()
+}
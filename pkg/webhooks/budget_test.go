@@ -0,0 +1,287 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakeAuditHandler struct {
+	deferred []*kyverno.ClusterPolicy
+}
+
+func (f *fakeAuditHandler) Add(request *v1beta1.AdmissionRequest) {}
+
+func (f *fakeAuditHandler) AddDeferred(request *v1beta1.AdmissionRequest, policies []*kyverno.ClusterPolicy) {
+	f.deferred = append(f.deferred, policies...)
+}
+
+func (f *fakeAuditHandler) Run(workers int, stopCh <-chan struct{}) {}
+
+// fakeBudgetConfig returns the policy evaluation budget of the ConfigMap
+type fakeBudgetConfig struct {
+	*config.ConfigData
+	budget config.WebhookBudget
+}
+
+func (f fakeBudgetConfig) GetWebhookBudget() config.WebhookBudget {
+	return f.budget
+}
+
+// newBudgetTestPolicies returns the enforce policies requiring the app label of the pod, and the policies of low
+// severity allowing the namespace thresholds, audited in the namespaces with the high enforcement threshold
+func newBudgetTestPolicies(t *testing.T, enforce, audit int) []*kyverno.ClusterPolicy {
+	var policies []*kyverno.ClusterPolicy
+	for i := 0; i < enforce+audit; i++ {
+		policy := newFailurePolicyTestPolicy(t, labelPolicyRaw, kyverno.Fail)
+		policy.SetName(fmt.Sprintf("require-labels-%d", i))
+		if i >= enforce {
+			allow := true
+			policy.Spec.AllowNamespaceThresholds = &allow
+			policy.SetAnnotations(map[string]string{kyverno.SeverityAnnotation: "low"})
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+type budgetTestResult struct {
+	ok           bool
+	msg          string
+	events       []event.Info
+	deferred     []*kyverno.ClusterPolicy
+	exceeded     float64
+	evaluated    int
+	exceededBy   string
+	overflowWith string
+}
+
+func handleBudgetTestRequest(t *testing.T, policies []*kyverno.ClusterPolicy, budget config.WebhookBudget, start time.Time) budgetTestResult {
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+	}
+	policyContext := &engine.PolicyContext{
+		NewResource:          *resource,
+		JSONContext:          ctx,
+		EnforcementThreshold: "high",
+	}
+
+	eventGen := &fakeEventGen{}
+	auditHandler := &fakeAuditHandler{}
+	v := &validationHandler{
+		log:          log.Log,
+		eventGen:     eventGen,
+		prGenerator:  &fakePRGenerator{},
+		budget:       newEvaluationBudget(budget, start),
+		auditHandler: auditHandler,
+	}
+
+	promConfig := metrics.NewPromConfig()
+	ok, msg, _, _ := v.handleValidation(promConfig, request, policies, policyContext, nil, 0)
+	result := budgetTestResult{ok: ok, msg: msg, deferred: auditHandler.deferred}
+	for _, e := range eventGen.events {
+		if e.Reason == event.PolicyBudgetExceeded.String() {
+			result.events = append(result.events, e)
+		}
+	}
+	if v.budget != nil {
+		result.evaluated = v.budget.evaluated
+	}
+
+	for _, limit := range []string{budgetPolicies, budgetTime} {
+		for _, overflow := range []string{"none", config.WebhookBudgetOverflowDeny, config.WebhookBudgetOverflowAllow} {
+			count := testutil.ToFloat64(promConfig.Metrics.AdmissionBudgetExceeded.With(prom.Labels{
+				"resource_kind":              "Pod",
+				"resource_namespace":         "test",
+				"resource_request_operation": "create",
+				"budget":                     limit,
+				"overflow_action":            overflow,
+			}))
+			if count > 0 {
+				result.exceeded += count
+				result.exceededBy, result.overflowWith = limit, overflow
+			}
+		}
+	}
+	return result
+}
+
+func Test_Budget_Max_Policies(t *testing.T) {
+	policies := newBudgetTestPolicies(t, 5, 0)
+	deny := config.WebhookBudget{MaxPolicies: 4, Overflow: config.WebhookBudgetOverflowDeny}
+
+	// one enforce policy beyond the budget
+	result := handleBudgetTestRequest(t, policies, deny, time.Now())
+	assert.Assert(t, !result.ok)
+	assert.Equal(t, result.evaluated, 4)
+	assert.Assert(t, strings.Contains(result.msg, "the enforce policies require-labels-4 are not evaluated and the request is denied"), result.msg)
+	assert.Equal(t, result.exceeded, float64(1))
+	assert.Equal(t, result.exceededBy, budgetPolicies)
+	assert.Equal(t, result.overflowWith, config.WebhookBudgetOverflowDeny)
+
+	// the event is created on the namespace of the request
+	assert.Equal(t, len(result.events), 1)
+	assert.Equal(t, result.events[0].Kind, "Namespace")
+	assert.Equal(t, result.events[0].Name, "test")
+	assert.Equal(t, result.events[0].Reason, event.PolicyBudgetExceeded.String())
+
+	// all the policies are within the budget
+	for _, maxPolicies := range []int{5, 6} {
+		result = handleBudgetTestRequest(t, policies, config.WebhookBudget{MaxPolicies: maxPolicies, Overflow: config.WebhookBudgetOverflowDeny}, time.Now())
+		assert.Assert(t, result.ok, maxPolicies)
+		assert.Equal(t, result.evaluated, 5)
+		assert.Equal(t, result.exceeded, float64(0))
+		assert.Equal(t, len(result.events), 0)
+	}
+
+	// the budget is not limited
+	assert.Assert(t, newEvaluationBudget(config.WebhookBudget{}, time.Now()) == nil)
+	result = handleBudgetTestRequest(t, policies, config.WebhookBudget{}, time.Now())
+	assert.Assert(t, result.ok)
+}
+
+func Test_Budget_Overflow_Allow(t *testing.T) {
+	policies := newBudgetTestPolicies(t, 5, 0)
+	result := handleBudgetTestRequest(t, policies, config.WebhookBudget{MaxPolicies: 2, Overflow: config.WebhookBudgetOverflowAllow}, time.Now())
+	assert.Assert(t, result.ok)
+	assert.Equal(t, result.msg, "")
+	assert.Equal(t, result.evaluated, 2)
+	assert.Equal(t, result.exceeded, float64(1))
+	assert.Equal(t, result.overflowWith, config.WebhookBudgetOverflowAllow)
+	assert.Equal(t, len(result.events), 1)
+	assert.Assert(t, strings.Contains(result.events[0].Message, "require-labels-2, require-labels-3, require-labels-4 are not evaluated and the request is allowed"), result.events[0].Message)
+	assert.Equal(t, len(result.deferred), 0)
+}
+
+func Test_Budget_Deferred_Audit_Policies(t *testing.T) {
+	// the audited policies beyond the budget are deferred, the request is not denied
+	policies := newBudgetTestPolicies(t, 2, 3)
+	result := handleBudgetTestRequest(t, policies, config.WebhookBudget{MaxPolicies: 2, Overflow: config.WebhookBudgetOverflowDeny}, time.Now())
+	assert.Assert(t, result.ok)
+	assert.Equal(t, len(result.deferred), 3)
+	assert.Equal(t, result.overflowWith, "none")
+	assert.Assert(t, strings.Contains(result.events[0].Message, "3 audit policies are evaluated in the background"), result.events[0].Message)
+
+	// the enforce policies beyond the budget are denied, the audited policies are deferred
+	policies = newBudgetTestPolicies(t, 2, 1)
+	policies = []*kyverno.ClusterPolicy{policies[0], policies[2], policies[1]}
+	result = handleBudgetTestRequest(t, policies, config.WebhookBudget{MaxPolicies: 1, Overflow: config.WebhookBudgetOverflowDeny}, time.Now())
+	assert.Assert(t, !result.ok)
+	assert.Equal(t, result.evaluated, 1)
+	assert.Equal(t, len(result.deferred), 1)
+	assert.Equal(t, result.deferred[0].GetName(), "require-labels-2")
+	assert.Assert(t, strings.Contains(result.msg, "1 audit policies are evaluated in the background, the enforce policies require-labels-1 are not evaluated"), result.msg)
+}
+
+func Test_Budget_Max_Evaluation_Time(t *testing.T) {
+	policies := newBudgetTestPolicies(t, 3, 0)
+	budget := config.WebhookBudget{MaxEvaluationTime: time.Second, Overflow: config.WebhookBudgetOverflowDeny}
+
+	// the time spent before the evaluation, e.g. loading the context, is accounted
+	result := handleBudgetTestRequest(t, policies, budget, time.Now().Add(-2*time.Second))
+	assert.Assert(t, !result.ok)
+	assert.Equal(t, result.evaluated, 0)
+	assert.Equal(t, result.exceededBy, budgetTime)
+	assert.Assert(t, strings.Contains(result.msg, "require-labels-0, require-labels-1, require-labels-2"), result.msg)
+
+	result = handleBudgetTestRequest(t, policies, budget, time.Now())
+	assert.Assert(t, result.ok)
+	assert.Equal(t, result.evaluated, 3)
+	assert.Equal(t, result.exceeded, float64(0))
+}
+
+func Test_Budget_Audit_Handler(t *testing.T) {
+	var policies []*kyverno.ClusterPolicy
+	for i := 0; i < 5; i++ {
+		policy := newFailurePolicyTestPolicy(t, labelPolicyRaw, kyverno.Fail)
+		policy.SetName(fmt.Sprintf("require-labels-%d", i))
+		policy.Spec.ValidationFailureAction = common.Audit
+		policies = append(policies, policy)
+	}
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+		Object:    runtime.RawExtension{Raw: podRaw},
+	}
+
+	process := func(budget config.WebhookBudget) (*fakePRGenerator, *fakeEventGen, *metrics.PromConfig) {
+		eventGen := &fakeEventGen{}
+		prGenerator := &fakePRGenerator{}
+		h := &auditHandler{
+			eventGen:      eventGen,
+			prGenerator:   prGenerator,
+			nsLister:      listerv1.NewNamespaceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+			log:           log.Log,
+			configHandler: fakeBudgetConfig{ConfigData: &config.ConfigData{}, budget: budget},
+			promConfig:    metrics.NewPromConfig(),
+		}
+
+		assert.NilError(t, h.process(request, policies))
+		return prGenerator, eventGen, h.promConfig
+	}
+
+	// the audit policies beyond the budget are not evaluated, nor deferred again
+	prGenerator, eventGen, promConfig := process(config.WebhookBudget{MaxPolicies: 2, Overflow: config.WebhookBudgetOverflowDeny})
+	evaluated := map[string]bool{}
+	for _, info := range prGenerator.infos {
+		evaluated[info.PolicyName] = true
+	}
+	assert.Equal(t, len(evaluated), 2)
+	assert.Assert(t, evaluated["require-labels-0"] && evaluated["require-labels-1"])
+
+	count := testutil.ToFloat64(promConfig.Metrics.AdmissionBudgetExceeded.With(prom.Labels{
+		"resource_kind":              "Pod",
+		"resource_namespace":         "test",
+		"resource_request_operation": "create",
+		"budget":                     budgetPolicies,
+		"overflow_action":            "none",
+	}))
+	assert.Equal(t, count, float64(1))
+
+	var events []event.Info
+	for _, e := range eventGen.events {
+		if e.Reason == event.PolicyBudgetExceeded.String() {
+			events = append(events, e)
+		}
+	}
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.Contains(events[0].Message, "3 audit policies are not evaluated"), events[0].Message)
+
+	// all the policies are evaluated without a budget
+	prGenerator, _, _ = process(config.WebhookBudget{})
+	evaluated = map[string]bool{}
+	for _, info := range prGenerator.infos {
+		evaluated[info.PolicyName] = true
+	}
+	assert.Equal(t, len(evaluated), 5)
+}
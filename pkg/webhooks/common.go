@@ -7,12 +7,17 @@ import (
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/policycache"
 	yamlv2 "gopkg.in/yaml.v2"
 	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	listerv1 "k8s.io/client-go/listers/core/v1"
 )
 
 // isResponseSuccessful return true if all responses are successful
@@ -39,8 +44,87 @@ func toBlockResource(engineReponses []*response.EngineResponse, log logr.Logger)
 	return false
 }
 
-// getEnforceFailureErrorMsg gets the error messages for failed enforce policy
-func getEnforceFailureErrorMsg(engineResponses []*response.EngineResponse) string {
+// requestLocale returns the locale of the rule messages set with the annotation of the resource,
+// the prior resource is used for a delete
+func requestLocale(newResource, oldResource unstructured.Unstructured) string {
+	if newResource.Object != nil {
+		return newResource.GetAnnotations()[engine.LocaleAnnotation]
+	}
+
+	return oldResource.GetAnnotations()[engine.LocaleAnnotation]
+}
+
+// denyMessageTemplate composes the messages of the failed rules in the denied requests
+type denyMessageTemplate struct {
+	template    *config.DenyMessageTemplate
+	clusterName string
+
+	// disabled are the policies that opt out of the template
+	disabled map[string]bool
+}
+
+// newDenyMessageTemplate returns the configured deny message template for the policies, or nil
+func newDenyMessageTemplate(configHandler config.Interface, policies []*kyverno.ClusterPolicy) *denyMessageTemplate {
+	if configHandler == nil {
+		return nil
+	}
+
+	template := configHandler.GetDenyMessageTemplate()
+	if template == nil {
+		return nil
+	}
+
+	disabled := make(map[string]bool)
+	for _, policy := range policies {
+		if policy.GetAnnotations()[config.DenyMessageTemplateAnnotation] == "disabled" {
+			disabled[policy.GetNamespace()+"/"+policy.GetName()] = true
+		}
+	}
+
+	return &denyMessageTemplate{
+		template:    template,
+		clusterName: configHandler.GetClusterName(),
+		disabled:    disabled,
+	}
+}
+
+// message returns the message of the failed rule, composed with the template unless the policy opts out.
+// The message is displayed in the locale of the request if the rule has a variant for it.
+func (t *denyMessageTemplate) message(er *response.EngineResponse, rule response.RuleResponse) string {
+	if rule.LocalizedMessage != "" {
+		rule.Message = rule.LocalizedMessage
+	}
+
+	message := rule.DocumentedMessage()
+	policy := er.PolicyResponse.Policy
+	if t == nil || t.disabled[policy.Namespace+"/"+policy.Name] {
+		return message
+	}
+
+	resource := er.PolicyResponse.Resource
+	return t.template.Render(map[string]interface{}{
+		"policy": map[string]interface{}{
+			"name":      policy.Name,
+			"namespace": policy.Namespace,
+		},
+		"rule": map[string]interface{}{
+			"name":    rule.Name,
+			"message": message,
+		},
+		"resource": map[string]interface{}{
+			"kind":      resource.Kind,
+			"namespace": resource.Namespace,
+			"name":      resource.Name,
+		},
+		"cluster": map[string]interface{}{
+			"name": t.clusterName,
+		},
+	})
+}
+
+// getEnforceFailureErrorMsg gets the error messages for failed enforce policy,
+// the rule messages are composed with the deny message template if it is not nil
+func getEnforceFailureErrorMsg(engineResponses []*response.EngineResponse, template *denyMessageTemplate) string {
 	policyToRule := make(map[string]interface{})
 	var resourceName string
 	for _, er := range engineResponses {
@@ -48,7 +132,7 @@ func getEnforceFailureErrorMsg(engineResponses []*response.EngineResponse) strin
 			ruleToReason := make(map[string]string)
 			for _, rule := range er.PolicyResponse.Rules {
 				if !rule.Success {
-					ruleToReason[rule.Name] = rule.Message
+					ruleToReason[rule.Name] = template.message(er, rule)
 				}
 			}
 
@@ -61,6 +145,35 @@ func getEnforceFailureErrorMsg(engineResponses []*response.EngineResponse) strin
 	return "\n\nresource " + resourceName + " was blocked due to the following policies\n\n" + string(result)
 }
 
+// violationCauseType is the type of the causes of a denied request set to the violations of the failed rules
+const violationCauseType metav1.CauseType = "PolicyViolation"
+
+// violationCauses returns the violations of the failed enforce policies as the causes of the status of a
+// denied request, so that the clients report which element failed which check
+func violationCauses(engineResponses []*response.EngineResponse) []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	for _, er := range engineResponses {
+		if er.IsSuccessful() || er.PolicyResponse.ValidationFailureAction != common.Enforce {
+			continue
+		}
+
+		for _, violation := range er.GetViolations() {
+			field := violation.Path
+			if field == "" && violation.Element != nil {
+				field = fmt.Sprintf("%s[%d]", violation.List, *violation.Element)
+			}
+
+			causes = append(causes, metav1.StatusCause{
+				Type:    violationCauseType,
+				Field:   field,
+				Message: fmt.Sprintf("policy %s: %s", er.PolicyResponse.Policy.Name, violation.String()),
+			})
+		}
+	}
+
+	return causes
+}
+
 // getErrorMsg gets all failed engine response message
 func getErrorMsg(engineReponses []*response.EngineResponse) string {
 	var str []string
@@ -126,6 +239,63 @@ func containsRBACInfo(policies ...[]*kyverno.ClusterPolicy) bool {
 	return false
 }
 
+// filterByValidationFailureAction returns the policies with the validation failure action for the namespace.
+// Enforce policies that are rolled out to a percentage of the namespaces are cached for both actions,
+// the percentage is read from the policy so that it applies to the next request without a cache rebuild.
+func filterByValidationFailureAction(policies []*kyverno.ClusterPolicy, namespace, action string) []*kyverno.ClusterPolicy {
+	var filtered []*kyverno.ClusterPolicy
+	for _, policy := range policies {
+		if policy.IsRollingOut() && policy.GetValidationFailureActionForNamespace(namespace) != action {
+			continue
+		}
+		filtered = append(filtered, policy)
+	}
+	return filtered
+}
+
+// filterByWebhookFailurePolicy returns the policies served by the resource webhook with the failure policy,
+// the policies whose failure policy is set to Fail are served by the Fail webhook
+func filterByWebhookFailurePolicy(policies []*kyverno.ClusterPolicy, fail bool) []*kyverno.ClusterPolicy {
+	var filtered []*kyverno.ClusterPolicy
+	for _, policy := range policies {
+		if policy.HasWebhookFailurePolicyFail() == fail {
+			filtered = append(filtered, policy)
+		}
+	}
+	return filtered
+}
+
+// getNamespaceLabels returns the labels of the namespace of the request, the labels are empty
+// for the namespaces and the cluster-wide resources
+func getNamespaceLabels(request *v1beta1.AdmissionRequest, nsLister listerv1.NamespaceLister, logger logr.Logger) map[string]string {
+	if request.Kind.Kind == "Namespace" || request.Namespace == "" {
+		return make(map[string]string)
+	}
+
+	return common.GetNamespaceSelectorsFromNamespaceLister(request.Kind.Kind, request.Namespace, nsLister, logger)
+}
+
+// requestSubResource returns the resource and the subresource of the request, e.g. "pods/exec", it is
+// empty for the requests of the resources
+func requestSubResource(request *v1beta1.AdmissionRequest) string {
+	if request.SubResource == "" {
+		return ""
+	}
+
+	return request.Resource.Resource + "/" + request.SubResource
+}
+
+// policyCacheRequest returns the policy cache lookup of the admission request
+func policyCacheRequest(request *v1beta1.AdmissionRequest, namespaceLabels map[string]string) policycache.Request {
+	return policycache.Request{
+		Kind:            request.Kind.Kind,
+		SubResource:     requestSubResource(request),
+		Namespace:       request.Namespace,
+		NamespaceLabels: namespaceLabels,
+		Operation:       string(request.Operation),
+	}
+}
+
 // extracts the new and old resource as unstructured
 func extractResources(newRaw []byte, request *v1beta1.AdmissionRequest) (unstructured.Unstructured, unstructured.Unstructured, error) {
 	var emptyResource unstructured.Unstructured
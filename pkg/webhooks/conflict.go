@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/event"
+	"k8s.io/api/admission/v1beta1"
+)
+
+// patchWrite is the value written to a path of the resource by the patch of a mutate policy
+type patchWrite struct {
+	policy *kyverno.ClusterPolicy
+	op     string
+	value  interface{}
+}
+
+// mutateConflict is a path of the resource written by a mutate policy and overridden by a policy applied later
+type mutateConflict struct {
+	path       string
+	policy     *kyverno.ClusterPolicy
+	overridden *kyverno.ClusterPolicy
+}
+
+// mutateConflicts detects the mutate policies of an admission request writing different values to
+// the same field of the resource. The policies are applied in order, the last write of a field wins.
+type mutateConflicts struct {
+	writes map[string]patchWrite
+}
+
+func newMutateConflicts() *mutateConflicts {
+	return &mutateConflicts{writes: make(map[string]patchWrite)}
+}
+
+// add records the patches of a policy, and returns the fields whose value, written by another policy, is overridden.
+// A patch of an object or a list, e.g. "add /metadata/labels", conflicts with the patches of its fields with other values.
+func (c *mutateConflicts) add(policy *kyverno.ClusterPolicy, patches [][]byte, logger logr.Logger) []mutateConflict {
+	var conflicts []mutateConflict
+	for _, patch := range patches {
+		var p struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(patch, &p); err != nil {
+			logger.Error(err, "failed to parse the patch")
+			continue
+		}
+
+		if p.Op != "add" && p.Op != "replace" && p.Op != "remove" {
+			continue
+		}
+
+		write := patchWrite{policy: policy, op: p.Op, value: p.Value}
+		for _, path := range c.paths() {
+			previous := c.writes[path]
+			if policyKey(previous.policy) == policyKey(policy) {
+				continue
+			}
+
+			if overrides(previous, write, path, p.Path) {
+				conflicts = append(conflicts, mutateConflict{path: path, policy: policy, overridden: previous.policy})
+			}
+		}
+
+		// the writes of the fields are replaced by the write of their parent
+		for _, path := range c.paths() {
+			if strings.HasPrefix(path, p.Path+"/") {
+				delete(c.writes, path)
+			}
+		}
+
+		c.writes[p.Path] = write
+	}
+
+	return conflicts
+}
+
+// paths returns the sorted paths written by the policies
+func (c *mutateConflicts) paths() []string {
+	paths := make([]string, 0, len(c.writes))
+	for path := range c.writes {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// overrides checks if the write of a path changes the value of a previous write of the same path, of one of
+// its fields, or of its parent
+func overrides(previous, write patchWrite, previousPath, path string) bool {
+	switch {
+	case path == previousPath:
+		return previous.op != write.op || !reflect.DeepEqual(previous.value, write.value)
+
+	case strings.HasPrefix(path, previousPath+"/"):
+		// a field of the previous value, the new fields do not conflict
+		value, ok := lookupPointer(previous.value, strings.TrimPrefix(path, previousPath+"/"))
+		return ok && (write.op == "remove" || !reflect.DeepEqual(value, write.value))
+
+	case strings.HasPrefix(previousPath, path+"/"):
+		// the parent of the previous value, the conflict is the previous field removed or changed
+		if write.op == "remove" {
+			return previous.op != "remove"
+		}
+		value, ok := lookupPointer(write.value, strings.TrimPrefix(previousPath, path+"/"))
+		return !ok || !reflect.DeepEqual(value, previous.value)
+	}
+
+	return false
+}
+
+// lookupPointer returns the value at the relative JSON pointer, e.g. "labels/team" or "containers/0/image"
+func lookupPointer(value interface{}, pointer string) (interface{}, bool) {
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			v, ok := typed[token]
+			if !ok {
+				return nil, false
+			}
+			value = v
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(typed) {
+				return nil, false
+			}
+			value = typed[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// reportMutateConflicts logs the conflicts of a mutate policy and returns the events created on the policy
+func reportMutateConflicts(request *v1beta1.AdmissionRequest, conflicts []mutateConflict, logger logr.Logger) []event.Info {
+	var events []event.Info
+	for _, conflict := range conflicts {
+		logger.Info("mutate policy overrides the patch of another policy", "path", conflict.path,
+			"policy", policyKey(conflict.policy), "priority", conflict.policy.GetPriority(),
+			"overriddenPolicy", policyKey(conflict.overridden), "overriddenPriority", conflict.overridden.GetPriority())
+
+		events = append(events, event.Info{
+			Kind:      conflict.policy.GetKind(),
+			Namespace: conflict.policy.GetNamespace(),
+			Name:      conflict.policy.GetName(),
+			Reason:    event.PolicyConflict.String(),
+			Source:    event.AdmissionController,
+			Message: fmt.Sprintf("the patches of %s %s override the value of %s set by the policy %s (priority %d), the policy priority is %d",
+				request.Kind.Kind, resourceKey(request), conflict.path, policyKey(conflict.overridden),
+				conflict.overridden.GetPriority(), conflict.policy.GetPriority()),
+			CorrelationID: correlationID(request),
+		})
+	}
+
+	return events
+}
+
+// resourceKey returns the namespace and name of the resource of the admission request, or its name
+func resourceKey(request *v1beta1.AdmissionRequest) string {
+	if request.Namespace == "" {
+		return request.Name
+	}
+
+	return request.Namespace + "/" + request.Name
+}
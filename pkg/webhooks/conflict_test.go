@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/openapi"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_MutateConflicts(t *testing.T) {
+	policyA := &kyverno.ClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: "a"}}
+	policyB := &kyverno.ClusterPolicy{ObjectMeta: metav1.ObjectMeta{Name: "b"}}
+
+	testCases := []struct {
+		name      string
+		previous  string
+		patch     string
+		conflicts []string
+	}{
+		{
+			name:      "same-path",
+			previous:  `{"op":"add","path":"/metadata/labels/team","value":"web"}`,
+			patch:     `{"op":"replace","path":"/metadata/labels/team","value":"platform"}`,
+			conflicts: []string{"/metadata/labels/team"},
+		},
+		{
+			name:     "other-path",
+			previous: `{"op":"add","path":"/metadata/labels/team","value":"web"}`,
+			patch:    `{"op":"add","path":"/metadata/labels/env","value":"prod"}`,
+		},
+		{
+			name:     "new-field-of-previous-value",
+			previous: `{"op":"add","path":"/metadata/labels","value":{"team":"web"}}`,
+			patch:    `{"op":"add","path":"/metadata/labels/env","value":"prod"}`,
+		},
+		{
+			name:      "field-of-previous-value",
+			previous:  `{"op":"add","path":"/metadata/labels","value":{"team":"web"}}`,
+			patch:     `{"op":"remove","path":"/metadata/labels/team"}`,
+			conflicts: []string{"/metadata/labels"},
+		},
+		{
+			name:      "parent-of-previous-value",
+			previous:  `{"op":"add","path":"/spec/containers/0/imagePullPolicy","value":"Always"}`,
+			patch:     `{"op":"replace","path":"/spec/containers","value":[{"name":"nginx","imagePullPolicy":"IfNotPresent"}]}`,
+			conflicts: []string{"/spec/containers/0/imagePullPolicy"},
+		},
+		{
+			name:     "parent-keeping-previous-value",
+			previous: `{"op":"add","path":"/spec/containers/0/imagePullPolicy","value":"Always"}`,
+			patch:    `{"op":"replace","path":"/spec/containers","value":[{"name":"nginx","imagePullPolicy":"Always"}]}`,
+		},
+	}
+
+	for _, test := range testCases {
+		conflicts := newMutateConflicts()
+		assert.Equal(t, len(conflicts.add(policyA, [][]byte{[]byte(test.previous)}, log.Log)), 0, test.name)
+
+		var paths []string
+		for _, conflict := range conflicts.add(policyB, [][]byte{[]byte(test.patch)}, log.Log) {
+			assert.Equal(t, conflict.overridden, policyA, test.name)
+			paths = append(paths, conflict.path)
+		}
+		assert.DeepEqual(t, paths, test.conflicts)
+	}
+
+	// the patches of the same policy do not conflict
+	conflicts := newMutateConflicts()
+	patches := [][]byte{
+		[]byte(`{"op":"add","path":"/metadata/labels","value":{"team":"web"}}`),
+		[]byte(`{"op":"replace","path":"/metadata/labels/team","value":"platform"}`),
+	}
+	assert.Equal(t, len(conflicts.add(policyA, patches, log.Log)), 0)
+}
+
+func Test_HandleMutation_Conflicts(t *testing.T) {
+	openAPIController, err := openapi.NewOpenAPIController()
+	assert.NilError(t, err)
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+		Object:    runtime.RawExtension{Raw: podRaw},
+	}
+
+	policy := func(name, team string, priority int) *kyverno.ClusterPolicy {
+		rawPolicy := []byte(fmt.Sprintf(`{
+			"apiVersion": "kyverno.io/v1",
+			"kind": "ClusterPolicy",
+			"metadata": {"name": %q},
+			"spec": {
+				"priority": %d,
+				"rules": [
+					{
+						"name": "add-team",
+						"match": {"resources": {"kinds": ["Pod"]}},
+						"mutate": {"patchStrategicMerge": {"metadata": {"labels": {"team": %q}}}}
+					}
+				]
+			}
+		}`, name, priority, team))
+
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+		return &policy
+	}
+
+	// handle returns the labels of the patched resource and the conflict events
+	handle := func(mutateConflictDetection bool) (map[string]string, []event.Info) {
+		policies := []*kyverno.ClusterPolicy{policy("team-override", "platform", 10), policy("team-default", "web", 0)}
+		engine.SortPolicies(policies)
+
+		resource, err := utils.ConvertToUnstructured(podRaw)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(podRaw))
+
+		eventGen := &fakeEventGen{}
+		ws := &WebhookServer{
+			nsLister:          listerv1.NewNamespaceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+			eventGen:          eventGen,
+			prGenerator:       &fakePRGenerator{},
+			configHandler:     fakeMutateConfig{mutateConflictDetection: mutateConflictDetection},
+			openAPIController: openAPIController,
+			promConfig:        metrics.NewPromConfig(),
+			log:               log.Log,
+		}
+
+		policyContext := &engine.PolicyContext{NewResource: *resource, JSONContext: ctx}
+		_, _, engineResponses := ws.handleMutation(request, policyContext, policies, nil, 0)
+		assert.Equal(t, len(engineResponses), 2)
+
+		var conflicts []event.Info
+		for _, e := range eventGen.events {
+			if e.Reason == event.PolicyConflict.String() {
+				conflicts = append(conflicts, e)
+			}
+		}
+
+		return policyContext.NewResource.GetLabels(), conflicts
+	}
+
+	// the policy with the higher priority is applied last and wins
+	labels, conflicts := handle(false)
+	assert.Equal(t, labels["team"], "platform")
+	assert.Equal(t, len(conflicts), 0)
+
+	labels, conflicts = handle(true)
+	assert.Equal(t, labels["team"], "platform")
+	assert.Equal(t, len(conflicts), 1)
+	assert.Equal(t, conflicts[0].Kind, "ClusterPolicy")
+	assert.Equal(t, conflicts[0].Name, "team-override")
+	assert.Equal(t, conflicts[0].Message, "the patches of Pod test/nginx override the value of /metadata/labels/team set by the policy team-default (priority 0), the policy priority is 10")
+}
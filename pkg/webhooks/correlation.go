@@ -0,0 +1,28 @@
+package webhooks
+
+import (
+	"k8s.io/api/admission/v1beta1"
+)
+
+// correlationIDAuditAnnotation is the audit annotation set to the correlation ID of the admission request,
+// the API server prefixes the key with the webhook name in the audit events
+const correlationIDAuditAnnotation = "correlation-id"
+
+// correlationID returns the ID correlating the logs, events and report results of an admission request,
+// and the audit events of the API server. It is the UID of the admission request.
+func correlationID(request *v1beta1.AdmissionRequest) string {
+	return string(request.UID)
+}
+
+// setCorrelationID returns the correlation ID of the request in the audit annotations of the response
+func setCorrelationID(resp *v1beta1.AdmissionResponse, request *v1beta1.AdmissionRequest) {
+	if resp == nil || request.UID == "" {
+		return
+	}
+
+	if resp.AuditAnnotations == nil {
+		resp.AuditAnnotations = make(map[string]string)
+	}
+
+	resp.AuditAnnotations[correlationIDAuditAnnotation] = correlationID(request)
+}
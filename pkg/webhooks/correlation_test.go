@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_CorrelationID_Logs_Events_Reports(t *testing.T) {
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	request := &v1beta1.AdmissionRequest{
+		UID:       "705ab4f5-6393-11e8-b7cc-42010a800002",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+	}
+
+	policyContext := &engine.PolicyContext{
+		NewResource:   *resource,
+		JSONContext:   ctx,
+		CorrelationID: correlationID(request),
+	}
+
+	recorder := &recordingLogger{}
+	eventGen := &fakeEventGen{}
+	prGenerator := &fakePRGenerator{}
+	v := &validationHandler{log: recorder, eventGen: eventGen, prGenerator: prGenerator}
+
+	policies := []*kyverno.ClusterPolicy{newFailurePolicyTestPolicy(t, labelPolicyRaw, kyverno.Fail)}
+	ok, _, _, _ := v.handleValidation(metrics.NewPromConfig(), request, policies, policyContext, nil, 0)
+	assert.Assert(t, ok)
+
+	id := "705ab4f5-6393-11e8-b7cc-42010a800002"
+
+	// the log lines
+	output := recorder.output()
+	assert.Assert(t, strings.Contains(output, "validation passed"), output)
+	assert.Assert(t, strings.Contains(output, "correlationID "+id), output)
+
+	// the events
+	assert.Assert(t, len(eventGen.events) > 0)
+	for _, e := range eventGen.events {
+		assert.Equal(t, e.CorrelationID, id)
+	}
+
+	// the report results
+	assert.Equal(t, len(prGenerator.infos), 1)
+	for _, result := range prGenerator.infos[0].Results {
+		for _, rule := range result.Rules {
+			assert.Equal(t, rule.Properties[response.RulePropertyCorrelationID], id)
+		}
+	}
+
+	// the audit annotation
+	resp := &v1beta1.AdmissionResponse{Allowed: true}
+	setCorrelationID(resp, request)
+	assert.Equal(t, resp.AuditAnnotations[correlationIDAuditAnnotation], id)
+}
+
+func Test_CorrelationID_IgnoredRuleErrors(t *testing.T) {
+	er := &response.EngineResponse{
+		PolicyResponse: response.PolicyResponse{
+			Policy:        response.PolicySpec{Name: "check-allowed-registries"},
+			CorrelationID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+			Rules:         []response.RuleResponse{{Name: "check-registry", Error: true, Message: "failed to load context"}},
+		},
+	}
+
+	events := ignoreRuleErrors(er, &recordingLogger{})
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Reason, event.PolicyFailed.String())
+	assert.Equal(t, events[0].CorrelationID, "705ab4f5-6393-11e8-b7cc-42010a800002")
+}
+
+// recordingLogger records the messages, errors and values, the metrics are registered
+// concurrently with the request so the lines are guarded by a mutex
+type recordingLogger struct {
+	mutex  sync.Mutex
+	lines  []string
+	values []interface{}
+}
+
+var _ logr.Logger = &recordingLogger{}
+
+func (l *recordingLogger) output() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func (l *recordingLogger) Enabled() bool {
+	return true
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf("%s %v %v", msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf("%v %s %v %v", err, msg, l.values, keysAndValues))
+}
+
+func (l *recordingLogger) V(level int) logr.Logger {
+	return l
+}
+
+func (l *recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.values = append(l.values, keysAndValues...)
+	return l
+}
+
+func (l *recordingLogger) WithName(name string) logr.Logger {
+	return l
+}
@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	"strings"
+
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// countSkippedDeleting counts the rules of the engine response skipped as the resource of the
+// admission request is being deleted
+func countSkippedDeleting(promConfig *metrics.PromConfig, request *v1beta1.AdmissionRequest, engineResponse *response.EngineResponse) {
+	if promConfig == nil {
+		return
+	}
+
+	policyNamespace := engineResponse.PolicyResponse.Policy.Namespace
+	if policyNamespace == "" {
+		policyNamespace = "-"
+	}
+
+	for _, rule := range engineResponse.PolicyResponse.Rules {
+		if !rule.Skipped || rule.Properties[response.RulePropertySkipReason] != response.SkipReasonDeleting {
+			continue
+		}
+
+		promConfig.Metrics.RuleSkippedDeleting.With(prom.Labels{
+			"policy_namespace":           policyNamespace,
+			"policy_name":                engineResponse.PolicyResponse.Policy.Name,
+			"rule_name":                  rule.Name,
+			"resource_kind":              request.Kind.Kind,
+			"resource_request_operation": strings.ToLower(string(request.Operation)),
+		}).Inc()
+	}
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var deletingPodRaw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"test","deletionTimestamp":"2021-03-29T08:30:00Z","finalizers":["example.com/cleanup"]},"spec":{"containers":[{"name":"nginx","image":"nginx:1.21"}]}}`)
+
+// deletePolicyRaw denies the admission requests of the pods with the operation
+var deletePolicyRaw = []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"deny-operations"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"deny-operation","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"the operation is denied","deny":{"conditions":[{"key":"{{request.operation}}","operator":"In","value":["UPDATE","DELETE"]}]}}}]}}`)
+
+func handleDeletingTestRequest(t *testing.T, operation v1beta1.Operation, applyToDeleting bool) (bool, *metrics.PromConfig) {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(deletePolicyRaw, &policy))
+	policy.Spec.Rules[0].ApplyToDeleting = applyToDeleting
+
+	resource, err := utils.ConvertToUnstructured(deletingPodRaw)
+	assert.NilError(t, err)
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: operation,
+		OldObject: runtime.RawExtension{Raw: deletingPodRaw},
+	}
+	policyContext := &engine.PolicyContext{
+		OldResource:   *resource,
+		JSONContext:   context.NewContext(),
+		AdmissionInfo: kyverno.RequestInfo{Operation: string(operation)},
+	}
+	if operation != v1beta1.Delete {
+		request.Object = runtime.RawExtension{Raw: deletingPodRaw}
+		policyContext.NewResource = *resource
+	}
+	assert.NilError(t, policyContext.JSONContext.AddRequest(request))
+
+	v := &validationHandler{log: log.Log, eventGen: &fakeEventGen{}, prGenerator: &fakePRGenerator{}}
+	promConfig := metrics.NewPromConfig()
+	ok, _, _, _ := v.handleValidation(promConfig, request, []*kyverno.ClusterPolicy{&policy}, policyContext, nil, 0)
+	return ok, promConfig
+}
+
+func skippedDeletingCount(promConfig *metrics.PromConfig, operation string) float64 {
+	return testutil.ToFloat64(promConfig.Metrics.RuleSkippedDeleting.With(prom.Labels{
+		"policy_namespace":           "-",
+		"policy_name":                "deny-operations",
+		"rule_name":                  "deny-operation",
+		"resource_kind":              "Pod",
+		"resource_request_operation": operation,
+	}))
+}
+
+func Test_Deleting_Update_Skipped(t *testing.T) {
+	// the update removing the finalizers is allowed, the skipped rule is counted
+	ok, promConfig := handleDeletingTestRequest(t, v1beta1.Update, false)
+	assert.Assert(t, ok)
+	assert.Equal(t, skippedDeletingCount(promConfig, "update"), float64(1))
+
+	// the rule guarding the removal of the finalizers applies
+	ok, promConfig = handleDeletingTestRequest(t, v1beta1.Update, true)
+	assert.Assert(t, !ok)
+	assert.Equal(t, skippedDeletingCount(promConfig, "update"), float64(0))
+}
+
+func Test_Deleting_Delete_Unchanged(t *testing.T) {
+	// the deletion requests have no new resource, the rules apply to them as before
+	ok, promConfig := handleDeletingTestRequest(t, v1beta1.Delete, false)
+	assert.Assert(t, !ok)
+	assert.Equal(t, skippedDeletingCount(promConfig, "delete"), float64(0))
+}
@@ -0,0 +1,210 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
+	"github.com/minio/pkg/wildcard"
+	"golang.org/x/time/rate"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AdmissionDumpConfig configures the admission reviews persisted to reproduce the admission requests offline
+type AdmissionDumpConfig struct {
+	// Dir is the directory the admission reviews are written to, one JSON file per admission review
+	Dir string
+
+	// Namespaces and Kinds filter the dumped admission requests, wildcards ('*' and '?') are allowed.
+	// All the admission requests are dumped if empty, the cluster-wide requests only match empty Namespaces.
+	Namespaces []string
+	Kinds      []string
+
+	// RateLimit is the maximum number of admission reviews dumped per second, and Burst the maximum burst
+	RateLimit float64
+	Burst     int
+
+	// MaxFiles is the maximum number of files kept in Dir, the oldest files are removed. The number of files
+	// is not limited if set to 0.
+	MaxFiles int
+}
+
+// AdmissionDumper writes the sanitized admission reviews, the request and the response, to a directory.
+// The data values of the sensitive resources are masked, as in the messages and the reports.
+type AdmissionDumper struct {
+	config         AdmissionDumpConfig
+	limiter        *rate.Limiter
+	sensitiveKinds func() []string
+	log            logr.Logger
+}
+
+// NewAdmissionDumper returns a dumper of the admission reviews, the directory is created if it does not exist
+func NewAdmissionDumper(config AdmissionDumpConfig, sensitiveKinds func() []string, log logr.Logger) (*AdmissionDumper, error) {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the admission dump directory %s: %v", config.Dir, err)
+	}
+
+	limit := rate.Limit(config.RateLimit)
+	if config.RateLimit <= 0 {
+		limit = rate.Inf
+	}
+
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+
+	return &AdmissionDumper{
+		config:         config,
+		limiter:        rate.NewLimiter(limit, config.Burst),
+		sensitiveKinds: sensitiveKinds,
+		log:            log,
+	}, nil
+}
+
+// dump writes the admission review in the background, if the request matches the filters and the rate limit
+// is not exceeded. The admission reviews are not dumped without a dumper.
+func (d *AdmissionDumper) dump(review *v1beta1.AdmissionReview) {
+	if d == nil || review.Request == nil || !d.matches(review.Request) {
+		return
+	}
+
+	if !d.limiter.Allow() {
+		d.log.V(4).Info("admission review not dumped, rate limit exceeded", "uid", review.Request.UID)
+		return
+	}
+
+	sanitized, err := sanitizeAdmissionReview(review, d.sensitiveKinds())
+	if err != nil {
+		d.log.Error(err, "failed to sanitize the admission review", "uid", review.Request.UID)
+		return
+	}
+
+	go d.write(review.Request, sanitized)
+}
+
+// matches checks if the admission request matches the namespaces and the kinds of the dumped requests
+func (d *AdmissionDumper) matches(request *v1beta1.AdmissionRequest) bool {
+	return matchesAny(d.config.Namespaces, request.Namespace) && matchesAny(d.config.Kinds, request.Kind.Kind)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && wildcard.Match(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// write writes the admission review to a file named after the time and the UID of the request, and removes
+// the oldest files exceeding the maximum
+func (d *AdmissionDumper) write(request *v1beta1.AdmissionRequest, review []byte) {
+	name := fmt.Sprintf("%s-%s.json", time.Now().UTC().Format("20060102T150405.000000000Z"), request.UID)
+	path := filepath.Join(d.config.Dir, name)
+	if err := ioutil.WriteFile(path, review, 0600); err != nil {
+		d.log.Error(err, "failed to write the admission review", "path", path)
+		return
+	}
+
+	d.log.V(4).Info("admission review dumped", "path", path, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name)
+	if d.config.MaxFiles > 0 {
+		d.prune()
+	}
+}
+
+// prune removes the oldest admission reviews exceeding the maximum number of files, the names sort by time
+func (d *AdmissionDumper) prune() {
+	files, err := filepath.Glob(filepath.Join(d.config.Dir, "*.json"))
+	if err != nil || len(files) <= d.config.MaxFiles {
+		return
+	}
+
+	sort.Strings(files)
+	for _, file := range files[:len(files)-d.config.MaxFiles] {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			d.log.Error(err, "failed to remove the admission review", "path", file)
+		}
+	}
+}
+
+// sanitizeAdmissionReview returns the JSON of the admission review with the data values of the sensitive
+// resources masked in the objects, the patches and the messages
+func sanitizeAdmissionReview(review *v1beta1.AdmissionReview, sensitiveKinds []string) ([]byte, error) {
+	review = review.DeepCopy()
+	if review.APIVersion == "" {
+		review.APIVersion = v1beta1.SchemeGroupVersion.String()
+		review.Kind = "AdmissionReview"
+	}
+
+	request := review.Request
+	if !mask.IsSensitive(request.Kind.Kind, sensitiveKinds) {
+		return json.Marshal(review)
+	}
+
+	var resources []unstructured.Unstructured
+	for _, raw := range [][]byte{request.Object.Raw, request.OldObject.Raw} {
+		if len(raw) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, fmt.Errorf("failed to decode the resource: %v", err)
+		}
+		resource := unstructured.Unstructured{Object: object}
+		resource.SetKind(request.Kind.Kind)
+		resources = append(resources, resource)
+	}
+
+	masker := mask.New(sensitiveKinds, resources...)
+	for _, object := range []*[]byte{&request.Object.Raw, &request.OldObject.Raw} {
+		if len(*object) > 0 {
+			*object = maskedJSON(masker, *object)
+		}
+	}
+
+	if review.Response != nil {
+		// the values a patch adds to the data are only masked if the masker knows the data fields
+		if len(review.Response.Patch) > 0 {
+			if masker == nil {
+				review.Response.Patch = nil
+			} else {
+				review.Response.Patch = maskedJSON(masker, review.Response.Patch)
+			}
+		}
+		if review.Response.Result != nil {
+			review.Response.Result.Message = masker.String(review.Response.Result.Message)
+		}
+		for i, warning := range review.Response.Warnings {
+			review.Response.Warnings[i] = masker.String(warning)
+		}
+	}
+
+	return json.Marshal(review)
+}
+
+// maskedJSON masks the data values of the JSON resource or patches
+func maskedJSON(masker *mask.Masker, raw []byte) []byte {
+	if masker == nil {
+		return raw
+	}
+
+	if masked, ok := masker.Value(raw).(string); ok {
+		return []byte(masked)
+	}
+
+	return raw
+}
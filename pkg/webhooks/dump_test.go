@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func secretAdmissionReview() *v1beta1.AdmissionReview {
+	secret := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db","namespace":"prod","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"stringData\":{\"password\":\"hunter22\"}}"}},"data":{"password":"aHVudGVyMjI="}}`
+	return &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			UID:       types.UID("42"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+			Namespace: "prod",
+			Name:      "db",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(secret)},
+		},
+		Response: &v1beta1.AdmissionResponse{
+			UID:     types.UID("42"),
+			Allowed: false,
+			Result:  &metav1.Status{Message: "password hunter22 is too weak"},
+			Patch:   []byte(`[{"op":"add","path":"/data/token","value":"c2VjcmV0LXRva2Vu"}]`),
+		},
+	}
+}
+
+func Test_SanitizeAdmissionReview(t *testing.T) {
+	raw, err := sanitizeAdmissionReview(secretAdmissionReview(), nil)
+	assert.NilError(t, err)
+
+	dumped := string(raw)
+	assert.Assert(t, !strings.Contains(dumped, "hunter22"), dumped)
+	assert.Assert(t, !strings.Contains(dumped, "aHVudGVyMjI="), dumped)
+	assert.Assert(t, !strings.Contains(dumped, "c2VjcmV0LXRva2Vu"), dumped)
+
+	var review v1beta1.AdmissionReview
+	assert.NilError(t, json.Unmarshal(raw, &review))
+	assert.Equal(t, review.APIVersion, "admission.k8s.io/v1beta1")
+	assert.Equal(t, review.Kind, "AdmissionReview")
+	assert.Equal(t, review.Request.Name, "db")
+	assert.Equal(t, review.Response.Allowed, false)
+
+	// the resources of other kinds are not masked
+	configMap := configMapAdmissionReview(12)
+	raw, err = sanitizeAdmissionReview(configMap, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(raw), "replicas: 3"))
+
+	raw, err = sanitizeAdmissionReview(configMap, []string{"Config*"})
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(raw), "replicas: 3"))
+}
+
+func Test_AdmissionDumper(t *testing.T) {
+	dir := t.TempDir()
+	dumper, err := NewAdmissionDumper(AdmissionDumpConfig{Dir: dir, Namespaces: []string{"prod*"}, Kinds: []string{"Secret"}, MaxFiles: 1}, func() []string { return nil }, log.Log)
+	assert.NilError(t, err)
+
+	assert.Assert(t, dumper.matches(secretAdmissionReview().Request))
+	assert.Assert(t, !dumper.matches(configMapAdmissionReview(12).Request))
+
+	other := secretAdmissionReview().Request
+	other.Namespace = "dev"
+	assert.Assert(t, !dumper.matches(other))
+
+	// the oldest admission reviews exceeding the maximum are removed
+	for _, uid := range []string{"1", "2"} {
+		request := secretAdmissionReview().Request
+		request.UID = types.UID(uid)
+		dumper.write(request, []byte(`{}`))
+		time.Sleep(time.Millisecond)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+	assert.Assert(t, strings.HasSuffix(files[0], "-2.json"), files[0])
+
+	content, err := ioutil.ReadFile(files[0])
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), `{}`)
+
+	// the admission reviews are not dumped without a dumper
+	var disabled *AdmissionDumper
+	disabled.dump(secretAdmissionReview())
+}
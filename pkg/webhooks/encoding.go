@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kyverno/kyverno/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// the media types and content encodings of the admission reviews
+const (
+	mediaTypeJSON     = "application/json"
+	mediaTypeProtobuf = "application/vnd.kubernetes.protobuf"
+
+	encodingIdentity = "identity"
+	encodingGzip     = "gzip"
+)
+
+// maxAdmissionReviewSize limits the size of the decompressed admission reviews, the compressed
+// bodies are otherwise unbounded once inflated
+const maxAdmissionReviewSize = 32 << 20
+
+var admissionCodecs = newAdmissionCodecs()
+
+func newAdmissionCodecs() serializer.CodecFactory {
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+
+	return serializer.NewCodecFactory(scheme)
+}
+
+// protobufSerializer returns the serializer of the protobuf admission reviews, the JSON bodies
+// are not recognized by it
+func protobufSerializer() (runtime.Serializer, error) {
+	info, ok := runtime.SerializerInfoForMediaType(admissionCodecs.SupportedMediaTypes(), mediaTypeProtobuf)
+	if !ok {
+		return nil, fmt.Errorf("no serializer for %s", mediaTypeProtobuf)
+	}
+
+	return info.Serializer, nil
+}
+
+// admissionEncoding is the media type and the content encoding of an admission review body
+type admissionEncoding struct {
+	mediaType       string
+	contentEncoding string
+}
+
+func (e admissionEncoding) String() string {
+	format := "json"
+	if e.mediaType == mediaTypeProtobuf {
+		format = "protobuf"
+	}
+
+	if e.contentEncoding == encodingGzip {
+		return format + "+gzip"
+	}
+
+	return format
+}
+
+// contentType returns the Content-Type header of the response
+func (e admissionEncoding) contentType() string {
+	if e.mediaType == mediaTypeJSON {
+		return "application/json; charset=utf-8"
+	}
+
+	return e.mediaType
+}
+
+// requestEncoding returns the encoding of the request body, JSON and protobuf bodies are supported,
+// either uncompressed or gzip compressed
+func requestEncoding(request *http.Request) (admissionEncoding, error) {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mediaTypeJSON && mediaType != mediaTypeProtobuf) {
+		return admissionEncoding{}, fmt.Errorf("invalid Content-Type %q, expect `%s` or `%s`", request.Header.Get("Content-Type"), mediaTypeJSON, mediaTypeProtobuf)
+	}
+
+	contentEncoding := strings.ToLower(strings.TrimSpace(request.Header.Get("Content-Encoding")))
+	switch contentEncoding {
+	case "", encodingIdentity:
+		contentEncoding = encodingIdentity
+	case encodingGzip:
+	default:
+		return admissionEncoding{}, fmt.Errorf("invalid Content-Encoding %q, expect `%s`", contentEncoding, encodingGzip)
+	}
+
+	return admissionEncoding{mediaType: mediaType, contentEncoding: contentEncoding}, nil
+}
+
+// responseEncoding returns the encoding of the response body, the response is protobuf encoded if
+// the request is and the client accepts it, and gzip compressed if the client accepts it.
+// JSON is the fallback.
+func responseEncoding(request *http.Request, requestEnc admissionEncoding) admissionEncoding {
+	enc := admissionEncoding{mediaType: mediaTypeJSON, contentEncoding: encodingIdentity}
+	if requestEnc.mediaType == mediaTypeProtobuf && accepts(request.Header.Get("Accept"), mediaTypeProtobuf, "") {
+		enc.mediaType = mediaTypeProtobuf
+	}
+
+	if accepts(request.Header.Get("Accept-Encoding"), encodingGzip, "*") {
+		enc.contentEncoding = encodingGzip
+	}
+
+	return enc
+}
+
+// accepts returns true if the value, or the wildcard if not empty, is listed in the Accept or
+// Accept-Encoding header with a non zero quality. The parameters other than the quality are ignored.
+func accepts(header, value, wildcard string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name != value && (wildcard == "" || name != wildcard) {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeAdmissionReview decompresses and decodes the request body
+func decodeAdmissionReview(body io.Reader, enc admissionEncoding) (*v1beta1.AdmissionReview, error) {
+	if enc.contentEncoding == encodingGzip {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress body: %v", err)
+		}
+		defer reader.Close()
+		body = reader
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxAdmissionReviewSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %v", err)
+	}
+
+	if len(data) > maxAdmissionReviewSize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxAdmissionReviewSize)
+	}
+
+	admissionReview := &v1beta1.AdmissionReview{}
+	if enc.mediaType == mediaTypeProtobuf {
+		s, err := protobufSerializer()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, _, err := s.Decode(data, nil, admissionReview); err != nil {
+			return nil, fmt.Errorf("failed to decode body: %v", err)
+		}
+		return admissionReview, nil
+	}
+
+	if err := json.Unmarshal(data, admissionReview); err != nil {
+		return nil, fmt.Errorf("failed to decode body: %v", err)
+	}
+
+	return admissionReview, nil
+}
+
+// encodeAdmissionReview encodes and compresses the response body
+func encodeAdmissionReview(admissionReview *v1beta1.AdmissionReview, enc admissionEncoding) ([]byte, error) {
+	var data []byte
+	var err error
+	if enc.mediaType == mediaTypeProtobuf {
+		s, serializerErr := protobufSerializer()
+		if serializerErr != nil {
+			return nil, serializerErr
+		}
+		data, err = runtime.Encode(admissionCodecs.EncoderForVersion(s, v1beta1.SchemeGroupVersion), admissionReview)
+	} else {
+		data, err = json.Marshal(admissionReview)
+	}
+
+	if err != nil || enc.contentEncoding != encodingGzip {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// countEncoding counts the admission reviews by the negotiated request and response encodings
+func countEncoding(promConfig *metrics.PromConfig, requestEnc, responseEnc admissionEncoding) {
+	if promConfig == nil {
+		return
+	}
+
+	promConfig.Metrics.AdmissionReviewEncodings.With(prom.Labels{
+		"request_encoding":  requestEnc.String(),
+		"response_encoding": responseEnc.String(),
+	}).Inc()
+}
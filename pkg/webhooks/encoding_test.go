@@ -0,0 +1,304 @@
+package webhooks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/webhookconfig"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newEncodingTestServer(t testing.TB) *WebhookServer {
+	monitor, err := webhookconfig.NewMonitor(nil, log.Log)
+	assert.NilError(t, err)
+
+	return &WebhookServer{webhookMonitor: monitor, log: log.Log, promConfig: metrics.NewPromConfig()}
+}
+
+// configMapAdmissionReview returns the admission review of a ConfigMap with the data of the size
+func configMapAdmissionReview(size int) *v1beta1.AdmissionReview {
+	configMap := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings", "namespace": "prod"},
+		"data":       map[string]interface{}{"settings.yaml": strings.Repeat("replicas: 3\n", size/12)},
+	}
+	raw, _ := json.Marshal(configMap)
+
+	return &v1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &v1beta1.AdmissionRequest{
+			UID:       types.UID("7c0b5f1a-7a9c-4b55-9f7e-9c2d1e2b3f40"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			Namespace: "prod",
+			Name:      "settings",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func gzipBytes(t testing.TB, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	assert.NilError(t, err)
+	assert.NilError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func Test_AdmissionReview_Encodings(t *testing.T) {
+	review := configMapAdmissionReview(1024)
+	jsonBody, err := json.Marshal(review)
+	assert.NilError(t, err)
+	protobufBody, err := encodeAdmissionReview(review, admissionEncoding{mediaType: mediaTypeProtobuf, contentEncoding: encodingIdentity})
+	assert.NilError(t, err)
+
+	testCases := []struct {
+		name             string
+		body             []byte
+		headers          map[string]string
+		expectedResponse string
+	}{
+		{
+			name:             "json",
+			body:             jsonBody,
+			headers:          map[string]string{"Content-Type": "application/json"},
+			expectedResponse: "json",
+		},
+		{
+			name:             "json with charset",
+			body:             jsonBody,
+			headers:          map[string]string{"Content-Type": "application/json; charset=utf-8", "Content-Encoding": "identity"},
+			expectedResponse: "json",
+		},
+		{
+			name:             "gzip json",
+			body:             gzipBytes(t, jsonBody),
+			headers:          map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip", "Accept-Encoding": "gzip"},
+			expectedResponse: "json+gzip",
+		},
+		{
+			name:             "gzip response only",
+			body:             jsonBody,
+			headers:          map[string]string{"Content-Type": "application/json", "Accept-Encoding": "br, *;q=0.5"},
+			expectedResponse: "json+gzip",
+		},
+		{
+			name:             "gzip refused",
+			body:             gzipBytes(t, jsonBody),
+			headers:          map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip", "Accept-Encoding": "gzip;q=0"},
+			expectedResponse: "json",
+		},
+		{
+			name:             "protobuf",
+			body:             protobufBody,
+			headers:          map[string]string{"Content-Type": mediaTypeProtobuf, "Accept": mediaTypeProtobuf + ", application/json"},
+			expectedResponse: "protobuf",
+		},
+		{
+			name:             "gzip protobuf",
+			body:             gzipBytes(t, protobufBody),
+			headers:          map[string]string{"Content-Type": mediaTypeProtobuf, "Content-Encoding": "gzip", "Accept": mediaTypeProtobuf, "Accept-Encoding": "gzip"},
+			expectedResponse: "protobuf+gzip",
+		},
+		{
+			// the response falls back to JSON unless protobuf is accepted
+			name:             "protobuf request json response",
+			body:             protobufBody,
+			headers:          map[string]string{"Content-Type": mediaTypeProtobuf, "Accept": "*/*"},
+			expectedResponse: "json",
+		},
+	}
+
+	for _, test := range testCases {
+		ws := newEncodingTestServer(t)
+		handler := ws.handlerFunc(func(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+			return &v1beta1.AdmissionResponse{UID: request.UID, Allowed: false, Result: &metav1.Status{Message: request.Name}}
+		}, false)
+
+		req := httptest.NewRequest("POST", "/validate", bytes.NewReader(test.body))
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, rec.Code, http.StatusOK, test.name)
+
+		body := rec.Body.Bytes()
+		enc := admissionEncoding{mediaType: mediaTypeJSON, contentEncoding: encodingIdentity}
+		if strings.HasSuffix(test.expectedResponse, "+gzip") {
+			assert.Equal(t, rec.Header().Get("Content-Encoding"), "gzip", test.name)
+			enc.contentEncoding = encodingGzip
+		} else {
+			assert.Equal(t, rec.Header().Get("Content-Encoding"), "", test.name)
+		}
+
+		if strings.HasPrefix(test.expectedResponse, "protobuf") {
+			assert.Equal(t, rec.Header().Get("Content-Type"), mediaTypeProtobuf, test.name)
+			enc.mediaType = mediaTypeProtobuf
+		} else {
+			assert.Equal(t, rec.Header().Get("Content-Type"), "application/json; charset=utf-8", test.name)
+		}
+
+		response, err := decodeAdmissionReview(bytes.NewReader(body), enc)
+		assert.NilError(t, err, test.name)
+		assert.Assert(t, response.Response != nil, test.name)
+		assert.Equal(t, response.Response.UID, review.Request.UID, test.name)
+		assert.Equal(t, response.Response.Allowed, false, test.name)
+		assert.Equal(t, response.Response.Result.Message, "settings", test.name)
+
+		requestEnc, err := requestEncoding(req)
+		assert.NilError(t, err, test.name)
+		assert.Equal(t, testutil.ToFloat64(ws.promConfig.Metrics.AdmissionReviewEncodings.With(prom.Labels{
+			"request_encoding":  requestEnc.String(),
+			"response_encoding": test.expectedResponse,
+		})), float64(1), test.name)
+	}
+}
+
+func Test_AdmissionReview_InvalidBodies(t *testing.T) {
+	jsonBody, err := json.Marshal(configMapAdmissionReview(1024))
+	assert.NilError(t, err)
+	compressed := gzipBytes(t, jsonBody)
+
+	testCases := []struct {
+		name         string
+		body         []byte
+		headers      map[string]string
+		expectedCode int
+	}{
+		{
+			name:         "unsupported content type",
+			body:         jsonBody,
+			headers:      map[string]string{"Content-Type": "text/plain"},
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "unsupported content encoding",
+			body:         jsonBody,
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"},
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "uncompressed body",
+			body:         jsonBody,
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			expectedCode: http.StatusExpectationFailed,
+		},
+		{
+			name:         "truncated gzip body",
+			body:         compressed[:len(compressed)/2],
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			expectedCode: http.StatusExpectationFailed,
+		},
+		{
+			name:         "corrupted gzip body",
+			body:         append(append([]byte{}, compressed[:10]...), bytes.Repeat([]byte{0xff}, 64)...),
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			expectedCode: http.StatusExpectationFailed,
+		},
+		{
+			name:         "gzip of invalid json",
+			body:         gzipBytes(t, []byte(`{"request": `)),
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			expectedCode: http.StatusExpectationFailed,
+		},
+		{
+			name:         "json as protobuf",
+			body:         jsonBody,
+			headers:      map[string]string{"Content-Type": mediaTypeProtobuf},
+			expectedCode: http.StatusExpectationFailed,
+		},
+		{
+			name:         "review without request",
+			body:         []byte(`{"apiVersion": "admission.k8s.io/v1beta1", "kind": "AdmissionReview"}`),
+			headers:      map[string]string{"Content-Type": "application/json"},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testCases {
+		ws := newEncodingTestServer(t)
+		called := false
+		handler := ws.handlerFunc(func(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+			called = true
+			return &v1beta1.AdmissionResponse{UID: request.UID, Allowed: true}
+		}, false)
+
+		req := httptest.NewRequest("POST", "/validate", bytes.NewReader(test.body))
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, rec.Code, test.expectedCode, test.name)
+		assert.Assert(t, !called, test.name)
+	}
+}
+
+func Test_DecodeAdmissionReview_SizeLimit(t *testing.T) {
+	// the limit applies to the decompressed body
+	body := gzipBytes(t, bytes.Repeat([]byte(" "), maxAdmissionReviewSize+1))
+	assert.Assert(t, len(body) < maxAdmissionReviewSize)
+
+	_, err := decodeAdmissionReview(bytes.NewReader(body), admissionEncoding{mediaType: mediaTypeJSON, contentEncoding: encodingGzip})
+	assert.ErrorContains(t, err, fmt.Sprintf("body exceeds %d bytes", maxAdmissionReviewSize))
+}
+
+func Test_Accepts(t *testing.T) {
+	assert.Assert(t, accepts("gzip", "gzip", "*"))
+	assert.Assert(t, accepts("deflate, GZIP;q=0.8", "gzip", "*"))
+	assert.Assert(t, accepts("*", "gzip", "*"))
+	assert.Assert(t, !accepts("", "gzip", "*"))
+	assert.Assert(t, !accepts("gzip;q=0", "gzip", "*"))
+	assert.Assert(t, !accepts("deflate, br", "gzip", "*"))
+	assert.Assert(t, accepts("application/json, application/vnd.kubernetes.protobuf;q=0.9", mediaTypeProtobuf, ""))
+	assert.Assert(t, !accepts("*/*", mediaTypeProtobuf, ""))
+}
+
+func benchmarkAdmissionReview(b *testing.B, enc admissionEncoding) {
+	review := configMapAdmissionReview(1 << 20)
+	body, err := encodeAdmissionReview(review, enc)
+	assert.NilError(b, err)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoded, err := decodeAdmissionReview(bytes.NewReader(body), enc)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		decoded.Response = &v1beta1.AdmissionResponse{UID: decoded.Request.UID, Allowed: true}
+		if _, err := encodeAdmissionReview(decoded, enc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_AdmissionReview_JSON(b *testing.B) {
+	benchmarkAdmissionReview(b, admissionEncoding{mediaType: mediaTypeJSON, contentEncoding: encodingIdentity})
+}
+
+func Benchmark_AdmissionReview_GzipJSON(b *testing.B) {
+	benchmarkAdmissionReview(b, admissionEncoding{mediaType: mediaTypeJSON, contentEncoding: encodingGzip})
+}
@@ -41,7 +41,7 @@ func (ws *WebhookServer) applyGeneratePolicies(request *v1beta1.AdmissionRequest
 	go registerAdmissionReviewLatencyMetricGenerate(logger, *ws.promConfig.Metrics, string(request.Operation), ts, &admissionReviewCompletionLatencyChannel, &triggeredGeneratePoliciesChannel, &generateEngineResponsesChannel)
 }
 
-//handleGenerate handles admission-requests for policies with generate rules
+// handleGenerate handles admission-requests for policies with generate rules
 func (ws *WebhookServer) handleGenerate(
 	request *v1beta1.AdmissionRequest,
 	policies []*kyverno.ClusterPolicy,
@@ -53,7 +53,7 @@ func (ws *WebhookServer) handleGenerate(
 	triggeredGeneratePoliciesSender *chan []kyverno.ClusterPolicy,
 	generateEngineResponsesSender *chan []*response.EngineResponse) {
 
-	logger := ws.log.WithValues("action", "generation", "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "generation", "correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
 	logger.V(6).Info("generate request")
 
 	var engineResponses []*response.EngineResponse
@@ -71,6 +71,9 @@ func (ws *WebhookServer) handleGenerate(
 			AdmissionInfo:       userRequestInfo,
 			ExcludeGroupRole:    dynamicConfig.GetExcludeGroupRole(),
 			ExcludeResourceFunc: ws.configHandler.ToFilter,
+			SensitiveKinds:      ws.configHandler.GetSensitiveKinds(),
+			MatchOriginalUser:   ws.configHandler.GetMatchOriginalUser(),
+			CorrelationID:       correlationID(request),
 			ResourceCache:       ws.resCache,
 			JSONContext:         ctx,
 			Client:              ws.client,
@@ -146,13 +149,13 @@ func (ws *WebhookServer) registerPolicyRuleExecutionLatencyMetricGenerate(logger
 	}
 }
 
-//handleUpdatesForGenerateRules handles admission-requests for update
+// handleUpdatesForGenerateRules handles admission-requests for update
 func (ws *WebhookServer) handleUpdatesForGenerateRules(request *v1beta1.AdmissionRequest, policies []*kyverno.ClusterPolicy) {
 	if request.Operation != v1beta1.Update {
 		return
 	}
 
-	logger := ws.log.WithValues("action", "generate", "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "generate", "correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
 	resource, err := enginutils.ConvertToUnstructured(request.OldObject.Raw)
 	if err != nil {
 		logger.Error(err, "failed to convert object resource to unstructured format")
@@ -168,7 +171,7 @@ func (ws *WebhookServer) handleUpdatesForGenerateRules(request *v1beta1.Admissio
 	}
 }
 
-//handleUpdateGenerateSourceResource - handles update of clone source for generate policy
+// handleUpdateGenerateSourceResource - handles update of clone source for generate policy
 func (ws *WebhookServer) handleUpdateGenerateSourceResource(resLabels map[string]string, logger logr.Logger) {
 	policyNames := strings.Split(resLabels["generate.kyverno.io/clone-policy-name"], ",")
 	for _, policyName := range policyNames {
@@ -216,7 +219,7 @@ func (ws *WebhookServer) updateAnnotationInGR(gr *v1.GenerateRequest, logger log
 	}
 }
 
-//handleUpdateGenerateTargetResource - handles update of target resource for generate policy
+// handleUpdateGenerateTargetResource - handles update of target resource for generate policy
 func (ws *WebhookServer) handleUpdateGenerateTargetResource(request *v1beta1.AdmissionRequest, policies []*v1.ClusterPolicy, resLabels map[string]string, logger logr.Logger) {
 	enqueueBool := false
 	newRes, err := enginutils.ConvertToUnstructured(request.Object.Raw)
@@ -235,6 +238,22 @@ func (ws *WebhookServer) handleUpdateGenerateTargetResource(request *v1beta1.Adm
 	}
 
 	for _, rule := range policy.Spec.Rules {
+		// the copies of a cloneList are compared with the source of the same name
+		if rule.HasCloneList() && resLabels["generate.kyverno.io/clone-list-rule"] == rule.Name {
+			obj, err := ws.client.GetResource(newRes.GetAPIVersion(), targetSourceKind, rule.Generation.CloneList.Namespace, targetSourceName)
+			if err != nil {
+				logger.V(4).Info("clone source not found", "kind", targetSourceKind, "namespace", rule.Generation.CloneList.Namespace, "name", targetSourceName)
+				continue
+			}
+
+			sourceObj, newResObj := stripNonPolicyFields(obj.Object, newRes.Object, logger)
+			if _, err := gen.ValidateResourceWithPattern(logger, newResObj, sourceObj); err != nil {
+				enqueueBool = true
+				break
+			}
+			continue
+		}
+
 		if rule.Generation.Kind == targetSourceKind && rule.Generation.Name == targetSourceName {
 			updatedRule, err := getGeneratedByResource(newRes, resLabels, ws.client, rule, logger)
 			if err != nil {
@@ -310,7 +329,7 @@ func getGeneratedByResource(newRes *unstructured.Unstructured, resLabels map[str
 	return rule, nil
 }
 
-//stripNonPolicyFields - remove feilds which get updated with each request by kyverno and are non policy fields
+// stripNonPolicyFields - remove feilds which get updated with each request by kyverno and are non policy fields
 func stripNonPolicyFields(obj, newRes map[string]interface{}, logger logr.Logger) (map[string]interface{}, map[string]interface{}) {
 
 	if metadata, found := obj["metadata"]; found {
@@ -374,9 +393,9 @@ func stripNonPolicyFields(obj, newRes map[string]interface{}, logger logr.Logger
 	return obj, newRes
 }
 
-//HandleDelete handles admission-requests for delete
+// HandleDelete handles admission-requests for delete
 func (ws *WebhookServer) handleDelete(request *v1beta1.AdmissionRequest) {
-	logger := ws.log.WithValues("action", "generation", "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "generation", "correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
 	resource, err := enginutils.ConvertToUnstructured(request.OldObject.Raw)
 	if err != nil {
 		logger.Error(err, "failed to convert object resource to unstructured format")
@@ -1,8 +1,9 @@
 package webhooks
 
 import (
+	"encoding/json"
 	"fmt"
-	"reflect"
+	"strings"
 	"time"
 
 	"github.com/kyverno/kyverno/pkg/common"
@@ -11,23 +12,24 @@ import (
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/mask"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
 	"github.com/kyverno/kyverno/pkg/metrics"
 	policyRuleExecutionLatency "github.com/kyverno/kyverno/pkg/metrics/policyruleexecutionlatency"
 	policyRuleResults "github.com/kyverno/kyverno/pkg/metrics/policyruleresults"
+	"github.com/kyverno/kyverno/pkg/policyreport"
 	"github.com/kyverno/kyverno/pkg/utils"
 	"github.com/pkg/errors"
 	v1beta1 "k8s.io/api/admission/v1beta1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func (ws *WebhookServer) applyMutatePolicies(request *v1beta1.AdmissionRequest, policyContext *engine.PolicyContext, policies []*v1.ClusterPolicy, ts int64, logger logr.Logger) []byte {
+func (ws *WebhookServer) applyMutatePolicies(request *v1beta1.AdmissionRequest, policyContext *engine.PolicyContext, policies []*v1.ClusterPolicy, budget *evaluationBudget, ts int64, logger logr.Logger) []byte {
 	var triggeredMutatePolicies []v1.ClusterPolicy
 	var mutateEngineResponses []*response.EngineResponse
 
-	mutatePatches, triggeredMutatePolicies, mutateEngineResponses := ws.handleMutation(request, policyContext, policies, ts)
+	logger = mask.New(policyContext.SensitiveKinds, policyContext.NewResource, policyContext.OldResource).Logger(logger)
+	mutatePatches, triggeredMutatePolicies, mutateEngineResponses := ws.handleMutation(request, policyContext, policies, budget, ts)
 	logger.V(6).Info("", "generated patches", string(mutatePatches))
 
 	admissionReviewLatencyDuration := int64(time.Since(time.Unix(ts, 0)))
@@ -37,11 +39,13 @@ func (ws *WebhookServer) applyMutatePolicies(request *v1beta1.AdmissionRequest,
 }
 
 // handleMutation handles mutating webhook admission request
+// the policies beyond the evaluation budget are not applied
 // return value: generated patches, triggered policies, engine responses correspdonding to the triggered policies
 func (ws *WebhookServer) handleMutation(
 	request *v1beta1.AdmissionRequest,
 	policyContext *engine.PolicyContext,
 	policies []*kyverno.ClusterPolicy,
+	budget *evaluationBudget,
 	admissionRequestTimestamp int64) ([]byte, []kyverno.ClusterPolicy, []*response.EngineResponse) {
 
 	if len(policies) == 0 {
@@ -53,7 +57,7 @@ func (ws *WebhookServer) handleMutation(
 		resourceName = request.Namespace + "/" + resourceName
 	}
 
-	logger := ws.log.WithValues("action", "mutate", "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "mutate", "correlationID", correlationID(request), "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
 
 	patchedResource := request.Object.Raw
 	newR, oldR, err := utils.ExtractResources(patchedResource, request)
@@ -62,25 +66,33 @@ func (ws *WebhookServer) handleMutation(
 		logger.Error(err, "failed to extract resource")
 		return nil, nil, nil
 	}
-	var deletionTimeStamp *metav1.Time
-	if reflect.DeepEqual(newR, unstructured.Unstructured{}) {
-		deletionTimeStamp = newR.GetDeletionTimestamp()
-	} else {
-		deletionTimeStamp = oldR.GetDeletionTimestamp()
-	}
 
-	if deletionTimeStamp != nil && request.Operation == v1beta1.Update {
-		return nil, nil, nil
-	}
+	// the patches and annotations of the sensitive resources are logged without their data
+	logger = mask.New(policyContext.SensitiveKinds, newR, oldR).Logger(logger)
+
 	var patches [][]byte
 	var engineResponses []*response.EngineResponse
+	var appliedResponses []*response.EngineResponse
+	var dryRunResponses []*response.EngineResponse
 	var triggeredPolicies []kyverno.ClusterPolicy
 
-	for _, policy := range policies {
+	// the policies writing different values to the same field are reported, the policy applied last wins
+	var conflicts *mutateConflicts
+	if ws.configHandler.GetMutateConflictDetection() {
+		conflicts = newMutateConflicts()
+	}
+
+	for i, policy := range policies {
 		if !policy.HasMutate() {
 			continue
 		}
 
+		if limit, exceeded := budget.exceeded(); exceeded {
+			ws.handleMutateBudgetOverflow(request, policies[i:], budget, limit, logger)
+			break
+		}
+
+		budget.add()
 		logger.V(3).Info("applying policy mutate rules", "policy", policy.Name)
 		policyContext.Policy = *policy
 		engineResponse, policyPatches, err := ws.applyMutation(request, policyContext, logger)
@@ -90,14 +102,30 @@ func (ws *WebhookServer) handleMutation(
 			continue
 		}
 
-		if len(policyPatches) > 0 {
-			patches = append(patches, policyPatches...)
-			rules := engineResponse.GetSuccessRules()
-			logger.Info("mutation rules from policy applied successfully", "policy", policy.Name, "rules", rules)
+		if policy.IsMutateDryRun() || ws.configHandler.GetMutateDryRun() {
+			// the patches are reported but not applied, the next policies are applied to the
+			// resource without them
+			setMutateDryRun(engineResponse, logger)
+			dryRunResponses = append(dryRunResponses, engineResponse)
+			if len(policyPatches) > 0 {
+				logger.Info("mutation rules from policy evaluated in dry run, the patches are not applied", "policy", policy.Name, "rules", engineResponse.GetSuccessRules())
+			}
+		} else {
+			if len(policyPatches) > 0 {
+				patches = append(patches, policyPatches...)
+				rules := engineResponse.GetSuccessRules()
+				logger.Info("mutation rules from policy applied successfully", "policy", policy.Name, "rules", rules)
+				if conflicts != nil {
+					ws.eventGen.Add(reportMutateConflicts(request, conflicts.add(policy, policyPatches, logger), logger)...)
+				}
+			}
+
+			policyContext.NewResource = engineResponse.PatchedResource
+			appliedResponses = append(appliedResponses, engineResponse)
 		}
 
-		policyContext.NewResource = engineResponse.PatchedResource
 		engineResponses = append(engineResponses, engineResponse)
+		countSkippedDeleting(ws.promConfig, request, engineResponse)
 
 		// registering the kyverno_policy_rule_results_info metric concurrently
 		go ws.registerPolicyRuleResultsMetricMutation(logger, string(request.Operation), *policy, *engineResponse, admissionRequestTimestamp)
@@ -109,10 +137,15 @@ func (ws *WebhookServer) handleMutation(
 	}
 
 	// generate annotations
-	if annPatches := generateAnnotationPatches(engineResponses, logger); annPatches != nil {
+	if annPatches := generateAnnotationPatches(appliedResponses, logger); annPatches != nil {
 		patches = append(patches, annPatches)
 	}
 
+	// the patches of the policies in dry run are reported with the status warn
+	if len(dryRunResponses) > 0 {
+		ws.prGenerator.Add(policyreport.GeneratePRsFromEngineResponse(dryRunResponses, logger)...)
+	}
+
 	// REPORTING EVENTS
 	// Scenario 1:
 	//   some/all policies failed to apply on the resource. a policy violation is generated.
@@ -147,6 +180,10 @@ func (ws *WebhookServer) applyMutation(request *v1beta1.AdmissionRequest, policy
 	}
 
 	engineResponse := engine.Mutate(policyContext)
+	if policyContext.Policy.GetFailurePolicy() == kyverno.Ignore {
+		ws.eventGen.Add(ignoreRuleErrors(engineResponse, logger)...)
+	}
+
 	policyPatches := engineResponse.GetPatches()
 
 	if !engineResponse.IsSuccessful() && len(engineResponse.GetFailedRules()) > 0 {
@@ -161,6 +198,44 @@ func (ws *WebhookServer) applyMutation(request *v1beta1.AdmissionRequest, policy
 	return engineResponse, policyPatches, nil
 }
 
+// setMutateDryRun marks the mutate rules of a policy in dry run, and sets the summary of the
+// patches they would apply, e.g. "add /metadata/labels/team", in the rule properties
+func setMutateDryRun(engineResponse *response.EngineResponse, logger logr.Logger) {
+	for i, rule := range engineResponse.PolicyResponse.Rules {
+		if rule.Type != engineutils.Mutation.String() {
+			continue
+		}
+
+		properties := make(map[string]string, len(rule.Properties)+2)
+		for key, value := range rule.Properties {
+			properties[key] = value
+		}
+
+		properties[response.RulePropertyMutateDryRun] = "true"
+		if summary := patchSummary(rule.Patches, logger); summary != "" {
+			properties[response.RulePropertyPatches] = summary
+		}
+
+		engineResponse.PolicyResponse.Rules[i].Properties = properties
+	}
+}
+
+// patchSummary returns the operations and paths of the patches, without their values
+func patchSummary(patches [][]byte, logger logr.Logger) string {
+	var summary []string
+	for _, patch := range patches {
+		var p rulePatch
+		if err := json.Unmarshal(patch, &p); err != nil {
+			logger.Error(err, "failed to parse the patch")
+			continue
+		}
+
+		summary = append(summary, p.Op+" "+p.Path)
+	}
+
+	return strings.Join(summary, ", ")
+}
+
 func (ws *WebhookServer) registerPolicyRuleResultsMetricMutation(logger logr.Logger, resourceRequestOperation string, policy kyverno.ClusterPolicy, engineResponse response.EngineResponse, admissionRequestTimestamp int64) {
 	resourceRequestOperationPromAlias, err := policyRuleResults.ParseResourceRequestOperation(resourceRequestOperation)
 	if err != nil {
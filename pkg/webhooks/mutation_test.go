@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"github.com/kyverno/kyverno/pkg/openapi"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeMutateConfig returns the mutateDryRun and mutateConflictDetection flags of the ConfigMap
+type fakeMutateConfig struct {
+	config.Interface
+	mutateDryRun            bool
+	mutateConflictDetection bool
+}
+
+func (f fakeMutateConfig) GetMutateDryRun() bool {
+	return f.mutateDryRun
+}
+
+func (f fakeMutateConfig) GetMutateConflictDetection() bool {
+	return f.mutateConflictDetection
+}
+
+func Test_HandleMutation_DryRun(t *testing.T) {
+	rawPolicy := []byte(`{
+		"apiVersion": "kyverno.io/v1",
+		"kind": "ClusterPolicy",
+		"metadata": {"name": "add-team-label"},
+		"spec": {
+			"rules": [
+				{
+					"name": "add-team",
+					"match": {"resources": {"kinds": ["Pod"]}},
+					"mutate": {"patchStrategicMerge": {"metadata": {"labels": {"team": "web"}}}}
+				}
+			]
+		}
+	}`)
+
+	openAPIController, err := openapi.NewOpenAPIController()
+	assert.NilError(t, err)
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+		Object:    runtime.RawExtension{Raw: podRaw},
+	}
+
+	// handle returns the patch of the admission response, the engine responses and the reported results
+	handle := func(mutateDryRun, configMutateDryRun bool) ([]byte, []*response.EngineResponse, *fakePRGenerator) {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(rawPolicy, &policy))
+		policy.Spec.MutateDryRun = mutateDryRun
+
+		resource, err := utils.ConvertToUnstructured(podRaw)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(podRaw))
+
+		prGenerator := &fakePRGenerator{}
+		ws := &WebhookServer{
+			nsLister:          listerv1.NewNamespaceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+			eventGen:          &fakeEventGen{},
+			prGenerator:       prGenerator,
+			configHandler:     fakeMutateConfig{mutateDryRun: configMutateDryRun},
+			openAPIController: openAPIController,
+			promConfig:        metrics.NewPromConfig(),
+			log:               log.Log,
+		}
+
+		policyContext := &engine.PolicyContext{NewResource: *resource, JSONContext: ctx}
+		patch, _, engineResponses := ws.handleMutation(request, policyContext, []*kyverno.ClusterPolicy{&policy}, nil, 0)
+		return patch, engineResponses, prGenerator
+	}
+
+	// the patches are applied
+	patch, engineResponses, prGenerator := handle(false, false)
+	assert.Assert(t, strings.Contains(string(patch), "/metadata/labels/team"))
+	assert.Equal(t, len(engineResponses), 1)
+	assert.Assert(t, engineResponses[0].PolicyResponse.Rules[0].Properties == nil)
+	assert.Equal(t, len(prGenerator.infos), 0)
+
+	// the policy in dry run, or all the policies with the ConfigMap flag, computes the patches
+	// and reports them with the status warn, no patch is returned
+	for _, dryRun := range [][2]bool{{true, false}, {false, true}} {
+		patch, engineResponses, prGenerator := handle(dryRun[0], dryRun[1])
+		assert.Equal(t, len(patch), 0)
+
+		assert.Equal(t, len(engineResponses), 1)
+		rule := engineResponses[0].PolicyResponse.Rules[0]
+		assert.Assert(t, rule.Success)
+		assert.Assert(t, len(rule.Patches) > 0)
+		assert.Equal(t, rule.Properties[response.RulePropertyMutateDryRun], "true")
+		assert.Assert(t, strings.Contains(rule.Properties[response.RulePropertyPatches], "/metadata/labels/team"))
+
+		assert.Equal(t, len(prGenerator.infos), 1)
+		rules := prGenerator.infos[0].Results[0].Rules
+		assert.Equal(t, len(rules), 1)
+		assert.Equal(t, rules[0].Check, "warn")
+		assert.Equal(t, rules[0].Properties[response.RulePropertyPatches], rule.Properties[response.RulePropertyPatches])
+	}
+}
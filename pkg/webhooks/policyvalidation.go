@@ -2,6 +2,7 @@ package webhooks
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,7 +14,7 @@ import (
 
 //HandlePolicyValidation performs the validation check on policy resource
 func (ws *WebhookServer) policyValidation(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
-	logger := ws.log.WithValues("action", "policy validation", "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "policy validation", "correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
 	var policy *kyverno.ClusterPolicy
 
 	if err := json.Unmarshal(request.Object.Raw, &policy); err != nil {
@@ -38,8 +39,12 @@ func (ws *WebhookServer) policyValidation(request *v1beta1.AdmissionRequest) *v1
 	logger.V(3).Info("start policy change validation")
 	defer logger.V(3).Info("finished policy change validation", "time", time.Since(startTime).String())
 
-	if err := policyvalidate.Validate(policy, ws.client, false, ws.openAPIController); err != nil {
-		logger.Error(err, "policy validation errors")
+	// the fields unknown to this version, e.g. of policies authored for a newer version, are dropped
+	// when the policy is decoded, the rules using them would not apply as authored
+	var warnings []string
+	warning, err := policyvalidate.CheckUnsupportedFields(policy, request.Object.Raw, ws.configHandler.GetUnsupportedPolicyFields())
+	if err != nil {
+		logger.Info("policy uses unsupported fields", "error", err.Error())
 		return &v1beta1.AdmissionResponse{
 			Allowed: false,
 			Result: &metav1.Status{
@@ -47,8 +52,53 @@ func (ws *WebhookServer) policyValidation(request *v1beta1.AdmissionRequest) *v1
 			},
 		}
 	}
+	if warning != "" {
+		logger.V(3).Info("policy rules use unsupported fields and are ignored", "rules", policy.Status.UnsupportedRules)
+		warnings = append(warnings, warning)
+	}
+
+	if err := policyvalidate.Validate(policy, ws.client, false, ws.openAPIController); err != nil {
+		logger.Error(err, "policy validation errors")
+		return &v1beta1.AdmissionResponse{
+			Allowed: false,
+			Result:  policyValidationStatus(err),
+		}
+	}
+
+	// the expressions returning null for the sample of the policy annotation are reported as warnings
+	testWarnings := policyvalidate.ValidateTestResource(policy, logger)
+	if len(testWarnings) > 0 {
+		logger.V(3).Info("policy expressions failed on the test resource", "warnings", testWarnings)
+		warnings = append(warnings, testWarnings...)
+	}
 
 	return &v1beta1.AdmissionResponse{
-		Allowed: true,
+		Allowed:  true,
+		Warnings: warnings,
+	}
+}
+
+// policyValidationStatus returns the status of a rejected policy, the errors of the rules are
+// returned as causes so that the clients report each of them
+func policyValidationStatus(err error) *metav1.Status {
+	status := &metav1.Status{
+		Message: err.Error(),
 	}
+
+	var ruleErrs policyvalidate.RuleErrors
+	if !errors.As(err, &ruleErrs) {
+		return status
+	}
+
+	status.Reason = metav1.StatusReasonInvalid
+	status.Details = &metav1.StatusDetails{}
+	for _, ruleErr := range ruleErrs {
+		status.Details.Causes = append(status.Details.Causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   ruleErr.Field(),
+			Message: fmt.Sprintf("rule %s: %s", ruleErr.Rule, ruleErr.Message),
+		})
+	}
+
+	return status
 }
@@ -1,6 +1,9 @@
 package webhooks
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/go-logr/logr"
 	kyvernov1alpha1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1alpha1"
 	"github.com/kyverno/kyverno/pkg/engine/response"
@@ -58,6 +61,17 @@ func generateEvents(engineResponses []*response.EngineResponse, blocked, onUpdat
 				failedRulesStr,
 				er.PolicyResponse.Policy.Name,
 			)
+
+			ids, urls := er.GetRuleDocumentation(false)
+			pe.SetRuleDocumentation(ids, urls)
+			re.SetRuleDocumentation(ids, urls)
+			pe.CorrelationID = er.PolicyResponse.CorrelationID
+			re.CorrelationID = er.PolicyResponse.CorrelationID
+			if violations := er.GetViolations(); len(violations) > 0 {
+				if raw, err := json.Marshal(violations); err == nil {
+					pe.Violations, re.Violations = string(raw), string(raw)
+				}
+			}
 			events = append(events, pe, re)
 		}
 
@@ -82,8 +96,43 @@ func generateEvents(engineResponses []*response.EngineResponse, blocked, onUpdat
 				successRulesStr,
 				er.PolicyResponse.Resource.GetKey(),
 			)
+			e.SetRuleDocumentation(er.GetRuleDocumentation(true))
+			e.CorrelationID = er.PolicyResponse.CorrelationID
 			events = append(events, e)
 		}
 	}
 	return events
 }
+
+// ignoreRuleErrors handles the rule errors of a policy with failurePolicy set to Ignore.
+// Errors are logged and reported as an event on the policy, and the rules no longer fail
+// so the policy does not block the request. The rules keep the error flag, so an error
+// result is recorded in the policy report.
+func ignoreRuleErrors(er *response.EngineResponse, log logr.Logger) []event.Info {
+	var events []event.Info
+	for i, rule := range er.PolicyResponse.Rules {
+		if !rule.Error || rule.Success {
+			continue
+		}
+
+		log.Info("ignoring rule error as spec.failurePolicy is set to Ignore", "policy", er.PolicyResponse.Policy.Name, "rule", rule.Name, "reason", rule.Message)
+		er.PolicyResponse.Rules[i].Success = true
+
+		kind := "ClusterPolicy"
+		if er.PolicyResponse.Policy.Namespace != "" {
+			kind = "Policy"
+		}
+
+		events = append(events, event.Info{
+			Kind:          kind,
+			Namespace:     er.PolicyResponse.Policy.Namespace,
+			Name:          er.PolicyResponse.Policy.Name,
+			Reason:        event.PolicyFailed.String(),
+			Source:        event.AdmissionController,
+			Message:       fmt.Sprintf("rule %s failed to process resource %s and was ignored: %s", rule.Name, er.PolicyResponse.Resource.GetKey(), rule.Message),
+			CorrelationID: er.PolicyResponse.CorrelationID,
+		})
+	}
+
+	return events
+}
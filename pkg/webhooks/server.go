@@ -3,9 +3,7 @@ package webhooks
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -128,6 +126,18 @@ type WebhookServer struct {
 	grController *generate.Controller
 
 	promConfig *metrics.PromConfig
+
+	// nsRuleCache caches the responses of the namespace scoped rules
+	nsRuleCache *engine.NamespaceRuleCache
+
+	// pexLister lists the policy exceptions, it is nil if the policy exceptions are disabled
+	pexLister kyvernolister.PolicyExceptionLister
+
+	// traces keeps the traces of the admission requests, it is nil if the admission requests are not traced
+	traces *TraceStore
+
+	// dumper persists the admission reviews, it is nil if the admission reviews are not dumped
+	dumper *AdmissionDumper
 }
 
 // NewWebhookServer creates new instance of WebhookServer accordingly to given configuration
@@ -157,6 +167,10 @@ func NewWebhookServer(
 	resCache resourcecache.ResourceCache,
 	grc *generate.Controller,
 	promConfig *metrics.PromConfig,
+	nsRuleCache *engine.NamespaceRuleCache,
+	pexLister kyvernolister.PolicyExceptionLister,
+	traces *TraceStore,
+	dumper *AdmissionDumper,
 ) (*WebhookServer, error) {
 
 	if tlsPair == nil {
@@ -202,11 +216,17 @@ func NewWebhookServer(
 		openAPIController: openAPIController,
 		resCache:          resCache,
 		promConfig:        promConfig,
+		nsRuleCache:       nsRuleCache,
+		pexLister:         pexLister,
+		traces:            traces,
+		dumper:            dumper,
 	}
 
 	mux := httprouter.New()
 	mux.HandlerFunc("POST", config.MutatingWebhookServicePath, ws.handlerFunc(ws.resourceMutation, true))
 	mux.HandlerFunc("POST", config.ValidatingWebhookServicePath, ws.handlerFunc(ws.resourceValidation, true))
+	mux.HandlerFunc("POST", config.MutatingFailWebhookServicePath, ws.handlerFunc(ws.failResourceMutation, true))
+	mux.HandlerFunc("POST", config.ValidatingFailWebhookServicePath, ws.handlerFunc(ws.failResourceValidation, true))
 	mux.HandlerFunc("POST", config.PolicyMutatingWebhookServicePath, ws.handlerFunc(ws.policyMutation, true))
 	mux.HandlerFunc("POST", config.PolicyValidatingWebhookServicePath, ws.handlerFunc(ws.policyValidation, true))
 	mux.HandlerFunc("POST", config.VerifyMutatingWebhookServicePath, ws.handlerFunc(ws.verifyHandler, false))
@@ -246,51 +266,76 @@ func (ws *WebhookServer) handlerFunc(handler func(request *v1beta1.AdmissionRequ
 		startTime := time.Now()
 		ws.webhookMonitor.SetTime(startTime)
 
-		admissionReview := ws.bodyToAdmissionReview(r, rw)
+		admissionReview, requestEnc := ws.bodyToAdmissionReview(r, rw)
 		if admissionReview == nil {
 			ws.log.Info("failed to parse admission review request", "request", r)
 			return
 		}
 
 		logger := ws.log.WithName("handlerFunc").WithValues("kind", admissionReview.Request.Kind, "namespace", admissionReview.Request.Namespace,
-			"name", admissionReview.Request.Name, "operation", admissionReview.Request.Operation, "uid", admissionReview.Request.UID)
+			"name", admissionReview.Request.Name, "operation", admissionReview.Request.Operation, "uid", admissionReview.Request.UID,
+			"correlationID", correlationID(admissionReview.Request))
 
 		admissionReview.Response = &v1beta1.AdmissionResponse{
 			Allowed: true,
 			UID:     admissionReview.Request.UID,
 		}
 
+		responseEnc := responseEncoding(r, requestEnc)
+		countEncoding(ws.promConfig, requestEnc, responseEnc)
+
 		// Do not process the admission requests for kinds that are in filterKinds for filtering
 		request := admissionReview.Request
 		if filter && ws.configHandler.ToFilter(request.Kind.Kind, request.Namespace, request.Name) {
-			writeResponse(rw, admissionReview)
+			writeResponse(rw, admissionReview, responseEnc)
 			return
 		}
 
 		admissionReview.Response = handler(request)
-		writeResponse(rw, admissionReview)
+		setCorrelationID(admissionReview.Response, request)
+		writeResponse(rw, admissionReview, responseEnc)
 		logger.V(4).Info("admission review request processed", "time", time.Since(startTime).String())
+		if trace := ws.traces.Get(correlationID(request)); trace != nil {
+			logger.V(6).Info("admission review request trace", "trace", trace)
+		}
+
+		ws.dumper.dump(admissionReview)
 
 		return
 	}
 }
 
-func writeResponse(rw http.ResponseWriter, admissionReview *v1beta1.AdmissionReview) {
-	responseJSON, err := json.Marshal(admissionReview)
+func writeResponse(rw http.ResponseWriter, admissionReview *v1beta1.AdmissionReview, enc admissionEncoding) {
+	body, err := encodeAdmissionReview(admissionReview, enc)
 	if err != nil {
 		http.Error(rw, fmt.Sprintf("Could not encode response: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if _, err := rw.Write(responseJSON); err != nil {
+	rw.Header().Set("Content-Type", enc.contentType())
+	rw.Header().Set("Vary", "Accept, Accept-Encoding")
+	if enc.contentEncoding == encodingGzip {
+		rw.Header().Set("Content-Encoding", encodingGzip)
+	}
+
+	if _, err := rw.Write(body); err != nil {
 		http.Error(rw, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
 	}
 }
 
-// resourceMutation mutates resource
+// resourceMutation mutates resource with the policies served by the webhook whose failure policy is Ignore,
+// and applies the generate policies
 func (ws *WebhookServer) resourceMutation(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
-	logger := ws.log.WithName("MutateWebhook").WithValues("uid", request.UID, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
+	return ws.mutateResource(request, false)
+}
+
+// failResourceMutation mutates resource with the policies whose failure policy is Fail
+func (ws *WebhookServer) failResourceMutation(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	return ws.mutateResource(request, true)
+}
+
+func (ws *WebhookServer) mutateResource(request *v1beta1.AdmissionRequest, fail bool) *v1beta1.AdmissionResponse {
+	logger := ws.log.WithName("MutateWebhook").WithValues("correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "gvk", request.Kind.String())
 
 	if excludeKyvernoResources(request.Kind.Kind) {
 		return successResponse(nil)
@@ -298,14 +343,33 @@ func (ws *WebhookServer) resourceMutation(request *v1beta1.AdmissionRequest) *v1
 
 	logger.V(4).Info("received an admission request in mutating webhook")
 	requestTime := time.Now().Unix()
-
-	mutatePolicies := ws.pCache.GetPolicies(policycache.Mutate, request.Kind.Kind, request.Namespace)
-	generatePolicies := ws.pCache.GetPolicies(policycache.Generate, request.Kind.Kind, request.Namespace)
-	verifyImagesPolicies := ws.pCache.GetPolicies(policycache.VerifyImages, request.Kind.Kind, request.Namespace)
+	budget := newEvaluationBudget(ws.configHandler.GetWebhookBudget(), time.Now())
+
+	// the policies whose rules only match other namespaces are skipped
+	namespaceLabels := getNamespaceLabels(request, ws.nsLister, logger)
+	lookup := policyCacheRequest(request, namespaceLabels)
+	mutatePolicies := ws.pCache.GetPoliciesForRequest(policycache.Mutate, lookup)
+	mutatePolicies = filterByWebhookFailurePolicy(mutatePolicies, fail)
+	verifyImagesPolicies := ws.pCache.GetPoliciesForRequest(policycache.VerifyImages, lookup)
+	verifyImagesPolicies = filterByWebhookFailurePolicy(verifyImagesPolicies, fail)
+
+	// the generate policies are applied in the background, they are served by the Ignore webhook only.
+	// They are not filtered by operation, the updates of the generated resources are synchronized.
+	var generatePolicies []*v1.ClusterPolicy
+	if !fail {
+		generateLookup := lookup
+		generateLookup.Operation = ""
+		generatePolicies = ws.pCache.GetPoliciesForRequest(policycache.Generate, generateLookup)
+	}
+
+	trace := ws.traces.trace(request)
+	trace.AddLookup(policycache.Mutate.String(), mutatePolicies)
+	trace.AddLookup(policycache.VerifyImages.String(), verifyImagesPolicies)
+	trace.AddLookup(policycache.Generate.String(), generatePolicies)
 
 	if len(mutatePolicies) == 0 && len(generatePolicies) == 0 && len(verifyImagesPolicies) == 0 {
 		logger.V(4).Info("no policies matched admission request")
-		if request.Operation == v1beta1.Update {
+		if request.Operation == v1beta1.Update && !fail {
 			// handle generate source resource updates
 			go ws.handleUpdatesForGenerateRules(request, []*v1.ClusterPolicy{})
 		}
@@ -319,8 +383,9 @@ func (ws *WebhookServer) resourceMutation(request *v1beta1.AdmissionRequest) *v1
 		logger.Error(err, "failed to build policy context")
 		return failureResponse(err.Error())
 	}
+	policyContext.Trace = trace
 
-	mutatePatches := ws.applyMutatePolicies(request, policyContext, mutatePolicies, requestTime, logger)
+	mutatePatches := ws.applyMutatePolicies(request, policyContext, mutatePolicies, budget, requestTime, logger)
 
 	newRequest := patchRequest(mutatePatches, request, logger)
 	imagePatches, err := ws.applyImageVerifyPolicies(newRequest, policyContext, verifyImagesPolicies, logger)
@@ -330,7 +395,9 @@ func (ws *WebhookServer) resourceMutation(request *v1beta1.AdmissionRequest) *v1
 	}
 
 	newRequest = patchRequest(imagePatches, newRequest, logger)
-	ws.applyGeneratePolicies(newRequest, policyContext, generatePolicies, requestTime, logger)
+	if !fail {
+		ws.applyGeneratePolicies(newRequest, policyContext, generatePolicies, requestTime, logger)
+	}
 
 	var patches = append(mutatePatches, imagePatches...)
 	return successResponse(patches)
@@ -347,6 +414,9 @@ func patchRequest(patches []byte, request *v1beta1.AdmissionRequest, logger logr
 func (ws *WebhookServer) buildPolicyContext(request *v1beta1.AdmissionRequest, addRoles bool) (*engine.PolicyContext, error) {
 	userRequestInfo := v1.RequestInfo{
 		AdmissionUserInfo: *request.UserInfo.DeepCopy(),
+		Operation:         string(request.Operation),
+		DryRun:            request.DryRun,
+		SubResource:       requestSubResource(request),
 	}
 
 	if addRoles {
@@ -374,13 +444,19 @@ func (ws *WebhookServer) buildPolicyContext(request *v1beta1.AdmissionRequest, a
 	}
 
 	policyContext := &engine.PolicyContext{
-		NewResource:         resource,
-		AdmissionInfo:       userRequestInfo,
-		ExcludeGroupRole:    ws.configHandler.GetExcludeGroupRole(),
-		ExcludeResourceFunc: ws.configHandler.ToFilter,
-		ResourceCache:       ws.resCache,
-		JSONContext:         ctx,
-		Client:              ws.client,
+		NewResource:           resource,
+		AdmissionInfo:         userRequestInfo,
+		ExcludeGroupRole:      ws.configHandler.GetExcludeGroupRole(),
+		ExcludeResourceFunc:   ws.configHandler.ToFilter,
+		PlatformFieldManagers: ws.configHandler.GetPlatformFieldManagers(),
+		SensitiveKinds:        ws.configHandler.GetSensitiveKinds(),
+		MatchOriginalUser:     ws.configHandler.GetMatchOriginalUser(),
+		CorrelationID:         correlationID(request),
+		ResourceCache:         ws.resCache,
+		JSONContext:           ctx,
+		Client:                ws.client,
+		Time:                  time.Now(),
+		Exceptions:            common.GetPolicyExceptions(request.Namespace, ws.pexLister, ws.log),
 	}
 
 	if request.Operation == v1beta1.Update {
@@ -457,9 +533,20 @@ func registerAdmissionReviewLatencyMetricGenerate(logger logr.Logger, promMetric
 	}
 }
 
+// resourceValidation validates resource with the enforce policies served by the webhook whose failure
+// policy is Ignore, and submits the request to the audit policies
 func (ws *WebhookServer) resourceValidation(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
-	logger := ws.log.WithName("ValidateWebhook").WithValues("uid", request.UID, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation)
-	if request.Operation == v1beta1.Delete {
+	return ws.validateResource(request, false)
+}
+
+// failResourceValidation validates resource with the enforce policies whose failure policy is Fail
+func (ws *WebhookServer) failResourceValidation(request *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	return ws.validateResource(request, true)
+}
+
+func (ws *WebhookServer) validateResource(request *v1beta1.AdmissionRequest, fail bool) *v1beta1.AdmissionResponse {
+	logger := ws.log.WithName("ValidateWebhook").WithValues("correlationID", correlationID(request), "uid", request.UID, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation)
+	if request.Operation == v1beta1.Delete && !fail {
 		ws.handleDelete(request)
 	}
 
@@ -470,11 +557,17 @@ func (ws *WebhookServer) resourceValidation(request *v1beta1.AdmissionRequest) *
 	logger.V(6).Info("received an admission request in validating webhook")
 	// timestamp at which this admission request got triggered
 	admissionRequestTimestamp := time.Now().Unix()
+	budget := newEvaluationBudget(ws.configHandler.GetWebhookBudget(), time.Now())
+
+	// the cluster policies and the policies of the requested resource namespace,
+	// the policies whose rules only match other namespaces are skipped
+	namespaceLabels := getNamespaceLabels(request, ws.nsLister, logger)
+	policies := ws.pCache.GetPoliciesForRequest(policycache.ValidateEnforce, policyCacheRequest(request, namespaceLabels))
+	policies = filterByValidationFailureAction(policies, request.Namespace, common.Enforce)
+	policies = filterByWebhookFailurePolicy(policies, fail)
 
-	policies := ws.pCache.GetPolicies(policycache.ValidateEnforce, request.Kind.Kind, "")
-	// Get namespace policies from the cache for the requested resource namespace
-	nsPolicies := ws.pCache.GetPolicies(policycache.ValidateEnforce, request.Kind.Kind, request.Namespace)
-	policies = append(policies, nsPolicies...)
+	trace := ws.traces.trace(request)
+	trace.AddLookup(policycache.ValidateEnforce.String(), policies)
 
 	var roles, clusterRoles []string
 	if containsRBACInfo(policies) {
@@ -489,6 +582,9 @@ func (ws *WebhookServer) resourceValidation(request *v1beta1.AdmissionRequest) *
 		Roles:             roles,
 		ClusterRoles:      clusterRoles,
 		AdmissionUserInfo: *request.UserInfo.DeepCopy(),
+		Operation:         string(request.Operation),
+		DryRun:            request.DryRun,
+		SubResource:       requestSubResource(request),
 	}
 
 	ctx, err := newVariablesContext(request, &userRequestInfo)
@@ -496,11 +592,6 @@ func (ws *WebhookServer) resourceValidation(request *v1beta1.AdmissionRequest) *
 		return errorResponse(logger, err, "failed create policy rule context")
 	}
 
-	namespaceLabels := make(map[string]string)
-	if request.Kind.Kind != "Namespace" && request.Namespace != "" {
-		namespaceLabels = common.GetNamespaceSelectorsFromNamespaceLister(request.Kind.Kind, request.Namespace, ws.nsLister, logger)
-	}
-
 	newResource, oldResource, err := utils.ExtractResources(nil, request)
 	if err != nil {
 		return errorResponse(logger, err, "failed create parse resource")
@@ -511,30 +602,53 @@ func (ws *WebhookServer) resourceValidation(request *v1beta1.AdmissionRequest) *
 	}
 
 	policyContext := &engine.PolicyContext{
-		NewResource:         newResource,
-		OldResource:         oldResource,
-		AdmissionInfo:       userRequestInfo,
-		ExcludeGroupRole:    ws.configHandler.GetExcludeGroupRole(),
-		ExcludeResourceFunc: ws.configHandler.ToFilter,
-		ResourceCache:       ws.resCache,
-		JSONContext:         ctx,
-		Client:              ws.client,
+		NewResource:           newResource,
+		OldResource:           oldResource,
+		AdmissionInfo:         userRequestInfo,
+		ExcludeGroupRole:      ws.configHandler.GetExcludeGroupRole(),
+		ExcludeResourceFunc:   ws.configHandler.ToFilter,
+		PlatformFieldManagers: ws.configHandler.GetPlatformFieldManagers(),
+		SensitiveKinds:        ws.configHandler.GetSensitiveKinds(),
+		MatchOriginalUser:     ws.configHandler.GetMatchOriginalUser(),
+		CorrelationID:         correlationID(request),
+		ResourceCache:         ws.resCache,
+		JSONContext:           ctx,
+		Client:                ws.client,
+		Time:                  time.Now(),
+		Locale:                requestLocale(newResource, oldResource),
+		DefaultLocale:         ws.configHandler.GetDefaultLocale(),
+		NamespaceRuleCache:    ws.nsRuleCache,
+		EnforcementThreshold:  common.GetNamespaceEnforcementThreshold(request.Kind.Kind, request.Namespace, ws.nsLister, logger),
+		Exceptions:            common.GetPolicyExceptions(request.Namespace, ws.pexLister, logger),
+		Trace:                 trace,
 	}
 
 	vh := &validationHandler{
-		log:         ws.log,
-		eventGen:    ws.eventGen,
-		prGenerator: ws.prGenerator,
+		log:           ws.log,
+		eventGen:      ws.eventGen,
+		prGenerator:   ws.prGenerator,
+		configHandler: ws.configHandler,
+		budget:        budget,
+		auditHandler:  ws.auditHandler,
 	}
 
-	ok, msg := vh.handleValidation(ws.promConfig, request, policies, policyContext, namespaceLabels, admissionRequestTimestamp)
+	ok, msg, changes, causes := vh.handleValidation(ws.promConfig, request, policies, policyContext, namespaceLabels, admissionRequestTimestamp)
 	if !ok {
 		logger.Info("admission request denied")
-		return failureResponse(msg)
+		resp := failureResponse(msg)
+		if len(causes) > 0 {
+			resp.Result.Details = &metav1.StatusDetails{Causes: causes}
+		}
+		if changes != "" {
+			resp.AuditAnnotations = map[string]string{changedFieldsAuditAnnotation: changes}
+		}
+		return resp
 	}
 
 	// push admission request to audit handler, this won't block the admission request
-	ws.auditHandler.Add(request.DeepCopy())
+	if !fail {
+		ws.auditHandler.Add(request.DeepCopy())
+	}
 
 	return successResponse(nil)
 }
@@ -573,38 +687,39 @@ func (ws *WebhookServer) Stop(ctx context.Context) {
 	}
 }
 
-// bodyToAdmissionReview creates AdmissionReview object from request body
+// bodyToAdmissionReview creates AdmissionReview object from request body, and returns the
+// negotiated encoding of the request. The JSON and protobuf bodies may be gzip compressed.
 // Answers to the http.ResponseWriter if request is not valid
-func (ws *WebhookServer) bodyToAdmissionReview(request *http.Request, writer http.ResponseWriter) *v1beta1.AdmissionReview {
+func (ws *WebhookServer) bodyToAdmissionReview(request *http.Request, writer http.ResponseWriter) (*v1beta1.AdmissionReview, admissionEncoding) {
 	logger := ws.log
 	if request.Body == nil {
 		logger.Info("empty body", "req", request.URL.String())
 		http.Error(writer, "empty body", http.StatusBadRequest)
-		return nil
+		return nil, admissionEncoding{}
 	}
 
 	defer request.Body.Close()
-	body, err := ioutil.ReadAll(request.Body)
+	enc, err := requestEncoding(request)
 	if err != nil {
-		logger.Info("failed to read HTTP body", "req", request.URL.String())
-		http.Error(writer, "failed to read HTTP body", http.StatusBadRequest)
+		logger.Info("invalid request encoding", "contentType", request.Header.Get("Content-Type"), "contentEncoding", request.Header.Get("Content-Encoding"))
+		http.Error(writer, err.Error(), http.StatusUnsupportedMediaType)
+		return nil, admissionEncoding{}
 	}
 
-	contentType := request.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		logger.Info("invalid Content-Type", "contextType", contentType)
-		http.Error(writer, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
-		return nil
+	admissionReview, err := decodeAdmissionReview(request.Body, enc)
+	if err != nil {
+		logger.Error(err, "failed to decode request body to type 'AdmissionReview", "encoding", enc.String())
+		http.Error(writer, "Can't decode body as AdmissionReview", http.StatusExpectationFailed)
+		return nil, admissionEncoding{}
 	}
 
-	admissionReview := &v1beta1.AdmissionReview{}
-	if err := json.Unmarshal(body, &admissionReview); err != nil {
-		logger.Error(err, "failed to decode request body to type 'AdmissionReview")
-		http.Error(writer, "Can't decode body as AdmissionReview", http.StatusExpectationFailed)
-		return nil
+	if admissionReview.Request == nil {
+		logger.Info("admission review without a request", "req", request.URL.String())
+		http.Error(writer, "AdmissionReview has no request", http.StatusBadRequest)
+		return nil, admissionEncoding{}
 	}
 
-	return admissionReview
+	return admissionReview, enc
 }
 
 func newVariablesContext(request *v1beta1.AdmissionRequest, userRequestInfo *v1.RequestInfo) (*enginectx.Context, error) {
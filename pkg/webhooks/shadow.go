@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	engineutils "github.com/kyverno/kyverno/pkg/engine/utils"
+)
+
+// isShadowEnforce returns true if the validate rules of the enforce policy are evaluated without denying
+// the requests, with the policy spec.shadowEnforce or with the shadowEnforce flag of the ConfigMap
+func (v *validationHandler) isShadowEnforce(policy *kyverno.ClusterPolicy) bool {
+	if policy.IsShadowEnforce() {
+		return true
+	}
+
+	return v.configHandler != nil && v.configHandler.GetShadowEnforce()
+}
+
+// setShadowEnforce audits the engine response of an enforce policy in shadow mode, and marks its
+// validate rules so that the reported failures are told apart from the failures of audit policies
+func setShadowEnforce(engineResponse *response.EngineResponse) {
+	engineResponse.PolicyResponse.ValidationFailureAction = common.Audit
+	for i, rule := range engineResponse.PolicyResponse.Rules {
+		if rule.Type != engineutils.Validation.String() {
+			continue
+		}
+
+		properties := make(map[string]string, len(rule.Properties)+1)
+		for key, value := range rule.Properties {
+			properties[key] = value
+		}
+
+		properties[response.RulePropertyShadowEnforce] = "true"
+		engineResponse.PolicyResponse.Rules[i].Properties = properties
+	}
+}
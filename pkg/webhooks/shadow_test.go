@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeShadowEnforceConfig returns the shadowEnforce flag of the ConfigMap
+type fakeShadowEnforceConfig struct {
+	config.Interface
+	shadowEnforce bool
+}
+
+func (f fakeShadowEnforceConfig) GetShadowEnforce() bool {
+	return f.shadowEnforce
+}
+
+func (f fakeShadowEnforceConfig) GetDenyMessageTemplate() *config.DenyMessageTemplate {
+	return nil
+}
+
+// teamPolicyRaw requires the label 'team', the pod of podRaw fails it
+var teamPolicyRaw = []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+func Test_HandleValidation_ShadowEnforce(t *testing.T) {
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+	}
+
+	// handle returns if the request is allowed and the reported results
+	handle := func(shadowEnforce, configShadowEnforce bool) (bool, *fakePRGenerator) {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(teamPolicyRaw, &policy))
+		policy.Spec.ShadowEnforce = shadowEnforce
+
+		resource, err := utils.ConvertToUnstructured(podRaw)
+		assert.NilError(t, err)
+
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(podRaw))
+
+		prGenerator := &fakePRGenerator{}
+		v := &validationHandler{
+			log:           log.Log,
+			eventGen:      &fakeEventGen{},
+			prGenerator:   prGenerator,
+			configHandler: fakeShadowEnforceConfig{shadowEnforce: configShadowEnforce},
+		}
+
+		policyContext := &engine.PolicyContext{NewResource: *resource, JSONContext: ctx}
+		ok, _, _, _ := v.handleValidation(metrics.NewPromConfig(), request, []*kyverno.ClusterPolicy{&policy}, policyContext, nil, 0)
+		return ok, prGenerator
+	}
+
+	// the enforce policy denies the request
+	ok, prGenerator := handle(false, false)
+	assert.Assert(t, !ok)
+	assert.Equal(t, len(prGenerator.infos), 0)
+
+	// the policy in shadow mode, or all the policies with the ConfigMap flag, report the failure
+	// as for an audit policy and the request is allowed
+	for _, shadow := range [][2]bool{{true, false}, {false, true}} {
+		ok, prGenerator := handle(shadow[0], shadow[1])
+		assert.Assert(t, ok)
+
+		assert.Equal(t, len(prGenerator.infos), 1)
+		rules := prGenerator.infos[0].Results[0].Rules
+		assert.Equal(t, len(rules), 1)
+		assert.Equal(t, rules[0].Check, report.StatusFail)
+		assert.Equal(t, rules[0].Properties[response.RulePropertyShadowEnforce], "true")
+	}
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"k8s.io/api/admission/v1beta1"
+)
+
+// TracesServicePath is the path of the traces of the admission requests, the trace of a request
+// is served at TracesServicePath/<correlation ID>
+const TracesServicePath = "/debug/traces"
+
+// TraceStore keeps the traces of the last admission requests in memory, by correlation ID. The
+// mutating and validating webhooks of a request record to the same trace.
+type TraceStore struct {
+	mutex  sync.Mutex
+	size   int
+	traces map[string]*engine.Trace
+
+	// order are the correlation IDs of the traces, from the oldest to the newest
+	order []string
+}
+
+// NewTraceStore returns a store of the traces of the last size admission requests
+func NewTraceStore(size int) *TraceStore {
+	return &TraceStore{
+		size:   size,
+		traces: make(map[string]*engine.Trace),
+	}
+}
+
+// trace returns the trace of an admission request, it is created for the first webhook of the request.
+// It returns nil if the admission requests are not traced.
+func (s *TraceStore) trace(request *v1beta1.AdmissionRequest) *engine.Trace {
+	if s == nil || s.size <= 0 {
+		return nil
+	}
+
+	id := correlationID(request)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if trace, ok := s.traces[id]; ok {
+		return trace
+	}
+
+	trace := engine.NewTrace(id, string(request.Operation), response.ResourceSpec{
+		Kind:       request.Kind.Kind,
+		APIVersion: schemaGroupVersion(request),
+		Namespace:  request.Namespace,
+		Name:       request.Name,
+	})
+
+	s.traces[id] = trace
+	s.order = append(s.order, id)
+	if len(s.order) > s.size {
+		delete(s.traces, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return trace
+}
+
+// Get returns the trace of the admission request with the correlation ID, or nil
+func (s *TraceStore) Get(id string) *engine.Trace {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.traces[id]
+}
+
+// List returns the traces, from the newest to the oldest
+func (s *TraceStore) List() []*engine.Trace {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	traces := make([]*engine.Trace, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		traces = append(traces, s.traces[s.order[i]])
+	}
+
+	return traces
+}
+
+// ServeHTTP serves the traces at TracesServicePath, and the trace of a request at TracesServicePath/<correlation ID>
+func (s *TraceStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body interface{}
+	if id := strings.Trim(strings.TrimPrefix(r.URL.Path, TracesServicePath), "/"); id != "" {
+		trace := s.Get(id)
+		if trace == nil {
+			http.Error(w, "trace not found", http.StatusNotFound)
+			return
+		}
+		body = trace
+	} else {
+		body = s.List()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CheckTraceAddr checks that the trace endpoint only listens on a loopback address, unless allowRemote is set.
+// The endpoint is not authenticated and the traces hold the resolved variables of the admission requests.
+func CheckTraceAddr(addr string, allowRemote bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid trace address %s: %v", addr, err)
+	}
+
+	if allowRemote || host == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return fmt.Errorf("the trace address %s is not a loopback address, set --trace-allow-remote to expose the unauthenticated trace endpoint", addr)
+}
+
+func schemaGroupVersion(request *v1beta1.AdmissionRequest) string {
+	if request.Kind.Group == "" {
+		return request.Kind.Version
+	}
+
+	return request.Kind.Group + "/" + request.Kind.Version
+}
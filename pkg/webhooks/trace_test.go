@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_TraceStore(t *testing.T) {
+	store := NewTraceStore(2)
+	request := func(uid string) *v1beta1.AdmissionRequest {
+		return &v1beta1.AdmissionRequest{
+			UID:       types.UID(uid),
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Namespace: "prod",
+			Name:      "web",
+			Operation: v1beta1.Create,
+		}
+	}
+
+	// the webhooks of a request share its trace
+	first := store.trace(request("1"))
+	assert.Assert(t, first == store.trace(request("1")))
+	store.trace(request("2"))
+	store.trace(request("3"))
+
+	// the oldest trace is evicted
+	assert.Assert(t, store.Get("1") == nil)
+	assert.Equal(t, len(store.List()), 2)
+	assert.Equal(t, store.List()[0].CorrelationID(), "3")
+
+	rec := httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, TracesServicePath+"/2", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	var trace map[string]interface{}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &trace))
+	assert.Equal(t, trace["correlationID"], "2")
+	assert.Equal(t, trace["operation"], "CREATE")
+	assert.DeepEqual(t, trace["resource"], map[string]interface{}{"kind": "Deployment", "apiVersion": "apps/v1", "namespace": "prod", "name": "web", "uid": ""})
+
+	rec = httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, TracesServicePath, nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	var traces []map[string]interface{}
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &traces))
+	assert.Equal(t, len(traces), 2)
+
+	rec = httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, TracesServicePath+"/1", nil))
+	assert.Equal(t, rec.Code, http.StatusNotFound)
+
+	// the requests are not traced without a store
+	var disabled *TraceStore
+	assert.Assert(t, disabled.trace(request("1")) == nil)
+	assert.Assert(t, disabled.Get("1") == nil)
+}
+
+func Test_CheckTraceAddr(t *testing.T) {
+	for _, addr := range []string{"localhost:6061", "127.0.0.1:6061", "[::1]:6061"} {
+		assert.NilError(t, CheckTraceAddr(addr, false), addr)
+	}
+
+	for _, addr := range []string{":6061", "0.0.0.0:6061", "10.0.0.1:6061", "kyverno:6061"} {
+		assert.ErrorContains(t, CheckTraceAddr(addr, false), "not a loopback address", addr)
+		assert.NilError(t, CheckTraceAddr(addr, true), addr)
+	}
+
+	assert.ErrorContains(t, CheckTraceAddr("6061", false), "invalid trace address")
+}
@@ -0,0 +1,197 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// changedFieldsAuditAnnotation is the audit annotation set to the changed fields of the denied UPDATE requests,
+// the API server prefixes the key with the webhook name in the audit events
+const changedFieldsAuditAnnotation = "changed-fields"
+
+// the limits of the summary of the changed fields, the values are truncated and the remaining fields counted
+const (
+	maxChangedFields      = 5
+	maxChangedValueLength = 40
+)
+
+// noisyFields are the fields changed by the API server and the clients on most updates,
+// they are not listed in the changed fields
+var noisyFields = map[string]bool{
+	"metadata.managedFields":     true,
+	"metadata.resourceVersion":   true,
+	"metadata.generation":        true,
+	"metadata.uid":               true,
+	"metadata.creationTimestamp": true,
+	"metadata.selfLink":          true,
+	"metadata.annotations.kubectl.kubernetes.io/last-applied-configuration": true,
+}
+
+// changedFields returns the summary of the fields changed by the UPDATE request at the paths failing
+// the enforce rules, e.g. "changed: spec.replicas 3→15", or an empty string if the failing fields are unchanged
+func changedFields(engineResponses []*response.EngineResponse, oldResource, newResource unstructured.Unstructured) string {
+	if oldResource.Object == nil || newResource.Object == nil {
+		return ""
+	}
+
+	var paths []string
+	for _, er := range engineResponses {
+		if er.IsSuccessful() || er.PolicyResponse.ValidationFailureAction != common.Enforce {
+			continue
+		}
+
+		for _, rule := range er.PolicyResponse.Rules {
+			if !rule.Success {
+				paths = append(paths, rule.FailedPaths...)
+			}
+		}
+	}
+
+	changes := make(map[string]string)
+	for _, path := range paths {
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		if len(segments) == 1 && segments[0] == "" {
+			segments = nil
+		}
+
+		oldValue, _ := fieldValue(oldResource.Object, segments)
+		newValue, _ := fieldValue(newResource.Object, segments)
+		diffValues(oldValue, newValue, fieldPath(segments), changes)
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var summary []string
+	for i, field := range fields {
+		if i == maxChangedFields {
+			summary = append(summary, fmt.Sprintf("and %d more", len(fields)-maxChangedFields))
+			break
+		}
+		summary = append(summary, field+" "+changes[field])
+	}
+
+	return "changed: " + strings.Join(summary, ", ")
+}
+
+// fieldValue returns the value at the path segments of the object, the list items are selected by their index
+func fieldValue(obj interface{}, segments []string) (interface{}, bool) {
+	value := obj
+	for _, segment := range segments {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			v, ok := typed[segment]
+			if !ok {
+				return nil, false
+			}
+			value = v
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(typed) {
+				return nil, false
+			}
+			value = typed[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// fieldPath returns the dotted path of the segments, e.g. "spec.containers[0].image"
+func fieldPath(segments []string) string {
+	var path string
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+			continue
+		}
+
+		if path != "" {
+			path += "."
+		}
+		path += segment
+	}
+	return path
+}
+
+// diffValues adds the changed fields under the path, with their old and new values, to the changes
+func diffValues(oldValue, newValue interface{}, path string, changes map[string]string) {
+	if noisyFields[path] || reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	// the added and removed objects are compared with an empty object, to list their fields
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if (oldIsMap || oldValue == nil) && (newIsMap || newValue == nil) {
+		keys := make(map[string]bool)
+		for key := range oldMap {
+			keys[key] = true
+		}
+		for key := range newMap {
+			keys[key] = true
+		}
+
+		for key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			diffValues(oldMap[key], newMap[key], child, changes)
+		}
+		return
+	}
+
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList && newIsList && len(oldList) == len(newList) {
+		for i := range oldList {
+			diffValues(oldList[i], newList[i], fmt.Sprintf("%s[%d]", path, i), changes)
+		}
+		return
+	}
+
+	if path == "" {
+		path = "."
+	}
+	changes[path] = formatValue(oldValue) + "→" + formatValue(newValue)
+}
+
+// formatValue returns the compact representation of the value, truncated to the maximum length
+func formatValue(value interface{}) string {
+	var str string
+	switch typed := value.(type) {
+	case nil:
+		str = "<none>"
+	case string:
+		str = strconv.Quote(typed)
+	default:
+		raw, err := json.Marshal(typed)
+		if err != nil {
+			str = fmt.Sprintf("%v", typed)
+		} else {
+			str = string(raw)
+		}
+	}
+
+	if runes := []rune(str); len(runes) > maxChangedValueLength {
+		str = string(runes[:maxChangedValueLength-3]) + "..."
+	}
+	return str
+}
@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var oldDeploymentRaw = []byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"test","resourceVersion":"100","labels":{"app":"web"}},"spec":{"replicas":3,"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.21","resources":{"limits":{"cpu":"500m"}}}]}}}}`)
+
+func newDeployment(t *testing.T, raw []byte, update func(obj map[string]interface{})) unstructured.Unstructured {
+	resource, err := utils.ConvertToUnstructured(raw)
+	assert.NilError(t, err)
+	if update != nil {
+		update(resource.Object)
+	}
+	return *resource
+}
+
+func failedEngineResponse(paths ...string) []*response.EngineResponse {
+	return []*response.EngineResponse{{
+		PolicyResponse: response.PolicyResponse{
+			Policy:                  response.PolicySpec{Name: "restrict-deployments"},
+			ValidationFailureAction: common.Enforce,
+			Rules:                   []response.RuleResponse{{Name: "check", Type: "Validation", FailedPaths: paths}},
+		},
+	}}
+}
+
+func Test_Changed_Fields_Denied_Update(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"max-replicas"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-replicas","match":{"resources":{"kinds":["Deployment"]}},"validate":{"message":"at most 10 replicas are allowed","pattern":{"spec":{"replicas":"<=10"}}}}]}}`)
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	oldResource := newDeployment(t, oldDeploymentRaw, nil)
+	newResource := newDeployment(t, oldDeploymentRaw, func(obj map[string]interface{}) {
+		obj["spec"].(map[string]interface{})["replicas"] = int64(15)
+		obj["metadata"].(map[string]interface{})["resourceVersion"] = "101"
+	})
+
+	newRaw, err := newResource.MarshalJSON()
+	assert.NilError(t, err)
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(newRaw))
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Name:      "web",
+		Namespace: "test",
+		Operation: v1beta1.Update,
+	}
+	policyContext := &engine.PolicyContext{
+		NewResource: newResource,
+		OldResource: oldResource,
+		JSONContext: ctx,
+	}
+
+	v := &validationHandler{log: log.Log, eventGen: &fakeEventGen{}, prGenerator: &fakePRGenerator{}}
+	ok, msg, changes, _ := v.handleValidation(metrics.NewPromConfig(), request, []*kyverno.ClusterPolicy{&policy}, policyContext, nil, 0)
+	assert.Assert(t, !ok)
+	assert.Equal(t, changes, "changed: spec.replicas 3→15")
+	assert.Assert(t, strings.HasSuffix(msg, "\nchanged: spec.replicas 3→15"), msg)
+
+	// the denied creations have no changes
+	request.Operation = v1beta1.Create
+	policyContext.OldResource = unstructured.Unstructured{}
+	ok, msg, changes, _ = v.handleValidation(metrics.NewPromConfig(), request, []*kyverno.ClusterPolicy{&policy}, policyContext, nil, 0)
+	assert.Assert(t, !ok)
+	assert.Equal(t, changes, "")
+	assert.Assert(t, !strings.Contains(msg, "changed:"), msg)
+}
+
+func Test_Changed_Fields_Multiple(t *testing.T) {
+	oldResource := newDeployment(t, oldDeploymentRaw, nil)
+	newResource := newDeployment(t, oldDeploymentRaw, func(obj map[string]interface{}) {
+		spec := obj["spec"].(map[string]interface{})
+		spec["replicas"] = int64(15)
+		container := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+		container["image"] = "nginx:latest"
+		container["resources"] = map[string]interface{}{}
+
+		// the noisy metadata fields are not listed
+		metadata := obj["metadata"].(map[string]interface{})
+		metadata["resourceVersion"] = "101"
+		metadata["managedFields"] = []interface{}{map[string]interface{}{"manager": "kubectl"}}
+		metadata["annotations"] = map[string]interface{}{"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	})
+
+	changes := changedFields(failedEngineResponse("/spec/template/spec/containers/0/", "/spec/replicas/", "/metadata/"), oldResource, newResource)
+	assert.Equal(t, changes, `changed: spec.replicas 3→15, `+
+		`spec.template.spec.containers[0].image "nginx:1.21"→"nginx:latest", `+
+		`spec.template.spec.containers[0].resources.limits.cpu "500m"→<none>`)
+
+	// the duplicated paths are listed once
+	changes = changedFields(failedEngineResponse("/spec/replicas/", "/spec/replicas/"), oldResource, newResource)
+	assert.Equal(t, changes, "changed: spec.replicas 3→15")
+}
+
+func Test_Changed_Fields_Size_Capped(t *testing.T) {
+	oldResource := newDeployment(t, oldDeploymentRaw, nil)
+	newResource := newDeployment(t, oldDeploymentRaw, func(obj map[string]interface{}) {
+		labels := obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+		labels["app"] = strings.Repeat("a", 100)
+		for _, key := range []string{"b", "c", "d", "e", "f", "g"} {
+			labels[key] = key
+		}
+	})
+
+	changes := changedFields(failedEngineResponse("/metadata/labels/"), oldResource, newResource)
+	assert.Equal(t, changes, `changed: metadata.labels.app "web"→"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa..., `+
+		`metadata.labels.b <none>→"b", metadata.labels.c <none>→"c", metadata.labels.d <none>→"d", `+
+		`metadata.labels.e <none>→"e", and 2 more`)
+}
+
+func Test_Changed_Fields_Unchanged(t *testing.T) {
+	oldResource := newDeployment(t, oldDeploymentRaw, nil)
+	newResource := newDeployment(t, oldDeploymentRaw, func(obj map[string]interface{}) {
+		obj["spec"].(map[string]interface{})["replicas"] = int64(15)
+	})
+
+	// the rule fails on the team label which is missing in both resources
+	assert.Equal(t, changedFields(failedEngineResponse("/metadata/labels/team/"), oldResource, newResource), "")
+	assert.Equal(t, changedFields(failedEngineResponse("/spec/template/spec/containers/0/image/"), oldResource, newResource), "")
+
+	// the rules without failed paths, and the audit failures, have no changes
+	assert.Equal(t, changedFields(failedEngineResponse(), oldResource, newResource), "")
+	audit := failedEngineResponse("/spec/replicas/")
+	audit[0].PolicyResponse.ValidationFailureAction = common.Audit
+	assert.Equal(t, changedFields(audit, oldResource, newResource), "")
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/go-logr/logr"
 	v1 "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	kyvernolister "github.com/kyverno/kyverno/pkg/client/listers/kyverno/v1"
 	"github.com/kyverno/kyverno/pkg/config"
 	"github.com/kyverno/kyverno/pkg/event"
 	"github.com/kyverno/kyverno/pkg/metrics"
@@ -42,9 +43,17 @@ const (
 // when process the admission request in the webhook
 type AuditHandler interface {
 	Add(request *v1beta1.AdmissionRequest)
+	AddDeferred(request *v1beta1.AdmissionRequest, policies []*v1.ClusterPolicy)
 	Run(workers int, stopCh <-chan struct{})
 }
 
+// deferredRequest is an admission request with the policies deferred by the validating webhook,
+// once the policy evaluation budget of the request is exceeded
+type deferredRequest struct {
+	request  *v1beta1.AdmissionRequest
+	policies []*v1.ClusterPolicy
+}
+
 type auditHandler struct {
 	client      *client.Client
 	queue       workqueue.RateLimitingInterface
@@ -63,6 +72,8 @@ type auditHandler struct {
 	configHandler config.Interface
 	resCache      resourcecache.ResourceCache
 	promConfig    *metrics.PromConfig
+	nsRuleCache   *engine.NamespaceRuleCache
+	pexLister     kyvernolister.PolicyExceptionLister
 }
 
 // NewValidateAuditHandler returns a new instance of audit policy handler
@@ -76,7 +87,9 @@ func NewValidateAuditHandler(pCache policycache.Interface,
 	dynamicConfig config.Interface,
 	resCache resourcecache.ResourceCache,
 	client *client.Client,
-	promConfig *metrics.PromConfig) AuditHandler {
+	promConfig *metrics.PromConfig,
+	nsRuleCache *engine.NamespaceRuleCache,
+	pexLister kyvernolister.PolicyExceptionLister) AuditHandler {
 
 	return &auditHandler{
 		pCache:         pCache,
@@ -94,6 +107,8 @@ func NewValidateAuditHandler(pCache policycache.Interface,
 		resCache:       resCache,
 		client:         client,
 		promConfig:     promConfig,
+		nsRuleCache:    nsRuleCache,
+		pexLister:      pexLister,
 	}
 }
 
@@ -102,6 +117,12 @@ func (h *auditHandler) Add(request *v1beta1.AdmissionRequest) {
 	h.queue.Add(request)
 }
 
+// AddDeferred adds the request to the work queue, the policies are applied instead of the audit policies
+func (h *auditHandler) AddDeferred(request *v1beta1.AdmissionRequest, policies []*v1.ClusterPolicy) {
+	h.log.V(4).Info("admission request deferred", "uid", request.UID, "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "policies", len(policies))
+	h.queue.Add(&deferredRequest{request: request, policies: policies})
+}
+
 func (h *auditHandler) Run(workers int, stopCh <-chan struct{}) {
 	h.log.V(4).Info("starting")
 
@@ -134,27 +155,41 @@ func (h *auditHandler) processNextWorkItem() bool {
 
 	defer h.queue.Done(obj)
 
-	request, ok := obj.(*v1beta1.AdmissionRequest)
-	if !ok {
+	var request *v1beta1.AdmissionRequest
+	var deferred []*v1.ClusterPolicy
+	switch item := obj.(type) {
+	case *v1beta1.AdmissionRequest:
+		request = item
+	case *deferredRequest:
+		request, deferred = item.request, item.policies
+	default:
 		h.queue.Forget(obj)
 		h.log.Info("incorrect type: expecting type 'AdmissionRequest'", "object", obj)
 		return true
 	}
 
-	err := h.process(request)
+	err := h.process(request, deferred)
 	h.handleErr(err, obj, request)
 
 	return true
 }
 
-func (h *auditHandler) process(request *v1beta1.AdmissionRequest) error {
+// process applies the audit policies to the request, or the deferred policies if any, within the evaluation budget
+func (h *auditHandler) process(request *v1beta1.AdmissionRequest, deferred []*v1.ClusterPolicy) error {
 	var roles, clusterRoles []string
 	var err error
 	// time at which the corresponding the admission request's processing got initiated
 	admissionRequestTimestamp := time.Now().Unix()
-	logger := h.log.WithName("process")
-
-	policies := h.pCache.GetPolicies(policycache.ValidateAudit, request.Kind.Kind, request.Namespace)
+	budget := newEvaluationBudget(h.configHandler.GetWebhookBudget(), time.Now())
+	logger := h.log.WithName("process").WithValues("correlationID", correlationID(request))
+
+	// the policies whose rules only match other namespaces are skipped
+	namespaceLabels := getNamespaceLabels(request, h.nsLister, logger)
+	policies := deferred
+	if policies == nil {
+		policies = h.pCache.GetPoliciesForRequest(policycache.ValidateAudit, policyCacheRequest(request, namespaceLabels))
+		policies = filterByValidationFailureAction(policies, request.Namespace, common.Audit)
+	}
 
 	// getRoleRef only if policy has roles/clusterroles defined
 	if containsRBACInfo(policies) {
@@ -167,18 +202,17 @@ func (h *auditHandler) process(request *v1beta1.AdmissionRequest) error {
 	userRequestInfo := v1.RequestInfo{
 		Roles:             roles,
 		ClusterRoles:      clusterRoles,
-		AdmissionUserInfo: request.UserInfo}
+		AdmissionUserInfo: request.UserInfo,
+		Operation:         string(request.Operation),
+		DryRun:            request.DryRun,
+		SubResource:       requestSubResource(request),
+	}
 
 	ctx, err := newVariablesContext(request, &userRequestInfo)
 	if err != nil {
 		return errors.Wrap(err, "unable to build variable context")
 	}
 
-	namespaceLabels := make(map[string]string)
-	if request.Kind.Kind != "Namespace" && request.Namespace != "" {
-		namespaceLabels = common.GetNamespaceSelectorsFromNamespaceLister(request.Kind.Kind, request.Namespace, h.nsLister, logger)
-	}
-
 	newResource, oldResource, err := utils.ExtractResources(nil, request)
 	if err != nil {
 		return errors.Wrap(err, "failed create parse resource")
@@ -189,20 +223,31 @@ func (h *auditHandler) process(request *v1beta1.AdmissionRequest) error {
 	}
 
 	policyContext := &engine.PolicyContext{
-		NewResource:         newResource,
-		OldResource:         oldResource,
-		AdmissionInfo:       userRequestInfo,
-		ExcludeGroupRole:    h.configHandler.GetExcludeGroupRole(),
-		ExcludeResourceFunc: h.configHandler.ToFilter,
-		ResourceCache:       h.resCache,
-		JSONContext:         ctx,
-		Client:              h.client,
+		NewResource:           newResource,
+		OldResource:           oldResource,
+		AdmissionInfo:         userRequestInfo,
+		ExcludeGroupRole:      h.configHandler.GetExcludeGroupRole(),
+		ExcludeResourceFunc:   h.configHandler.ToFilter,
+		PlatformFieldManagers: h.configHandler.GetPlatformFieldManagers(),
+		SensitiveKinds:        h.configHandler.GetSensitiveKinds(),
+		MatchOriginalUser:     h.configHandler.GetMatchOriginalUser(),
+		CorrelationID:         correlationID(request),
+		ResourceCache:         h.resCache,
+		JSONContext:           ctx,
+		Client:                h.client,
+		Locale:                requestLocale(newResource, oldResource),
+		DefaultLocale:         h.configHandler.GetDefaultLocale(),
+		NamespaceRuleCache:    h.nsRuleCache,
+		EnforcementThreshold:  common.GetNamespaceEnforcementThreshold(request.Kind.Kind, request.Namespace, h.nsLister, logger),
+		Exceptions:            common.GetPolicyExceptions(request.Namespace, h.pexLister, logger),
 	}
 
 	vh := &validationHandler{
-		log:         h.log,
-		eventGen:    h.eventGen,
-		prGenerator: h.prGenerator,
+		log:           h.log,
+		eventGen:      h.eventGen,
+		prGenerator:   h.prGenerator,
+		configHandler: h.configHandler,
+		budget:        budget,
 	}
 
 	vh.handleValidation(h.promConfig, request, policies, policyContext, namespaceLabels, admissionRequestTimestamp)
@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-logr/logr"
 	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
 	"github.com/kyverno/kyverno/pkg/engine"
 	"github.com/kyverno/kyverno/pkg/engine/response"
 	"github.com/kyverno/kyverno/pkg/metrics"
@@ -20,43 +22,49 @@ import (
 )
 
 type validationHandler struct {
-	log         logr.Logger
-	eventGen    event.Interface
-	prGenerator policyreport.GeneratorInterface
+	log           logr.Logger
+	eventGen      event.Interface
+	prGenerator   policyreport.GeneratorInterface
+	configHandler config.Interface
+
+	// budget limits the policies evaluated for the request, nil if it is not limited
+	budget *evaluationBudget
+
+	// auditHandler evaluates the audit policies deferred once the budget is exceeded
+	auditHandler AuditHandler
 }
 
 // handleValidation handles validating webhook admission request
 // If there are no errors in validating rule we apply generation rules
 // patchedResource is the (resource + patches) after applying mutation rules
+// The denied UPDATE requests are returned with the summary of the changes of the failing fields, and
+// the denied requests with the violations of the failed rules
 func (v *validationHandler) handleValidation(
 	promConfig *metrics.PromConfig,
 	request *v1beta1.AdmissionRequest,
 	policies []*kyverno.ClusterPolicy,
 	policyContext *engine.PolicyContext,
 	namespaceLabels map[string]string,
-	admissionRequestTimestamp int64) (bool, string) {
+	admissionRequestTimestamp int64) (bool, string, string, []metav1.StatusCause) {
 
 	if len(policies) == 0 {
-		return true, ""
+		return true, "", "", nil
 	}
 
 	resourceName := getResourceName(request)
-	logger := v.log.WithValues("action", "validate", "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
-
-	var deletionTimeStamp *metav1.Time
-	if reflect.DeepEqual(policyContext.NewResource, unstructured.Unstructured{}) {
-		deletionTimeStamp = policyContext.NewResource.GetDeletionTimestamp()
-	} else {
-		deletionTimeStamp = policyContext.OldResource.GetDeletionTimestamp()
-	}
-
-	if deletionTimeStamp != nil && request.Operation == v1beta1.Update {
-		return true, ""
-	}
+	logger := v.log.WithValues("action", "validate", "correlationID", correlationID(request), "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
 
 	var engineResponses []*response.EngineResponse
 	var triggeredPolicies []kyverno.ClusterPolicy
-	for _, policy := range policies {
+	var overflow []*kyverno.ClusterPolicy
+	var exceededLimit string
+	for i, policy := range policies {
+		if limit, exceeded := v.budget.exceeded(); exceeded {
+			overflow, exceededLimit = policies[i:], limit
+			break
+		}
+
+		v.budget.add()
 		logger.V(3).Info("evaluating policy", "policy", policy.Name)
 		policyContext.Policy = *policy
 		policyContext.NamespaceLabels = namespaceLabels
@@ -67,6 +75,20 @@ func (v *validationHandler) handleValidation(
 			continue
 		}
 
+		if policy.GetFailurePolicy() == kyverno.Ignore {
+			v.eventGen.Add(ignoreRuleErrors(engineResponse, logger)...)
+		}
+
+		if engineResponse.PolicyResponse.ValidationFailureAction == common.Enforce && v.isShadowEnforce(policy) {
+			// the failures are reported as for an audit policy, the request is not denied
+			setShadowEnforce(engineResponse)
+			if !engineResponse.IsSuccessful() {
+				logger.Info("validation failed for the policy in shadow enforce mode, the request is not denied", "policy", policy.Name, "failed rules", engineResponse.GetFailedRules())
+			}
+		}
+
+		countSkippedDeleting(promConfig, request, engineResponse)
+
 		// registering the kyverno_policy_rule_results_info metric concurrently
 		go registerPolicyRuleResultsMetricValidation(promConfig, logger, string(request.Operation), policyContext.Policy, *engineResponse, admissionRequestTimestamp)
 		// registering the kyverno_policy_rule_execution_latency_milliseconds metric concurrently
@@ -105,12 +127,26 @@ func (v *validationHandler) handleValidation(
 		//registering the kyverno_admission_review_latency_milliseconds metric concurrently
 		admissionReviewLatencyDuration := int64(time.Since(time.Unix(admissionRequestTimestamp, 0)))
 		go registerAdmissionReviewLatencyMetricValidate(promConfig, logger, string(request.Operation), engineResponses, triggeredPolicies, admissionReviewLatencyDuration, admissionRequestTimestamp)
-		return false, getEnforceFailureErrorMsg(engineResponses)
+		msg := getEnforceFailureErrorMsg(engineResponses, newDenyMessageTemplate(v.configHandler, policies))
+		var changes string
+		if request.Operation == v1beta1.Update {
+			changes = changedFields(engineResponses, policyContext.OldResource, policyContext.NewResource)
+			if changes != "" {
+				msg += "\n" + changes
+			}
+		}
+		return false, msg, changes, violationCauses(engineResponses)
+	}
+
+	if len(overflow) > 0 {
+		if msg, deny := v.handleBudgetOverflow(promConfig, request, overflow, exceededLimit, policyContext.EnforcementThreshold, logger); deny {
+			return false, msg, "", nil
+		}
 	}
 
 	if request.Operation == v1beta1.Delete {
 		v.prGenerator.Add(buildDeletionPrInfo(policyContext.OldResource))
-		return true, ""
+		return true, "", "", nil
 	}
 
 	prInfos := policyreport.GeneratePRsFromEngineResponse(engineResponses, logger)
@@ -120,7 +156,7 @@ func (v *validationHandler) handleValidation(
 	admissionReviewLatencyDuration := int64(time.Since(time.Unix(admissionRequestTimestamp, 0)))
 	go registerAdmissionReviewLatencyMetricValidate(promConfig, logger, string(request.Operation), engineResponses, triggeredPolicies, admissionReviewLatencyDuration, admissionRequestTimestamp)
 
-	return true, ""
+	return true, "", "", nil
 }
 
 func getResourceName(request *v1beta1.AdmissionRequest) string {
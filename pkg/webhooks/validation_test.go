@@ -0,0 +1,424 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	kyverno "github.com/kyverno/kyverno/pkg/api/kyverno/v1"
+	report "github.com/kyverno/kyverno/pkg/api/policyreport/v1alpha1"
+	"github.com/kyverno/kyverno/pkg/common"
+	"github.com/kyverno/kyverno/pkg/config"
+	"github.com/kyverno/kyverno/pkg/engine"
+	"github.com/kyverno/kyverno/pkg/engine/context"
+	"github.com/kyverno/kyverno/pkg/engine/response"
+	"github.com/kyverno/kyverno/pkg/engine/utils"
+	"github.com/kyverno/kyverno/pkg/event"
+	"github.com/kyverno/kyverno/pkg/metrics"
+	policy2 "github.com/kyverno/kyverno/pkg/policy"
+	"github.com/kyverno/kyverno/pkg/policyreport"
+	"github.com/kyverno/kyverno/pkg/resourcecache"
+	yamlv2 "gopkg.in/yaml.v2"
+	"gotest.tools/assert"
+	v1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// emptyResourceCache has no informers, loading a ConfigMap context entry fails
+type emptyResourceCache struct{}
+
+func (emptyResourceCache) CreateInformers(gvks ...string) []error { return nil }
+func (emptyResourceCache) CreateGVKInformer(gvk string) (resourcecache.GenericCache, error) {
+	return nil, nil
+}
+func (emptyResourceCache) StopResourceInformer(gvk string) {}
+func (emptyResourceCache) GetGVRCache(gvk string) (resourcecache.GenericCache, bool) {
+	return nil, false
+}
+func (emptyResourceCache) GetAPICallCache(gvr schema.GroupVersionResource) (resourcecache.GenericCache, bool) {
+	return nil, false
+}
+
+type fakeEventGen struct {
+	events []event.Info
+}
+
+func (f *fakeEventGen) Add(infos ...event.Info) {
+	f.events = append(f.events, infos...)
+}
+
+type fakePRGenerator struct {
+	infos []policyreport.Info
+}
+
+func (f *fakePRGenerator) Add(infos ...policyreport.Info) {
+	f.infos = append(f.infos, infos...)
+}
+
+var contextPolicyRaw = []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"check-allowed-registries"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-registry","match":{"resources":{"kinds":["Pod"]}},"context":[{"name":"registries","configMap":{"name":"allowed-registries","namespace":"kyverno"}}],"validate":{"message":"image registry is not allowed","deny":{"conditions":[{"key":"{{request.object.spec.containers[0].image}}","operator":"NotIn","value":"{{registries.data.allowed}}"}]}}}]}}`)
+
+var labelPolicyRaw = []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-labels"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-app-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'app' is required","pattern":{"metadata":{"labels":{"app":"?*"}}}}}]}}`)
+
+var podRaw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"test","labels":{"app":"nginx"}},"spec":{"containers":[{"name":"nginx","image":"nginx:1.21"}]}}`)
+
+func newFailurePolicyTestPolicy(t *testing.T, raw []byte, failurePolicy kyverno.FailurePolicyType) *kyverno.ClusterPolicy {
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(raw, &policy))
+	policy.Spec.FailurePolicy = failurePolicy
+	return &policy
+}
+
+func handleFailurePolicyTestRequest(t *testing.T, policies []*kyverno.ClusterPolicy) (bool, *fakeEventGen, *fakePRGenerator) {
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+	}
+
+	policyContext := &engine.PolicyContext{
+		NewResource:   *resource,
+		JSONContext:   ctx,
+		ResourceCache: emptyResourceCache{},
+	}
+
+	eventGen := &fakeEventGen{}
+	prGenerator := &fakePRGenerator{}
+	v := &validationHandler{log: log.Log, eventGen: eventGen, prGenerator: prGenerator}
+
+	ok, _, _, _ := v.handleValidation(metrics.NewPromConfig(), request, policies, policyContext, nil, 0)
+	return ok, eventGen, prGenerator
+}
+
+func Test_FailurePolicy_Ignore_Does_Not_Block(t *testing.T) {
+	policies := []*kyverno.ClusterPolicy{
+		newFailurePolicyTestPolicy(t, contextPolicyRaw, kyverno.Ignore),
+		newFailurePolicyTestPolicy(t, labelPolicyRaw, kyverno.Fail),
+	}
+
+	ok, eventGen, prGenerator := handleFailurePolicyTestRequest(t, policies)
+	assert.Assert(t, ok)
+
+	// the error is reported as an event on the policy
+	var failedEvents int
+	for _, e := range eventGen.events {
+		if e.Reason == event.PolicyFailed.String() {
+			failedEvents++
+			assert.Equal(t, e.Kind, "ClusterPolicy")
+			assert.Equal(t, e.Name, "check-allowed-registries")
+		}
+	}
+	assert.Equal(t, failedEvents, 1)
+
+	// an error result is recorded for the Ignore policy, the Fail policy passes
+	checks := map[string]string{}
+	for _, info := range prGenerator.infos {
+		for _, result := range info.Results {
+			for _, rule := range result.Rules {
+				checks[info.PolicyName+"/"+rule.Name] = rule.Check
+			}
+		}
+	}
+	assert.Equal(t, len(checks), 2)
+	assert.Equal(t, checks["check-allowed-registries/check-registry"], report.StatusError)
+	assert.Equal(t, checks["require-labels/check-app-label"], report.StatusPass)
+}
+
+func Test_FailurePolicy_Fail_Blocks(t *testing.T) {
+	policies := []*kyverno.ClusterPolicy{
+		newFailurePolicyTestPolicy(t, contextPolicyRaw, kyverno.Fail),
+		newFailurePolicyTestPolicy(t, labelPolicyRaw, kyverno.Fail),
+	}
+
+	ok, _, _ := handleFailurePolicyTestRequest(t, policies)
+	assert.Assert(t, !ok)
+
+	// Fail is the default
+	policies[0].Spec.FailurePolicy = ""
+	ok, _, _ = handleFailurePolicyTestRequest(t, policies)
+	assert.Assert(t, !ok)
+}
+
+// denyMessages returns the rule messages of the deny message of a request by policy
+func denyMessages(t *testing.T, msg string) map[string]map[string]string {
+	header := "was blocked due to the following policies\n\n"
+	assert.Assert(t, strings.Contains(msg, header), msg)
+	messages := map[string]map[string]string{}
+	assert.NilError(t, yamlv2.Unmarshal([]byte(msg[strings.Index(msg, header)+len(header):]), &messages))
+	return messages
+}
+
+func Test_Enforcement_Threshold_Blocks(t *testing.T) {
+	// the pod has no team label
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","allowNamespaceThresholds":true,"rules":[{"name":"check-team","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	tests := []struct {
+		name            string
+		severity        string
+		threshold       string
+		expectedBlocked bool
+		expectedAction  string
+	}{
+		{name: "below threshold", severity: "medium", threshold: "high", expectedBlocked: false, expectedAction: "audit"},
+		{name: "at threshold", severity: "high", threshold: "high", expectedBlocked: true},
+		{name: "no threshold", severity: "low", threshold: "", expectedBlocked: true},
+		{name: "missing severity", severity: "", threshold: "critical", expectedBlocked: true},
+	}
+
+	for _, test := range tests {
+		var policy kyverno.ClusterPolicy
+		assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+		if test.severity != "" {
+			policy.SetAnnotations(map[string]string{kyverno.SeverityAnnotation: test.severity})
+		}
+
+		resource, err := utils.ConvertToUnstructured(podRaw)
+		assert.NilError(t, err)
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(podRaw))
+
+		request := &v1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Name:      "nginx",
+			Namespace: "test",
+			Operation: v1beta1.Create,
+		}
+		policyContext := &engine.PolicyContext{
+			NewResource:          *resource,
+			JSONContext:          ctx,
+			EnforcementThreshold: test.threshold,
+		}
+
+		prGenerator := &fakePRGenerator{}
+		v := &validationHandler{log: log.Log, eventGen: &fakeEventGen{}, prGenerator: prGenerator}
+		ok, msg, _, _ := v.handleValidation(metrics.NewPromConfig(), request, []*kyverno.ClusterPolicy{&policy}, policyContext, nil, 0)
+		assert.Equal(t, !ok, test.expectedBlocked, test.name)
+		if test.expectedBlocked {
+			assert.Equal(t, denyMessages(t, msg)["require-team"]["check-team"], "validation error: label 'team' is required. Rule check-team failed at path /metadata/labels/team/", test.name)
+			continue
+		}
+
+		// the downgraded failure is reported with the effective action
+		assert.Equal(t, len(prGenerator.infos), 1, test.name)
+		rule := prGenerator.infos[0].Results[0].Rules[0]
+		assert.Equal(t, rule.Check, report.StatusFail, test.name)
+		assert.Equal(t, rule.Properties[response.RulePropertyValidationFailureAction], test.expectedAction, test.name)
+	}
+}
+
+func Test_Filter_By_Validation_Failure_Action(t *testing.T) {
+	newPolicy := func(name, action string, percentage *int) *kyverno.ClusterPolicy {
+		return &kyverno.ClusterPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       kyverno.Spec{ValidationFailureAction: action, RolloutPercentage: percentage},
+		}
+	}
+	names := func(policies []*kyverno.ClusterPolicy) []string {
+		var names []string
+		for _, policy := range policies {
+			names = append(names, policy.GetName())
+		}
+		return names
+	}
+
+	// the namespace assignment is deterministic, team-b is in bucket 27 and team-a in bucket 46
+	assert.Equal(t, kyverno.RolloutBucket("team-b"), 27)
+	assert.Equal(t, kyverno.RolloutBucket("team-a"), 46)
+
+	// the canary policy is cached for both actions
+	percentage := 30
+	canary := newPolicy("canary", "enforce", &percentage)
+	enforcePolicies := []*kyverno.ClusterPolicy{newPolicy("enforce", "enforce", nil), canary}
+	auditPolicies := []*kyverno.ClusterPolicy{newPolicy("audit", "audit", nil), canary}
+
+	assert.DeepEqual(t, names(filterByValidationFailureAction(enforcePolicies, "team-b", common.Enforce)), []string{"enforce", "canary"})
+	assert.DeepEqual(t, names(filterByValidationFailureAction(auditPolicies, "team-b", common.Audit)), []string{"audit"})
+	assert.DeepEqual(t, names(filterByValidationFailureAction(enforcePolicies, "team-a", common.Enforce)), []string{"enforce"})
+	assert.DeepEqual(t, names(filterByValidationFailureAction(auditPolicies, "team-a", common.Audit)), []string{"audit", "canary"})
+
+	// the percentage applies to the next request, 0 audits and 100 enforces all namespaces
+	for _, namespace := range []string{"team-a", "team-b", "default", ""} {
+		percentage = 0
+		assert.Equal(t, canary.GetValidationFailureActionForNamespace(namespace), "audit", namespace)
+		assert.DeepEqual(t, names(filterByValidationFailureAction(enforcePolicies, namespace, common.Enforce)), []string{"enforce"})
+		assert.DeepEqual(t, names(filterByValidationFailureAction(auditPolicies, namespace, common.Audit)), []string{"audit", "canary"})
+
+		percentage = 100
+		assert.Equal(t, canary.GetValidationFailureActionForNamespace(namespace), "enforce", namespace)
+		assert.DeepEqual(t, names(filterByValidationFailureAction(enforcePolicies, namespace, common.Enforce)), []string{"enforce", "canary"})
+		assert.DeepEqual(t, names(filterByValidationFailureAction(auditPolicies, namespace, common.Audit)), []string{"audit"})
+	}
+}
+
+func Test_RuleDocumentation_Deny_Message_And_Events(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team-label","id":"SEC-014","documentationURL":"https://controls.example.com/SEC-014","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	er := engine.Validate(&engine.PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Assert(t, !er.IsSuccessful())
+
+	// the deny message
+	msg := getEnforceFailureErrorMsg([]*response.EngineResponse{er}, nil)
+	assert.Equal(t, denyMessages(t, msg)["require-team"]["check-team-label"], "validation error: label 'team' is required. Rule check-team-label failed at path /metadata/labels/team/ (SEC-014, see https://controls.example.com/SEC-014)")
+
+	// the events on the policy and the resource
+	events := generateEvents([]*response.EngineResponse{er}, true, false, log.Log)
+	assert.Equal(t, len(events), 2)
+	for _, e := range events {
+		assert.Equal(t, e.RuleIDs, "SEC-014")
+		assert.Equal(t, e.DocumentationURLs, "https://controls.example.com/SEC-014")
+	}
+}
+
+func Test_Violations_Causes_And_Events(t *testing.T) {
+	policyRaw := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-digest"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-digest","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"container {{element.name}} image {{element.image}} is not pinned to a digest","foreach":[{"list":"request.object.spec.containers","deny":{"conditions":{"any":[{"key":"{{element.image}}","operator":"NotEquals","value":"*@sha256:*"}]}}}]}}]}}`)
+
+	var policy kyverno.ClusterPolicy
+	assert.NilError(t, json.Unmarshal(policyRaw, &policy))
+
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	er := engine.Validate(&engine.PolicyContext{Policy: policy, NewResource: *resource, JSONContext: ctx})
+	assert.Assert(t, !er.IsSuccessful())
+
+	// the causes of the denied request
+	causes := violationCauses([]*response.EngineResponse{er})
+	assert.DeepEqual(t, causes, []metav1.StatusCause{{
+		Type:    violationCauseType,
+		Field:   "request.object.spec.containers[0]",
+		Message: "policy require-digest: rule check-digest: deny failed for element 0 (nginx) of request.object.spec.containers: container nginx image nginx:1.21 is not pinned to a digest",
+	}})
+
+	// the audit policies do not deny the request
+	er.PolicyResponse.ValidationFailureAction = common.Audit
+	assert.Equal(t, len(violationCauses([]*response.EngineResponse{er})), 0)
+
+	// the events on the policy and the resource
+	events := generateEvents([]*response.EngineResponse{er}, false, false, log.Log)
+	assert.Equal(t, len(events), 2)
+	for _, e := range events {
+		var violations []response.Violation
+		assert.NilError(t, json.Unmarshal([]byte(e.Violations), &violations))
+		assert.Equal(t, len(violations), 1)
+		assert.Equal(t, violations[0].ElementName, "nginx")
+		assert.Equal(t, violations[0].Message, "container nginx image nginx:1.21 is not pinned to a digest")
+	}
+}
+
+// fakeConfig returns the deny message template and the cluster name
+type fakeConfig struct {
+	config.Interface
+	denyMessageTemplate *config.DenyMessageTemplate
+	clusterName         string
+}
+
+func (f fakeConfig) GetDenyMessageTemplate() *config.DenyMessageTemplate {
+	return f.denyMessageTemplate
+}
+
+func (f fakeConfig) GetClusterName() string {
+	return f.clusterName
+}
+
+func (f fakeConfig) GetShadowEnforce() bool {
+	return false
+}
+
+func Test_DenyMessageTemplate(t *testing.T) {
+	template, err := config.ParseDenyMessageTemplate("[{{cluster.name}}] {{rule.message}} ({{policy.name}}/{{rule.name}} on {{resource.kind}} {{resource.namespace}}/{{resource.name}}), see https://tickets.example.com/{{cluster.name}}")
+	assert.NilError(t, err)
+
+	teamPolicy := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-team"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`)
+	ownerPolicy := []byte(`{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"require-owner","annotations":{"policies.kyverno.io/deny-message-template":"disabled"}},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-owner-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'owner' is required","pattern":{"metadata":{"labels":{"owner":"?*"}}}}}]}}`)
+	policies := []*kyverno.ClusterPolicy{
+		newFailurePolicyTestPolicy(t, teamPolicy, kyverno.Fail),
+		newFailurePolicyTestPolicy(t, ownerPolicy, kyverno.Fail),
+	}
+
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	request := &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Name:      "nginx",
+		Namespace: "test",
+		Operation: v1beta1.Create,
+	}
+
+	// handle returns the rule messages of the denied request by policy
+	handle := func(configHandler config.Interface) map[string]map[string]string {
+		ctx := context.NewContext()
+		assert.NilError(t, ctx.AddResource(podRaw))
+
+		policyContext := &engine.PolicyContext{NewResource: *resource, JSONContext: ctx}
+		v := &validationHandler{log: log.Log, eventGen: &fakeEventGen{}, prGenerator: &fakePRGenerator{}, configHandler: configHandler}
+		ok, msg, _, _ := v.handleValidation(metrics.NewPromConfig(), request, policies, policyContext, nil, 0)
+		assert.Assert(t, !ok)
+		return denyMessages(t, msg)
+	}
+
+	teamMessage := "validation error: label 'team' is required. Rule check-team-label failed at path /metadata/labels/team/"
+	ownerMessage := "validation error: label 'owner' is required. Rule check-owner-label failed at path /metadata/labels/owner/"
+
+	messages := handle(fakeConfig{denyMessageTemplate: template, clusterName: "prod-eu-1"})
+	assert.Equal(t, messages["require-team"]["check-team-label"], "[prod-eu-1] "+teamMessage+" (require-team/check-team-label on Pod test/nginx), see https://tickets.example.com/prod-eu-1")
+
+	// the policy opts out of the template
+	assert.Equal(t, messages["require-owner"]["check-owner-label"], ownerMessage)
+
+	// no template
+	messages = handle(fakeConfig{clusterName: "prod-eu-1"})
+	assert.Equal(t, messages["require-team"]["check-team-label"], teamMessage)
+	assert.Equal(t, messages["require-owner"]["check-owner-label"], ownerMessage)
+}
+
+func Test_Events_NamespacedPolicy(t *testing.T) {
+	// the Policies returned by the listers have an empty TypeMeta
+	var nsPolicy kyverno.Policy
+	assert.NilError(t, json.Unmarshal([]byte(`{"metadata":{"name":"require-team-label","namespace":"test"},"spec":{"validationFailureAction":"enforce","rules":[{"name":"check-team-label","match":{"resources":{"kinds":["Pod"]}},"validate":{"message":"label 'team' is required","pattern":{"metadata":{"labels":{"team":"?*"}}}}}]}}`), &nsPolicy))
+	policy := policy2.ConvertPolicyToClusterPolicy(&nsPolicy)
+
+	resource, err := utils.ConvertToUnstructured(podRaw)
+	assert.NilError(t, err)
+
+	ctx := context.NewContext()
+	assert.NilError(t, ctx.AddResource(podRaw))
+
+	er := engine.Validate(&engine.PolicyContext{Policy: *policy, NewResource: *resource, JSONContext: ctx})
+	assert.Equal(t, er.PolicyResponse.Policy.Namespace, "test")
+
+	events := generateEvents([]*response.EngineResponse{er}, true, false, log.Log)
+	assert.Assert(t, len(events) > 0)
+
+	policyEvents := 0
+	for _, e := range events {
+		if e.Name != "require-team-label" {
+			continue
+		}
+
+		policyEvents++
+		assert.Equal(t, e.Kind, "Policy")
+		assert.Equal(t, e.Namespace, "test")
+	}
+	assert.Assert(t, policyEvents > 0)
+}
@@ -29,7 +29,7 @@ func (ws *WebhookServer) handleVerifyImages(request *v1beta1.AdmissionRequest,
 	}
 
 	resourceName := getResourceName(request)
-	logger := ws.log.WithValues("action", "verifyImages", "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
+	logger := ws.log.WithValues("action", "verifyImages", "correlationID", correlationID(request), "resource", resourceName, "operation", request.Operation, "gvk", request.Kind.String())
 
 	var engineResponses []*response.EngineResponse
 	var patches [][]byte
@@ -37,13 +37,14 @@ func (ws *WebhookServer) handleVerifyImages(request *v1beta1.AdmissionRequest,
 		policyContext.Policy = *p
 		resp := engine.VerifyAndPatchImages(policyContext)
 		engineResponses = append(engineResponses, resp)
+		countSkippedDeleting(ws.promConfig, request, resp)
 		patches = append(patches, resp.GetPatches()...)
 	}
 
 	blocked := toBlockResource(engineResponses, logger)
 	if blocked {
 		logger.V(4).Info("resource blocked")
-		return false, getEnforceFailureErrorMsg(engineResponses), nil
+		return false, getEnforceFailureErrorMsg(engineResponses, newDenyMessageTemplate(ws.configHandler, policies)), nil
 	}
 
 	return true, "", engineutils.JoinPatches(patches)
@@ -32,4 +32,4 @@ certsGenerator="./scripts/generate-self-signed-cert-and-k8secrets-debug.sh"
 chmod +x "${certsGenerator}"
 
 ${certsGenerator} "--service=${service}" "--serverIP=${serverIP}" || exit 2
-echo -e "\n### You can build and run kyverno project locally.\n### To check its work, run it with flags --kubeconfig and --serverIP parameters."
+echo -e "\n### You can build and run kyverno project locally.\n### To check its work, run it with flags --kubeconfig, --serverIP and --devMode parameters."